@@ -0,0 +1,198 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/majorcontext/moat/internal/run"
+	"github.com/majorcontext/moat/internal/storage"
+	"github.com/majorcontext/moat/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var (
+	actionsCommands bool
+	actionsAPI      bool
+)
+
+var actionsCmd = &cobra.Command{
+	Use:   "actions [run]",
+	Short: "Summarize the commands and API requests of a run",
+	Long: `Show what an agent did during a run, in chronological order: the commands
+it ran, the files those commands wrote, and the API requests it made through
+the proxy, including requests the proxy denied. A summary follows the log.
+Accepts a run ID or name. If no argument is specified, uses the most recent run.
+
+Commands come from the run's exec trace and from 'moat exec'. Files are
+inferred from the arguments of common file commands (cp, mv, rm, touch,
+sed -i, git add, ...); writes a program makes on its own are not listed.
+
+Examples:
+  moat actions                 # Actions from most recent run
+  moat actions my-agent        # Actions from run by name
+  moat actions --api           # Only API requests
+  moat actions --commands      # Only commands
+  moat actions --json          # Actions and summary as JSON`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runActions,
+}
+
+func init() {
+	rootCmd.AddCommand(actionsCmd)
+	actionsCmd.Flags().BoolVar(&actionsCommands, "commands", false, "only show commands")
+	actionsCmd.Flags().BoolVar(&actionsAPI, "api", false, "only show API requests")
+}
+
+func runActions(_ *cobra.Command, args []string) error {
+	if actionsCommands && actionsAPI {
+		return fmt.Errorf("--commands and --api are mutually exclusive")
+	}
+
+	baseDir := storage.DefaultBaseDir()
+	var runID string
+	if len(args) > 0 {
+		manager, err := run.NewManager()
+		if err != nil {
+			return fmt.Errorf("creating run manager: %w", err)
+		}
+		defer manager.Close()
+
+		runID, err = resolveRunArgSingle(manager, args[0])
+		if err != nil {
+			return err
+		}
+	} else {
+		var err error
+		runID, err = findLatestRun(baseDir)
+		if err != nil {
+			return err
+		}
+	}
+
+	store, err := storage.NewRunStore(baseDir, runID)
+	if err != nil {
+		return fmt.Errorf("opening run storage: %w", err)
+	}
+	actions, err := run.ActionLog(store)
+	if err != nil {
+		return err
+	}
+	actions = filterActions(actions)
+	summary := run.SummarizeActions(actions)
+
+	if jsonOut {
+		data, _ := json.MarshalIndent(struct {
+			RunID   string            `json:"run_id"`
+			Actions []run.Action      `json:"actions"`
+			Summary run.ActionSummary `json:"summary"`
+		}{runID, actions, summary}, "", "  ")
+		fmt.Println(string(data))
+		return nil
+	}
+
+	if len(actions) == 0 {
+		fmt.Println("No actions recorded")
+		return nil
+	}
+	for _, a := range actions {
+		fmt.Println(formatAction(a))
+	}
+	fmt.Println()
+	printActionSummary(summary)
+	return nil
+}
+
+func filterActions(actions []run.Action) []run.Action {
+	if !actionsCommands && !actionsAPI {
+		return actions
+	}
+	kind := run.ActionCommand
+	if actionsAPI {
+		kind = run.ActionAPI
+	}
+	var out []run.Action
+	for _, a := range actions {
+		if a.Kind == kind {
+			out = append(out, a)
+		}
+	}
+	return out
+}
+
+// formatAction renders one action as a line (plus a files line for commands
+// that wrote files).
+func formatAction(a run.Action) string {
+	ts := ui.Dim("[" + a.Time.Format("15:04:05.000") + "]")
+	if a.Kind == run.ActionAPI {
+		method := a.Method
+		if method == "" {
+			method = "-"
+		}
+		line := fmt.Sprintf("%s %-7s %s%s", ts, method, a.Host, a.Path)
+		switch {
+		case a.Denied:
+			line += " " + ui.Red("DENIED")
+			if a.Reason != "" {
+				line += ": " + a.Reason
+			}
+		case a.Error != "":
+			line += " " + ui.Red("ERR") + " " + a.Error
+		default:
+			line += " " + strconv.Itoa(a.Status)
+		}
+		if len(a.Grants) > 0 {
+			line += ui.Dim("  grant=" + strings.Join(a.Grants, ","))
+		}
+		return line
+	}
+
+	line := fmt.Sprintf("%s $ %s", ts, strings.Join(a.Command, " "))
+	var notes []string
+	if a.ExitCode != nil {
+		notes = append(notes, "exit "+strconv.Itoa(*a.ExitCode))
+	}
+	if a.DurationMS > 0 {
+		notes = append(notes, strconv.FormatInt(a.DurationMS, 10)+"ms")
+	}
+	if a.Source == "moat exec" {
+		notes = append(notes, "moat exec")
+	}
+	if len(notes) > 0 {
+		note := "(" + strings.Join(notes, ", ") + ")"
+		if a.ExitCode != nil && *a.ExitCode != 0 {
+			note = ui.Red(note)
+		} else {
+			note = ui.Dim(note)
+		}
+		line += "  " + note
+	}
+	if len(a.Files) > 0 {
+		line += "\n" + strings.Repeat(" ", 16) + ui.Dim("files: "+strings.Join(a.Files, " "))
+	}
+	return line
+}
+
+func printActionSummary(s run.ActionSummary) {
+	fmt.Println(ui.Bold("Summary"))
+	fmt.Printf("  Commands:     %d", s.Commands)
+	if s.FailedCommands > 0 {
+		fmt.Printf(" (%d failed)", s.FailedCommands)
+	}
+	fmt.Println()
+	fmt.Printf("  API requests: %d", s.APIRequests)
+	if s.DeniedRequests > 0 {
+		fmt.Printf(" (%d denied)", s.DeniedRequests)
+	}
+	fmt.Println()
+	if len(s.Hosts) > 0 {
+		fmt.Printf("  Hosts:        %s\n", strings.Join(s.Hosts, ", "))
+	}
+	if len(s.Files) > 0 {
+		fmt.Println("  Files touched:")
+		for _, f := range s.Files {
+			fmt.Printf("    %s\n", f)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/majorcontext/moat/internal/log"
+	"github.com/majorcontext/moat/internal/run"
+	"github.com/majorcontext/moat/internal/ui"
+)
+
+// runAPIErrors summarizes the LLM API errors in r's network log. ok is false
+// when the run has no stored network log or it cannot be read.
+func runAPIErrors(r *run.Run) (s run.APIErrorSummary, ok bool) {
+	if r.Store == nil {
+		return s, false
+	}
+	reqs, err := r.Store.ReadNetworkRequests()
+	if err != nil {
+		log.Debug("reading network log for API errors", "runID", r.ID, "error", err)
+		return s, false
+	}
+	return run.SummarizeAPIErrors(reqs), true
+}
+
+// printAPIErrorSummary prints the upstream API errors a run saw at the end of
+// the run, so throttling is not mistaken for a stuck agent.
+func printAPIErrorSummary(r *run.Run) {
+	if ui.Quiet() {
+		return
+	}
+	s, ok := runAPIErrors(r)
+	if !ok || s.Errors() == 0 {
+		return
+	}
+	fmt.Println()
+	ui.Warnf("LLM API errors during this run: %s (of %d %s)", s, s.Requests, plural(s.Requests, "request", "requests"))
+	if hint := run.APIErrorHint(s); hint != "" {
+		ui.Info(hint)
+	}
+}
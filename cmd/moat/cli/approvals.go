@@ -0,0 +1,93 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/majorcontext/moat/internal/daemon"
+	"github.com/majorcontext/moat/internal/term"
+	"github.com/spf13/cobra"
+)
+
+var (
+	approvalsAllow bool
+	approvalsDeny  bool
+)
+
+var approvalsCmd = &cobra.Command{
+	Use:   "approvals [run]",
+	Short: "List and answer runs' approval requests",
+	Long: `List the approval requests runs are waiting on, like 'moat network
+approvals'. Accepts a run ID or name to show only that run's requests.
+
+When a request waits without an answer, the proxy daemon shows a desktop
+notification naming 'moat approvals respond <id>'. Turn notifications off
+with notifications.approvals: false in ~/.moat/config.yaml or
+MOAT_NOTIFICATIONS=0.
+
+Examples:
+  moat approvals                       # Requests from all active runs
+  moat approvals respond apr_1a2b3c    # Review a request and answer it
+  moat approvals respond apr_1a2b3c --allow`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runNetworkApprovals,
+}
+
+var approvalsRespondCmd = &cobra.Command{
+	Use:   "respond <id>",
+	Short: "Answer a run's approval request",
+	Long: `Show a pending approval request and ask whether to allow it. Answering
+anything but yes denies it. With --allow or --deny, answers without asking.
+
+An allowed host is added to the run's network rules for the rest of the
+run, as with 'moat network approve'.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runApprovalsRespond,
+}
+
+func init() {
+	approvalsRespondCmd.Flags().BoolVar(&approvalsAllow, "allow", false, "allow the request without asking")
+	approvalsRespondCmd.Flags().BoolVar(&approvalsDeny, "deny", false, "deny the request without asking")
+	approvalsRespondCmd.MarkFlagsMutuallyExclusive("allow", "deny")
+	approvalsCmd.AddCommand(approvalsRespondCmd)
+	rootCmd.AddCommand(approvalsCmd)
+}
+
+func runApprovalsRespond(_ *cobra.Command, args []string) error {
+	approvalID := args[0]
+	if approvalsAllow || approvalsDeny {
+		return decideApproval(approvalID, approvalsAllow)
+	}
+	if !term.IsTerminal(os.Stdin) {
+		return fmt.Errorf("no terminal to ask on; pass --allow or --deny")
+	}
+
+	approvals, err := approvalsClient().ListApprovals(context.Background(), "")
+	if err != nil {
+		return fmt.Errorf("listing approvals: %w", err)
+	}
+	var a *daemon.Approval
+	for i := range approvals {
+		if approvals[i].ID == approvalID {
+			a = &approvals[i]
+		}
+	}
+	switch {
+	case a == nil:
+		return fmt.Errorf("no network approval %s; run 'moat approvals' to list them", approvalID)
+	case a.Status != daemon.ApprovalPending:
+		return fmt.Errorf("network approval %s was already %s", approvalID, a.Status)
+	}
+
+	fmt.Fprintf(os.Stderr, "Run %s asks to reach %s (requested %s)\n", a.RunID, a.Host, formatAge(a.RequestedAt))
+	if a.Reason != "" {
+		fmt.Fprintf(os.Stderr, "Reason: %s\n", a.Reason)
+	}
+	fmt.Fprintf(os.Stderr, "Allow %s? [y/N]: ", a.Host)
+	line, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	ans := strings.ToLower(strings.TrimSpace(line))
+	return decideApproval(approvalID, ans == "y" || ans == "yes")
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/majorcontext/moat/internal/audit"
 	"github.com/majorcontext/moat/internal/run"
@@ -209,6 +210,14 @@ func formatEntryData(e *audit.Entry) string {
 		status, _ := data["status_code"].(float64)
 		return fmt.Sprintf("%s %s → %d", method, url, int(status))
 
+	case audit.EntryMessage:
+		grant, _ := data["grant"].(string)
+		decision, _ := data["decision"].(string)
+		url, _ := data["url"].(string)
+		sent, _ := data["sent"].(float64)
+		limit, _ := data["limit"].(float64)
+		return fmt.Sprintf("%s %s %s (%d/%d sent)", grant, decision, url, int(sent), int(limit))
+
 	case audit.EntryCredential:
 		name, _ := data["name"].(string)
 		action, _ := data["action"].(string)
@@ -223,6 +232,26 @@ func formatEntryData(e *audit.Entry) string {
 		}
 		return ""
 
+	case audit.EntryEnv:
+		action, _ := data["action"].(string)
+		names, _ := data["names"].([]any)
+		parts := make([]string, 0, len(names))
+		for _, n := range names {
+			if name, ok := n.(string); ok {
+				parts = append(parts, name)
+			}
+		}
+		return action + " " + strings.Join(parts, ", ")
+
+	case audit.EntryCtl:
+		command, _ := data["command"].(string)
+		detail, _ := data["detail"].(string)
+		result, _ := data["result"].(string)
+		if detail != "" {
+			return fmt.Sprintf("%s %s → %s", command, detail, result)
+		}
+		return fmt.Sprintf("%s → %s", command, result)
+
 	case audit.EntryConsole:
 		line, _ := data["line"].(string)
 		if len(line) > 80 {
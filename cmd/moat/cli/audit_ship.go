@@ -0,0 +1,42 @@
+package cli
+
+import (
+	"context"
+	"os"
+
+	"github.com/majorcontext/moat/internal/audit"
+	"github.com/majorcontext/moat/internal/config"
+	"github.com/majorcontext/moat/internal/ui"
+)
+
+// startAuditShipping streams audit events to the collectors in the global
+// config and returns the function that flushes and stops shipping. A
+// collector that cannot be set up is reported and skipped; the local audit
+// log is written either way.
+func startAuditShipping(cfg *config.GlobalConfig) func(context.Context) error {
+	if cfg == nil {
+		return func(context.Context) error { return nil }
+	}
+	var sinks []audit.Sink
+	if s := cfg.Audit.Syslog; s != nil {
+		sink, err := audit.NewSyslogSink(s.Address, s.Tag)
+		if err != nil {
+			ui.Warnf("audit shipping to syslog disabled: %v", err)
+		} else {
+			sinks = append(sinks, sink)
+		}
+	}
+	if w := cfg.Audit.Webhook; w != nil {
+		headers := make(map[string]string, len(w.Headers))
+		for name, value := range w.Headers {
+			headers[name] = os.ExpandEnv(value)
+		}
+		sink, err := audit.NewWebhookSink(w.URL, headers)
+		if err != nil {
+			ui.Warnf("audit shipping to webhook disabled: %v", err)
+		} else {
+			sinks = append(sinks, sink)
+		}
+	}
+	return audit.StartShipping(sinks...)
+}
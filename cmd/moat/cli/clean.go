@@ -189,6 +189,9 @@ func cleanResources(cmd *cobra.Command, args []string) error {
 		if r.NetworkID != "" {
 			knownNetworkIDs[r.NetworkID] = true
 		}
+		if r.InternalNetworkID != "" {
+			knownNetworkIDs[r.InternalNetworkID] = true
+		}
 	}
 
 	var orphanedNetworks []runtimeNetwork
@@ -0,0 +1,293 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+	"unicode"
+
+	"github.com/majorcontext/moat/internal/clipboard"
+	"github.com/majorcontext/moat/internal/config"
+	"github.com/majorcontext/moat/internal/daemon"
+	"github.com/majorcontext/moat/internal/run"
+	"github.com/majorcontext/moat/internal/storage"
+	"github.com/majorcontext/moat/internal/term"
+	"github.com/majorcontext/moat/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var (
+	clipSend   bool
+	clipFile   string
+	clipStdout bool
+	clipOutput string
+	clipYes    bool
+)
+
+var clipCmd = &cobra.Command{
+	Use:   "clip [run]",
+	Short: "Hand snippets and small files between a run and the host",
+	Long: `Hand snippets and small files between a run and the host.
+
+Inside the container, 'moatctl clip' offers stdin or a file to the host. On
+the host, 'moat clip' previews each offered clip and asks before copying it
+to the host clipboard (or, for files, saving it to the current directory).
+Rejected clips are discarded.
+
+With --send, 'moat clip' hands the host clipboard, stdin, or --file to the
+run instead; inside the container, 'moatctl paste' prints it.
+
+Every offer, decision, and hand-off is recorded in the run's audit log.
+Clips are limited to 1 MB. Accepts a run ID or name; defaults to the most
+recent run.
+
+Examples:
+  moat clip                            # Review clips from the latest run
+  moat clip my-agent --stdout > out.sql
+  moat clip my-agent --send            # Send the host clipboard
+  git diff | moat clip my-agent --send # Send stdin
+  moat clip my-agent --send --file notes.md`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runClip,
+}
+
+func init() {
+	rootCmd.AddCommand(clipCmd)
+	clipCmd.Flags().BoolVar(&clipSend, "send", false, "send the host clipboard, stdin, or --file to the run")
+	clipCmd.Flags().StringVar(&clipFile, "file", "", "with --send, send this file")
+	clipCmd.Flags().BoolVar(&clipStdout, "stdout", false, "write accepted clips to stdout instead of the clipboard")
+	clipCmd.Flags().StringVarP(&clipOutput, "output", "o", ".", "directory to save accepted files in")
+	clipCmd.Flags().BoolVarP(&clipYes, "yes", "y", false, "accept every clip without asking")
+}
+
+func runClip(_ *cobra.Command, args []string) error {
+	if clipFile != "" && !clipSend {
+		return fmt.Errorf("--file requires --send")
+	}
+
+	manager, err := run.NewManager()
+	if err != nil {
+		return fmt.Errorf("creating run manager: %w", err)
+	}
+	defer manager.Close()
+
+	var runID string
+	if len(args) > 0 {
+		runID, err = resolveRunArgSingle(manager, args[0])
+	} else {
+		runID, err = findLatestRun(storage.DefaultBaseDir())
+	}
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	client := daemon.NewClient(filepath.Join(config.GlobalConfigDir(), "proxy", "daemon.sock"))
+	health, err := client.Health(ctx)
+	if err != nil {
+		return fmt.Errorf("the proxy daemon is not running, so run %s has no clips", runID)
+	}
+	if !slices.Contains(health.Capabilities, daemon.CapClip) {
+		return fmt.Errorf("the running proxy daemon is too old for clips; run 'moat proxy restart' to upgrade it")
+	}
+
+	if clipSend {
+		return sendClip(ctx, client, runID)
+	}
+	return reviewClips(ctx, client, runID)
+}
+
+// sendClip hands --file, stdin, or the host clipboard to the run.
+func sendClip(ctx context.Context, client *daemon.Client, runID string) error {
+	clip := daemon.Clip{RunID: runID}
+	switch {
+	case clipFile != "":
+		data, err := os.ReadFile(clipFile)
+		if err != nil {
+			return err
+		}
+		clip.Name = filepath.Base(clipFile)
+		clip.Data = data
+	case !term.IsTerminal(os.Stdin):
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return fmt.Errorf("reading stdin: %w", err)
+		}
+		clip.Data = data
+	default:
+		content, err := clipboard.Read()
+		if err != nil {
+			return fmt.Errorf("reading host clipboard: %w", err)
+		}
+		if content == nil {
+			return fmt.Errorf("the host clipboard is empty")
+		}
+		clip.Data = content.Data
+	}
+
+	sent, err := client.SendClip(ctx, clip)
+	if errors.Is(err, daemon.ErrRunNotFound) {
+		return fmt.Errorf("run %s is not active in the proxy daemon", runID)
+	}
+	if err != nil {
+		return fmt.Errorf("sending clip: %w", err)
+	}
+	fmt.Printf("Sent %s to %s; paste it in the container with 'moatctl paste'\n", describeClip(sent, len(clip.Data)), runID)
+	return nil
+}
+
+// reviewClips previews each clip the run offered and delivers the ones the
+// user accepts.
+func reviewClips(ctx context.Context, client *daemon.Client, runID string) error {
+	clips, err := client.ListClips(ctx, runID)
+	if err != nil {
+		return fmt.Errorf("listing clips: %w", err)
+	}
+	if len(clips) == 0 {
+		fmt.Fprintf(os.Stderr, "No clips waiting from %s\n", runID)
+		return nil
+	}
+	if !clipYes && !term.IsTerminal(os.Stdin) {
+		return fmt.Errorf("%d clips need confirmation; run in a terminal or pass --yes", len(clips))
+	}
+
+	in := bufio.NewReader(os.Stdin)
+	var held <-chan struct{}
+	for _, clip := range clips {
+		printClipPreview(os.Stderr, clip)
+		prompt, done := clipDestination(clip)
+		if !clipYes && !confirmClip(in, prompt) {
+			if _, err := client.DecideClip(ctx, clip.ID, false); err != nil && !errors.Is(err, daemon.ErrClipNotFound) {
+				return err
+			}
+			fmt.Fprintln(os.Stderr, "Discarded")
+			continue
+		}
+		changed, err := deliverClip(clip)
+		if err != nil {
+			return fmt.Errorf("delivering %s: %w", clip.ID, err)
+		}
+		if changed != nil {
+			held = changed
+		}
+		if _, err := client.DecideClip(ctx, clip.ID, true); err != nil && !errors.Is(err, daemon.ErrClipNotFound) {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "%s %s\n", ui.OKTag(), done)
+	}
+
+	if held != nil && clipboard.HeldByProcess() {
+		// X11 serves the clipboard from this process, so keep it alive
+		// until the content is pasted and replaced, or the user is done.
+		ctx, stop := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
+		defer stop()
+		fmt.Fprintln(os.Stderr, "Holding the clipboard until something else is copied (Ctrl-C to release)")
+		select {
+		case <-held:
+		case <-ctx.Done():
+		}
+	}
+	return nil
+}
+
+// clipDestination returns the question asked before delivering a clip and
+// the message printed after.
+func clipDestination(clip daemon.Clip) (prompt, done string) {
+	switch {
+	case clipStdout:
+		return "Write to stdout?", "Written to stdout"
+	case clip.Name != "":
+		path := filepath.Join(clipOutput, clip.Name)
+		return "Save to " + path + "?", "Saved to " + path
+	default:
+		return "Copy to clipboard?", "Copied to clipboard"
+	}
+}
+
+// confirmClip asks a yes/no question, defaulting to no.
+func confirmClip(in *bufio.Reader, prompt string) bool {
+	fmt.Fprintf(os.Stderr, "%s [y/N]: ", prompt)
+	line, _ := in.ReadString('\n')
+	ans := strings.ToLower(strings.TrimSpace(line))
+	return ans == "y" || ans == "yes"
+}
+
+// deliverClip writes an accepted clip to stdout, a file, or the host
+// clipboard. For the clipboard it returns the channel that is closed when
+// the content is replaced.
+func deliverClip(clip daemon.Clip) (<-chan struct{}, error) {
+	switch {
+	case clipStdout:
+		_, err := os.Stdout.Write(clip.Data)
+		return nil, err
+	case clip.Name != "":
+		path := filepath.Join(clipOutput, clip.Name)
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := f.Write(clip.Data); err != nil {
+			f.Close()
+			return nil, err
+		}
+		return nil, f.Close()
+	default:
+		return clipboard.Write(&clipboard.Content{Data: clip.Data, MIMEType: clip.MIMEType})
+	}
+}
+
+// maxPreviewLines and maxPreviewWidth bound the text shown for a clip.
+const (
+	maxPreviewLines = 10
+	maxPreviewWidth = 120
+)
+
+// printClipPreview prints a clip's header and, for text, its first lines.
+func printClipPreview(w io.Writer, clip daemon.Clip) {
+	fmt.Fprintf(w, "\n%s %s\n", ui.Bold(clip.ID), ui.Dim(fmt.Sprintf("from %s, %s, %s",
+		clip.RunID, describeClip(clip, len(clip.Data)), formatAge(clip.CreatedAt))))
+	if !strings.HasPrefix(clip.MIMEType, "text/") {
+		return
+	}
+	lines := strings.Split(strings.TrimRight(string(clip.Data), "\n"), "\n")
+	for i, line := range lines {
+		if i == maxPreviewLines {
+			fmt.Fprintf(w, "  %s\n", ui.Dim(fmt.Sprintf("… %d more lines", len(lines)-i)))
+			break
+		}
+		line = stripControls(line)
+		if runes := []rune(line); len(runes) > maxPreviewWidth {
+			line = string(runes[:maxPreviewWidth]) + "…"
+		}
+		fmt.Fprintf(w, "  │ %s\n", line)
+	}
+}
+
+// stripControls replaces control characters other than tabs in text from
+// a container, so escape sequences (C0, DEL, and C1 controls such as CSI)
+// never reach the terminal.
+func stripControls(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) && r != '\t' {
+			return '·'
+		}
+		return r
+	}, s)
+}
+
+// describeClip summarizes a clip as its name or type and size.
+func describeClip(clip daemon.Clip, size int) string {
+	what := clip.Name
+	if what == "" {
+		what, _, _ = strings.Cut(clip.MIMEType, ";")
+	}
+	return fmt.Sprintf("%s, %s", stripControls(what), formatStatsBytes(uint64(size)))
+}
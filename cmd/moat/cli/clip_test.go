@@ -0,0 +1,64 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/majorcontext/moat/internal/daemon"
+)
+
+func TestPrintClipPreview(t *testing.T) {
+	var lines []string
+	for i := range 15 {
+		lines = append(lines, fmt.Sprintf("line %d", i))
+	}
+	lines[0] = "evil \x1b[2Jclear \u009b2J\x7f " + strings.Repeat("x", 200)
+	clip := daemon.Clip{
+		ID:        "clp_1",
+		RunID:     "run_1",
+		MIMEType:  "text/plain; charset=utf-8",
+		Data:      []byte(strings.Join(lines, "\n") + "\n"),
+		CreatedAt: time.Now(),
+	}
+
+	var buf bytes.Buffer
+	printClipPreview(&buf, clip)
+	out := buf.String()
+	if strings.ContainsAny(out, "\x1b\u009b\x7f") {
+		t.Error("preview passed a control character through")
+	}
+	if !strings.Contains(out, "line 9") || strings.Contains(out, "line 10") {
+		t.Errorf("preview should show the first %d lines:\n%s", maxPreviewLines, out)
+	}
+	if !strings.Contains(out, "… 5 more lines") {
+		t.Errorf("preview missing the remaining line count:\n%s", out)
+	}
+	if !strings.Contains(out, "text/plain,") {
+		t.Errorf("preview header missing the type:\n%s", out)
+	}
+
+	buf.Reset()
+	printClipPreview(&buf, daemon.Clip{ID: "clp_2", Name: "shot.png", MIMEType: "image/png", Data: make([]byte, 2048)})
+	if got := buf.String(); !strings.Contains(got, "shot.png, 2.0 KB") || strings.Contains(got, "│") {
+		t.Errorf("binary preview = %q", got)
+	}
+}
+
+func TestClipDestination(t *testing.T) {
+	defer func(out string, stdout bool) { clipOutput, clipStdout = out, stdout }(clipOutput, clipStdout)
+	clipOutput, clipStdout = "out", false
+
+	if prompt, _ := clipDestination(daemon.Clip{}); prompt != "Copy to clipboard?" {
+		t.Errorf("text clip prompt = %q", prompt)
+	}
+	if prompt, done := clipDestination(daemon.Clip{Name: "a.txt"}); prompt != "Save to out/a.txt?" || done != "Saved to out/a.txt" {
+		t.Errorf("file clip = %q, %q", prompt, done)
+	}
+	clipStdout = true
+	if prompt, _ := clipDestination(daemon.Clip{Name: "a.txt"}); prompt != "Write to stdout?" {
+		t.Errorf("--stdout prompt = %q", prompt)
+	}
+}
@@ -0,0 +1,444 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"slices"
+	"sort"
+	"sync"
+	"syscall"
+
+	"github.com/majorcontext/moat/internal/config"
+	"github.com/majorcontext/moat/internal/container"
+	"github.com/majorcontext/moat/internal/log"
+	"github.com/majorcontext/moat/internal/run"
+	"github.com/majorcontext/moat/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+// Labels that tie a run to its compose project and run name.
+const (
+	composeProjectLabel = "compose"
+	composeRunLabel     = "compose.run"
+)
+
+var (
+	composeFile    string
+	composeDetach  bool
+	composeRebuild bool
+)
+
+var composeCmd = &cobra.Command{
+	Use:   "compose",
+	Short: "Start and stop a set of runs from moat.compose.yaml",
+	Long: `Start and stop a set of named runs declared in moat.compose.yaml.
+
+Each run uses its workspace's moat.yaml, with the grants, command, env, and
+labels in the compose file taking precedence. The runs share a network on
+which each is reachable by its run name from the compose file, and are
+started in dependency order.`,
+}
+
+var composeUpCmd = &cobra.Command{
+	Use:   "up",
+	Short: "Start the runs in moat.compose.yaml",
+	Long: `Start the runs in moat.compose.yaml in dependency order.
+
+A run starts only after the runs in its depends_on have started. Runs of the
+project that are already running are left as they are.
+
+Without --detach, output from every run is streamed with the run name as a
+prefix, and Ctrl+C stops the project as 'moat compose down' does.
+
+Examples:
+  moat compose up
+  moat compose up -d
+  moat compose -f fleet/moat.compose.yaml up`,
+	Args: cobra.NoArgs,
+	RunE: composeUp,
+}
+
+var composeDownCmd = &cobra.Command{
+	Use:   "down",
+	Short: "Stop the runs in moat.compose.yaml",
+	Long: `Stop the project's runs in reverse dependency order and remove its
+shared network.`,
+	Args: cobra.NoArgs,
+	RunE: composeDown,
+}
+
+func init() {
+	rootCmd.AddCommand(composeCmd)
+	composeCmd.AddCommand(composeUpCmd, composeDownCmd)
+	composeCmd.PersistentFlags().StringVarP(&composeFile, "file", "f", config.ComposeFilename, "compose file")
+	composeUpCmd.Flags().BoolVarP(&composeDetach, "detach", "d", false, "start the runs and exit without streaming output")
+	composeUpCmd.Flags().BoolVar(&composeRebuild, "rebuild", false, "force rebuild of container images")
+}
+
+// loadComposeManager loads the compose file and a run manager for its
+// runtime. Shared networks need a runtime that can attach a container to a
+// second network, which Apple containers cannot.
+func loadComposeManager() (*config.Compose, *run.Manager, error) {
+	c, err := config.LoadCompose(composeFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	if c.Runtime != "" {
+		os.Setenv("MOAT_RUNTIME", c.Runtime)
+	}
+	manager, err := run.NewManagerWithOptions(run.ManagerOptions{ReapOrphanNetworks: true})
+	if err != nil {
+		return nil, nil, fmt.Errorf("creating run manager: %w", err)
+	}
+	if manager.RuntimeType() == string(container.RuntimeApple) {
+		manager.Close()
+		return nil, nil, fmt.Errorf("moat compose needs Docker or Podman; Apple containers cannot join a shared network")
+	}
+	return c, manager, nil
+}
+
+// composeRuns returns the project's running runs keyed by compose run name.
+func composeRuns(manager *run.Manager, project string) map[string]*run.Run {
+	runs := make(map[string]*run.Run)
+	for _, r := range manager.List() {
+		if r.Labels[composeProjectLabel] != project || r.GetState() != run.StateRunning {
+			continue
+		}
+		runs[r.Labels[composeRunLabel]] = r
+	}
+	return runs
+}
+
+// composeRunOptions builds the run options for the named compose run from
+// the compose file and the workspace's moat.yaml (cfg, which may be nil).
+func composeRunOptions(c *config.Compose, name string, cfg *config.Config, networkID string) (run.Options, error) {
+	spec := c.Runs[name]
+	opts := run.Options{
+		Name:      c.RunName(name),
+		Workspace: c.WorkspacePath(name),
+		Grants:    spec.Grants,
+		Cmd:       spec.Command,
+		Config:    cfg,
+		Rebuild:   composeRebuild,
+		Group:     c.Name,
+	}
+	if cfg != nil {
+		if len(opts.Grants) == 0 {
+			opts.Grants = cfg.Grants
+		}
+		if len(opts.Cmd) == 0 {
+			opts.Cmd = cfg.Command
+		}
+	}
+
+	var wsCfg config.WorkspaceConfig
+	if cfg != nil {
+		wsCfg = cfg.Workspace
+	}
+	wsMode, err := config.ResolveWorkspaceMode(wsCfg, "")
+	if err != nil {
+		return run.Options{}, err
+	}
+	opts.WorkspaceMode = wsMode
+
+	for _, k := range sortedKeys(spec.Env) {
+		opts.Env = append(opts.Env, k+"="+spec.Env[k])
+	}
+
+	var labelSpecs []string
+	for _, k := range sortedKeys(spec.Labels) {
+		if k == composeProjectLabel || k == composeRunLabel {
+			return run.Options{}, fmt.Errorf("run %q: label %q is set by moat compose", name, k)
+		}
+		labelSpecs = append(labelSpecs, k+"="+spec.Labels[k])
+	}
+	labelSpecs = append(labelSpecs, composeProjectLabel+"="+c.Name, composeRunLabel+"="+name)
+	if opts.Labels, err = run.ParseLabels(labelSpecs); err != nil {
+		return run.Options{}, fmt.Errorf("run %q: %w", name, err)
+	}
+
+	peers := make([]string, 0, len(c.Runs))
+	for peer := range c.Runs {
+		peers = append(peers, peer)
+	}
+	sort.Strings(peers)
+	opts.Network = &run.SharedNetwork{ID: networkID, Alias: name, Peers: peers}
+	return opts, nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// ensureComposeNetwork returns the ID of the project's shared network,
+// creating it if needed.
+func ensureComposeNetwork(ctx context.Context, manager *run.Manager, c *config.Compose) (string, error) {
+	netMgr, err := composeNetworkManager(manager)
+	if err != nil {
+		return "", err
+	}
+	networks, err := netMgr.ListNetworks(ctx)
+	if err != nil {
+		return "", err
+	}
+	for _, n := range networks {
+		if n.Name == c.NetworkName() {
+			return n.ID, nil
+		}
+	}
+	return netMgr.CreateNetwork(ctx, c.NetworkName())
+}
+
+func composeNetworkManager(manager *run.Manager) (container.NetworkManager, error) {
+	rt, err := manager.RuntimePool().Default()
+	if err != nil {
+		return nil, err
+	}
+	netMgr := rt.NetworkManager()
+	if netMgr == nil {
+		return nil, fmt.Errorf("moat compose needs a runtime with network support")
+	}
+	return netMgr, nil
+}
+
+func composeUp(cmd *cobra.Command, args []string) error {
+	c, manager, err := loadComposeManager()
+	if err != nil {
+		return err
+	}
+	defer manager.Close()
+
+	order, err := c.StartOrder()
+	if err != nil {
+		return err
+	}
+	if dryRun {
+		fmt.Printf("Dry run - would start %v\n", order)
+		return nil
+	}
+
+	ctx := context.Background()
+	networkID, err := ensureComposeNetwork(ctx, manager, c)
+	if err != nil {
+		return fmt.Errorf("creating shared network: %w", err)
+	}
+
+	// Runs are created one at a time from this process, so each one's
+	// routes are registered before the next run starts.
+	running := composeRuns(manager, c.Name)
+	for _, name := range order {
+		if r, ok := running[name]; ok {
+			ui.Infof("%s is already running (%s)", r.Name, r.ID)
+			continue
+		}
+		for _, dep := range c.Runs[name].DependsOn {
+			if running[dep].GetState() != run.StateRunning {
+				return fmt.Errorf("run %q: dependency %q is no longer running; see 'moat logs %s'\nRun 'moat compose down' to stop the runs that started",
+					name, dep, running[dep].ID)
+			}
+		}
+
+		ws := c.WorkspacePath(name)
+		cfg, err := config.Load(ws)
+		if err != nil {
+			return fmt.Errorf("run %q: loading config: %w", name, err)
+		}
+		opts, err := composeRunOptions(c, name, cfg, networkID)
+		if err != nil {
+			return err
+		}
+		r, err := manager.Create(ctx, opts)
+		if err != nil {
+			return fmt.Errorf("run %q: creating run: %w\nRun 'moat compose down' to stop the runs that started", name, err)
+		}
+		if err := manager.Start(ctx, r.ID); err != nil {
+			return fmt.Errorf("run %q: starting run: %w\nRun 'moat compose down' to stop the runs that started", name, err)
+		}
+		running[name] = r
+		fmt.Printf("Started %s (%s)\n", r.Name, r.ID)
+	}
+
+	if composeDetach {
+		ui.Status(ui.Dim(fmt.Sprintf("Stop with: moat compose -f %s down", composeFile)))
+		return nil
+	}
+	return followCompose(ctx, manager, c, order, running)
+}
+
+// followCompose streams the output of the project's runs until they all
+// exit, or stops them on Ctrl+C.
+func followCompose(ctx context.Context, manager *run.Manager, c *config.Compose, order []string, running map[string]*run.Run) error {
+	ui.Status(ui.Dim("Press Ctrl+C to stop"))
+
+	logCtx, logCancel := context.WithCancel(ctx)
+	defer logCancel()
+	var outMu sync.Mutex
+	width := 0
+	for _, name := range order {
+		width = max(width, len(name))
+	}
+
+	type exit struct {
+		name string
+		err  error
+	}
+	exits := make(chan exit, len(running))
+	for _, name := range order {
+		r := running[name]
+		w := &prefixWriter{mu: &outMu, w: os.Stdout, prefix: fmt.Sprintf("%-*s | ", width, name)}
+		go func() {
+			if err := manager.FollowLogs(logCtx, r.ID, w); err != nil && logCtx.Err() == nil {
+				log.Debug("log streaming ended", "run", r.ID, "error", err)
+			}
+			w.Flush()
+		}()
+		go func() {
+			exits <- exit{name, manager.Wait(ctx, r.ID)}
+		}()
+	}
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigCh)
+
+	var failed []string
+	for remaining := len(running); remaining > 0; {
+		select {
+		case <-sigCh:
+			logCancel()
+			ui.Status("\nStopping...")
+			return stopCompose(ctx, manager, c)
+		case e := <-exits:
+			remaining--
+			if e.err != nil {
+				failed = append(failed, e.name)
+				ui.Warnf("%s exited: %v", e.name, e.err)
+			}
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("runs failed: %v", failed)
+	}
+	return nil
+}
+
+func composeDown(cmd *cobra.Command, args []string) error {
+	c, manager, err := loadComposeManager()
+	if err != nil {
+		return err
+	}
+	defer manager.Close()
+
+	if dryRun {
+		for _, r := range composeStopOrder(c, composeRuns(manager, c.Name)) {
+			fmt.Printf("Dry run - would stop %s (%s)\n", r.Name, r.ID)
+		}
+		return nil
+	}
+	return stopCompose(context.Background(), manager, c)
+}
+
+// stopCompose stops the project's running runs in reverse dependency order
+// and removes its shared network.
+func stopCompose(ctx context.Context, manager *run.Manager, c *config.Compose) error {
+	var errs []error
+	for _, r := range composeStopOrder(c, composeRuns(manager, c.Name)) {
+		if err := manager.Stop(ctx, r.ID); err != nil {
+			errs = append(errs, fmt.Errorf("stopping %s: %w", r.Name, err))
+			continue
+		}
+		fmt.Printf("Stopped %s (%s)\n", r.Name, r.ID)
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
+	netMgr, err := composeNetworkManager(manager)
+	if err != nil {
+		return err
+	}
+	networks, err := netMgr.ListNetworks(ctx)
+	if err != nil {
+		return err
+	}
+	for _, n := range networks {
+		if n.Name == c.NetworkName() {
+			if err := netMgr.RemoveNetwork(ctx, n.ID); err != nil {
+				return fmt.Errorf("removing shared network: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// composeStopOrder returns running runs in reverse start order. Runs whose
+// name is no longer in the compose file are stopped first.
+func composeStopOrder(c *config.Compose, running map[string]*run.Run) []*run.Run {
+	order, _ := c.StartOrder()
+	var stale []string
+	for name := range running {
+		if !slices.Contains(order, name) {
+			stale = append(stale, name)
+		}
+	}
+	sort.Strings(stale)
+
+	var out []*run.Run
+	for _, name := range stale {
+		out = append(out, running[name])
+	}
+	for i := len(order) - 1; i >= 0; i-- {
+		if r, ok := running[order[i]]; ok {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// prefixWriter writes each complete line with a prefix, holding mu while it
+// writes so lines from several writers sharing w don't interleave.
+type prefixWriter struct {
+	mu     *sync.Mutex
+	w      io.Writer
+	prefix string
+	buf    []byte
+}
+
+func (p *prefixWriter) Write(b []byte) (int, error) {
+	p.buf = append(p.buf, b...)
+	for {
+		i := bytes.IndexByte(p.buf, '\n')
+		if i < 0 {
+			return len(b), nil
+		}
+		if err := p.writeLine(p.buf[:i+1]); err != nil {
+			return len(b), err
+		}
+		p.buf = p.buf[i+1:]
+	}
+}
+
+// Flush writes any partial last line.
+func (p *prefixWriter) Flush() {
+	if len(p.buf) > 0 {
+		_ = p.writeLine(append(p.buf, '\n'))
+		p.buf = nil
+	}
+}
+
+func (p *prefixWriter) writeLine(line []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	_, err := io.WriteString(p.w, p.prefix+string(line))
+	return err
+}
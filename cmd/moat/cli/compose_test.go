@@ -0,0 +1,97 @@
+package cli
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/majorcontext/moat/internal/config"
+	"github.com/majorcontext/moat/internal/run"
+)
+
+func testCompose() *config.Compose {
+	return &config.Compose{
+		Name: "fleet",
+		Dir:  "/src/fleet",
+		Runs: map[string]config.ComposeRun{
+			"api": {Workspace: "./api", Env: map[string]string{"B": "2", "A": "1"}},
+			"web": {Grants: []string{"npm"}, Command: []string{"npm", "start"}, Labels: map[string]string{"team": "web"}, DependsOn: []string{"api"}},
+		},
+	}
+}
+
+func TestComposeRunOptions(t *testing.T) {
+	c := testCompose()
+	cfg := &config.Config{Grants: []string{"github"}, Command: []string{"make", "serve"}}
+
+	opts, err := composeRunOptions(c, "api", cfg, "net123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.Name != "fleet-api" || opts.Workspace != "/src/fleet/api" {
+		t.Errorf("Name = %q, Workspace = %q", opts.Name, opts.Workspace)
+	}
+	if !slices.Equal(opts.Grants, []string{"github"}) || !slices.Equal(opts.Cmd, []string{"make", "serve"}) {
+		t.Errorf("moat.yaml defaults not applied: grants %v, cmd %v", opts.Grants, opts.Cmd)
+	}
+	if !slices.Equal(opts.Env, []string{"A=1", "B=2"}) {
+		t.Errorf("Env = %v", opts.Env)
+	}
+	if opts.Labels[composeProjectLabel] != "fleet" || opts.Labels[composeRunLabel] != "api" {
+		t.Errorf("Labels = %v", opts.Labels)
+	}
+	if n := opts.Network; n == nil || n.ID != "net123" || n.Alias != "api" || !slices.Equal(n.Peers, []string{"api", "web"}) {
+		t.Errorf("Network = %+v", opts.Network)
+	}
+
+	opts, err = composeRunOptions(c, "web", cfg, "net123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(opts.Grants, []string{"npm"}) || !slices.Equal(opts.Cmd, []string{"npm", "start"}) {
+		t.Errorf("compose overrides not applied: grants %v, cmd %v", opts.Grants, opts.Cmd)
+	}
+	if opts.Labels["team"] != "web" {
+		t.Errorf("Labels = %v", opts.Labels)
+	}
+
+	c.Runs["web"] = config.ComposeRun{Labels: map[string]string{composeProjectLabel: "other"}}
+	if _, err := composeRunOptions(c, "web", nil, "net123"); err == nil {
+		t.Error("expected error for a run overriding the compose label")
+	}
+}
+
+func TestComposeStopOrder(t *testing.T) {
+	c := testCompose()
+	running := map[string]*run.Run{
+		"api": {ID: "run_1", Name: "fleet-api"},
+		"web": {ID: "run_2", Name: "fleet-web"},
+		"old": {ID: "run_3", Name: "fleet-old"},
+	}
+	var got []string
+	for _, r := range composeStopOrder(c, running) {
+		got = append(got, r.ID)
+	}
+	if want := []string{"run_3", "run_2", "run_1"}; !slices.Equal(got, want) {
+		t.Errorf("stop order = %v, want %v (removed runs, then reverse dependency order)", got, want)
+	}
+}
+
+func TestPrefixWriter(t *testing.T) {
+	var out bytes.Buffer
+	var mu sync.Mutex
+	w := &prefixWriter{mu: &mu, w: &out, prefix: "api | "}
+	w.Write([]byte("listen"))
+	w.Write([]byte("ing on :3000\nready\npart"))
+	w.Flush()
+
+	want := "api | listening on :3000\napi | ready\napi | part\n"
+	if out.String() != want {
+		t.Errorf("output = %q, want %q", out.String(), want)
+	}
+	if strings.Count(out.String(), "api | ") != 3 {
+		t.Error("partial writes were prefixed more than once")
+	}
+}
@@ -0,0 +1,212 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/majorcontext/moat/internal/log"
+	"github.com/majorcontext/moat/internal/metering"
+	"github.com/majorcontext/moat/internal/storage"
+	"github.com/majorcontext/moat/internal/worktree"
+	"github.com/spf13/cobra"
+)
+
+var (
+	costGroupBy []string
+	costSince   string
+	costUntil   string
+	costFormat  string
+	costPrices  string
+	costOutput  string
+)
+
+var costCmd = &cobra.Command{
+	Use:   "cost",
+	Short: "Report LLM spend from local run records",
+	Long: `Report LLM spend from local run records.
+
+The proxy meters token usage on responses from the Anthropic and OpenAI APIs
+and records it in each run's usage.jsonl. Reports are built from those records
+and the run metadata only; nothing leaves the machine.`,
+}
+
+var costExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export LLM spend grouped by label, agent, or repo",
+	Long: `Export LLM token usage and cost, grouped by run attributes, as CSV or JSON.
+
+Group-by keys (repeat the flag or separate with commas):
+  agent         Agent of the run (claude, codex, ...)
+  repo          Git repository of the workspace (host/owner/repo)
+  name          Run name
+  group         Run group (moat run --group)
+  run           Run ID
+  model         Model reported by the provider
+  label:<key>   Value of a run label, e.g. label:team
+
+--since and --until take a date (YYYY-MM-DD, UTC) or an RFC 3339 timestamp.
+A date for --until includes that whole day.
+
+Costs use the providers' published list prices. Pass --prices with a YAML
+file mapping model prefixes to USD per million tokens to apply negotiated
+rates:
+
+  claude-sonnet-4: {input: 2.4, output: 12, cache_read: 0.24, cache_write: 3}
+
+Examples:
+  moat cost export --group-by label:team --since 2024-01-01
+  moat cost export --group-by agent,repo --since 2024-01-01 --until 2024-03-31 -o q1.csv
+  moat cost export --group-by model --format json`,
+	Args: cobra.NoArgs,
+	RunE: runCostExport,
+}
+
+func init() {
+	rootCmd.AddCommand(costCmd)
+	costCmd.AddCommand(costExportCmd)
+	costExportCmd.Flags().StringSliceVar(&costGroupBy, "group-by", []string{metering.GroupAgent}, "group by run, name, group, agent, repo, model, or label:<key>")
+	costExportCmd.Flags().StringVar(&costSince, "since", "", "include usage at or after this date or time")
+	costExportCmd.Flags().StringVar(&costUntil, "until", "", "include usage up to this date (inclusive) or before this time")
+	costExportCmd.Flags().StringVar(&costFormat, "format", "csv", "output format: csv or json")
+	costExportCmd.Flags().StringVar(&costPrices, "prices", "", "YAML file of per-model prices overriding the defaults")
+	costExportCmd.Flags().StringVarP(&costOutput, "output", "o", "", "write the report to a file instead of stdout")
+}
+
+func runCostExport(cmd *cobra.Command, args []string) error {
+	groupBy, err := metering.ParseGroupBy(costGroupBy)
+	if err != nil {
+		return err
+	}
+	if len(groupBy) == 0 {
+		groupBy = []string{metering.GroupAgent}
+	}
+	format := costFormat
+	if jsonOut {
+		format = "json"
+	}
+	if format != "csv" && format != "json" {
+		return fmt.Errorf("invalid --format %q: use csv or json", format)
+	}
+	since, err := parseCostTime(costSince, false)
+	if err != nil {
+		return fmt.Errorf("invalid --since: %w", err)
+	}
+	until, err := parseCostTime(costUntil, true)
+	if err != nil {
+		return fmt.Errorf("invalid --until: %w", err)
+	}
+
+	prices := metering.DefaultPrices()
+	if costPrices != "" {
+		if prices, err = metering.LoadPrices(costPrices); err != nil {
+			return err
+		}
+	}
+
+	runs, err := loadRunUsage(storage.DefaultBaseDir())
+	if err != nil {
+		return err
+	}
+	rep := metering.BuildReport(runs, groupBy, since, until, prices)
+
+	var w io.Writer = os.Stdout
+	if costOutput != "" {
+		f, createErr := os.Create(costOutput)
+		if createErr != nil {
+			return fmt.Errorf("creating output file: %w", createErr)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	if format == "json" {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(rep)
+	}
+	return metering.WriteCSV(w, rep)
+}
+
+// parseCostTime parses a YYYY-MM-DD date (UTC) or an RFC 3339 timestamp. An
+// empty value is the zero time. With endOfDay, a date means the start of the
+// following day, so the window includes the whole date.
+func parseCostTime(s string, endOfDay bool) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.DateOnly, s); err == nil {
+		if endOfDay {
+			t = t.AddDate(0, 0, 1)
+		}
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is not a date (YYYY-MM-DD) or RFC 3339 time", s)
+	}
+	return t, nil
+}
+
+// loadRunUsage reads the usage records and metadata of every run under
+// baseDir that recorded usage.
+func loadRunUsage(baseDir string) ([]metering.RunUsage, error) {
+	runIDs, err := storage.ListRunDirs(baseDir)
+	if err != nil {
+		return nil, fmt.Errorf("listing runs: %w", err)
+	}
+	repoIDs := map[string]string{} // workspace -> repo ID
+	var runs []metering.RunUsage
+	for _, id := range runIDs {
+		store, storeErr := storage.NewRunStore(baseDir, id)
+		if storeErr != nil {
+			log.Debug("skipping run", "run_id", id, "error", storeErr)
+			continue
+		}
+		usage, readErr := store.ReadUsage()
+		if readErr != nil {
+			log.Debug("skipping run usage", "run_id", id, "error", readErr)
+			continue
+		}
+		if len(usage) == 0 {
+			continue
+		}
+		meta, metaErr := store.LoadMetadata()
+		if metaErr != nil {
+			log.Debug("skipping run metadata", "run_id", id, "error", metaErr)
+			continue
+		}
+		repo := meta.WorktreeRepoID
+		if repo == "" && meta.Workspace != "" {
+			var ok bool
+			if repo, ok = repoIDs[meta.Workspace]; !ok {
+				repo = workspaceRepoID(meta.Workspace)
+				repoIDs[meta.Workspace] = repo
+			}
+		}
+		runs = append(runs, metering.RunUsage{
+			RunID:  id,
+			Name:   meta.Name,
+			Agent:  meta.Agent,
+			Repo:   repo,
+			Group:  meta.Group,
+			Labels: meta.Labels,
+			Usage:  usage,
+		})
+	}
+	return runs, nil
+}
+
+// workspaceRepoID returns the repo ID of the git repository containing
+// workspace, or _local/<dirname> if it is not (or no longer) a repository.
+func workspaceRepoID(workspace string) string {
+	if root, err := worktree.FindRepoRoot(workspace); err == nil {
+		if id, err := worktree.ResolveRepoID(root); err == nil {
+			return id
+		}
+	}
+	return "_local/" + filepath.Base(workspace)
+}
@@ -6,6 +6,7 @@ import (
 	"os/signal"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"sync"
 	"syscall"
 	"time"
@@ -15,8 +16,11 @@ import (
 	"github.com/majorcontext/moat/internal/config"
 	"github.com/majorcontext/moat/internal/daemon"
 	"github.com/majorcontext/moat/internal/log"
+	"github.com/majorcontext/moat/internal/metering"
+	"github.com/majorcontext/moat/internal/notify"
 	"github.com/majorcontext/moat/internal/routing"
 	"github.com/majorcontext/moat/internal/storage"
+	"github.com/majorcontext/moat/internal/telemetry"
 	"github.com/spf13/cobra"
 )
 
@@ -78,75 +82,155 @@ func runDaemon(_ *cobra.Command, _ []string) error {
 	var storeMu sync.Mutex
 	stores := make(map[string]*storage.RunStore)
 	baseDir := storage.DefaultBaseDir()
+	mirror := daemon.NewMirror()
+	recorder := daemon.NewEgressRecorder()
+	runStore := func(runID string) *storage.RunStore {
+		storeMu.Lock()
+		defer storeMu.Unlock()
+		if store, ok := stores[runID]; ok {
+			return store
+		}
+		store, err := storage.NewRunStore(baseDir, runID)
+		if err != nil {
+			log.Warn("failed to open run store",
+				"run_id", runID, "error", err)
+			return nil
+		}
+		stores[runID] = store
+		return store
+	}
+
+	// Policy decisions and messaging sends are recorded in per-run audit
+	// stores, opened on first use. The run's own process appends to the
+	// same store; each append chains onto the last entry either wrote.
+	var auditMu sync.Mutex
+	auditStores := make(map[string]*audit.Store)
+	auditStore := func(runID string) *audit.Store {
+		auditMu.Lock()
+		defer auditMu.Unlock()
+		if as, ok := auditStores[runID]; ok {
+			return as
+		}
+		as, err := audit.OpenStore(filepath.Join(baseDir, runID, "audit.db"))
+		if err != nil {
+			log.Warn("failed to open audit store",
+				"run_id", runID, "error", err)
+			return nil
+		}
+		auditStores[runID] = as
+		return as
+	}
 
-	p.SetLogger(func(data proxy.RequestLogData) {
+	// Requests the front refuses are logged the same way as the proxy's.
+	logRequest := func(data proxy.RequestLogData) {
 		if data.RunID == "" {
 			return
 		}
 
-		storeMu.Lock()
-		store, ok := stores[data.RunID]
-		if !ok {
-			var storeErr error
-			store, storeErr = storage.NewRunStore(baseDir, data.RunID)
-			if storeErr != nil {
-				storeMu.Unlock()
-				log.Warn("failed to open run store for network log",
-					"run_id", data.RunID, "error", storeErr)
-				return
+		// Duplicate the request to the run's mirror endpoint, if configured.
+		// Fire-and-forget: Observe never blocks the proxy.
+		rc, _ := apiServer.Registry().LookupRun(data.RunID)
+		if rc != nil && rc.Mirror != nil {
+			mirror.Observe(rc.Mirror, data)
+		}
+
+		store := runStore(data.RunID)
+		if store == nil {
+			return
+		}
+
+		netReq := daemon.NewNetworkRequest(rc, data)
+		_ = store.WriteNetworkRequest(netReq)
+
+		// Record why the request was allowed or denied and what was attached,
+		// separately from the network trace, and stream it to any
+		// `moat network --follow` subscribers.
+		decision := daemon.NewDecision(rc, data)
+		_ = store.WriteDecision(decision)
+		telemetry.ProxyRequest(data.RunID, decision)
+		recorder.Observe(rc, store, decision)
+		apiServer.Events().Publish(daemon.RequestEvent{RunID: data.RunID, Decision: decision})
+
+		// The network trace records every request locally. Copy it into the
+		// run's audit log only to ship it to a configured audit collector.
+		if audit.Shipping() {
+			if as := auditStore(data.RunID); as != nil {
+				_, _ = as.AppendNetwork(audit.NetworkData{
+					Method:         data.Method,
+					URL:            netReq.URL,
+					StatusCode:     data.StatusCode,
+					DurationMs:     data.Duration.Milliseconds(),
+					CredentialUsed: strings.Join(data.Grants, ","),
+					Error:          netReq.Error,
+				})
 			}
-			stores[data.RunID] = store
 		}
-		storeMu.Unlock()
 
-		var errStr string
-		if data.Err != nil {
-			errStr = data.Err.Error()
+		// Record every messaging send, allowed or blocked by the run's cap.
+		if msg, ok := daemon.NewMessageData(rc, data); ok {
+			if as := auditStore(data.RunID); as != nil {
+				if _, err := as.AppendMessage(msg); err != nil {
+					log.Warn("failed to record messaging send in audit log",
+						"run_id", data.RunID, "error", err)
+				}
+			}
 		}
-		_ = store.WriteNetworkRequest(storage.NetworkRequest{
-			Timestamp:       time.Now().UTC(),
-			Method:          data.Method,
-			URL:             data.URL,
-			StatusCode:      data.StatusCode,
-			Duration:        data.Duration.Milliseconds(),
-			Error:           errStr,
-			RequestHeaders:  proxy.FilterHeaders(data.RequestHeaders, data.InjectedHeaders),
-			ResponseHeaders: proxy.FilterHeaders(data.ResponseHeaders, nil),
-			RequestBody:     string(data.RequestBody),
-			ResponseBody:    string(data.ResponseBody),
-			BodyTruncated:   len(data.RequestBody) >= proxy.MaxBodySize || len(data.ResponseBody) >= proxy.MaxBodySize,
-		})
-	})
+	}
+	p.SetLogger(logRequest)
 
-	// Wire policy decision logging. Routes to per-run audit stores.
-	var auditMu sync.Mutex
-	auditStores := make(map[string]*audit.Store)
+	// Wire policy decision logging.
 	p.SetPolicyLogger(func(data proxy.PolicyLogData) {
 		if data.RunID == "" {
 			return
 		}
-
-		auditMu.Lock()
-		as, ok := auditStores[data.RunID]
-		if !ok {
-			runDir := filepath.Join(baseDir, data.RunID)
-			var openErr error
-			as, openErr = audit.OpenStore(filepath.Join(runDir, "audit.db"))
-			if openErr != nil {
-				auditMu.Unlock()
-				log.Warn("failed to open audit store for policy log",
-					"run_id", data.RunID, "error", openErr)
-				return
+		if as := auditStore(data.RunID); as != nil {
+			if err := as.AppendPolicyEntry(data.Scope, data.Operation, "deny", data.Rule, data.Message); err != nil {
+				log.Warn("failed to record policy decision in audit log",
+					"run_id", data.RunID, "error", err)
 			}
-			auditStores[data.RunID] = as
 		}
-		auditMu.Unlock()
+	})
 
-		_ = as.AppendPolicyEntry(data.Scope, data.Operation, "deny", data.Rule, data.Message)
+	// Record token usage metered from LLM API responses for cost reports.
+	daemon.SetUsageRecorder(func(runID string, u storage.Usage) {
+		if store := runStore(runID); store != nil {
+			if err := store.WriteUsage(u); err != nil {
+				log.Warn("failed to write usage record", "run_id", runID, "error", err)
+			}
+		}
 	})
 
-	// Start credential proxy.
-	proxyServer := proxy.NewServer(p)
+	// Enforce machine-wide daily LLM quotas from the global config. Quotas
+	// are read once; changing them takes 'moat proxy restart'.
+	globalCfg, err := config.LoadGlobal()
+	if err != nil {
+		log.Warn("failed to load global config; LLM quotas not enforced", "error", err)
+		globalCfg = config.DefaultGlobalConfig()
+	} else if len(globalCfg.Quotas) > 0 {
+		tracker := daemon.NewQuotaTracker(globalCfg.Quotas, metering.DefaultPrices())
+		if err := tracker.Load(baseDir); err != nil {
+			log.Warn("failed to load today's usage for quotas", "error", err)
+		}
+		daemon.SetQuotaTracker(tracker)
+	}
+
+	// Concurrent run limits, read once like quotas.
+	apiServer.Scheduler().SetLimits(globalCfg.Concurrency)
+
+	// In-container moatctl calls (snapshots, progress, budget, approvals).
+	ctl := daemon.NewCtl(baseDir, runStore, auditStore)
+	daemon.SetCtl(ctl)
+	ctl.SetEvents(apiServer.RunEvents())
+	ctl.SetRegistry(apiServer.Registry())
+	if globalCfg.Notifications.ApprovalsEnabled() {
+		ctl.SetNotifier(notify.Send, globalCfg.Notifications.EffectiveDelay())
+	}
+
+	// Start credential proxy behind the front, which runs the checks a run
+	// makes before a request is forwarded (see daemon.Front).
+	proxyServer := daemon.NewFront(p, apiServer.Registry(), ca)
+	proxyServer.SetLogger(logRequest)
+	proxyServer.SetMirror(mirror)
 	proxyServer.SetBindAddr("0.0.0.0")
 	if daemonProxyPort > 0 {
 		proxyServer.SetPort(daemonProxyPort)
@@ -177,6 +261,7 @@ func runDaemon(_ *cobra.Command, _ []string) error {
 
 	// Update API server with actual proxy port (may differ from requested if port was 0).
 	apiServer.SetProxyPort(actualPort)
+	apiServer.SetRunsDir(baseDir)
 
 	// Write lock file BEFORE starting the API server. The parent's
 	// EnsureRunning polls the socket for health — if the lock file isn't
@@ -222,7 +307,14 @@ func runDaemon(_ *cobra.Command, _ []string) error {
 	// run directories (a run dir survives until `moat destroy`), NOT the empty
 	// idle registry — so persisted volumes for not-yet-destroyed runs are never
 	// touched. It is fully defensive and never blocks shutdown.
-	idleShutdown := daemon.NewIdleTimer(5*time.Minute, func() {
+	var idleShutdown *daemon.IdleTimer
+	idleShutdown = daemon.NewIdleTimer(5*time.Minute, func() {
+		// Runs that do not use the proxy never register, but may hold
+		// or wait for a run slot; stay up for them.
+		if apiServer.Scheduler().Len() > 0 {
+			idleShutdown.Reset()
+			return
+		}
 		gcCtx, gcCancel := context.WithTimeout(context.Background(), 30*time.Second)
 		daemon.GCOrphanWorkspaceVolumes(gcCtx)
 		gcCancel()
@@ -235,8 +327,13 @@ func runDaemon(_ *cobra.Command, _ []string) error {
 	// Start container liveness checker to clean up dead runs.
 	livenessCtx, livenessCancel := context.WithCancel(context.Background())
 	defer livenessCancel()
-	lc := daemon.NewLivenessChecker(apiServer.Registry(), daemon.NewCommandContainerChecker())
+	checker := daemon.NewCommandContainerChecker()
+	checker.KubeContext = globalCfg.Kubernetes.Context
+	lc := daemon.NewLivenessChecker(apiServer.Registry(), checker)
 	cleanupStore := func(runID string) {
+		recorder.Forget(runID)
+		ctl.Forget(runID)
+
 		storeMu.Lock()
 		delete(stores, runID)
 		storeMu.Unlock()
@@ -278,6 +375,22 @@ func runDaemon(_ *cobra.Command, _ []string) error {
 		lc.Run(livenessCtx)
 	}()
 
+	// Keep runs usable across host sleep: refresh tokens and give the
+	// runtime time to resume on wake instead of dropping the runs.
+	suspend := daemon.NewSuspendHandler(apiServer.Registry(), lc)
+	suspend.SetPersister(persister)
+	sleepWatcher := daemon.NewSleepWatcher()
+	if globalCfg.Sleep.PauseContainers {
+		suspend.SetPauser(daemon.CommandContainerPauser{})
+		sleepWatcher.SetOnSleep(func() { suspend.Sleep(livenessCtx) })
+	}
+	sleepWatcher.SetOnWake(func(time.Duration) { suspend.Wake(livenessCtx) })
+	go sleepWatcher.Run(livenessCtx)
+
+	// Purge grants past their --expires time, from the store and from the
+	// runs still using them.
+	go daemon.RunGrantExpirySweep(livenessCtx, apiServer.Registry())
+
 	// Clean up per-run stores when runs are unregistered.
 	apiServer.SetOnUnregister(cleanupStore)
 
@@ -35,7 +35,9 @@ This command shows:
 - Credential status (scrubbed for safety)
 - Claude Code configuration
 - Recent runs
-- Network connectivity
+- Health checks of the runtime, proxy daemon, CA certificate, keyring,
+  credential expiry, orphaned containers and networks, and stale routes,
+  each with a suggested fix
 
 All sensitive information (tokens, keys, secrets) is automatically redacted.`,
 	RunE: runDoctor,
@@ -62,6 +64,7 @@ func runDoctor(cmd *cobra.Command, args []string) error {
 	reg.Register(&codex.DoctorSection{})
 	reg.Register(&storageSection{})
 	reg.Register(&runsSection{})
+	reg.Register(&healthSection{})
 
 	// Run all sections
 	for _, section := range reg.Sections() {
@@ -115,6 +118,16 @@ func (s *containerSection) Print(w io.Writer) error {
 		runtimes = append(runtimes, "docker"+marker)
 	}
 
+	// Check Podman (needs its API socket to exist)
+	if podmanRT, err := container.NewPodmanRuntime(false); err == nil {
+		podmanRT.Close()
+		marker := ""
+		if defaultRT.Type() == container.RuntimePodman {
+			marker = " (default)"
+		}
+		runtimes = append(runtimes, "podman"+marker)
+	}
+
 	// Check Apple Containers
 	if appleRT, err := container.NewAppleRuntime(); err == nil {
 		_ = appleRT // Suppress unused warning
@@ -0,0 +1,412 @@
+package cli
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/majorcontext/moat/internal/config"
+	"github.com/majorcontext/moat/internal/container"
+	"github.com/majorcontext/moat/internal/credential"
+	"github.com/majorcontext/moat/internal/daemon"
+	"github.com/majorcontext/moat/internal/run"
+	"github.com/majorcontext/moat/internal/ui"
+)
+
+// checkStatus is the outcome of a health check.
+type checkStatus int
+
+const (
+	checkOK checkStatus = iota
+	checkWarn
+	checkFail
+)
+
+// doctorCheck is one line of the Health Checks section. Fix tells the user
+// how to resolve a warning or failure.
+type doctorCheck struct {
+	Name   string
+	Status checkStatus
+	Detail string
+	Fix    string
+}
+
+// Thresholds for warning about certificates and credentials that are about
+// to expire.
+const (
+	caExpiryWarning         = 30 * 24 * time.Hour
+	credentialExpiryWarning = 7 * 24 * time.Hour
+)
+
+// healthSection runs checks against each moat subsystem and prints a fix
+// for every problem found.
+type healthSection struct{}
+
+func (s *healthSection) Name() string { return "Health Checks" }
+
+func (s *healthSection) Print(w io.Writer) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	proxyDir := filepath.Join(config.GlobalConfigDir(), "proxy")
+	now := time.Now()
+
+	var checks []doctorCheck
+	runtimeCheck := checkRuntime(ctx)
+	checks = append(checks, runtimeCheck)
+
+	client := daemon.NewClient(filepath.Join(proxyDir, "daemon.sock"))
+	health, healthErr := client.Health(ctx)
+	lock, _ := daemon.ReadLockFile(proxyDir)
+	checks = append(checks, checkDaemon(health, healthErr, lock, commit))
+	checks = append(checks, checkCACert(filepath.Join(proxyDir, "ca"), now))
+
+	creds, keyringCheck := checkKeyring()
+	checks = append(checks, keyringCheck)
+	checks = append(checks, checkCredentialExpiry(creds, now)...)
+
+	// Orphans can only be found through a working runtime.
+	if runtimeCheck.Status == checkOK {
+		checks = append(checks, checkOrphans(ctx)...)
+	}
+
+	if healthErr == nil && slices.Contains(health.Capabilities, daemon.CapRouteList) {
+		routes, err := client.ListRoutes(ctx)
+		if err != nil {
+			checks = append(checks, doctorCheck{Name: "Routes", Status: checkWarn, Detail: fmt.Sprintf("listing routes: %v", err)})
+		} else {
+			checks = append(checks, checkStaleRoutes(routes, filepath.Join(proxyDir, "routes.json"), routeReachable))
+		}
+	}
+
+	return printChecks(w, checks)
+}
+
+// printChecks writes checks as a table, with each fix on the line below its
+// check.
+func printChecks(w io.Writer, checks []doctorCheck) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	problems := 0
+	for _, c := range checks {
+		tag := ui.OKTag()
+		switch c.Status {
+		case checkWarn:
+			tag = ui.WarnTag()
+			problems++
+		case checkFail:
+			tag = ui.FailTag()
+			problems++
+		}
+		fmt.Fprintf(tw, "%s %s:\t%s\n", tag, c.Name, c.Detail)
+		if c.Fix != "" && c.Status != checkOK {
+			fmt.Fprintf(tw, "  %s\t%s\n", ui.Dim("fix:"), c.Fix)
+		}
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	if problems == 0 {
+		fmt.Fprintln(w, "\nNo problems found")
+	} else {
+		fmt.Fprintf(w, "\n%d problem(s) found\n", problems)
+	}
+	return nil
+}
+
+// checkRuntime checks that the default container runtime responds. gVisor
+// is not required here; the Container Runtime section reports it.
+func checkRuntime(ctx context.Context) doctorCheck {
+	c := doctorCheck{Name: "Runtime"}
+	rt, err := container.NewRuntimeWithOptions(container.RuntimeOptions{Sandbox: false})
+	if err != nil {
+		c.Status = checkFail
+		c.Detail = err.Error()
+		c.Fix = "install and start Docker, Podman, or Apple containers, or set MOAT_RUNTIME to one that is running"
+		return c
+	}
+	defer rt.Close()
+	if err := rt.Ping(ctx); err != nil {
+		c.Status = checkFail
+		c.Detail = err.Error()
+		c.Fix = fmt.Sprintf("start the %s engine and re-run 'moat doctor'", rt.Type())
+		return c
+	}
+	version, err := rt.Version(ctx)
+	if err != nil {
+		version = "version unknown"
+	}
+	c.Detail = fmt.Sprintf("%s %s", rt.Type(), version)
+	return c
+}
+
+// checkDaemon checks the proxy daemon. A daemon that is not running is fine,
+// since runs start it, but a lock file left by a dead daemon or a daemon
+// built from a different commit than the CLI needs attention.
+func checkDaemon(health *daemon.HealthResponse, healthErr error, lock *daemon.LockInfo, cliCommit string) doctorCheck {
+	c := doctorCheck{Name: "Proxy daemon"}
+	if healthErr != nil {
+		if lock != nil && lock.IsAlive() {
+			c.Status = checkFail
+			c.Detail = fmt.Sprintf("pid %d is running but not answering on its socket", lock.PID)
+			c.Fix = "run 'moat proxy restart'"
+			return c
+		}
+		c.Detail = "not running (started by the next run)"
+		return c
+	}
+	c.Detail = fmt.Sprintf("pid %d, proxy port %d, %d active run(s)", health.PID, health.ProxyPort, health.RunCount)
+	if health.Commit != "" && cliCommit != "none" && health.Commit != cliCommit {
+		c.Status = checkWarn
+		c.Detail += fmt.Sprintf("; built from %s, CLI is %s", health.Commit, cliCommit)
+		c.Fix = "run 'moat proxy restart' to pick up the installed version"
+	}
+	return c
+}
+
+// checkCACert checks the proxy CA certificate in caDir, which containers
+// trust for TLS interception.
+func checkCACert(caDir string, now time.Time) doctorCheck {
+	c := doctorCheck{Name: "CA certificate"}
+	certPath := filepath.Join(caDir, "ca.crt")
+	fix := fmt.Sprintf("remove %s and run 'moat proxy restart' to generate a new CA", caDir)
+	data, err := os.ReadFile(certPath)
+	if os.IsNotExist(err) {
+		c.Detail = "not created yet (generated by the first run)"
+		return c
+	}
+	if err != nil {
+		c.Status = checkFail
+		c.Detail = err.Error()
+		c.Fix = fix
+		return c
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		c.Status = checkFail
+		c.Detail = certPath + " is not a PEM certificate"
+		c.Fix = fix
+		return c
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		c.Status = checkFail
+		c.Detail = fmt.Sprintf("parsing %s: %v", certPath, err)
+		c.Fix = fix
+		return c
+	}
+	switch {
+	case now.After(cert.NotAfter):
+		c.Status = checkFail
+		c.Detail = fmt.Sprintf("expired %s", cert.NotAfter.Format("2006-01-02"))
+		c.Fix = fix
+	case !cert.IsCA:
+		c.Status = checkFail
+		c.Detail = certPath + " is not a CA certificate"
+		c.Fix = fix
+	case cert.NotAfter.Sub(now) < caExpiryWarning:
+		c.Status = checkWarn
+		c.Detail = fmt.Sprintf("expires %s", cert.NotAfter.Format("2006-01-02"))
+		c.Fix = fix
+	default:
+		c.Detail = fmt.Sprintf("valid until %s", cert.NotAfter.Format("2006-01-02"))
+	}
+	return c
+}
+
+// checkKeyring checks that the credential encryption key can be read and
+// the credential store decrypted, returning the stored credentials.
+func checkKeyring() ([]credential.Credential, doctorCheck) {
+	c := doctorCheck{Name: "Keyring"}
+	key, err := credential.DefaultEncryptionKey()
+	if err != nil {
+		c.Status = checkFail
+		c.Detail = err.Error()
+		c.Fix = "unlock the system keychain, or set MOAT_KEYRING_BACKEND=file (or MOAT_KEY_PROVIDER) for every moat process"
+		return nil, c
+	}
+	store, err := credential.NewFileStore(credential.DefaultStoreDir(), key)
+	if err != nil {
+		c.Status = checkFail
+		c.Detail = err.Error()
+		c.Fix = "check permissions on " + credential.DefaultStoreDir()
+		return nil, c
+	}
+	creds, err := store.List()
+	if err != nil {
+		c.Status = checkFail
+		c.Detail = fmt.Sprintf("reading credentials: %v", err)
+		c.Fix = "the encryption key may have changed; re-grant credentials with 'moat grant <provider>'"
+		return nil, c
+	}
+	c.Detail = fmt.Sprintf("encryption key available, %d credential(s) stored", len(creds))
+	return creds, c
+}
+
+// checkCredentialExpiry reports credentials whose grant or token has expired
+// or expires soon. Credentials that can be refreshed are not reported for
+// token expiry, since moat refreshes them before use.
+func checkCredentialExpiry(creds []credential.Credential, now time.Time) []doctorCheck {
+	var checks []doctorCheck
+	for i := range creds {
+		cred := &creds[i]
+		name := fmt.Sprintf("Credential %s", cred.Provider)
+		fix := fmt.Sprintf("run 'moat grant %s'", cred.Provider)
+		if exp := cred.GrantExpiry(); !exp.IsZero() {
+			switch {
+			case cred.GrantExpired(now):
+				checks = append(checks, doctorCheck{Name: name, Status: checkFail, Detail: fmt.Sprintf("grant expired %s ago", formatAge(exp)), Fix: fix})
+				continue
+			case exp.Sub(now) < credentialExpiryWarning:
+				checks = append(checks, doctorCheck{Name: name, Status: checkWarn, Detail: fmt.Sprintf("grant expires %s", exp.Format("2006-01-02 15:04")), Fix: fix})
+				continue
+			}
+		}
+		if cred.ExpiresAt.IsZero() || cred.Metadata["refresh_token"] != "" {
+			continue
+		}
+		switch {
+		case now.After(cred.ExpiresAt):
+			checks = append(checks, doctorCheck{Name: name, Status: checkFail, Detail: fmt.Sprintf("token expired %s ago", formatAge(cred.ExpiresAt)), Fix: fix})
+		case cred.ExpiresAt.Sub(now) < credentialExpiryWarning:
+			checks = append(checks, doctorCheck{Name: name, Status: checkWarn, Detail: fmt.Sprintf("token expires %s", cred.ExpiresAt.Format("2006-01-02 15:04")), Fix: fix})
+		}
+	}
+	if len(checks) == 0 && len(creds) > 0 {
+		checks = append(checks, doctorCheck{Name: "Credential expiry", Detail: "no credentials expired or expiring soon"})
+	}
+	return checks
+}
+
+// checkOrphans reports moat containers and networks that no known run owns,
+// usually left behind by a crash.
+func checkOrphans(ctx context.Context) []doctorCheck {
+	noSandbox := true
+	manager, err := run.NewManagerWithOptions(run.ManagerOptions{NoSandbox: &noSandbox})
+	if err != nil {
+		return []doctorCheck{{Name: "Orphans", Status: checkWarn, Detail: fmt.Sprintf("loading runs: %v", err)}}
+	}
+	defer manager.Close()
+
+	owned := make(map[string]bool)
+	for _, r := range manager.List() {
+		owned[r.ID] = true
+		for _, id := range []string{r.ContainerID, r.BuildkitContainerID, r.NetworkID} {
+			if id != "" {
+				owned[id] = true
+			}
+		}
+		for _, id := range r.ServiceContainers {
+			owned[id] = true
+		}
+	}
+
+	var containers, networks []string
+	var errs []string
+	_ = manager.RuntimePool().ForEachAvailable(func(rt container.Runtime) error {
+		cs, err := rt.ListContainers(ctx)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("listing %s containers: %v", rt.Type(), err))
+			// Without the containers we cannot tell which networks are in use.
+			return nil
+		}
+		containers = append(containers, orphanedContainers(cs, owned)...)
+		if netMgr := rt.NetworkManager(); netMgr != nil {
+			ns, err := netMgr.ListNetworks(ctx)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("listing %s networks: %v", rt.Type(), err))
+				return nil
+			}
+			networks = append(networks, orphanedNetworks(ns, owned)...)
+		}
+		return nil
+	})
+
+	var checks []doctorCheck
+	if len(errs) > 0 {
+		checks = append(checks, doctorCheck{Name: "Orphans", Status: checkWarn, Detail: strings.Join(errs, "; ")})
+	}
+	checks = append(checks,
+		orphanCheck("Orphaned containers", containers),
+		orphanCheck("Orphaned networks", networks))
+	return checks
+}
+
+func orphanedContainers(cs []container.Info, owned map[string]bool) []string {
+	var names []string
+	for _, c := range cs {
+		if !owned[c.Name] && !owned[c.ID] {
+			names = append(names, c.Name)
+		}
+	}
+	return names
+}
+
+func orphanedNetworks(ns []container.NetworkInfo, owned map[string]bool) []string {
+	var names []string
+	for _, n := range ns {
+		if !owned[n.ID] {
+			names = append(names, n.Name)
+		}
+	}
+	return names
+}
+
+func orphanCheck(name string, orphans []string) doctorCheck {
+	if len(orphans) == 0 {
+		return doctorCheck{Name: name, Detail: "none"}
+	}
+	sort.Strings(orphans)
+	return doctorCheck{
+		Name:   name,
+		Status: checkWarn,
+		Detail: fmt.Sprintf("%d (%s)", len(orphans), strings.Join(orphans, ", ")),
+		Fix:    "run 'moat clean' to remove them",
+	}
+}
+
+// checkStaleRoutes reports hostname routes whose agent no longer answers on
+// any of its endpoints.
+func checkStaleRoutes(routes []daemon.RouteInfo, routesFile string, reachable func(addr string) bool) doctorCheck {
+	var stale []string
+	for _, r := range routes {
+		alive := false
+		for _, addr := range r.Services {
+			if reachable(addr) {
+				alive = true
+				break
+			}
+		}
+		if !alive {
+			stale = append(stale, r.Agent)
+		}
+	}
+	if len(stale) == 0 {
+		return doctorCheck{Name: "Routes", Detail: fmt.Sprintf("%d registered, none stale", len(routes))}
+	}
+	return doctorCheck{
+		Name:   "Routes",
+		Status: checkWarn,
+		Detail: fmt.Sprintf("%d stale (%s)", len(stale), strings.Join(stale, ", ")),
+		Fix:    fmt.Sprintf("starting a run with the same --name replaces a stale route; otherwise remove the agents from %s", routesFile),
+	}
+}
+
+// routeReachable reports whether a route endpoint accepts TCP connections.
+func routeReachable(addr string) bool {
+	conn, err := net.DialTimeout("tcp", addr, 500*time.Millisecond)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
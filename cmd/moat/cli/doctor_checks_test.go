@@ -0,0 +1,197 @@
+package cli
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/majorcontext/moat/internal/container"
+	"github.com/majorcontext/moat/internal/credential"
+	"github.com/majorcontext/moat/internal/daemon"
+)
+
+func writeTestCA(t *testing.T, dir string, notAfter time.Time, isCA bool) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             notAfter.Add(-365 * 24 * time.Hour),
+		NotAfter:              notAfter,
+		IsCA:                  isCA,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	if err := os.WriteFile(filepath.Join(dir, "ca.crt"), data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCheckCACert(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		notAfter time.Time
+		isCA     bool
+		want     checkStatus
+	}{
+		{"valid", now.Add(365 * 24 * time.Hour), true, checkOK},
+		{"expiring soon", now.Add(10 * 24 * time.Hour), true, checkWarn},
+		{"expired", now.Add(-time.Hour), true, checkFail},
+		{"not a CA", now.Add(365 * 24 * time.Hour), false, checkFail},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeTestCA(t, dir, tt.notAfter, tt.isCA)
+			got := checkCACert(dir, now)
+			if got.Status != tt.want {
+				t.Errorf("status = %v, want %v (%s)", got.Status, tt.want, got.Detail)
+			}
+			if got.Status != checkOK && got.Fix == "" {
+				t.Error("problem reported without a fix")
+			}
+		})
+	}
+
+	t.Run("missing", func(t *testing.T) {
+		if got := checkCACert(t.TempDir(), now); got.Status != checkOK {
+			t.Errorf("status = %v, want OK for a CA not created yet", got.Status)
+		}
+	})
+
+	t.Run("garbage", func(t *testing.T) {
+		dir := t.TempDir()
+		if err := os.WriteFile(filepath.Join(dir, "ca.crt"), []byte("not a cert"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if got := checkCACert(dir, now); got.Status != checkFail {
+			t.Errorf("status = %v, want fail", got.Status)
+		}
+	})
+}
+
+func TestCheckCredentialExpiry(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	expiredGrant := credential.Credential{Provider: "github"}
+	expiredGrant.SetGrantExpiry(now.Add(-time.Hour))
+	soonGrant := credential.Credential{Provider: "npm"}
+	soonGrant.SetGrantExpiry(now.Add(24 * time.Hour))
+
+	creds := []credential.Credential{
+		expiredGrant,
+		soonGrant,
+		{Provider: "aws", ExpiresAt: now.Add(-time.Minute)},
+		{Provider: "gcp", ExpiresAt: now.Add(-time.Minute), Metadata: map[string]string{"refresh_token": "r"}},
+		{Provider: "anthropic", ExpiresAt: now.Add(90 * 24 * time.Hour)},
+		{Provider: "openai"},
+	}
+	checks := checkCredentialExpiry(creds, now)
+
+	got := make(map[string]checkStatus)
+	for _, c := range checks {
+		got[c.Name] = c.Status
+		if !strings.Contains(c.Fix, "moat grant") {
+			t.Errorf("%s: fix = %q, want a moat grant command", c.Name, c.Fix)
+		}
+	}
+	want := map[string]checkStatus{
+		"Credential github": checkFail,
+		"Credential npm":    checkWarn,
+		"Credential aws":    checkFail,
+	}
+	if len(got) != len(want) {
+		t.Fatalf("checks = %+v, want %v", checks, want)
+	}
+	for name, status := range want {
+		if got[name] != status {
+			t.Errorf("%s = %v, want %v", name, got[name], status)
+		}
+	}
+
+	if checks := checkCredentialExpiry(creds[4:], now); len(checks) != 1 || checks[0].Status != checkOK {
+		t.Errorf("healthy credentials: checks = %+v, want one OK check", checks)
+	}
+}
+
+func TestCheckDaemon(t *testing.T) {
+	health := &daemon.HealthResponse{PID: 42, ProxyPort: 9100, Commit: "abc"}
+
+	if got := checkDaemon(health, nil, nil, "abc"); got.Status != checkOK {
+		t.Errorf("matching commit: status = %v, want OK", got.Status)
+	}
+	if got := checkDaemon(health, nil, nil, "def"); got.Status != checkWarn || !strings.Contains(got.Fix, "moat proxy restart") {
+		t.Errorf("commit mismatch: got %+v, want warning with restart fix", got)
+	}
+	if got := checkDaemon(health, nil, nil, "none"); got.Status != checkOK {
+		t.Errorf("dev build: status = %v, want OK", got.Status)
+	}
+	if got := checkDaemon(nil, errors.New("refused"), nil, "abc"); got.Status != checkOK {
+		t.Errorf("not running: status = %v, want OK", got.Status)
+	}
+	alive := &daemon.LockInfo{PID: os.Getpid()}
+	if got := checkDaemon(nil, errors.New("refused"), alive, "abc"); got.Status != checkFail {
+		t.Errorf("unresponsive: status = %v, want fail", got.Status)
+	}
+}
+
+func TestCheckStaleRoutes(t *testing.T) {
+	routes := []daemon.RouteInfo{
+		{Agent: "live", Services: map[string]string{"web": "127.0.0.1:1", "api": "127.0.0.1:2"}},
+		{Agent: "gone", Services: map[string]string{"web": "127.0.0.1:3"}},
+	}
+	reachable := func(addr string) bool { return addr == "127.0.0.1:2" }
+
+	got := checkStaleRoutes(routes, "/tmp/routes.json", reachable)
+	if got.Status != checkWarn {
+		t.Fatalf("status = %v, want warning", got.Status)
+	}
+	if !strings.Contains(got.Detail, "gone") || strings.Contains(got.Detail, "live") {
+		t.Errorf("detail = %q, want only the stale agent", got.Detail)
+	}
+
+	if got := checkStaleRoutes(routes[:1], "/tmp/routes.json", reachable); got.Status != checkOK {
+		t.Errorf("live routes: status = %v, want OK", got.Status)
+	}
+}
+
+func TestOrphanedContainersAndNetworks(t *testing.T) {
+	owned := map[string]bool{"run_1": true, "svc123": true, "net1": true}
+
+	cs := []container.Info{
+		{ID: "c1", Name: "run_1"},
+		{ID: "svc123", Name: "moat-postgres-run_1"},
+		{ID: "c3", Name: "run_old"},
+	}
+	if got := orphanedContainers(cs, owned); len(got) != 1 || got[0] != "run_old" {
+		t.Errorf("orphanedContainers = %v, want [run_old]", got)
+	}
+
+	ns := []container.NetworkInfo{{ID: "net1", Name: "moat-run_1"}, {ID: "net2", Name: "moat-run_old"}}
+	if got := orphanedNetworks(ns, owned); len(got) != 1 || got[0] != "moat-run_old" {
+		t.Errorf("orphanedNetworks = %v, want [moat-run_old]", got)
+	}
+
+	if c := orphanCheck("Orphaned networks", []string{"b", "a"}); c.Status != checkWarn || c.Fix == "" || !strings.Contains(c.Detail, "a, b") {
+		t.Errorf("orphanCheck = %+v", c)
+	}
+}
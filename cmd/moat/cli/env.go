@@ -0,0 +1,252 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	intcli "github.com/majorcontext/moat/internal/cli"
+	"github.com/majorcontext/moat/internal/config"
+	"github.com/majorcontext/moat/internal/errcode"
+	"github.com/majorcontext/moat/internal/run"
+	"github.com/majorcontext/moat/internal/storage"
+	"github.com/majorcontext/moat/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var envDiff bool
+
+// envValueWidth truncates long values (e.g. MOAT_INIT_FILES) in the table.
+// --json prints them in full.
+const envValueWidth = 80
+
+var envCmd = &cobra.Command{
+	Use:   "env [run]",
+	Short: "Show the environment a run's container was created with",
+	Long: `Show the environment variables a run's container was created with, and
+where each one came from: moat.yaml env, --env, a secret, a grant, the proxy,
+an agent, a service, or moat itself. Secrets and credential-like values are
+redacted. Accepts a run ID or name; defaults to the most recent run.
+
+With --diff, compares the recorded environment against the environment of
+the agent process inside the running container, showing variables that the
+init script or pre_run hook added, removed, or changed. Redacted values are
+compared without being shown.
+
+Variables set with 'moat env set' are listed with the source "moat env set".
+
+Examples:
+  moat env                     # Environment of the most recent run
+  moat env my-agent            # Environment of a run by name
+  moat env my-agent --diff     # What changed inside the container
+  moat env my-agent --json     # Machine-readable output`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runEnv,
+}
+
+var envSetCmd = &cobra.Command{
+	Use:   "set <run> KEY=VALUE...",
+	Short: "Set environment variables for later exec sessions in a running run",
+	Long: `Set environment variables for commands started in a running container
+after this point: 'moat exec' and agents added with 'moat join'. The container
+is not restarted, and processes already running in it, including the agent,
+keep their environment.
+
+Overrides last until the run stops. Setting a name again replaces its value;
+use 'moat env unset' to remove one. Each change is recorded in the run's audit
+log by variable name; values are not logged.
+
+Examples:
+  moat env set my-agent DEBUG=1
+  moat env set my-agent API_URL=http://localhost:3000 LOG_LEVEL=debug
+  moat exec my-agent -- printenv DEBUG`,
+	Args: cobra.MinimumNArgs(2),
+	RunE: runEnvSet,
+}
+
+var envUnsetCmd = &cobra.Command{
+	Use:   "unset <run> KEY...",
+	Short: "Remove environment variables set with 'moat env set'",
+	Long: `Remove environment overrides set with 'moat env set'. Later exec sessions
+see the environment the container was created with for these names.
+
+Examples:
+  moat env unset my-agent DEBUG`,
+	Args: cobra.MinimumNArgs(2),
+	RunE: runEnvUnset,
+}
+
+func init() {
+	rootCmd.AddCommand(envCmd)
+	envCmd.Flags().BoolVar(&envDiff, "diff", false, "compare against the environment inside the running container")
+	envCmd.AddCommand(envSetCmd)
+	envCmd.AddCommand(envUnsetCmd)
+}
+
+func runEnvSet(_ *cobra.Command, args []string) error {
+	parsed := &config.Config{}
+	if err := intcli.ParseEnvFlags(args[1:], parsed); err != nil {
+		return err
+	}
+	return updateEnvOverrides(args[0], parsed.Env, nil)
+}
+
+func runEnvUnset(_ *cobra.Command, args []string) error {
+	return updateEnvOverrides(args[0], nil, args[1:])
+}
+
+func updateEnvOverrides(runArg string, set map[string]string, unset []string) error {
+	manager, err := run.NewManager()
+	if err != nil {
+		return fmt.Errorf("creating run manager: %w", err)
+	}
+	defer manager.Close()
+
+	runID, err := resolveRunArgSingle(manager, runArg)
+	if err != nil {
+		return err
+	}
+	if _, err := manager.SetEnv(runID, set, unset); err != nil {
+		return err
+	}
+	r, err := manager.Get(runID)
+	if err != nil {
+		return err
+	}
+	overrides, err := run.EnvOverrides(r)
+	if err != nil {
+		return err
+	}
+	if jsonOut {
+		return json.NewEncoder(os.Stdout).Encode(overrides)
+	}
+
+	if len(set) > 0 {
+		ui.Infof("Set %s for new exec sessions in %s", strings.Join(slices.Sorted(maps.Keys(set)), ", "), r.Name)
+	}
+	if len(unset) > 0 {
+		ui.Infof("Unset %s for new exec sessions in %s", strings.Join(unset, ", "), r.Name)
+	}
+	if len(overrides) == 0 {
+		return nil
+	}
+	fmt.Println()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tVALUE")
+	for _, v := range overrides {
+		fmt.Fprintf(w, "%s\t%s\n", v.Name, truncateEnvValue(v.Value))
+	}
+	return w.Flush()
+}
+
+// envDiffOutput is the --json shape of `moat env <run> --diff`.
+type envDiffOutput struct {
+	RunID   string          `json:"run_id"`
+	Name    string          `json:"name"`
+	Drifted bool            `json:"drifted"`
+	Changes []run.EnvChange `json:"changes"`
+}
+
+func runEnv(cmd *cobra.Command, args []string) error {
+	manager, err := run.NewManager()
+	if err != nil {
+		return fmt.Errorf("creating run manager: %w", err)
+	}
+	defer manager.Close()
+
+	var runID string
+	if len(args) > 0 {
+		runID, err = resolveRunArgSingle(manager, args[0])
+	} else {
+		runID, err = findLatestRun(storage.DefaultBaseDir())
+	}
+	if err != nil {
+		return err
+	}
+	r, err := manager.Get(runID)
+	if err != nil {
+		return err
+	}
+
+	if envDiff {
+		return showEnvDiff(cmd.Context(), manager, r)
+	}
+
+	env, err := run.EnvSnapshot(r)
+	if errors.Is(err, run.ErrNoEnvSnapshot) {
+		return errcode.Wrap(errcode.NoEnvSnapshot, fmt.Errorf("run %s has no recorded environment; it was created by an older moat version", runID))
+	}
+	if err != nil {
+		return err
+	}
+	overrides, err := run.EnvOverrides(r)
+	if err != nil {
+		return err
+	}
+	env = append(env, overrides...)
+	if jsonOut {
+		if env == nil {
+			env = []storage.EnvVar{}
+		}
+		return json.NewEncoder(os.Stdout).Encode(env)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tVALUE\tSOURCE")
+	for _, v := range env {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", v.Name, truncateEnvValue(v.Value), v.Source)
+	}
+	return w.Flush()
+}
+
+func showEnvDiff(ctx context.Context, manager *run.Manager, r *run.Run) error {
+	if r.GetState() != run.StateRunning {
+		return errcode.Wrap(errcode.RunNotRunning, fmt.Errorf("run %s is not running (state: %s); --diff inspects the live container", r.ID, r.GetState()))
+	}
+	changes, err := manager.EnvDrift(ctx, r.ID)
+	if errors.Is(err, run.ErrNoEnvSnapshot) {
+		return errcode.Wrap(errcode.NoEnvSnapshot, fmt.Errorf("run %s has no recorded environment; it was created by an older moat version", r.ID))
+	}
+	if err != nil {
+		return err
+	}
+
+	if jsonOut {
+		if changes == nil {
+			changes = []run.EnvChange{}
+		}
+		return json.NewEncoder(os.Stdout).Encode(envDiffOutput{
+			RunID:   r.ID,
+			Name:    r.Name,
+			Drifted: len(changes) > 0,
+			Changes: changes,
+		})
+	}
+
+	if len(changes) == 0 {
+		fmt.Printf("%s The agent's environment matches what %s was created with\n", ui.OKTag(), r.Name)
+		return nil
+	}
+	fmt.Printf("%s The agent's environment differs from what %s (%s) was created with:\n\n", ui.WarnTag(), r.Name, r.ID)
+	for _, c := range changes {
+		if c.Source != "" {
+			fmt.Printf("  %s  (from %s)\n", c, c.Source)
+		} else {
+			fmt.Printf("  %s\n", c)
+		}
+	}
+	return nil
+}
+
+func truncateEnvValue(v string) string {
+	if len(v) <= envValueWidth {
+		return v
+	}
+	return v[:envValueWidth-3] + "..."
+}
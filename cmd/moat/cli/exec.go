@@ -2,6 +2,7 @@ package cli
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -15,6 +16,7 @@ import (
 	intcli "github.com/majorcontext/moat/internal/cli"
 	clipboardpkg "github.com/majorcontext/moat/internal/clipboard"
 	"github.com/majorcontext/moat/internal/config"
+	"github.com/majorcontext/moat/internal/container"
 	"github.com/majorcontext/moat/internal/log"
 	"github.com/majorcontext/moat/internal/run"
 	"github.com/majorcontext/moat/internal/snapshot"
@@ -186,7 +188,7 @@ func setupTTYTracer(tracePath string, r *run.Run, command []string) *ttyTracer {
 	)
 
 	log.Info("TTY tracing enabled", "path", tracePath, "run_id", r.ID)
-	fmt.Printf("Recording terminal I/O to %s\n", tracePath)
+	ui.Statusf("Recording terminal I/O to %s", tracePath)
 
 	return &ttyTracer{
 		recorder: recorder,
@@ -205,7 +207,7 @@ func (t *ttyTracer) save() {
 		ui.Warnf("Failed to save terminal trace to %s: %v", t.path, err)
 	} else {
 		log.Info("TTY trace saved", "path", t.path)
-		fmt.Printf("Terminal trace saved to %s\n", t.path)
+		ui.Statusf("Terminal trace saved to %s", t.path)
 	}
 }
 
@@ -213,7 +215,7 @@ func (t *ttyTracer) save() {
 // It handles creating the run, starting it, and managing the lifecycle.
 // Returns the run for further inspection if needed.
 func ExecuteRun(ctx context.Context, opts intcli.ExecOptions) (*run.Run, error) {
-	fmt.Println("Initializing...")
+	ui.Status("Initializing...")
 
 	// Set runtime based on CLI flag or moat.yaml, in priority order:
 	// 1. --runtime CLI flag (if provided)
@@ -235,6 +237,30 @@ func ExecuteRun(ctx context.Context, opts intcli.ExecOptions) (*run.Run, error)
 	if err != nil {
 		return nil, err
 	}
+	if len(wsCfg.Paths) > 0 && opts.WorktreePath == "" {
+		ui.Warnf("workspace.paths only applies to worktree runs (moat wt, --worktree); the full workspace is mounted")
+	}
+
+	labels, err := run.ParseLabels(opts.Flags.Labels)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Flags.Group != "" {
+		if err := run.ValidateGroup(opts.Flags.Group); err != nil {
+			return nil, err
+		}
+	}
+	if opts.Flags.PR != "" {
+		if _, _, err := run.ParsePullRequest(opts.Flags.PR); err != nil {
+			return nil, fmt.Errorf("--pr: %w", err)
+		}
+	}
+	var priority container.Priority
+	if opts.Flags.Priority != "" {
+		if priority, err = container.ParsePriority(opts.Flags.Priority); err != nil {
+			return nil, err
+		}
+	}
 
 	// Create manager. ReapOrphanNetworks=true because this path creates a
 	// new network — best moment to clean up leaks from prior crashed runs.
@@ -274,17 +300,32 @@ func ExecuteRun(ctx context.Context, opts intcli.ExecOptions) (*run.Run, error)
 
 	// Build run options
 	runOpts := run.Options{
-		Name:          opts.Flags.Name,
-		Workspace:     opts.Workspace,
-		Grants:        opts.Flags.Grants,
-		Cmd:           opts.Command,
-		Config:        opts.Config,
-		Env:           opts.Flags.Env,
-		Rebuild:       opts.Flags.Rebuild,
-		KeepContainer: opts.Flags.KeepContainer,
-		Interactive:   opts.Interactive,
-		Clipboard:     clipboard,
-		WorkspaceMode: wsMode,
+		Name:           opts.Flags.Name,
+		Workspace:      opts.Workspace,
+		Grants:         opts.Flags.Grants,
+		Cmd:            opts.Command,
+		Config:         opts.Config,
+		Env:            opts.Flags.Env,
+		Rebuild:        opts.Flags.Rebuild,
+		KeepContainer:  opts.Flags.KeepContainer,
+		Interactive:    opts.Interactive,
+		Clipboard:      clipboard,
+		WorkspaceMode:  wsMode,
+		NoEgress:       opts.Flags.NoEgress,
+		Labels:         labels,
+		Group:          opts.Flags.Group,
+		IdempotencyKey: opts.Flags.IDFrom,
+		PullRequest:    opts.Flags.PR,
+		Priority:       priority,
+	}
+
+	// The pre-flight checks below see grant bundles expanded the same way
+	// Create expands them; an expansion error is left for Create to report.
+	preflightGrants := opts.Flags.Grants
+	if globalCfg, err := config.LoadGlobal(); err == nil {
+		if expanded, err := run.ExpandGrantBundles(preflightGrants, globalCfg.GrantBundles); err == nil {
+			preflightGrants = expanded
+		}
 	}
 
 	// Pre-flight: on an interactive terminal, offer to grant any missing
@@ -292,9 +333,9 @@ func ExecuteRun(ctx context.Context, opts intcli.ExecOptions) (*run.Run, error)
 	// still caught by manager.Create's validation below (today's behavior),
 	// so non-interactive runs and --no-prompt are unaffected.
 	noPrompt := opts.Flags.NoPrompt || os.Getenv("MOAT_NO_PROMPT") == "1"
-	if !noPrompt && stdinIsInteractive() {
+	if !noPrompt && !opts.Flags.NoEgress && stdinIsInteractive() {
 		if store, storeErr := run.OpenDefaultStore(); storeErr == nil {
-			grants := run.AppendMCPGrants(opts.Flags.Grants, opts.Config)
+			grants := run.AppendMCPGrants(preflightGrants, opts.Config)
 			if missing := run.DetectMissingGrants(grants, opts.Config, store); len(missing) > 0 {
 				promptForMissingGrants(ctx, missing)
 			}
@@ -303,11 +344,35 @@ func ExecuteRun(ctx context.Context, opts intcli.ExecOptions) (*run.Run, error)
 		}
 	}
 
+	// Pre-flight: catch expired or revoked credentials before building an
+	// image and starting a container that would only fail on its first API
+	// call. Inconclusive checks (offline, slow provider) never block the run.
+	if !opts.Flags.SkipPreflight && !opts.Flags.NoEgress {
+		if err := preflightCredentials(ctx, run.AppendMCPGrants(preflightGrants, opts.Config)); err != nil {
+			return nil, err
+		}
+	}
+
+	// Wait for a run slot when concurrency limits are set. The slot is held
+	// until the run ends, which is when this function returns.
+	slot, err := acquireRunSlot(ctx, opts.Workspace, opts.Flags.Name)
+	if err != nil {
+		return nil, err
+	}
+	defer slot.Release()
+
 	// Create run
 	r, err := manager.Create(ctx, runOpts)
+	var exists *run.ExistsError
+	if errors.As(err, &exists) {
+		// The existing run holds a slot of its own.
+		slot.Release()
+		return resumeExistingRun(ctx, manager, exists.Run, opts)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("creating run: %w", err)
 	}
+	slot.Attach(ctx, r)
 
 	log.Info("created run", "id", r.ID, "name", r.Name)
 
@@ -323,7 +388,10 @@ func ExecuteRun(ctx context.Context, opts intcli.ExecOptions) (*run.Run, error)
 
 	// Call the OnRunCreated callback if provided (provider commands set this).
 	// For moat run, print the run info here so it appears before the session starts.
-	if opts.OnRunCreated != nil {
+	// --quiet prints only the run ID, for scripts.
+	if ui.Quiet() {
+		fmt.Println(r.ID)
+	} else if opts.OnRunCreated != nil {
 		opts.OnRunCreated(intcli.RunInfo{
 			ID:   r.ID,
 			Name: r.Name,
@@ -336,7 +404,15 @@ func ExecuteRun(ctx context.Context, opts intcli.ExecOptions) (*run.Run, error)
 	// This is required for TUI applications like Codex CLI that need to detect terminal
 	// capabilities immediately on startup.
 	if opts.Interactive {
-		return r, RunInteractiveAttached(ctx, manager, r, opts.Command, opts.Flags.TTYTrace)
+		err := RunInteractiveAttached(ctx, manager, r, opts.Command, opts.Flags.TTYTrace)
+		printAPIErrorSummary(r)
+		printUploadViolations(r)
+		printBlockedTrafficSuggestion(r)
+		if err == nil {
+			draftPRDescriptionAfterRun(ctx, opts.Config, r)
+		}
+		commentOnPRAfterRun(ctx, opts.Config, r)
+		return r, err
 	}
 
 	// Non-interactive: start the container, stream its output, and wait for exit.
@@ -346,23 +422,63 @@ func ExecuteRun(ctx context.Context, opts intcli.ExecOptions) (*run.Run, error)
 	}
 
 	log.Info("run started", "id", r.ID)
+	return followRun(ctx, manager, r, opts.Config)
+}
+
+// resumeExistingRun handles a --id-from key that already has a run: it
+// follows the run if it is still going, and otherwise reports how it ended,
+// so a retried invocation behaves like the original rather than starting a
+// duplicate.
+func resumeExistingRun(ctx context.Context, manager *run.Manager, r *run.Run, opts intcli.ExecOptions) (*run.Run, error) {
+	state := r.GetState()
+	if ui.Quiet() {
+		fmt.Println(r.ID)
+	} else {
+		fmt.Printf("Run %s (%s) already exists for this --id-from key: %s\n", r.Name, r.ID, state)
+	}
 
+	switch state {
+	case run.StateRunning:
+		if opts.Interactive {
+			return r, fmt.Errorf("run %s is already running; interactive sessions cannot be reattached (view output with 'moat logs %s')", r.ID, r.ID)
+		}
+		ui.Status(ui.Dim("Following the existing run"))
+		return followRun(ctx, manager, r, opts.Config)
+	case run.StateStopped:
+		return r, nil
+	case run.StateFailed:
+		if r.Error == "" {
+			return r, fmt.Errorf("run failed: exited with code %d", r.ExitCode)
+		}
+		return r, fmt.Errorf("run failed: %s", r.Error)
+	default:
+		return r, fmt.Errorf("run %s is %s; wait for it to start, or remove it with 'moat destroy %s' to retry the key", r.ID, state, r.ID)
+	}
+}
+
+// followRun streams a started run's output and waits for it to exit,
+// stopping it on Ctrl+C.
+func followRun(ctx context.Context, manager *run.Manager, r *run.Run, cfg *config.Config) (*run.Run, error) {
 	// Print port information if available. Use the proxy's actual bound port
 	// (not the configured default) so the advertised URLs are reachable even
 	// when the proxy fell back to an OS-assigned port.
-	if len(r.Ports) > 0 {
+	if len(r.Ports) > 0 || len(r.PublishedServices) > 0 {
 		proxyPort := manager.RoutingPort()
 
-		fmt.Println("Endpoints:")
+		ui.Status("Endpoints:")
 		for endpointName, containerPort := range r.Ports {
 			url := fmt.Sprintf("https://%s.%s.localhost:%d", endpointName, r.Name, proxyPort)
-			fmt.Printf("  %s: %s (container :%d)\n", endpointName, url, containerPort)
+			ui.Statusf("  %s: %s (container :%d)", endpointName, url, containerPort)
 		}
-		fmt.Printf("  %s\n", ui.Dim(fmt.Sprintf("all endpoints: https://localhost:%d/  ·  moat open %s", proxyPort, r.Name)))
+		for serviceName, svc := range r.PublishedServices {
+			url := fmt.Sprintf("https://%s.%s.localhost:%d%s", serviceName, r.Name, proxyPort, svc.Path)
+			ui.Statusf("  %s: %s (service)", serviceName, url)
+		}
+		ui.Statusf("  %s", ui.Dim(fmt.Sprintf("all endpoints: https://localhost:%d/  ·  moat open %s", proxyPort, r.Name)))
 	}
 
-	fmt.Println(ui.Dim("Press Ctrl+C to stop"))
-	fmt.Println()
+	ui.Status(ui.Dim("Press Ctrl+C to stop"))
+	ui.Status("")
 
 	// Stream container logs to stdout in a goroutine.
 	// FollowLogs blocks until the container exits or context is canceled.
@@ -388,14 +504,16 @@ func ExecuteRun(ctx context.Context, opts intcli.ExecOptions) (*run.Run, error)
 	case sig := <-sigCh:
 		log.Info("received signal, stopping run", "signal", sig, "id", r.ID)
 		logCancel()
-		fmt.Printf("\nStopping run %s...\n", r.ID)
+		ui.Statusf("\nStopping run %s...", r.ID)
 		if err := manager.Stop(ctx, r.ID); err != nil {
 			log.Error("failed to stop run", "id", r.ID, "error", err)
 		}
 		// Wait for monitorContainerExit to finish cleanup
 		<-waitDone
-		fmt.Println()
-		fmt.Println(ui.Dim(fmt.Sprintf("View output: moat logs %s", r.ID)))
+		printAPIErrorSummary(r)
+		printUploadViolations(r)
+		ui.Status("")
+		ui.Status(ui.Dim(fmt.Sprintf("View output: moat logs %s", r.ID)))
 		return r, nil
 	case err := <-waitDone:
 		logCancel()
@@ -414,10 +532,22 @@ func ExecuteRun(ctx context.Context, opts intcli.ExecOptions) (*run.Run, error)
 					log.Debug("reading network requests for credential hints", "error", rerr)
 				}
 			}
+			if hint := run.FailureHint(r); hint != "" {
+				ui.Info(hint)
+			}
+			printAPIErrorSummary(r)
+			printUploadViolations(r)
+			printBlockedTrafficSuggestion(r)
+			commentOnPRAfterRun(ctx, cfg, r)
 			return r, fmt.Errorf("run failed: %w", err)
 		}
-		fmt.Println()
-		fmt.Println(ui.Dim(fmt.Sprintf("View output: moat logs %s", r.ID)))
+		printAPIErrorSummary(r)
+		printUploadViolations(r)
+		printBlockedTrafficSuggestion(r)
+		draftPRDescriptionAfterRun(ctx, cfg, r)
+		commentOnPRAfterRun(ctx, cfg, r)
+		ui.Status("")
+		ui.Status(ui.Dim(fmt.Sprintf("View output: moat logs %s", r.ID)))
 		return r, nil
 	}
 }
@@ -658,7 +788,7 @@ func RunInteractiveAttached(ctx context.Context, manager *run.Manager, r *run.Ru
 				log.Error("run failed", "id", r.ID, "error", err)
 				return fmt.Errorf("run failed: %w", err)
 			}
-			fmt.Printf("Run %s completed\n", r.ID)
+			ui.Statusf("Run %s completed", r.ID)
 			return nil
 		}
 	}
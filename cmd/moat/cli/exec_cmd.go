@@ -6,7 +6,11 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"os/signal"
+	"syscall"
 
+	intcli "github.com/majorcontext/moat/internal/cli"
+	"github.com/majorcontext/moat/internal/config"
 	"github.com/majorcontext/moat/internal/container"
 	"github.com/majorcontext/moat/internal/run"
 	"github.com/majorcontext/moat/internal/term"
@@ -23,30 +27,51 @@ func exitWithExecError(manager *run.Manager, err error) error {
 	var ee *container.ExecError
 	if errors.As(err, &ee) {
 		manager.Close()
+		ctx, cancel := context.WithTimeout(context.Background(), telemetryFlushTimeout)
+		_ = shutdownTelemetry(ctx)
+		cancel()
 		os.Exit(ee.ExitCode)
 	}
 	return err
 }
 
+var (
+	execTTY     bool
+	execWorkDir string
+	execEnv     []string
+)
+
 var execCmd = &cobra.Command{
 	Use:   "exec <run> -- <command> [args...]",
 	Short: "Run a command in a running container",
 	Long: `Execute a command inside a running container.
 
 The run can be specified by ID or name. Use -- to separate moat flags
-from the command to execute.
+from the command to execute. The command runs as the container's moat
+user, sees variables set with 'moat env set', and is recorded in the
+run's audit log with its working directory, the names of variables set
+with --env, and its exit code.
+
+Use -t to allocate a terminal for interactive commands such as shells.
+--workdir changes directory before running the command; relative paths
+resolve against /workspace. --env sets variables for this command only.
 
 Examples:
   moat exec run_a1b2c3d4e5f6 -- echo hello
   moat exec run_a1b2c3d4e5f6 -- ls /workspace
   echo "data" | moat exec run_a1b2c3d4e5f6 -- cat
-  moat exec run_a1b2c3d4e5f6 -- sh -c "ps aux"`,
+  moat exec run_a1b2c3d4e5f6 -- sh -c "ps aux"
+  moat exec -t my-agent -- bash
+  moat exec -w src -e DEBUG=1 my-agent -- make test`,
 	Args: cobra.MinimumNArgs(1),
 	RunE: runExec,
 }
 
 func init() {
 	rootCmd.AddCommand(execCmd)
+	execCmd.Flags().BoolVarP(&execTTY, "tty", "t", false, "allocate a terminal for the command")
+	execCmd.Flags().StringVarP(&execWorkDir, "workdir", "w", "", "directory to run the command in (relative to /workspace)")
+	execCmd.Flags().StringArrayVarP(&execEnv, "env", "e", nil, "set an environment variable for the command (KEY=VALUE, repeatable)")
 }
 
 func runExec(cmd *cobra.Command, args []string) error {
@@ -60,6 +85,14 @@ func runExec(cmd *cobra.Command, args []string) error {
 	runArg := args[0]
 	execArgs := args[1:]
 
+	// Validate --env up front; ParseEnvFlags rejects malformed names.
+	if err := intcli.ParseEnvFlags(execEnv, &config.Config{}); err != nil {
+		return err
+	}
+	if execTTY && !term.IsTerminal(os.Stdin) {
+		return fmt.Errorf("--tty requires stdin to be a terminal")
+	}
+
 	manager, err := run.NewManager()
 	if err != nil {
 		return fmt.Errorf("creating run manager: %w", err)
@@ -71,17 +104,66 @@ func runExec(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	// Read stdin if it's not a terminal (piped input), capped at 64 MB.
-	const maxStdin = 64 << 20
-	var stdin []byte
+	copts := run.CommandOptions{WorkDir: execWorkDir, Env: execEnv}
+	ctx := context.Background()
+	if execTTY {
+		return exitWithExecError(manager, runExecTTY(ctx, manager, runID, execArgs, copts))
+	}
+
+	// Forward piped stdin so "echo ... | moat exec <run> -- cat" works.
+	var stdin io.Reader
 	if !term.IsTerminal(os.Stdin) {
-		stdin, err = io.ReadAll(io.LimitReader(os.Stdin, maxStdin))
-		if err != nil {
-			return fmt.Errorf("reading stdin: %w", err)
+		stdin = os.Stdin
+	}
+	execErr := manager.ExecCommand(ctx, runID, execArgs, copts, container.ExecOptions{
+		Stdin:  stdin,
+		Stdout: os.Stdout,
+		Stderr: os.Stderr,
+	})
+	return exitWithExecError(manager, execErr)
+}
+
+// runExecTTY runs the command with a PTY, the local terminal in raw mode,
+// and terminal resizes forwarded. The terminal is restored before
+// returning so exitWithExecError can exit cleanly.
+func runExecTTY(ctx context.Context, manager *run.Manager, runID string, command []string, copts run.CommandOptions) error {
+	rawState, err := term.EnableRawMode(os.Stdin)
+	if err != nil {
+		return fmt.Errorf("enabling raw mode: %w", err)
+	}
+	defer func() { _ = term.RestoreTerminal(rawState) }()
+
+	var initialW, initialH uint
+	if w, h := term.GetSize(os.Stdout); w > 0 && h > 0 {
+		initialW, initialH = uint(w), uint(h) // #nosec G115
+	}
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGWINCH)
+	defer signal.Stop(sigCh)
+
+	// Resize channel owned by resizePump — do NOT close it here.
+	resize := make(chan container.TTYSize, 1)
+	done := make(chan struct{})
+	onWinch := func() (container.TTYSize, bool) {
+		w, h := term.GetSize(os.Stdout)
+		if w <= 0 || h <= 0 {
+			return container.TTYSize{}, false
 		}
+		return container.TTYSize{Width: uint(w), Height: uint(h)}, true // #nosec G115
 	}
+	go resizePump(done, sigCh, onWinch, resize)
 
-	ctx := context.Background()
-	execErr := manager.Exec(ctx, runID, execArgs, stdin, os.Stdout, os.Stderr)
-	return exitWithExecError(manager, execErr)
+	execErr := manager.ExecCommand(ctx, runID, command, copts, container.ExecOptions{
+		Stdin:         os.Stdin,
+		Stdout:        os.Stdout,
+		Stderr:        os.Stderr,
+		TTY:           true,
+		InitialWidth:  initialW,
+		InitialHeight: initialH,
+		Resize:        resize,
+	})
+	// Signal resizePump to stop; it closes resize, which unblocks the runtime side.
+	close(done)
+	return execErr
 }
@@ -3,19 +3,59 @@ package cli
 
 import (
 	"bufio"
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/majorcontext/moat/internal/credential"
+	"github.com/majorcontext/moat/internal/errcode"
 	"github.com/majorcontext/moat/internal/provider"
+	"github.com/majorcontext/moat/internal/provider/util"
 	"github.com/majorcontext/moat/internal/providers/aws"
+	"github.com/majorcontext/moat/internal/providers/gemini"
 	"github.com/spf13/cobra"
 	"golang.org/x/term"
 )
 
+// Non-interactive provisioning flags, shared by every grant subcommand.
+var (
+	grantFromEnv       bool
+	grantNoInteractive bool
+	grantFromJSON      string
+
+	// grantErrorsAsJSON and grantTarget are set once --no-interactive has
+	// taken effect, so Execute reports a failure as a JSON error naming
+	// what was being granted.
+	grantErrorsAsJSON bool
+	grantTarget       string
+)
+
+// Workspace scoping and expiry flags, shared by every grant subcommand that
+// stores a credential. grantRestriction and grantExpiry are built from them
+// before the grant runs.
+var (
+	grantOnlyRepos      []string
+	grantOnlyWorkspaces []string
+	grantRestriction    *credential.Restriction
+	grantExpires        time.Duration
+	grantExpiry         time.Time
+)
+
+// errUnknownProvider is returned when a grant names no registered provider.
+var errUnknownProvider = errors.New("unknown provider")
+
+func init() {
+	errcode.Register(errUnknownProvider, errcode.ProviderNotFound)
+}
+
 // AWS grant flags - these need to be passed to the AWS provider
 var (
 	awsRole            string
@@ -25,6 +65,9 @@ var (
 	awsProfile         string
 )
 
+// Gemini grant flags - passed to the gemini provider for Vertex AI
+var geminiOpts gemini.GrantOptions
+
 var grantCmd = &cobra.Command{
 	Use:   "grant <provider>",
 	Short: "Grant a credential for use in runs",
@@ -38,6 +81,11 @@ Profile-scoped credentials are isolated from the default store.
 
 Run 'moat grant providers' to list all available providers.
 
+For scripts and CI images, --from-env reads credentials only from
+environment variables, --no-interactive fails instead of prompting and
+prints errors as JSON on stderr, and --from-json supplies flag values
+(e.g. --role for aws, --host for ssh) as a JSON object.
+
 Subcommands:
   providers   List all available credential providers
   ssh         Grant SSH access for a specific host
@@ -48,21 +96,36 @@ Examples:
   moat grant anthropic                           # Grant Anthropic API key (for any agent)
   moat grant github                              # Grant GitHub access
   moat grant aws --role=arn:aws:...              # Grant AWS access via IAM role
+  moat grant gemini --vertex --project my-proj   # Route Gemini CLI through Vertex AI
   moat grant github --profile myproject          # Grant GitHub access in a profile
   moat grant providers                           # List all available providers
   moat run my-agent . --grant github             # Use credential in a run
-  moat run --grant github --profile myproject    # Use profile-scoped credential`,
+  moat run --grant github --profile myproject    # Use profile-scoped credential
+
+  # Provision from CI without prompts
+  GITHUB_TOKEN=... moat grant github --from-env --no-interactive
+  echo '{"role":"arn:aws:iam::123456789012:role/Agent"}' | moat grant aws --no-interactive --from-json -`,
 	Args: cobra.MinimumNArgs(1),
 	RunE: runGrant,
 }
 
 func init() {
 	rootCmd.AddCommand(grantCmd)
+	grantCmd.PersistentPreRunE = preRunGrant
+	grantCmd.PersistentFlags().BoolVar(&grantFromEnv, "from-env", false, "read credentials only from environment variables, skipping CLI and config file imports")
+	grantCmd.PersistentFlags().BoolVar(&grantNoInteractive, "no-interactive", false, "fail instead of prompting; errors are printed as JSON on stderr")
+	grantCmd.PersistentFlags().StringVar(&grantFromJSON, "from-json", "", "read flag values from a JSON object in `FILE` (- for stdin)")
+	grantCmd.PersistentFlags().StringArrayVar(&grantOnlyRepos, "only-repo", nil, "only attach the credential to runs in workspaces whose origin is `REPO` (owner/repo; repeatable)")
+	grantCmd.PersistentFlags().StringArrayVar(&grantOnlyWorkspaces, "only-workspace", nil, "only attach the credential to runs in `PATH` or below it (repeatable)")
+	grantCmd.PersistentFlags().DurationVar(&grantExpires, "expires", 0, "delete the credential after `DURATION` (e.g. 8h); runs can no longer use it")
 	grantCmd.Flags().StringVar(&awsRole, "role", "", "IAM role ARN to assume (required for aws)")
 	grantCmd.Flags().StringVar(&awsRegion, "region", "", "AWS region (default: us-east-1)")
 	grantCmd.Flags().StringVar(&awsSessionDuration, "session-duration", "", "Session duration (default: 15m, max: 12h)")
 	grantCmd.Flags().StringVar(&awsExternalID, "external-id", "", "External ID for role assumption")
 	grantCmd.Flags().StringVar(&awsProfile, "aws-profile", "", "AWS shared config profile for role assumption (falls back to AWS_PROFILE env var if not set)")
+	grantCmd.Flags().BoolVar(&geminiOpts.Vertex, "vertex", false, "Use Vertex AI for gemini: an express mode API key, or project routing with --project")
+	grantCmd.Flags().StringVar(&geminiOpts.Project, "project", "", "Google Cloud project for gemini --vertex (falls back to GOOGLE_CLOUD_PROJECT)")
+	grantCmd.Flags().StringVar(&geminiOpts.Location, "location", "", "Vertex AI location for gemini --vertex --project (default: global)")
 }
 
 // saveCredential stores a credential and returns the file path.
@@ -76,9 +139,17 @@ func saveCredential(cred credential.Credential) (string, error) {
 	if err != nil {
 		return "", fmt.Errorf("opening credential store: %w", err)
 	}
+	cred.Restrict = grantRestriction
+	cred.SetGrantExpiry(grantExpiry)
 	if err := store.Save(cred); err != nil {
 		return "", fmt.Errorf("saving credential: %w", err)
 	}
+	if cred.Restrict != nil {
+		fmt.Printf("Restricted to %s\n", cred.Restrict)
+	}
+	if !grantExpiry.IsZero() {
+		fmt.Printf("Expires %s\n", grantExpiry.Local().Format(time.RFC1123))
+	}
 	return filepath.Join(storeDir, string(cred.Provider)+".enc"), nil
 }
 
@@ -99,12 +170,20 @@ func runGrant(cmd *cobra.Command, args []string) error {
 	// Look up provider in registry
 	prov := provider.Get(providerName)
 	if prov == nil {
-		return fmt.Errorf("unknown provider: %s\n\nRun 'moat grant providers' to list all available providers",
-			args[0])
+		return fmt.Errorf("%w: %s\n\nRun 'moat grant providers' to list all available providers",
+			errUnknownProvider, args[0])
+	}
+
+	ctx := cmd.Context()
+	if ctx == nil {
+		ctx = context.Background()
 	}
 
 	// For AWS, validate required flags before calling Grant
 	if providerName == "aws" && awsRole == "" {
+		if err := util.RequireInput(ctx, "pass --role (or \"role\" in --from-json)"); err != nil {
+			return err
+		}
 		return fmt.Errorf(`--role is required for AWS grant
 
 Usage: moat grant aws --role=arn:aws:iam::ACCOUNT:role/ROLE_NAME
@@ -117,17 +196,18 @@ Options:
   --aws-profile      AWS shared config profile (falls back to AWS_PROFILE env var)`)
 	}
 
-	// Call the provider's Grant method
-	ctx := cmd.Context()
-	if ctx == nil {
-		ctx = context.Background()
-	}
-
 	// For AWS, pass the CLI flags via context
 	if providerName == "aws" {
 		ctx = aws.WithGrantOptions(ctx, awsRole, awsRegion, awsSessionDuration, awsExternalID, awsProfile)
 	}
 
+	// For Gemini, pass the Vertex AI flags via context
+	if providerName == "gemini" {
+		ctx = gemini.WithGrantOptions(ctx, geminiOpts)
+	} else if geminiOpts.Vertex || geminiOpts.Project != "" || geminiOpts.Location != "" {
+		return fmt.Errorf("--vertex, --project, and --location are only supported for gemini")
+	}
+
 	provCred, err := prov.Grant(ctx)
 	if err != nil {
 		return err
@@ -156,6 +236,163 @@ Options:
 	return nil
 }
 
+// preRunGrant applies the non-interactive provisioning flags before any grant
+// subcommand runs. It replaces the root pre-run for the grant tree, so it
+// calls that first.
+func preRunGrant(cmd *cobra.Command, args []string) error {
+	if err := rootCmd.PersistentPreRunE(cmd, args); err != nil {
+		return err
+	}
+	// Report errors as JSON as early as possible, including errors applying
+	// --from-json, which may itself set --no-interactive.
+	silenceGrantErrors(cmd, args)
+	if grantFromJSON != "" {
+		if err := applyJSONFlags(cmd, grantFromJSON); err != nil {
+			return err
+		}
+		silenceGrantErrors(cmd, args)
+	}
+	ctx := cmd.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	cmd.SetContext(util.WithGrantOptions(ctx, util.GrantOptions{
+		EnvOnly:        grantFromEnv,
+		NonInteractive: grantNoInteractive,
+	}))
+
+	restriction, err := credential.NewRestriction(grantOnlyRepos, grantOnlyWorkspaces)
+	if err != nil {
+		return fmt.Errorf("invalid grant restriction: %w", err)
+	}
+	if restriction != nil && cmd == grantSSHCmd {
+		return fmt.Errorf("--only-repo and --only-workspace are not supported for ssh grants")
+	}
+	grantRestriction = restriction
+
+	if grantExpires < 0 {
+		return fmt.Errorf("--expires must be positive")
+	}
+	if grantExpires > 0 {
+		if cmd == grantSSHCmd {
+			return fmt.Errorf("--expires is not supported for ssh grants")
+		}
+		grantExpiry = time.Now().Add(grantExpires)
+	}
+	return nil
+}
+
+// silenceGrantErrors switches error reporting to JSON under --no-interactive.
+func silenceGrantErrors(cmd *cobra.Command, args []string) {
+	if grantNoInteractive {
+		cmd.SilenceErrors = true
+		grantErrorsAsJSON = true
+		grantTarget = grantTargetName(cmd, args)
+	}
+}
+
+// grantTargetName names what cmd grants, as it would appear in a run's
+// grants list: the provider for 'moat grant <provider>', "mcp:<name>" and
+// "oauth:<name>" for those subcommands, and the subcommand name otherwise.
+func grantTargetName(cmd *cobra.Command, args []string) string {
+	switch {
+	case cmd == grantCmd && len(args) > 0:
+		return args[0]
+	case (cmd == grantMCPCmd || cmd == grantOAuthCmd) && len(args) > 0:
+		return cmd.Name() + ":" + args[0]
+	}
+	return cmd.Name()
+}
+
+// applyJSONFlags sets cmd's flags from a JSON object read from path ("-" for
+// stdin). Keys are flag names; arrays set repeatable flags once per element.
+// Flags given on the command line take precedence.
+func applyJSONFlags(cmd *cobra.Command, path string) error {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: reading --from-json: %v", errInvalidGrantInput, err)
+	}
+	var fields map[string]any
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&fields); err != nil {
+		return fmt.Errorf("%w: --from-json must be a JSON object of flag values: %v", errInvalidGrantInput, err)
+	}
+
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		f := cmd.Flags().Lookup(name)
+		if f == nil || name == "from-json" {
+			return fmt.Errorf("%w: --from-json: %q is not a flag of '%s'", errInvalidGrantInput, name, cmd.CommandPath())
+		}
+		if f.Changed {
+			continue
+		}
+		values, ok := fields[name].([]any)
+		if !ok {
+			values = []any{fields[name]}
+		}
+		for _, v := range values {
+			if err := cmd.Flags().Set(name, fmt.Sprint(v)); err != nil {
+				return fmt.Errorf("%w: --from-json: %s: %v", errInvalidGrantInput, name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// errInvalidGrantInput marks --from-json input that could not be applied.
+var errInvalidGrantInput = errors.New("invalid input")
+
+// grantErrorJSON is the machine-readable form of a failed grant, written to
+// stderr under --no-interactive.
+type grantErrorJSON struct {
+	Error struct {
+		// Code is one of input_required, unknown_provider, invalid_input,
+		// grant_failed, or error.
+		Code string `json:"code"`
+		// ErrorCode is the stable MOAT-E code, when the error has one.
+		ErrorCode errcode.Code `json:"error_code,omitempty"`
+		Provider  string       `json:"provider,omitempty"`
+		Message   string       `json:"message"`
+		Hint      string       `json:"hint,omitempty"`
+	} `json:"error"`
+}
+
+// writeGrantErrorJSON writes err as a single-line grantErrorJSON to w.
+func writeGrantErrorJSON(w io.Writer, target string, err error) {
+	var out grantErrorJSON
+	out.Error.Provider = target
+	out.Error.Message = err.Error()
+	out.Error.ErrorCode = errcode.Of(err)
+	var grantErr *provider.GrantError
+	switch {
+	case errors.Is(err, util.ErrInputRequired):
+		out.Error.Code = "input_required"
+	case errors.Is(err, errUnknownProvider):
+		out.Error.Code = "unknown_provider"
+	case errors.Is(err, errInvalidGrantInput):
+		out.Error.Code = "invalid_input"
+	case errors.As(err, &grantErr):
+		out.Error.Code = "grant_failed"
+		out.Error.Message = grantErr.Cause.Error()
+		out.Error.Hint = grantErr.Hint
+	default:
+		out.Error.Code = "error"
+	}
+	_ = json.NewEncoder(w).Encode(out)
+}
+
 // readPassword reads a password from stdin without echoing.
 // This is used by grant subcommands that need to prompt for secrets.
 func readPassword() ([]byte, error) {
@@ -0,0 +1,76 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/majorcontext/moat/internal/credential"
+	"github.com/majorcontext/moat/internal/provider"
+	"github.com/majorcontext/moat/internal/providers/atlassian"
+	"github.com/spf13/cobra"
+)
+
+var grantAtlassianCmd = &cobra.Command{
+	Use:   "atlassian",
+	Short: "Grant Bitbucket Cloud and Atlassian Cloud credentials",
+	Long: `Grant credentials for Bitbucket Cloud (api.bitbucket.org) and Atlassian Cloud
+(api.atlassian.com, used by Jira and Confluence clients and MCP servers).
+
+Without --oauth, a Bitbucket username and app password are read from
+BITBUCKET_USERNAME and BITBUCKET_APP_PASSWORD when set, otherwise prompted for.
+
+With --oauth, the browser authorization flow runs for the --product's OAuth
+app: a Bitbucket OAuth consumer, or an Atlassian OAuth 2.0 (3LO) app from
+developer.atlassian.com. Register http://127.0.0.1:8976/callback as the app's
+callback URL. The client ID and secret come from --client-id and
+--client-secret, or BITBUCKET_CLIENT_ID/BITBUCKET_CLIENT_SECRET and
+ATLASSIAN_CLIENT_ID/ATLASSIAN_CLIENT_SECRET. Access tokens are refreshed while
+runs use them.
+
+Each product is granted separately; granting one keeps the other.
+
+Examples:
+  moat grant atlassian
+  moat grant atlassian --oauth
+  moat grant atlassian --product atlassian --oauth --scope "read:jira-work write:jira-work"
+  moat run --grant atlassian ./my-project`,
+	RunE: runGrantAtlassian,
+}
+
+var atlassianGrantOpts atlassian.GrantOptions
+
+func init() {
+	grantCmd.AddCommand(grantAtlassianCmd)
+	f := grantAtlassianCmd.Flags()
+	f.StringVar(&atlassianGrantOpts.Product, "product", atlassian.ProductBitbucket, "product to grant: bitbucket or atlassian")
+	f.BoolVar(&atlassianGrantOpts.OAuth, "oauth", false, "authorize an OAuth app in the browser instead of using an app password")
+	f.StringVar(&atlassianGrantOpts.ClientID, "client-id", "", "OAuth client ID (consumer key for Bitbucket)")
+	f.StringVar(&atlassianGrantOpts.ClientSecret, "client-secret", "", "OAuth client secret")
+	f.StringVar(&atlassianGrantOpts.Scopes, "scope", "", "space-separated OAuth scopes for --product atlassian (default \""+atlassian.DefaultAtlassianScopes+"\")")
+	f.IntVar(&atlassianGrantOpts.CallbackPort, "callback-port", atlassian.DefaultCallbackPort, "port of the OAuth callback URL")
+}
+
+func runGrantAtlassian(cmd *cobra.Command, args []string) error {
+	prov := provider.Get(string(credential.ProviderAtlassian))
+	if prov == nil {
+		return fmt.Errorf("atlassian provider not registered")
+	}
+
+	ctx := atlassian.WithGrantOptions(cmd.Context(), atlassianGrantOpts)
+	provCred, err := prov.Grant(ctx)
+	if err != nil {
+		return err
+	}
+
+	cred := credential.Credential{
+		Provider:  credential.ProviderAtlassian,
+		Token:     provCred.Token,
+		CreatedAt: provCred.CreatedAt,
+		Metadata:  provCred.Metadata,
+	}
+	credPath, err := saveCredential(cred)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Credential saved to %s\n", credPath)
+	return nil
+}
@@ -0,0 +1,87 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/majorcontext/moat/internal/credential"
+	"github.com/majorcontext/moat/internal/provider"
+	"github.com/majorcontext/moat/internal/providers/azure"
+	"github.com/spf13/cobra"
+)
+
+var grantAzureCmd = &cobra.Command{
+	Use:   "azure",
+	Short: "Grant Azure tokens from your az CLI login or a service principal",
+	Long: `Grant Azure access backed by your host's 'az login' session or by a
+service principal.
+
+Runs with this grant get a managed identity endpoint (IDENTITY_ENDPOINT and
+MSI_ENDPOINT). Azure SDKs and 'az login --identity' request tokens from it,
+and the moat daemon issues them on demand for whichever resource (audience)
+is asked for. No credential is stored in the container.
+
+By default tokens come from your current az account, via
+'az account get-access-token'. Use --tenant or --subscription to pin another
+account, or --use-device-code to sign in with a device code first (for hosts
+without a browser).
+
+With --client-id, tokens are issued to a service principal instead: the
+daemon requests them from Entra ID with the client secret, which is read from
+AZURE_CLIENT_SECRET or prompted for and stored encrypted. --tenant (or
+AZURE_TENANT_ID) is required. With --from-env, AZURE_CLIENT_ID selects this
+mode too.
+
+Examples:
+  moat grant azure
+  moat grant azure --subscription 00000000-0000-0000-0000-000000000000
+  moat grant azure --use-device-code --tenant contoso.onmicrosoft.com
+  AZURE_CLIENT_SECRET=... moat grant azure --client-id <app-id> --tenant <tenant-id>
+  moat run --grant azure -- az login --identity`,
+	RunE: runGrantAzure,
+}
+
+var (
+	azureTenant        string
+	azureSubscription  string
+	azureClientID      string
+	azureUseDeviceCode bool
+)
+
+func init() {
+	grantCmd.AddCommand(grantAzureCmd)
+	grantAzureCmd.Flags().StringVar(&azureTenant, "tenant", "", "Entra ID tenant to request tokens from")
+	grantAzureCmd.Flags().StringVar(&azureSubscription, "subscription", "", "Subscription whose account requests tokens")
+	grantAzureCmd.Flags().StringVar(&azureClientID, "client-id", "", "Service principal application (client) ID to request tokens as")
+	grantAzureCmd.Flags().BoolVar(&azureUseDeviceCode, "use-device-code", false, "Sign in with 'az login --use-device-code' before granting")
+}
+
+func runGrantAzure(cmd *cobra.Command, args []string) error {
+	prov := provider.Get(string(credential.ProviderAzure))
+	if prov == nil {
+		return fmt.Errorf("azure provider not registered")
+	}
+
+	ctx := azure.WithGrantOptions(cmd.Context(), azure.GrantOptions{
+		Tenant:        azureTenant,
+		Subscription:  azureSubscription,
+		ClientID:      azureClientID,
+		UseDeviceCode: azureUseDeviceCode,
+	})
+	provCred, err := prov.Grant(ctx)
+	if err != nil {
+		return err
+	}
+
+	cred := credential.Credential{
+		Provider:  credential.ProviderAzure,
+		Token:     provCred.Token,
+		CreatedAt: provCred.CreatedAt,
+		Metadata:  provCred.Metadata,
+	}
+	credPath, err := saveCredential(cred)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Credential saved to %s\n", credPath)
+	return nil
+}
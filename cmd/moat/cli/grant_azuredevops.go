@@ -0,0 +1,64 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/majorcontext/moat/internal/credential"
+	"github.com/majorcontext/moat/internal/provider"
+	"github.com/majorcontext/moat/internal/providers/azuredevops"
+	"github.com/spf13/cobra"
+)
+
+var grantAzureDevOpsCmd = &cobra.Command{
+	Use:   "azure-devops",
+	Short: "Grant Azure DevOps credentials",
+	Long: `Grant a personal access token for Azure DevOps (dev.azure.com and
+<org>.visualstudio.com), used for git over HTTPS and the REST API.
+
+--org names the organization the token is for and can be repeated. The token
+is validated against each one. Organizations may be given as a name or as a
+https://dev.azure.com/<org> or https://<org>.visualstudio.com URL;
+AZURE_DEVOPS_ORG (comma-separated) can stand in for --org.
+
+The token is read from AZURE_DEVOPS_EXT_PAT or AZURE_DEVOPS_PAT when set,
+otherwise prompted for. Granting again replaces the stored token and
+organizations.
+
+Examples:
+  moat grant azure-devops --org contoso
+  moat run --grant azure-devops -- git push`,
+	RunE: runGrantAzureDevOps,
+}
+
+var azureDevOpsOrgs []string
+
+func init() {
+	grantCmd.AddCommand(grantAzureDevOpsCmd)
+	grantAzureDevOpsCmd.Flags().StringSliceVar(&azureDevOpsOrgs, "org", nil, "Azure DevOps organization (repeatable)")
+}
+
+func runGrantAzureDevOps(cmd *cobra.Command, args []string) error {
+	prov := provider.Get(string(credential.ProviderAzureDevOps))
+	if prov == nil {
+		return fmt.Errorf("azure-devops provider not registered")
+	}
+
+	ctx := azuredevops.WithGrantOptions(cmd.Context(), azureDevOpsOrgs)
+	provCred, err := prov.Grant(ctx)
+	if err != nil {
+		return err
+	}
+
+	cred := credential.Credential{
+		Provider:  credential.ProviderAzureDevOps,
+		Token:     provCred.Token,
+		CreatedAt: provCred.CreatedAt,
+		Metadata:  provCred.Metadata,
+	}
+	credPath, err := saveCredential(cred)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Credential saved to %s\n", credPath)
+	return nil
+}
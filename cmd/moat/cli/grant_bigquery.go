@@ -0,0 +1,66 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/majorcontext/moat/internal/credential"
+	"github.com/majorcontext/moat/internal/provider"
+	"github.com/majorcontext/moat/internal/providers/bigquery"
+	"github.com/spf13/cobra"
+)
+
+var grantBigQueryCmd = &cobra.Command{
+	Use:   "bigquery",
+	Short: "Grant BigQuery access from Google application default credentials",
+	Long: `Grant BigQuery access backed by Google application default credentials.
+
+Credentials are read from --credentials-file, GOOGLE_APPLICATION_CREDENTIALS,
+or the file written by 'gcloud auth application-default login'. User
+credentials and service account keys are supported.
+
+The refresh token or key stays on the host. The proxy injects short-lived
+OAuth access tokens for bigquery.googleapis.com and renews them before they
+expire. The container gets GOOGLE_CLOUD_PROJECT when a project is known.
+
+Examples:
+  gcloud auth application-default login
+  moat grant bigquery --project analytics-prod
+  moat grant bigquery --credentials-file ./agent-sa.json
+  moat run --grant bigquery ./my-project`,
+	RunE: runGrantBigQuery,
+}
+
+var bigqueryOpts bigquery.GrantOptions
+
+func init() {
+	grantCmd.AddCommand(grantBigQueryCmd)
+	grantBigQueryCmd.Flags().StringVar(&bigqueryOpts.CredentialsFile, "credentials-file", "", "Application default credentials or service account key file")
+	grantBigQueryCmd.Flags().StringVar(&bigqueryOpts.Project, "project", "", "Default project (falls back to GOOGLE_CLOUD_PROJECT or the credentials file)")
+}
+
+func runGrantBigQuery(cmd *cobra.Command, args []string) error {
+	prov := provider.Get(string(credential.ProviderBigQuery))
+	if prov == nil {
+		return fmt.Errorf("bigquery provider not registered")
+	}
+
+	ctx := bigquery.WithGrantOptions(cmd.Context(), bigqueryOpts)
+	provCred, err := prov.Grant(ctx)
+	if err != nil {
+		return err
+	}
+
+	cred := credential.Credential{
+		Provider:  credential.ProviderBigQuery,
+		Token:     provCred.Token,
+		ExpiresAt: provCred.ExpiresAt,
+		CreatedAt: provCred.CreatedAt,
+		Metadata:  provCred.Metadata,
+	}
+	credPath, err := saveCredential(cred)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Credential saved to %s\n", credPath)
+	return nil
+}
@@ -0,0 +1,69 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/majorcontext/moat/internal/credential"
+	"github.com/majorcontext/moat/internal/provider"
+	"github.com/majorcontext/moat/internal/providers/containerregistry"
+	"github.com/spf13/cobra"
+)
+
+var grantContainerRegistryCmd = &cobra.Command{
+	Use:   "container-registry <registry>",
+	Short: "Grant container registry credentials for image pulls",
+	Long: `Grant a username and password or access token for a container registry,
+such as Docker Hub (docker.io) or the GitHub Container Registry (ghcr.io).
+
+Runs get a ~/.docker/config.json with placeholder passwords, and the proxy
+swaps in the real credentials on their way to the registry, so they never
+enter the container. With docker:dind, 'docker pull' of private images works
+inside the run. The username comes from --username or
+CONTAINER_REGISTRY_USERNAME and the password from
+CONTAINER_REGISTRY_PASSWORD; either is prompted for when unset.
+
+Each registry is granted separately; granting one keeps the others.
+
+Examples:
+  moat grant container-registry ghcr.io --username octocat
+  moat grant container-registry docker.io
+  moat grant container-registry registry.example.com:5000
+  moat run --grant container-registry ./my-project`,
+	Args: cobra.ExactArgs(1),
+	RunE: runGrantContainerRegistry,
+}
+
+var containerRegistryGrantOpts containerregistry.GrantOptions
+
+func init() {
+	grantCmd.AddCommand(grantContainerRegistryCmd)
+	grantContainerRegistryCmd.Flags().StringVar(&containerRegistryGrantOpts.Username, "username", "", "registry username")
+}
+
+func runGrantContainerRegistry(cmd *cobra.Command, args []string) error {
+	prov := provider.Get(string(credential.ProviderContainerRegistry))
+	if prov == nil {
+		return fmt.Errorf("container-registry provider not registered")
+	}
+
+	opts := containerRegistryGrantOpts
+	opts.Registry = args[0]
+	ctx := containerregistry.WithGrantOptions(cmd.Context(), opts)
+	provCred, err := prov.Grant(ctx)
+	if err != nil {
+		return err
+	}
+
+	cred := credential.Credential{
+		Provider:  credential.ProviderContainerRegistry,
+		Token:     provCred.Token,
+		CreatedAt: provCred.CreatedAt,
+		Metadata:  provCred.Metadata,
+	}
+	credPath, err := saveCredential(cred)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Credential saved to %s\n", credPath)
+	return nil
+}
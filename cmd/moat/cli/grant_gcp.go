@@ -0,0 +1,70 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/majorcontext/moat/internal/credential"
+	"github.com/majorcontext/moat/internal/provider"
+	"github.com/majorcontext/moat/internal/providers/gcp"
+	"github.com/spf13/cobra"
+)
+
+var grantGCPCmd = &cobra.Command{
+	Use:   "gcp",
+	Short: "Grant Google Cloud access through an emulated metadata server",
+	Long: `Grant Google Cloud access backed by Google application default credentials.
+
+Credentials are read from --credentials-file, GOOGLE_APPLICATION_CREDENTIALS,
+or the file written by 'gcloud auth application-default login'. User
+credentials and service account keys are supported.
+
+Runs with this grant get an emulated GCE metadata server: GCE_METADATA_HOST
+points at the proxy, which serves the project ID and short-lived access tokens
+minted from the credentials on the host. Client libraries that discover
+credentials from the metadata server through HTTP_PROXY, such as google-auth
+for Python, work without a key file in the container. The container also gets
+GOOGLE_CLOUD_PROJECT when a project is known.
+
+Examples:
+  gcloud auth application-default login
+  moat grant gcp --project my-project
+  moat grant gcp --credentials-file ./agent-sa.json
+  moat run --grant gcp ./my-project`,
+	RunE: runGrantGCP,
+}
+
+var gcpOpts gcp.GrantOptions
+
+func init() {
+	grantCmd.AddCommand(grantGCPCmd)
+	grantGCPCmd.Flags().StringVar(&gcpOpts.CredentialsFile, "credentials-file", "", "Application default credentials or service account key file")
+	grantGCPCmd.Flags().StringVar(&gcpOpts.Project, "project", "", "Default project (falls back to GOOGLE_CLOUD_PROJECT or the credentials file)")
+}
+
+func runGrantGCP(cmd *cobra.Command, args []string) error {
+	prov := provider.Get(string(credential.ProviderGCP))
+	if prov == nil {
+		return fmt.Errorf("gcp provider not registered")
+	}
+
+	ctx := gcp.WithGrantOptions(cmd.Context(), gcpOpts)
+	provCred, err := prov.Grant(ctx)
+	if err != nil {
+		return err
+	}
+
+	// The access token minted at grant time is only a check; the daemon
+	// mints its own, so no expiry is stored.
+	cred := credential.Credential{
+		Provider:  credential.ProviderGCP,
+		Token:     provCred.Token,
+		CreatedAt: provCred.CreatedAt,
+		Metadata:  provCred.Metadata,
+	}
+	credPath, err := saveCredential(cred)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Credential saved to %s\n", credPath)
+	return nil
+}
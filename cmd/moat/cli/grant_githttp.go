@@ -0,0 +1,88 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/majorcontext/moat/internal/credential"
+	"github.com/majorcontext/moat/internal/provider"
+	"github.com/majorcontext/moat/internal/providers/githttp"
+	"github.com/spf13/cobra"
+)
+
+var grantGerritCmd = &cobra.Command{
+	Use:   "gerrit",
+	Short: "Grant Gerrit HTTP credentials",
+	Long: `Grant HTTP credentials for a self-hosted Gerrit server.
+
+Gerrit authenticates REST calls and git over HTTPS with your account's HTTP
+password (Settings > HTTP Credentials). Each 'moat grant gerrit --url=<url>'
+adds a server to the existing credential.
+
+The username and password are read from GERRIT_USERNAME and
+GERRIT_HTTP_PASSWORD when set, otherwise prompted for. GERRIT_URL can stand in
+for --url.
+
+Examples:
+  moat grant gerrit --url=https://review.example.com
+  moat run --grant gerrit -- git push origin HEAD:refs/for/main`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runGrantGitHTTP(cmd, credential.ProviderGerrit, gerritURL)
+	},
+}
+
+var grantBitbucketServerCmd = &cobra.Command{
+	Use:   "bitbucket-server",
+	Short: "Grant Bitbucket Server/Data Center credentials",
+	Long: `Grant an HTTP access token for a self-hosted Bitbucket Server or Data Center
+instance. Bitbucket Cloud is not supported by this provider.
+
+Each 'moat grant bitbucket-server --url=<url>' adds an instance to the existing
+credential. The username and token are read from BITBUCKET_SERVER_USERNAME and
+BITBUCKET_SERVER_TOKEN when set, otherwise prompted for. BITBUCKET_SERVER_URL
+can stand in for --url.
+
+Examples:
+  moat grant bitbucket-server --url=https://bitbucket.example.com
+  moat run --grant bitbucket-server -- git push`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runGrantGitHTTP(cmd, credential.ProviderBitbucketServer, bitbucketServerURL)
+	},
+}
+
+var (
+	gerritURL          string
+	bitbucketServerURL string
+)
+
+func init() {
+	grantCmd.AddCommand(grantGerritCmd)
+	grantCmd.AddCommand(grantBitbucketServerCmd)
+	grantGerritCmd.Flags().StringVar(&gerritURL, "url", "", "Gerrit server base URL (e.g., https://review.example.com)")
+	grantBitbucketServerCmd.Flags().StringVar(&bitbucketServerURL, "url", "", "Bitbucket Server base URL (e.g., https://bitbucket.example.com)")
+}
+
+func runGrantGitHTTP(cmd *cobra.Command, name credential.Provider, serverURL string) error {
+	prov := provider.Get(string(name))
+	if prov == nil {
+		return fmt.Errorf("%s provider not registered", name)
+	}
+
+	ctx := githttp.WithGrantOptions(cmd.Context(), serverURL)
+	provCred, err := prov.Grant(ctx)
+	if err != nil {
+		return err
+	}
+
+	cred := credential.Credential{
+		Provider:  name,
+		Token:     provCred.Token,
+		CreatedAt: provCred.CreatedAt,
+		Metadata:  provCred.Metadata,
+	}
+	credPath, err := saveCredential(cred)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Credential saved to %s\n", credPath)
+	return nil
+}
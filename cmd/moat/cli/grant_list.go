@@ -9,6 +9,11 @@ import (
 
 	"github.com/majorcontext/moat/internal/credential"
 	"github.com/majorcontext/moat/internal/mcpcatalog"
+	"github.com/majorcontext/moat/internal/providers/atlassian"
+	"github.com/majorcontext/moat/internal/providers/azure"
+	"github.com/majorcontext/moat/internal/providers/containerregistry"
+	"github.com/majorcontext/moat/internal/providers/githttp"
+	"github.com/majorcontext/moat/internal/providers/registry"
 	"github.com/majorcontext/moat/internal/ui"
 	"github.com/spf13/cobra"
 )
@@ -82,17 +87,24 @@ func runGrantList(cmd *cobra.Command, args []string) error {
 	if jsonOut {
 		// Redact tokens for JSON output
 		type jsonCred struct {
-			Provider  string `json:"provider"`
-			Type      string `json:"type"`
-			GrantedAt string `json:"granted_at"`
+			Provider  string                  `json:"provider"`
+			Type      string                  `json:"type"`
+			GrantedAt string                  `json:"granted_at"`
+			Restrict  *credential.Restriction `json:"restrict,omitempty"`
+			RevokesAt string                  `json:"revokes_at,omitempty"`
 		}
 		out := make([]jsonCred, 0, len(creds)+len(sshMappings))
 		for _, c := range creds {
-			out = append(out, jsonCred{
+			jc := jsonCred{
 				Provider:  string(c.Provider),
 				Type:      credType(c),
 				GrantedAt: c.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
-			})
+				Restrict:  c.Restrict,
+			}
+			if exp := c.GrantExpiry(); !exp.IsZero() {
+				jc.RevokesAt = exp.Format("2006-01-02T15:04:05Z07:00")
+			}
+			out = append(out, jc)
 		}
 		for _, m := range sshMappings {
 			out = append(out, jsonCred{
@@ -107,9 +119,16 @@ func runGrantList(cmd *cobra.Command, args []string) error {
 	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
 	fmt.Fprintln(w, "PROVIDER\tTYPE\tGRANTED")
 	for _, c := range creds {
+		typ := credType(c)
+		if c.Restrict != nil {
+			typ += " (restricted)"
+		}
+		if exp := c.GrantExpiry(); !exp.IsZero() {
+			typ += " (revokes " + formatUntil(exp) + ")"
+		}
 		fmt.Fprintf(w, "%s\t%s\t%s\n",
 			c.Provider,
-			credType(c),
+			typ,
 			formatAge(c.CreatedAt),
 		)
 	}
@@ -129,8 +148,32 @@ func credType(c credential.Credential) string {
 	switch c.Provider {
 	case credential.ProviderAWS:
 		return "role"
-	case credential.ProviderGitHub:
+	case credential.ProviderAzure:
+		if c.Metadata != nil && c.Metadata[azure.MetaKeyClientID] != "" {
+			return "service-principal"
+		}
+		return "az-cli"
+	case credential.ProviderGitHub, credential.ProviderAzureDevOps:
 		return "token"
+	case credential.ProviderSnowflake:
+		return "key-pair"
+	case credential.ProviderBigQuery, credential.ProviderGCP:
+		if c.Metadata != nil && c.Metadata["auth_type"] == "service_account" {
+			return "service-account"
+		}
+		return "oauth"
+	case credential.ProviderTwilio:
+		if c.Metadata != nil && c.Metadata["api_key_sid"] != "" {
+			return "api-key"
+		}
+		return "auth-token"
+	case credential.ProviderSendGrid:
+		return "api-key"
+	case credential.ProviderStripe:
+		if c.Metadata != nil && c.Metadata["test_mode_only"] == "true" {
+			return "api-key (test only)"
+		}
+		return "api-key"
 	case credential.ProviderClaude:
 		return "oauth"
 	case credential.ProviderAnthropic:
@@ -146,6 +189,41 @@ func credType(c credential.Credential) string {
 			return fmt.Sprintf("%d registries", entries)
 		}
 		return "registry"
+	case credential.ProviderRegistry:
+		registries, err := registry.UnmarshalRegistries(c.Token)
+		if err == nil && len(registries) > 1 {
+			return fmt.Sprintf("%d registries", len(registries))
+		}
+		if err == nil && len(registries) == 1 {
+			return registries[0].Type
+		}
+		return "token"
+	case credential.ProviderContainerRegistry:
+		registries, err := containerregistry.UnmarshalRegistries(c.Token)
+		if err == nil && len(registries) > 1 {
+			return fmt.Sprintf("%d registries", len(registries))
+		}
+		if err == nil && len(registries) == 1 {
+			return registries[0].Host
+		}
+		return "password"
+	case credential.ProviderAtlassian:
+		accounts, err := atlassian.UnmarshalAccounts(c.Token)
+		if err != nil || len(accounts) == 0 {
+			return "token"
+		}
+		if len(accounts) > 1 {
+			return fmt.Sprintf("%d products", len(accounts))
+		}
+		if accounts[0].IsOAuth() {
+			return "oauth"
+		}
+		return "app-password"
+	case credential.ProviderGerrit, credential.ProviderBitbucketServer:
+		if instances, err := githttp.UnmarshalInstances(c.Token); err == nil && len(instances) > 1 {
+			return fmt.Sprintf("%d servers", len(instances))
+		}
+		return "server"
 	default:
 		// Accept both "mcp:<name>" (canonical) and "mcp-<name>" (deprecated).
 		if mcpcatalog.IsGrant(string(c.Provider)) {
@@ -3,10 +3,12 @@ package cli
 
 import (
 	"fmt"
+	"os"
 	"strings"
 	"time"
 
 	"github.com/majorcontext/moat/internal/credential"
+	"github.com/majorcontext/moat/internal/provider/util"
 	"github.com/spf13/cobra"
 )
 
@@ -56,17 +58,25 @@ func runGrantMCP(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("invalid server name: %q contains invalid characters", name)
 	}
 
-	fmt.Printf("Enter credential for MCP server '%s'\n", name)
-	fmt.Printf("This will be stored as grant 'mcp:%s'\n\n", name)
-	fmt.Print("Credential: ")
-
-	credBytes, err := readPassword()
-	if err != nil {
-		return fmt.Errorf("reading credential: %w", err)
+	var credentialStr string
+	if opts := util.GrantOptionsFrom(cmd.Context()); opts.EnvOnly || opts.NonInteractive {
+		credentialStr = os.Getenv("MOAT_MCP_CREDENTIAL")
+		if credentialStr == "" {
+			return fmt.Errorf("%w: set MOAT_MCP_CREDENTIAL", util.ErrInputRequired)
+		}
+		fmt.Println("Using credential from MOAT_MCP_CREDENTIAL environment variable")
+	} else {
+		fmt.Printf("Enter credential for MCP server '%s'\n", name)
+		fmt.Printf("This will be stored as grant 'mcp:%s'\n\n", name)
+		fmt.Print("Credential: ")
+
+		credBytes, err := readPassword()
+		if err != nil {
+			return fmt.Errorf("reading credential: %w", err)
+		}
+		fmt.Println() // newline after hidden input
+		credentialStr = strings.TrimSpace(string(credBytes))
 	}
-	fmt.Println() // newline after hidden input
-
-	credentialStr := strings.TrimSpace(string(credBytes))
 	if credentialStr == "" {
 		return fmt.Errorf("no credential provided")
 	}
@@ -0,0 +1,112 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/majorcontext/moat/internal/credential"
+	"github.com/majorcontext/moat/internal/credential/keyring"
+	"github.com/spf13/cobra"
+)
+
+var (
+	migrateKeyFrom        string
+	migrateKeyTo          string
+	migrateKeyAgeIdentity string
+	migrateKeyKMSKey      string
+)
+
+var grantMigrateKeyCmd = &cobra.Command{
+	Use:   "migrate-key",
+	Short: "Move the credential store key to another key provider",
+	Long: `Move the credential store encryption key between key providers.
+
+Key providers:
+  keychain   System keychain, falling back to ~/.moat/encryption.key (default)
+  file       ~/.moat/encryption.key only; never touches the keychain
+  age        Key file encrypted to an age identity (requires the age CLI)
+  aws-kms    Key file encrypted with an AWS KMS key (requires the aws CLI)
+  gcp-kms    Key file encrypted with a Cloud KMS key (requires gcloud)
+
+The key itself does not change, so stored credentials stay readable. It is
+removed from the old provider only after it reads back from the new one.
+
+--to defaults to the provider set by credentials.key_provider in
+~/.moat/config.yaml, and --from defaults to keychain. Set key_provider first,
+then run this command to move an existing key into it.
+
+Examples:
+  # After setting key_provider: age and age_identity in config.yaml
+  moat grant migrate-key
+
+  # Move the key from the keychain to an AWS KMS-wrapped key file
+  moat grant migrate-key --to aws-kms --kms-key alias/moat
+
+  # Move back from age to the keychain
+  moat grant migrate-key --from age --age-identity ~/.config/moat/age.txt --to keychain`,
+	Args: cobra.NoArgs,
+	RunE: runGrantMigrateKey,
+}
+
+func init() {
+	grantCmd.AddCommand(grantMigrateKeyCmd)
+	grantMigrateKeyCmd.Flags().StringVar(&migrateKeyFrom, "from", keyring.ModeKeychain, "key provider the key is in now")
+	grantMigrateKeyCmd.Flags().StringVar(&migrateKeyTo, "to", "", "key provider to move the key to (default: credentials.key_provider)")
+	grantMigrateKeyCmd.Flags().StringVar(&migrateKeyAgeIdentity, "age-identity", "", "age identity file for the age key provider")
+	grantMigrateKeyCmd.Flags().StringVar(&migrateKeyKMSKey, "kms-key", "", "KMS key for the aws-kms or gcp-kms key provider")
+}
+
+func runGrantMigrateKey(cmd *cobra.Command, args []string) error {
+	configured, err := credential.ConfiguredKeyProvider()
+	if err != nil {
+		return err
+	}
+	if configured.Mode == "" {
+		configured.Mode = keyring.ModeKeychain
+	}
+	from := migrateKeyProvider(migrateKeyFrom, configured)
+	to := configured
+	if migrateKeyTo != "" {
+		to = migrateKeyProvider(migrateKeyTo, configured)
+	}
+	if from == to {
+		return fmt.Errorf("the key is already configured for %s\n\nPass --to, or set credentials.key_provider in ~/.moat/config.yaml", to)
+	}
+
+	if err := keyring.MigrateKey(from, to); err != nil {
+		return fmt.Errorf("migrating credential store key: %w", err)
+	}
+	fmt.Printf("Moved credential store key from %s to %s\n", from, to)
+
+	if to != configured {
+		fmt.Println("\nSelect the new key provider in ~/.moat/config.yaml:")
+		fmt.Println("  credentials:")
+		fmt.Printf("    key_provider: %s\n", to.Mode)
+		switch to.Mode {
+		case keyring.ModeAge:
+			fmt.Printf("    age_identity: %s\n", to.AgeIdentity)
+		case keyring.ModeAWSKMS, keyring.ModeGCPKMS:
+			fmt.Printf("    kms_key: %s\n", to.KMSKey)
+		}
+	}
+	return nil
+}
+
+// migrateKeyProvider builds the key provider for mode. Settings come from the
+// flags, falling back to the configured provider when it uses the same mode.
+func migrateKeyProvider(mode string, configured keyring.KeyProvider) keyring.KeyProvider {
+	p := keyring.KeyProvider{Mode: mode}
+	if mode == configured.Mode {
+		p = configured
+	}
+	switch mode {
+	case keyring.ModeAge:
+		if migrateKeyAgeIdentity != "" {
+			p.AgeIdentity = migrateKeyAgeIdentity
+		}
+	case keyring.ModeAWSKMS, keyring.ModeGCPKMS:
+		if migrateKeyKMSKey != "" {
+			p.KMSKey = migrateKeyKMSKey
+		}
+	}
+	return p
+}
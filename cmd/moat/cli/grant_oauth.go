@@ -10,6 +10,7 @@ import (
 	"github.com/majorcontext/moat/internal/credential"
 	"github.com/majorcontext/moat/internal/log"
 	"github.com/majorcontext/moat/internal/mcpcatalog"
+	"github.com/majorcontext/moat/internal/provider/util"
 	"github.com/majorcontext/moat/internal/providers/oauth"
 	"github.com/spf13/cobra"
 )
@@ -133,6 +134,11 @@ func runGrantOAuth(cmd *cobra.Command, args []string) error {
 			"See: https://majorcontext.com/moat/guides/mcp", name, cfgPath)
 	}
 
+	// The flow needs a browser sign-in, which a script cannot complete.
+	if err := util.RequireInput(ctx, "OAuth grants need a browser sign-in; run 'moat grant oauth "+name+"' interactively"); err != nil {
+		return err
+	}
+
 	// Run the OAuth flow (includes DCR if RegistrationEndpoint is set)
 	provCred, err := oauth.RunGrant(ctx, name, cfg, resource)
 	if err != nil {
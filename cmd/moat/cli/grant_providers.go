@@ -30,13 +30,26 @@ func init() {
 // goProviderDescriptions provides descriptions for Go-implemented providers
 // that don't implement DescribableProvider.
 var goProviderDescriptions = map[string]string{
-	"github":   "GitHub token",
-	"claude":   "Anthropic API key or OAuth credentials",
-	"codex":    "OpenAI API key or OAuth credentials",
-	"gemini":   "Gemini API key or OAuth credentials",
-	"aws":      "AWS IAM role assumption",
-	"npm":      "npm registry credentials",
-	"graphite": "Graphite API token for stacked PRs",
+	"github":             "GitHub token",
+	"claude":             "Anthropic API key or OAuth credentials",
+	"codex":              "OpenAI API key or OAuth credentials",
+	"gemini":             "Gemini API key or OAuth credentials",
+	"aws":                "AWS IAM role assumption",
+	"npm":                "npm registry credentials",
+	"registry":           "Private npm registry and PyPI index tokens",
+	"container-registry": "Container registry credentials for docker pull (Docker Hub, ghcr.io)",
+	"graphite":           "Graphite API token for stacked PRs",
+	"gerrit":             "Self-hosted Gerrit HTTP credentials",
+	"bitbucket-server":   "Self-hosted Bitbucket Server/Data Center access token",
+	"atlassian":          "Bitbucket Cloud app password or OAuth, Atlassian Cloud OAuth (refreshed)",
+	"azure":              "Azure tokens from host az login (managed identity endpoint)",
+	"azure-devops":       "Azure DevOps personal access token",
+	"snowflake":          "Snowflake key-pair JWTs for the SQL and REST APIs",
+	"bigquery":           "BigQuery OAuth tokens from Google application default credentials",
+	"gcp":                "Google Cloud tokens from application default credentials (metadata server)",
+	"stripe":             "Stripe secret or restricted key, optionally test mode only",
+	"twilio":             "Twilio auth token or API key, with a per-run send cap",
+	"sendgrid":           "SendGrid API key, with a per-run send cap",
 }
 
 // goProviderCLINames maps internal provider names to their CLI-facing names.
@@ -0,0 +1,76 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/majorcontext/moat/internal/credential"
+	"github.com/majorcontext/moat/internal/provider"
+	"github.com/majorcontext/moat/internal/providers/registry"
+	"github.com/spf13/cobra"
+)
+
+var grantRegistryCmd = &cobra.Command{
+	Use:   "registry <url>",
+	Short: "Grant private npm registry and PyPI index credentials",
+	Long: `Grant a token for a private npm registry or PyPI index.
+
+The proxy injects the token for the registry's host, and runs get a
+generated npm and pip configuration pointing at the registry. The token never
+enters the container. It is read from REGISTRY_TOKEN when set, otherwise
+prompted for.
+
+For npm, the URL is the registry root. Without --scope the registry replaces
+registry.npmjs.org; with --scope only those scopes resolve from it. For PyPI,
+the URL is the simple index. It replaces pypi.org unless --extra is given.
+PyPI tokens are sent with HTTP Basic auth as user __token__ unless --username
+is set.
+
+Each registry is granted separately; granting one keeps the others.
+
+Examples:
+  moat grant registry https://npm.corp.com/ --type npm --scope @corp
+  moat grant registry https://pypi.corp.com/simple/ --type pypi
+  moat grant registry https://corp.jfrog.io/artifactory/api/pypi/pypi/simple/ --type pypi --username jdoe --extra
+  moat run --grant registry ./my-project`,
+	Args: cobra.ExactArgs(1),
+	RunE: runGrantRegistry,
+}
+
+var registryGrantOpts registry.GrantOptions
+
+func init() {
+	grantCmd.AddCommand(grantRegistryCmd)
+	f := grantRegistryCmd.Flags()
+	f.StringVar(&registryGrantOpts.Type, "type", "", "registry type: npm or pypi")
+	f.StringVar(&registryGrantOpts.Username, "username", "", "username for HTTP Basic auth (default: Bearer token for npm, __token__ for PyPI)")
+	f.StringSliceVar(&registryGrantOpts.Scopes, "scope", nil, "npm scopes to resolve from the registry (e.g. @corp)")
+	f.BoolVar(&registryGrantOpts.Extra, "extra", false, "add the PyPI index as an extra index instead of replacing pypi.org")
+}
+
+func runGrantRegistry(cmd *cobra.Command, args []string) error {
+	prov := provider.Get(string(credential.ProviderRegistry))
+	if prov == nil {
+		return fmt.Errorf("registry provider not registered")
+	}
+
+	opts := registryGrantOpts
+	opts.URL = args[0]
+	ctx := registry.WithGrantOptions(cmd.Context(), opts)
+	provCred, err := prov.Grant(ctx)
+	if err != nil {
+		return err
+	}
+
+	cred := credential.Credential{
+		Provider:  credential.ProviderRegistry,
+		Token:     provCred.Token,
+		CreatedAt: provCred.CreatedAt,
+		Metadata:  provCred.Metadata,
+	}
+	credPath, err := saveCredential(cred)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Credential saved to %s\n", credPath)
+	return nil
+}
@@ -8,6 +8,17 @@ import (
 	"strings"
 
 	"github.com/majorcontext/moat/internal/credential"
+	"github.com/majorcontext/moat/internal/providers/atlassian"
+	"github.com/majorcontext/moat/internal/providers/azure"
+	"github.com/majorcontext/moat/internal/providers/azuredevops"
+	"github.com/majorcontext/moat/internal/providers/bigquery"
+	"github.com/majorcontext/moat/internal/providers/containerregistry"
+	"github.com/majorcontext/moat/internal/providers/gcp"
+	"github.com/majorcontext/moat/internal/providers/githttp"
+	"github.com/majorcontext/moat/internal/providers/registry"
+	"github.com/majorcontext/moat/internal/providers/snowflake"
+	"github.com/majorcontext/moat/internal/providers/stripe"
+	"github.com/majorcontext/moat/internal/providers/twilio"
 	"github.com/majorcontext/moat/internal/ui"
 	"github.com/spf13/cobra"
 )
@@ -89,9 +100,34 @@ func showCredentialTable(cred *credential.Credential) error {
 		fmt.Fprintf(os.Stdout, "%s    %s\n", ui.Bold("Scopes:"), strings.Join(cred.Scopes, ", "))
 	}
 
+	// Self-hosted git servers keep their URLs in the token, not metadata.
+	if cred.Provider == credential.ProviderGerrit || cred.Provider == credential.ProviderBitbucketServer {
+		showGitHTTPServers(cred.Token)
+	}
+	if cred.Provider == credential.ProviderAtlassian {
+		showAtlassianAccounts(cred.Token)
+	}
+	if cred.Provider == credential.ProviderRegistry {
+		showPackageRegistries(cred.Token)
+	}
+	if cred.Provider == credential.ProviderContainerRegistry {
+		showContainerRegistries(cred.Token)
+	}
+
 	// Provider-specific metadata
 	showProviderMetadata(cred)
 
+	if cred.Restrict != nil {
+		fmt.Fprintf(os.Stdout, "%s   %s\n", ui.Bold("Only in:"), cred.Restrict)
+	}
+	if exp := cred.GrantExpiry(); !exp.IsZero() {
+		fmt.Fprintf(os.Stdout, "%s   %s %s\n",
+			ui.Bold("Revokes:"),
+			exp.Format("2006-01-02T15:04:05Z07:00"),
+			ui.Dim("("+formatUntil(exp)+")"),
+		)
+	}
+
 	fmt.Fprintf(os.Stdout, "%s   %s %s\n",
 		ui.Bold("Granted:"),
 		cred.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
@@ -108,14 +144,29 @@ func showCredentialTable(cred *credential.Credential) error {
 		fmt.Fprintf(os.Stdout, "%s   %s\n", ui.Bold("Expires:"), ui.Dim("never"))
 	}
 
-	// AWS token is the role ARN, already shown as "Role" above
-	if cred.Provider != credential.ProviderAWS {
+	// AWS token is the role ARN, already shown as "Role" above; the Azure
+	// token is the tenant ID, shown as "Tenant", unless it is a service
+	// principal's client secret
+	if tokenIsSecret(cred) {
 		fmt.Fprintf(os.Stdout, "%s     %s\n", ui.Bold("Token:"), redactToken(cred.Token))
 	}
 
 	return nil
 }
 
+// tokenIsSecret reports whether cred.Token must be redacted. AWS stores a role
+// ARN there and Azure az CLI grants a tenant ID; Azure service principals
+// store their client secret.
+func tokenIsSecret(cred *credential.Credential) bool {
+	switch cred.Provider {
+	case credential.ProviderAWS:
+		return false
+	case credential.ProviderAzure:
+		return cred.Metadata[azure.MetaKeyClientID] != ""
+	}
+	return true
+}
+
 func showProviderMetadata(cred *credential.Credential) {
 	if cred.Metadata == nil {
 		return
@@ -136,6 +187,51 @@ func showProviderMetadata(cred *credential.Credential) {
 		}
 	case credential.ProviderNpm:
 		showNpmRegistries(cred.Token)
+	case credential.ProviderAzure:
+		fmt.Fprintf(os.Stdout, "%s    %s\n", ui.Bold("Tenant:"), cred.Metadata[azure.MetaKeyTenant])
+		if v := cred.Metadata[azure.MetaKeySubscription]; v != "" {
+			fmt.Fprintf(os.Stdout, "%s      %s\n", ui.Bold("Subs:"), v)
+		}
+		if v := cred.Metadata[azure.MetaKeyUser]; v != "" {
+			fmt.Fprintf(os.Stdout, "%s   %s\n", ui.Bold("Account:"), v)
+		}
+		if v := cred.Metadata[azure.MetaKeyClientID]; v != "" {
+			fmt.Fprintf(os.Stdout, "%s    %s (service principal)\n", ui.Bold("Client:"), v)
+		}
+	case credential.ProviderSnowflake:
+		fmt.Fprintf(os.Stdout, "%s   %s\n", ui.Bold("Account:"), cred.Metadata[snowflake.MetaKeyAccount])
+		fmt.Fprintf(os.Stdout, "%s      %s\n", ui.Bold("User:"), cred.Metadata[snowflake.MetaKeyUser])
+		if v := cred.Metadata[snowflake.MetaKeyFingerprint]; v != "" {
+			fmt.Fprintf(os.Stdout, "%s       %s\n", ui.Bold("Key:"), v)
+		}
+	case credential.ProviderBigQuery, credential.ProviderGCP:
+		fmt.Fprintf(os.Stdout, "%s      %s\n", ui.Bold("Auth:"), cred.Metadata[bigquery.MetaKeyAuthType])
+		if v := cred.Metadata[bigquery.MetaKeyClientEmail]; v != "" {
+			fmt.Fprintf(os.Stdout, "%s   %s\n", ui.Bold("Account:"), v)
+		} else if v := cred.Metadata[gcp.MetaKeyEmail]; v != "" {
+			fmt.Fprintf(os.Stdout, "%s   %s\n", ui.Bold("Account:"), v)
+		}
+		if v := cred.Metadata[bigquery.MetaKeyProject]; v != "" {
+			fmt.Fprintf(os.Stdout, "%s   %s\n", ui.Bold("Project:"), v)
+		}
+	case credential.ProviderStripe:
+		mode := "live"
+		if stripe.IsTestKey(cred.Token) {
+			mode = "test"
+		}
+		if cred.Metadata[stripe.MetaKeyTestModeOnly] == "true" {
+			mode += " (test mode only; live-mode responses blocked)"
+		}
+		fmt.Fprintf(os.Stdout, "%s      %s\n", ui.Bold("Mode:"), mode)
+	case credential.ProviderTwilio:
+		fmt.Fprintf(os.Stdout, "%s   %s\n", ui.Bold("Account:"), cred.Metadata[twilio.MetaKeyAccountSID])
+		if v := cred.Metadata[twilio.MetaKeyAPIKeySID]; v != "" {
+			fmt.Fprintf(os.Stdout, "%s   %s\n", ui.Bold("API key:"), v)
+		}
+	case credential.ProviderAzureDevOps:
+		if v := cred.Metadata[azuredevops.MetaKeyOrganizations]; v != "" {
+			fmt.Fprintf(os.Stdout, "%s      %s\n", ui.Bold("Orgs:"), strings.ReplaceAll(v, ",", ", "))
+		}
 	default:
 		// Show auth_type if present (e.g., for openai/gemini OAuth)
 		if v := cred.Metadata["auth_type"]; v != "" {
@@ -160,16 +256,72 @@ func showNpmRegistries(token string) {
 	}
 }
 
+func showGitHTTPServers(token string) {
+	instances, err := githttp.UnmarshalInstances(token)
+	if err != nil {
+		return
+	}
+	for _, inst := range instances {
+		fmt.Fprintf(os.Stdout, "%s    %s %s\n", ui.Bold("Server:"), inst.URL, ui.Dim("("+inst.Username+")"))
+	}
+}
+
+func showAtlassianAccounts(token string) {
+	accounts, err := atlassian.UnmarshalAccounts(token)
+	if err != nil {
+		return
+	}
+	for _, acct := range accounts {
+		detail := "app password, " + acct.Username
+		if acct.IsOAuth() {
+			detail = "oauth"
+			if !acct.ExpiresAt.IsZero() {
+				detail += ", token expires " + acct.ExpiresAt.Format("2006-01-02T15:04:05Z07:00")
+			}
+		}
+		fmt.Fprintf(os.Stdout, "%s      %s %s\n", ui.Bold("Host:"), acct.Host(), ui.Dim("("+detail+")"))
+	}
+}
+
+func showPackageRegistries(token string) {
+	registries, err := registry.UnmarshalRegistries(token)
+	if err != nil {
+		return
+	}
+	for _, r := range registries {
+		detail := r.Type
+		switch {
+		case len(r.Scopes) > 0:
+			detail += ", " + strings.Join(r.Scopes, ", ")
+		case r.Extra:
+			detail += ", extra index"
+		}
+		fmt.Fprintf(os.Stdout, "%s  %s %s\n", ui.Bold("Registry:"), r.URL, ui.Dim("("+detail+")"))
+	}
+}
+
+func showContainerRegistries(token string) {
+	registries, err := containerregistry.UnmarshalRegistries(token)
+	if err != nil {
+		return
+	}
+	for _, r := range registries {
+		fmt.Fprintf(os.Stdout, "%s  %s %s\n", ui.Bold("Registry:"), r.Host, ui.Dim("("+r.Username+")"))
+	}
+}
+
 func showCredentialJSON(cred *credential.Credential) error {
 	type jsonOutput struct {
-		Provider  string            `json:"provider"`
-		Type      string            `json:"type"`
-		Source    string            `json:"source,omitempty"`
-		Scopes    []string          `json:"scopes,omitempty"`
-		Metadata  map[string]string `json:"metadata,omitempty"`
-		GrantedAt string            `json:"granted_at"`
-		ExpiresAt string            `json:"expires_at,omitempty"`
-		Token     string            `json:"token,omitempty"`
+		Provider  string                  `json:"provider"`
+		Type      string                  `json:"type"`
+		Source    string                  `json:"source,omitempty"`
+		Scopes    []string                `json:"scopes,omitempty"`
+		Metadata  map[string]string       `json:"metadata,omitempty"`
+		GrantedAt string                  `json:"granted_at"`
+		ExpiresAt string                  `json:"expires_at,omitempty"`
+		Token     string                  `json:"token,omitempty"`
+		Restrict  *credential.Restriction `json:"restrict,omitempty"`
+		RevokesAt string                  `json:"revokes_at,omitempty"`
 	}
 
 	out := jsonOutput{
@@ -178,19 +330,22 @@ func showCredentialJSON(cred *credential.Credential) error {
 		Source:    cred.Metadata[credential.MetaKeyTokenSource],
 		Scopes:    cred.Scopes,
 		GrantedAt: cred.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+		Restrict:  cred.Restrict,
 	}
 
 	if !cred.ExpiresAt.IsZero() {
 		out.ExpiresAt = cred.ExpiresAt.Format("2006-01-02T15:04:05Z07:00")
 	}
+	if exp := cred.GrantExpiry(); !exp.IsZero() {
+		out.RevokesAt = exp.Format("2006-01-02T15:04:05Z07:00")
+	}
 
 	// Include safe metadata (exclude secrets like refresh_token, client_secret)
 	out.Metadata = filterMetadata(cred.Metadata)
 
-	// AWS token is the role ARN (not a secret) — always include it
-	if cred.Provider == credential.ProviderAWS {
-		out.Token = cred.Token
-	} else if showToken {
+	// AWS token is the role ARN and Azure's the tenant ID (not secrets) —
+	// always include them
+	if !tokenIsSecret(cred) || showToken {
 		out.Token = cred.Token
 	}
 
@@ -213,6 +368,7 @@ func filterMetadata(m map[string]string) map[string]string {
 		"token_url":                   true,
 		"meta_app_id":                 true,
 		"meta_app_secret":             true,
+		"private_key":                 true,
 		credential.MetaKeyTokenSource: true, // already shown as top-level "source"
 	}
 
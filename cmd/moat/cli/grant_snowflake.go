@@ -0,0 +1,68 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/majorcontext/moat/internal/credential"
+	"github.com/majorcontext/moat/internal/provider"
+	"github.com/majorcontext/moat/internal/providers/snowflake"
+	"github.com/spf13/cobra"
+)
+
+var grantSnowflakeCmd = &cobra.Command{
+	Use:   "snowflake",
+	Short: "Grant Snowflake access with key-pair authentication",
+	Long: `Grant Snowflake access using a user's RSA key pair.
+
+The private key stays on the host. For each run, the proxy signs short-lived
+JWTs with it and injects them for <account>.snowflakecomputing.com, so the
+agent can use the SQL API (/api/v2/statements) and REST API without seeing
+the key. The container gets SNOWFLAKE_ACCOUNT, SNOWFLAKE_USER, and
+SNOWFLAKE_HOST.
+
+The public key must be set on the user first:
+  ALTER USER <user> SET RSA_PUBLIC_KEY='MIIBIjANBgkqh...';
+
+Flags fall back to SNOWFLAKE_ACCOUNT, SNOWFLAKE_USER, and
+SNOWFLAKE_PRIVATE_KEY_PATH. The key must be an unencrypted PEM file.
+
+Examples:
+  moat grant snowflake --account myorg-myaccount --user AGENT --private-key ~/.snowflake/rsa_key.p8
+  moat run --grant snowflake ./my-project`,
+	RunE: runGrantSnowflake,
+}
+
+var snowflakeOpts snowflake.GrantOptions
+
+func init() {
+	grantCmd.AddCommand(grantSnowflakeCmd)
+	grantSnowflakeCmd.Flags().StringVar(&snowflakeOpts.Account, "account", "", "Snowflake account identifier (e.g., myorg-myaccount)")
+	grantSnowflakeCmd.Flags().StringVar(&snowflakeOpts.User, "user", "", "Snowflake user the key is registered to")
+	grantSnowflakeCmd.Flags().StringVar(&snowflakeOpts.PrivateKeyPath, "private-key", "", "Path to the unencrypted PEM private key")
+}
+
+func runGrantSnowflake(cmd *cobra.Command, args []string) error {
+	prov := provider.Get(string(credential.ProviderSnowflake))
+	if prov == nil {
+		return fmt.Errorf("snowflake provider not registered")
+	}
+
+	ctx := snowflake.WithGrantOptions(cmd.Context(), snowflakeOpts)
+	provCred, err := prov.Grant(ctx)
+	if err != nil {
+		return err
+	}
+
+	cred := credential.Credential{
+		Provider:  credential.ProviderSnowflake,
+		Token:     provCred.Token,
+		CreatedAt: provCred.CreatedAt,
+		Metadata:  provCred.Metadata,
+	}
+	credPath, err := saveCredential(cred)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Credential saved to %s\n", credPath)
+	return nil
+}
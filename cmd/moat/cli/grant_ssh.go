@@ -2,15 +2,21 @@
 package cli
 
 import (
+	"bufio"
+	"context"
 	"encoding/base64"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/majorcontext/moat/internal/credential"
+	"github.com/majorcontext/moat/internal/deps"
+	"github.com/majorcontext/moat/internal/provider/util"
 	"github.com/majorcontext/moat/internal/sshagent"
 	"github.com/spf13/cobra"
+	"golang.org/x/crypto/ssh"
 )
 
 var grantSSHCmd = &cobra.Command{
@@ -21,6 +27,11 @@ var grantSSHCmd = &cobra.Command{
 The container will be able to use SSH keys to authenticate to the specified host.
 By default, uses the first key from your SSH agent. Use --key to specify a different key.
 
+The host's SSH host keys are fetched and pinned at grant time. They are
+verified against moat's built-in keys for GitHub, GitLab, and Bitbucket, or
+against ~/.ssh/known_hosts; otherwise you are asked to confirm their
+fingerprints. Containers only trust the pinned keys.
+
 Examples:
   # Grant SSH access to github.com using the default key
   moat grant ssh --host github.com
@@ -121,11 +132,17 @@ func runGrantSSH(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("opening credential store: %w", err)
 	}
 
+	hostKeys, err := pinSSHHostKeys(cmd.Context(), sshHost)
+	if err != nil {
+		return err
+	}
+
 	// Store the mapping
 	mapping := credential.SSHMapping{
 		Host:           sshHost,
 		KeyFingerprint: selectedKey.Fingerprint(),
 		KeyPath:        sshKeyPath,
+		HostKeys:       hostKeys,
 	}
 	if err := store.AddSSHMapping(mapping); err != nil {
 		return fmt.Errorf("storing SSH mapping: %w", err)
@@ -136,11 +153,86 @@ func runGrantSSH(cmd *cobra.Command, args []string) error {
 	if selectedKey.Comment != "" {
 		fmt.Printf("  Comment: %s\n", selectedKey.Comment)
 	}
+	fmt.Printf("  Host keys pinned: %d\n", len(hostKeys))
 	fmt.Printf("\nUse in runs with: moat run --grant ssh:%s\n", sshHost)
 
 	return nil
 }
 
+// pinSSHHostKeys fetches host's SSH host keys and returns the verified ones
+// as known_hosts entries. Keys are verified against moat's embedded keys for
+// well-known hosts, then ~/.ssh/known_hosts, and otherwise confirmed by the
+// user on a terminal.
+func pinSSHHostKeys(ctx context.Context, host string) ([]string, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	builtin := deps.KnownSSHHostKeys(host)
+	fetched, err := sshagent.FetchHostKeys(ctx, host)
+	if err != nil {
+		if len(builtin) > 0 {
+			fmt.Printf("Could not reach %s to check its host keys; pinning moat's built-in keys\n", host)
+			return builtin, nil
+		}
+		return nil, fmt.Errorf("%w\n\n"+
+			"moat pins the host's SSH keys at grant time so containers can verify them.\n"+
+			"Check that %s is reachable on port 22 and try again.", err, host)
+	}
+
+	if len(builtin) > 0 {
+		matched := sshagent.MatchTrustedKeys(fetched, builtin)
+		if len(matched) == 0 {
+			return nil, fmt.Errorf("host keys presented by %s do not match its published keys\n\n"+
+				"Someone may be intercepting the connection. Not granting access.", host)
+		}
+		return knownHostsLines(host, matched), nil
+	}
+
+	home, _ := os.UserHomeDir()
+	knownHostsPath := filepath.Join(home, ".ssh", "known_hosts")
+	matched, verdict, err := sshagent.VerifyHostKeys(host, fetched, knownHostsPath)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", knownHostsPath, err)
+	}
+	switch verdict {
+	case sshagent.HostKeyMatch:
+		return knownHostsLines(host, matched), nil
+	case sshagent.HostKeyMismatch:
+		return nil, fmt.Errorf("host keys presented by %s do not match %s\n\n"+
+			"Someone may be intercepting the connection, or the host's keys changed.\n"+
+			"Verify the new keys with the host's operator before updating known_hosts.", host, knownHostsPath)
+	}
+
+	fmt.Printf("No trusted host key is recorded for %s. It presented:\n", host)
+	for _, key := range fetched {
+		fmt.Printf("  %s\n", sshagent.HostKeyFingerprint(key))
+	}
+	if util.GrantOptionsFrom(ctx).NonInteractive || !stdinIsInteractive() {
+		return nil, fmt.Errorf("%w: cannot verify host keys for %s without a terminal\n\n"+
+			"Verify the fingerprints above, add the host to %s (e.g. by running 'ssh %s' once),\n"+
+			"then grant again.", util.ErrInputRequired, host, knownHostsPath, host)
+	}
+	fmt.Print("Trust and pin these host keys? [y/N]: ")
+	reader := bufio.NewReader(os.Stdin)
+	response, _ := reader.ReadString('\n')
+	response = strings.TrimSpace(strings.ToLower(response))
+	if response != "y" && response != "yes" {
+		return nil, fmt.Errorf("host keys for %s not trusted; SSH access not granted", host)
+	}
+	return knownHostsLines(host, fetched), nil
+}
+
+func knownHostsLines(host string, keys []ssh.PublicKey) []string {
+	lines := make([]string, len(keys))
+	for i, key := range keys {
+		lines[i] = sshagent.KnownHostsLine(host, key)
+	}
+	return lines
+}
+
 func expandPath(path string) string {
 	if strings.HasPrefix(path, "~/") {
 		home, err := os.UserHomeDir()
@@ -0,0 +1,67 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/majorcontext/moat/internal/credential"
+	"github.com/majorcontext/moat/internal/provider"
+	"github.com/majorcontext/moat/internal/providers/stripe"
+	"github.com/spf13/cobra"
+)
+
+var grantStripeCmd = &cobra.Command{
+	Use:   "stripe",
+	Short: "Grant a Stripe API key",
+	Long: `Grant a Stripe secret key (sk_) or restricted key (rk_).
+
+The key is read from STRIPE_API_KEY or STRIPE_SECRET_KEY, or prompted for,
+and validated against the Stripe API. The proxy injects it for
+api.stripe.com and files.stripe.com; the container gets a placeholder
+STRIPE_API_KEY.
+
+With --test-mode-only, live-mode keys (sk_live_, rk_live_) are rejected
+when the grant is saved, and the proxy refuses any request that would run
+in live mode before forwarding it.
+
+Examples:
+  moat grant stripe --test-mode-only
+  STRIPE_API_KEY=rk_test_... moat grant stripe --test-mode-only
+  moat run --grant stripe ./my-project`,
+	RunE: runGrantStripe,
+}
+
+var stripeTestModeOnly bool
+
+func init() {
+	grantCmd.AddCommand(grantStripeCmd)
+	grantStripeCmd.Flags().BoolVar(&stripeTestModeOnly, "test-mode-only", false, "Accept only test-mode keys and block live-mode requests at the proxy")
+}
+
+func runGrantStripe(cmd *cobra.Command, args []string) error {
+	prov := provider.Get(string(credential.ProviderStripe))
+	if prov == nil {
+		return fmt.Errorf("stripe provider not registered")
+	}
+
+	ctx := stripe.WithGrantOptions(cmd.Context(), stripeTestModeOnly)
+	provCred, err := prov.Grant(ctx)
+	if err != nil {
+		return err
+	}
+	if err := stripe.CheckKey(provCred); err != nil {
+		return err
+	}
+
+	cred := credential.Credential{
+		Provider:  credential.ProviderStripe,
+		Token:     provCred.Token,
+		CreatedAt: provCred.CreatedAt,
+		Metadata:  provCred.Metadata,
+	}
+	credPath, err := saveCredential(cred)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Credential saved to %s\n", credPath)
+	return nil
+}
@@ -1,10 +1,19 @@
 package cli
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/majorcontext/moat/internal/credential"
+	"github.com/majorcontext/moat/internal/errcode"
+	"github.com/majorcontext/moat/internal/provider"
+	"github.com/majorcontext/moat/internal/provider/util"
+	"github.com/spf13/cobra"
 )
 
 func TestGrantMCP(t *testing.T) {
@@ -60,3 +69,77 @@ func TestGrantMCP(t *testing.T) {
 		t.Errorf("expected token 'test-api-key-123', got %q", cred.Token)
 	}
 }
+
+func TestApplyJSONFlags(t *testing.T) {
+	var host, role string
+	var keys []string
+	var duration int
+	newCmd := func() *cobra.Command {
+		host, role, keys, duration = "", "", nil, 0
+		cmd := &cobra.Command{Use: "test"}
+		cmd.Flags().StringVar(&host, "host", "", "")
+		cmd.Flags().StringVar(&role, "role", "", "")
+		cmd.Flags().StringSliceVar(&keys, "key", nil, "")
+		cmd.Flags().IntVar(&duration, "duration", 0, "")
+		return cmd
+	}
+	writeJSON := func(body string) string {
+		path := filepath.Join(t.TempDir(), "grant.json")
+		if err := os.WriteFile(path, []byte(body), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	cmd := newCmd()
+	if err := cmd.Flags().Set("role", "from-flag"); err != nil {
+		t.Fatal(err)
+	}
+	path := writeJSON(`{"host": "github.com", "role": "from-json", "key": ["a", "b"], "duration": 3600}`)
+	if err := applyJSONFlags(cmd, path); err != nil {
+		t.Fatalf("applyJSONFlags: %v", err)
+	}
+	if host != "github.com" || role != "from-flag" || strings.Join(keys, ",") != "a,b" || duration != 3600 {
+		t.Errorf("got host=%q role=%q key=%v duration=%d", host, role, keys, duration)
+	}
+
+	for name, body := range map[string]string{
+		"unknown flag": `{"nope": "x"}`,
+		"not object":   `["host"]`,
+		"bad value":    `{"duration": "soon"}`,
+	} {
+		if err := applyJSONFlags(newCmd(), writeJSON(body)); !errors.Is(err, errInvalidGrantInput) {
+			t.Errorf("%s: applyJSONFlags() = %v, want errInvalidGrantInput", name, err)
+		}
+	}
+}
+
+func TestWriteGrantErrorJSON(t *testing.T) {
+	tests := []struct {
+		name          string
+		err           error
+		wantCode      string
+		wantHint      string
+		wantErrorCode errcode.Code
+	}{
+		{"input required", fmt.Errorf("%w: set GITHUB_TOKEN", util.ErrInputRequired), "input_required", "", errcode.InputRequired},
+		{"unknown provider", fmt.Errorf("%w: nope", errUnknownProvider), "unknown_provider", "", errcode.ProviderNotFound},
+		{"invalid input", fmt.Errorf("%w: bad", errInvalidGrantInput), "invalid_input", "", ""},
+		{"grant failed", &provider.GrantError{Provider: "github", Cause: errors.New("invalid token"), Hint: "Create a new token"}, "grant_failed", "Create a new token", errcode.GrantFailed},
+		{"other", errors.New("boom"), "error", "", ""},
+	}
+	for _, tt := range tests {
+		var buf strings.Builder
+		writeGrantErrorJSON(&buf, "github", tt.err)
+		var got grantErrorJSON
+		if err := json.Unmarshal([]byte(buf.String()), &got); err != nil {
+			t.Fatalf("%s: output is not JSON: %v\n%s", tt.name, err, buf.String())
+		}
+		if got.Error.Code != tt.wantCode || got.Error.Hint != tt.wantHint || got.Error.Provider != "github" || got.Error.Message == "" {
+			t.Errorf("%s: got %+v, want code %q hint %q", tt.name, got.Error, tt.wantCode, tt.wantHint)
+		}
+		if got.Error.ErrorCode != tt.wantErrorCode {
+			t.Errorf("%s: error_code = %q, want %q", tt.name, got.Error.ErrorCode, tt.wantErrorCode)
+		}
+	}
+}
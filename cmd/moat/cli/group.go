@@ -0,0 +1,246 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/majorcontext/moat/internal/metering"
+	"github.com/majorcontext/moat/internal/run"
+	"github.com/majorcontext/moat/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var groupCleanForce bool
+
+var groupCmd = &cobra.Command{
+	Use:   "group",
+	Short: "List, stop, and clean groups of related runs",
+	Long: `Manage groups of related runs — a pipeline, a swarm of agents, or a
+supervisor and its workers — as a unit.
+
+Add a run to a group with --group when starting it:
+
+  moat run --group nightly-triage ./repo-a
+  moat claude --group nightly-triage ./repo-b
+
+Runs started with 'moat compose up' are grouped by compose project name.
+
+Without a subcommand, lists every group with its aggregate state and LLM
+cost.`,
+	Args: cobra.NoArgs,
+	RunE: runGroupList,
+}
+
+var groupStatusCmd = &cobra.Command{
+	Use:   "status <group>",
+	Short: "Show the runs of a group with aggregate state and cost",
+	Long: `Show the runs of a group and roll up their state and LLM usage.
+
+The group's state is running if any run is running, starting while any run
+is being created or started, failed if any run failed, and stopped once
+every run has stopped. Costs use the providers' list prices (see
+'moat cost export').`,
+	Args: cobra.ExactArgs(1),
+	RunE: runGroupStatus,
+}
+
+var groupStopCmd = &cobra.Command{
+	Use:   "stop <group>",
+	Short: "Stop every running run in a group",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runGroupStop,
+}
+
+var groupCleanCmd = &cobra.Command{
+	Use:   "clean <group>",
+	Short: "Destroy the stopped runs of a group",
+	Long: `Destroy the stopped and failed runs of a group and their resources.
+Running runs are left alone; stop them first with 'moat group stop'.
+
+Volume-mode runs without an extraction snapshot are skipped to protect
+un-extracted work, as with 'moat destroy'. Pass --force to destroy them too.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runGroupClean,
+}
+
+func init() {
+	rootCmd.AddCommand(groupCmd)
+	groupCmd.AddCommand(groupStatusCmd)
+	groupCmd.AddCommand(groupStopCmd)
+	groupCmd.AddCommand(groupCleanCmd)
+	groupCleanCmd.Flags().BoolVarP(&groupCleanForce, "force", "f", false, "also destroy volume-mode runs that have no extraction snapshot")
+}
+
+func runGroupList(cmd *cobra.Command, args []string) error {
+	manager, err := run.NewManager()
+	if err != nil {
+		return fmt.Errorf("creating run manager: %w", err)
+	}
+	defer manager.Close()
+
+	groups := manager.Groups(metering.DefaultPrices())
+	if jsonOut {
+		return json.NewEncoder(os.Stdout).Encode(groups)
+	}
+	if len(groups) == 0 {
+		fmt.Println("No run groups found")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "GROUP\tSTATE\tRUNS\tAGE\tCOST")
+	for _, g := range groups {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", g.ID, g.State, formatGroupRuns(g), formatAge(g.CreatedAt), formatGroupCost(g.Usage))
+	}
+	return w.Flush()
+}
+
+// groupStatusOutput is the --json shape of `moat group status`.
+type groupStatusOutput struct {
+	run.GroupSummary
+	Members []*run.Run `json:"members"`
+}
+
+func runGroupStatus(cmd *cobra.Command, args []string) error {
+	manager, err := run.NewManager()
+	if err != nil {
+		return fmt.Errorf("creating run manager: %w", err)
+	}
+	defer manager.Close()
+
+	runs, err := groupRuns(manager, args[0])
+	if err != nil {
+		return err
+	}
+	summary := run.SummarizeGroup(args[0], runs, metering.DefaultPrices())
+	if jsonOut {
+		return json.NewEncoder(os.Stdout).Encode(groupStatusOutput{GroupSummary: summary, Members: runs})
+	}
+
+	fmt.Printf("Group %s: %s, %s, %s\n\n", summary.ID, summary.State, formatGroupRuns(summary), formatGroupCost(summary.Usage))
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tRUN ID\tSTATE\tAGE")
+	for _, r := range runs {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", r.Name, r.ID, formatRunState(r), formatAge(r.CreatedAt))
+	}
+	return w.Flush()
+}
+
+func runGroupStop(cmd *cobra.Command, args []string) error {
+	manager, err := run.NewManager()
+	if err != nil {
+		return fmt.Errorf("creating run manager: %w", err)
+	}
+	defer manager.Close()
+
+	runs, err := groupRuns(manager, args[0])
+	if err != nil {
+		return err
+	}
+	if dryRun {
+		for _, r := range runs {
+			if r.GetState() == run.StateRunning {
+				fmt.Printf("Dry run - would stop run %s (%s)\n", r.ID, r.Name)
+			}
+		}
+		return nil
+	}
+
+	stopped, stopErr := manager.StopGroup(context.Background(), args[0])
+	for _, id := range stopped {
+		fmt.Printf("Run %s stopped\n", id)
+	}
+	if stopErr != nil {
+		return stopErr
+	}
+	if len(stopped) == 0 {
+		fmt.Printf("No running runs in group %s\n", args[0])
+	}
+	return nil
+}
+
+func runGroupClean(cmd *cobra.Command, args []string) error {
+	manager, err := run.NewManager()
+	if err != nil {
+		return fmt.Errorf("creating run manager: %w", err)
+	}
+	defer manager.Close()
+
+	runs, err := groupRuns(manager, args[0])
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	destroyed, skipped, running := 0, 0, 0
+	for _, r := range runs {
+		if state := r.GetState(); state != run.StateStopped && state != run.StateFailed {
+			running++
+			continue
+		}
+		if err := run.CheckDestroyAllowed(r.WorkspaceMode, hasExtractionSnapshot(r.ID), groupCleanForce); err != nil {
+			ui.Warnf("Skipping volume-mode run %s (%s): no extraction snapshot", r.Name, r.ID)
+			skipped++
+			continue
+		}
+		if dryRun {
+			fmt.Printf("Dry run - would destroy run %s (%s)\n", r.ID, r.Name)
+			continue
+		}
+		if err := manager.Destroy(ctx, r.ID); err != nil {
+			return fmt.Errorf("destroying run %s: %w", r.ID, err)
+		}
+		fmt.Printf("Run %s destroyed\n", r.ID)
+		destroyed++
+	}
+
+	if running > 0 {
+		ui.Infof("%d runs of group %s are still running; stop them with 'moat group stop %s'", running, args[0], args[0])
+	}
+	if skipped > 0 {
+		return fmt.Errorf("skipped %d volume-mode runs without an extraction snapshot; pass --force to destroy them", skipped)
+	}
+	return nil
+}
+
+// groupRuns returns the runs of a group, or an error if it has none.
+func groupRuns(manager *run.Manager, group string) ([]*run.Run, error) {
+	runs := manager.GroupRuns(group)
+	if len(runs) == 0 {
+		return nil, fmt.Errorf("no runs in group %q", group)
+	}
+	return runs, nil
+}
+
+// formatGroupRuns renders a group's run count with a breakdown by state,
+// e.g. "3 runs (2 running, 1 stopped)".
+func formatGroupRuns(g run.GroupSummary) string {
+	noun := "runs"
+	if g.Runs == 1 {
+		noun = "run"
+	}
+	states := make([]string, 0, len(g.States))
+	for state, n := range g.States {
+		states = append(states, fmt.Sprintf("%d %s", n, state))
+	}
+	slices.Sort(states)
+	return fmt.Sprintf("%d %s (%s)", g.Runs, noun, strings.Join(states, ", "))
+}
+
+// formatGroupCost renders a group's LLM cost, or "-" if it made no metered
+// requests.
+func formatGroupCost(t metering.Totals) string {
+	if t.Requests == 0 {
+		return "-"
+	}
+	cost := fmt.Sprintf("$%.2f", t.CostUSD)
+	if t.UnpricedRequests > 0 {
+		cost += "+"
+	}
+	return cost
+}
@@ -0,0 +1,117 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/majorcontext/moat/internal/config"
+	"github.com/majorcontext/moat/internal/run"
+	"github.com/majorcontext/moat/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var ideOpen string
+
+var ideCmd = &cobra.Command{
+	Use:   "ide [run]",
+	Short: "Attach an IDE to a running agent's container over SSH",
+	Long: `Print SSH connection details for a run started with ide.enabled, so VS Code
+(Remote - SSH), Cursor, or JetBrains Gateway can attach to the sandbox the
+agent is working in.
+
+The run's SSH server accepts only a key minted for that run and is published
+on a loopback port. moat writes a Host entry named moat-<run> to
+~/.moat/ide/ssh_config.d/; add this line to ~/.ssh/config once so editors
+can find it:
+
+  Include ~/.moat/ide/ssh_config.d/*
+
+If no argument is provided, uses the most recent running run with IDE attach.
+
+Examples:
+  moat ide                 # connection details for the latest run
+  moat ide my-agent        # a specific run
+  moat ide --open code     # open /workspace in VS Code over SSH
+  ssh moat-my-agent        # a shell in the container, after the Include`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runIDE,
+}
+
+func init() {
+	ideCmd.Flags().StringVar(&ideOpen, "open", "", "open /workspace in an editor over SSH (code or cursor)")
+	rootCmd.AddCommand(ideCmd)
+}
+
+func runIDE(cmd *cobra.Command, args []string) error {
+	if ideOpen != "" && ideOpen != "code" && ideOpen != "cursor" {
+		return fmt.Errorf("--open must be code or cursor, got %q", ideOpen)
+	}
+
+	manager, err := run.NewManager()
+	if err != nil {
+		return fmt.Errorf("creating run manager: %w", err)
+	}
+	defer manager.Close()
+
+	var runID string
+	if len(args) > 0 {
+		runID, err = resolveRunArgSingle(manager, args[0])
+		if err != nil {
+			return err
+		}
+	} else {
+		for _, r := range manager.List() {
+			if r.IDE && r.GetState() == run.StateRunning {
+				runID = r.ID
+				break
+			}
+		}
+		if runID == "" {
+			return fmt.Errorf("no running runs with IDE attach (set ide.enabled: true in moat.yaml)")
+		}
+	}
+
+	attach, err := manager.IDEAttach(context.Background(), runID)
+	if err != nil {
+		return err
+	}
+	configPath, err := writeIDESSHConfig(attach)
+	if err != nil {
+		return err
+	}
+
+	fmt.Print(attach.SSHConfig())
+	fmt.Println()
+	fmt.Printf("Wrote %s\n", shortenPath(configPath))
+	fmt.Printf("Connect with: ssh %s\n", attach.HostAlias)
+	fmt.Println(ui.Dim("Editors find the host after adding `Include ~/.moat/ide/ssh_config.d/*` to ~/.ssh/config."))
+
+	if ideOpen == "" {
+		return nil
+	}
+	bin, err := exec.LookPath(ideOpen)
+	if err != nil {
+		return fmt.Errorf("%s is not on PATH: %w", ideOpen, err)
+	}
+	c := exec.Command(bin, "--remote", "ssh-remote+"+attach.HostAlias, "/workspace")
+	c.Stdout = os.Stdout
+	c.Stderr = os.Stderr
+	return c.Run()
+}
+
+// writeIDESSHConfig writes the run's Host block where the documented
+// Include picks it up, and returns its path.
+func writeIDESSHConfig(attach run.IDEAttach) (string, error) {
+	dir := filepath.Join(config.GlobalConfigDir(), "ide", "ssh_config.d")
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", fmt.Errorf("creating SSH config directory: %w", err)
+	}
+	path := filepath.Join(dir, attach.HostAlias)
+	if err := os.WriteFile(path, []byte(attach.SSHConfig()), 0o600); err != nil {
+		return "", fmt.Errorf("writing SSH config: %w", err)
+	}
+	return path, nil
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/majorcontext/moat/internal/config"
 	"github.com/majorcontext/moat/internal/routing"
 	"github.com/majorcontext/moat/internal/run"
+	"github.com/majorcontext/moat/internal/testresult"
 	"github.com/majorcontext/moat/internal/ui"
 	"github.com/spf13/cobra"
 )
@@ -23,15 +24,44 @@ var listCmd = &cobra.Command{
 
 When any runs were started via 'moat wt', the output includes a WORKTREE
 column showing the branch name. Use 'moat wt list' to filter to worktree
-runs for the current repository only.`,
+runs for the current repository only.
+
+When test runner output (go test, jest, pytest) was detected in a run's
+logs, the output includes a TESTS column with pass/fail counts. The same
+counts appear as TestResults in --json output for CI gating.
+
+Failed runs show their failure class in the STATE column, e.g.
+"failed (oom_killed)". The class is also available as FailureClass in
+--json output.
+
+Filter runs by label with -l (repeatable; all must match). A bare key
+matches any run that has the label:
+
+  moat list -l team=payments
+  moat list -l team=payments -l ticket
+
+Filter runs by group with --group. Runs in a group show it in the GROUP
+column; see 'moat group' for group-wide status and stop.`,
 	RunE: listRuns,
 }
 
+var (
+	listLabelSelector []string
+	listGroup         string
+)
+
 func init() {
+	listCmd.Flags().StringArrayVarP(&listLabelSelector, "label", "l", nil, "filter by label (KEY=VALUE or KEY, repeatable)")
+	listCmd.Flags().StringVar(&listGroup, "group", "", "only list runs in this group")
 	rootCmd.AddCommand(listCmd)
 }
 
 func listRuns(cmd *cobra.Command, args []string) error {
+	sel, err := run.ParseLabelSelector(listLabelSelector)
+	if err != nil {
+		return err
+	}
+
 	manager, err := run.NewManager()
 	if err != nil {
 		return fmt.Errorf("creating run manager: %w", err)
@@ -39,6 +69,15 @@ func listRuns(cmd *cobra.Command, args []string) error {
 	defer manager.Close()
 
 	runs := manager.List()
+	if sel != nil || listGroup != "" {
+		filtered := runs[:0]
+		for _, r := range runs {
+			if run.MatchesLabels(r.Labels, sel) && (listGroup == "" || r.Group == listGroup) {
+				filtered = append(filtered, r)
+			}
+		}
+		runs = filtered
+	}
 
 	// Sort runs by age (newest first)
 	sort.Slice(runs, func(i, j int) bool {
@@ -59,19 +98,41 @@ func listRuns(cmd *cobra.Command, args []string) error {
 	// shown in the WORKTREE column — a path without a branch name isn't
 	// useful to show.
 	hasWorktree := false
+	hasTests := false
+	hasLabels := false
+	hasGroups := false
 	for _, r := range runs {
+		if len(r.Labels) > 0 {
+			hasLabels = true
+		}
+		if r.Group != "" {
+			hasGroups = true
+		}
 		if r.WorktreeBranch != "" {
 			hasWorktree = true
-			break
+		}
+		if r.GetTestResults() != nil {
+			hasTests = true
 		}
 	}
 
-	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	header := []string{"NAME", "RUN ID", "RUNTIME", "STATE", "AGE"}
+	if hasGroups {
+		header = append(header, "GROUP")
+	}
 	if hasWorktree {
-		fmt.Fprintln(w, "NAME\tRUN ID\tRUNTIME\tSTATE\tAGE\tWORKTREE\tENDPOINTS")
-	} else {
-		fmt.Fprintln(w, "NAME\tRUN ID\tRUNTIME\tSTATE\tAGE\tENDPOINTS")
+		header = append(header, "WORKTREE")
+	}
+	if hasTests {
+		header = append(header, "TESTS")
+	}
+	if hasLabels {
+		header = append(header, "LABELS")
 	}
+	header = append(header, "ENDPOINTS")
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, strings.Join(header, "\t"))
 	for _, r := range runs {
 		endpoints := ""
 		if len(r.Ports) > 0 {
@@ -86,30 +147,21 @@ func listRuns(cmd *cobra.Command, args []string) error {
 		if rtLabel == "" {
 			rtLabel = "-"
 		}
+		row := []string{r.Name, r.ID, rtLabel, formatRunState(r), formatAge(r.CreatedAt)}
+		if hasGroups {
+			row = append(row, r.Group)
+		}
 		if hasWorktree {
-			wt := ""
-			if r.WorktreeBranch != "" {
-				wt = r.WorktreeBranch
-			}
-			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
-				r.Name,
-				r.ID,
-				rtLabel,
-				r.GetState(),
-				formatAge(r.CreatedAt),
-				wt,
-				endpoints,
-			)
-		} else {
-			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
-				r.Name,
-				r.ID,
-				rtLabel,
-				r.GetState(),
-				formatAge(r.CreatedAt),
-				endpoints,
-			)
+			row = append(row, r.WorktreeBranch)
 		}
+		if hasTests {
+			row = append(row, formatTestResults(r.GetTestResults()))
+		}
+		if hasLabels {
+			row = append(row, run.FormatLabels(r.Labels))
+		}
+		row = append(row, endpoints)
+		fmt.Fprintln(w, strings.Join(row, "\t"))
 	}
 	if err := w.Flush(); err != nil {
 		return err
@@ -133,3 +185,22 @@ func listRuns(cmd *cobra.Command, args []string) error {
 
 	return nil
 }
+
+// formatRunState renders the STATE column, appending the failure class for
+// failed runs that have one, e.g. "failed (oom_killed)".
+func formatRunState(r *run.Run) string {
+	state := string(r.GetState())
+	if class := r.GetFailureClass(); class != "" && r.GetState() == run.StateFailed {
+		state += " (" + string(class) + ")"
+	}
+	return state
+}
+
+// formatTestResults renders a run's test summary for the TESTS column,
+// e.g. "12 passed, 1 failed". Runs without detected test output show "-".
+func formatTestResults(s *testresult.Summary) string {
+	if s == nil {
+		return "-"
+	}
+	return s.String()
+}
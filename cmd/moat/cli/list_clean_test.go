@@ -28,6 +28,10 @@ func (s *listCleanStubRuntime) Ping(ctx context.Context) error {
 	panic("unexpected call to Ping")
 }
 
+func (s *listCleanStubRuntime) Version(ctx context.Context) (string, error) {
+	panic("unexpected call to Version")
+}
+
 func (s *listCleanStubRuntime) CreateContainer(ctx context.Context, cfg container.Config) (string, error) {
 	panic("unexpected call to CreateContainer")
 }
@@ -103,6 +107,9 @@ func (s *listCleanStubRuntime) Close() error { panic("unexpected call to Close")
 func (s *listCleanStubRuntime) SetupFirewall(ctx context.Context, id string, proxyHost string, proxyPort int) error {
 	panic("unexpected call to SetupFirewall")
 }
+func (s *listCleanStubRuntime) RestrictServiceAccess(ctx context.Context, id string, rules []container.ServiceAccess) error {
+	panic("unexpected call to RestrictServiceAccess")
+}
 
 func (s *listCleanStubRuntime) ListImages(ctx context.Context) ([]container.ImageInfo, error) {
 	panic("unexpected call to ListImages")
@@ -112,6 +119,22 @@ func (s *listCleanStubRuntime) ContainerState(ctx context.Context, id string) (s
 	panic("unexpected call to ContainerState")
 }
 
+func (s *listCleanStubRuntime) UserNamespaceMode(context.Context) (string, error) {
+	panic("unexpected call to UserNamespaceMode")
+}
+
+func (s *listCleanStubRuntime) UpdatePriority(context.Context, string, container.Priority) error {
+	return nil
+}
+
+func (s *listCleanStubRuntime) ContainerOOMKilled(ctx context.Context, id string) (bool, error) {
+	panic("unexpected call to ContainerOOMKilled")
+}
+
+func (s *listCleanStubRuntime) ContainerStats(context.Context, string) (container.Stats, error) {
+	return container.Stats{}, container.ErrStatsUnsupported
+}
+
 func (s *listCleanStubRuntime) RemoveImage(ctx context.Context, id string) error {
 	panic("unexpected call to RemoveImage")
 }
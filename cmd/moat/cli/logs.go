@@ -1,11 +1,19 @@
 package cli
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"sort"
+	"syscall"
 	"time"
 
+	"github.com/majorcontext/moat/internal/config"
+	"github.com/majorcontext/moat/internal/daemon"
+	"github.com/majorcontext/moat/internal/errcode"
 	"github.com/majorcontext/moat/internal/log"
 	"github.com/majorcontext/moat/internal/run"
 	"github.com/majorcontext/moat/internal/storage"
@@ -24,6 +32,11 @@ var logsCmd = &cobra.Command{
 	Long: `View logs from a run. Accepts a run ID or name.
 If no argument is specified, shows logs from the most recent run.
 
+With --follow, streams the run's output until it exits or Ctrl-C. Live
+output comes from the proxy daemon, so following works after the terminal
+that started the run has closed. Logs of a stopped run are printed as
+recorded.
+
 Examples:
   moat logs                    # Logs from most recent run
   moat logs my-agent           # Logs from run by name
@@ -75,20 +88,59 @@ func runLogs(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("reading logs: %w", err)
 	}
 
-	// Warn up front, before the log flood, so -f doesn't look like it streamed.
 	if logsFollow {
-		ui.Warn("follow mode (-f) is not yet supported; showing current logs only")
+		err := followLogs(runID)
+		if !errors.Is(err, daemon.ErrRunNotFound) && errcode.Of(err) != errcode.DaemonUnavailable {
+			return err
+		}
+		// The run is not active in the daemon, so there is nothing to follow.
+		ui.Warn("run is not running; showing recorded logs only")
+	} else if len(entries) == 0 {
+		// logs.jsonl is written when the container exits; while it runs,
+		// the daemon can read its output from the container runtime.
+		if streamed, err := daemonLogs(runID); err == nil && len(streamed) > 0 {
+			entries = streamed
+		}
 	}
 
 	log.Info("displaying logs", "runID", runID)
 	for _, entry := range entries {
-		ts := entry.Timestamp.Format("15:04:05.000")
-		fmt.Printf("[%s] %s\n", ts, entry.Line)
+		printLogEntry(entry)
 	}
 
 	return nil
 }
 
+// followLogs streams a run's logs from the proxy daemon, which reads them
+// from the container runtime, until the container exits or Ctrl-C.
+func followLogs(runID string) error {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	client := daemon.NewClient(filepath.Join(config.GlobalConfigDir(), "proxy", "daemon.sock"))
+	err := client.StreamLogs(ctx, runID, true, logsLines, printLogEntry)
+	if errors.Is(err, daemon.ErrStreamUnsupported) {
+		return fmt.Errorf("the running proxy daemon is too old to stream logs; run 'moat proxy restart' to upgrade it")
+	}
+	return err
+}
+
+// daemonLogs returns the last logsLines entries of a running run's output
+// from the proxy daemon.
+func daemonLogs(runID string) ([]storage.LogEntry, error) {
+	var entries []storage.LogEntry
+	client := daemon.NewClient(filepath.Join(config.GlobalConfigDir(), "proxy", "daemon.sock"))
+	err := client.StreamLogs(context.Background(), runID, false, logsLines, func(e storage.LogEntry) {
+		entries = append(entries, e)
+	})
+	return entries, err
+}
+
+func printLogEntry(entry storage.LogEntry) {
+	ts := entry.Timestamp.Local().Format("15:04:05.000")
+	fmt.Printf("[%s] %s\n", ts, entry.Line)
+}
+
 // findLatestRun finds the most recently modified run directory.
 func findLatestRun(baseDir string) (string, error) {
 	entries, err := os.ReadDir(baseDir)
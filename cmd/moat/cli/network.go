@@ -0,0 +1,239 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/majorcontext/moat/internal/config"
+	"github.com/majorcontext/moat/internal/daemon"
+	"github.com/majorcontext/moat/internal/run"
+	"github.com/majorcontext/moat/internal/storage"
+	"github.com/spf13/cobra"
+)
+
+var (
+	networkFollow bool
+	networkFilter requestFilter
+)
+
+var networkCmd = &cobra.Command{
+	Use:     "network [run]",
+	Aliases: []string{"net"},
+	Short:   "View a run's proxied requests",
+	Long: `View the requests a run's proxy handled: method, host, path, status,
+credential grant used, bytes, and duration. Accepts a run ID or name.
+If no argument is specified, uses the most recent run.
+
+With --follow, streams requests from the proxy daemon as they happen until
+the run stops or you press Ctrl-C. Only new requests are streamed; run
+without --follow to see earlier ones.
+
+Examples:
+  moat network                         # Requests from most recent run
+  moat network my-agent --follow       # Stream requests in real time
+  moat network -f --host '*.github.com'
+  moat network -f --status 4xx         # Only client errors
+  moat network -f --denied             # Only blocked requests
+  moat network -f --json               # One JSON object per line`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runNetwork,
+}
+
+func init() {
+	rootCmd.AddCommand(networkCmd)
+	networkCmd.Flags().BoolVarP(&networkFollow, "follow", "f", false, "stream requests as the proxy handles them")
+	networkCmd.Flags().StringVar(&networkFilter.Host, "host", "", "only show requests to hosts matching this pattern (e.g. '*.github.com')")
+	networkCmd.Flags().StringVar(&networkFilter.Method, "method", "", "only show requests with this HTTP method")
+	networkCmd.Flags().StringVar(&networkFilter.Status, "status", "", "only show responses with this status: a code (404), a class (4xx), or 'error'")
+	networkCmd.Flags().StringVar(&networkFilter.Grant, "grant", "", "only show requests that used this credential grant")
+	networkCmd.Flags().BoolVar(&networkFilter.Denied, "denied", false, "only show requests the proxy blocked")
+}
+
+func runNetwork(_ *cobra.Command, args []string) error {
+	if err := networkFilter.validate(); err != nil {
+		return err
+	}
+
+	manager, err := run.NewManager()
+	if err != nil {
+		return fmt.Errorf("creating run manager: %w", err)
+	}
+	defer manager.Close()
+
+	baseDir := storage.DefaultBaseDir()
+	var runID string
+	if len(args) > 0 {
+		runID, err = resolveRunArgSingle(manager, args[0])
+	} else {
+		runID, err = findLatestRun(baseDir)
+	}
+	if err != nil {
+		return err
+	}
+
+	if networkFollow {
+		return followNetwork(runID)
+	}
+
+	store, err := storage.NewRunStore(baseDir, runID)
+	if err != nil {
+		return fmt.Errorf("opening run storage: %w", err)
+	}
+	decisions, err := store.ReadDecisions()
+	if err != nil {
+		return fmt.Errorf("reading decisions: %w", err)
+	}
+	shown := 0
+	for _, d := range decisions {
+		if !networkFilter.match(d) {
+			continue
+		}
+		printNetworkRequest(d)
+		shown++
+	}
+	if shown == 0 && !jsonOut {
+		fmt.Println("No matching requests recorded")
+	}
+	return nil
+}
+
+// followNetwork streams runID's requests from the proxy daemon until the run
+// unregisters or the user interrupts.
+func followNetwork(runID string) error {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	sockPath := filepath.Join(config.GlobalConfigDir(), "proxy", "daemon.sock")
+	client := daemon.NewClient(sockPath)
+	if !jsonOut {
+		fmt.Fprintf(os.Stderr, "Following requests for %s (Ctrl-C to stop)\n", runID)
+	}
+	err := client.StreamRequests(ctx, runID, func(ev daemon.RequestEvent) {
+		if networkFilter.match(ev.Decision) {
+			printNetworkRequest(ev.Decision)
+		}
+	})
+	switch {
+	case errors.Is(err, daemon.ErrRunNotFound):
+		return fmt.Errorf("run %s is not active in the proxy daemon; run without --follow to see its recorded requests", runID)
+	case errors.Is(err, daemon.ErrStreamUnsupported):
+		return fmt.Errorf("the running proxy daemon is too old to stream requests; run 'moat proxy restart' to upgrade it")
+	case err != nil:
+		return err
+	}
+	return nil
+}
+
+// printNetworkRequest prints one request as a line of text, or as a JSON
+// object per line with --json so the output can be piped while following.
+func printNetworkRequest(d storage.Decision) {
+	if jsonOut {
+		data, _ := json.Marshal(d)
+		fmt.Println(string(data))
+		return
+	}
+
+	status := strconv.Itoa(d.StatusCode)
+	switch {
+	case d.Decision == "deny":
+		status = "DENY"
+	case d.Error != "":
+		status = "ERR"
+	}
+	grant := "-"
+	if len(d.Grants) > 0 {
+		grant = strings.Join(d.Grants, ",")
+	}
+	method := d.Method
+	if method == "" {
+		method = strings.ToUpper(d.Type)
+	}
+	fmt.Printf("[%s] %-7s %-4s %s%s  grant=%s  %s/%s  %dms\n",
+		d.Timestamp.Format("15:04:05.000"), method, status, d.Host, d.Path, grant,
+		formatNetworkBytes(d.RequestBytes), formatNetworkBytes(d.ResponseBytes), d.Duration)
+}
+
+func formatNetworkBytes(n int64) string {
+	if n < 0 {
+		return "?"
+	}
+	return strconv.FormatInt(n, 10) + "B"
+}
+
+// requestFilter selects which requests `moat network` shows. Zero-valued
+// fields match everything.
+type requestFilter struct {
+	Host   string // glob matched against the host, e.g. "*.github.com"
+	Method string
+	Status string // "404", "4xx", or "error"
+	Grant  string
+	Denied bool
+}
+
+func (f requestFilter) validate() error {
+	if f.Host != "" {
+		if _, err := path.Match(f.Host, ""); err != nil {
+			return fmt.Errorf("invalid --host pattern %q: %w", f.Host, err)
+		}
+	}
+	switch s := strings.ToLower(f.Status); {
+	case s == "", s == "error":
+	case len(s) == 3 && s[0] >= '1' && s[0] <= '5' && s[1:] == "xx":
+	default:
+		if code, err := strconv.Atoi(s); err != nil || code < 100 || code > 599 {
+			return fmt.Errorf("invalid --status %q: use a code (404), a class (4xx), or 'error'", f.Status)
+		}
+	}
+	return nil
+}
+
+func (f requestFilter) match(d storage.Decision) bool {
+	if f.Host != "" {
+		if ok, _ := path.Match(strings.ToLower(f.Host), strings.ToLower(d.Host)); !ok {
+			return false
+		}
+	}
+	if f.Method != "" && !strings.EqualFold(f.Method, d.Method) {
+		return false
+	}
+	if f.Denied && d.Decision != "deny" {
+		return false
+	}
+	if f.Grant != "" && !containsFold(d.Grants, f.Grant) {
+		return false
+	}
+	switch s := strings.ToLower(f.Status); {
+	case s == "":
+	case s == "error":
+		if d.Error == "" {
+			return false
+		}
+	case strings.HasSuffix(s, "xx"):
+		if d.StatusCode/100 != int(s[0]-'0') {
+			return false
+		}
+	default:
+		if strconv.Itoa(d.StatusCode) != s {
+			return false
+		}
+	}
+	return true
+}
+
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
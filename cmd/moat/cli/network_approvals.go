@@ -0,0 +1,115 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/majorcontext/moat/internal/config"
+	"github.com/majorcontext/moat/internal/daemon"
+	"github.com/majorcontext/moat/internal/run"
+	"github.com/spf13/cobra"
+)
+
+var networkApprovalsCmd = &cobra.Command{
+	Use:   "approvals [run]",
+	Short: "List network approvals runs have requested",
+	Long: `List the network approvals runs requested with 'moatctl approve'. Runs
+with network.policy: strict can ask for a host they are not allowed to
+reach; the request waits until you approve or deny it, or it times out.
+Accepts a run ID or name to show only that run's approvals.
+
+Examples:
+  moat network approvals               # Approvals from all active runs
+  moat network approve apr_1a2b3c      # Allow the host for that run
+  moat network deny apr_1a2b3c`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runNetworkApprovals,
+}
+
+var networkApproveCmd = &cobra.Command{
+	Use:   "approve <id>",
+	Short: "Approve a run's network approval request",
+	Long: `Approve a pending network approval. The requested host is added to the
+run's network rules and allowed from the next request on. The rule lasts
+for the run only; add it to network.rules in moat.yaml to keep it.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(_ *cobra.Command, args []string) error {
+		return decideApproval(args[0], true)
+	},
+}
+
+var networkDenyCmd = &cobra.Command{
+	Use:   "deny <id>",
+	Short: "Deny a run's network approval request",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(_ *cobra.Command, args []string) error {
+		return decideApproval(args[0], false)
+	},
+}
+
+func init() {
+	networkCmd.AddCommand(networkApprovalsCmd, networkApproveCmd, networkDenyCmd)
+}
+
+func approvalsClient() *daemon.Client {
+	return daemon.NewClient(filepath.Join(config.GlobalConfigDir(), "proxy", "daemon.sock"))
+}
+
+func runNetworkApprovals(_ *cobra.Command, args []string) error {
+	var runID string
+	if len(args) > 0 {
+		manager, err := run.NewManager()
+		if err != nil {
+			return fmt.Errorf("creating run manager: %w", err)
+		}
+		defer manager.Close()
+		if runID, err = resolveRunArgSingle(manager, args[0]); err != nil {
+			return err
+		}
+	}
+
+	approvals, err := approvalsClient().ListApprovals(context.Background(), runID)
+	if err != nil {
+		return fmt.Errorf("listing approvals: %w", err)
+	}
+	if jsonOut {
+		if approvals == nil {
+			approvals = []daemon.Approval{}
+		}
+		return json.NewEncoder(os.Stdout).Encode(approvals)
+	}
+	if len(approvals) == 0 {
+		fmt.Println("No network approvals requested")
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tRUN\tHOST\tSTATUS\tREQUESTED\tREASON")
+	for _, a := range approvals {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			a.ID, a.RunID, a.Host, a.Status, formatAge(a.RequestedAt), a.Reason)
+	}
+	return w.Flush()
+}
+
+func decideApproval(approvalID string, approve bool) error {
+	a, err := approvalsClient().DecideApproval(context.Background(), approvalID, approve)
+	switch {
+	case errors.Is(err, daemon.ErrApprovalNotFound):
+		return fmt.Errorf("no network approval %s; run 'moat network approvals' to list them", approvalID)
+	case errors.Is(err, daemon.ErrApprovalDecided):
+		return fmt.Errorf("network approval %s was already %s", approvalID, a.Status)
+	case err != nil:
+		return fmt.Errorf("deciding approval: %w", err)
+	}
+	if approve {
+		fmt.Printf("Approved %s for %s\n", a.Host, a.RunID)
+	} else {
+		fmt.Printf("Denied %s for %s\n", a.Host, a.RunID)
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/majorcontext/moat/internal/har"
+	"github.com/majorcontext/moat/internal/run"
+	"github.com/majorcontext/moat/internal/storage"
+	"github.com/majorcontext/moat/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var (
+	networkExportHAR    bool
+	networkExportOutput string
+)
+
+var networkExportCmd = &cobra.Command{
+	Use:   "export [run]",
+	Short: "Export a run's network trace",
+	Long: `Export the requests a run's proxy handled in a standard format. Accepts a
+run ID or name. If no argument is specified, uses the most recent run.
+
+--har writes an HTTP Archive (HAR 1.2) file that Chrome DevTools, Firefox,
+Fiddler, and Charles can load. Headers and request bodies are included only
+for runs with network.capture: full in moat.yaml; credentials are redacted
+when the trace is recorded.
+
+Examples:
+  moat network export --har > run.har
+  moat net export my-agent --har -o my-agent.har`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runNetworkExport,
+}
+
+func init() {
+	networkCmd.AddCommand(networkExportCmd)
+	networkExportCmd.Flags().BoolVar(&networkExportHAR, "har", false, "write an HTTP Archive (HAR) file")
+	networkExportCmd.Flags().StringVarP(&networkExportOutput, "output", "o", "", "write the export to a file instead of stdout")
+}
+
+func runNetworkExport(_ *cobra.Command, args []string) error {
+	if !networkExportHAR {
+		return fmt.Errorf("choose an export format: --har")
+	}
+
+	manager, err := run.NewManager()
+	if err != nil {
+		return fmt.Errorf("creating run manager: %w", err)
+	}
+	defer manager.Close()
+
+	baseDir := storage.DefaultBaseDir()
+	var runID string
+	if len(args) > 0 {
+		runID, err = resolveRunArgSingle(manager, args[0])
+	} else {
+		runID, err = findLatestRun(baseDir)
+	}
+	if err != nil {
+		return err
+	}
+
+	store, err := storage.NewRunStore(baseDir, runID)
+	if err != nil {
+		return fmt.Errorf("opening run storage: %w", err)
+	}
+	reqs, err := store.ReadNetworkRequests()
+	if err != nil {
+		return fmt.Errorf("reading network requests: %w", err)
+	}
+
+	var w io.Writer = os.Stdout
+	if networkExportOutput != "" {
+		f, createErr := os.Create(networkExportOutput)
+		if createErr != nil {
+			return fmt.Errorf("creating output file: %w", createErr)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(har.FromNetworkRequests(reqs, version)); err != nil {
+		return fmt.Errorf("writing HAR: %w", err)
+	}
+	if networkExportOutput != "" {
+		fmt.Fprintf(os.Stderr, "Wrote %d requests to %s\n", len(reqs), networkExportOutput)
+	} else if len(reqs) == 0 {
+		fmt.Fprintln(os.Stderr, ui.Dim("No network requests recorded for "+runID))
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/majorcontext/moat/internal/storage"
+)
+
+func TestRequestFilter(t *testing.T) {
+	ok := storage.Decision{Method: "GET", Host: "api.github.com", Decision: "allow", Grants: []string{"github"}, StatusCode: 200}
+	notFound := storage.Decision{Method: "POST", Host: "registry.npmjs.org", Decision: "allow", StatusCode: 404}
+	denied := storage.Decision{Method: "GET", Host: "evil.example.com", Decision: "deny", StatusCode: 407}
+	failed := storage.Decision{Method: "GET", Host: "api.openai.com", Decision: "allow", Error: "connection reset"}
+
+	tests := []struct {
+		name   string
+		filter requestFilter
+		want   []storage.Decision
+	}{
+		{"empty matches all", requestFilter{}, []storage.Decision{ok, notFound, denied, failed}},
+		{"host glob", requestFilter{Host: "*.GitHub.com"}, []storage.Decision{ok}},
+		{"method", requestFilter{Method: "post"}, []storage.Decision{notFound}},
+		{"status code", requestFilter{Status: "404"}, []storage.Decision{notFound}},
+		{"status class", requestFilter{Status: "4XX"}, []storage.Decision{notFound, denied}},
+		{"status error", requestFilter{Status: "error"}, []storage.Decision{failed}},
+		{"grant", requestFilter{Grant: "GitHub"}, []storage.Decision{ok}},
+		{"denied", requestFilter{Denied: true}, []storage.Decision{denied}},
+		{"combined", requestFilter{Method: "GET", Status: "2xx"}, []storage.Decision{ok}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.filter.validate(); err != nil {
+				t.Fatalf("validate: %v", err)
+			}
+			var got []storage.Decision
+			for _, d := range []storage.Decision{ok, notFound, denied, failed} {
+				if tt.filter.match(d) {
+					got = append(got, d)
+				}
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("matched %d requests, want %d: %+v", len(got), len(tt.want), got)
+			}
+			for i := range got {
+				if got[i].Host != tt.want[i].Host {
+					t.Errorf("match[%d] = %s, want %s", i, got[i].Host, tt.want[i].Host)
+				}
+			}
+		})
+	}
+}
+
+func TestRequestFilterValidate(t *testing.T) {
+	for _, f := range []requestFilter{
+		{Host: "[bad"},
+		{Status: "teapot"},
+		{Status: "6xx"},
+		{Status: "99"},
+	} {
+		if err := f.validate(); err == nil {
+			t.Errorf("validate(%+v) = nil, want error", f)
+		}
+	}
+}
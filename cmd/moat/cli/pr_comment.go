@@ -0,0 +1,114 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/majorcontext/moat/internal/config"
+	"github.com/majorcontext/moat/internal/run"
+	"github.com/majorcontext/moat/internal/storage"
+	"github.com/majorcontext/moat/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var (
+	prCommentPR        string
+	prCommentBundleURL string
+)
+
+var prCommentCmd = &cobra.Command{
+	Use:   "pr-comment [run]",
+	Short: "Post a run summary comment to a pull request",
+	Long: `Post a summary of a run to its pull request: the workspace diff stats since
+the run started, the commands it ran, its test results, its LLM cost, and its
+exported audit bundle. A later run on the same pull request updates the
+comment instead of posting another.
+Accepts a run ID or name. If no argument is specified, uses the most recent run.
+
+The pull request is the one given with --pr, else the run's own --pr, else
+the open pull request for the run's worktree branch. The comment is posted
+with the run's github grant through the credential-injecting proxy. The audit
+bundle is written to <run-id>.proof.json in the run directory; --bundle-url
+links the comment to wherever you publish it.
+
+Set pr_comment.enabled in moat.yaml, or start the run with --pr, to post
+automatically when a run ends.
+
+Examples:
+  moat pr-comment                          # Most recent run
+  moat pr-comment my-agent --pr 42
+  moat pr-comment --pr https://github.com/acme/api/pull/42`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runPRComment,
+}
+
+func init() {
+	rootCmd.AddCommand(prCommentCmd)
+	prCommentCmd.Flags().StringVar(&prCommentPR, "pr", "", "pull request number or URL (default: the run's pull request)")
+	prCommentCmd.Flags().StringVar(&prCommentBundleURL, "bundle-url", "", "URL the audit bundle is published at, linked from the comment ({run} expands to the run ID)")
+}
+
+func runPRComment(cmd *cobra.Command, args []string) error {
+	baseDir := storage.DefaultBaseDir()
+	var runID string
+	if len(args) > 0 {
+		manager, err := run.NewManager()
+		if err != nil {
+			return fmt.Errorf("creating run manager: %w", err)
+		}
+		defer manager.Close()
+
+		runID, err = resolveRunArgSingle(manager, args[0])
+		if err != nil {
+			return err
+		}
+	} else {
+		var err error
+		runID, err = findLatestRun(baseDir)
+		if err != nil {
+			return err
+		}
+	}
+	if prCommentPR != "" {
+		if _, _, err := run.ParsePullRequest(prCommentPR); err != nil {
+			return fmt.Errorf("--pr: %w", err)
+		}
+	}
+
+	store, err := storage.NewRunStore(baseDir, runID)
+	if err != nil {
+		return fmt.Errorf("opening run storage: %w", err)
+	}
+	url, err := run.PostPRComment(cmd.Context(), store, run.PRCommentOptions{
+		PR:        prCommentPR,
+		BundleURL: prCommentBundleURL,
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Println(url)
+	return nil
+}
+
+// commentOnPRAfterRun posts the run summary to the run's pull request when
+// the run was started with --pr or moat.yaml enables pr_comment. Failures
+// are reported as warnings: the run itself is done.
+func commentOnPRAfterRun(ctx context.Context, cfg *config.Config, r *run.Run) {
+	if r.Store == nil {
+		return
+	}
+	var opts run.PRCommentOptions
+	if cfg != nil {
+		opts.BundleURL = cfg.PRComment.BundleURL
+	}
+	if r.PullRequest == "" && (cfg == nil || !cfg.PRComment.Enabled) {
+		return
+	}
+	ui.Status("Posting run summary to the pull request...")
+	url, err := run.PostPRComment(ctx, r.Store, opts)
+	if err != nil {
+		ui.Warnf("PR comment not posted: %v", err)
+		return
+	}
+	ui.Statusf("PR comment: %s", url)
+}
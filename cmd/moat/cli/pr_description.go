@@ -0,0 +1,108 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/majorcontext/moat/internal/config"
+	"github.com/majorcontext/moat/internal/run"
+	"github.com/majorcontext/moat/internal/storage"
+	"github.com/majorcontext/moat/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var (
+	prDescriptionGrant string
+	prDescriptionModel string
+)
+
+var prDescriptionCmd = &cobra.Command{
+	Use:   "pr-description [run]",
+	Short: "Draft a pull request description for a run",
+	Long: `Draft a pull request description from what a run changed: the workspace
+diff since the run started, the commands it ran, and the results of its test
+commands. The draft is written to pr-description.md in the run directory and
+printed to stdout.
+Accepts a run ID or name. If no argument is specified, uses the most recent run.
+
+The model is called with the run's own LLM grant (claude, anthropic, openai,
+or codex) through the credential-injecting proxy. Set pr_description.enabled
+in moat.yaml to draft automatically when a run ends.
+
+Examples:
+  moat pr-description                          # Most recent run
+  moat pr-description my-agent --grant openai  # Use the run's OpenAI grant
+  moat pr-description | gh pr create --title "..." --body-file -`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runPRDescription,
+}
+
+func init() {
+	rootCmd.AddCommand(prDescriptionCmd)
+	prDescriptionCmd.Flags().StringVar(&prDescriptionGrant, "grant", "", "LLM grant to draft with (default: the run's first LLM grant)")
+	prDescriptionCmd.Flags().StringVar(&prDescriptionModel, "model", "", "model to draft with (default depends on the grant)")
+}
+
+func runPRDescription(cmd *cobra.Command, args []string) error {
+	baseDir := storage.DefaultBaseDir()
+	var runID string
+	if len(args) > 0 {
+		manager, err := run.NewManager()
+		if err != nil {
+			return fmt.Errorf("creating run manager: %w", err)
+		}
+		defer manager.Close()
+
+		runID, err = resolveRunArgSingle(manager, args[0])
+		if err != nil {
+			return err
+		}
+	} else {
+		var err error
+		runID, err = findLatestRun(baseDir)
+		if err != nil {
+			return err
+		}
+	}
+
+	store, err := storage.NewRunStore(baseDir, runID)
+	if err != nil {
+		return fmt.Errorf("opening run storage: %w", err)
+	}
+	path, err := run.DraftPRDescription(cmd.Context(), store, run.PRDescriptionOptions{
+		Grant: prDescriptionGrant,
+		Model: prDescriptionModel,
+	})
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	fmt.Print(string(data))
+	return nil
+}
+
+// draftPRDescriptionAfterRun drafts a PR description for a finished run when
+// moat.yaml enables pr_description. Failures are reported as warnings: the
+// run itself succeeded.
+func draftPRDescriptionAfterRun(ctx context.Context, cfg *config.Config, r *run.Run) {
+	if cfg == nil || !cfg.PRDescription.Enabled || r.Store == nil {
+		return
+	}
+	ui.Status("Drafting PR description...")
+	path, err := run.DraftPRDescription(ctx, r.Store, run.PRDescriptionOptions{
+		Grant: cfg.PRDescription.Grant,
+		Model: cfg.PRDescription.Model,
+	})
+	if err != nil {
+		ui.Warnf("PR description not drafted: %v", err)
+		return
+	}
+	ui.Statusf("PR description: %s", path)
+	if r.WorktreeBranch != "" {
+		ui.Status(ui.Dim(fmt.Sprintf("Open a pull request: git push -u origin %s && gh pr create --head %s --body-file %s", r.WorktreeBranch, r.WorktreeBranch, path)))
+	}
+}
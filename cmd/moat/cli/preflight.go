@@ -0,0 +1,45 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/majorcontext/moat/internal/log"
+	"github.com/majorcontext/moat/internal/run"
+)
+
+// preflightCredentials checks the run's granted credentials with their
+// providers and fails if any provider rejects one. Checks that cannot reach
+// the provider in time are logged and ignored so offline runs still start.
+func preflightCredentials(ctx context.Context, grants []string) error {
+	if len(grants) == 0 {
+		return nil
+	}
+	store, err := run.OpenDefaultStore()
+	if err != nil {
+		log.Debug("credential pre-flight: could not open store", "error", err)
+		return nil
+	}
+	return preflightError(run.PreflightGrants(ctx, grants, store))
+}
+
+// preflightError formats rejected credentials as an actionable error and logs
+// inconclusive checks. It returns nil when no credential was rejected.
+func preflightError(results []run.PreflightResult) error {
+	var errs []string
+	for _, r := range results {
+		switch {
+		case r.Rejected:
+			errs = append(errs, fmt.Sprintf("  - %s: %v\n    Run: moat grant %s", r.Grant, r.Err, run.GrantToCommand(r.Grant)))
+		case r.Err != nil:
+			log.Debug("credential pre-flight inconclusive", "grant", r.Grant, "error", r.Err)
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("credentials rejected by provider (expired or revoked):\n%s\n\n"+
+		"Re-grant the credentials above, then run again. Use --skip-preflight to start anyway.",
+		strings.Join(errs, "\n"))
+}
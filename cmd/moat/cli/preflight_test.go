@@ -0,0 +1,42 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/majorcontext/moat/internal/provider"
+	"github.com/majorcontext/moat/internal/run"
+)
+
+func TestPreflightError(t *testing.T) {
+	rejected := fmt.Errorf("%w (api.github.com returned 401)", provider.ErrCredentialRejected)
+
+	if err := preflightError(nil); err != nil {
+		t.Errorf("no results: err = %v, want nil", err)
+	}
+
+	inconclusive := []run.PreflightResult{{Grant: "openai", Err: errors.New("timeout")}}
+	if err := preflightError(inconclusive); err != nil {
+		t.Errorf("inconclusive check should not block the run, got %v", err)
+	}
+
+	err := preflightError([]run.PreflightResult{
+		{Grant: "github", Err: rejected, Rejected: true},
+		{Grant: "anthropic"},
+		{Grant: "oauth:notion", Err: rejected, Rejected: true},
+	})
+	if err == nil {
+		t.Fatal("expected error for rejected credentials")
+	}
+	msg := err.Error()
+	for _, want := range []string{"moat grant github", "moat grant oauth notion", "--skip-preflight", "returned 401"} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("error missing %q:\n%s", want, msg)
+		}
+	}
+	if strings.Contains(msg, "anthropic") {
+		t.Errorf("accepted grant should not be listed:\n%s", msg)
+	}
+}
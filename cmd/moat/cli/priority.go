@@ -0,0 +1,81 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/majorcontext/moat/internal/container"
+	"github.com/majorcontext/moat/internal/run"
+	"github.com/majorcontext/moat/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var priorityCmd = &cobra.Command{
+	Use:   "priority <run> [high|normal|background]",
+	Short: "Show or change a run's CPU and IO priority",
+	Long: `Show or change the priority class of a running run.
+
+Priority classes weight runs against each other when they compete for the
+host's CPU and disk. A high run (an interactive session) gets four times the
+CPU share of a normal run; a background run (a batch agent) gets an eighth
+and the lowest IO weight. Weights only apply under contention: an idle host
+gives a background run all the CPU it asks for.
+
+The change applies immediately, without restarting the container. Set the
+class at start with --priority or container.priority in moat.yaml. Docker
+and Podman only.
+
+Examples:
+  moat priority my-agent
+  moat priority my-agent background
+  moat priority my-agent high`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: runPriority,
+}
+
+func init() {
+	rootCmd.AddCommand(priorityCmd)
+}
+
+func runPriority(cmd *cobra.Command, args []string) error {
+	manager, err := run.NewManager()
+	if err != nil {
+		return fmt.Errorf("creating run manager: %w", err)
+	}
+	defer manager.Close()
+
+	runID, err := resolveRunArgSingle(manager, args[0])
+	if err != nil {
+		return err
+	}
+	r, err := manager.Get(runID)
+	if err != nil {
+		return err
+	}
+
+	if len(args) == 1 {
+		if jsonOut {
+			return json.NewEncoder(os.Stdout).Encode(map[string]string{"id": r.ID, "name": r.Name, "priority": string(r.GetPriority())})
+		}
+		fmt.Printf("%s: %s\n", r.Name, r.GetPriority())
+		return nil
+	}
+
+	priority, err := container.ParsePriority(args[1])
+	if err != nil {
+		return err
+	}
+	if dryRun {
+		fmt.Printf("Dry run - would set priority of run %s to %s\n", runID, priority)
+		return nil
+	}
+	if err := manager.SetPriority(cmd.Context(), runID, priority); err != nil {
+		return err
+	}
+	if jsonOut {
+		return json.NewEncoder(os.Stdout).Encode(map[string]string{"id": r.ID, "name": r.Name, "priority": string(priority)})
+	}
+	fmt.Printf("%s Set priority of %s to %s\n", ui.OKTag(), r.Name, priority)
+	return nil
+}
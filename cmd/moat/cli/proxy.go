@@ -2,9 +2,14 @@ package cli
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"sort"
+	"strings"
 	"syscall"
 	"time"
 
@@ -68,8 +73,44 @@ without waiting for the idle timeout.`,
 	RunE: restartProxy,
 }
 
+var proxyAPISpecCmd = &cobra.Command{
+	Use:   "api-spec",
+	Short: "Print the OpenAPI document of the daemon API",
+	Long: `Print the OpenAPI 3.1 document describing the proxy daemon's management
+API, for generating clients in other languages.
+
+By default the document is the one built into this binary, so no daemon needs
+to be running. With --running, it is fetched from the running daemon, which
+may be an older or newer binary.`,
+	Args: cobra.NoArgs,
+	RunE: printAPISpec,
+}
+
+var proxyEventsCmd = &cobra.Command{
+	Use:   "events",
+	Short: "Stream daemon events",
+	Long: `Stream run, approval, and clip events from the proxy daemon until
+interrupted: runs registering and unregistering, network approvals requested
+with moatctl and their decisions, and clips offered, accepted, rejected, or
+sent.
+
+Use --run to limit the stream to one run, and --json for one JSON object per
+line.`,
+	Args: cobra.NoArgs,
+	RunE: streamProxyEvents,
+}
+
+var (
+	apiSpecRunning bool
+	eventsRunID    string
+)
+
 func init() {
 	proxyCmd.AddCommand(proxyStartCmd)
+	proxyCmd.AddCommand(proxyAPISpecCmd)
+	proxyCmd.AddCommand(proxyEventsCmd)
+	proxyAPISpecCmd.Flags().BoolVar(&apiSpecRunning, "running", false, "fetch the document from the running daemon")
+	proxyEventsCmd.Flags().StringVar(&eventsRunID, "run", "", "only stream events for this run ID")
 	proxyCmd.AddCommand(proxyStopCmd)
 	proxyCmd.AddCommand(proxyStatusCmd)
 	proxyCmd.AddCommand(proxyRestartCmd)
@@ -153,6 +194,7 @@ func statusProxy(_ *cobra.Command, _ []string) error {
 		if health.Commit != "" {
 			fmt.Printf("  Commit: %s (cli: %s)\n", health.Commit, commit)
 		}
+		printQuotaStatus(health.Quotas)
 	}
 
 	// Advertise the hostname-routing entry point when an agent has started it.
@@ -186,3 +228,86 @@ func statusProxy(_ *cobra.Command, _ []string) error {
 
 	return nil
 }
+
+// printQuotaStatus lists each daily LLM quota with today's usage.
+func printQuotaStatus(quotas map[string]daemon.QuotaStatus) {
+	if len(quotas) == 0 {
+		return
+	}
+	providers := make([]string, 0, len(quotas))
+	for p := range quotas {
+		providers = append(providers, p)
+	}
+	sort.Strings(providers)
+
+	fmt.Println("\nDaily LLM quotas:")
+	for _, p := range providers {
+		q := quotas[p]
+		var parts []string
+		if q.DailyTokens > 0 {
+			parts = append(parts, fmt.Sprintf("%d/%d tokens", q.UsedTokens, q.DailyTokens))
+		}
+		if q.DailyCost > 0 {
+			parts = append(parts, fmt.Sprintf("$%.2f/$%.2f", q.UsedCost, q.DailyCost))
+		}
+		line := fmt.Sprintf("  %s: %s", p, strings.Join(parts, ", "))
+		if q.Exceeded {
+			line += " (exceeded; requests denied until midnight)"
+		}
+		fmt.Println(line)
+	}
+}
+
+func printAPISpec(_ *cobra.Command, _ []string) error {
+	spec := daemon.OpenAPI()
+	if apiSpecRunning {
+		sockPath := filepath.Join(config.GlobalConfigDir(), "proxy", "daemon.sock")
+		var err error
+		spec, err = daemon.NewClient(sockPath).OpenAPI(context.Background())
+		if err != nil {
+			return err
+		}
+	}
+	fmt.Println(strings.TrimSpace(string(spec)))
+	return nil
+}
+
+func streamProxyEvents(_ *cobra.Command, _ []string) error {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	sockPath := filepath.Join(config.GlobalConfigDir(), "proxy", "daemon.sock")
+	client := daemon.NewClient(sockPath)
+	if !jsonOut {
+		fmt.Fprintln(os.Stderr, "Streaming daemon events (Ctrl-C to stop)")
+	}
+	err := client.StreamEvents(ctx, eventsRunID, printDaemonEvent)
+	if errors.Is(err, daemon.ErrStreamUnsupported) {
+		return fmt.Errorf("the running proxy daemon is too old to stream events; run 'moat proxy restart' to upgrade it")
+	}
+	return err
+}
+
+// printDaemonEvent prints one event as a line of text, or as a JSON object
+// per line with --json.
+func printDaemonEvent(ev daemon.RunEvent) {
+	if jsonOut {
+		data, _ := json.Marshal(ev)
+		fmt.Println(string(data))
+		return
+	}
+	var detail string
+	switch {
+	case ev.Approval != nil:
+		detail = ev.Approval.Host
+		if ev.Approval.Reason != "" {
+			detail += " (" + ev.Approval.Reason + ")"
+		}
+	case ev.Clip != nil:
+		detail = ev.Clip.MIMEType
+		if ev.Clip.Name != "" {
+			detail = ev.Clip.Name + " " + detail
+		}
+	}
+	fmt.Printf("%s  %-20s %s  %s\n", ev.Time.Local().Format("15:04:05"), ev.Type, ev.RunID, detail)
+}
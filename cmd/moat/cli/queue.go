@@ -0,0 +1,164 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/majorcontext/moat/internal/config"
+	"github.com/majorcontext/moat/internal/daemon"
+	"github.com/majorcontext/moat/internal/log"
+	"github.com/majorcontext/moat/internal/run"
+	"github.com/majorcontext/moat/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var queueCmd = &cobra.Command{
+	Use:   "queue",
+	Short: "Show running and queued runs under the concurrency limits",
+	Long: `Show the runs holding a slot and the runs waiting for one.
+
+Concurrency limits are set in ~/.moat/config.yaml:
+
+  concurrency:
+    max_runs: 4                 # across the machine
+    max_runs_per_workspace: 2   # in any one workspace
+
+A run started beyond a limit waits in the proxy daemon's queue, in the order
+runs were started, and prints its place until a slot frees up. A run waiting
+only on its workspace's limit does not hold up runs in other workspaces.
+The daemon reads the limits when it starts; apply changes with
+'moat proxy restart'.`,
+	Args: cobra.NoArgs,
+	RunE: runQueue,
+}
+
+func init() {
+	rootCmd.AddCommand(queueCmd)
+}
+
+func runQueue(_ *cobra.Command, _ []string) error {
+	ctx := context.Background()
+	client := daemon.NewClient(filepath.Join(config.GlobalConfigDir(), "proxy", "daemon.sock"))
+	info := &daemon.QueueInfo{Running: []daemon.QueueSlot{}, Queued: []daemon.QueueSlot{}}
+	if _, err := client.Health(ctx); err == nil {
+		if info, err = client.ListQueue(ctx); errors.Is(err, daemon.ErrQueueUnsupported) {
+			return fmt.Errorf("the running proxy daemon is too old for the run queue; run 'moat proxy restart' to upgrade it")
+		} else if err != nil {
+			return fmt.Errorf("listing the run queue: %w", err)
+		}
+	} else if globalCfg, cfgErr := config.LoadGlobal(); cfgErr == nil {
+		// No daemon, so nothing is running or queued; show the limits a
+		// run would start under.
+		info.ConcurrencyConfig = globalCfg.Concurrency
+	}
+
+	if jsonOut {
+		return json.NewEncoder(os.Stdout).Encode(info)
+	}
+
+	fmt.Println(describeConcurrencyLimits(info.ConcurrencyConfig))
+	if len(info.Running) == 0 && len(info.Queued) == 0 {
+		fmt.Println("No runs running or queued")
+		return nil
+	}
+	fmt.Println()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "STATE\tRUN\tWORKSPACE\tSINCE")
+	for _, slot := range info.Running {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", slot.State, slotRunLabel(slot), slot.Workspace, formatAge(slot.Since))
+	}
+	for i, slot := range info.Queued {
+		fmt.Fprintf(w, "%s #%d\t%s\t%s\t%s\n", slot.State, i+1, slotRunLabel(slot), slot.Workspace, formatAge(slot.Since))
+	}
+	return w.Flush()
+}
+
+// slotRunLabel names the run holding or waiting for a slot. A queued run
+// has not been created yet, so it may have neither a name nor an ID.
+func slotRunLabel(slot daemon.QueueSlot) string {
+	switch {
+	case slot.Name != "" && slot.RunID != "":
+		return fmt.Sprintf("%s (%s)", slot.Name, slot.RunID)
+	case slot.Name != "":
+		return slot.Name
+	case slot.RunID != "":
+		return slot.RunID
+	}
+	return "-"
+}
+
+func describeConcurrencyLimits(c config.ConcurrencyConfig) string {
+	switch {
+	case c.MaxRuns > 0 && c.MaxRunsPerWorkspace > 0:
+		return fmt.Sprintf("Limits: %d at once, %d per workspace", c.MaxRuns, c.MaxRunsPerWorkspace)
+	case c.MaxRuns > 0:
+		return fmt.Sprintf("Limit: %d at once", c.MaxRuns)
+	case c.MaxRunsPerWorkspace > 0:
+		return fmt.Sprintf("Limit: %d at once per workspace", c.MaxRunsPerWorkspace)
+	}
+	return "No concurrency limits (set concurrency: in ~/.moat/config.yaml)"
+}
+
+// runSlot is a slot a run holds under the concurrency limits.
+type runSlot struct {
+	client *daemon.Client
+	slot   *daemon.Slot
+}
+
+// acquireRunSlot waits for a run slot when concurrency limits are set,
+// printing the run's place in the queue while it waits. It returns nil when
+// no limits are set. The caller holds the slot until Release; a process
+// that exits without releasing it gives it up with its connection.
+func acquireRunSlot(ctx context.Context, workspace, name string) (*runSlot, error) {
+	globalCfg, err := config.LoadGlobal()
+	if err != nil || !globalCfg.Concurrency.Limited() {
+		return nil, nil
+	}
+	client, err := daemon.EnsureRunning(filepath.Join(config.GlobalConfigDir(), "proxy"), 0)
+	if err != nil {
+		return nil, fmt.Errorf("starting proxy daemon: %w", err)
+	}
+
+	queued := false
+	slot, err := client.Enqueue(ctx, daemon.QueueRequest{Name: name, Workspace: workspace}, func(st daemon.QueueStatus) {
+		queued = true
+		limit := "the run limit"
+		if st.Limit == "max_runs_per_workspace" {
+			limit = "the workspace's run limit"
+		}
+		ui.Statusf("Queued at position %d: %d %s running, waiting on %s (see 'moat queue')", st.Position, st.Running, plural(st.Running, "run", "runs"), limit)
+	})
+	if errors.Is(err, daemon.ErrQueueUnsupported) {
+		ui.Warnf("the running proxy daemon is too old for the run queue; concurrency limits apply after 'moat proxy restart'")
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("waiting for a run slot: %w", err)
+	}
+	if queued {
+		ui.Status("Run slot available, starting")
+	}
+	return &runSlot{client: client, slot: slot}, nil
+}
+
+// Attach records the run holding the slot, for 'moat queue'.
+func (s *runSlot) Attach(ctx context.Context, r *run.Run) {
+	if s == nil {
+		return
+	}
+	if err := s.client.UpdateSlot(ctx, s.slot.Ticket, daemon.QueueSlotUpdate{RunID: r.ID, Name: r.Name}); err != nil {
+		log.Debug("failed to record run on its slot", "run", r.ID, "error", err)
+	}
+}
+
+// Release gives the slot back.
+func (s *runSlot) Release() {
+	if s != nil {
+		s.slot.Release()
+	}
+}
@@ -4,22 +4,40 @@
 package cli
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"time"
 
 	intcli "github.com/majorcontext/moat/internal/cli"
 	"github.com/majorcontext/moat/internal/config"
 	"github.com/majorcontext/moat/internal/credential"
+	"github.com/majorcontext/moat/internal/errcode"
 	"github.com/majorcontext/moat/internal/log"
 	"github.com/majorcontext/moat/internal/provider"
+	"github.com/majorcontext/moat/internal/telemetry"
+	"github.com/majorcontext/moat/internal/ui"
 	"github.com/spf13/cobra"
 )
 
 var (
-	verbose bool
-	dryRun  bool
-	jsonOut bool
-	profile string
+	verbose   bool
+	verbosity int
+	quiet     bool
+	dryRun    bool
+	jsonOut   bool
+	profile   string
+
+	// shutdownTelemetry flushes OpenTelemetry export started for the
+	// command (see telemetry.Init).
+	shutdownTelemetry = func(context.Context) error { return nil }
+
+	// stopAuditShipping flushes audit events queued for the collectors in
+	// the global config (see startAuditShipping).
+	stopAuditShipping = func(context.Context) error { return nil }
 )
 
 var rootCmd = &cobra.Command{
@@ -32,7 +50,15 @@ code, dependencies, tools, credentials, and observability.
 Core promise: moat run my-agent . just works — zero Docker knowledge,
 zero secret copying, full visibility.`,
 	SilenceUsage: true,
+	// Errors are printed by Execute, with their error code.
+	SilenceErrors: true,
 	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if quiet && verbosity > 0 {
+			return fmt.Errorf("--quiet and --verbose cannot be used together")
+		}
+		verbose = verbosity > 0
+		ui.SetQuiet(quiet)
+
 		// Resolve profile: --profile flag > MOAT_PROFILE env var
 		if profile == "" {
 			profile = os.Getenv("MOAT_PROFILE")
@@ -55,7 +81,7 @@ zero secret copying, full visibility.`,
 		}
 
 		if err := log.Init(log.Options{
-			Verbose:       verbose,
+			Verbosity:     verbosity,
 			JSONFormat:    jsonOut,
 			Interactive:   interactive,
 			DebugDir:      debugDir,
@@ -66,15 +92,74 @@ zero secret copying, full visibility.`,
 			cmd.PrintErrf("Warning: failed to initialize debug logging: %v\n", err)
 		}
 
+		// Export run and proxy events when MOAT_OTEL_ENDPOINT is set.
+		component := "cli"
+		if cmd == daemonCmd {
+			component = "daemon"
+		}
+		shutdown, err := telemetry.Init(context.Background(), component, version)
+		if err != nil {
+			log.Warn("OpenTelemetry export disabled", "error", err)
+		}
+		shutdownTelemetry = shutdown
+
+		// Stream audit events to the collectors in the global config.
+		stopAuditShipping = startAuditShipping(globalCfg)
+
 		// Sync dry-run state to internal/cli package for providers
 		intcli.DryRun = dryRun
 		return nil
 	},
 }
 
+// telemetryFlushTimeout bounds how long exiting waits to export pending
+// telemetry and audit events.
+const telemetryFlushTimeout = 5 * time.Second
+
 // Execute runs the root command.
 func Execute() error {
-	return rootCmd.Execute()
+	cmd, err := rootCmd.ExecuteC()
+	ctx, cancel := context.WithTimeout(context.Background(), telemetryFlushTimeout)
+	if flushErr := shutdownTelemetry(ctx); flushErr != nil {
+		log.Debug("flushing OpenTelemetry export", "error", flushErr)
+	}
+	if flushErr := stopAuditShipping(ctx); flushErr != nil {
+		log.Debug("flushing audit events", "error", flushErr)
+	}
+	cancel()
+	switch {
+	case err == nil:
+	case grantErrorsAsJSON:
+		writeGrantErrorJSON(os.Stderr, grantTarget, err)
+	case cmd == nil || !cmd.SilenceErrors:
+		writeError(os.Stderr, err, jsonOut)
+	}
+	return err
+}
+
+// errorJSON is the --json form of a failed command, written to stderr.
+type errorJSON struct {
+	Error struct {
+		Code    errcode.Code `json:"code,omitempty"`
+		Message string       `json:"message"`
+	} `json:"error"`
+}
+
+// writeError prints err with its error code, if it has one.
+func writeError(w io.Writer, err error, asJSON bool) {
+	code := errcode.Of(err)
+	if asJSON {
+		var out errorJSON
+		out.Error.Code = code
+		out.Error.Message = err.Error()
+		_ = json.NewEncoder(w).Encode(out)
+		return
+	}
+	if code != "" {
+		fmt.Fprintf(w, "Error [%s]: %v\n", code, err)
+		return
+	}
+	fmt.Fprintf(w, "Error: %v\n", err)
 }
 
 // RegisterProviderCLI registers CLI commands for all agent providers.
@@ -87,7 +172,8 @@ func RegisterProviderCLI() {
 }
 
 func init() {
-	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "verbose output")
+	rootCmd.PersistentFlags().CountVarP(&verbosity, "verbose", "v", "verbose output (-v for info logs, -vv for debug logs)")
+	rootCmd.PersistentFlags().BoolVarP(&quiet, "quiet", "q", false, "print only errors and results, such as the run ID")
 	rootCmd.PersistentFlags().BoolVar(&dryRun, "dry-run", false, "show what would happen without executing")
 	rootCmd.PersistentFlags().BoolVar(&jsonOut, "json", false, "output in JSON format")
 	rootCmd.PersistentFlags().StringVar(&profile, "profile", "", "credential profile to use (env: MOAT_PROFILE)")
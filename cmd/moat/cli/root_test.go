@@ -0,0 +1,38 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/majorcontext/moat/internal/errcode"
+	"github.com/majorcontext/moat/internal/run"
+)
+
+func TestWriteError(t *testing.T) {
+	coded := fmt.Errorf("%w: run_abc", run.ErrRunNotFound)
+
+	var buf strings.Builder
+	writeError(&buf, coded, false)
+	if want := "Error [" + string(errcode.RunNotFound) + "]: run not found: run_abc\n"; buf.String() != want {
+		t.Errorf("text output = %q, want %q", buf.String(), want)
+	}
+
+	buf.Reset()
+	writeError(&buf, errors.New("boom"), false)
+	if buf.String() != "Error: boom\n" {
+		t.Errorf("uncoded text output = %q, want %q", buf.String(), "Error: boom\n")
+	}
+
+	buf.Reset()
+	writeError(&buf, coded, true)
+	var got errorJSON
+	if err := json.Unmarshal([]byte(buf.String()), &got); err != nil {
+		t.Fatalf("output is not JSON: %v\n%s", err, buf.String())
+	}
+	if got.Error.Code != errcode.RunNotFound || got.Error.Message != "run not found: run_abc" {
+		t.Errorf("JSON output = %+v", got.Error)
+	}
+}
@@ -8,11 +8,16 @@ import (
 
 	"github.com/majorcontext/moat/internal/config"
 	"github.com/majorcontext/moat/internal/log"
+	"github.com/majorcontext/moat/internal/templates"
 	"github.com/majorcontext/moat/internal/ui"
 	"github.com/spf13/cobra"
 )
 
-var runFlags ExecFlags
+var (
+	runFlags    ExecFlags
+	runTemplate string
+	runParams   []string
+)
 
 var runCmd = &cobra.Command{
 	Use:   "run [path] [-- command]",
@@ -57,7 +62,10 @@ Examples:
   moat run -- sh -c "npm install && npm test"
 
   # Run interactive shell
-  moat run -i -- bash`,
+  moat run -i -- bash
+
+  # Run from a shared template instead of the workspace's moat.yaml
+  moat run --template code-review --param repo=acme/api ./api`,
 	Args: cobra.ArbitraryArgs,
 	RunE: runAgent,
 }
@@ -66,6 +74,8 @@ func init() {
 	rootCmd.AddCommand(runCmd)
 	AddExecFlags(runCmd, &runFlags)
 	runCmd.Flags().BoolVarP(&runFlags.Interactive, "interactive", "i", false, "interactive mode (stdin + TTY)")
+	runCmd.Flags().StringVar(&runTemplate, "template", "", "use a run template instead of the workspace's moat.yaml; list with 'moat template'")
+	runCmd.Flags().StringArrayVar(&runParams, "param", nil, "template parameter (KEY=VALUE, repeatable)")
 }
 
 func runAgent(cmd *cobra.Command, args []string) error {
@@ -109,6 +119,16 @@ func runAgent(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return fmt.Errorf("loading config: %w", err)
 	}
+	if runTemplate != "" {
+		if cfg != nil {
+			ui.Warnf("Using template %s instead of the workspace's moat.yaml", runTemplate)
+		}
+		if cfg, err = loadRunTemplate(runTemplate, runParams); err != nil {
+			return err
+		}
+	} else if len(runParams) > 0 {
+		return fmt.Errorf("--param needs --template")
+	}
 
 	// Determine agent name: --name flag > config.Name > random
 	if runFlags.Name == "" && cfg != nil && cfg.Name != "" {
@@ -178,3 +198,21 @@ func runAgent(cmd *cobra.Command, args []string) error {
 	_, err = ExecuteRun(ctx, opts)
 	return err
 }
+
+// loadRunTemplate finds the named template and renders it with the
+// --param values.
+func loadRunTemplate(name string, paramSpecs []string) (*config.Config, error) {
+	values, err := templates.ParseParams(paramSpecs)
+	if err != nil {
+		return nil, err
+	}
+	sources, err := templateSources()
+	if err != nil {
+		return nil, err
+	}
+	t, err := templates.Find(context.Background(), sources, name)
+	if err != nil {
+		return nil, err
+	}
+	return t.Instantiate(values)
+}
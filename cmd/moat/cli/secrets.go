@@ -0,0 +1,97 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/majorcontext/moat/internal/run"
+	"github.com/majorcontext/moat/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var secretsCmd = &cobra.Command{
+	Use:   "secrets",
+	Short: "Manage the secrets of running runs",
+}
+
+var secretsRefreshCmd = &cobra.Command{
+	Use:   "refresh <run>",
+	Short: "Re-resolve a running run's secrets and push new values into it",
+	Long: `Re-resolve the secrets a run was created with from their backends
+(1Password, AWS SSM, Vault, Doppler, environment) and write the values to
+` + run.SecretsDir + `/<NAME> in the running container. Use this after a
+secret is rotated so a long-lived agent does not keep a stale value.
+
+The container is not restarted and its environment is not changed: processes
+see rotated values by reading the files. If any value changed, the
+hooks.secrets_refresh command from moat.yaml then runs in the container, e.g.
+to signal a server to reload.
+
+Each secret is recorded in the run's audit log with whether it changed;
+values are never logged.
+
+Examples:
+  moat secrets refresh my-agent
+  moat secrets refresh my-agent --json`,
+	Args: cobra.ExactArgs(1),
+	RunE: runSecretsRefresh,
+}
+
+func init() {
+	rootCmd.AddCommand(secretsCmd)
+	secretsCmd.AddCommand(secretsRefreshCmd)
+}
+
+func runSecretsRefresh(cmd *cobra.Command, args []string) error {
+	manager, err := run.NewManager()
+	if err != nil {
+		return fmt.Errorf("creating run manager: %w", err)
+	}
+	defer manager.Close()
+
+	runID, err := resolveRunArgSingle(manager, args[0])
+	if err != nil {
+		return err
+	}
+	r, err := manager.Get(runID)
+	if err != nil {
+		return err
+	}
+
+	if dryRun {
+		fmt.Printf("Dry run - would refresh secrets of run %s\n", runID)
+		return nil
+	}
+
+	refreshed, err := manager.RefreshSecrets(cmd.Context(), runID)
+	if err != nil {
+		return err
+	}
+	if jsonOut {
+		return json.NewEncoder(os.Stdout).Encode(refreshed)
+	}
+
+	changed := 0
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tBACKEND\tSTATUS")
+	for _, s := range refreshed {
+		status := "unchanged"
+		if s.Changed {
+			status = "updated"
+			changed++
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", s.Name, s.Backend, status)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Println()
+	if changed == 0 {
+		fmt.Printf("%s No secrets of %s changed\n", ui.OKTag(), r.Name)
+		return nil
+	}
+	fmt.Printf("%s Updated %d of %d secrets in %s under %s\n", ui.OKTag(), changed, len(refreshed), r.Name, run.SecretsDir)
+	return nil
+}
@@ -0,0 +1,379 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/majorcontext/moat/internal/config"
+	"github.com/majorcontext/moat/internal/container"
+	"github.com/majorcontext/moat/internal/credential"
+	"github.com/majorcontext/moat/internal/netrules"
+	claudeprov "github.com/majorcontext/moat/internal/providers/claude"
+	codexprov "github.com/majorcontext/moat/internal/providers/codex"
+	geminiprov "github.com/majorcontext/moat/internal/providers/gemini"
+	"github.com/majorcontext/moat/internal/run"
+	"github.com/majorcontext/moat/internal/storage"
+	"github.com/majorcontext/moat/internal/ui"
+)
+
+var setupCmd = &cobra.Command{
+	Use:   "setup",
+	Short: "Set up Moat on this machine",
+	Long: `Walk through first-run setup interactively.
+
+Setup:
+- Verifies a container runtime (Docker or Apple containers) is available
+- Detects installed agent CLIs (claude, codex, gemini, gh) and offers to
+  grant each one's credential, importing it from the CLI where possible
+- Installs shell completion for bash, zsh, or fish
+- Runs a hello-world sandbox to confirm traffic flows through the proxy
+
+Every step is skippable and safe to re-run. Steps that are already done
+are reported and left alone.
+
+Examples:
+  moat setup
+  moat setup --skip-sandbox`,
+	Args: cobra.NoArgs,
+	RunE: runSetup,
+}
+
+var setupSkipSandbox bool
+
+func init() {
+	rootCmd.AddCommand(setupCmd)
+	setupCmd.Flags().BoolVar(&setupSkipSandbox, "skip-sandbox", false, "skip the hello-world sandbox run")
+}
+
+// setupAgent is an agent CLI setup looks for on the host, paired with the
+// grant that lets moat run it.
+type setupAgent struct {
+	name    string      // display name
+	binary  string      // executable looked up on PATH
+	grant   string      // grant offered when the CLI is installed but not granted
+	granted func() bool // reports whether a usable credential is stored
+}
+
+// setupAgents returns the agent CLIs setup detects, in display order.
+func setupAgents() []setupAgent {
+	return []setupAgent{
+		{name: "Claude Code", binary: "claude", grant: "claude", granted: func() bool { return claudeprov.GetCredentialName() != "" }},
+		{name: "Codex", binary: "codex", grant: "openai", granted: func() bool { return codexprov.GetCredentialName() != "" }},
+		{name: "Gemini CLI", binary: "gemini", grant: "gemini", granted: geminiprov.HasCredential},
+		{name: "GitHub CLI", binary: "gh", grant: "github", granted: hasGitHubCredential},
+	}
+}
+
+// hasGitHubCredential reports whether a github credential is stored.
+func hasGitHubCredential() bool {
+	store, err := run.OpenDefaultStore()
+	if err != nil {
+		return false
+	}
+	_, err = store.Get(credential.ProviderGitHub)
+	return err == nil
+}
+
+// setupFlow holds the I/O and host probes the setup steps use, so each step
+// can be exercised without a terminal, real CLIs, or a credential store.
+type setupFlow struct {
+	in          *bufio.Reader
+	out         io.Writer
+	interactive bool
+	lookPath    func(string) (string, error)
+	grant       func(context.Context, string) error
+}
+
+func runSetup(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	f := &setupFlow{
+		in:          bufio.NewReader(os.Stdin),
+		out:         os.Stdout,
+		interactive: stdinIsInteractive(),
+		lookPath:    exec.LookPath,
+		grant:       grantInline,
+	}
+
+	fmt.Fprintln(f.out, ui.Bold("Moat Setup"))
+	fmt.Fprintln(f.out)
+
+	f.section("Container Runtime")
+	runtimeOK := f.checkRuntime()
+
+	f.section("Agent Credentials")
+	f.setupCredentials(ctx, setupAgents())
+
+	f.section("Shell Completion")
+	f.setupCompletion(os.Getenv("SHELL"))
+
+	f.section("Sandbox Check")
+	switch {
+	case setupSkipSandbox:
+		fmt.Fprintf(f.out, "%s Skipped (--skip-sandbox)\n", ui.Dim("—"))
+	case !runtimeOK:
+		fmt.Fprintf(f.out, "%s Skipped: no container runtime\n", ui.Dim("—"))
+	default:
+		if err := f.checkSandbox(ctx); err != nil {
+			fmt.Fprintf(f.out, "%s Sandbox check failed: %v\n", ui.FailTag(), err)
+			fmt.Fprintln(f.out, "  Run 'moat doctor' for details.")
+			return fmt.Errorf("setup incomplete: sandbox check failed")
+		}
+	}
+
+	fmt.Fprintln(f.out)
+	fmt.Fprintln(f.out, "Setup complete. Start an agent with:")
+	fmt.Fprintln(f.out, "  moat claude ./my-project")
+	return nil
+}
+
+// section prints a bold title with a thin underline, like ui.Section but to
+// the flow's writer.
+func (f *setupFlow) section(title string) {
+	fmt.Fprintln(f.out)
+	fmt.Fprintln(f.out, ui.Bold(title))
+	fmt.Fprintln(f.out, ui.Dim(strings.Repeat("─", len(title))))
+}
+
+// confirm asks a yes/no question, defaulting to yes. Without a terminal
+// every question is answered no, so setup only reports.
+func (f *setupFlow) confirm(question string) bool {
+	if !f.interactive {
+		return false
+	}
+	fmt.Fprintf(f.out, "%s [Y/n] ", question)
+	line, err := f.in.ReadString('\n')
+	ans := strings.ToLower(strings.TrimSpace(line))
+	if err != nil && ans == "" {
+		// EOF (Ctrl-D): treat as no rather than the default yes.
+		fmt.Fprintln(f.out)
+		return false
+	}
+	return ans != "n" && ans != "no"
+}
+
+// checkRuntime reports the container runtime moat will use.
+func (f *setupFlow) checkRuntime() bool {
+	rt, err := container.NewRuntime()
+	if err != nil {
+		fmt.Fprintf(f.out, "%s No container runtime available: %v\n", ui.FailTag(), err)
+		fmt.Fprintln(f.out, "  Install Docker (https://docs.docker.com/get-docker/) or, on macOS 26+,")
+		fmt.Fprintln(f.out, "  Apple containers, then run 'moat setup' again.")
+		return false
+	}
+	defer rt.Close()
+	fmt.Fprintf(f.out, "%s Using %s\n", ui.OKTag(), rt.Type())
+	return true
+}
+
+// setupCredentials offers to grant each installed agent CLI's credential.
+// CLIs that are not installed are listed but not offered; moat can still
+// run them, since the agent is installed in the container, not on the host.
+func (f *setupFlow) setupCredentials(ctx context.Context, agents []setupAgent) {
+	for _, a := range agents {
+		_, lookErr := f.lookPath(a.binary)
+		installed := lookErr == nil
+		switch {
+		case a.granted():
+			fmt.Fprintf(f.out, "%s %s: granted\n", ui.OKTag(), a.name)
+			continue
+		case !installed:
+			fmt.Fprintf(f.out, "%s %s: not installed (grant later with: moat grant %s)\n", ui.Dim("—"), a.name, a.grant)
+			continue
+		}
+
+		fmt.Fprintf(f.out, "%s %s: installed, not granted\n", ui.WarnTag(), a.name)
+		if !f.confirm(fmt.Sprintf("  Grant %s now?", a.grant)) {
+			fmt.Fprintf(f.out, "  Skipped. Run later with: moat grant %s\n", a.grant)
+			continue
+		}
+		if err := f.grant(ctx, a.grant); err != nil {
+			fmt.Fprintf(f.out, "  %s %s: %v\n", ui.FailTag(), a.grant, err)
+			if ctx.Err() != nil {
+				fmt.Fprintln(f.out, "  Aborted.")
+				return
+			}
+			continue
+		}
+		fmt.Fprintf(f.out, "  %s %s granted\n", ui.OKTag(), a.grant)
+	}
+}
+
+// completionPath returns where shell completion for shell is installed and
+// whether the shell loads it from there without further configuration.
+// Returns "" for unsupported shells.
+func completionPath(shell, home string) (path string, autoLoaded bool) {
+	dataHome := os.Getenv("XDG_DATA_HOME")
+	if dataHome == "" {
+		dataHome = filepath.Join(home, ".local", "share")
+	}
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if configHome == "" {
+		configHome = filepath.Join(home, ".config")
+	}
+	switch filepath.Base(shell) {
+	case "bash":
+		// bash-completion 2 loads completions from here on demand.
+		return filepath.Join(dataHome, "bash-completion", "completions", "moat"), true
+	case "fish":
+		return filepath.Join(configHome, "fish", "completions", "moat.fish"), true
+	case "zsh":
+		// zsh has no per-user directory on fpath by default.
+		zdot := os.Getenv("ZDOTDIR")
+		if zdot == "" {
+			zdot = home
+		}
+		return filepath.Join(zdot, ".zfunc", "_moat"), false
+	}
+	return "", false
+}
+
+// setupCompletion installs the completion script for the user's shell.
+func (f *setupFlow) setupCompletion(shell string) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		fmt.Fprintf(f.out, "%s Cannot locate home directory: %v\n", ui.WarnTag(), err)
+		return
+	}
+	path, autoLoaded := completionPath(shell, home)
+	if path == "" {
+		fmt.Fprintf(f.out, "%s Unsupported shell %q; see 'moat completion --help'\n", ui.Dim("—"), filepath.Base(shell))
+		return
+	}
+	if _, err := os.Stat(path); err == nil {
+		fmt.Fprintf(f.out, "%s Installed at %s\n", ui.OKTag(), path)
+		return
+	}
+	if !f.confirm(fmt.Sprintf("Install %s completion to %s?", filepath.Base(shell), path)) {
+		fmt.Fprintf(f.out, "  Skipped. Generate it later with: moat completion %s\n", filepath.Base(shell))
+		return
+	}
+	if err := writeCompletion(filepath.Base(shell), path); err != nil {
+		fmt.Fprintf(f.out, "  %s %v\n", ui.FailTag(), err)
+		return
+	}
+	fmt.Fprintf(f.out, "  %s Installed at %s\n", ui.OKTag(), path)
+	if !autoLoaded {
+		fmt.Fprintf(f.out, "  Add to ~/.zshrc if not already present:\n")
+		fmt.Fprintf(f.out, "    fpath=(%s $fpath)\n", filepath.Dir(path))
+		fmt.Fprintf(f.out, "    autoload -Uz compinit && compinit\n")
+	}
+}
+
+// writeCompletion generates the completion script for shell into path.
+func writeCompletion(shell, path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("creating completion directory: %w", err)
+	}
+	out, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("creating completion file: %w", err)
+	}
+	switch shell {
+	case "bash":
+		err = rootCmd.GenBashCompletionV2(out, true)
+	case "zsh":
+		err = rootCmd.GenZshCompletion(out)
+	case "fish":
+		err = rootCmd.GenFishCompletion(out, true)
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return fmt.Errorf("writing completion: %w", err)
+	}
+	return nil
+}
+
+// setupSandboxHost is the host the sandbox check fetches. The run allows
+// only this host under a strict policy, so the request must pass through
+// the proxy to succeed.
+const setupSandboxHost = "example.com"
+
+// checkSandbox runs a throwaway container that makes one HTTPS request and
+// confirms the proxy logged it.
+func (f *setupFlow) checkSandbox(ctx context.Context) error {
+	fmt.Fprintln(f.out, "Starting a hello-world sandbox (the first run builds an image)...")
+
+	tmpDir, err := os.MkdirTemp("", "moat-setup-*")
+	if err != nil {
+		return fmt.Errorf("creating temp workspace: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	cfg := &config.Config{
+		Dependencies: []string{"curl"},
+		Network: config.NetworkConfig{
+			Policy: "strict",
+			Rules:  []netrules.NetworkRuleEntry{{HostRules: netrules.HostRules{Host: setupSandboxHost}}},
+		},
+	}
+
+	mgr, err := run.NewManager()
+	if err != nil {
+		return fmt.Errorf("creating run manager: %w", err)
+	}
+	defer mgr.Close()
+
+	r, err := mgr.Create(ctx, run.Options{
+		Name:      "setup-check",
+		Workspace: tmpDir,
+		Config:    cfg,
+		Cmd:       []string{"curl", "-fsS", "-o", "/dev/null", "https://" + setupSandboxHost},
+	})
+	if err != nil {
+		return fmt.Errorf("creating sandbox: %w", err)
+	}
+	defer func() {
+		_ = mgr.Stop(context.Background(), r.ID)
+		_ = mgr.Destroy(context.Background(), r.ID)
+	}()
+
+	if err := mgr.Start(ctx, r.ID); err != nil {
+		return fmt.Errorf("starting sandbox: %w", err)
+	}
+	waitCtx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+	defer cancel()
+	if err := mgr.Wait(waitCtx, r.ID); err != nil {
+		return fmt.Errorf("sandbox command failed: %w", err)
+	}
+
+	requests, err := r.Store.ReadNetworkRequests()
+	if err != nil {
+		return fmt.Errorf("reading network log: %w", err)
+	}
+	if !proxiedRequestTo(requests, setupSandboxHost) {
+		return fmt.Errorf("the request to %s did not appear in the proxy's network log", setupSandboxHost)
+	}
+	fmt.Fprintf(f.out, "%s Container reached https://%s through the proxy\n", ui.OKTag(), setupSandboxHost)
+	return nil
+}
+
+// proxiedRequestTo reports whether requests include one to host that the
+// proxy forwarded (not denied) and got a response for.
+func proxiedRequestTo(requests []storage.NetworkRequest, host string) bool {
+	for _, req := range requests {
+		u, err := url.Parse(req.URL)
+		if err != nil || u.Hostname() != host {
+			continue
+		}
+		if !req.Denied && req.StatusCode > 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,139 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	stderrors "errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/majorcontext/moat/internal/storage"
+)
+
+func newTestSetupFlow(input string, installed ...string) (*setupFlow, *bytes.Buffer, *[]string) {
+	var out bytes.Buffer
+	var granted []string
+	onPath := map[string]bool{}
+	for _, b := range installed {
+		onPath[b] = true
+	}
+	f := &setupFlow{
+		in:          bufio.NewReader(strings.NewReader(input)),
+		out:         &out,
+		interactive: true,
+		lookPath: func(name string) (string, error) {
+			if onPath[name] {
+				return "/usr/bin/" + name, nil
+			}
+			return "", stderrors.New("not found")
+		},
+		grant: func(_ context.Context, grant string) error {
+			granted = append(granted, grant)
+			return nil
+		},
+	}
+	return f, &out, &granted
+}
+
+func TestSetupCredentialsOffersInstalledUngranted(t *testing.T) {
+	agents := []setupAgent{
+		{name: "Claude Code", binary: "claude", grant: "claude", granted: func() bool { return true }},
+		{name: "Codex", binary: "codex", grant: "openai", granted: func() bool { return false }},
+		{name: "Gemini CLI", binary: "gemini", grant: "gemini", granted: func() bool { return false }},
+		{name: "GitHub CLI", binary: "gh", grant: "github", granted: func() bool { return false }},
+	}
+	// codex: default yes; gh: declined. gemini is not installed, so not offered.
+	f, out, granted := newTestSetupFlow("\nn\n", "claude", "codex", "gh")
+	f.setupCredentials(context.Background(), agents)
+
+	if strings.Join(*granted, ",") != "openai" {
+		t.Errorf("granted = %v, want [openai]", *granted)
+	}
+	for _, want := range []string{
+		"Claude Code: granted",
+		"openai granted",
+		"Gemini CLI: not installed",
+		"Skipped. Run later with: moat grant github",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output missing %q:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestSetupCredentialsNonInteractiveOnlyReports(t *testing.T) {
+	agents := []setupAgent{
+		{name: "GitHub CLI", binary: "gh", grant: "github", granted: func() bool { return false }},
+	}
+	f, out, granted := newTestSetupFlow("", "gh")
+	f.interactive = false
+	f.setupCredentials(context.Background(), agents)
+
+	if len(*granted) != 0 {
+		t.Errorf("granted = %v, want none without a terminal", *granted)
+	}
+	if strings.Contains(out.String(), "[Y/n]") {
+		t.Errorf("non-interactive setup must not prompt:\n%s", out.String())
+	}
+}
+
+func TestCompletionPath(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", "")
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv("ZDOTDIR", "")
+	home := "/home/u"
+
+	tests := []struct {
+		shell      string
+		want       string
+		autoLoaded bool
+	}{
+		{"/bin/bash", filepath.Join(home, ".local/share/bash-completion/completions/moat"), true},
+		{"/usr/bin/fish", filepath.Join(home, ".config/fish/completions/moat.fish"), true},
+		{"/bin/zsh", filepath.Join(home, ".zfunc/_moat"), false},
+		{"/bin/tcsh", "", false},
+	}
+	for _, tt := range tests {
+		got, autoLoaded := completionPath(tt.shell, home)
+		if got != tt.want || autoLoaded != tt.autoLoaded {
+			t.Errorf("completionPath(%q) = %q, %v; want %q, %v", tt.shell, got, autoLoaded, tt.want, tt.autoLoaded)
+		}
+	}
+}
+
+func TestWriteCompletion(t *testing.T) {
+	for _, shell := range []string{"bash", "zsh", "fish"} {
+		path := filepath.Join(t.TempDir(), "completions", "moat")
+		if err := writeCompletion(shell, path); err != nil {
+			t.Fatalf("writeCompletion(%s): %v", shell, err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("reading %s completion: %v", shell, err)
+		}
+		if !strings.Contains(string(data), "moat") {
+			t.Errorf("%s completion does not mention moat", shell)
+		}
+	}
+}
+
+func TestProxiedRequestTo(t *testing.T) {
+	tests := []struct {
+		name     string
+		requests []storage.NetworkRequest
+		want     bool
+	}{
+		{"forwarded", []storage.NetworkRequest{{URL: "https://example.com/", StatusCode: 200}}, true},
+		{"denied", []storage.NetworkRequest{{URL: "https://example.com/", StatusCode: 407, Denied: true}}, false},
+		{"other host", []storage.NetworkRequest{{URL: "https://example.org/", StatusCode: 200}}, false},
+		{"no response", []storage.NetworkRequest{{URL: "https://example.com/", Error: "timeout"}}, false},
+	}
+	for _, tt := range tests {
+		if got := proxiedRequestTo(tt.requests, "example.com"); got != tt.want {
+			t.Errorf("%s: proxiedRequestTo = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -6,7 +6,9 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+	"strings"
 	"text/tabwriter"
+	"time"
 
 	"github.com/majorcontext/moat/internal/config"
 	"github.com/majorcontext/moat/internal/container"
@@ -17,9 +19,11 @@ import (
 )
 
 var (
-	snapshotLabel     string
-	snapshotPruneKeep int
-	snapshotRestoreTo string
+	snapshotLabel        string
+	snapshotPruneKeep    int
+	snapshotRestoreTo    string
+	snapshotRestorePaths []string
+	snapshotRestoreForce bool
 )
 
 var snapshotCmd = &cobra.Command{
@@ -35,6 +39,7 @@ Examples:
   moat snapshot run_a1b2c3d4e5f6                             # Create snapshot by ID
   moat snapshot run_a1b2c3d4e5f6 --label "before refactor"   # Create with label
   moat snapshot list run_a1b2c3d4e5f6                         # List snapshots
+  moat snapshot diff run_a1b2c3d4e5f6 snap_a1b2c3d4e5f6       # Show changes since a snapshot
   moat snapshot prune run_a1b2c3d4e5f6                        # Prune old snapshots
   moat snapshot restore run_a1b2c3d4e5f6                      # Restore most recent`,
 	Args: cobra.ExactArgs(1),
@@ -57,6 +62,25 @@ Examples:
 	RunE: listSnapshots,
 }
 
+var snapshotDiffCmd = &cobra.Command{
+	Use:   "diff <run-id> <snapshot-id>",
+	Short: "Show workspace changes since a snapshot",
+	Long: `Show the files that differ between a snapshot and the current workspace.
+
+Each line is marked A (added since the snapshot), M (modified), or D
+(deleted). Paths excluded from snapshots by the workspace's moat.yaml
+(gitignored files and snapshots.exclude.additional) are not compared.
+
+Volume-mode runs are not supported: the host directory is not the live
+workspace. Extract with 'moat snapshot restore --to' and compare there.
+
+Examples:
+  moat snapshot diff run_a1b2c3d4e5f6 snap_a1b2c3d4e5f6          # List changed files
+  moat snapshot diff run_a1b2c3d4e5f6 snap_a1b2c3d4e5f6 --json   # Output as JSON`,
+	Args: cobra.ExactArgs(2),
+	RunE: diffSnapshot,
+}
+
 var snapshotPruneCmd = &cobra.Command{
 	Use:   "prune <run-id>",
 	Short: "Remove old snapshots, keeping the newest N",
@@ -88,6 +112,15 @@ Use --to to extract the snapshot to a different directory instead of
 restoring in-place. This is useful for comparing states or recovering
 specific files without modifying the current workspace.
 
+Use --path to restore only some files or directories (relative to the
+workspace root). Other files are left alone; files the snapshot does not
+contain are removed. Use 'moat snapshot diff' to see what would change.
+
+If the run has stopped, an in-place restore refuses to overwrite files that
+were edited after the run stopped, since those edits are probably yours
+rather than the agent's. Pass --force to restore anyway; the safety snapshot
+still captures them.
+
 Volume-mode runs require --to: in-place restore is blocked because the host
 workspace was never the live tree, so writing into it would defeat the host
 protection volume mode provides.
@@ -95,6 +128,7 @@ protection volume mode provides.
 Examples:
   moat snapshot restore run_a1b2c3d4e5f6                      # Restore most recent snapshot
   moat snapshot restore run_a1b2c3d4e5f6 snap_a1b2c3d4e5f6    # Restore specific snapshot
+  moat snapshot restore run_a1b2c3d4e5f6 snap_a1b2c3d4e5f6 --path src/main.go   # Restore one file
   moat snapshot restore run_a1b2c3d4e5f6 --to /tmp/recovery   # Extract to different directory`,
 	Args: cobra.RangeArgs(1, 2),
 	RunE: runSnapshotRestore,
@@ -106,11 +140,16 @@ func init() {
 
 	snapshotCmd.AddCommand(snapshotListCmd)
 
+	snapshotCmd.AddCommand(snapshotDiffCmd)
+
 	snapshotCmd.AddCommand(snapshotPruneCmd)
 	snapshotPruneCmd.Flags().IntVar(&snapshotPruneKeep, "keep", 5, "number of snapshots to keep (excluding pre-run)")
 
 	snapshotCmd.AddCommand(snapshotRestoreCmd)
 	snapshotRestoreCmd.Flags().StringVar(&snapshotRestoreTo, "to", "", "extract snapshot to a different directory instead of restoring in-place")
+	snapshotRestoreCmd.Flags().StringArrayVar(&snapshotRestorePaths, "path", nil, "restore only this file or directory, relative to the workspace (repeatable)")
+	snapshotRestoreCmd.Flags().BoolVar(&snapshotRestoreForce, "force", false, "overwrite files edited after the run stopped")
+	snapshotRestoreCmd.MarkFlagsMutuallyExclusive("to", "path")
 }
 
 // resolveSnapshotRunID resolves a run argument for snapshot commands.
@@ -286,6 +325,117 @@ func listSnapshots(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+func diffSnapshot(cmd *cobra.Command, args []string) error {
+	runID, err := resolveSnapshotRunID(args[0])
+	if err != nil {
+		return err
+	}
+	baseDir := storage.DefaultBaseDir()
+	runDir := filepath.Join(baseDir, runID)
+
+	// Check if run directory exists
+	if _, statErr := os.Stat(runDir); os.IsNotExist(statErr) {
+		return fmt.Errorf("run not found: %s", runID)
+	}
+
+	// Load run metadata to get workspace path
+	store, err := storage.NewRunStore(baseDir, runID)
+	if err != nil {
+		return fmt.Errorf("opening run storage: %w", err)
+	}
+
+	meta, err := store.LoadMetadata()
+	if err != nil {
+		return fmt.Errorf("loading run metadata: %w", err)
+	}
+
+	if config.IsVolumeMode(meta.WorkspaceMode) {
+		return fmt.Errorf("diff is not supported for volume-mode runs; use 'moat snapshot restore %s %s --to <dir>' and compare there", runID, args[1])
+	}
+
+	snapshotDir := filepath.Join(runDir, "snapshots")
+	engine, err := snapshot.NewEngine(meta.Workspace, snapshotDir, snapshotExcludeOptions(meta.Workspace))
+	if err != nil {
+		return fmt.Errorf("initializing snapshot engine: %w", err)
+	}
+	if _, ok := engine.Get(args[1]); !ok {
+		return fmt.Errorf("snapshot not found: %s", args[1])
+	}
+
+	changes, err := engine.Diff(args[1])
+	if err != nil {
+		return fmt.Errorf("comparing snapshot: %w", err)
+	}
+
+	if jsonOut {
+		if changes == nil {
+			changes = []snapshot.Change{}
+		}
+		return json.NewEncoder(os.Stdout).Encode(changes)
+	}
+
+	if len(changes) == 0 {
+		fmt.Println("No changes since snapshot")
+		return nil
+	}
+	for _, c := range changes {
+		fmt.Printf("%s  %s\n", changeMarker(c.Kind), c.Path)
+	}
+	return nil
+}
+
+// changeMarker returns the one-letter status shown by 'moat snapshot diff'.
+func changeMarker(kind snapshot.ChangeKind) string {
+	switch kind {
+	case snapshot.ChangeAdded:
+		return "A"
+	case snapshot.ChangeDeleted:
+		return "D"
+	default:
+		return "M"
+	}
+}
+
+// snapshotExcludeOptions returns engine options carrying the snapshot
+// excludes from the workspace's moat.yaml, matching what the run's own
+// engine used. Without a readable moat.yaml the defaults apply
+// (gitignore honored, no extra patterns).
+func snapshotExcludeOptions(workspace string) snapshot.EngineOptions {
+	opts := snapshot.EngineOptions{UseGitignore: true}
+	cfg, err := config.Load(workspace)
+	if err != nil || cfg == nil {
+		return opts
+	}
+	opts.UseGitignore = !cfg.Snapshots.Exclude.IgnoreGitignore
+	opts.Additional = cfg.Snapshots.Exclude.Additional
+	return opts
+}
+
+// checkLocalEdits refuses a restore that would overwrite files edited after
+// the run stopped. Those edits were made outside the run, so they are
+// probably the user's own work. A zero stoppedAt (run still active or never
+// recorded) skips the check, as does force.
+func checkLocalEdits(workspace string, changes []snapshot.Change, stoppedAt time.Time, force bool) error {
+	if force || stoppedAt.IsZero() {
+		return nil
+	}
+	newer := snapshot.ModifiedSince(workspace, changes, stoppedAt)
+	if len(newer) == 0 {
+		return nil
+	}
+	const maxListed = 10
+	listed := newer
+	if len(listed) > maxListed {
+		listed = listed[:maxListed]
+	}
+	msg := fmt.Sprintf("%d file(s) were modified after the run stopped and would be overwritten:\n  %s",
+		len(newer), strings.Join(listed, "\n  "))
+	if len(newer) > maxListed {
+		msg += fmt.Sprintf("\n  ... and %d more", len(newer)-maxListed)
+	}
+	return fmt.Errorf("%s\nUse --path to restore other files only, or --force to overwrite", msg)
+}
+
 func pruneSnapshots(cmd *cobra.Command, args []string) error {
 	runID, err := resolveSnapshotRunID(args[0])
 	if err != nil {
@@ -531,6 +681,36 @@ func runSnapshotRestore(cmd *cobra.Command, args []string) error {
 		return nil
 	}
 
+	// Per-path restore compares with the run's snapshot excludes so ignored
+	// files are never touched; a full restore replaces the whole workspace,
+	// so every file it would overwrite is checked.
+	if len(snapshotRestorePaths) > 0 {
+		engine, err = snapshot.NewEngine(engineWorkspace, snapshotDir, snapshotExcludeOptions(meta.Workspace))
+		if err != nil {
+			return fmt.Errorf("initializing snapshot engine: %w", err)
+		}
+	}
+	var changes []snapshot.Change
+	if len(snapshotRestorePaths) > 0 || (!snapshotRestoreForce && !meta.StoppedAt.IsZero()) {
+		changes, err = engine.Diff(snapshotID)
+		if err != nil {
+			return fmt.Errorf("comparing snapshot: %w", err)
+		}
+	}
+	if len(snapshotRestorePaths) > 0 {
+		changes, err = snapshot.FilterChanges(changes, snapshotRestorePaths)
+		if err != nil {
+			return err
+		}
+		if len(changes) == 0 {
+			fmt.Println("Nothing to restore: the given paths match the snapshot")
+			return nil
+		}
+	}
+	if err := checkLocalEdits(meta.Workspace, changes, meta.StoppedAt, snapshotRestoreForce); err != nil {
+		return err
+	}
+
 	// In-place restore: create safety snapshot first
 	fmt.Print("Creating safety snapshot of current state... ")
 	safetySnap, err := engine.Create(snapshot.TypeSafety, "pre-restore")
@@ -540,6 +720,20 @@ func runSnapshotRestore(cmd *cobra.Command, args []string) error {
 	}
 	fmt.Printf("done (%s)\n", safetySnap.ID)
 
+	if len(snapshotRestorePaths) > 0 {
+		fmt.Printf("Restoring %d file(s) from %s... ", len(changes), snapshotID)
+		if _, err := engine.RestorePaths(snapshotID, snapshotRestorePaths); err != nil {
+			fmt.Println("error")
+			return fmt.Errorf("restoring paths: %w", err)
+		}
+		fmt.Println("done")
+		for _, c := range changes {
+			fmt.Printf("  %s  %s\n", changeMarker(c.Kind), c.Path)
+		}
+		fmt.Printf("\nTo undo: moat snapshot restore %s %s\n", runID, safetySnap.ID)
+		return nil
+	}
+
 	// Restore the snapshot
 	fmt.Printf("Restoring workspace to %s... ", snapshotID)
 	if err := engine.Restore(snapshotID); err != nil {
@@ -1,6 +1,14 @@
 package cli
 
-import "testing"
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/majorcontext/moat/internal/snapshot"
+)
 
 func TestCheckRestoreAllowed(t *testing.T) {
 	// volume mode + no --to → blocked
@@ -20,3 +28,25 @@ func TestCheckRestoreAllowed(t *testing.T) {
 		t.Errorf("empty mode in-place restore should be allowed: %v", err)
 	}
 }
+
+func TestCheckLocalEdits(t *testing.T) {
+	ws := t.TempDir()
+	if err := os.WriteFile(filepath.Join(ws, "notes.md"), []byte("mine"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	changes := []snapshot.Change{{Path: "notes.md", Kind: snapshot.ChangeModified}}
+	stopped := time.Now().Add(-time.Hour)
+
+	if err := checkLocalEdits(ws, changes, stopped, false); err == nil || !strings.Contains(err.Error(), "notes.md") {
+		t.Errorf("edit after stop should be refused naming the file, got %v", err)
+	}
+	if err := checkLocalEdits(ws, changes, stopped, true); err != nil {
+		t.Errorf("--force should allow restore: %v", err)
+	}
+	if err := checkLocalEdits(ws, changes, time.Time{}, false); err != nil {
+		t.Errorf("run without stop time should not be checked: %v", err)
+	}
+	if err := checkLocalEdits(ws, changes, time.Now().Add(time.Hour), false); err != nil {
+		t.Errorf("edits before stop belong to the run: %v", err)
+	}
+}
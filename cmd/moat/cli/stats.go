@@ -0,0 +1,193 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/majorcontext/moat/internal/run"
+	"github.com/majorcontext/moat/internal/storage"
+	"github.com/spf13/cobra"
+)
+
+var (
+	statsHistory  bool
+	statsNoStream bool
+	statsInterval time.Duration
+)
+
+var statsCmd = &cobra.Command{
+	Use:   "stats [run]",
+	Short: "Show CPU, memory, and network usage of a run",
+	Long: `Show the resource usage of a run's container. Accepts a run ID or name.
+If no argument is specified, uses the most recent run.
+
+For a running run, samples usage live until Ctrl+C or the run exits. For a
+stopped run, or with --history, summarizes the usage recorded in the run's
+metrics.jsonl.
+
+While the moat process that started a run is alive (for example, an
+attached run), moat records a sample every 10 seconds. Set
+MOAT_METRICS_INTERVAL to a duration such as 5s to change the interval, or 0
+to stop recording.
+
+CPU is a percentage of one CPU: 200% means two CPUs fully busy. Network
+totals are zero for containers on the host network (Docker on Linux without
+published ports).
+
+Examples:
+  moat stats                   # Live usage of the most recent run
+  moat stats my-agent --no-stream
+  moat stats my-agent --history
+  moat stats my-agent --history --json`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runStats,
+}
+
+func init() {
+	rootCmd.AddCommand(statsCmd)
+	statsCmd.Flags().BoolVar(&statsHistory, "history", false, "summarize recorded usage even if the run is running")
+	statsCmd.Flags().BoolVar(&statsNoStream, "no-stream", false, "print one live sample and exit")
+	statsCmd.Flags().DurationVar(&statsInterval, "interval", 2*time.Second, "time between live samples")
+}
+
+func runStats(_ *cobra.Command, args []string) error {
+	if statsInterval <= 0 {
+		return fmt.Errorf("--interval must be positive")
+	}
+	manager, err := run.NewManager()
+	if err != nil {
+		return fmt.Errorf("creating run manager: %w", err)
+	}
+	defer manager.Close()
+
+	baseDir := storage.DefaultBaseDir()
+	var runID string
+	if len(args) > 0 {
+		runID, err = resolveRunArgSingle(manager, args[0])
+	} else {
+		runID, err = findLatestRun(baseDir)
+	}
+	if err != nil {
+		return err
+	}
+
+	if r, getErr := manager.Get(runID); getErr == nil && r.GetState() == run.StateRunning && !statsHistory {
+		return streamStats(manager, runID)
+	}
+
+	store, err := storage.NewRunStore(baseDir, runID)
+	if err != nil {
+		return fmt.Errorf("opening run storage: %w", err)
+	}
+	samples, err := store.ReadMetrics()
+	if err != nil {
+		return fmt.Errorf("reading metrics: %w", err)
+	}
+	summary := run.SummarizeMetrics(samples)
+
+	if jsonOut {
+		data, _ := json.MarshalIndent(struct {
+			RunID   string             `json:"run_id"`
+			Summary run.MetricsSummary `json:"summary"`
+			Samples []storage.Metric   `json:"samples"`
+		}{runID, summary, samples}, "", "  ")
+		fmt.Println(string(data))
+		return nil
+	}
+	if len(samples) == 0 {
+		fmt.Printf("No resource usage recorded for %s\n", runID)
+		return nil
+	}
+	printMetricsSummary(runID, summary)
+	return nil
+}
+
+// streamStats prints a live sample every statsInterval until interrupted or
+// the run stops. With --json each sample is a line of JSON.
+func streamStats(manager *run.Manager, runID string) error {
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	// CPU usage needs two samples, so take a baseline first.
+	prev, err := manager.SampleMetrics(ctx, runID, nil)
+	if err != nil {
+		return fmt.Errorf("sampling %s: %w", runID, err)
+	}
+	if !jsonOut {
+		fmt.Printf("%-8s  %7s  %21s  %10s  %10s\n", "TIME", "CPU", "MEMORY", "NET RX", "NET TX")
+	}
+	ticker := time.NewTicker(min(statsInterval, time.Second))
+	defer ticker.Stop()
+	for first := true; ; first = false {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		if first {
+			ticker.Reset(statsInterval)
+		}
+		m, err := manager.SampleMetrics(ctx, runID, &prev)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			if r, getErr := manager.Get(runID); getErr == nil && r.GetState() != run.StateRunning {
+				return nil
+			}
+			return fmt.Errorf("sampling %s: %w", runID, err)
+		}
+		prev = m
+		if jsonOut {
+			data, _ := json.Marshal(m)
+			fmt.Println(string(data))
+			if statsNoStream {
+				return nil
+			}
+			continue
+		}
+		fmt.Printf("%-8s  %6.1f%%  %21s  %10s  %10s\n",
+			m.Timestamp.Format("15:04:05"), m.CPUPercent,
+			formatMemory(m.MemoryBytes, m.MemoryLimitBytes),
+			formatStatsBytes(m.NetRxBytes), formatStatsBytes(m.NetTxBytes))
+		if statsNoStream {
+			return nil
+		}
+	}
+}
+
+func printMetricsSummary(runID string, s run.MetricsSummary) {
+	fmt.Printf("Run:      %s\n", runID)
+	fmt.Printf("Period:   %s – %s (%d samples)\n",
+		s.Start.Local().Format("2006-01-02 15:04:05"), s.End.Local().Format("15:04:05"), s.Samples)
+	fmt.Printf("CPU:      avg %.1f%%, peak %.1f%%, %s total\n", s.CPUAvgPercent, s.CPUMaxPercent, s.CPUTime.Round(time.Second))
+	fmt.Printf("Memory:   avg %s, peak %s\n", formatStatsBytes(s.MemoryAvg), formatMemory(s.MemoryMax, s.MemoryLimit))
+	fmt.Printf("Network:  %s received, %s sent\n", formatStatsBytes(s.NetRxBytes), formatStatsBytes(s.NetTxBytes))
+}
+
+// formatMemory renders used memory, with the limit when known.
+func formatMemory(used, limit uint64) string {
+	if limit == 0 {
+		return formatStatsBytes(used)
+	}
+	return formatStatsBytes(used) + " / " + formatStatsBytes(limit)
+}
+
+// formatStatsBytes renders a byte count in KB, MB, or GB (powers of 1024).
+func formatStatsBytes(b uint64) string {
+	switch {
+	case b >= 1<<30:
+		return fmt.Sprintf("%.2f GB", float64(b)/(1<<30))
+	case b >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(b)/(1<<20))
+	case b >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(b)/(1<<10))
+	default:
+		return fmt.Sprintf("%d B", b)
+	}
+}
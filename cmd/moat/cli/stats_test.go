@@ -0,0 +1,29 @@
+package cli
+
+import "testing"
+
+func TestFormatStatsBytes(t *testing.T) {
+	for _, tt := range []struct {
+		in   uint64
+		want string
+	}{
+		{0, "0 B"},
+		{1023, "1023 B"},
+		{1536, "1.5 KB"},
+		{5 << 20, "5.0 MB"},
+		{3 << 30, "3.00 GB"},
+	} {
+		if got := formatStatsBytes(tt.in); got != tt.want {
+			t.Errorf("formatStatsBytes(%d) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestFormatMemory(t *testing.T) {
+	if got := formatMemory(1<<20, 0); got != "1.0 MB" {
+		t.Errorf("formatMemory without limit = %q", got)
+	}
+	if got := formatMemory(1<<20, 1<<30); got != "1.0 MB / 1.00 GB" {
+		t.Errorf("formatMemory with limit = %q", got)
+	}
+}
@@ -19,22 +19,36 @@ import (
 	"github.com/spf13/cobra"
 )
 
+var statusCheckConfig bool
+
 var statusCmd = &cobra.Command{
-	Use:   "status",
+	Use:   "status [run]",
 	Short: "Show system status summary",
 	Long: `Display a high-level summary of moat resources including:
 - Active runs
 - Totals for stopped runs and images
-- Health indicators
+- Health indicators, including LLM API errors (rate limits, overload,
+  context length) active runs are seeing
+
+With a run and --check-config, compare the moat.yaml the run was created
+with against the moat.yaml in its workspace now, and report changed
+dependencies, env, secrets, grants, network policy, and command. On a
+terminal, offers to stop the run so it can be started with the changes.
 
 For detailed information, use:
   moat list              List all runs
   moat system images     List all images
-  moat system containers List all containers`,
+  moat system containers List all containers
+
+Examples:
+  moat status
+  moat status my-agent --check-config`,
+	Args: cobra.MaximumNArgs(1),
 	RunE: showStatus,
 }
 
 func init() {
+	statusCmd.Flags().BoolVar(&statusCheckConfig, "check-config", false, "report moat.yaml changes since the run was created")
 	rootCmd.AddCommand(statusCmd)
 }
 
@@ -54,6 +68,9 @@ type runInfo struct {
 	Age       string `json:"age"`
 	DiskMB    int64  `json:"disk_mb"`
 	Endpoints string `json:"endpoints,omitempty"`
+
+	APIErrors *run.APIErrorSummary `json:"api_errors,omitempty"`
+	Progress  *storage.Progress    `json:"progress,omitempty"` // latest moatctl progress event
 }
 
 type imageInfo struct {
@@ -69,6 +86,16 @@ type healthItem struct {
 }
 
 func showStatus(cmd *cobra.Command, args []string) error {
+	if statusCheckConfig {
+		if len(args) == 0 {
+			return fmt.Errorf("--check-config requires a run: moat status <run> --check-config")
+		}
+		return checkRunConfig(args[0])
+	}
+	if len(args) > 0 {
+		return fmt.Errorf("a run argument requires --check-config: moat status %s --check-config", args[0])
+	}
+
 	ctx := context.Background()
 
 	// Get runs (no sandbox needed for status queries)
@@ -160,7 +187,7 @@ func showStatus(cmd *cobra.Command, args []string) error {
 			sort.Strings(names)
 			endpoints = strings.Join(names, ", ")
 		}
-		output.ActiveRuns = append(output.ActiveRuns, runInfo{
+		info := runInfo{
 			Name:      r.Name,
 			ID:        r.ID,
 			Runtime:   r.Runtime,
@@ -168,7 +195,18 @@ func showStatus(cmd *cobra.Command, args []string) error {
 			Age:       age,
 			DiskMB:    diskMB,
 			Endpoints: endpoints,
-		})
+		}
+		// Upstream API errors tell a throttled run apart from a stuck agent.
+		if apiErrs, ok := runAPIErrors(r); ok && apiErrs.Errors() > 0 {
+			info.APIErrors = &apiErrs
+			output.Health = append(output.Health, healthItem{
+				Status: "warning",
+				Message: fmt.Sprintf("%s: LLM API errors: %s (last error %s)",
+					r.Name, apiErrs, formatAge(apiErrs.LastError)),
+			})
+		}
+		info.Progress = latestProgress(baseDir, r.ID)
+		output.ActiveRuns = append(output.ActiveRuns, info)
 	}
 
 	// Images section - calculate total size from image info
@@ -260,6 +298,15 @@ func showStatus(cmd *cobra.Command, args []string) error {
 				r.Name, r.ID, rtLabel, r.Age, diskStr, r.Endpoints)
 		}
 		w.Flush()
+		for _, r := range output.ActiveRuns {
+			if p := r.Progress; p != nil {
+				pct := ""
+				if p.Percent != nil {
+					pct = fmt.Sprintf("%d%% ", *p.Percent)
+				}
+				fmt.Printf("  %s: %s%s (%s)\n", r.Name, pct, p.Message, formatAge(p.Timestamp))
+			}
+		}
 	}
 	fmt.Println()
 
@@ -297,6 +344,20 @@ func showStatus(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// latestProgress returns the last progress event a run reported with
+// moatctl, or nil if it has reported none.
+func latestProgress(baseDir, runID string) *storage.Progress {
+	store, err := storage.NewRunStore(baseDir, runID)
+	if err != nil {
+		return nil
+	}
+	events, err := store.ReadProgress()
+	if err != nil || len(events) == 0 {
+		return nil
+	}
+	return &events[len(events)-1]
+}
+
 func formatAge(t time.Time) string {
 	if t.IsZero() {
 		return "-"
@@ -314,6 +375,22 @@ func formatAge(t time.Time) string {
 	return fmt.Sprintf("%dd ago", int(d.Hours()/24))
 }
 
+// formatUntil formats the time remaining until t, e.g. "in 3h".
+func formatUntil(t time.Time) string {
+	d := time.Until(t)
+	switch {
+	case d <= 0:
+		return "expired"
+	case d < time.Minute:
+		return "in <1m"
+	case d < time.Hour:
+		return fmt.Sprintf("in %dm", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("in %dh", int(d.Hours()))
+	}
+	return fmt.Sprintf("in %dd", int(d.Hours()/24))
+}
+
 // getDirSizeWithTimeout calculates directory size with a timeout to prevent
 // blocking on slow filesystems. Returns -1 if the operation times out.
 func getDirSizeWithTimeout(path string, timeout time.Duration) int64 {
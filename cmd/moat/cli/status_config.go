@@ -0,0 +1,97 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/majorcontext/moat/internal/errcode"
+	"github.com/majorcontext/moat/internal/run"
+	"github.com/majorcontext/moat/internal/term"
+	"github.com/majorcontext/moat/internal/ui"
+)
+
+// configDriftOutput is the --json shape of `moat status <run> --check-config`.
+type configDriftOutput struct {
+	RunID   string             `json:"run_id"`
+	Name    string             `json:"name"`
+	Drifted bool               `json:"drifted"`
+	Changes []run.ConfigChange `json:"changes"`
+}
+
+// checkRunConfig reports moat.yaml drift for a run and, on a terminal,
+// offers to stop a running run so it can be restarted with the changes.
+func checkRunConfig(arg string) error {
+	manager, err := run.NewManager()
+	if err != nil {
+		return fmt.Errorf("creating run manager: %w", err)
+	}
+	defer manager.Close()
+
+	runID, err := resolveRunArgSingle(manager, arg)
+	if err != nil {
+		return err
+	}
+	r, err := manager.Get(runID)
+	if err != nil {
+		return err
+	}
+
+	changes, err := run.CheckConfigDrift(r)
+	if errors.Is(err, run.ErrNoConfigManifest) {
+		return errcode.Wrap(errcode.NoConfigManifest, fmt.Errorf("run %s has no recorded configuration; it was created by an older moat version", runID))
+	}
+	if err != nil {
+		return err
+	}
+
+	if jsonOut {
+		if changes == nil {
+			changes = []run.ConfigChange{}
+		}
+		return json.NewEncoder(os.Stdout).Encode(configDriftOutput{
+			RunID:   r.ID,
+			Name:    r.Name,
+			Drifted: len(changes) > 0,
+			Changes: changes,
+		})
+	}
+
+	if len(changes) == 0 {
+		fmt.Printf("%s moat.yaml matches the configuration %s was created with\n", ui.OKTag(), r.Name)
+		return nil
+	}
+
+	fmt.Printf("%s moat.yaml has changed since %s (%s) was created:\n\n", ui.WarnTag(), r.Name, r.ID)
+	for _, c := range changes {
+		fmt.Printf("  %s\n", c)
+	}
+	fmt.Println()
+
+	if r.GetState() != run.StateRunning {
+		fmt.Println("Changes apply the next time the agent is started.")
+		return nil
+	}
+	if !term.IsTerminal(os.Stdin) {
+		fmt.Printf("The run keeps its original configuration. Stop it with `moat stop %s` and start it again to apply the changes.\n", r.ID)
+		return nil
+	}
+
+	fmt.Printf("Stop %s now so it can be restarted with these changes? [y/N]: ", r.Name)
+	reader := bufio.NewReader(os.Stdin)
+	answer, _ := reader.ReadString('\n')
+	answer = strings.TrimSpace(strings.ToLower(answer))
+	if answer != "y" && answer != "yes" {
+		fmt.Println("Run left unchanged")
+		return nil
+	}
+	if err := manager.Stop(context.Background(), r.ID); err != nil {
+		return fmt.Errorf("stopping run %s: %w", r.ID, err)
+	}
+	fmt.Printf("Run %s stopped. Start it again with the command you used to launch it (e.g. moat run %s).\n", r.ID, r.Workspace)
+	return nil
+}
@@ -0,0 +1,238 @@
+package cli
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/majorcontext/gatekeeper/proxy"
+	"github.com/majorcontext/moat/internal/provider"
+	"github.com/majorcontext/moat/internal/run"
+	"github.com/majorcontext/moat/internal/storage"
+	"github.com/majorcontext/moat/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var suggestCmd = &cobra.Command{
+	Use:   "suggest <run>",
+	Short: "Suggest moat.yaml policy from blocked traffic",
+	Long: `Suggest moat.yaml additions that would allow the traffic a run's strict
+network policy blocked.
+
+Reads the run's network log, collects the distinct hosts the proxy blocked
+because they were not in the allowlist, and prints a moat.yaml snippet:
+hosts covered by a known credential provider are suggested as grants, the
+rest as network.rules entries, and blocked host-service ports as
+network.host entries.
+
+Review the suggestion before applying it — it allows everything the agent
+tried to reach, which is not necessarily everything it should reach.
+
+Examples:
+  moat suggest my-agent
+  moat suggest run_a1b2c3d4e5f6`,
+	Args: cobra.ExactArgs(1),
+	RunE: runSuggest,
+}
+
+func init() {
+	rootCmd.AddCommand(suggestCmd)
+}
+
+func runSuggest(cmd *cobra.Command, args []string) error {
+	manager, err := run.NewManager()
+	if err != nil {
+		return fmt.Errorf("creating run manager: %w", err)
+	}
+	defer manager.Close()
+
+	runID, err := resolveRunArgSingle(manager, args[0])
+	if err != nil {
+		return err
+	}
+	r, err := manager.Get(runID)
+	if err != nil {
+		return err
+	}
+	if r.Store == nil {
+		return fmt.Errorf("run %s has no stored data", runID)
+	}
+
+	reqs, err := r.Store.ReadNetworkRequests()
+	if err != nil {
+		return fmt.Errorf("reading network log: %w", err)
+	}
+
+	s := suggestFromBlocked(reqs, r.Grants)
+	if s.empty() {
+		fmt.Printf("No requests were blocked by the network allowlist in run %s.\n", runID)
+		return nil
+	}
+	fmt.Print(s.format())
+	return nil
+}
+
+// Deny reason prefixes the proxy writes for allowlist denials. Requests
+// blocked by per-path rules or Keep policy have other reasons and are not
+// suggestion targets: their hosts are already allowed.
+const (
+	denyPrefixHost        = "Host not in allow list: "
+	denyPrefixHostService = "Host service blocked: "
+)
+
+// policySuggestion is the set of moat.yaml additions that would allow a
+// run's blocked traffic.
+type policySuggestion struct {
+	// Grants maps a suggested grant to the blocked hosts it covers.
+	Grants map[string][]string
+	// Hosts lists blocked hosts not covered by any known grant.
+	Hosts []string
+	// HostPorts lists blocked host-service ports (network.host).
+	HostPorts []int
+	// Counts is the number of blocked requests per host (or "host:port").
+	Counts map[string]int
+}
+
+func (s policySuggestion) empty() bool {
+	return len(s.Grants) == 0 && len(s.Hosts) == 0 && len(s.HostPorts) == 0
+}
+
+// suggestFromBlocked builds a policy suggestion from a run's network log.
+// Grants the run already has are never suggested again.
+func suggestFromBlocked(reqs []storage.NetworkRequest, grants []string) policySuggestion {
+	s := policySuggestion{Grants: map[string][]string{}, Counts: map[string]int{}}
+	seenHost := map[string]bool{}
+	for _, req := range reqs {
+		if !req.Denied {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(req.DenyReason, denyPrefixHost):
+			host := strings.ToLower(strings.TrimPrefix(req.DenyReason, denyPrefixHost))
+			s.Counts[host]++
+			if seenHost[host] {
+				continue
+			}
+			seenHost[host] = true
+			if g := grantForHost(host); g != "" && !slices.Contains(grants, g) {
+				s.Grants[g] = append(s.Grants[g], host)
+			} else {
+				s.Hosts = append(s.Hosts, host)
+			}
+		case strings.HasPrefix(req.DenyReason, denyPrefixHostService):
+			hostPort := strings.TrimPrefix(req.DenyReason, denyPrefixHostService)
+			s.Counts[hostPort]++
+			i := strings.LastIndex(hostPort, ":")
+			if i < 0 {
+				continue
+			}
+			port, err := strconv.Atoi(hostPort[i+1:])
+			if err != nil || slices.Contains(s.HostPorts, port) {
+				continue
+			}
+			s.HostPorts = append(s.HostPorts, port)
+		}
+	}
+	sort.Strings(s.Hosts)
+	sort.Ints(s.HostPorts)
+	return s
+}
+
+// grantNames lists the grants considered for suggestions. A variable so tests
+// can run without the provider registry populated.
+var grantNames = provider.Names
+
+// grantForHost returns the first registered provider (in name order) whose
+// grant allows host, or "" if none does.
+func grantForHost(host string) string {
+	for _, name := range grantNames() {
+		for _, pattern := range proxy.GetHostsForGrant(name) {
+			if grantPatternMatches(pattern, host) {
+				return name
+			}
+		}
+	}
+	return ""
+}
+
+// grantPatternMatches reports whether a grant host pattern ("api.github.com"
+// or "*.github.com", optionally with a port) matches host.
+func grantPatternMatches(pattern, host string) bool {
+	pattern, _, _ = strings.Cut(strings.ToLower(pattern), ":")
+	if suffix, ok := strings.CutPrefix(pattern, "*."); ok {
+		return strings.HasSuffix(host, "."+suffix)
+	}
+	return pattern == host
+}
+
+// format renders the blocked hosts and the suggested moat.yaml snippet.
+func (s policySuggestion) format() string {
+	var b strings.Builder
+
+	keys := make([]string, 0, len(s.Counts))
+	for k := range s.Counts {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	b.WriteString("Blocked by network policy:\n")
+	for _, k := range keys {
+		fmt.Fprintf(&b, "  %s (%d %s)\n", k, s.Counts[k], plural(s.Counts[k], "request", "requests"))
+	}
+
+	b.WriteString("\nSuggested moat.yaml additions:\n\n")
+	if len(s.Grants) > 0 {
+		names := make([]string, 0, len(s.Grants))
+		for g := range s.Grants {
+			names = append(names, g)
+		}
+		sort.Strings(names)
+		b.WriteString("  grants:\n")
+		for _, g := range names {
+			hosts := s.Grants[g]
+			sort.Strings(hosts)
+			fmt.Fprintf(&b, "    - %s  # %s\n", g, strings.Join(hosts, ", "))
+		}
+	}
+	if len(s.Hosts) > 0 || len(s.HostPorts) > 0 {
+		b.WriteString("  network:\n")
+		if len(s.Hosts) > 0 {
+			b.WriteString("    rules:\n")
+			for _, h := range s.Hosts {
+				fmt.Fprintf(&b, "      - %q\n", h)
+			}
+		}
+		if len(s.HostPorts) > 0 {
+			b.WriteString("    host:\n")
+			for _, p := range s.HostPorts {
+				fmt.Fprintf(&b, "      - %d\n", p)
+			}
+		}
+	}
+	if len(s.Grants) > 0 {
+		b.WriteString("\nGrants also inject credentials; store them first with `moat grant <provider>`.\n")
+	}
+	return b.String()
+}
+
+// printBlockedTrafficSuggestion prints a policy suggestion at the end of a
+// run whose strict network policy blocked traffic. Errors are ignored: the
+// suggestion is a convenience and `moat suggest` can reproduce it.
+func printBlockedTrafficSuggestion(r *run.Run) {
+	if ui.Quiet() || r.Store == nil || !r.FirewallEnabled {
+		return
+	}
+	reqs, err := r.Store.ReadNetworkRequests()
+	if err != nil {
+		return
+	}
+	s := suggestFromBlocked(reqs, r.Grants)
+	if s.empty() {
+		return
+	}
+	fmt.Println()
+	ui.Info("The network policy blocked requests during this run.")
+	fmt.Print(s.format())
+	fmt.Println(ui.Dim(fmt.Sprintf("Show again: moat suggest %s", r.ID)))
+}
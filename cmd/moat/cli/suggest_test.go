@@ -0,0 +1,94 @@
+package cli
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/majorcontext/moat/internal/storage"
+)
+
+func TestSuggestFromBlocked(t *testing.T) {
+	orig := grantNames
+	grantNames = func() []string { return []string{"github"} }
+	t.Cleanup(func() { grantNames = orig })
+
+	reqs := []storage.NetworkRequest{
+		{URL: "api.github.com:443", Denied: true, DenyReason: "Host not in allow list: api.github.com"},
+		{URL: "api.github.com:443", Denied: true, DenyReason: "Host not in allow list: api.github.com"},
+		{URL: "https://registry.npmjs.org/lodash", Denied: true, DenyReason: "Host not in allow list: registry.npmjs.org"},
+		{URL: "http://host.docker.internal:5432", Denied: true, DenyReason: "Host service blocked: host.docker.internal:5432"},
+		// Path-rule and Keep denials are on allowed hosts: not suggestions.
+		{URL: "https://example.com/admin", Denied: true, DenyReason: "Request blocked by network policy: POST example.com/admin"},
+		{URL: "https://pypi.org/simple", StatusCode: 200},
+	}
+
+	s := suggestFromBlocked(reqs, nil)
+	if got := s.Grants["github"]; !slices.Equal(got, []string{"api.github.com"}) {
+		t.Errorf("Grants[github] = %v, want [api.github.com]", got)
+	}
+	if !slices.Equal(s.Hosts, []string{"registry.npmjs.org"}) {
+		t.Errorf("Hosts = %v, want [registry.npmjs.org]", s.Hosts)
+	}
+	if !slices.Equal(s.HostPorts, []int{5432}) {
+		t.Errorf("HostPorts = %v, want [5432]", s.HostPorts)
+	}
+	if s.Counts["api.github.com"] != 2 {
+		t.Errorf("Counts[api.github.com] = %d, want 2", s.Counts["api.github.com"])
+	}
+
+	out := s.format()
+	for _, want := range []string{
+		"api.github.com (2 requests)",
+		"grants:\n    - github  # api.github.com",
+		"rules:\n      - \"registry.npmjs.org\"",
+		"host:\n      - 5432",
+		"moat grant <provider>",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("format() missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestSuggestFromBlocked_ExistingGrant(t *testing.T) {
+	orig := grantNames
+	grantNames = func() []string { return []string{"github"} }
+	t.Cleanup(func() { grantNames = orig })
+
+	// A host covered by a grant the run already has is suggested as a rule:
+	// the grant did not allow it (e.g. a scoped grant), so granting again
+	// would not help.
+	reqs := []storage.NetworkRequest{
+		{Denied: true, DenyReason: "Host not in allow list: api.github.com"},
+	}
+	s := suggestFromBlocked(reqs, []string{"github"})
+	if len(s.Grants) != 0 || !slices.Equal(s.Hosts, []string{"api.github.com"}) {
+		t.Errorf("suggestion = %+v, want api.github.com as a rule", s)
+	}
+}
+
+func TestSuggestFromBlocked_Empty(t *testing.T) {
+	s := suggestFromBlocked([]storage.NetworkRequest{{URL: "https://example.com", StatusCode: 200}}, nil)
+	if !s.empty() {
+		t.Errorf("suggestion = %+v, want empty", s)
+	}
+}
+
+func TestGrantPatternMatches(t *testing.T) {
+	tests := []struct {
+		pattern, host string
+		want          bool
+	}{
+		{"api.github.com", "api.github.com", true},
+		{"*.github.com", "api.github.com", true},
+		{"*.github.com", "github.com", false},
+		{"api.example.com:8443", "api.example.com", true},
+		{"github.com", "api.github.com", false},
+	}
+	for _, tt := range tests {
+		if got := grantPatternMatches(tt.pattern, tt.host); got != tt.want {
+			t.Errorf("grantPatternMatches(%q, %q) = %v, want %v", tt.pattern, tt.host, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/majorcontext/moat/internal/config"
+	"github.com/majorcontext/moat/internal/templates"
+	"github.com/spf13/cobra"
+)
+
+var templateCmd = &cobra.Command{
+	Use:   "template",
+	Short: "List and update run templates",
+	Long: `Manage run templates: moat.yaml files with ${{ params.NAME }}
+placeholders that a team shares to standardize agent configurations.
+
+Start a run from a template with --template, filling in its parameters:
+
+  moat run --template code-review --param repo=acme/api ./api
+
+Templates are <name>.yaml files or <name>/moat.yaml directories in
+~/.moat/templates, then in each source listed under templates.sources in
+~/.moat/config.yaml. A source is a directory or a git repository URL.
+
+Without a subcommand, lists every template with its parameters.`,
+	Args: cobra.NoArgs,
+	RunE: runTemplateList,
+}
+
+var templateShowCmd = &cobra.Command{
+	Use:   "show <name>",
+	Short: "Print a template and its parameters",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runTemplateShow,
+}
+
+var templateUpdateCmd = &cobra.Command{
+	Use:   "update",
+	Short: "Pull the latest templates from git sources",
+	Long: `Pull the latest templates from every git source in templates.sources.
+Git sources are cloned the first time they are used and not updated after
+that until this command runs.`,
+	Args: cobra.NoArgs,
+	RunE: runTemplateUpdate,
+}
+
+func init() {
+	rootCmd.AddCommand(templateCmd)
+	templateCmd.AddCommand(templateShowCmd)
+	templateCmd.AddCommand(templateUpdateCmd)
+}
+
+// templateSources returns the template sources from the global config.
+func templateSources() ([]string, error) {
+	globalCfg, err := config.LoadGlobal()
+	if err != nil {
+		return nil, err
+	}
+	return templates.Sources(globalCfg.Templates.Sources), nil
+}
+
+// templateEntry is the --json shape of a template.
+type templateEntry struct {
+	Name        string            `json:"name"`
+	Source      string            `json:"source"`
+	Description string            `json:"description,omitempty"`
+	Params      []templates.Param `json:"params"`
+}
+
+func newTemplateEntry(t templates.Template) templateEntry {
+	e := templateEntry{Name: t.Name, Source: t.Source, Description: t.Description(), Params: []templates.Param{}}
+	if data, err := os.ReadFile(t.Path); err == nil {
+		e.Params = templates.Params(data)
+	}
+	return e
+}
+
+func runTemplateList(cmd *cobra.Command, args []string) error {
+	sources, err := templateSources()
+	if err != nil {
+		return err
+	}
+	all, err := templates.List(context.Background(), sources)
+	if err != nil {
+		return err
+	}
+	entries := make([]templateEntry, 0, len(all))
+	for _, t := range all {
+		entries = append(entries, newTemplateEntry(t))
+	}
+	if jsonOut {
+		return json.NewEncoder(os.Stdout).Encode(entries)
+	}
+	if len(entries) == 0 {
+		fmt.Printf("No templates found. Add <name>.yaml files to %s.\n", templates.Dir())
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tPARAMS\tDESCRIPTION")
+	for _, e := range entries {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", e.Name, formatTemplateParams(e.Params), e.Description)
+	}
+	return w.Flush()
+}
+
+// formatTemplateParams lists parameters, marking optional ones with their
+// default.
+func formatTemplateParams(params []templates.Param) string {
+	if len(params) == 0 {
+		return "-"
+	}
+	parts := make([]string, 0, len(params))
+	for _, p := range params {
+		if p.Required {
+			parts = append(parts, p.Name)
+		} else {
+			parts = append(parts, fmt.Sprintf("%s=%q", p.Name, p.Default))
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+func runTemplateShow(cmd *cobra.Command, args []string) error {
+	sources, err := templateSources()
+	if err != nil {
+		return err
+	}
+	t, err := templates.Find(context.Background(), sources, args[0])
+	if err != nil {
+		return err
+	}
+	if jsonOut {
+		return json.NewEncoder(os.Stdout).Encode(newTemplateEntry(t))
+	}
+	data, err := os.ReadFile(t.Path)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Template: %s\nSource:   %s\nParams:   %s\n\n", t.Name, t.Source, formatTemplateParams(templates.Params(data)))
+	os.Stdout.Write(data)
+	return nil
+}
+
+func runTemplateUpdate(cmd *cobra.Command, args []string) error {
+	sources, err := templateSources()
+	if err != nil {
+		return err
+	}
+	if err := templates.Update(context.Background(), sources); err != nil {
+		return err
+	}
+	fmt.Println("Templates updated")
+	return nil
+}
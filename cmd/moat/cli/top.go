@@ -0,0 +1,184 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/majorcontext/moat/internal/config"
+	"github.com/majorcontext/moat/internal/daemon"
+	"github.com/majorcontext/moat/internal/run"
+	"github.com/majorcontext/moat/internal/ui"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var (
+	topNoStream bool
+	topInterval time.Duration
+)
+
+var topCmd = &cobra.Command{
+	Use:   "top",
+	Short: "Show live resource usage of all running runs",
+	Long: `Show the CPU, memory, and network usage of every running run and of its
+service containers, updating in place until Ctrl+C.
+
+Usage is sampled by the proxy daemon through the container runtime's API,
+so watching many runs does not start a process per run, and several
+viewers share one sample. CPU is a percentage of one CPU: 200% means two
+CPUs fully busy. NET I/O is the total received and sent since the
+container started, and NET RATE the rate since the previous sample.
+Network totals are zero for containers on the host network (Docker on
+Linux without published ports). Kubernetes pods do not report usage.
+
+For one run's usage over time, including stopped runs, use 'moat stats'.
+
+Examples:
+  moat top
+  moat top --interval 5s
+  moat top --no-stream --json`,
+	Args: cobra.NoArgs,
+	RunE: runTop,
+}
+
+func init() {
+	rootCmd.AddCommand(topCmd)
+	topCmd.Flags().BoolVar(&topNoStream, "no-stream", false, "print one sample and exit")
+	topCmd.Flags().DurationVar(&topInterval, "interval", 2*time.Second, "time between samples")
+}
+
+func runTop(_ *cobra.Command, _ []string) error {
+	if topInterval <= 0 {
+		return fmt.Errorf("--interval must be positive")
+	}
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	client := daemon.NewClient(filepath.Join(config.GlobalConfigDir(), "proxy", "daemon.sock"))
+	health, err := client.Health(ctx)
+	if err != nil {
+		// No daemon means no runs are registered.
+		if jsonOut {
+			fmt.Println("[]")
+		} else {
+			fmt.Println("No runs are running")
+		}
+		return nil
+	}
+	if !slices.Contains(health.Capabilities, daemon.CapStats) {
+		return fmt.Errorf("the running proxy daemon is too old for moat top; run 'moat proxy restart' to upgrade it")
+	}
+
+	manager, err := run.NewManager()
+	if err != nil {
+		return fmt.Errorf("creating run manager: %w", err)
+	}
+	defer manager.Close()
+
+	// CPU and network rates need two samples, so the first is a baseline
+	// taken a second before the first one shown.
+	if _, err := client.Stats(ctx); err != nil {
+		return fmt.Errorf("sampling runs: %w", err)
+	}
+	live := term.IsTerminal(int(os.Stdout.Fd())) && !jsonOut
+	ticker := time.NewTicker(min(topInterval, time.Second))
+	defer ticker.Stop()
+	for first := true; ; first = false {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		if first {
+			ticker.Reset(topInterval)
+		}
+		stats, err := client.Stats(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("sampling runs: %w", err)
+		}
+		if jsonOut {
+			data, _ := json.Marshal(stats)
+			fmt.Println(string(data))
+		} else {
+			frame := renderTop(stats, runNames(manager, stats), time.Now())
+			if live {
+				// Home the cursor and clear the screen, so the table
+				// updates in place.
+				fmt.Print("\033[H\033[2J")
+			} else if !first {
+				fmt.Println()
+			}
+			fmt.Print(frame)
+		}
+		if topNoStream {
+			return nil
+		}
+	}
+}
+
+// runNames returns the names of the sampled runs, by run ID. Runs the
+// manager does not know, such as ones started after moat top, are shown by
+// ID.
+func runNames(manager *run.Manager, stats []daemon.RunStats) map[string]string {
+	names := make(map[string]string, len(stats))
+	for _, rs := range stats {
+		if r, err := manager.Get(rs.RunID); err == nil && r.Name != "" {
+			names[rs.RunID] = r.Name
+		}
+	}
+	return names
+}
+
+// renderTop formats a sample as a table: a row per run's container, with
+// its service containers indented beneath it.
+func renderTop(stats []daemon.RunStats, names map[string]string, now time.Time) string {
+	var buf bytes.Buffer
+	containers := 0
+	for _, rs := range stats {
+		containers += len(rs.Containers)
+	}
+	fmt.Fprintln(&buf, ui.Dim(fmt.Sprintf("%s  %d runs, %d containers", now.Format("15:04:05"), len(stats), containers)))
+	if len(stats) == 0 {
+		fmt.Fprintln(&buf, "No runs are running")
+		return buf.String()
+	}
+
+	w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tRUN\tCPU\tMEMORY\tNET I/O\tNET RATE")
+	for _, rs := range stats {
+		for _, c := range rs.Containers {
+			name, id := c.Service, ""
+			if name == "" {
+				name, id = names[rs.RunID], rs.RunID
+				if name == "" {
+					name = rs.RunID
+				}
+			} else {
+				name = "  └ " + name
+			}
+			if c.Error != "" {
+				fmt.Fprintf(w, "%s\t%s\t-\t-\t-\t-\n", name, id)
+				continue
+			}
+			fmt.Fprintf(w, "%s\t%s\t%.1f%%\t%s\t%s / %s\t%s/s / %s/s\n",
+				name, id, c.CPUPercent,
+				formatMemory(c.MemoryBytes, c.MemoryLimitBytes),
+				formatStatsBytes(c.NetRxBytes), formatStatsBytes(c.NetTxBytes),
+				formatStatsBytes(uint64(c.NetRxRate)), formatStatsBytes(uint64(c.NetTxRate)))
+		}
+	}
+	w.Flush()
+	return buf.String()
+}
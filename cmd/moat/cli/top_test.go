@@ -0,0 +1,39 @@
+package cli
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/majorcontext/moat/internal/daemon"
+)
+
+func TestRenderTop(t *testing.T) {
+	stats := []daemon.RunStats{{
+		RunID: "run_a1b2c3d4e5f6",
+		Containers: []daemon.ContainerStats{
+			{ContainerID: "ctr1", CPUPercent: 12.5, MemoryBytes: 256 << 20, MemoryLimitBytes: 4 << 30, NetRxBytes: 3 << 20, NetTxBytes: 2048, NetRxRate: 1536},
+			{Service: "postgres", ContainerID: "ctr2", Error: "container resource usage is not available from this runtime"},
+		},
+	}}
+	out := renderTop(stats, map[string]string{"run_a1b2c3d4e5f6": "my-agent"}, time.Date(2026, 1, 2, 15, 4, 5, 0, time.UTC))
+	lines := strings.Split(strings.TrimRight(out, "\n"), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("renderTop printed %d lines, want 4:\n%s", len(lines), out)
+	}
+	if !strings.Contains(lines[0], "1 runs, 2 containers") {
+		t.Errorf("summary = %q", lines[0])
+	}
+	for _, want := range []string{"my-agent", "run_a1b2c3d4e5f6", "12.5%", "256.0 MB / 4.00 GB", "3.0 MB / 2.0 KB", "1.5 KB/s / 0 B/s"} {
+		if !strings.Contains(lines[2], want) {
+			t.Errorf("run row %q does not contain %q", lines[2], want)
+		}
+	}
+	if fields := strings.Fields(lines[3]); len(fields) != 6 || fields[1] != "postgres" || fields[2] != "-" {
+		t.Errorf("service row = %q, want postgres with no usage", lines[3])
+	}
+
+	if out := renderTop(nil, nil, time.Now()); !strings.Contains(out, "No runs are running") {
+		t.Errorf("renderTop(nil) = %q", out)
+	}
+}
@@ -3,17 +3,21 @@ package cli
 import (
 	"encoding/json"
 	"fmt"
+	"slices"
 	"sort"
+	"strings"
 
 	"github.com/majorcontext/moat/internal/log"
 	"github.com/majorcontext/moat/internal/run"
 	"github.com/majorcontext/moat/internal/storage"
+	"github.com/majorcontext/moat/internal/ui"
 	"github.com/spf13/cobra"
 )
 
 var (
-	traceNetwork bool
-	traceVerbose bool
+	traceNetwork   bool
+	traceDecisions bool
+	traceVerbose   bool
 )
 
 var traceCmd = &cobra.Command{
@@ -28,6 +32,7 @@ Examples:
   moat trace run_a1b2c3d4e5f6  # Traces from specific run
   moat trace --network         # Show network requests
   moat trace --network -v      # Show network requests with headers and bodies
+  moat trace --decisions       # Show why each request was allowed or denied
   moat trace --json            # Output as JSON`,
 	Args: cobra.MaximumNArgs(1),
 	RunE: runTrace,
@@ -36,6 +41,7 @@ Examples:
 func init() {
 	rootCmd.AddCommand(traceCmd)
 	traceCmd.Flags().BoolVar(&traceNetwork, "network", false, "show network requests")
+	traceCmd.Flags().BoolVar(&traceDecisions, "decisions", false, "show proxy decisions: matched rule, injected grants, and transformers per request")
 	traceCmd.Flags().BoolVarP(&traceVerbose, "verbose", "v", false, "show headers and bodies (requires --network)")
 }
 
@@ -68,9 +74,15 @@ func runTrace(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("opening run storage: %w", err)
 	}
 
+	if traceNetwork && traceDecisions {
+		return fmt.Errorf("--network and --decisions are mutually exclusive")
+	}
 	if traceNetwork {
 		return showNetworkRequests(store, runID)
 	}
+	if traceDecisions {
+		return showDecisions(store, runID)
+	}
 
 	return showSpans(store, runID)
 }
@@ -126,6 +138,9 @@ func showNetworkRequests(store *storage.RunStore, runID string) error {
 		return nil
 	}
 
+	// Without network.capture: full, the trace holds no headers.
+	captured := slices.ContainsFunc(reqs, func(r storage.NetworkRequest) bool { return len(r.RequestHeaders) > 0 })
+
 	for _, req := range reqs {
 		status := fmt.Sprintf("%d", req.StatusCode)
 		if req.Error != "" {
@@ -142,9 +157,73 @@ func showNetworkRequests(store *storage.RunStore, runID string) error {
 			fmt.Println()
 		}
 	}
+	if traceVerbose && !captured {
+		fmt.Println(ui.Dim("Headers and request bodies were not recorded; set network.capture: full in moat.yaml to capture them."))
+	}
 	return nil
 }
 
+func showDecisions(store *storage.RunStore, runID string) error {
+	decisions, err := store.ReadDecisions()
+	if err != nil {
+		return fmt.Errorf("reading decisions: %w", err)
+	}
+
+	if jsonOut {
+		data, _ := json.MarshalIndent(decisions, "", "  ")
+		fmt.Println(string(data))
+		return nil
+	}
+
+	log.Info("displaying proxy decisions", "runID", runID)
+	if len(decisions) == 0 {
+		fmt.Println("No proxy decisions recorded")
+		return nil
+	}
+
+	for _, d := range decisions {
+		fmt.Printf("[%s] %-5s %s %s%s %s\n", d.Timestamp.Format("15:04:05.000"), strings.ToUpper(d.Decision), d.Method, d.Host, d.Path, formatDecisionReason(d))
+		if len(d.Grants) > 0 {
+			fmt.Printf("  grants: %s (headers: %s)\n", strings.Join(d.Grants, ", "), strings.Join(d.Injected, ", "))
+		}
+		if len(d.Transformers) > 0 {
+			fmt.Printf("  transformers: %s\n", strings.Join(d.Transformers, ", "))
+		}
+		if d.Decision != "deny" {
+			fmt.Printf("  %s, %s sent, %s received, %dms\n", decisionStatus(d), formatDecisionBytes(d.RequestBytes), formatDecisionBytes(d.ResponseBytes), d.Duration)
+		}
+	}
+	return nil
+}
+
+// formatDecisionReason renders why a request was allowed or denied.
+func formatDecisionReason(d storage.Decision) string {
+	switch {
+	case d.Reason == storage.DecisionRule:
+		return fmt.Sprintf("(rule %q)", d.Rule)
+	case d.Rule != "":
+		return fmt.Sprintf("(%s, rule %q)", d.Reason, d.Rule)
+	case d.Reason == storage.DecisionPolicy && d.Policy != "":
+		return fmt.Sprintf("(%s policy default)", d.Policy)
+	default:
+		return fmt.Sprintf("(%s)", d.Reason)
+	}
+}
+
+func decisionStatus(d storage.Decision) string {
+	if d.Error != "" {
+		return "error: " + d.Error
+	}
+	return fmt.Sprintf("status %d", d.StatusCode)
+}
+
+func formatDecisionBytes(n int64) string {
+	if n < 0 {
+		return "? bytes"
+	}
+	return fmt.Sprintf("%d bytes", n)
+}
+
 func printHeadersAndBody(label string, headers map[string]string, body string) {
 	if len(headers) > 0 {
 		fmt.Printf("  %s Headers:\n", label)
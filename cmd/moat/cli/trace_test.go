@@ -300,3 +300,65 @@ func TestShowNetworkRequestsEmpty(t *testing.T) {
 		t.Errorf("expected 'No network requests recorded' message, got: %q", output)
 	}
 }
+
+func TestShowDecisions(t *testing.T) {
+	dir := t.TempDir()
+	store, err := storage.NewRunStore(dir, "run_tracedec1")
+	if err != nil {
+		t.Fatalf("NewRunStore: %v", err)
+	}
+
+	ts := time.Date(2025, 3, 15, 10, 23, 44, 0, time.UTC)
+	for _, d := range []storage.Decision{
+		{
+			Timestamp:     ts,
+			Method:        "GET",
+			Host:          "api.github.com",
+			Path:          "/repos/org/repo",
+			Decision:      "allow",
+			Reason:        storage.DecisionRule,
+			Rule:          "api.github.com: allow GET /repos/*",
+			Policy:        "strict",
+			Grants:        []string{"github"},
+			Injected:      []string{"authorization"},
+			Transformers:  []string{"request-header-set"},
+			StatusCode:    200,
+			RequestBytes:  0,
+			ResponseBytes: 512,
+			Duration:      80,
+		},
+		{
+			Timestamp:     ts.Add(time.Second),
+			Method:        "GET",
+			Host:          "evil.example.com",
+			Path:          "/",
+			Decision:      "deny",
+			Reason:        "network_policy",
+			RequestBytes:  -1,
+			ResponseBytes: -1,
+		},
+	} {
+		if err := store.WriteDecision(d); err != nil {
+			t.Fatalf("WriteDecision: %v", err)
+		}
+	}
+
+	jsonOut = false
+	output := captureStdout(t, func() {
+		if err := showDecisions(store, "run_tracedec1"); err != nil {
+			t.Fatalf("showDecisions: %v", err)
+		}
+	})
+
+	for _, want := range []string{
+		`ALLOW GET api.github.com/repos/org/repo (rule "api.github.com: allow GET /repos/*")`,
+		"grants: github (headers: authorization)",
+		"transformers: request-header-set",
+		"status 200, 0 bytes sent, 512 bytes received, 80ms",
+		"DENY  GET evil.example.com/ (network_policy)",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("output missing %q:\n%s", want, output)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/majorcontext/moat/internal/log"
+	"github.com/majorcontext/moat/internal/run"
+	"github.com/majorcontext/moat/internal/ui"
+)
+
+// printUploadViolations prints the requests refused for exceeding the run's
+// upload caps at the end of the run, since the agent may not surface the
+// refusals.
+func printUploadViolations(r *run.Run) {
+	if ui.Quiet() || r.Store == nil {
+		return
+	}
+	reqs, err := r.Store.ReadNetworkRequests()
+	if err != nil {
+		log.Debug("reading network log for upload violations", "runID", r.ID, "error", err)
+		return
+	}
+	s := run.SummarizeUploadViolations(reqs)
+	if s.Violations == 0 {
+		return
+	}
+	fmt.Println()
+	ui.Warnf("%d %s refused for exceeding upload limits: %s", s.Violations, plural(s.Violations, "request", "requests"), s)
+	ui.Info("Raise the caps in network.uploads if the uploads were expected.")
+}
@@ -1,9 +1,10 @@
 package cli
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
-	"sort"
+	"strings"
 	"text/tabwriter"
 
 	"github.com/spf13/cobra"
@@ -18,6 +19,13 @@ import (
 
 var wtFlags intcli.ExecFlags
 
+var (
+	wtCleanForce  bool
+	wtMergeInto   string
+	wtMergeFFOnly bool
+	wtMergeClean  bool
+)
+
 var wtCmd = &cobra.Command{
 	Use:   "wt <branch> [-- command]",
 	Short: "Start a run in a git worktree",
@@ -39,9 +47,12 @@ Examples:
   # Run a specific command in the worktree
   moat wt dark-mode -- make test
 
-  # List worktree-based runs
+  # List worktrees with their runs and unmerged commits
   moat wt list
 
+  # Merge a finished branch back and remove its worktree
+  moat wt merge dark-mode --clean
+
   # Clean up stopped worktrees
   moat wt clean
   moat wt clean dark-mode`,
@@ -55,7 +66,7 @@ func init() {
 
 	wtListCmd := &cobra.Command{
 		Use:   "list",
-		Short: "List worktree-based runs",
+		Short: "List worktrees with their runs",
 		RunE:  runWorktreeList,
 	}
 	wtCmd.AddCommand(wtListCmd)
@@ -66,11 +77,37 @@ func init() {
 		Long: `Remove worktree directories for stopped runs. Never deletes branches.
 
 Without arguments, cleans all worktrees for the current repo whose runs are stopped.
-With a branch name, cleans only that worktree.`,
+With a branch name, cleans only that worktree. Worktrees with uncommitted
+changes are kept unless --force is given.`,
 		Args: cobra.MaximumNArgs(1),
 		RunE: runWorktreeClean,
 	}
+	wtCleanCmd.Flags().BoolVar(&wtCleanForce, "force", false, "also remove worktrees with uncommitted changes")
 	wtCmd.AddCommand(wtCleanCmd)
+
+	wtMergeCmd := &cobra.Command{
+		Use:   "merge <branch>",
+		Short: "Merge a worktree branch into its base branch",
+		Long: `Merge a worktree's branch back into the base branch after a run completes.
+
+The base branch is the branch checked out in the repository's main checkout,
+or the one given with --into. When the base branch has not moved, it is
+fast-forwarded; otherwise a merge commit is created. Conflicts are detected
+before anything changes: if the merge would conflict, the conflicting files
+are listed and nothing is merged.
+
+If the base branch is checked out, the merge runs there and needs a checkout
+without uncommitted changes. Otherwise only the branch ref is updated.
+
+Uncommitted changes in the worktree are not merged. Use --dry-run to see what
+would happen.`,
+		Args: cobra.ExactArgs(1),
+		RunE: runWorktreeMerge,
+	}
+	wtMergeCmd.Flags().StringVar(&wtMergeInto, "into", "", "branch to merge into (default: the branch checked out in the main checkout)")
+	wtMergeCmd.Flags().BoolVar(&wtMergeFFOnly, "ff-only", false, "refuse to merge unless the base branch can be fast-forwarded")
+	wtMergeCmd.Flags().BoolVar(&wtMergeClean, "clean", false, "remove the worktree after a successful merge")
+	wtCmd.AddCommand(wtMergeCmd)
 }
 
 func runWorktree(cmd *cobra.Command, args []string) error {
@@ -114,17 +151,13 @@ func runWorktree(cmd *cobra.Command, args []string) error {
 	}
 
 	// Resolve worktree
-	result, err := worktree.Resolve(repoRoot, repoID, branch, cfg.Name)
+	result, err := worktree.Resolve(repoRoot, repoID, branch, cfg.Name, worktree.ProvisionFor(cfg))
 	if err != nil {
 		return fmt.Errorf("resolving worktree: %w", err)
 	}
 
 	// User feedback
-	if result.Reused {
-		ui.Infof("Using existing worktree at %s", result.WorkspacePath)
-	} else {
-		ui.Infof("Created worktree at %s", result.WorkspacePath)
-	}
+	intcli.ReportWorktree(result)
 
 	// Reload config from worktree if it has its own moat.yaml
 	if wtCfg, loadErr := config.Load(result.WorkspacePath); loadErr == nil && wtCfg != nil {
@@ -196,20 +229,95 @@ func runWorktree(cmd *cobra.Command, args []string) error {
 	return err
 }
 
-func runWorktreeList(cmd *cobra.Command, args []string) error {
+// worktreeRepo returns the root and repo ID of the git repository
+// containing the current directory.
+func worktreeRepo() (repoRoot, repoID string, err error) {
 	cwd, err := os.Getwd()
 	if err != nil {
-		return fmt.Errorf("getting current directory: %w", err)
+		return "", "", fmt.Errorf("getting current directory: %w", err)
 	}
-
-	repoRoot, err := worktree.FindRepoRoot(cwd)
+	repoRoot, err = worktree.FindRepoRoot(cwd)
 	if err != nil {
-		return fmt.Errorf("not inside a git repository: %w", err)
+		return "", "", fmt.Errorf("not inside a git repository: %w", err)
+	}
+	repoID, err = worktree.ResolveRepoID(repoRoot)
+	if err != nil {
+		return "", "", fmt.Errorf("resolving repo identity: %w", err)
 	}
+	return repoRoot, repoID, nil
+}
 
-	repoID, err := worktree.ResolveRepoID(repoRoot)
+// runUsesWorktree reports whether r runs in the worktree at path, as its
+// workspace or as one of its workspaces: repositories.
+func runUsesWorktree(r *run.Run, path string) bool {
+	if r.WorktreePath == path {
+		return true
+	}
+	for _, repo := range r.WorkspaceRepos {
+		if repo.Worktree && repo.Source == path {
+			return true
+		}
+	}
+	return false
+}
+
+// latestWorktreeRun returns the most recent run in the worktree at path, or
+// nil.
+func latestWorktreeRun(runs []*run.Run, path string) *run.Run {
+	var latest *run.Run
+	for _, r := range runs {
+		if runUsesWorktree(r, path) && (latest == nil || r.CreatedAt.After(latest.CreatedAt)) {
+			latest = r
+		}
+	}
+	return latest
+}
+
+// activeWorktreeRun returns a running run in the worktree at path, or nil.
+func activeWorktreeRun(runs []*run.Run, path string) *run.Run {
+	for _, r := range runs {
+		if runUsesWorktree(r, path) && r.GetState() == run.StateRunning {
+			return r
+		}
+	}
+	return nil
+}
+
+// worktreeInfo is a row of moat wt list, and its --json shape.
+type worktreeInfo struct {
+	Branch   string `json:"branch"`
+	Path     string `json:"path"`
+	RunID    string `json:"run_id,omitempty"`
+	RunName  string `json:"run_name,omitempty"`
+	RunState string `json:"run_state,omitempty"`
+	Base     string `json:"base,omitempty"`
+	Ahead    int    `json:"ahead"`
+	Behind   int    `json:"behind"`
+	Dirty    bool   `json:"dirty"`
+}
+
+// changes summarizes how the worktree differs from its base branch.
+func (wi worktreeInfo) changes() string {
+	var parts []string
+	if wi.Ahead > 0 {
+		parts = append(parts, fmt.Sprintf("%d ahead", wi.Ahead))
+	}
+	if wi.Behind > 0 {
+		parts = append(parts, fmt.Sprintf("%d behind", wi.Behind))
+	}
+	if wi.Dirty {
+		parts = append(parts, "uncommitted changes")
+	}
+	if len(parts) == 0 {
+		return "-"
+	}
+	return strings.Join(parts, ", ")
+}
+
+func runWorktreeList(cmd *cobra.Command, args []string) error {
+	repoRoot, repoID, err := worktreeRepo()
 	if err != nil {
-		return fmt.Errorf("resolving repo identity: %w", err)
+		return err
 	}
 
 	manager, err := run.NewManager()
@@ -217,48 +325,78 @@ func runWorktreeList(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("creating run manager: %w", err)
 	}
 	defer manager.Close()
-
 	runs := manager.List()
 
-	var wtRuns []*run.Run
-	for _, r := range runs {
-		if r.WorktreeRepoID == repoID {
-			wtRuns = append(wtRuns, r)
+	entries, err := worktree.ListWorktrees(repoID)
+	if err != nil {
+		return err
+	}
+	// Branches are compared with what is checked out in the main checkout,
+	// where moat wt merge merges them by default.
+	base, _ := worktree.CurrentBranch(repoRoot)
+
+	infos := make([]worktreeInfo, 0, len(entries))
+	for _, e := range entries {
+		wi := worktreeInfo{Branch: e.Branch, Path: e.Path}
+		if r := latestWorktreeRun(runs, e.Path); r != nil {
+			wi.RunID, wi.RunName, wi.RunState = r.ID, r.Name, string(r.GetState())
 		}
+		if base != "" && base != e.Branch {
+			if plan, planErr := worktree.PlanMerge(repoRoot, e.Branch, base); planErr == nil {
+				wi.Base, wi.Ahead, wi.Behind = base, plan.Ahead, plan.Behind
+			}
+		}
+		wi.Dirty, _ = worktree.Dirty(e.Path)
+		infos = append(infos, wi)
 	}
 
-	if len(wtRuns) == 0 {
-		fmt.Println("No worktree runs found for this repository")
+	if jsonOut {
+		return json.NewEncoder(os.Stdout).Encode(infos)
+	}
+	if len(infos) == 0 {
+		fmt.Println("No worktrees found for this repository")
 		return nil
 	}
 
-	sort.Slice(wtRuns, func(i, j int) bool {
-		return wtRuns[i].CreatedAt.After(wtRuns[j].CreatedAt)
-	})
-
 	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-	fmt.Fprintln(w, "BRANCH\tRUN NAME\tSTATUS\tWORKTREE")
-	for _, r := range wtRuns {
-		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n",
-			r.WorktreeBranch, r.Name, r.GetState(), intcli.ShortenPath(r.WorktreePath))
+	fmt.Fprintf(w, "BRANCH\tRUN NAME\tSTATUS\tCHANGES\tWORKTREE\n")
+	for _, wi := range infos {
+		name, state := wi.RunName, wi.RunState
+		if name == "" {
+			name, state = "-", "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", wi.Branch, name, state, wi.changes(), intcli.ShortenPath(wi.Path))
 	}
 	return w.Flush()
 }
 
-func runWorktreeClean(cmd *cobra.Command, args []string) error {
-	cwd, err := os.Getwd()
-	if err != nil {
-		return fmt.Errorf("getting current directory: %w", err)
+// cleanWorktree removes the worktree for branch unless a run is active in
+// it or, without force, it has uncommitted changes. It reports whether the
+// worktree was (or, with --dry-run, would be) removed.
+func cleanWorktree(repoRoot string, runs []*run.Run, branch, path string, force bool) (bool, error) {
+	if r := activeWorktreeRun(runs, path); r != nil {
+		return false, fmt.Errorf("cannot clean worktree for branch %q: run %s is still active. Stop it first with 'moat stop %s'", branch, r.Name, r.ID)
 	}
-
-	repoRoot, err := worktree.FindRepoRoot(cwd)
-	if err != nil {
-		return fmt.Errorf("not inside a git repository: %w", err)
+	if !force {
+		if dirty, _ := worktree.Dirty(path); dirty {
+			return false, fmt.Errorf("worktree for branch %q has uncommitted changes; commit them or pass --force to discard them", branch)
+		}
+	}
+	if dryRun {
+		fmt.Printf("Dry run - would clean worktree for branch %s (%s)\n", branch, intcli.ShortenPath(path))
+		return true, nil
 	}
+	if err := worktree.Clean(repoRoot, path); err != nil {
+		return false, err
+	}
+	ui.Infof("Cleaned worktree for branch %s", branch)
+	return true, nil
+}
 
-	repoID, err := worktree.ResolveRepoID(repoRoot)
+func runWorktreeClean(cmd *cobra.Command, args []string) error {
+	repoRoot, repoID, err := worktreeRepo()
 	if err != nil {
-		return fmt.Errorf("resolving repo identity: %w", err)
+		return err
 	}
 
 	manager, err := run.NewManager()
@@ -266,25 +404,15 @@ func runWorktreeClean(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("creating run manager: %w", err)
 	}
 	defer manager.Close()
+	runs := manager.List()
 
 	if len(args) > 0 {
 		branch := args[0]
 		if validateErr := worktree.ValidateBranch(branch); validateErr != nil {
 			return validateErr
 		}
-		wtPath := worktree.Path(repoID, branch)
-
-		for _, r := range manager.List() {
-			if r.WorktreePath == wtPath && r.GetState() == run.StateRunning {
-				return fmt.Errorf("cannot clean worktree for branch %q: run %s is still active. Stop it first with 'moat stop %s'", branch, r.Name, r.ID)
-			}
-		}
-
-		if cleanErr := worktree.Clean(repoRoot, wtPath); cleanErr != nil {
-			return cleanErr
-		}
-		ui.Infof("Cleaned worktree for branch %s", branch)
-		return nil
+		_, err := cleanWorktree(repoRoot, runs, branch, worktree.Path(repoID, branch), wtCleanForce)
+		return err
 	}
 
 	entries, err := worktree.ListWorktrees(repoID)
@@ -299,23 +427,17 @@ func runWorktreeClean(cmd *cobra.Command, args []string) error {
 
 	cleaned := 0
 	for _, entry := range entries {
-		active := false
-		for _, r := range manager.List() {
-			if r.WorktreePath == entry.Path && r.GetState() == run.StateRunning {
-				active = true
-				break
-			}
-		}
-		if active {
+		if activeWorktreeRun(runs, entry.Path) != nil {
 			continue
 		}
-
-		if err := worktree.Clean(repoRoot, entry.Path); err != nil {
-			ui.Warnf("Failed to clean %s: %v", entry.Branch, err)
+		ok, err := cleanWorktree(repoRoot, runs, entry.Branch, entry.Path, wtCleanForce)
+		if err != nil {
+			ui.Warnf("Skipped %s: %v", entry.Branch, err)
 			continue
 		}
-		ui.Infof("Cleaned worktree for branch %s", entry.Branch)
-		cleaned++
+		if ok {
+			cleaned++
+		}
 	}
 
 	if cleaned == 0 {
@@ -323,3 +445,93 @@ func runWorktreeClean(cmd *cobra.Command, args []string) error {
 	}
 	return nil
 }
+
+func runWorktreeMerge(cmd *cobra.Command, args []string) error {
+	branch := args[0]
+	if err := worktree.ValidateBranch(branch); err != nil {
+		return err
+	}
+	repoRoot, repoID, err := worktreeRepo()
+	if err != nil {
+		return err
+	}
+
+	base := wtMergeInto
+	if base == "" {
+		if base, err = worktree.CurrentBranch(repoRoot); err != nil {
+			return err
+		}
+		if base == "" {
+			return fmt.Errorf("%s has a detached HEAD; pass --into <branch> to choose the branch to merge into", repoRoot)
+		}
+	}
+	if base == branch {
+		return fmt.Errorf("cannot merge %s into itself", branch)
+	}
+
+	manager, err := run.NewManager()
+	if err != nil {
+		return fmt.Errorf("creating run manager: %w", err)
+	}
+	defer manager.Close()
+	runs := manager.List()
+
+	wtPath := worktree.Path(repoID, branch)
+	if r := activeWorktreeRun(runs, wtPath); r != nil {
+		return fmt.Errorf("run %s is still active in the worktree for branch %q. Wait for it to finish or stop it with 'moat stop %s'", r.Name, branch, r.ID)
+	}
+	dirty, _ := worktree.Dirty(wtPath)
+	if dirty {
+		ui.Warnf("The worktree for %s has uncommitted changes; they are not merged", branch)
+	}
+
+	plan, err := worktree.PlanMerge(repoRoot, branch, base)
+	if err != nil {
+		return err
+	}
+	switch {
+	case plan.UpToDate():
+		fmt.Printf("%s is already up to date with %s\n", base, branch)
+		return nil
+	case len(plan.Conflicts) > 0:
+		fmt.Printf("Merging %s into %s would conflict in:\n", branch, base)
+		for _, f := range plan.Conflicts {
+			fmt.Printf("  %s\n", f)
+		}
+		return fmt.Errorf("merge conflicts; resolve them by merging %s into %s manually", base, branch)
+	case wtMergeFFOnly && !plan.FastForward():
+		return fmt.Errorf("%s has %d commit(s) not on %s; not a fast-forward (drop --ff-only to create a merge commit)", base, plan.Behind, branch)
+	}
+
+	how := fmt.Sprintf("fast-forward %s to %s (%d commit(s))", base, branch, plan.Ahead)
+	if !plan.FastForward() {
+		how = fmt.Sprintf("merge %s into %s with a merge commit (%d commit(s); %s has %d new)", branch, base, plan.Ahead, base, plan.Behind)
+	}
+	if dryRun {
+		fmt.Printf("Dry run - would %s\n", how)
+		if wtMergeClean && !dirty {
+			fmt.Printf("Dry run - would clean worktree for branch %s\n", branch)
+		}
+		return nil
+	}
+
+	if err := worktree.Merge(repoRoot, plan, wtMergeFFOnly); err != nil {
+		return err
+	}
+	if plan.FastForward() {
+		ui.Infof("Fast-forwarded %s to %s", base, branch)
+	} else {
+		ui.Infof("Merged %s into %s", branch, base)
+	}
+
+	if wtMergeClean {
+		if dirty {
+			ui.Warnf("Kept the worktree for %s because it has uncommitted changes", branch)
+			return nil
+		}
+		if _, err := cleanWorktree(repoRoot, runs, branch, wtPath, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
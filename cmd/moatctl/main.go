@@ -0,0 +1,14 @@
+// Command moatctl is the helper moat mounts into every run at
+// /moat/bin/moatctl. moat embeds static Linux builds of it; see
+// internal/run/moatctl.go.
+package main
+
+import (
+	"os"
+
+	"github.com/majorcontext/moat/internal/moatctl"
+)
+
+func main() {
+	os.Exit(moatctl.Main(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
@@ -1,6 +1,7 @@
 package audit
 
 import (
+	"fmt"
 	"time"
 )
 
@@ -17,3 +18,30 @@ type Attestation struct {
 func (a *Attestation) Verify() bool {
 	return VerifySignature(a.PublicKey, []byte(a.RootHash), a.Signature)
 }
+
+// Attest signs the hash of the most recent entry and saves the resulting
+// attestation, checkpointing the chain up to that entry.
+func (s *Store) Attest(signer *Signer) (*Attestation, error) {
+	s.mu.Lock()
+	err := s.loadLastEntry()
+	seq, hash := s.lastSeq, s.lastHash
+	s.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	if seq == 0 {
+		return nil, fmt.Errorf("cannot attest an empty log")
+	}
+
+	att := &Attestation{
+		Sequence:  seq,
+		RootHash:  hash,
+		Timestamp: time.Now().UTC(),
+		Signature: signer.Sign([]byte(hash)),
+		PublicKey: signer.PublicKey(),
+	}
+	if err := s.SaveAttestation(att); err != nil {
+		return nil, err
+	}
+	return att, nil
+}
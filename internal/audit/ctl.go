@@ -0,0 +1,17 @@
+package audit
+
+// EntryCtl is the entry type for calls a run made with moatctl, the helper
+// mounted into every container.
+const EntryCtl EntryType = "ctl"
+
+// CtlData records one moatctl call and how moat handled it.
+type CtlData struct {
+	Command string `json:"command"`          // "snapshot", "progress", "budget", or "approve"
+	Detail  string `json:"detail,omitempty"` // e.g. the snapshot label or requested host
+	Result  string `json:"result"`           // e.g. "ok", "approved", "denied", or an error
+}
+
+// AppendCtl adds a moatctl call entry.
+func (s *Store) AppendCtl(data CtlData) (*Entry, error) {
+	return s.Append(EntryCtl, &data)
+}
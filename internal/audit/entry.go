@@ -52,14 +52,16 @@ type CredentialData struct {
 
 // SecretData holds secret resolution entry data.
 type SecretData struct {
-	Name    string `json:"name"`    // env var name, e.g., "OPENAI_API_KEY"
-	Backend string `json:"backend"` // e.g., "1password", "ssm"
+	Name    string `json:"name"`              // env var name, e.g., "OPENAI_API_KEY"
+	Backend string `json:"backend"`           // e.g., "1password", "ssm"
+	Refresh bool   `json:"refresh,omitempty"` // re-resolved by moat secrets refresh
+	Changed bool   `json:"changed,omitempty"` // refresh found a new value
 	// Note: value is never logged
 }
 
 // SSHData holds SSH agent operation entry data.
 type SSHData struct {
-	Action      string `json:"action"`                // "list", "sign_allowed", "sign_denied"
+	Action      string `json:"action"`                // "list", "sign_allowed", "sign_denied", "sign_limited", "alert_<kind>"
 	Host        string `json:"host,omitempty"`        // target host (for sign operations)
 	Fingerprint string `json:"fingerprint,omitempty"` // key fingerprint (for sign operations)
 	Error       string `json:"error,omitempty"`       // error message (for denied operations)
@@ -71,6 +73,9 @@ type ContainerData struct {
 	Privileged bool   `json:"privileged,omitempty"` // true if container runs in privileged mode
 	Reason     string `json:"reason,omitempty"`     // e.g., "docker:dind" for why privileged
 
+	// Labels are the run's user-supplied labels (creation entry only).
+	Labels map[string]string `json:"labels,omitempty"`
+
 	// BuildKit sidecar info (dind mode only)
 	BuildKitEnabled     bool   `json:"buildkit_enabled,omitempty"`
 	BuildKitContainerID string `json:"buildkit_container_id,omitempty"`
@@ -82,6 +87,9 @@ type ExecData struct {
 	Command  []string `json:"command"`
 	HasStdin bool     `json:"has_stdin"`
 	ExitCode int      `json:"exit_code"`
+	WorkDir  string   `json:"workdir,omitempty"`
+	Env      []string `json:"env,omitempty"` // variable names only; values may be secrets
+	TTY      bool     `json:"tty,omitempty"`
 }
 
 // Entry represents a single hash-chained log entry.
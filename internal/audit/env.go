@@ -0,0 +1,17 @@
+package audit
+
+// EntryEnv is the entry type for changes to a running run's environment
+// overrides (moat env set / moat env unset).
+const EntryEnv EntryType = "env"
+
+// EnvData records which environment overrides changed. Values are never
+// logged, since they may hold secrets.
+type EnvData struct {
+	Action string   `json:"action"` // "set" or "unset"
+	Names  []string `json:"names"`
+}
+
+// AppendEnv adds an environment override entry.
+func (s *Store) AppendEnv(data EnvData) (*Entry, error) {
+	return s.Append(EntryEnv, &data)
+}
@@ -0,0 +1,32 @@
+package audit
+
+// EntryIsolation is the entry type for the isolation record of a no-egress run.
+const EntryIsolation EntryType = "isolation"
+
+// IsolationData describes the isolation guarantees a no-egress run was
+// created with. It is appended once, before the container starts, and
+// signed with an attestation so the record can be verified offline.
+type IsolationData struct {
+	Mode              string           `json:"mode"`               // always "no-egress"
+	Grants            []string         `json:"grants"`             // always empty
+	NetworkPolicy     string           `json:"network_policy"`     // always "strict"
+	AllowedHosts      []string         `json:"allowed_hosts"`      // always empty
+	WorkspaceReadOnly bool             `json:"workspace_readonly"` // /workspace mounted read-only
+	Mounts            []IsolationMount `json:"mounts"`
+	Services          []string         `json:"services"` // service containers started; always empty
+	Image             string           `json:"image"`
+	Runtime           string           `json:"runtime"`
+	Privileged        bool             `json:"privileged"`
+}
+
+// IsolationMount records a host bind mount visible to the container.
+type IsolationMount struct {
+	Source   string `json:"source"`
+	Target   string `json:"target"`
+	ReadOnly bool   `json:"readonly"`
+}
+
+// AppendIsolation adds an isolation record entry.
+func (s *Store) AppendIsolation(data IsolationData) (*Entry, error) {
+	return s.Append(EntryIsolation, &data)
+}
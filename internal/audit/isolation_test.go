@@ -0,0 +1,54 @@
+package audit
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestAppendIsolation_Attest(t *testing.T) {
+	store := newTestStore(t)
+	defer store.Close()
+
+	signer, err := NewSigner(filepath.Join(t.TempDir(), "key.pem"))
+	if err != nil {
+		t.Fatalf("NewSigner: %v", err)
+	}
+
+	if _, err := store.Attest(signer); err == nil {
+		t.Error("Attest on empty log should fail")
+	}
+
+	entry, err := store.AppendIsolation(IsolationData{
+		Mode:              "no-egress",
+		Grants:            []string{},
+		NetworkPolicy:     "strict",
+		AllowedHosts:      []string{},
+		WorkspaceReadOnly: true,
+		Mounts:            []IsolationMount{{Source: "/src", Target: "/workspace", ReadOnly: true}},
+	})
+	if err != nil {
+		t.Fatalf("AppendIsolation: %v", err)
+	}
+	if entry.Type != EntryIsolation {
+		t.Errorf("Type = %q, want %q", entry.Type, EntryIsolation)
+	}
+
+	att, err := store.Attest(signer)
+	if err != nil {
+		t.Fatalf("Attest: %v", err)
+	}
+	if att.Sequence != entry.Sequence || att.RootHash != entry.Hash {
+		t.Errorf("attestation = (%d, %s), want (%d, %s)", att.Sequence, att.RootHash, entry.Sequence, entry.Hash)
+	}
+	if !att.Verify() {
+		t.Error("attestation signature should verify")
+	}
+
+	saved, err := store.LoadAttestations()
+	if err != nil {
+		t.Fatalf("LoadAttestations: %v", err)
+	}
+	if len(saved) != 1 || !saved[0].Verify() {
+		t.Fatalf("LoadAttestations = %+v, want one valid attestation", saved)
+	}
+}
@@ -0,0 +1,21 @@
+package audit
+
+// EntryMessage is the entry type for messages sent to third parties through
+// a messaging grant (Twilio SMS and calls, SendGrid email).
+const EntryMessage EntryType = "message"
+
+// MessageData records one send request and whether the proxy let it through.
+type MessageData struct {
+	Grant      string `json:"grant"`
+	Method     string `json:"method"`
+	URL        string `json:"url"`
+	Decision   string `json:"decision"` // "allow" or "deny"
+	StatusCode int    `json:"status_code,omitempty"`
+	Sent       int    `json:"sent"`  // sends allowed so far in the run
+	Limit      int    `json:"limit"` // the run's messaging.max_messages
+}
+
+// AppendMessage adds a message send entry.
+func (s *Store) AppendMessage(data MessageData) (*Entry, error) {
+	return s.Append(EntryMessage, &data)
+}
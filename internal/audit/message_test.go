@@ -0,0 +1,73 @@
+package audit
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestAppendMessage(t *testing.T) {
+	store := newTestStore(t)
+	defer store.Close()
+
+	entry, err := store.AppendMessage(MessageData{
+		Grant:      "sendgrid",
+		Method:     "POST",
+		URL:        "https://api.sendgrid.com/v3/mail/send",
+		Decision:   "deny",
+		StatusCode: 407,
+		Sent:       20,
+		Limit:      20,
+	})
+	if err != nil {
+		t.Fatalf("AppendMessage: %v", err)
+	}
+	if entry.Type != EntryMessage {
+		t.Errorf("Type = %q, want %q", entry.Type, EntryMessage)
+	}
+
+	got, err := store.Get(1)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if !got.Verify() {
+		t.Error("round-trip hash verification failed")
+	}
+	data, ok := got.Data.(map[string]any)
+	if !ok {
+		t.Fatalf("Data = %T, want map", got.Data)
+	}
+	if data["grant"] != "sendgrid" || data["decision"] != "deny" || data["limit"] != float64(20) {
+		t.Errorf("Data = %v", data)
+	}
+}
+
+func TestAppendMessage_AfterOtherWriter(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "audit.db")
+	run, err := OpenStore(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer run.Close()
+	daemon, err := OpenStore(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer daemon.Close()
+
+	// The run's process writes after the daemon opened the store; the
+	// daemon's send entry must still chain onto it.
+	console, err := run.AppendConsole("starting")
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := daemon.AppendMessage(MessageData{Grant: "sendgrid", Method: "POST", Decision: "allow"})
+	if err != nil {
+		t.Fatalf("AppendMessage: %v", err)
+	}
+	if msg.Sequence != 2 || msg.PrevHash != console.Hash {
+		t.Errorf("message entry seq %d prev %q, want 2 after %q", msg.Sequence, msg.PrevHash, console.Hash)
+	}
+	if _, err := run.AppendConsole("done"); err != nil {
+		t.Fatalf("AppendConsole after the daemon's entry: %v", err)
+	}
+}
@@ -0,0 +1,201 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/majorcontext/moat/internal/log"
+)
+
+// Event is an audit entry as it is shipped off the host. It carries the
+// entry's hash chain fields, so a collector can check that it received an
+// unbroken chain for each run.
+type Event struct {
+	RunID     string          `json:"run_id"`
+	Host      string          `json:"host"`
+	Sequence  uint64          `json:"seq"`
+	Timestamp time.Time       `json:"ts"`
+	Type      EntryType       `json:"type"`
+	PrevHash  string          `json:"prev"`
+	Data      json.RawMessage `json:"data"`
+	Hash      string          `json:"hash"`
+}
+
+// Sink delivers audit events to a collector off the host.
+type Sink interface {
+	// Name identifies the sink in log messages, e.g. "syslog".
+	Name() string
+	// Send delivers a batch of events, in order. It is called from a
+	// single goroutine per sink.
+	Send(ctx context.Context, events []Event) error
+	// Close releases the sink's connections.
+	Close() error
+}
+
+const (
+	// shipQueueSize is how many events may wait for a slow sink before new
+	// ones are dropped.
+	shipQueueSize = 4096
+	// shipBatchSize caps the events delivered in one Send.
+	shipBatchSize = 100
+	// shipAttempts is how many times a batch is sent before it is dropped.
+	shipAttempts = 3
+	// shipTimeout bounds a single Send.
+	shipTimeout = 10 * time.Second
+)
+
+// shipRetryDelay is the pause before the first retry of a failed batch; it
+// doubles for each later one (replaceable for testing).
+var shipRetryDelay = time.Second
+
+var (
+	shipMu   sync.RWMutex
+	shippers []*shipper
+)
+
+// shipper streams events to one sink from a background goroutine, so
+// appending to an audit store never waits on the network.
+type shipper struct {
+	sink    Sink
+	queue   chan Event
+	done    chan struct{}
+	dropped atomic.Int64
+}
+
+// StartShipping streams every entry appended to an audit store in this
+// process to sinks, in near real time. Delivery is best-effort: events that
+// cannot be delivered after retries, or that arrive while a sink's queue is
+// full, are dropped and counted in a warning; the local audit log is
+// written either way. It returns a function that delivers queued events,
+// waiting until ctx is done, and stops shipping.
+func StartShipping(sinks ...Sink) (stop func(context.Context) error) {
+	if len(sinks) == 0 {
+		return func(context.Context) error { return nil }
+	}
+	started := make([]*shipper, 0, len(sinks))
+	for _, sink := range sinks {
+		s := &shipper{
+			sink:  sink,
+			queue: make(chan Event, shipQueueSize),
+			done:  make(chan struct{}),
+		}
+		go s.run()
+		started = append(started, s)
+	}
+	shipMu.Lock()
+	shippers = append(shippers, started...)
+	shipMu.Unlock()
+
+	return func(ctx context.Context) error {
+		shipMu.Lock()
+		shippers = slices.DeleteFunc(shippers, func(s *shipper) bool {
+			return slices.Contains(started, s)
+		})
+		shipMu.Unlock()
+
+		var errs []error
+		for _, s := range started {
+			close(s.queue)
+			select {
+			case <-s.done:
+			case <-ctx.Done():
+				errs = append(errs, ctx.Err())
+			}
+			errs = append(errs, s.sink.Close())
+		}
+		return errors.Join(errs...)
+	}
+}
+
+// Shipping reports whether audit entries appended in this process are
+// being shipped to a collector.
+func Shipping() bool {
+	shipMu.RLock()
+	defer shipMu.RUnlock()
+	return len(shippers) > 0
+}
+
+// shipHost is the machine name events are labelled with.
+var shipHost = sync.OnceValue(func() string {
+	host, _ := os.Hostname()
+	return host
+})
+
+// ship queues e, appended to runID's audit log, for every running shipper.
+func ship(runID string, e *Entry) {
+	shipMu.RLock()
+	defer shipMu.RUnlock()
+	if len(shippers) == 0 {
+		return
+	}
+	ev := Event{
+		RunID:     runID,
+		Host:      shipHost(),
+		Sequence:  e.Sequence,
+		Timestamp: e.Timestamp,
+		Type:      e.Type,
+		PrevHash:  e.PrevHash,
+		Data:      json.RawMessage(e.dataJSON),
+		Hash:      e.Hash,
+	}
+	for _, s := range shippers {
+		select {
+		case s.queue <- ev:
+		default:
+			s.dropped.Add(1)
+		}
+	}
+}
+
+func (s *shipper) run() {
+	defer close(s.done)
+	for ev := range s.queue {
+		batch := []Event{ev}
+	fill:
+		for len(batch) < shipBatchSize {
+			select {
+			case next, ok := <-s.queue:
+				if !ok {
+					break fill
+				}
+				batch = append(batch, next)
+			default:
+				break fill
+			}
+		}
+		s.deliver(batch)
+	}
+}
+
+// deliver sends batch, retrying failures with backoff, and reports events
+// dropped since the last delivery.
+func (s *shipper) deliver(batch []Event) {
+	delay := shipRetryDelay
+	var err error
+	for attempt := 1; attempt <= shipAttempts; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), shipTimeout)
+		err = s.sink.Send(ctx, batch)
+		cancel()
+		if err == nil {
+			break
+		}
+		if attempt < shipAttempts {
+			time.Sleep(delay)
+			delay *= 2
+		}
+	}
+	if err != nil {
+		log.Warn("dropping audit events the sink did not accept",
+			"subsystem", "audit", "sink", s.sink.Name(), "events", len(batch), "error", err)
+	}
+	if n := s.dropped.Swap(0); n > 0 {
+		log.Warn("dropped audit events while the sink was behind",
+			"subsystem", "audit", "sink", s.sink.Name(), "events", n)
+	}
+}
@@ -0,0 +1,137 @@
+package audit
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// syslogPriority is facility 13 (log audit) at severity 5 (notice).
+const syslogPriority = 13*8 + 5
+
+// SyslogSink ships audit events as RFC 5424 syslog messages, one per event,
+// with the event's JSON as the message. Messages over TCP and TLS are framed
+// with octet counting (RFC 6587); over UDP each is one datagram.
+type SyslogSink struct {
+	network string // "udp", "tcp", or "tls"
+	addr    string // host:port
+	tag     string
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+// NewSyslogSink returns a sink for the syslog server at address, written
+// as udp://host[:port], tcp://host[:port], or tls://host[:port]. The port
+// defaults to 514, or 6514 for tls. tag is the message's APP-NAME.
+func NewSyslogSink(address, tag string) (*SyslogSink, error) {
+	network, addr, err := ParseSyslogAddress(address)
+	if err != nil {
+		return nil, err
+	}
+	if tag == "" {
+		tag = "moat"
+	}
+	return &SyslogSink{network: network, addr: addr, tag: tag}, nil
+}
+
+// ParseSyslogAddress splits a syslog address into its transport and
+// host:port, applying the transport's default port.
+func ParseSyslogAddress(address string) (network, addr string, err error) {
+	u, err := url.Parse(address)
+	if err != nil || u.Hostname() == "" || u.Path != "" {
+		return "", "", fmt.Errorf("syslog address must be udp://, tcp://, or tls:// followed by host[:port], got %q", address)
+	}
+	port := u.Port()
+	switch u.Scheme {
+	case "udp", "tcp":
+		if port == "" {
+			port = "514"
+		}
+	case "tls":
+		if port == "" {
+			port = "6514"
+		}
+	default:
+		return "", "", fmt.Errorf("syslog address must be udp://, tcp://, or tls:// followed by host[:port], got %q", address)
+	}
+	return u.Scheme, net.JoinHostPort(u.Hostname(), port), nil
+}
+
+// Name implements Sink.
+func (s *SyslogSink) Name() string { return "syslog" }
+
+// Send implements Sink. A write error drops the connection, so the next
+// attempt redials.
+func (s *SyslogSink) Send(ctx context.Context, events []Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		conn, err := s.dial(ctx)
+		if err != nil {
+			return fmt.Errorf("connecting to syslog server %s: %w", s.addr, err)
+		}
+		s.conn = conn
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = s.conn.SetWriteDeadline(deadline)
+	}
+	for _, ev := range events {
+		msg, err := s.format(ev)
+		if err != nil {
+			return err
+		}
+		if _, err := s.conn.Write(msg); err != nil {
+			s.conn.Close()
+			s.conn = nil
+			return fmt.Errorf("writing to syslog server %s: %w", s.addr, err)
+		}
+	}
+	return nil
+}
+
+func (s *SyslogSink) dial(ctx context.Context) (net.Conn, error) {
+	if s.network == "tls" {
+		d := &tls.Dialer{Config: &tls.Config{MinVersion: tls.VersionTLS12}}
+		return d.DialContext(ctx, "tcp", s.addr)
+	}
+	var d net.Dialer
+	return d.DialContext(ctx, s.network, s.addr)
+}
+
+// format renders ev as a syslog message, framed for the transport.
+func (s *SyslogSink) format(ev Event) ([]byte, error) {
+	body, err := json.Marshal(ev)
+	if err != nil {
+		return nil, fmt.Errorf("encoding audit event: %w", err)
+	}
+	host := ev.Host
+	if host == "" {
+		host = "-"
+	}
+	// <PRI>VERSION TIMESTAMP HOSTNAME APP-NAME PROCID MSGID SD MSG
+	msg := fmt.Sprintf("<%d>1 %s %s %s - %s - %s",
+		syslogPriority, ev.Timestamp.UTC().Format(time.RFC3339Nano), host, s.tag, ev.Type, body)
+	if s.network == "udp" {
+		return []byte(msg), nil
+	}
+	return []byte(strconv.Itoa(len(msg)) + " " + msg), nil
+}
+
+// Close implements Sink.
+func (s *SyslogSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
@@ -0,0 +1,196 @@
+package audit
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// recordingSink collects shipped events, failing the first failures sends.
+type recordingSink struct {
+	mu       sync.Mutex
+	events   []Event
+	failures int
+	closed   bool
+}
+
+func (s *recordingSink) Name() string { return "recording" }
+
+func (s *recordingSink) Send(_ context.Context, events []Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.failures > 0 {
+		s.failures--
+		return errors.New("collector unavailable")
+	}
+	s.events = append(s.events, events...)
+	return nil
+}
+
+func (s *recordingSink) Close() error {
+	s.closed = true
+	return nil
+}
+
+func TestStartShipping(t *testing.T) {
+	old := shipRetryDelay
+	shipRetryDelay = 0
+	t.Cleanup(func() { shipRetryDelay = old })
+
+	runDir := filepath.Join(t.TempDir(), "run_abc123")
+	if err := os.MkdirAll(runDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	store, err := OpenStore(filepath.Join(runDir, "audit.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	sink := &recordingSink{failures: 1}
+	stop := StartShipping(sink)
+	first, _ := store.AppendContainer(ContainerData{Action: "created"})
+	second, _ := store.AppendSecret(SecretData{Name: "API_KEY", Backend: "1password"})
+	if err := stop(context.Background()); err != nil {
+		t.Fatalf("stop: %v", err)
+	}
+
+	// Entries appended after stopping are not shipped.
+	_, _ = store.AppendConsole("after stop")
+
+	if !sink.closed {
+		t.Error("sink not closed")
+	}
+	if len(sink.events) != 2 {
+		t.Fatalf("shipped %d events, want 2", len(sink.events))
+	}
+	for i, want := range []*Entry{first, second} {
+		got := sink.events[i]
+		if got.RunID != "run_abc123" || got.Sequence != want.Sequence || got.Hash != want.Hash || got.PrevHash != want.PrevHash || got.Type != want.Type {
+			t.Errorf("event %d = %+v, want entry %+v from run_abc123", i, got, want)
+		}
+	}
+	if string(sink.events[1].Data) != `{"name":"API_KEY","backend":"1password"}` {
+		t.Errorf("event data = %s", sink.events[1].Data)
+	}
+
+	// The shipped data is what was hashed, so the collector can verify it.
+	ev := sink.events[1]
+	e := &Entry{Sequence: ev.Sequence, Timestamp: ev.Timestamp, Type: ev.Type, PrevHash: ev.PrevHash, dataJSON: ev.Data}
+	if e.computeHash() != ev.Hash {
+		t.Error("shipped event does not verify against its hash")
+	}
+}
+
+func TestWebhookSink(t *testing.T) {
+	var got []Event
+	var auth string
+	status := http.StatusAccepted
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		body, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(body, &got)
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	sink, err := NewWebhookSink(srv.URL, map[string]string{"Authorization": "Bearer tok"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+	events := []Event{{RunID: "run_1", Sequence: 1, Type: EntryExec, Data: json.RawMessage(`{}`)}}
+	if err := sink.Send(context.Background(), events); err != nil {
+		t.Fatal(err)
+	}
+	if auth != "Bearer tok" || len(got) != 1 || got[0].RunID != "run_1" {
+		t.Errorf("collector got auth %q, events %+v", auth, got)
+	}
+
+	status = http.StatusServiceUnavailable
+	if err := sink.Send(context.Background(), events); err == nil {
+		t.Error("Send succeeded on a 503")
+	}
+}
+
+func TestValidateWebhookURL(t *testing.T) {
+	for _, u := range []string{"https://collector.example.com/moat", "http://localhost:8080/audit", "http://127.0.0.1:9000"} {
+		if err := ValidateWebhookURL(u); err != nil {
+			t.Errorf("ValidateWebhookURL(%q): %v", u, err)
+		}
+	}
+	for _, u := range []string{"http://collector.example.com/moat", "collector.example.com", "ftp://example.com"} {
+		if err := ValidateWebhookURL(u); err == nil {
+			t.Errorf("ValidateWebhookURL(%q) succeeded, want error", u)
+		}
+	}
+}
+
+func TestParseSyslogAddress(t *testing.T) {
+	tests := []struct {
+		in, network, addr string
+	}{
+		{"udp://logs.example.com", "udp", "logs.example.com:514"},
+		{"tcp://logs.example.com:1514", "tcp", "logs.example.com:1514"},
+		{"tls://logs.example.com", "tls", "logs.example.com:6514"},
+	}
+	for _, tt := range tests {
+		network, addr, err := ParseSyslogAddress(tt.in)
+		if err != nil || network != tt.network || addr != tt.addr {
+			t.Errorf("ParseSyslogAddress(%q) = (%q, %q, %v), want (%q, %q)", tt.in, network, addr, err, tt.network, tt.addr)
+		}
+	}
+	for _, in := range []string{"logs.example.com:514", "http://logs.example.com", "udp://"} {
+		if _, _, err := ParseSyslogAddress(in); err == nil {
+			t.Errorf("ParseSyslogAddress(%q) succeeded, want error", in)
+		}
+	}
+}
+
+func TestSyslogSinkTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	received := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		// Octet-counted frame: "<len> <msg>".
+		r := bufio.NewReader(conn)
+		lenStr, _ := r.ReadString(' ')
+		n, _ := strconv.Atoi(strings.TrimSpace(lenStr))
+		msg := make([]byte, n)
+		_, _ = io.ReadFull(r, msg)
+		received <- string(msg)
+	}()
+
+	sink, err := NewSyslogSink("tcp://"+ln.Addr().String(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+	ev := Event{RunID: "run_1", Host: "build-7", Sequence: 3, Type: EntrySSH, Data: json.RawMessage(`{"action":"sign_allowed"}`)}
+	if err := sink.Send(context.Background(), []Event{ev}); err != nil {
+		t.Fatal(err)
+	}
+	msg := <-received
+	if !strings.HasPrefix(msg, "<109>1 ") || !strings.Contains(msg, " build-7 moat - ssh - {") || !strings.Contains(msg, `"run_id":"run_1"`) {
+		t.Errorf("syslog message = %q", msg)
+	}
+}
@@ -0,0 +1,86 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+)
+
+// WebhookSink ships audit events to an HTTPS collector. Each batch is POSTed
+// as a JSON array of events; any 2xx response acknowledges it.
+type WebhookSink struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+}
+
+// NewWebhookSink returns a sink that POSTs to rawURL with headers added to
+// each request, e.g. an Authorization header. rawURL must use https, except
+// for loopback collectors.
+func NewWebhookSink(rawURL string, headers map[string]string) (*WebhookSink, error) {
+	if err := ValidateWebhookURL(rawURL); err != nil {
+		return nil, err
+	}
+	return &WebhookSink{url: rawURL, headers: headers, client: &http.Client{}}, nil
+}
+
+// ValidateWebhookURL checks that rawURL is an https URL, or an http URL for
+// a loopback address. Audit events name hosts, commands, and grants, so they
+// are not sent in the clear across a network.
+func ValidateWebhookURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("webhook URL must be an https URL, got %q", rawURL)
+	}
+	switch u.Scheme {
+	case "https":
+		return nil
+	case "http":
+		host := u.Hostname()
+		if ip := net.ParseIP(host); host == "localhost" || (ip != nil && ip.IsLoopback()) {
+			return nil
+		}
+	}
+	return fmt.Errorf("webhook URL must be an https URL (http is allowed only for localhost), got %q", rawURL)
+}
+
+// Name implements Sink.
+func (s *WebhookSink) Name() string { return "webhook" }
+
+// Send implements Sink.
+func (s *WebhookSink) Send(ctx context.Context, events []Event) error {
+	body, err := json.Marshal(events)
+	if err != nil {
+		return fmt.Errorf("encoding audit events: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "moat-audit")
+	for name, value := range s.headers {
+		req.Header.Set(name, value)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("collector responded %s", resp.Status)
+	}
+	return nil
+}
+
+// Close implements Sink.
+func (s *WebhookSink) Close() error {
+	s.client.CloseIdleConnections()
+	return nil
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"path/filepath"
 	"sync"
 	"time"
 
@@ -20,11 +21,19 @@ type Store struct {
 	mu       sync.Mutex
 	lastHash string
 	lastSeq  uint64
+	// runID labels shipped events; audit stores live at <run dir>/audit.db.
+	runID string
 }
 
-// OpenStore opens or creates a log store at the given path.
+// OpenStore opens or creates a log store at the given path. Several
+// processes may append to the same store, such as the run's CLI process and
+// the proxy daemon: each append waits for the database's write lock and
+// chains onto the last entry written by any of them.
 func OpenStore(path string) (*Store, error) {
-	db, err := sql.Open("sqlite", path)
+	// Transactions take the write lock when they begin, so an append reads
+	// the last entry and inserts the next one atomically; busy_timeout has
+	// an append wait for another process's instead of failing.
+	db, err := sql.Open("sqlite", path+"?_txlock=immediate&_pragma=busy_timeout(5000)")
 	if err != nil {
 		return nil, fmt.Errorf("opening database: %w", err)
 	}
@@ -43,7 +52,7 @@ func OpenStore(path string) (*Store, error) {
 	}
 
 	// Load last entry state
-	store := &Store{db: db}
+	store := &Store{db: db, runID: filepath.Base(filepath.Dir(path))}
 	if err := store.loadLastEntry(); err != nil {
 		db.Close()
 		return nil, err
@@ -93,7 +102,24 @@ func createTables(db *sql.DB) error {
 }
 
 func (s *Store) loadLastEntry() error {
-	row := s.db.QueryRow(`
+	seq, hash, err := lastEntry(s.db)
+	if err != nil {
+		return err
+	}
+	s.lastSeq = seq
+	s.lastHash = hash
+	return nil
+}
+
+// queryRower is satisfied by *sql.DB and *sql.Tx.
+type queryRower interface {
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// lastEntry returns the sequence number and hash of the most recent entry,
+// or zero values for an empty store.
+func lastEntry(q queryRower) (uint64, string, error) {
+	row := q.QueryRow(`
 		SELECT seq, hash FROM entries ORDER BY seq DESC LIMIT 1
 	`)
 	var seq uint64
@@ -102,29 +128,38 @@ func (s *Store) loadLastEntry() error {
 	switch {
 	case err == sql.ErrNoRows:
 		// Empty store - no entries yet
-		return nil
+		return 0, "", nil
 	case err != nil:
-		return fmt.Errorf("loading last entry: %w", err)
-	default:
-		s.lastSeq = seq
-		s.lastHash = hash
+		return 0, "", fmt.Errorf("loading last entry: %w", err)
 	}
-	return nil
+	return seq, hash, nil
 }
 
-// Append adds a new entry to the store, returning the created entry.
+// Append adds a new entry to the store, returning the created entry. The
+// entry follows the last one in the database, which another process may
+// have appended since this store last wrote.
 func (s *Store) Append(entryType EntryType, data any) (*Entry, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	entry := NewEntry(s.lastSeq+1, s.lastHash, entryType, data)
-
 	dataJSON, err := json.Marshal(data)
 	if err != nil {
 		return nil, fmt.Errorf("marshaling data: %w", err)
 	}
 
-	_, err = s.db.Exec(`
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("beginning append: %w", err)
+	}
+	defer tx.Rollback() // no-op after Commit
+
+	lastSeq, lastHash, err := lastEntry(tx)
+	if err != nil {
+		return nil, err
+	}
+	entry := NewEntry(lastSeq+1, lastHash, entryType, data)
+
+	_, err = tx.Exec(`
 		INSERT INTO entries (seq, ts, type, prev_hash, data, hash)
 		VALUES (?, ?, ?, ?, ?, ?)
 	`, entry.Sequence, entry.Timestamp.Format(time.RFC3339Nano),
@@ -132,9 +167,13 @@ func (s *Store) Append(entryType EntryType, data any) (*Entry, error) {
 	if err != nil {
 		return nil, fmt.Errorf("inserting entry: %w", err)
 	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("committing entry: %w", err)
+	}
 
 	s.lastSeq = entry.Sequence
 	s.lastHash = entry.Hash
+	ship(s.runID, entry)
 
 	return entry, nil
 }
@@ -143,6 +182,8 @@ func (s *Store) Append(entryType EntryType, data any) (*Entry, error) {
 func (s *Store) LastHash() string {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	// On error, fall back to the last entry this store has seen.
+	_ = s.loadLastEntry()
 	return s.lastHash
 }
 
@@ -363,6 +404,10 @@ func (s *Store) Export() (*ProofBundle, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	if err := s.loadLastEntry(); err != nil {
+		return nil, err
+	}
+
 	// Load all entries
 	entries, err := s.Range(1, s.lastSeq)
 	if err != nil {
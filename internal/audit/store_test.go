@@ -3,6 +3,7 @@ package audit
 import (
 	"os"
 	"path/filepath"
+	"sync"
 	"testing"
 	"time"
 )
@@ -114,6 +115,52 @@ func TestStore_PersistenceAcrossReopen(t *testing.T) {
 	_ = e1
 }
 
+func TestStore_ConcurrentWriters(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "logs.db")
+
+	// Two stores open on the same file, as the run's CLI process and the
+	// proxy daemon have, interleave appends into one chain.
+	a, err := OpenStore(dbPath)
+	if err != nil {
+		t.Fatalf("OpenStore: %v", err)
+	}
+	defer a.Close()
+	b, err := OpenStore(dbPath)
+	if err != nil {
+		t.Fatalf("OpenStore: %v", err)
+	}
+	defer b.Close()
+
+	var wg sync.WaitGroup
+	for _, s := range []*Store{a, b} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 20; i++ {
+				if _, err := s.AppendConsole("line"); err != nil {
+					t.Errorf("Append: %v", err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n, _ := a.Count(); n != 40 {
+		t.Errorf("Count = %d, want 40", n)
+	}
+	result, err := a.VerifyChain()
+	if err != nil {
+		t.Fatalf("VerifyChain: %v", err)
+	}
+	if !result.Valid {
+		t.Errorf("chain invalid: %+v", result)
+	}
+	if a.LastHash() != b.LastHash() {
+		t.Error("stores disagree on the last entry")
+	}
+}
+
 func TestStore_Get(t *testing.T) {
 	store := newTestStore(t)
 	defer store.Close()
@@ -9,6 +9,11 @@ import (
 // These are shared between `moat run`, `moat claude`, and future tool commands.
 type ExecFlags struct {
 	Grants        []string
+	Labels        []string
+	Group         string
+	IDFrom        string
+	PR            string
+	Priority      string
 	Env           []string
 	Mounts        []string
 	Name          string
@@ -20,22 +25,31 @@ type ExecFlags struct {
 	NoSandbox     bool
 	NoClipboard   bool
 	NoPrompt      bool
+	NoEgress      bool
+	SkipPreflight bool
 	TTYTrace      string // Path to save terminal I/O trace for debugging
 }
 
 // AddExecFlags adds the common execution flags to a command.
 func AddExecFlags(cmd *cobra.Command, flags *ExecFlags) {
 	cmd.Flags().StringSliceVarP(&flags.Grants, "grant", "g", nil, "capabilities to grant (e.g., github, aws:s3.read)")
+	cmd.Flags().StringArrayVar(&flags.Labels, "label", nil, "label for this run (KEY=VALUE, repeatable); filter with 'moat list -l'")
+	cmd.Flags().StringVar(&flags.Group, "group", "", "add this run to a group of related runs; manage with 'moat group'")
+	cmd.Flags().StringVar(&flags.IDFrom, "id-from", "", "derive the run ID from this key; reusing the key returns the existing run instead of starting another")
+	cmd.Flags().StringVar(&flags.PR, "pr", "", "pull request the run works on (number or URL); a summary comment is posted to it when the run ends")
+	cmd.Flags().StringVar(&flags.Priority, "priority", "", "CPU and IO priority when runs compete: high, normal, or background (default from moat.yaml)")
 	cmd.Flags().StringArrayVarP(&flags.Env, "env", "e", nil, "environment variables (KEY=VALUE)")
 	cmd.Flags().StringArrayVarP(&flags.Mounts, "mount", "m", nil, "additional mounts (source:target[:ro])")
 	cmd.Flags().StringVarP(&flags.Name, "name", "n", "", "name for this run (default: from moat.yaml or random)")
 	cmd.Flags().BoolVar(&flags.Rebuild, "rebuild", false, "force rebuild of container image")
 	cmd.Flags().BoolVar(&flags.KeepContainer, "keep", false, "keep container after run completes (for debugging)")
-	cmd.Flags().StringVar(&flags.Runtime, "runtime", "", "container runtime to use (apple, docker)")
+	cmd.Flags().StringVar(&flags.Runtime, "runtime", "", "container runtime to use (apple, docker, podman)")
 	cmd.Flags().StringVar(&flags.WorkspaceMode, "workspace-mode", "", "workspace mode: 'bind' (default) or 'volume' (isolated copy in a named volume)")
 	cmd.Flags().BoolVar(&flags.NoSandbox, "no-sandbox", false, "disable gVisor sandbox (reduced isolation, Docker only)")
 	cmd.Flags().BoolVar(&flags.NoClipboard, "no-clipboard", false, "disable host clipboard bridging")
 	cmd.Flags().BoolVar(&flags.NoPrompt, "no-prompt", false, "never prompt to grant missing credentials; fail instead")
+	cmd.Flags().BoolVar(&flags.NoEgress, "no-egress", false, "isolated run: no grants, no network egress, read-only workspace, signed attestation")
+	cmd.Flags().BoolVar(&flags.SkipPreflight, "skip-preflight", false, "skip checking granted credentials with their providers before the run (for offline use)")
 	cmd.Flags().StringVar(&flags.TTYTrace, "tty-trace", "", "capture terminal I/O to file for debugging (e.g., session.json)")
 }
 
@@ -56,7 +56,7 @@ func ResolveWorktreeWorkspace(wtBranch, workspace string, flags *ExecFlags, cfg
 		agentName = cfg.Name
 	}
 
-	result, err := worktree.Resolve(repoRoot, repoID, wtBranch, agentName)
+	result, err := worktree.Resolve(repoRoot, repoID, wtBranch, agentName, worktree.ProvisionFor(cfg))
 	if err != nil {
 		return nil, fmt.Errorf("resolving worktree: %w", err)
 	}
@@ -69,11 +69,7 @@ func ResolveWorktreeWorkspace(wtBranch, workspace string, flags *ExecFlags, cfg
 	}
 
 	// User feedback
-	if result.Reused {
-		ui.Infof("Using existing worktree at %s", result.WorkspacePath)
-	} else {
-		ui.Infof("Created worktree at %s", result.WorkspacePath)
-	}
+	ReportWorktree(result)
 
 	// Reload config from worktree path if it has its own moat.yaml
 	outCfg := cfg
@@ -92,6 +88,29 @@ func ResolveWorktreeWorkspace(wtBranch, workspace string, flags *ExecFlags, cfg
 	}, nil
 }
 
+// ReportWorktree tells the user which worktree a run uses and how a new one
+// was provisioned.
+func ReportWorktree(result *worktree.Result) {
+	if result.Reused {
+		ui.Infof("Using existing worktree at %s", result.WorkspacePath)
+		return
+	}
+	ui.Infof("Created worktree at %s", result.WorkspacePath)
+	for _, step := range result.Provisioned {
+		switch step {
+		case "submodules":
+			ui.Infof("Initialized submodules")
+		case "lfs":
+			ui.Infof("Fetched Git LFS objects")
+		case "sparse":
+			ui.Infof("Checked out only workspace.paths (sparse checkout)")
+		}
+	}
+	for _, w := range result.Warnings {
+		ui.Warnf("Worktree: %s", w)
+	}
+}
+
 // SetWorktreeFields copies worktree metadata from a Result into ExecOptions.
 func SetWorktreeFields(opts *ExecOptions, wt *worktree.Result) {
 	if wt == nil {
@@ -1,9 +1,11 @@
-// Package clipboard reads the host system clipboard.
+// Package clipboard reads and writes the host system clipboard.
 package clipboard
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
+	"runtime"
 	"strings"
 
 	nativeclipboard "github.com/aymanbagabas/go-nativeclipboard"
@@ -63,3 +65,42 @@ func Read() (content *Content, err error) {
 	}
 	return &Content{Data: text, MIMEType: "text/plain"}, nil
 }
+
+// Write copies content to the host clipboard. Content that is not an image
+// is written as text. The returned channel is closed when another
+// application replaces the clipboard content.
+func Write(c *Content) (changed <-chan struct{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			changed = nil
+			err = fmt.Errorf("clipboard unavailable: %v", r)
+		}
+	}()
+
+	format := nativeclipboard.Text
+	if c.IsImage() {
+		format = nativeclipboard.Image
+	}
+	changed, err = format.Write(c.Data)
+	if err != nil {
+		if errors.Is(err, nativeclipboard.ErrUnavailable) {
+			return nil, fmt.Errorf("clipboard not available on this platform")
+		}
+		return nil, err
+	}
+	// X11 reports no error when it cannot open a display; read the text
+	// back to make sure the write took.
+	if !c.IsImage() {
+		if got, readErr := nativeclipboard.Text.Read(); readErr != nil || !bytes.Equal(got, c.Data) {
+			return nil, fmt.Errorf("clipboard not available (no display?)")
+		}
+	}
+	return changed, nil
+}
+
+// HeldByProcess reports whether content written with Write disappears when
+// this process exits. X11 clipboards are served by the process that set
+// them.
+func HeldByProcess() bool {
+	return runtime.GOOS == "linux" || runtime.GOOS == "freebsd"
+}
@@ -0,0 +1,60 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Package-manager cache names accepted in caches.exclude. Each maps to a host
+// directory at ~/.moat/caches/<name> shared by every run that enables caches.
+const (
+	CacheNpm   = "npm"
+	CacheGo    = "go"
+	CachePip   = "pip"
+	CacheCargo = "cargo"
+)
+
+// CacheNames lists every supported package-manager cache in a stable order.
+var CacheNames = []string{CacheNpm, CacheGo, CachePip, CacheCargo}
+
+// CachesConfig is the moat.yaml `caches:` block.
+//
+// When enabled, moat mounts a host cache directory for each package manager
+// implied by `dependencies` (node → npm, go → go, python → pip, rust → cargo),
+// so repeated runs reuse downloaded packages instead of fetching them again.
+//
+// Example:
+//
+//	caches:
+//	  enabled: true
+//	  exclude: [pip]
+type CachesConfig struct {
+	Enabled bool     `yaml:"enabled,omitempty"`
+	Exclude []string `yaml:"exclude,omitempty"`
+}
+
+// Validate rejects unknown cache names in exclude.
+func (c CachesConfig) Validate() error {
+	for _, name := range c.Exclude {
+		if !slices.Contains(CacheNames, name) {
+			return fmt.Errorf("caches.exclude: unknown cache %q (must be one of: %s)", name, strings.Join(CacheNames, ", "))
+		}
+	}
+	return nil
+}
+
+// Excludes reports whether the named cache is listed in caches.exclude.
+func (c CachesConfig) Excludes(name string) bool {
+	return slices.Contains(c.Exclude, name)
+}
+
+// CacheDir returns the host directory for a package-manager cache.
+// Path: ~/.moat/caches/<name>/
+//
+// Caches are shared across agents and runs; callers must create the
+// directory before mounting.
+func CacheDir(name string) string {
+	return filepath.Join(GlobalConfigDir(), "caches", name)
+}
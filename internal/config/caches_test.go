@@ -0,0 +1,73 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCachesConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		exclude []string
+		wantErr bool
+	}{
+		{"empty", nil, false},
+		{"all known", []string{"npm", "go", "pip", "cargo"}, false},
+		{"unknown", []string{"maven"}, true},
+		{"known then unknown", []string{"npm", "yarn"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CachesConfig{Enabled: true, Exclude: tt.exclude}.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate(%v) err=%v wantErr=%v", tt.exclude, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadCaches(t *testing.T) {
+	dir := t.TempDir()
+	yaml := "agent: test\ncaches:\n  enabled: true\n  exclude: [pip]\n"
+	if err := os.WriteFile(filepath.Join(dir, "moat.yaml"), []byte(yaml), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if !cfg.Caches.Enabled {
+		t.Error("caches.enabled should be true")
+	}
+	if !cfg.Caches.Excludes("pip") {
+		t.Error("caches.exclude should contain pip")
+	}
+	if cfg.Caches.Excludes("npm") {
+		t.Error("caches.exclude should not contain npm")
+	}
+}
+
+func TestLoadCachesRejectsUnknownExclude(t *testing.T) {
+	dir := t.TempDir()
+	yaml := "agent: test\ncaches:\n  enabled: true\n  exclude: [maven]\n"
+	if err := os.WriteFile(filepath.Join(dir, "moat.yaml"), []byte(yaml), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	_, err := Load(dir)
+	if err == nil {
+		t.Fatal("expected error for unknown cache name")
+	}
+	if !strings.Contains(err.Error(), "maven") {
+		t.Errorf("error should mention offending value, got: %v", err)
+	}
+}
+
+func TestCacheDir(t *testing.T) {
+	got := CacheDir("npm")
+	want := filepath.Join(GlobalConfigDir(), "caches", "npm")
+	if got != want {
+		t.Errorf("CacheDir(npm) = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,106 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// ClockConfig is the moat.yaml `container.clock:` block.
+//
+// It runs the container against a fake clock using libfaketime, which moat
+// installs into the image and preloads into every dynamically linked
+// process. Set either start (the clock begins at that time and advances
+// normally, or stays there with freeze) or offset (real time shifted by a
+// duration).
+//
+// Example:
+//
+//	container:
+//	  clock:
+//	    start: 2024-02-29T23:59:50Z
+//	    freeze: true
+type ClockConfig struct {
+	Start  string `yaml:"start,omitempty"`
+	Offset string `yaml:"offset,omitempty"`
+	Freeze bool   `yaml:"freeze,omitempty"`
+}
+
+// clockStartLayouts are the accepted container.clock.start formats. Times
+// without a zone are in the container's timezone.
+var clockStartLayouts = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02"}
+
+// validateContainerClock checks container.timezone and container.clock.
+func validateContainerClock(c ContainerConfig) error {
+	if c.Timezone != "" {
+		if _, err := time.LoadLocation(c.Timezone); err != nil || c.Timezone == "Local" {
+			return fmt.Errorf("container.timezone: unknown timezone %q (use an IANA name such as America/New_York)", c.Timezone)
+		}
+	}
+	if c.Clock == nil {
+		return nil
+	}
+	switch {
+	case c.Clock.Start == "" && c.Clock.Offset == "":
+		return fmt.Errorf("container.clock: set start or offset")
+	case c.Clock.Start != "" && c.Clock.Offset != "":
+		return fmt.Errorf("container.clock: start and offset are mutually exclusive")
+	case c.Clock.Offset != "" && c.Clock.Freeze:
+		return fmt.Errorf("container.clock.freeze requires start")
+	}
+	if c.Clock.Start != "" {
+		if _, err := parseClockStart(c.Clock.Start, time.UTC); err != nil {
+			return fmt.Errorf("container.clock.start: %w", err)
+		}
+	}
+	if c.Clock.Offset != "" {
+		if _, err := time.ParseDuration(c.Clock.Offset); err != nil {
+			return fmt.Errorf("container.clock.offset: %w", err)
+		}
+	}
+	return nil
+}
+
+func parseClockStart(s string, loc *time.Location) (time.Time, error) {
+	for _, layout := range clockStartLayouts {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q (use RFC 3339, e.g. 2024-01-01T09:00:00Z)", s)
+}
+
+// FakeTime returns the libfaketime FAKETIME value for container.clock, or ""
+// if no fake clock is configured. Absolute times are rendered in the
+// container's timezone, which is how libfaketime interprets them.
+func (c ContainerConfig) FakeTime() string {
+	if c.Clock == nil {
+		return ""
+	}
+	if c.Clock.Offset != "" {
+		d, err := time.ParseDuration(c.Clock.Offset)
+		if err != nil {
+			return ""
+		}
+		secs := int64(d / time.Second)
+		if secs < 0 {
+			return strconv.FormatInt(secs, 10)
+		}
+		return "+" + strconv.FormatInt(secs, 10)
+	}
+	loc := time.UTC
+	if c.Timezone != "" {
+		if l, err := time.LoadLocation(c.Timezone); err == nil {
+			loc = l
+		}
+	}
+	t, err := parseClockStart(c.Clock.Start, loc)
+	if err != nil {
+		return ""
+	}
+	ts := t.In(loc).Format("2006-01-02 15:04:05")
+	if c.Clock.Freeze {
+		return ts
+	}
+	return "@" + ts
+}
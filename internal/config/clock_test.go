@@ -0,0 +1,83 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadConfigContainerClock(t *testing.T) {
+	dir := t.TempDir()
+	content := `
+agent: claude-code
+container:
+  timezone: America/New_York
+  locale: ja_JP.UTF-8
+  clock:
+    start: 2024-02-29T23:59:50Z
+    freeze: true
+`
+	os.WriteFile(filepath.Join(dir, "moat.yaml"), []byte(content), 0o644)
+
+	cfg, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Container.Timezone != "America/New_York" || cfg.Container.Locale != "ja_JP.UTF-8" {
+		t.Errorf("Timezone = %q, Locale = %q", cfg.Container.Timezone, cfg.Container.Locale)
+	}
+	// 23:59:50 UTC is 18:59:50 EST; libfaketime reads it in the container's zone.
+	if got, want := cfg.Container.FakeTime(), "2024-02-29 18:59:50"; got != want {
+		t.Errorf("FakeTime() = %q, want %q", got, want)
+	}
+}
+
+func TestContainerConfigFakeTime(t *testing.T) {
+	tests := []struct {
+		name string
+		c    ContainerConfig
+		want string
+	}{
+		{"unset", ContainerConfig{}, ""},
+		{"start", ContainerConfig{Clock: &ClockConfig{Start: "2024-01-01"}}, "@2024-01-01 00:00:00"},
+		{"start in timezone", ContainerConfig{Timezone: "Asia/Tokyo", Clock: &ClockConfig{Start: "2024-01-01T09:00:00"}}, "@2024-01-01 09:00:00"},
+		{"past offset", ContainerConfig{Clock: &ClockConfig{Offset: "-72h"}}, "-259200"},
+		{"future offset", ContainerConfig{Clock: &ClockConfig{Offset: "90m"}}, "+5400"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.c.FakeTime(); got != tt.want {
+				t.Errorf("FakeTime() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadConfigContainerClockValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		wantErr string
+	}{
+		{"unknown timezone", "timezone: Mars/Olympus", "container.timezone"},
+		{"empty clock", "clock: {freeze: true}", "set start or offset"},
+		{"start and offset", "clock: {start: 2024-01-01, offset: 1h}", "mutually exclusive"},
+		{"freeze with offset", "clock: {offset: 1h, freeze: true}", "freeze requires start"},
+		{"bad start", "clock: {start: yesterday}", "container.clock.start"},
+		{"bad offset", "clock: {offset: 3 days}", "container.clock.offset"},
+		{"locale without codeset", "locale: en_US", "container.locale"},
+		{"locale injection", "locale: \"en_US.UTF-8\\nRUN id\"", "container.locale"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			content := "agent: claude-code\ncontainer:\n  " + tt.yaml + "\n"
+			os.WriteFile(filepath.Join(dir, "moat.yaml"), []byte(content), 0o644)
+			_, err := Load(dir)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Load() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,178 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ComposeFilename is the file `moat compose` reads by default.
+const ComposeFilename = "moat.compose.yaml"
+
+// composeNameRe restricts project and run names to DNS labels, since run
+// names become hostnames on the shared network and in routing URLs.
+var composeNameRe = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?$`)
+
+// Compose is a moat.compose.yaml file: a set of named runs started together
+// on a shared network.
+type Compose struct {
+	// Name is the project name. Defaults to the name of the directory
+	// containing the file.
+	Name string `yaml:"name,omitempty"`
+	// Runtime selects the container runtime for every run, like the
+	// runtime field of moat.yaml.
+	Runtime string                `yaml:"runtime,omitempty"`
+	Runs    map[string]ComposeRun `yaml:"runs"`
+
+	// Dir is the directory containing the file; relative workspaces are
+	// resolved against it.
+	Dir string `yaml:"-"`
+}
+
+// ComposeRun is one run in a Compose file. The workspace's moat.yaml
+// supplies everything not set here.
+type ComposeRun struct {
+	// Workspace is the run's workspace directory. Defaults to the
+	// directory containing the compose file.
+	Workspace string `yaml:"workspace,omitempty"`
+	// Grants replaces the grants of the workspace's moat.yaml.
+	Grants []string `yaml:"grants,omitempty"`
+	// Command replaces the command of the workspace's moat.yaml.
+	Command []string          `yaml:"command,omitempty"`
+	Env     map[string]string `yaml:"env,omitempty"`
+	Labels  map[string]string `yaml:"labels,omitempty"`
+	// DependsOn names runs that must be started before this one.
+	DependsOn []string `yaml:"depends_on,omitempty"`
+}
+
+// LoadCompose reads and validates the compose file at path.
+func LoadCompose(path string) (*Compose, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("resolving %s: %w", path, err)
+	}
+	data, err := os.ReadFile(abs)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", filepath.Base(abs), err)
+	}
+	var c Compose
+	if err := yaml.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", filepath.Base(abs), err)
+	}
+	c.Dir = filepath.Dir(abs)
+	if c.Name == "" {
+		c.Name = strings.ToLower(filepath.Base(c.Dir))
+	}
+	if err := c.validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", filepath.Base(abs), err)
+	}
+	return &c, nil
+}
+
+func (c *Compose) validate() error {
+	if !composeNameRe.MatchString(c.Name) {
+		return fmt.Errorf("project name %q must use lowercase letters, digits, and '-' (set name: to override the directory name)", c.Name)
+	}
+	switch c.Runtime {
+	case "", "docker", "podman":
+	case "apple":
+		return fmt.Errorf("runtime apple does not support shared networks; use docker or podman")
+	default:
+		return fmt.Errorf("invalid runtime %q: must be 'docker' or 'podman'", c.Runtime)
+	}
+	if len(c.Runs) == 0 {
+		return fmt.Errorf("no runs defined")
+	}
+	for name, r := range c.Runs {
+		if !composeNameRe.MatchString(name) {
+			return fmt.Errorf("run name %q must use lowercase letters, digits, and '-'", name)
+		}
+		for _, dep := range r.DependsOn {
+			if dep == name {
+				return fmt.Errorf("run %q depends on itself", name)
+			}
+			if _, ok := c.Runs[dep]; !ok {
+				return fmt.Errorf("run %q depends on undefined run %q", name, dep)
+			}
+		}
+	}
+	_, err := c.StartOrder()
+	return err
+}
+
+// WorkspacePath returns the absolute workspace of the named run.
+func (c *Compose) WorkspacePath(name string) string {
+	ws := c.Runs[name].Workspace
+	if ws == "" {
+		return c.Dir
+	}
+	if strings.HasPrefix(ws, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			ws = filepath.Join(home, ws[2:])
+		}
+	}
+	if !filepath.IsAbs(ws) {
+		ws = filepath.Join(c.Dir, ws)
+	}
+	return filepath.Clean(ws)
+}
+
+// StartOrder returns the run names ordered so that each run comes after the
+// runs it depends on. Runs with no ordering between them are sorted by name.
+func (c *Compose) StartOrder() ([]string, error) {
+	names := make([]string, 0, len(c.Runs))
+	for name := range c.Runs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make(map[string]int, len(names))
+	order := make([]string, 0, len(names))
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		switch state[name] {
+		case done:
+			return nil
+		case visiting:
+			return fmt.Errorf("dependency cycle: %s", strings.Join(append(path, name), " -> "))
+		}
+		state[name] = visiting
+		deps := append([]string(nil), c.Runs[name].DependsOn...)
+		sort.Strings(deps)
+		for _, dep := range deps {
+			if err := visit(dep, append(path, name)); err != nil {
+				return err
+			}
+		}
+		state[name] = done
+		order = append(order, name)
+		return nil
+	}
+	for _, name := range names {
+		if err := visit(name, nil); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
+
+// RunName returns the run name used for the named run: the project name and
+// run name joined by '-', so runs of different projects don't collide.
+func (c *Compose) RunName(name string) string {
+	return c.Name + "-" + name
+}
+
+// NetworkName returns the name of the project's shared network.
+func (c *Compose) NetworkName() string {
+	return "moat-compose-" + c.Name
+}
@@ -0,0 +1,89 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeCompose(t *testing.T, content string) string {
+	t.Helper()
+	dir := filepath.Join(t.TempDir(), "Fleet")
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, ComposeFilename)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadCompose(t *testing.T) {
+	path := writeCompose(t, `
+runs:
+  api:
+    workspace: ./api
+    grants: [github]
+    env:
+      PORT: "3000"
+  web:
+    workspace: /src/web
+    depends_on: [api]
+  reviewer:
+    depends_on: [web, api]
+`)
+	c, err := LoadCompose(path)
+	if err != nil {
+		t.Fatalf("LoadCompose: %v", err)
+	}
+	if c.Name != "fleet" {
+		t.Errorf("Name = %q, want directory name %q", c.Name, "fleet")
+	}
+	if got := c.WorkspacePath("api"); got != filepath.Join(c.Dir, "api") {
+		t.Errorf("WorkspacePath(api) = %q", got)
+	}
+	if got := c.WorkspacePath("web"); got != "/src/web" {
+		t.Errorf("WorkspacePath(web) = %q", got)
+	}
+	if got := c.WorkspacePath("reviewer"); got != c.Dir {
+		t.Errorf("WorkspacePath(reviewer) = %q, want compose dir", got)
+	}
+	if c.RunName("api") != "fleet-api" || c.NetworkName() != "moat-compose-fleet" {
+		t.Errorf("RunName = %q, NetworkName = %q", c.RunName("api"), c.NetworkName())
+	}
+
+	order, err := c.StartOrder()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(order, " "); got != "api web reviewer" {
+		t.Errorf("StartOrder = %q, want %q", got, "api web reviewer")
+	}
+}
+
+func TestLoadCompose_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"no runs", "name: x\n", "no runs defined"},
+		{"bad project name", "name: My_Fleet\nruns:\n  a: {}\n", "project name"},
+		{"bad run name", "runs:\n  Api: {}\n", `run name "Api"`},
+		{"unknown dependency", "runs:\n  a:\n    depends_on: [b]\n", `undefined run "b"`},
+		{"self dependency", "runs:\n  a:\n    depends_on: [a]\n", "depends on itself"},
+		{"cycle", "runs:\n  a:\n    depends_on: [b]\n  b:\n    depends_on: [a]\n", "dependency cycle: a -> b -> a"},
+		{"apple runtime", "runtime: apple\nruns:\n  a: {}\n", "does not support shared networks"},
+		{"unknown runtime", "runtime: lxc\nruns:\n  a: {}\n", "invalid runtime"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadCompose(writeCompose(t, tt.content))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("LoadCompose error = %v, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
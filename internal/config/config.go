@@ -7,6 +7,7 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"strings"
 
 	"github.com/majorcontext/moat/internal/keep"
@@ -29,6 +30,10 @@ var agentVolumeNameRe = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
 // Prevents Dockerfile injection via newlines or special characters in base_image.
 var imageRefRe = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._\-/:]*(@sha256:[a-f0-9]{64})?$`)
 
+// localeRe matches container.locale values: language_TERRITORY.codeset with
+// an optional @modifier, or C.<codeset>. Also prevents Dockerfile injection.
+var localeRe = regexp.MustCompile(`^(C|[a-z]{2,3}_[A-Z]{2})\.[A-Za-z0-9-]+(@[a-z]+)?$`)
+
 // Config represents a moat.yaml manifest.
 type Config struct {
 	Name         string            `yaml:"name,omitempty"`
@@ -53,13 +58,26 @@ type Config struct {
 	Tracing   TracingConfig   `yaml:"tracing,omitempty"`
 	Hooks     HooksConfig     `yaml:"hooks,omitempty"`
 	Workspace WorkspaceConfig `yaml:"workspace,omitempty"`
+	// Workspaces mounts more host repositories under /workspace, each with
+	// its own worktree option and git identity.
+	Workspaces []WorkspaceRepo `yaml:"workspaces,omitempty"`
+	Caches     CachesConfig    `yaml:"caches,omitempty"`
+	SSH        SSHConfig       `yaml:"ssh,omitempty"`
+	Docker     DockerConfig    `yaml:"docker,omitempty"`
+	Messaging  MessagingConfig `yaml:"messaging,omitempty"`
+	Display    DisplayConfig   `yaml:"display,omitempty"`
+	IDE        IDEConfig       `yaml:"ide,omitempty"`
+
+	PRDescription PRDescriptionConfig `yaml:"pr_description,omitempty"`
+	PRComment     PRCommentConfig     `yaml:"pr_comment,omitempty"`
 
 	// Sandbox configures container sandboxing.
 	// "none" disables gVisor sandbox (Docker only).
 	// Empty string or omitted uses default (gVisor enabled).
 	Sandbox string `yaml:"sandbox,omitempty"`
 
-	// Runtime forces a specific container runtime ("docker" or "apple").
+	// Runtime forces a specific container runtime ("docker", "apple", "podman",
+	// or "kubernetes").
 	// If not set, moat auto-detects the best available runtime.
 	// Useful when agent needs docker:dind on macOS (Apple containers can't run dind).
 	Runtime string `yaml:"runtime,omitempty"`
@@ -109,6 +127,17 @@ type ContainerConfig struct {
 	//     cpus: 8
 	CPUs int `yaml:"cpus,omitempty"`
 
+	// Priority is the run's scheduling class when runs compete for the
+	// host: "high", "normal" (default), or "background". It sets the
+	// container's relative CPU shares and block IO weight, so a background
+	// batch run yields to an interactive session but still uses idle CPU.
+	// Docker and Podman only; overridden by --priority.
+	//
+	// Example:
+	//   container:
+	//     priority: background
+	Priority string `yaml:"priority,omitempty"`
+
 	// DNS specifies DNS servers for both runtime containers and builders.
 	// Applies to both Docker and Apple containers.
 	// If not set, defaults to ["8.8.8.8", "8.8.4.4"] (Google DNS).
@@ -133,6 +162,48 @@ type ContainerConfig struct {
 	//         soft: 1024
 	//         hard: 65536
 	Ulimits map[string]UlimitSpec `yaml:"ulimits,omitempty"`
+
+	// Timezone sets the container's timezone as an IANA name. moat installs
+	// tzdata into the image and sets TZ. If not set, the container uses UTC.
+	//
+	// Example:
+	//   container:
+	//     timezone: America/New_York
+	Timezone string `yaml:"timezone,omitempty"`
+
+	// Locale sets the container's locale, such as "en_US.UTF-8". moat
+	// generates the locale in the image and sets LANG. If not set, the
+	// container has no locale configured and programs fall back to POSIX.
+	//
+	// Example:
+	//   container:
+	//     locale: ja_JP.UTF-8
+	Locale string `yaml:"locale,omitempty"`
+
+	// Clock runs the container against a fake clock. See ClockConfig.
+	Clock *ClockConfig `yaml:"clock,omitempty"`
+
+	// Devices lists host devices to pass into the container: "audio",
+	// "usb", or absolute paths under /dev. Docker runtime only. Each
+	// device must also be allowed by the host's global config (see
+	// DevicePolicy); none are passed through by default.
+	//
+	// Example:
+	//   container:
+	//     devices: [audio, /dev/ttyUSB0]
+	Devices []string `yaml:"devices,omitempty"`
+
+	// Userns runs the container in a user namespace where the runtime
+	// supports one, so root in the container is not root on the host. On
+	// rootless Podman, moat requests keep-id and maps the host user to the
+	// container user; on Docker, it relies on the daemon's userns-remap or
+	// rootless mode. Elsewhere the run falls back to the default user
+	// handling with a warning. Linux only.
+	//
+	// Example:
+	//   container:
+	//     userns: true
+	Userns bool `yaml:"userns,omitempty"`
 }
 
 // VolumeConfig defines a named volume to mount inside the container.
@@ -191,6 +262,9 @@ type ServiceSpec struct {
 	Image  string            `yaml:"image,omitempty"`
 	Wait   *bool             `yaml:"wait,omitempty"`
 	Memory int               `yaml:"memory,omitempty"` // Memory limit in MB for the service container (0 = runtime default)
+	// Network limits the agent's access to the service and the service's
+	// egress. See ServiceNetworkConfig.
+	Network *ServiceNetworkConfig `yaml:"network,omitempty"`
 	// Extra holds unknown list-valued keys (e.g., "models" for ollama).
 	// Populated by UnmarshalYAML. The run layer maps these to provisions
 	// using the registry's provisions_key.
@@ -198,7 +272,7 @@ type ServiceSpec struct {
 }
 
 // UnmarshalYAML implements custom unmarshaling to capture unknown list-valued keys
-// into Extra. Known keys (env, image, wait) are parsed normally.
+// into Extra. Known keys (env, image, wait, memory, network) are parsed normally.
 func (s *ServiceSpec) UnmarshalYAML(value *yaml.Node) error {
 	// First, decode known fields using an alias to avoid recursion.
 	type plain ServiceSpec
@@ -210,7 +284,7 @@ func (s *ServiceSpec) UnmarshalYAML(value *yaml.Node) error {
 	if value.Kind != yaml.MappingNode {
 		return nil
 	}
-	known := map[string]bool{"env": true, "image": true, "wait": true, "memory": true}
+	known := map[string]bool{"env": true, "image": true, "wait": true, "memory": true, "network": true}
 	for i := 0; i+1 < len(value.Content); i += 2 {
 		key := value.Content[i].Value
 		val := value.Content[i+1]
@@ -262,11 +336,156 @@ func (c *Config) ValidateServices(serviceNames []string) error {
 
 // NetworkConfig configures network access policies for the agent.
 type NetworkConfig struct {
-	Policy     string                      `yaml:"policy,omitempty"` // "permissive" or "strict", default "permissive"
+	Policy     string                      `yaml:"policy,omitempty"` // "permissive", "strict", or "record", default "permissive"
 	Allow      []string                    `yaml:"allow,omitempty"`  // deprecated: hard error
 	Rules      []netrules.NetworkRuleEntry `yaml:"rules,omitempty"`
 	KeepPolicy *keep.PolicyConfig          `yaml:"keep_policy,omitempty"`
 	Host       []int                       `yaml:"host,omitempty"` // TCP ports on the host the container may access
+	Mirror     *MirrorConfig               `yaml:"mirror,omitempty"`
+	Transforms []TransformConfig           `yaml:"transforms,omitempty"`
+	Faults     []FaultConfig               `yaml:"faults,omitempty"`
+	Scrub      *ScrubConfig                `yaml:"scrub,omitempty"`
+	Uploads    *UploadsConfig              `yaml:"uploads,omitempty"`
+	// Capture sets what the network trace records for each request:
+	// NetworkCaptureMetadata (the default) or NetworkCaptureFull.
+	Capture string `yaml:"capture,omitempty"`
+}
+
+// Network trace capture modes (network.capture).
+const (
+	// NetworkCaptureMetadata records the request line, status, and timing,
+	// and the body of error responses.
+	NetworkCaptureMetadata = "metadata"
+	// NetworkCaptureFull also records headers and request and response
+	// bodies, up to the proxy's capture limit.
+	NetworkCaptureFull = "full"
+)
+
+// TransformConfig applies a named request or response transformer to
+// traffic for a host. Kinds and their args are defined by the proxy
+// daemon's transformer registry (e.g. request-header-set, response-body-replace);
+// unknown kinds are rejected when the run starts.
+type TransformConfig struct {
+	Host string            `yaml:"host"`
+	Kind string            `yaml:"kind"`
+	Args map[string]string `yaml:"args,omitempty"`
+}
+
+// FaultConfig injects failures into a run's traffic to a host, so agents
+// and their retry logic can be tested against a degraded API. The proxy
+// daemon applies it to responses: the request still reaches the upstream.
+// An entry adds latency, a failure, or both, to the fraction of requests
+// given by Rate.
+type FaultConfig struct {
+	Host string `yaml:"host" json:"host"`
+	// Rate is the fraction of requests affected, in (0, 1]. Zero means all.
+	Rate float64 `yaml:"rate,omitempty" json:"rate,omitempty"`
+	// LatencyMS delays the request by this many milliseconds before it is
+	// forwarded.
+	LatencyMS int `yaml:"latency_ms,omitempty" json:"latency_ms,omitempty"`
+	// Status answers the request with an error of this status (e.g. 429)
+	// instead of forwarding it.
+	Status int `yaml:"status,omitempty" json:"status,omitempty"`
+	// RetryAfter sets the Retry-After header, in seconds, on Status responses.
+	RetryAfter int `yaml:"retry_after,omitempty" json:"retry_after,omitempty"`
+	// Reset closes the connection instead of forwarding the request.
+	Reset bool `yaml:"reset,omitempty" json:"reset,omitempty"`
+	// Truncate closes the connection after this many response body bytes.
+	Truncate int `yaml:"truncate,omitempty" json:"truncate,omitempty"`
+}
+
+// ScrubConfig strips response headers from the hosts the proxy injects
+// credentials for, so session cookies and other upstream state issued to
+// the real credential never reach the container.
+type ScrubConfig struct {
+	// Cookies strips Set-Cookie headers.
+	Cookies bool `yaml:"cookies,omitempty" json:"cookies,omitempty"`
+	// Headers, when set, is an allowlist: every other response header is
+	// removed, except those HTTP needs to deliver the body.
+	Headers []string `yaml:"headers,omitempty" json:"headers,omitempty"`
+	// Hosts are scrubbed in addition to the credentialed hosts.
+	Hosts []string `yaml:"hosts,omitempty" json:"hosts,omitempty"`
+}
+
+// UploadsConfig caps the request body size the proxy lets a run send to
+// each host, to blunt bulk exfiltration of repository contents. Sizes are
+// in megabytes.
+type UploadsConfig struct {
+	// MaxMB caps uploads to every host not listed in Hosts. Zero means no cap.
+	MaxMB int `yaml:"max_mb,omitempty"`
+	// Hosts sets caps for individual hosts or host patterns, overriding
+	// MaxMB. Zero means no cap for the host.
+	Hosts map[string]int `yaml:"hosts,omitempty"`
+}
+
+// SSHConfig limits how a run may use the SSH keys it was granted. Zero
+// values mean no limit.
+type SSHConfig struct {
+	// MaxSignaturesPerMinute caps SSH agent signatures in any 60-second window.
+	MaxSignaturesPerMinute int `yaml:"max_signatures_per_minute,omitempty"`
+	// MaxSignatures caps SSH agent signatures over the life of the run.
+	MaxSignatures int `yaml:"max_signatures,omitempty"`
+}
+
+// DockerConfig configures the docker:host dependency.
+type DockerConfig struct {
+	// Filter routes the container's Docker API requests through a filter
+	// that allows building images and running unprivileged containers, and
+	// denies privileged containers, host mounts, and host namespaces.
+	Filter bool `yaml:"filter,omitempty"`
+}
+
+// DefaultMaxMessages is the messaging send cap for runs that don't set
+// messaging.max_messages.
+const DefaultMaxMessages = 100
+
+// MessagingConfig limits how many messages a run may send through messaging
+// grants (twilio, sendgrid). The proxy enforces the cap.
+type MessagingConfig struct {
+	// MaxMessages caps SMS, calls, and emails sent over the life of the run.
+	// Zero means DefaultMaxMessages.
+	MaxMessages int `yaml:"max_messages,omitempty"`
+}
+
+// Limit returns the effective send cap.
+func (m MessagingConfig) Limit() int {
+	if m.MaxMessages == 0 {
+		return DefaultMaxMessages
+	}
+	return m.MaxMessages
+}
+
+// MirrorConfig duplicates a run's LLM API requests to a secondary endpoint
+// (an eval logger, a candidate-model harness) without affecting the primary
+// response. Copies are sent asynchronously by the proxy daemon and dropped
+// if the endpoint is slow or unreachable.
+type MirrorConfig struct {
+	// URL is the base URL requests are replayed against, or, with the
+	// record format, the endpoint that receives one JSON record per
+	// mirrored request via POST.
+	URL string `yaml:"url" json:"url"`
+	// Hosts limits mirroring to these request hosts. Empty means the
+	// Anthropic, OpenAI, and Gemini API hosts.
+	Hosts []string `yaml:"hosts,omitempty" json:"hosts,omitempty"`
+	// Format is how requests are mirrored: MirrorFormatReplay (the
+	// default) or MirrorFormatRecord.
+	Format string `yaml:"format,omitempty" json:"format,omitempty"`
+}
+
+// Mirror formats.
+const (
+	// MirrorFormatReplay sends each request's method, path, query, and
+	// body to the mirror URL, as the agent sent them to the primary host.
+	MirrorFormatReplay = "replay"
+	// MirrorFormatRecord POSTs a JSON record of each request and its
+	// response to the mirror URL.
+	MirrorFormatRecord = "record"
+)
+
+// Replays reports whether requests are replayed against the mirror URL
+// rather than recorded to it.
+func (m MirrorConfig) Replays() bool {
+	return m.Format != MirrorFormatRecord
 }
 
 // LLMGatewayConfig configures Keep LLM policy evaluation in the proxy.
@@ -285,6 +504,17 @@ type ClaudeConfig struct {
 	// URLs work because the relay runs on the host.
 	BaseURL string `yaml:"base_url,omitempty"`
 
+	// Provider selects the API Claude Code runs against: "anthropic" (the
+	// default), "bedrock" (Amazon Bedrock, authenticated with the aws grant),
+	// or "vertex" (Google Vertex AI, authenticated with the gcp grant). The
+	// proxy signs or authenticates the requests, so the container holds no
+	// cloud credentials for them.
+	Provider string `yaml:"provider,omitempty"`
+
+	// Region is the Vertex AI region (CLOUD_ML_REGION) when Provider is
+	// "vertex". Default: "global". Bedrock uses the aws grant's region.
+	Region string `yaml:"region,omitempty"`
+
 	// SyncLogs enables mounting Claude's session logs directory so logs from
 	// inside the container appear on the host at the correct project location.
 	// Default: false, unless the "anthropic" grant is configured (then true).
@@ -401,6 +631,7 @@ type SnapshotConfig struct {
 // SnapshotTriggerConfig configures when snapshots are created.
 type SnapshotTriggerConfig struct {
 	DisablePreRun        bool `yaml:"disable_pre_run,omitempty"`
+	DisablePostRun       bool `yaml:"disable_post_run,omitempty"`
 	DisableGitCommits    bool `yaml:"disable_git_commits,omitempty"`
 	DisableBuilds        bool `yaml:"disable_builds,omitempty"`
 	DisableIdle          bool `yaml:"disable_idle,omitempty"`
@@ -424,6 +655,28 @@ type TracingConfig struct {
 	DisableExec bool `yaml:"disable_exec,omitempty"`
 }
 
+// PRDescriptionConfig configures drafting a pull request description when a
+// run ends. The draft is written to pr-description.md in the run directory.
+type PRDescriptionConfig struct {
+	Enabled bool `yaml:"enabled,omitempty"`
+	// Grant is the LLM grant to draft with ("claude", "anthropic", "openai",
+	// or "codex"). It must be one of the run's grants. Empty picks the first
+	// such grant.
+	Grant string `yaml:"grant,omitempty"`
+	// Model overrides the default model for the grant's API.
+	Model string `yaml:"model,omitempty"`
+}
+
+// PRCommentConfig configures posting a run summary comment to the run's
+// pull request when the run ends. The comment is posted with the run's
+// github grant.
+type PRCommentConfig struct {
+	Enabled bool `yaml:"enabled,omitempty"`
+	// BundleURL is where the run's exported audit bundle is published, for
+	// the comment to link to. "{run}" expands to the run ID.
+	BundleURL string `yaml:"bundle_url,omitempty"`
+}
+
 // HooksConfig configures lifecycle hooks that run at different stages.
 type HooksConfig struct {
 	// PostBuild runs as the container user (moatuser) during image build,
@@ -440,6 +693,11 @@ type HooksConfig struct {
 	// container start, before the main command. Use for workspace-level
 	// setup that needs project files (e.g., "npm install").
 	PreRun string `yaml:"pre_run,omitempty"`
+
+	// SecretsRefresh runs as the container user (moatuser) in /workspace
+	// after moat secrets refresh writes rotated values to the secret files.
+	// Use it to make long-lived processes re-read them (e.g., send SIGHUP).
+	SecretsRefresh string `yaml:"secrets_refresh,omitempty"`
 }
 
 // ShouldSyncClaudeLogs returns true if Claude session logs should be synced.
@@ -527,9 +785,15 @@ func Load(dir string) (*Config, error) {
 		}
 	}
 
+	return Parse(data, filepath.Base(path))
+}
+
+// Parse parses and validates moat.yaml content read from somewhere other
+// than a workspace, such as a run template. name labels parse errors.
+func Parse(data []byte, name string) (*Config, error) {
 	var cfg Config
 	if err := yaml.Unmarshal(data, &cfg); err != nil {
-		return nil, fmt.Errorf("parsing %s: %w", filepath.Base(path), err)
+		return nil, fmt.Errorf("parsing %s: %w", name, err)
 	}
 
 	// Resolve bare/partial mcp[] entries against the well-known catalog before
@@ -538,15 +802,48 @@ func Load(dir string) (*Config, error) {
 		return nil, err
 	}
 
-	// Validate runtime field (only "docker" or "apple" allowed)
-	if cfg.Runtime != "" && cfg.Runtime != "docker" && cfg.Runtime != "apple" {
-		return nil, fmt.Errorf("invalid runtime %q: must be 'docker' or 'apple'", cfg.Runtime)
+	// Validate runtime field (only "docker", "apple", "podman", or "kubernetes" allowed)
+	switch cfg.Runtime {
+	case "", "docker", "apple", "podman", "kubernetes":
+	default:
+		return nil, fmt.Errorf("invalid runtime %q: must be 'docker', 'apple', 'podman', or 'kubernetes'", cfg.Runtime)
+	}
+
+	if cfg.SSH.MaxSignaturesPerMinute < 0 || cfg.SSH.MaxSignatures < 0 {
+		return nil, fmt.Errorf("ssh: max_signatures_per_minute and max_signatures must not be negative (omit them for no limit)")
+	}
+	if cfg.Docker.Filter && !slices.Contains(cfg.Dependencies, "docker:host") {
+		return nil, fmt.Errorf("docker.filter requires the docker:host dependency")
+	}
+	if cfg.Messaging.MaxMessages < 0 {
+		return nil, fmt.Errorf("messaging: max_messages must not be negative (omit it for the default of %d)", DefaultMaxMessages)
+	}
+	if g := cfg.PRDescription.Grant; g != "" {
+		switch g {
+		case "claude", "anthropic", "openai", "codex":
+		default:
+			return nil, fmt.Errorf("pr_description.grant %q is not an LLM grant; use claude, anthropic, openai, or codex", g)
+		}
+		if !slices.ContainsFunc(cfg.Grants, func(s string) bool { return strings.Split(s, ":")[0] == g }) {
+			return nil, fmt.Errorf("pr_description.grant %q is not in grants; add it to grants or remove pr_description.grant", g)
+		}
+	}
+	if u := cfg.PRComment.BundleURL; u != "" && !strings.HasPrefix(u, "https://") && !strings.HasPrefix(u, "http://") {
+		return nil, fmt.Errorf("pr_comment.bundle_url must be an http or https URL, got %q", u)
 	}
 
 	// Validate workspace mode
 	if err := cfg.Workspace.Validate(); err != nil {
 		return nil, err
 	}
+	if err := validateWorkspaces(cfg.Workspaces, cfg.Workspace.Mode); err != nil {
+		return nil, err
+	}
+
+	// Validate package-manager caches
+	if err := cfg.Caches.Validate(); err != nil {
+		return nil, err
+	}
 
 	// Validate container resource limits
 	if cfg.Container.Memory < 0 {
@@ -558,6 +855,11 @@ func Load(dir string) (*Config, error) {
 	if cfg.Container.CPUs < 0 {
 		return nil, fmt.Errorf("container.cpus must be non-negative, got %d", cfg.Container.CPUs)
 	}
+	switch cfg.Container.Priority {
+	case "", "high", "normal", "background":
+	default:
+		return nil, fmt.Errorf("container.priority must be high, normal, or background, got %q", cfg.Container.Priority)
+	}
 
 	// Validate ulimits
 	validUlimits := map[string]bool{
@@ -584,14 +886,36 @@ func Load(dir string) (*Config, error) {
 		}
 	}
 
+	if cfg.Container.Locale != "" && !localeRe.MatchString(cfg.Container.Locale) {
+		return nil, fmt.Errorf("container.locale: invalid locale %q (use language_TERRITORY.codeset, e.g. en_US.UTF-8)", cfg.Container.Locale)
+	}
+	if err := validateContainerClock(cfg.Container); err != nil {
+		return nil, err
+	}
+	if err := validateDisplay(&cfg); err != nil {
+		return nil, err
+	}
+	if err := validateIDE(&cfg); err != nil {
+		return nil, err
+	}
+	if err := validateServiceNetworks(&cfg); err != nil {
+		return nil, err
+	}
+	if err := validateDevices(cfg.Container.Devices); err != nil {
+		return nil, err
+	}
+	if err := CheckDeviceRuntimeSupport(cfg.Container.Devices, cfg.Runtime == "apple"); err != nil {
+		return nil, err
+	}
+
 	// Set default network policy if not specified
 	if cfg.Network.Policy == "" {
 		cfg.Network.Policy = "permissive"
 	}
 
 	// Validate network policy
-	if cfg.Network.Policy != "permissive" && cfg.Network.Policy != "strict" {
-		return nil, fmt.Errorf("invalid network policy %q: must be 'permissive' or 'strict'", cfg.Network.Policy)
+	if cfg.Network.Policy != "permissive" && cfg.Network.Policy != "strict" && cfg.Network.Policy != "record" {
+		return nil, fmt.Errorf("invalid network policy %q: must be 'permissive', 'strict', or 'record'", cfg.Network.Policy)
 	}
 
 	if len(cfg.Network.Allow) > 0 {
@@ -673,10 +997,93 @@ func Load(dir string) (*Config, error) {
 		}
 	}
 
+	if m := cfg.Network.Mirror; m != nil {
+		u, err := url.Parse(m.URL)
+		if err != nil || m.URL == "" {
+			return nil, fmt.Errorf("network.mirror.url: invalid URL %q (e.g., http://localhost:9000/ingest)", m.URL)
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return nil, fmt.Errorf("network.mirror.url: scheme must be http or https, got %q", u.Scheme)
+		}
+		if u.Host == "" {
+			return nil, fmt.Errorf("network.mirror.url: missing host in %q", m.URL)
+		}
+		for _, h := range m.Hosts {
+			if h == "" || strings.ContainsAny(h, "/: ") {
+				return nil, fmt.Errorf("network.mirror.hosts: invalid host %q (use a bare hostname like api.anthropic.com)", h)
+			}
+		}
+		switch m.Format {
+		case "", MirrorFormatReplay, MirrorFormatRecord:
+		default:
+			return nil, fmt.Errorf("network.mirror.format: must be %q or %q, got %q", MirrorFormatReplay, MirrorFormatRecord, m.Format)
+		}
+	}
+
+	for i, t := range cfg.Network.Transforms {
+		if t.Host == "" || strings.ContainsAny(t.Host, "/ ") {
+			return nil, fmt.Errorf("network.transforms[%d]: invalid host %q (use a hostname like api.example.com)", i, t.Host)
+		}
+		if t.Kind == "" {
+			return nil, fmt.Errorf("network.transforms[%d]: kind is required (e.g., request-header-set)", i)
+		}
+	}
+
+	for i, f := range cfg.Network.Faults {
+		if err := validateFault(f); err != nil {
+			return nil, fmt.Errorf("network.faults[%d]: %w", i, err)
+		}
+	}
+
+	if s := cfg.Network.Scrub; s != nil {
+		if !s.Cookies && len(s.Headers) == 0 {
+			return nil, fmt.Errorf("network.scrub: set cookies or headers")
+		}
+		for _, h := range s.Hosts {
+			if h == "" || strings.ContainsAny(h, "/: ") {
+				return nil, fmt.Errorf("network.scrub.hosts: invalid host %q (use a bare hostname like api.example.com)", h)
+			}
+		}
+		for _, name := range s.Headers {
+			if name == "" || strings.ContainsAny(name, ": ") {
+				return nil, fmt.Errorf("network.scrub.headers: invalid header name %q", name)
+			}
+		}
+	}
+
+	switch cfg.Network.Capture {
+	case "", NetworkCaptureMetadata, NetworkCaptureFull:
+	default:
+		return nil, fmt.Errorf("network.capture: invalid value %q (use %s or %s)", cfg.Network.Capture, NetworkCaptureMetadata, NetworkCaptureFull)
+	}
+
+	if u := cfg.Network.Uploads; u != nil {
+		if u.MaxMB < 0 {
+			return nil, fmt.Errorf("network.uploads.max_mb must be non-negative, got %d", u.MaxMB)
+		}
+		capped := u.MaxMB > 0
+		for h, mb := range u.Hosts {
+			if h == "" || strings.ContainsAny(h, "/: ") {
+				return nil, fmt.Errorf("network.uploads.hosts: invalid host %q (use a bare hostname like api.example.com)", h)
+			}
+			if mb < 0 {
+				return nil, fmt.Errorf("network.uploads.hosts.%s must be non-negative, got %d", h, mb)
+			}
+			capped = capped || mb > 0
+		}
+		if !capped {
+			return nil, fmt.Errorf("network.uploads: set max_mb or a host cap")
+		}
+	}
+
 	if cfg.Claude.BaseURL != "" && cfg.Claude.LLMGateway != nil {
 		return nil, fmt.Errorf("claude: base_url and llm-gateway are mutually exclusive — base_url routes to an external LLM proxy, llm-gateway routes to a local Keep sidecar")
 	}
 
+	if err := validateClaudeProvider(cfg.Claude); err != nil {
+		return nil, err
+	}
+
 	// Validate Codex MCP server specs
 	for name, spec := range cfg.Codex.MCP {
 		if err := validateMCPServerSpec("codex", name, spec); err != nil {
@@ -859,6 +1266,66 @@ func validateMCPServerSpec(section, name string, spec MCPServerSpec) error {
 	return nil
 }
 
+// cloudRegionRe matches a cloud region name such as us-east5 or europe-west1.
+var cloudRegionRe = regexp.MustCompile(`^[a-z][a-z0-9-]*[a-z0-9]$`)
+
+// validateClaudeProvider validates claude.provider and claude.region.
+func validateClaudeProvider(c ClaudeConfig) error {
+	switch c.Provider {
+	case "", "anthropic":
+		if c.Region != "" {
+			return fmt.Errorf("claude.region is only used with claude.provider: vertex")
+		}
+		return nil
+	case "bedrock":
+		if c.Region != "" {
+			return fmt.Errorf("claude.region is only used with claude.provider: vertex — Bedrock uses the aws grant's region (moat grant aws --region)")
+		}
+	case "vertex":
+		if c.Region != "" && !cloudRegionRe.MatchString(c.Region) {
+			return fmt.Errorf("claude.region: invalid region %q (e.g., us-east5 or global)", c.Region)
+		}
+	default:
+		return fmt.Errorf("claude.provider: unknown provider %q (valid: anthropic, bedrock, vertex)", c.Provider)
+	}
+	if c.BaseURL != "" || c.LLMGateway != nil {
+		return fmt.Errorf("claude.provider: %s cannot be combined with claude.base_url or claude.llm-gateway", c.Provider)
+	}
+	return nil
+}
+
+// validateFault validates a network.faults entry.
+func validateFault(f FaultConfig) error {
+	if f.Host == "" || strings.ContainsAny(f.Host, "/: ") {
+		return fmt.Errorf("invalid host %q (use a bare hostname like api.anthropic.com)", f.Host)
+	}
+	if f.Rate < 0 || f.Rate > 1 {
+		return fmt.Errorf("rate must be between 0 and 1, got %v", f.Rate)
+	}
+	if f.LatencyMS < 0 || f.RetryAfter < 0 || f.Truncate < 0 {
+		return fmt.Errorf("latency_ms, retry_after, and truncate must not be negative")
+	}
+	if f.Status != 0 && (f.Status < 400 || f.Status > 599) {
+		return fmt.Errorf("status must be an HTTP error status (400-599), got %d", f.Status)
+	}
+	if f.RetryAfter != 0 && f.Status == 0 {
+		return fmt.Errorf("retry_after requires status")
+	}
+	failures := 0
+	for _, set := range []bool{f.Status != 0, f.Reset, f.Truncate != 0} {
+		if set {
+			failures++
+		}
+	}
+	if failures > 1 {
+		return fmt.Errorf("set at most one of status, reset, and truncate")
+	}
+	if failures == 0 && f.LatencyMS == 0 {
+		return fmt.Errorf("set latency_ms, status, reset, or truncate")
+	}
+	return nil
+}
+
 // isHostLocalURL returns true if the URL points to a host-local address
 // (localhost, 127.0.0.1, or [::1]). These are MCP servers running on the
 // host machine that the container cannot reach directly.
@@ -359,6 +359,79 @@ runtime: docker
 	}
 }
 
+func TestLoadConfigAcceptsPodmanRuntime(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "moat.yaml"), []byte("name: myapp\nagent: test\nruntime: podman\n"), 0o644)
+
+	cfg, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load should accept runtime: podman, got error: %v", err)
+	}
+	if cfg.Runtime != "podman" {
+		t.Errorf("Runtime = %q, want %q", cfg.Runtime, "podman")
+	}
+}
+
+func TestLoadConfigPRDescription(t *testing.T) {
+	tests := []struct {
+		yaml    string
+		wantErr string
+	}{
+		{"grants: [claude, github]\npr_description:\n  enabled: true\n  grant: claude\n", ""},
+		{"grants: [\"codex:org\"]\npr_description:\n  grant: codex\n", ""},
+		{"grants: [github]\npr_description:\n  grant: github\n", "not an LLM grant"},
+		{"grants: [anthropic]\npr_description:\n  grant: openai\n", "not in grants"},
+	}
+	for _, tt := range tests {
+		dir := t.TempDir()
+		os.WriteFile(filepath.Join(dir, "moat.yaml"), []byte("name: myapp\nagent: test\n"+tt.yaml), 0o644)
+
+		cfg, err := Load(dir)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Load(%q) error = %v, want %q", tt.yaml, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Load(%q): %v", tt.yaml, err)
+			continue
+		}
+		if cfg.PRDescription.Grant == "" {
+			t.Errorf("Load(%q): PRDescription.Grant not parsed", tt.yaml)
+		}
+	}
+}
+
+func TestLoadConfigPRComment(t *testing.T) {
+	tests := []struct {
+		yaml    string
+		wantErr string
+	}{
+		{"pr_comment:\n  enabled: true\n  bundle_url: https://ci.example.com/artifacts/{run}.proof.json\n", ""},
+		{"pr_comment:\n  bundle_url: s3://bucket/{run}.proof.json\n", "must be an http or https URL"},
+	}
+	for _, tt := range tests {
+		dir := t.TempDir()
+		os.WriteFile(filepath.Join(dir, "moat.yaml"), []byte("name: myapp\nagent: test\n"+tt.yaml), 0o644)
+
+		cfg, err := Load(dir)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Load(%q) error = %v, want %q", tt.yaml, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Load(%q): %v", tt.yaml, err)
+			continue
+		}
+		if !cfg.PRComment.Enabled || cfg.PRComment.BundleURL == "" {
+			t.Errorf("Load(%q): PRComment = %+v", tt.yaml, cfg.PRComment)
+		}
+	}
+}
+
 func TestLoadConfigRejectsInvalidRuntime(t *testing.T) {
 	dir := t.TempDir()
 	configPath := filepath.Join(dir, "moat.yaml")
@@ -527,6 +600,23 @@ container:
 	}
 }
 
+func TestLoadConfigContainerPriority(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "moat.yaml"), []byte("agent: test\ncontainer:\n  priority: background\n"), 0o644)
+	cfg, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Container.Priority != "background" {
+		t.Errorf("Container.Priority = %q, want background", cfg.Container.Priority)
+	}
+
+	os.WriteFile(filepath.Join(dir, "moat.yaml"), []byte("agent: test\ncontainer:\n  priority: urgent\n"), 0o644)
+	if _, err := Load(dir); err == nil || !strings.Contains(err.Error(), "container.priority") {
+		t.Errorf("Load with priority urgent: err = %v, want a container.priority error", err)
+	}
+}
+
 func TestLoadConfigWithNetworkStrict(t *testing.T) {
 	dir := t.TempDir()
 	configPath := filepath.Join(dir, "moat.yaml")
@@ -644,6 +734,19 @@ network:
 	}
 }
 
+func TestLoadConfigRecordNetworkPolicy(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "moat.yaml"), []byte("agent: test\nnetwork:\n  policy: record\n"), 0o644)
+
+	cfg, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Network.Policy != "record" {
+		t.Errorf("Network.Policy = %q, want record", cfg.Network.Policy)
+	}
+}
+
 func TestNetworkRulesConfig(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -1707,6 +1810,7 @@ hooks:
   post_build: git config --global core.autocrlf input
   post_build_root: apt-get install -y figlet
   pre_run: npm install
+  secrets_refresh: pkill -HUP server
 `
 	os.WriteFile(configPath, []byte(content), 0o644)
 
@@ -1723,6 +1827,9 @@ hooks:
 	if cfg.Hooks.PreRun != "npm install" {
 		t.Errorf("Hooks.PreRun = %q, want %q", cfg.Hooks.PreRun, "npm install")
 	}
+	if cfg.Hooks.SecretsRefresh != "pkill -HUP server" {
+		t.Errorf("Hooks.SecretsRefresh = %q, want %q", cfg.Hooks.SecretsRefresh, "pkill -HUP server")
+	}
 }
 
 func TestLoadConfigWithHooksEmpty(t *testing.T) {
@@ -1852,6 +1959,236 @@ func TestLoadConfigWithClaudeBaseURL(t *testing.T) {
 	}
 }
 
+func TestLoadConfigWithClaudeProvider(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		wantErr string
+	}{
+		{name: "bedrock", yaml: "claude:\n  provider: bedrock\n"},
+		{name: "vertex default region", yaml: "claude:\n  provider: vertex\n"},
+		{name: "vertex region", yaml: "claude:\n  provider: vertex\n  region: us-east5\n"},
+		{name: "unknown provider", yaml: "claude:\n  provider: azure\n", wantErr: "unknown provider"},
+		{name: "bad region", yaml: "claude:\n  provider: vertex\n  region: US_EAST\n", wantErr: "invalid region"},
+		{name: "region with bedrock", yaml: "claude:\n  provider: bedrock\n  region: us-west-2\n", wantErr: "aws grant's region"},
+		{name: "region without provider", yaml: "claude:\n  region: us-east5\n", wantErr: "only used with claude.provider: vertex"},
+		{name: "with base_url", yaml: "claude:\n  provider: vertex\n  base_url: http://localhost:8080\n", wantErr: "cannot be combined"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFile(t, dir, "moat.yaml", tt.yaml)
+			_, err := Load(dir)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestLoadConfigWithNetworkMirror(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		wantErr string
+	}{
+		{name: "url only", yaml: "network:\n  mirror:\n    url: http://localhost:9000/ingest\n"},
+		{name: "with hosts", yaml: "network:\n  mirror:\n    url: https://eval.internal/v1\n    hosts: [api.anthropic.com]\n"},
+		{name: "missing url", yaml: "network:\n  mirror:\n    hosts: [api.openai.com]\n", wantErr: "network.mirror.url"},
+		{name: "bad scheme", yaml: "network:\n  mirror:\n    url: ftp://localhost\n", wantErr: "scheme must be http or https"},
+		{name: "host with path", yaml: "network:\n  mirror:\n    url: http://localhost:9000\n    hosts: [api.openai.com/v1]\n", wantErr: "network.mirror.hosts"},
+		{name: "record format", yaml: "network:\n  mirror:\n    url: http://localhost:9000/ingest\n    format: record\n"},
+		{name: "bad format", yaml: "network:\n  mirror:\n    url: http://localhost:9000\n    format: json\n", wantErr: "network.mirror.format"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFile(t, dir, "moat.yaml", tt.yaml)
+			cfg, err := Load(dir)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if cfg.Network.Mirror == nil || cfg.Network.Mirror.URL == "" {
+				t.Errorf("Network.Mirror = %+v, want URL set", cfg.Network.Mirror)
+			}
+		})
+	}
+}
+
+func TestLoadConfigWithNetworkScrub(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		wantErr string
+	}{
+		{name: "cookies", yaml: "network:\n  scrub:\n    cookies: true\n"},
+		{name: "allowlist with hosts", yaml: "network:\n  scrub:\n    headers: [x-request-id]\n    hosts: [auth.example.com]\n"},
+		{name: "nothing to scrub", yaml: "network:\n  scrub:\n    hosts: [auth.example.com]\n", wantErr: "set cookies or headers"},
+		{name: "host with port", yaml: "network:\n  scrub:\n    cookies: true\n    hosts: [auth.example.com:443]\n", wantErr: "network.scrub.hosts: invalid host"},
+		{name: "bad header", yaml: "network:\n  scrub:\n    headers: [\"x-a: b\"]\n", wantErr: "invalid header name"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFile(t, dir, "moat.yaml", tt.yaml)
+			cfg, err := Load(dir)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if cfg.Network.Scrub == nil {
+				t.Fatal("Network.Scrub = nil")
+			}
+		})
+	}
+}
+
+func TestLoadConfigWithNetworkFaults(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		wantErr string
+	}{
+		{name: "latency", yaml: "network:\n  faults:\n    - host: api.anthropic.com\n      latency_ms: 2000\n"},
+		{name: "status with rate", yaml: "network:\n  faults:\n    - host: api.openai.com\n      status: 429\n      retry_after: 5\n      rate: 0.25\n"},
+		{name: "latency and truncate", yaml: "network:\n  faults:\n    - host: api.openai.com\n      latency_ms: 100\n      truncate: 512\n"},
+		{name: "nothing to inject", yaml: "network:\n  faults:\n    - host: api.openai.com\n      rate: 0.5\n", wantErr: "set latency_ms, status, reset, or truncate"},
+		{name: "two failures", yaml: "network:\n  faults:\n    - host: api.openai.com\n      status: 503\n      reset: true\n", wantErr: "at most one of"},
+		{name: "success status", yaml: "network:\n  faults:\n    - host: api.openai.com\n      status: 200\n", wantErr: "HTTP error status"},
+		{name: "rate out of range", yaml: "network:\n  faults:\n    - host: api.openai.com\n      reset: true\n      rate: 2\n", wantErr: "rate must be between 0 and 1"},
+		{name: "retry_after without status", yaml: "network:\n  faults:\n    - host: api.openai.com\n      reset: true\n      retry_after: 5\n", wantErr: "retry_after requires status"},
+		{name: "host with port", yaml: "network:\n  faults:\n    - host: api.openai.com:443\n      reset: true\n", wantErr: "network.faults[0]: invalid host"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFile(t, dir, "moat.yaml", tt.yaml)
+			cfg, err := Load(dir)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(cfg.Network.Faults) != 1 {
+				t.Errorf("Network.Faults = %+v, want one entry", cfg.Network.Faults)
+			}
+		})
+	}
+}
+
+func TestLoadConfigWithSSHLimits(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "moat.yaml", "ssh:\n  max_signatures_per_minute: 30\n  max_signatures: 500\n")
+	cfg, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.SSH.MaxSignaturesPerMinute != 30 || cfg.SSH.MaxSignatures != 500 {
+		t.Errorf("SSH = %+v, want 30/minute and 500 total", cfg.SSH)
+	}
+
+	writeFile(t, dir, "moat.yaml", "ssh:\n  max_signatures: -1\n")
+	if _, err := Load(dir); err == nil || !strings.Contains(err.Error(), "ssh:") {
+		t.Errorf("Load with negative limit: err = %v, want ssh error", err)
+	}
+}
+
+func TestLoadConfigWithDockerFilter(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "moat.yaml", "dependencies:\n  - docker:host\ndocker:\n  filter: true\n")
+	cfg, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if !cfg.Docker.Filter {
+		t.Error("Docker.Filter = false, want true")
+	}
+
+	writeFile(t, dir, "moat.yaml", "dependencies:\n  - docker:dind\ndocker:\n  filter: true\n")
+	if _, err := Load(dir); err == nil || !strings.Contains(err.Error(), "docker:host") {
+		t.Errorf("Load with docker:dind: err = %v, want docker:host error", err)
+	}
+}
+
+func TestLoadConfigWithMessagingLimit(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "moat.yaml", "agent: test\n")
+	cfg, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if got := cfg.Messaging.Limit(); got != DefaultMaxMessages {
+		t.Errorf("default Limit() = %d, want %d", got, DefaultMaxMessages)
+	}
+
+	writeFile(t, dir, "moat.yaml", "messaging:\n  max_messages: 5\n")
+	cfg, err = Load(dir)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if got := cfg.Messaging.Limit(); got != 5 {
+		t.Errorf("Limit() = %d, want 5", got)
+	}
+
+	writeFile(t, dir, "moat.yaml", "messaging:\n  max_messages: -1\n")
+	if _, err := Load(dir); err == nil || !strings.Contains(err.Error(), "messaging:") {
+		t.Errorf("Load with negative limit: err = %v, want messaging error", err)
+	}
+}
+
+func TestLoadConfigWithNetworkTransforms(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "moat.yaml", `
+network:
+  transforms:
+    - host: api.example.com
+      kind: request-header-set
+      args:
+        name: X-Team
+        value: platform
+    - host: api.example.com
+      kind: response-header-remove
+      args:
+        name: Set-Cookie
+`)
+	cfg, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(cfg.Network.Transforms) != 2 {
+		t.Fatalf("Transforms = %d entries, want 2", len(cfg.Network.Transforms))
+	}
+	if got := cfg.Network.Transforms[0].Args["value"]; got != "platform" {
+		t.Errorf("Transforms[0].Args[value] = %q, want platform", got)
+	}
+
+	writeFile(t, dir, "moat.yaml", "network:\n  transforms:\n    - host: api.example.com\n")
+	if _, err := Load(dir); err == nil || !strings.Contains(err.Error(), "kind is required") {
+		t.Errorf("missing kind: err = %v, want kind is required", err)
+	}
+}
+
 // --- Additional host-local MCP tests ---
 
 func TestLoad_MCP_MixedHostAndRemote(t *testing.T) {
@@ -2699,3 +3036,54 @@ func TestLoadConfigPiBlockDefaultsEmpty(t *testing.T) {
 		t.Errorf("expected empty Pi config, got %+v", cfg.Pi)
 	}
 }
+
+func TestLoadConfigWithNetworkUploads(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		wantErr string
+	}{
+		{name: "global cap", yaml: "network:\n  uploads:\n    max_mb: 25\n"},
+		{name: "host caps", yaml: "network:\n  uploads:\n    max_mb: 25\n    hosts:\n      uploads.github.com: 500\n      \"*.googleapis.com\": 0\n"},
+		{name: "no cap", yaml: "network:\n  uploads:\n    hosts:\n      uploads.github.com: 0\n", wantErr: "set max_mb or a host cap"},
+		{name: "negative max", yaml: "network:\n  uploads:\n    max_mb: -1\n", wantErr: "max_mb must be non-negative"},
+		{name: "negative host", yaml: "network:\n  uploads:\n    max_mb: 25\n    hosts:\n      example.com: -5\n", wantErr: "must be non-negative"},
+		{name: "host with port", yaml: "network:\n  uploads:\n    hosts:\n      example.com:443: 5\n", wantErr: "network.uploads.hosts: invalid host"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFile(t, dir, "moat.yaml", tt.yaml)
+			cfg, err := Load(dir)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if cfg.Network.Uploads == nil || cfg.Network.Uploads.MaxMB != 25 {
+				t.Fatalf("Network.Uploads = %+v, want max_mb 25", cfg.Network.Uploads)
+			}
+		})
+	}
+}
+
+func TestLoadConfigWithNetworkCapture(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "moat.yaml", "network:\n  capture: full\n")
+	cfg, err := Load(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Network.Capture != NetworkCaptureFull {
+		t.Errorf("Network.Capture = %q, want %q", cfg.Network.Capture, NetworkCaptureFull)
+	}
+
+	writeFile(t, dir, "moat.yaml", "network:\n  capture: bodies\n")
+	if _, err := Load(dir); err == nil || !strings.Contains(err.Error(), "network.capture: invalid value") {
+		t.Errorf("err = %v, want network.capture error", err)
+	}
+}
@@ -0,0 +1,108 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// deviceAliases map the named device classes accepted in container.devices
+// to the host paths they pass through.
+var deviceAliases = map[string]string{
+	"audio": "/dev/snd",
+	"usb":   "/dev/bus/usb",
+}
+
+// DevicePolicy is the `devices:` block of the global config. Host devices
+// are denied by default: a moat.yaml can only pass through a device that
+// the host user has listed here, so a workspace cannot grant itself
+// hardware access.
+//
+// Example (~/.moat/config.yaml):
+//
+//	devices:
+//	  allow:
+//	    - audio
+//	    - /dev/ttyUSB0
+type DevicePolicy struct {
+	Allow []string `yaml:"allow,omitempty"`
+}
+
+// DevicePath returns the host path for a container.devices entry: the path
+// behind an alias ("audio", "usb"), or the entry itself when it is a clean
+// absolute path under /dev. ok is false for anything else. Entries carry no
+// container path or permissions, so runtime-specific device syntax such as
+// "host:container:rwm" cannot be passed through.
+func DevicePath(entry string) (string, bool) {
+	if p, ok := deviceAliases[entry]; ok {
+		return p, true
+	}
+	if !strings.HasPrefix(entry, "/dev/") || path.Clean(entry) != entry ||
+		strings.ContainsAny(entry, ":, \t\n") {
+		return "", false
+	}
+	return entry, true
+}
+
+// validateDevices checks container.devices entries.
+func validateDevices(devices []string) error {
+	seen := make(map[string]bool, len(devices))
+	for i, d := range devices {
+		p, ok := DevicePath(d)
+		if !ok {
+			return fmt.Errorf("container.devices[%d]: invalid device %q (use audio, usb, or an absolute path under /dev)", i, d)
+		}
+		if seen[p] {
+			return fmt.Errorf("container.devices[%d]: duplicate device %q", i, d)
+		}
+		seen[p] = true
+	}
+	return nil
+}
+
+// CheckDevicePolicy returns the host paths for the requested devices, or an
+// error naming every device the policy does not allow. A device is allowed
+// when its path, or a directory containing it, is in the allow list.
+func (p DevicePolicy) CheckDevicePolicy(requested []string) ([]string, error) {
+	var allowed []string
+	for _, a := range p.Allow {
+		if ap, ok := DevicePath(a); ok {
+			allowed = append(allowed, ap)
+		}
+	}
+
+	paths := make([]string, 0, len(requested))
+	var denied []string
+	for _, r := range requested {
+		rp, ok := DevicePath(r)
+		if !ok {
+			return nil, fmt.Errorf("container.devices: invalid device %q", r)
+		}
+		permitted := false
+		for _, ap := range allowed {
+			if rp == ap || strings.HasPrefix(rp, ap+"/") {
+				permitted = true
+				break
+			}
+		}
+		if !permitted {
+			denied = append(denied, r)
+			continue
+		}
+		paths = append(paths, rp)
+	}
+	if len(denied) > 0 {
+		return nil, fmt.Errorf("container.devices: %s not allowed by host policy\n\nDevice access is denied by default. To allow it, add to %s/config.yaml:\n\n  devices:\n    allow:\n      - %s",
+			strings.Join(denied, ", "), GlobalConfigDir(), strings.Join(denied, "\n      - "))
+	}
+	return paths, nil
+}
+
+// CheckDeviceRuntimeSupport rejects container.devices on the Apple container
+// runtime, which cannot pass host devices into its VMs.
+func CheckDeviceRuntimeSupport(devices []string, appleRuntime bool) error {
+	if appleRuntime && len(devices) > 0 {
+		return fmt.Errorf("container.devices is not supported on the Apple container runtime; use runtime: docker")
+	}
+	return nil
+}
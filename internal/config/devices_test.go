@@ -0,0 +1,81 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestDevicePath(t *testing.T) {
+	tests := []struct {
+		entry  string
+		want   string
+		wantOK bool
+	}{
+		{"audio", "/dev/snd", true},
+		{"usb", "/dev/bus/usb", true},
+		{"/dev/ttyUSB0", "/dev/ttyUSB0", true},
+		{"/dev/bus/usb/001/004", "/dev/bus/usb/001/004", true},
+		{"/dev/../etc/shadow", "", false},
+		{"/dev/sda:/dev/sda:rwm", "", false},
+		{"/dev/", "", false},
+		{"/etc/passwd", "", false},
+		{"dev/ttyUSB0", "", false},
+		{"gpu", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := DevicePath(tt.entry)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("DevicePath(%q) = %q, %v; want %q, %v", tt.entry, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestLoadConfigDevices(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "moat.yaml"), []byte("agent: claude-code\ncontainer:\n  devices: [audio, /dev/ttyUSB0]\n"), 0o644)
+	cfg, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if !slices.Equal(cfg.Container.Devices, []string{"audio", "/dev/ttyUSB0"}) {
+		t.Errorf("Devices = %v", cfg.Container.Devices)
+	}
+
+	for _, content := range []string{
+		"container:\n  devices: [\"/dev/sda:/dev/sda\"]\n",
+		"container:\n  devices: [audio, /dev/snd]\n",
+		"runtime: apple\ncontainer:\n  devices: [audio]\n",
+	} {
+		os.WriteFile(filepath.Join(dir, "moat.yaml"), []byte("agent: claude-code\n"+content), 0o644)
+		if _, err := Load(dir); err == nil || !strings.Contains(err.Error(), "container.devices") {
+			t.Errorf("Load(%q) error = %v, want container.devices error", content, err)
+		}
+	}
+}
+
+func TestCheckDevicePolicy(t *testing.T) {
+	// Deny by default.
+	if _, err := (DevicePolicy{}).CheckDevicePolicy([]string{"audio"}); err == nil {
+		t.Error("empty policy should deny audio")
+	}
+
+	policy := DevicePolicy{Allow: []string{"audio", "/dev/bus/usb", "not-a-device"}}
+	paths, err := policy.CheckDevicePolicy([]string{"audio", "/dev/bus/usb/001/004"})
+	if err != nil {
+		t.Fatalf("CheckDevicePolicy: %v", err)
+	}
+	if !slices.Equal(paths, []string{"/dev/snd", "/dev/bus/usb/001/004"}) {
+		t.Errorf("paths = %v", paths)
+	}
+
+	_, err = policy.CheckDevicePolicy([]string{"audio", "/dev/ttyUSB0", "/dev/bus/usbx"})
+	if err == nil {
+		t.Fatal("unlisted devices should be denied")
+	}
+	if !strings.Contains(err.Error(), "/dev/ttyUSB0, /dev/bus/usbx") || strings.Contains(err.Error(), "audio,") {
+		t.Errorf("error should name only the denied devices: %v", err)
+	}
+}
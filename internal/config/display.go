@@ -0,0 +1,69 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// DisplayEndpoint is the endpoint name under which a display's noVNC viewer
+// is published through the routing proxy (gui.<agent>.localhost).
+const DisplayEndpoint = "gui"
+
+// DisplayPort is the container port noVNC (websockify) listens on.
+const DisplayPort = 6080
+
+// DefaultDisplayResolution is the virtual screen size when
+// display.resolution is not set.
+const DefaultDisplayResolution = "1280x800x24"
+
+// displayResolutionRe matches WIDTHxHEIGHT with an optional xDEPTH.
+var displayResolutionRe = regexp.MustCompile(`^([1-9][0-9]{2,4})x([1-9][0-9]{2,4})(x(8|16|24))?$`)
+
+// DisplayConfig is the moat.yaml `display:` block.
+//
+// It starts a virtual X display (Xvfb) in the container for agents that
+// drive GUI applications, and serves it as a noVNC viewer on the "gui"
+// endpoint, so the session can be watched and controlled from a browser at
+// gui.<agent>.localhost. DISPLAY is set for the agent.
+//
+// Example:
+//
+//	display:
+//	  enabled: true
+//	  resolution: 1920x1080
+type DisplayConfig struct {
+	Enabled    bool   `yaml:"enabled,omitempty"`
+	Resolution string `yaml:"resolution,omitempty"`
+}
+
+// Screen returns the Xvfb screen geometry (WIDTHxHEIGHTxDEPTH), applying
+// the default resolution and a 24-bit depth when none is given.
+func (d DisplayConfig) Screen() string {
+	if d.Resolution == "" {
+		return DefaultDisplayResolution
+	}
+	if m := displayResolutionRe.FindStringSubmatch(d.Resolution); m != nil && m[3] == "" {
+		return d.Resolution + "x24"
+	}
+	return d.Resolution
+}
+
+// validateDisplay checks the display block and that its endpoint does not
+// collide with a user-defined port.
+func validateDisplay(cfg *Config) error {
+	if cfg.Display.Resolution != "" && !displayResolutionRe.MatchString(cfg.Display.Resolution) {
+		return fmt.Errorf("display.resolution: invalid resolution %q (use WIDTHxHEIGHT, e.g. 1920x1080)", cfg.Display.Resolution)
+	}
+	if !cfg.Display.Enabled {
+		return nil
+	}
+	if _, ok := cfg.Ports[DisplayEndpoint]; ok {
+		return fmt.Errorf("ports.%s: the name %q is reserved for the display viewer when display.enabled is true", DisplayEndpoint, DisplayEndpoint)
+	}
+	for name, port := range cfg.Ports {
+		if port == DisplayPort {
+			return fmt.Errorf("ports.%s: port %d is used by the display viewer when display.enabled is true", name, DisplayPort)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadConfigDisplay(t *testing.T) {
+	dir := t.TempDir()
+	content := `
+agent: claude-code
+ports:
+  web: 3000
+display:
+  enabled: true
+  resolution: 1920x1080
+`
+	os.WriteFile(filepath.Join(dir, "moat.yaml"), []byte(content), 0o644)
+
+	cfg, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if !cfg.Display.Enabled {
+		t.Error("Display.Enabled = false, want true")
+	}
+	if got := cfg.Display.Screen(); got != "1920x1080x24" {
+		t.Errorf("Screen() = %q, want 1920x1080x24", got)
+	}
+}
+
+func TestDisplayConfigScreen(t *testing.T) {
+	tests := []struct {
+		resolution string
+		want       string
+	}{
+		{"", DefaultDisplayResolution},
+		{"1024x768", "1024x768x24"},
+		{"1024x768x16", "1024x768x16"},
+	}
+	for _, tt := range tests {
+		if got := (DisplayConfig{Resolution: tt.resolution}).Screen(); got != tt.want {
+			t.Errorf("Screen(%q) = %q, want %q", tt.resolution, got, tt.want)
+		}
+	}
+}
+
+func TestLoadConfigDisplayInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{"bad resolution", "display:\n  enabled: true\n  resolution: big\n", "display.resolution"},
+		{"odd depth", "display:\n  enabled: true\n  resolution: 800x600x32\n", "display.resolution"},
+		{"reserved endpoint", "ports:\n  gui: 8080\ndisplay:\n  enabled: true\n", "ports.gui"},
+		{"reserved port", "ports:\n  vnc: 6080\ndisplay:\n  enabled: true\n", "ports.vnc"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			os.WriteFile(filepath.Join(dir, "moat.yaml"), []byte("agent: claude-code\n"+tt.content), 0o644)
+			_, err := Load(dir)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Load() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	// Without display enabled, "gui" is an ordinary port name.
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "moat.yaml"), []byte("agent: claude-code\nports:\n  gui: 8080\n"), 0o644)
+	if _, err := Load(dir); err != nil {
+		t.Errorf("gui port without display: %v", err)
+	}
+}
@@ -4,9 +4,11 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/majorcontext/moat/internal/ui"
 	"gopkg.in/yaml.v3"
@@ -21,6 +23,206 @@ type GlobalConfig struct {
 	Proxy  ProxyConfig  `yaml:"proxy"`
 	Debug  DebugConfig  `yaml:"debug"`
 	Mounts []MountEntry `yaml:"mounts,omitempty"`
+
+	// GrantBundles maps a bundle name to the grants it stands for. A bundle
+	// name may appear anywhere a grant can (moat.yaml grants:, --grant) and
+	// is expanded when the run is created.
+	GrantBundles map[string][]string `yaml:"grant_bundles,omitempty"`
+
+	Credentials CredentialsConfig `yaml:"credentials,omitempty"`
+
+	// Quotas caps each LLM provider's usage per day across every run on
+	// the machine, keyed by provider (anthropic or openai). The proxy
+	// daemon enforces them.
+	Quotas map[string]ProviderQuota `yaml:"quotas,omitempty"`
+
+	// Devices lists the host devices runs may request in container.devices.
+	Devices DevicePolicy `yaml:"devices,omitempty"`
+
+	// Sleep controls what the proxy daemon does when the host sleeps.
+	Sleep SleepConfig `yaml:"sleep,omitempty"`
+
+	// Retry controls retries of transient failures while a run is created.
+	Retry RetryConfig `yaml:"retry,omitempty"`
+
+	// Templates configures where moat run --template finds templates.
+	Templates TemplatesConfig `yaml:"templates,omitempty"`
+
+	// Concurrency caps how many runs execute at once. Runs beyond a limit
+	// wait in the proxy daemon's queue.
+	Concurrency ConcurrencyConfig `yaml:"concurrency,omitempty"`
+
+	// Kubernetes configures the kubernetes runtime, which runs agents as
+	// pods on a cluster.
+	Kubernetes KubernetesConfig `yaml:"kubernetes,omitempty"`
+
+	// Audit ships audit events off the host as they are recorded.
+	Audit AuditConfig `yaml:"audit,omitempty"`
+
+	// Notifications controls the proxy daemon's desktop notifications.
+	Notifications NotificationsConfig `yaml:"notifications,omitempty"`
+}
+
+// DefaultNotificationDelay is how long an approval request waits for an
+// answer before the proxy daemon shows a desktop notification.
+const DefaultNotificationDelay = 10 * time.Second
+
+// NotificationsConfig holds desktop notification settings. The daemon reads
+// them once, so changing them takes 'moat proxy restart'.
+type NotificationsConfig struct {
+	// Approvals notifies when a run's approval request has waited Delay
+	// without an answer. Default true; MOAT_NOTIFICATIONS=0 turns it off.
+	Approvals *bool `yaml:"approvals,omitempty"`
+	// Delay is how long to wait before notifying; 0 uses
+	// DefaultNotificationDelay.
+	Delay time.Duration `yaml:"delay,omitempty"`
+}
+
+// ApprovalsEnabled reports whether approval requests notify.
+func (n NotificationsConfig) ApprovalsEnabled() bool {
+	return n.Approvals == nil || *n.Approvals
+}
+
+// EffectiveDelay returns Delay, or DefaultNotificationDelay when unset.
+func (n NotificationsConfig) EffectiveDelay() time.Duration {
+	if n.Delay > 0 {
+		return n.Delay
+	}
+	return DefaultNotificationDelay
+}
+
+// AuditConfig names the collectors audit events are streamed to. Every
+// process that records audit events (the CLI and the proxy daemon) ships
+// them; the daemon reads this once, so changing it takes 'moat proxy
+// restart'.
+type AuditConfig struct {
+	Syslog  *AuditSyslogConfig  `yaml:"syslog,omitempty"`
+	Webhook *AuditWebhookConfig `yaml:"webhook,omitempty"`
+}
+
+// AuditSyslogConfig ships audit events to a syslog server.
+type AuditSyslogConfig struct {
+	// Address is udp://host[:port], tcp://host[:port], or tls://host[:port].
+	Address string `yaml:"address"`
+	// Tag is the syslog APP-NAME; default "moat".
+	Tag string `yaml:"tag,omitempty"`
+}
+
+// AuditWebhookConfig ships audit events to an HTTPS collector.
+type AuditWebhookConfig struct {
+	URL string `yaml:"url"`
+	// Headers are added to each request. ${VAR} in a value is replaced with
+	// the environment variable, so tokens need not be kept in the file.
+	Headers map[string]string `yaml:"headers,omitempty"`
+}
+
+// KubernetesConfig holds kubernetes runtime settings.
+type KubernetesConfig struct {
+	// Context is the kubectl context to use; empty uses the current one.
+	Context string `yaml:"context,omitempty"`
+	// Namespace is where run pods are created; empty uses "default".
+	Namespace string `yaml:"namespace,omitempty"`
+	// Workspace is how the workspace reaches the pod: "sync" (default)
+	// copies it in through an init container; "pvc" mounts Claim.
+	Workspace string `yaml:"workspace,omitempty"`
+	// Claim is the PersistentVolumeClaim mounted at /workspace when
+	// Workspace is "pvc".
+	Claim string `yaml:"claim,omitempty"`
+	// ProxyHost is the address pods reach the proxy daemon at.
+	ProxyHost string `yaml:"proxy_host,omitempty"`
+	// ProxyPort is a NodePort forwarding to the proxy daemon. When set, a
+	// sidecar in each pod forwards the proxy port to ProxyHost, or to the
+	// pod's node when ProxyHost is empty, on this port.
+	ProxyPort int `yaml:"proxy_port,omitempty"`
+	// Registry is where images moat builds are pushed for the cluster to
+	// pull, e.g. "registry.example.com/team".
+	Registry string `yaml:"registry,omitempty"`
+	// SidecarImage is the image of the proxy sidecar; it must provide socat.
+	// Required with ProxyPort.
+	SidecarImage string `yaml:"sidecar_image,omitempty"`
+}
+
+// ConcurrencyConfig holds concurrent run limits. Zero fields are not
+// enforced.
+type ConcurrencyConfig struct {
+	// MaxRuns caps the runs executing at once across the machine.
+	MaxRuns int `yaml:"max_runs,omitempty" json:"max_runs,omitempty"`
+	// MaxRunsPerWorkspace caps the runs executing at once in one workspace.
+	MaxRunsPerWorkspace int `yaml:"max_runs_per_workspace,omitempty" json:"max_runs_per_workspace,omitempty"`
+}
+
+// Limited reports whether any concurrency limit is set.
+func (c ConcurrencyConfig) Limited() bool {
+	return c.MaxRuns > 0 || c.MaxRunsPerWorkspace > 0
+}
+
+// TemplatesConfig holds run template settings.
+type TemplatesConfig struct {
+	// Sources are directories or git repository URLs holding templates,
+	// searched in order after <GlobalConfigDir>/templates.
+	Sources []string `yaml:"sources,omitempty"`
+}
+
+// Retry defaults. CI runs fail on the first error so flaky infrastructure
+// shows up in the build instead of as slow starts.
+const (
+	DefaultRetryAttempts = 3
+	MaxRetryAttempts     = 10
+)
+
+// RetryConfig holds retry settings for run creation.
+type RetryConfig struct {
+	// Attempts is how many times an image pull, network creation, or
+	// sidecar or service start is tried before the run fails. 0 uses the
+	// default: DefaultRetryAttempts, or 1 (no retries) when CI is set.
+	Attempts int `yaml:"attempts,omitempty"`
+}
+
+// EffectiveAttempts returns the number of attempts to make, applying the
+// default when Attempts is unset.
+func (r RetryConfig) EffectiveAttempts() int {
+	if r.Attempts > 0 {
+		return r.Attempts
+	}
+	if os.Getenv("CI") != "" {
+		return 1
+	}
+	return DefaultRetryAttempts
+}
+
+// SleepConfig holds host sleep settings.
+type SleepConfig struct {
+	// PauseContainers pauses run containers before the host suspends and
+	// unpauses them on wake. Needs systemd-logind (Linux); on macOS the
+	// container VM is frozen with the host regardless.
+	PauseContainers bool `yaml:"pause_containers,omitempty"`
+}
+
+// ProviderQuota is a daily usage cap for one LLM provider. Zero fields are
+// not enforced.
+type ProviderQuota struct {
+	// DailyTokens caps input, output, and cache tokens combined.
+	DailyTokens int64 `yaml:"daily_tokens,omitempty" json:"daily_tokens,omitempty"`
+	// DailyCost caps the USD cost at list prices. Usage of models without a
+	// known price counts toward DailyTokens only.
+	DailyCost float64 `yaml:"daily_cost,omitempty" json:"daily_cost,omitempty"`
+}
+
+// QuotaProviders are the providers a quota can be set for: those whose
+// responses the proxy meters.
+var QuotaProviders = []string{"anthropic", "openai"}
+
+// CredentialsConfig selects where the credential store encryption key is kept.
+type CredentialsConfig struct {
+	// KeyProvider is keychain (default), file, age, aws-kms, or gcp-kms.
+	// Overridden by MOAT_KEY_PROVIDER.
+	KeyProvider string `yaml:"key_provider,omitempty"`
+	// AgeIdentity is the age identity file for the age key provider.
+	// Overridden by MOAT_AGE_IDENTITY.
+	AgeIdentity string `yaml:"age_identity,omitempty"`
+	// KMSKey is the AWS or Cloud KMS key for the KMS key providers.
+	// Overridden by MOAT_KMS_KEY.
+	KMSKey string `yaml:"kms_key,omitempty"`
 }
 
 // DebugConfig holds debug logging settings.
@@ -95,16 +297,134 @@ func LoadGlobal() (*GlobalConfig, error) {
 	}
 	cfg.Mounts = validMounts
 
+	if err := validateGrantBundles(cfg.GrantBundles); err != nil {
+		return nil, err
+	}
+	if err := validateQuotas(cfg.Quotas); err != nil {
+		return nil, err
+	}
+	if cfg.Concurrency.MaxRuns < 0 || cfg.Concurrency.MaxRunsPerWorkspace < 0 {
+		return nil, fmt.Errorf("concurrency: max_runs and max_runs_per_workspace must not be negative")
+	}
+	if err := validateKubernetes(cfg.Kubernetes); err != nil {
+		return nil, err
+	}
+	if err := validateAudit(cfg.Audit); err != nil {
+		return nil, err
+	}
+
 	// Apply environment overrides
 	if portStr := os.Getenv("MOAT_PROXY_PORT"); portStr != "" {
 		if port, err := strconv.Atoi(portStr); err == nil {
 			cfg.Proxy.Port = port
 		}
 	}
+	if v := os.Getenv("MOAT_RETRY_ATTEMPTS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			cfg.Retry.Attempts = n
+		}
+	}
+	if cfg.Retry.Attempts < 0 || cfg.Retry.Attempts > MaxRetryAttempts {
+		return nil, fmt.Errorf("retry.attempts must be at most %d and not negative, got %d", MaxRetryAttempts, cfg.Retry.Attempts)
+	}
+	if v := os.Getenv("MOAT_KEY_PROVIDER"); v != "" {
+		cfg.Credentials.KeyProvider = v
+	}
+	if v := os.Getenv("MOAT_AGE_IDENTITY"); v != "" {
+		cfg.Credentials.AgeIdentity = v
+	}
+	if v := os.Getenv("MOAT_KMS_KEY"); v != "" {
+		cfg.Credentials.KMSKey = v
+	}
+	if v := os.Getenv("MOAT_NOTIFICATIONS"); v != "" {
+		if enabled, err := strconv.ParseBool(v); err == nil {
+			cfg.Notifications.Approvals = &enabled
+		}
+	}
+	if cfg.Notifications.Delay < 0 {
+		return nil, fmt.Errorf("notifications.delay must not be negative")
+	}
+	if strings.HasPrefix(cfg.Credentials.AgeIdentity, "~/") && homeDir != "" {
+		cfg.Credentials.AgeIdentity = filepath.Join(homeDir, cfg.Credentials.AgeIdentity[2:])
+	}
 
 	return cfg, nil
 }
 
+// validateKubernetes checks the kubernetes runtime settings. They are only
+// used when the kubernetes runtime is selected, but a typo is reported
+// whatever the runtime.
+func validateKubernetes(k KubernetesConfig) error {
+	switch k.Workspace {
+	case "", "sync":
+		if k.Claim != "" {
+			return fmt.Errorf("kubernetes.claim requires kubernetes.workspace: pvc")
+		}
+	case "pvc":
+		if k.Claim == "" {
+			return fmt.Errorf("kubernetes.workspace: pvc requires kubernetes.claim")
+		}
+	default:
+		return fmt.Errorf("kubernetes.workspace must be 'sync' or 'pvc', got %q", k.Workspace)
+	}
+	if k.ProxyPort < 0 || k.ProxyPort > 65535 {
+		return fmt.Errorf("kubernetes.proxy_port must be a port number, got %d", k.ProxyPort)
+	}
+	return nil
+}
+
+// validateAudit checks that each configured collector has an address. The
+// addresses themselves are checked when shipping starts.
+func validateAudit(a AuditConfig) error {
+	if a.Syslog != nil && a.Syslog.Address == "" {
+		return fmt.Errorf("audit.syslog.address is required")
+	}
+	if a.Webhook != nil && a.Webhook.URL == "" {
+		return fmt.Errorf("audit.webhook.url is required")
+	}
+	return nil
+}
+
+// validateGrantBundles checks bundle definitions. Bundles may not be empty
+// or reference other bundles; nesting would make the expanded grant set hard
+// to read off the config file.
+func validateGrantBundles(bundles map[string][]string) error {
+	for name, grants := range bundles {
+		if name == "" || strings.ContainsAny(name, ": ") {
+			return fmt.Errorf("grant bundle %q: name must be non-empty and contain no spaces or colons", name)
+		}
+		if len(grants) == 0 {
+			return fmt.Errorf("grant bundle %q: must list at least one grant", name)
+		}
+		for _, g := range grants {
+			if g == "" {
+				return fmt.Errorf("grant bundle %q: contains an empty grant", name)
+			}
+			if _, nested := bundles[g]; nested {
+				return fmt.Errorf("grant bundle %q: %q is itself a bundle; bundles cannot be nested", name, g)
+			}
+		}
+	}
+	return nil
+}
+
+// validateQuotas checks that each quota names a metered provider and sets a
+// positive cap.
+func validateQuotas(quotas map[string]ProviderQuota) error {
+	for name, q := range quotas {
+		if !slices.Contains(QuotaProviders, name) {
+			return fmt.Errorf("quota %q: unknown provider (valid: %s)", name, strings.Join(QuotaProviders, ", "))
+		}
+		if q.DailyTokens < 0 || q.DailyCost < 0 {
+			return fmt.Errorf("quota %q: daily_tokens and daily_cost must not be negative", name)
+		}
+		if q.DailyTokens == 0 && q.DailyCost == 0 {
+			return fmt.Errorf("quota %q: set daily_tokens, daily_cost, or both", name)
+		}
+	}
+	return nil
+}
+
 // GlobalConfigDir returns the path to the moat configuration directory.
 //
 // By default this is ~/.moat, but the MOAT_HOME environment variable may
@@ -5,6 +5,7 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestLoadGlobalConfig(t *testing.T) {
@@ -197,6 +198,162 @@ mounts:
 	}
 }
 
+func TestLoadGlobal_GrantBundles(t *testing.T) {
+	tmpHome := t.TempDir()
+	t.Setenv("HOME", tmpHome)
+	t.Setenv("MOAT_HOME", "")
+
+	moatDir := filepath.Join(tmpHome, ".moat")
+	os.MkdirAll(moatDir, 0o755)
+
+	content := `
+grant_bundles:
+  web-dev: [github, npm, vercel]
+`
+	os.WriteFile(filepath.Join(moatDir, "config.yaml"), []byte(content), 0o644)
+
+	cfg, err := LoadGlobal()
+	if err != nil {
+		t.Fatalf("LoadGlobal: %v", err)
+	}
+	got := strings.Join(cfg.GrantBundles["web-dev"], ",")
+	if got != "github,npm,vercel" {
+		t.Errorf("GrantBundles[web-dev] = %q, want github,npm,vercel", got)
+	}
+}
+
+func TestLoadGlobal_GrantBundlesRejected(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{"empty", "grant_bundles:\n  web-dev: []\n", "at least one grant"},
+		{"nested", "grant_bundles:\n  web: [github]\n  web-dev: [web, npm]\n", "cannot be nested"},
+		{"colon in name", "grant_bundles:\n  \"web:dev\": [github]\n", "no spaces or colons"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpHome := t.TempDir()
+			t.Setenv("HOME", tmpHome)
+			t.Setenv("MOAT_HOME", "")
+
+			moatDir := filepath.Join(tmpHome, ".moat")
+			os.MkdirAll(moatDir, 0o755)
+			os.WriteFile(filepath.Join(moatDir, "config.yaml"), []byte(tt.content), 0o644)
+
+			_, err := LoadGlobal()
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want substring %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadGlobal_Quotas(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{"valid", "quotas:\n  anthropic:\n    daily_tokens: 5000000\n    daily_cost: 50\n", ""},
+		{"unknown provider", "quotas:\n  gemini:\n    daily_tokens: 1000\n", "unknown provider"},
+		{"negative", "quotas:\n  openai:\n    daily_cost: -1\n", "must not be negative"},
+		{"no cap", "quotas:\n  openai: {}\n", "set daily_tokens"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpHome := t.TempDir()
+			t.Setenv("HOME", tmpHome)
+			t.Setenv("MOAT_HOME", "")
+
+			moatDir := filepath.Join(tmpHome, ".moat")
+			os.MkdirAll(moatDir, 0o755)
+			os.WriteFile(filepath.Join(moatDir, "config.yaml"), []byte(tt.content), 0o644)
+
+			cfg, err := LoadGlobal()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("LoadGlobal: %v", err)
+				}
+				if q := cfg.Quotas["anthropic"]; q.DailyTokens != 5000000 || q.DailyCost != 50 {
+					t.Errorf("Quotas[anthropic] = %+v", q)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want substring %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadGlobal_Concurrency(t *testing.T) {
+	tmpHome := t.TempDir()
+	t.Setenv("HOME", tmpHome)
+	t.Setenv("MOAT_HOME", "")
+	moatDir := filepath.Join(tmpHome, ".moat")
+	os.MkdirAll(moatDir, 0o755)
+	path := filepath.Join(moatDir, "config.yaml")
+
+	os.WriteFile(path, []byte("concurrency:\n  max_runs: 4\n  max_runs_per_workspace: 2\n"), 0o644)
+	cfg, err := LoadGlobal()
+	if err != nil {
+		t.Fatalf("LoadGlobal: %v", err)
+	}
+	if cfg.Concurrency.MaxRuns != 4 || cfg.Concurrency.MaxRunsPerWorkspace != 2 || !cfg.Concurrency.Limited() {
+		t.Errorf("Concurrency = %+v", cfg.Concurrency)
+	}
+
+	os.WriteFile(path, []byte("concurrency:\n  max_runs: -1\n"), 0o644)
+	if _, err := LoadGlobal(); err == nil || !strings.Contains(err.Error(), "must not be negative") {
+		t.Errorf("LoadGlobal(negative) error = %v", err)
+	}
+}
+
+func TestLoadGlobal_Credentials(t *testing.T) {
+	tmpHome := t.TempDir()
+	t.Setenv("HOME", tmpHome)
+	t.Setenv("MOAT_HOME", "")
+	t.Setenv("MOAT_KEY_PROVIDER", "")
+	t.Setenv("MOAT_AGE_IDENTITY", "")
+	t.Setenv("MOAT_KMS_KEY", "")
+
+	moatDir := filepath.Join(tmpHome, ".moat")
+	os.MkdirAll(moatDir, 0o755)
+
+	content := `
+credentials:
+  key_provider: age
+  age_identity: ~/.config/moat/age.txt
+`
+	os.WriteFile(filepath.Join(moatDir, "config.yaml"), []byte(content), 0o644)
+
+	cfg, err := LoadGlobal()
+	if err != nil {
+		t.Fatalf("LoadGlobal: %v", err)
+	}
+	if cfg.Credentials.KeyProvider != "age" {
+		t.Errorf("KeyProvider = %q, want age", cfg.Credentials.KeyProvider)
+	}
+	if want := filepath.Join(tmpHome, ".config/moat/age.txt"); cfg.Credentials.AgeIdentity != want {
+		t.Errorf("AgeIdentity = %q, want %q", cfg.Credentials.AgeIdentity, want)
+	}
+
+	t.Setenv("MOAT_KEY_PROVIDER", "aws-kms")
+	t.Setenv("MOAT_KMS_KEY", "alias/moat")
+	cfg, err = LoadGlobal()
+	if err != nil {
+		t.Fatalf("LoadGlobal: %v", err)
+	}
+	if cfg.Credentials.KeyProvider != "aws-kms" || cfg.Credentials.KMSKey != "alias/moat" {
+		t.Errorf("Credentials = %+v, want env overrides applied", cfg.Credentials)
+	}
+}
+
 func TestLoadGlobal_MountsTildeExpansion(t *testing.T) {
 	tmpHome := t.TempDir()
 	t.Setenv("HOME", tmpHome)
@@ -283,3 +440,151 @@ func TestLoadGlobalMalformedReturnsDefaults(t *testing.T) {
 		t.Errorf("Debug.RetentionDays = %d, want default %d", cfg.Debug.RetentionDays, def.Debug.RetentionDays)
 	}
 }
+
+func TestLoadGlobal_Retry(t *testing.T) {
+	tmpHome := t.TempDir()
+	t.Setenv("HOME", tmpHome)
+	t.Setenv("MOAT_HOME", "")
+	t.Setenv("MOAT_RETRY_ATTEMPTS", "")
+
+	t.Setenv("CI", "")
+	cfg, err := LoadGlobal()
+	if err != nil {
+		t.Fatalf("LoadGlobal: %v", err)
+	}
+	if got := cfg.Retry.EffectiveAttempts(); got != DefaultRetryAttempts {
+		t.Errorf("EffectiveAttempts() = %d, want default %d", got, DefaultRetryAttempts)
+	}
+
+	t.Setenv("CI", "true")
+	if got := cfg.Retry.EffectiveAttempts(); got != 1 {
+		t.Errorf("EffectiveAttempts() in CI = %d, want 1", got)
+	}
+
+	t.Setenv("MOAT_RETRY_ATTEMPTS", "5")
+	cfg, err = LoadGlobal()
+	if err != nil {
+		t.Fatalf("LoadGlobal: %v", err)
+	}
+	if got := cfg.Retry.EffectiveAttempts(); got != 5 {
+		t.Errorf("EffectiveAttempts() = %d, want 5 from env even in CI", got)
+	}
+
+	t.Setenv("MOAT_RETRY_ATTEMPTS", "")
+	moatDir := filepath.Join(tmpHome, ".moat")
+	os.MkdirAll(moatDir, 0o755)
+	os.WriteFile(filepath.Join(moatDir, "config.yaml"), []byte("retry:\n  attempts: 50\n"), 0o644)
+	if _, err := LoadGlobal(); err == nil || !strings.Contains(err.Error(), "retry.attempts") {
+		t.Errorf("error = %v, want retry.attempts range error", err)
+	}
+}
+
+func TestLoadGlobal_Kubernetes(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{"valid", "kubernetes:\n  namespace: agents\n  workspace: pvc\n  claim: repos\n  proxy_port: 30080\n", ""},
+		{"bad workspace", "kubernetes:\n  workspace: nfs\n", "must be 'sync' or 'pvc'"},
+		{"pvc without claim", "kubernetes:\n  workspace: pvc\n", "requires kubernetes.claim"},
+		{"claim without pvc", "kubernetes:\n  claim: repos\n", "requires kubernetes.workspace: pvc"},
+		{"bad port", "kubernetes:\n  proxy_port: 70000\n", "must be a port number"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpHome := t.TempDir()
+			t.Setenv("HOME", tmpHome)
+			t.Setenv("MOAT_HOME", "")
+
+			moatDir := filepath.Join(tmpHome, ".moat")
+			os.MkdirAll(moatDir, 0o755)
+			os.WriteFile(filepath.Join(moatDir, "config.yaml"), []byte(tt.content), 0o644)
+
+			cfg, err := LoadGlobal()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("LoadGlobal: %v", err)
+				}
+				if k := cfg.Kubernetes; k.Namespace != "agents" || k.Claim != "repos" || k.ProxyPort != 30080 {
+					t.Errorf("Kubernetes = %+v", k)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want substring %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadGlobal_Audit(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{"valid", "audit:\n  syslog:\n    address: tls://logs.example.com\n  webhook:\n    url: https://collector.example.com/moat\n    headers:\n      Authorization: Bearer ${AUDIT_TOKEN}\n", ""},
+		{"syslog without address", "audit:\n  syslog:\n    tag: moat-ci\n", "audit.syslog.address is required"},
+		{"webhook without url", "audit:\n  webhook:\n    headers:\n      X-Key: abc\n", "audit.webhook.url is required"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpHome := t.TempDir()
+			t.Setenv("HOME", tmpHome)
+			t.Setenv("MOAT_HOME", "")
+
+			moatDir := filepath.Join(tmpHome, ".moat")
+			os.MkdirAll(moatDir, 0o755)
+			os.WriteFile(filepath.Join(moatDir, "config.yaml"), []byte(tt.content), 0o644)
+
+			cfg, err := LoadGlobal()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("LoadGlobal: %v", err)
+				}
+				a := cfg.Audit
+				if a.Syslog == nil || a.Syslog.Address != "tls://logs.example.com" || a.Webhook == nil || a.Webhook.Headers["Authorization"] != "Bearer ${AUDIT_TOKEN}" {
+					t.Errorf("Audit = %+v", a)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want substring %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadGlobal_Notifications(t *testing.T) {
+	tmpHome := t.TempDir()
+	t.Setenv("HOME", tmpHome)
+	t.Setenv("MOAT_HOME", "")
+	t.Setenv("MOAT_NOTIFICATIONS", "")
+
+	cfg, err := LoadGlobal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cfg.Notifications.ApprovalsEnabled() || cfg.Notifications.EffectiveDelay() != DefaultNotificationDelay {
+		t.Errorf("default notifications = %+v", cfg.Notifications)
+	}
+
+	moatDir := filepath.Join(tmpHome, ".moat")
+	os.MkdirAll(moatDir, 0o755)
+	os.WriteFile(filepath.Join(moatDir, "config.yaml"), []byte("notifications:\n  delay: 30s\n"), 0o644)
+	if cfg, err = LoadGlobal(); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Notifications.EffectiveDelay() != 30*time.Second {
+		t.Errorf("delay = %v, want 30s", cfg.Notifications.EffectiveDelay())
+	}
+
+	t.Setenv("MOAT_NOTIFICATIONS", "0")
+	if cfg, err = LoadGlobal(); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Notifications.ApprovalsEnabled() {
+		t.Error("MOAT_NOTIFICATIONS=0 did not disable approval notifications")
+	}
+}
@@ -0,0 +1,36 @@
+package config
+
+import "fmt"
+
+// IDEPort is the container port sshd listens on when ide.enabled is set.
+// The host publishes it on a loopback port for `moat ide`.
+const IDEPort = 2222
+
+// IDEConfig is the moat.yaml `ide:` block.
+//
+// It runs an SSH server in the container so a developer can attach VS Code
+// (Remote - SSH) or a JetBrains Gateway to the sandbox the agent is working
+// in. The server accepts only a key minted for the run, logs in as the
+// agent's user, and is reachable only from the host's loopback interface.
+//
+// Example:
+//
+//	ide:
+//	  enabled: true
+type IDEConfig struct {
+	Enabled bool `yaml:"enabled,omitempty"`
+}
+
+// validateIDE checks that the SSH server's port does not collide with a
+// user-defined port.
+func validateIDE(cfg *Config) error {
+	if !cfg.IDE.Enabled {
+		return nil
+	}
+	for name, port := range cfg.Ports {
+		if port == IDEPort {
+			return fmt.Errorf("ports.%s: port %d is used by the IDE SSH server when ide.enabled is true", name, IDEPort)
+		}
+	}
+	return nil
+}
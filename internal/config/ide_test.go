@@ -0,0 +1,27 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadConfigIDE(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "moat.yaml"), []byte("agent: claude-code\nports:\n  web: 3000\nide:\n  enabled: true\n"), 0o644)
+
+	cfg, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if !cfg.IDE.Enabled {
+		t.Error("IDE.Enabled = false, want true")
+	}
+
+	// The SSH server's port cannot also be a user endpoint.
+	os.WriteFile(filepath.Join(dir, "moat.yaml"), []byte("agent: claude-code\nports:\n  ssh: 2222\nide:\n  enabled: true\n"), 0o644)
+	if _, err := Load(dir); err == nil || !strings.Contains(err.Error(), "ports.ssh") {
+		t.Errorf("Load() error = %v, want containing %q", err, "ports.ssh")
+	}
+}
@@ -0,0 +1,84 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+)
+
+// ServiceNetworkConfig is the `network:` block of a service under
+// `services:`. It limits what the agent can reach on the service and
+// whether the service can reach anything outside the run.
+//
+// By default every service shares the run network with the agent: the
+// agent may connect to any of its ports, and it may connect out to the
+// internet.
+//
+// Example:
+//
+//	services:
+//	  postgres:
+//	    network:
+//	      ports: [5432]
+//	      egress: false
+//	  redis:
+//	    network:
+//	      agent: false
+type ServiceNetworkConfig struct {
+	// Agent sets whether the agent container may connect to the service at
+	// all. Default true.
+	Agent *bool `yaml:"agent,omitempty"`
+
+	// Ports limits the agent to these TCP ports on the service. Empty
+	// allows every port.
+	Ports []int `yaml:"ports,omitempty"`
+
+	// Egress sets whether the service may connect outside the run, e.g. to
+	// the internet. Default true. A service without egress can still reach,
+	// and be reached by, the agent and the run's other services.
+	Egress *bool `yaml:"egress,omitempty"`
+}
+
+// AgentAccess reports whether the agent may connect to the service, and on
+// which TCP ports; nil ports with ok true means every port.
+func (s ServiceSpec) AgentAccess() (ports []int, ok bool) {
+	if s.Network == nil {
+		return nil, true
+	}
+	if s.Network.Agent != nil && !*s.Network.Agent {
+		return nil, false
+	}
+	return s.Network.Ports, true
+}
+
+// Egress reports whether the service may connect outside the run (default:
+// true).
+func (s ServiceSpec) Egress() bool {
+	if s.Network == nil || s.Network.Egress == nil {
+		return true
+	}
+	return *s.Network.Egress
+}
+
+// validateServiceNetworks checks each service's network block.
+func validateServiceNetworks(cfg *Config) error {
+	names := make([]string, 0, len(cfg.Services))
+	for name := range cfg.Services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		n := cfg.Services[name].Network
+		if n == nil {
+			continue
+		}
+		if n.Agent != nil && !*n.Agent && len(n.Ports) > 0 {
+			return fmt.Errorf("services.%s.network: ports cannot be set with agent: false", name)
+		}
+		for _, port := range n.Ports {
+			if port < 1 || port > 65535 {
+				return fmt.Errorf("services.%s.network.ports: invalid port %d (must be 1-65535)", name, port)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadConfigServiceNetwork(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "moat.yaml"), []byte(`agent: claude-code
+dependencies:
+  - postgres@17
+  - redis@7
+  - ollama
+services:
+  postgres:
+    network:
+      ports: [5432]
+      egress: false
+  redis:
+    network:
+      agent: false
+  ollama:
+    models: [qwen2.5-coder:1.5b]
+`), 0o644)
+
+	cfg, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	pg := cfg.Services["postgres"]
+	if ports, ok := pg.AgentAccess(); !ok || len(ports) != 1 || ports[0] != 5432 {
+		t.Errorf("postgres AgentAccess() = %v, %v, want [5432], true", ports, ok)
+	}
+	if pg.Egress() {
+		t.Error("postgres Egress() = true, want false")
+	}
+	if _, ok := cfg.Services["redis"].AgentAccess(); ok {
+		t.Error("redis AgentAccess() ok = true, want false")
+	}
+	if !cfg.Services["redis"].Egress() {
+		t.Error("redis Egress() = false, want the default true")
+	}
+	ollama := cfg.Services["ollama"]
+	if ports, ok := ollama.AgentAccess(); !ok || ports != nil || !ollama.Egress() {
+		t.Errorf("ollama without a network block = %v, %v, egress %v, want unrestricted", ports, ok, ollama.Egress())
+	}
+	if _, isExtra := ollama.Extra["network"]; isExtra {
+		t.Error("network parsed as an extra key")
+	}
+
+	for yaml, want := range map[string]string{
+		"agent: false\n      ports: [5432]": "services.postgres.network: ports cannot be set with agent: false",
+		"ports: [0]":                        "services.postgres.network.ports: invalid port 0",
+	} {
+		os.WriteFile(filepath.Join(dir, "moat.yaml"), []byte("agent: claude-code\ndependencies:\n  - postgres@17\nservices:\n  postgres:\n    network:\n      "+yaml+"\n"), 0o644)
+		if _, err := Load(dir); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Load(%q) error = %v, want containing %q", yaml, err, want)
+		}
+	}
+}
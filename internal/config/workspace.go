@@ -1,6 +1,11 @@
 package config
 
-import "fmt"
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
 
 // WorkspaceMode selects how the host working tree is presented to the container.
 type WorkspaceMode string
@@ -25,16 +30,184 @@ func IsVolumeMode(mode string) bool {
 type WorkspaceConfig struct {
 	// Mode is "bind" (default) or "volume". Empty means bind.
 	Mode WorkspaceMode `yaml:"mode,omitempty"`
+
+	// The fields below apply when moat creates a git worktree for a run
+	// (`moat wt`, --worktree).
+
+	// Submodules initializes submodules in new worktrees. Nil means yes when
+	// the branch has a .gitmodules file.
+	Submodules *bool `yaml:"submodules,omitempty"`
+	// LFS pulls Git LFS objects into new worktrees. Nil means yes when the
+	// branch tracks files with LFS and git-lfs is installed.
+	LFS *bool `yaml:"lfs,omitempty"`
+	// Filter is a partial-clone filter, e.g. "blob:none", used when cloning
+	// submodules into new worktrees.
+	Filter string `yaml:"filter,omitempty"`
+	// Paths limits new worktrees to these directories (relative to the
+	// repository root) with a cone-mode sparse checkout. Empty checks out
+	// everything.
+	Paths []string `yaml:"paths,omitempty"`
+
+	// BranchPrefix names the branch the agent commits on when /workspace is
+	// checked out on a protected branch: <prefix><run-name>. Nil means
+	// "agent/"; empty leaves the checkout on the protected branch.
+	BranchPrefix *string `yaml:"branch_prefix,omitempty"`
+	// ProtectedBranches are branch name globs (e.g. "release/*") that the
+	// agent may not commit or push to. Nil means DefaultProtectedBranches;
+	// an empty list turns protection off.
+	ProtectedBranches []string `yaml:"protected_branches,omitempty"`
+	// CommitTrailers controls the trailers added to commits made in the
+	// container.
+	CommitTrailers CommitTrailersConfig `yaml:"commit_trailers,omitempty"`
+}
+
+// CommitTrailersConfig is the workspace.commit_trailers block.
+type CommitTrailersConfig struct {
+	// CoAuthor is the "Name <email>" credited in a Co-Authored-By trailer.
+	// Nil means the agent's own identity, when moat knows one; empty
+	// disables the trailer.
+	CoAuthor *string `yaml:"co_author,omitempty"`
+	// RunID adds a Moat-Run-ID trailer. Nil means true.
+	RunID *bool `yaml:"run_id,omitempty"`
+}
+
+// trailerIdentity matches a "Name <email>" trailer value.
+var trailerIdentity = regexp.MustCompile(`^[^<>\n]+ <[^<>\s]+>$`)
+
+// DefaultProtectedBranches are protected when workspace.protected_branches
+// is unset.
+var DefaultProtectedBranches = []string{"main", "master", "release/*"}
+
+// DefaultBranchPrefix is used when workspace.branch_prefix is unset.
+const DefaultBranchPrefix = "agent/"
+
+// AgentBranchPrefix returns the agent branch prefix, applying the default.
+func (w WorkspaceConfig) AgentBranchPrefix() string {
+	if w.BranchPrefix == nil {
+		return DefaultBranchPrefix
+	}
+	return *w.BranchPrefix
 }
 
-// Validate rejects any mode other than "", "bind", or "volume".
+// Protected returns the protected branch globs, applying the default.
+func (w WorkspaceConfig) Protected() []string {
+	if w.ProtectedBranches == nil {
+		return DefaultProtectedBranches
+	}
+	return w.ProtectedBranches
+}
+
+// partialCloneFilter matches the git --filter specs moat accepts.
+var partialCloneFilter = regexp.MustCompile(`^(blob:none|tree:0|blob:limit=[0-9]+[kmg]?)$`)
+
+// Validate rejects any mode other than "", "bind", or "volume", filters git
+// does not understand, and branch settings git would reject.
 func (w WorkspaceConfig) Validate() error {
 	switch w.Mode {
 	case "", WorkspaceModeBind, WorkspaceModeVolume:
-		return nil
 	default:
 		return fmt.Errorf("workspace.mode %q is invalid (must be 'bind' or 'volume')", w.Mode)
 	}
+	if w.Filter != "" && !partialCloneFilter.MatchString(w.Filter) {
+		return fmt.Errorf("workspace.filter %q is invalid (use blob:none, tree:0, or blob:limit=<size>)", w.Filter)
+	}
+	for _, p := range w.Paths {
+		clean := path.Clean(p)
+		if p == "" || path.IsAbs(p) || clean == "." || clean == ".." || strings.HasPrefix(clean, "../") {
+			return fmt.Errorf("workspace.paths entry %q is invalid (use a directory relative to the repository root, e.g. services/api)", p)
+		}
+	}
+	if w.BranchPrefix != nil && *w.BranchPrefix != "" && !validBranchPart(*w.BranchPrefix) {
+		return fmt.Errorf("workspace.branch_prefix %q is not a valid branch name prefix", *w.BranchPrefix)
+	}
+	if c := w.CommitTrailers.CoAuthor; c != nil && *c != "" && !trailerIdentity.MatchString(*c) {
+		return fmt.Errorf("workspace.commit_trailers.co_author %q is invalid (use \"Name <email>\")", *c)
+	}
+	for _, b := range w.ProtectedBranches {
+		if _, err := path.Match(b, ""); err != nil || b == "" || strings.ContainsAny(b, " \t\n") {
+			return fmt.Errorf("workspace.protected_branches entry %q is invalid (use a branch name or glob, e.g. release/*)", b)
+		}
+	}
+	return nil
+}
+
+// validBranchPart reports whether s can start a git branch name. It checks
+// the common check-ref-format rules, not all of them.
+func validBranchPart(s string) bool {
+	if strings.HasPrefix(s, "-") || strings.HasPrefix(s, "/") || strings.Contains(s, "..") || strings.Contains(s, "//") {
+		return false
+	}
+	return !strings.ContainsAny(s, " \t\n~^:?*[\\")
+}
+
+// WorkspaceRepo is an entry of the moat.yaml `workspaces:` list: a host git
+// repository mounted at /workspace/<target>, for a workspace whose root is a
+// plain directory holding several repositories.
+type WorkspaceRepo struct {
+	// Path is the repository on the host, relative to the directory with
+	// moat.yaml or absolute.
+	Path string `yaml:"path"`
+	// Target is the directory under /workspace. Empty means the base name
+	// of Path.
+	Target string `yaml:"target,omitempty"`
+	// Worktree runs the agent on this branch in a moat-managed worktree of
+	// the repository instead of the repository's own checkout.
+	Worktree string `yaml:"worktree,omitempty"`
+	// ReadOnly mounts the repository read-only.
+	ReadOnly bool `yaml:"readonly,omitempty"`
+	// Git sets the identity for commits in this repository. Unset fields
+	// fall back to the repository's identity on the host.
+	Git GitIdentity `yaml:"git,omitempty"`
+}
+
+// GitIdentity is a git user.name and user.email.
+type GitIdentity struct {
+	Name  string `yaml:"name,omitempty"`
+	Email string `yaml:"email,omitempty"`
+}
+
+// ContainerTarget returns the directory under /workspace the repository is
+// mounted at.
+func (r WorkspaceRepo) ContainerTarget() string {
+	target := r.Target
+	if target == "" {
+		target = path.Base(strings.TrimRight(strings.ReplaceAll(r.Path, "\\", "/"), "/"))
+	}
+	return path.Join("/workspace", target)
+}
+
+// validateWorkspaces checks the workspaces: list.
+func validateWorkspaces(repos []WorkspaceRepo, mode WorkspaceMode) error {
+	if len(repos) > 0 && mode == WorkspaceModeVolume {
+		return fmt.Errorf("workspaces: cannot be combined with workspace.mode: volume")
+	}
+	seen := make(map[string]bool)
+	for i, r := range repos {
+		if r.Path == "" {
+			return fmt.Errorf("workspaces[%d]: path is required", i)
+		}
+		if r.Target != "" {
+			clean := path.Clean(r.Target)
+			if path.IsAbs(r.Target) || clean == "." || clean == ".." || strings.HasPrefix(clean, "../") {
+				return fmt.Errorf("workspaces[%d].target %q is invalid (use a directory relative to /workspace, e.g. api)", i, r.Target)
+			}
+		}
+		target := r.ContainerTarget()
+		if !strings.HasPrefix(target, "/workspace/") {
+			return fmt.Errorf("workspaces[%d]: cannot derive a target from path %q; set target", i, r.Path)
+		}
+		if seen[target] {
+			return fmt.Errorf("workspaces[%d]: %s is already used by another workspace; set a different target", i, target)
+		}
+		seen[target] = true
+		if r.Worktree != "" && !validBranchPart(r.Worktree) {
+			return fmt.Errorf("workspaces[%d].worktree %q is not a valid branch name", i, r.Worktree)
+		}
+		if strings.ContainsAny(r.Git.Name+r.Git.Email, "\n\x1f") {
+			return fmt.Errorf("workspaces[%d].git: name and email must be a single line", i)
+		}
+	}
+	return nil
 }
 
 // ResolveWorkspaceMode applies precedence: CLI override > yaml > default(bind).
@@ -1,8 +1,11 @@
 package config
 
 import (
+	"slices"
 	"strings"
 	"testing"
+
+	"gopkg.in/yaml.v3"
 )
 
 func TestWorkspaceModeValidate(t *testing.T) {
@@ -28,6 +31,90 @@ func TestWorkspaceModeValidate(t *testing.T) {
 	}
 }
 
+func TestWorkspaceFilterValidate(t *testing.T) {
+	for _, f := range []string{"", "blob:none", "tree:0", "blob:limit=1m", "blob:limit=500"} {
+		if err := (WorkspaceConfig{Filter: f}).Validate(); err != nil {
+			t.Errorf("Validate(filter %q) = %v, want nil", f, err)
+		}
+	}
+	for _, f := range []string{"none", "blob:limit=", "blob:none; rm -rf /"} {
+		if err := (WorkspaceConfig{Filter: f}).Validate(); err == nil || !strings.Contains(err.Error(), "workspace.filter") {
+			t.Errorf("Validate(filter %q) = %v, want workspace.filter error", f, err)
+		}
+	}
+}
+
+func TestWorkspacePathsValidate(t *testing.T) {
+	if err := (WorkspaceConfig{Paths: []string{"services/api", "libs/common/"}}).Validate(); err != nil {
+		t.Errorf("Validate(valid paths) = %v", err)
+	}
+	for _, p := range []string{"", "/etc", ".", "..", "../sibling", "a/../../b"} {
+		if err := (WorkspaceConfig{Paths: []string{p}}).Validate(); err == nil || !strings.Contains(err.Error(), "workspace.paths") {
+			t.Errorf("Validate(path %q) = %v, want workspace.paths error", p, err)
+		}
+	}
+}
+
+func TestWorkspaceBranchValidate(t *testing.T) {
+	for _, prefix := range []string{"", "agent/", "bots/claude-"} {
+		if err := (WorkspaceConfig{BranchPrefix: &prefix}).Validate(); err != nil {
+			t.Errorf("Validate(branch_prefix %q) = %v", prefix, err)
+		}
+	}
+	for _, prefix := range []string{"-x", "/agent", "a..b", "has space", "a:b"} {
+		if err := (WorkspaceConfig{BranchPrefix: &prefix}).Validate(); err == nil || !strings.Contains(err.Error(), "workspace.branch_prefix") {
+			t.Errorf("Validate(branch_prefix %q) = %v, want workspace.branch_prefix error", prefix, err)
+		}
+	}
+	if err := (WorkspaceConfig{ProtectedBranches: []string{"main", "release/*", "v[0-9]*"}}).Validate(); err != nil {
+		t.Errorf("Validate(valid protected_branches) = %v", err)
+	}
+	for _, b := range []string{"", "release/[", "two words"} {
+		if err := (WorkspaceConfig{ProtectedBranches: []string{b}}).Validate(); err == nil || !strings.Contains(err.Error(), "workspace.protected_branches") {
+			t.Errorf("Validate(protected_branches %q) = %v, want workspace.protected_branches error", b, err)
+		}
+	}
+}
+
+func TestWorkspaceCommitTrailersValidate(t *testing.T) {
+	for _, c := range []string{"", "Claude <noreply@anthropic.com>", "Build Bot <bot@example.com>"} {
+		if err := (WorkspaceConfig{CommitTrailers: CommitTrailersConfig{CoAuthor: &c}}).Validate(); err != nil {
+			t.Errorf("Validate(co_author %q) = %v", c, err)
+		}
+	}
+	for _, c := range []string{"Claude", "<a@b>", "A <a@b>\nSigned-off-by: x <x@y>"} {
+		if err := (WorkspaceConfig{CommitTrailers: CommitTrailersConfig{CoAuthor: &c}}).Validate(); err == nil || !strings.Contains(err.Error(), "co_author") {
+			t.Errorf("Validate(co_author %q) = %v, want co_author error", c, err)
+		}
+	}
+}
+
+func TestWorkspaceBranchDefaults(t *testing.T) {
+	var unset WorkspaceConfig
+	if err := yaml.Unmarshal([]byte("mode: bind\n"), &unset); err != nil {
+		t.Fatal(err)
+	}
+	if got := unset.AgentBranchPrefix(); got != "agent/" {
+		t.Errorf("AgentBranchPrefix() = %q, want agent/", got)
+	}
+	if got := unset.Protected(); !slices.Equal(got, []string{"main", "master", "release/*"}) {
+		t.Errorf("Protected() = %v, want defaults", got)
+	}
+
+	// Explicitly empty values turn the features off rather than falling
+	// back to the defaults.
+	var off WorkspaceConfig
+	if err := yaml.Unmarshal([]byte("branch_prefix: \"\"\nprotected_branches: []\n"), &off); err != nil {
+		t.Fatal(err)
+	}
+	if got := off.AgentBranchPrefix(); got != "" {
+		t.Errorf("AgentBranchPrefix() = %q, want empty", got)
+	}
+	if got := off.Protected(); len(got) != 0 {
+		t.Errorf("Protected() = %v, want none", got)
+	}
+}
+
 func TestResolveWorkspaceMode(t *testing.T) {
 	cases := []struct {
 		yaml     WorkspaceMode
@@ -81,3 +168,61 @@ func TestIsVolumeMode(t *testing.T) {
 		}
 	}
 }
+
+func TestParseWorkspaces(t *testing.T) {
+	cfg, err := Parse([]byte(`
+agent: claude
+workspaces:
+  - path: api
+    worktree: feature/search
+    git:
+      email: me@work.example
+  - path: ../shared/web/
+  - path: /src/docs
+    target: reference/docs
+    readonly: true
+`), "moat.yaml")
+	if err != nil {
+		t.Fatalf("Parse() = %v", err)
+	}
+	var targets []string
+	for _, r := range cfg.Workspaces {
+		targets = append(targets, r.ContainerTarget())
+	}
+	want := []string{"/workspace/api", "/workspace/web", "/workspace/reference/docs"}
+	if !slices.Equal(targets, want) {
+		t.Errorf("targets = %v, want %v", targets, want)
+	}
+	if cfg.Workspaces[0].Worktree != "feature/search" || cfg.Workspaces[0].Git.Email != "me@work.example" {
+		t.Errorf("workspaces[0] = %+v", cfg.Workspaces[0])
+	}
+}
+
+func TestValidateWorkspaces(t *testing.T) {
+	tests := []struct {
+		name  string
+		repos []WorkspaceRepo
+		mode  WorkspaceMode
+		want  string
+	}{
+		{"missing path", []WorkspaceRepo{{Target: "api"}}, "", "path is required"},
+		{"absolute target", []WorkspaceRepo{{Path: "api", Target: "/api"}}, "", "target"},
+		{"escaping target", []WorkspaceRepo{{Path: "api", Target: "../api"}}, "", "target"},
+		{"no derivable target", []WorkspaceRepo{{Path: "."}}, "", "set target"},
+		{"duplicate target", []WorkspaceRepo{{Path: "a/api"}, {Path: "b/api"}}, "", "already used"},
+		{"bad branch", []WorkspaceRepo{{Path: "api", Worktree: "a..b"}}, "", "worktree"},
+		{"multiline identity", []WorkspaceRepo{{Path: "api", Git: GitIdentity{Name: "a\nb"}}}, "", "single line"},
+		{"volume mode", []WorkspaceRepo{{Path: "api"}}, WorkspaceModeVolume, "volume"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateWorkspaces(tt.repos, tt.mode)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("validateWorkspaces() = %v, want error containing %q", err, tt.want)
+			}
+		})
+	}
+	if err := validateWorkspaces([]WorkspaceRepo{{Path: "a/api"}, {Path: "b/api", Target: "b-api"}}, ""); err != nil {
+		t.Errorf("validateWorkspaces(valid) = %v", err)
+	}
+}
@@ -168,6 +168,15 @@ func (r *AppleRuntime) Ping(ctx context.Context) error {
 	return nil
 }
 
+// Version returns the version of the container CLI.
+func (r *AppleRuntime) Version(ctx context.Context) (string, error) {
+	out, err := exec.CommandContext(ctx, r.containerBin, "--version").Output()
+	if err != nil {
+		return "", fmt.Errorf("getting apple container version: %w", err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
 // CreateContainer creates a new Apple container without starting it.
 // The container can later be started with StartContainer (non-interactive)
 // or StartAttached (interactive with TTY).
@@ -661,6 +670,22 @@ func (r *AppleRuntime) SetupFirewall(ctx context.Context, containerID string, pr
 	return nil
 }
 
+// RestrictServiceAccess installs iptables rules limiting the container's
+// connections to the run's service containers.
+func (r *AppleRuntime) RestrictServiceAccess(ctx context.Context, containerID string, rules []ServiceAccess) error {
+	script, err := serviceAccessScript(rules, true)
+	if err != nil {
+		return err
+	}
+	cmd := exec.CommandContext(ctx, r.containerBin, "exec", "--user", "root", containerID, "sh", "-c", script)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("service access setup failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
 // Exec runs a command inside a running container.
 func (r *AppleRuntime) Exec(ctx context.Context, containerID string, cmd []string, stdin []byte, stdout, stderr io.Writer) error {
 	var args []string
@@ -1324,6 +1349,66 @@ func (r *AppleRuntime) ContainerState(ctx context.Context, containerID string) (
 	return info[0].state(), nil
 }
 
+// UserNamespaceMode reports UsernsNone. Each Apple container runs in its own
+// lightweight VM, so container users are already separate from host users.
+func (r *AppleRuntime) UserNamespaceMode(ctx context.Context) (string, error) {
+	return UsernsNone, nil
+}
+
+// ContainerOOMKilled always reports false: Apple's container inspect output
+// does not record whether the OOM killer ended the process.
+func (r *AppleRuntime) ContainerOOMKilled(ctx context.Context, containerID string) (bool, error) {
+	return false, nil
+}
+
+// UpdatePriority returns ErrPriorityUnsupported: each Apple container is a
+// separate VM, which the CLI cannot weight against other VMs.
+func (r *AppleRuntime) UpdatePriority(ctx context.Context, containerID string, p Priority) error {
+	return ErrPriorityUnsupported
+}
+
+// ContainerStats samples a container's resource usage with
+// `container stats --no-stream --format json`, available from Apple container
+// 0.6. Older CLIs without the command return ErrStatsUnsupported.
+func (r *AppleRuntime) ContainerStats(ctx context.Context, containerID string) (Stats, error) {
+	cmd := exec.CommandContext(ctx, r.containerBin, "stats", "--no-stream", "--format", "json", containerID)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if strings.Contains(stderr.String(), "Unexpected argument") {
+			return Stats{}, ErrStatsUnsupported
+		}
+		return Stats{}, fmt.Errorf("reading stats for container %s: %s: %w", containerID, strings.TrimSpace(stderr.String()), err)
+	}
+	return parseAppleStats(stdout.Bytes())
+}
+
+// parseAppleStats parses the JSON array printed by `container stats`.
+func parseAppleStats(data []byte) (Stats, error) {
+	var entries []struct {
+		MemoryUsageBytes uint64 `json:"memoryUsageBytes"`
+		MemoryLimitBytes uint64 `json:"memoryLimitBytes"`
+		CPUUsageUsec     uint64 `json:"cpuUsageUsec"`
+		NetworkRxBytes   uint64 `json:"networkRxBytes"`
+		NetworkTxBytes   uint64 `json:"networkTxBytes"`
+	}
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return Stats{}, fmt.Errorf("parsing container stats: %w", err)
+	}
+	if len(entries) == 0 {
+		return Stats{}, fmt.Errorf("container stats returned no entries")
+	}
+	e := entries[0]
+	return Stats{
+		CPUNanos:         e.CPUUsageUsec * 1000,
+		MemoryBytes:      e.MemoryUsageBytes,
+		MemoryLimitBytes: e.MemoryLimitBytes,
+		NetRxBytes:       e.NetworkRxBytes,
+		NetTxBytes:       e.NetworkTxBytes,
+	}, nil
+}
+
 // ResizeTTY resizes the container's TTY to the given dimensions.
 // For Apple containers, this resizes the PTY master created during StartAttached.
 func (r *AppleRuntime) ResizeTTY(ctx context.Context, containerID string, height, width uint) error {
@@ -88,6 +88,12 @@ func (m *appleNetworkManager) CreateNetwork(ctx context.Context, name string) (s
 	return name, nil
 }
 
+// CreateInternalNetwork is not supported: Apple container networks always
+// route to the outside.
+func (m *appleNetworkManager) CreateInternalNetwork(ctx context.Context, name string) (string, error) {
+	return "", fmt.Errorf("creating internal network %s: not supported by Apple containers", name)
+}
+
 // RemoveNetwork removes an Apple container network by name.
 // Best-effort: does not fail if the network doesn't exist.
 //
@@ -187,6 +193,12 @@ func (m *appleNetworkManager) NetworkGateway(ctx context.Context, networkID stri
 	return inspectAppleNetworkGateway(ctx, m.containerBin, networkID)
 }
 
+// ConnectContainer is not supported: the Apple container CLI attaches
+// networks only when a container is created.
+func (m *appleNetworkManager) ConnectContainer(ctx context.Context, networkID, containerID string, aliases []string) error {
+	return fmt.Errorf("connecting a container to network %s: not supported by Apple containers", networkID)
+}
+
 // inspectAppleNetworkGateway runs `container network inspect` and extracts the
 // IPv4 gateway address from the JSON output. Returns empty string on failure.
 // Used by both probeDefaultGateway (init-time default network) and
@@ -5,8 +5,10 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"net"
 	"os/exec"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -85,7 +87,16 @@ func (m *appleServiceManager) StartService(ctx context.Context, cfg ServiceConfi
 	}
 	log.Debug("resolved service container IP", "service", cfg.Name, "ip", host)
 
-	return buildServiceInfo(containerID, cfg, host), nil
+	// The host reaches Apple containers at their own IP, so published ports
+	// need no host binding.
+	info := buildServiceInfo(containerID, cfg, host)
+	if len(cfg.Publish) > 0 {
+		info.Published = make(map[int]string, len(cfg.Publish))
+		for _, port := range cfg.Publish {
+			info.Published[port] = net.JoinHostPort(host, strconv.Itoa(port))
+		}
+	}
+	return info, nil
 }
 
 // CheckReady runs the readiness command inside the service container.
@@ -0,0 +1,402 @@
+// Package conformance is a test suite that every container.Runtime
+// implementation must pass.
+//
+// The suite checks the behavior the rest of moat relies on, independent of
+// the backend: container lifecycle and the state strings the run manager
+// maps, exec output and exit codes, logs, port bindings, bind mounts, and
+// named volumes. A new runtime is wired in with a single test:
+//
+//	func TestConformance(t *testing.T) {
+//		rt, err := newMyRuntime()
+//		if err != nil {
+//			t.Skipf("runtime not available: %v", err)
+//		}
+//		defer rt.Close()
+//		conformance.Run(t, rt, conformance.Options{})
+//	}
+//
+// Run creates real containers, so runtime test entry points belong behind the
+// e2e build tag with the other tests that need a container runtime.
+package conformance
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/majorcontext/moat/internal/container"
+	"github.com/majorcontext/moat/internal/id"
+)
+
+// DefaultImage is the image the suite runs when Options.Image is empty. It
+// needs a POSIX shell, cat, sleep, and a netcat that can listen.
+const DefaultImage = "alpine:3.20"
+
+// Timeout bounds each check, including image pulls on first use.
+const Timeout = 3 * time.Minute
+
+// States the run manager accepts from Runtime.ContainerState. See
+// loadPersistedRuns in internal/run.
+var (
+	createdStates = []string{"created", "stopped"}
+	exitedStates  = []string{"exited", "dead", "stopped"}
+)
+
+// Options adjusts the suite for a runtime's documented limitations.
+type Options struct {
+	// Image overrides DefaultImage.
+	Image string
+
+	// NoVolumes skips the named-volume checks, for runtimes that only
+	// support bind mounts.
+	NoVolumes bool
+
+	// NoPortBindings skips the port binding checks, for runtimes that
+	// cannot publish container ports on the host.
+	NoPortBindings bool
+}
+
+// Run runs the conformance suite against rt as subtests of t.
+func Run(t *testing.T, rt container.Runtime, opts Options) {
+	if opts.Image == "" {
+		opts.Image = DefaultImage
+	}
+	s := &suite{rt: rt, opts: opts}
+
+	t.Run("Ping", s.testPing)
+	t.Run("Lifecycle", s.testLifecycle)
+	t.Run("Exec", s.testExec)
+	t.Run("ExitCodeAndLogs", s.testExitCodeAndLogs)
+	t.Run("FollowLogs", s.testFollowLogs)
+	t.Run("EnvAndWorkingDir", s.testEnvAndWorkingDir)
+	t.Run("BindMounts", s.testBindMounts)
+	t.Run("PortBindings", func(t *testing.T) {
+		if opts.NoPortBindings {
+			t.Skip("runtime does not support port bindings")
+		}
+		s.testPortBindings(t)
+	})
+	t.Run("Volumes", func(t *testing.T) {
+		if opts.NoVolumes {
+			t.Skip("runtime does not support named volumes")
+		}
+		s.testVolumes(t)
+	})
+}
+
+type suite struct {
+	rt   container.Runtime
+	opts Options
+}
+
+// idleCmd keeps a container running until it is stopped. The trap makes the
+// shell exit on SIGTERM, which PID 1 otherwise ignores.
+var idleCmd = []string{"sh", "-c", `trap "exit 0" TERM; while :; do sleep 1; done`}
+
+func testContext(t *testing.T) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), Timeout)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+// create creates a container named like a moat run and removes it when the
+// test ends.
+func (s *suite) create(ctx context.Context, t *testing.T, cfg container.Config) string {
+	t.Helper()
+	cfg.Name = id.Generate("run")
+	if cfg.Image == "" {
+		cfg.Image = s.opts.Image
+	}
+	cid, err := s.rt.CreateContainer(ctx, cfg)
+	if err != nil {
+		t.Fatalf("CreateContainer: %v", err)
+	}
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		_ = s.rt.StopContainer(ctx, cid)
+		_ = s.rt.RemoveContainer(ctx, cid)
+	})
+	return cid
+}
+
+// start creates and starts a container.
+func (s *suite) start(ctx context.Context, t *testing.T, cfg container.Config) string {
+	t.Helper()
+	cid := s.create(ctx, t, cfg)
+	if err := s.rt.StartContainer(ctx, cid); err != nil {
+		t.Fatalf("StartContainer: %v", err)
+	}
+	return cid
+}
+
+// runToExit starts a container, waits for it to exit, and returns its exit
+// code and logs.
+func (s *suite) runToExit(ctx context.Context, t *testing.T, cfg container.Config) (int64, string) {
+	t.Helper()
+	cid := s.start(ctx, t, cfg)
+	code, err := s.rt.WaitContainer(ctx, cid)
+	if err != nil {
+		t.Fatalf("WaitContainer: %v", err)
+	}
+	logs, err := s.rt.ContainerLogsAll(ctx, cid)
+	if err != nil {
+		t.Fatalf("ContainerLogsAll: %v", err)
+	}
+	return code, string(logs)
+}
+
+func (s *suite) state(ctx context.Context, t *testing.T, cid string) string {
+	t.Helper()
+	state, err := s.rt.ContainerState(ctx, cid)
+	if err != nil {
+		t.Fatalf("ContainerState: %v", err)
+	}
+	return state
+}
+
+func (s *suite) testPing(t *testing.T) {
+	if err := s.rt.Ping(testContext(t)); err != nil {
+		t.Fatalf("Ping: %v", err)
+	}
+}
+
+func (s *suite) testLifecycle(t *testing.T) {
+	ctx := testContext(t)
+	cid := s.create(ctx, t, container.Config{Cmd: idleCmd})
+
+	if state := s.state(ctx, t, cid); !slices.Contains(createdStates, state) {
+		t.Errorf("state after create = %q, want one of %v", state, createdStates)
+	}
+
+	listed, err := s.rt.ListContainers(ctx)
+	if err != nil {
+		t.Fatalf("ListContainers: %v", err)
+	}
+	if !slices.ContainsFunc(listed, func(c container.Info) bool { return c.ID == cid || strings.HasPrefix(cid, c.ID) }) {
+		t.Errorf("ListContainers does not include created container %s", cid)
+	}
+
+	if err := s.rt.StartContainer(ctx, cid); err != nil {
+		t.Fatalf("StartContainer: %v", err)
+	}
+	if state := s.state(ctx, t, cid); state != "running" {
+		t.Errorf("state after start = %q, want running", state)
+	}
+
+	if err := s.rt.StopContainer(ctx, cid); err != nil {
+		t.Fatalf("StopContainer: %v", err)
+	}
+	if state := s.state(ctx, t, cid); !slices.Contains(exitedStates, state) {
+		t.Errorf("state after stop = %q, want one of %v", state, exitedStates)
+	}
+
+	if err := s.rt.RemoveContainer(ctx, cid); err != nil {
+		t.Fatalf("RemoveContainer: %v", err)
+	}
+	if state, err := s.rt.ContainerState(ctx, cid); err == nil {
+		t.Errorf("ContainerState after remove = %q, want an error", state)
+	}
+}
+
+func (s *suite) testExec(t *testing.T) {
+	ctx := testContext(t)
+	cid := s.start(ctx, t, container.Config{Cmd: idleCmd})
+
+	var stdout, stderr bytes.Buffer
+	if err := s.rt.Exec(ctx, cid, []string{"sh", "-c", "echo out; echo err >&2"}, nil, &stdout, &stderr); err != nil {
+		t.Fatalf("Exec: %v", err)
+	}
+	if stdout.String() != "out\n" || stderr.String() != "err\n" {
+		t.Errorf("Exec stdout = %q, stderr = %q; want %q and %q", stdout.String(), stderr.String(), "out\n", "err\n")
+	}
+
+	stdout.Reset()
+	if err := s.rt.Exec(ctx, cid, []string{"cat"}, []byte("from stdin"), &stdout, io.Discard); err != nil {
+		t.Fatalf("Exec with stdin: %v", err)
+	}
+	if stdout.String() != "from stdin" {
+		t.Errorf("Exec with stdin printed %q, want %q", stdout.String(), "from stdin")
+	}
+
+	err := s.rt.Exec(ctx, cid, []string{"sh", "-c", "exit 3"}, nil, io.Discard, io.Discard)
+	var execErr *container.ExecError
+	if !errors.As(err, &execErr) || execErr.ExitCode != 3 {
+		t.Errorf("Exec exiting 3 returned %v, want *container.ExecError with exit code 3", err)
+	}
+}
+
+func (s *suite) testExitCodeAndLogs(t *testing.T) {
+	ctx := testContext(t)
+	code, logs := s.runToExit(ctx, t, container.Config{
+		Cmd: []string{"sh", "-c", "echo to-stdout; echo to-stderr >&2; exit 7"},
+	})
+	if code != 7 {
+		t.Errorf("exit code = %d, want 7", code)
+	}
+	for _, want := range []string{"to-stdout", "to-stderr"} {
+		if !strings.Contains(logs, want) {
+			t.Errorf("logs = %q, want them to contain %q", logs, want)
+		}
+	}
+}
+
+func (s *suite) testFollowLogs(t *testing.T) {
+	ctx := testContext(t)
+	cid := s.start(ctx, t, container.Config{
+		Cmd: []string{"sh", "-c", "echo first; sleep 1; echo second"},
+	})
+	rc, err := s.rt.ContainerLogs(ctx, cid)
+	if err != nil {
+		t.Fatalf("ContainerLogs: %v", err)
+	}
+	defer rc.Close()
+	logs, err := io.ReadAll(rc)
+	if err != nil {
+		t.Fatalf("reading followed logs: %v", err)
+	}
+	first, second := strings.Index(string(logs), "first"), strings.Index(string(logs), "second")
+	if first < 0 || second < first {
+		t.Errorf("followed logs = %q, want %q then %q", logs, "first", "second")
+	}
+}
+
+func (s *suite) testEnvAndWorkingDir(t *testing.T) {
+	ctx := testContext(t)
+	code, logs := s.runToExit(ctx, t, container.Config{
+		Cmd:        []string{"sh", "-c", `echo "value=$CONFORMANCE_VAR"; echo "dir=$(pwd)"`},
+		Env:        []string{"CONFORMANCE_VAR=set by moat"},
+		WorkingDir: "/tmp",
+	})
+	if code != 0 {
+		t.Fatalf("exit code = %d, want 0 (logs: %s)", code, logs)
+	}
+	for _, want := range []string{"value=set by moat", "dir=/tmp"} {
+		if !strings.Contains(logs, want) {
+			t.Errorf("logs = %q, want them to contain %q", logs, want)
+		}
+	}
+}
+
+func (s *suite) testBindMounts(t *testing.T) {
+	ctx := testContext(t)
+	rw, ro := t.TempDir(), t.TempDir()
+	if err := os.WriteFile(filepath.Join(rw, "in.txt"), []byte("from host"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	code, logs := s.runToExit(ctx, t, container.Config{
+		Cmd: []string{"sh", "-c", "cat /mnt/rw/in.txt && echo from-container > /mnt/rw/out.txt && ! touch /mnt/ro/denied 2>/dev/null"},
+		Mounts: []container.MountConfig{
+			{Source: rw, Target: "/mnt/rw"},
+			{Source: ro, Target: "/mnt/ro", ReadOnly: true},
+		},
+	})
+	if code != 0 {
+		t.Fatalf("exit code = %d, want 0 (logs: %s)", code, logs)
+	}
+	if !strings.Contains(logs, "from host") {
+		t.Errorf("logs = %q, want the host file's contents", logs)
+	}
+	if out, err := os.ReadFile(filepath.Join(rw, "out.txt")); err != nil || strings.TrimSpace(string(out)) != "from-container" {
+		t.Errorf("host out.txt = %q (%v), want the container's write", out, err)
+	}
+	if _, err := os.Stat(filepath.Join(ro, "denied")); err == nil {
+		t.Error("container wrote to a read-only mount")
+	}
+}
+
+func (s *suite) testPortBindings(t *testing.T) {
+	ctx := testContext(t)
+	const port = 8080
+	cid := s.start(ctx, t, container.Config{
+		Cmd:          []string{"sh", "-c", "echo pong | nc -l -p " + strconv.Itoa(port)},
+		PortBindings: map[int]string{port: "127.0.0.1"},
+	})
+
+	bindings, err := s.rt.GetPortBindings(ctx, cid)
+	if err != nil {
+		t.Fatalf("GetPortBindings: %v", err)
+	}
+	hostPort := bindings[port]
+	if hostPort == 0 {
+		t.Fatalf("GetPortBindings = %v, want a host port for %d", bindings, port)
+	}
+
+	addr := net.JoinHostPort("127.0.0.1", strconv.Itoa(hostPort))
+	deadline := time.Now().Add(30 * time.Second)
+	for {
+		got, err := readFrom(addr)
+		if err == nil && strings.TrimSpace(got) == "pong" {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("reading from %s: got %q, err %v; want pong", addr, got, err)
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+}
+
+func readFrom(addr string) (string, error) {
+	conn, err := net.DialTimeout("tcp", addr, 2*time.Second)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	b, err := io.ReadAll(conn)
+	return string(b), err
+}
+
+func (s *suite) testVolumes(t *testing.T) {
+	ctx := testContext(t)
+	name := id.Generate("moat-conformance")
+	if err := s.rt.VolumeCreate(ctx, name); err != nil {
+		t.Fatalf("VolumeCreate: %v", err)
+	}
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		_ = s.rt.VolumeRemove(ctx, name, true)
+	})
+	if err := s.rt.VolumeCreate(ctx, name); err != nil {
+		t.Errorf("VolumeCreate on an existing volume: %v, want it to be idempotent", err)
+	}
+
+	names, err := s.rt.VolumeList(ctx, "moat-conformance")
+	if err != nil {
+		t.Fatalf("VolumeList: %v", err)
+	}
+	if !slices.Contains(names, name) {
+		t.Errorf("VolumeList = %v, want it to include %s", names, name)
+	}
+
+	mount := []container.MountConfig{{Source: name, Target: "/data", Volume: true}}
+	if code, logs := s.runToExit(ctx, t, container.Config{
+		Cmd:    []string{"sh", "-c", "echo persisted > /data/file"},
+		Mounts: mount,
+	}); code != 0 {
+		t.Fatalf("writing to volume: exit code %d (logs: %s)", code, logs)
+	}
+	code, logs := s.runToExit(ctx, t, container.Config{Cmd: []string{"cat", "/data/file"}, Mounts: mount})
+	if code != 0 || !strings.Contains(logs, "persisted") {
+		t.Errorf("reading volume from a second container: exit code %d, logs %q; want persisted", code, logs)
+	}
+
+	dir := t.TempDir()
+	if err := s.rt.VolumeExport(ctx, name, dir); err != nil {
+		t.Fatalf("VolumeExport: %v", err)
+	}
+	if b, err := os.ReadFile(filepath.Join(dir, "file")); err != nil || strings.TrimSpace(string(b)) != "persisted" {
+		t.Errorf("exported file = %q (%v), want persisted", b, err)
+	}
+}
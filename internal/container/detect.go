@@ -2,6 +2,7 @@ package container
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
@@ -11,6 +12,7 @@ import (
 	"time"
 
 	"github.com/docker/docker/client"
+	"github.com/majorcontext/moat/internal/errcode"
 	"github.com/majorcontext/moat/internal/log"
 	"github.com/majorcontext/moat/internal/ui"
 )
@@ -21,6 +23,10 @@ type RuntimeOptions struct {
 	// When true (default), requires gVisor and fails if unavailable.
 	// When false, uses runc with reduced isolation.
 	Sandbox bool
+
+	// Kubernetes configures the kubernetes runtime, which is only used
+	// when selected explicitly.
+	Kubernetes KubernetesOptions
 }
 
 // DefaultRuntimeOptions returns the default runtime options.
@@ -45,7 +51,7 @@ func NewRuntimeWithOptions(opts RuntimeOptions) (Runtime, error) {
 			log.Debug("using Docker runtime (MOAT_RUNTIME=docker)")
 			rt, err := newDockerRuntimeWithPing(opts.Sandbox)
 			if err != nil {
-				hint := "Set MOAT_RUNTIME=apple, use --runtime apple, or remove 'runtime: docker' from moat.yaml to use auto-detection."
+				hint := "Set MOAT_RUNTIME=apple or MOAT_RUNTIME=podman, use --runtime apple or --runtime podman, or remove 'runtime: docker' from moat.yaml to use auto-detection."
 				return nil, fmt.Errorf("Docker runtime requested (via MOAT_RUNTIME or moat.yaml) but not available: %w\n\n%s", err, hint)
 			}
 			return rt, nil
@@ -56,8 +62,23 @@ func NewRuntimeWithOptions(opts RuntimeOptions) (Runtime, error) {
 				return rt, nil
 			}
 			return nil, fmt.Errorf("Apple container runtime not available: %s\n\nTo start the container system manually:\n  container system start", reason)
+		case "podman":
+			log.Debug("using Podman runtime (MOAT_RUNTIME=podman)")
+			rt, err := newPodmanRuntimeWithPing(opts.Sandbox)
+			if err != nil {
+				hint := "Set MOAT_RUNTIME=docker, use --runtime docker, or remove 'runtime: podman' from moat.yaml to use auto-detection."
+				return nil, fmt.Errorf("Podman runtime requested (via MOAT_RUNTIME or moat.yaml) but not available: %w\n\n%s", err, hint)
+			}
+			return rt, nil
+		case "kubernetes":
+			log.Debug("using kubernetes runtime (MOAT_RUNTIME=kubernetes)")
+			rt, err := newKubernetesRuntimeWithPing(opts.Kubernetes)
+			if err != nil {
+				return nil, fmt.Errorf("kubernetes runtime requested (via MOAT_RUNTIME or moat.yaml) but not available: %w", err)
+			}
+			return rt, nil
 		default:
-			return nil, fmt.Errorf("unknown MOAT_RUNTIME value %q (use 'docker' or 'apple')", override)
+			return nil, fmt.Errorf("unknown MOAT_RUNTIME value %q (use 'docker', 'apple', 'podman', or 'kubernetes')", override)
 		}
 	}
 
@@ -74,24 +95,36 @@ func NewRuntimeWithOptions(opts RuntimeOptions) (Runtime, error) {
 		}
 	}
 
-	// Fall back to Docker
+	// Fall back to Docker, then Podman
 	rt, err := newDockerRuntimeWithPing(opts.Sandbox)
 	if err != nil {
+		podmanRT, podmanErr := newPodmanRuntimeWithPing(opts.Sandbox)
+		if podmanErr == nil {
+			return podmanRT, nil
+		}
+		if !errors.Is(podmanErr, errPodmanNotFound) {
+			// Podman is installed but unusable; report both so the user
+			// can fix whichever they meant to use.
+			err = fmt.Errorf("%w\n  Podman: %v", err, podmanErr)
+		}
 		if appleReason != "" {
-			return nil, fmt.Errorf("no container runtime available:\n  Apple containers: %s\n  Docker: %w\n\nTo start Apple containers manually:\n  container system start\n\nTo force a specific runtime:\n  moat run --runtime apple\n  moat run --runtime docker", appleReason, err)
+			return nil, errcode.Wrap(errcode.RuntimeUnavailable, fmt.Errorf("no container runtime available:\n  Apple containers: %s\n  Docker: %w\n\nTo start Apple containers manually:\n  container system start\n\nTo force a specific runtime:\n  moat run --runtime apple\n  moat run --runtime docker", appleReason, err))
 		}
-		return nil, fmt.Errorf("no container runtime available: %w", err)
+		return nil, errcode.Wrap(errcode.RuntimeUnavailable, fmt.Errorf("no container runtime available: %w", err))
 	}
 	return rt, nil
 }
 
 // NewRuntime creates a new container runtime, auto-detecting the best available option.
 // On macOS with Apple Silicon, it prefers Apple's container tool if available,
-// falling back to Docker otherwise. Docker containers use gVisor by default.
+// falling back to Docker otherwise, and to Podman if Docker is unreachable.
+// Docker and Podman containers use gVisor by default.
 //
 // The MOAT_RUNTIME environment variable can override auto-detection:
 //   - MOAT_RUNTIME=docker: force Docker runtime
 //   - MOAT_RUNTIME=apple: force Apple container runtime
+//   - MOAT_RUNTIME=podman: force Podman runtime
+//   - MOAT_RUNTIME=kubernetes: run pods on a Kubernetes cluster (never auto-detected)
 func NewRuntime() (Runtime, error) {
 	return NewRuntimeWithOptions(DefaultRuntimeOptions())
 }
@@ -138,6 +171,42 @@ func newDockerRuntimeWithPing(sandbox bool) (Runtime, error) {
 	return rt, nil
 }
 
+// newPodmanRuntimeWithPing creates a Podman runtime and verifies its API
+// socket answers. Returns an error wrapping errPodmanNotFound when no socket
+// exists.
+func newPodmanRuntimeWithPing(sandbox bool) (Runtime, error) {
+	rt, err := NewPodmanRuntime(sandbox)
+	if err != nil {
+		return nil, fmt.Errorf("Podman runtime error: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := rt.Ping(ctx); err != nil {
+		rt.Close()
+		return nil, err
+	}
+
+	log.Debug("using Podman runtime", "socket", rt.socket, "gvisor", sandbox)
+	return rt, nil
+}
+
+// newKubernetesRuntimeWithPing creates a kubernetes runtime and verifies the
+// cluster accepts pods in its namespace.
+func newKubernetesRuntimeWithPing(opts KubernetesOptions) (Runtime, error) {
+	rt, err := NewKubernetesRuntime(opts)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := rt.Ping(ctx); err != nil {
+		return nil, err
+	}
+	log.Debug("using kubernetes runtime", "context", opts.Context, "namespace", rt.namespace)
+	return rt, nil
+}
+
 // dockerSocketCandidate represents a known Docker-compatible socket from a
 // third-party container tool.
 type dockerSocketCandidate struct {
@@ -305,6 +374,10 @@ func NewRuntimeByType(rt RuntimeType, opts RuntimeOptions) (Runtime, error) {
 			return r, nil
 		}
 		return nil, fmt.Errorf("Apple container runtime not available: %s", reason)
+	case RuntimePodman:
+		return newPodmanRuntimeWithPing(opts.Sandbox)
+	case RuntimeKubernetes:
+		return newKubernetesRuntimeWithPing(opts.Kubernetes)
 	default:
 		return nil, fmt.Errorf("unknown runtime type: %q", rt)
 	}
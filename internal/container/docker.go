@@ -88,13 +88,24 @@ type dockerSidecarManager struct {
 // dockerBuildManager implements BuildManager for Docker.
 type dockerBuildManager struct {
 	cli *client.Client
+
+	// legacyOnly skips the embedded BuildKit attempt. Podman's
+	// Docker-compatible API builds with Buildah and has no BuildKit session
+	// endpoint, so trying it first only produces a fallback warning.
+	legacyOnly bool
 }
 
 // NewDockerRuntime creates a new Docker runtime.
 // If sandbox is true, requires gVisor (runsc) and fails if unavailable.
 // If sandbox is false, uses standard runc runtime with a warning.
 func NewDockerRuntime(sandbox bool) (*DockerRuntime, error) {
-	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	return newDockerRuntime(sandbox, client.FromEnv)
+}
+
+// newDockerRuntime creates a runtime on a Docker API client built with opts.
+// PodmanRuntime uses it to talk to Podman's Docker-compatible socket.
+func newDockerRuntime(sandbox bool, opts ...client.Opt) (*DockerRuntime, error) {
+	cli, err := client.NewClientWithOpts(append(opts, client.WithAPIVersionNegotiation())...)
 	if err != nil {
 		return nil, fmt.Errorf("creating docker client: %w", err)
 	}
@@ -166,6 +177,15 @@ func (r *DockerRuntime) Ping(ctx context.Context) error {
 	return nil
 }
 
+// Version returns the Docker engine version.
+func (r *DockerRuntime) Version(ctx context.Context) (string, error) {
+	v, err := r.cli.ServerVersion(ctx)
+	if err != nil {
+		return "", fmt.Errorf("getting docker version: %w", err)
+	}
+	return v.Version, nil
+}
+
 // buildContainerMounts converts moat's MountConfig and TmpfsMount entries into
 // Docker SDK mount.Mount structs. Tmpfs mounts follow bind mounts so overlays
 // of paths inside a bind take effect.
@@ -309,6 +329,15 @@ func (r *DockerRuntime) initNamedVolumeOwnership(ctx context.Context, cfg Config
 	return nil
 }
 
+// initFlag maps Config.Init to HostConfig.Init, leaving the daemon's
+// default in place when unset.
+func initFlag(init bool) *bool {
+	if !init {
+		return nil
+	}
+	return &init
+}
+
 // CreateContainer creates a new Docker container.
 func (r *DockerRuntime) CreateContainer(ctx context.Context, cfg Config) (string, error) {
 	// Verify gVisor is still available if we're configured to use it
@@ -384,6 +413,18 @@ func (r *DockerRuntime) CreateContainer(ctx context.Context, cfg Config) (string
 		cpuQuota = int64(cfg.CPUs) * cpuPeriod
 	}
 
+	// CPU and IO weights: only set for non-default classes so normal runs
+	// are created exactly as before.
+	var weights PriorityWeights
+	if cfg.Priority != "" && cfg.Priority != PriorityNormal {
+		weights = cfg.Priority.Weights()
+	}
+
+	var shmSize int64
+	if cfg.ShmSizeMB > 0 {
+		shmSize = int64(cfg.ShmSizeMB) * 1024 * 1024
+	}
+
 	var dockerUlimits []*container.Ulimit
 	for _, u := range cfg.Ulimits {
 		dockerUlimits = append(dockerUlimits, &container.Ulimit{
@@ -393,6 +434,15 @@ func (r *DockerRuntime) CreateContainer(ctx context.Context, cfg Config) (string
 		})
 	}
 
+	var devices []container.DeviceMapping
+	for _, d := range cfg.Devices {
+		devices = append(devices, container.DeviceMapping{
+			PathOnHost:        d,
+			PathInContainer:   d,
+			CgroupPermissions: "rwm",
+		})
+	}
+
 	resp, err := r.cli.ContainerCreate(ctx,
 		&container.Config{
 			Image:        cfg.Image,
@@ -406,6 +456,7 @@ func (r *DockerRuntime) CreateContainer(ctx context.Context, cfg Config) (string
 		},
 		&container.HostConfig{
 			Runtime:      r.ociRuntime, // "runsc" or "runc" or ""
+			UsernsMode:   container.UsernsMode(cfg.UsernsMode),
 			Mounts:       mounts,
 			NetworkMode:  networkMode,
 			ExtraHosts:   cfg.ExtraHosts,
@@ -414,11 +465,16 @@ func (r *DockerRuntime) CreateContainer(ctx context.Context, cfg Config) (string
 			GroupAdd:     cfg.GroupAdd,
 			Privileged:   cfg.Privileged,
 			DNS:          dns,
+			Init:         initFlag(cfg.Init),
+			ShmSize:      shmSize,
 			Resources: container.Resources{
-				Memory:    memoryBytes,
-				CPUQuota:  cpuQuota,
-				CPUPeriod: cpuPeriod,
-				Ulimits:   dockerUlimits,
+				Memory:      memoryBytes,
+				CPUQuota:    cpuQuota,
+				CPUPeriod:   cpuPeriod,
+				CPUShares:   weights.CPUShares,
+				BlkioWeight: weights.BlkioWeight,
+				Ulimits:     dockerUlimits,
+				Devices:     devices,
 			},
 		},
 		nil, // network config
@@ -790,18 +846,83 @@ func (r *DockerRuntime) SetupFirewall(ctx context.Context, containerID string, p
 		return fmt.Errorf("invalid proxy port %d: must be between 1 and 65535", proxyPort)
 	}
 
+	output, err := r.execRootScript(ctx, containerID, firewallScript(proxyPort), "firewall setup")
+	if err != nil {
+		return err
+	}
+
+	// Surface ip6tables warnings so they appear in moat's diagnostic logs.
+	if strings.Contains(output, "WARN: ip6tables") {
+		log.Warn("ip6tables unavailable in container — IPv6 egress is not firewalled", "container", containerID)
+	}
+
+	return nil
+}
+
+// RestrictServiceAccess installs iptables rules limiting the container's
+// connections to the run's service containers.
+func (r *DockerRuntime) RestrictServiceAccess(ctx context.Context, containerID string, rules []ServiceAccess) error {
+	script, err := serviceAccessScript(rules, false)
+	if err != nil {
+		return err
+	}
+	_, err = r.execRootScript(ctx, containerID, script, "service access setup")
+	return err
+}
+
+// execRootScript runs script with sh as root in the container, since
+// iptables requires root privileges, and returns its combined output. what
+// names the operation in errors.
+func (r *DockerRuntime) execRootScript(ctx context.Context, containerID, script, what string) (string, error) {
+	execConfig := container.ExecOptions{
+		Cmd:          []string{"sh", "-c", script},
+		AttachStdout: true,
+		AttachStderr: true,
+		User:         "root",
+	}
+
+	execID, err := r.cli.ContainerExecCreate(ctx, containerID, execConfig)
+	if err != nil {
+		return "", fmt.Errorf("creating exec for %s: %w", what, err)
+	}
+
+	resp, err := r.cli.ContainerExecAttach(ctx, execID.ID, container.ExecAttachOptions{})
+	if err != nil {
+		return "", fmt.Errorf("attaching to exec for %s: %w", what, err)
+	}
+	defer resp.Close()
+
+	// Read output to capture error messages
+	var output bytes.Buffer
+	_, _ = io.Copy(&output, resp.Reader)
+
+	// Check exit code
+	inspect, err := r.cli.ContainerExecInspect(ctx, execID.ID)
+	if err != nil {
+		return "", fmt.Errorf("inspecting exec for %s: %w", what, err)
+	}
+
+	if inspect.ExitCode != 0 {
+		return "", fmt.Errorf("%s failed with exit code %d: %s", what, inspect.ExitCode, output.String())
+	}
+	return output.String(), nil
+}
+
+// firewallScript returns the shell script SetupFirewall runs as root in the
+// container: it drops all outbound IPv4 and IPv6 traffic except loopback,
+// DNS, established connections, and TCP to proxyPort.
+func firewallScript(proxyPort int) string {
 	// iptables rules:
 	// 1. Allow loopback
 	// 2. Allow established connections (for responses)
 	// 3. Allow DNS (needed to resolve hostnames before proxy can intercept)
-	// 4. Allow traffic to proxy port (any destination - see function comment)
+	// 4. Allow traffic to proxy port (any destination - see SetupFirewall)
 	// 5. Drop everything else
 
 	// We run these as a single script to minimize exec calls
 	// Use -w flag to wait for xtables lock (avoids exit code 4 from lock contention)
 	// Use conntrack module instead of state for better container compatibility
-	_ = proxyHost // See function comment for why this is unused
-	script := fmt.Sprintf(`
+	return fmt.Sprintf(`
 		# Verify iptables is available
 		if ! command -v iptables >/dev/null 2>&1; then
 			echo "ERROR: iptables not found - container will not be firewalled" >&2
@@ -820,7 +941,7 @@ func (r *DockerRuntime) SetupFirewall(ctx context.Context, containerID string, p
 		# Allow DNS (UDP 53) - needed for initial hostname resolution
 		iptables -w -A OUTPUT -p udp --dport 53 -j ACCEPT
 
-		# Allow traffic to proxy port (destination IP not filtered - see function comment)
+		# Allow traffic to proxy port (destination IP not filtered - see SetupFirewall)
 		iptables -w -A OUTPUT -p tcp --dport %d -j ACCEPT
 
 		# Drop all other outbound traffic
@@ -859,45 +980,6 @@ func (r *DockerRuntime) SetupFirewall(ctx context.Context, containerID string, p
 			fi
 		fi
 	`, proxyPort, proxyPort)
-
-	execConfig := container.ExecOptions{
-		Cmd:          []string{"sh", "-c", script},
-		AttachStdout: true,
-		AttachStderr: true,
-		User:         "root", // iptables requires root privileges
-	}
-
-	execID, err := r.cli.ContainerExecCreate(ctx, containerID, execConfig)
-	if err != nil {
-		return fmt.Errorf("creating exec for firewall setup: %w", err)
-	}
-
-	resp, err := r.cli.ContainerExecAttach(ctx, execID.ID, container.ExecAttachOptions{})
-	if err != nil {
-		return fmt.Errorf("attaching to exec for firewall setup: %w", err)
-	}
-	defer resp.Close()
-
-	// Read output to capture error messages
-	var output bytes.Buffer
-	_, _ = io.Copy(&output, resp.Reader)
-
-	// Check exit code
-	inspect, err := r.cli.ContainerExecInspect(ctx, execID.ID)
-	if err != nil {
-		return fmt.Errorf("inspecting exec for firewall setup: %w", err)
-	}
-
-	if inspect.ExitCode != 0 {
-		return fmt.Errorf("firewall setup failed with exit code %d: %s", inspect.ExitCode, output.String())
-	}
-
-	// Surface ip6tables warnings so they appear in moat's diagnostic logs.
-	if strings.Contains(output.String(), "WARN: ip6tables") {
-		log.Warn("ip6tables unavailable in container — IPv6 egress is not firewalled", "container", containerID)
-	}
-
-	return nil
 }
 
 // Exec runs a command inside a running container.
@@ -1140,6 +1222,112 @@ func (r *DockerRuntime) ContainerState(ctx context.Context, containerID string)
 	return inspect.State.Status, nil
 }
 
+// ContainerPID returns the PID of the container's main process as seen by
+// the Docker daemon's host. On Docker Desktop that host is the VM, not the
+// machine moat runs on.
+func (r *DockerRuntime) ContainerPID(ctx context.Context, containerID string) (int, error) {
+	inspect, err := r.cli.ContainerInspect(ctx, containerID)
+	if err != nil {
+		return 0, fmt.Errorf("inspecting container: %w", err)
+	}
+	if inspect.State == nil || inspect.State.Pid == 0 {
+		return 0, fmt.Errorf("container %s is not running", containerID)
+	}
+	return inspect.State.Pid, nil
+}
+
+// UserNamespaceMode reports UsernsDaemon when the daemon remaps container
+// users itself (userns-remap or rootless mode) and UsernsNone otherwise;
+// Docker has no per-container user namespace option.
+func (r *DockerRuntime) UserNamespaceMode(ctx context.Context) (string, error) {
+	info, err := r.cli.Info(ctx)
+	if err != nil {
+		return UsernsNone, fmt.Errorf("getting daemon info: %w", err)
+	}
+	return usernsModeFromSecurityOptions(info.SecurityOptions, false), nil
+}
+
+// usernsModeFromSecurityOptions maps the daemon's security options
+// ("name=userns", "name=rootless", ...) to a user namespace mode. Rootless
+// Podman supports keep-id; rootful Podman shares host IDs like Docker.
+func usernsModeFromSecurityOptions(opts []string, podman bool) string {
+	for _, opt := range opts {
+		for _, field := range strings.Split(opt, ",") {
+			switch field {
+			case "name=rootless":
+				if podman {
+					return UsernsKeepID
+				}
+				return UsernsDaemon
+			case "name=userns":
+				return UsernsDaemon
+			}
+		}
+	}
+	return UsernsNone
+}
+
+// ContainerOOMKilled reports whether the kernel OOM killer terminated the container.
+func (r *DockerRuntime) ContainerOOMKilled(ctx context.Context, containerID string) (bool, error) {
+	inspect, err := r.cli.ContainerInspect(ctx, containerID)
+	if err != nil {
+		return false, fmt.Errorf("inspecting container: %w", err)
+	}
+	return inspect.State.OOMKilled, nil
+}
+
+// UpdatePriority applies the priority class's CPU shares and block IO weight
+// to a running container. Daemons whose kernel has no IO weighting drop the
+// IO weight with a warning rather than failing.
+func (r *DockerRuntime) UpdatePriority(ctx context.Context, containerID string, p Priority) error {
+	w := p.Weights()
+	_, err := r.cli.ContainerUpdate(ctx, containerID, container.UpdateConfig{
+		Resources: container.Resources{CPUShares: w.CPUShares, BlkioWeight: w.BlkioWeight},
+	})
+	if err != nil {
+		return fmt.Errorf("updating container priority: %w", err)
+	}
+	return nil
+}
+
+// ContainerStats samples a container's resource usage with a one-shot stats
+// request. Memory excludes the page cache, as `docker stats` reports it.
+func (r *DockerRuntime) ContainerStats(ctx context.Context, containerID string) (Stats, error) {
+	resp, err := r.cli.ContainerStatsOneShot(ctx, containerID)
+	if err != nil {
+		return Stats{}, fmt.Errorf("reading container stats: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var v container.StatsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&v); err != nil {
+		return Stats{}, fmt.Errorf("decoding container stats: %w", err)
+	}
+	return dockerStats(v), nil
+}
+
+func dockerStats(v container.StatsResponse) Stats {
+	mem := v.MemoryStats.Usage
+	// cgroup v2 reports inactive_file, v1 total_inactive_file.
+	cache, ok := v.MemoryStats.Stats["inactive_file"]
+	if !ok {
+		cache = v.MemoryStats.Stats["total_inactive_file"]
+	}
+	if cache < mem {
+		mem -= cache
+	}
+	s := Stats{
+		CPUNanos:         v.CPUStats.CPUUsage.TotalUsage,
+		MemoryBytes:      mem,
+		MemoryLimitBytes: v.MemoryStats.Limit,
+	}
+	for _, n := range v.Networks {
+		s.NetRxBytes += n.RxBytes
+		s.NetTxBytes += n.TxBytes
+	}
+	return s
+}
+
 // ResizeTTY resizes the container's TTY to the given dimensions.
 func (r *DockerRuntime) ResizeTTY(ctx context.Context, containerID string, height, width uint) error {
 	return r.cli.ContainerResize(ctx, containerID, container.ResizeOptions{
@@ -1266,6 +1454,24 @@ func (m *dockerNetworkManager) CreateNetwork(ctx context.Context, name string) (
 	return resp.ID, nil
 }
 
+// CreateInternalNetwork creates a Docker bridge network with no route out
+// of the host, so its containers reach only each other. Returns the network
+// ID.
+func (m *dockerNetworkManager) CreateInternalNetwork(ctx context.Context, name string) (string, error) {
+	callCtx, cancel := context.WithTimeout(ctx, networkCreateTimeout)
+	defer cancel()
+
+	resp, err := m.cli.NetworkCreate(callCtx, name, network.CreateOptions{
+		Driver:   "bridge",
+		Internal: true,
+		Labels:   map[string]string{"moat.managed": "true"},
+	})
+	if err != nil {
+		return "", fmt.Errorf("creating internal network: %w", err)
+	}
+	return resp.ID, nil
+}
+
 // RemoveNetwork removes a Docker network by ID.
 // Returns an error if the network has active endpoints (use ForceRemoveNetwork as fallback).
 // Does not fail if network doesn't exist.
@@ -1312,6 +1518,15 @@ func (m *dockerNetworkManager) ForceRemoveNetwork(ctx context.Context, networkID
 	return nil
 }
 
+// ConnectContainer attaches a container to an additional Docker network with
+// the given DNS aliases.
+func (m *dockerNetworkManager) ConnectContainer(ctx context.Context, networkID, containerID string, aliases []string) error {
+	if err := m.cli.NetworkConnect(ctx, networkID, containerID, &network.EndpointSettings{Aliases: aliases}); err != nil {
+		return fmt.Errorf("connecting container to network: %w", err)
+	}
+	return nil
+}
+
 // ListNetworks returns all moat-managed networks (those with label moat.managed=true).
 func (m *dockerNetworkManager) ListNetworks(ctx context.Context) ([]NetworkInfo, error) {
 	networks, err := m.cli.NetworkList(ctx, network.ListOptions{
@@ -1372,6 +1587,18 @@ func (m *dockerSidecarManager) StartSidecar(ctx context.Context, cfg SidecarConf
 
 	mounts := buildContainerMounts(cfg.Mounts, nil)
 
+	var exposedPorts nat.PortSet
+	var portBindings nat.PortMap
+	if len(cfg.PortBindings) > 0 {
+		exposedPorts = make(nat.PortSet)
+		portBindings = make(nat.PortMap)
+		for containerPort, hostIP := range cfg.PortBindings {
+			port := nat.Port(fmt.Sprintf("%d/tcp", containerPort))
+			exposedPorts[port] = struct{}{}
+			portBindings[port] = []nat.PortBinding{{HostIP: hostIP}}
+		}
+	}
+
 	// Create container with labels for orphan cleanup
 	labels := make(map[string]string)
 	if cfg.RunID != "" {
@@ -1384,17 +1611,19 @@ func (m *dockerSidecarManager) StartSidecar(ctx context.Context, cfg SidecarConf
 
 	resp, err := m.cli.ContainerCreate(ctx,
 		&container.Config{
-			Image:    cfg.Image,
-			Cmd:      cfg.Cmd,
-			Hostname: cfg.Hostname,
-			Labels:   labels,
-			Env:      cfg.Env,
+			Image:        cfg.Image,
+			Cmd:          cfg.Cmd,
+			Hostname:     cfg.Hostname,
+			Labels:       labels,
+			Env:          cfg.Env,
+			ExposedPorts: exposedPorts,
 		},
 		&container.HostConfig{
-			Runtime:     m.ociRuntime, // Use same OCI runtime as main container
-			NetworkMode: container.NetworkMode(cfg.NetworkID),
-			Privileged:  cfg.Privileged,
-			Mounts:      mounts,
+			Runtime:      m.ociRuntime, // Use same OCI runtime as main container
+			NetworkMode:  container.NetworkMode(cfg.NetworkID),
+			Privileged:   cfg.Privileged,
+			Mounts:       mounts,
+			PortBindings: portBindings,
 			Resources: container.Resources{
 				Memory: int64(cfg.MemoryMB) * 1024 * 1024, // 0 means no limit
 			},
@@ -1489,7 +1718,7 @@ func (m *dockerBuildManager) ImageExists(ctx context.Context, tag string) (bool,
 //
 // Build routing:
 //  1. BUILDKIT_HOST set       → standalone BuildKit (dind sidecar)
-//  2. MOAT_DISABLE_BUILDKIT=1 → legacy builder directly
+//  2. MOAT_DISABLE_BUILDKIT=1 → legacy builder directly (always on Podman)
 //  3. Default                 → try Docker's embedded BuildKit, fall back to legacy builder
 //
 // Note: opts.DNS is ignored for Docker builds; Docker uses daemon-level DNS configuration.
@@ -1505,6 +1734,10 @@ func (m *dockerBuildManager) BuildImage(ctx context.Context, dockerfile string,
 		log.Debug("buildkit disabled via MOAT_DISABLE_BUILDKIT, using legacy builder", "tag", tag)
 		return m.buildImageWithLegacyBuilder(ctx, dockerfile, tag, opts)
 	}
+	if m.legacyOnly {
+		log.Debug("runtime has no embedded buildkit, using legacy builder", "tag", tag)
+		return m.buildImageWithLegacyBuilder(ctx, dockerfile, tag, opts)
+	}
 
 	// Default: try Docker's embedded BuildKit, fall back to legacy builder
 	log.Debug("trying embedded buildkit for image build", "tag", tag)
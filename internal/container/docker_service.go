@@ -4,12 +4,14 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"net"
 	"sort"
 	"time"
 
 	dockercontainer "github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/client"
 	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/docker/go-connections/nat"
 )
 
 // dockerServiceManager implements ServiceManager using Docker sidecars.
@@ -39,7 +41,24 @@ func (m *dockerServiceManager) StartService(ctx context.Context, cfg ServiceConf
 		return ServiceInfo{}, fmt.Errorf("starting %s service: %w", cfg.Name, err)
 	}
 
-	return buildServiceInfo(containerID, cfg, cfg.Name), nil
+	info := buildServiceInfo(containerID, cfg, cfg.Name)
+	if len(cfg.Publish) > 0 {
+		inspect, err := m.cli.ContainerInspect(ctx, containerID)
+		if err != nil {
+			_ = m.StopService(ctx, info)
+			return ServiceInfo{}, fmt.Errorf("inspecting %s service ports: %w", cfg.Name, err)
+		}
+		info.Published = make(map[int]string, len(cfg.Publish))
+		for _, port := range cfg.Publish {
+			bindings := inspect.NetworkSettings.Ports[nat.Port(fmt.Sprintf("%d/tcp", port))]
+			if len(bindings) == 0 {
+				_ = m.StopService(ctx, info)
+				return ServiceInfo{}, fmt.Errorf("%s service port %d was not published", cfg.Name, port)
+			}
+			info.Published[port] = net.JoinHostPort("127.0.0.1", bindings[0].HostPort)
+		}
+	}
+	return info, nil
 }
 
 // CheckReady runs the service's readiness command inside the container.
@@ -176,6 +195,15 @@ func buildSidecarConfig(cfg ServiceConfig, networkID string) SidecarConfig {
 		},
 	}
 
+	// Published ports are bound to loopback only; the routing proxy, which
+	// listens on localhost, is the way in.
+	if len(cfg.Publish) > 0 {
+		sc.PortBindings = make(map[int]string, len(cfg.Publish))
+		for _, port := range cfg.Publish {
+			sc.PortBindings[port] = "127.0.0.1"
+		}
+	}
+
 	// Add cache mount if configured
 	if cfg.CachePath != "" && cfg.CacheHostPath != "" {
 		sc.Mounts = append(sc.Mounts, MountConfig{
@@ -77,3 +77,20 @@ func TestBuildSidecarConfigNoCachePath(t *testing.T) {
 	sidecarCfg := buildSidecarConfig(cfg, "net-000")
 	assert.Empty(t, sidecarCfg.Mounts)
 }
+
+func TestBuildSidecarConfigPublish(t *testing.T) {
+	cfg := ServiceConfig{
+		Name:    "jupyter",
+		Version: "python-3.12",
+		Image:   "quay.io/jupyter/scipy-notebook",
+		Ports:   map[string]int{"default": 8888},
+		RunID:   "test-run-789",
+		Publish: []int{8888},
+	}
+
+	sidecarCfg := buildSidecarConfig(cfg, "net-789")
+	assert.Equal(t, map[int]string{8888: "127.0.0.1"}, sidecarCfg.PortBindings)
+
+	cfg.Publish = nil
+	assert.Nil(t, buildSidecarConfig(cfg, "net-789").PortBindings)
+}
@@ -737,3 +737,25 @@ func TestDockerRuntime_BuildImage_PathSelection(t *testing.T) {
 		})
 	}
 }
+
+func TestUsernsModeFromSecurityOptions(t *testing.T) {
+	tests := []struct {
+		name   string
+		opts   []string
+		podman bool
+		want   string
+	}{
+		{name: "plain docker", opts: []string{"name=apparmor", "name=seccomp,profile=builtin"}, want: UsernsNone},
+		{name: "docker userns-remap", opts: []string{"name=seccomp,profile=builtin", "name=userns"}, want: UsernsDaemon},
+		{name: "rootless docker", opts: []string{"name=seccomp,profile=builtin", "name=rootless"}, want: UsernsDaemon},
+		{name: "rootless podman", opts: []string{"name=seccomp,profile=/usr/share/containers/seccomp.json", "name=rootless"}, podman: true, want: UsernsKeepID},
+		{name: "rootful podman", opts: []string{"name=seccomp,profile=/usr/share/containers/seccomp.json"}, podman: true, want: UsernsNone},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := usernsModeFromSecurityOptions(tt.opts, tt.podman); got != tt.want {
+				t.Errorf("usernsModeFromSecurityOptions() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,1244 @@
+package container
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/creack/pty"
+	"github.com/majorcontext/moat/internal/log"
+	"github.com/majorcontext/moat/internal/term"
+)
+
+// errKubernetesVolumes is returned by the kubernetes runtime's volume
+// methods. Workspaces reach pods through kubernetes.workspace instead.
+var errKubernetesVolumes = errors.New("volume mode is not supported with the kubernetes runtime; use kubernetes.workspace: pvc in ~/.moat/config.yaml to keep the workspace on the cluster")
+
+// Pod layout. The main container runs the agent; the sync init container
+// holds the pod until moat has copied the bind mounts in; the proxy sidecar
+// forwards the proxy port to a NodePort.
+const (
+	k8sMainContainer  = "main"
+	k8sSyncContainer  = "moat-sync"
+	k8sProxyContainer = "moat-proxy"
+	k8sSyncDir        = "/moat-sync"
+	k8sManagedByLabel = "app.kubernetes.io/managed-by"
+	k8sRunLabel       = "moat.run"
+
+	// k8sWorkspaceTarget is the container path the kubernetes.claim
+	// volume is mounted at in pvc mode.
+	k8sWorkspaceTarget = "/workspace"
+
+	// k8sStartTimeout bounds how long StartContainer waits for the pod
+	// to be scheduled and its images pulled.
+	k8sStartTimeout = 5 * time.Minute
+
+	// k8sPollInterval is how often pod state is polled.
+	k8sPollInterval = time.Second
+
+	// k8sMoatUserID owns synced files in moat-built images.
+	k8sMoatUserID = 5000
+)
+
+// KubernetesOptions configures the kubernetes runtime. It mirrors the
+// kubernetes: block of ~/.moat/config.yaml.
+type KubernetesOptions struct {
+	// Context is the kubectl context; empty uses the current one.
+	Context string
+	// Namespace is where run pods are created; empty uses "default".
+	Namespace string
+	// Workspace is "sync" (copy bind mounts in through an init container)
+	// or "pvc" (mount Claim at /workspace; other bind mounts are synced).
+	Workspace string
+	// Claim is the PersistentVolumeClaim used when Workspace is "pvc".
+	Claim string
+	// ProxyHost is the address pods reach the proxy daemon at.
+	ProxyHost string
+	// ProxyPort is a NodePort forwarding to the proxy daemon. When set, a
+	// sidecar forwards the proxy port inside the pod to ProxyHost, or to
+	// the pod's node when ProxyHost is empty, on this port.
+	ProxyPort int
+	// Registry is where built images are pushed for the cluster to pull.
+	Registry string
+	// SidecarImage is the proxy sidecar image; it must provide socat.
+	// Required when ProxyPort is set.
+	SidecarImage string
+}
+
+// KubernetesRuntime implements Runtime by scheduling each run as a pod with
+// kubectl. The cluster cannot see the host filesystem, so bind mounts are
+// copied into the pod before the agent starts (or, in pvc mode, the
+// workspace comes from a PersistentVolumeClaim); changes made in the pod are
+// not copied back. Images moat builds are built with the local docker CLI
+// and pushed to Options.Registry.
+//
+// Container IDs are "<namespace>/<pod>". There are no networks, sidecars,
+// services, named volumes, or published ports.
+type KubernetesRuntime struct {
+	opts      KubernetesOptions
+	kubectl   string
+	namespace string
+	buildMgr  *kubernetesBuildManager
+
+	// pending holds the bind mounts to copy into each created pod until
+	// StartContainer copies them, keyed by container ID. Protected by mu.
+	mu      sync.Mutex
+	pending map[string][]k8sSync
+
+	// activePTY tracks the PTY of each attached pod so ResizeTTY can
+	// propagate terminal size changes. Protected by ptyMu.
+	ptyMu     sync.Mutex
+	activePTY map[string]*os.File
+}
+
+// Verify KubernetesRuntime satisfies the Runtime interface at compile time.
+var _ Runtime = (*KubernetesRuntime)(nil)
+
+// NewKubernetesRuntime creates a kubernetes runtime. It fails when kubectl
+// is not installed or the pods would have no way to reach the proxy.
+func NewKubernetesRuntime(opts KubernetesOptions) (*KubernetesRuntime, error) {
+	kubectl, err := exec.LookPath("kubectl")
+	if err != nil {
+		return nil, fmt.Errorf("kubectl not found in PATH: %w", err)
+	}
+	if opts.ProxyHost == "" && opts.ProxyPort == 0 {
+		return nil, fmt.Errorf("the kubernetes runtime needs a way for pods to reach the proxy: set kubernetes.proxy_host or kubernetes.proxy_port in ~/.moat/config.yaml")
+	}
+	if opts.Workspace == "pvc" && opts.Claim == "" {
+		return nil, fmt.Errorf("kubernetes.workspace: pvc requires kubernetes.claim")
+	}
+	// The sidecar carries all of the pod's proxy traffic, so moat does not
+	// pick a floating third-party image for it.
+	if opts.ProxyPort != 0 && opts.SidecarImage == "" {
+		return nil, fmt.Errorf("kubernetes.proxy_port runs a socat sidecar in each pod: set kubernetes.sidecar_image in ~/.moat/config.yaml to a socat image, pinned by digest")
+	}
+	namespace := opts.Namespace
+	if namespace == "" {
+		namespace = "default"
+	}
+	return &KubernetesRuntime{
+		opts:      opts,
+		kubectl:   kubectl,
+		namespace: namespace,
+		buildMgr:  &kubernetesBuildManager{registry: opts.Registry},
+		pending:   make(map[string][]k8sSync),
+	}, nil
+}
+
+// Type returns RuntimeKubernetes.
+func (r *KubernetesRuntime) Type() RuntimeType {
+	return RuntimeKubernetes
+}
+
+// command returns a kubectl command with the configured context.
+func (r *KubernetesRuntime) command(ctx context.Context, args ...string) *exec.Cmd {
+	if r.opts.Context != "" {
+		args = append([]string{"--context", r.opts.Context}, args...)
+	}
+	return exec.CommandContext(ctx, r.kubectl, args...)
+}
+
+// run runs kubectl and returns its stdout, folding stderr into the error.
+func (r *KubernetesRuntime) run(ctx context.Context, args ...string) ([]byte, error) {
+	cmd := r.command(ctx, args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return out, fmt.Errorf("kubectl %s: %w: %s", args[0], err, msg)
+		}
+		return out, fmt.Errorf("kubectl %s: %w", args[0], err)
+	}
+	return out, nil
+}
+
+// Ping verifies the cluster is reachable and pods can be created in the
+// namespace.
+func (r *KubernetesRuntime) Ping(ctx context.Context) error {
+	out, err := r.run(ctx, "auth", "can-i", "create", "pods", "--namespace", r.namespace)
+	answer := strings.TrimSpace(string(out))
+	if answer == "no" {
+		return fmt.Errorf("not allowed to create pods in namespace %s", r.namespace)
+	}
+	if err != nil {
+		return fmt.Errorf("kubernetes cluster not accessible: %w", err)
+	}
+	return nil
+}
+
+// Version returns the cluster's Kubernetes version.
+func (r *KubernetesRuntime) Version(ctx context.Context) (string, error) {
+	out, err := r.run(ctx, "version", "--output", "json")
+	if err != nil {
+		return "", err
+	}
+	var v struct {
+		ServerVersion struct {
+			GitVersion string `json:"gitVersion"`
+		} `json:"serverVersion"`
+	}
+	if err := json.Unmarshal(out, &v); err != nil {
+		return "", fmt.Errorf("parsing kubectl version: %w", err)
+	}
+	return v.ServerVersion.GitVersion, nil
+}
+
+// CreateContainer creates the run's pod. The pod is scheduled right away,
+// but the sync init container holds it until StartContainer has copied the
+// bind mounts in.
+func (r *KubernetesRuntime) CreateContainer(ctx context.Context, cfg Config) (string, error) {
+	pod, syncs, err := r.buildPod(cfg)
+	if err != nil {
+		return "", err
+	}
+	// The run's environment carries the proxy token and other secrets, so
+	// it goes into a Secret the pod references rather than the pod spec,
+	// which anyone allowed to get pods can read.
+	secret := buildEnvSecret(cfg)
+	if err := r.create(ctx, "secret", secret.Metadata.Name, secret); err != nil {
+		return "", err
+	}
+	if err := r.create(ctx, "pod", pod.Metadata.Name, pod); err != nil {
+		r.deleteEnvSecret(ctx, r.namespace, pod.Metadata.Name)
+		return "", err
+	}
+	id := r.namespace + "/" + pod.Metadata.Name
+	if len(syncs) > 0 {
+		r.mu.Lock()
+		r.pending[id] = syncs
+		r.mu.Unlock()
+	}
+	log.Debug("created pod", "pod", id, "syncs", len(syncs))
+	return id, nil
+}
+
+// create creates object in the runtime's namespace from its JSON manifest.
+func (r *KubernetesRuntime) create(ctx context.Context, kind, name string, object any) error {
+	manifest, err := json.Marshal(object)
+	if err != nil {
+		return fmt.Errorf("encoding %s: %w", kind, err)
+	}
+	cmd := r.command(ctx, "create", "--namespace", r.namespace, "--filename", "-", "--output", "name")
+	cmd.Stdin = bytes.NewReader(manifest)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("creating %s %s: %w: %s", kind, name, err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// deleteEnvSecret deletes the environment Secret of the named pod.
+// Failures are logged: a leftover Secret does not affect other runs.
+func (r *KubernetesRuntime) deleteEnvSecret(ctx context.Context, namespace, pod string) {
+	if _, err := r.run(ctx, "delete", "secret", "--namespace", namespace, k8sEnvSecretName(pod), "--ignore-not-found", "--wait=false"); err != nil {
+		log.Debug("deleting pod environment secret", "pod", namespace+"/"+pod, "error", err)
+	}
+}
+
+// k8sEnvSecretName returns the name of the Secret holding a pod's
+// environment.
+func k8sEnvSecretName(pod string) string {
+	return pod + "-env"
+}
+
+// buildEnvSecret returns the Secret holding cfg's environment. buildPod
+// references each variable from it by name.
+func buildEnvSecret(cfg Config) k8sSecret {
+	data := make(map[string]string, len(cfg.Env))
+	for _, kv := range cfg.Env {
+		k, v, _ := strings.Cut(kv, "=")
+		data[k] = v
+	}
+	return k8sSecret{
+		APIVersion: "v1",
+		Kind:       "Secret",
+		Metadata: k8sObjectMeta{
+			Name: k8sEnvSecretName(k8sPodName(cfg.Name)),
+			Labels: map[string]string{
+				k8sManagedByLabel: "moat",
+				k8sRunLabel:       cfg.Name,
+			},
+		},
+		Type:       "Opaque",
+		StringData: data,
+	}
+}
+
+// k8sSync is a bind mount copied into a pod: Source on the host is
+// extracted into the sync volume Volume.
+type k8sSync struct {
+	Source string
+	Volume string
+	Owner  int
+}
+
+// buildPod returns the pod for cfg and the bind mounts to copy into it.
+// Config.User is not applied: it exists to match bind mount ownership on
+// the host, and synced copies are owned by the image's user instead.
+func (r *KubernetesRuntime) buildPod(cfg Config) (k8sPod, []k8sSync, error) {
+	if len(cfg.Devices) > 0 {
+		return k8sPod{}, nil, fmt.Errorf("container.devices is not supported on the kubernetes runtime; use runtime: docker")
+	}
+	name := k8sPodName(cfg.Name)
+	owner := 0
+	if cfg.HasMoatUser {
+		owner = k8sMoatUserID
+	}
+
+	main := k8sContainer{
+		Name:       k8sMainContainer,
+		Image:      r.buildMgr.imageRef(cfg.Image),
+		Args:       cfg.Cmd,
+		WorkingDir: cfg.WorkingDir,
+		Stdin:      cfg.Interactive,
+		StdinOnce:  cfg.Interactive,
+		TTY:        cfg.Interactive,
+	}
+	secretName := k8sEnvSecretName(name)
+	seenEnv := make(map[string]bool, len(cfg.Env))
+	for _, kv := range cfg.Env {
+		k, _, _ := strings.Cut(kv, "=")
+		if seenEnv[k] {
+			continue
+		}
+		seenEnv[k] = true
+		main.Env = append(main.Env, k8sEnvVar{Name: k, ValueFrom: &k8sEnvVarSource{
+			SecretKeyRef: &k8sSecretKeyRef{Name: secretName, Key: k},
+		}})
+	}
+	if cfg.MemoryMB > 0 || cfg.CPUs > 0 {
+		main.Resources = &k8sResources{Limits: map[string]string{}}
+		if cfg.MemoryMB > 0 {
+			main.Resources.Limits["memory"] = strconv.Itoa(cfg.MemoryMB) + "Mi"
+		}
+		if cfg.CPUs > 0 {
+			main.Resources.Limits["cpu"] = strconv.Itoa(cfg.CPUs)
+		}
+	}
+	if cfg.Privileged || len(cfg.CapAdd) > 0 {
+		main.SecurityContext = &k8sSecurityContext{}
+		if cfg.Privileged {
+			main.SecurityContext.Privileged = &cfg.Privileged
+		}
+		if len(cfg.CapAdd) > 0 {
+			main.SecurityContext.Capabilities = &k8sCapabilities{Add: cfg.CapAdd}
+		}
+	}
+
+	noToken := false
+	spec := k8sPodSpec{
+		RestartPolicy: "Never",
+		// The agent must not act on the cluster with the namespace's
+		// service account.
+		AutomountServiceAccountToken: &noToken,
+	}
+
+	var syncs []k8sSync
+	syncMounts := []k8sVolumeMount{{Name: "moat-sync", MountPath: k8sSyncDir}}
+	for i, m := range cfg.Mounts {
+		if m.Volume {
+			return k8sPod{}, nil, errKubernetesVolumes
+		}
+		if rel, ok := k8sClaimPath(m.Target); ok && r.opts.Workspace == "pvc" {
+			main.VolumeMounts = append(main.VolumeMounts, k8sVolumeMount{Name: "workspace", MountPath: m.Target, SubPath: rel, ReadOnly: m.ReadOnly})
+			continue
+		}
+		info, err := os.Stat(m.Source)
+		if err != nil {
+			return k8sPod{}, nil, fmt.Errorf("mount %s: %w", m.Target, err)
+		}
+		vol := "mount-" + strconv.Itoa(i)
+		spec.Volumes = append(spec.Volumes, k8sVolume{Name: vol, EmptyDir: &k8sEmptyDir{}})
+		mount := k8sVolumeMount{Name: vol, MountPath: m.Target, ReadOnly: m.ReadOnly}
+		if !info.IsDir() {
+			// A file is copied into its own volume and mounted by name.
+			mount.SubPath = filepath.Base(m.Source)
+		}
+		main.VolumeMounts = append(main.VolumeMounts, mount)
+		syncMounts = append(syncMounts, k8sVolumeMount{Name: vol, MountPath: path.Join(k8sSyncDir, vol)})
+		syncs = append(syncs, k8sSync{Source: m.Source, Volume: vol, Owner: owner})
+	}
+	if r.opts.Workspace == "pvc" {
+		spec.Volumes = append(spec.Volumes, k8sVolume{Name: "workspace", PersistentVolumeClaim: &k8sPVCSource{ClaimName: r.opts.Claim}})
+	}
+	for i, t := range cfg.TmpfsMounts {
+		vol := "tmpfs-" + strconv.Itoa(i)
+		spec.Volumes = append(spec.Volumes, k8sVolume{Name: vol, EmptyDir: &k8sEmptyDir{Medium: "Memory"}})
+		main.VolumeMounts = append(main.VolumeMounts, k8sVolumeMount{Name: vol, MountPath: t.Target})
+	}
+
+	if len(syncs) > 0 {
+		spec.Volumes = append(spec.Volumes, k8sVolume{Name: "moat-sync", EmptyDir: &k8sEmptyDir{}})
+		spec.InitContainers = append(spec.InitContainers, k8sContainer{
+			Name:         k8sSyncContainer,
+			Image:        main.Image,
+			Command:      []string{"sh", "-c", "until [ -e " + k8sSyncDir + "/.ready ]; do sleep 1; done"},
+			VolumeMounts: syncMounts,
+			SecurityContext: &k8sSecurityContext{
+				RunAsUser: new(int64), // root, so extracted files keep their owner
+			},
+		})
+	}
+
+	if r.opts.ProxyPort > 0 {
+		proxyPort := proxyPortFromEnv(cfg.Env)
+		if proxyPort == 0 {
+			return k8sPod{}, nil, fmt.Errorf("kubernetes.proxy_port is set but the run has no proxy to forward")
+		}
+		target := r.opts.ProxyHost
+		sidecar := k8sContainer{
+			Name:  k8sProxyContainer,
+			Image: r.opts.SidecarImage,
+			// A native sidecar: it starts before the main container and
+			// does not keep the pod running after the main container exits.
+			RestartPolicy: "Always",
+		}
+		if target == "" {
+			target = "$(NODE_IP)"
+			sidecar.Env = []k8sEnvVar{{Name: "NODE_IP", ValueFrom: &k8sEnvVarSource{FieldRef: &k8sFieldRef{FieldPath: "status.hostIP"}}}}
+		}
+		sidecar.Args = []string{
+			fmt.Sprintf("tcp-listen:%d,bind=127.0.0.1,fork,reuseaddr", proxyPort),
+			fmt.Sprintf("tcp-connect:%s:%d", target, r.opts.ProxyPort),
+		}
+		spec.InitContainers = append(spec.InitContainers, sidecar)
+	}
+
+	for _, h := range cfg.ExtraHosts {
+		host, ip, ok := strings.Cut(h, ":")
+		// host-gateway entries have no meaning on a cluster; the proxy
+		// address comes from GetHostAddress.
+		if ok && ip != "host-gateway" {
+			spec.HostAliases = append(spec.HostAliases, k8sHostAlias{IP: ip, Hostnames: []string{host}})
+		}
+	}
+	if len(cfg.PortBindings) > 0 {
+		log.Debug("kubernetes runtime does not publish ports; use kubectl port-forward", "pod", name)
+	}
+
+	spec.Containers = []k8sContainer{main}
+	pod := k8sPod{
+		APIVersion: "v1",
+		Kind:       "Pod",
+		Metadata: k8sObjectMeta{
+			Name: name,
+			Labels: map[string]string{
+				k8sManagedByLabel: "moat",
+				k8sRunLabel:       cfg.Name,
+			},
+		},
+		Spec: spec,
+	}
+	return pod, syncs, nil
+}
+
+// k8sPodName turns a container name (a run ID like run_1a2b3c4d5e6f) into a
+// valid pod name.
+func k8sPodName(name string) string {
+	return strings.ReplaceAll(strings.ToLower(name), "_", "-")
+}
+
+// k8sClaimPath reports whether target is the workspace or under it, and
+// its path within the workspace claim.
+func k8sClaimPath(target string) (string, bool) {
+	if target == k8sWorkspaceTarget {
+		return "", true
+	}
+	rel, ok := strings.CutPrefix(target, k8sWorkspaceTarget+"/")
+	return rel, ok
+}
+
+// proxyPortFromEnv returns the port of the run's HTTP_PROXY, or 0.
+func proxyPortFromEnv(env []string) int {
+	for _, kv := range env {
+		if v, ok := strings.CutPrefix(kv, "HTTP_PROXY="); ok {
+			u, err := url.Parse(v)
+			if err != nil {
+				return 0
+			}
+			port, _ := strconv.Atoi(u.Port())
+			return port
+		}
+	}
+	return 0
+}
+
+// splitPodID splits a container ID into namespace and pod name.
+func splitPodID(id string) (namespace, name string, err error) {
+	namespace, name, ok := strings.Cut(id, "/")
+	if !ok || namespace == "" || name == "" {
+		return "", "", fmt.Errorf("invalid kubernetes container ID %q (want <namespace>/<pod>)", id)
+	}
+	return namespace, name, nil
+}
+
+// podArgs returns kubectl arguments naming the pod of id, after verb.
+func podArgs(id string, verb string, extra ...string) ([]string, error) {
+	namespace, name, err := splitPodID(id)
+	if err != nil {
+		return nil, err
+	}
+	return append([]string{verb, "--namespace", namespace, name}, extra...), nil
+}
+
+// getPod fetches the pod of id.
+func (r *KubernetesRuntime) getPod(ctx context.Context, id string) (k8sPodInfo, error) {
+	args, err := podArgs(id, "get", "--output", "json")
+	if err != nil {
+		return k8sPodInfo{}, err
+	}
+	out, err := r.run(ctx, args...)
+	if err != nil {
+		return k8sPodInfo{}, err
+	}
+	var pod k8sPodInfo
+	if err := json.Unmarshal(out, &pod); err != nil {
+		return k8sPodInfo{}, fmt.Errorf("parsing pod %s: %w", id, err)
+	}
+	return pod, nil
+}
+
+// StartContainer copies the bind mounts into the pod, releases the sync
+// init container, and waits for the main container to start.
+func (r *KubernetesRuntime) StartContainer(ctx context.Context, id string) error {
+	ctx, cancel := context.WithTimeout(ctx, k8sStartTimeout)
+	defer cancel()
+
+	r.mu.Lock()
+	syncs := r.pending[id]
+	r.mu.Unlock()
+	if len(syncs) > 0 {
+		if _, err := r.waitPod(ctx, id, func(p k8sPodInfo) bool {
+			return p.containerState(k8sSyncContainer, true).Running != nil
+		}); err != nil {
+			return err
+		}
+		for _, s := range syncs {
+			if err := r.copyIn(ctx, id, s); err != nil {
+				return err
+			}
+		}
+		args, _ := podArgs(id, "exec", "--container", k8sSyncContainer, "--", "touch", k8sSyncDir+"/.ready")
+		if _, err := r.run(ctx, args...); err != nil {
+			return fmt.Errorf("releasing pod %s: %w", id, err)
+		}
+		r.mu.Lock()
+		delete(r.pending, id)
+		r.mu.Unlock()
+	}
+
+	_, err := r.waitPod(ctx, id, func(p k8sPodInfo) bool {
+		st := p.containerState(k8sMainContainer, false)
+		return st.Running != nil || st.Terminated != nil
+	})
+	return err
+}
+
+// copyIn extracts a tar of s.Source into its sync volume in the pod.
+func (r *KubernetesRuntime) copyIn(ctx context.Context, id string, s k8sSync) error {
+	args, err := podArgs(id, "exec", "--stdin", "--container", k8sSyncContainer, "--", "tar", "-x", "-C", path.Join(k8sSyncDir, s.Volume))
+	if err != nil {
+		return err
+	}
+	cmd := r.command(ctx, args...)
+	pr, pw := io.Pipe()
+	cmd.Stdin = pr
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	go func() {
+		pw.CloseWithError(writeSyncTar(pw, s.Source, s.Owner))
+	}()
+	if err := cmd.Run(); err != nil {
+		pr.CloseWithError(err)
+		return fmt.Errorf("copying %s into pod %s: %w: %s", s.Source, id, err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// writeSyncTar writes source as a tar stream: a directory's contents, or
+// a file under its base name. Entries are owned by owner. Sockets, devices,
+// and other special files are skipped.
+func writeSyncTar(w io.Writer, source string, owner int) error {
+	tw := tar.NewWriter(w)
+	info, err := os.Stat(source)
+	if err != nil {
+		return err
+	}
+	root, prefix := source, ""
+	if !info.IsDir() {
+		root, prefix = filepath.Dir(source), filepath.Base(source)
+	}
+	walkRoot := filepath.Join(root, prefix)
+	err = filepath.WalkDir(walkRoot, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil || rel == "." {
+			return err
+		}
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+		var link string
+		switch {
+		case fi.Mode()&fs.ModeSymlink != 0:
+			if link, err = os.Readlink(p); err != nil {
+				return err
+			}
+		case !fi.Mode().IsRegular() && !fi.IsDir():
+			return nil
+		}
+		hdr, err := tar.FileInfoHeader(fi, link)
+		if err != nil {
+			return err
+		}
+		hdr.Name = filepath.ToSlash(rel)
+		hdr.Uid, hdr.Gid = owner, owner
+		hdr.Uname, hdr.Gname = "", ""
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if !fi.Mode().IsRegular() {
+			return nil
+		}
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+// waitPod polls the pod of id until done reports true. It fails early when
+// the pod cannot start, e.g. because an image cannot be pulled.
+func (r *KubernetesRuntime) waitPod(ctx context.Context, id string, done func(k8sPodInfo) bool) (k8sPodInfo, error) {
+	for {
+		pod, err := r.getPod(ctx, id)
+		if err != nil {
+			return pod, err
+		}
+		if done(pod) {
+			return pod, nil
+		}
+		if reason := pod.startFailure(); reason != "" {
+			return pod, fmt.Errorf("pod %s cannot start: %s", id, reason)
+		}
+		select {
+		case <-ctx.Done():
+			return pod, fmt.Errorf("waiting for pod %s (phase %s): %w", id, pod.Status.Phase, ctx.Err())
+		case <-time.After(k8sPollInterval):
+		}
+	}
+}
+
+// StopContainer signals the main container's PID 1 to stop. Kubernetes
+// cannot stop a container without deleting its pod, which would lose its
+// logs and exit code.
+func (r *KubernetesRuntime) StopContainer(ctx context.Context, id string) error {
+	args, err := podArgs(id, "exec", "--container", k8sMainContainer, "--", "kill", "-TERM", "1")
+	if err != nil {
+		return err
+	}
+	if _, err := r.run(ctx, args...); err != nil {
+		// The container is not running (or the pod is gone).
+		log.Debug("stopping pod", "pod", id, "error", err)
+		return nil
+	}
+	waitCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	if _, err := r.WaitContainer(waitCtx, id); err != nil {
+		log.Debug("pod did not stop, deleting it", "pod", id, "error", err)
+		return r.RemoveContainer(ctx, id)
+	}
+	return nil
+}
+
+// WaitContainer blocks until the main container exits and returns its exit
+// code.
+func (r *KubernetesRuntime) WaitContainer(ctx context.Context, id string) (int64, error) {
+	for {
+		pod, err := r.getPod(ctx, id)
+		if err != nil {
+			return -1, err
+		}
+		if t := pod.containerState(k8sMainContainer, false).Terminated; t != nil {
+			return t.ExitCode, nil
+		}
+		if pod.Status.Phase == "Failed" {
+			return -1, fmt.Errorf("pod %s failed: %s", id, pod.Status.Reason)
+		}
+		select {
+		case <-ctx.Done():
+			return -1, ctx.Err()
+		case <-time.After(k8sPollInterval):
+		}
+	}
+}
+
+// RemoveContainer deletes the pod and its environment Secret.
+func (r *KubernetesRuntime) RemoveContainer(ctx context.Context, id string) error {
+	r.mu.Lock()
+	delete(r.pending, id)
+	r.mu.Unlock()
+	namespace, name, err := splitPodID(id)
+	if err != nil {
+		return err
+	}
+	args, _ := podArgs(id, "delete", "--ignore-not-found", "--wait=false")
+	_, err = r.run(ctx, args...)
+	r.deleteEnvSecret(ctx, namespace, name)
+	return err
+}
+
+// ContainerLogs follows the main container's logs.
+func (r *KubernetesRuntime) ContainerLogs(ctx context.Context, id string) (io.ReadCloser, error) {
+	args, err := podArgs(id, "logs", "--follow", "--container", k8sMainContainer)
+	if err != nil {
+		return nil, err
+	}
+	cmd := r.command(ctx, args...)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("getting stdout pipe: %w", err)
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		stdout.Close()
+		return nil, fmt.Errorf("getting stderr pipe: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		stdout.Close()
+		stderr.Close()
+		return nil, fmt.Errorf("starting logs command: %w", err)
+	}
+	return &combinedReadCloser{
+		readers: []io.Reader{stdout, stderr},
+		cmd:     cmd,
+		stdout:  stdout,
+		stderr:  stderr,
+	}, nil
+}
+
+// ContainerLogsAll returns the main container's logs.
+func (r *KubernetesRuntime) ContainerLogsAll(ctx context.Context, id string) ([]byte, error) {
+	args, err := podArgs(id, "logs", "--container", k8sMainContainer)
+	if err != nil {
+		return nil, err
+	}
+	return r.run(ctx, args...)
+}
+
+// GetPortBindings returns no bindings: pods publish no ports to the host.
+func (r *KubernetesRuntime) GetPortBindings(ctx context.Context, id string) (map[int]int, error) {
+	return map[int]int{}, nil
+}
+
+// GetHostAddress returns the address pods reach the proxy at: loopback when
+// the proxy sidecar forwards it, otherwise the configured proxy host.
+func (r *KubernetesRuntime) GetHostAddress() string {
+	if r.opts.ProxyPort > 0 {
+		return "127.0.0.1"
+	}
+	return r.opts.ProxyHost
+}
+
+// SupportsHostNetwork returns false.
+func (r *KubernetesRuntime) SupportsHostNetwork() bool {
+	return false
+}
+
+// UserNamespaceMode returns UsernsNone; user namespaces are the cluster's
+// business.
+func (r *KubernetesRuntime) UserNamespaceMode(ctx context.Context) (string, error) {
+	return UsernsNone, nil
+}
+
+// NetworkManager returns nil; pods share the cluster network.
+func (r *KubernetesRuntime) NetworkManager() NetworkManager { return nil }
+
+// SidecarManager returns nil.
+func (r *KubernetesRuntime) SidecarManager() SidecarManager { return nil }
+
+// BuildManager returns the build manager, which builds with the local
+// docker CLI and pushes to the configured registry.
+func (r *KubernetesRuntime) BuildManager() BuildManager { return r.buildMgr }
+
+// ServiceManager returns nil.
+func (r *KubernetesRuntime) ServiceManager() ServiceManager { return nil }
+
+// Close releases nothing; every call is a kubectl process.
+func (r *KubernetesRuntime) Close() error { return nil }
+
+// SetupFirewall installs the same rules as the Docker runtime. With the
+// proxy sidecar, which shares the pod's network namespace, traffic to the
+// NodePort is allowed too.
+func (r *KubernetesRuntime) SetupFirewall(ctx context.Context, id string, proxyHost string, proxyPort int) error {
+	if proxyPort < 1 || proxyPort > 65535 {
+		return fmt.Errorf("invalid proxy port %d: must be between 1 and 65535", proxyPort)
+	}
+	script := firewallScript(proxyPort)
+	if r.opts.ProxyPort > 0 && r.opts.ProxyPort != proxyPort {
+		script += fmt.Sprintf("\niptables -w -I OUTPUT 1 -p tcp --dport %d -j ACCEPT\n", r.opts.ProxyPort)
+	}
+	var stderr bytes.Buffer
+	if err := r.Exec(ctx, id, []string{"sh", "-c", script}, nil, io.Discard, &stderr); err != nil {
+		return fmt.Errorf("firewall setup failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// RestrictServiceAccess returns an error: the kubernetes runtime does not
+// run service containers.
+func (r *KubernetesRuntime) RestrictServiceAccess(ctx context.Context, id string, rules []ServiceAccess) error {
+	return fmt.Errorf("service network policies are not supported by the kubernetes runtime")
+}
+
+// ListImages returns nothing: images live in the registry, not on the
+// cluster.
+func (r *KubernetesRuntime) ListImages(ctx context.Context) ([]ImageInfo, error) {
+	return nil, nil
+}
+
+// ListContainers returns the moat pods in the namespace.
+func (r *KubernetesRuntime) ListContainers(ctx context.Context) ([]Info, error) {
+	out, err := r.run(ctx, "get", "pods", "--namespace", r.namespace, "--selector", k8sManagedByLabel+"=moat", "--output", "json")
+	if err != nil {
+		return nil, fmt.Errorf("listing pods: %w", err)
+	}
+	var list struct {
+		Items []k8sPodInfo `json:"items"`
+	}
+	if err := json.Unmarshal(out, &list); err != nil {
+		return nil, fmt.Errorf("parsing pod list: %w", err)
+	}
+	var result []Info
+	for _, pod := range list.Items {
+		info := Info{
+			ID:      pod.Metadata.Namespace + "/" + pod.Metadata.Name,
+			Name:    pod.Metadata.Labels[k8sRunLabel],
+			Status:  pod.state(),
+			Created: pod.Metadata.CreationTimestamp,
+		}
+		for _, c := range pod.Spec.Containers {
+			if c.Name == k8sMainContainer {
+				info.Image = c.Image
+			}
+		}
+		result = append(result, info)
+	}
+	return result, nil
+}
+
+// ContainerState returns "running", "exited", or "created".
+func (r *KubernetesRuntime) ContainerState(ctx context.Context, id string) (string, error) {
+	pod, err := r.getPod(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	return pod.state(), nil
+}
+
+// ContainerOOMKilled reports whether the main container was OOM killed.
+func (r *KubernetesRuntime) ContainerOOMKilled(ctx context.Context, id string) (bool, error) {
+	pod, err := r.getPod(ctx, id)
+	if err != nil {
+		return false, err
+	}
+	t := pod.containerState(k8sMainContainer, false).Terminated
+	return t != nil && t.Reason == "OOMKilled", nil
+}
+
+// UpdatePriority returns ErrPriorityUnsupported; use a PriorityClass.
+func (r *KubernetesRuntime) UpdatePriority(ctx context.Context, id string, p Priority) error {
+	return ErrPriorityUnsupported
+}
+
+// ContainerStats returns ErrStatsUnsupported.
+func (r *KubernetesRuntime) ContainerStats(ctx context.Context, id string) (Stats, error) {
+	return Stats{}, ErrStatsUnsupported
+}
+
+// RemoveImage removes the local copy of a built image. The registry copy
+// is replaced by the next push.
+func (r *KubernetesRuntime) RemoveImage(ctx context.Context, id string) error {
+	return r.buildMgr.removeLocal(ctx, id)
+}
+
+// StartAttached starts the pod and attaches to the main container. Output
+// the process writes before kubectl attaches is not shown.
+func (r *KubernetesRuntime) StartAttached(ctx context.Context, id string, opts AttachOptions) error {
+	if err := r.StartContainer(ctx, id); err != nil {
+		return err
+	}
+	extra := []string{"--quiet", "--container", k8sMainContainer}
+	if opts.Stdin != nil {
+		extra = append(extra, "--stdin")
+	}
+	if opts.TTY {
+		extra = append(extra, "--tty")
+	}
+	args, err := podArgs(id, "attach", extra...)
+	if err != nil {
+		return err
+	}
+	cmd := r.command(ctx, args...)
+	if !opts.TTY {
+		cmd.Stdin = opts.Stdin
+		cmd.Stdout = opts.Stdout
+		cmd.Stderr = opts.Stderr
+		if err := cmd.Run(); err != nil && ctx.Err() == nil {
+			return fmt.Errorf("attaching to pod %s: %w", id, err)
+		}
+		return ctx.Err()
+	}
+	width, height := opts.InitialWidth, opts.InitialHeight
+	if (width == 0 || height == 0) && term.IsTerminal(os.Stdout) {
+		if w, h := term.GetSize(os.Stdout); w > 0 && h > 0 {
+			width, height = uint(w), uint(h) // #nosec G115 -- validated positive
+		}
+	}
+	stdout := opts.Stdout
+	if stdout == nil {
+		stdout = os.Stdout
+	}
+	return r.runPTY(ctx, cmd, id, opts.Stdin, stdout, width, height, nil)
+}
+
+// ResizeTTY resizes the PTY of an attached pod.
+func (r *KubernetesRuntime) ResizeTTY(ctx context.Context, id string, height, width uint) error {
+	r.ptyMu.Lock()
+	ptmx := r.activePTY[id]
+	r.ptyMu.Unlock()
+	if ptmx == nil {
+		return nil
+	}
+	return pty.Setsize(ptmx, &pty.Winsize{
+		Rows: uint16(height), // #nosec G115
+		Cols: uint16(width),  // #nosec G115
+	})
+}
+
+// runPTY runs cmd on a PTY tracked under key, copying stdin in and output
+// to stdout. An error from copying stdin (such as a detach escape) ends the
+// command and is returned.
+func (r *KubernetesRuntime) runPTY(ctx context.Context, cmd *exec.Cmd, key string, stdin io.Reader, stdout io.Writer, width, height uint, resize <-chan TTYSize) error {
+	ptmx, err := pty.Start(cmd)
+	if err != nil {
+		return fmt.Errorf("starting kubectl with pty: %w", err)
+	}
+	defer func() { _ = ptmx.Close() }()
+
+	if key != "" {
+		r.ptyMu.Lock()
+		if r.activePTY == nil {
+			r.activePTY = make(map[string]*os.File)
+		}
+		r.activePTY[key] = ptmx
+		r.ptyMu.Unlock()
+		defer func() {
+			r.ptyMu.Lock()
+			delete(r.activePTY, key)
+			r.ptyMu.Unlock()
+		}()
+	}
+	if width > 0 && height > 0 {
+		_ = pty.Setsize(ptmx, &pty.Winsize{
+			Rows: uint16(height), // #nosec G115
+			Cols: uint16(width),  // #nosec G115
+		})
+	}
+	if resize != nil {
+		go func() {
+			for size := range resize {
+				if size.Width == 0 || size.Height == 0 {
+					continue
+				}
+				_ = pty.Setsize(ptmx, &pty.Winsize{
+					Rows: uint16(size.Height), // #nosec G115
+					Cols: uint16(size.Width),  // #nosec G115
+				})
+			}
+		}()
+	}
+
+	stdinErr := make(chan error, 1)
+	if stdin != nil {
+		go func() {
+			_, err := io.Copy(ptmx, stdin)
+			stdinErr <- err
+		}()
+	}
+	outputDone := make(chan struct{})
+	go func() {
+		defer close(outputDone)
+		_, _ = io.Copy(stdout, ptmx)
+	}()
+	cmdDone := make(chan error, 1)
+	go func() { cmdDone <- cmd.Wait() }()
+
+	var result error
+	select {
+	case err := <-stdinErr:
+		if err == nil {
+			// Stdin closed; keep the session until the command ends.
+			result = exitErrorOrNil(<-cmdDone)
+			break
+		}
+		_ = ptmx.Close()
+		if cmd.Process != nil {
+			_ = cmd.Process.Kill()
+		}
+		<-cmdDone
+		result = err
+	case err := <-cmdDone:
+		result = exitErrorOrNil(err)
+	case <-ctx.Done():
+		_ = ptmx.Close()
+		if cmd.Process != nil {
+			_ = cmd.Process.Kill()
+		}
+		<-cmdDone
+		return ctx.Err()
+	}
+	// Drain output briefly so nothing is lost on fast exit.
+	select {
+	case <-outputDone:
+	case <-time.After(2 * time.Second):
+	}
+	return result
+}
+
+// exitErrorOrNil is exitError for a non-nil error.
+func exitErrorOrNil(err error) error {
+	if err == nil {
+		return nil
+	}
+	return exitError(err)
+}
+
+// Exec runs a command in the main container.
+func (r *KubernetesRuntime) Exec(ctx context.Context, id string, cmd []string, stdin []byte, stdout, stderr io.Writer) error {
+	extra := []string{"--container", k8sMainContainer}
+	if len(stdin) > 0 {
+		extra = append(extra, "--stdin")
+	}
+	args, err := podArgs(id, "exec", append(extra, append([]string{"--"}, cmd...)...)...)
+	if err != nil {
+		return err
+	}
+	c := r.command(ctx, args...)
+	if len(stdin) > 0 {
+		c.Stdin = bytes.NewReader(stdin)
+	}
+	c.Stdout = stdout
+	c.Stderr = stderr
+	return exitErrorOrNil(c.Run())
+}
+
+// ExecInteractive runs a command in the main container with a PTY.
+func (r *KubernetesRuntime) ExecInteractive(ctx context.Context, id string, cmd []string, opts ExecOptions) error {
+	extra := []string{"--stdin", "--container", k8sMainContainer}
+	if opts.TTY {
+		extra = append(extra, "--tty")
+	}
+	args, err := podArgs(id, "exec", append(extra, append([]string{"--"}, cmd...)...)...)
+	if err != nil {
+		return err
+	}
+	c := r.command(ctx, args...)
+	if !opts.TTY {
+		c.Stdin = opts.Stdin
+		c.Stdout = opts.Stdout
+		c.Stderr = opts.Stderr
+		return exitErrorOrNil(c.Run())
+	}
+	return r.runPTY(ctx, c, "", opts.Stdin, opts.Stdout, opts.InitialWidth, opts.InitialHeight, opts.Resize)
+}
+
+// VolumeCreate returns errKubernetesVolumes.
+func (r *KubernetesRuntime) VolumeCreate(ctx context.Context, name string) error {
+	return errKubernetesVolumes
+}
+
+// VolumeRemove returns errKubernetesVolumes.
+func (r *KubernetesRuntime) VolumeRemove(ctx context.Context, name string, force bool) error {
+	return errKubernetesVolumes
+}
+
+// VolumeList returns no volumes.
+func (r *KubernetesRuntime) VolumeList(ctx context.Context, prefix string) ([]string, error) {
+	return nil, nil
+}
+
+// VolumeExport returns errKubernetesVolumes.
+func (r *KubernetesRuntime) VolumeExport(ctx context.Context, name, hostDir string) error {
+	return errKubernetesVolumes
+}
+
+// KubernetesPodRunning reports whether the main container of the pod with
+// container ID id is running, for liveness checks outside a runtime.
+// kubeContext is the kubectl context; empty uses the current one.
+func KubernetesPodRunning(ctx context.Context, kubeContext, id string) (bool, error) {
+	r := &KubernetesRuntime{opts: KubernetesOptions{Context: kubeContext}, kubectl: "kubectl"}
+	pod, err := r.getPod(ctx, id)
+	if err != nil {
+		return false, err
+	}
+	if pod.Status.Phase == "Pending" {
+		// Still syncing or pulling; the run has not started.
+		return true, nil
+	}
+	return pod.containerState(k8sMainContainer, false).Running != nil, nil
+}
+
+// kubernetesBuildManager builds images with the local docker CLI and pushes
+// them to the registry the cluster pulls from.
+type kubernetesBuildManager struct {
+	registry string
+}
+
+// imageRef returns the reference the cluster pulls tag by: images moat
+// builds (moat/...) are prefixed with the registry; others are public
+// images used as is.
+func (m *kubernetesBuildManager) imageRef(tag string) string {
+	if m.registry != "" && strings.HasPrefix(tag, "moat/") {
+		return strings.TrimSuffix(m.registry, "/") + "/" + tag
+	}
+	return tag
+}
+
+// docker runs the docker CLI, folding stderr into the error.
+func (m *kubernetesBuildManager) docker(ctx context.Context, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "docker", args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return out, fmt.Errorf("docker %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+// BuildImage builds the image with docker and pushes it to the registry.
+func (m *kubernetesBuildManager) BuildImage(ctx context.Context, dockerfile string, tag string, opts BuildOptions) error {
+	if m.registry == "" {
+		return fmt.Errorf("the kubernetes runtime needs a registry to push built images to: set kubernetes.registry in ~/.moat/config.yaml")
+	}
+	if _, err := exec.LookPath("docker"); err != nil {
+		return fmt.Errorf("building images for the kubernetes runtime needs the docker CLI: %w", err)
+	}
+	dir, err := os.MkdirTemp("", "moat-build-*")
+	if err != nil {
+		return fmt.Errorf("creating build context: %w", err)
+	}
+	defer os.RemoveAll(dir)
+	if err := os.WriteFile(filepath.Join(dir, "Dockerfile"), []byte(dockerfile), 0o644); err != nil {
+		return fmt.Errorf("writing Dockerfile: %w", err)
+	}
+	for name, content := range opts.ContextFiles {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			return fmt.Errorf("writing build context: %w", err)
+		}
+		if err := os.WriteFile(p, content, 0o644); err != nil {
+			return fmt.Errorf("writing build context: %w", err)
+		}
+	}
+
+	ref := m.imageRef(tag)
+	args := []string{"build", "--tag", ref}
+	if opts.NoCache {
+		args = append(args, "--no-cache")
+	}
+	build := exec.CommandContext(ctx, "docker", append(args, dir)...)
+	build.Stdout, build.Stderr = os.Stderr, os.Stderr
+	if err := build.Run(); err != nil {
+		return fmt.Errorf("docker build: %w", err)
+	}
+	push := exec.CommandContext(ctx, "docker", "push", ref)
+	push.Stdout, push.Stderr = os.Stderr, os.Stderr
+	if err := push.Run(); err != nil {
+		return fmt.Errorf("pushing %s: %w", ref, err)
+	}
+	return nil
+}
+
+// ImageExists reports whether a built image is in the registry. Other
+// images are pulled by the cluster, so they always exist as far as moat is
+// concerned.
+func (m *kubernetesBuildManager) ImageExists(ctx context.Context, tag string) (bool, error) {
+	ref := m.imageRef(tag)
+	if ref == tag {
+		return true, nil
+	}
+	if _, err := m.docker(ctx, "manifest", "inspect", ref); err != nil {
+		return false, nil
+	}
+	return true, nil
+}
+
+// GetImageHomeDir inspects the image with docker, pulling it if needed.
+// Returns "/root" if detection fails or no home is configured.
+func (m *kubernetesBuildManager) GetImageHomeDir(ctx context.Context, imageName string) string {
+	const defaultHome = "/root"
+	ref := m.imageRef(imageName)
+	out, err := m.docker(ctx, "image", "inspect", "--format", "{{json .Config}}", ref)
+	if err != nil {
+		if _, pullErr := m.docker(ctx, "pull", ref); pullErr != nil {
+			return defaultHome
+		}
+		if out, err = m.docker(ctx, "image", "inspect", "--format", "{{json .Config}}", ref); err != nil {
+			return defaultHome
+		}
+	}
+	var cfg struct {
+		User string   `json:"User"`
+		Env  []string `json:"Env"`
+	}
+	if err := json.Unmarshal(out, &cfg); err != nil {
+		return defaultHome
+	}
+	for _, env := range cfg.Env {
+		if home, ok := strings.CutPrefix(env, "HOME="); ok {
+			return home
+		}
+	}
+	user, _, _ := strings.Cut(cfg.User, ":")
+	if user == "" || user == "root" || !isValidUsername(user) {
+		return defaultHome
+	}
+	if _, err := strconv.Atoi(user); err == nil {
+		return defaultHome
+	}
+	return "/home/" + user
+}
+
+// removeLocal removes the local copy of a built image.
+func (m *kubernetesBuildManager) removeLocal(ctx context.Context, tag string) error {
+	_, err := m.docker(ctx, "image", "rm", m.imageRef(tag))
+	return err
+}
@@ -0,0 +1,216 @@
+package container
+
+import (
+	"strings"
+	"time"
+)
+
+// The subset of the Kubernetes Pod and Secret APIs the kubernetes runtime
+// writes and reads through kubectl's JSON.
+
+type k8sPod struct {
+	APIVersion string        `json:"apiVersion"`
+	Kind       string        `json:"kind"`
+	Metadata   k8sObjectMeta `json:"metadata"`
+	Spec       k8sPodSpec    `json:"spec"`
+}
+
+type k8sSecret struct {
+	APIVersion string            `json:"apiVersion"`
+	Kind       string            `json:"kind"`
+	Metadata   k8sObjectMeta     `json:"metadata"`
+	Type       string            `json:"type"`
+	StringData map[string]string `json:"stringData"`
+}
+
+type k8sObjectMeta struct {
+	Name   string            `json:"name"`
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+type k8sPodSpec struct {
+	RestartPolicy                string         `json:"restartPolicy"`
+	AutomountServiceAccountToken *bool          `json:"automountServiceAccountToken,omitempty"`
+	InitContainers               []k8sContainer `json:"initContainers,omitempty"`
+	Containers                   []k8sContainer `json:"containers"`
+	Volumes                      []k8sVolume    `json:"volumes,omitempty"`
+	HostAliases                  []k8sHostAlias `json:"hostAliases,omitempty"`
+}
+
+type k8sContainer struct {
+	Name            string              `json:"name"`
+	Image           string              `json:"image"`
+	Command         []string            `json:"command,omitempty"`
+	Args            []string            `json:"args,omitempty"`
+	WorkingDir      string              `json:"workingDir,omitempty"`
+	Env             []k8sEnvVar         `json:"env,omitempty"`
+	VolumeMounts    []k8sVolumeMount    `json:"volumeMounts,omitempty"`
+	Resources       *k8sResources       `json:"resources,omitempty"`
+	SecurityContext *k8sSecurityContext `json:"securityContext,omitempty"`
+	RestartPolicy   string              `json:"restartPolicy,omitempty"`
+	Stdin           bool                `json:"stdin,omitempty"`
+	StdinOnce       bool                `json:"stdinOnce,omitempty"`
+	TTY             bool                `json:"tty,omitempty"`
+}
+
+type k8sEnvVar struct {
+	Name      string           `json:"name"`
+	Value     string           `json:"value,omitempty"`
+	ValueFrom *k8sEnvVarSource `json:"valueFrom,omitempty"`
+}
+
+type k8sEnvVarSource struct {
+	FieldRef     *k8sFieldRef     `json:"fieldRef,omitempty"`
+	SecretKeyRef *k8sSecretKeyRef `json:"secretKeyRef,omitempty"`
+}
+
+type k8sSecretKeyRef struct {
+	Name string `json:"name"`
+	Key  string `json:"key"`
+}
+
+type k8sFieldRef struct {
+	FieldPath string `json:"fieldPath"`
+}
+
+type k8sVolumeMount struct {
+	Name      string `json:"name"`
+	MountPath string `json:"mountPath"`
+	SubPath   string `json:"subPath,omitempty"`
+	ReadOnly  bool   `json:"readOnly,omitempty"`
+}
+
+type k8sResources struct {
+	Limits map[string]string `json:"limits,omitempty"`
+}
+
+type k8sSecurityContext struct {
+	RunAsUser    *int64           `json:"runAsUser,omitempty"`
+	Privileged   *bool            `json:"privileged,omitempty"`
+	Capabilities *k8sCapabilities `json:"capabilities,omitempty"`
+}
+
+type k8sCapabilities struct {
+	Add []string `json:"add,omitempty"`
+}
+
+type k8sVolume struct {
+	Name                  string        `json:"name"`
+	EmptyDir              *k8sEmptyDir  `json:"emptyDir,omitempty"`
+	PersistentVolumeClaim *k8sPVCSource `json:"persistentVolumeClaim,omitempty"`
+}
+
+type k8sEmptyDir struct {
+	Medium string `json:"medium,omitempty"`
+}
+
+type k8sPVCSource struct {
+	ClaimName string `json:"claimName"`
+}
+
+type k8sHostAlias struct {
+	IP        string   `json:"ip"`
+	Hostnames []string `json:"hostnames"`
+}
+
+// k8sPodInfo is a pod as kubectl get reports it.
+type k8sPodInfo struct {
+	Metadata struct {
+		Name              string            `json:"name"`
+		Namespace         string            `json:"namespace"`
+		Labels            map[string]string `json:"labels"`
+		CreationTimestamp time.Time         `json:"creationTimestamp"`
+	} `json:"metadata"`
+	Spec struct {
+		Containers []struct {
+			Name  string `json:"name"`
+			Image string `json:"image"`
+		} `json:"containers"`
+	} `json:"spec"`
+	Status struct {
+		Phase                 string               `json:"phase"`
+		Reason                string               `json:"reason"`
+		InitContainerStatuses []k8sContainerStatus `json:"initContainerStatuses"`
+		ContainerStatuses     []k8sContainerStatus `json:"containerStatuses"`
+	} `json:"status"`
+}
+
+type k8sContainerStatus struct {
+	Name  string            `json:"name"`
+	State k8sContainerState `json:"state"`
+}
+
+type k8sContainerState struct {
+	Waiting *struct {
+		Reason  string `json:"reason"`
+		Message string `json:"message"`
+	} `json:"waiting"`
+	Running *struct {
+		StartedAt time.Time `json:"startedAt"`
+	} `json:"running"`
+	Terminated *struct {
+		ExitCode int64  `json:"exitCode"`
+		Reason   string `json:"reason"`
+	} `json:"terminated"`
+}
+
+// containerState returns the state of the named container, or an empty
+// state before the kubelet reports it.
+func (p k8sPodInfo) containerState(name string, init bool) k8sContainerState {
+	statuses := p.Status.ContainerStatuses
+	if init {
+		statuses = p.Status.InitContainerStatuses
+	}
+	for _, s := range statuses {
+		if s.Name == name {
+			return s.State
+		}
+	}
+	return k8sContainerState{}
+}
+
+// state maps the main container's state onto the runtime's container
+// states: "running", "exited", or "created".
+func (p k8sPodInfo) state() string {
+	st := p.containerState(k8sMainContainer, false)
+	switch {
+	case st.Running != nil:
+		return "running"
+	case st.Terminated != nil, p.Status.Phase == "Failed", p.Status.Phase == "Succeeded":
+		return "exited"
+	}
+	return "created"
+}
+
+// k8sStartFailures are waiting reasons a pod does not recover from
+// without changes.
+var k8sStartFailures = []string{
+	"ErrImagePull",
+	"ImagePullBackOff",
+	"InvalidImageName",
+	"CreateContainerConfigError",
+	"CreateContainerError",
+}
+
+// startFailure returns why the pod cannot start, or "" while it still
+// might.
+func (p k8sPodInfo) startFailure() string {
+	if p.Status.Phase == "Failed" {
+		if p.Status.Reason != "" {
+			return p.Status.Reason
+		}
+		return "pod failed"
+	}
+	for _, statuses := range [][]k8sContainerStatus{p.Status.InitContainerStatuses, p.Status.ContainerStatuses} {
+		for _, s := range statuses {
+			if w := s.State.Waiting; w != nil {
+				for _, reason := range k8sStartFailures {
+					if w.Reason == reason {
+						return strings.TrimSpace(s.Name + ": " + w.Reason + " " + w.Message)
+					}
+				}
+			}
+		}
+	}
+	return ""
+}
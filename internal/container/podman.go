@@ -0,0 +1,155 @@
+package container
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/client"
+)
+
+// errPodmanNotFound is returned when no Podman API socket can be found.
+var errPodmanNotFound = errors.New("no Podman API socket found")
+
+// PodmanRuntime implements Runtime using Podman's Docker-compatible REST API.
+// Containers, images, builds, networks, sidecars, and volumes all go through
+// the same code as DockerRuntime; only socket discovery and host networking
+// differ.
+type PodmanRuntime struct {
+	*DockerRuntime
+	socket string
+}
+
+// Verify PodmanRuntime satisfies the Runtime interface at compile time.
+var _ Runtime = (*PodmanRuntime)(nil)
+
+// NewPodmanRuntime creates a runtime on the Podman API socket found by
+// podmanSocket. Rootless sockets are preferred over the rootful one.
+// Sandbox has the same meaning as for NewDockerRuntime: gVisor must be
+// configured as a Podman OCI runtime named "runsc".
+func NewPodmanRuntime(sandbox bool) (*PodmanRuntime, error) {
+	socket, err := podmanSocket()
+	if err != nil {
+		return nil, err
+	}
+	rt, err := newDockerRuntime(sandbox, client.WithHost("unix://"+socket))
+	if err != nil {
+		return nil, err
+	}
+	rt.buildMgr.legacyOnly = true
+	return &PodmanRuntime{DockerRuntime: rt, socket: socket}, nil
+}
+
+// Type returns RuntimePodman.
+func (r *PodmanRuntime) Type() RuntimeType {
+	return RuntimePodman
+}
+
+// UserNamespaceMode reports UsernsKeepID for rootless Podman. Rootful Podman
+// shares host IDs unless configured otherwise, so it reports UsernsNone.
+func (r *PodmanRuntime) UserNamespaceMode(ctx context.Context) (string, error) {
+	info, err := r.cli.Info(ctx)
+	if err != nil {
+		return UsernsNone, fmt.Errorf("getting podman info: %w", err)
+	}
+	return usernsModeFromSecurityOptions(info.SecurityOptions, true), nil
+}
+
+// Ping verifies the Podman API service is accessible.
+func (r *PodmanRuntime) Ping(ctx context.Context) error {
+	if _, err := r.cli.Ping(ctx); err != nil {
+		return fmt.Errorf("podman API not accessible at %s: %w", r.socket, err)
+	}
+	return nil
+}
+
+// GetHostAddress returns host.containers.internal, which Podman adds to every
+// container's /etc/hosts.
+func (r *PodmanRuntime) GetHostAddress() string {
+	return "host.containers.internal"
+}
+
+// SupportsHostNetwork returns false. Rootless Podman's host network is the
+// user namespace's, and moat always publishes the proxy to containers through
+// the bridge, so Podman uses bridge mode everywhere.
+func (r *PodmanRuntime) SupportsHostNetwork() bool {
+	return false
+}
+
+// PodmanSocket returns the path of the Podman API socket NewPodmanRuntime
+// connects to. docker:host mounts it as the container's Docker socket.
+func PodmanSocket() (string, error) {
+	return podmanSocket()
+}
+
+// podmanSocket returns the path of a Podman API socket, checking in order:
+// CONTAINER_HOST (unix:// only), the rootless socket under XDG_RUNTIME_DIR or
+// /run/user/<uid>, the rootful /run/podman/podman.sock, and on macOS the
+// socket of the default Podman machine.
+func podmanSocket() (string, error) {
+	if host := os.Getenv("CONTAINER_HOST"); host != "" {
+		path, ok := strings.CutPrefix(host, "unix://")
+		if !ok {
+			return "", fmt.Errorf("CONTAINER_HOST=%s is not supported; moat only connects to local Podman sockets (unix://)", host)
+		}
+		return path, nil
+	}
+	for _, path := range podmanSocketCandidates() {
+		if isSocket(path) {
+			return path, nil
+		}
+	}
+	if runtime.GOOS == "darwin" {
+		if path := podmanMachineSocket(); path != "" && isSocket(path) {
+			return path, nil
+		}
+		return "", fmt.Errorf("%w\n\nStart the Podman machine:\n  podman machine start", errPodmanNotFound)
+	}
+	return "", fmt.Errorf("%w\n\nEnable the Podman API socket:\n  systemctl --user enable --now podman.socket   # rootless\n  sudo systemctl enable --now podman.socket     # rootful", errPodmanNotFound)
+}
+
+// podmanSocketCandidates returns the well-known Linux socket paths, rootless
+// first.
+func podmanSocketCandidates() []string {
+	var paths []string
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		paths = append(paths, filepath.Join(dir, "podman", "podman.sock"))
+	}
+	if uid := os.Getuid(); uid > 0 {
+		path := filepath.Join("/run/user", strconv.Itoa(uid), "podman", "podman.sock")
+		if len(paths) == 0 || paths[0] != path {
+			paths = append(paths, path)
+		}
+	}
+	return append(paths, "/run/podman/podman.sock")
+}
+
+// podmanMachineSocket asks the podman CLI for the default machine's API
+// socket. Returns "" if podman is not installed or no machine exists.
+func podmanMachineSocket() string {
+	if _, err := exec.LookPath("podman"); err != nil {
+		return ""
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, "podman", "machine", "inspect", "--format", "{{.ConnectionInfo.PodmanSocket.Path}}").Output()
+	if err != nil {
+		return ""
+	}
+	// One line per machine; the first is the default.
+	first, _, _ := strings.Cut(strings.TrimSpace(string(out)), "\n")
+	return strings.TrimSpace(first)
+}
+
+// isSocket reports whether path is a Unix socket, following symlinks.
+func isSocket(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode()&os.ModeSocket != 0
+}
@@ -0,0 +1,143 @@
+package container
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// listenPodmanSocket serves a minimal Podman API (just /_ping) on a Unix
+// socket at dir/podman/podman.sock and returns its path. The directory is
+// short-lived under os.TempDir because socket paths are length-limited.
+func listenPodmanSocket(t *testing.T) (dir, sock string) {
+	t.Helper()
+	dir, err := os.MkdirTemp("", "p")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	if err := os.Mkdir(filepath.Join(dir, "podman"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	sock = filepath.Join(dir, "podman", "podman.sock")
+	ln, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatalf("creating socket: %v", err)
+	}
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/_ping") {
+			w.Header().Set("Api-Version", "1.41")
+			w.Write([]byte("OK"))
+			return
+		}
+		http.NotFound(w, r)
+	})}
+	go srv.Serve(ln)
+	t.Cleanup(func() { srv.Close() })
+	return dir, sock
+}
+
+func TestPodmanSocketFromXDGRuntimeDir(t *testing.T) {
+	t.Setenv("CONTAINER_HOST", "")
+	dir, sock := listenPodmanSocket(t)
+	t.Setenv("XDG_RUNTIME_DIR", dir)
+
+	got, err := podmanSocket()
+	if err != nil {
+		t.Fatalf("podmanSocket: %v", err)
+	}
+	if got != sock {
+		t.Errorf("podmanSocket() = %q, want %q", got, sock)
+	}
+}
+
+func TestPodmanSocketContainerHost(t *testing.T) {
+	t.Setenv("CONTAINER_HOST", "unix:///tmp/custom/podman.sock")
+	got, err := podmanSocket()
+	if err != nil {
+		t.Fatalf("podmanSocket: %v", err)
+	}
+	if got != "/tmp/custom/podman.sock" {
+		t.Errorf("podmanSocket() = %q, want /tmp/custom/podman.sock", got)
+	}
+
+	t.Setenv("CONTAINER_HOST", "ssh://core@localhost:2222/run/podman/podman.sock")
+	if _, err := podmanSocket(); err == nil || !strings.Contains(err.Error(), "unix://") {
+		t.Errorf("podmanSocket() with ssh CONTAINER_HOST: err = %v, want unix:// hint", err)
+	}
+}
+
+func TestPodmanSocketNotFound(t *testing.T) {
+	t.Setenv("CONTAINER_HOST", "")
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+	t.Setenv("PATH", "/nonexistent")
+	for _, path := range podmanSocketCandidates() {
+		if isSocket(path) {
+			t.Skipf("Podman socket present at %s", path)
+		}
+	}
+
+	_, err := podmanSocket()
+	if !errors.Is(err, errPodmanNotFound) {
+		t.Fatalf("podmanSocket() error = %v, want errPodmanNotFound", err)
+	}
+	if !strings.Contains(err.Error(), "podman") {
+		t.Errorf("error should say how to start Podman, got: %s", err)
+	}
+}
+
+func TestNewPodmanRuntime(t *testing.T) {
+	t.Setenv("CONTAINER_HOST", "")
+	dir, sock := listenPodmanSocket(t)
+	t.Setenv("XDG_RUNTIME_DIR", dir)
+
+	rt, err := NewPodmanRuntime(false)
+	if err != nil {
+		t.Fatalf("NewPodmanRuntime: %v", err)
+	}
+	defer rt.Close()
+
+	if rt.Type() != RuntimePodman {
+		t.Errorf("Type() = %v, want %v", rt.Type(), RuntimePodman)
+	}
+	if rt.socket != sock {
+		t.Errorf("socket = %q, want %q", rt.socket, sock)
+	}
+	if got := rt.GetHostAddress(); got != "host.containers.internal" {
+		t.Errorf("GetHostAddress() = %q, want host.containers.internal", got)
+	}
+	if rt.SupportsHostNetwork() {
+		t.Error("SupportsHostNetwork() = true, want false")
+	}
+	if !rt.buildMgr.legacyOnly {
+		t.Error("build manager should skip embedded BuildKit on Podman")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := rt.Ping(ctx); err != nil {
+		t.Errorf("Ping: %v", err)
+	}
+}
+
+func TestNewRuntimeWithOptionsPodmanOverrideNoPodman(t *testing.T) {
+	t.Setenv("MOAT_RUNTIME", "podman")
+	t.Setenv("CONTAINER_HOST", "unix:///nonexistent/podman.sock")
+	_, err := NewRuntimeWithOptions(RuntimeOptions{Sandbox: false})
+	if err == nil {
+		t.Fatal("expected error when the Podman socket is unreachable")
+	}
+	msg := err.Error()
+	if !strings.Contains(msg, "Podman runtime requested") {
+		t.Errorf("error should mention Podman was requested, got: %s", msg)
+	}
+	if !strings.Contains(msg, "MOAT_RUNTIME=docker") {
+		t.Errorf("error should suggest MOAT_RUNTIME=docker, got: %s", msg)
+	}
+}
@@ -81,6 +81,9 @@ type poolStubRuntime struct {
 func (s *poolStubRuntime) Type() RuntimeType          { return RuntimeDocker }
 func (s *poolStubRuntime) Close() error               { s.closed = true; return nil }
 func (s *poolStubRuntime) Ping(context.Context) error { panic("not implemented") }
+func (s *poolStubRuntime) Version(context.Context) (string, error) {
+	panic("not implemented")
+}
 func (s *poolStubRuntime) CreateContainer(context.Context, Config) (string, error) {
 	panic("not implemented")
 }
@@ -137,6 +140,9 @@ func (s *poolStubRuntime) ServiceManager() ServiceManager { return nil }
 func (s *poolStubRuntime) SetupFirewall(context.Context, string, string, int) error {
 	panic("not implemented")
 }
+func (s *poolStubRuntime) RestrictServiceAccess(context.Context, string, []ServiceAccess) error {
+	panic("not implemented")
+}
 
 func (s *poolStubRuntime) ListImages(context.Context) ([]ImageInfo, error) {
 	panic("not implemented")
@@ -150,6 +156,22 @@ func (s *poolStubRuntime) ContainerState(context.Context, string) (string, error
 	panic("not implemented")
 }
 
+func (s *poolStubRuntime) UserNamespaceMode(context.Context) (string, error) {
+	panic("not implemented")
+}
+
+func (s *poolStubRuntime) UpdatePriority(context.Context, string, Priority) error {
+	return nil
+}
+
+func (s *poolStubRuntime) ContainerOOMKilled(context.Context, string) (bool, error) {
+	panic("not implemented")
+}
+
+func (s *poolStubRuntime) ContainerStats(context.Context, string) (Stats, error) {
+	return Stats{}, ErrStatsUnsupported
+}
+
 func (s *poolStubRuntime) RemoveImage(context.Context, string) error {
 	panic("not implemented")
 }
@@ -0,0 +1,56 @@
+package container
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Priority is a run's scheduling class relative to other runs on the host.
+type Priority string
+
+// Priority classes.
+const (
+	PriorityHigh       Priority = "high"       // interactive sessions that must stay responsive
+	PriorityNormal     Priority = "normal"     // the runtime's defaults
+	PriorityBackground Priority = "background" // batch agents that yield to everything else
+)
+
+// Priorities lists the priority classes from highest to lowest.
+var Priorities = []Priority{PriorityHigh, PriorityNormal, PriorityBackground}
+
+// ErrPriorityUnsupported is returned by UpdatePriority when the runtime
+// cannot weight containers against each other.
+var ErrPriorityUnsupported = errors.New("priority classes are not supported by this runtime")
+
+// ParsePriority validates a priority class name. The empty string is
+// PriorityNormal.
+func ParsePriority(s string) (Priority, error) {
+	if s == "" {
+		return PriorityNormal, nil
+	}
+	for _, p := range Priorities {
+		if Priority(s) == p {
+			return p, nil
+		}
+	}
+	return "", fmt.Errorf("invalid priority %q: must be high, normal, or background", s)
+}
+
+// PriorityWeights are the relative CPU and block IO weights of a class.
+// Weights only matter when runs compete: an idle host gives a background
+// run all the CPU it asks for.
+type PriorityWeights struct {
+	CPUShares   int64  // cgroup cpu.shares; Docker converts to cpu.weight on cgroup v2
+	BlkioWeight uint16 // cgroup blkio/io weight, 10-1000; ignored by kernels without a weighted IO scheduler
+}
+
+// Weights returns the class's weights. Normal uses the runtime defaults.
+func (p Priority) Weights() PriorityWeights {
+	switch p {
+	case PriorityHigh:
+		return PriorityWeights{CPUShares: 4096, BlkioWeight: 1000}
+	case PriorityBackground:
+		return PriorityWeights{CPUShares: 128, BlkioWeight: 100}
+	}
+	return PriorityWeights{CPUShares: 1024, BlkioWeight: 500}
+}
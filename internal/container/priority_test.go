@@ -0,0 +1,32 @@
+package container
+
+import "testing"
+
+func TestParsePriority(t *testing.T) {
+	for in, want := range map[string]Priority{
+		"":           PriorityNormal,
+		"high":       PriorityHigh,
+		"normal":     PriorityNormal,
+		"background": PriorityBackground,
+	} {
+		got, err := ParsePriority(in)
+		if err != nil || got != want {
+			t.Errorf("ParsePriority(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	if _, err := ParsePriority("low"); err == nil {
+		t.Error("ParsePriority(low) succeeded")
+	}
+}
+
+func TestPriorityWeightsOrdered(t *testing.T) {
+	for i := 1; i < len(Priorities); i++ {
+		hi, lo := Priorities[i-1].Weights(), Priorities[i].Weights()
+		if hi.CPUShares <= lo.CPUShares || hi.BlkioWeight <= lo.BlkioWeight {
+			t.Errorf("%s weights %+v not above %s weights %+v", Priorities[i-1], hi, Priorities[i], lo)
+		}
+	}
+	if w := PriorityNormal.Weights(); w.CPUShares != 1024 || w.BlkioWeight != 500 {
+		t.Errorf("normal weights = %+v, want the runtime defaults", w)
+	}
+}
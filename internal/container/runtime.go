@@ -1,9 +1,11 @@
 // Package container provides an abstraction over container runtimes.
-// It supports Docker and Apple's container tool, with automatic detection.
+// It supports Docker, Podman, and Apple's container tool, with automatic
+// detection, and Kubernetes clusters when selected explicitly.
 package container
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"time"
@@ -25,11 +27,14 @@ type RuntimeType string
 const (
 	RuntimeDocker RuntimeType = "docker"
 	RuntimeApple  RuntimeType = "apple"
+	RuntimePodman RuntimeType = "podman"
+
+	RuntimeKubernetes RuntimeType = "kubernetes"
 )
 
 // AllRuntimeTypes returns all known runtime types.
 func AllRuntimeTypes() []RuntimeType {
-	return []RuntimeType{RuntimeDocker, RuntimeApple}
+	return []RuntimeType{RuntimeDocker, RuntimeApple, RuntimePodman, RuntimeKubernetes}
 }
 
 // DefaultAgentMemoryMB is the default memory limit for AI agent containers
@@ -52,6 +57,10 @@ type Runtime interface {
 	// Ping verifies the runtime is accessible.
 	Ping(ctx context.Context) error
 
+	// Version returns the version reported by the runtime's engine, for
+	// diagnostics.
+	Version(ctx context.Context) (string, error)
+
 	// CreateContainer creates a new container without starting it.
 	// Returns the container ID.
 	CreateContainer(ctx context.Context, cfg Config) (string, error)
@@ -105,6 +114,10 @@ type Runtime interface {
 	// Docker on Linux supports this; Apple container does not.
 	SupportsHostNetwork() bool
 
+	// UserNamespaceMode reports how the runtime can run containers in a
+	// user namespace: UsernsKeepID, UsernsDaemon, or UsernsNone.
+	UserNamespaceMode(ctx context.Context) (string, error)
+
 	// NetworkManager returns the network manager if supported, nil otherwise.
 	// Both Docker and Apple runtimes provide this.
 	NetworkManager() NetworkManager
@@ -130,6 +143,12 @@ type Runtime interface {
 	// This blocks all other outbound IPv4 and IPv6 traffic, forcing everything through the proxy.
 	SetupFirewall(ctx context.Context, id string, proxyHost string, proxyPort int) error
 
+	// RestrictServiceAccess installs iptables rules that limit the
+	// container's connections to the run's service containers, ahead of
+	// any SetupFirewall rules. Runtimes without service support return an
+	// error.
+	RestrictServiceAccess(ctx context.Context, id string, rules []ServiceAccess) error
+
 	// ListImages returns all moat-managed images.
 	ListImages(ctx context.Context) ([]ImageInfo, error)
 
@@ -140,6 +159,20 @@ type Runtime interface {
 	// Returns an error if the container doesn't exist.
 	ContainerState(ctx context.Context, id string) (string, error)
 
+	// ContainerOOMKilled reports whether the kernel OOM killer terminated the
+	// container's main process. Call after the container has exited. Runtimes
+	// that do not expose OOM state return false, nil.
+	ContainerOOMKilled(ctx context.Context, id string) (bool, error)
+
+	// UpdatePriority changes a container's CPU and IO weights to those of
+	// the priority class. Runtimes that cannot weight containers return
+	// ErrPriorityUnsupported.
+	UpdatePriority(ctx context.Context, id string, p Priority) error
+
+	// ContainerStats samples a running container's resource usage.
+	// Runtimes that do not expose usage return ErrStatsUnsupported.
+	ContainerStats(ctx context.Context, id string) (Stats, error)
+
 	// RemoveImage removes an image by ID or tag.
 	RemoveImage(ctx context.Context, id string) error
 
@@ -165,6 +198,21 @@ type Runtime interface {
 	ExecInteractive(ctx context.Context, id string, cmd []string, opts ExecOptions) error
 }
 
+// Stats is a sample of a container's resource usage. CPU time and network
+// bytes are cumulative since the container started; network bytes are zero
+// for containers on the host network.
+type Stats struct {
+	CPUNanos         uint64
+	MemoryBytes      uint64
+	MemoryLimitBytes uint64
+	NetRxBytes       uint64
+	NetTxBytes       uint64
+}
+
+// ErrStatsUnsupported is returned by ContainerStats when the runtime does not
+// expose resource usage.
+var ErrStatsUnsupported = errors.New("container resource usage is not available from this runtime")
+
 // ExecError is returned when a command executed inside a container exits
 // with a non-zero exit code.
 type ExecError struct {
@@ -182,6 +230,10 @@ type NetworkManager interface {
 	// Returns the network ID.
 	CreateNetwork(ctx context.Context, name string) (string, error)
 
+	// CreateInternalNetwork creates a network whose containers can reach
+	// each other but nothing outside it. Returns the network ID.
+	CreateInternalNetwork(ctx context.Context, name string) (string, error)
+
 	// RemoveNetwork removes a network by ID.
 	// Returns an error if the network has active endpoints.
 	// Does not fail if network doesn't exist.
@@ -198,6 +250,10 @@ type NetworkManager interface {
 	// NetworkGateway returns the IPv4 gateway address for the given network.
 	// Returns empty string if the gateway cannot be determined.
 	NetworkGateway(ctx context.Context, networkID string) string
+
+	// ConnectContainer attaches a created container to an additional network,
+	// where other containers resolve it by each of aliases.
+	ConnectContainer(ctx context.Context, networkID, containerID string, aliases []string) error
 }
 
 // NetworkInfo contains information about a network.
@@ -267,6 +323,9 @@ type ServiceConfig struct {
 	ProvisionCmd string
 	// MemoryMB is the memory limit for the service container in megabytes (0 = runtime default).
 	MemoryMB int
+	// Publish lists container ports to make reachable from the host, so the
+	// routing proxy can serve them.
+	Publish []int
 }
 
 // ServiceInfo contains connection details for a started service.
@@ -278,6 +337,9 @@ type ServiceInfo struct {
 	Env          map[string]string
 	ReadinessCmd string // Command to check if service is ready
 	PasswordEnv  string // Env var name containing the password
+	// Published maps each port in ServiceConfig.Publish to the host:port
+	// the host reaches it at.
+	Published map[int]string
 }
 
 // BuildManager handles image building operations.
@@ -338,8 +400,28 @@ type Config struct {
 	CPUs         int            // Number of CPUs (both Docker and Apple)
 	DNS          []string       // DNS servers (both Docker and Apple)
 	Ulimits      []Ulimit       // Resource limits (both Docker and Apple)
+	Init         bool           // If true, run the runtime's init as PID 1 to reap zombies and forward signals (Docker only; moat-built images include tini)
+	ShmSizeMB    int            // /dev/shm size in megabytes, 0 = runtime default (Docker only)
+	Devices      []string       // Host device paths passed through at the same path, read-write (Docker only)
+	UsernsMode   string         // User namespace mode, e.g. "keep-id:uid=1000,gid=1000" (Podman only; empty = runtime default)
+	Priority     Priority       // CPU and IO weight class; empty = runtime defaults (Docker and Podman only)
 }
 
+// User namespace support reported by Runtime.UserNamespaceMode.
+const (
+	// UsernsNone means containers share the host's user IDs: root in the
+	// container is root on the host.
+	UsernsNone = ""
+	// UsernsDaemon means the daemon already runs every container in a user
+	// namespace (Docker userns-remap or rootless Docker); there is nothing
+	// to request per container.
+	UsernsDaemon = "daemon"
+	// UsernsKeepID means containers can request Podman's keep-id mode, which
+	// maps the host user to a chosen container user and every other
+	// container ID, root included, to unprivileged subordinate IDs.
+	UsernsKeepID = "keep-id"
+)
+
 // SidecarConfig holds configuration for starting a sidecar container.
 type SidecarConfig struct {
 	// Image is the container image to use (e.g., "moby/buildkit:latest")
@@ -375,6 +457,10 @@ type SidecarConfig struct {
 
 	// MemoryMB is the memory limit for the container in megabytes (0 = no limit).
 	MemoryMB int
+
+	// PortBindings maps container ports to the host IP to publish them on;
+	// the runtime assigns the host port.
+	PortBindings map[int]string
 }
 
 // MountConfig describes a volume mount.
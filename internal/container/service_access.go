@@ -0,0 +1,107 @@
+package container
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// ServiceAccess limits a container's connections to one of the run's
+// service containers.
+type ServiceAccess struct {
+	// Service is the service's name, used in error messages.
+	Service string
+	// Host is the hostname or IP address the container reaches the
+	// service at (ServiceInfo.Host).
+	Host string
+	// Ports lists the TCP ports the container may connect to. Empty blocks
+	// the service entirely.
+	Ports []int
+}
+
+// serviceAccessChain holds the service access rules, so they can be
+// replaced without touching the rest of the OUTPUT chain.
+const serviceAccessChain = "MOAT-SERVICES"
+
+// serviceAccessScript returns the shell script RestrictServiceAccess runs
+// as root in the container. The rules live in their own chain, jumped to
+// first from OUTPUT, so they apply ahead of the strict network policy's
+// rules: listed ports are allowed even under network.policy: strict, and
+// everything else to the service is dropped. Established connections are
+// left to the rest of OUTPUT, so the container can still answer connections
+// a service opens to it. Hostnames are resolved when
+// the rules are installed; a name that does not resolve fails the script
+// rather than leaving the service open. Run networks are IPv4-only, so only
+// IPv4 rules are installed.
+//
+// preferLegacy selects iptables-legacy when the container has it, matching
+// the runtime's SetupFirewall so both sets of rules land in the same tables.
+func serviceAccessScript(rules []ServiceAccess, preferLegacy bool) (string, error) {
+	var b strings.Builder
+	if preferLegacy {
+		b.WriteString(`
+		if command -v iptables-legacy >/dev/null 2>&1; then
+			IPT=iptables-legacy
+		else
+			IPT=iptables
+		fi`)
+	} else {
+		b.WriteString(`
+		IPT=iptables`)
+	}
+	fmt.Fprintf(&b, `
+		if ! command -v $IPT >/dev/null 2>&1; then
+			echo "ERROR: iptables not found - service access cannot be restricted" >&2
+			exit 1
+		fi
+		$IPT -w -N %[1]s 2>/dev/null || $IPT -w -F %[1]s
+		$IPT -w -C OUTPUT -j %[1]s 2>/dev/null || $IPT -w -I OUTPUT 1 -j %[1]s
+		$IPT -w -A %[1]s -m conntrack --ctstate ESTABLISHED,RELATED -j RETURN
+`, serviceAccessChain)
+	for _, r := range rules {
+		for _, port := range r.Ports {
+			if port < 1 || port > 65535 {
+				return "", fmt.Errorf("service %s: invalid port %d", r.Service, port)
+			}
+		}
+		var addrs string
+		if ip := net.ParseIP(r.Host); ip != nil {
+			if ip.To4() == nil {
+				return "", fmt.Errorf("service %s: IPv6 address %s is not supported", r.Service, r.Host)
+			}
+			addrs = ip.String()
+		} else {
+			if !validServiceHost(r.Host) {
+				return "", fmt.Errorf("service %s: invalid host %q", r.Service, r.Host)
+			}
+			fmt.Fprintf(&b, `
+		addrs=$(getent ahostsv4 %[1]s | awk '{print $1}' | sort -u)
+		if [ -z "$addrs" ]; then
+			echo "ERROR: cannot resolve service host %[1]s" >&2
+			exit 1
+		fi
+`, r.Host)
+			addrs = "$addrs"
+		}
+		fmt.Fprintf(&b, "\t\tfor ip in %s; do\n", addrs)
+		for _, port := range r.Ports {
+			fmt.Fprintf(&b, "\t\t\t$IPT -w -A %s -d \"$ip\" -p tcp --dport %d -j ACCEPT\n", serviceAccessChain, port)
+		}
+		fmt.Fprintf(&b, "\t\t\t$IPT -w -A %s -d \"$ip\" -j DROP\n\t\tdone\n", serviceAccessChain)
+	}
+	return b.String(), nil
+}
+
+// validServiceHost reports whether host is a plain DNS name, safe to embed
+// in a shell script.
+func validServiceHost(host string) bool {
+	if host == "" || len(host) > 253 {
+		return false
+	}
+	for _, c := range host {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '.' || c == '_') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,63 @@
+package container
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestServiceAccessScript(t *testing.T) {
+	script, err := serviceAccessScript([]ServiceAccess{
+		{Service: "postgres", Host: "moat-postgres-run1", Ports: []int{5432}},
+		{Service: "redis", Host: "192.168.64.7"},
+	}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"IPT=iptables\n",
+		"$IPT -w -I OUTPUT 1 -j MOAT-SERVICES",
+		"getent ahostsv4 moat-postgres-run1",
+		`-A MOAT-SERVICES -d "$ip" -p tcp --dport 5432 -j ACCEPT`,
+		"for ip in 192.168.64.7; do",
+	} {
+		if !strings.Contains(script, want) {
+			t.Errorf("script missing %q:\n%s", want, script)
+		}
+	}
+	// Each service's accepts come before its drop.
+	if strings.Index(script, "--dport 5432 -j ACCEPT") > strings.Index(script, "-j DROP") {
+		t.Errorf("DROP precedes ACCEPT:\n%s", script)
+	}
+	// The redis rule has no ports, so it only drops.
+	redis := script[strings.Index(script, "192.168.64.7"):]
+	if strings.Contains(redis, "ACCEPT") {
+		t.Errorf("blocked service has an ACCEPT rule:\n%s", redis)
+	}
+
+	legacy, err := serviceAccessScript(nil, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(legacy, "IPT=iptables-legacy") {
+		t.Errorf("preferLegacy script does not select iptables-legacy:\n%s", legacy)
+	}
+}
+
+func TestServiceAccessScript_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		rule ServiceAccess
+	}{
+		{"shell metacharacters", ServiceAccess{Service: "db", Host: "db; rm -rf /"}},
+		{"empty host", ServiceAccess{Service: "db"}},
+		{"IPv6", ServiceAccess{Service: "db", Host: "fd00::2"}},
+		{"port out of range", ServiceAccess{Service: "db", Host: "db", Ports: []int{70000}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := serviceAccessScript([]ServiceAccess{tt.rule}, false); err == nil {
+				t.Error("serviceAccessScript() = nil error, want error")
+			}
+		})
+	}
+}
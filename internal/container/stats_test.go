@@ -0,0 +1,44 @@
+package container
+
+import (
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+func TestDockerStats(t *testing.T) {
+	var v container.StatsResponse
+	v.CPUStats.CPUUsage.TotalUsage = 5e9
+	v.MemoryStats.Usage = 300
+	v.MemoryStats.Limit = 1000
+	v.MemoryStats.Stats = map[string]uint64{"inactive_file": 100}
+	v.Networks = map[string]container.NetworkStats{
+		"eth0": {RxBytes: 10, TxBytes: 20},
+		"eth1": {RxBytes: 1, TxBytes: 2},
+	}
+	want := Stats{CPUNanos: 5e9, MemoryBytes: 200, MemoryLimitBytes: 1000, NetRxBytes: 11, NetTxBytes: 22}
+	if got := dockerStats(v); got != want {
+		t.Errorf("dockerStats = %+v, want %+v", got, want)
+	}
+
+	// cgroup v1 reports the page cache as total_inactive_file.
+	v.MemoryStats.Stats = map[string]uint64{"total_inactive_file": 50}
+	if got := dockerStats(v).MemoryBytes; got != 250 {
+		t.Errorf("MemoryBytes with cgroup v1 stats = %d, want 250", got)
+	}
+}
+
+func TestParseAppleStats(t *testing.T) {
+	data := []byte(`[{"id":"run_abc","memoryUsageBytes":2048,"memoryLimitBytes":4096,"cpuUsageUsec":1500,"networkRxBytes":7,"networkTxBytes":9,"numProcesses":3}]`)
+	got, err := parseAppleStats(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Stats{CPUNanos: 1500000, MemoryBytes: 2048, MemoryLimitBytes: 4096, NetRxBytes: 7, NetTxBytes: 9}
+	if got != want {
+		t.Errorf("parseAppleStats = %+v, want %+v", got, want)
+	}
+	if _, err := parseAppleStats([]byte(`[]`)); err == nil {
+		t.Error("expected error for empty stats output")
+	}
+}
@@ -1,6 +1,10 @@
 package credential
 
-import "errors"
+import (
+	"errors"
+
+	"github.com/majorcontext/moat/internal/errcode"
+)
 
 // ErrNotFound is returned (wrapped) when no stored credential exists for a
 // provider. Match it with errors.Is rather than inspecting the error string.
@@ -10,3 +14,8 @@ var ErrNotFound = errors.New("credential not found")
 // decrypted — usually because the encryption key changed. Match it with
 // errors.Is rather than inspecting the error string.
 var ErrDecrypt = errors.New("decrypting credential")
+
+func init() {
+	errcode.Register(ErrNotFound, errcode.CredentialNotFound)
+	errcode.Register(ErrDecrypt, errcode.CredentialDecrypt)
+}
@@ -0,0 +1,69 @@
+package credential
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/majorcontext/moat/internal/log"
+)
+
+// MetaKeyGrantExpires is the metadata key for when a grant stops being
+// usable, in RFC 3339. It is set by `moat grant --expires` and is unrelated
+// to ExpiresAt, the lifetime of the token itself, which refresh extends.
+const MetaKeyGrantExpires = "grant_expires"
+
+// GrantExpiry returns when the grant expires, or the zero time if it does
+// not. An unparseable value is treated as already expired.
+func (c *Credential) GrantExpiry() time.Time {
+	v, ok := c.Metadata[MetaKeyGrantExpires]
+	if !ok {
+		return time.Time{}
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return time.Unix(0, 0)
+	}
+	return t
+}
+
+// SetGrantExpiry records when the grant expires. The zero time clears it.
+func (c *Credential) SetGrantExpiry(t time.Time) {
+	if t.IsZero() {
+		delete(c.Metadata, MetaKeyGrantExpires)
+		return
+	}
+	if c.Metadata == nil {
+		c.Metadata = make(map[string]string)
+	}
+	c.Metadata[MetaKeyGrantExpires] = t.UTC().Format(time.RFC3339)
+}
+
+// GrantExpired reports whether the grant had expired at now.
+func (c *Credential) GrantExpired(now time.Time) bool {
+	exp := c.GrantExpiry()
+	return !exp.IsZero() && !now.Before(exp)
+}
+
+// PurgeExpired deletes the credentials in store whose grant had expired at
+// now and returns their providers.
+func PurgeExpired(store Store, now time.Time) ([]Provider, error) {
+	creds, err := store.List()
+	if err != nil {
+		return nil, err
+	}
+	var purged []Provider
+	var errs []error
+	for _, c := range creds {
+		if !c.GrantExpired(now) {
+			continue
+		}
+		if err := store.Delete(c.Provider); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", c.Provider, err))
+			continue
+		}
+		log.Info("purged expired grant", "provider", c.Provider, "expired", c.GrantExpiry())
+		purged = append(purged, c.Provider)
+	}
+	return purged, errors.Join(errs...)
+}
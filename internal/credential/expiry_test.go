@@ -0,0 +1,68 @@
+package credential
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCredential_GrantExpiry(t *testing.T) {
+	now := time.Date(2026, 3, 4, 12, 0, 0, 0, time.UTC)
+	var c Credential
+	if !c.GrantExpiry().IsZero() || c.GrantExpired(now) {
+		t.Error("credential without an expiry should never expire")
+	}
+
+	c.SetGrantExpiry(now.Add(time.Hour))
+	if got := c.Metadata[MetaKeyGrantExpires]; got != "2026-03-04T13:00:00Z" {
+		t.Errorf("metadata = %q", got)
+	}
+	if c.GrantExpired(now) {
+		t.Error("expired an hour early")
+	}
+	if !c.GrantExpired(now.Add(time.Hour)) {
+		t.Error("not expired at the expiry time")
+	}
+
+	c.Metadata[MetaKeyGrantExpires] = "tomorrow"
+	if !c.GrantExpired(now) {
+		t.Error("an unparseable expiry should count as expired")
+	}
+
+	c.SetGrantExpiry(time.Time{})
+	if _, ok := c.Metadata[MetaKeyGrantExpires]; ok {
+		t.Error("SetGrantExpiry(zero) did not clear the expiry")
+	}
+}
+
+func TestPurgeExpired(t *testing.T) {
+	store, err := NewFileStore(t.TempDir(), []byte("test-encryption-key-32-bytes!!ab"))
+	if err != nil {
+		t.Fatalf("NewFileStore: %v", err)
+	}
+	now := time.Now()
+	expired := Credential{Provider: ProviderGitHub, Token: "ghp_old"}
+	expired.SetGrantExpiry(now.Add(-time.Minute))
+	current := Credential{Provider: ProviderNpm, Token: "npm_new"}
+	current.SetGrantExpiry(now.Add(time.Hour))
+	for _, c := range []Credential{expired, current, {Provider: ProviderAnthropic, Token: "sk-ant"}} {
+		if err := store.Save(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	purged, err := PurgeExpired(store, now)
+	if err != nil {
+		t.Fatalf("PurgeExpired: %v", err)
+	}
+	if len(purged) != 1 || purged[0] != ProviderGitHub {
+		t.Errorf("purged = %v, want [github]", purged)
+	}
+	if _, err := store.Get(ProviderGitHub); err == nil {
+		t.Error("expired credential still stored")
+	}
+	for _, p := range []Provider{ProviderNpm, ProviderAnthropic} {
+		if _, err := store.Get(p); err != nil {
+			t.Errorf("Get(%s): %v", p, err)
+		}
+	}
+}
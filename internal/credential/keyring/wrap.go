@@ -0,0 +1,440 @@
+package keyring
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/majorcontext/moat/internal/errcode"
+)
+
+// Key provider modes. The mode decides where the credential store key lives.
+const (
+	// ModeKeychain stores the key in the system keychain, falling back to a
+	// key file when no keychain is available. This is the default.
+	ModeKeychain = "keychain"
+	// ModeFile stores the key in a key file and never touches the keychain.
+	ModeFile = "file"
+	// ModeAge stores the key encrypted to an age identity file.
+	ModeAge = "age"
+	// ModeAWSKMS stores the key encrypted with an AWS KMS key.
+	ModeAWSKMS = "aws-kms"
+	// ModeGCPKMS stores the key encrypted with a Google Cloud KMS key.
+	ModeGCPKMS = "gcp-kms"
+)
+
+// Modes lists the valid key provider modes.
+var Modes = []string{ModeKeychain, ModeFile, ModeAge, ModeAWSKMS, ModeGCPKMS}
+
+// KeyProvider selects where the credential store key is kept. The zero value
+// is ModeKeychain.
+//
+// In the age and KMS modes, the key file holds the store key wrapped
+// (encrypted) by an identity or KMS key that Moat never stores, so hosts
+// without a keychain do not keep the store key in plaintext on disk.
+type KeyProvider struct {
+	Mode string
+	// AgeIdentity is the path to an age identity file (ModeAge).
+	AgeIdentity string
+	// KMSKey is an AWS KMS key ID, ARN, or alias (ModeAWSKMS), or a Cloud KMS
+	// key resource name, projects/P/locations/L/keyRings/R/cryptoKeys/K
+	// (ModeGCPKMS).
+	KMSKey string
+}
+
+// Validate checks that p names a known mode and carries the settings the
+// mode needs.
+func (p KeyProvider) Validate() error {
+	switch p.Mode {
+	case "", ModeKeychain, ModeFile:
+	case ModeAge:
+		if p.AgeIdentity == "" {
+			return fmt.Errorf("key provider %q requires an age identity file", p.Mode)
+		}
+	case ModeAWSKMS, ModeGCPKMS:
+		if p.KMSKey == "" {
+			return fmt.Errorf("key provider %q requires a KMS key", p.Mode)
+		}
+		if p.Mode == ModeGCPKMS && !strings.HasPrefix(p.KMSKey, "projects/") {
+			return fmt.Errorf("key provider %q: KMS key must be a resource name (projects/P/locations/L/keyRings/R/cryptoKeys/K), got %q", p.Mode, p.KMSKey)
+		}
+	default:
+		return fmt.Errorf("unknown key provider %q (valid: %s)", p.Mode, strings.Join(Modes, ", "))
+	}
+	return nil
+}
+
+// String describes p for messages, e.g. "aws-kms (alias/moat)".
+func (p KeyProvider) String() string {
+	switch p.Mode {
+	case "":
+		return ModeKeychain
+	case ModeAge:
+		return p.Mode + " (" + p.AgeIdentity + ")"
+	case ModeAWSKMS, ModeGCPKMS:
+		return p.Mode + " (" + p.KMSKey + ")"
+	}
+	return p.Mode
+}
+
+// wrapped reports whether p keeps the key wrapped by an external identity.
+func (p KeyProvider) wrapped() bool {
+	return p.Mode == ModeAge || p.Mode == ModeAWSKMS || p.Mode == ModeGCPKMS
+}
+
+// Wrapper encrypts and decrypts the store key with a key held outside Moat.
+type Wrapper interface {
+	Wrap(key []byte) ([]byte, error)
+	Unwrap(data []byte) ([]byte, error)
+	Name() string
+}
+
+// wrappedBackend stores the key in a file, wrapped by a Wrapper.
+type wrappedBackend struct {
+	path    string
+	wrapper Wrapper
+}
+
+func (w *wrappedBackend) Get() ([]byte, error) {
+	data, err := os.ReadFile(w.path)
+	if err != nil {
+		return nil, fmt.Errorf("reading wrapped key file: %w", err)
+	}
+	key, err := w.wrapper.Unwrap(data)
+	if err != nil {
+		return nil, fmt.Errorf("unwrapping key with %s: %w", w.wrapper.Name(), err)
+	}
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("unwrapped key has length %d, expected %d", len(key), KeySize)
+	}
+	return key, nil
+}
+
+func (w *wrappedBackend) Set(key []byte) error {
+	// Don't overwrite an existing key; see fileBackend.Set. Callers hold
+	// the global key lock, so no separate file lock is needed here.
+	if _, err := os.Stat(w.path); err == nil {
+		return nil
+	}
+	data, err := w.wrapper.Wrap(key)
+	if err != nil {
+		return fmt.Errorf("wrapping key with %s: %w", w.wrapper.Name(), err)
+	}
+	if err := os.MkdirAll(filepath.Dir(w.path), 0o700); err != nil {
+		return fmt.Errorf("creating key directory: %w", err)
+	}
+	tmp := w.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("writing wrapped key file: %w", err)
+	}
+	if err := os.Rename(tmp, w.path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("writing wrapped key file: %w", err)
+	}
+	return nil
+}
+
+func (w *wrappedBackend) Delete() error {
+	if err := os.Remove(w.path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("deleting wrapped key file: %w", err)
+	}
+	return nil
+}
+
+func (w *wrappedBackend) Name() string {
+	return w.wrapper.Name() + " (" + w.path + ")"
+}
+
+// wrappedKeyFilePath returns the key file for a wrapped mode, next to the
+// plain key file: encryption.key.age, encryption.key.aws-kms, and so on.
+func wrappedKeyFilePath(mode string) (string, error) {
+	path, err := DefaultKeyFilePath()
+	if err != nil {
+		return "", err
+	}
+	return path + "." + mode, nil
+}
+
+// newWrapper returns the Wrapper for a wrapped mode.
+func newWrapper(p KeyProvider) Wrapper {
+	switch p.Mode {
+	case ModeAge:
+		return &ageWrapper{identity: p.AgeIdentity}
+	case ModeAWSKMS:
+		return &awsKMSWrapper{keyID: p.KMSKey}
+	case ModeGCPKMS:
+		return &gcpKMSWrapper{key: p.KMSKey}
+	}
+	return nil
+}
+
+// backendsFor returns the backends getOrCreateKeyWithBackends uses for p.
+func backendsFor(p KeyProvider) (primary, fallback Backend, err error) {
+	if p.wrapped() {
+		path, err := wrappedKeyFilePath(p.Mode)
+		if err != nil {
+			return nil, nil, err
+		}
+		return nil, &wrappedBackend{path: path, wrapper: newWrapper(p)}, nil
+	}
+	keyFilePath, err := DefaultKeyFilePath()
+	if err != nil {
+		return nil, nil, err
+	}
+	fallback = &fileBackend{path: keyFilePath}
+	if p.Mode != ModeFile && !KeychainDisabled() {
+		primary = &keychainBackend{}
+	}
+	return primary, fallback, nil
+}
+
+// ErrKeyNotMigrated is returned when a wrapped mode is selected but the store
+// key still lives in the plain key file. Creating a fresh key would make
+// every stored credential unreadable.
+var ErrKeyNotMigrated = errors.New("credential store key has not been migrated to the configured key provider")
+
+func init() {
+	errcode.Register(ErrKeyNotMigrated, errcode.KeyNotMigrated)
+	errcode.Register(ErrInsecurePermissions, errcode.KeyInsecurePermissions)
+}
+
+// GetOrCreateKeyFor retrieves the encryption key from the storage p selects,
+// generating a new one if none exists. The default mode behaves like
+// GetOrCreateKey.
+func GetOrCreateKeyFor(p KeyProvider) ([]byte, error) {
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	return withGlobalKeyLock(func() ([]byte, error) {
+		primary, fallback, err := backendsFor(p)
+		if err != nil {
+			return nil, err
+		}
+		if p.wrapped() {
+			if _, statErr := os.Stat(fallback.(*wrappedBackend).path); os.IsNotExist(statErr) {
+				if plain, pathErr := DefaultKeyFilePath(); pathErr == nil {
+					if _, err := os.Stat(plain); err == nil {
+						return nil, fmt.Errorf("%w: the key is in %s\n\n"+
+							"Move it with: moat grant migrate-key",
+							ErrKeyNotMigrated, plain)
+					}
+				}
+			}
+		}
+		return getOrCreateKeyWithBackends(primary, fallback)
+	})
+}
+
+// MigrateKey moves the existing store key from one key provider to another.
+// The key itself is unchanged, so stored credentials stay readable. The key
+// is removed from the source only after it reads back from the destination.
+func MigrateKey(from, to KeyProvider) error {
+	if err := from.Validate(); err != nil {
+		return err
+	}
+	if err := to.Validate(); err != nil {
+		return err
+	}
+	if from.wrapped() && from.Mode == to.Mode {
+		return fmt.Errorf("cannot migrate between two %s keys directly; migrate to %s first, then to the new key", to.Mode, ModeFile)
+	}
+	_, err := withGlobalKeyLock(func() ([]byte, error) {
+		return nil, migrateKeyWithBackends(from, to)
+	})
+	return err
+}
+
+func migrateKeyWithBackends(from, to KeyProvider) error {
+	srcPrimary, srcFallback, err := backendsFor(from)
+	if err != nil {
+		return err
+	}
+	dstPrimary, dstFallback, err := backendsFor(to)
+	if err != nil {
+		return err
+	}
+	return migrateBetween(srcPrimary, srcFallback, dstPrimary, dstFallback)
+}
+
+// migrateBetween copies the key from the source backends to the destination
+// backends and then deletes it from the source. A nil primary is skipped.
+func migrateBetween(srcPrimary, srcFallback, dstPrimary, dstFallback Backend) error {
+	var key []byte
+	var src []Backend
+	for _, b := range []Backend{srcPrimary, srcFallback} {
+		if b == nil {
+			continue
+		}
+		if k, err := b.Get(); err == nil {
+			if key == nil {
+				key = k
+			}
+			src = append(src, b)
+		}
+	}
+	if key == nil {
+		return fmt.Errorf("no credential store key found in %s", backendNames(srcPrimary, srcFallback))
+	}
+
+	dst := dstFallback
+	if dstPrimary != nil {
+		dst = dstPrimary
+	}
+	if existing, err := dst.Get(); err == nil {
+		if !bytes.Equal(existing, key) {
+			return fmt.Errorf("%s already holds a different key; remove it before migrating", dst.Name())
+		}
+	} else {
+		if err := dst.Set(key); err != nil {
+			if dstPrimary == nil {
+				return err
+			}
+			dst = dstFallback
+			if err := dst.Set(key); err != nil {
+				return err
+			}
+		}
+		stored, err := dst.Get()
+		if err != nil {
+			return fmt.Errorf("verifying key in %s: %w", dst.Name(), err)
+		}
+		if !bytes.Equal(stored, key) {
+			return fmt.Errorf("%s already holds a different key; remove it before migrating", dst.Name())
+		}
+	}
+
+	for _, b := range src {
+		if b.Name() == dst.Name() {
+			continue
+		}
+		if err := b.Delete(); err != nil {
+			return fmt.Errorf("key copied to %s, but removing it from %s failed: %w", dst.Name(), b.Name(), err)
+		}
+	}
+	return nil
+}
+
+func backendNames(backends ...Backend) string {
+	var names []string
+	for _, b := range backends {
+		if b != nil {
+			names = append(names, b.Name())
+		}
+	}
+	return strings.Join(names, " or ")
+}
+
+// runCLI runs a key-wrapping CLI with stdin and returns its stdout. It is a
+// variable so tests can stub out the age, aws, and gcloud binaries.
+var runCLI = func(stdin []byte, name string, args ...string) ([]byte, error) {
+	if _, err := exec.LookPath(name); err != nil {
+		return nil, fmt.Errorf("%s CLI not found in PATH", name)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdin = bytes.NewReader(stdin)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%s: %s", name, msg)
+		}
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return stdout.Bytes(), nil
+}
+
+// ageWrapper encrypts the key to the recipient of an age identity file using
+// the age CLI (https://age-encryption.org, v1.1 or later).
+type ageWrapper struct {
+	identity string
+}
+
+func (a *ageWrapper) Wrap(key []byte) ([]byte, error) {
+	return runCLI(key, "age", "--encrypt", "--armor", "--identity", a.identity)
+}
+
+func (a *ageWrapper) Unwrap(data []byte) ([]byte, error) {
+	return runCLI(data, "age", "--decrypt", "--identity", a.identity)
+}
+
+func (a *ageWrapper) Name() string {
+	return "age identity " + a.identity
+}
+
+// awsKMSWrapper encrypts the key with an AWS KMS key using the aws CLI. The
+// key file holds the base64 ciphertext blob.
+type awsKMSWrapper struct {
+	keyID string
+}
+
+func (k *awsKMSWrapper) args(op string) []string {
+	args := []string{"kms", op, "--key-id", k.keyID, "--output", "text"}
+	// The CLI sends requests to its configured region, so a key ARN from
+	// another region fails unless the region is passed explicitly.
+	if parts := strings.Split(k.keyID, ":"); len(parts) > 4 && parts[0] == "arn" {
+		args = append(args, "--region", parts[3])
+	}
+	return args
+}
+
+func (k *awsKMSWrapper) Wrap(key []byte) ([]byte, error) {
+	args := append(k.args("encrypt"), "--plaintext", "fileb:///dev/stdin", "--query", "CiphertextBlob")
+	out, err := runCLI(key, "aws", args...)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.TrimSpace(out), nil
+}
+
+func (k *awsKMSWrapper) Unwrap(data []byte) ([]byte, error) {
+	blob, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid wrapped key encoding: %w", err)
+	}
+	args := append(k.args("decrypt"), "--ciphertext-blob", "fileb:///dev/stdin", "--query", "Plaintext")
+	out, err := runCLI(blob, "aws", args...)
+	if err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(strings.TrimSpace(string(out)))
+}
+
+func (k *awsKMSWrapper) Name() string {
+	return "AWS KMS key " + k.keyID
+}
+
+// gcpKMSWrapper encrypts the key with a Cloud KMS key using the gcloud CLI.
+// The key file holds the base64 ciphertext.
+type gcpKMSWrapper struct {
+	key string
+}
+
+func (g *gcpKMSWrapper) Wrap(key []byte) ([]byte, error) {
+	out, err := runCLI(key, "gcloud", "kms", "encrypt", "--key", g.key, "--plaintext-file", "-", "--ciphertext-file", "-")
+	if err != nil {
+		return nil, err
+	}
+	return []byte(base64.StdEncoding.EncodeToString(out)), nil
+}
+
+func (g *gcpKMSWrapper) Unwrap(data []byte) ([]byte, error) {
+	blob, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid wrapped key encoding: %w", err)
+	}
+	return runCLI(blob, "gcloud", "kms", "decrypt", "--key", g.key, "--ciphertext-file", "-", "--plaintext-file", "-")
+}
+
+func (g *gcpKMSWrapper) Name() string {
+	return "Cloud KMS key " + g.key
+}
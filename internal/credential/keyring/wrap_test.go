@@ -0,0 +1,197 @@
+package keyring
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+// stubCLI replaces runCLI with a fake that "encrypts" by reversing stdin and
+// records each invocation.
+func stubCLI(t *testing.T) *[]string {
+	t.Helper()
+	var calls []string
+	old := runCLI
+	runCLI = func(stdin []byte, name string, args ...string) ([]byte, error) {
+		calls = append(calls, name+" "+strings.Join(args, " "))
+		out := make([]byte, len(stdin))
+		for i, b := range stdin {
+			out[len(stdin)-1-i] = b
+		}
+		return out, nil
+	}
+	t.Cleanup(func() { runCLI = old })
+	return &calls
+}
+
+func TestKeyProviderValidate(t *testing.T) {
+	valid := []KeyProvider{
+		{},
+		{Mode: ModeKeychain},
+		{Mode: ModeFile},
+		{Mode: ModeAge, AgeIdentity: "/id.txt"},
+		{Mode: ModeAWSKMS, KMSKey: "alias/moat"},
+		{Mode: ModeGCPKMS, KMSKey: "projects/p/locations/global/keyRings/r/cryptoKeys/k"},
+	}
+	for _, p := range valid {
+		if err := p.Validate(); err != nil {
+			t.Errorf("Validate(%+v) = %v, want nil", p, err)
+		}
+	}
+	invalid := []KeyProvider{
+		{Mode: "vault"},
+		{Mode: ModeAge},
+		{Mode: ModeAWSKMS},
+		{Mode: ModeGCPKMS, KMSKey: "my-key"},
+	}
+	for _, p := range invalid {
+		if err := p.Validate(); err == nil {
+			t.Errorf("Validate(%+v) = nil, want error", p)
+		}
+	}
+}
+
+func TestGetOrCreateKeyForAge(t *testing.T) {
+	t.Setenv("MOAT_KEYRING_SERVICE", "")
+	t.Setenv("MOAT_HOME", t.TempDir())
+	calls := stubCLI(t)
+	p := KeyProvider{Mode: ModeAge, AgeIdentity: "/id.txt"}
+
+	key, err := GetOrCreateKeyFor(p)
+	if err != nil {
+		t.Fatalf("GetOrCreateKeyFor: %v", err)
+	}
+	key2, err := GetOrCreateKeyFor(p)
+	if err != nil {
+		t.Fatalf("GetOrCreateKeyFor (2nd): %v", err)
+	}
+	if !bytes.Equal(key, key2) {
+		t.Error("second GetOrCreateKeyFor returned a different key")
+	}
+
+	path, _ := wrappedKeyFilePath(ModeAge)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading wrapped key file: %v", err)
+	}
+	if bytes.Equal(data, key) {
+		t.Error("wrapped key file holds the plaintext key")
+	}
+	plain, _ := DefaultKeyFilePath()
+	if _, err := os.Stat(plain); !os.IsNotExist(err) {
+		t.Errorf("plain key file %s should not exist in age mode", plain)
+	}
+	if len(*calls) == 0 || !strings.HasPrefix((*calls)[0], "age --encrypt") {
+		t.Errorf("calls = %v, want age --encrypt first", *calls)
+	}
+}
+
+func TestGetOrCreateKeyForRefusesUnmigratedKey(t *testing.T) {
+	t.Setenv("MOAT_KEYRING_SERVICE", "")
+	t.Setenv("MOAT_HOME", t.TempDir())
+	stubCLI(t)
+
+	if _, err := GetOrCreateKeyFor(KeyProvider{Mode: ModeFile}); err != nil {
+		t.Fatalf("creating file key: %v", err)
+	}
+	_, err := GetOrCreateKeyFor(KeyProvider{Mode: ModeAWSKMS, KMSKey: "alias/moat"})
+	if !errors.Is(err, ErrKeyNotMigrated) {
+		t.Fatalf("GetOrCreateKeyFor = %v, want ErrKeyNotMigrated", err)
+	}
+}
+
+func TestMigrateKeyFileToAgeAndBack(t *testing.T) {
+	t.Setenv("MOAT_KEYRING_SERVICE", "")
+	t.Setenv("MOAT_HOME", t.TempDir())
+	stubCLI(t)
+	file := KeyProvider{Mode: ModeFile}
+	age := KeyProvider{Mode: ModeAge, AgeIdentity: "/id.txt"}
+
+	key, err := GetOrCreateKeyFor(file)
+	if err != nil {
+		t.Fatalf("creating file key: %v", err)
+	}
+	if err := MigrateKey(file, age); err != nil {
+		t.Fatalf("MigrateKey(file, age): %v", err)
+	}
+	plain, _ := DefaultKeyFilePath()
+	if _, err := os.Stat(plain); !os.IsNotExist(err) {
+		t.Error("plain key file should be removed after migrating to age")
+	}
+	got, err := GetOrCreateKeyFor(age)
+	if err != nil || !bytes.Equal(got, key) {
+		t.Fatalf("key after migration = %x, %v; want the original key", got, err)
+	}
+
+	if err := MigrateKey(age, file); err != nil {
+		t.Fatalf("MigrateKey(age, file): %v", err)
+	}
+	got, err = GetOrCreateKeyFor(file)
+	if err != nil || !bytes.Equal(got, key) {
+		t.Fatalf("key after migrating back = %x, %v; want the original key", got, err)
+	}
+	wrapped, _ := wrappedKeyFilePath(ModeAge)
+	if _, err := os.Stat(wrapped); !os.IsNotExist(err) {
+		t.Error("wrapped key file should be removed after migrating back")
+	}
+}
+
+func TestMigrateBetween(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, KeySize)
+
+	t.Run("no source key", func(t *testing.T) {
+		err := migrateBetween(nil, &mockBackend{}, nil, &mockBackend{})
+		if err == nil || !strings.Contains(err.Error(), "no credential store key") {
+			t.Errorf("migrateBetween = %v, want no key error", err)
+		}
+	})
+
+	t.Run("destination holds a different key", func(t *testing.T) {
+		src := &mockBackend{key: key}
+		dst := &mockBackend{key: bytes.Repeat([]byte{2}, KeySize)}
+		if err := migrateBetween(nil, src, nil, dst); err == nil {
+			t.Error("migrateBetween should refuse to overwrite a different key")
+		}
+		if src.key == nil {
+			t.Error("source key must be kept when migration fails")
+		}
+	})
+
+	t.Run("destination write fails", func(t *testing.T) {
+		src := &mockBackend{key: key}
+		dst := &mockBackend{setErr: errors.New("denied")}
+		if err := migrateBetween(nil, src, nil, dst); err == nil {
+			t.Error("migrateBetween should fail when the destination cannot store the key")
+		}
+	})
+}
+
+func TestMigrateKeySameWrappedMode(t *testing.T) {
+	a := KeyProvider{Mode: ModeAWSKMS, KMSKey: "alias/a"}
+	b := KeyProvider{Mode: ModeAWSKMS, KMSKey: "alias/b"}
+	if err := MigrateKey(a, b); err == nil {
+		t.Error("MigrateKey between two aws-kms keys should fail")
+	}
+}
+
+func TestAWSKMSWrapperRegionFromARN(t *testing.T) {
+	calls := stubCLI(t)
+	w := &awsKMSWrapper{keyID: "arn:aws:kms:eu-west-1:123456789012:key/abc"}
+	if _, err := w.Wrap([]byte("k")); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains((*calls)[0], "--region eu-west-1") {
+		t.Errorf("aws call = %q, want --region eu-west-1", (*calls)[0])
+	}
+
+	*calls = nil
+	w = &awsKMSWrapper{keyID: "alias/moat"}
+	if _, err := w.Wrap([]byte("k")); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains((*calls)[0], "--region") {
+		t.Errorf("aws call = %q, want no --region for an alias", (*calls)[0])
+	}
+}
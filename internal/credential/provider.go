@@ -97,7 +97,8 @@ func GenerateIDTokenPlaceholder(accountID string) string {
 
 // GenerateAccessTokenPlaceholder creates a JWT-formatted access token placeholder.
 // The Codex CLI also validates the access_token as a JWT and extracts claims from it.
-// This placeholder mirrors the structure of a real OpenAI access token.
+// This placeholder mirrors the structure of a real OpenAI access token. The
+// result depends only on accountID, so the proxy can recognize it.
 func GenerateAccessTokenPlaceholder(accountID string) string {
 	// JWT header: {"alg":"RS256","typ":"JWT"}
 	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`))
@@ -108,7 +109,7 @@ func GenerateAccessTokenPlaceholder(accountID string) string {
 		"aud":       []string{"https://api.openai.com/v1"},
 		"client_id": codexCLIClientID,
 		"exp":       9999999999, // Far future expiration
-		"iat":       time.Now().Unix(),
+		"iat":       1700000000, // Fixed so the placeholder is stable for token substitution
 		"iss":       "https://auth.openai.com",
 		"sub":       "moat-proxy-placeholder",
 		"https://api.openai.com/auth": map[string]interface{}{
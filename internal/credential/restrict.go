@@ -0,0 +1,130 @@
+package credential
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/majorcontext/moat/internal/worktree"
+)
+
+// Restriction limits a credential to runs in particular workspaces. A run
+// may use the credential if its workspace is one of Workspaces (or inside
+// one), or if the workspace's origin remote is one of Repos. A nil
+// Restriction allows every run.
+type Restriction struct {
+	// Repos are repository IDs: host/owner/repo, or owner/repo on any host.
+	Repos []string `json:"repos,omitempty"`
+	// Workspaces are absolute directory paths with symlinks resolved.
+	Workspaces []string `json:"workspaces,omitempty"`
+}
+
+// NewRestriction builds a Restriction from --only-repo and --only-workspace
+// values, normalizing each. It returns nil if both are empty.
+func NewRestriction(repos, workspaces []string) (*Restriction, error) {
+	if len(repos) == 0 && len(workspaces) == 0 {
+		return nil, nil
+	}
+	r := &Restriction{}
+	for _, repo := range repos {
+		id, err := normalizeRepo(repo)
+		if err != nil {
+			return nil, err
+		}
+		r.Repos = append(r.Repos, id)
+	}
+	for _, ws := range workspaces {
+		abs, err := filepath.Abs(ws)
+		if err != nil {
+			return nil, fmt.Errorf("workspace %q: %w", ws, err)
+		}
+		resolved, err := filepath.EvalSymlinks(abs)
+		if err != nil {
+			return nil, fmt.Errorf("workspace %q: %w", ws, err)
+		}
+		r.Workspaces = append(r.Workspaces, resolved)
+	}
+	return r, nil
+}
+
+// normalizeRepo accepts owner/repo, host/owner/repo, or a git remote URL and
+// returns the lowercased repository ID.
+func normalizeRepo(repo string) (string, error) {
+	id := strings.TrimSpace(repo)
+	if strings.Contains(id, "://") || strings.HasPrefix(id, "git@") {
+		parsed, err := worktree.ParseRemoteURL(id)
+		if err != nil {
+			return "", fmt.Errorf("repo %q: %w", repo, err)
+		}
+		id = parsed
+	}
+	id = strings.ToLower(strings.Trim(strings.TrimSuffix(id, ".git"), "/"))
+	if strings.Count(id, "/") < 1 || strings.Contains(id, "//") {
+		return "", fmt.Errorf("repo %q: want owner/repo, host/owner/repo, or a remote URL", repo)
+	}
+	return id, nil
+}
+
+// Check returns an error if a run in workspace may not use the credential.
+// The workspace's repository is resolved only when Repos is set.
+func (r *Restriction) Check(workspace string) error {
+	if r == nil {
+		return nil
+	}
+	if r.allowsWorkspace(workspace) {
+		return nil
+	}
+	if len(r.Repos) > 0 {
+		if root, err := worktree.FindRepoRoot(workspace); err == nil {
+			if id, err := worktree.ResolveRepoID(root); err == nil && r.allowsRepo(id) {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("restricted to %s", r)
+}
+
+func (r *Restriction) allowsWorkspace(workspace string) bool {
+	if len(r.Workspaces) == 0 {
+		return false
+	}
+	ws, err := filepath.Abs(workspace)
+	if err != nil {
+		return false
+	}
+	if resolved, err := filepath.EvalSymlinks(ws); err == nil {
+		ws = resolved
+	}
+	for _, allowed := range r.Workspaces {
+		if rel, err := filepath.Rel(allowed, ws); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowsRepo reports whether repoID (host/owner/repo) matches Repos. An
+// entry without a host matches the path after the host.
+func (r *Restriction) allowsRepo(repoID string) bool {
+	repoID = strings.ToLower(repoID)
+	_, path, _ := strings.Cut(repoID, "/")
+	for _, allowed := range r.Repos {
+		if allowed == repoID || allowed == path {
+			return true
+		}
+	}
+	return false
+}
+
+// String describes the restriction for messages, e.g.
+// "repo acme/api, workspace /src/api".
+func (r *Restriction) String() string {
+	var parts []string
+	for _, repo := range r.Repos {
+		parts = append(parts, "repo "+repo)
+	}
+	for _, ws := range r.Workspaces {
+		parts = append(parts, "workspace "+ws)
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,109 @@
+package credential
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNewRestriction(t *testing.T) {
+	r, err := NewRestriction(nil, nil)
+	if err != nil || r != nil {
+		t.Fatalf("NewRestriction(nil, nil) = %v, %v; want nil, nil", r, err)
+	}
+
+	dir := t.TempDir()
+	r, err = NewRestriction([]string{"Acme/API", "github.com/acme/web.git", "git@github.com:acme/cli.git"}, []string{dir})
+	if err != nil {
+		t.Fatalf("NewRestriction: %v", err)
+	}
+	want := []string{"acme/api", "github.com/acme/web", "github.com/acme/cli"}
+	if strings.Join(r.Repos, " ") != strings.Join(want, " ") {
+		t.Errorf("Repos = %v, want %v", r.Repos, want)
+	}
+	if resolved, _ := filepath.EvalSymlinks(dir); len(r.Workspaces) != 1 || r.Workspaces[0] != resolved {
+		t.Errorf("Workspaces = %v, want [%s]", r.Workspaces, resolved)
+	}
+
+	if _, err := NewRestriction([]string{"api"}, nil); err == nil {
+		t.Error("expected error for a repo without an owner")
+	}
+	if _, err := NewRestriction(nil, []string{filepath.Join(dir, "missing")}); err == nil {
+		t.Error("expected error for a workspace that does not exist")
+	}
+}
+
+func TestRestriction_CheckWorkspace(t *testing.T) {
+	allowed := t.TempDir()
+	sub := filepath.Join(allowed, "pkg")
+	if err := os.Mkdir(sub, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewRestriction(nil, []string{allowed})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := r.Check(allowed); err != nil {
+		t.Errorf("Check(allowed) = %v", err)
+	}
+	if err := r.Check(sub); err != nil {
+		t.Errorf("Check(subdirectory) = %v", err)
+	}
+	// A sibling sharing the prefix is not inside the workspace.
+	sibling := allowed + "-other"
+	if err := os.Mkdir(sibling, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Check(sibling); err == nil || !strings.Contains(err.Error(), "restricted to workspace") {
+		t.Errorf("Check(sibling) = %v, want restricted error", err)
+	}
+
+	var none *Restriction
+	if err := none.Check(sibling); err != nil {
+		t.Errorf("nil Restriction Check = %v", err)
+	}
+}
+
+func TestRestriction_CheckRepo(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	repo := t.TempDir()
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"remote", "add", "origin", "git@github.com:Acme/API.git"},
+	} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = repo
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+
+	for _, tt := range []struct {
+		repo  string
+		allow bool
+	}{
+		{"acme/api", true},
+		{"github.com/acme/api", true},
+		{"gitlab.com/acme/api", false},
+		{"acme/web", false},
+	} {
+		r, err := NewRestriction([]string{tt.repo}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := r.Check(repo); (err == nil) != tt.allow {
+			t.Errorf("--only-repo %s: Check = %v, want allowed=%v", tt.repo, err, tt.allow)
+		}
+	}
+
+	// A directory outside any repository has no origin to match.
+	r, _ := NewRestriction([]string{"acme/api"}, nil)
+	if err := r.Check(t.TempDir()); err == nil {
+		t.Error("Check outside a repository allowed a repo-restricted credential")
+	}
+}
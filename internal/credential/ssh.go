@@ -13,6 +13,9 @@ type SSHMapping struct {
 	KeyFingerprint string    `json:"key_fingerprint"`
 	KeyPath        string    `json:"key_path,omitempty"`
 	CreatedAt      time.Time `json:"created_at"`
+	// HostKeys are the host's SSH host keys pinned at grant time, as
+	// known_hosts entries ("github.com ssh-ed25519 AAAA...").
+	HostKeys []string `json:"host_keys,omitempty"`
 }
 
 // sshCredential stores all SSH host-to-key mappings.
@@ -11,6 +11,7 @@ import (
 	"path/filepath"
 	"regexp"
 	"strings"
+	"sync"
 
 	"github.com/majorcontext/moat/internal/config"
 	"github.com/majorcontext/moat/internal/credential/keyring"
@@ -220,7 +221,52 @@ func ListProfiles() ([]string, error) {
 }
 
 // DefaultEncryptionKey retrieves the encryption key from secure storage.
-// Uses system keychain when available, falls back to file-based storage.
+// Uses the key provider selected in the global config (credentials.key_provider);
+// by default the system keychain when available, falling back to file-based storage.
 func DefaultEncryptionKey() ([]byte, error) {
-	return keyring.GetOrCreateKey()
+	p, err := ConfiguredKeyProvider()
+	if err != nil {
+		return nil, err
+	}
+	if p.Mode == "" || p.Mode == keyring.ModeKeychain {
+		return keyring.GetOrCreateKey()
+	}
+	// Unwrapping calls out to age or a KMS, so remember the key for the
+	// life of the process.
+	wrappedKeyCache.mu.Lock()
+	defer wrappedKeyCache.mu.Unlock()
+	if wrappedKeyCache.key != nil && wrappedKeyCache.provider == p {
+		return wrappedKeyCache.key, nil
+	}
+	key, err := keyring.GetOrCreateKeyFor(p)
+	if err != nil {
+		return nil, err
+	}
+	wrappedKeyCache.provider, wrappedKeyCache.key = p, key
+	return key, nil
+}
+
+var wrappedKeyCache struct {
+	mu       sync.Mutex
+	provider keyring.KeyProvider
+	key      []byte
+}
+
+// ConfiguredKeyProvider returns the key provider selected by the global
+// config and its environment overrides.
+func ConfiguredKeyProvider() (keyring.KeyProvider, error) {
+	cfg, err := config.LoadGlobal()
+	if err != nil {
+		return keyring.KeyProvider{}, err
+	}
+	p := keyring.KeyProvider{
+		Mode:        cfg.Credentials.KeyProvider,
+		AgeIdentity: cfg.Credentials.AgeIdentity,
+		KMSKey:      cfg.Credentials.KMSKey,
+	}
+	if err := p.Validate(); err != nil {
+		return keyring.KeyProvider{}, fmt.Errorf("credentials.key_provider in %s: %w",
+			filepath.Join(config.GlobalConfigDir(), "config.yaml"), err)
+	}
+	return p, nil
 }
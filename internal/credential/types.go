@@ -24,6 +24,20 @@ const (
 	ProviderNpm       Provider = "npm"
 	ProviderGraphite  Provider = "graphite"
 	ProviderMeta      Provider = "meta"
+
+	ProviderGerrit            Provider = "gerrit"
+	ProviderBitbucketServer   Provider = "bitbucket-server"
+	ProviderAtlassian         Provider = "atlassian"
+	ProviderRegistry          Provider = "registry"
+	ProviderContainerRegistry Provider = "container-registry"
+	ProviderAzureDevOps       Provider = "azure-devops"
+	ProviderAzure             Provider = "azure"
+	ProviderSnowflake         Provider = "snowflake"
+	ProviderBigQuery          Provider = "bigquery"
+	ProviderGCP               Provider = "gcp"
+	ProviderStripe            Provider = "stripe"
+	ProviderTwilio            Provider = "twilio"
+	ProviderSendGrid          Provider = "sendgrid"
 )
 
 // Credential represents a stored credential.
@@ -34,6 +48,10 @@ type Credential struct {
 	ExpiresAt time.Time         `json:"expires_at,omitempty"`
 	CreatedAt time.Time         `json:"created_at"`
 	Metadata  map[string]string `json:"metadata,omitempty"` // Provider-specific extra data
+
+	// Restrict limits which workspaces' runs may use the credential.
+	// Nil allows every run.
+	Restrict *Restriction `json:"restrict,omitempty"`
 }
 
 // Store defines the credential storage interface.
@@ -55,14 +73,14 @@ func RegisterDynamicProvider(p Provider) {
 
 // KnownProviders returns a list of all known credential providers.
 func KnownProviders() []Provider {
-	base := []Provider{ProviderGitHub, ProviderAWS, ProviderAnthropic, ProviderClaude, ProviderOpenAI, ProviderGemini, ProviderNpm, ProviderGraphite, ProviderMeta}
+	base := []Provider{ProviderGitHub, ProviderAWS, ProviderAnthropic, ProviderClaude, ProviderOpenAI, ProviderGemini, ProviderNpm, ProviderRegistry, ProviderContainerRegistry, ProviderGraphite, ProviderMeta, ProviderGerrit, ProviderBitbucketServer, ProviderAtlassian, ProviderAzureDevOps, ProviderAzure, ProviderSnowflake, ProviderBigQuery, ProviderGCP, ProviderStripe, ProviderTwilio, ProviderSendGrid}
 	return append(base, dynamicProviders...)
 }
 
 // IsKnownProvider returns true if the provider is a known credential provider.
 func IsKnownProvider(p Provider) bool {
 	switch p {
-	case ProviderGitHub, ProviderAWS, ProviderAnthropic, ProviderClaude, ProviderOpenAI, ProviderGemini, ProviderNpm, ProviderGraphite, ProviderMeta:
+	case ProviderGitHub, ProviderAWS, ProviderAnthropic, ProviderClaude, ProviderOpenAI, ProviderGemini, ProviderNpm, ProviderRegistry, ProviderContainerRegistry, ProviderGraphite, ProviderMeta, ProviderGerrit, ProviderBitbucketServer, ProviderAtlassian, ProviderAzureDevOps, ProviderAzure, ProviderSnowflake, ProviderBigQuery, ProviderGCP, ProviderStripe, ProviderTwilio, ProviderSendGrid:
 		return true
 	default:
 		for _, dp := range dynamicProviders {
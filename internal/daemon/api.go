@@ -15,11 +15,20 @@
 //     handle 404 gracefully when talking to an older daemon.
 //   - Never change the semantics of existing fields.
 //
+// Every endpoint is listed in one table (schema.go) that both registers the
+// routes and generates the OpenAPI document served at GET /v1/openapi.json,
+// so the schema is always the one the daemon actually serves. Requests and
+// responses carry the major API version in the Moat-Api-Version header; a
+// daemon rejects a request for a major version it does not serve. Bumping
+// APIVersion is reserved for breaks the rules above forbid.
+//
 // When adding new API surface, consider: "will a CLI built today still work
 // if the daemon is an older binary?" and vice versa.
 package daemon
 
 import (
+	"time"
+
 	"github.com/majorcontext/moat/internal/config"
 	"github.com/majorcontext/moat/internal/netrules"
 )
@@ -37,6 +46,7 @@ type ExtraHeaderSpec struct {
 	Host       string `json:"host"`
 	HeaderName string `json:"header_name"`
 	Value      string `json:"value"`
+	Grant      string `json:"grant,omitempty"`
 }
 
 // TokenSubstitutionSpec describes a token substitution.
@@ -44,6 +54,7 @@ type TokenSubstitutionSpec struct {
 	Host        string `json:"host"`
 	Placeholder string `json:"placeholder"`
 	RealToken   string `json:"real_token"`
+	Grant       string `json:"grant,omitempty"`
 }
 
 // RemoveHeaderSpec describes a header to remove from requests.
@@ -54,10 +65,15 @@ type RemoveHeaderSpec struct {
 
 // TransformerSpec describes a response transformer to apply for a host.
 // Since transformers are Go functions (not serializable), this spec allows
-// the daemon to reconstruct them from well-known kinds.
+// the daemon to reconstruct them from kinds in its transformer registry
+// (see RegisterTransformer).
 type TransformerSpec struct {
-	Host string `json:"host"`
-	Kind string `json:"kind"` // "oauth-endpoint-workaround" or "response-scrub"
+	Host string            `json:"host"`
+	Kind string            `json:"kind"`           // e.g. "oauth-endpoint-workaround", "response-header-set"
+	Args map[string]string `json:"args,omitempty"` // kind-specific arguments
+	// Grant is the grant that registered the transformer, if any; it is
+	// removed when the grant is revoked.
+	Grant string `json:"grant,omitempty"`
 }
 
 // RegisterRequest is sent to POST /v1/runs.
@@ -74,18 +90,30 @@ type RegisterRequest struct {
 	NetworkRules         []netrules.HostRules     `json:"network_rules,omitempty"`
 	Grants               []string                 `json:"grants,omitempty"`
 	AWSConfig            *AWSConfig               `json:"aws_config,omitempty"`
+	AzureConfig          *AzureConfig             `json:"azure_config,omitempty"`
+	GCPConfig            *GCPConfig               `json:"gcp_config,omitempty"`
 	ResponseTransformers []TransformerSpec        `json:"response_transformers,omitempty"`
 	// CredProfile is the credential profile the run was created under. The
 	// daemon scopes token refresh to it. Additive/optional: an older CLI omits
 	// it and the daemon falls back to the default profile (prior behavior).
 	// Named to match RunContext/PersistedRun and avoid confusion with
 	// AWSConfig.Profile (an AWS shared-config profile).
-	CredProfile      string              `json:"cred_profile,omitempty"`
-	PolicyYAML       map[string][]byte   `json:"policy_yaml,omitempty"`
-	PolicyRuleSets   []PolicyRuleSetSpec `json:"policy_rule_sets,omitempty"`
-	HostGateway      string              `json:"host_gateway,omitempty"`
-	HostGatewayIP    string              `json:"host_gateway_ip,omitempty"`
-	AllowedHostPorts []int               `json:"allowed_host_ports,omitempty"`
+	CredProfile      string               `json:"cred_profile,omitempty"`
+	PolicyYAML       map[string][]byte    `json:"policy_yaml,omitempty"`
+	PolicyRuleSets   []PolicyRuleSetSpec  `json:"policy_rule_sets,omitempty"`
+	HostGateway      string               `json:"host_gateway,omitempty"`
+	HostGatewayIP    string               `json:"host_gateway_ip,omitempty"`
+	AllowedHostPorts []int                `json:"allowed_host_ports,omitempty"`
+	Mirror           *config.MirrorConfig `json:"mirror,omitempty"`
+	SendGuard        *SendGuard           `json:"send_guard,omitempty"`
+	UploadGuard      *UploadGuard         `json:"upload_guard,omitempty"`
+	RequestGuards    []RequestGuard       `json:"request_guards,omitempty"`
+	Faults           []config.FaultConfig `json:"faults,omitempty"`
+	Scrub            *config.ScrubConfig  `json:"scrub,omitempty"`
+	Capture          string               `json:"capture,omitempty"`
+	// Workspace is the run's host workspace path. The daemon checks grants
+	// restricted to particular workspaces or repositories against it.
+	Workspace string `json:"workspace,omitempty"`
 }
 
 // PolicyRuleSetSpec describes a programmatic policy using Keep's RuleSet builder.
@@ -107,6 +135,14 @@ type RegisterResponse struct {
 // UpdateRunRequest is sent to PATCH /v1/runs/{token}.
 type UpdateRunRequest struct {
 	ContainerID string `json:"container_id"`
+
+	// Runtime is the container runtime that owns the run's containers
+	// ("docker", "podman", "apple", or "kubernetes").
+	Runtime string `json:"runtime,omitempty"`
+
+	// ServiceContainers maps each service the run depends on (e.g.
+	// "postgres") to its container ID.
+	ServiceContainers map[string]string `json:"service_containers,omitempty"`
 }
 
 // Daemon capability identifiers advertised in HealthResponse.Capabilities and
@@ -116,9 +152,36 @@ type UpdateRunRequest struct {
 // literal — keep them as shared constants to prevent an advertise/check typo
 // from silently disabling a capability gate. Add, never rename or remove.
 const (
-	CapKeepPolicy     = "keep-policy"
-	CapKeepBodyPolicy = "keep-body-policy"
-	CapHostGatewayV2  = "host-gateway-v2"
+	CapKeepPolicy            = "keep-policy"
+	CapKeepBodyPolicy        = "keep-body-policy"
+	CapHostGatewayV2         = "host-gateway-v2"
+	CapRequestMirror         = "request-mirror"
+	CapTransformers          = "transformer-registry"
+	CapRequestStream         = "request-stream"
+	CapAzureIdentity         = "azure-identity"
+	CapStripeLiveMode        = "stripe-live-mode"
+	CapSendGuard             = "send-guard"
+	CapFaults                = "fault-injection"
+	CapAzureServicePrincipal = "azure-service-principal"
+	CapGCPMetadata           = "gcp-metadata"
+	CapLogStream             = "log-stream"
+	CapNetworkCIDR           = "network-cidr"
+	CapRouteList             = "route-list"
+	CapMoatctl               = "moatctl"
+	CapClip                  = "clip"
+	CapClaudeCloud           = "claude-cloud"
+	CapOpenAPI               = "openapi"
+	CapEventStream           = "event-stream"
+	CapRunQueue              = "run-queue"
+	CapResponseScrub         = "response-scrub-policy"
+	CapUploadGuard           = "upload-guard"
+	CapNetworkCapture        = "network-capture"
+	CapStats                 = "stats"
+	CapRequestGuards         = "request-guards"
+	CapMirrorReplay          = "mirror-replay"
+	CapRequestTransforms     = "request-transforms"
+	CapGrantScope            = "grant-scope"
+	CapStarlarkTransforms    = "starlark-transforms"
 )
 
 // HealthResponse is returned from GET /v1/health.
@@ -129,6 +192,22 @@ type HealthResponse struct {
 	StartedAt    string   `json:"started_at"`
 	Commit       string   `json:"commit,omitempty"`       // Git commit hash of the daemon binary
 	Capabilities []string `json:"capabilities,omitempty"` // Feature capabilities supported by this daemon
+	// APIVersion is the major API version the daemon serves. Zero from a
+	// daemon that predates versioning, which serves version 1.
+	APIVersion int `json:"api_version,omitempty"`
+
+	// Quotas are the daily LLM quotas the daemon enforces, by provider,
+	// with today's usage.
+	Quotas map[string]QuotaStatus `json:"quotas,omitempty"`
+}
+
+// ServesAPIVersion reports whether the daemon serves major API version v.
+// A daemon that predates versioning reports no version and serves 1.
+func (h *HealthResponse) ServesAPIVersion(v int) bool {
+	if h.APIVersion == 0 {
+		return v == 1
+	}
+	return h.APIVersion == v
 }
 
 // RunInfo is an element of the list returned by GET /v1/runs.
@@ -138,11 +217,127 @@ type RunInfo struct {
 	RegisteredAt string `json:"registered_at"`
 }
 
+// RunStats is an element of the list returned by GET /v1/stats: the
+// resource usage of a run's container and of its service containers.
+type RunStats struct {
+	RunID string `json:"run_id"`
+	// Containers lists the run's own container first, then its services
+	// sorted by name.
+	Containers []ContainerStats `json:"containers"`
+}
+
+// ContainerStats is a sample of one container's resource usage. CPU and
+// network rates are measured since the daemon's previous sample of the
+// container, and are zero in the first sample.
+type ContainerStats struct {
+	Service          string    `json:"service,omitempty"` // empty for the run's own container
+	ContainerID      string    `json:"container_id"`
+	SampledAt        time.Time `json:"sampled_at,omitzero"`
+	CPUPercent       float64   `json:"cpu_percent"` // percent of one CPU
+	MemoryBytes      uint64    `json:"memory_bytes"`
+	MemoryLimitBytes uint64    `json:"memory_limit_bytes,omitempty"`
+	NetRxBytes       uint64    `json:"net_rx_bytes"`
+	NetTxBytes       uint64    `json:"net_tx_bytes"`
+	NetRxRate        float64   `json:"net_rx_rate"` // bytes per second
+	NetTxRate        float64   `json:"net_tx_rate"` // bytes per second
+	Error            string    `json:"error,omitempty"`
+}
+
 // RouteRegistration is sent to POST /v1/routes/{agent}.
 type RouteRegistration struct {
 	Services map[string]string `json:"services"`
 }
 
+// RouteInfo is an element of the list returned by GET /v1/routes.
+type RouteInfo struct {
+	Agent    string            `json:"agent"`
+	Services map[string]string `json:"services"` // endpoint → host:port
+}
+
+// Approval is a request, made from inside a run with `moatctl approve`, to
+// allow a host the run's network policy blocks. It is an element of the list
+// returned by GET /v1/approvals.
+type Approval struct {
+	ID          string    `json:"id"`
+	RunID       string    `json:"run_id"`
+	Host        string    `json:"host"`
+	Reason      string    `json:"reason,omitempty"`
+	Status      string    `json:"status"` // ApprovalPending, ApprovalApproved, or ApprovalDenied
+	RequestedAt time.Time `json:"requested_at"`
+	DecidedAt   time.Time `json:"decided_at,omitzero"`
+}
+
+// ApprovalDecision is sent to POST /v1/approvals/{id}.
+type ApprovalDecision struct {
+	Approve bool `json:"approve"`
+}
+
+// Clip is text, an image, or a file handed between a run and the host. Clips
+// offered by a run with `moatctl clip` are listed by GET /v1/clips until the
+// user accepts or rejects them; clips sent by the host with POST /v1/clips
+// wait for `moatctl paste`.
+type Clip struct {
+	ID        string    `json:"id"`
+	RunID     string    `json:"run_id"`
+	Name      string    `json:"name,omitempty"` // file name; empty for clipboard content
+	MIMEType  string    `json:"mime_type"`
+	Data      []byte    `json:"data"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ClipDecision is sent to POST /v1/clips/{id}.
+type ClipDecision struct {
+	Accept bool `json:"accept"`
+}
+
+// QueueRequest is sent to POST /v1/queue to wait for a run slot.
+type QueueRequest struct {
+	Name      string `json:"name,omitempty"` // run name, if known before the run is created
+	Workspace string `json:"workspace"`
+}
+
+// Queue slot states, the State of a QueueSlot or QueueStatus.
+const (
+	SlotQueued  = "queued"
+	SlotRunning = "running"
+)
+
+// QueueStatus is streamed by POST /v1/queue: whenever a waiting slot moves,
+// and once when it is granted.
+type QueueStatus struct {
+	Ticket   string `json:"ticket"`
+	State    string `json:"state"`              // SlotQueued or SlotRunning
+	Position int    `json:"position,omitempty"` // 1-based place in the queue while queued
+	Running  int    `json:"running"`            // slots held machine-wide
+	// Limit names the limit the slot is waiting on: max_runs or
+	// max_runs_per_workspace.
+	Limit string `json:"limit,omitempty"`
+}
+
+// QueueSlot is a running or queued slot in QueueInfo.
+type QueueSlot struct {
+	Ticket    string    `json:"ticket"`
+	State     string    `json:"state"`
+	RunID     string    `json:"run_id,omitempty"`
+	Name      string    `json:"name,omitempty"`
+	Workspace string    `json:"workspace"`
+	Since     time.Time `json:"since"` // when the slot was queued, or granted once running
+}
+
+// QueueInfo is returned from GET /v1/queue.
+type QueueInfo struct {
+	config.ConcurrencyConfig
+	Running []QueueSlot `json:"running"`
+	Queued  []QueueSlot `json:"queued"`
+}
+
+// QueueSlotUpdate is sent to PATCH /v1/queue/{ticket} once the run holding
+// the slot has been created.
+type QueueSlotUpdate struct {
+	RunID string `json:"run_id"`
+	Name  string `json:"name,omitempty"`
+}
+
 // ToRunContext converts a RegisterRequest into a RunContext.
 func (req *RegisterRequest) ToRunContext() *RunContext {
 	rc := NewRunContext(req.RunID)
@@ -150,19 +345,21 @@ func (req *RegisterRequest) ToRunContext() *RunContext {
 		rc.SetCredentialWithGrant(c.Host, c.Header, c.Value, c.Grant)
 	}
 	for _, h := range req.ExtraHeaders {
-		rc.AddExtraHeader(h.Host, h.HeaderName, h.Value)
+		rc.addExtraHeader(h.Host, ExtraHeaderEntry{Name: h.HeaderName, Value: h.Value, Grant: h.Grant})
 	}
 	for _, r := range req.RemoveHeaders {
 		rc.RemoveRequestHeader(r.Host, r.HeaderName)
 	}
 	for _, ts := range req.TokenSubstitutions {
-		rc.SetTokenSubstitution(ts.Host, ts.Placeholder, ts.RealToken)
+		rc.setTokenSubstitution(ts.Host, TokenSubstitutionEntry{Placeholder: ts.Placeholder, RealToken: ts.RealToken, Grant: ts.Grant})
 	}
 	rc.MCPServers = req.MCPServers
 	rc.NetworkPolicy = req.NetworkPolicy
 	rc.NetworkAllow = req.NetworkAllow
 	rc.NetworkRules = req.NetworkRules
 	rc.AWSConfig = req.AWSConfig
+	rc.AzureConfig = req.AzureConfig
+	rc.GCPConfig = req.GCPConfig
 	rc.Grants = req.Grants
 	rc.CredProfile = req.CredProfile
 	rc.TransformerSpecs = req.ResponseTransformers
@@ -172,5 +369,13 @@ func (req *RegisterRequest) ToRunContext() *RunContext {
 		rc.AllowedHostPorts = make([]int, len(req.AllowedHostPorts))
 		copy(rc.AllowedHostPorts, req.AllowedHostPorts)
 	}
+	rc.Mirror = req.Mirror
+	rc.SendGuard = req.SendGuard
+	rc.UploadGuard = req.UploadGuard
+	rc.RequestGuards = req.RequestGuards
+	rc.Faults = req.Faults
+	rc.Scrub = req.Scrub
+	rc.Capture = req.Capture
+	rc.Workspace = req.Workspace
 	return rc
 }
@@ -288,6 +288,7 @@ func TestToRunContext(t *testing.T) {
 			RoleARN: "arn:aws:iam::123456789012:role/test",
 			Region:  "us-west-2",
 		},
+		AzureConfig: &AzureConfig{Tenant: "tenant-1"},
 	}
 
 	rc := req.ToRunContext()
@@ -367,4 +368,9 @@ func TestToRunContext(t *testing.T) {
 	if rc.AWSConfig.Region != "us-west-2" {
 		t.Errorf("AWSConfig.Region: got %q, want %q", rc.AWSConfig.Region, "us-west-2")
 	}
+
+	// Verify Azure config.
+	if rc.AzureConfig == nil || rc.AzureConfig.Tenant != "tenant-1" {
+		t.Errorf("AzureConfig: got %+v, want tenant tenant-1", rc.AzureConfig)
+	}
 }
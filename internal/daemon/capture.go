@@ -0,0 +1,224 @@
+package daemon
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/majorcontext/gatekeeper/proxy"
+	"github.com/majorcontext/moat/internal/config"
+	"github.com/majorcontext/moat/internal/storage"
+)
+
+// redacted replaces credential values in the network trace.
+const redacted = "[REDACTED]"
+
+// sensitiveNameParts mark a header, query parameter, or body field whose
+// value is a credential. Names are compared lower-cased; a name ending in
+// "token" is also sensitive, but not one like "max_tokens".
+var sensitiveNameParts = []string{
+	"authorization", "cookie", "secret", "password", "passwd",
+	"api-key", "api_key", "apikey", "private_key", "credential", "signature",
+}
+
+// sensitiveExactNames are short names that would match too much as parts.
+var sensitiveExactNames = map[string]bool{"key": true, "sig": true, "code": true, "auth": true}
+
+// tokenPatterns match well-known credential formats anywhere in a captured
+// body, such as a key an agent pasted into a prompt.
+var tokenPatterns = regexp.MustCompile(
+	`sk-ant-[A-Za-z0-9_-]{20,}` +
+		`|sk-(?:proj-)?[A-Za-z0-9_-]{20,}` +
+		`|gh[pousr]_[A-Za-z0-9]{30,}` +
+		`|github_pat_[A-Za-z0-9_]{30,}` +
+		`|xox[abpr]-[A-Za-z0-9-]{10,}` +
+		`|AKIA[0-9A-Z]{16}` +
+		`|AIza[0-9A-Za-z_-]{35}` +
+		`|(?i:bearer)\s+[A-Za-z0-9._~+/=-]{16,}`)
+
+// NewNetworkRequest builds the network trace entry for a proxied request,
+// recording what the run's network.capture mode asks for. By default that is
+// the request line, status, and timing, plus the body of error responses,
+// which moat reads to explain failed runs. In full mode, headers and the
+// proxy's body samples are recorded too. Credentials are redacted in either
+// mode: injected headers, the run's credential values, credential-bearing
+// headers, query parameters, and body fields, and well-known token formats.
+// A request refused for its body size records the upload cap it exceeded.
+// rc may be nil if the run has since unregistered.
+func NewNetworkRequest(rc *RunContext, data proxy.RequestLogData) storage.NetworkRequest {
+	req := storage.NetworkRequest{
+		Timestamp:   time.Now().UTC(),
+		Method:      data.Method,
+		StatusCode:  data.StatusCode,
+		Duration:    data.Duration.Milliseconds(),
+		Denied:      data.Denied,
+		DenyReason:  data.DenyReason,
+		RequestSize: max(data.RequestSize, 0),
+		UploadLimit: uploadLimit(rc, data),
+	}
+	if data.Err != nil {
+		req.Error = data.Err.Error()
+	}
+
+	var mode string
+	var secrets []string
+	if rc != nil {
+		mode, secrets = rc.captureSettings()
+	}
+	r := redactor{secrets: secrets}
+	req.URL = r.url(data.URL)
+
+	if mode == config.NetworkCaptureFull {
+		req.RequestHeaders = r.headers(proxy.FilterHeaders(data.RequestHeaders, data.InjectedHeaders))
+		req.ResponseHeaders = r.headers(proxy.FilterHeaders(data.ResponseHeaders, nil))
+		req.RequestBody = r.body(data.RequestBody, data.RequestHeaders)
+		req.ResponseBody = r.body(data.ResponseBody, data.ResponseHeaders)
+		req.BodyTruncated = len(data.RequestBody) >= proxy.MaxBodySize || len(data.ResponseBody) >= proxy.MaxBodySize
+	} else if data.StatusCode >= 400 {
+		req.ResponseBody = r.body(data.ResponseBody, data.ResponseHeaders)
+		req.BodyTruncated = len(data.ResponseBody) >= proxy.MaxBodySize
+	}
+	return req
+}
+
+// captureSettings returns the run's capture mode and the credential values
+// the proxy injects for it, which must never appear in the trace.
+func (rc *RunContext) captureSettings() (string, []string) {
+	rc.mu.RLock()
+	defer rc.mu.RUnlock()
+	var secrets []string
+	add := func(v string) {
+		// Short values would redact ordinary text.
+		if len(v) >= 8 {
+			secrets = append(secrets, v)
+		}
+	}
+	for _, entries := range rc.Credentials {
+		for _, c := range entries {
+			add(c.Value)
+			// "Bearer <token>", "Basic <creds>": the token alone may be echoed.
+			if _, token, ok := strings.Cut(c.Value, " "); ok {
+				add(token)
+			}
+		}
+	}
+	for _, ts := range rc.TokenSubstitutions {
+		add(ts.RealToken)
+	}
+	return rc.Capture, secrets
+}
+
+// redactor removes credentials from captured request data.
+type redactor struct {
+	secrets []string
+}
+
+// isSensitiveName reports whether a header, parameter, or field name
+// carries a credential.
+func isSensitiveName(name string) bool {
+	name = strings.ToLower(name)
+	if sensitiveExactNames[name] || strings.HasSuffix(name, "token") {
+		return true
+	}
+	for _, part := range sensitiveNameParts {
+		if strings.Contains(name, part) {
+			return true
+		}
+	}
+	return false
+}
+
+// text redacts the run's credential values and well-known token formats.
+func (r redactor) text(s string) string {
+	for _, secret := range r.secrets {
+		s = strings.ReplaceAll(s, secret, redacted)
+	}
+	return tokenPatterns.ReplaceAllString(s, redacted)
+}
+
+func (r redactor) url(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return r.text(raw)
+	}
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), redacted)
+	}
+	if u.RawQuery != "" {
+		q := u.Query()
+		for name := range q {
+			if isSensitiveName(name) {
+				q[name] = []string{redacted}
+			}
+		}
+		u.RawQuery = q.Encode()
+	}
+	// Encoding escapes the brackets; keep the marker readable.
+	return r.text(strings.ReplaceAll(u.String(), url.QueryEscape(redacted), redacted))
+}
+
+func (r redactor) headers(h map[string]string) map[string]string {
+	for name, value := range h {
+		if isSensitiveName(name) {
+			h[name] = redacted
+		} else {
+			h[name] = r.text(value)
+		}
+	}
+	return h
+}
+
+// body redacts a captured body. JSON and form bodies have the values of
+// credential-named fields replaced; every body is then scanned for the
+// run's credential values and well-known token formats. A truncated JSON
+// body does not parse and gets only the scan.
+func (r redactor) body(b []byte, h http.Header) string {
+	if len(b) == 0 {
+		return ""
+	}
+	ct := strings.ToLower(h.Get("Content-Type"))
+	switch {
+	case strings.Contains(ct, "json"):
+		var v any
+		dec := json.NewDecoder(bytes.NewReader(b))
+		dec.UseNumber()
+		if dec.Decode(&v) == nil {
+			if out, err := json.Marshal(redactJSON(v)); err == nil {
+				b = out
+			}
+		}
+	case strings.Contains(ct, "x-www-form-urlencoded"):
+		if q, err := url.ParseQuery(string(b)); err == nil {
+			for name := range q {
+				if isSensitiveName(name) {
+					q[name] = []string{redacted}
+				}
+			}
+			b = []byte(strings.ReplaceAll(q.Encode(), url.QueryEscape(redacted), redacted))
+		}
+	}
+	return r.text(string(b))
+}
+
+// redactJSON replaces the values of credential-named object fields.
+func redactJSON(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, child := range v {
+			if _, isString := child.(string); isString && isSensitiveName(k) {
+				v[k] = redacted
+			} else {
+				v[k] = redactJSON(child)
+			}
+		}
+	case []any:
+		for i, child := range v {
+			v[i] = redactJSON(child)
+		}
+	}
+	return v
+}
@@ -6,13 +6,29 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/majorcontext/moat/internal/errcode"
+	"github.com/majorcontext/moat/internal/storage"
 )
 
 // ErrRunNotFound is returned when a run is not registered with the daemon.
 var ErrRunNotFound = errors.New("run not found")
 
+// ErrStreamUnsupported is returned by StreamRequests and StreamLogs when the
+// daemon is too old to stream.
+var ErrStreamUnsupported = errors.New("daemon does not support request streaming")
+
+func init() {
+	errcode.Register(ErrRunNotFound, errcode.RunNotFound)
+	errcode.Register(ErrStreamUnsupported, errcode.DaemonStreamUnsupported)
+}
+
 // Client communicates with the daemon over a Unix socket.
 type Client struct {
 	sockPath   string
@@ -24,15 +40,26 @@ func NewClient(sockPath string) *Client {
 	return &Client{
 		sockPath: sockPath,
 		httpClient: &http.Client{
-			Transport: &http.Transport{
+			Transport: versionTransport{&http.Transport{
 				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
 					return (&net.Dialer{}).DialContext(ctx, "unix", sockPath)
 				},
-			},
+			}},
 		},
 	}
 }
 
+// versionTransport sends the client's API version with every request.
+type versionTransport struct {
+	base http.RoundTripper
+}
+
+func (t versionTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set(APIVersionHeader, strconv.Itoa(APIVersion))
+	return t.base.RoundTrip(req)
+}
+
 // Health returns the daemon's health status.
 func (c *Client) Health(ctx context.Context) (*HealthResponse, error) {
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://daemon/v1/health", nil)
@@ -41,7 +68,7 @@ func (c *Client) Health(ctx context.Context) (*HealthResponse, error) {
 	}
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("connecting to daemon: %w", err)
+		return nil, errcode.Wrap(errcode.DaemonUnavailable, fmt.Errorf("connecting to daemon: %w", err))
 	}
 	defer resp.Body.Close()
 	var health HealthResponse
@@ -51,6 +78,28 @@ func (c *Client) Health(ctx context.Context) (*HealthResponse, error) {
 	return &health, nil
 }
 
+// OpenAPI returns the OpenAPI document the daemon serves. Unlike the
+// package-level OpenAPI, it describes the daemon's binary, which may be
+// older or newer than the caller's.
+func (c *Client) OpenAPI(ctx context.Context) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://daemon/v1/openapi.json", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, errcode.Wrap(errcode.DaemonUnavailable, fmt.Errorf("connecting to daemon: %w", err))
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("daemon predates the OpenAPI endpoint; restart it with 'moat proxy restart'")
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("daemon returned %d", resp.StatusCode)
+	}
+	return io.ReadAll(resp.Body)
+}
+
 // RegisterRun registers a new run with the daemon.
 func (c *Client) RegisterRun(ctx context.Context, regReq RegisterRequest) (*RegisterResponse, error) {
 	body, err := json.Marshal(regReq)
@@ -64,10 +113,14 @@ func (c *Client) RegisterRun(ctx context.Context, regReq RegisterRequest) (*Regi
 	req.Header.Set("Content-Type", "application/json")
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("connecting to daemon: %w", err)
+		return nil, errcode.Wrap(errcode.DaemonUnavailable, fmt.Errorf("connecting to daemon: %w", err))
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		var errResp RegisterResponse
+		if json.NewDecoder(resp.Body).Decode(&errResp) == nil && errResp.Error != "" {
+			return nil, fmt.Errorf("daemon returned %d: %s", resp.StatusCode, errResp.Error)
+		}
 		return nil, fmt.Errorf("daemon returned %d", resp.StatusCode)
 	}
 	var regResp RegisterResponse
@@ -77,9 +130,10 @@ func (c *Client) RegisterRun(ctx context.Context, regReq RegisterRequest) (*Regi
 	return &regResp, nil
 }
 
-// UpdateRun updates a run's container ID (phase 2 of registration).
-func (c *Client) UpdateRun(ctx context.Context, token, containerID string) error {
-	body, err := json.Marshal(UpdateRunRequest{ContainerID: containerID})
+// UpdateRun records a run's container ID, runtime, and service containers
+// (phase 2 of registration).
+func (c *Client) UpdateRun(ctx context.Context, token string, update UpdateRunRequest) error {
+	body, err := json.Marshal(update)
 	if err != nil {
 		return err
 	}
@@ -90,7 +144,7 @@ func (c *Client) UpdateRun(ctx context.Context, token, containerID string) error
 	req.Header.Set("Content-Type", "application/json")
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return fmt.Errorf("connecting to daemon: %w", err)
+		return errcode.Wrap(errcode.DaemonUnavailable, fmt.Errorf("connecting to daemon: %w", err))
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode == http.StatusNotFound {
@@ -110,7 +164,7 @@ func (c *Client) UnregisterRun(ctx context.Context, token string) error {
 	}
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return fmt.Errorf("connecting to daemon: %w", err)
+		return errcode.Wrap(errcode.DaemonUnavailable, fmt.Errorf("connecting to daemon: %w", err))
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusNoContent {
@@ -127,7 +181,7 @@ func (c *Client) ListRuns(ctx context.Context) ([]RunInfo, error) {
 	}
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("connecting to daemon: %w", err)
+		return nil, errcode.Wrap(errcode.DaemonUnavailable, fmt.Errorf("connecting to daemon: %w", err))
 	}
 	defer resp.Body.Close()
 	var runs []RunInfo
@@ -137,6 +191,451 @@ func (c *Client) ListRuns(ctx context.Context) ([]RunInfo, error) {
 	return runs, nil
 }
 
+// StreamRequests calls fn for each request the proxy handles for runID until
+// ctx is canceled or the run unregisters (which returns nil). Returns
+// ErrRunNotFound if the run is not registered, and ErrStreamUnsupported if
+// the daemon predates the request stream.
+func (c *Client) StreamRequests(ctx context.Context, runID string, fn func(RequestEvent)) error {
+	u := "http://daemon/v1/requests?run_id=" + url.QueryEscape(runID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return errcode.Wrap(errcode.DaemonUnavailable, fmt.Errorf("connecting to daemon: %w", err))
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		// An older daemon has no such route; its mux 404s with a generic
+		// body rather than our "run not found" error.
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		if strings.Contains(string(body), "run not found") {
+			return ErrRunNotFound
+		}
+		return ErrStreamUnsupported
+	default:
+		return fmt.Errorf("daemon returned %d", resp.StatusCode)
+	}
+
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var ev RequestEvent
+		if err := dec.Decode(&ev); err != nil {
+			if ctx.Err() != nil || errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("reading request stream: %w", err)
+		}
+		fn(ev)
+	}
+}
+
+// StreamEvents calls fn for each run, approval, and clip event until ctx
+// is done. An empty runID streams every run. It returns
+// ErrStreamUnsupported if the daemon predates the event stream.
+func (c *Client) StreamEvents(ctx context.Context, runID string, fn func(RunEvent)) error {
+	u := "http://daemon/v1/events"
+	if runID != "" {
+		u += "?run_id=" + url.QueryEscape(runID)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return errcode.Wrap(errcode.DaemonUnavailable, fmt.Errorf("connecting to daemon: %w", err))
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return ErrStreamUnsupported
+	default:
+		return fmt.Errorf("daemon returned %d", resp.StatusCode)
+	}
+
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var ev RunEvent
+		if err := dec.Decode(&ev); err != nil {
+			if ctx.Err() != nil || errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("reading event stream: %w", err)
+		}
+		fn(ev)
+	}
+}
+
+// ErrQueueUnsupported is returned by Enqueue and ListQueue when the daemon
+// predates the run queue.
+var ErrQueueUnsupported = errors.New("daemon does not support the run queue")
+
+// Slot is a run slot granted by Enqueue. The daemon holds it for the caller
+// until Release, or until the caller's process exits.
+type Slot struct {
+	Ticket string
+	body   io.Closer
+	cancel context.CancelFunc
+}
+
+// Release gives the slot back to the daemon.
+func (s *Slot) Release() {
+	s.cancel()
+	_ = s.body.Close()
+}
+
+// Enqueue waits for a run slot under the daemon's concurrency limits,
+// calling fn with each status update while the slot is queued. It returns
+// once the slot is granted; canceling ctx before then gives up the place in
+// the queue. The granted slot outlives ctx and is held until Release.
+func (c *Client) Enqueue(ctx context.Context, qr QueueRequest, fn func(QueueStatus)) (*Slot, error) {
+	body, err := json.Marshal(qr)
+	if err != nil {
+		return nil, err
+	}
+	holdCtx, cancel := context.WithCancel(context.Background())
+	stop := context.AfterFunc(ctx, cancel)
+	req, err := http.NewRequestWithContext(holdCtx, http.MethodPost, "http://daemon/v1/queue", bytes.NewReader(body))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		cancel()
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, errcode.Wrap(errcode.DaemonUnavailable, fmt.Errorf("connecting to daemon: %w", err))
+	}
+	fail := func(err error) (*Slot, error) {
+		cancel()
+		resp.Body.Close()
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound, http.StatusMethodNotAllowed:
+		return fail(ErrQueueUnsupported)
+	default:
+		return fail(fmt.Errorf("daemon returned %d", resp.StatusCode))
+	}
+
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var st QueueStatus
+		if err := dec.Decode(&st); err != nil {
+			return fail(fmt.Errorf("reading queue status: %w", err))
+		}
+		if st.State == SlotRunning {
+			stop()
+			return &Slot{Ticket: st.Ticket, body: resp.Body, cancel: cancel}, nil
+		}
+		if fn != nil {
+			fn(st)
+		}
+	}
+}
+
+// ListQueue returns the daemon's concurrency limits and its running and
+// queued run slots.
+func (c *Client) ListQueue(ctx context.Context) (*QueueInfo, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://daemon/v1/queue", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, errcode.Wrap(errcode.DaemonUnavailable, fmt.Errorf("connecting to daemon: %w", err))
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound, http.StatusMethodNotAllowed:
+		return nil, ErrQueueUnsupported
+	default:
+		return nil, fmt.Errorf("daemon returned %d", resp.StatusCode)
+	}
+	var info QueueInfo
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// UpdateSlot records the run holding a slot, for ListQueue.
+func (c *Client) UpdateSlot(ctx context.Context, ticket string, u QueueSlotUpdate) error {
+	body, err := json.Marshal(u)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPatch, "http://daemon/v1/queue/"+url.PathEscape(ticket), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return errcode.Wrap(errcode.DaemonUnavailable, fmt.Errorf("connecting to daemon: %w", err))
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("daemon returned %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// StreamLogs calls fn for the last lines log entries of runID (all of them
+// if lines is 0) and, with follow, for new output until the container exits
+// or ctx is done. It returns ErrRunNotFound if the run is not registered,
+// and ErrStreamUnsupported if the daemon predates log streaming.
+func (c *Client) StreamLogs(ctx context.Context, runID string, follow bool, lines int, fn func(storage.LogEntry)) error {
+	q := url.Values{"run_id": {runID}, "lines": {strconv.Itoa(lines)}}
+	if follow {
+		q.Set("follow", "1")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://daemon/v1/logs?"+q.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return errcode.Wrap(errcode.DaemonUnavailable, fmt.Errorf("connecting to daemon: %w", err))
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		if strings.Contains(string(body), "run not found") {
+			return ErrRunNotFound
+		}
+		return ErrStreamUnsupported
+	default:
+		return fmt.Errorf("daemon returned %d", resp.StatusCode)
+	}
+
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var e storage.LogEntry
+		if err := dec.Decode(&e); err != nil {
+			if ctx.Err() != nil || errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("reading log stream: %w", err)
+		}
+		fn(e)
+	}
+}
+
+// ListApprovals returns the network approvals runs requested with moatctl,
+// limited to runID unless it is empty.
+func (c *Client) ListApprovals(ctx context.Context, runID string) ([]Approval, error) {
+	u := "http://daemon/v1/approvals"
+	if runID != "" {
+		u += "?run_id=" + url.QueryEscape(runID)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, errcode.Wrap(errcode.DaemonUnavailable, fmt.Errorf("connecting to daemon: %w", err))
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("daemon returned %d", resp.StatusCode)
+	}
+	var approvals []Approval
+	if err := json.NewDecoder(resp.Body).Decode(&approvals); err != nil {
+		return nil, err
+	}
+	return approvals, nil
+}
+
+// DecideApproval approves or denies a pending network approval and returns
+// it. Returns ErrApprovalNotFound for an unknown ID and ErrApprovalDecided,
+// with the approval, if it was already decided.
+func (c *Client) DecideApproval(ctx context.Context, approvalID string, approve bool) (Approval, error) {
+	body, err := json.Marshal(ApprovalDecision{Approve: approve})
+	if err != nil {
+		return Approval{}, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "http://daemon/v1/approvals/"+url.PathEscape(approvalID), bytes.NewReader(body))
+	if err != nil {
+		return Approval{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return Approval{}, errcode.Wrap(errcode.DaemonUnavailable, fmt.Errorf("connecting to daemon: %w", err))
+	}
+	defer resp.Body.Close()
+	var a Approval
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return Approval{}, ErrApprovalNotFound
+	case http.StatusConflict:
+		_ = json.NewDecoder(resp.Body).Decode(&a)
+		return a, ErrApprovalDecided
+	default:
+		return Approval{}, fmt.Errorf("daemon returned %d", resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&a); err != nil {
+		return Approval{}, err
+	}
+	return a, nil
+}
+
+// ListClips returns the clips runs offered with `moatctl clip`, limited to
+// runID unless it is empty.
+func (c *Client) ListClips(ctx context.Context, runID string) ([]Clip, error) {
+	u := "http://daemon/v1/clips"
+	if runID != "" {
+		u += "?run_id=" + url.QueryEscape(runID)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, errcode.Wrap(errcode.DaemonUnavailable, fmt.Errorf("connecting to daemon: %w", err))
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("daemon returned %d", resp.StatusCode)
+	}
+	var clips []Clip
+	if err := json.NewDecoder(resp.Body).Decode(&clips); err != nil {
+		return nil, err
+	}
+	return clips, nil
+}
+
+// DecideClip accepts or rejects an offered clip and returns it. Returns
+// ErrClipNotFound if no clip has the ID.
+func (c *Client) DecideClip(ctx context.Context, clipID string, accept bool) (Clip, error) {
+	body, err := json.Marshal(ClipDecision{Accept: accept})
+	if err != nil {
+		return Clip{}, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "http://daemon/v1/clips/"+url.PathEscape(clipID), bytes.NewReader(body))
+	if err != nil {
+		return Clip{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return Clip{}, errcode.Wrap(errcode.DaemonUnavailable, fmt.Errorf("connecting to daemon: %w", err))
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return Clip{}, ErrClipNotFound
+	default:
+		return Clip{}, fmt.Errorf("daemon returned %d", resp.StatusCode)
+	}
+	var clip Clip
+	if err := json.NewDecoder(resp.Body).Decode(&clip); err != nil {
+		return Clip{}, err
+	}
+	return clip, nil
+}
+
+// SendClip hands a clip to clip.RunID for `moatctl paste` and returns it
+// without its data. Returns ErrRunNotFound if the run is not registered.
+func (c *Client) SendClip(ctx context.Context, clip Clip) (Clip, error) {
+	body, err := json.Marshal(clip)
+	if err != nil {
+		return Clip{}, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "http://daemon/v1/clips", bytes.NewReader(body))
+	if err != nil {
+		return Clip{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return Clip{}, errcode.Wrap(errcode.DaemonUnavailable, fmt.Errorf("connecting to daemon: %w", err))
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusCreated:
+	case http.StatusNotFound:
+		return Clip{}, ErrRunNotFound
+	default:
+		var e struct {
+			Error string `json:"error"`
+		}
+		if json.NewDecoder(resp.Body).Decode(&e) == nil && e.Error != "" {
+			return Clip{}, errors.New(e.Error)
+		}
+		return Clip{}, fmt.Errorf("daemon returned %d", resp.StatusCode)
+	}
+	var sent Clip
+	if err := json.NewDecoder(resp.Body).Decode(&sent); err != nil {
+		return Clip{}, err
+	}
+	return sent, nil
+}
+
+// ListRoutes returns the service routes registered with the daemon. Daemons
+// without CapRouteList do not serve the list and return an error.
+func (c *Client) ListRoutes(ctx context.Context) ([]RouteInfo, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://daemon/v1/routes", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, errcode.Wrap(errcode.DaemonUnavailable, fmt.Errorf("connecting to daemon: %w", err))
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("daemon returned %d", resp.StatusCode)
+	}
+	var routes []RouteInfo
+	if err := json.NewDecoder(resp.Body).Decode(&routes); err != nil {
+		return nil, err
+	}
+	return routes, nil
+}
+
+// Stats samples the resource usage of registered runs and their service
+// containers. Daemons without CapStats do not serve it and return an error.
+func (c *Client) Stats(ctx context.Context) ([]RunStats, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://daemon/v1/stats", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, errcode.Wrap(errcode.DaemonUnavailable, fmt.Errorf("connecting to daemon: %w", err))
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("daemon returned %d", resp.StatusCode)
+	}
+	var stats []RunStats
+	if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
 // RegisterRoutes registers service routes for an agent.
 func (c *Client) RegisterRoutes(ctx context.Context, agent string, services map[string]string) error {
 	body, err := json.Marshal(RouteRegistration{Services: services})
@@ -150,7 +649,7 @@ func (c *Client) RegisterRoutes(ctx context.Context, agent string, services map[
 	req.Header.Set("Content-Type", "application/json")
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return fmt.Errorf("connecting to daemon: %w", err)
+		return errcode.Wrap(errcode.DaemonUnavailable, fmt.Errorf("connecting to daemon: %w", err))
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusNoContent {
@@ -167,7 +666,7 @@ func (c *Client) UnregisterRoutes(ctx context.Context, agent string) error {
 	}
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return fmt.Errorf("connecting to daemon: %w", err)
+		return errcode.Wrap(errcode.DaemonUnavailable, fmt.Errorf("connecting to daemon: %w", err))
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusNoContent {
@@ -2,8 +2,14 @@ package daemon
 
 import (
 	"context"
+	"errors"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/majorcontext/moat/internal/routing"
+	"github.com/majorcontext/moat/internal/storage"
 )
 
 func TestClient_Health(t *testing.T) {
@@ -63,7 +69,7 @@ func TestClient_RegisterAndUnregister(t *testing.T) {
 	}
 
 	// Update container ID
-	if err := client.UpdateRun(context.Background(), resp.AuthToken, "container_xyz"); err != nil {
+	if err := client.UpdateRun(context.Background(), resp.AuthToken, UpdateRunRequest{ContainerID: "container_xyz"}); err != nil {
 		t.Fatal(err)
 	}
 
@@ -99,7 +105,7 @@ func TestClient_UpdateRunNotFound(t *testing.T) {
 	defer srv.Stop(context.Background())
 
 	client := NewClient(sockPath)
-	err := client.UpdateRun(context.Background(), "nonexistent-token", "ctr-999")
+	err := client.UpdateRun(context.Background(), "nonexistent-token", UpdateRunRequest{ContainerID: "ctr-999"})
 	if err == nil {
 		t.Fatal("expected error for nonexistent token")
 	}
@@ -124,6 +130,133 @@ func TestClient_UnregisterRunNotFound(t *testing.T) {
 	}
 }
 
+func TestClient_ListRoutes(t *testing.T) {
+	dir := testSockDir(t)
+	sockPath := filepath.Join(dir, "d.sock")
+	srv := NewServer(sockPath, 9100)
+	routes, err := routing.NewRouteTable(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.SetRoutes(routes)
+	if err := srv.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Stop(context.Background())
+
+	client := NewClient(sockPath)
+	ctx := context.Background()
+	if err := client.RegisterRoutes(ctx, "web", map[string]string{"http": "127.0.0.1:3000"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.RegisterRoutes(ctx, "api", map[string]string{"grpc": "127.0.0.1:9000"}); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := client.ListRoutes(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].Agent != "api" || got[1].Agent != "web" {
+		t.Fatalf("ListRoutes = %+v, want api then web", got)
+	}
+	if got[1].Services["http"] != "127.0.0.1:3000" {
+		t.Errorf("web http = %q, want 127.0.0.1:3000", got[1].Services["http"])
+	}
+}
+
+func TestClient_Approvals(t *testing.T) {
+	dir := testSockDir(t)
+	sockPath := filepath.Join(dir, "d.sock")
+	srv := NewServer(sockPath, 9100)
+	if err := srv.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Stop(context.Background())
+
+	c := NewCtl(t.TempDir(), nil, nil)
+	c.SetRegistry(srv.Registry())
+	SetCtl(c)
+	defer SetCtl(nil)
+	rc := NewRunContext("run_1")
+	srv.Registry().Register(rc)
+	pa, _ := c.request(rc, "pypi.org", "install deps")
+	c.request(NewRunContext("run_2"), "example.com", "")
+
+	client := NewClient(sockPath)
+	ctx := context.Background()
+	got, err := client.ListApprovals(ctx, "run_1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].ID != pa.ID || got[0].Status != ApprovalPending {
+		t.Fatalf("ListApprovals = %+v, want run_1's pending approval", got)
+	}
+	if all, err := client.ListApprovals(ctx, ""); err != nil || len(all) != 2 {
+		t.Errorf("ListApprovals(all) = %+v, %v, want 2", all, err)
+	}
+
+	a, err := client.DecideApproval(ctx, pa.ID, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Status != ApprovalApproved || len(rc.NetworkRules) != 1 {
+		t.Errorf("approval = %+v, rules = %+v", a, rc.NetworkRules)
+	}
+	if a, err := client.DecideApproval(ctx, pa.ID, false); !errors.Is(err, ErrApprovalDecided) || a.Status != ApprovalApproved {
+		t.Errorf("re-deciding: %+v, %v, want ErrApprovalDecided", a, err)
+	}
+	if _, err := client.DecideApproval(ctx, "apr_missing", true); !errors.Is(err, ErrApprovalNotFound) {
+		t.Errorf("unknown approval: err = %v, want ErrApprovalNotFound", err)
+	}
+}
+
+func TestClient_Clips(t *testing.T) {
+	dir := testSockDir(t)
+	sockPath := filepath.Join(dir, "d.sock")
+	srv := NewServer(sockPath, 9100)
+	if err := srv.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Stop(context.Background())
+
+	c := NewCtl(t.TempDir(), nil, nil)
+	c.SetRegistry(srv.Registry())
+	SetCtl(c)
+	defer SetCtl(nil)
+	rc := NewRunContext("run_1")
+	srv.Registry().Register(rc)
+	srv.Registry().Register(rc)
+	rc.SetCtlHandler(newCtlHandler(rc))
+	offerClip(rc.ToProxyContextData().AWSHandler, "", []byte("hello"))
+
+	client := NewClient(sockPath)
+	ctx := context.Background()
+	clips, err := client.ListClips(ctx, "run_1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(clips) != 1 || string(clips[0].Data) != "hello" {
+		t.Fatalf("ListClips = %+v, want the offered clip", clips)
+	}
+	if _, err := client.DecideClip(ctx, clips[0].ID, true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.DecideClip(ctx, clips[0].ID, true); !errors.Is(err, ErrClipNotFound) {
+		t.Errorf("deciding twice: err = %v, want ErrClipNotFound", err)
+	}
+
+	if _, err := client.SendClip(ctx, Clip{RunID: "run_1", Data: []byte("hi")}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.SendClip(ctx, Clip{RunID: "run_gone", Data: []byte("hi")}); !errors.Is(err, ErrRunNotFound) {
+		t.Errorf("sending to unknown run: err = %v, want ErrRunNotFound", err)
+	}
+	if _, err := client.SendClip(ctx, Clip{RunID: "run_1"}); err == nil || !strings.Contains(err.Error(), "empty") {
+		t.Errorf("sending empty clip: err = %v", err)
+	}
+}
+
 func TestClient_Shutdown(t *testing.T) {
 	dir := testSockDir(t)
 	sockPath := filepath.Join(dir, "d.sock")
@@ -215,3 +348,83 @@ func TestClient_MultipleRuns(t *testing.T) {
 		t.Errorf("expected remaining run to be run_b, got %s", runs[0].RunID)
 	}
 }
+
+func TestClient_StreamRequests(t *testing.T) {
+	dir := testSockDir(t)
+	sockPath := filepath.Join(dir, "d.sock")
+	srv := NewServer(sockPath, 9100)
+	if err := srv.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Stop(context.Background())
+
+	client := NewClient(sockPath)
+	if err := client.StreamRequests(context.Background(), "run_missing", func(RequestEvent) {}); !errors.Is(err, ErrRunNotFound) {
+		t.Fatalf("StreamRequests(unregistered) = %v, want ErrRunNotFound", err)
+	}
+
+	resp, err := client.RegisterRun(context.Background(), RegisterRequest{RunID: "run_stream"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := make(chan RequestEvent, 1)
+	done := make(chan error, 1)
+	go func() {
+		done <- client.StreamRequests(context.Background(), "run_stream", func(ev RequestEvent) {
+			select {
+			case got <- ev:
+			default:
+			}
+		})
+	}()
+
+	// Publish until the subscriber is attached and receives an event.
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	deadline := time.After(5 * time.Second)
+	for received := false; !received; {
+		select {
+		case <-ticker.C:
+			srv.Events().Publish(RequestEvent{RunID: "run_other"})
+			srv.Events().Publish(RequestEvent{RunID: "run_stream", Decision: storage.Decision{Method: "GET", Host: "api.github.com", Decision: "allow"}})
+		case ev := <-got:
+			if ev.RunID != "run_stream" || ev.Host != "api.github.com" {
+				t.Fatalf("event = %+v, want run_stream GET api.github.com", ev)
+			}
+			received = true
+		case <-deadline:
+			t.Fatal("timed out waiting for streamed event")
+		}
+	}
+
+	// Unregistering the run ends the stream cleanly.
+	old := requestStreamPoll
+	requestStreamPoll = 10 * time.Millisecond
+	defer func() { requestStreamPoll = old }()
+	if err := client.UnregisterRun(context.Background(), resp.AuthToken); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("StreamRequests returned %v after unregister, want nil", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream did not end after run unregistered")
+	}
+}
+
+func TestRequestEvents_DropsForSlowSubscriber(t *testing.T) {
+	e := NewRequestEvents()
+	ch, unsubscribe := e.Subscribe("run_1")
+	for i := 0; i < requestEventBuffer+10; i++ {
+		e.Publish(RequestEvent{RunID: "run_1"}) // must not block
+	}
+	if len(ch) != requestEventBuffer {
+		t.Errorf("buffered %d events, want %d", len(ch), requestEventBuffer)
+	}
+	unsubscribe()
+	unsubscribe() // idempotent
+	e.Publish(RequestEvent{RunID: "run_1"})
+}
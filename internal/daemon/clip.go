@@ -0,0 +1,191 @@
+package daemon
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"slices"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/majorcontext/moat/internal/audit"
+	"github.com/majorcontext/moat/internal/id"
+	"github.com/majorcontext/moat/internal/log"
+)
+
+// maxClipSize caps a clip's content. Clips are for snippets and small
+// artifacts; larger files belong in the workspace.
+const maxClipSize = 1 << 20
+
+// clipNameHeader carries the file name of a clip offered with
+// `moatctl clip FILE`.
+const clipNameHeader = "X-Moat-Clip-Name"
+
+// maxOfferedClips caps how many clips a run can have waiting for the user.
+const maxOfferedClips = 20
+
+// ErrClipNotFound is returned when no offered clip has the requested ID.
+var ErrClipNotFound = errors.New("clip not found")
+
+// clipMIMEType returns the MIME type of clip content. Clipboard content (no
+// file name) must be text or a PNG image, the formats host clipboards take.
+func clipMIMEType(name string, data []byte) (string, error) {
+	detected := http.DetectContentType(data)
+	if name != "" {
+		return detected, nil
+	}
+	switch {
+	case detected == "image/png":
+		return detected, nil
+	case utf8.Valid(data):
+		return "text/plain; charset=utf-8", nil
+	}
+	return "", fmt.Errorf("clipboard content must be text or a PNG image; hand off other data as a file")
+}
+
+// clipDetail describes a clip in the audit log without its content.
+func clipDetail(clip *Clip) string {
+	what := clip.Name
+	if what == "" {
+		what = clip.MIMEType
+	}
+	return fmt.Sprintf("%s %s (%d bytes)", clip.ID, what, len(clip.Data))
+}
+
+// handleClip offers the request body to the host. The user sees it with
+// `moat clip` and decides whether to take it.
+func (c *Ctl) handleClip(rc *RunContext, w http.ResponseWriter, r *http.Request) {
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxClipSize))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("clip exceeds %d bytes", maxClipSize), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if len(data) == 0 {
+		http.Error(w, "empty clip", http.StatusBadRequest)
+		return
+	}
+	name := path.Base(r.Header.Get(clipNameHeader))
+	if name == "." || name == "/" {
+		name = ""
+	}
+	mimeType, err := clipMIMEType(name, data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	c.mu.Lock()
+	pending := 0
+	for _, clip := range c.offered {
+		if clip.RunID == rc.RunID {
+			pending++
+		}
+	}
+	if pending >= maxOfferedClips {
+		c.mu.Unlock()
+		http.Error(w, "too many clips waiting for the user; ask them to run 'moat clip'", http.StatusTooManyRequests)
+		return
+	}
+	clip := &Clip{
+		ID:        id.Generate("clp"),
+		RunID:     rc.RunID,
+		Name:      name,
+		MIMEType:  mimeType,
+		Data:      data,
+		CreatedAt: c.now().UTC(),
+	}
+	c.offered[clip.ID] = clip
+	c.mu.Unlock()
+
+	c.record(rc.RunID, audit.CtlData{Command: "clip", Detail: clipDetail(clip), Result: "offered"})
+	log.Info("clip offered", "run_id", rc.RunID, "id", clip.ID, "bytes", len(data))
+	c.publish(EventClipOffered, rc.RunID, nil, clip)
+	resp := *clip
+	resp.Data = nil
+	writeJSON(w, http.StatusCreated, resp)
+}
+
+// handlePaste returns the clip the host sent the run, once.
+func (c *Ctl) handlePaste(rc *RunContext, w http.ResponseWriter) {
+	c.mu.Lock()
+	clip, ok := c.inbox[rc.RunID]
+	delete(c.inbox, rc.RunID)
+	c.mu.Unlock()
+	if !ok {
+		http.Error(w, "nothing to paste; send a clip from the host with 'moat clip --send'", http.StatusNotFound)
+		return
+	}
+	c.record(rc.RunID, audit.CtlData{Command: "paste", Detail: clipDetail(clip), Result: "delivered"})
+	w.Header().Set("Content-Type", clip.MIMEType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(clip.Data)))
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(clip.Data)
+}
+
+// Clips returns the clips offered by runID, or by every run if runID is
+// empty, oldest first.
+func (c *Ctl) Clips(runID string) []Clip {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var out []Clip
+	for _, clip := range c.offered {
+		if runID == "" || clip.RunID == runID {
+			out = append(out, *clip)
+		}
+	}
+	slices.SortFunc(out, func(a, b Clip) int { return a.CreatedAt.Compare(b.CreatedAt) })
+	return out
+}
+
+// DecideClip removes an offered clip, recording whether the user accepted
+// it, and returns it.
+func (c *Ctl) DecideClip(clipID string, accept bool) (Clip, error) {
+	c.mu.Lock()
+	clip, ok := c.offered[clipID]
+	delete(c.offered, clipID)
+	c.mu.Unlock()
+	if !ok {
+		return Clip{}, ErrClipNotFound
+	}
+	result, event := "rejected", EventClipRejected
+	if accept {
+		result, event = "accepted", EventClipAccepted
+	}
+	c.record(clip.RunID, audit.CtlData{Command: "clip", Detail: clipDetail(clip), Result: result})
+	c.publish(event, clip.RunID, nil, clip)
+	return *clip, nil
+}
+
+// Send hands a clip from the host to its run, replacing any the run has not
+// pasted yet.
+func (c *Ctl) Send(clip Clip) (Clip, error) {
+	if len(clip.Data) == 0 {
+		return Clip{}, errors.New("empty clip")
+	}
+	if len(clip.Data) > maxClipSize {
+		return Clip{}, fmt.Errorf("clip exceeds %d bytes", maxClipSize)
+	}
+	clip.Name = path.Base(clip.Name)
+	if clip.Name == "." || clip.Name == "/" {
+		clip.Name = ""
+	}
+	mimeType, err := clipMIMEType(clip.Name, clip.Data)
+	if err != nil {
+		return Clip{}, err
+	}
+	clip.MIMEType = mimeType
+	clip.ID = id.Generate("clp")
+	clip.CreatedAt = c.now().UTC()
+
+	c.mu.Lock()
+	c.inbox[clip.RunID] = &clip
+	c.mu.Unlock()
+
+	c.record(clip.RunID, audit.CtlData{Command: "paste", Detail: clipDetail(&clip), Result: "sent"})
+	c.publish(EventClipSent, clip.RunID, nil, &clip)
+	resp := clip
+	resp.Data = nil
+	return resp, nil
+}
@@ -0,0 +1,136 @@
+package daemon
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func offerClip(h http.Handler, name string, data []byte) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, CtlEndpointPath+"/clip", bytes.NewReader(data))
+	req.Header.Set("Content-Type", "application/octet-stream")
+	if name != "" {
+		req.Header.Set(clipNameHeader, name)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestCtl_ClipOffer(t *testing.T) {
+	rc := NewRunContext("run_clip")
+	c, h, as := newTestCtl(t, rc)
+
+	if rec := offerClip(h, "", []byte("SELECT 1;\n")); rec.Code != http.StatusCreated {
+		t.Fatalf("text clip: status = %d: %s", rec.Code, rec.Body)
+	}
+	if rec := offerClip(h, "../../etc/report.csv", []byte{0x00, 0x01, 0x02}); rec.Code != http.StatusCreated {
+		t.Fatalf("file clip: status = %d: %s", rec.Code, rec.Body)
+	}
+	if d := lastCtlEntry(t, as); d.Command != "clip" || d.Result != "offered" || !strings.Contains(d.Detail, "report.csv") {
+		t.Errorf("audit = %+v", d)
+	}
+
+	clips := c.Clips(rc.RunID)
+	if len(clips) != 2 {
+		t.Fatalf("clips = %+v, want 2", clips)
+	}
+	if !strings.HasPrefix(clips[0].MIMEType, "text/plain") || string(clips[0].Data) != "SELECT 1;\n" {
+		t.Errorf("text clip = %+v", clips[0])
+	}
+	if clips[1].Name != "report.csv" {
+		t.Errorf("file clip name = %q, want the base name only", clips[1].Name)
+	}
+
+	got, err := c.DecideClip(clips[0].ID, true)
+	if err != nil || string(got.Data) != "SELECT 1;\n" {
+		t.Fatalf("DecideClip = %+v, %v", got, err)
+	}
+	if d := lastCtlEntry(t, as); d.Result != "accepted" {
+		t.Errorf("audit after accept = %+v", d)
+	}
+	if _, err := c.DecideClip(clips[0].ID, true); !errors.Is(err, ErrClipNotFound) {
+		t.Errorf("deciding twice: err = %v, want ErrClipNotFound", err)
+	}
+	if _, err := c.DecideClip(clips[1].ID, false); err != nil {
+		t.Fatal(err)
+	}
+	if d := lastCtlEntry(t, as); d.Result != "rejected" {
+		t.Errorf("audit after reject = %+v", d)
+	}
+	if got := c.Clips(""); len(got) != 0 {
+		t.Errorf("clips after deciding = %+v", got)
+	}
+}
+
+func TestCtl_ClipRejects(t *testing.T) {
+	_, h, _ := newTestCtl(t, NewRunContext("run_clip_bad"))
+
+	if rec := offerClip(h, "", nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("empty clip: status = %d, want 400", rec.Code)
+	}
+	if rec := offerClip(h, "", []byte{0xff, 0xfe, 0x00}); rec.Code != http.StatusBadRequest {
+		t.Errorf("binary clipboard content: status = %d, want 400", rec.Code)
+	}
+	if rec := offerClip(h, "big.bin", make([]byte, maxClipSize+1)); rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("oversized clip: status = %d, want 413", rec.Code)
+	}
+	for i := range maxOfferedClips {
+		if rec := offerClip(h, "", []byte("x")); rec.Code != http.StatusCreated {
+			t.Fatalf("clip %d: status = %d", i, rec.Code)
+		}
+	}
+	if rec := offerClip(h, "", []byte("x")); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("clip over the limit: status = %d, want 429", rec.Code)
+	}
+}
+
+func TestCtl_SendAndPaste(t *testing.T) {
+	rc := NewRunContext("run_paste")
+	c, h, as := newTestCtl(t, rc)
+
+	if rec := ctlRequest(h, http.MethodGet, "paste", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("paste with nothing sent: status = %d, want 404", rec.Code)
+	}
+
+	sent, err := c.Send(Clip{RunID: rc.RunID, Data: []byte("first")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sent.Data != nil || sent.ID == "" {
+		t.Errorf("Send returned %+v, want an ID and no data", sent)
+	}
+	if _, err := c.Send(Clip{RunID: rc.RunID, Name: "notes.md", Data: []byte("second")}); err != nil {
+		t.Fatal(err)
+	}
+	if d := lastCtlEntry(t, as); d.Command != "paste" || d.Result != "sent" {
+		t.Errorf("audit after send = %+v", d)
+	}
+
+	rec := ctlRequest(h, http.MethodGet, "paste", nil)
+	if rec.Code != http.StatusOK || rec.Body.String() != "second" {
+		t.Fatalf("paste = %d %q, want the latest clip", rec.Code, rec.Body)
+	}
+	if d := lastCtlEntry(t, as); d.Command != "paste" || d.Result != "delivered" {
+		t.Errorf("audit after paste = %+v", d)
+	}
+	if rec := ctlRequest(h, http.MethodGet, "paste", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("second paste: status = %d, want 404", rec.Code)
+	}
+
+	if _, err := c.Send(Clip{RunID: rc.RunID}); err == nil {
+		t.Error("Send accepted an empty clip")
+	}
+	offerClip(h, "", []byte("x"))
+	c.Send(Clip{RunID: rc.RunID, Data: []byte("y")})
+	c.Forget(rc.RunID)
+	if got := c.Clips(""); len(got) != 0 {
+		t.Errorf("clips after Forget = %+v", got)
+	}
+	if rec := ctlRequest(h, http.MethodGet, "paste", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("paste after Forget: status = %d, want 404", rec.Code)
+	}
+}
@@ -2,17 +2,26 @@ package daemon
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os/exec"
 	"strings"
 
+	"github.com/majorcontext/moat/internal/container"
 	"github.com/majorcontext/moat/internal/log"
 )
 
 // CommandContainerChecker checks container liveness by shelling out to
-// the container runtime CLI. It tries Docker first, then Apple containers.
+// the container runtime CLI. It tries Docker first, then Podman, then Apple
+// containers. Kubernetes pods, whose IDs are "<namespace>/<pod>", are
+// checked with kubectl.
 type CommandContainerChecker struct {
-	// runtimes caches the runtime type per container ID ("docker" or "apple").
+	// KubeContext is the kubectl context kubernetes pods run in; empty
+	// uses the current one.
+	KubeContext string
+
+	// runtimes caches the runtime type per container ID ("docker", "podman",
+	// "apple", or "kubernetes").
 	// A global cache was wrong: when both Docker and Apple containers are
 	// active, caching a single runtime caused all checks for the other
 	// runtime's containers to fail, leading to false liveness failures
@@ -25,10 +34,17 @@ type CommandContainerChecker struct {
 // (false, nil) when confirmed dead, and (false, err) when the
 // check itself failed (e.g. transient CLI error).
 func (c *CommandContainerChecker) IsContainerRunning(ctx context.Context, id string) (bool, error) {
+	if strings.Contains(id, "/") {
+		c.runtimes[id] = "kubernetes"
+		return container.KubernetesPodRunning(ctx, c.KubeContext, id)
+	}
+
 	// Try cached runtime for this specific container first.
 	switch c.runtimes[id] {
 	case "docker":
 		return c.checkDocker(ctx, id)
+	case "podman":
+		return c.checkPodman(ctx, id)
 	case "apple":
 		return c.checkApple(ctx, id)
 	}
@@ -44,7 +60,18 @@ func (c *CommandContainerChecker) IsContainerRunning(ctx context.Context, id str
 		return false, nil
 	}
 
-	// Docker failed with an error — try Apple containers.
+	// Docker failed with an error — try Podman.
+	alive, podmanErr := c.checkPodman(ctx, id)
+	if alive {
+		c.runtimes[id] = "podman"
+		return true, nil
+	}
+	if podmanErr == nil {
+		// Podman confirmed container is not running.
+		return false, nil
+	}
+
+	// Podman failed too — try Apple containers.
 	alive, appleErr := c.checkApple(ctx, id)
 	if alive {
 		c.runtimes[id] = "apple"
@@ -55,7 +82,7 @@ func (c *CommandContainerChecker) IsContainerRunning(ctx context.Context, id str
 		return false, nil
 	}
 
-	// Both checks failed; prefer the Docker error as primary.
+	// All checks failed; prefer the Docker error as primary.
 	return false, err
 }
 
@@ -69,6 +96,16 @@ func (c *CommandContainerChecker) checkDocker(ctx context.Context, id string) (b
 	return strings.TrimSpace(string(out)) == "true", nil
 }
 
+// checkPodman checks if a Podman container is running.
+func (c *CommandContainerChecker) checkPodman(ctx context.Context, id string) (bool, error) {
+	cmd := exec.CommandContext(ctx, "podman", "inspect", "--format", "{{.State.Running}}", id)
+	out, err := cmd.Output()
+	if err != nil {
+		return false, fmt.Errorf("podman inspect: %w", err)
+	}
+	return strings.TrimSpace(string(out)) == "true", nil
+}
+
 // checkApple checks if an Apple container is running.
 func (c *CommandContainerChecker) checkApple(ctx context.Context, id string) (bool, error) {
 	cmd := exec.CommandContext(ctx, "container", "inspect", id)
@@ -94,3 +131,38 @@ func NewCommandContainerChecker() *CommandContainerChecker {
 		runtimes: make(map[string]string),
 	}
 }
+
+// ErrPauseUnsupported is returned by a ContainerPauser for runtimes whose
+// containers cannot be paused.
+var ErrPauseUnsupported = errors.New("runtime cannot pause containers")
+
+// CommandContainerPauser pauses and unpauses containers with the CLI of the
+// run's runtime. Apple containers and kubernetes pods cannot be paused.
+type CommandContainerPauser struct{}
+
+// SetContainerPaused pauses or unpauses a container with the docker or
+// podman CLI, as runtime says. Runs registered without a runtime try
+// Docker first, then Podman.
+func (CommandContainerPauser) SetContainerPaused(ctx context.Context, runtime, id string, paused bool) error {
+	verb := "unpause"
+	if paused {
+		verb = "pause"
+	}
+	switch container.RuntimeType(runtime) {
+	case container.RuntimeDocker, container.RuntimePodman:
+		if out, err := exec.CommandContext(ctx, runtime, verb, id).CombinedOutput(); err != nil {
+			return fmt.Errorf("%s %s: %w: %s", runtime, verb, err, strings.TrimSpace(string(out)))
+		}
+		return nil
+	case container.RuntimeApple, container.RuntimeKubernetes:
+		return fmt.Errorf("%s: %w", runtime, ErrPauseUnsupported)
+	}
+	err := exec.CommandContext(ctx, "docker", verb, id).Run()
+	if err == nil {
+		return nil
+	}
+	if podmanErr := exec.CommandContext(ctx, "podman", verb, id).Run(); podmanErr == nil {
+		return nil
+	}
+	return fmt.Errorf("docker %s: %w", verb, err)
+}
@@ -0,0 +1,152 @@
+package daemon
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/majorcontext/gatekeeper/proxy"
+	"github.com/majorcontext/moat/internal/netrules"
+	"github.com/majorcontext/moat/internal/storage"
+)
+
+// NewDecision builds the decision log entry for a proxied request. rc is the
+// run's context at log time and may be nil if the run has since unregistered,
+// in which case the entry carries only what the proxy reported.
+func NewDecision(rc *RunContext, data proxy.RequestLogData) storage.Decision {
+	d := storage.Decision{
+		Timestamp:     time.Now().UTC(),
+		RequestID:     data.RequestID,
+		Type:          data.RequestType,
+		Method:        data.Method,
+		Host:          data.Host,
+		Path:          data.Path,
+		Grants:        data.Grants,
+		StatusCode:    data.StatusCode,
+		RequestBytes:  data.RequestSize,
+		ResponseBytes: data.ResponseSize,
+		Duration:      data.Duration.Milliseconds(),
+	}
+	if data.Err != nil {
+		d.Error = data.Err.Error()
+	}
+	for name := range data.InjectedHeaders {
+		d.Injected = append(d.Injected, name)
+	}
+	sort.Strings(d.Injected)
+
+	host, port := decisionHostPort(data)
+	if d.Host == "" {
+		d.Host = host
+	}
+
+	if data.Denied {
+		d.Decision = "deny"
+		d.Reason = data.DenyReason
+	} else {
+		d.Decision = "allow"
+		d.Reason = storage.DecisionPolicy
+	}
+	if rc == nil {
+		return d
+	}
+
+	d.Transformers = rc.transformerKinds(host)
+
+	rc.mu.RLock()
+	d.Policy = rc.NetworkPolicy
+	if d.Policy == "" {
+		d.Policy = "permissive"
+	}
+	rules := rc.NetworkRules
+	if len(rules) == 0 {
+		// Older CLIs register plain host strings only.
+		for _, h := range rc.NetworkAllow {
+			rules = append(rules, netrules.HostRules{Host: h})
+		}
+	}
+	gateway := rc.HostGateway
+	hostPorts := rc.AllowedHostPorts
+	rc.mu.RUnlock()
+
+	if entry, rule := netrules.Explain(rules, host, port, data.Method, data.Path, hostMatchAdapter); entry != nil {
+		switch {
+		case rule != nil:
+			d.Rule = fmt.Sprintf("%s: %s %s %s", entry.Host, rule.Action, rule.Method, rule.PathPattern)
+			if !data.Denied && rule.Action == "allow" {
+				d.Reason = storage.DecisionRule
+			}
+		case len(entry.Rules) == 0:
+			// Host-level entry: the host is listed without path rules.
+			d.Rule = entry.Host
+			if !data.Denied {
+				d.Reason = storage.DecisionRule
+			}
+		}
+	}
+	if !data.Denied && gateway != "" && host == gateway && slices.Contains(hostPorts, port) {
+		d.Reason = storage.DecisionHostPort
+	}
+	return d
+}
+
+// decisionHostPort extracts the target hostname and port from the logged
+// request, defaulting the port from the URL scheme.
+func decisionHostPort(data proxy.RequestLogData) (string, int) {
+	host := data.Host
+	port := 0
+	if u, err := url.Parse(data.URL); err == nil && u.Host != "" {
+		if host == "" {
+			host = u.Hostname()
+		}
+		if p, err := strconv.Atoi(u.Port()); err == nil {
+			port = p
+		} else if u.Scheme == "http" {
+			port = 80
+		} else {
+			port = 443
+		}
+	}
+	if h, p, err := net.SplitHostPort(host); err == nil {
+		host = h
+		if n, err := strconv.Atoi(p); err == nil {
+			port = n
+		}
+	}
+	if port == 0 {
+		port = 443
+	}
+	return host, port
+}
+
+// transformerKinds lists the transformer kinds configured for host: registry
+// specs plus the request header edits and token substitution providers set
+// directly.
+func (rc *RunContext) transformerKinds(host string) []string {
+	var kinds []string
+	if len(rc.GetExtraHeaders(host)) > 0 {
+		kinds = append(kinds, TransformRequestHeaderSet)
+	}
+	if len(rc.GetRemoveHeaders(host)) > 0 {
+		kinds = append(kinds, TransformRequestHeaderRemove)
+	}
+	if _, ok := rc.GetTokenSubstitution(host); ok {
+		kinds = append(kinds, "token-substitution")
+	}
+	rc.mu.RLock()
+	for _, spec := range rc.TransformerSpecs {
+		h := spec.Host
+		if sh, _, err := net.SplitHostPort(h); err == nil {
+			h = sh
+		}
+		if h == host && !slices.Contains(kinds, spec.Kind) {
+			kinds = append(kinds, spec.Kind)
+		}
+	}
+	rc.mu.RUnlock()
+	return kinds
+}
@@ -0,0 +1,104 @@
+package daemon
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/majorcontext/gatekeeper/proxy"
+	"github.com/majorcontext/moat/internal/netrules"
+	"github.com/majorcontext/moat/internal/storage"
+)
+
+func TestNewDecision(t *testing.T) {
+	rc := NewRunContext("run_decide")
+	rc.NetworkPolicy = "strict"
+	rc.NetworkRules = []netrules.HostRules{
+		{Host: "api.github.com", Rules: []netrules.Rule{{Action: "allow", Method: "GET", PathPattern: "/repos/*"}}},
+		{Host: "pypi.org"},
+	}
+	rc.HostGateway = "moat-host"
+	rc.AllowedHostPorts = []int{8288}
+	rc.AddExtraHeader("api.github.com", "X-Team", "platform")
+	rc.TransformerSpecs = []TransformerSpec{{Host: "api.github.com:443", Kind: TransformResponseHeaderRemove, Args: map[string]string{"name": "Set-Cookie"}}}
+
+	tests := []struct {
+		name       string
+		data       proxy.RequestLogData
+		wantDec    string
+		wantReason string
+		wantRule   string
+	}{
+		{
+			name:       "path rule",
+			data:       proxy.RequestLogData{Method: "GET", URL: "https://api.github.com/repos/x", Host: "api.github.com", Path: "/repos/x"},
+			wantDec:    "allow",
+			wantReason: storage.DecisionRule,
+			wantRule:   "api.github.com: allow GET /repos/*",
+		},
+		{
+			name:       "host-level entry",
+			data:       proxy.RequestLogData{Method: "GET", URL: "https://pypi.org/simple/", Host: "pypi.org", Path: "/simple/"},
+			wantDec:    "allow",
+			wantReason: storage.DecisionRule,
+			wantRule:   "pypi.org",
+		},
+		{
+			name:       "host port",
+			data:       proxy.RequestLogData{Method: "GET", URL: "http://moat-host:8288/", Host: "moat-host", Path: "/"},
+			wantDec:    "allow",
+			wantReason: storage.DecisionHostPort,
+		},
+		{
+			name:       "denied",
+			data:       proxy.RequestLogData{Method: "POST", URL: "https://api.github.com/user", Host: "api.github.com", Path: "/user", Denied: true, DenyReason: "network_policy"},
+			wantDec:    "deny",
+			wantReason: "network_policy",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewDecision(rc, tt.data)
+			if d.Decision != tt.wantDec || d.Reason != tt.wantReason || d.Rule != tt.wantRule {
+				t.Errorf("decision = %s/%s rule %q, want %s/%s rule %q", d.Decision, d.Reason, d.Rule, tt.wantDec, tt.wantReason, tt.wantRule)
+			}
+			if d.Policy != "strict" {
+				t.Errorf("Policy = %q, want strict", d.Policy)
+			}
+		})
+	}
+
+	d := NewDecision(rc, proxy.RequestLogData{
+		Method:          "GET",
+		URL:             "https://api.github.com/repos/x",
+		Host:            "api.github.com",
+		Path:            "/repos/x",
+		StatusCode:      200,
+		Duration:        120 * time.Millisecond,
+		RequestSize:     -1,
+		ResponseSize:    2048,
+		InjectedHeaders: map[string]bool{"x-team": true, "authorization": true},
+		Grants:          []string{"github"},
+	})
+	if !slices.Equal(d.Injected, []string{"authorization", "x-team"}) {
+		t.Errorf("Injected = %v, want sorted header names", d.Injected)
+	}
+	if !slices.Equal(d.Transformers, []string{TransformRequestHeaderSet, TransformResponseHeaderRemove}) {
+		t.Errorf("Transformers = %v", d.Transformers)
+	}
+	if d.Duration != 120 || d.ResponseBytes != 2048 || d.RequestBytes != -1 || d.Grants[0] != "github" {
+		t.Errorf("decision = %+v", d)
+	}
+}
+
+func TestNewDecision_NoRunContext(t *testing.T) {
+	d := NewDecision(nil, proxy.RequestLogData{
+		Method: "GET",
+		URL:    "https://example.com:8443/x",
+		Err:    errors.New("upstream reset"),
+	})
+	if d.Decision != "allow" || d.Reason != storage.DecisionPolicy || d.Host != "example.com" || d.Error != "upstream reset" {
+		t.Errorf("decision = %+v", d)
+	}
+}
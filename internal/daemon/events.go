@@ -0,0 +1,115 @@
+package daemon
+
+import (
+	"sync"
+	"time"
+
+	"github.com/majorcontext/moat/internal/storage"
+)
+
+// requestEventBuffer is how many events a subscriber may fall behind before
+// further events are dropped for it.
+const requestEventBuffer = 256
+
+// RequestEvent is a proxied request as streamed to `moat network --follow`.
+// It carries the same fields as the run's decision log.
+type RequestEvent struct {
+	RunID string `json:"run_id"`
+	storage.Decision
+}
+
+func (ev RequestEvent) eventRunID() string { return ev.RunID }
+
+// Run event types, the Type of a RunEvent. Part of the API contract: add,
+// never rename or remove.
+const (
+	EventRunRegistered     = "run.registered"
+	EventRunUnregistered   = "run.unregistered"
+	EventApprovalRequested = "approval.requested"
+	EventApprovalDecided   = "approval.decided"
+	EventClipOffered       = "clip.offered"
+	EventClipAccepted      = "clip.accepted"
+	EventClipRejected      = "clip.rejected"
+	EventClipSent          = "clip.sent"
+)
+
+// RunEvent is a change in the daemon's state, as streamed by GET /v1/events:
+// a run registering or unregistering, or an approval or clip that needs the
+// user or was just decided.
+type RunEvent struct {
+	Type     string    `json:"type"`
+	RunID    string    `json:"run_id"`
+	Time     time.Time `json:"time"`
+	Approval *Approval `json:"approval,omitempty"`
+	Clip     *Clip     `json:"clip,omitempty"` // without Data
+}
+
+func (ev RunEvent) eventRunID() string { return ev.RunID }
+
+// runScoped is an event that belongs to a run.
+type runScoped interface {
+	eventRunID() string
+}
+
+// EventHub fans events out to live subscribers. Publish never blocks the
+// caller: a subscriber that stops reading loses events rather than slowing
+// request handling.
+type EventHub[E runScoped] struct {
+	mu   sync.Mutex
+	subs map[*eventSub[E]]struct{}
+}
+
+// RequestEvents streams proxied requests.
+type RequestEvents = EventHub[RequestEvent]
+
+// RunEvents streams run lifecycle, approval, and clip events.
+type RunEvents = EventHub[RunEvent]
+
+type eventSub[E runScoped] struct {
+	runID string
+	ch    chan E
+}
+
+// NewRequestEvents creates an empty request event hub.
+func NewRequestEvents() *RequestEvents {
+	return &RequestEvents{subs: make(map[*eventSub[RequestEvent]]struct{})}
+}
+
+// NewRunEvents creates an empty run event hub.
+func NewRunEvents() *RunEvents {
+	return &RunEvents{subs: make(map[*eventSub[RunEvent]]struct{})}
+}
+
+// Subscribe returns a channel of events for runID and a function that
+// unsubscribes and closes the channel. An empty runID receives every run.
+func (e *EventHub[E]) Subscribe(runID string) (<-chan E, func()) {
+	sub := &eventSub[E]{runID: runID, ch: make(chan E, requestEventBuffer)}
+	e.mu.Lock()
+	e.subs[sub] = struct{}{}
+	e.mu.Unlock()
+
+	var once sync.Once
+	return sub.ch, func() {
+		once.Do(func() {
+			e.mu.Lock()
+			delete(e.subs, sub)
+			e.mu.Unlock()
+			close(sub.ch)
+		})
+	}
+}
+
+// Publish delivers ev to every subscriber of its run.
+func (e *EventHub[E]) Publish(ev E) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for sub := range e.subs {
+		if sub.runID != "" && sub.runID != ev.eventRunID() {
+			continue
+		}
+		select {
+		case sub.ch <- ev:
+		default:
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package daemon
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/majorcontext/moat/internal/credential"
+	"github.com/majorcontext/moat/internal/log"
+)
+
+// grantExpirySweepInterval is how often the daemon purges expired grants.
+const grantExpirySweepInterval = time.Minute
+
+// RunGrantExpirySweep purges grants past their `moat grant --expires` time
+// from every credential profile, once now and then every minute until ctx is
+// canceled. Blocks until ctx is canceled.
+func RunGrantExpirySweep(ctx context.Context, reg *Registry) {
+	ticker := time.NewTicker(grantExpirySweepInterval)
+	defer ticker.Stop()
+	for {
+		SweepExpiredGrants(reg, time.Now())
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// SweepExpiredGrants deletes credentials whose grant had expired at now from
+// the default store and every profile store, and stops injecting their
+// headers into registered runs of the same profile.
+func SweepExpiredGrants(reg *Registry, now time.Time) {
+	key, err := credential.DefaultEncryptionKey()
+	if err != nil {
+		log.Debug("grant expiry sweep: cannot get encryption key", "error", err)
+		return
+	}
+	profiles, err := credential.ListProfiles()
+	if err != nil {
+		log.Debug("grant expiry sweep: cannot list profiles", "error", err)
+	}
+	for _, profile := range append([]string{""}, profiles...) {
+		store, err := credential.NewFileStore(credential.StoreDirForProfile(profile), key)
+		if err != nil {
+			log.Debug("grant expiry sweep: cannot open store", "profile", profile, "error", err)
+			continue
+		}
+		purged, err := credential.PurgeExpired(store, now)
+		if err != nil {
+			log.Warn("grant expiry sweep: could not purge every expired grant", "profile", profile, "error", err)
+		}
+		if len(purged) > 0 {
+			revokeExpiredGrants(reg, profile, purged)
+		}
+	}
+}
+
+// revokeExpiredGrants revokes purged grants in the registered runs of
+// profile, stopping everything they inject.
+func revokeExpiredGrants(reg *Registry, profile string, purged []credential.Provider) {
+	expired := make(map[credential.Provider]bool, len(purged))
+	for _, p := range purged {
+		expired[p] = true
+	}
+	for _, rc := range reg.List() {
+		if rc.CredProfile != profile {
+			continue
+		}
+		for _, grant := range rc.Grants {
+			grantName := strings.Split(grant, ":")[0]
+			if grantName == "ssh" || !expired[resolveCredName(grantName, grant)] {
+				continue
+			}
+			// Providers label injections with their grant name, MCP
+			// servers with the full grant; endpoints go by credential name.
+			n := rc.RevokeGrants(grant, grantName, string(resolveCredName(grantName, grant)))
+			log.Info("grant expired; stopped injecting its credentials",
+				"run_id", rc.RunID, "grant", grant, "injections", n)
+		}
+	}
+}
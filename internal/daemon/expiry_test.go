@@ -0,0 +1,198 @@
+package daemon
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/majorcontext/moat/internal/credential"
+	"github.com/majorcontext/moat/internal/provider"
+	azureprov "github.com/majorcontext/moat/internal/providers/azure"
+	gcpprov "github.com/majorcontext/moat/internal/providers/gcp"
+)
+
+func TestSweepExpiredGrants(t *testing.T) {
+	t.Setenv("MOAT_HOME", t.TempDir())
+	key, err := credential.DefaultEncryptionKey()
+	if err != nil {
+		t.Fatalf("encryption key: %v", err)
+	}
+	now := time.Now()
+	save := func(profile string, provider credential.Provider, expires time.Time) {
+		t.Helper()
+		store, err := credential.NewFileStore(credential.StoreDirForProfile(profile), key)
+		if err != nil {
+			t.Fatalf("open store: %v", err)
+		}
+		cred := credential.Credential{Provider: provider, Token: "tok"}
+		cred.SetGrantExpiry(expires)
+		if err := store.Save(cred); err != nil {
+			t.Fatalf("save: %v", err)
+		}
+	}
+	save("", "npm", now.Add(-time.Minute))
+	save("", "github", now.Add(time.Hour))
+	save("work", "npm", now.Add(time.Hour))
+
+	reg := NewRegistry()
+	defaultRun := NewRunContext("run-default")
+	defaultRun.Grants = []string{"npm", "github"}
+	defaultRun.SetCredentialWithGrant("registry.npmjs.org", "Authorization", "Bearer npm", "npm")
+	defaultRun.SetCredentialWithGrant("api.github.com", "Authorization", "Bearer gh", "github")
+	reg.Register(defaultRun)
+	workRun := NewRunContext("run-work")
+	workRun.CredProfile = "work"
+	workRun.Grants = []string{"npm"}
+	workRun.SetCredentialWithGrant("registry.npmjs.org", "Authorization", "Bearer npm", "npm")
+	reg.Register(workRun)
+
+	SweepExpiredGrants(reg, now)
+
+	store, _ := credential.NewFileStore(credential.StoreDirForProfile(""), key)
+	if _, err := store.Get("npm"); err == nil {
+		t.Error("expired npm grant still stored")
+	}
+	if _, err := store.Get("github"); err != nil {
+		t.Errorf("unexpired github grant purged: %v", err)
+	}
+	if got := defaultRun.GetCredentials("registry.npmjs.org"); len(got) != 0 {
+		t.Errorf("expired grant still injected: %v", got)
+	}
+	if got := defaultRun.GetCredentials("api.github.com"); len(got) != 1 {
+		t.Errorf("unexpired grant no longer injected: %v", got)
+	}
+	// The work profile's npm grant has its own expiry.
+	if got := workRun.GetCredentials("registry.npmjs.org"); len(got) != 1 {
+		t.Errorf("another profile's grant removed: %v", got)
+	}
+}
+
+func TestRevokeGrants(t *testing.T) {
+	noop := func(req, resp any) (any, bool) { return resp, false }
+
+	t.Run("credentials", func(t *testing.T) {
+		rc := NewRunContext("run_test")
+		rc.ForGrant("gemini").SetCredential("generativelanguage.googleapis.com", "Bearer gem")
+		rc.ForGrant("github").SetCredential("api.github.com", "Bearer gh")
+		rc.RevokeGrants("gemini")
+		if got := rc.GetCredentials("generativelanguage.googleapis.com"); len(got) != 0 {
+			t.Errorf("revoked credential still injected: %v", got)
+		}
+		if got := rc.GetCredentials("api.github.com"); len(got) != 1 {
+			t.Errorf("other grant's credential removed: %v", got)
+		}
+	})
+
+	t.Run("token substitutions", func(t *testing.T) {
+		rc := NewRunContext("run_test")
+		rc.ForGrant("telegram").SetTokenSubstitution("api.telegram.org", "moat-placeholder", "123:real")
+		rc.RevokeGrants("telegram")
+		if _, ok := rc.GetTokenSubstitution("api.telegram.org"); ok {
+			t.Error("revoked token substitution still applied")
+		}
+		if subs := rc.ToProxyContextData().TokenSubstitutions; len(subs) != 0 {
+			t.Errorf("proxy context has substitutions: %v", subs)
+		}
+	})
+
+	t.Run("credential files", func(t *testing.T) {
+		rc := NewRunContext("run_test")
+		secrets := []provider.FileSecret{{Path: "~/.tool/creds", Content: "token=PH", Placeholder: "PH", Hosts: []string{"api.tool.example"}}}
+		provider.ConfigureFileSecrets(rc.ForGrant("tool"), &provider.Credential{Token: "real"}, secrets)
+		rc.RevokeGrants("tool")
+		if _, ok := rc.GetTokenSubstitution("api.tool.example"); ok {
+			t.Error("revoked file secret's placeholder is still swapped for the credential")
+		}
+	})
+
+	t.Run("extra headers", func(t *testing.T) {
+		rc := NewRunContext("run_test")
+		rc.ForGrant("claude").AddExtraHeader("api.anthropic.com", "anthropic-beta", "oauth-2025-04-20")
+		rc.AddExtraHeader("api.anthropic.com", "x-config", "kept")
+		rc.RevokeGrants("claude")
+		if got := rc.GetExtraHeaders("api.anthropic.com"); len(got) != 1 || got[0].Name != "x-config" {
+			t.Errorf("extra headers = %v, want only the unattributed one", got)
+		}
+	})
+
+	t.Run("response transformers", func(t *testing.T) {
+		rc := NewRunContext("run_test")
+		rc.AddResponseTransformer("api.anthropic.com", noop)
+		rc.ForGrant("claude").AddResponseTransformer("api.anthropic.com", noop)
+		rc.TransformerSpecs = []TransformerSpec{
+			{Host: "api.stripe.com", Kind: TransformOAuthEndpointWorkaround, Grant: "claude"},
+			{Host: "example.com", Kind: TransformOAuthEndpointWorkaround},
+		}
+		rc.RevokeGrants("claude")
+		if got := rc.GetResponseTransformers("api.anthropic.com"); len(got) != 1 {
+			t.Errorf("%d transformers left, want the unattributed one", len(got))
+		}
+		if len(rc.TransformerSpecs) != 1 || rc.TransformerSpecs[0].Host != "example.com" {
+			t.Errorf("transformer specs = %+v", rc.TransformerSpecs)
+		}
+	})
+
+	t.Run("aws endpoint", func(t *testing.T) {
+		rc := NewRunContext("run_test")
+		rc.AWSConfig = &AWSConfig{RoleARN: "arn:aws:iam::1:role/r", Bedrock: true}
+		rc.SetAWSHandler(http.NotFoundHandler())
+		rc.SetBedrockHandler(http.NotFoundHandler())
+		rc.RevokeGrants("aws")
+		if h := rc.ToProxyContextData().AWSHandler; h != nil {
+			t.Error("AWS credential endpoint still served")
+		}
+		if rc.AWSConfig != nil {
+			t.Error("AWSConfig kept")
+		}
+	})
+
+	for _, tt := range []struct{ grant, path string }{
+		{"azure", azureprov.EndpointPath},
+		{"gcp", gcpprov.EndpointPath},
+	} {
+		t.Run(tt.grant+" endpoint", func(t *testing.T) {
+			rc := NewRunContext("run_test")
+			served := false
+			h := http.HandlerFunc(func(http.ResponseWriter, *http.Request) { served = true })
+			rc.SetAzureHandler(h)
+			rc.SetGCPHandler(h)
+			rc.SetCtlHandler(http.NotFoundHandler())
+			rc.RevokeGrants(tt.grant)
+			rc.ToProxyContextData().AWSHandler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", tt.path, nil))
+			if served {
+				t.Errorf("%s credential endpoint still served", tt.grant)
+			}
+		})
+	}
+
+	t.Run("later configuration ignored", func(t *testing.T) {
+		rc := NewRunContext("run_test")
+		rc.RevokeGrants("github")
+		// A token refresh already in flight when the grant expired.
+		rc.ForGrant("github").SetCredential("api.github.com", "Bearer refreshed")
+		rc.ForGrant("github").SetTokenSubstitution("api.github.com", "ph", "refreshed")
+		if got := rc.GetCredentials("api.github.com"); len(got) != 0 {
+			t.Errorf("revoked grant re-injected: %v", got)
+		}
+		if _, ok := rc.GetTokenSubstitution("api.github.com"); ok {
+			t.Error("revoked grant's substitution re-added")
+		}
+	})
+
+	t.Run("registered over the API", func(t *testing.T) {
+		req := RegisterRequest{
+			RunID:              "run_test",
+			ExtraHeaders:       []ExtraHeaderSpec{{Host: "api.anthropic.com", HeaderName: "anthropic-beta", Value: "x", Grant: "claude"}},
+			TokenSubstitutions: []TokenSubstitutionSpec{{Host: "api.telegram.org", Placeholder: "ph", RealToken: "real", Grant: "telegram"}},
+		}
+		rc := req.ToRunContext()
+		rc.RevokeGrants("claude", "telegram")
+		if len(rc.GetExtraHeaders("api.anthropic.com")) != 0 {
+			t.Error("registered extra header not revoked")
+		}
+		if _, ok := rc.GetTokenSubstitution("api.telegram.org"); ok {
+			t.Error("registered token substitution not revoked")
+		}
+	})
+}
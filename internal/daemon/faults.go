@@ -0,0 +1,230 @@
+package daemon
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/majorcontext/gatekeeper/proxy"
+	"github.com/majorcontext/moat/internal/config"
+	"github.com/majorcontext/moat/internal/log"
+)
+
+// FaultHeader is set on responses the proxy replaced or altered by fault
+// injection, so an agent's logs show which failures were injected. Its value
+// is the fault kind: "status" or "truncate". A reset fault sends no
+// response to carry it.
+const FaultHeader = "X-Moat-Fault"
+
+// errFaultInjected ends a response body cut short by a truncate fault, and
+// is logged for a request a reset fault closed the connection on.
+var errFaultInjected = errors.New("connection closed by moat fault injection")
+
+// faultRoll reports whether a fault with the given rate applies to this
+// request. Tests replace it to make faults deterministic.
+var faultRoll = func(rate float64) bool {
+	return rate == 0 || rate >= 1 || rand.Float64() < rate
+}
+
+// applyFaults wraps the response transformers of each host that has
+// truncate faults configured, so truncation runs after (not instead of)
+// transformers such as credential scrubbing. Latency, status, and reset
+// faults are injected before the request is forwarded; see faultFor.
+func applyFaults(runID string, faults []config.FaultConfig, transformers map[string][]proxy.ResponseTransformer) {
+	byHost := map[string][]config.FaultConfig{}
+	var hosts []string
+	for _, f := range faults {
+		if f.Truncate == 0 {
+			continue
+		}
+		host := strings.ToLower(f.Host)
+		if _, ok := byHost[host]; !ok {
+			hosts = append(hosts, host)
+		}
+		byHost[host] = append(byHost[host], f)
+	}
+	for _, host := range hosts {
+		transformers[host] = []proxy.ResponseTransformer{newFaultTransformer(runID, host, byHost[host], transformers[host])}
+	}
+}
+
+// newFaultTransformer returns a response transformer that rolls each
+// truncate fault and, if one applies, runs next and then cuts the (possibly
+// rewritten) body short.
+func newFaultTransformer(runID, host string, faults []config.FaultConfig, next []proxy.ResponseTransformer) proxy.ResponseTransformer {
+	return func(reqI, respI any) (any, bool) {
+		resp, ok := respI.(*http.Response)
+		if !ok {
+			return runTransformers(next, reqI, respI)
+		}
+		var failure *config.FaultConfig
+		for i := range faults {
+			if faultRoll(faults[i].Rate) {
+				failure = &faults[i]
+				break
+			}
+		}
+		if failure == nil {
+			return runTransformers(next, reqI, resp)
+		}
+
+		out, _ := runTransformers(next, reqI, resp)
+		if r, ok := out.(*http.Response); ok {
+			resp = r
+		}
+		log.Debug("fault injection: cutting response short", "run_id", runID, "host", host, "bytes", failure.Truncate)
+		truncateBody(resp, int64(failure.Truncate))
+		resp.Header.Set(FaultHeader, "truncate")
+		return resp, true
+	}
+}
+
+// requestFault is what the faults of a run inject into one request before
+// it is forwarded: a delay, then optionally a failure in place of the
+// forwarded request.
+type requestFault struct {
+	latency    time.Duration
+	status     int
+	retryAfter int
+	reset      bool
+}
+
+// failed reports whether the request is answered by the fault rather than
+// forwarded.
+func (f requestFault) failed() bool {
+	return f.status != 0 || f.reset
+}
+
+// hasRequestFaults reports whether faults has latency, status, or reset
+// faults for host:port.
+func hasRequestFaults(faults []config.FaultConfig, host string, port int) bool {
+	for _, f := range faults {
+		if faultHostMatches(f.Host, host, port) && (f.LatencyMS > 0 || f.Status != 0 || f.Reset) {
+			return true
+		}
+	}
+	return false
+}
+
+// faultFor rolls the latency, status, and reset faults for host:port. The
+// latencies of all faults that apply are added together, and the first
+// failure that applies is used.
+func faultFor(faults []config.FaultConfig, host string, port int) requestFault {
+	var rf requestFault
+	failed := false
+	for _, f := range faults {
+		if !faultHostMatches(f.Host, host, port) || (f.LatencyMS == 0 && f.Status == 0 && !f.Reset) {
+			continue
+		}
+		if !faultRoll(f.Rate) {
+			continue
+		}
+		rf.latency += time.Duration(f.LatencyMS) * time.Millisecond
+		if !failed && (f.Status != 0 || f.Reset) {
+			failed = true
+			rf.status, rf.retryAfter, rf.reset = f.Status, f.RetryAfter, f.Reset
+		}
+	}
+	return rf
+}
+
+// requestFault rolls rc's latency, status, and reset faults for a request
+// to host:port.
+func (rc *RunContext) requestFault(host string, port int) requestFault {
+	rc.mu.RLock()
+	faults := rc.Faults
+	rc.mu.RUnlock()
+	return faultFor(faults, host, port)
+}
+
+// faultHostMatches reports whether a fault's host names host:port. Like the
+// proxy's per-host transformers, a fault host matches either the bare host
+// or host:port.
+func faultHostMatches(faultHost, host string, port int) bool {
+	faultHost = strings.ToLower(faultHost)
+	host = strings.ToLower(host)
+	return faultHost == host || faultHost == net.JoinHostPort(host, strconv.Itoa(port))
+}
+
+// runTransformers applies transformers the way the proxy does: the first one
+// that reports a change ends the chain.
+func runTransformers(transformers []proxy.ResponseTransformer, req, resp any) (any, bool) {
+	for _, tf := range transformers {
+		if out, changed := tf(req, resp); changed {
+			return out, true
+		}
+	}
+	return resp, false
+}
+
+// faultSleep waits for d or until the request is canceled.
+func faultSleep(req *http.Request, d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+	case <-req.Context().Done():
+	}
+}
+
+// writeFaultStatus answers a request with a JSON error of the given status,
+// in place of forwarding it.
+func writeFaultStatus(w http.ResponseWriter, status, retryAfter int) {
+	body := fmt.Sprintf(`{"error":{"type":"moat_fault_injection","message":"HTTP %d injected by moat network.faults"}}`, status)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set(FaultHeader, "status")
+	if retryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(status)
+	_, _ = io.WriteString(w, body)
+}
+
+// truncateBody makes resp's body fail after n bytes. The announced length is
+// kept larger than n, so the client sees the connection close mid-response
+// rather than a complete short body.
+func truncateBody(resp *http.Response, n int64) {
+	declared := resp.ContentLength
+	if declared <= n {
+		declared = n + 1
+	}
+	if resp.Header == nil {
+		resp.Header = http.Header{}
+	}
+	resp.ContentLength = declared
+	resp.Header.Set("Content-Length", strconv.FormatInt(declared, 10))
+	resp.TransferEncoding = nil
+
+	var body io.ReadCloser = io.NopCloser(bytes.NewReader(nil))
+	if resp.Body != nil {
+		body = resp.Body
+	}
+	resp.Body = &faultBody{r: io.LimitReader(body, n), c: body}
+}
+
+// faultBody passes reads through until its limit, then fails.
+type faultBody struct {
+	r io.Reader
+	c io.Closer
+}
+
+func (b *faultBody) Read(p []byte) (int, error) {
+	n, err := b.r.Read(p)
+	if err == io.EOF {
+		err = errFaultInjected
+	}
+	return n, err
+}
+
+func (b *faultBody) Close() error { return b.c.Close() }
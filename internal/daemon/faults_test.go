@@ -0,0 +1,90 @@
+package daemon
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/majorcontext/moat/internal/config"
+)
+
+func faultResponse(body string) *http.Response {
+	return &http.Response{
+		StatusCode:    http.StatusOK,
+		Header:        http.Header{"Content-Type": {"text/plain"}},
+		Body:          io.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+	}
+}
+
+func TestFaultFor(t *testing.T) {
+	old := faultRoll
+	t.Cleanup(func() { faultRoll = old })
+
+	faults := []config.FaultConfig{
+		{Host: "API.example.com", LatencyMS: 20},
+		{Host: "api.example.com", Status: 503, Rate: 0.5},
+		{Host: "api.example.com", Status: 429, RetryAfter: 5},
+		{Host: "api.example.com:8443", Reset: true},
+		{Host: "api.example.com", Truncate: 10},
+	}
+	if !hasRequestFaults(faults, "api.example.com", 443) || hasRequestFaults(faults, "other.example.com", 443) {
+		t.Error("hasRequestFaults does not follow the fault hosts")
+	}
+
+	// Latencies add up; the first failure that applies is used.
+	faultRoll = func(float64) bool { return true }
+	f := faultFor(faults, "api.example.com", 443)
+	if f.latency != 20*time.Millisecond || f.status != 503 || f.reset {
+		t.Errorf("faultFor = %+v, want 20ms and 503", f)
+	}
+	faultRoll = func(rate float64) bool { return rate == 0 }
+	if f := faultFor(faults, "api.example.com", 443); f.status != 429 || f.retryAfter != 5 {
+		t.Errorf("faultFor = %+v, want 429 with Retry-After 5", f)
+	}
+	// A host:port entry applies to that port only.
+	if f := faultFor(faults[3:], "api.example.com", 8443); !f.reset {
+		t.Errorf("faultFor on port 8443 = %+v, want a reset", f)
+	}
+
+	// Nothing applies.
+	faultRoll = func(float64) bool { return false }
+	if f := faultFor(faults, "api.example.com", 443); f.latency != 0 || f.failed() {
+		t.Errorf("faultFor = %+v, want no fault when none rolls", f)
+	}
+}
+
+func TestApplyFaults_TruncateOnly(t *testing.T) {
+	rc := NewRunContext("run_fault")
+	rc.Faults = []config.FaultConfig{{Host: "api.example.com", Status: 429, LatencyMS: 10}}
+	if tfs := rc.ToProxyContextData().ResponseTransformers["api.example.com"]; len(tfs) != 0 {
+		t.Errorf("got %d response transformers for request faults, want 0", len(tfs))
+	}
+}
+
+func TestFaultTruncateRunsScrubberFirst(t *testing.T) {
+	rc := NewRunContext("run_fault")
+	rc.SetTokenSubstitution("api.example.com", "moat-placeholder", "real-secret")
+	rc.TransformerSpecs = []TransformerSpec{{Host: "api.example.com", Kind: TransformResponseScrub}}
+	rc.Faults = []config.FaultConfig{{Host: "api.example.com", Truncate: 10}}
+
+	tfs := rc.ToProxyContextData().ResponseTransformers["api.example.com"]
+	out, changed := tfs[0](&http.Request{}, faultResponse("real-secret and more"))
+	resp := out.(*http.Response)
+	if !changed || resp.Header.Get(FaultHeader) != "truncate" {
+		t.Fatalf("changed=%v, %s=%q; want truncate", changed, FaultHeader, resp.Header.Get(FaultHeader))
+	}
+	body, err := io.ReadAll(resp.Body)
+	if !errors.Is(err, errFaultInjected) {
+		t.Errorf("read error = %v, want errFaultInjected", err)
+	}
+	if string(body) != "moat-place" {
+		t.Errorf("body = %q, want the first 10 bytes of the scrubbed body", body)
+	}
+	if resp.ContentLength <= int64(len(body)) {
+		t.Errorf("ContentLength = %d, want more than the %d bytes delivered", resp.ContentLength, len(body))
+	}
+}
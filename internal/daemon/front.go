@@ -0,0 +1,647 @@
+package daemon
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"io"
+	stdlog "log"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/majorcontext/gatekeeper/proxy"
+	"github.com/majorcontext/moat/internal/log"
+)
+
+// frontDialTimeout bounds opening a tunnel to the credential proxy.
+const frontDialTimeout = 30 * time.Second
+
+// Front is the daemon's proxy listener. It hands each request to the
+// credential proxy, first running the checks of the request's run that must
+// happen before a request is forwarded (see checkRequest in guards.go). A
+// request those checks refuse never reaches the proxy: the front answers it
+// with a reason of its own, distinct from the proxy's network policy denials.
+// The run's latency, status, and reset faults (network.faults) are injected
+// here too, so a request failed by a fault never reaches the upstream.
+//
+// The proxy intercepts every HTTPS tunnel, so for a host the run's checks
+// apply to the front does the same: it terminates the agent's TLS with the
+// proxy's CA, checks each request, and forwards the ones it allows through a
+// tunnel of its own to the proxy, which is served in-process. Requests to
+// other hosts, and runs without such checks, go to the proxy untouched.
+//
+// The proxy admits HTTPS tunnels by exact or wildcard host, so before
+// handing on a tunnel to a raw IP address within one of the run's CIDR
+// network.rules, the front admits that address to the run (see
+// admitCIDRTunnel).
+//
+// The run's request transformers (network.transforms kinds such as
+// request-path-rewrite) are applied here as well, after the checks, and a
+// request a transformer fails on is refused.
+//
+// Requests a run mirrors by replay (network.mirror) pass through the front
+// too: it keeps a copy of each one's body as the proxy reads it, and once
+// the proxy has forwarded the request, replays it against the mirror URL.
+type Front struct {
+	next     http.Handler
+	registry *Registry
+	ca       *proxy.CA
+	roots    *x509.CertPool
+	logger   proxy.RequestLogger
+	mirror   *Mirror
+
+	backend    *pipeListener
+	backendSrv *http.Server
+
+	server   *http.Server
+	addr     string
+	bindAddr string
+	port     int
+}
+
+// NewFront returns a Front for the credential proxy next, resolving runs
+// from registry. ca must be the CA next intercepts TLS with.
+func NewFront(next http.Handler, registry *Registry, ca *proxy.CA) *Front {
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM(ca.CertPEM())
+	return &Front{
+		next:     next,
+		registry: registry,
+		ca:       ca,
+		roots:    roots,
+		bindAddr: "127.0.0.1",
+	}
+}
+
+// SetLogger sets the logger for requests the front refuses. It should be
+// the proxy's own logger.
+func (f *Front) SetLogger(logger proxy.RequestLogger) {
+	f.logger = logger
+}
+
+// SetMirror sets the Mirror that replays requests of runs that mirror by
+// replay. Must be called before Start.
+func (f *Front) SetMirror(m *Mirror) {
+	f.mirror = m
+}
+
+// SetBindAddr sets the address to listen on. Must be called before Start.
+func (f *Front) SetBindAddr(addr string) {
+	f.bindAddr = addr
+}
+
+// SetPort sets the port to listen on; 0 means an OS-assigned port. Must be
+// called before Start.
+func (f *Front) SetPort(port int) {
+	f.port = port
+}
+
+// Start listens on the configured address and serves in the background.
+func (f *Front) Start() error {
+	listener, err := net.Listen("tcp", net.JoinHostPort(f.bindAddr, strconv.Itoa(f.port)))
+	if err != nil {
+		return fmt.Errorf("creating listener: %w", err)
+	}
+	if f.backendSrv == nil {
+		f.backend = newPipeListener()
+		f.backendSrv = &http.Server{
+			Handler:           f.next,
+			ReadHeaderTimeout: 60 * time.Second,
+			IdleTimeout:       120 * time.Second,
+		}
+		go func() { _ = f.backendSrv.Serve(f.backend) }()
+	}
+	f.addr = listener.Addr().String()
+	f.server = &http.Server{
+		Handler:           f,
+		ReadHeaderTimeout: 60 * time.Second,
+		IdleTimeout:       120 * time.Second,
+	}
+	go func() { _ = f.server.Serve(listener) }()
+	return nil
+}
+
+// Port returns the port the front is listening on.
+func (f *Front) Port() string {
+	_, port, _ := net.SplitHostPort(f.addr)
+	return port
+}
+
+// Stop shuts the front down, then the in-process proxy server.
+func (f *Front) Stop(ctx context.Context) error {
+	var err error
+	if f.server != nil {
+		err = f.server.Shutdown(ctx)
+	}
+	if f.backendSrv != nil {
+		_ = f.backendSrv.Close()
+	}
+	return err
+}
+
+// ServeHTTP implements http.Handler.
+func (f *Front) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Direct requests (relays, MCP, credential endpoints) are the proxy's.
+	if r.Method != http.MethodConnect && r.URL.Host == "" {
+		f.next.ServeHTTP(w, r)
+		return
+	}
+	rc := f.runContext(r)
+	if rc == nil {
+		// The proxy rejects the missing or unknown token.
+		f.next.ServeHTTP(w, r)
+		return
+	}
+	host, port := targetHostPort(r)
+	if r.Method == http.MethodConnect {
+		rc.admitCIDRTunnel(host, port)
+	}
+	if !rc.guardsHost(host, port) {
+		f.next.ServeHTTP(w, r)
+		return
+	}
+	if r.Method == http.MethodConnect {
+		f.intercept(w, r, rc, host, port)
+		return
+	}
+	f.serveGuarded(w, r, rc, host, port, "http", f.next)
+}
+
+// runContext resolves the run a proxied request belongs to from its
+// Proxy-Authorization header, as the proxy does.
+func (f *Front) runContext(r *http.Request) *RunContext {
+	token, ok := proxyToken(r)
+	if !ok {
+		return nil
+	}
+	rc, _ := f.registry.Lookup(token)
+	return rc
+}
+
+// proxyToken extracts the run's token from a Bearer Proxy-Authorization
+// header, or from the password of a Basic one.
+func proxyToken(r *http.Request) (string, bool) {
+	auth := r.Header.Get("Proxy-Authorization")
+	if token, ok := strings.CutPrefix(auth, "Bearer "); ok {
+		return token, true
+	}
+	if encoded, ok := strings.CutPrefix(auth, "Basic "); ok {
+		decoded, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return "", false
+		}
+		_, password, ok := strings.Cut(string(decoded), ":")
+		return password, ok
+	}
+	return "", false
+}
+
+// targetHostPort returns the host and port a proxied request is for.
+func targetHostPort(r *http.Request) (string, int) {
+	hostport, port := r.Host, 443
+	if r.Method != http.MethodConnect {
+		hostport = r.URL.Host
+		if r.URL.Scheme == "http" {
+			port = 80
+		}
+	}
+	host, portStr, err := net.SplitHostPort(hostport)
+	if err != nil {
+		return strings.Trim(hostport, "[]"), port
+	}
+	if n, err := strconv.Atoi(portStr); err == nil {
+		port = n
+	}
+	return host, port
+}
+
+// serveGuarded runs rc's checks and faults on req and, if they pass, hands
+// it to next. A count a check took for the request is given back if a fault
+// or the proxy then refuses it.
+func (f *Front) serveGuarded(w http.ResponseWriter, req *http.Request, rc *RunContext, host string, port int, reqType string, next http.Handler) {
+	start := time.Now()
+	adm, d := rc.checkRequest(req, host, port)
+	if d != nil {
+		f.refuse(w, req, rc, host, reqType, start, d)
+		return
+	}
+	if fault := rc.requestFault(host, port); fault.latency > 0 || fault.failed() {
+		faultSleep(req, fault.latency)
+		if fault.failed() {
+			adm.undo()
+			f.injectFault(w, req, rc, host, reqType, start, fault)
+			return
+		}
+	}
+	var replay *mirrorReplay
+	if f.mirror != nil {
+		replay = newMirrorReplay(rc, req, host, port)
+	}
+	if d := rc.transformRequest(req, host, port); d != nil {
+		adm.undo()
+		if adm.upload.exceeded() {
+			d = uploadDenial(adm.upload.limit)
+		}
+		f.refuse(w, req, rc, host, reqType, start, d)
+		return
+	}
+	sw := &statusWriter{ResponseWriter: w, upload: adm.upload}
+	next.ServeHTTP(sw, req)
+	if sw.blocked || sw.cut {
+		adm.undo()
+	} else if replay != nil && sw.wrote {
+		f.mirror.replay(replay)
+	}
+	if sw.cut {
+		// The body passed its cap while being forwarded, failing the
+		// request; answer for it in place of the forwarding error.
+		f.refuse(w, req, rc, host, reqType, start, uploadDenial(adm.upload.limit))
+	}
+}
+
+// refuse answers a request a check refused and logs it as denied.
+func (f *Front) refuse(w http.ResponseWriter, req *http.Request, rc *RunContext, host, reqType string, start time.Time, d *denial) {
+	w.Header().Set(blockedHeader, d.kind)
+	w.Header().Set("Content-Type", "text/plain")
+	w.WriteHeader(d.status)
+	fmt.Fprintf(w, "Moat: request blocked — %s.\nHost: %s\n", d.message, host)
+
+	f.log(req, rc, proxy.RequestLogData{
+		Method:         req.Method,
+		URL:            req.URL.String(),
+		Host:           host,
+		Path:           req.URL.Path,
+		RequestType:    reqType,
+		StatusCode:     d.status,
+		Duration:       time.Since(start),
+		RequestHeaders: req.Header.Clone(),
+		RequestSize:    req.ContentLength,
+		ResponseSize:   -1,
+		Denied:         true,
+		DenyReason:     d.reason,
+	})
+}
+
+// injectFault answers a request with the failure a fault injects, in place
+// of forwarding it, and logs the result. A reset closes the connection
+// without a response.
+func (f *Front) injectFault(w http.ResponseWriter, req *http.Request, rc *RunContext, host, reqType string, start time.Time, fault requestFault) {
+	data := proxy.RequestLogData{
+		Method:         req.Method,
+		URL:            req.URL.String(),
+		Host:           host,
+		Path:           req.URL.Path,
+		RequestType:    reqType,
+		RequestHeaders: req.Header.Clone(),
+		RequestSize:    req.ContentLength,
+		ResponseSize:   -1,
+	}
+	if fault.reset {
+		log.Debug("fault injection: reset connection", "run_id", rc.RunID, "host", host)
+		data.Duration = time.Since(start)
+		data.Err = errFaultInjected
+		f.log(req, rc, data)
+		panic(http.ErrAbortHandler)
+	}
+	log.Debug("fault injection: replaced response", "run_id", rc.RunID, "host", host, "status", fault.status)
+	writeFaultStatus(w, fault.status, fault.retryAfter)
+	data.StatusCode = fault.status
+	data.Duration = time.Since(start)
+	data.ResponseHeaders = w.Header().Clone()
+	f.log(req, rc, data)
+}
+
+// log passes data for a request of rc's to the request logger.
+func (f *Front) log(req *http.Request, rc *RunContext, data proxy.RequestLogData) {
+	if f.logger == nil {
+		return
+	}
+	data.RunID = rc.RunID
+	data.RequestID = req.Header.Get("X-Request-Id")
+	data.Ctx = req.Context()
+	f.logger(data)
+}
+
+// intercept terminates the agent's TLS tunnel to host and serves the
+// requests in it, opening a tunnel of its own to the proxy for the requests
+// rc's checks allow. If the proxy refuses the tunnel, its answer is relayed.
+func (f *Front) intercept(w http.ResponseWriter, r *http.Request, rc *RunContext, host string, port int) {
+	auth := r.Header.Get("Proxy-Authorization")
+	tunnel, refusal, err := f.dialProxy(r.Context(), r.Host, auth)
+	if err != nil {
+		log.Debug("front: cannot open tunnel to proxy", "subsystem", "daemon", "host", r.Host, "error", err)
+		http.Error(w, "moat proxy: tunnel failed", http.StatusBadGateway)
+		return
+	}
+	if refusal != nil {
+		for name, values := range refusal.Header {
+			w.Header()[name] = values
+		}
+		w.WriteHeader(refusal.StatusCode)
+		_, _ = io.Copy(w, refusal.Body)
+		return
+	}
+
+	var first atomic.Pointer[net.Conn]
+	first.Store(&tunnel)
+	defer func() {
+		if c := first.Swap(nil); c != nil {
+			(*c).Close()
+		}
+	}()
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "hijacking not supported", http.StatusInternalServerError)
+		return
+	}
+	clientConn, _, err := hijacker.Hijack()
+	if err != nil {
+		return
+	}
+	// An upgraded connection (a WebSocket) is closed by the reverse proxy.
+	var hijacked atomic.Bool
+	defer func() {
+		if !hijacked.Load() {
+			clientConn.Close()
+		}
+	}()
+	_, _ = clientConn.Write([]byte("HTTP/1.1 200 Connection Established\r\n\r\n"))
+
+	cert, err := f.ca.GenerateCert(host)
+	if err != nil {
+		log.Debug("front: cannot generate certificate", "subsystem", "daemon", "host", host, "error", err)
+		return
+	}
+	tlsConn := tls.Server(clientConn, &tls.Config{
+		Certificates: []tls.Certificate{*cert},
+		MinVersion:   tls.VersionTLS12,
+		NextProtos:   []string{"http/1.1"},
+	})
+	if err := tlsConn.Handshake(); err != nil {
+		log.Debug("front: TLS handshake failed", "subsystem", "daemon", "host", host, "error", err)
+		return
+	}
+
+	transport := &http.Transport{
+		DialTLSContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var conn net.Conn
+			if c := first.Swap(nil); c != nil {
+				conn = *c
+			} else {
+				var refusal *http.Response
+				var err error
+				conn, refusal, err = f.dialProxy(ctx, r.Host, auth)
+				if err != nil {
+					return nil, err
+				}
+				if refusal != nil {
+					return nil, fmt.Errorf("proxy refused tunnel: %s", refusal.Status)
+				}
+			}
+			tc := tls.Client(conn, &tls.Config{
+				ServerName: host,
+				RootCAs:    f.roots,
+				MinVersion: tls.VersionTLS12,
+				NextProtos: []string{"http/1.1"},
+			})
+			if err := tc.HandshakeContext(ctx); err != nil {
+				conn.Close()
+				return nil, err
+			}
+			return tc, nil
+		},
+		ResponseHeaderTimeout: 5 * time.Minute,
+		IdleConnTimeout:       90 * time.Second,
+	}
+	defer transport.CloseIdleConnections()
+
+	forward := &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.Out.URL.Scheme = "https"
+			pr.Out.URL.Host = r.Host
+			pr.Out.Host = pr.In.Host
+		},
+		Transport:     transport,
+		FlushInterval: -1,
+		ErrorLog:      stdlog.New(io.Discard, "", 0),
+		ErrorHandler: func(w http.ResponseWriter, req *http.Request, err error) {
+			log.Debug("front: forwarding failed", "subsystem", "daemon", "run_id", rc.RunID, "host", host, "error", err)
+			http.Error(w, "moat proxy: upstream request failed", http.StatusBadGateway)
+		},
+	}
+	handler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		// Give the request its full URL, as a proxied one has.
+		req.URL.Scheme = "https"
+		req.URL.Host = r.Host
+		f.serveGuarded(w, req, rc, host, port, "connect", forward)
+	})
+
+	ln := newOneConnListener(tlsConn)
+	srv := &http.Server{
+		Handler:     handler,
+		IdleTimeout: 120 * time.Second,
+		ErrorLog:    stdlog.New(io.Discard, "", 0),
+		ConnState: func(_ net.Conn, state http.ConnState) {
+			if state == http.StateHijacked {
+				hijacked.Store(true)
+			}
+			if state == http.StateClosed || state == http.StateHijacked {
+				ln.Close()
+			}
+		},
+	}
+	_ = srv.Serve(ln)
+}
+
+// dialProxy opens a tunnel to target through the in-process proxy,
+// authenticating with auth. If the proxy refuses, its response is returned
+// with the body read, and no connection.
+func (f *Front) dialProxy(ctx context.Context, target, auth string) (net.Conn, *http.Response, error) {
+	ctx, cancel := context.WithTimeout(ctx, frontDialTimeout)
+	defer cancel()
+	conn, err := f.backend.dial(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	deadline, _ := ctx.Deadline()
+	_ = conn.SetDeadline(deadline)
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: target},
+		Host:   target,
+		Header: http.Header{},
+	}
+	if auth != "" {
+		req.Header.Set("Proxy-Authorization", auth)
+	}
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		resp.Body.Close()
+		conn.Close()
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+		return nil, resp, nil
+	}
+	_ = conn.SetDeadline(time.Time{})
+	return &bufferedConn{Conn: conn, r: br}, nil, nil
+}
+
+// statusWriter notes whether the proxy refused a request it was handed,
+// which it marks with the X-Moat-Blocked header. If the request's body was
+// cut off at its upload cap before the response began, the response (the
+// forwarding error) is dropped, and cut is set.
+type statusWriter struct {
+	http.ResponseWriter
+	upload  *uploadLimiter
+	wrote   bool
+	blocked bool
+	cut     bool
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	if !w.wrote && code >= 200 {
+		w.wrote = true
+		w.blocked = w.Header().Get(blockedHeader) != ""
+		w.cut = w.upload.exceeded()
+		if w.cut {
+			clear(w.Header())
+		}
+	}
+	if w.cut {
+		return
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if !w.wrote {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.cut {
+		return len(b), nil
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer to flush
+// streamed responses and hijack upgraded connections.
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// bufferedConn is a net.Conn whose reads drain a bufio.Reader first.
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
+
+// pipeListener is an in-process net.Listener: each dial hands Accept one
+// end of a pipe.
+type pipeListener struct {
+	conns     chan net.Conn
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func newPipeListener() *pipeListener {
+	return &pipeListener{conns: make(chan net.Conn), closed: make(chan struct{})}
+}
+
+func (l *pipeListener) dial(ctx context.Context) (net.Conn, error) {
+	client, server := net.Pipe()
+	select {
+	case l.conns <- server:
+		return client, nil
+	case <-l.closed:
+	case <-ctx.Done():
+	}
+	client.Close()
+	server.Close()
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	return nil, net.ErrClosed
+}
+
+func (l *pipeListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.closed:
+		return nil, net.ErrClosed
+	}
+}
+
+func (l *pipeListener) Close() error {
+	l.closeOnce.Do(func() { close(l.closed) })
+	return nil
+}
+
+func (l *pipeListener) Addr() net.Addr {
+	return pipeAddr{}
+}
+
+type pipeAddr struct{}
+
+func (pipeAddr) Network() string { return "pipe" }
+func (pipeAddr) String() string  { return "moat-proxy" }
+
+// oneConnListener is a net.Listener that accepts a single connection, then
+// blocks until closed, keeping http.Server.Serve running for the life of
+// the connection.
+type oneConnListener struct {
+	conn      net.Conn
+	conns     chan net.Conn
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func newOneConnListener(conn net.Conn) *oneConnListener {
+	conns := make(chan net.Conn, 1)
+	conns <- conn
+	return &oneConnListener{conn: conn, conns: conns, closed: make(chan struct{})}
+}
+
+func (l *oneConnListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.closed:
+		return nil, net.ErrClosed
+	}
+}
+
+func (l *oneConnListener) Close() error {
+	l.closeOnce.Do(func() { close(l.closed) })
+	return nil
+}
+
+func (l *oneConnListener) Addr() net.Addr {
+	return l.conn.LocalAddr()
+}
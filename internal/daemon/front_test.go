@@ -0,0 +1,376 @@
+package daemon
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/majorcontext/gatekeeper/proxy"
+	"github.com/majorcontext/moat/internal/config"
+	"github.com/majorcontext/moat/internal/metering"
+	"github.com/majorcontext/moat/internal/netrules"
+	"github.com/majorcontext/moat/internal/storage"
+)
+
+// frontTest is a credential proxy behind a Front, with one registered run.
+type frontTest struct {
+	rc       *RunContext
+	client   *http.Client
+	upstream *httptest.Server
+
+	mu   sync.Mutex
+	logs []proxy.RequestLogData
+}
+
+func newFrontTest(t *testing.T, rc *RunContext, upstream http.Handler) *frontTest {
+	t.Helper()
+	ft := &frontTest{rc: rc, upstream: httptest.NewTLSServer(upstream)}
+	t.Cleanup(ft.upstream.Close)
+
+	ca, err := proxy.NewCA(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	registry := NewRegistry()
+	token := registry.Register(rc)
+
+	logger := func(data proxy.RequestLogData) {
+		ft.mu.Lock()
+		defer ft.mu.Unlock()
+		ft.logs = append(ft.logs, data)
+	}
+	p := proxy.NewProxy()
+	p.SetCA(ca)
+	upstreamCAs := x509.NewCertPool()
+	upstreamCAs.AddCert(ft.upstream.Certificate())
+	p.SetUpstreamCAs(upstreamCAs)
+	p.SetContextResolver(func(token string) (*proxy.RunContextData, bool) {
+		rc, ok := registry.Lookup(token)
+		if !ok {
+			return nil, false
+		}
+		return rc.ToProxyContextData(), true
+	})
+	p.SetLogger(logger)
+
+	front := NewFront(p, registry, ca)
+	front.SetLogger(logger)
+	front.SetMirror(NewMirror())
+	if err := front.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = front.Stop(context.Background()) })
+
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM(ca.CertPEM())
+	proxyURL := &url.URL{Scheme: "http", User: url.UserPassword("moat", token), Host: "127.0.0.1:" + front.Port()}
+	ft.client = &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: &http.Transport{
+			Proxy:           http.ProxyURL(proxyURL),
+			TLSClientConfig: &tls.Config{RootCAs: roots},
+		},
+	}
+	return ft
+}
+
+// denied returns the logged requests the proxy or the front denied.
+func (ft *frontTest) denied() []proxy.RequestLogData {
+	ft.mu.Lock()
+	defer ft.mu.Unlock()
+	var out []proxy.RequestLogData
+	for _, d := range ft.logs {
+		if d.Denied {
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+func TestFront_PassesUnguardedRequests(t *testing.T) {
+	ft := newFrontTest(t, NewRunContext("run_test"), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "hello")
+	}))
+	resp, err := ft.client.Get(ft.upstream.URL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || string(body) != "hello" {
+		t.Errorf("response = %d %q, want 200 hello", resp.StatusCode, body)
+	}
+}
+
+func TestFront_RelaysProxyDenials(t *testing.T) {
+	rc := NewRunContext("run_test")
+	rc.NetworkPolicy = "strict"
+	ft := newFrontTest(t, rc, http.NotFoundHandler())
+
+	qt := NewQuotaTracker(map[string]config.ProviderQuota{"anthropic": {DailyTokens: 10}}, metering.DefaultPrices())
+	SetQuotaTracker(qt)
+	t.Cleanup(func() { SetQuotaTracker(nil) })
+
+	// A guarded host outside the allow list is denied by the proxy, with
+	// the proxy's reason.
+	_, err := ft.client.Post("https://api.anthropic.com/v1/messages", "application/json", strings.NewReader("{}"))
+	if err == nil {
+		t.Fatal("request to a host outside the allow list succeeded")
+	}
+	denied := ft.denied()
+	if len(denied) != 1 || !strings.HasPrefix(denied[0].DenyReason, "Host not in allow list: ") {
+		t.Errorf("denied = %+v, want one allow-list denial", denied)
+	}
+}
+
+func TestFront_AdmitsCIDRTunnels(t *testing.T) {
+	rc := NewRunContext("run_test")
+	rc.NetworkPolicy = "strict"
+	ft := newFrontTest(t, rc, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "hello")
+	}))
+	_, port, _ := strings.Cut(strings.TrimPrefix(ft.upstream.URL, "https://"), ":")
+	rc.NetworkRules = []netrules.HostRules{{Host: "127.0.0.0/30:" + port}}
+
+	resp, err := ft.client.Get(ft.upstream.URL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || string(body) != "hello" {
+		t.Errorf("response = %d %q, want 200 hello", resp.StatusCode, body)
+	}
+	if len(rc.cidrAdmitted) != 1 {
+		t.Errorf("admitted = %v, want the one address connected to", rc.cidrAdmitted)
+	}
+}
+
+func TestFront_ReplaysMirroredRequests(t *testing.T) {
+	type replayed struct {
+		method, uri, body string
+		header            http.Header
+	}
+	got := make(chan replayed, 1)
+	mirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		got <- replayed{r.Method, r.RequestURI, string(body), r.Header}
+	}))
+	t.Cleanup(mirror.Close)
+
+	rc := NewRunContext("run_test")
+	ft := newFrontTest(t, rc, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"id":"msg_1"}`)
+	}))
+	host := strings.TrimPrefix(ft.upstream.URL, "https://")
+	rc.SetCredentialHeader(host, "x-api-key", "sk-ant-REDACTED")
+	rc.Mirror = &config.MirrorConfig{URL: mirror.URL + "/candidate", Hosts: []string{"127.0.0.1"}}
+
+	req, _ := http.NewRequest("POST", ft.upstream.URL+"/v1/messages?beta=true", strings.NewReader(`{"model":"x"}`))
+	req.Header.Set("x-api-key", "moat-placeholder")
+	req.Header.Set("anthropic-version", "2023-06-01")
+	resp, err := ft.client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != `{"id":"msg_1"}` {
+		t.Errorf("primary response = %q", body)
+	}
+
+	select {
+	case r := <-got:
+		if r.method != "POST" || r.uri != "/candidate/v1/messages?beta=true" || r.body != `{"model":"x"}` {
+			t.Errorf("replayed %s %s %q", r.method, r.uri, r.body)
+		}
+		if r.header.Get("anthropic-version") != "2023-06-01" || r.header.Get("X-Moat-Run-Id") != "run_test" {
+			t.Errorf("replayed headers = %v", r.header)
+		}
+		if r.header.Get("x-api-key") != "" || r.header.Get("Proxy-Authorization") != "" {
+			t.Errorf("credentials replayed to the mirror: %v", r.header)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("mirror never received the replayed request")
+	}
+}
+
+func TestFront_TransformsRequests(t *testing.T) {
+	rc := NewRunContext("run_test")
+	ft := newFrontTest(t, rc, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		io.WriteString(w, r.URL.Path+" "+string(body))
+	}))
+	host := strings.TrimPrefix(ft.upstream.URL, "https://")
+	rc.TransformerSpecs = []TransformerSpec{
+		{Host: host, Kind: TransformRequestPathRewrite, Args: map[string]string{"from": "/old/", "to": "/new/"}},
+		{Host: host, Kind: TransformRequestBodyTemplate, Args: map[string]string{"template": `{"wrapped":{{.Body}}}`}},
+	}
+
+	resp, err := ft.client.Post(ft.upstream.URL+"/old/charge", "application/json", strings.NewReader(`{"n":1}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != `/new/charge {"wrapped":{"n":1}}` {
+		t.Errorf("upstream saw %q", body)
+	}
+}
+
+func TestFront_RefusesBeforeForwarding(t *testing.T) {
+	ft := newFrontTest(t, NewRunContext("run_test"), http.NotFoundHandler())
+
+	qt := NewQuotaTracker(map[string]config.ProviderQuota{"anthropic": {DailyTokens: 10}}, metering.DefaultPrices())
+	SetQuotaTracker(qt)
+	t.Cleanup(func() { SetQuotaTracker(nil) })
+	qt.Add(storage.Usage{Timestamp: time.Now(), Provider: "anthropic", OutputTokens: 10})
+
+	// The request never leaves the daemon: api.anthropic.com is not
+	// reachable from the test.
+	resp, err := ft.client.Post("https://api.anthropic.com/v1/messages", "application/json", strings.NewReader("{}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusTooManyRequests || resp.Header.Get("X-Moat-Blocked") != "quota" {
+		t.Errorf("response = %d %v %q, want a 429 quota block", resp.StatusCode, resp.Header, body)
+	}
+	denied := ft.denied()
+	if len(denied) != 1 {
+		t.Fatalf("denied = %+v, want one", denied)
+	}
+	if d := denied[0]; d.RunID != "run_test" || d.DenyReason != "Daily LLM quota reached: anthropic" ||
+		d.URL != "https://api.anthropic.com:443/v1/messages" || d.RequestType != "connect" {
+		t.Errorf("logged denial = %+v", d)
+	}
+}
+
+func TestFront_UncountsSendsTheProxyDenies(t *testing.T) {
+	rc := NewRunContext("run_test")
+	rc.NetworkRules = []netrules.HostRules{
+		{Host: "api.sendgrid.com", Rules: []netrules.Rule{{Action: "deny", Method: "POST", PathPattern: "/v3/mail/send"}}},
+	}
+	rc.SendGuard = newSendGuard(5)
+	ft := newFrontTest(t, rc, http.NotFoundHandler())
+
+	resp, err := ft.client.Post("https://api.sendgrid.com/v3/mail/send", "application/json", strings.NewReader("{}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.Header.Get("X-Moat-Blocked") != "request-rule" {
+		t.Errorf("response = %d %v, want the proxy's rule denial", resp.StatusCode, resp.Header)
+	}
+	if got := rc.SendGuard.Count(); got != 0 {
+		t.Errorf("Count() = %d after a rule denial, want 0", got)
+	}
+}
+
+func TestFront_CapsUploadsBeforeForwarding(t *testing.T) {
+	rc := NewRunContext("run_test")
+	rc.UploadGuard = NewUploadGuard(1, nil)
+	var received atomic.Int64
+	ft := newFrontTest(t, rc, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n, err := io.Copy(io.Discard, r.Body)
+		if err == nil {
+			received.Add(n)
+		}
+		io.WriteString(w, "stored")
+	}))
+
+	// A declared size over the cap is refused without contacting upstream.
+	resp, err := ft.client.Post(ft.upstream.URL+"/upload", "application/octet-stream", bytes.NewReader(make([]byte, 2<<20)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusRequestEntityTooLarge || resp.Header.Get("X-Moat-Blocked") != "upload-limit" {
+		t.Errorf("response = %d %v, want a 413 upload-limit block", resp.StatusCode, resp.Header)
+	}
+
+	// A body of unknown length fails once it passes the cap.
+	resp, err = ft.client.Post(ft.upstream.URL+"/upload", "application/octet-stream", io.NopCloser(bytes.NewReader(make([]byte, 2<<20))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusRequestEntityTooLarge || resp.Header.Get("X-Moat-Blocked") != "upload-limit" {
+		t.Errorf("response = %d %v, want a 413 upload-limit block", resp.StatusCode, resp.Header)
+	}
+	if n := received.Load(); n != 0 {
+		t.Errorf("upstream received %d bytes of oversized uploads", n)
+	}
+
+	// Uploads under the cap go through.
+	resp, err = ft.client.Post(ft.upstream.URL+"/upload", "application/octet-stream", io.NopCloser(bytes.NewReader(make([]byte, 1<<10))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(body) != "stored" {
+		t.Errorf("response = %d %q, want 200 stored", resp.StatusCode, body)
+	}
+
+	denied := ft.denied()
+	var refused int
+	for _, d := range denied {
+		if strings.HasPrefix(d.DenyReason, uploadDenyReason) {
+			refused++
+		}
+	}
+	if refused != 2 {
+		t.Errorf("denied = %+v, want two upload refusals", denied)
+	}
+}
+
+func TestFront_InjectsFaultsBeforeForwarding(t *testing.T) {
+	var forwarded atomic.Int64
+	rc := NewRunContext("run_test")
+	ft := newFrontTest(t, rc, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwarded.Add(1)
+		io.WriteString(w, "ok")
+	}))
+	host := strings.TrimPrefix(ft.upstream.URL, "https://")
+	rc.Faults = []config.FaultConfig{{Host: host, Status: 429, RetryAfter: 5}}
+
+	resp, err := ft.client.Post(ft.upstream.URL+"/v1/charge", "application/json", strings.NewReader("{}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusTooManyRequests || resp.Header.Get(FaultHeader) != "status" || resp.Header.Get("Retry-After") != "5" {
+		t.Errorf("response = %d %v, want an injected 429", resp.StatusCode, resp.Header)
+	}
+	if !strings.Contains(string(body), "moat_fault_injection") {
+		t.Errorf("body = %s, want the injected error", body)
+	}
+
+	rc.Faults = []config.FaultConfig{{Host: host, Reset: true}}
+	if _, err := ft.client.Post(ft.upstream.URL+"/v1/charge", "application/json", strings.NewReader("{}")); err == nil {
+		t.Error("request with a reset fault succeeded")
+	}
+	if n := forwarded.Load(); n != 0 {
+		t.Errorf("upstream received %d faulted requests, want 0", n)
+	}
+
+	ft.mu.Lock()
+	defer ft.mu.Unlock()
+	if len(ft.logs) != 2 || ft.logs[0].StatusCode != 429 || ft.logs[0].Denied || !errors.Is(ft.logs[1].Err, errFaultInjected) {
+		t.Errorf("logs = %+v, want the injected 429 and reset", ft.logs)
+	}
+}
@@ -0,0 +1,225 @@
+package daemon
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/majorcontext/gatekeeper/proxy"
+	"github.com/majorcontext/moat/internal/log"
+)
+
+// minScopedSecretLen is the shortest credential value checkGrantScopes looks
+// for in requests. Shorter values are too likely to occur by chance.
+const minScopedSecretLen = 16
+
+// scopeGrants fixes, for each grant with credentials or token substitutions
+// in rc, the hosts the grant may be used with: the hosts its provider
+// declares, plus the hosts the run registered the grant for, such as a
+// configured base URL. From then on SetCredentialWithGrant and
+// setTokenSubstitution refuse the grant anywhere else, and checkRequest
+// refuses requests carrying the grant's token to other hosts.
+func (rc *RunContext) scopeGrants() {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	scopes := make(map[string][]string)
+	add := func(grant, host string) {
+		if grant == "" {
+			return
+		}
+		if _, ok := scopes[grant]; !ok {
+			scopes[grant] = append([]string(nil), proxy.GetHostsForGrant(grant)...)
+		}
+		if !slices.Contains(scopes[grant], host) {
+			scopes[grant] = append(scopes[grant], host)
+		}
+	}
+	for host, entries := range rc.Credentials {
+		for _, e := range entries {
+			add(e.Grant, host)
+		}
+	}
+	for host, ts := range rc.TokenSubstitutions {
+		add(ts.Grant, host)
+	}
+	for _, hosts := range scopes {
+		sort.Strings(hosts)
+	}
+	rc.GrantScopes = scopes
+}
+
+// HasGrants reports whether any of rc's credentials or token substitutions
+// belong to a grant, so scopeGrants limits them to the grant's hosts.
+func (rc *RunContext) HasGrants() bool {
+	rc.mu.RLock()
+	defer rc.mu.RUnlock()
+	for _, entries := range rc.Credentials {
+		for _, e := range entries {
+			if e.Grant != "" {
+				return true
+			}
+		}
+	}
+	for _, ts := range rc.TokenSubstitutions {
+		if ts.Grant != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// grantScope returns the hosts grant may be used with, and false if it may
+// be used anywhere: before scopeGrants runs, or for a grant the run was not
+// registered with whose provider declares no hosts. A grant the run was not
+// registered with is limited to its provider's declared hosts. The caller
+// must hold rc.mu.
+func (rc *RunContext) grantScope(grant string) ([]string, bool) {
+	if rc.GrantScopes == nil {
+		return nil, false
+	}
+	if hosts, ok := rc.GrantScopes[grant]; ok {
+		return hosts, true
+	}
+	hosts := proxy.GetHostsForGrant(grant)
+	return hosts, len(hosts) > 0
+}
+
+// inGrantScope reports whether grant may be used with host, a host or
+// host:port. The caller must hold rc.mu.
+func (rc *RunContext) inGrantScope(grant, host string) bool {
+	hosts, scoped := rc.grantScope(grant)
+	if !scoped {
+		return true
+	}
+	h, port := host, 443
+	if hostOnly, portStr, err := net.SplitHostPort(host); err == nil {
+		h = hostOnly
+		port, _ = strconv.Atoi(portStr)
+	}
+	return inScope(hostPatterns(hosts), h, port)
+}
+
+// inScope reports whether host:port matches one of a grant's host patterns.
+// A pattern without a port matches any port, as credential injection falls
+// back from host:port to the bare host.
+func inScope(patterns []proxy.HostPattern, host string, port int) bool {
+	return matchesAny(patterns, host, port) || matchesAny(patterns, host, 443)
+}
+
+// scopedSecret is a credential value and the hosts it may be sent to.
+type scopedSecret struct {
+	value string
+	grant string
+	hosts []proxy.HostPattern
+}
+
+// scopedSecrets returns the token of each scoped grant's credentials and
+// token substitutions, with the grant's hosts. A Basic or Bearer scheme is
+// dropped from header values, since the agent would send the bare token.
+// The caller must hold rc.mu.
+func (rc *RunContext) scopedSecrets() []scopedSecret {
+	var secrets []scopedSecret
+	seen := make(map[string]bool)
+	add := func(grant, value string) {
+		hosts, scoped := rc.grantScope(grant)
+		if !scoped {
+			return
+		}
+		if i := strings.LastIndexByte(value, ' '); i >= 0 {
+			value = value[i+1:]
+		}
+		if len(value) < minScopedSecretLen || seen[grant+"\x00"+value] {
+			return
+		}
+		seen[grant+"\x00"+value] = true
+		secrets = append(secrets, scopedSecret{value: value, grant: grant, hosts: hostPatterns(hosts)})
+	}
+	for _, entries := range rc.Credentials {
+		for _, e := range entries {
+			if e.Grant != "" {
+				add(e.Grant, e.Value)
+			}
+		}
+	}
+	for _, ts := range rc.TokenSubstitutions {
+		if ts.Grant != "" {
+			add(ts.Grant, ts.RealToken)
+		}
+	}
+	return secrets
+}
+
+// hostPatterns parses hosts as proxy host patterns.
+func hostPatterns(hosts []string) []proxy.HostPattern {
+	patterns := make([]proxy.HostPattern, 0, len(hosts))
+	for _, h := range hosts {
+		patterns = append(patterns, proxy.ParseHostPattern(h))
+	}
+	return patterns
+}
+
+// guardsGrantScopes reports whether a request to host:port could carry a
+// scoped grant's token outside its scope.
+func (rc *RunContext) guardsGrantScopes(host string, port int) bool {
+	rc.mu.RLock()
+	defer rc.mu.RUnlock()
+	return slices.ContainsFunc(rc.scopedSecrets(), func(s scopedSecret) bool {
+		return !inScope(s.hosts, host, port)
+	})
+}
+
+// checkGrantScopes refuses req, a request to host:port, if its URL or
+// headers carry a scoped grant's token and host is outside the grant's
+// scope, as when the agent replays a token it has seen to another host.
+// Request bodies are not searched.
+func (rc *RunContext) checkGrantScopes(req *http.Request, host string, port int) *denial {
+	rc.mu.RLock()
+	secrets := rc.scopedSecrets()
+	rc.mu.RUnlock()
+	for _, s := range secrets {
+		if inScope(s.hosts, host, port) || !carries(req, s.value) {
+			continue
+		}
+		log.Warn("blocked request carrying a grant's token outside its scope",
+			"subsystem", "daemon", "run_id", rc.RunID, "grant", s.grant, "host", host, "method", req.Method)
+		return &denial{
+			kind:    "grant-scope",
+			status:  http.StatusForbidden,
+			reason:  "Grant token outside its scope: " + s.grant,
+			message: fmt.Sprintf("the request carries the %s grant's token, which may not be sent to this host", s.grant),
+		}
+	}
+	return nil
+}
+
+// carries reports whether req's URL or any of its header values contains
+// value, looking inside Basic credentials too.
+func carries(req *http.Request, value string) bool {
+	return carriesFunc(req, func(s string) bool { return strings.Contains(s, value) })
+}
+
+// carriesFunc reports whether match holds for req's URL or any of its
+// header values, including decoded Basic credentials.
+func carriesFunc(req *http.Request, match func(string) bool) bool {
+	if match(req.URL.RequestURI()) || match(req.URL.Path) {
+		return true
+	}
+	for _, values := range req.Header {
+		for _, v := range values {
+			if match(v) {
+				return true
+			}
+			if encoded, ok := strings.CutPrefix(v, "Basic "); ok {
+				if decoded, err := base64.StdEncoding.DecodeString(encoded); err == nil && match(string(decoded)) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,104 @@
+package daemon
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/majorcontext/moat/internal/metering"
+)
+
+// blockedHeader marks a response the daemon or the proxy sent in place of
+// the upstream's, naming what blocked the request.
+const blockedHeader = "X-Moat-Blocked"
+
+// A denial is a request one of a run's guards refused before forwarding.
+type denial struct {
+	kind    string // X-Moat-Blocked value
+	status  int    // status sent to the agent
+	reason  string // deny reason in the network and decision logs
+	message string // what the agent is told
+}
+
+// An admission is a request every guard allowed. undo gives back what the
+// guards counted for it, for when the proxy then refuses the request.
+// upload, if set, is the request's body, cut off at its upload cap.
+type admission struct {
+	undos  []func()
+	upload *uploadLimiter
+}
+
+func (a admission) undo() {
+	for _, f := range a.undos {
+		f()
+	}
+}
+
+// guardsHost reports whether any of rc's guards can apply to requests to
+// host:port, or rc transforms or mirrors them. Front hands other requests
+// straight to the proxy.
+func (rc *RunContext) guardsHost(host string, port int) bool {
+	if currentQuotaTracker() != nil && metering.ProviderForHost(host) != "" {
+		return true
+	}
+	rc.mu.RLock()
+	sends, uploads, faults, mirror := rc.SendGuard, rc.UploadGuard, rc.Faults, rc.Mirror
+	guarded := rc.guardsRequests(host, port)
+	rc.mu.RUnlock()
+	if guarded || hasRequestFaults(faults, host, port) {
+		return true
+	}
+	if mirror != nil && mirror.Replays() && mirrorHostMatches(mirror.Hosts, host) {
+		return true
+	}
+	if tfs, _ := rc.requestTransformers(host, port); len(tfs) > 0 {
+		return true
+	}
+	if uploads != nil && uploads.Limit(host, port) > 0 {
+		return true
+	}
+	if rc.guardsGrantScopes(host, port) {
+		return true
+	}
+	return sends != nil && slices.ContainsFunc(sends.Routes, func(r SendRoute) bool {
+		return hostMatchAdapter(r.Host, host, port)
+	})
+}
+
+// checkRequest runs rc's guards on req, a request to host:port, in order,
+// and returns the first denial. These are the run's limits the proxy's
+// network policy does not express; the proxy applies that policy after them.
+// Guards that count requests run last, so a request is counted only once
+// every other guard has allowed it.
+func (rc *RunContext) checkRequest(req *http.Request, host string, port int) (admission, *denial) {
+	var adm admission
+	rc.mu.RLock()
+	sends, uploads := rc.SendGuard, rc.UploadGuard
+	rc.mu.RUnlock()
+
+	if uploads != nil {
+		l, d := uploads.check(req, host, port)
+		if d != nil {
+			return adm, d
+		}
+		adm.upload = l
+	}
+	if d := rc.checkGrantScopes(req, host, port); d != nil {
+		return adm, d
+	}
+	if d := rc.checkRequestGuards(req, host, port); d != nil {
+		return adm, d
+	}
+	if d := checkQuota(currentQuotaTracker(), req.Method, host); d != nil {
+		return adm, d
+	}
+	if sends != nil {
+		undo, d := sends.check(host, port, req.Method, req.URL.Path)
+		if d != nil {
+			return adm, d
+		}
+		if undo != nil {
+			adm.undos = append(adm.undos, undo)
+		}
+	}
+	return adm, nil
+}
@@ -111,7 +111,7 @@ func EnsureRunning(dir string, proxyPort int) (*Client, error) {
 		// Verify the daemon is actually responsive (process may be alive
 		// but socket deleted during partial shutdown).
 		healthCtx, healthCancel := context.WithTimeout(context.Background(), 3*time.Second)
-		_, healthErr := client.Health(healthCtx)
+		health, healthErr := client.Health(healthCtx)
 		healthCancel()
 		if healthErr == nil {
 			// A healthy daemon exists. Decide whether to adopt the caller's
@@ -131,9 +131,12 @@ func EnsureRunning(dir string, proxyPort int) (*Client, error) {
 			// case; it does not prevent two-known-version flip-flop. We accept
 			// this rather than tracking a "preferred" version, since mixed
 			// production installs sharing one daemon dir are not expected.
-			if shouldAdoptVersion(lock.Commit, BuildCommit) {
+			//
+			// A daemon serving another major API version is replaced the same
+			// way, whatever its commit: the caller could not talk to it.
+			if shouldAdoptVersion(lock.Commit, BuildCommit) || !health.ServesAPIVersion(APIVersion) {
 				log.Warn("version adoption: proxy daemon commit differs from caller; restarting daemon to adopt caller version",
-					"daemon_commit", lock.Commit, "caller_commit", BuildCommit)
+					"daemon_commit", lock.Commit, "caller_commit", BuildCommit, "daemon_api_version", health.APIVersion)
 				// Restart under the spawn lock we already hold. restartLocked
 				// requests shutdown of the existing daemon, waits for the
 				// process to exit, then spawns a fresh one from this binary.
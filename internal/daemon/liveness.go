@@ -19,6 +19,11 @@ type ContainerChecker interface {
 // failure will no longer cause immediate cleanup.
 const defaultMaxFailures = 3
 
+// wakeGrace is how long after the host wakes from sleep that failed checks
+// are not counted. Docker Desktop and Apple containers resume their VM after
+// the host, and until then every check fails.
+const wakeGrace = 2 * time.Minute
+
 // LivenessChecker periodically checks container liveness and cleans up dead runs.
 type LivenessChecker struct {
 	registry    *Registry
@@ -29,6 +34,8 @@ type LivenessChecker struct {
 	onEmpty     func()         // called when registry becomes empty after cleanup
 	failCounts  map[string]int // keyed by containerID (not token or runID)
 	maxFailures int
+	wakeCh      chan struct{}
+	graceUntil  time.Time // failed checks before this are not counted
 }
 
 // NewLivenessChecker creates a new liveness checker with 30-second default interval.
@@ -39,6 +46,7 @@ func NewLivenessChecker(registry *Registry, checker ContainerChecker) *LivenessC
 		interval:    30 * time.Second,
 		failCounts:  make(map[string]int),
 		maxFailures: defaultMaxFailures,
+		wakeCh:      make(chan struct{}, 1),
 	}
 }
 
@@ -85,6 +93,13 @@ func (lc *LivenessChecker) CheckOnce(ctx context.Context) {
 				"container_id", containerID)
 			lc.removeRun(rc)
 
+		case time.Now().Before(lc.graceUntil):
+			// The runtime is still resuming after sleep — don't count it.
+			log.Debug("container liveness check failed after wake",
+				"run_id", rc.RunID,
+				"container_id", containerID,
+				"error", checkErr)
+
 		default:
 			// Check failed (transient error) — increment failure count.
 			lc.failCounts[containerID]++
@@ -106,6 +121,22 @@ func (lc *LivenessChecker) CheckOnce(ctx context.Context) {
 	}
 }
 
+// Wake tells the checker the host resumed from sleep. Failure counts from
+// before the sleep are dropped and failed checks are not counted for a
+// grace period.
+func (lc *LivenessChecker) Wake() {
+	select {
+	case lc.wakeCh <- struct{}{}:
+	default:
+	}
+}
+
+// startGrace drops failure counts and starts the wake grace period.
+func (lc *LivenessChecker) startGrace() {
+	clear(lc.failCounts)
+	lc.graceUntil = time.Now().Add(wakeGrace)
+}
+
 // removeRun cancels refresh, unregisters the run, and fires callbacks.
 func (lc *LivenessChecker) removeRun(rc *RunContext) {
 	rc.Close()
@@ -139,6 +170,8 @@ func (lc *LivenessChecker) Run(ctx context.Context) {
 			return
 		case <-ticker.C:
 			lc.CheckOnce(ctx)
+		case <-lc.wakeCh:
+			lc.startGrace()
 		}
 	}
 }
@@ -0,0 +1,209 @@
+package daemon
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"net/http"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/majorcontext/moat/internal/log"
+	"github.com/majorcontext/moat/internal/storage"
+)
+
+// defaultLogLines is how many past lines a log stream starts with when the
+// request does not say.
+const defaultLogLines = 100
+
+// errContainerNotRunning is returned by containerLogs when the run's
+// container is not running, so the stream falls back to logs.jsonl.
+var errContainerNotRunning = errors.New("container not running")
+
+// containerLogs streams the last lines of a running container's output to
+// emit, then new output until ctx is done or the container exits if follow
+// is set. lines <= 0 starts from the beginning. It is a variable so tests
+// can replace the runtime CLI.
+var containerLogs = commandContainerLogs
+
+// handleStreamLogs streams a registered run's console output as
+// newline-delimited JSON storage.LogEntry values. While the container runs,
+// output comes from the container runtime, so it works after the CLI that
+// started the run has exited; otherwise it comes from the run's logs.jsonl.
+// Query parameters: run_id (required), lines (default 100, 0 for all), and
+// follow=1 to keep streaming until the container exits or the client
+// disconnects.
+func (s *Server) handleStreamLogs(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	runID := q.Get("run_id")
+	if runID == "" {
+		http.Error(w, `{"error":"missing run_id"}`, http.StatusBadRequest)
+		return
+	}
+	lines := defaultLogLines
+	if v := q.Get("lines"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			http.Error(w, `{"error":"invalid lines"}`, http.StatusBadRequest)
+			return
+		}
+		lines = n
+	}
+	follow := q.Get("follow") == "1"
+	rc, ok := s.registry.LookupRun(runID)
+	if !ok {
+		http.Error(w, `{"error":"run not found"}`, http.StatusNotFound)
+		return
+	}
+
+	// The stream is long-lived; lift the server's write timeout for it.
+	ctrl := http.NewResponseController(w)
+	_ = ctrl.SetWriteDeadline(time.Time{})
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	_ = ctrl.Flush()
+
+	enc := json.NewEncoder(w)
+	emit := func(e storage.LogEntry) error {
+		if err := enc.Encode(e); err != nil {
+			return err
+		}
+		return ctrl.Flush()
+	}
+
+	err := errContainerNotRunning
+	if id := rc.GetContainerID(); id != "" {
+		err = containerLogs(r.Context(), id, follow, lines, emit)
+	}
+	if !errors.Is(err, errContainerNotRunning) {
+		if err != nil && r.Context().Err() == nil {
+			log.Debug("log stream ended", "run_id", runID, "error", err)
+		}
+		return
+	}
+	s.streamStoredLogs(runID, lines, emit)
+}
+
+// streamStoredLogs emits the last lines entries of a run's logs.jsonl.
+func (s *Server) streamStoredLogs(runID string, lines int, emit func(storage.LogEntry) error) {
+	if s.runsDir == "" {
+		return
+	}
+	store, err := storage.NewRunStore(s.runsDir, runID)
+	if err != nil {
+		log.Debug("opening run storage for logs", "run_id", runID, "error", err)
+		return
+	}
+	entries, err := store.ReadLogs(0, math.MaxInt)
+	if err != nil {
+		log.Debug("reading logs", "run_id", runID, "error", err)
+		return
+	}
+	if lines > 0 && len(entries) > lines {
+		entries = entries[len(entries)-lines:]
+	}
+	for _, e := range entries {
+		if emit(e) != nil {
+			return
+		}
+	}
+}
+
+// commandContainerLogs implements containerLogs with the runtime CLI that
+// owns the container.
+func commandContainerLogs(ctx context.Context, id string, follow bool, lines int, emit func(storage.LogEntry) error) error {
+	checker := NewCommandContainerChecker()
+	if alive, _ := checker.IsContainerRunning(ctx, id); !alive {
+		return errContainerNotRunning
+	}
+	runtime := checker.runtimes[id]
+
+	bin, args := containerLogsArgs(runtime, id, follow, lines)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, bin, args...)
+	pr, pw := io.Pipe()
+	cmd.Stdout = pw
+	cmd.Stderr = pw
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	go func() {
+		pw.CloseWithError(cmd.Wait())
+	}()
+
+	// Apple containers cannot tail, so without follow keep only the last
+	// lines here.
+	var tail []storage.LogEntry
+	keepTail := runtime == "apple" && !follow && lines > 0
+
+	scanner := bufio.NewScanner(pr)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		entry := parseContainerLogLine(runtime, scanner.Text(), time.Now().UTC())
+		if keepTail {
+			tail = append(tail, entry)
+			if len(tail) > lines {
+				tail = tail[1:]
+			}
+			continue
+		}
+		if err := emit(entry); err != nil {
+			return err
+		}
+	}
+	for _, entry := range tail {
+		if err := emit(entry); err != nil {
+			return err
+		}
+	}
+	err := scanner.Err()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && ctx.Err() != nil {
+		return nil
+	}
+	return err
+}
+
+// containerLogsArgs returns the runtime CLI command that prints a
+// container's logs. Docker and Podman prefix each line with its timestamp.
+func containerLogsArgs(runtime, id string, follow bool, lines int) (string, []string) {
+	if runtime == "apple" {
+		args := []string{"logs"}
+		if follow {
+			args = append(args, "--follow")
+		}
+		return "container", append(args, id)
+	}
+	args := []string{"logs", "--timestamps"}
+	if follow {
+		args = append(args, "--follow")
+	}
+	if lines > 0 {
+		args = append(args, "--tail", strconv.Itoa(lines))
+	}
+	if runtime == "kubernetes" {
+		namespace, pod, _ := strings.Cut(id, "/")
+		return "kubectl", append(args, "--namespace", namespace, "--container", "main", pod)
+	}
+	return runtime, append(args, id)
+}
+
+// parseContainerLogLine converts a line of runtime CLI output to a log
+// entry, taking the timestamp from the Docker and Podman prefix when present
+// and using now otherwise.
+func parseContainerLogLine(runtime, line string, now time.Time) storage.LogEntry {
+	if runtime != "apple" {
+		if ts, rest, ok := strings.Cut(line, " "); ok {
+			if t, err := time.Parse(time.RFC3339Nano, ts); err == nil {
+				return storage.LogEntry{Timestamp: t.UTC(), Line: rest}
+			}
+		}
+	}
+	return storage.LogEntry{Timestamp: now, Line: line}
+}
@@ -0,0 +1,127 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/majorcontext/moat/internal/storage"
+)
+
+func TestClient_StreamLogs(t *testing.T) {
+	dir := testSockDir(t)
+	sockPath := filepath.Join(dir, "d.sock")
+	runsDir := t.TempDir()
+	srv := NewServer(sockPath, 9100)
+	srv.SetRunsDir(runsDir)
+	if err := srv.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Stop(context.Background())
+
+	old := containerLogs
+	defer func() { containerLogs = old }()
+
+	client := NewClient(sockPath)
+	if err := client.StreamLogs(context.Background(), "run_missing", false, 10, func(storage.LogEntry) {}); !errors.Is(err, ErrRunNotFound) {
+		t.Fatalf("StreamLogs(unregistered) = %v, want ErrRunNotFound", err)
+	}
+
+	resp, err := client.RegisterRun(context.Background(), RegisterRequest{RunID: "run_logs"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.UpdateRun(context.Background(), resp.AuthToken, UpdateRunRequest{ContainerID: "ctr123"}); err != nil {
+		t.Fatal(err)
+	}
+
+	collect := func(lines int) []string {
+		t.Helper()
+		var got []string
+		err := client.StreamLogs(context.Background(), "run_logs", false, lines, func(e storage.LogEntry) {
+			got = append(got, e.Line)
+		})
+		if err != nil {
+			t.Fatalf("StreamLogs: %v", err)
+		}
+		return got
+	}
+
+	t.Run("running container", func(t *testing.T) {
+		containerLogs = func(_ context.Context, id string, follow bool, lines int, emit func(storage.LogEntry) error) error {
+			if id != "ctr123" || follow || lines != 2 {
+				return fmt.Errorf("containerLogs(%q, %v, %d)", id, follow, lines)
+			}
+			_ = emit(storage.LogEntry{Timestamp: time.Now(), Line: "live 1"})
+			return emit(storage.LogEntry{Timestamp: time.Now(), Line: "live 2"})
+		}
+		if got, want := collect(2), []string{"live 1", "live 2"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("lines = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("stopped container reads logs.jsonl", func(t *testing.T) {
+		containerLogs = func(context.Context, string, bool, int, func(storage.LogEntry) error) error {
+			return errContainerNotRunning
+		}
+		store, err := storage.NewRunStore(runsDir, "run_logs")
+		if err != nil {
+			t.Fatal(err)
+		}
+		w, err := store.LogWriter()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte("one\ntwo\nthree\n")); err != nil {
+			t.Fatal(err)
+		}
+		w.Close()
+
+		if got, want := collect(2), []string{"two", "three"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("lines = %v, want %v", got, want)
+		}
+		if got := collect(0); len(got) != 3 {
+			t.Errorf("lines=0 returned %v, want all 3 lines", got)
+		}
+	})
+}
+
+func TestParseContainerLogLine(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		name, runtime, line string
+		want                storage.LogEntry
+	}{
+		{"docker timestamp", "docker", "2026-01-02T10:00:00.123456789Z hello world",
+			storage.LogEntry{Timestamp: time.Date(2026, 1, 2, 10, 0, 0, 123456789, time.UTC), Line: "hello world"}},
+		{"no timestamp", "podman", "hello world", storage.LogEntry{Timestamp: now, Line: "hello world"}},
+		{"apple keeps line", "apple", "2026-01-02T10:00:00Z hello", storage.LogEntry{Timestamp: now, Line: "2026-01-02T10:00:00Z hello"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseContainerLogLine(tt.runtime, tt.line, now)
+			if !got.Timestamp.Equal(tt.want.Timestamp) || got.Line != tt.want.Line {
+				t.Errorf("parseContainerLogLine() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestContainerLogsArgs(t *testing.T) {
+	bin, args := containerLogsArgs("docker", "abc", true, 50)
+	if want := []string{"logs", "--timestamps", "--follow", "--tail", "50", "abc"}; bin != "docker" || !reflect.DeepEqual(args, want) {
+		t.Errorf("docker: %s %v, want docker %v", bin, args, want)
+	}
+	bin, args = containerLogsArgs("apple", "abc", false, 50)
+	if want := []string{"logs", "abc"}; bin != "container" || !reflect.DeepEqual(args, want) {
+		t.Errorf("apple: %s %v, want container %v", bin, args, want)
+	}
+	bin, args = containerLogsArgs("kubernetes", "agents/run-abc", true, 0)
+	if want := []string{"logs", "--timestamps", "--follow", "--namespace", "agents", "--container", "main", "run-abc"}; bin != "kubectl" || !reflect.DeepEqual(args, want) {
+		t.Errorf("kubernetes: %s %v, want kubectl %v", bin, args, want)
+	}
+}
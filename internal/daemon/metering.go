@@ -0,0 +1,115 @@
+package daemon
+
+import (
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/majorcontext/gatekeeper/proxy"
+	"github.com/majorcontext/moat/internal/metering"
+	"github.com/majorcontext/moat/internal/storage"
+)
+
+var (
+	usageMu       sync.RWMutex
+	usageRecorder func(runID string, u storage.Usage)
+)
+
+// SetUsageRecorder sets where metered LLM usage is sent. The daemon command
+// writes it to the run's usage.jsonl. With no recorder, responses are not
+// metered.
+func SetUsageRecorder(f func(runID string, u storage.Usage)) {
+	usageMu.Lock()
+	defer usageMu.Unlock()
+	usageRecorder = f
+}
+
+func recordUsage(runID string, u storage.Usage) {
+	usageMu.RLock()
+	f := usageRecorder
+	usageMu.RUnlock()
+	if f != nil {
+		f(runID, u)
+	}
+	if t := currentQuotaTracker(); t != nil {
+		t.Add(u)
+	}
+}
+
+// applyMetering wraps the response transformers of each metered LLM host so
+// the body the agent reads is fed through a metering.Meter after the other
+// transformers have run. Responses are metered only when usage is recorded
+// or quotas are enforced.
+func applyMetering(runID string, transformers map[string][]proxy.ResponseTransformer) {
+	usageMu.RLock()
+	enabled := usageRecorder != nil
+	usageMu.RUnlock()
+	if !enabled && currentQuotaTracker() == nil {
+		return
+	}
+	for _, host := range metering.Hosts() {
+		transformers[host] = []proxy.ResponseTransformer{newMeteringTransformer(runID, host, transformers[host])}
+	}
+}
+
+// newMeteringTransformer returns a response transformer that runs next and
+// then meters the successful response of a POST. The body is wrapped in
+// place, so the transformer reports a change only if next did.
+func newMeteringTransformer(runID, host string, next []proxy.ResponseTransformer) proxy.ResponseTransformer {
+	provider := metering.ProviderForHost(host)
+	return func(reqI, respI any) (any, bool) {
+		out, changed := runTransformers(next, reqI, respI)
+		resp, ok := out.(*http.Response)
+		if !ok || resp.Body == nil || resp.StatusCode != http.StatusOK {
+			return out, changed
+		}
+		req, _ := reqI.(*http.Request)
+		if req == nil || req.Method != http.MethodPost {
+			return out, changed
+		}
+		m := metering.NewMeter(provider, resp.Header.Get("Content-Type"), resp.Header.Get("Content-Encoding"))
+		if m == nil {
+			return out, changed
+		}
+		path := req.URL.Path
+		resp.Body = &meteredBody{
+			r: io.TeeReader(resp.Body, m),
+			c: resp.Body,
+			done: func() {
+				u, ok := m.Usage()
+				if !ok {
+					return
+				}
+				u.Timestamp = time.Now().UTC()
+				u.Host = host
+				u.Path = path
+				recordUsage(runID, u)
+			},
+		}
+		return resp, changed
+	}
+}
+
+// meteredBody calls done once, when the body is read to the end or closed,
+// whichever comes first. A client that hangs up mid-stream is still charged
+// for what the provider reported so far.
+type meteredBody struct {
+	r    io.Reader
+	c    io.Closer
+	once sync.Once
+	done func()
+}
+
+func (b *meteredBody) Read(p []byte) (int, error) {
+	n, err := b.r.Read(p)
+	if err == io.EOF {
+		b.once.Do(b.done)
+	}
+	return n, err
+}
+
+func (b *meteredBody) Close() error {
+	b.once.Do(b.done)
+	return b.c.Close()
+}
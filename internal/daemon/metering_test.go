@@ -0,0 +1,87 @@
+package daemon
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/majorcontext/moat/internal/storage"
+)
+
+func TestMeteringRecordsUsage(t *testing.T) {
+	var got []storage.Usage
+	SetUsageRecorder(func(runID string, u storage.Usage) {
+		if runID != "run_meter" {
+			t.Errorf("runID = %q, want run_meter", runID)
+		}
+		got = append(got, u)
+	})
+	t.Cleanup(func() { SetUsageRecorder(nil) })
+
+	rc := NewRunContext("run_meter")
+	tfs := rc.ToProxyContextData().ResponseTransformers["api.anthropic.com"]
+	if len(tfs) != 1 {
+		t.Fatalf("got %d transformers, want 1", len(tfs))
+	}
+
+	stream := "event: message_start\n" +
+		`data: {"type":"message_start","message":{"model":"claude-sonnet-4-5","usage":{"input_tokens":10,"cache_read_input_tokens":200,"output_tokens":1}}}` + "\n\n" +
+		"event: message_delta\n" +
+		`data: {"type":"message_delta","usage":{"output_tokens":42}}` + "\n\n"
+	req := &http.Request{Method: http.MethodPost, URL: &url.URL{Path: "/v1/messages"}}
+	resp := &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": {"text/event-stream; charset=utf-8"}},
+		Body:       io.NopCloser(strings.NewReader(stream)),
+	}
+	out, changed := tfs[0](req, resp)
+	if changed {
+		t.Error("metering reported a change to the response")
+	}
+	body := out.(*http.Response).Body
+	data, _ := io.ReadAll(body)
+	body.Close()
+	if string(data) != stream {
+		t.Errorf("body altered:\n%s", data)
+	}
+
+	if len(got) != 1 {
+		t.Fatalf("recorded %d usage records, want 1", len(got))
+	}
+	u := got[0]
+	if u.Host != "api.anthropic.com" || u.Path != "/v1/messages" || u.Model != "claude-sonnet-4-5" {
+		t.Errorf("usage = %+v", u)
+	}
+	if u.InputTokens != 10 || u.OutputTokens != 42 || u.CacheReadTokens != 200 {
+		t.Errorf("tokens = %d in / %d out / %d cached, want 10/42/200", u.InputTokens, u.OutputTokens, u.CacheReadTokens)
+	}
+}
+
+func TestMeteringSkipsFailedResponses(t *testing.T) {
+	recorded := 0
+	SetUsageRecorder(func(string, storage.Usage) { recorded++ })
+	t.Cleanup(func() { SetUsageRecorder(nil) })
+
+	rc := NewRunContext("run_meter")
+	tfs := rc.ToProxyContextData().ResponseTransformers["api.openai.com"]
+
+	req := &http.Request{Method: http.MethodPost, URL: &url.URL{Path: "/v1/chat/completions"}}
+	resp := faultResponse(`{"model":"gpt-4o","usage":{"prompt_tokens":5,"completion_tokens":5}}`)
+	resp.StatusCode = http.StatusTooManyRequests
+	out, _ := tfs[0](req, resp)
+	body := out.(*http.Response).Body
+	_, _ = io.ReadAll(body)
+	body.Close()
+	if recorded != 0 {
+		t.Errorf("recorded %d usage records for a 429, want 0", recorded)
+	}
+}
+
+func TestMeteringDisabledWithoutRecorder(t *testing.T) {
+	rc := NewRunContext("run_meter")
+	if tfs := rc.ToProxyContextData().ResponseTransformers["api.anthropic.com"]; len(tfs) != 0 {
+		t.Errorf("got %d transformers without a usage recorder, want 0", len(tfs))
+	}
+}
@@ -0,0 +1,314 @@
+package daemon
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/majorcontext/gatekeeper/proxy"
+	"github.com/majorcontext/moat/internal/config"
+	"github.com/majorcontext/moat/internal/log"
+)
+
+// DefaultMirrorHosts are the hosts mirrored when network.mirror.hosts is empty.
+var DefaultMirrorHosts = []string{
+	"api.anthropic.com",
+	"api.openai.com",
+	"generativelanguage.googleapis.com",
+	"cloudcode-pa.googleapis.com",
+}
+
+const (
+	// mirrorMaxInFlight bounds concurrent mirror POSTs across all runs.
+	// Records beyond it are dropped rather than queued so a slow mirror
+	// endpoint can never build up memory or delay the proxy.
+	mirrorMaxInFlight = 16
+	// mirrorTimeout bounds each mirror POST.
+	mirrorTimeout = 10 * time.Second
+	// mirrorMaxBody bounds the request body Front keeps to replay a
+	// request. Requests with larger bodies are not replayed.
+	mirrorMaxBody = 8 << 20
+)
+
+// MirrorRecord is the JSON body POSTed to a mirror endpoint for each mirrored
+// request in the record format. Bodies are the proxy's captured copies, limited to
+// proxy.MaxBodySize; Truncated marks records whose request or response body
+// was cut off. Injected credential headers are never included.
+type MirrorRecord struct {
+	RunID          string            `json:"run_id"`
+	RequestID      string            `json:"request_id,omitempty"`
+	Timestamp      time.Time         `json:"ts"`
+	Method         string            `json:"method"`
+	URL            string            `json:"url"`
+	StatusCode     int               `json:"status_code"`
+	DurationMS     int64             `json:"duration_ms"`
+	RequestHeaders map[string]string `json:"req_headers,omitempty"`
+	RequestBody    string            `json:"req_body,omitempty"`
+	ResponseBody   string            `json:"resp_body,omitempty"`
+	Truncated      bool              `json:"truncated,omitempty"`
+}
+
+// Mirror sends fire-and-forget copies of proxied requests to each run's
+// configured mirror endpoint. It never blocks the caller. By default a
+// request is replayed against the mirror URL (see Front); with the record
+// format, a MirrorRecord of it is POSTed there (see Observe).
+type Mirror struct {
+	client *http.Client
+	sem    chan struct{}
+}
+
+// NewMirror creates a Mirror.
+func NewMirror() *Mirror {
+	return &Mirror{
+		client: &http.Client{Timeout: mirrorTimeout},
+		sem:    make(chan struct{}, mirrorMaxInFlight),
+	}
+}
+
+// Observe POSTs a record of data to cfg.URL if cfg uses the record format,
+// the request reached its upstream, and its host is mirrored. It returns
+// whether a copy was dispatched.
+func (m *Mirror) Observe(cfg *config.MirrorConfig, data proxy.RequestLogData) bool {
+	host := data.Host
+	if host == "" {
+		if u, err := url.Parse(data.URL); err == nil {
+			host = u.Host
+		}
+	}
+	if cfg == nil || cfg.Replays() || data.Denied || !mirrorHostMatches(cfg.Hosts, host) {
+		return false
+	}
+	select {
+	case m.sem <- struct{}{}:
+	default:
+		log.Debug("mirror: dropping request, too many in flight", "run_id", data.RunID, "url", data.URL)
+		return false
+	}
+
+	rec := MirrorRecord{
+		RunID:          data.RunID,
+		RequestID:      data.RequestID,
+		Timestamp:      time.Now().UTC(),
+		Method:         data.Method,
+		URL:            data.URL,
+		StatusCode:     data.StatusCode,
+		DurationMS:     data.Duration.Milliseconds(),
+		RequestHeaders: proxy.FilterHeaders(data.RequestHeaders, data.InjectedHeaders),
+		RequestBody:    string(data.RequestBody),
+		ResponseBody:   string(data.ResponseBody),
+		Truncated:      len(data.RequestBody) >= proxy.MaxBodySize || len(data.ResponseBody) >= proxy.MaxBodySize,
+	}
+	go func() {
+		defer func() { <-m.sem }()
+		m.post(cfg.URL, rec)
+	}()
+	return true
+}
+
+func (m *Mirror) post(endpoint string, rec MirrorRecord) {
+	body, err := json.Marshal(rec)
+	if err != nil {
+		log.Debug("mirror: marshal failed", "error", err)
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), mirrorTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		log.Debug("mirror: bad request", "url", endpoint, "error", err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "moat-mirror")
+	resp, err := m.client.Do(req)
+	if err != nil {
+		log.Debug("mirror: post failed", "url", endpoint, "run_id", rec.RunID, "error", err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		log.Debug("mirror: endpoint returned error", "url", endpoint, "run_id", rec.RunID, "status", resp.StatusCode)
+	}
+}
+
+// replay sends the request r captured to its mirror URL, if its body was
+// read in full and fit under mirrorMaxBody. It returns whether a copy was
+// dispatched.
+func (m *Mirror) replay(r *mirrorReplay) bool {
+	body, ok := r.captured()
+	if !ok {
+		log.Debug("mirror: not replaying request, body incomplete or too large", "run_id", r.runID, "path", r.path)
+		return false
+	}
+	select {
+	case m.sem <- struct{}{}:
+	default:
+		log.Debug("mirror: dropping request, too many in flight", "run_id", r.runID, "path", r.path)
+		return false
+	}
+	go func() {
+		defer func() { <-m.sem }()
+		m.send(r, body)
+	}()
+	return true
+}
+
+// send sends r's method, path, query, headers, and body to its mirror URL,
+// with r's path joined onto the URL's.
+func (m *Mirror) send(r *mirrorReplay, body []byte) {
+	u, err := url.Parse(r.url)
+	if err != nil {
+		log.Debug("mirror: bad URL", "url", r.url, "error", err)
+		return
+	}
+	u = u.JoinPath(r.path)
+	u.RawQuery = r.query
+	ctx, cancel := context.WithTimeout(context.Background(), mirrorTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, r.method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		log.Debug("mirror: bad request", "url", u.String(), "error", err)
+		return
+	}
+	req.Header = r.header
+	req.Header.Set("X-Moat-Run-Id", r.runID)
+	resp, err := m.client.Do(req)
+	if err != nil {
+		log.Debug("mirror: replay failed", "url", u.String(), "run_id", r.runID, "error", err)
+		return
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<20))
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		log.Debug("mirror: endpoint returned error", "url", u.String(), "run_id", r.runID, "status", resp.StatusCode)
+	}
+}
+
+// mirrorReplay is a request Front is forwarding, captured to replay
+// against the run's mirror URL: its body is kept as the proxy reads it.
+type mirrorReplay struct {
+	url    string // mirror URL
+	runID  string
+	method string
+	path   string
+	query  string
+	header http.Header
+
+	mu   sync.Mutex
+	body bytes.Buffer
+	over bool // body passed mirrorMaxBody
+	done bool // body read in full
+}
+
+// mirrorHopHeaders are dropped from replayed requests, with the run's
+// credential headers: they belong to the connection to moat's proxy, not
+// to the request.
+var mirrorHopHeaders = []string{
+	"Connection", "Keep-Alive", "Proxy-Authenticate", "Proxy-Authorization", "Proxy-Connection",
+	"Te", "Trailer", "Transfer-Encoding", "Upgrade",
+}
+
+// newMirrorReplay captures req, a request to host:port, to replay against
+// rc's mirror URL, wrapping its body to keep a copy. It returns nil if rc
+// does not mirror the request by replay. The request's headers are copied
+// without hop-by-hop headers, moat's proxy credentials, and the headers
+// the proxy injects the run's credentials into.
+func newMirrorReplay(rc *RunContext, req *http.Request, host string, port int) *mirrorReplay {
+	rc.mu.RLock()
+	cfg := rc.Mirror
+	rc.mu.RUnlock()
+	if cfg == nil || !cfg.Replays() || !mirrorHostMatches(cfg.Hosts, host) || req.Header.Get("Upgrade") != "" {
+		return nil
+	}
+	header := req.Header.Clone()
+	for _, h := range mirrorHopHeaders {
+		header.Del(h)
+	}
+	for _, f := range req.Header.Values("Connection") {
+		for _, h := range strings.Split(f, ",") {
+			header.Del(strings.TrimSpace(h))
+		}
+	}
+	hostPort := net.JoinHostPort(host, strconv.Itoa(port))
+	for _, c := range rc.GetCredentials(hostPort) {
+		header.Del(c.Name)
+	}
+	if ts, ok := rc.GetTokenSubstitution(hostPort); ok && ts.Placeholder != "" {
+		for name, values := range header {
+			if slices.ContainsFunc(values, func(v string) bool { return strings.Contains(v, ts.Placeholder) }) {
+				header.Del(name)
+			}
+		}
+	}
+	r := &mirrorReplay{
+		url:    cfg.URL,
+		runID:  rc.RunID,
+		method: req.Method,
+		path:   req.URL.EscapedPath(),
+		query:  req.URL.RawQuery,
+		header: header,
+	}
+	if req.Body == nil || req.Body == http.NoBody {
+		r.done = true
+	} else {
+		req.Body = &mirrorBody{ReadCloser: req.Body, r: r}
+	}
+	return r
+}
+
+// captured returns the request's body, and false if it was not read in
+// full or was too large to keep.
+func (r *mirrorReplay) captured() ([]byte, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.done || r.over {
+		return nil, false
+	}
+	return bytes.Clone(r.body.Bytes()), true
+}
+
+// mirrorBody is a request body that copies what is read from it into a
+// mirrorReplay.
+type mirrorBody struct {
+	io.ReadCloser
+	r *mirrorReplay
+}
+
+func (b *mirrorBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.r.mu.Lock()
+	defer b.r.mu.Unlock()
+	if !b.r.over {
+		if b.r.body.Len()+n > mirrorMaxBody {
+			b.r.over = true
+			b.r.body.Reset()
+		} else {
+			b.r.body.Write(p[:n])
+		}
+	}
+	if err == io.EOF {
+		b.r.done = true
+	}
+	return n, err
+}
+
+// mirrorHostMatches reports whether host (optionally with a port) is one of
+// hosts, or of DefaultMirrorHosts when hosts is empty.
+func mirrorHostMatches(hosts []string, host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if len(hosts) == 0 {
+		hosts = DefaultMirrorHosts
+	}
+	return slices.ContainsFunc(hosts, func(h string) bool { return strings.EqualFold(h, host) })
+}
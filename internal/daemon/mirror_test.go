@@ -0,0 +1,99 @@
+package daemon
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/majorcontext/gatekeeper/proxy"
+	"github.com/majorcontext/moat/internal/config"
+)
+
+func TestMirrorObserve(t *testing.T) {
+	got := make(chan MirrorRecord, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var rec MirrorRecord
+		if err := json.NewDecoder(r.Body).Decode(&rec); err != nil {
+			t.Errorf("decoding mirror record: %v", err)
+		}
+		got <- rec
+	}))
+	defer srv.Close()
+
+	m := NewMirror()
+	cfg := &config.MirrorConfig{URL: srv.URL, Format: config.MirrorFormatRecord}
+	data := proxy.RequestLogData{
+		RunID:      "run_abc",
+		Method:     "POST",
+		URL:        "https://api.anthropic.com/v1/messages",
+		Host:       "api.anthropic.com",
+		StatusCode: 200,
+		RequestHeaders: http.Header{
+			"X-Api-Key":    []string{"sk-ant-secret"},
+			"Content-Type": []string{"application/json"},
+		},
+		InjectedHeaders: map[string]bool{"x-api-key": true},
+		RequestBody:     []byte(`{"model":"x"}`),
+		ResponseBody:    []byte(`{"id":"msg_1"}`),
+	}
+	if !m.Observe(cfg, data) {
+		t.Fatal("Observe() = false, want request mirrored")
+	}
+
+	select {
+	case rec := <-got:
+		if rec.RunID != "run_abc" || rec.URL != data.URL || rec.RequestBody != `{"model":"x"}` || rec.ResponseBody != `{"id":"msg_1"}` {
+			t.Errorf("record = %+v", rec)
+		}
+		for k, v := range rec.RequestHeaders {
+			if v == "sk-ant-secret" {
+				t.Errorf("injected credential leaked to mirror in header %s", k)
+			}
+		}
+		if rec.Truncated {
+			t.Error("small bodies should not be marked truncated")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("mirror endpoint never received the record")
+	}
+}
+
+func TestMirrorObserveSkips(t *testing.T) {
+	m := NewMirror()
+	// The endpoint is never contacted for skipped requests.
+	cfg := &config.MirrorConfig{URL: "http://127.0.0.1:1/unused", Format: config.MirrorFormatRecord}
+
+	if m.Observe(nil, proxy.RequestLogData{Host: "api.openai.com"}) {
+		t.Error("nil config should not mirror")
+	}
+	if m.Observe(cfg, proxy.RequestLogData{Host: "github.com", URL: "https://github.com/"}) {
+		t.Error("non-LLM host should not be mirrored by default")
+	}
+	if m.Observe(cfg, proxy.RequestLogData{Host: "api.openai.com", Denied: true}) {
+		t.Error("denied request never reached upstream and should not be mirrored")
+	}
+	if m.Observe(&config.MirrorConfig{URL: cfg.URL}, proxy.RequestLogData{Host: "api.openai.com"}) {
+		t.Error("requests mirrored by replay should not be recorded")
+	}
+}
+
+func TestMirrorHostMatches(t *testing.T) {
+	tests := []struct {
+		hosts []string
+		host  string
+		want  bool
+	}{
+		{nil, "api.anthropic.com", true},
+		{nil, "api.openai.com:443", true},
+		{nil, "example.com", false},
+		{[]string{"api.openai.com"}, "api.anthropic.com", false},
+		{[]string{"llm.internal"}, "LLM.internal", true},
+	}
+	for _, tt := range tests {
+		if got := mirrorHostMatches(tt.hosts, tt.host); got != tt.want {
+			t.Errorf("mirrorHostMatches(%v, %q) = %v, want %v", tt.hosts, tt.host, got, tt.want)
+		}
+	}
+}
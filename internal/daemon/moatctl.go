@@ -0,0 +1,667 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/majorcontext/moat/internal/audit"
+	"github.com/majorcontext/moat/internal/config"
+	"github.com/majorcontext/moat/internal/id"
+	"github.com/majorcontext/moat/internal/log"
+	"github.com/majorcontext/moat/internal/netrules"
+	"github.com/majorcontext/moat/internal/notify"
+	"github.com/majorcontext/moat/internal/snapshot"
+	"github.com/majorcontext/moat/internal/storage"
+)
+
+// CtlEndpointPath is where the proxy serves moatctl, the helper mounted into
+// every run. Like the Azure and GCP endpoints it sits under the proxy's
+// credential endpoint slot, so requests are authenticated with the run's
+// proxy token before they reach the handler.
+const CtlEndpointPath = "/_aws/credentials/moatctl"
+
+// Approval statuses.
+const (
+	ApprovalPending  = "pending"
+	ApprovalApproved = "approved"
+	ApprovalDenied   = "denied"
+)
+
+// Limits on how long `moatctl approve` waits for a decision.
+const (
+	defaultApprovalWait = 5 * time.Minute
+	maxApprovalWait     = 30 * time.Minute
+)
+
+// approvalRetention is how long a decided approval stays listed.
+const approvalRetention = time.Hour
+
+// Limits on `moatctl approve`, so an agent cannot flood the user with
+// requests or put arbitrary text in their terminal and notifications.
+const (
+	maxPendingApprovals = 20
+	maxApprovalReason   = 256
+)
+
+// approvalHostLabel matches one label of a host `moatctl approve` accepts.
+var approvalHostLabel = regexp.MustCompile(`^[a-z0-9]([a-z0-9_-]*[a-z0-9])?$`)
+
+// Limits on `moatctl snapshot`, so an agent cannot fill the disk with
+// snapshots. Snapshots taken from the host with `moat snapshot` are not
+// limited.
+const (
+	minCtlSnapshotInterval = time.Minute
+	maxCtlSnapshots        = 50
+)
+
+// ErrApprovalNotFound is returned when no approval has the requested ID.
+var ErrApprovalNotFound = errors.New("approval not found")
+
+// errTooManyApprovals is returned when a run already has
+// maxPendingApprovals approvals pending.
+var errTooManyApprovals = fmt.Errorf("too many pending approvals (at most %d); wait for the user to answer them", maxPendingApprovals)
+
+// ErrApprovalDecided is returned when deciding an approval that is no longer
+// pending.
+var ErrApprovalDecided = errors.New("approval already decided")
+
+// CtlBudget is the response to `moatctl budget`: each machine-wide daily LLM
+// quota with what is left of it today.
+type CtlBudget struct {
+	Quotas map[string]CtlQuota `json:"quotas"`
+}
+
+// CtlQuota is one provider's quota in CtlBudget. Remaining amounts are set
+// only for the limits the quota has.
+type CtlQuota struct {
+	QuotaStatus
+	RemainingTokens *int64   `json:"remaining_tokens,omitempty"`
+	RemainingCost   *float64 `json:"remaining_cost,omitempty"`
+}
+
+// Ctl serves moatctl requests from run containers: workspace snapshots,
+// progress events, budget queries, network approvals, and clips. Every call is
+// recorded in the run's audit log.
+type Ctl struct {
+	runsDir    string
+	runStore   func(runID string) *storage.RunStore
+	auditStore func(runID string) *audit.Store
+
+	mu        sync.Mutex
+	approvals map[string]*pendingApproval
+	offered   map[string]*Clip          // clip ID -> clip a run offered the host
+	inbox     map[string]*Clip          // run ID -> clip the host sent the run
+	snapshots map[string]ctlSnapshotUse // run ID -> snapshots the run asked for
+
+	// snapshot creates a workspace snapshot (injectable for testing).
+	snapshot func(runsDir, runID, label string) (snapshot.Metadata, error)
+	now      func() time.Time
+
+	events   *RunEvents // nil until SetEvents
+	registry *Registry  // nil until SetRegistry
+
+	notifier    Notifier // nil until SetNotifier
+	notifyDelay time.Duration
+	notifying   map[string]bool // run ID -> notification scheduled
+}
+
+// Notifier shows a desktop notification, like notify.Send.
+type Notifier func(ctx context.Context, n notify.Notification) error
+
+type pendingApproval struct {
+	Approval
+	done     chan struct{} // closed when decided or the run ends
+	notified bool
+	waiters  int // `moatctl approve` requests waiting for the decision
+}
+
+type ctlSnapshotUse struct {
+	count int
+	last  time.Time
+}
+
+// NewCtl creates a Ctl. Run files are under runsDir; runStore and auditStore
+// return a run's open stores, or nil if they cannot be opened.
+func NewCtl(runsDir string, runStore func(string) *storage.RunStore, auditStore func(string) *audit.Store) *Ctl {
+	return &Ctl{
+		runsDir:    runsDir,
+		runStore:   runStore,
+		auditStore: auditStore,
+		approvals:  make(map[string]*pendingApproval),
+		offered:    make(map[string]*Clip),
+		inbox:      make(map[string]*Clip),
+		snapshots:  make(map[string]ctlSnapshotUse),
+		notifying:  make(map[string]bool),
+		snapshot:   snapshotWorkspace,
+		now:        time.Now,
+	}
+}
+
+// SetEvents sets the hub that approval and clip events are published to.
+func (c *Ctl) SetEvents(events *RunEvents) { c.events = events }
+
+// SetNotifier shows a desktop notification for each approval request still
+// pending after delay, so a user who is not watching the terminal learns
+// that a run is waiting.
+func (c *Ctl) SetNotifier(n Notifier, delay time.Duration) {
+	c.notifier = n
+	c.notifyDelay = delay
+}
+
+// SetRegistry sets the registry approved hosts are added to runs through.
+func (c *Ctl) SetRegistry(r *Registry) { c.registry = r }
+
+// publish sends an approval or clip event to the API's event stream.
+func (c *Ctl) publish(typ, runID string, a *Approval, clip *Clip) {
+	if c.events == nil {
+		return
+	}
+	if clip != nil {
+		stripped := *clip
+		stripped.Data = nil
+		clip = &stripped
+	}
+	c.events.Publish(RunEvent{Type: typ, RunID: runID, Time: c.now().UTC(), Approval: a, Clip: clip})
+}
+
+var (
+	ctlMu      sync.RWMutex
+	ctlService *Ctl
+)
+
+// SetCtl sets the service that answers moatctl requests. With none set,
+// moatctl requests fail with 503.
+func SetCtl(c *Ctl) {
+	ctlMu.Lock()
+	defer ctlMu.Unlock()
+	ctlService = c
+}
+
+func currentCtl() *Ctl {
+	ctlMu.RLock()
+	defer ctlMu.RUnlock()
+	return ctlService
+}
+
+// newCtlHandler returns rc's moatctl endpoint.
+func newCtlHandler(rc *RunContext) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c := currentCtl()
+		if c == nil {
+			http.Error(w, "moatctl is not available", http.StatusServiceUnavailable)
+			return
+		}
+		c.serve(rc, w, r)
+	})
+}
+
+func (c *Ctl) serve(rc *RunContext, w http.ResponseWriter, r *http.Request) {
+	command := strings.Trim(strings.TrimPrefix(r.URL.Path, CtlEndpointPath), "/")
+	method := http.MethodPost
+	if command == "budget" || command == "paste" {
+		method = http.MethodGet
+	}
+	if r.Method != method {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	// A clip's body is its content, not a form.
+	if command != "clip" {
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	switch command {
+	case "snapshot":
+		c.handleSnapshot(rc, w, r)
+	case "progress":
+		c.handleProgress(rc, w, r)
+	case "budget":
+		c.handleBudget(rc, w)
+	case "approve":
+		c.handleApprove(rc, w, r)
+	case "clip":
+		c.handleClip(rc, w, r)
+	case "paste":
+		c.handlePaste(rc, w)
+	default:
+		http.Error(w, fmt.Sprintf("unknown moatctl command %q", command), http.StatusNotFound)
+	}
+}
+
+// record appends a moatctl call to the run's audit log.
+func (c *Ctl) record(runID string, data audit.CtlData) {
+	if c.auditStore == nil {
+		return
+	}
+	if as := c.auditStore(runID); as != nil {
+		if _, err := as.AppendCtl(data); err != nil {
+			log.Warn("recording moatctl call", "run_id", runID, "command", data.Command, "error", err)
+		}
+	}
+}
+
+func (c *Ctl) handleSnapshot(rc *RunContext, w http.ResponseWriter, r *http.Request) {
+	label := r.PostForm.Get("label")
+	release, err := c.reserveSnapshot(rc.RunID)
+	if err != nil {
+		c.record(rc.RunID, audit.CtlData{Command: "snapshot", Detail: label, Result: err.Error()})
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+		return
+	}
+	snap, err := c.snapshot(c.runsDir, rc.RunID, label)
+	if err != nil {
+		release()
+		c.record(rc.RunID, audit.CtlData{Command: "snapshot", Detail: label, Result: err.Error()})
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	c.record(rc.RunID, audit.CtlData{Command: "snapshot", Detail: snap.ID, Result: "ok"})
+	writeJSON(w, http.StatusCreated, snap)
+}
+
+// reserveSnapshot counts a snapshot against runID's limits, or returns an
+// error if the run has taken one too recently or too many in all. The
+// returned func gives the reservation back if the snapshot fails.
+func (c *Ctl) reserveSnapshot(runID string) (release func(), err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	use := c.snapshots[runID]
+	now := c.now()
+	if use.count >= maxCtlSnapshots {
+		return nil, fmt.Errorf("snapshot limit reached (%d per run); use 'moat snapshot' from the host", maxCtlSnapshots)
+	}
+	if wait := use.last.Add(minCtlSnapshotInterval).Sub(now); !use.last.IsZero() && wait > 0 {
+		return nil, fmt.Errorf("too many snapshots; try again in %s", wait.Round(time.Second))
+	}
+	c.snapshots[runID] = ctlSnapshotUse{count: use.count + 1, last: now}
+	return func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if cur, ok := c.snapshots[runID]; ok && cur.last.Equal(now) {
+			c.snapshots[runID] = use
+		}
+	}, nil
+}
+
+// snapshotWorkspace creates a manual snapshot of a bind-mode run's
+// workspace, like `moat snapshot`. Volume-mode workspaces live in a
+// container volume the daemon does not export.
+func snapshotWorkspace(runsDir, runID, label string) (snapshot.Metadata, error) {
+	store, err := storage.NewRunStore(runsDir, runID)
+	if err != nil {
+		return snapshot.Metadata{}, fmt.Errorf("opening run storage: %w", err)
+	}
+	meta, err := store.LoadMetadata()
+	if err != nil {
+		return snapshot.Metadata{}, fmt.Errorf("loading run metadata: %w", err)
+	}
+	if config.IsVolumeMode(meta.WorkspaceMode) {
+		return snapshot.Metadata{}, fmt.Errorf("volume-mode workspaces can only be snapshotted from the host with 'moat snapshot %s'", runID)
+	}
+	if meta.Workspace == "" {
+		return snapshot.Metadata{}, fmt.Errorf("run %s has no workspace to snapshot", runID)
+	}
+	engine, err := snapshot.NewEngine(meta.Workspace, filepath.Join(store.Dir(), "snapshots"), snapshot.EngineOptions{})
+	if err != nil {
+		return snapshot.Metadata{}, fmt.Errorf("initializing snapshot engine: %w", err)
+	}
+	return engine.Create(snapshot.TypeManual, label)
+}
+
+// maxProgressMessage caps the length of a progress message.
+const maxProgressMessage = 1024
+
+func (c *Ctl) handleProgress(rc *RunContext, w http.ResponseWriter, r *http.Request) {
+	p := storage.Progress{Timestamp: c.now().UTC(), Message: strings.TrimSpace(r.PostForm.Get("message"))}
+	if p.Message == "" {
+		http.Error(w, "missing message", http.StatusBadRequest)
+		return
+	}
+	if len(p.Message) > maxProgressMessage {
+		p.Message = p.Message[:maxProgressMessage]
+	}
+	if v := r.PostForm.Get("percent"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 || n > 100 {
+			http.Error(w, "percent must be a whole number from 0 to 100", http.StatusBadRequest)
+			return
+		}
+		p.Percent = &n
+	}
+	store := c.runStore(rc.RunID)
+	if store == nil {
+		http.Error(w, "run storage unavailable", http.StatusInternalServerError)
+		return
+	}
+	if err := store.WriteProgress(p); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	detail := p.Message
+	if p.Percent != nil {
+		detail = fmt.Sprintf("%d%% %s", *p.Percent, detail)
+	}
+	c.record(rc.RunID, audit.CtlData{Command: "progress", Detail: detail, Result: "ok"})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (c *Ctl) handleBudget(rc *RunContext, w http.ResponseWriter) {
+	budget := CtlBudget{Quotas: map[string]CtlQuota{}}
+	if qt := currentQuotaTracker(); qt != nil {
+		for provider, st := range qt.Status() {
+			q := CtlQuota{QuotaStatus: st}
+			if st.DailyTokens > 0 {
+				left := max(st.DailyTokens-st.UsedTokens, 0)
+				q.RemainingTokens = &left
+			}
+			if st.DailyCost > 0 {
+				left := max(st.DailyCost-st.UsedCost, 0)
+				q.RemainingCost = &left
+			}
+			budget.Quotas[provider] = q
+		}
+	}
+	c.record(rc.RunID, audit.CtlData{Command: "budget", Result: "ok"})
+	writeJSON(w, http.StatusOK, budget)
+}
+
+func (c *Ctl) handleApprove(rc *RunContext, w http.ResponseWriter, r *http.Request) {
+	host := strings.ToLower(strings.TrimSpace(r.PostForm.Get("host")))
+	if !validApprovalHost(host) {
+		http.Error(w, "host must be a hostname, optionally with :port", http.StatusBadRequest)
+		return
+	}
+	wait := defaultApprovalWait
+	if v := r.PostForm.Get("wait"); v != "" {
+		secs, err := strconv.Atoi(v)
+		if err != nil || secs < 0 {
+			http.Error(w, "wait must be a number of seconds", http.StatusBadRequest)
+			return
+		}
+		wait = min(time.Duration(secs)*time.Second, maxApprovalWait)
+	}
+
+	rc.mu.RLock()
+	policy := rc.NetworkPolicy
+	rc.mu.RUnlock()
+	if policy != "strict" {
+		// Nothing to approve: the policy already allows every host.
+		c.record(rc.RunID, audit.CtlData{Command: "approve", Detail: host, Result: ApprovalApproved})
+		writeJSON(w, http.StatusOK, Approval{RunID: rc.RunID, Host: host, Status: ApprovalApproved})
+		return
+	}
+
+	pa, err := c.request(rc, host, approvalReason(r.PostForm.Get("reason")))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+		return
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-pa.done:
+	case <-timer.C:
+	case <-r.Context().Done():
+		c.abandon(pa)
+		return
+	}
+
+	c.mu.Lock()
+	pa.waiters--
+	a := pa.Approval
+	c.mu.Unlock()
+	c.record(rc.RunID, audit.CtlData{Command: "approve", Detail: host, Result: a.Status})
+	writeJSON(w, http.StatusOK, a)
+}
+
+// request returns the pending approval for host in rc's run, creating it if
+// there is none, and counts the caller as one of its waiters. Approvals
+// decided more than approvalRetention ago are dropped.
+func (c *Ctl) request(rc *RunContext, host, reason string) (*pendingApproval, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cutoff := c.now().Add(-approvalRetention)
+	for approvalID, pa := range c.approvals {
+		if pa.Status != ApprovalPending && pa.DecidedAt.Before(cutoff) {
+			delete(c.approvals, approvalID)
+		}
+	}
+	pending := 0
+	for _, pa := range c.approvals {
+		if pa.RunID != rc.RunID || pa.Status != ApprovalPending {
+			continue
+		}
+		if pa.Host == host {
+			pa.waiters++
+			return pa, nil
+		}
+		pending++
+	}
+	if pending >= maxPendingApprovals {
+		return nil, errTooManyApprovals
+	}
+	pa := &pendingApproval{
+		Approval: Approval{
+			ID:          id.Generate("apr"),
+			RunID:       rc.RunID,
+			Host:        host,
+			Reason:      reason,
+			Status:      ApprovalPending,
+			RequestedAt: c.now().UTC(),
+		},
+		done:    make(chan struct{}),
+		waiters: 1,
+	}
+	c.approvals[pa.ID] = pa
+	log.Info("network approval requested", "run_id", rc.RunID, "id", pa.ID, "host", host, "reason", reason)
+	a := pa.Approval
+	c.publish(EventApprovalRequested, rc.RunID, &a, nil)
+	if c.notifier != nil && !c.notifying[rc.RunID] {
+		c.notifying[rc.RunID] = true
+		runID := rc.RunID
+		time.AfterFunc(c.notifyDelay, func() { c.notifyPending(runID) })
+	}
+	return pa, nil
+}
+
+// abandon drops a waiter whose `moatctl approve` went away before the
+// approval was decided. A pending approval nobody waits for any more is
+// withdrawn. One left pending by a --wait timeout stays listed, since the
+// agent asked for it and may check back.
+func (c *Ctl) abandon(pa *pendingApproval) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	pa.waiters--
+	if pa.waiters > 0 || pa.Status != ApprovalPending || c.approvals[pa.ID] != pa {
+		return
+	}
+	delete(c.approvals, pa.ID)
+	log.Info("network approval withdrawn", "run_id", pa.RunID, "id", pa.ID, "host", pa.Host)
+}
+
+// validApprovalHost reports whether host is a hostname or IPv4 address,
+// optionally with a port.
+func validApprovalHost(host string) bool {
+	if h, port, ok := strings.Cut(host, ":"); ok {
+		n, err := strconv.Atoi(port)
+		if err != nil || n < 1 || n > 65535 || strconv.Itoa(n) != port {
+			return false
+		}
+		host = h
+	}
+	if host == "" || len(host) > 253 {
+		return false
+	}
+	for _, label := range strings.Split(host, ".") {
+		if len(label) > 63 || !approvalHostLabel.MatchString(label) {
+			return false
+		}
+	}
+	return true
+}
+
+// approvalReason returns reason with control characters removed, cut to
+// maxApprovalReason bytes.
+func approvalReason(reason string) string {
+	reason = strings.TrimSpace(strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return ' '
+		}
+		return r
+	}, reason))
+	if len(reason) > maxApprovalReason {
+		reason = strings.ToValidUTF8(reason[:maxApprovalReason], "")
+	}
+	return reason
+}
+
+// notifyPending shows one desktop notification for runID's pending
+// approvals that have not notified yet. Each approval notifies at most once;
+// one requested after this schedules the next notification.
+func (c *Ctl) notifyPending(runID string) {
+	c.mu.Lock()
+	delete(c.notifying, runID)
+	var pending []Approval
+	for _, pa := range c.approvals {
+		if pa.RunID == runID && pa.Status == ApprovalPending && !pa.notified {
+			pa.notified = true
+			pending = append(pending, pa.Approval)
+		}
+	}
+	c.mu.Unlock()
+	if len(pending) == 0 {
+		return
+	}
+	slices.SortFunc(pending, func(a, b Approval) int { return a.RequestedAt.Compare(b.RequestedAt) })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := c.notifier(ctx, approvalNotification(c.runName(runID), pending)); err != nil {
+		log.Debug("approval notification not shown", "run_id", runID, "error", err)
+	}
+}
+
+// approvalNotification describes a run's pending approvals and the command
+// that answers them.
+func approvalNotification(runName string, pending []Approval) notify.Notification {
+	n := notify.Notification{Title: "moat: " + runName + " is waiting for approval"}
+	if len(pending) == 1 {
+		n.Body = fmt.Sprintf("Allow %s? Run: moat approvals respond %s", pending[0].Host, pending[0].ID)
+	} else {
+		n.Body = fmt.Sprintf("%d requests waiting. Run: moat approvals", len(pending))
+	}
+	return n
+}
+
+// runName returns runID's name, or the ID if the run's metadata cannot be
+// read.
+func (c *Ctl) runName(runID string) string {
+	if c.runStore == nil {
+		return runID
+	}
+	if store := c.runStore(runID); store != nil {
+		if meta, err := store.LoadMetadata(); err == nil && meta.Name != "" {
+			return meta.Name
+		}
+	}
+	return runID
+}
+
+// Approvals returns the approvals requested by runID, or by every run if
+// runID is empty, oldest first.
+func (c *Ctl) Approvals(runID string) []Approval {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var out []Approval
+	for _, pa := range c.approvals {
+		if runID == "" || pa.RunID == runID {
+			out = append(out, pa.Approval)
+		}
+	}
+	slices.SortFunc(out, func(a, b Approval) int { return a.RequestedAt.Compare(b.RequestedAt) })
+	return out
+}
+
+// Decide approves or denies a pending approval. An approved host is added to
+// the run's network rules and allowed from the next request on.
+func (c *Ctl) Decide(approvalID string, approve bool) (Approval, error) {
+	c.mu.Lock()
+	pa, ok := c.approvals[approvalID]
+	if !ok {
+		c.mu.Unlock()
+		return Approval{}, ErrApprovalNotFound
+	}
+	if pa.Status != ApprovalPending {
+		a := pa.Approval
+		c.mu.Unlock()
+		return a, ErrApprovalDecided
+	}
+	pa.Status = ApprovalDenied
+	if approve {
+		pa.Status = ApprovalApproved
+	}
+	pa.DecidedAt = c.now().UTC()
+	a := pa.Approval
+	close(pa.done)
+	c.mu.Unlock()
+
+	if approve && c.registry != nil {
+		if rc, ok := c.registry.LookupRun(a.RunID); ok {
+			rc.allowHost(a.Host)
+		}
+	}
+	log.Info("network approval decided", "run_id", a.RunID, "id", a.ID, "host", a.Host, "status", a.Status)
+	c.publish(EventApprovalDecided, a.RunID, &a, nil)
+	return a, nil
+}
+
+// Forget drops the approvals, clips, and snapshot counts of a run that has
+// ended. A `moatctl approve` still waiting returns its approval as denied.
+func (c *Ctl) Forget(runID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for approvalID, pa := range c.approvals {
+		if pa.RunID != runID {
+			continue
+		}
+		if pa.Status == ApprovalPending {
+			pa.Status = ApprovalDenied
+			pa.DecidedAt = c.now().UTC()
+			close(pa.done)
+		}
+		delete(c.approvals, approvalID)
+	}
+	delete(c.snapshots, runID)
+	delete(c.notifying, runID)
+	for clipID, clip := range c.offered {
+		if clip.RunID == runID {
+			delete(c.offered, clipID)
+		}
+	}
+	delete(c.inbox, runID)
+}
+
+// allowHost adds a host-level allow entry for host to the run's network
+// rules.
+func (rc *RunContext) allowHost(host string) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if len(rc.NetworkRules) == 0 && len(rc.NetworkAllow) > 0 {
+		// Registered by an old CLI that sends plain hosts.
+		rc.NetworkAllow = append(rc.NetworkAllow, host)
+		return
+	}
+	rc.NetworkRules = append(rc.NetworkRules, netrules.HostRules{Host: host})
+}
@@ -0,0 +1,452 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/majorcontext/moat/internal/audit"
+	"github.com/majorcontext/moat/internal/config"
+	"github.com/majorcontext/moat/internal/metering"
+	"github.com/majorcontext/moat/internal/notify"
+	"github.com/majorcontext/moat/internal/snapshot"
+	"github.com/majorcontext/moat/internal/storage"
+)
+
+// newTestCtl installs a Ctl backed by temp run and audit stores and returns
+// it with rc's proxy endpoint.
+func newTestCtl(t *testing.T, rc *RunContext) (*Ctl, http.Handler, *audit.Store) {
+	t.Helper()
+	dir := t.TempDir()
+	store, err := storage.NewRunStore(dir, rc.RunID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	as, err := audit.OpenStore(filepath.Join(dir, "audit.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { as.Close() })
+
+	c := NewCtl(dir, func(string) *storage.RunStore { return store }, func(string) *audit.Store { return as })
+	reg := NewRegistry()
+	reg.Register(rc)
+	c.SetRegistry(reg)
+	SetCtl(c)
+	t.Cleanup(func() { SetCtl(nil) })
+	rc.SetCtlHandler(newCtlHandler(rc))
+	return c, rc.ToProxyContextData().AWSHandler, as
+}
+
+func ctlRequest(h http.Handler, method, command string, form url.Values) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, CtlEndpointPath+"/"+command, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func lastCtlEntry(t *testing.T, as *audit.Store) audit.CtlData {
+	t.Helper()
+	n, err := as.Count()
+	if err != nil || n == 0 {
+		t.Fatalf("audit count = %d, %v", n, err)
+	}
+	e, err := as.Get(n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Type != audit.EntryCtl {
+		t.Fatalf("last audit entry type = %s, want %s", e.Type, audit.EntryCtl)
+	}
+	raw, _ := json.Marshal(e.Data)
+	var d audit.CtlData
+	if err := json.Unmarshal(raw, &d); err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func TestCtl_Progress(t *testing.T) {
+	rc := NewRunContext("run_progress")
+	c, h, as := newTestCtl(t, rc)
+
+	rec := ctlRequest(h, http.MethodPost, "progress", url.Values{"message": {"running tests"}, "percent": {"40"}})
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	events, err := c.runStore(rc.RunID).ReadProgress()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].Message != "running tests" || events[0].Percent == nil || *events[0].Percent != 40 {
+		t.Errorf("progress = %+v", events)
+	}
+	if d := lastCtlEntry(t, as); d.Command != "progress" || d.Detail != "40% running tests" {
+		t.Errorf("audit = %+v", d)
+	}
+
+	for _, form := range []url.Values{
+		{"message": {""}},
+		{"message": {"x"}, "percent": {"150"}},
+	} {
+		if rec := ctlRequest(h, http.MethodPost, "progress", form); rec.Code != http.StatusBadRequest {
+			t.Errorf("%v: status = %d, want 400", form, rec.Code)
+		}
+	}
+	if rec := ctlRequest(h, http.MethodGet, "progress", nil); rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET progress: status = %d, want 405", rec.Code)
+	}
+	if rec := ctlRequest(h, http.MethodPost, "reboot", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown command: status = %d, want 404", rec.Code)
+	}
+}
+
+func TestCtl_Budget(t *testing.T) {
+	qt := NewQuotaTracker(map[string]config.ProviderQuota{"anthropic": {DailyTokens: 1000}}, metering.DefaultPrices())
+	qt.Add(storage.Usage{Timestamp: time.Now(), Provider: "anthropic", InputTokens: 300})
+	SetQuotaTracker(qt)
+	t.Cleanup(func() { SetQuotaTracker(nil) })
+
+	_, h, as := newTestCtl(t, NewRunContext("run_budget"))
+	rec := ctlRequest(h, http.MethodGet, "budget", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	var budget CtlBudget
+	if err := json.NewDecoder(rec.Body).Decode(&budget); err != nil {
+		t.Fatal(err)
+	}
+	q, ok := budget.Quotas["anthropic"]
+	if !ok || q.RemainingTokens == nil || *q.RemainingTokens != 700 || q.RemainingCost != nil {
+		t.Errorf("budget = %+v", budget)
+	}
+	if d := lastCtlEntry(t, as); d.Command != "budget" {
+		t.Errorf("audit = %+v", d)
+	}
+}
+
+func TestCtl_Snapshot(t *testing.T) {
+	rc := NewRunContext("run_snap")
+	c, h, as := newTestCtl(t, rc)
+	c.snapshot = func(_, runID, label string) (snapshot.Metadata, error) {
+		if label == "bad" {
+			return snapshot.Metadata{}, errors.New("disk full")
+		}
+		return snapshot.Metadata{ID: "snap_1", Label: label}, nil
+	}
+
+	rec := ctlRequest(h, http.MethodPost, "snapshot", url.Values{"label": {"before-migrate"}})
+	if rec.Code != http.StatusCreated || !strings.Contains(rec.Body.String(), "snap_1") {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	if d := lastCtlEntry(t, as); d.Command != "snapshot" || d.Detail != "snap_1" || d.Result != "ok" {
+		t.Errorf("audit = %+v", d)
+	}
+
+	c.now = func() time.Time { return time.Now().Add(minCtlSnapshotInterval) }
+	if rec := ctlRequest(h, http.MethodPost, "snapshot", url.Values{"label": {"bad"}}); rec.Code != http.StatusInternalServerError {
+		t.Errorf("failed snapshot: status = %d, want 500", rec.Code)
+	}
+	if d := lastCtlEntry(t, as); d.Result != "disk full" {
+		t.Errorf("audit after failure = %+v", d)
+	}
+}
+
+func TestCtl_SnapshotRateLimit(t *testing.T) {
+	rc := NewRunContext("run_snaplimit")
+	c, h, as := newTestCtl(t, rc)
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	c.now = func() time.Time { return now }
+	taken := 0
+	c.snapshot = func(_, _, _ string) (snapshot.Metadata, error) {
+		taken++
+		return snapshot.Metadata{ID: "snap"}, nil
+	}
+
+	if rec := ctlRequest(h, http.MethodPost, "snapshot", nil); rec.Code != http.StatusCreated {
+		t.Fatalf("first snapshot: status = %d: %s", rec.Code, rec.Body)
+	}
+	if rec := ctlRequest(h, http.MethodPost, "snapshot", nil); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("immediate second snapshot: status = %d, want 429", rec.Code)
+	}
+	if d := lastCtlEntry(t, as); d.Command != "snapshot" || !strings.Contains(d.Result, "too many snapshots") {
+		t.Errorf("audit = %+v", d)
+	}
+
+	for i := 1; i < maxCtlSnapshots; i++ {
+		now = now.Add(minCtlSnapshotInterval)
+		if rec := ctlRequest(h, http.MethodPost, "snapshot", nil); rec.Code != http.StatusCreated {
+			t.Fatalf("snapshot %d: status = %d: %s", i+1, rec.Code, rec.Body)
+		}
+	}
+	now = now.Add(time.Hour)
+	if rec := ctlRequest(h, http.MethodPost, "snapshot", nil); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("snapshot over the per-run limit: status = %d, want 429", rec.Code)
+	}
+	if taken != maxCtlSnapshots {
+		t.Errorf("snapshots taken = %d, want %d", taken, maxCtlSnapshots)
+	}
+
+	// A new run of the same ID starts over.
+	c.Forget(rc.RunID)
+	if rec := ctlRequest(h, http.MethodPost, "snapshot", nil); rec.Code != http.StatusCreated {
+		t.Errorf("snapshot after Forget: status = %d", rec.Code)
+	}
+}
+
+func TestCtl_Approve(t *testing.T) {
+	rc := NewRunContext("run_approve")
+	rc.NetworkPolicy = "strict"
+	c, h, as := newTestCtl(t, rc)
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() {
+		done <- ctlRequest(h, http.MethodPost, "approve", url.Values{"host": {"Pypi.org"}, "reason": {"install deps"}})
+	}()
+
+	var pending []Approval
+	for deadline := time.Now().Add(5 * time.Second); len(pending) == 0; {
+		if time.Now().After(deadline) {
+			t.Fatal("approval never became pending")
+		}
+		time.Sleep(5 * time.Millisecond)
+		pending = c.Approvals(rc.RunID)
+	}
+	a := pending[0]
+	if a.Host != "pypi.org" || a.Reason != "install deps" || a.Status != ApprovalPending {
+		t.Fatalf("pending approval = %+v", a)
+	}
+
+	if _, err := c.Decide(a.ID, true); err != nil {
+		t.Fatal(err)
+	}
+	rec := <-done
+	var got Approval
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != ApprovalApproved {
+		t.Errorf("response = %+v, want approved", got)
+	}
+	if len(rc.NetworkRules) != 1 || rc.NetworkRules[0].Host != "pypi.org" {
+		t.Errorf("network rules = %+v, want pypi.org allowed", rc.NetworkRules)
+	}
+	if d := lastCtlEntry(t, as); d.Command != "approve" || d.Detail != "pypi.org" || d.Result != ApprovalApproved {
+		t.Errorf("audit = %+v", d)
+	}
+
+	if _, err := c.Decide(a.ID, false); !errors.Is(err, ErrApprovalDecided) {
+		t.Errorf("second decision: err = %v, want ErrApprovalDecided", err)
+	}
+	if _, err := c.Decide("apr_missing", true); !errors.Is(err, ErrApprovalNotFound) {
+		t.Errorf("unknown approval: err = %v, want ErrApprovalNotFound", err)
+	}
+
+	c.Forget(rc.RunID)
+	if got := c.Approvals(""); len(got) != 0 {
+		t.Errorf("approvals after Forget = %+v", got)
+	}
+}
+
+func TestCtl_ForgetReleasesWaiters(t *testing.T) {
+	rc := NewRunContext("run_ended")
+	rc.NetworkPolicy = "strict"
+	c, h, _ := newTestCtl(t, rc)
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() {
+		done <- ctlRequest(h, http.MethodPost, "approve", url.Values{"host": {"pypi.org"}})
+	}()
+	for deadline := time.Now().Add(5 * time.Second); len(c.Approvals(rc.RunID)) == 0; {
+		if time.Now().After(deadline) {
+			t.Fatal("approval never became pending")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	c.Forget(rc.RunID)
+	select {
+	case rec := <-done:
+		if !strings.Contains(rec.Body.String(), `"status":"denied"`) {
+			t.Errorf("response = %s, want denied", rec.Body)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("approve still waiting after the run was forgotten")
+	}
+}
+
+func TestCtl_PrunesDecidedApprovals(t *testing.T) {
+	rc := NewRunContext("run_prune")
+	rc.NetworkPolicy = "strict"
+	c, h, _ := newTestCtl(t, rc)
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	c.now = func() time.Time { return now }
+
+	ctlRequest(h, http.MethodPost, "approve", url.Values{"host": {"old.example.com"}, "wait": {"0"}})
+	old := c.Approvals(rc.RunID)[0]
+	if _, err := c.Decide(old.ID, false); err != nil {
+		t.Fatal(err)
+	}
+
+	now = now.Add(approvalRetention + time.Minute)
+	ctlRequest(h, http.MethodPost, "approve", url.Values{"host": {"new.example.com"}, "wait": {"0"}})
+	got := c.Approvals(rc.RunID)
+	if len(got) != 1 || got[0].Host != "new.example.com" {
+		t.Errorf("approvals = %+v, want only new.example.com", got)
+	}
+}
+
+func TestCtl_ApprovalNotifications(t *testing.T) {
+	rc := NewRunContext("run_notify")
+	rc.NetworkPolicy = "strict"
+	c, h, _ := newTestCtl(t, rc)
+	sent := make(chan notify.Notification, 10)
+	c.SetNotifier(func(_ context.Context, n notify.Notification) error {
+		sent <- n
+		return nil
+	}, 50*time.Millisecond)
+	next := func() notify.Notification {
+		t.Helper()
+		select {
+		case n := <-sent:
+			return n
+		case <-time.After(5 * time.Second):
+			t.Fatal("no notification sent")
+			return notify.Notification{}
+		}
+	}
+
+	ctlRequest(h, http.MethodPost, "approve", url.Values{"host": {"pypi.org"}, "wait": {"0"}})
+	a := c.Approvals(rc.RunID)[0]
+	if n := next(); !strings.Contains(n.Title, "run_notify") || !strings.Contains(n.Body, "moat approvals respond "+a.ID) {
+		t.Errorf("notification = %+v", n)
+	}
+
+	// Requests pending together notify once, summarized; an answered one
+	// does not notify.
+	ctlRequest(h, http.MethodPost, "approve", url.Values{"host": {"a.example.com"}, "wait": {"0"}})
+	ctlRequest(h, http.MethodPost, "approve", url.Values{"host": {"b.example.com"}, "wait": {"0"}})
+	if n := next(); !strings.Contains(n.Body, "2 requests waiting") {
+		t.Errorf("summary notification = %+v", n)
+	}
+	ctlRequest(h, http.MethodPost, "approve", url.Values{"host": {"c.example.com"}, "wait": {"0"}})
+	for _, pending := range c.Approvals(rc.RunID) {
+		if pending.Host == "c.example.com" {
+			if _, err := c.Decide(pending.ID, false); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	select {
+	case n := <-sent:
+		t.Errorf("unexpected notification %+v", n)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestCtl_ApproveTimeout(t *testing.T) {
+	rc := NewRunContext("run_timeout")
+	rc.NetworkPolicy = "strict"
+	c, h, _ := newTestCtl(t, rc)
+
+	rec := ctlRequest(h, http.MethodPost, "approve", url.Values{"host": {"example.com"}, "wait": {"0"}})
+	if !strings.Contains(rec.Body.String(), `"status":"pending"`) {
+		t.Errorf("response = %s, want pending", rec.Body)
+	}
+	// A repeat request joins the pending approval instead of adding one.
+	ctlRequest(h, http.MethodPost, "approve", url.Values{"host": {"example.com"}, "wait": {"0"}})
+	if got := c.Approvals(rc.RunID); len(got) != 1 {
+		t.Errorf("approvals = %+v, want one", got)
+	}
+}
+
+func TestCtl_ApproveLimits(t *testing.T) {
+	rc := NewRunContext("run_limits")
+	rc.NetworkPolicy = "strict"
+	c, h, _ := newTestCtl(t, rc)
+
+	for _, host := range []string{"", "example.com\nfake", "example.com/path", "example.com:0", "example.com:99999", "-bad.example.com", "a..b", "\x1b[2Jexample.com"} {
+		if rec := ctlRequest(h, http.MethodPost, "approve", url.Values{"host": {host}, "wait": {"0"}}); rec.Code != http.StatusBadRequest {
+			t.Errorf("host %q: status = %d, want 400", host, rec.Code)
+		}
+	}
+
+	rec := ctlRequest(h, http.MethodPost, "approve", url.Values{"host": {"api.example.com:8443"}, "reason": {"fetch\x1b[31m " + strings.Repeat("x", 1000)}, "wait": {"0"}})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d (%s), want 200", rec.Code, rec.Body)
+	}
+	a := c.Approvals(rc.RunID)[0]
+	if len(a.Reason) > maxApprovalReason || strings.ContainsRune(a.Reason, '\x1b') {
+		t.Errorf("reason = %q, want control characters removed and at most %d bytes", a.Reason, maxApprovalReason)
+	}
+
+	for i := 1; i < maxPendingApprovals; i++ {
+		ctlRequest(h, http.MethodPost, "approve", url.Values{"host": {fmt.Sprintf("h%d.example.com", i)}, "wait": {"0"}})
+	}
+	if rec := ctlRequest(h, http.MethodPost, "approve", url.Values{"host": {"one-more.example.com"}, "wait": {"0"}}); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("status = %d, want 429 past %d pending approvals", rec.Code, maxPendingApprovals)
+	}
+	// Joining an existing approval is still allowed.
+	if rec := ctlRequest(h, http.MethodPost, "approve", url.Values{"host": {"h1.example.com"}, "wait": {"0"}}); rec.Code != http.StatusOK {
+		t.Errorf("repeat request: status = %d, want 200", rec.Code)
+	}
+}
+
+func TestCtl_ApproveWithdrawnWhenWaiterLeaves(t *testing.T) {
+	rc := NewRunContext("run_gone")
+	rc.NetworkPolicy = "strict"
+	c, h, _ := newTestCtl(t, rc)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		form := url.Values{"host": {"pypi.org"}}
+		req := httptest.NewRequest(http.MethodPost, CtlEndpointPath+"/approve", strings.NewReader(form.Encode())).WithContext(ctx)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		h.ServeHTTP(httptest.NewRecorder(), req)
+	}()
+	for deadline := time.Now().Add(5 * time.Second); len(c.Approvals(rc.RunID)) == 0; {
+		if time.Now().After(deadline) {
+			t.Fatal("approval never became pending")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	cancel()
+	<-done
+	if got := c.Approvals(rc.RunID); len(got) != 0 {
+		t.Errorf("approvals after the waiter left = %+v, want none", got)
+	}
+}
+
+func TestCtl_ApprovePermissive(t *testing.T) {
+	rc := NewRunContext("run_permissive")
+	c, h, _ := newTestCtl(t, rc)
+
+	rec := ctlRequest(h, http.MethodPost, "approve", url.Values{"host": {"example.com"}})
+	if !strings.Contains(rec.Body.String(), `"status":"approved"`) {
+		t.Errorf("response = %s, want approved", rec.Body)
+	}
+	if got := c.Approvals(""); len(got) != 0 {
+		t.Errorf("approvals = %+v, want none under a permissive policy", got)
+	}
+}
+
+func TestCtl_Unavailable(t *testing.T) {
+	rc := NewRunContext("run_noctl")
+	rc.SetCtlHandler(newCtlHandler(rc))
+	rec := ctlRequest(rc.ToProxyContextData().AWSHandler, http.MethodGet, "budget", nil)
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want 503", rec.Code)
+	}
+}
@@ -27,13 +27,25 @@ type PersistedRun struct {
 	AuthToken        string                   `json:"auth_token"`
 	RunID            string                   `json:"run_id"`
 	ContainerID      string                   `json:"container_id,omitempty"`
+	Runtime          string                   `json:"runtime,omitempty"`
+	Services         map[string]string        `json:"service_containers,omitempty"`
 	Grants           []string                 `json:"grants,omitempty"`
 	MCPServers       []config.MCPServerConfig `json:"mcp_servers,omitempty"`
 	NetworkPolicy    string                   `json:"network_policy,omitempty"`
 	NetworkAllow     []string                 `json:"network_allow,omitempty"`
 	AWSConfig        *AWSConfig               `json:"aws_config,omitempty"`
+	AzureConfig      *AzureConfig             `json:"azure_config,omitempty"`
+	GCPConfig        *GCPConfig               `json:"gcp_config,omitempty"`
 	TransformerSpecs []TransformerSpec        `json:"transformer_specs,omitempty"`
 	CredProfile      string                   `json:"cred_profile,omitempty"`
+	Workspace        string                   `json:"workspace,omitempty"`
+	Mirror           *config.MirrorConfig     `json:"mirror,omitempty"`
+	SendGuard        *SendGuard               `json:"send_guard,omitempty"`
+	UploadGuard      *UploadGuard             `json:"upload_guard,omitempty"`
+	RequestGuards    []RequestGuard           `json:"request_guards,omitempty"`
+	Faults           []config.FaultConfig     `json:"faults,omitempty"`
+	Scrub            *config.ScrubConfig      `json:"scrub,omitempty"`
+	Capture          string                   `json:"capture,omitempty"`
 }
 
 // persistedFile is the versioned on-disk format.
@@ -73,13 +85,27 @@ func (p *RunPersister) Save() error {
 			AuthToken:        rc.AuthToken,
 			RunID:            rc.RunID,
 			ContainerID:      rc.ContainerID,
+			Runtime:          rc.Runtime,
+			Services:         rc.ServiceContainers,
 			Grants:           rc.Grants,
 			MCPServers:       rc.MCPServers,
 			NetworkPolicy:    rc.NetworkPolicy,
 			NetworkAllow:     rc.NetworkAllow,
 			AWSConfig:        rc.AWSConfig,
+			AzureConfig:      rc.AzureConfig,
+			GCPConfig:        rc.GCPConfig,
 			TransformerSpecs: rc.TransformerSpecs,
 			CredProfile:      rc.CredProfile,
+			Workspace:        rc.Workspace,
+			Mirror:           rc.Mirror,
+			UploadGuard:      rc.UploadGuard,
+			RequestGuards:    rc.RequestGuards,
+			Faults:           rc.Faults,
+			Scrub:            rc.Scrub,
+			Capture:          rc.Capture,
+		}
+		if rc.SendGuard != nil {
+			pr.SendGuard = rc.SendGuard.snapshot()
 		}
 		rc.mu.RUnlock()
 		runs = append(runs, pr)
@@ -206,13 +232,25 @@ func RestoreRuns(ctx context.Context, registry *Registry, runs []PersistedRun) i
 
 		rc := NewRunContext(pr.RunID)
 		rc.ContainerID = pr.ContainerID
+		rc.Runtime = pr.Runtime
+		rc.ServiceContainers = pr.Services
 		rc.Grants = pr.Grants
 		rc.MCPServers = pr.MCPServers
 		rc.NetworkPolicy = pr.NetworkPolicy
 		rc.NetworkAllow = pr.NetworkAllow
 		rc.AWSConfig = pr.AWSConfig
+		rc.AzureConfig = pr.AzureConfig
+		rc.GCPConfig = pr.GCPConfig
 		rc.TransformerSpecs = pr.TransformerSpecs
 		rc.CredProfile = pr.CredProfile
+		rc.Workspace = pr.Workspace
+		rc.Mirror = pr.Mirror
+		rc.SendGuard = pr.SendGuard
+		rc.UploadGuard = pr.UploadGuard
+		rc.RequestGuards = pr.RequestGuards
+		rc.Faults = pr.Faults
+		rc.Scrub = pr.Scrub
+		rc.Capture = pr.Capture
 
 		// Open the store scoped to this run's profile — the daemon serves runs
 		// from many profiles, so a single default-profile store would re-resolve
@@ -224,11 +262,19 @@ func RestoreRuns(ctx context.Context, registry *Registry, runs []PersistedRun) i
 			continue
 		}
 
+		// A grant restricted since the run registered no longer applies.
+		if err := checkGrantRestrictions(rc); err != nil {
+			log.Warn("restore: grant restriction, skipping run",
+				"run_id", pr.RunID, "error", err)
+			continue
+		}
+
 		if err := resolveCredentials(rc, pr.Grants, pr.MCPServers, store); err != nil {
 			log.Warn("restore: failed to resolve credentials, skipping run",
 				"run_id", pr.RunID, "error", err)
 			continue
 		}
+		rc.scopeGrants()
 
 		// Create a per-run context and set cancel BEFORE registering so that
 		// a concurrent handleUnregisterRun sees a valid cancel function.
@@ -262,8 +308,21 @@ func RestoreRuns(ctx context.Context, registry *Registry, runs []PersistedRun) i
 			} else {
 				awsProvider.SetAuthToken(pr.AuthToken)
 				rc.SetAWSHandler(awsProvider.Handler())
+				if pr.AWSConfig.Bedrock {
+					rc.SetBedrockHandler(awsprov.NewBedrockHandler(awsProvider.GetCredentials, pr.AWSConfig.Region))
+				}
+			}
+		}
+		if pr.AzureConfig != nil {
+			rc.SetAzureHandler(newAzureHandler(rc, pr.AzureConfig, pr.AuthToken))
+		}
+		if pr.GCPConfig != nil {
+			rc.SetGCPHandler(newGCPHandler(rc, pr.GCPConfig))
+			if pr.GCPConfig.VertexHost != "" {
+				startVertexAuth(runCtx, rc, pr.GCPConfig.VertexHost)
 			}
 		}
+		rc.SetCtlHandler(newCtlHandler(rc))
 
 		registry.RegisterWithToken(rc, pr.AuthToken)
 
@@ -313,7 +372,7 @@ func resolveCredentials(rc *RunContext, grants []string, mcpServers []config.MCP
 		if prov == nil {
 			continue
 		}
-		prov.ConfigureProxy(rc, provCred)
+		prov.ConfigureProxy(rc.ForGrant(grantName), provCred)
 	}
 	return nil
 }
@@ -28,12 +28,15 @@ func TestRunPersister_SaveAndLoad(t *testing.T) {
 
 	rc2 := NewRunContext("run-2")
 	rc2.ContainerID = "container-abc"
+	rc2.SetContainers("podman", map[string]string{"postgres": "container-db"})
 	rc2.Grants = []string{"claude"}
 	rc2.AWSConfig = &AWSConfig{RoleARN: "arn:aws:iam::123:role/test", Region: "us-east-1"}
+	rc2.AzureConfig = &AzureConfig{Tenant: "tenant-1", Subscription: "sub-1"}
 	rc2.TransformerSpecs = []TransformerSpec{
 		{Host: "api.github.com", Kind: "response-scrub"},
 		{Host: "api.anthropic.com", Kind: "oauth-endpoint-workaround"},
 	}
+	rc2.SendGuard = &SendGuard{Max: 10, Sent: 3, Routes: []SendRoute{{Grant: "sendgrid", Host: "api.sendgrid.com", Method: "POST", Path: "/v3/mail/send"}}}
 	token2 := reg.Register(rc2)
 
 	p := NewRunPersister(path, reg)
@@ -87,14 +90,24 @@ func TestRunPersister_SaveAndLoad(t *testing.T) {
 	if pr2.ContainerID != "container-abc" {
 		t.Errorf("run-2 ContainerID = %q, want %q", pr2.ContainerID, "container-abc")
 	}
+	if pr2.Runtime != "podman" || pr2.Services["postgres"] != "container-db" {
+		t.Errorf("run-2 containers = %q %v, want podman with postgres", pr2.Runtime, pr2.Services)
+	}
 	if pr2.AWSConfig == nil || pr2.AWSConfig.RoleARN != "arn:aws:iam::123:role/test" {
 		t.Errorf("run-2 AWSConfig = %v, want role ARN arn:aws:iam::123:role/test", pr2.AWSConfig)
 	}
+	if pr2.AzureConfig == nil || pr2.AzureConfig.Subscription != "sub-1" {
+		t.Errorf("run-2 AzureConfig = %v, want subscription sub-1", pr2.AzureConfig)
+	}
 	if len(pr2.TransformerSpecs) != 2 {
 		t.Errorf("run-2 TransformerSpecs len = %d, want 2", len(pr2.TransformerSpecs))
 	} else if pr2.TransformerSpecs[0].Kind != "response-scrub" {
 		t.Errorf("run-2 TransformerSpecs[0].Kind = %q, want %q", pr2.TransformerSpecs[0].Kind, "response-scrub")
 	}
+	// The send count survives a restart so the cap isn't reset.
+	if pr2.SendGuard == nil || pr2.SendGuard.Max != 10 || pr2.SendGuard.Sent != 3 || len(pr2.SendGuard.Routes) != 1 {
+		t.Errorf("run-2 SendGuard = %+v, want max 10, 3 sent, 1 route", pr2.SendGuard)
+	}
 }
 
 func TestRunPersister_LoadNonexistent(t *testing.T) {
@@ -0,0 +1,205 @@
+package daemon
+
+import (
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/majorcontext/moat/internal/config"
+	"github.com/majorcontext/moat/internal/id"
+)
+
+// Scheduler hands out run slots under the configured concurrency limits.
+// Callers wait in a single first-come queue; a slot waiting only on its
+// workspace's limit does not hold up slots for other workspaces behind it.
+// A slot is held until its ticket is released, which the API server does
+// when the caller's connection closes, so a run that dies without cleaning
+// up never leaks its slot.
+type Scheduler struct {
+	mu      sync.Mutex
+	limits  config.ConcurrencyConfig
+	slots   []*QueueSlot // arrival order
+	changed chan struct{}
+
+	// now returns the current time (injectable for testing)
+	now func() time.Time
+}
+
+// NewScheduler returns a scheduler without limits: every slot is granted
+// as soon as it is queued.
+func NewScheduler() *Scheduler {
+	return &Scheduler{changed: make(chan struct{}), now: time.Now}
+}
+
+// SetLimits replaces the concurrency limits. Raising a limit grants waiting
+// slots right away; lowering one never revokes a granted slot.
+func (s *Scheduler) SetLimits(limits config.ConcurrencyConfig) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.limits = limits
+	s.schedule()
+}
+
+// Limits returns the concurrency limits.
+func (s *Scheduler) Limits() config.ConcurrencyConfig {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.limits
+}
+
+// Enqueue adds a slot for a run in workspace and returns its ticket. The
+// slot may be granted immediately; check with Status.
+func (s *Scheduler) Enqueue(name, workspace string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	slot := &QueueSlot{
+		Ticket:    id.Generate("slot"),
+		State:     SlotQueued,
+		Name:      name,
+		Workspace: filepath.Clean(workspace),
+		Since:     s.now(),
+	}
+	s.slots = append(s.slots, slot)
+	s.schedule()
+	return slot.Ticket
+}
+
+// Release gives up a slot, granted or not, and grants waiting slots that
+// now fit.
+func (s *Scheduler) Release(ticket string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, slot := range s.slots {
+		if slot.Ticket == ticket {
+			s.slots = append(s.slots[:i], s.slots[i+1:]...)
+			s.schedule()
+			return
+		}
+	}
+}
+
+// Update records the run holding a slot. It returns false for an unknown
+// ticket.
+func (s *Scheduler) Update(ticket string, u QueueSlotUpdate) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	slot := s.find(ticket)
+	if slot == nil {
+		return false
+	}
+	slot.RunID = u.RunID
+	if u.Name != "" {
+		slot.Name = u.Name
+	}
+	s.notify()
+	return true
+}
+
+// Status returns a slot's state and, while it waits, its place in the
+// queue and the limit it is waiting on.
+func (s *Scheduler) Status(ticket string) (QueueStatus, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	slot := s.find(ticket)
+	if slot == nil {
+		return QueueStatus{}, false
+	}
+	st := QueueStatus{Ticket: ticket, State: slot.State}
+	perWorkspace := make(map[string]int)
+	for _, other := range s.slots {
+		if other.State == SlotRunning {
+			st.Running++
+			perWorkspace[other.Workspace]++
+		}
+	}
+	if slot.State == SlotRunning {
+		return st, true
+	}
+	for _, other := range s.slots {
+		if other.State == SlotQueued {
+			st.Position++
+		}
+		if other == slot {
+			break
+		}
+	}
+	st.Limit = "max_runs"
+	if s.limits.MaxRuns == 0 || st.Running < s.limits.MaxRuns {
+		st.Limit = "max_runs_per_workspace"
+	}
+	return st, true
+}
+
+// Info lists the running and queued slots.
+func (s *Scheduler) Info() QueueInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	info := QueueInfo{ConcurrencyConfig: s.limits, Running: []QueueSlot{}, Queued: []QueueSlot{}}
+	for _, slot := range s.slots {
+		if slot.State == SlotRunning {
+			info.Running = append(info.Running, *slot)
+		} else {
+			info.Queued = append(info.Queued, *slot)
+		}
+	}
+	return info
+}
+
+// Len returns the number of slots, running or queued.
+func (s *Scheduler) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.slots)
+}
+
+// Changed returns a channel that is closed at the next change to the
+// queue.
+func (s *Scheduler) Changed() <-chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.changed
+}
+
+func (s *Scheduler) find(ticket string) *QueueSlot {
+	for _, slot := range s.slots {
+		if slot.Ticket == ticket {
+			return slot
+		}
+	}
+	return nil
+}
+
+// schedule grants queued slots in arrival order while they fit the limits,
+// then wakes everyone watching the queue. Callers hold mu.
+func (s *Scheduler) schedule() {
+	running := 0
+	perWorkspace := make(map[string]int)
+	for _, slot := range s.slots {
+		if slot.State == SlotRunning {
+			running++
+			perWorkspace[slot.Workspace]++
+		}
+	}
+	for _, slot := range s.slots {
+		if slot.State != SlotQueued {
+			continue
+		}
+		if s.limits.MaxRuns > 0 && running >= s.limits.MaxRuns {
+			break
+		}
+		if s.limits.MaxRunsPerWorkspace > 0 && perWorkspace[slot.Workspace] >= s.limits.MaxRunsPerWorkspace {
+			continue
+		}
+		slot.State = SlotRunning
+		slot.Since = s.now()
+		running++
+		perWorkspace[slot.Workspace]++
+	}
+	s.notify()
+}
+
+// notify wakes everyone watching the queue. Callers hold mu.
+func (s *Scheduler) notify() {
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
@@ -0,0 +1,186 @@
+package daemon
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/majorcontext/moat/internal/config"
+)
+
+func slotState(t *testing.T, s *Scheduler, ticket string) QueueStatus {
+	t.Helper()
+	st, ok := s.Status(ticket)
+	if !ok {
+		t.Fatalf("Status(%s): unknown ticket", ticket)
+	}
+	return st
+}
+
+func TestScheduler_MaxRuns(t *testing.T) {
+	s := NewScheduler()
+	s.SetLimits(config.ConcurrencyConfig{MaxRuns: 2})
+
+	a := s.Enqueue("a", "/ws/one")
+	b := s.Enqueue("b", "/ws/two")
+	c := s.Enqueue("c", "/ws/three")
+	d := s.Enqueue("d", "/ws/four")
+
+	for _, ticket := range []string{a, b} {
+		if st := slotState(t, s, ticket); st.State != SlotRunning {
+			t.Errorf("%s = %+v, want running", ticket, st)
+		}
+	}
+	st := slotState(t, s, d)
+	if st.State != SlotQueued || st.Position != 2 || st.Running != 2 || st.Limit != "max_runs" {
+		t.Errorf("d = %+v, want queued second behind max_runs", st)
+	}
+
+	s.Release(a)
+	if st := slotState(t, s, c); st.State != SlotRunning {
+		t.Errorf("c after release = %+v, want running", st)
+	}
+	if st := slotState(t, s, d); st.Position != 1 {
+		t.Errorf("d after release = %+v, want first in queue", st)
+	}
+
+	// Raising the limit grants waiting slots; lowering it revokes nothing.
+	s.SetLimits(config.ConcurrencyConfig{MaxRuns: 3})
+	if st := slotState(t, s, d); st.State != SlotRunning {
+		t.Errorf("d after raising the limit = %+v, want running", st)
+	}
+	s.SetLimits(config.ConcurrencyConfig{MaxRuns: 1})
+	if info := s.Info(); len(info.Running) != 3 {
+		t.Errorf("running after lowering the limit = %d, want 3", len(info.Running))
+	}
+}
+
+func TestScheduler_MaxRunsPerWorkspace(t *testing.T) {
+	s := NewScheduler()
+	s.SetLimits(config.ConcurrencyConfig{MaxRuns: 3, MaxRunsPerWorkspace: 1})
+
+	a := s.Enqueue("a", "/ws/one")
+	b := s.Enqueue("b", "/ws/one/")
+	c := s.Enqueue("c", "/ws/two")
+
+	if st := slotState(t, s, b); st.State != SlotQueued || st.Limit != "max_runs_per_workspace" {
+		t.Errorf("b = %+v, want queued on max_runs_per_workspace", st)
+	}
+	// b waits on its workspace; c in another workspace is not held up.
+	if st := slotState(t, s, c); st.State != SlotRunning {
+		t.Errorf("c = %+v, want running", st)
+	}
+
+	s.Release(a)
+	if st := slotState(t, s, b); st.State != SlotRunning {
+		t.Errorf("b after release = %+v, want running", st)
+	}
+}
+
+func TestScheduler_Unlimited(t *testing.T) {
+	s := NewScheduler()
+	for i := 0; i < 10; i++ {
+		if st := slotState(t, s, s.Enqueue("", "/ws")); st.State != SlotRunning {
+			t.Fatalf("slot %d = %+v, want running without limits", i, st)
+		}
+	}
+}
+
+func TestServer_Queue(t *testing.T) {
+	sock := filepath.Join(testSockDir(t), "d.sock")
+	srv := NewServer(sock, 9119)
+	srv.Scheduler().SetLimits(config.ConcurrencyConfig{MaxRuns: 1})
+	if err := srv.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer srv.Stop(context.Background())
+	client := NewClient(sock)
+	ctx := context.Background()
+
+	first, err := client.Enqueue(ctx, QueueRequest{Workspace: "/ws"}, nil)
+	if err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+	if err := client.UpdateSlot(ctx, first.Ticket, QueueSlotUpdate{RunID: "run_1", Name: "one"}); err != nil {
+		t.Fatalf("UpdateSlot: %v", err)
+	}
+
+	statuses := make(chan QueueStatus, 10)
+	granted := make(chan *Slot, 1)
+	go func() {
+		slot, err := client.Enqueue(ctx, QueueRequest{Name: "two", Workspace: "/ws"}, func(st QueueStatus) { statuses <- st })
+		if err != nil {
+			t.Errorf("second Enqueue: %v", err)
+		}
+		granted <- slot
+	}()
+
+	select {
+	case st := <-statuses:
+		if st.State != SlotQueued || st.Position != 1 || st.Running != 1 {
+			t.Errorf("second slot status = %+v, want queued first", st)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for queue status")
+	}
+
+	info, err := client.ListQueue(ctx)
+	if err != nil {
+		t.Fatalf("ListQueue: %v", err)
+	}
+	if info.MaxRuns != 1 || len(info.Running) != 1 || info.Running[0].RunID != "run_1" || len(info.Queued) != 1 || info.Queued[0].Name != "two" {
+		t.Errorf("ListQueue = %+v", info)
+	}
+
+	// Releasing the first slot hands it to the waiting caller.
+	first.Release()
+	select {
+	case slot := <-granted:
+		if slot == nil {
+			t.Fatal("second Enqueue returned no slot")
+		}
+		slot.Release()
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the slot")
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for srv.Scheduler().Len() > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := srv.Scheduler().Len(); n != 0 {
+		t.Errorf("%d slots held after release, want 0", n)
+	}
+}
+
+func TestClient_EnqueueCanceled(t *testing.T) {
+	sock := filepath.Join(testSockDir(t), "d.sock")
+	srv := NewServer(sock, 9119)
+	srv.Scheduler().SetLimits(config.ConcurrencyConfig{MaxRuns: 1})
+	if err := srv.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer srv.Stop(context.Background())
+	client := NewClient(sock)
+
+	held, err := client.Enqueue(context.Background(), QueueRequest{Workspace: "/ws"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer held.Release()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	_, err = client.Enqueue(ctx, QueueRequest{Workspace: "/ws"}, func(QueueStatus) { cancel() })
+	if err != context.Canceled {
+		t.Errorf("Enqueue after cancel = %v, want context.Canceled", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for srv.Scheduler().Len() > 1 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := len(srv.Scheduler().Info().Queued); n != 0 {
+		t.Errorf("%d slots queued after cancel, want 0", n)
+	}
+}
@@ -0,0 +1,189 @@
+package daemon
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/majorcontext/moat/internal/config"
+	"github.com/majorcontext/moat/internal/log"
+	"github.com/majorcontext/moat/internal/metering"
+	"github.com/majorcontext/moat/internal/storage"
+)
+
+// QuotaStatus is one provider's daily quota and today's usage, reported in
+// HealthResponse.Quotas.
+type QuotaStatus struct {
+	config.ProviderQuota
+	UsedTokens int64   `json:"used_tokens"`
+	UsedCost   float64 `json:"used_cost"`
+	Exceeded   bool    `json:"exceeded,omitempty"`
+}
+
+// QuotaTracker sums metered LLM usage per provider for the current local day
+// across every run on the daemon, and reports when a provider's quota is
+// used up. Totals reset at local midnight.
+type QuotaTracker struct {
+	quotas map[string]config.ProviderQuota
+	prices metering.Prices
+
+	mu     sync.Mutex
+	day    string
+	tokens map[string]int64
+	cost   map[string]float64
+	warned map[string]bool // providers whose exhaustion was logged today
+
+	// now returns the current time (injectable for testing)
+	now func() time.Time
+}
+
+// NewQuotaTracker returns a tracker for quotas, pricing usage with prices.
+func NewQuotaTracker(quotas map[string]config.ProviderQuota, prices metering.Prices) *QuotaTracker {
+	return &QuotaTracker{quotas: quotas, prices: prices, now: time.Now}
+}
+
+// rollover clears the totals when the local day has changed. Callers hold mu.
+func (t *QuotaTracker) rollover() {
+	day := t.now().Format(time.DateOnly)
+	if day == t.day {
+		return
+	}
+	t.day = day
+	t.tokens = make(map[string]int64)
+	t.cost = make(map[string]float64)
+	t.warned = make(map[string]bool)
+}
+
+// Add counts u toward its provider's total if it happened today.
+func (t *QuotaTracker) Add(u storage.Usage) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.rollover()
+	if !u.Timestamp.IsZero() && u.Timestamp.In(t.now().Location()).Format(time.DateOnly) != t.day {
+		return
+	}
+	t.tokens[u.Provider] += u.InputTokens + u.OutputTokens + u.CacheReadTokens + u.CacheWriteTokens
+	if c, ok := t.prices.Cost(u); ok {
+		t.cost[u.Provider] += c
+	}
+}
+
+// Exceeded reports whether provider's quota for today is used up.
+func (t *QuotaTracker) Exceeded(provider string) bool {
+	q, ok := t.quotas[provider]
+	if !ok {
+		return false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.rollover()
+	if !exceeded(q, t.tokens[provider], t.cost[provider]) {
+		return false
+	}
+	if !t.warned[provider] {
+		t.warned[provider] = true
+		log.Warn("daily LLM quota reached; denying requests until midnight",
+			"provider", provider, "used_tokens", t.tokens[provider], "used_cost", t.cost[provider])
+	}
+	return true
+}
+
+func exceeded(q config.ProviderQuota, tokens int64, cost float64) bool {
+	return (q.DailyTokens > 0 && tokens >= q.DailyTokens) || (q.DailyCost > 0 && cost >= q.DailyCost)
+}
+
+// Status returns each quota with today's usage.
+func (t *QuotaTracker) Status() map[string]QuotaStatus {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.rollover()
+	out := make(map[string]QuotaStatus, len(t.quotas))
+	for provider, q := range t.quotas {
+		out[provider] = QuotaStatus{
+			ProviderQuota: q,
+			UsedTokens:    t.tokens[provider],
+			UsedCost:      t.cost[provider],
+			Exceeded:      exceeded(q, t.tokens[provider], t.cost[provider]),
+		}
+	}
+	return out
+}
+
+// Load adds today's usage from the usage.jsonl of every run under baseDir,
+// so a restarted daemon does not reset the day's totals. Files not written
+// today are skipped without being read.
+func (t *QuotaTracker) Load(baseDir string) error {
+	names, err := storage.ListRunDirNames(baseDir)
+	if err != nil {
+		return err
+	}
+	t.mu.Lock()
+	t.rollover()
+	day := t.day
+	t.mu.Unlock()
+
+	runIDs := make([]string, 0, len(names))
+	for name := range names {
+		runIDs = append(runIDs, name)
+	}
+	sort.Strings(runIDs)
+	for _, runID := range runIDs {
+		info, err := os.Stat(filepath.Join(baseDir, runID, "usage.jsonl"))
+		if err != nil || info.ModTime().Format(time.DateOnly) != day {
+			continue
+		}
+		store, err := storage.NewRunStore(baseDir, runID)
+		if err != nil {
+			continue
+		}
+		usage, err := store.ReadUsage()
+		if err != nil {
+			log.Warn("failed to read usage for quota", "run_id", runID, "error", err)
+			continue
+		}
+		for _, u := range usage {
+			t.Add(u)
+		}
+	}
+	return nil
+}
+
+var (
+	quotaMu      sync.RWMutex
+	quotaTracker *QuotaTracker
+)
+
+// SetQuotaTracker sets the tracker that enforces machine-wide LLM quotas.
+// Metered usage is added to it, and requests to a metered provider are
+// denied once its quota is used up. With no tracker, no quotas apply.
+func SetQuotaTracker(t *QuotaTracker) {
+	quotaMu.Lock()
+	defer quotaMu.Unlock()
+	quotaTracker = t
+}
+
+func currentQuotaTracker() *QuotaTracker {
+	quotaMu.RLock()
+	defer quotaMu.RUnlock()
+	return quotaTracker
+}
+
+// checkQuota denies a POST to a metered provider whose quota is used up.
+func checkQuota(t *QuotaTracker, method, host string) *denial {
+	if t == nil || method != http.MethodPost {
+		return nil
+	}
+	provider := metering.ProviderForHost(host)
+	if provider == "" || !t.Exceeded(provider) {
+		return nil
+	}
+	return &denial{
+		kind:    "quota",
+		status:  http.StatusTooManyRequests,
+		reason:  "Daily LLM quota reached: " + provider,
+		message: "the machine-wide daily " + provider + " quota is used up until midnight",
+	}
+}
@@ -0,0 +1,106 @@
+package daemon
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/majorcontext/moat/internal/config"
+	"github.com/majorcontext/moat/internal/metering"
+	"github.com/majorcontext/moat/internal/storage"
+)
+
+func TestQuotaTracker(t *testing.T) {
+	now := time.Date(2026, 3, 2, 23, 0, 0, 0, time.Local)
+	qt := NewQuotaTracker(map[string]config.ProviderQuota{
+		"anthropic": {DailyTokens: 1000},
+		"openai":    {DailyCost: 1},
+	}, metering.DefaultPrices())
+	qt.now = func() time.Time { return now }
+
+	// Yesterday's usage does not count.
+	qt.Add(storage.Usage{Timestamp: now.Add(-24 * time.Hour), Provider: "anthropic", InputTokens: 5000})
+	qt.Add(storage.Usage{Timestamp: now, Provider: "anthropic", InputTokens: 600, CacheReadTokens: 300})
+	if qt.Exceeded("anthropic") {
+		t.Fatal("anthropic exceeded at 900/1000 tokens")
+	}
+	qt.Add(storage.Usage{Timestamp: now, Provider: "anthropic", OutputTokens: 100})
+	if !qt.Exceeded("anthropic") {
+		t.Fatal("anthropic not exceeded at 1000/1000 tokens")
+	}
+
+	// gpt-4o output is $10 per million tokens.
+	qt.Add(storage.Usage{Timestamp: now, Provider: "openai", Model: "gpt-4o", OutputTokens: 200_000})
+	if st := qt.Status()["openai"]; !st.Exceeded || st.UsedCost < 1.99 {
+		t.Errorf("openai status = %+v, want $2 used and exceeded", st)
+	}
+
+	// Totals reset at midnight.
+	now = now.Add(2 * time.Hour)
+	if qt.Exceeded("anthropic") || qt.Exceeded("openai") {
+		t.Error("quotas still exceeded the next day")
+	}
+	if qt.Exceeded("gemini") {
+		t.Error("provider without a quota reported exceeded")
+	}
+}
+
+func TestQuotaTracker_Load(t *testing.T) {
+	baseDir := t.TempDir()
+	store, err := storage.NewRunStore(baseDir, "run_a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	for _, u := range []storage.Usage{
+		{Timestamp: now, Provider: "anthropic", InputTokens: 700},
+		{Timestamp: now.AddDate(0, 0, -2), Provider: "anthropic", InputTokens: 700},
+	} {
+		if err := store.WriteUsage(u); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	qt := NewQuotaTracker(map[string]config.ProviderQuota{"anthropic": {DailyTokens: 1000}}, metering.DefaultPrices())
+	if err := qt.Load(baseDir); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if got := qt.Status()["anthropic"].UsedTokens; got != 700 {
+		t.Errorf("UsedTokens = %d, want 700 (today's usage only)", got)
+	}
+}
+
+func TestCheckQuota_DeniesLLMCalls(t *testing.T) {
+	qt := NewQuotaTracker(map[string]config.ProviderQuota{"anthropic": {DailyTokens: 10}}, metering.DefaultPrices())
+	SetQuotaTracker(qt)
+	t.Cleanup(func() { SetQuotaTracker(nil) })
+
+	rc := NewRunContext("run_test")
+	if !rc.guardsHost("api.anthropic.com", 443) || rc.guardsHost("example.com", 443) {
+		t.Error("guardsHost does not follow the metered hosts")
+	}
+	check := func(method, rawURL string) *denial {
+		req := httptest.NewRequest(method, rawURL, nil)
+		_, d := rc.checkRequest(req, req.URL.Hostname(), 443)
+		return d
+	}
+	if d := check("POST", "https://api.anthropic.com/v1/messages"); d != nil {
+		t.Fatalf("request under the quota was denied: %+v", d)
+	}
+
+	recordUsage("run_test", storage.Usage{Timestamp: time.Now(), Provider: "anthropic", OutputTokens: 10})
+	d := check("POST", "https://api.anthropic.com/v1/messages")
+	if d == nil {
+		t.Fatal("request past the quota was allowed")
+	}
+	if d.kind != "quota" || d.status != http.StatusTooManyRequests || d.reason != "Daily LLM quota reached: anthropic" {
+		t.Errorf("denial = %+v", d)
+	}
+	// Other providers, non-POST requests, and other hosts are unaffected.
+	if check("POST", "https://api.openai.com/v1/responses") != nil ||
+		check("GET", "https://api.anthropic.com/v1/models") != nil ||
+		check("POST", "https://example.com/") != nil {
+		t.Error("request unaffected by the quota was denied")
+	}
+}
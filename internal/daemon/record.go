@@ -0,0 +1,158 @@
+package daemon
+
+import (
+	"fmt"
+	"maps"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/majorcontext/moat/internal/log"
+	"github.com/majorcontext/moat/internal/storage"
+)
+
+// PolicyRecord is the network.policy that allows all traffic like
+// permissive and records each destination into a suggested strict policy.
+const PolicyRecord = "record"
+
+// SuggestedPolicyFile is the file in a run's directory that holds the
+// suggested network policy for runs under network.policy record.
+const SuggestedPolicyFile = "network-suggested.yaml"
+
+// EgressRecorder keeps SuggestedPolicyFile up to date for runs under
+// network.policy record. The file is regenerated from the run's decision log
+// whenever a destination is seen for the first time, so it survives daemon
+// restarts and stays complete if the run is stopped abruptly.
+type EgressRecorder struct {
+	mu   sync.Mutex
+	seen map[string]map[string]bool // run ID → recorded entries
+}
+
+// NewEgressRecorder creates an EgressRecorder.
+func NewEgressRecorder() *EgressRecorder {
+	return &EgressRecorder{seen: make(map[string]map[string]bool)}
+}
+
+// Observe records a decision logged for rc's run, which stores its files in
+// store. It does nothing unless the run's policy is record.
+func (e *EgressRecorder) Observe(rc *RunContext, store *storage.RunStore, d storage.Decision) {
+	if rc == nil || store == nil {
+		return
+	}
+	rc.mu.RLock()
+	policy := rc.NetworkPolicy
+	rc.mu.RUnlock()
+	if policy != PolicyRecord {
+		return
+	}
+	entry, ok := suggestedEntry(d)
+	if !ok {
+		return
+	}
+	if len(d.Grants) > 0 {
+		// A granted host is listed differently; track it separately so a
+		// later ungranted request still rewrites the file.
+		entry = "grant:" + entry
+	}
+
+	e.mu.Lock()
+	seen := e.seen[rc.RunID]
+	if seen == nil {
+		seen = make(map[string]bool)
+		e.seen[rc.RunID] = seen
+	}
+	if seen[entry] {
+		e.mu.Unlock()
+		return
+	}
+	seen[entry] = true
+	// Hold the lock while rewriting so concurrent requests cannot write an
+	// older snapshot over a newer one.
+	defer e.mu.Unlock()
+
+	decisions, err := store.ReadDecisions()
+	if err != nil {
+		log.Warn("reading decisions for suggested policy", "run_id", rc.RunID, "error", err)
+		return
+	}
+	path := filepath.Join(store.Dir(), SuggestedPolicyFile)
+	if err := os.WriteFile(path, SuggestPolicy(rc.RunID, decisions, time.Now()), 0o644); err != nil {
+		log.Warn("writing suggested policy", "run_id", rc.RunID, "error", err)
+	}
+}
+
+// Forget drops the destinations recorded for a run.
+func (e *EgressRecorder) Forget(runID string) {
+	e.mu.Lock()
+	delete(e.seen, runID)
+	e.mu.Unlock()
+}
+
+// SuggestPolicy renders a moat.yaml network block that allows exactly the
+// destinations reached in decisions. Hosts that only received grant
+// credentials are listed as comments, since strict mode allows grant hosts
+// automatically.
+func SuggestPolicy(runID string, decisions []storage.Decision, now time.Time) []byte {
+	rules := make(map[string]bool)
+	granted := make(map[string]bool)
+	for _, d := range decisions {
+		entry, ok := suggestedEntry(d)
+		if !ok {
+			continue
+		}
+		if len(d.Grants) > 0 {
+			granted[entry] = true
+		} else {
+			rules[entry] = true
+		}
+	}
+	for entry := range rules {
+		delete(granted, entry)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Suggested network policy for %s, recorded %s.\n", runID, now.UTC().Format(time.RFC3339))
+	b.WriteString("# Review it, then copy the network block into moat.yaml.\n")
+	b.WriteString("network:\n  policy: strict\n")
+	if len(rules) == 0 {
+		b.WriteString("  rules: []\n")
+	} else {
+		b.WriteString("  rules:\n")
+		for _, entry := range slices.Sorted(maps.Keys(rules)) {
+			fmt.Fprintf(&b, "    - %q\n", entry)
+		}
+	}
+	if len(granted) > 0 {
+		b.WriteString("# Reached with grant credentials, so allowed without a rule:\n")
+		for _, entry := range slices.Sorted(maps.Keys(granted)) {
+			fmt.Fprintf(&b, "#   %s\n", entry)
+		}
+	}
+	return []byte(b.String())
+}
+
+// suggestedEntry returns the network.rules entry that would allow the
+// destination of d, or false if d should not appear in a suggested policy:
+// denied requests, host gateway ports (configured with network.host), and
+// moat's own MCP and LLM relays.
+func suggestedEntry(d storage.Decision) (string, bool) {
+	if d.Decision != "allow" || d.Reason == storage.DecisionHostPort || d.Host == "" {
+		return "", false
+	}
+	switch d.Type {
+	case "mcp", "relay":
+		return "", false
+	}
+	host := strings.ToLower(d.Host)
+	if h, port, err := net.SplitHostPort(host); err == nil {
+		if port == "80" || port == "443" {
+			return h, true
+		}
+		return host, true
+	}
+	return host, true
+}
@@ -0,0 +1,84 @@
+package daemon
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/majorcontext/moat/internal/storage"
+)
+
+func TestSuggestPolicy(t *testing.T) {
+	decisions := []storage.Decision{
+		{Host: "registry.npmjs.org", Decision: "allow", Reason: storage.DecisionPolicy},
+		{Host: "API.github.com", Decision: "allow", Reason: storage.DecisionPolicy, Grants: []string{"github"}},
+		{Host: "pypi.org:443", Decision: "allow", Reason: storage.DecisionPolicy},
+		{Host: "db.internal:5432", Decision: "allow", Reason: storage.DecisionPolicy},
+		{Host: "registry.npmjs.org", Decision: "allow", Reason: storage.DecisionPolicy},
+		{Host: "evil.example.com", Decision: "deny", Reason: "rule"},
+		{Host: "moat-host:8288", Decision: "allow", Reason: storage.DecisionHostPort},
+		{Host: "mcp.example.com", Type: "mcp", Decision: "allow"},
+	}
+	got := string(SuggestPolicy("run_rec", decisions, time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)))
+	want := `# Suggested network policy for run_rec, recorded 2026-01-02T03:04:05Z.
+# Review it, then copy the network block into moat.yaml.
+network:
+  policy: strict
+  rules:
+    - "db.internal:5432"
+    - "pypi.org"
+    - "registry.npmjs.org"
+# Reached with grant credentials, so allowed without a rule:
+#   api.github.com
+`
+	if got != want {
+		t.Errorf("SuggestPolicy() =\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestEgressRecorderObserve(t *testing.T) {
+	store, err := storage.NewRunStore(t.TempDir(), "run_rec")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(store.Dir(), SuggestedPolicyFile)
+	rec := NewEgressRecorder()
+	log := func(rc *RunContext, host string) {
+		d := storage.Decision{Host: host, Decision: "allow", Reason: storage.DecisionPolicy}
+		if err := store.WriteDecision(d); err != nil {
+			t.Fatal(err)
+		}
+		rec.Observe(rc, store, d)
+	}
+
+	permissive := NewRunContext("run_rec")
+	permissive.NetworkPolicy = "permissive"
+	log(permissive, "example.com")
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("suggested policy written for permissive run (stat err %v)", err)
+	}
+
+	rc := NewRunContext("run_rec")
+	rc.NetworkPolicy = PolicyRecord
+	log(rc, "api.openai.com")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The file is built from the whole decision log, including requests
+	// logged before the recorder saw the run.
+	for _, want := range []string{`"api.openai.com"`, `"example.com"`} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("suggested policy missing %s:\n%s", want, data)
+		}
+	}
+
+	// A repeated destination does not rewrite the file.
+	os.Remove(path)
+	log(rc, "api.openai.com")
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("suggested policy rewritten for a known destination (stat err %v)", err)
+	}
+}
@@ -92,6 +92,9 @@ func StartTokenRefresh(ctx context.Context, rc *RunContext, grants []string) {
 				return
 			case <-ticker.C:
 				refreshTokensForRun(ctx, rc, grants, store)
+			case <-rc.Woken():
+				refreshTokensForRun(ctx, rc, grants, store)
+				ticker.Reset(5 * time.Minute)
 			}
 		}
 	}()
@@ -122,7 +125,7 @@ func refreshTokensForRun(ctx context.Context, rc *RunContext, grants []string, s
 		}
 
 		refreshCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
-		updated, err := rp.Refresh(refreshCtx, rc, provCred)
+		updated, err := rp.Refresh(refreshCtx, rc.ForGrant(grantName), provCred)
 		cancel()
 		if err != nil {
 			log.Debug("token refresh failed", "provider", credName, "error", err)
@@ -137,6 +140,11 @@ func refreshTokensForRun(ctx context.Context, rc *RunContext, grants []string, s
 				ExpiresAt: updated.ExpiresAt,
 				CreatedAt: updated.CreatedAt,
 				Metadata:  updated.Metadata,
+				Restrict:  cred.Restrict,
+			}
+			// Refresh replaces the token, not the grant: keep its expiry.
+			if exp := cred.GrantExpiry(); !exp.IsZero() {
+				storeCred.SetGrantExpiry(exp)
 			}
 			if saveErr := store.Save(storeCred); saveErr != nil {
 				log.Debug("failed to persist refreshed credential", "provider", credName, "error", saveErr)
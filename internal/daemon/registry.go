@@ -53,6 +53,19 @@ func (r *Registry) Lookup(token string) (*RunContext, bool) {
 	return rc, ok
 }
 
+// LookupRun finds a RunContext by run ID. The proxy's request logger only
+// knows the run ID, not the auth token.
+func (r *Registry) LookupRun(runID string) (*RunContext, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, rc := range r.runs {
+		if rc.RunID == runID {
+			return rc, true
+		}
+	}
+	return nil, false
+}
+
 // Unregister removes a RunContext by its auth token.
 func (r *Registry) Unregister(token string) {
 	r.mu.Lock()
@@ -0,0 +1,92 @@
+package daemon
+
+import (
+	"net/http"
+
+	"github.com/majorcontext/moat/internal/log"
+	"github.com/majorcontext/moat/internal/providers/stripe"
+)
+
+// Request guard kinds.
+const (
+	GuardStripeLiveMode = stripe.GuardLiveMode
+)
+
+// RequestGuard is a check a grant has the daemon run on requests to Host
+// before forwarding them. Grants declare them through
+// provider.GuardingProvider.
+type RequestGuard struct {
+	Grant string `json:"grant,omitempty"`
+	Host  string `json:"host"`
+	Kind  string `json:"kind"`
+}
+
+// requestGuardChecks maps each guard kind to its check. A check runs with
+// rc's lock held and returns the denial for a request it refuses.
+var requestGuardChecks = map[string]func(rc *RunContext, g RequestGuard, req *http.Request) *denial{
+	GuardStripeLiveMode: checkStripeLiveMode,
+}
+
+// guardsRequests reports whether any of rc's request guards applies to
+// host:port. The caller must hold rc.mu.
+func (rc *RunContext) guardsRequests(host string, port int) bool {
+	for _, g := range rc.RequestGuards {
+		if hostMatchAdapter(g.Host, host, port) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkRequestGuards runs rc's request guards for host:port on req. A guard
+// of a kind this daemon does not know refuses every request, since the
+// grant relies on it.
+func (rc *RunContext) checkRequestGuards(req *http.Request, host string, port int) *denial {
+	rc.mu.RLock()
+	defer rc.mu.RUnlock()
+	for _, g := range rc.RequestGuards {
+		if !hostMatchAdapter(g.Host, host, port) {
+			continue
+		}
+		check, ok := requestGuardChecks[g.Kind]
+		if !ok {
+			log.Warn("unknown request guard kind; refusing request", "subsystem", "daemon", "run_id", rc.RunID, "kind", g.Kind, "host", host)
+			return &denial{
+				kind:    "request-guard",
+				status:  http.StatusForbidden,
+				reason:  "Unknown request guard: " + g.Kind,
+				message: "the " + g.Grant + " grant requires a check this proxy daemon does not support; run 'moat proxy restart' to upgrade",
+			}
+		}
+		if d := check(rc, g, req); d != nil {
+			return d
+		}
+	}
+	return nil
+}
+
+// checkStripeLiveMode refuses a request to a test-mode-only Stripe grant's
+// host that would run in live mode: one carrying a live key in its URL or
+// headers, or one the proxy would give a live key. Stripe picks the mode
+// from the key alone. The caller must hold rc.mu.
+func checkStripeLiveMode(rc *RunContext, g RequestGuard, req *http.Request) *denial {
+	live := carriesFunc(req, stripe.ContainsLiveKey)
+	for _, e := range rc.Credentials[g.Host] {
+		if e.Grant == g.Grant && stripe.ContainsLiveKey(e.Value) {
+			live = true
+		}
+	}
+	if ts, ok := rc.TokenSubstitutions[g.Host]; ok && ts.Grant == g.Grant && stripe.ContainsLiveKey(ts.RealToken) {
+		live = true
+	}
+	if !live {
+		return nil
+	}
+	log.Warn("blocked live-mode stripe request", "subsystem", "daemon", "run_id", rc.RunID, "method", req.Method, "path", req.URL.Path)
+	return &denial{
+		kind:    "stripe-live-mode",
+		status:  http.StatusForbidden,
+		reason:  "Stripe live mode blocked: " + g.Grant,
+		message: "the " + g.Grant + " grant is test-mode only, and this request would run in live mode",
+	}
+}
@@ -0,0 +1,245 @@
+package daemon
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+// RequestTransformer rewrites a request before Front forwards it to the
+// proxy. An error refuses the request.
+type RequestTransformer func(req *http.Request) error
+
+// RequestTransformerFactory builds a request transformer for spec. It runs
+// when the spec is validated, so errors in spec's args fail the run at
+// start.
+type RequestTransformerFactory func(spec TransformerSpec) (RequestTransformer, error)
+
+type requestTransformerKind struct {
+	factory      RequestTransformerFactory
+	requiredArgs []string
+}
+
+var (
+	requestTransformerRegistry = map[string]requestTransformerKind{}
+
+	// requestTransformers caches built request transformers by spec, so
+	// each is built once rather than on every request.
+	requestTransformers sync.Map // string -> RequestTransformer
+)
+
+// RegisterRequestTransformer adds a named request transformer kind that
+// TransformerSpecs can reference. Front applies it to a run's requests to
+// the spec's host before forwarding them. requiredArgs lists the spec Args
+// keys the kind needs. Registering an existing kind replaces it.
+func RegisterRequestTransformer(kind string, requiredArgs []string, f RequestTransformerFactory) {
+	transformerMu.Lock()
+	defer transformerMu.Unlock()
+	requestTransformerRegistry[kind] = requestTransformerKind{factory: f, requiredArgs: requiredArgs}
+}
+
+// IsRequestTransformer reports whether kind is a registered request
+// transformer kind, applied by Front rather than the proxy.
+func IsRequestTransformer(kind string) bool {
+	transformerMu.RLock()
+	defer transformerMu.RUnlock()
+	_, ok := requestTransformerRegistry[kind]
+	return ok
+}
+
+// buildRequestTransformer returns the request transformer for spec, which
+// must name a registered request kind.
+func buildRequestTransformer(spec TransformerSpec) (RequestTransformer, error) {
+	key := requestTransformerKey(spec)
+	if tf, ok := requestTransformers.Load(key); ok {
+		return tf.(RequestTransformer), nil
+	}
+	transformerMu.RLock()
+	k, ok := requestTransformerRegistry[spec.Kind]
+	transformerMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown request transformer kind %q", spec.Kind)
+	}
+	tf, err := k.factory(spec)
+	if err != nil {
+		return nil, err
+	}
+	requestTransformers.Store(key, tf)
+	return tf, nil
+}
+
+// requestTransformerKey identifies a spec's kind and args.
+func requestTransformerKey(spec TransformerSpec) string {
+	names := make([]string, 0, len(spec.Args))
+	for name := range spec.Args {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	b.WriteString(spec.Kind)
+	for _, name := range names {
+		fmt.Fprintf(&b, "\x00%s=%s", name, spec.Args[name])
+	}
+	return b.String()
+}
+
+// requestTransformers returns the request transformers of rc's specs for
+// host:port, in the order they were configured, with their kinds. A spec
+// whose host has no port applies to any port.
+func (rc *RunContext) requestTransformers(host string, port int) ([]RequestTransformer, []string) {
+	rc.mu.RLock()
+	specs := rc.TransformerSpecs
+	rc.mu.RUnlock()
+	var tfs []RequestTransformer
+	var kinds []string
+	for _, spec := range specs {
+		if !IsRequestTransformer(spec.Kind) || !transformerHostMatches(spec.Host, host, port) {
+			continue
+		}
+		tf, err := buildRequestTransformer(spec)
+		if err != nil {
+			continue
+		}
+		tfs = append(tfs, tf)
+		kinds = append(kinds, spec.Kind)
+	}
+	return tfs, kinds
+}
+
+// transformRequest applies rc's request transformers for host:port to req.
+// A transformer that fails refuses the request.
+func (rc *RunContext) transformRequest(req *http.Request, host string, port int) *denial {
+	tfs, kinds := rc.requestTransformers(host, port)
+	for i, tf := range tfs {
+		if err := tf(req); err != nil {
+			return &denial{
+				kind:    "request-transform",
+				status:  http.StatusBadGateway,
+				reason:  "Request transform failed: " + kinds[i],
+				message: fmt.Sprintf("the %s transform (network.transforms) failed: %v", kinds[i], err),
+			}
+		}
+	}
+	return nil
+}
+
+// transformerHostMatches reports whether a transformer spec's host, a host
+// or host:port, applies to host:port.
+func transformerHostMatches(specHost, host string, port int) bool {
+	if h, p, err := net.SplitHostPort(specHost); err == nil {
+		return strings.EqualFold(h, host) && p == strconv.Itoa(port)
+	}
+	return strings.EqualFold(specHost, host)
+}
+
+// requestTemplateData is what a request-body-template template renders.
+type requestTemplateData struct {
+	Method string
+	Host   string
+	Path   string
+	Query  string
+	Body   string // the original body
+	JSON   any    // the original body parsed as JSON, or nil
+}
+
+// requestTemplateFuncs are the functions request body templates may call.
+var requestTemplateFuncs = template.FuncMap{
+	// json renders a value as JSON, for embedding parts of the original
+	// body in the new one.
+	"json": func(v any) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+// newPathRewriter rewrites request paths starting with from to start with
+// to instead. Other paths are left alone.
+func newPathRewriter(from, to string) RequestTransformer {
+	return func(req *http.Request) error {
+		rest, ok := strings.CutPrefix(req.URL.Path, from)
+		if !ok {
+			return nil
+		}
+		path := to + rest
+		if !strings.HasPrefix(path, "/") {
+			path = "/" + path
+		}
+		req.URL.Path = path
+		req.URL.RawPath = ""
+		return nil
+	}
+}
+
+// newBodyTemplate replaces request bodies with tmpl rendered with the
+// request (see requestTemplateData). Bodies over maxScrubBodySize are
+// forwarded unchanged.
+func newBodyTemplate(tmpl *template.Template) RequestTransformer {
+	return func(req *http.Request) error {
+		var body []byte
+		if req.Body != nil && req.Body != http.NoBody {
+			if req.ContentLength > maxScrubBodySize {
+				return nil
+			}
+			var err error
+			body, err = io.ReadAll(io.LimitReader(req.Body, maxScrubBodySize+1))
+			if err != nil {
+				return fmt.Errorf("reading request body: %w", err)
+			}
+			if len(body) > maxScrubBodySize {
+				req.Body = readCloser{io.MultiReader(bytes.NewReader(body), req.Body), req.Body}
+				return nil
+			}
+			req.Body.Close()
+		}
+		data := requestTemplateData{
+			Method: req.Method,
+			Host:   req.URL.Hostname(),
+			Path:   req.URL.Path,
+			Query:  req.URL.RawQuery,
+			Body:   string(body),
+		}
+		_ = json.Unmarshal(body, &data.JSON)
+		var out bytes.Buffer
+		if err := tmpl.Execute(&out, data); err != nil {
+			return err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(out.Bytes()))
+		req.ContentLength = int64(out.Len())
+		req.Header.Del("Transfer-Encoding")
+		req.TransferEncoding = nil
+		return nil
+	}
+}
+
+// readCloser reads from one reader and closes another.
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+func init() {
+	RegisterRequestTransformer(TransformRequestPathRewrite, []string{"from"}, func(spec TransformerSpec) (RequestTransformer, error) {
+		if !strings.HasPrefix(spec.Args["from"], "/") {
+			return nil, fmt.Errorf("arg \"from\" must be a path starting with /")
+		}
+		return newPathRewriter(spec.Args["from"], spec.Args["to"]), nil
+	})
+	RegisterRequestTransformer(TransformRequestBodyTemplate, []string{"template"}, func(spec TransformerSpec) (RequestTransformer, error) {
+		tmpl, err := template.New(spec.Host).Option("missingkey=zero").Funcs(requestTemplateFuncs).Parse(spec.Args["template"])
+		if err != nil {
+			return nil, err
+		}
+		return newBodyTemplate(tmpl), nil
+	})
+	RegisterRequestTransformer(TransformRequestStarlark, []string{"script"}, func(spec TransformerSpec) (RequestTransformer, error) {
+		return newStarlarkTransformer(spec.Host, spec.Args["script"])
+	})
+}
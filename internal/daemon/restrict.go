@@ -0,0 +1,57 @@
+package daemon
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/majorcontext/moat/internal/credential"
+)
+
+// checkGrantRestrictions re-checks, against the credential store, that each
+// of rc's grants has not expired and may be used in the run's registered
+// workspace. The CLI checks this before creating the run; the daemon does
+// not rely on it, so a run registered from another workspace, or with an
+// expired grant, gets no credentials at all.
+func checkGrantRestrictions(rc *RunContext) error {
+	if len(rc.Grants) == 0 {
+		return nil
+	}
+	key, err := credential.DefaultEncryptionKey()
+	if err != nil {
+		return fmt.Errorf("getting encryption key: %w", err)
+	}
+	store, err := credential.NewFileStore(storeDirForRun(rc), key)
+	if err != nil {
+		return fmt.Errorf("opening credential store: %w", err)
+	}
+	for _, grant := range rc.Grants {
+		grantName := strings.Split(grant, ":")[0]
+		if grantName == "ssh" {
+			continue
+		}
+		cred, err := store.Get(resolveCredName(grantName, grant))
+		if errors.Is(err, credential.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("grant %q: %w", grant, err)
+		}
+		if cred.GrantExpired(time.Now()) {
+			return fmt.Errorf("grant %q expired at %s", grant, cred.GrantExpiry().Format(time.RFC3339))
+		}
+		if cred.Restrict == nil {
+			continue
+		}
+		// An older CLI does not register the workspace; a restricted
+		// grant cannot be checked without it.
+		if rc.Workspace == "" {
+			return fmt.Errorf("grant %q is restricted to %s, but the run registered no workspace", grant, cred.Restrict)
+		}
+		if err := cred.Restrict.Check(rc.Workspace); err != nil {
+			return fmt.Errorf("grant %q is %v; run workspace is %s", grant, err, rc.Workspace)
+		}
+	}
+	return nil
+}
@@ -3,8 +3,11 @@ package daemon
 
 import (
 	"context"
+	"fmt"
 	"net"
 	"net/http"
+	"slices"
+	"strconv"
 	"sync"
 	"time"
 
@@ -15,6 +18,10 @@ import (
 	"github.com/majorcontext/moat/internal/credential"
 	"github.com/majorcontext/moat/internal/log"
 	"github.com/majorcontext/moat/internal/netrules"
+	"github.com/majorcontext/moat/internal/provider"
+	awsprov "github.com/majorcontext/moat/internal/providers/aws"
+	azureprov "github.com/majorcontext/moat/internal/providers/azure"
+	gcpprov "github.com/majorcontext/moat/internal/providers/gcp"
 )
 
 // CredentialEntry holds a credential header for proxy injection.
@@ -28,12 +35,14 @@ type CredentialEntry struct {
 type ExtraHeaderEntry struct {
 	Name  string `json:"name"`
 	Value string `json:"value"`
+	Grant string `json:"grant,omitempty"` // grant that registered the header, if any
 }
 
 // TokenSubstitutionEntry holds a placeholder-to-real-token mapping.
 type TokenSubstitutionEntry struct {
 	Placeholder string `json:"placeholder"`
 	RealToken   string `json:"real_token"`
+	Grant       string `json:"grant,omitempty"` // grant that registered the substitution, if any
 }
 
 // AWSConfig holds AWS credential provider configuration.
@@ -43,6 +52,30 @@ type AWSConfig struct {
 	SessionDuration time.Duration `json:"session_duration"`
 	ExternalID      string        `json:"external_id,omitempty"`
 	Profile         string        `json:"profile,omitempty"`
+
+	// Bedrock enables the Bedrock relay, which signs Claude Code's Bedrock
+	// requests with the role's credentials (claude.provider: bedrock).
+	Bedrock bool `json:"bedrock,omitempty"`
+}
+
+// AzureConfig holds Azure token endpoint configuration. For a service
+// principal grant only the client ID is sent; the daemon reads the client
+// secret from the run's credential store.
+type AzureConfig struct {
+	Tenant       string `json:"tenant"`
+	Subscription string `json:"subscription,omitempty"`
+	ClientID     string `json:"client_id,omitempty"`
+}
+
+// GCPConfig holds GCP metadata endpoint configuration. The refresh token or
+// service account key is not sent; the daemon reads it from the run's
+// credential store.
+type GCPConfig struct {
+	Project string `json:"project,omitempty"`
+
+	// VertexHost, when set, is the Vertex AI API host the daemon injects
+	// an access token for (claude.provider: vertex).
+	VertexHost string `json:"vertex_host,omitempty"`
 }
 
 // RunContext holds per-run proxy state. It implements credential.ProxyConfigurer
@@ -52,6 +85,11 @@ type RunContext struct {
 	ContainerID string `json:"container_id,omitempty"`
 	AuthToken   string `json:"auth_token"`
 
+	// Runtime and ServiceContainers identify the run's containers for the
+	// stats sampler. Both are set with the container ID.
+	Runtime           string            `json:"runtime,omitempty"`
+	ServiceContainers map[string]string `json:"service_containers,omitempty"`
+
 	Credentials          map[string][]CredentialEntry                `json:"credentials"`
 	ExtraHeaders         map[string][]ExtraHeaderEntry               `json:"extra_headers"`
 	RemoveHeaders        map[string][]string                         `json:"remove_headers"`
@@ -64,24 +102,72 @@ type RunContext struct {
 	NetworkRules  []netrules.HostRules     `json:"network_rules,omitempty"`
 
 	AWSConfig        *AWSConfig        `json:"aws_config,omitempty"`
+	AzureConfig      *AzureConfig      `json:"azure_config,omitempty"`
+	GCPConfig        *GCPConfig        `json:"gcp_config,omitempty"`
 	TransformerSpecs []TransformerSpec `json:"transformer_specs,omitempty"`
 	Grants           []string          `json:"grants,omitempty"`
 	HostGateway      string            `json:"host_gateway,omitempty"`
 	HostGatewayIP    string            `json:"host_gateway_ip,omitempty"` // actual IP for forwarding allowed host traffic
 	AllowedHostPorts []int             `json:"allowed_host_ports,omitempty"`
 
+	// Mirror, when set, duplicates this run's LLM API requests to a
+	// secondary endpoint. See Mirror in mirror.go.
+	Mirror *config.MirrorConfig `json:"mirror,omitempty"`
+
+	// Faults, when set, injects latency and failures into this run's
+	// responses from the listed hosts. See faults.go.
+	Faults []config.FaultConfig `json:"faults,omitempty"`
+
+	// Scrub, when set, strips response headers from the hosts this run has
+	// credentials for. See scrub.go.
+	Scrub *config.ScrubConfig `json:"scrub,omitempty"`
+
+	// SendGuard, when set, caps the messages this run may send through
+	// messaging grants. See SendGuard in sendguard.go.
+	SendGuard *SendGuard `json:"send_guard,omitempty"`
+
+	// UploadGuard, when set, caps the request body size this run may send
+	// to each host. See UploadGuard in uploads.go.
+	UploadGuard *UploadGuard `json:"upload_guard,omitempty"`
+
+	// RequestGuards are checks the run's grants have the daemon run on
+	// requests before forwarding them. See requestguards.go.
+	RequestGuards []RequestGuard `json:"request_guards,omitempty"`
+
+	// Capture is the run's network.capture mode, which sets what the network
+	// trace records. See NewNetworkRequest in capture.go.
+	Capture string `json:"capture,omitempty"`
+
 	// CredProfile is the credential profile this run was created under (from
 	// the CLI's --profile/MOAT_PROFILE). The daemon is shared across profiles,
 	// so token refresh must scope to this value rather than the daemon
 	// process's own credential.ActiveProfile. Empty means the default profile.
 	CredProfile string `json:"cred_profile,omitempty"`
 
+	// Workspace is the run's host workspace path, checked against grants
+	// restricted to particular workspaces or repositories.
+	Workspace string `json:"workspace,omitempty"`
+
+	// GrantScopes maps each grant with credentials to the host patterns it
+	// may be used with, fixed when the run registers. See scopeGrants in
+	// grantscope.go.
+	GrantScopes map[string][]string `json:"grant_scopes,omitempty"`
+
 	RegisteredAt time.Time `json:"registered_at"`
 
-	KeepEngines   map[string]*keeplib.Engine `json:"-"` // compiled Keep policy engines per scope
-	refreshCancel context.CancelFunc         `json:"-"` // cancels token refresh goroutine
-	awsHandler    http.Handler               `json:"-"` // AWS credential endpoint handler
-	mu            sync.RWMutex
+	KeepEngines       map[string]*keeplib.Engine `json:"-"` // compiled Keep policy engines per scope
+	transformerGrants map[string][]string        `json:"-"` // grant of each ResponseTransformers entry, by host
+	revokedGrants     map[string]bool            `json:"-"` // grants whose injections were revoked
+	cidrAdmitted      map[string]bool            `json:"-"` // addresses CIDR rules admitted HTTPS tunnels to, as host:port
+	refreshCancel     context.CancelFunc         `json:"-"` // cancels token refresh goroutine
+	woken             chan struct{}              `json:"-"` // closed by NotifyWake
+	awsHandler        http.Handler               `json:"-"` // AWS credential endpoint handler
+	bedrockHandler    http.Handler               `json:"-"` // Bedrock signing relay
+	azureHandler      http.Handler               `json:"-"` // Azure token endpoint handler
+	gcpHandler        http.Handler               `json:"-"` // GCP metadata endpoint handler
+	ctlHandler        http.Handler               `json:"-"` // moatctl endpoint handler
+	endpoints         http.Handler               `json:"-"` // credential endpoint handlers combined
+	mu                sync.RWMutex
 }
 
 // NewRunContext creates a new RunContext for a run.
@@ -109,6 +195,29 @@ func (rc *RunContext) CancelRefresh() {
 	}
 }
 
+// Woken returns a channel closed the next time the host wakes from sleep.
+// Loops that refresh on a timer select on it: timers do not advance while
+// the host is suspended, so a refresh due during the sleep would otherwise
+// run late.
+func (rc *RunContext) Woken() <-chan struct{} {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if rc.woken == nil {
+		rc.woken = make(chan struct{})
+	}
+	return rc.woken
+}
+
+// NotifyWake wakes every goroutine waiting on Woken.
+func (rc *RunContext) NotifyWake() {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if rc.woken != nil {
+		close(rc.woken)
+		rc.woken = nil
+	}
+}
+
 // Close releases resources held by this RunContext, including all Keep engines.
 // Safe to call concurrently and multiple times.
 func (rc *RunContext) Close() {
@@ -150,11 +259,170 @@ func (rc *RunContext) SetContainerID(id string) {
 	rc.ContainerID = id
 }
 
+// SetContainers records the runtime and service containers reported with
+// the run's container ID.
+func (rc *RunContext) SetContainers(runtime string, services map[string]string) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.Runtime = runtime
+	rc.ServiceContainers = services
+}
+
+// containers returns the run's runtime, container ID, and service
+// containers.
+func (rc *RunContext) containers() (runtime, id string, services map[string]string) {
+	rc.mu.RLock()
+	defer rc.mu.RUnlock()
+	return rc.Runtime, rc.ContainerID, rc.ServiceContainers
+}
+
 // SetAWSHandler stores the AWS credential endpoint handler for this run.
 func (rc *RunContext) SetAWSHandler(h http.Handler) {
 	rc.mu.Lock()
 	defer rc.mu.Unlock()
 	rc.awsHandler = h
+	rc.endpoints = rc.combineEndpoints()
+}
+
+// SetBedrockHandler stores the Bedrock signing relay for this run.
+func (rc *RunContext) SetBedrockHandler(h http.Handler) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.bedrockHandler = h
+	rc.endpoints = rc.combineEndpoints()
+}
+
+// SetAzureHandler stores the Azure token endpoint handler for this run.
+func (rc *RunContext) SetAzureHandler(h http.Handler) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.azureHandler = h
+	rc.endpoints = rc.combineEndpoints()
+}
+
+// SetGCPHandler stores the GCP metadata endpoint handler for this run.
+func (rc *RunContext) SetGCPHandler(h http.Handler) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.gcpHandler = h
+	rc.endpoints = rc.combineEndpoints()
+}
+
+// SetCtlHandler stores the moatctl endpoint handler for this run.
+func (rc *RunContext) SetCtlHandler(h http.Handler) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.ctlHandler = h
+	rc.endpoints = rc.combineEndpoints()
+}
+
+// combineEndpoints returns the handler the proxy dispatches /_aws/ requests
+// to. Gatekeeper has a single credential endpoint slot per run, so when
+// Azure, GCP, Bedrock, or moatctl is configured its path is routed ahead of
+// AWS. Caller must hold rc.mu.
+func (rc *RunContext) combineEndpoints() http.Handler {
+	if rc.azureHandler == nil && rc.gcpHandler == nil && rc.ctlHandler == nil && rc.bedrockHandler == nil {
+		return rc.awsHandler
+	}
+	mux := http.NewServeMux()
+	if rc.bedrockHandler != nil {
+		mux.Handle(awsprov.BedrockEndpointPath+"/", rc.bedrockHandler)
+	}
+	if rc.ctlHandler != nil {
+		mux.Handle(CtlEndpointPath+"/", rc.ctlHandler)
+	}
+	if rc.azureHandler != nil {
+		mux.Handle(azureprov.EndpointPath, rc.azureHandler)
+	}
+	if rc.gcpHandler != nil {
+		mux.Handle(gcpprov.EndpointPath, rc.gcpHandler)
+		mux.Handle(gcpprov.EndpointPath+"/", rc.gcpHandler)
+	}
+	if rc.awsHandler != nil {
+		mux.Handle("/", rc.awsHandler)
+	}
+	return mux
+}
+
+// newAzureHandler builds the Azure token endpoint for cfg. Containers present
+// the run's proxy token as the managed identity secret.
+func newAzureHandler(rc *RunContext, cfg *AzureConfig, authToken string) http.Handler {
+	pcfg := azureprov.Config{Tenant: cfg.Tenant, Subscription: cfg.Subscription, ClientID: cfg.ClientID}
+	if cfg.ClientID != "" {
+		secret, err := azureClientSecret(rc, cfg.ClientID)
+		if err != nil {
+			// Token requests fail with a re-grant hint until the run ends.
+			log.Warn("azure: cannot load service principal secret", "run_id", rc.RunID, "error", err)
+		}
+		pcfg.ClientSecret = secret
+	}
+	h := azureprov.NewEndpointHandler(pcfg)
+	h.SetAuthToken(authToken)
+	return h
+}
+
+// azureClientSecret reads the service principal's client secret from the
+// run's credential store, so it never travels over the register API or into
+// the persisted run registry.
+func azureClientSecret(rc *RunContext, clientID string) (string, error) {
+	key, err := credential.DefaultEncryptionKey()
+	if err != nil {
+		return "", fmt.Errorf("getting encryption key: %w", err)
+	}
+	store, err := credential.NewFileStore(storeDirForRun(rc), key)
+	if err != nil {
+		return "", fmt.Errorf("opening credential store: %w", err)
+	}
+	cred, err := store.Get(credential.ProviderAzure)
+	if err != nil {
+		return "", err
+	}
+	cfg, err := azureprov.ConfigFromCredential(provider.FromLegacy(cred))
+	if err != nil {
+		return "", err
+	}
+	if cfg.ClientID != clientID {
+		return "", fmt.Errorf("stored azure grant is for client %q, not %q", cfg.ClientID, clientID)
+	}
+	return cfg.ClientSecret, nil
+}
+
+// newGCPHandler builds the GCP metadata endpoint for cfg from the gcp grant
+// in the run's credential store.
+func newGCPHandler(rc *RunContext, cfg *GCPConfig) http.Handler {
+	pcfg, err := gcpGrantConfig(rc)
+	if err != nil {
+		// Token requests fail with a re-grant hint until the run ends.
+		log.Warn("gcp: cannot load grant", "run_id", rc.RunID, "error", err)
+		h := gcpprov.NewMetadataHandler(gcpprov.Config{Project: cfg.Project})
+		h.SetFetcher(func(context.Context, []string) (*gcpprov.Token, error) {
+			return nil, fmt.Errorf("no gcp credential for run: %w", err)
+		})
+		return h
+	}
+	if cfg.Project != "" {
+		pcfg.Project = cfg.Project
+	}
+	return gcpprov.NewMetadataHandler(*pcfg)
+}
+
+// gcpGrantConfig reads the refresh token or service account key from the
+// run's credential store, so it never travels over the register API or into
+// the persisted run registry.
+func gcpGrantConfig(rc *RunContext) (*gcpprov.Config, error) {
+	key, err := credential.DefaultEncryptionKey()
+	if err != nil {
+		return nil, fmt.Errorf("getting encryption key: %w", err)
+	}
+	store, err := credential.NewFileStore(storeDirForRun(rc), key)
+	if err != nil {
+		return nil, fmt.Errorf("opening credential store: %w", err)
+	}
+	cred, err := store.Get(credential.ProviderGCP)
+	if err != nil {
+		return nil, err
+	}
+	return gcpprov.ConfigFromCredential(provider.FromLegacy(cred))
 }
 
 // SetCredential implements credential.ProxyConfigurer.
@@ -167,14 +435,69 @@ func (rc *RunContext) SetCredentialHeader(host, headerName, headerValue string)
 	rc.SetCredentialWithGrant(host, headerName, headerValue, "")
 }
 
+// ForGrant returns a credential.ProxyConfigurer that configures rc on behalf
+// of grant. Providers configure the run through it so that every header,
+// substitution, and transformer they register can be revoked with the grant.
+func (rc *RunContext) ForGrant(grant string) credential.ProxyConfigurer {
+	return grantConfigurer{rc: rc, grant: grant}
+}
+
+// grantConfigurer attributes what a provider configures to a grant. A
+// provider that labels its credentials with its own grant name keeps it.
+type grantConfigurer struct {
+	rc    *RunContext
+	grant string
+}
+
+func (g grantConfigurer) SetCredential(host, value string) {
+	g.rc.SetCredentialWithGrant(host, "Authorization", value, g.grant)
+}
+
+func (g grantConfigurer) SetCredentialHeader(host, headerName, headerValue string) {
+	g.rc.SetCredentialWithGrant(host, headerName, headerValue, g.grant)
+}
+
+func (g grantConfigurer) SetCredentialWithGrant(host, headerName, headerValue, grant string) {
+	if grant == "" {
+		grant = g.grant
+	}
+	g.rc.SetCredentialWithGrant(host, headerName, headerValue, grant)
+}
+
+func (g grantConfigurer) AddExtraHeader(host, headerName, headerValue string) {
+	g.rc.addExtraHeader(host, ExtraHeaderEntry{Name: headerName, Value: headerValue, Grant: g.grant})
+}
+
+func (g grantConfigurer) AddResponseTransformer(host string, transformer credential.ResponseTransformer) {
+	g.rc.addResponseTransformer(host, transformer, g.grant)
+}
+
+func (g grantConfigurer) RemoveRequestHeader(host, headerName string) {
+	g.rc.RemoveRequestHeader(host, headerName)
+}
+
+func (g grantConfigurer) SetTokenSubstitution(host, placeholder, realToken string) {
+	g.rc.setTokenSubstitution(host, TokenSubstitutionEntry{Placeholder: placeholder, RealToken: realToken, Grant: g.grant})
+}
+
 // SetCredentialWithGrant implements credential.ProxyConfigurer.
 // When multiple grants target the same host (e.g., "claude" and "anthropic"
 // on api.anthropic.com), each is stored separately. If an entry with the same
 // grant and header name already exists for the host (e.g., during token
-// refresh), it is updated in place rather than duplicated.
+// refresh), it is updated in place rather than duplicated. Once the run's
+// grants are scoped, a credential for a host outside its grant's scope is
+// refused.
 func (rc *RunContext) SetCredentialWithGrant(host, headerName, headerValue, grant string) {
 	rc.mu.Lock()
 	defer rc.mu.Unlock()
+	if rc.revokedGrants[grant] {
+		return
+	}
+	if grant != "" && !rc.inGrantScope(grant, host) {
+		log.Warn("refused credential injection outside the grant's scope",
+			"subsystem", "daemon", "run_id", rc.RunID, "grant", grant, "host", host)
+		return
+	}
 	entry := CredentialEntry{Name: headerName, Value: headerValue, Grant: grant}
 	for i, existing := range rc.Credentials[host] {
 		if existing.Grant == grant && existing.Name == headerName {
@@ -185,18 +508,160 @@ func (rc *RunContext) SetCredentialWithGrant(host, headerName, headerValue, gran
 	rc.Credentials[host] = append(rc.Credentials[host], entry)
 }
 
+// RevokeGrants stops every injection registered for any of grants:
+// credential headers, extra headers, token substitutions (and with them the
+// credential files holding their placeholders), response transformers, and
+// the AWS, Azure, and GCP credential endpoints of those grants. Later
+// attempts to configure a revoked grant, such as a token refresh already in
+// flight, are ignored. It returns how many injections were removed.
+func (rc *RunContext) RevokeGrants(grants ...string) int {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	revoked := func(grant string) bool {
+		return grant != "" && slices.Contains(grants, grant)
+	}
+	if rc.revokedGrants == nil {
+		rc.revokedGrants = make(map[string]bool)
+	}
+	for _, g := range grants {
+		if g != "" {
+			rc.revokedGrants[g] = true
+		}
+	}
+
+	removed := 0
+	for host, entries := range rc.Credentials {
+		kept := entries[:0]
+		for _, e := range entries {
+			if revoked(e.Grant) {
+				removed++
+				continue
+			}
+			kept = append(kept, e)
+		}
+		if len(kept) == 0 {
+			delete(rc.Credentials, host)
+		} else {
+			rc.Credentials[host] = kept
+		}
+	}
+	for host, entries := range rc.ExtraHeaders {
+		kept := entries[:0]
+		for _, e := range entries {
+			if revoked(e.Grant) {
+				removed++
+				continue
+			}
+			kept = append(kept, e)
+		}
+		if len(kept) == 0 {
+			delete(rc.ExtraHeaders, host)
+		} else {
+			rc.ExtraHeaders[host] = kept
+		}
+	}
+	for host, ts := range rc.TokenSubstitutions {
+		if revoked(ts.Grant) {
+			delete(rc.TokenSubstitutions, host)
+			removed++
+		}
+	}
+	for host, tfs := range rc.ResponseTransformers {
+		owners := rc.transformerGrants[host]
+		var kept []credential.ResponseTransformer
+		var keptOwners []string
+		for i, tf := range tfs {
+			if i < len(owners) && revoked(owners[i]) {
+				removed++
+				continue
+			}
+			kept = append(kept, tf)
+			if i < len(owners) {
+				keptOwners = append(keptOwners, owners[i])
+			}
+		}
+		if len(kept) == 0 {
+			delete(rc.ResponseTransformers, host)
+			delete(rc.transformerGrants, host)
+		} else {
+			rc.ResponseTransformers[host] = kept
+			rc.transformerGrants[host] = keptOwners
+		}
+	}
+	specs := rc.TransformerSpecs[:0]
+	for _, spec := range rc.TransformerSpecs {
+		if revoked(spec.Grant) {
+			removed++
+			continue
+		}
+		specs = append(specs, spec)
+	}
+	rc.TransformerSpecs = specs
+
+	// Endpoint grants hand out credentials through the run's credential
+	// endpoints rather than injected headers.
+	if slices.Contains(grants, string(credential.ProviderAWS)) && (rc.awsHandler != nil || rc.bedrockHandler != nil) {
+		rc.awsHandler, rc.bedrockHandler, rc.AWSConfig = nil, nil, nil
+		removed++
+	}
+	if slices.Contains(grants, string(credential.ProviderAzure)) && rc.azureHandler != nil {
+		rc.azureHandler, rc.AzureConfig = nil, nil
+		removed++
+	}
+	if slices.Contains(grants, string(credential.ProviderGCP)) && rc.gcpHandler != nil {
+		rc.gcpHandler, rc.GCPConfig = nil, nil
+		removed++
+	}
+	rc.endpoints = rc.combineEndpoints()
+	return removed
+}
+
 // AddExtraHeader implements credential.ProxyConfigurer.
 func (rc *RunContext) AddExtraHeader(host, headerName, headerValue string) {
+	rc.addExtraHeader(host, ExtraHeaderEntry{Name: headerName, Value: headerValue})
+}
+
+func (rc *RunContext) addExtraHeader(host string, e ExtraHeaderEntry) {
 	rc.mu.Lock()
 	defer rc.mu.Unlock()
-	rc.ExtraHeaders[host] = append(rc.ExtraHeaders[host], ExtraHeaderEntry{Name: headerName, Value: headerValue})
+	if rc.revokedGrants[e.Grant] {
+		return
+	}
+	rc.ExtraHeaders[host] = append(rc.ExtraHeaders[host], e)
 }
 
 // AddResponseTransformer implements credential.ProxyConfigurer.
 func (rc *RunContext) AddResponseTransformer(host string, transformer credential.ResponseTransformer) {
+	rc.addResponseTransformer(host, transformer, "")
+}
+
+func (rc *RunContext) addResponseTransformer(host string, transformer credential.ResponseTransformer, grant string) {
 	rc.mu.Lock()
 	defer rc.mu.Unlock()
+	if rc.revokedGrants[grant] {
+		return
+	}
+	if rc.transformerGrants == nil {
+		rc.transformerGrants = make(map[string][]string)
+	}
+	// Keep the grants aligned with the transformers they belong to.
+	owners := rc.transformerGrants[host]
+	for len(owners) < len(rc.ResponseTransformers[host]) {
+		owners = append(owners, "")
+	}
 	rc.ResponseTransformers[host] = append(rc.ResponseTransformers[host], transformer)
+	rc.transformerGrants[host] = append(owners, grant)
+}
+
+// TransformerGrant returns the grant that registered the first response
+// transformer for host, or "".
+func (rc *RunContext) TransformerGrant(host string) string {
+	rc.mu.RLock()
+	defer rc.mu.RUnlock()
+	if owners := rc.transformerGrants[host]; len(owners) > 0 {
+		return owners[0]
+	}
+	return ""
 }
 
 // RemoveRequestHeader implements credential.ProxyConfigurer.
@@ -208,9 +673,21 @@ func (rc *RunContext) RemoveRequestHeader(host, headerName string) {
 
 // SetTokenSubstitution implements credential.ProxyConfigurer.
 func (rc *RunContext) SetTokenSubstitution(host, placeholder, realToken string) {
+	rc.setTokenSubstitution(host, TokenSubstitutionEntry{Placeholder: placeholder, RealToken: realToken})
+}
+
+func (rc *RunContext) setTokenSubstitution(host string, ts TokenSubstitutionEntry) {
 	rc.mu.Lock()
 	defer rc.mu.Unlock()
-	rc.TokenSubstitutions[host] = TokenSubstitutionEntry{Placeholder: placeholder, RealToken: realToken}
+	if rc.revokedGrants[ts.Grant] {
+		return
+	}
+	if ts.Grant != "" && !rc.inGrantScope(ts.Grant, host) {
+		log.Warn("refused token substitution outside the grant's scope",
+			"subsystem", "daemon", "run_id", rc.RunID, "grant", ts.Grant, "host", host)
+		return
+	}
+	rc.TokenSubstitutions[host] = ts
 }
 
 // GetCredential returns the first credential for a host, checking host:port fallback.
@@ -346,30 +823,17 @@ func (rc *RunContext) ToProxyContextData() *proxy.RunContextData {
 		}
 	}
 	for _, spec := range rc.TransformerSpecs {
-		var tf credential.ResponseTransformer
-		switch spec.Kind {
-		case "oauth-endpoint-workaround":
-			tf = newOAuthEndpointTransformer()
-		case "response-scrub":
-			ts, ok := rc.TokenSubstitutions[spec.Host]
-			if !ok {
-				// Fall back to hostname without port (credentials are registered by
-				// hostname only, but spec.Host may include a port).
-				if h, _, _ := net.SplitHostPort(spec.Host); h != "" {
-					ts, ok = rc.TokenSubstitutions[h]
-				}
-			}
-			if ok {
-				tf = newResponseScrubber(ts.RealToken, ts.Placeholder)
-			} else {
-				log.Warn("response-scrub transformer has no matching token substitution",
-					"host", spec.Host, "run_id", rc.RunID)
-			}
-		}
-		if tf != nil {
+		if tf := buildTransformer(rc, spec); tf != nil {
 			d.ResponseTransformers[spec.Host] = append(d.ResponseTransformers[spec.Host], proxy.ResponseTransformer(tf))
 		}
 	}
+	if rc.Scrub != nil {
+		applyScrub(rc.Scrub, rc.scrubHosts(), d.ResponseTransformers)
+	}
+	applyMetering(rc.RunID, d.ResponseTransformers)
+	if len(rc.Faults) > 0 {
+		applyFaults(rc.RunID, rc.Faults, d.ResponseTransformers)
+	}
 
 	// Copy MCP servers, converting from config types to proxy types.
 	if len(rc.MCPServers) > 0 {
@@ -408,8 +872,15 @@ func (rc *RunContext) ToProxyContextData() *proxy.RunContextData {
 			return false
 		}
 		// Also add rule hosts to AllowedHosts for host-level matching.
+		// The proxy admits HTTPS tunnels by exact or wildcard host only, so
+		// CIDR rules contribute the addresses Front admitted instead.
 		for _, hr := range rc.NetworkRules {
-			d.AllowedHosts = append(d.AllowedHosts, proxy.ParseHostPattern(hr.Host))
+			if !netrules.IsCIDRHost(hr.Host) {
+				d.AllowedHosts = append(d.AllowedHosts, proxy.ParseHostPattern(hr.Host))
+			}
+		}
+		for hostPort := range rc.cidrAdmitted {
+			d.AllowedHosts = append(d.AllowedHosts, proxy.ParseHostPattern(hostPort))
 		}
 	} else {
 		// Old CLI: NetworkAllow contains plain host strings.
@@ -418,8 +889,8 @@ func (rc *RunContext) ToProxyContextData() *proxy.RunContextData {
 		}
 	}
 
-	// Include AWS handler if configured.
-	d.AWSHandler = rc.awsHandler
+	// Include credential endpoint handlers (AWS, Azure, GCP) if configured.
+	d.AWSHandler = rc.endpoints
 
 	// Propagate Keep policy engines.
 	d.KeepEngines = rc.KeepEngines
@@ -438,6 +909,51 @@ func (rc *RunContext) ToProxyContextData() *proxy.RunContextData {
 // hostMatchAdapter bridges proxy host pattern matching with the netrules
 // HostMatcher interface. Used to create RequestChecker closures.
 func hostMatchAdapter(pattern, host string, port int) bool {
+	if netrules.IsCIDRHost(pattern) {
+		c, ok := compileCIDRHost(pattern)
+		return ok && c.Match(host, port)
+	}
 	hp := proxy.ParseHostPattern(pattern)
 	return proxy.MatchesHostPattern(hp, host, port)
 }
+
+// cidrHosts caches parsed CIDR network.rules hosts by pattern, so each is
+// parsed once rather than on every request matched against it.
+var cidrHosts sync.Map // string -> cidrHostEntry
+
+type cidrHostEntry struct {
+	host netrules.CIDRHost
+	ok   bool
+}
+
+// compileCIDRHost returns the parsed form of a CIDR network.rules host, and
+// false if it is invalid.
+func compileCIDRHost(pattern string) (netrules.CIDRHost, bool) {
+	if e, ok := cidrHosts.Load(pattern); ok {
+		return e.(cidrHostEntry).host, e.(cidrHostEntry).ok
+	}
+	c, err := netrules.ParseCIDRHost(pattern)
+	cidrHosts.Store(pattern, cidrHostEntry{host: c, ok: err == nil})
+	return c, err == nil
+}
+
+// admitCIDRTunnel notes an HTTPS tunnel to host:port, a raw IP address
+// within one of rc's CIDR rules, so ToProxyContextData lists the address in
+// AllowedHosts and the proxy admits the tunnel. It reports whether the
+// address was admitted. Requests inside the tunnel are still checked
+// against the rules.
+func (rc *RunContext) admitCIDRTunnel(host string, port int) bool {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	for _, hr := range rc.NetworkRules {
+		if !netrules.IsCIDRHost(hr.Host) || !hostMatchAdapter(hr.Host, host, port) {
+			continue
+		}
+		if rc.cidrAdmitted == nil {
+			rc.cidrAdmitted = make(map[string]bool)
+		}
+		rc.cidrAdmitted[net.JoinHostPort(host, strconv.Itoa(port))] = true
+		return true
+	}
+	return false
+}
@@ -1,9 +1,16 @@
 package daemon
 
 import (
+	"github.com/majorcontext/moat/internal/netrules"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/majorcontext/moat/internal/credential"
+	awsprov "github.com/majorcontext/moat/internal/providers/aws"
+	azureprov "github.com/majorcontext/moat/internal/providers/azure"
+	gcpprov "github.com/majorcontext/moat/internal/providers/gcp"
 )
 
 func TestRunContext_ToProxyContextData_HostGateway(t *testing.T) {
@@ -21,6 +28,54 @@ func TestRunContext_ToProxyContextData_HostGateway(t *testing.T) {
 	}
 }
 
+func TestRunContext_ToProxyContextData_CredentialEndpoints(t *testing.T) {
+	handler := func(name string) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(name))
+		})
+	}
+	serve := func(h http.Handler, path string) string {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		return rec.Body.String()
+	}
+
+	rc := NewRunContext("run_endpoints")
+	if d := rc.ToProxyContextData(); d.AWSHandler != nil {
+		t.Fatal("AWSHandler set without any credential endpoint")
+	}
+
+	rc.SetAWSHandler(handler("aws"))
+	rc.SetAzureHandler(handler("azure"))
+	d := rc.ToProxyContextData()
+	if got := serve(d.AWSHandler, "/_aws/credentials"); got != "aws" {
+		t.Errorf("/_aws/credentials served by %q, want aws", got)
+	}
+	if got := serve(d.AWSHandler, azureprov.EndpointPath+"?resource=x"); got != "azure" {
+		t.Errorf("%s served by %q, want azure", azureprov.EndpointPath, got)
+	}
+
+	rc.SetGCPHandler(handler("gcp"))
+	d = rc.ToProxyContextData()
+	for _, path := range []string{gcpprov.EndpointPath, gcpprov.EndpointPath + "/computeMetadata/v1/project/project-id"} {
+		if got := serve(d.AWSHandler, path); got != "gcp" {
+			t.Errorf("%s served by %q, want gcp", path, got)
+		}
+	}
+	if got := serve(d.AWSHandler, "/_aws/credentials"); got != "aws" {
+		t.Errorf("/_aws/credentials served by %q after adding gcp, want aws", got)
+	}
+
+	rc.SetBedrockHandler(handler("bedrock"))
+	d = rc.ToProxyContextData()
+	if got := serve(d.AWSHandler, awsprov.BedrockEndpointPath+"/model/m/invoke"); got != "bedrock" {
+		t.Errorf("bedrock relay served by %q, want bedrock", got)
+	}
+	if got := serve(d.AWSHandler, "/_aws/credentials"); got != "aws" {
+		t.Errorf("/_aws/credentials served by %q after adding bedrock, want aws", got)
+	}
+}
+
 func TestRunContext_ImplementsProxyConfigurer(t *testing.T) {
 	var _ credential.ProxyConfigurer = (*RunContext)(nil)
 }
@@ -78,3 +133,88 @@ func TestRunContext_GetCredentialWithPort(t *testing.T) {
 		t.Errorf("unexpected value: %s", cred.Value)
 	}
 }
+
+func TestAzureClientSecret(t *testing.T) {
+	t.Setenv("MOAT_HOME", t.TempDir())
+	key, err := credential.DefaultEncryptionKey()
+	if err != nil {
+		t.Fatalf("encryption key: %v", err)
+	}
+	store, err := credential.NewFileStore(credential.StoreDirForProfile(""), key)
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	if err := store.Save(credential.Credential{
+		Provider: credential.ProviderAzure,
+		Token:    "sp-secret",
+		Metadata: map[string]string{azureprov.MetaKeyTenant: "t1", azureprov.MetaKeyClientID: "app-1"},
+	}); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	rc := NewRunContext("run_azure_sp")
+	got, err := azureClientSecret(rc, "app-1")
+	if err != nil || got != "sp-secret" {
+		t.Errorf("azureClientSecret = %q, %v; want sp-secret", got, err)
+	}
+	// The grant was replaced with another service principal after the run
+	// registered: refuse rather than serve tokens for a different identity.
+	if _, err := azureClientSecret(rc, "app-2"); err == nil {
+		t.Error("azureClientSecret for a different client ID should fail")
+	}
+}
+
+func TestNewGCPHandlerWithoutGrant(t *testing.T) {
+	t.Setenv("MOAT_HOME", t.TempDir())
+	rc := NewRunContext("run_gcp_missing")
+	h := newGCPHandler(rc, &GCPConfig{Project: "proj"})
+
+	req := httptest.NewRequest("GET", gcpprov.EndpointPath+"/computeMetadata/v1/instance/service-accounts/default/token", nil)
+	req.Header.Set("Metadata-Flavor", "Google")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusInternalServerError || !strings.Contains(rec.Body.String(), "moat grant gcp") {
+		t.Errorf("token without a stored grant = %d %q, want 500 with a re-grant hint", rec.Code, rec.Body.String())
+	}
+}
+
+func TestToProxyContextData_CIDRRules(t *testing.T) {
+	rc := NewRunContext("run_cidr")
+	rc.NetworkPolicy = "strict"
+	rc.NetworkRules = []netrules.HostRules{
+		{Host: "10.1.0.0/30:8080"},
+		{Host: "*.internal.corp"},
+	}
+	d := rc.ToProxyContextData()
+
+	for _, tt := range []struct {
+		host string
+		port int
+		want bool
+	}{
+		{"10.1.0.2", 8080, true},
+		{"10.1.0.9", 8080, false},
+		{"10.1.0.2", 443, false},
+		{"git.internal.corp", 443, true},
+	} {
+		if got := d.RequestCheck(tt.host, tt.port, "GET", "/"); got != tt.want {
+			t.Errorf("RequestCheck(%s:%d) = %v, want %v", tt.host, tt.port, got, tt.want)
+		}
+	}
+
+	// HTTPS tunnels are admitted by AllowedHosts, which lists only the
+	// addresses tunnels were opened to.
+	if len(d.AllowedHosts) != 1 {
+		t.Errorf("AllowedHosts has %d patterns, want the wildcard only", len(d.AllowedHosts))
+	}
+	if rc.admitCIDRTunnel("10.1.0.9", 8080) || rc.admitCIDRTunnel("10.1.0.3", 443) {
+		t.Error("admitted a tunnel outside the CIDR rules")
+	}
+	if !rc.admitCIDRTunnel("10.1.0.3", 8080) {
+		t.Fatal("tunnel within a CIDR rule not admitted")
+	}
+	d = rc.ToProxyContextData()
+	if len(d.AllowedHosts) != 2 || !matchesAny(d.AllowedHosts, "10.1.0.3", 8080) || matchesAny(d.AllowedHosts, "10.1.0.2", 8080) {
+		t.Errorf("AllowedHosts = %+v, want the wildcard and 10.1.0.3:8080", d.AllowedHosts)
+	}
+}
@@ -0,0 +1,343 @@
+package daemon
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/majorcontext/moat/internal/storage"
+)
+
+// APIVersion is the major version of the management API. It changes only
+// for a break the compatibility rules in the package doc forbid; additions
+// are advertised as capabilities instead.
+const APIVersion = 1
+
+// APIVersionHeader carries the major API version a client speaks on
+// requests, and the version the daemon serves on responses. A daemon
+// rejects a request for a version it does not serve; a request without the
+// header is served, so clients that predate versioning keep working.
+const APIVersionHeader = "Moat-Api-Version"
+
+// endpoint describes one route of the management API. The table below is
+// the single source for both the routes the server registers and the
+// OpenAPI document served at GET /v1/openapi.json, so the two cannot drift.
+type endpoint struct {
+	method string
+	path   string // mux pattern; a trailing "/" is followed by param
+	param  string // name of the path parameter, if any
+
+	// operation names the operation in the OpenAPI document. It matches the
+	// Client method that calls the endpoint; TestClient_MatchesEndpoints
+	// checks that the method exists and uses the request and response
+	// types.
+	operation string
+	summary   string
+	query     []queryParam
+
+	request  any // request body type, nil for none
+	response any // response body type, nil for none
+	status   int // success status, default 200
+	stream   bool
+	// capability is advertised by daemons that serve the endpoint, for
+	// endpoints added after the first release of the API.
+	capability string
+
+	handle func(*Server, http.ResponseWriter, *http.Request)
+}
+
+type queryParam struct {
+	name, description string
+	required          bool
+}
+
+var runIDQuery = queryParam{name: "run_id", description: "Limit to this run"}
+
+// endpoints lists the management API. It is filled in by init because the
+// OpenAPI handler itself reads the table.
+var endpoints []endpoint
+
+func init() {
+	endpoints = []endpoint{
+		{method: "GET", path: "/v1/health", operation: "Health", summary: "Daemon status, API version, and capabilities",
+			response: HealthResponse{}, handle: (*Server).handleHealth},
+		{method: "GET", path: "/v1/openapi.json", operation: "OpenAPI", summary: "This API description",
+			response: map[string]any{}, capability: CapOpenAPI, handle: (*Server).handleOpenAPI},
+		{method: "POST", path: "/v1/runs", operation: "RegisterRun", summary: "Register a run and its credentials",
+			request: RegisterRequest{}, response: RegisterResponse{}, status: http.StatusCreated, handle: (*Server).handleRegisterRun},
+		{method: "GET", path: "/v1/runs", operation: "ListRuns", summary: "List registered runs",
+			response: []RunInfo{}, handle: (*Server).handleListRuns},
+		{method: "PATCH", path: "/v1/runs/", param: "token", operation: "UpdateRun", summary: "Set a run's container ID, runtime, and service containers",
+			request: UpdateRunRequest{}, status: http.StatusNoContent, handle: (*Server).handleUpdateRun},
+		{method: "DELETE", path: "/v1/runs/", param: "token", operation: "UnregisterRun", summary: "Unregister a run",
+			status: http.StatusNoContent, handle: (*Server).handleUnregisterRun},
+		{method: "GET", path: "/v1/requests", operation: "StreamRequests", summary: "Stream a run's proxied requests",
+			query:    []queryParam{{name: "run_id", description: "Run to stream", required: true}},
+			response: RequestEvent{}, stream: true, capability: CapRequestStream, handle: (*Server).handleStreamRequests},
+		{method: "GET", path: "/v1/logs", operation: "StreamLogs", summary: "Stream a run's container output",
+			query: []queryParam{
+				{name: "run_id", description: "Run to stream", required: true},
+				{name: "lines", description: "Number of recent lines to send first; 0 for all"},
+				{name: "follow", description: "1 to keep streaming new output until the container exits"},
+			},
+			response: storage.LogEntry{}, stream: true, capability: CapLogStream, handle: (*Server).handleStreamLogs},
+		{method: "GET", path: "/v1/events", operation: "StreamEvents", summary: "Stream run, approval, and clip events",
+			query:    []queryParam{runIDQuery},
+			response: RunEvent{}, stream: true, capability: CapEventStream, handle: (*Server).handleStreamEvents},
+		{method: "POST", path: "/v1/queue", operation: "Enqueue", summary: "Wait for a run slot, then hold it until disconnecting",
+			request: QueueRequest{}, response: QueueStatus{}, stream: true, capability: CapRunQueue, handle: (*Server).handleEnqueue},
+		{method: "GET", path: "/v1/queue", operation: "ListQueue", summary: "List concurrency limits and running and queued run slots",
+			response: QueueInfo{}, capability: CapRunQueue, handle: (*Server).handleListQueue},
+		{method: "PATCH", path: "/v1/queue/", param: "ticket", operation: "UpdateSlot", summary: "Record the run holding a slot",
+			request: QueueSlotUpdate{}, status: http.StatusNoContent, capability: CapRunQueue, handle: (*Server).handleUpdateSlot},
+		{method: "GET", path: "/v1/approvals", operation: "ListApprovals", summary: "List network approvals requested with moatctl",
+			query: []queryParam{runIDQuery}, response: []Approval{}, capability: CapMoatctl, handle: (*Server).handleListApprovals},
+		{method: "POST", path: "/v1/approvals/", param: "id", operation: "DecideApproval", summary: "Approve or deny a network approval",
+			request: ApprovalDecision{}, response: Approval{}, capability: CapMoatctl, handle: (*Server).handleDecideApproval},
+		{method: "GET", path: "/v1/clips", operation: "ListClips", summary: "List clips runs offered with moatctl",
+			query: []queryParam{runIDQuery}, response: []Clip{}, capability: CapClip, handle: (*Server).handleListClips},
+		{method: "POST", path: "/v1/clips", operation: "SendClip", summary: "Send a clip to a run for moatctl paste",
+			request: Clip{}, response: Clip{}, status: http.StatusCreated, capability: CapClip, handle: (*Server).handleSendClip},
+		{method: "POST", path: "/v1/clips/", param: "id", operation: "DecideClip", summary: "Accept or reject a clip a run offered",
+			request: ClipDecision{}, response: Clip{}, capability: CapClip, handle: (*Server).handleDecideClip},
+		{method: "GET", path: "/v1/stats", operation: "Stats", summary: "Sample the resource usage of registered runs and their services",
+			response: []RunStats{}, capability: CapStats, handle: (*Server).handleStats},
+		{method: "GET", path: "/v1/routes", operation: "ListRoutes", summary: "List service routes",
+			response: []RouteInfo{}, capability: CapRouteList, handle: (*Server).handleListRoutes},
+		{method: "POST", path: "/v1/routes/", param: "agent", operation: "RegisterRoutes", summary: "Register an agent's service routes",
+			request: RouteRegistration{}, status: http.StatusNoContent, handle: (*Server).handleRegisterRoutes},
+		{method: "DELETE", path: "/v1/routes/", param: "agent", operation: "UnregisterRoutes", summary: "Remove an agent's service routes",
+			status: http.StatusNoContent, handle: (*Server).handleUnregisterRoutes},
+		{method: "POST", path: "/v1/shutdown", operation: "Shutdown", summary: "Stop the daemon",
+			response: map[string]string{}, handle: (*Server).handleShutdown},
+	}
+}
+
+// withAPIVersion sets the API version header on every response and rejects
+// requests for a version the daemon does not serve.
+func withAPIVersion(next http.Handler) http.Handler {
+	served := strconv.Itoa(APIVersion)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(APIVersionHeader, served)
+		if v := r.Header.Get(APIVersionHeader); v != "" && v != served {
+			writeJSON(w, http.StatusBadRequest, map[string]string{
+				"error": fmt.Sprintf("unsupported API version %q: this daemon serves version %s", v, served),
+			})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// handleOpenAPI serves the OpenAPI document for the API.
+func (s *Server) handleOpenAPI(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(OpenAPI())
+}
+
+// OpenAPI returns an OpenAPI 3.1 document describing the management API,
+// for generating clients in other languages. Schemas are derived from the
+// request and response types.
+func OpenAPI() []byte {
+	g := &schemaGen{defs: make(map[string]any), names: make(map[reflect.Type]string)}
+	paths := make(map[string]map[string]any)
+	for _, e := range endpoints {
+		path := e.path
+		var params []any
+		if e.param != "" {
+			path += "{" + e.param + "}"
+			params = append(params, map[string]any{"name": e.param, "in": "path", "required": true, "schema": map[string]any{"type": "string"}})
+		}
+		for _, q := range e.query {
+			params = append(params, map[string]any{"name": q.name, "in": "query", "required": q.required, "description": q.description, "schema": map[string]any{"type": "string"}})
+		}
+
+		op := map[string]any{
+			"operationId": lowerFirst(e.operation),
+			"summary":     e.summary,
+		}
+		if len(params) > 0 {
+			op["parameters"] = params
+		}
+		if e.capability != "" {
+			op["x-moat-capability"] = e.capability
+		}
+		if e.request != nil {
+			op["requestBody"] = map[string]any{
+				"required": true,
+				"content":  map[string]any{"application/json": map[string]any{"schema": g.schema(reflect.TypeOf(e.request))}},
+			}
+		}
+
+		status := e.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		ok := map[string]any{"description": http.StatusText(status)}
+		if e.response != nil {
+			mediaType := "application/json"
+			if e.stream {
+				mediaType = "application/x-ndjson"
+				ok["description"] = "Newline-delimited JSON, one object per line, until the client disconnects or the stream ends"
+			}
+			ok["content"] = map[string]any{mediaType: map[string]any{"schema": g.schema(reflect.TypeOf(e.response))}}
+		}
+		op["responses"] = map[string]any{
+			strconv.Itoa(status): ok,
+			"default": map[string]any{
+				"description": "Error",
+				"content":     map[string]any{"application/json": map[string]any{"schema": map[string]any{"$ref": "#/components/schemas/Error"}}},
+			},
+		}
+
+		if paths[path] == nil {
+			paths[path] = make(map[string]any)
+		}
+		paths[path][strings.ToLower(e.method)] = op
+	}
+	g.defs["Error"] = map[string]any{
+		"type":       "object",
+		"properties": map[string]any{"error": map[string]any{"type": "string"}},
+	}
+
+	doc := map[string]any{
+		"openapi": "3.1.0",
+		"info": map[string]any{
+			"title":   "moat daemon API",
+			"version": strconv.Itoa(APIVersion),
+			"description": "The management API of the moat proxy daemon, served over the Unix socket " +
+				"~/.moat/proxy/daemon.sock. Send the " + APIVersionHeader + " header with the major version. " +
+				"Endpoints with x-moat-capability exist only on daemons that list that capability in GET /v1/health.",
+		},
+		"servers":    []any{map[string]any{"url": "http://daemon"}},
+		"paths":      paths,
+		"components": map[string]any{"schemas": g.defs},
+	}
+	data, _ := json.MarshalIndent(doc, "", "  ")
+	return data
+}
+
+func lowerFirst(s string) string {
+	if s == "" {
+		return s
+	}
+	r := []rune(s)
+	r[0] = unicode.ToLower(r[0])
+	return string(r)
+}
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+)
+
+// schemaGen derives JSON Schemas from Go types the way encoding/json
+// encodes them. Named structs become shared component schemas.
+type schemaGen struct {
+	defs  map[string]any
+	names map[reflect.Type]string
+}
+
+func (g *schemaGen) schema(t reflect.Type) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch {
+	case t == timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case t.Implements(jsonMarshalerType) || reflect.PointerTo(t).Implements(jsonMarshalerType):
+		// Custom encoding; the Go type says nothing reliable about it.
+		return map[string]any{}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string", "contentEncoding": "base64"}
+		}
+		return map[string]any{"type": "array", "items": g.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": g.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.object(t)
+		}
+		return map[string]any{"$ref": "#/components/schemas/" + g.define(t)}
+	}
+	return map[string]any{}
+}
+
+// define adds the component schema for the named struct t and returns its
+// name.
+func (g *schemaGen) define(t reflect.Type) string {
+	if name, ok := g.names[t]; ok {
+		return name
+	}
+	name := t.Name()
+	if _, taken := g.defs[name]; taken {
+		// Same name in another package.
+		pkg := t.PkgPath()
+		name = strings.ToUpper(pkg[strings.LastIndex(pkg, "/")+1:][:1]) + pkg[strings.LastIndex(pkg, "/")+2:] + name
+	}
+	g.names[t] = name
+	g.defs[name] = map[string]any{} // placeholder for recursive types
+	g.defs[name] = g.object(t)
+	return name
+}
+
+// object returns the schema of struct t's JSON object.
+func (g *schemaGen) object(t reflect.Type) map[string]any {
+	props := make(map[string]any)
+	var required []string
+	g.fields(t, props, &required)
+	s := map[string]any{"type": "object", "properties": props}
+	if len(required) > 0 {
+		s["required"] = required
+	}
+	return s
+}
+
+func (g *schemaGen) fields(t reflect.Type, props map[string]any, required *[]string) {
+	for i := range t.NumField() {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				g.fields(ft, props, required)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		props[name] = g.schema(f.Type)
+		if !strings.Contains(opts, "omitempty") && !strings.Contains(opts, "omitzero") {
+			*required = append(*required, name)
+		}
+	}
+}
@@ -0,0 +1,287 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOpenAPI_CoversEndpoints(t *testing.T) {
+	var doc struct {
+		OpenAPI string                               `json:"openapi"`
+		Paths   map[string]map[string]map[string]any `json:"paths"`
+	}
+	if err := json.Unmarshal(OpenAPI(), &doc); err != nil {
+		t.Fatalf("OpenAPI() is not JSON: %v", err)
+	}
+	if doc.OpenAPI != "3.1.0" {
+		t.Errorf("openapi = %q, want 3.1.0", doc.OpenAPI)
+	}
+
+	client := reflect.TypeOf(&Client{})
+	for _, e := range endpoints {
+		path := e.path
+		if e.param != "" {
+			path += "{" + e.param + "}"
+		}
+		op, ok := doc.Paths[path][strings.ToLower(e.method)]
+		if !ok {
+			t.Errorf("%s %s missing from the document", e.method, path)
+			continue
+		}
+		if op["operationId"] == "" {
+			t.Errorf("%s %s has no operationId", e.method, path)
+		}
+		// The document is only as good as the client it describes: every
+		// operation must be callable from Go.
+		if _, ok := client.MethodByName(e.operation); !ok {
+			t.Errorf("%s %s: Client has no method %s", e.method, path, e.operation)
+		}
+		if e.handle == nil {
+			t.Errorf("%s %s has no handler", e.method, path)
+		}
+	}
+}
+
+// TestClient_MatchesEndpoints keeps the hand-written Client in step with the
+// endpoints table: each operation has a Client method of the same name that
+// takes the path parameter and the request body type, and returns (or, for
+// streams, passes to its callback) the response body type.
+func TestClient_MatchesEndpoints(t *testing.T) {
+	// Methods that build the request body from plain arguments, or do not
+	// return the response body.
+	builtRequest := map[string]bool{"DecideApproval": true, "DecideClip": true, "RegisterRoutes": true}
+	droppedResponse := map[string]bool{"OpenAPI": true, "Shutdown": true}
+
+	errType := reflect.TypeOf((*error)(nil)).Elem()
+	ctxType := reflect.TypeOf((*context.Context)(nil)).Elem()
+	client := reflect.TypeOf(&Client{})
+	for _, e := range endpoints {
+		m, ok := client.MethodByName(e.operation)
+		if !ok {
+			t.Errorf("%s %s: no Client.%s method", e.method, e.path, e.operation)
+			continue
+		}
+		var in, out []reflect.Type
+		for i := 1; i < m.Type.NumIn(); i++ { // skip the receiver
+			in = append(in, m.Type.In(i))
+		}
+		for i := 0; i < m.Type.NumOut(); i++ {
+			out = append(out, m.Type.Out(i))
+		}
+		if len(in) == 0 || in[0] != ctxType {
+			t.Errorf("Client.%s: first parameter is not a context.Context", e.operation)
+			continue
+		}
+		if len(out) == 0 || out[len(out)-1] != errType {
+			t.Errorf("Client.%s: does not return an error last", e.operation)
+			continue
+		}
+		if e.param != "" && (len(in) < 2 || in[1].Kind() != reflect.String) {
+			t.Errorf("Client.%s: takes no string %s parameter", e.operation, e.param)
+		}
+		if e.request != nil && !builtRequest[e.operation] && !slices.Contains(in, reflect.TypeOf(e.request)) {
+			t.Errorf("Client.%s: takes no %T request body", e.operation, e.request)
+		}
+		if e.response == nil || droppedResponse[e.operation] {
+			continue
+		}
+		resp := reflect.TypeOf(e.response)
+		if e.stream {
+			if !slices.Contains(in, reflect.FuncOf([]reflect.Type{resp}, nil, false)) {
+				t.Errorf("Client.%s: takes no func(%s) callback", e.operation, resp)
+			}
+			continue
+		}
+		if len(out) != 2 || (out[0] != resp && out[0] != reflect.PointerTo(resp)) {
+			t.Errorf("Client.%s: returns %v, want (%s, error)", e.operation, out, resp)
+		}
+	}
+
+	// The exceptions must still name endpoints.
+	for _, names := range []map[string]bool{builtRequest, droppedResponse} {
+		for name := range names {
+			if !slices.ContainsFunc(endpoints, func(e endpoint) bool { return e.operation == name }) {
+				t.Errorf("exception %s names no endpoint", name)
+			}
+		}
+	}
+}
+
+func TestOpenAPI_Schemas(t *testing.T) {
+	var doc struct {
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]map[string]any `json:"properties"`
+				Required   []string                  `json:"required"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(OpenAPI(), &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	clip, ok := doc.Components.Schemas["Clip"]
+	if !ok {
+		t.Fatal("no Clip schema")
+	}
+	if got := clip.Properties["data"]["contentEncoding"]; got != "base64" {
+		t.Errorf("Clip.data contentEncoding = %v, want base64", got)
+	}
+	if got := clip.Properties["created_at"]["format"]; got != "date-time" {
+		t.Errorf("Clip.created_at format = %v, want date-time", got)
+	}
+	if got := clip.Properties["name"]; got == nil {
+		t.Error("Clip.name missing")
+	}
+	for _, name := range clip.Required {
+		if name == "name" {
+			t.Error("Clip.name (omitempty) is required")
+		}
+	}
+
+	// RequestEvent embeds storage.Decision; its fields are inlined.
+	ev := doc.Components.Schemas["RequestEvent"]
+	if ev.Properties["run_id"] == nil || ev.Properties["host"] == nil {
+		t.Errorf("RequestEvent properties = %v, want run_id and the Decision fields", ev.Properties)
+	}
+}
+
+func TestServer_APIVersionHeader(t *testing.T) {
+	sock := filepath.Join(testSockDir(t), "d.sock")
+	srv := NewServer(sock, 9119)
+	if err := srv.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer srv.Stop(context.Background())
+	client := testClient(sock)
+
+	for _, tc := range []struct {
+		version string
+		want    int
+	}{
+		{"", http.StatusOK}, // clients that predate versioning
+		{strconv.Itoa(APIVersion), http.StatusOK},
+		{strconv.Itoa(APIVersion + 1), http.StatusBadRequest},
+	} {
+		req, _ := http.NewRequest(http.MethodGet, "http://localhost/v1/health", nil)
+		if tc.version != "" {
+			req.Header.Set(APIVersionHeader, tc.version)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tc.want {
+			t.Errorf("version %q: status = %d, want %d", tc.version, resp.StatusCode, tc.want)
+		}
+		if got := resp.Header.Get(APIVersionHeader); got != strconv.Itoa(APIVersion) {
+			t.Errorf("version %q: response %s = %q, want %d", tc.version, APIVersionHeader, got, APIVersion)
+		}
+	}
+
+	health, err := NewClient(sock).Health(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !health.ServesAPIVersion(APIVersion) {
+		t.Errorf("daemon api_version = %d, want %d", health.APIVersion, APIVersion)
+	}
+}
+
+func TestHealthResponse_ServesAPIVersion(t *testing.T) {
+	if !(&HealthResponse{}).ServesAPIVersion(1) {
+		t.Error("unversioned daemon should serve version 1")
+	}
+	if (&HealthResponse{}).ServesAPIVersion(2) {
+		t.Error("unversioned daemon should not serve version 2")
+	}
+	if (&HealthResponse{APIVersion: 2}).ServesAPIVersion(1) {
+		t.Error("version 2 daemon should not serve version 1")
+	}
+}
+
+func TestServer_RunEvents(t *testing.T) {
+	sock := filepath.Join(testSockDir(t), "d.sock")
+	srv := NewServer(sock, 9119)
+	if err := srv.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer srv.Stop(context.Background())
+
+	events, unsubscribe := srv.RunEvents().Subscribe("")
+	defer unsubscribe()
+
+	client := NewClient(sock)
+	resp, err := client.RegisterRun(context.Background(), RegisterRequest{RunID: "run_events"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.UnregisterRun(context.Background(), resp.AuthToken); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{EventRunRegistered, EventRunUnregistered} {
+		select {
+		case ev := <-events:
+			if ev.Type != want || ev.RunID != "run_events" {
+				t.Errorf("event = %s %s, want %s run_events", ev.Type, ev.RunID, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for %s", want)
+		}
+	}
+}
+
+func TestClient_StreamEvents(t *testing.T) {
+	sock := filepath.Join(testSockDir(t), "d.sock")
+	srv := NewServer(sock, 9119)
+	if err := srv.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer srv.Stop(context.Background())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	got := make(chan RunEvent, 1)
+	done := make(chan error, 1)
+	go func() {
+		done <- NewClient(sock).StreamEvents(ctx, "run_a", func(ev RunEvent) {
+			select {
+			case got <- ev:
+			default:
+			}
+		})
+	}()
+
+	// Publish until the subscriber is attached and receives an event.
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	deadline := time.After(5 * time.Second)
+	for received := false; !received; {
+		select {
+		case <-ticker.C:
+			srv.RunEvents().Publish(RunEvent{Type: EventRunRegistered, RunID: "run_b"})
+			srv.RunEvents().Publish(RunEvent{Type: EventApprovalRequested, RunID: "run_a", Approval: &Approval{Host: "example.com"}})
+		case ev := <-got:
+			if ev.RunID != "run_a" || ev.Type != EventApprovalRequested || ev.Approval == nil || ev.Approval.Host != "example.com" {
+				t.Fatalf("event = %+v, want run_a approval.requested for example.com", ev)
+			}
+			received = true
+		case <-deadline:
+			t.Fatal("timed out waiting for streamed event")
+		}
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("StreamEvents after cancel = %v, want nil", err)
+	}
+}
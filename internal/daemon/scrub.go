@@ -0,0 +1,98 @@
+package daemon
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/majorcontext/gatekeeper/proxy"
+	"github.com/majorcontext/moat/internal/config"
+	"github.com/majorcontext/moat/internal/log"
+)
+
+// scrubKeptHeaders are kept by a header allowlist whatever it lists: HTTP
+// needs them to deliver the body, and clients need them to read it.
+var scrubKeptHeaders = []string{
+	"Content-Type",
+	"Content-Length",
+	"Content-Encoding",
+	"Content-Range",
+	"Transfer-Encoding",
+	"Trailer",
+	"Date",
+	"Location",
+	"Retry-After",
+}
+
+// scrubHosts returns the hosts a scrub policy applies to: every host the
+// run injects a credential or substitutes a token for, plus the policy's
+// own hosts. Callers hold rc.mu.
+func (rc *RunContext) scrubHosts() []string {
+	seen := make(map[string]bool)
+	var hosts []string
+	add := func(host string) {
+		host = strings.ToLower(host)
+		// Per-run entries are matched exactly, so a wildcard never
+		// carries a credential.
+		if !seen[host] && !strings.Contains(host, "*") {
+			seen[host] = true
+			hosts = append(hosts, host)
+		}
+	}
+	for host := range rc.Credentials {
+		add(host)
+	}
+	for host := range rc.TokenSubstitutions {
+		add(host)
+	}
+	for _, host := range rc.Scrub.Hosts {
+		add(host)
+	}
+	sort.Strings(hosts)
+	return hosts
+}
+
+// applyScrub wraps the response transformers of each host, so headers are
+// scrubbed from the response the other transformers produce.
+func applyScrub(policy *config.ScrubConfig, hosts []string, transformers map[string][]proxy.ResponseTransformer) {
+	for _, host := range hosts {
+		transformers[host] = []proxy.ResponseTransformer{newScrubTransformer(host, policy, transformers[host])}
+	}
+}
+
+// newScrubTransformer returns a response transformer that runs next and
+// then strips Set-Cookie and, with a header allowlist, every header not on
+// it. Moat's own X-Moat-* headers are always kept.
+func newScrubTransformer(host string, policy *config.ScrubConfig, next []proxy.ResponseTransformer) proxy.ResponseTransformer {
+	var allow map[string]bool
+	if len(policy.Headers) > 0 {
+		allow = make(map[string]bool, len(policy.Headers)+len(scrubKeptHeaders))
+		for _, name := range append(append([]string(nil), scrubKeptHeaders...), policy.Headers...) {
+			allow[http.CanonicalHeaderKey(name)] = true
+		}
+	}
+	return func(reqI, respI any) (any, bool) {
+		out, changed := runTransformers(next, reqI, respI)
+		resp, ok := out.(*http.Response)
+		if !ok || resp.Header == nil {
+			return out, changed
+		}
+		var removed []string
+		for name := range resp.Header {
+			drop := policy.Cookies && name == "Set-Cookie"
+			if allow != nil && !allow[name] && !strings.HasPrefix(name, "X-Moat-") {
+				drop = true
+			}
+			if drop {
+				resp.Header.Del(name)
+				removed = append(removed, name)
+			}
+		}
+		if len(removed) == 0 {
+			return out, changed
+		}
+		sort.Strings(removed)
+		log.Debug("scrubbed response headers", "subsystem", "daemon", "host", host, "headers", removed)
+		return resp, true
+	}
+}
@@ -0,0 +1,73 @@
+package daemon
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/majorcontext/moat/internal/config"
+)
+
+func scrubResponse() *http.Response {
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header: http.Header{
+			"Content-Type":   {"application/json"},
+			"Set-Cookie":     {"session=upstream-secret; HttpOnly"},
+			"X-Request-Id":   {"req_1"},
+			"X-Internal-Ref": {"node-7"},
+		},
+		Body: io.NopCloser(strings.NewReader(`{}`)),
+	}
+}
+
+func TestScrubCookiesOnCredentialedHosts(t *testing.T) {
+	rc := NewRunContext("run_scrub")
+	rc.SetCredentialWithGrant("api.example.com", "Authorization", "Bearer real", "example")
+	rc.SetTokenSubstitution("auth.example.com", "moat-placeholder", "real")
+	rc.Scrub = &config.ScrubConfig{Cookies: true, Hosts: []string{"extra.example.com"}}
+
+	d := rc.ToProxyContextData()
+	for _, host := range []string{"api.example.com", "auth.example.com", "extra.example.com"} {
+		tfs := d.ResponseTransformers[host]
+		if len(tfs) != 1 {
+			t.Fatalf("%s: got %d transformers, want 1", host, len(tfs))
+		}
+		out, changed := tfs[0](&http.Request{}, scrubResponse())
+		resp := out.(*http.Response)
+		if !changed || resp.Header.Get("Set-Cookie") != "" {
+			t.Errorf("%s: Set-Cookie = %q (changed=%v), want it stripped", host, resp.Header.Get("Set-Cookie"), changed)
+		}
+		if resp.Header.Get("X-Internal-Ref") == "" {
+			t.Errorf("%s: X-Internal-Ref stripped without a header allowlist", host)
+		}
+	}
+	if _, ok := d.ResponseTransformers["other.example.com"]; ok {
+		t.Error("uncredentialed host got a scrub transformer")
+	}
+}
+
+func TestScrubHeaderAllowlist(t *testing.T) {
+	rc := NewRunContext("run_scrub")
+	rc.SetCredentialWithGrant("api.example.com", "Authorization", "Bearer real", "example")
+	rc.AddResponseTransformer("api.example.com", newResponseHeaderTransformer("X-Moat-Transformed", "1", false))
+	rc.Scrub = &config.ScrubConfig{Headers: []string{"x-request-id"}}
+
+	out, changed := rc.ToProxyContextData().ResponseTransformers["api.example.com"][0](&http.Request{}, scrubResponse())
+	resp := out.(*http.Response)
+	if !changed {
+		t.Fatal("changed = false, want headers scrubbed")
+	}
+	for _, name := range []string{"Content-Type", "X-Request-Id", "X-Moat-Transformed"} {
+		if resp.Header.Get(name) == "" {
+			t.Errorf("%s stripped, want it kept", name)
+		}
+	}
+	// The allowlist alone strips cookies too.
+	for _, name := range []string{"Set-Cookie", "X-Internal-Ref"} {
+		if resp.Header.Get(name) != "" {
+			t.Errorf("%s = %q, want it stripped", name, resp.Header.Get(name))
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package daemon
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/majorcontext/gatekeeper/proxy"
+	"github.com/majorcontext/moat/internal/audit"
+	"github.com/majorcontext/moat/internal/netrules"
+)
+
+// SendRoute identifies requests that deliver a message to a third party,
+// attributed to the grant whose provider declared it.
+type SendRoute struct {
+	Grant  string `json:"grant"`
+	Host   string `json:"host"`
+	Method string `json:"method"`
+	Path   string `json:"path"` // netrules path pattern
+}
+
+// SendGuard caps how many messages a run may send through messaging grants
+// (Twilio SMS and calls, SendGrid email). The daemon counts requests matching
+// Routes before forwarding them and denies them once Max have been allowed.
+type SendGuard struct {
+	Max    int         `json:"max"`
+	Routes []SendRoute `json:"routes"`
+	Sent   int         `json:"sent,omitempty"` // sends allowed so far
+
+	mu sync.Mutex
+}
+
+// Match returns the route a request matches, or nil. The query string is
+// ignored.
+func (g *SendGuard) Match(host string, port int, method, path string) *SendRoute {
+	if i := strings.IndexByte(path, '?'); i != -1 {
+		path = path[:i]
+	}
+	for i := range g.Routes {
+		r := &g.Routes[i]
+		if strings.EqualFold(r.Method, method) && hostMatchAdapter(r.Host, host, port) && netrules.MatchPath(r.Path, path) {
+			return r
+		}
+	}
+	return nil
+}
+
+// check counts a send and denies it once the cap is reached. It returns
+// a func that gives the count back, for a send the proxy then refused, or
+// nil if the request is not a send or was denied.
+func (g *SendGuard) check(host string, port int, method, path string) (func(), *denial) {
+	route := g.Match(host, port, method, path)
+	if route == nil {
+		return nil, nil
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.Sent >= g.Max {
+		return nil, &denial{
+			kind:    "send-limit",
+			status:  http.StatusForbidden,
+			reason:  fmt.Sprintf("Messaging send limit reached: %d sends", g.Max),
+			message: fmt.Sprintf("this run has sent its %d messages (messaging.max_messages)", g.Max),
+		}
+	}
+	g.Sent++
+	return func() {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		g.Sent--
+	}, nil
+}
+
+// Count returns the number of sends allowed so far.
+func (g *SendGuard) Count() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.Sent
+}
+
+// snapshot returns a copy of g safe to serialize.
+func (g *SendGuard) snapshot() *SendGuard {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return &SendGuard{Max: g.Max, Routes: g.Routes, Sent: g.Sent}
+}
+
+// matchesAny reports whether host:port matches any of patterns.
+func matchesAny(patterns []proxy.HostPattern, host string, port int) bool {
+	for _, p := range patterns {
+		if proxy.MatchesHostPattern(p, host, port) {
+			return true
+		}
+	}
+	return false
+}
+
+// NewMessageData builds the audit entry for a proxied request if it matches
+// one of rc's send routes.
+func NewMessageData(rc *RunContext, data proxy.RequestLogData) (audit.MessageData, bool) {
+	if rc == nil {
+		return audit.MessageData{}, false
+	}
+	rc.mu.RLock()
+	guard := rc.SendGuard
+	rc.mu.RUnlock()
+	if guard == nil {
+		return audit.MessageData{}, false
+	}
+	host, port := decisionHostPort(data)
+	route := guard.Match(host, port, data.Method, data.Path)
+	if route == nil {
+		return audit.MessageData{}, false
+	}
+	decision := "allow"
+	if data.Denied {
+		decision = "deny"
+	}
+	return audit.MessageData{
+		Grant:      route.Grant,
+		Method:     data.Method,
+		URL:        data.URL,
+		Decision:   decision,
+		StatusCode: data.StatusCode,
+		Sent:       guard.Count(),
+		Limit:      guard.Max,
+	}, true
+}
@@ -0,0 +1,94 @@
+package daemon
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/majorcontext/gatekeeper/proxy"
+)
+
+func newSendGuard(max int) *SendGuard {
+	return &SendGuard{Max: max, Routes: []SendRoute{
+		{Grant: "twilio", Host: "api.twilio.com", Method: "POST", Path: "/2010-04-01/Accounts/*/Messages.json"},
+		{Grant: "sendgrid", Host: "api.sendgrid.com", Method: "POST", Path: "/v3/mail/send"},
+	}}
+}
+
+// checkSend runs rc's checks on a request to host:443.
+func checkSend(rc *RunContext, host, method, target string) (admission, *denial) {
+	return rc.checkRequest(httptest.NewRequest(method, "https://"+host+target, nil), host, 443)
+}
+
+func TestSendGuard_CapsSends(t *testing.T) {
+	rc := NewRunContext("run_test")
+	rc.SendGuard = newSendGuard(2)
+	if !rc.guardsHost("api.twilio.com", 443) || rc.guardsHost("example.com", 443) {
+		t.Fatal("guardsHost does not follow the send routes")
+	}
+
+	sms := "/2010-04-01/Accounts/AC1/Messages.json"
+	if _, d := checkSend(rc, "api.twilio.com", "POST", sms); d != nil {
+		t.Fatalf("send under the cap was denied: %+v", d)
+	}
+	if _, d := checkSend(rc, "api.sendgrid.com", "POST", "/v3/mail/send?x=1"); d != nil {
+		t.Fatalf("send under the cap was denied: %+v", d)
+	}
+	_, d := checkSend(rc, "api.twilio.com", "POST", sms)
+	if d == nil {
+		t.Fatal("send past the cap was allowed")
+	}
+	if d.kind != "send-limit" || d.status != http.StatusForbidden || d.reason != "Messaging send limit reached: 2 sends" {
+		t.Errorf("denial = %+v", d)
+	}
+	// Non-send requests are unaffected, including to the same hosts.
+	if _, d := checkSend(rc, "api.twilio.com", "GET", sms); d != nil {
+		t.Error("non-send request denied after the cap")
+	}
+	if got := rc.SendGuard.Count(); got != 2 {
+		t.Errorf("Count() = %d, want 2", got)
+	}
+}
+
+func TestSendGuard_UndoesRefusedSends(t *testing.T) {
+	rc := NewRunContext("run_test")
+	rc.SendGuard = newSendGuard(1)
+	adm, d := checkSend(rc, "api.sendgrid.com", "POST", "/v3/mail/send")
+	if d != nil {
+		t.Fatalf("send under the cap was denied: %+v", d)
+	}
+	adm.undo()
+	if got := rc.SendGuard.Count(); got != 0 {
+		t.Errorf("Count() = %d after undo, want 0", got)
+	}
+	if _, d := checkSend(rc, "api.sendgrid.com", "POST", "/v3/mail/send"); d != nil {
+		t.Error("send after an undone send was denied")
+	}
+}
+
+func TestNewMessageData(t *testing.T) {
+	rc := NewRunContext("run_test")
+	rc.SendGuard = newSendGuard(3)
+	rc.SendGuard.Sent = 3
+
+	msg, ok := NewMessageData(rc, proxy.RequestLogData{
+		Method: "POST",
+		URL:    "https://api.sendgrid.com/v3/mail/send",
+		Host:   "api.sendgrid.com",
+		Path:   "/v3/mail/send",
+		Denied: true,
+	})
+	if !ok {
+		t.Fatal("NewMessageData did not match a send")
+	}
+	if msg.Grant != "sendgrid" || msg.Decision != "deny" || msg.Sent != 3 || msg.Limit != 3 {
+		t.Errorf("MessageData = %+v", msg)
+	}
+
+	if _, ok := NewMessageData(rc, proxy.RequestLogData{Method: "GET", URL: "https://api.sendgrid.com/v3/scopes", Host: "api.sendgrid.com", Path: "/v3/scopes"}); ok {
+		t.Error("NewMessageData matched a non-send request")
+	}
+	if _, ok := NewMessageData(NewRunContext("run_other"), proxy.RequestLogData{Method: "POST", Host: "api.sendgrid.com", Path: "/v3/mail/send"}); ok {
+		t.Error("NewMessageData matched a run without a send guard")
+	}
+}
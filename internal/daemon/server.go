@@ -3,10 +3,12 @@ package daemon
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net"
 	"net/http"
 	"os"
+	"slices"
 	"strings"
 	"time"
 
@@ -33,6 +35,11 @@ type Server struct {
 	listener     net.Listener
 	startedAt    time.Time
 	persister    *RunPersister
+	events       *RequestEvents
+	runEvents    *RunEvents
+	scheduler    *Scheduler
+	stats        *StatsSampler
+	runsDir      string             // run storage directory, for logs.jsonl
 	onRegister   func()             // called when a new run is registered
 	onEmpty      func()             // called when last run is unregistered
 	onUnregister func(runID string) // called when a run is unregistered (for resource cleanup)
@@ -41,10 +48,15 @@ type Server struct {
 
 // NewServer creates a daemon API server that will listen on the given Unix socket path.
 func NewServer(sockPath string, proxyPort int) *Server {
+	registry := NewRegistry()
 	s := &Server{
 		sockPath:  sockPath,
 		proxyPort: proxyPort,
-		registry:  NewRegistry(),
+		registry:  registry,
+		stats:     NewStatsSampler(registry),
+		events:    NewRequestEvents(),
+		runEvents: NewRunEvents(),
+		scheduler: NewScheduler(),
 		startedAt: time.Now(),
 	}
 
@@ -55,17 +67,13 @@ func NewServer(sockPath string, proxyPort int) *Server {
 	// so older daemons must handle requests from newer CLIs and vice versa.
 	// See the package doc comment in api.go for the compatibility rules.
 	mux := http.NewServeMux()
-	mux.HandleFunc("GET /v1/health", s.handleHealth)
-	mux.HandleFunc("POST /v1/runs", s.handleRegisterRun)
-	mux.HandleFunc("GET /v1/runs", s.handleListRuns)
-	mux.HandleFunc("PATCH /v1/runs/", s.handleUpdateRun)
-	mux.HandleFunc("DELETE /v1/runs/", s.handleUnregisterRun)
-	mux.HandleFunc("POST /v1/routes/", s.handleRegisterRoutes)
-	mux.HandleFunc("DELETE /v1/routes/", s.handleUnregisterRoutes)
-	mux.HandleFunc("POST /v1/shutdown", s.handleShutdown)
+	for _, e := range endpoints {
+		handle := e.handle
+		mux.HandleFunc(e.method+" "+e.path, func(w http.ResponseWriter, r *http.Request) { handle(s, w, r) })
+	}
 
 	s.server = &http.Server{
-		Handler:           mux,
+		Handler:           withAPIVersion(mux),
 		ReadHeaderTimeout: 10 * time.Second,
 		WriteTimeout:      30 * time.Second,
 		IdleTimeout:       120 * time.Second,
@@ -73,6 +81,11 @@ func NewServer(sockPath string, proxyPort int) *Server {
 	return s
 }
 
+// Scheduler returns the scheduler that hands out run slots.
+func (s *Server) Scheduler() *Scheduler {
+	return s.scheduler
+}
+
 // SetProxyPort updates the proxy port reported in API responses.
 // Call after the credential proxy starts to set the actual port.
 func (s *Server) SetProxyPort(port int) { s.proxyPort = port }
@@ -80,6 +93,12 @@ func (s *Server) SetProxyPort(port int) { s.proxyPort = port }
 // Registry returns the server's run registry.
 func (s *Server) Registry() *Registry { return s.registry }
 
+// Events returns the hub that streams proxied requests to subscribers.
+func (s *Server) Events() *RequestEvents { return s.events }
+
+// RunEvents returns the hub that streams run, approval, and clip events.
+func (s *Server) RunEvents() *RunEvents { return s.runEvents }
+
 // SetOnRegister sets a callback invoked when a new run is registered.
 func (s *Server) SetOnRegister(fn func()) { s.onRegister = fn }
 
@@ -97,6 +116,10 @@ func (s *Server) SetOnShutdown(fn func()) { s.onShutdown = fn }
 // SetPersister sets the run persister for saving registry state to disk.
 func (s *Server) SetPersister(p *RunPersister) { s.persister = p }
 
+// SetRunsDir sets the run storage directory, from which the log stream
+// reads logs.jsonl once a run's container has stopped.
+func (s *Server) SetRunsDir(dir string) { s.runsDir = dir }
+
 // SetRoutes sets the route table used for route registration handlers.
 func (s *Server) SetRoutes(rt *routing.RouteTable) { s.routes = rt }
 
@@ -127,7 +150,11 @@ func (s *Server) handleHealth(w http.ResponseWriter, _ *http.Request) {
 		RunCount:     s.registry.Count(),
 		StartedAt:    s.startedAt.Format(time.RFC3339),
 		Commit:       BuildCommit,
-		Capabilities: []string{CapKeepPolicy, CapKeepBodyPolicy, CapHostGatewayV2},
+		Capabilities: []string{CapKeepPolicy, CapKeepBodyPolicy, CapHostGatewayV2, CapRequestMirror, CapTransformers, CapRequestStream, CapLogStream, CapNetworkCIDR, CapRouteList, CapMoatctl, CapClip, CapAzureIdentity, CapStripeLiveMode, CapSendGuard, CapFaults, CapAzureServicePrincipal, CapGCPMetadata, CapClaudeCloud, CapOpenAPI, CapEventStream, CapRunQueue, CapResponseScrub, CapUploadGuard, CapNetworkCapture, CapStats, CapRequestGuards, CapMirrorReplay, CapRequestTransforms, CapGrantScope, CapStarlarkTransforms},
+		APIVersion:   APIVersion,
+	}
+	if qt := currentQuotaTracker(); qt != nil {
+		resp.Quotas = qt.Status()
 	}
 	writeJSON(w, http.StatusOK, resp)
 }
@@ -152,6 +179,14 @@ func (s *Server) handleRegisterRun(w http.ResponseWriter, r *http.Request) {
 
 	rc := req.ToRunContext()
 
+	if err := checkGrantRestrictions(rc); err != nil {
+		log.Warn("refusing run registration", "run_id", rc.RunID, "error", err)
+		writeJSON(w, http.StatusForbidden, RegisterResponse{Error: err.Error()})
+		return
+	}
+
+	rc.scopeGrants()
+
 	// On Linux with Docker host networking, the host gateway is 127.0.0.1 and
 	// the proxy also listens on 127.0.0.1. Implicitly allow the proxy port so
 	// the proxy does not block its own traffic.
@@ -258,8 +293,21 @@ func (s *Server) handleRegisterRun(w http.ResponseWriter, r *http.Request) {
 		} else {
 			awsProvider.SetAuthToken(token)
 			rc.SetAWSHandler(awsProvider.Handler())
+			if req.AWSConfig.Bedrock {
+				rc.SetBedrockHandler(awsprov.NewBedrockHandler(awsProvider.GetCredentials, req.AWSConfig.Region))
+			}
 		}
 	}
+	if req.AzureConfig != nil {
+		rc.SetAzureHandler(newAzureHandler(rc, req.AzureConfig, token))
+	}
+	if req.GCPConfig != nil {
+		rc.SetGCPHandler(newGCPHandler(rc, req.GCPConfig))
+		if req.GCPConfig.VertexHost != "" {
+			startVertexAuth(runCtx, rc, req.GCPConfig.VertexHost)
+		}
+	}
+	rc.SetCtlHandler(newCtlHandler(rc))
 
 	// Register the fully-initialized RunContext so the proxy never sees
 	// an incomplete run.
@@ -269,6 +317,7 @@ func (s *Server) handleRegisterRun(w http.ResponseWriter, r *http.Request) {
 		s.persister.SaveDebounced()
 	}
 
+	s.runEvents.Publish(RunEvent{Type: EventRunRegistered, RunID: rc.RunID, Time: time.Now()})
 	if s.onRegister != nil {
 		s.onRegister()
 	}
@@ -294,7 +343,156 @@ func (s *Server) handleListRuns(w http.ResponseWriter, _ *http.Request) {
 	writeJSON(w, http.StatusOK, infos)
 }
 
-// handleUpdateRun updates a run's container ID.
+// requestStreamPoll is how often a request stream checks whether its run
+// is still registered.
+var requestStreamPoll = 2 * time.Second
+
+// handleStreamRequests streams a run's proxied requests as newline-delimited
+// JSON RequestEvents until the client disconnects or the run unregisters.
+func (s *Server) handleStreamRequests(w http.ResponseWriter, r *http.Request) {
+	runID := r.URL.Query().Get("run_id")
+	if runID == "" {
+		http.Error(w, `{"error":"missing run_id"}`, http.StatusBadRequest)
+		return
+	}
+	if _, ok := s.registry.LookupRun(runID); !ok {
+		http.Error(w, `{"error":"run not found"}`, http.StatusNotFound)
+		return
+	}
+
+	// The stream is long-lived; lift the server's write timeout for it.
+	rc := http.NewResponseController(w)
+	_ = rc.SetWriteDeadline(time.Time{})
+
+	events, unsubscribe := s.events.Subscribe(runID)
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	_ = rc.Flush()
+
+	enc := json.NewEncoder(w)
+	ticker := time.NewTicker(requestStreamPoll)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+			if _, ok := s.registry.LookupRun(runID); !ok {
+				return
+			}
+		case ev := <-events:
+			if err := enc.Encode(ev); err != nil {
+				return
+			}
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// handleStreamEvents streams run, approval, and clip events as
+// newline-delimited JSON RunEvents until the client disconnects. With
+// run_id, only that run's events are sent.
+func (s *Server) handleStreamEvents(w http.ResponseWriter, r *http.Request) {
+	rc := http.NewResponseController(w)
+	_ = rc.SetWriteDeadline(time.Time{})
+
+	events, unsubscribe := s.runEvents.Subscribe(r.URL.Query().Get("run_id"))
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	_ = rc.Flush()
+
+	enc := json.NewEncoder(w)
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case ev := <-events:
+			if err := enc.Encode(ev); err != nil {
+				return
+			}
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// handleEnqueue queues the caller for a run slot and streams its status as
+// newline-delimited JSON until the slot is granted. The slot is then held
+// until the caller disconnects.
+func (s *Server) handleEnqueue(w http.ResponseWriter, r *http.Request) {
+	var req QueueRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"invalid JSON"}`, http.StatusBadRequest)
+		return
+	}
+	if req.Workspace == "" {
+		http.Error(w, `{"error":"missing workspace"}`, http.StatusBadRequest)
+		return
+	}
+
+	rc := http.NewResponseController(w)
+	_ = rc.SetWriteDeadline(time.Time{})
+
+	ticket := s.scheduler.Enqueue(req.Name, req.Workspace)
+	defer s.scheduler.Release(ticket)
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+
+	enc := json.NewEncoder(w)
+	var last QueueStatus
+	for {
+		changed := s.scheduler.Changed()
+		if last.State != SlotRunning {
+			st, _ := s.scheduler.Status(ticket)
+			if st != last {
+				if err := enc.Encode(st); err != nil {
+					return
+				}
+				if err := rc.Flush(); err != nil {
+					return
+				}
+				last = st
+			}
+		}
+		select {
+		case <-r.Context().Done():
+			return
+		case <-changed:
+		}
+	}
+}
+
+// handleListQueue returns the concurrency limits and the running and queued
+// run slots.
+func (s *Server) handleListQueue(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, s.scheduler.Info())
+}
+
+// handleUpdateSlot records the run holding a slot.
+func (s *Server) handleUpdateSlot(w http.ResponseWriter, r *http.Request) {
+	ticket := extractToken(r.URL.Path, "/v1/queue/")
+	var req QueueSlotUpdate
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"invalid JSON"}`, http.StatusBadRequest)
+		return
+	}
+	if !s.scheduler.Update(ticket, req) {
+		http.Error(w, `{"error":"slot not found"}`, http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleUpdateRun records a run's container ID, runtime, and service
+// containers.
 func (s *Server) handleUpdateRun(w http.ResponseWriter, r *http.Request) {
 	token := extractToken(r.URL.Path, "/v1/runs/")
 	if token == "" {
@@ -312,6 +510,9 @@ func (s *Server) handleUpdateRun(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, `{"error":"run not found"}`, http.StatusNotFound)
 		return
 	}
+	if rc, ok := s.registry.Lookup(token); ok {
+		rc.SetContainers(req.Runtime, req.ServiceContainers)
+	}
 
 	if s.persister != nil {
 		s.persister.SaveDebounced()
@@ -344,6 +545,7 @@ func (s *Server) handleUnregisterRun(w http.ResponseWriter, r *http.Request) {
 
 	w.WriteHeader(http.StatusNoContent)
 
+	s.runEvents.Publish(RunEvent{Type: EventRunUnregistered, RunID: rc.RunID, Time: time.Now()})
 	if s.onUnregister != nil {
 		s.onUnregister(rc.RunID)
 	}
@@ -352,6 +554,113 @@ func (s *Server) handleUnregisterRun(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// handleListApprovals returns the network approvals requested with moatctl,
+// optionally filtered to one run.
+func (s *Server) handleListApprovals(w http.ResponseWriter, r *http.Request) {
+	approvals := []Approval{}
+	if c := currentCtl(); c != nil {
+		approvals = append(approvals, c.Approvals(r.URL.Query().Get("run_id"))...)
+	}
+	writeJSON(w, http.StatusOK, approvals)
+}
+
+// handleDecideApproval approves or denies a pending network approval.
+func (s *Server) handleDecideApproval(w http.ResponseWriter, r *http.Request) {
+	approvalID := extractToken(r.URL.Path, "/v1/approvals/")
+	var dec ApprovalDecision
+	if err := json.NewDecoder(r.Body).Decode(&dec); err != nil {
+		http.Error(w, `{"error":"invalid JSON"}`, http.StatusBadRequest)
+		return
+	}
+	c := currentCtl()
+	if c == nil {
+		http.Error(w, `{"error":"approval not found"}`, http.StatusNotFound)
+		return
+	}
+	a, err := c.Decide(approvalID, dec.Approve)
+	switch {
+	case errors.Is(err, ErrApprovalNotFound):
+		http.Error(w, `{"error":"approval not found"}`, http.StatusNotFound)
+		return
+	case errors.Is(err, ErrApprovalDecided):
+		writeJSON(w, http.StatusConflict, a)
+		return
+	}
+	// An approved host changes the run's network rules.
+	if dec.Approve && s.persister != nil {
+		s.persister.SaveDebounced()
+	}
+	writeJSON(w, http.StatusOK, a)
+}
+
+// handleListClips returns the clips runs offered with moatctl, optionally
+// filtered to one run.
+func (s *Server) handleListClips(w http.ResponseWriter, r *http.Request) {
+	clips := []Clip{}
+	if c := currentCtl(); c != nil {
+		clips = append(clips, c.Clips(r.URL.Query().Get("run_id"))...)
+	}
+	writeJSON(w, http.StatusOK, clips)
+}
+
+// handleSendClip hands a clip from the host to a run for `moatctl paste`.
+func (s *Server) handleSendClip(w http.ResponseWriter, r *http.Request) {
+	var clip Clip
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 2*maxClipSize)).Decode(&clip); err != nil {
+		http.Error(w, `{"error":"invalid JSON"}`, http.StatusBadRequest)
+		return
+	}
+	if _, ok := s.registry.LookupRun(clip.RunID); !ok {
+		http.Error(w, `{"error":"run not found"}`, http.StatusNotFound)
+		return
+	}
+	c := currentCtl()
+	if c == nil {
+		http.Error(w, `{"error":"clip not supported"}`, http.StatusServiceUnavailable)
+		return
+	}
+	sent, err := c.Send(clip)
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":%q}`, err.Error()), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, http.StatusCreated, sent)
+}
+
+// handleDecideClip accepts or rejects a clip a run offered. Either way the
+// clip is removed; an accepted clip is returned for the CLI to deliver.
+func (s *Server) handleDecideClip(w http.ResponseWriter, r *http.Request) {
+	clipID := extractToken(r.URL.Path, "/v1/clips/")
+	var dec ClipDecision
+	if err := json.NewDecoder(r.Body).Decode(&dec); err != nil {
+		http.Error(w, `{"error":"invalid JSON"}`, http.StatusBadRequest)
+		return
+	}
+	c := currentCtl()
+	if c == nil {
+		http.Error(w, `{"error":"clip not found"}`, http.StatusNotFound)
+		return
+	}
+	clip, err := c.DecideClip(clipID, dec.Accept)
+	if errors.Is(err, ErrClipNotFound) {
+		http.Error(w, `{"error":"clip not found"}`, http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, clip)
+}
+
+// handleListRoutes returns the registered service routes, sorted by agent.
+func (s *Server) handleListRoutes(w http.ResponseWriter, _ *http.Request) {
+	routes := []RouteInfo{}
+	if s.routes != nil {
+		for agent, services := range s.routes.Snapshot() {
+			routes = append(routes, RouteInfo{Agent: agent, Services: services})
+		}
+	}
+	slices.SortFunc(routes, func(a, b RouteInfo) int { return strings.Compare(a.Agent, b.Agent) })
+	writeJSON(w, http.StatusOK, routes)
+}
+
 // handleRegisterRoutes registers service routes for an agent.
 func (s *Server) handleRegisterRoutes(w http.ResponseWriter, r *http.Request) {
 	agent := extractToken(r.URL.Path, "/v1/routes/")
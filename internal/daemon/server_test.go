@@ -9,6 +9,8 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+
+	"github.com/majorcontext/moat/internal/credential"
 )
 
 // testSockDir creates a short temp directory for Unix sockets.
@@ -93,7 +95,7 @@ func TestServer_HealthEndpointCapabilities(t *testing.T) {
 		t.Fatalf("decode: %v", err)
 	}
 
-	wantCaps := map[string]bool{CapKeepPolicy: false, CapKeepBodyPolicy: false, CapHostGatewayV2: false}
+	wantCaps := map[string]bool{CapKeepPolicy: false, CapKeepBodyPolicy: false, CapHostGatewayV2: false, CapRequestStream: false}
 	for _, c := range health.Capabilities {
 		if _, ok := wantCaps[c]; ok {
 			wantCaps[c] = true
@@ -174,6 +176,59 @@ func TestServer_RegisterRejectsInvalidProfile(t *testing.T) {
 	}
 }
 
+// A grant restricted to one workspace must not be registered for a run from
+// another, even if the CLI skipped its own check.
+func TestServer_RegisterRejectsRestrictedGrant(t *testing.T) {
+	t.Setenv("MOAT_HOME", t.TempDir())
+	saved := credential.ActiveProfile
+	credential.ActiveProfile = ""
+	t.Cleanup(func() { credential.ActiveProfile = saved })
+
+	allowed := t.TempDir()
+	restrict, err := credential.NewRestriction(nil, []string{allowed})
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := credential.DefaultEncryptionKey()
+	if err != nil {
+		t.Fatalf("encryption key: %v", err)
+	}
+	store, err := credential.NewFileStore(credential.StoreDirForProfile(""), key)
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	if err := store.Save(credential.Credential{Provider: "github", Token: "tok", Restrict: restrict}); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	sock := filepath.Join(testSockDir(t), "d.sock")
+	srv := NewServer(sock, 9119)
+	if err := srv.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer srv.Stop(context.Background())
+	client := testClient(sock)
+
+	register := func(runID, workspace string) int {
+		body, _ := json.Marshal(RegisterRequest{RunID: runID, Grants: []string{"github"}, Workspace: workspace})
+		resp, err := client.Post("http://localhost/v1/runs", "application/json", bytes.NewReader(body))
+		if err != nil {
+			t.Fatalf("POST /v1/runs: %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	if code := register("run-other", t.TempDir()); code != http.StatusForbidden {
+		t.Errorf("register from another workspace: status %d, want 403", code)
+	}
+	if code := register("run-none", ""); code != http.StatusForbidden {
+		t.Errorf("register without a workspace: status %d, want 403", code)
+	}
+	if code := register("run-allowed", allowed); code != http.StatusCreated && code != http.StatusOK {
+		t.Errorf("register from the allowed workspace: status %d", code)
+	}
+}
+
 func TestServer_RegisterAndListRuns(t *testing.T) {
 	sock := filepath.Join(testSockDir(t), "d.sock")
 	srv := NewServer(sock, 9119)
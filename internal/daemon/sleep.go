@@ -0,0 +1,138 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/majorcontext/moat/internal/log"
+)
+
+// Sleep detection defaults. A wall-clock gap longer than the monotonic gap
+// by sleepThreshold means the host was suspended between two ticks.
+const (
+	sleepPollInterval = 10 * time.Second
+	sleepThreshold    = 30 * time.Second
+)
+
+// errSleepNotifyUnsupported is returned by watchSystemSleep on hosts that do
+// not announce sleep before it happens.
+var errSleepNotifyUnsupported = errors.New("sleep notifications not supported on this host")
+
+// SleepWatcher reports host suspend and resume.
+//
+// Wake is detected on every platform from the clocks: Go's monotonic clock
+// does not advance while macOS or Linux is suspended but the wall clock
+// does, so a poll whose wall-clock gap exceeds its monotonic gap spanned a
+// sleep. Timers and tickers run on the monotonic clock, so without this a
+// 5-minute refresh ticker armed before an overnight sleep fires 5 minutes
+// after wake — long after the tokens it refreshes have expired.
+//
+// Sleep itself is only reported where the OS announces it in advance
+// (systemd-logind on Linux), and only when an OnSleep callback is set.
+type SleepWatcher struct {
+	interval  time.Duration
+	threshold time.Duration
+	onSleep   func()
+	onWake    func(slept time.Duration)
+
+	mu       sync.Mutex
+	asleep   bool      // OnSleep fired and OnWake has not
+	lastWake time.Time // wall time of the last OnWake, to report each wake once
+}
+
+// NewSleepWatcher creates a sleep watcher with the default poll interval.
+func NewSleepWatcher() *SleepWatcher {
+	return &SleepWatcher{
+		interval:  sleepPollInterval,
+		threshold: sleepThreshold,
+	}
+}
+
+// SetOnSleep sets a callback invoked before the host suspends. The host
+// waits for it to return, up to the OS's inhibitor delay (5s by default).
+func (w *SleepWatcher) SetOnSleep(fn func()) {
+	w.onSleep = fn
+}
+
+// SetOnWake sets a callback invoked after the host resumes, with how long
+// it was suspended (0 when unknown).
+func (w *SleepWatcher) SetOnWake(fn func(slept time.Duration)) {
+	w.onWake = fn
+}
+
+// Run watches for sleep and wake until ctx is canceled.
+func (w *SleepWatcher) Run(ctx context.Context) {
+	if w.onSleep != nil {
+		go func() {
+			err := watchSystemSleep(ctx, func(sleeping bool) {
+				if sleeping {
+					w.sleep()
+				} else {
+					w.wake(0)
+				}
+			})
+			if err != nil && ctx.Err() == nil {
+				log.Warn("cannot watch for system sleep; containers will not be paused", "error", err)
+			}
+		}()
+	}
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	last := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			now := time.Now()
+			if slept := suspendedBetween(last, now); slept >= w.threshold {
+				w.wake(slept)
+			}
+			last = now
+		}
+	}
+}
+
+// suspendedBetween returns how much longer the wall clock advanced than the
+// monotonic clock between two readings taken with time.Now.
+func suspendedBetween(prev, now time.Time) time.Duration {
+	return now.Round(0).Sub(prev.Round(0)) - now.Sub(prev)
+}
+
+// sleep reports an announced suspend.
+func (w *SleepWatcher) sleep() {
+	w.mu.Lock()
+	if w.asleep {
+		w.mu.Unlock()
+		return
+	}
+	w.asleep = true
+	w.mu.Unlock()
+
+	log.Info("host is going to sleep")
+	if w.onSleep != nil {
+		w.onSleep()
+	}
+}
+
+// wake reports a resume. The logind signal and the clock check both see the
+// same wake, in either order; only the first is reported.
+func (w *SleepWatcher) wake(slept time.Duration) {
+	w.mu.Lock()
+	now := time.Now().Round(0)
+	if !w.asleep && now.Sub(w.lastWake) < 2*w.interval {
+		w.mu.Unlock()
+		return
+	}
+	w.asleep = false
+	w.lastWake = now
+	w.mu.Unlock()
+
+	log.Info("host woke from sleep", "slept", slept.Round(time.Second))
+	if w.onWake != nil {
+		w.onWake(slept)
+	}
+}
@@ -0,0 +1,88 @@
+//go:build linux
+
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"syscall"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	logindDest    = "org.freedesktop.login1"
+	logindPath    = dbus.ObjectPath("/org/freedesktop/login1")
+	logindManager = "org.freedesktop.login1.Manager"
+)
+
+// watchSystemSleep calls notify(true) before systemd-logind suspends the
+// host and notify(false) after it resumes, until ctx is canceled. A delay
+// inhibitor lock holds the suspend until notify(true) returns.
+func watchSystemSleep(ctx context.Context, notify func(sleeping bool)) error {
+	conn, err := dbus.ConnectSystemBus()
+	if err != nil {
+		return fmt.Errorf("connecting to system bus: %w", err)
+	}
+	defer conn.Close()
+
+	if err := conn.AddMatchSignal(
+		dbus.WithMatchObjectPath(logindPath),
+		dbus.WithMatchInterface(logindManager),
+		dbus.WithMatchMember("PrepareForSleep"),
+	); err != nil {
+		return fmt.Errorf("subscribing to logind: %w", err)
+	}
+	signals := make(chan *dbus.Signal, 4)
+	conn.Signal(signals)
+
+	inhibit := func() (int, error) {
+		var fd dbus.UnixFD
+		err := conn.Object(logindDest, logindPath).CallWithContext(ctx, logindManager+".Inhibit", 0,
+			"sleep", "moat", "Pausing containers before sleep", "delay").Store(&fd)
+		if err != nil {
+			return -1, fmt.Errorf("taking logind inhibitor lock: %w", err)
+		}
+		return int(fd), nil
+	}
+	lock, err := inhibit()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if lock >= 0 {
+			syscall.Close(lock)
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case sig, ok := <-signals:
+			if !ok {
+				return fmt.Errorf("system bus connection closed")
+			}
+			if sig.Name != logindManager+".PrepareForSleep" || len(sig.Body) != 1 {
+				continue
+			}
+			sleeping, _ := sig.Body[0].(bool)
+			if sleeping {
+				notify(true)
+				// Releasing the lock lets the suspend proceed.
+				if lock >= 0 {
+					syscall.Close(lock)
+					lock = -1
+				}
+				continue
+			}
+			notify(false)
+			// Re-take the lock for the next suspend.
+			if lock < 0 {
+				if lock, err = inhibit(); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,12 @@
+//go:build !linux
+
+package daemon
+
+import "context"
+
+// watchSystemSleep is only implemented for systemd-logind. On macOS the
+// container VM is frozen with the host, and wake is still detected from the
+// clocks (see SleepWatcher).
+func watchSystemSleep(context.Context, func(sleeping bool)) error {
+	return errSleepNotifyUnsupported
+}
@@ -0,0 +1,125 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSuspendedBetween(t *testing.T) {
+	prev := time.Now()
+	now := prev.Add(10 * time.Second)
+	if got := suspendedBetween(prev, now); got != 0 {
+		t.Errorf("suspendedBetween without sleep = %v, want 0", got)
+	}
+
+}
+
+func TestSleepWatcher_ReportsEachWakeOnce(t *testing.T) {
+	w := NewSleepWatcher()
+	var wakes int
+	w.SetOnSleep(func() {})
+	w.SetOnWake(func(time.Duration) { wakes++ })
+
+	// logind announces the sleep, then the clock check and the logind
+	// signal both see the wake.
+	w.sleep()
+	w.wake(time.Hour)
+	w.wake(0)
+	if wakes != 1 {
+		t.Fatalf("wakes = %d, want 1", wakes)
+	}
+
+	// A second sleep right away is still reported.
+	w.sleep()
+	w.wake(0)
+	if wakes != 2 {
+		t.Errorf("wakes = %d, want 2 after second sleep", wakes)
+	}
+}
+
+type recordingPauser struct {
+	mu     sync.Mutex
+	calls  []string
+	failOn string
+}
+
+func (p *recordingPauser) SetContainerPaused(_ context.Context, runtime, id string, paused bool) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if runtime == "kubernetes" {
+		return ErrPauseUnsupported
+	}
+	verb := "unpause"
+	if paused {
+		verb = "pause"
+	}
+	p.calls = append(p.calls, verb+" "+runtime+" "+id)
+	if id == p.failOn {
+		return errors.New("no such container")
+	}
+	return nil
+}
+
+func TestSuspendHandler_PausesAndResumes(t *testing.T) {
+	reg := NewRegistry()
+	for id, runtime := range map[string]string{"c1": "podman", "c2": "docker", "": "docker", "ns/pod": "kubernetes"} {
+		rc := NewRunContext("run_" + id)
+		rc.ContainerID = id
+		rc.SetContainers(runtime, nil)
+		reg.Register(rc)
+	}
+	rc, _ := reg.LookupRun("run_c1")
+	woken := rc.Woken()
+
+	pauser := &recordingPauser{failOn: "c2"}
+	h := NewSuspendHandler(reg, nil)
+	h.SetPauser(pauser)
+
+	h.Sleep(context.Background())
+	slices.Sort(pauser.calls)
+	if want := []string{"pause docker c2", "pause podman c1"}; !slices.Equal(pauser.calls, want) {
+		t.Fatalf("calls after sleep = %v, want %v", pauser.calls, want)
+	}
+
+	pauser.calls = nil
+	h.Wake(context.Background())
+	if want := []string{"unpause podman c1"}; !slices.Equal(pauser.calls, want) {
+		t.Errorf("calls after wake = %v, want %v (only containers that were paused)", pauser.calls, want)
+	}
+	select {
+	case <-woken:
+	default:
+		t.Error("Wake did not notify the run's refresh loops")
+	}
+}
+
+func TestLivenessChecker_WakeGrace(t *testing.T) {
+	reg := NewRegistry()
+	rc := NewRunContext("run_1")
+	rc.ContainerID = "resuming"
+	reg.Register(rc)
+
+	checker := &mockContainerChecker{err: map[string]error{"resuming": errors.New("Cannot connect to the Docker daemon")}}
+	lc := NewLivenessChecker(reg, checker)
+	lc.failCounts["resuming"] = lc.maxFailures - 1
+
+	lc.startGrace()
+	for range lc.maxFailures + 1 {
+		lc.CheckOnce(context.Background())
+	}
+	if reg.Count() != 1 {
+		t.Fatal("failed checks during the wake grace period should not remove the run")
+	}
+
+	lc.graceUntil = time.Time{}
+	for range lc.maxFailures {
+		lc.CheckOnce(context.Background())
+	}
+	if reg.Count() != 0 {
+		t.Error("failed checks after the grace period should remove the run")
+	}
+}
@@ -0,0 +1,221 @@
+package daemon
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	starlarkjson "go.starlark.net/lib/json"
+	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
+
+	"github.com/majorcontext/moat/internal/log"
+)
+
+// Limits on a request-starlark script. Scripts have no file, network, or
+// module access; these bound the CPU they can use on each request.
+const (
+	starlarkMaxSteps = 1_000_000
+	starlarkTimeout  = time.Second
+)
+
+// starlarkPredeclared is what request-starlark scripts can use besides the
+// Starlark built-ins.
+var starlarkPredeclared = starlark.StringDict{
+	"json": starlarkjson.Module,
+}
+
+// starlarkHiddenHeaders are request headers scripts can neither see nor set.
+var starlarkHiddenHeaders = map[string]bool{
+	"Proxy-Authorization": true,
+	"Proxy-Connection":    true,
+	"Host":                true,
+}
+
+// newStarlarkTransformer compiles script, which must define a function
+// transform(req). For each request, transform gets a dict with the keys
+// method, host, path, query, headers (a dict of header names to values),
+// and body (a string). It changes the request by modifying the dict in
+// place or by returning a new one; a script that calls fail() refuses the
+// request. Bodies over maxScrubBodySize are not read: the request is
+// forwarded unchanged.
+func newStarlarkTransformer(name, script string) (RequestTransformer, error) {
+	thread := newStarlarkThread(name)
+	globals, err := starlark.ExecFileOptions(&syntax.FileOptions{}, thread, name, script, starlarkPredeclared)
+	if err != nil {
+		return nil, err
+	}
+	fn, ok := globals["transform"].(*starlark.Function)
+	if !ok || fn.NumParams() != 1 {
+		return nil, fmt.Errorf("script must define transform(req)")
+	}
+
+	return func(req *http.Request) error {
+		var body []byte
+		if req.Body != nil && req.Body != http.NoBody {
+			if req.ContentLength > maxScrubBodySize {
+				return nil
+			}
+			var err error
+			body, err = io.ReadAll(io.LimitReader(req.Body, maxScrubBodySize+1))
+			if err != nil {
+				return fmt.Errorf("reading request body: %w", err)
+			}
+			if len(body) > maxScrubBodySize {
+				req.Body = readCloser{io.MultiReader(bytes.NewReader(body), req.Body), req.Body}
+				return nil
+			}
+			req.Body.Close()
+			req.Body = io.NopCloser(bytes.NewReader(body))
+		}
+
+		in := starlarkRequest(req, body)
+		thread := newStarlarkThread(name)
+		timer := time.AfterFunc(starlarkTimeout, func() { thread.Cancel("timed out") })
+		out, err := starlark.Call(thread, fn, starlark.Tuple{in}, nil)
+		timer.Stop()
+		if err != nil {
+			if evalErr, ok := err.(*starlark.EvalError); ok {
+				return fmt.Errorf("%s", evalErr.Msg)
+			}
+			return err
+		}
+		switch out := out.(type) {
+		case starlark.NoneType:
+		case *starlark.Dict:
+			in = out
+		default:
+			return fmt.Errorf("transform returned %s, want a dict or None", out.Type())
+		}
+		return applyStarlarkRequest(req, in, body)
+	}, nil
+}
+
+// newStarlarkThread returns a thread that cannot load modules, logs print
+// calls, and stops after starlarkMaxSteps.
+func newStarlarkThread(name string) *starlark.Thread {
+	thread := &starlark.Thread{
+		Name: name,
+		Print: func(_ *starlark.Thread, msg string) {
+			log.Debug("request-starlark print", "transform", name, "message", msg)
+		},
+	}
+	thread.SetMaxExecutionSteps(starlarkMaxSteps)
+	return thread
+}
+
+// starlarkRequest returns req as the dict transform receives.
+func starlarkRequest(req *http.Request, body []byte) *starlark.Dict {
+	headers := starlark.NewDict(len(req.Header))
+	for name, values := range req.Header {
+		if starlarkHiddenHeaders[name] || len(values) == 0 {
+			continue
+		}
+		headers.SetKey(starlark.String(name), starlark.String(strings.Join(values, ", ")))
+	}
+	d := starlark.NewDict(6)
+	d.SetKey(starlark.String("method"), starlark.String(req.Method))
+	d.SetKey(starlark.String("host"), starlark.String(req.URL.Hostname()))
+	d.SetKey(starlark.String("path"), starlark.String(req.URL.Path))
+	d.SetKey(starlark.String("query"), starlark.String(req.URL.RawQuery))
+	d.SetKey(starlark.String("headers"), headers)
+	d.SetKey(starlark.String("body"), starlark.String(body))
+	return d
+}
+
+// applyStarlarkRequest makes req match the dict d that transform left or
+// returned. The host cannot be changed; headers missing from d's headers
+// are removed.
+func applyStarlarkRequest(req *http.Request, d *starlark.Dict, body []byte) error {
+	fields := make(map[string]starlark.Value, d.Len())
+	for _, item := range d.Items() {
+		key, ok := starlark.AsString(item[0])
+		if !ok {
+			return fmt.Errorf("request key %s is not a string", item[0])
+		}
+		fields[key] = item[1]
+	}
+	str := func(key string) (string, bool, error) {
+		v, ok := fields[key]
+		if !ok {
+			return "", false, nil
+		}
+		s, ok := starlark.AsString(v)
+		if !ok {
+			return "", false, fmt.Errorf("request %s is %s, want a string", key, v.Type())
+		}
+		return s, true, nil
+	}
+	for key := range fields {
+		switch key {
+		case "method", "host", "path", "query", "headers", "body":
+		default:
+			return fmt.Errorf("unknown request key %q", key)
+		}
+	}
+
+	if host, ok, err := str("host"); err != nil {
+		return err
+	} else if ok && !strings.EqualFold(host, req.URL.Hostname()) {
+		return fmt.Errorf("the request host cannot be changed")
+	}
+	if method, ok, err := str("method"); err != nil {
+		return err
+	} else if ok {
+		req.Method = method
+	}
+	if path, ok, err := str("path"); err != nil {
+		return err
+	} else if ok && path != req.URL.Path {
+		if !strings.HasPrefix(path, "/") {
+			return fmt.Errorf("request path %q must start with /", path)
+		}
+		req.URL.Path = path
+		req.URL.RawPath = ""
+	}
+	if query, ok, err := str("query"); err != nil {
+		return err
+	} else if ok {
+		req.URL.RawQuery = query
+	}
+	if v, ok := fields["headers"]; ok {
+		headers, ok := v.(*starlark.Dict)
+		if !ok {
+			return fmt.Errorf("request headers is %s, want a dict", v.Type())
+		}
+		set := make(map[string]string, headers.Len())
+		for _, item := range headers.Items() {
+			name, ok1 := starlark.AsString(item[0])
+			value, ok2 := starlark.AsString(item[1])
+			if !ok1 || !ok2 {
+				return fmt.Errorf("request headers must map strings to strings")
+			}
+			set[http.CanonicalHeaderKey(name)] = value
+		}
+		for name := range req.Header {
+			if _, keep := set[name]; !keep && !starlarkHiddenHeaders[name] {
+				req.Header.Del(name)
+			}
+		}
+		for name, value := range set {
+			if starlarkHiddenHeaders[name] {
+				continue
+			}
+			if strings.Join(req.Header.Values(name), ", ") != value {
+				req.Header.Set(name, value)
+			}
+		}
+	}
+	if newBody, ok, err := str("body"); err != nil {
+		return err
+	} else if ok && newBody != string(body) {
+		req.Body = io.NopCloser(strings.NewReader(newBody))
+		req.ContentLength = int64(len(newBody))
+		req.Header.Del("Transfer-Encoding")
+		req.TransferEncoding = nil
+	}
+	return nil
+}
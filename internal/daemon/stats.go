@@ -0,0 +1,189 @@
+package daemon
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/majorcontext/moat/internal/container"
+)
+
+const (
+	// statsMaxAge is how long a sample is served before GET /v1/stats takes
+	// a new one, so several viewers share one sample of each container.
+	statsMaxAge = time.Second
+	// statsTimeout bounds sampling a single container.
+	statsTimeout = 5 * time.Second
+)
+
+// containerStats samples a container's resource usage with the runtime
+// that owns it; an empty runtime uses the default one. It is a variable so
+// tests can replace the runtime.
+var containerStats = runtimeContainerStats
+
+var (
+	statsPoolOnce sync.Once
+	statsPool     *container.RuntimePool
+	statsPoolErr  error
+)
+
+// runtimeContainerStats implements containerStats through the runtime's
+// API, with runtimes created on first use and kept for the life of the
+// daemon. Kubernetes pods, whose IDs are "<namespace>/<pod>", do not expose
+// usage.
+func runtimeContainerStats(ctx context.Context, runtime, id string) (container.Stats, error) {
+	if runtime == string(container.RuntimeKubernetes) || strings.Contains(id, "/") {
+		return container.Stats{}, container.ErrStatsUnsupported
+	}
+	statsPoolOnce.Do(func() {
+		// Reading usage does not depend on the sandbox, so the pool is
+		// created without it rather than requiring gVisor.
+		statsPool, statsPoolErr = container.NewRuntimePool(container.RuntimeOptions{})
+	})
+	if statsPoolErr != nil {
+		return container.Stats{}, statsPoolErr
+	}
+	rt, err := statsPool.Get(container.RuntimeType(runtime))
+	if err != nil {
+		return container.Stats{}, err
+	}
+	return rt.ContainerStats(ctx, id)
+}
+
+// StatsSampler samples the resource usage of registered runs' containers
+// on demand. It keeps each container's previous sample to turn cumulative
+// CPU time and network bytes into rates, and serves a sample younger than
+// statsMaxAge instead of asking the runtime again.
+type StatsSampler struct {
+	registry *Registry
+
+	mu        sync.Mutex
+	prev      map[string]statsSample // last sample, by container ID
+	sampledAt time.Time
+	current   []RunStats
+}
+
+// statsSample is the runtime's cumulative usage of a container at a time.
+type statsSample struct {
+	at time.Time
+	container.Stats
+}
+
+// NewStatsSampler creates a sampler for the runs in registry.
+func NewStatsSampler(registry *Registry) *StatsSampler {
+	return &StatsSampler{
+		registry: registry,
+		prev:     make(map[string]statsSample),
+	}
+}
+
+// Sample returns the resource usage of every registered run with a
+// container, sorted by run ID. Containers are sampled concurrently; one
+// that cannot be read is reported with Error set.
+func (s *StatsSampler) Sample(ctx context.Context) []RunStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.current != nil && time.Since(s.sampledAt) < statsMaxAge {
+		return s.current
+	}
+
+	var runs []RunStats
+	runtimes := make(map[string]string) // by run ID
+	for _, rc := range s.registry.List() {
+		runtime, id, services := rc.containers()
+		if id == "" {
+			continue
+		}
+		rs := RunStats{RunID: rc.RunID, Containers: []ContainerStats{{ContainerID: id}}}
+		names := make([]string, 0, len(services))
+		for name := range services {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			rs.Containers = append(rs.Containers, ContainerStats{Service: name, ContainerID: services[name]})
+		}
+		runs = append(runs, rs)
+		runtimes[rc.RunID] = runtime
+	}
+	sort.Slice(runs, func(i, j int) bool { return runs[i].RunID < runs[j].RunID })
+
+	var wg sync.WaitGroup
+	raw := make([][]*container.Stats, len(runs))
+	for i := range runs {
+		raw[i] = make([]*container.Stats, len(runs[i].Containers))
+		for j := range runs[i].Containers {
+			cs := &runs[i].Containers[j]
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				sctx, cancel := context.WithTimeout(ctx, statsTimeout)
+				defer cancel()
+				st, err := containerStats(sctx, runtimes[runs[i].RunID], cs.ContainerID)
+				cs.SampledAt = time.Now()
+				if err != nil {
+					cs.Error = err.Error()
+					return
+				}
+				raw[i][j] = &st
+			}()
+		}
+	}
+	wg.Wait()
+
+	prev := s.prev
+	s.prev = make(map[string]statsSample)
+	for i := range runs {
+		for j := range runs[i].Containers {
+			cs := &runs[i].Containers[j]
+			st := raw[i][j]
+			if st == nil {
+				continue
+			}
+			cs.MemoryBytes = st.MemoryBytes
+			cs.MemoryLimitBytes = st.MemoryLimitBytes
+			cs.NetRxBytes = st.NetRxBytes
+			cs.NetTxBytes = st.NetTxBytes
+			cur := statsSample{at: cs.SampledAt, Stats: *st}
+			if p, ok := prev[cs.ContainerID]; ok {
+				applyRates(cs, p, cur)
+			}
+			s.prev[cs.ContainerID] = cur
+		}
+	}
+
+	if runs == nil {
+		runs = []RunStats{}
+	}
+	s.current = runs
+	s.sampledAt = time.Now()
+	return runs
+}
+
+// applyRates sets cs's CPU and network rates from the container's previous
+// and current samples. Counters that went backwards, as after a container
+// restart, give no rate.
+func applyRates(cs *ContainerStats, prev, cur statsSample) {
+	elapsed := cur.at.Sub(prev.at)
+	if elapsed <= 0 {
+		return
+	}
+	if cur.CPUNanos >= prev.CPUNanos {
+		cs.CPUPercent = float64(cur.CPUNanos-prev.CPUNanos) / float64(elapsed.Nanoseconds()) * 100
+	}
+	if cur.NetRxBytes >= prev.NetRxBytes {
+		cs.NetRxRate = float64(cur.NetRxBytes-prev.NetRxBytes) / elapsed.Seconds()
+	}
+	if cur.NetTxBytes >= prev.NetTxBytes {
+		cs.NetTxRate = float64(cur.NetTxBytes-prev.NetTxBytes) / elapsed.Seconds()
+	}
+}
+
+// handleStats returns the resource usage of registered runs and their
+// service containers.
+func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.stats.Sample(r.Context()))
+}
@@ -0,0 +1,122 @@
+package daemon
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/majorcontext/moat/internal/container"
+)
+
+func TestClient_Stats(t *testing.T) {
+	dir := testSockDir(t)
+	sockPath := filepath.Join(dir, "d.sock")
+	srv := NewServer(sockPath, 9100)
+	if err := srv.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Stop(context.Background())
+
+	// Each call advances the containers' counters: a second of CPU and 1000
+	// bytes received. The database cannot be read.
+	var mu sync.Mutex
+	calls := make(map[string]uint64)
+	var runtimes []string
+	old := containerStats
+	defer func() { containerStats = old }()
+	containerStats = func(_ context.Context, runtime, id string) (container.Stats, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		runtimes = append(runtimes, runtime)
+		if id == "ctr_db" {
+			return container.Stats{}, container.ErrStatsUnsupported
+		}
+		calls[id]++
+		n := calls[id]
+		return container.Stats{CPUNanos: n * uint64(time.Second), MemoryBytes: 64 << 20, NetRxBytes: n * 1000}, nil
+	}
+
+	client := NewClient(sockPath)
+	resp, err := client.RegisterRun(context.Background(), RegisterRequest{RunID: "run_stats"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.RegisterRun(context.Background(), RegisterRequest{RunID: "run_pending"}); err != nil {
+		t.Fatal(err)
+	}
+	update := UpdateRunRequest{
+		ContainerID:       "ctr_agent",
+		Runtime:           "podman",
+		ServiceContainers: map[string]string{"redis": "ctr_redis", "postgres": "ctr_db"},
+	}
+	if err := client.UpdateRun(context.Background(), resp.AuthToken, update); err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := client.Stats(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A run without a container yet is not listed.
+	if len(first) != 1 || first[0].RunID != "run_stats" {
+		t.Fatalf("Stats() = %+v, want run_stats only", first)
+	}
+	var services []string
+	for _, c := range first[0].Containers {
+		services = append(services, c.Service)
+	}
+	if len(services) != 3 || services[0] != "" || services[1] != "postgres" || services[2] != "redis" {
+		t.Fatalf("containers = %q, want the run's, then postgres and redis", services)
+	}
+	if c := first[0].Containers[0]; c.MemoryBytes != 64<<20 || c.CPUPercent != 0 {
+		t.Errorf("first sample = %+v, want memory and no CPU rate", c)
+	}
+	if c := first[0].Containers[1]; c.Error == "" {
+		t.Errorf("unreadable container = %+v, want Error", c)
+	}
+
+	// A second request within statsMaxAge is served from the same sample.
+	again, _ := client.Stats(context.Background())
+	if again[0].Containers[0].SampledAt != first[0].Containers[0].SampledAt {
+		t.Error("second request sampled again")
+	}
+
+	srv.stats.mu.Lock()
+	srv.stats.sampledAt = time.Time{}
+	srv.stats.mu.Unlock()
+	time.Sleep(10 * time.Millisecond)
+	second, err := client.Stats(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := second[0].Containers[0]
+	if c.CPUPercent <= 0 || c.NetRxRate <= 0 || c.NetRxBytes != 2000 {
+		t.Errorf("second sample = %+v, want CPU and network rates", c)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	for _, rt := range runtimes {
+		if rt != "podman" {
+			t.Errorf("sampled with runtime %q, want podman", rt)
+		}
+	}
+}
+
+func TestApplyRates(t *testing.T) {
+	now := time.Now()
+	prev := statsSample{at: now, Stats: container.Stats{CPUNanos: 1e9, NetRxBytes: 500, NetTxBytes: 900}}
+	cur := statsSample{at: now.Add(2 * time.Second), Stats: container.Stats{CPUNanos: 4e9, NetRxBytes: 2500, NetTxBytes: 100}}
+
+	var cs ContainerStats
+	applyRates(&cs, prev, cur)
+	if cs.CPUPercent != 150 || cs.NetRxRate != 1000 {
+		t.Errorf("rates = %.1f%% CPU, %.0f B/s received, want 150%%, 1000 B/s", cs.CPUPercent, cs.NetRxRate)
+	}
+	// The transmit counter went backwards, as after a restart.
+	if cs.NetTxRate != 0 {
+		t.Errorf("NetTxRate = %.0f, want 0", cs.NetTxRate)
+	}
+}
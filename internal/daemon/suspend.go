@@ -0,0 +1,122 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/majorcontext/moat/internal/log"
+)
+
+// ContainerPauser pauses and unpauses containers of the given runtime
+// ("docker", "podman", ...; empty for runs registered without one).
+type ContainerPauser interface {
+	SetContainerPaused(ctx context.Context, runtime, id string, paused bool) error
+}
+
+// SuspendHandler keeps registered runs usable across host sleep. Before
+// sleep it saves the registry and, with a pauser, freezes the run
+// containers so agents do not wake to half-finished requests. After wake it
+// unpauses them, gives the liveness checker a grace period while the
+// container runtime resumes, and refreshes every run's tokens at once.
+//
+// Processes in a paused container, including `moat exec` sessions, stay in
+// memory; nothing is written to disk.
+type SuspendHandler struct {
+	registry  *Registry
+	liveness  *LivenessChecker
+	persister *RunPersister
+	pauser    ContainerPauser // nil leaves containers running across sleep
+
+	mu     sync.Mutex
+	paused []pausedContainer // containers paused by Sleep
+}
+
+type pausedContainer struct {
+	runtime string
+	id      string
+}
+
+// NewSuspendHandler creates a suspend handler for the registry's runs.
+// liveness may be nil.
+func NewSuspendHandler(registry *Registry, liveness *LivenessChecker) *SuspendHandler {
+	return &SuspendHandler{registry: registry, liveness: liveness}
+}
+
+// SetPauser sets the pauser used to freeze containers before sleep.
+func (h *SuspendHandler) SetPauser(p ContainerPauser) {
+	h.pauser = p
+}
+
+// SetPersister sets the run persister flushed before sleep.
+func (h *SuspendHandler) SetPersister(p *RunPersister) {
+	h.persister = p
+}
+
+// Sleep prepares the runs for a host suspend. It must return quickly: the
+// host waits for it only up to the OS's inhibitor delay.
+func (h *SuspendHandler) Sleep(ctx context.Context) {
+	// Save the registry first so the runs are restored even if the daemon
+	// does not survive the sleep.
+	if h.persister != nil {
+		if err := h.persister.Flush(); err != nil {
+			log.Warn("failed to save run registry before sleep", "error", err)
+		}
+	}
+	if h.pauser == nil {
+		return
+	}
+
+	// Pause in parallel: logind holds the suspend for only 5 seconds.
+	var wg sync.WaitGroup
+	for _, rc := range h.registry.List() {
+		runtime, id, _ := rc.containers()
+		if id == "" {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			pauseCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+			defer cancel()
+			if err := h.pauser.SetContainerPaused(pauseCtx, runtime, id, true); err != nil {
+				if errors.Is(err, ErrPauseUnsupported) {
+					log.Debug("not pausing container for sleep", "run_id", rc.RunID, "container_id", id, "reason", err)
+					return
+				}
+				log.Warn("failed to pause container before sleep", "run_id", rc.RunID, "container_id", id, "error", err)
+				return
+			}
+			log.Debug("paused container for sleep", "run_id", rc.RunID, "container_id", id)
+			h.mu.Lock()
+			h.paused = append(h.paused, pausedContainer{runtime: runtime, id: id})
+			h.mu.Unlock()
+		}()
+	}
+	wg.Wait()
+}
+
+// Wake resumes the runs after a host suspend.
+func (h *SuspendHandler) Wake(ctx context.Context) {
+	h.mu.Lock()
+	paused := h.paused
+	h.paused = nil
+	h.mu.Unlock()
+
+	for _, c := range paused {
+		unpauseCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		err := h.pauser.SetContainerPaused(unpauseCtx, c.runtime, c.id, false)
+		cancel()
+		if err != nil {
+			log.Warn("failed to unpause container after sleep", "container_id", c.id, "error", err)
+		}
+	}
+
+	if h.liveness != nil {
+		h.liveness.Wake()
+	}
+	for _, rc := range h.registry.List() {
+		rc.NotifyWake()
+	}
+}
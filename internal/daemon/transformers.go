@@ -2,17 +2,207 @@ package daemon
 
 import (
 	"bytes"
+	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"sort"
+	"strings"
+	"sync"
 
+	"github.com/majorcontext/moat/internal/credential"
 	"github.com/majorcontext/moat/internal/log"
 	"github.com/majorcontext/moat/internal/providers/claude"
+	"github.com/majorcontext/moat/internal/providers/stripe"
 )
 
 // maxScrubBodySize is the maximum response body size to scrub for tokens.
 // Larger responses are passed through unscrubbed to avoid memory issues.
 const maxScrubBodySize = 512 * 1024
 
+// Transformer kinds. Response kinds are built by the daemon from the
+// registry below. Request header kinds map onto the RunContext's extra and
+// removed headers, which the proxy applies before forwarding; other request
+// kinds come from the request transformer registry and are applied by Front
+// (see requesttransforms.go).
+const (
+	TransformOAuthEndpointWorkaround = "oauth-endpoint-workaround"
+	TransformResponseScrub           = "response-scrub"
+	TransformResponseHeaderSet       = "response-header-set"
+	TransformResponseHeaderRemove    = "response-header-remove"
+	TransformResponseBodyReplace     = "response-body-replace"
+	TransformStripeLiveModeBlock     = "stripe-live-mode-block"
+	TransformRequestHeaderSet        = "request-header-set"
+	TransformRequestHeaderRemove     = "request-header-remove"
+	TransformRequestPathRewrite      = "request-path-rewrite"
+	TransformRequestBodyTemplate     = "request-body-template"
+	TransformRequestStarlark         = "request-starlark"
+)
+
+// TransformerFactory builds a response transformer for spec. Factories run
+// from ToProxyContextData with rc's lock held: they may read rc's fields
+// directly but must not call its locking methods. Returning nil, nil skips
+// the transformer.
+type TransformerFactory func(rc *RunContext, spec TransformerSpec) (credential.ResponseTransformer, error)
+
+type transformerKind struct {
+	factory      TransformerFactory
+	requiredArgs []string
+}
+
+var (
+	transformerMu       sync.RWMutex
+	transformerRegistry = map[string]transformerKind{}
+)
+
+// RegisterTransformer adds a named response transformer kind that
+// TransformerSpecs (from providers or moat.yaml network.transforms) can
+// reference. requiredArgs lists the spec Args keys the kind needs.
+// Registering an existing kind replaces it.
+func RegisterTransformer(kind string, requiredArgs []string, f TransformerFactory) {
+	transformerMu.Lock()
+	defer transformerMu.Unlock()
+	transformerRegistry[kind] = transformerKind{factory: f, requiredArgs: requiredArgs}
+}
+
+// requestTransformerArgs lists the request kinds and their required args.
+var requestTransformerArgs = map[string][]string{
+	TransformRequestHeaderSet:    {"name", "value"},
+	TransformRequestHeaderRemove: {"name"},
+}
+
+// TransformerKinds returns every known transformer kind, sorted.
+func TransformerKinds() []string {
+	transformerMu.RLock()
+	defer transformerMu.RUnlock()
+	kinds := make([]string, 0, len(transformerRegistry)+len(requestTransformerRegistry)+len(requestTransformerArgs))
+	for k := range transformerRegistry {
+		kinds = append(kinds, k)
+	}
+	for k := range requestTransformerRegistry {
+		kinds = append(kinds, k)
+	}
+	for k := range requestTransformerArgs {
+		kinds = append(kinds, k)
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+// ValidateTransformerSpec checks that spec names a known kind and carries
+// the args the kind requires. A request transformer is built too, so errors
+// in its args, such as a malformed template, are reported.
+func ValidateTransformerSpec(spec TransformerSpec) error {
+	if spec.Host == "" {
+		return fmt.Errorf("transformer %q: host is required", spec.Kind)
+	}
+	transformerMu.RLock()
+	rk, isRequest := requestTransformerRegistry[spec.Kind]
+	transformerMu.RUnlock()
+	if isRequest {
+		for _, arg := range rk.requiredArgs {
+			if spec.Args[arg] == "" {
+				return fmt.Errorf("transformer %q for %s: missing required arg %q", spec.Kind, spec.Host, arg)
+			}
+		}
+		if _, err := buildRequestTransformer(spec); err != nil {
+			return fmt.Errorf("transformer %q for %s: %w", spec.Kind, spec.Host, err)
+		}
+		return nil
+	}
+	required, ok := requestTransformerArgs[spec.Kind]
+	if !ok {
+		transformerMu.RLock()
+		k, found := transformerRegistry[spec.Kind]
+		transformerMu.RUnlock()
+		if !found {
+			return fmt.Errorf("unknown transformer kind %q (available: %s)", spec.Kind, strings.Join(TransformerKinds(), ", "))
+		}
+		required = k.requiredArgs
+	}
+	for _, arg := range required {
+		if spec.Args[arg] == "" {
+			return fmt.Errorf("transformer %q for %s: missing required arg %q", spec.Kind, spec.Host, arg)
+		}
+	}
+	return nil
+}
+
+// ApplyTransformerSpec validates spec and configures it on rc: request header
+// kinds become extra or removed headers, other kinds are recorded in
+// rc.TransformerSpecs for the daemon to build.
+func ApplyTransformerSpec(rc *RunContext, spec TransformerSpec) error {
+	if err := ValidateTransformerSpec(spec); err != nil {
+		return err
+	}
+	switch spec.Kind {
+	case TransformRequestHeaderSet:
+		rc.AddExtraHeader(spec.Host, spec.Args["name"], spec.Args["value"])
+	case TransformRequestHeaderRemove:
+		rc.RemoveRequestHeader(spec.Host, spec.Args["name"])
+	default:
+		rc.mu.Lock()
+		rc.TransformerSpecs = append(rc.TransformerSpecs, spec)
+		rc.mu.Unlock()
+	}
+	return nil
+}
+
+// buildTransformer constructs the response transformer for spec, or nil if
+// spec is a request transformer, the kind is unknown (e.g. a spec persisted
+// by a newer daemon), or the factory declines.
+func buildTransformer(rc *RunContext, spec TransformerSpec) credential.ResponseTransformer {
+	transformerMu.RLock()
+	k, ok := transformerRegistry[spec.Kind]
+	_, isRequest := requestTransformerRegistry[spec.Kind]
+	transformerMu.RUnlock()
+	if isRequest {
+		return nil
+	}
+	if !ok {
+		log.Warn("unknown response transformer kind", "kind", spec.Kind, "host", spec.Host, "run_id", rc.RunID)
+		return nil
+	}
+	tf, err := k.factory(rc, spec)
+	if err != nil {
+		log.Warn("failed to build response transformer", "kind", spec.Kind, "host", spec.Host, "run_id", rc.RunID, "error", err)
+		return nil
+	}
+	return tf
+}
+
+func init() {
+	RegisterTransformer(TransformOAuthEndpointWorkaround, nil, func(*RunContext, TransformerSpec) (credential.ResponseTransformer, error) {
+		return newOAuthEndpointTransformer(), nil
+	})
+	RegisterTransformer(TransformResponseScrub, nil, func(rc *RunContext, spec TransformerSpec) (credential.ResponseTransformer, error) {
+		ts, ok := rc.TokenSubstitutions[spec.Host]
+		if !ok {
+			// Fall back to hostname without port (credentials are registered by
+			// hostname only, but spec.Host may include a port).
+			if h, _, _ := net.SplitHostPort(spec.Host); h != "" {
+				ts, ok = rc.TokenSubstitutions[h]
+			}
+		}
+		if !ok {
+			return nil, fmt.Errorf("no matching token substitution")
+		}
+		return newResponseScrubber(ts.RealToken, ts.Placeholder), nil
+	})
+	RegisterTransformer(TransformResponseHeaderSet, []string{"name", "value"}, func(_ *RunContext, spec TransformerSpec) (credential.ResponseTransformer, error) {
+		return newResponseHeaderTransformer(spec.Args["name"], spec.Args["value"], false), nil
+	})
+	RegisterTransformer(TransformResponseHeaderRemove, []string{"name"}, func(_ *RunContext, spec TransformerSpec) (credential.ResponseTransformer, error) {
+		return newResponseHeaderTransformer(spec.Args["name"], "", true), nil
+	})
+	RegisterTransformer(TransformResponseBodyReplace, []string{"old"}, func(_ *RunContext, spec TransformerSpec) (credential.ResponseTransformer, error) {
+		return newBodyReplacer(spec.Args["old"], spec.Args["new"]), nil
+	})
+	RegisterTransformer(TransformStripeLiveModeBlock, nil, func(*RunContext, TransformerSpec) (credential.ResponseTransformer, error) {
+		return stripe.BlockLiveMode, nil
+	})
+}
+
 // newOAuthEndpointTransformer creates a response transformer that handles 403 errors
 // on OAuth endpoints by returning empty success responses. This prevents Claude Code
 // from crashing when using long-lived tokens that lack the user:profile scope.
@@ -23,6 +213,44 @@ func newOAuthEndpointTransformer() func(req, resp interface{}) (interface{}, boo
 	return claude.CreateOAuthEndpointTransformer()
 }
 
+// newResponseHeaderTransformer sets (or, with remove, deletes) a response header.
+func newResponseHeaderTransformer(name, value string, remove bool) func(req, resp interface{}) (interface{}, bool) {
+	return func(_, respInterface interface{}) (interface{}, bool) {
+		resp, ok := respInterface.(*http.Response)
+		if !ok {
+			return respInterface, false
+		}
+		if resp.Header == nil {
+			resp.Header = make(http.Header)
+		}
+		if remove {
+			if resp.Header.Get(name) == "" {
+				return resp, false
+			}
+			resp.Header.Del(name)
+		} else {
+			resp.Header.Set(name, value)
+		}
+		return resp, true
+	}
+}
+
+// newBodyReplacer creates a response transformer that replaces every
+// occurrence of old with replacement in text and JSON response bodies up to
+// maxScrubBodySize.
+func newBodyReplacer(old, replacement string) func(req, resp interface{}) (interface{}, bool) {
+	return func(_, respInterface interface{}) (interface{}, bool) {
+		resp, ok := respInterface.(*http.Response)
+		if !ok {
+			return respInterface, false
+		}
+		changed := rewriteTextBody(resp, func(body []byte) []byte {
+			return bytes.ReplaceAll(body, []byte(old), []byte(replacement))
+		})
+		return resp, changed
+	}
+}
+
 // newResponseScrubber creates a response transformer that replaces real tokens
 // with placeholders in response bodies, preventing credential leakage.
 //
@@ -30,41 +258,57 @@ func newOAuthEndpointTransformer() func(req, resp interface{}) (interface{}, boo
 func newResponseScrubber(realToken, placeholder string) func(req, resp interface{}) (interface{}, bool) {
 	return func(_, respInterface interface{}) (interface{}, bool) {
 		resp, ok := respInterface.(*http.Response)
-		if !ok || resp.Body == nil {
+		if !ok {
 			return respInterface, false
 		}
+		tokenBytes := []byte(realToken)
+		changed := rewriteTextBody(resp, func(body []byte) []byte {
+			scrubbed := bytes.ReplaceAll(body, tokenBytes, []byte(placeholder))
+			if !bytes.Equal(body, scrubbed) {
+				log.Debug("scrubbed credential from response body",
+					"subsystem", "daemon",
+					"placeholder", placeholder,
+					"bodyLen", len(body),
+					"occurrences", bytes.Count(body, tokenBytes),
+				)
+			}
+			return scrubbed
+		})
+		return resp, changed
+	}
+}
 
-		ct := resp.Header.Get("Content-Type")
-		if ct != "" && !bytes.Contains([]byte(ct), []byte("json")) && !bytes.Contains([]byte(ct), []byte("text")) {
-			return resp, false
-		}
-
-		if resp.ContentLength > maxScrubBodySize {
-			return resp, false
-		}
+// rewriteTextBody applies fn to a text or JSON response body of at most
+// maxScrubBodySize bytes and reports whether the body changed. Other bodies
+// are left untouched.
+func rewriteTextBody(resp *http.Response, fn func([]byte) []byte) bool {
+	if resp.Body == nil {
+		return false
+	}
 
-		body, err := io.ReadAll(io.LimitReader(resp.Body, maxScrubBodySize))
-		resp.Body.Close()
-		if err != nil {
-			resp.Body = io.NopCloser(bytes.NewReader(body))
-			return resp, false
-		}
+	ct := resp.Header.Get("Content-Type")
+	if ct != "" && !bytes.Contains([]byte(ct), []byte("json")) && !bytes.Contains([]byte(ct), []byte("text")) {
+		return false
+	}
 
-		tokenBytes := []byte(realToken)
-		scrubbed := bytes.ReplaceAll(body, tokenBytes, []byte(placeholder))
-		if !bytes.Equal(body, scrubbed) {
-			log.Debug("scrubbed credential from response body",
-				"subsystem", "daemon",
-				"placeholder", placeholder,
-				"bodyLen", len(body),
-				"occurrences", bytes.Count(body, tokenBytes),
-			)
-			resp.Body = io.NopCloser(bytes.NewReader(scrubbed))
-			resp.ContentLength = int64(len(scrubbed))
-			return resp, true
-		}
+	if resp.ContentLength > maxScrubBodySize {
+		return false
+	}
 
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxScrubBodySize))
+	resp.Body.Close()
+	if err != nil {
 		resp.Body = io.NopCloser(bytes.NewReader(body))
-		return resp, false
+		return false
+	}
+
+	rewritten := fn(body)
+	if !bytes.Equal(body, rewritten) {
+		resp.Body = io.NopCloser(bytes.NewReader(rewritten))
+		resp.ContentLength = int64(len(rewritten))
+		return true
 	}
+
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	return false
 }
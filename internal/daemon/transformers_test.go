@@ -0,0 +1,235 @@
+package daemon
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestApplyTransformerSpec_RequestHeaders(t *testing.T) {
+	rc := NewRunContext("run_tf")
+	if err := ApplyTransformerSpec(rc, TransformerSpec{
+		Host: "api.example.com",
+		Kind: TransformRequestHeaderSet,
+		Args: map[string]string{"name": "X-Team", "value": "platform"},
+	}); err != nil {
+		t.Fatalf("ApplyTransformerSpec(set): %v", err)
+	}
+	if err := ApplyTransformerSpec(rc, TransformerSpec{
+		Host: "api.example.com",
+		Kind: TransformRequestHeaderRemove,
+		Args: map[string]string{"name": "X-Debug"},
+	}); err != nil {
+		t.Fatalf("ApplyTransformerSpec(remove): %v", err)
+	}
+
+	if got := rc.GetExtraHeaders("api.example.com"); len(got) != 1 || got[0].Name != "X-Team" || got[0].Value != "platform" {
+		t.Errorf("ExtraHeaders = %+v, want X-Team: platform", got)
+	}
+	if got := rc.RemoveHeaders["api.example.com"]; len(got) != 1 || got[0] != "X-Debug" {
+		t.Errorf("RemoveHeaders = %v, want [X-Debug]", got)
+	}
+	if len(rc.TransformerSpecs) != 0 {
+		t.Errorf("TransformerSpecs = %v, want none for request kinds", rc.TransformerSpecs)
+	}
+}
+
+func TestApplyTransformerSpec_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    TransformerSpec
+		wantErr string
+	}{
+		{"unknown kind", TransformerSpec{Host: "a.com", Kind: "path-rewrite"}, "unknown transformer kind"},
+		{"missing arg", TransformerSpec{Host: "a.com", Kind: TransformResponseHeaderSet, Args: map[string]string{"name": "X"}}, `missing required arg "value"`},
+		{"missing host", TransformerSpec{Kind: TransformRequestHeaderRemove, Args: map[string]string{"name": "X"}}, "host is required"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ApplyTransformerSpec(NewRunContext("run_tf"), tt.spec)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("err = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestToProxyContextData_RegistryTransformers(t *testing.T) {
+	rc := NewRunContext("run_tf")
+	rc.TransformerSpecs = []TransformerSpec{
+		{Host: "api.example.com", Kind: TransformResponseHeaderRemove, Args: map[string]string{"name": "Set-Cookie"}},
+		{Host: "api.example.com", Kind: TransformResponseBodyReplace, Args: map[string]string{"old": "internal.corp", "new": "example.com"}},
+		{Host: "api.example.com", Kind: "from-a-newer-cli"},
+	}
+
+	d := rc.ToProxyContextData()
+	tfs := d.ResponseTransformers["api.example.com"]
+	if len(tfs) != 2 {
+		t.Fatalf("got %d transformers, want 2 (unknown kind skipped)", len(tfs))
+	}
+
+	resp := &http.Response{
+		Header: http.Header{"Set-Cookie": {"a=b"}, "Content-Type": {"application/json"}},
+		Body:   io.NopCloser(strings.NewReader(`{"host":"internal.corp"}`)),
+	}
+	for _, tf := range tfs {
+		out, _ := tf(&http.Request{}, resp)
+		resp = out.(*http.Response)
+	}
+	if resp.Header.Get("Set-Cookie") != "" {
+		t.Error("Set-Cookie header was not removed")
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != `{"host":"example.com"}` {
+		t.Errorf("body = %s, want replaced host", body)
+	}
+}
+
+func TestResponseScrubber_RegistryLookupWithPort(t *testing.T) {
+	rc := NewRunContext("run_tf")
+	rc.SetTokenSubstitution("api.example.com", "moat-placeholder", "real-secret")
+	rc.TransformerSpecs = []TransformerSpec{{Host: "api.example.com:443", Kind: TransformResponseScrub}}
+
+	tfs := rc.ToProxyContextData().ResponseTransformers["api.example.com:443"]
+	if len(tfs) != 1 {
+		t.Fatalf("got %d transformers, want 1", len(tfs))
+	}
+	resp := &http.Response{
+		Header: http.Header{"Content-Type": {"text/plain"}},
+		Body:   io.NopCloser(strings.NewReader("token=real-secret")),
+	}
+	out, changed := tfs[0](&http.Request{}, resp)
+	body, _ := io.ReadAll(out.(*http.Response).Body)
+	if !changed || string(body) != "token=moat-placeholder" {
+		t.Errorf("body = %q (changed=%v), want scrubbed", body, changed)
+	}
+}
+
+func TestRequestTransformers(t *testing.T) {
+	rc := NewRunContext("run_tf")
+	for _, spec := range []TransformerSpec{
+		{Host: "api.example.com", Kind: TransformRequestPathRewrite, Args: map[string]string{"from": "/v1/", "to": "/api/v2/"}},
+		{Host: "api.example.com", Kind: TransformRequestBodyTemplate, Args: map[string]string{"template": `{"model":"candidate","messages":{{json .JSON.messages}},"path":"{{.Path}}"}`}},
+	} {
+		if err := ApplyTransformerSpec(rc, spec); err != nil {
+			t.Fatalf("ApplyTransformerSpec(%s): %v", spec.Kind, err)
+		}
+	}
+	if tfs, _ := rc.requestTransformers("api.example.com", 443); len(tfs) != 2 {
+		t.Fatalf("got %d transformers for api.example.com, want 2", len(tfs))
+	}
+	if tfs, _ := rc.requestTransformers("other.example.com", 443); len(tfs) != 0 {
+		t.Errorf("got %d transformers for another host, want 0", len(tfs))
+	}
+	// Request kinds are not built as response transformers.
+	if d := rc.ToProxyContextData(); len(d.ResponseTransformers["api.example.com"]) != 0 {
+		t.Errorf("request kinds built as response transformers: %v", d.ResponseTransformers)
+	}
+
+	req, _ := http.NewRequest("POST", "https://api.example.com/v1/messages", strings.NewReader(`{"model":"x","messages":[{"role":"user"}]}`))
+	if d := rc.transformRequest(req, "api.example.com", 443); d != nil {
+		t.Fatalf("transformRequest: %+v", d)
+	}
+	body, _ := io.ReadAll(req.Body)
+	if req.URL.Path != "/api/v2/messages" {
+		t.Errorf("path = %q, want /api/v2/messages", req.URL.Path)
+	}
+	want := `{"model":"candidate","messages":[{"role":"user"}],"path":"/api/v2/messages"}`
+	if string(body) != want || req.ContentLength != int64(len(want)) {
+		t.Errorf("body = %s (length %d), want %s", body, req.ContentLength, want)
+	}
+
+	// Paths without the prefix are left alone.
+	req, _ = http.NewRequest("GET", "https://api.example.com/health", nil)
+	rc.transformRequest(req, "api.example.com", 443)
+	if req.URL.Path != "/health" {
+		t.Errorf("path = %q, want /health", req.URL.Path)
+	}
+}
+
+func TestRequestTransformers_Errors(t *testing.T) {
+	for _, spec := range []TransformerSpec{
+		{Host: "a.com", Kind: TransformRequestPathRewrite, Args: map[string]string{"from": "v1"}},
+		{Host: "a.com", Kind: TransformRequestBodyTemplate, Args: map[string]string{"template": "{{.Body"}},
+		{Host: "a.com", Kind: TransformRequestBodyTemplate},
+	} {
+		if err := ValidateTransformerSpec(spec); err == nil {
+			t.Errorf("ValidateTransformerSpec(%+v) = nil, want error", spec)
+		}
+	}
+
+	// A template that fails on a request refuses it.
+	rc := NewRunContext("run_tf")
+	rc.TransformerSpecs = []TransformerSpec{{Host: "a.com", Kind: TransformRequestBodyTemplate, Args: map[string]string{"template": `{{index .JSON "k" | len}}`}}}
+	req, _ := http.NewRequest("POST", "https://a.com/", strings.NewReader(`{"k":1}`))
+	if d := rc.transformRequest(req, "a.com", 443); d == nil || d.kind != "request-transform" || d.status != http.StatusBadGateway {
+		t.Errorf("denial = %+v, want a request-transform refusal", d)
+	}
+}
+
+func TestStarlarkTransformer(t *testing.T) {
+	rc := NewRunContext("run_tf")
+	script := `
+def transform(req):
+    if "Proxy-Authorization" in req["headers"]:
+        fail("proxy token visible")
+    body = json.decode(req["body"])
+    body["model"] = "candidate"
+    req["body"] = json.encode(body)
+    req["path"] = req["path"].replace("/v1/", "/v2/")
+    req["headers"]["X-Variant"] = "b"
+    req["headers"].pop("X-Debug")
+`
+	if err := ApplyTransformerSpec(rc, TransformerSpec{Host: "api.example.com", Kind: TransformRequestStarlark, Args: map[string]string{"script": script}}); err != nil {
+		t.Fatalf("ApplyTransformerSpec: %v", err)
+	}
+
+	req, _ := http.NewRequest("POST", "https://api.example.com/v1/messages?beta=1", strings.NewReader(`{"model":"x"}`))
+	req.Header.Set("X-Debug", "1")
+	req.Header.Set("Proxy-Authorization", "Basic cnVuOnRvaw==")
+	if d := rc.transformRequest(req, "api.example.com", 443); d != nil {
+		t.Fatalf("transformRequest: %+v", d)
+	}
+	body, _ := io.ReadAll(req.Body)
+	if want := `{"model":"candidate"}`; string(body) != want || req.ContentLength != int64(len(want)) {
+		t.Errorf("body = %s (length %d), want %s", body, req.ContentLength, want)
+	}
+	if req.URL.Path != "/v2/messages" || req.URL.RawQuery != "beta=1" {
+		t.Errorf("URL = %s, want /v2/messages?beta=1", req.URL)
+	}
+	if req.Header.Get("X-Variant") != "b" || req.Header.Get("X-Debug") != "" || req.Header.Get("Proxy-Authorization") == "" {
+		t.Errorf("headers = %v, want X-Variant set, X-Debug removed, Proxy-Authorization kept", req.Header)
+	}
+}
+
+func TestStarlarkTransformer_Errors(t *testing.T) {
+	for name, script := range map[string]string{
+		"syntax error":  "def transform(req)\n    return req\n",
+		"no transform":  "x = 1\n",
+		"wrong arity":   "def transform():\n    pass\n",
+		"load":          "load('os.star', 'os')\ndef transform(req):\n    pass\n",
+		"endless setup": "def f():\n    for i in range(100000000):\n        pass\nf()\ndef transform(req):\n    pass\n",
+	} {
+		spec := TransformerSpec{Host: "a.com", Kind: TransformRequestStarlark, Args: map[string]string{"script": script}}
+		if err := ValidateTransformerSpec(spec); err == nil {
+			t.Errorf("%s: ValidateTransformerSpec = nil, want error", name)
+		}
+	}
+
+	// A script that fails, runs too long, or breaks the rules on a request
+	// refuses it.
+	for name, script := range map[string]string{
+		"fail":        "def transform(req):\n    fail('no')\n",
+		"endless":     "def transform(req):\n    for i in range(100000000):\n        pass\n",
+		"change host": "def transform(req):\n    req['host'] = 'evil.example.com'\n",
+		"bad result":  "def transform(req):\n    return 'x'\n",
+	} {
+		rc := NewRunContext("run_tf")
+		rc.TransformerSpecs = []TransformerSpec{{Host: "a.com", Kind: TransformRequestStarlark, Args: map[string]string{"script": script}}}
+		req, _ := http.NewRequest("POST", "https://a.com/", strings.NewReader(`{}`))
+		if d := rc.transformRequest(req, "a.com", 443); d == nil || d.kind != "request-transform" {
+			t.Errorf("%s: denial = %+v, want a request-transform refusal", name, d)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package daemon
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"github.com/majorcontext/gatekeeper/proxy"
+)
+
+// UploadGuard caps the request body size a run may send to each host, to
+// blunt bulk exfiltration of repository contents. A request whose
+// Content-Length is over its host's cap is refused before it is forwarded;
+// a body of unknown length is forwarded until it passes the cap, and the
+// request then fails.
+type UploadGuard struct {
+	// Max caps uploads, in bytes, to hosts not in Hosts. Zero means no cap.
+	Max int64 `json:"max,omitempty"`
+	// Hosts maps host patterns to caps in bytes, overriding Max. Zero means
+	// no cap for the host.
+	Hosts map[string]int64 `json:"hosts,omitempty"`
+}
+
+// NewUploadGuard returns a guard with caps given in megabytes, as in
+// moat.yaml's network.uploads.
+func NewUploadGuard(maxMB int, hostsMB map[string]int) *UploadGuard {
+	g := &UploadGuard{Max: int64(maxMB) << 20}
+	if len(hostsMB) > 0 {
+		g.Hosts = make(map[string]int64, len(hostsMB))
+		for host, mb := range hostsMB {
+			g.Hosts[strings.ToLower(host)] = int64(mb) << 20
+		}
+	}
+	return g
+}
+
+// Limit returns the cap for host:port, or 0 when uploads to it are not
+// capped. An exact host entry wins over a pattern; among patterns, the
+// longest matching one applies.
+func (g *UploadGuard) Limit(host string, port int) int64 {
+	host = strings.ToLower(host)
+	if limit, ok := g.Hosts[host]; ok {
+		return limit
+	}
+	patterns := make([]string, 0, len(g.Hosts))
+	for pattern := range g.Hosts {
+		patterns = append(patterns, pattern)
+	}
+	sort.Slice(patterns, func(i, j int) bool {
+		if len(patterns[i]) != len(patterns[j]) {
+			return len(patterns[i]) > len(patterns[j])
+		}
+		return patterns[i] < patterns[j]
+	})
+	for _, pattern := range patterns {
+		if hostMatchAdapter(pattern, host, port) {
+			return g.Hosts[pattern]
+		}
+	}
+	return g.Max
+}
+
+// uploadDenyReason prefixes the deny reason of requests refused for their
+// body size.
+const uploadDenyReason = "Upload exceeds limit: "
+
+// errUploadLimit is returned by reads of a request body past its cap.
+var errUploadLimit = errors.New("request body exceeds upload limit")
+
+// check refuses req, a request to host:port, if its Content-Length is over
+// the host's cap. A body of unknown length is replaced with one that fails
+// once the cap is passed; check returns it so the caller can tell.
+func (g *UploadGuard) check(req *http.Request, host string, port int) (*uploadLimiter, *denial) {
+	limit := g.Limit(host, port)
+	if limit == 0 || req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	if req.ContentLength > limit {
+		return nil, uploadDenial(limit)
+	}
+	if req.ContentLength >= 0 {
+		return nil, nil
+	}
+	l := &uploadLimiter{body: req.Body, limit: limit, left: limit}
+	req.Body = l
+	return l, nil
+}
+
+// uploadDenial is the denial for a request body over limit bytes.
+func uploadDenial(limit int64) *denial {
+	return &denial{
+		kind:    "upload-limit",
+		status:  http.StatusRequestEntityTooLarge,
+		reason:  fmt.Sprintf("%s%d bytes", uploadDenyReason, limit),
+		message: fmt.Sprintf("the request body is over this host's %.1f MB upload cap (network.uploads)", float64(limit)/(1<<20)),
+	}
+}
+
+// uploadLimiter is a request body that fails with errUploadLimit once more
+// than left bytes have been read.
+type uploadLimiter struct {
+	body  io.ReadCloser
+	limit int64
+	left  int64
+	over  atomic.Bool
+}
+
+func (l *uploadLimiter) Read(p []byte) (int, error) {
+	if l.over.Load() {
+		return 0, errUploadLimit
+	}
+	n, err := l.body.Read(p)
+	l.left -= int64(n)
+	if l.left < 0 {
+		l.over.Store(true)
+		return 0, errUploadLimit
+	}
+	return n, err
+}
+
+func (l *uploadLimiter) Close() error {
+	return l.body.Close()
+}
+
+// exceeded reports whether the body passed its cap.
+func (l *uploadLimiter) exceeded() bool {
+	return l != nil && l.over.Load()
+}
+
+// uploadLimit returns the cap a request refused for its body size exceeded,
+// or 0 if the request was not refused for its size.
+func uploadLimit(rc *RunContext, data proxy.RequestLogData) int64 {
+	if rc == nil || !data.Denied || !strings.HasPrefix(data.DenyReason, uploadDenyReason) {
+		return 0
+	}
+	rc.mu.RLock()
+	guard := rc.UploadGuard
+	rc.mu.RUnlock()
+	if guard == nil {
+		return 0
+	}
+	host, port := decisionHostPort(data)
+	return guard.Limit(host, port)
+}
@@ -0,0 +1,99 @@
+package daemon
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/majorcontext/gatekeeper/proxy"
+)
+
+func TestUploadGuard_Limit(t *testing.T) {
+	g := NewUploadGuard(25, map[string]int{
+		"uploads.github.com": 500,
+		"*.googleapis.com":   0,
+		"*.example.com":      10,
+		"*.big.example.com":  100,
+	})
+	tests := []struct {
+		host string
+		want int64
+	}{
+		{"uploads.github.com", 500 << 20},
+		{"Uploads.GitHub.com", 500 << 20},
+		{"storage.googleapis.com", 0},
+		{"a.example.com", 10 << 20},
+		{"a.big.example.com", 100 << 20},
+		{"paste.example.org", 25 << 20},
+	}
+	for _, tt := range tests {
+		if got := g.Limit(tt.host, 443); got != tt.want {
+			t.Errorf("Limit(%q) = %d, want %d", tt.host, got, tt.want)
+		}
+	}
+}
+
+func TestUploadGuard_RefusesOversizedBodies(t *testing.T) {
+	rc := NewRunContext("run_test")
+	rc.UploadGuard = NewUploadGuard(1, map[string]int{"uploads.github.com": 0})
+	if !rc.guardsHost("paste.example.com", 443) || rc.guardsHost("uploads.github.com", 443) {
+		t.Fatal("guardsHost does not follow the upload caps")
+	}
+
+	sized := func(url string, size int) *http.Request {
+		return httptest.NewRequest("POST", url, bytes.NewReader(make([]byte, size)))
+	}
+	if _, d := rc.checkRequest(sized("https://paste.example.com/upload", 1<<20), "paste.example.com", 443); d != nil {
+		t.Errorf("upload at the cap was refused: %+v", d)
+	}
+	if _, d := rc.checkRequest(sized("https://uploads.github.com/assets", 2<<20), "uploads.github.com", 443); d != nil {
+		t.Errorf("upload to an uncapped host was refused: %+v", d)
+	}
+	_, d := rc.checkRequest(sized("https://paste.example.com/upload", 2<<20), "paste.example.com", 443)
+	if d == nil {
+		t.Fatal("upload over the cap was allowed")
+	}
+	if d.kind != "upload-limit" || d.status != http.StatusRequestEntityTooLarge || d.reason != "Upload exceeds limit: 1048576 bytes" {
+		t.Errorf("denial = %+v", d)
+	}
+
+	// The network log records the cap a refused request exceeded.
+	logged := NewNetworkRequest(rc, proxy.RequestLogData{
+		Method: "POST", URL: "https://paste.example.com:443/upload", Host: "paste.example.com",
+		Denied: true, DenyReason: d.reason,
+	})
+	if logged.UploadLimit != 1<<20 {
+		t.Errorf("UploadLimit = %d, want %d", logged.UploadLimit, 1<<20)
+	}
+}
+
+func TestUploadGuard_CutsOffBodiesOfUnknownLength(t *testing.T) {
+	rc := NewRunContext("run_test")
+	rc.UploadGuard = NewUploadGuard(1, nil)
+
+	req := chunkedPost(t, 1<<20)
+	adm, d := rc.checkRequest(req, "paste.example.com", 443)
+	if d != nil {
+		t.Fatalf("body of unknown length was refused: %+v", d)
+	}
+	if n, err := io.Copy(io.Discard, req.Body); err != nil || n != 1<<20 || adm.upload.exceeded() {
+		t.Errorf("reading a body at the cap = %d, %v", n, err)
+	}
+
+	req = chunkedPost(t, 1<<20+1)
+	adm, _ = rc.checkRequest(req, "paste.example.com", 443)
+	if _, err := io.Copy(io.Discard, req.Body); !errors.Is(err, errUploadLimit) || !adm.upload.exceeded() {
+		t.Errorf("reading a body over the cap: err = %v", err)
+	}
+}
+
+// chunkedPost returns a request with a body of size bytes and no Content-Length.
+func chunkedPost(t *testing.T, size int) *http.Request {
+	t.Helper()
+	req := httptest.NewRequest("POST", "https://paste.example.com/upload", io.NopCloser(bytes.NewReader(make([]byte, size))))
+	req.ContentLength = -1
+	return req
+}
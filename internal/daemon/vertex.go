@@ -0,0 +1,64 @@
+package daemon
+
+import (
+	"context"
+	"time"
+
+	"github.com/majorcontext/moat/internal/log"
+	gcpprov "github.com/majorcontext/moat/internal/providers/gcp"
+)
+
+// vertexRefreshBuffer is how long before expiry the Vertex AI access token
+// is replaced.
+const vertexRefreshBuffer = 5 * time.Minute
+
+// vertexRetryInterval is how long to wait after a failed token fetch.
+const vertexRetryInterval = time.Minute
+
+// fetchVertexToken mints an access token from the run's gcp grant. A
+// variable so tests can stub it.
+var fetchVertexToken = func(ctx context.Context, rc *RunContext) (string, time.Time, error) {
+	cfg, err := gcpGrantConfig(rc)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return gcpprov.FetchAccessToken(ctx, cfg.Meta, []string{gcpprov.ScopeCloudPlatform})
+}
+
+// startVertexAuth injects a Google OAuth access token minted from the gcp
+// grant into requests to the Vertex AI host, and replaces it before it
+// expires until ctx is done. The first token is fetched before returning so
+// the run's first request is authenticated.
+func startVertexAuth(ctx context.Context, rc *RunContext, host string) {
+	next := setVertexToken(ctx, rc, host)
+	go func() {
+		for {
+			timer := time.NewTimer(time.Until(next))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			case <-rc.Woken():
+				timer.Stop()
+			}
+			next = setVertexToken(ctx, rc, host)
+		}
+	}()
+}
+
+// setVertexToken fetches a token, injects it for host, and returns when the
+// next fetch is due.
+func setVertexToken(ctx context.Context, rc *RunContext, host string) time.Time {
+	token, expiry, err := fetchVertexToken(ctx, rc)
+	if err != nil {
+		// Requests fail with Vertex AI's 401 until a fetch succeeds.
+		log.Warn("vertex: cannot mint access token", "run_id", rc.RunID, "error", err)
+		return time.Now().Add(vertexRetryInterval)
+	}
+	rc.SetCredentialWithGrant(host, "Authorization", "Bearer "+token, "gcp")
+	if next := expiry.Add(-vertexRefreshBuffer); next.After(time.Now().Add(vertexRetryInterval)) {
+		return next
+	}
+	return time.Now().Add(vertexRetryInterval)
+}
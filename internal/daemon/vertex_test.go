@@ -0,0 +1,61 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestStartVertexAuth(t *testing.T) {
+	orig := fetchVertexToken
+	t.Cleanup(func() { fetchVertexToken = orig })
+
+	calls := 0
+	fetchVertexToken = func(ctx context.Context, rc *RunContext) (string, time.Time, error) {
+		calls++
+		return "ya29.token", time.Now().Add(time.Hour), nil
+	}
+
+	rc := NewRunContext("run_1")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	startVertexAuth(ctx, rc, "us-east5-aiplatform.googleapis.com")
+
+	cred, ok := rc.GetCredential("us-east5-aiplatform.googleapis.com")
+	if !ok {
+		t.Fatal("no credential injected before startVertexAuth returned")
+	}
+	if cred.Name != "Authorization" || cred.Value != "Bearer ya29.token" || cred.Grant != "gcp" {
+		t.Errorf("credential = %+v", cred)
+	}
+	if calls != 1 {
+		t.Errorf("fetch calls = %d, want 1", calls)
+	}
+}
+
+func TestSetVertexTokenSchedule(t *testing.T) {
+	orig := fetchVertexToken
+	t.Cleanup(func() { fetchVertexToken = orig })
+	rc := NewRunContext("run_1")
+
+	fetchVertexToken = func(ctx context.Context, rc *RunContext) (string, time.Time, error) {
+		return "tok", time.Now().Add(time.Hour), nil
+	}
+	next := setVertexToken(context.Background(), rc, "aiplatform.googleapis.com")
+	if d := time.Until(next); d < 50*time.Minute || d > 56*time.Minute {
+		t.Errorf("next refresh in %v, want about 55m", d)
+	}
+
+	fetchVertexToken = func(ctx context.Context, rc *RunContext) (string, time.Time, error) {
+		return "", time.Time{}, errors.New("no gcp grant")
+	}
+	next = setVertexToken(context.Background(), rc, "aiplatform.googleapis.com")
+	if d := time.Until(next); d > vertexRetryInterval {
+		t.Errorf("retry in %v after failure, want at most %v", d, vertexRetryInterval)
+	}
+	// The last good token stays in place.
+	if cred, _ := rc.GetCredential("aiplatform.googleapis.com"); cred.Value != "Bearer tok" {
+		t.Errorf("credential after failed refresh = %q", cred.Value)
+	}
+}
@@ -31,8 +31,8 @@ func ImageTag(deps []Dependency, opts *ImageSpec) string {
 	}
 	sort.Strings(sorted)
 
-	// Build the hash input
-	hashInput := strings.Join(sorted, ",")
+	// Build the hash input. Images predating tini as PID 1 must rebuild.
+	hashInput := strings.Join(sorted, ",") + ",pid1:tini"
 	if opts.BaseImage != "" {
 		hashInput += ",base:" + opts.BaseImage
 	}
@@ -51,6 +51,27 @@ func ImageTag(deps []Dependency, opts *ImageSpec) string {
 	if opts.NeedsClipboard {
 		hashInput += ",clipboard:xvfb"
 	}
+	if opts.NeedsBrowserTrust {
+		hashInput += ",browser:nss"
+	}
+	if opts.NeedsDisplay {
+		hashInput += ",display:novnc"
+	}
+	if opts.NeedsSSHServer {
+		hashInput += ",sshd"
+	}
+	if opts.NeedsDevices {
+		hashInput += ",devices"
+	}
+	if opts.NeedsTimezone {
+		hashInput += ",tzdata"
+	}
+	if opts.NeedsFakeClock {
+		hashInput += ",clock:libfaketime"
+	}
+	if opts.Locale != "" {
+		hashInput += ",locale:" + opts.Locale
+	}
 
 	// When the moat-init entrypoint is used, hash the script contents so that
 	// changes to moat-init.sh (e.g. adding /etc/hosts injection for synthetic
@@ -69,6 +90,10 @@ func ImageTag(deps []Dependency, opts *ImageSpec) string {
 		scriptHash := sha256.Sum256([]byte(MoatInitScript))
 		hashInput += ",moat-init:" + hex.EncodeToString(scriptHash[:])[:8]
 	}
+	if opts.NeedsGitIdentity {
+		hookHash := sha256.Sum256([]byte(MoatGitHookScript))
+		hashInput += ",git-hooks:" + hex.EncodeToString(hookHash[:])[:8]
+	}
 
 	// Include plugins in hash (different plugins = different image).
 	// Note: Plugin format validation happens in claude.GenerateDockerfileSnippet()
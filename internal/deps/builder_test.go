@@ -90,6 +90,24 @@ func TestImageTagWithFirewall(t *testing.T) {
 	}
 }
 
+func TestImageTagWithClockAndLocale(t *testing.T) {
+	deps := []Dependency{{Name: "python", Version: "3.11"}}
+	tags := map[string]string{
+		"none":     ImageTag(deps, nil),
+		"timezone": ImageTag(deps, &ImageSpec{NeedsTimezone: true}),
+		"clock":    ImageTag(deps, &ImageSpec{NeedsFakeClock: true}),
+		"en_US":    ImageTag(deps, &ImageSpec{Locale: "en_US.UTF-8"}),
+		"ja_JP":    ImageTag(deps, &ImageSpec{Locale: "ja_JP.UTF-8"}),
+	}
+	seen := map[string]string{}
+	for name, tag := range tags {
+		if other, ok := seen[tag]; ok {
+			t.Errorf("%s and %s share tag %s", name, other, tag)
+		}
+		seen[tag] = name
+	}
+}
+
 func TestImageTagWithBaseImage(t *testing.T) {
 	// Base image should affect tag
 	tagDefault := ImageTag(nil, nil)
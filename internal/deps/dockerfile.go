@@ -3,6 +3,8 @@ package deps
 
 import (
 	"fmt"
+	"path"
+	"slices"
 	"sort"
 	"strings"
 
@@ -56,6 +58,12 @@ var knownSSHHostKeys = map[string][]string{
 	},
 }
 
+// KnownSSHHostKeys returns moat's embedded known_hosts entries for host, or
+// nil if host is not one of the well-known hosts moat ships keys for.
+func KnownSSHHostKeys(host string) []string {
+	return knownSSHHostKeys[host]
+}
+
 // runtimeBaseImage returns the official Docker image for a runtime, or empty string
 // if we should fall back to installing on Debian.
 func runtimeBaseImage(name, version string) string {
@@ -126,7 +134,8 @@ func categorizeDeps(deps []Dependency) categorizedDeps {
 		spec, _ := GetSpec(dep.Name)
 		switch spec.Type {
 		case TypeApt:
-			c.aptPkgs = append(c.aptPkgs, spec.Package)
+			// A single entry may name several packages (e.g., font bundles).
+			c.aptPkgs = append(c.aptPkgs, strings.Fields(spec.Package)...)
 		case TypeRuntime:
 			c.runtimes = append(c.runtimes, dep)
 		case TypeGithubBinary:
@@ -209,8 +218,34 @@ func GenerateDockerfile(deps []Dependency, opts *ImageSpec) (*DockerfileResult,
 		c.aptPkgs = append(c.aptPkgs, "xvfb", "xclip")
 	}
 
+	// Virtual display served to the browser over noVNC
+	if opts.NeedsDisplay {
+		c.aptPkgs = append(c.aptPkgs, "xvfb", "x11vnc", "novnc", "websockify")
+	}
+
+	// SSH server for IDE attach
+	if opts.NeedsSSHServer {
+		c.aptPkgs = append(c.aptPkgs, "openssh-server")
+	}
+
+	// TZ needs zoneinfo; slim base images ship without it
+	if opts.NeedsTimezone {
+		c.aptPkgs = append(c.aptPkgs, "tzdata")
+	}
+	if opts.NeedsFakeClock {
+		c.aptPkgs = append(c.aptPkgs, "libfaketime")
+	}
+	// C.<codeset> is built into glibc; other locales need the locales package
+	if opts.Locale != "" && !strings.HasPrefix(opts.Locale, "C.") {
+		c.aptPkgs = append(c.aptPkgs, "locales")
+	}
+
 	// Write all sections
 	writeAllAptPackages(&b, c.aptPkgs, opts.useBuildKit())
+	writeFakeClock(&b, opts.NeedsFakeClock)
+	writeLocale(&b, opts.Locale)
+	writeDisplay(&b, opts.NeedsDisplay)
+	writeSSHServer(&b, opts.NeedsSSHServer)
 	writeUserSetup(&b)
 	writeDockerCLI(&b, c.dockerMode)
 	writeRuntimes(&b, c.runtimes, baseRuntime)
@@ -257,6 +292,8 @@ func GenerateDockerfile(deps []Dependency, opts *ImageSpec) (*DockerfileResult,
 	// User-defined build hooks
 	writeBuildHooks(&b, opts.Hooks)
 
+	writeGitHooks(&b, opts, contextFiles)
+
 	// Finalize with entrypoint and user setup
 	writeEntrypoint(&b, opts, c.dockerMode, contextFiles)
 
@@ -296,7 +333,76 @@ func selectBaseImage(runtimes []Dependency) (string, *Dependency) {
 
 // baseAptPackages are always installed regardless of user configuration.
 // iptables is NOT included here; it is added conditionally via NeedsFirewall.
-var baseAptPackages = []string{"ca-certificates", "curl", "gnupg", "gosu", "unzip"}
+// tini runs as PID 1 (see writeEntrypoint).
+var baseAptPackages = []string{"ca-certificates", "curl", "gnupg", "gosu", "tini", "unzip"}
+
+// TiniPath is the init that moat-built images run as PID 1.
+const TiniPath = "/usr/bin/tini"
+
+// FakeTimeLibPath is where images built with ImageSpec.NeedsFakeClock
+// provide libfaketime, independent of the Debian architecture triplet.
+const FakeTimeLibPath = "/usr/local/lib/faketime/libfaketime.so.1"
+
+// writeFakeClock links the architecture-specific libfaketime to
+// FakeTimeLibPath so the run can preload it with a fixed LD_PRELOAD.
+func writeFakeClock(b *strings.Builder, needed bool) {
+	if !needed {
+		return
+	}
+	b.WriteString("# Fake clock (libfaketime)\n")
+	b.WriteString("RUN mkdir -p " + path.Dir(FakeTimeLibPath) + " \\\n")
+	b.WriteString("    && ln -sf \"$(dpkg -L libfaketime | grep '/libfaketime.so.1$')\" " + FakeTimeLibPath + "\n\n")
+}
+
+// writeDisplay makes the noVNC viewer the index page, so the bare
+// gui.<agent>.localhost URL connects straight to the display.
+func writeDisplay(b *strings.Builder, needsDisplay bool) {
+	if !needsDisplay {
+		return
+	}
+	b.WriteString("# noVNC viewer\n")
+	b.WriteString(`RUN printf '%s' '<!DOCTYPE html><meta http-equiv="refresh" content="0; url=vnc.html?autoconnect=1&resize=scale">' \` + "\n")
+	b.WriteString("    > /usr/share/novnc/index.html\n\n")
+}
+
+// writeSSHServer removes the host keys generated when openssh-server was
+// installed: they would be shared by every container built from the image.
+// moat-init installs the run's own host key before starting sshd.
+func writeSSHServer(b *strings.Builder, needsSSHServer bool) {
+	if !needsSSHServer {
+		return
+	}
+	b.WriteString("# SSH server for IDE attach\n")
+	b.WriteString("RUN rm -f /etc/ssh/ssh_host_* && mkdir -p /run/sshd\n\n")
+}
+
+// writeLocale compiles the container locale and makes it the default.
+// locale is validated by config as language_TERRITORY.codeset[@modifier]
+// or C.<codeset>.
+func writeLocale(b *strings.Builder, locale string) {
+	if locale == "" {
+		return
+	}
+	b.WriteString("# Locale\n")
+	if !strings.HasPrefix(locale, "C.") {
+		name, modifier, _ := strings.Cut(locale, "@")
+		lang, codeset, _ := strings.Cut(name, ".")
+		if modifier != "" {
+			lang += "@" + modifier
+		}
+		fmt.Fprintf(b, "RUN localedef -i %s -c -f %s -A /usr/share/locale/locale.alias %s\n", lang, localeCharmap(codeset), locale)
+	}
+	fmt.Fprintf(b, "ENV LANG=%s\n\n", locale)
+}
+
+// localeCharmap maps a locale codeset to its glibc charmap name, accepting
+// the common "utf8" spelling for UTF-8.
+func localeCharmap(codeset string) string {
+	if strings.EqualFold(strings.ReplaceAll(codeset, "-", ""), "utf8") {
+		return "UTF-8"
+	}
+	return strings.ToUpper(codeset)
+}
 
 // writeAllAptPackages writes a single apt-get install layer combining base and user packages.
 // Uses BuildKit cache mounts for apt to speed up rebuilds when useBuildKit is true.
@@ -305,6 +411,8 @@ func writeAllAptPackages(b *strings.Builder, userPkgs []string, useBuildKit bool
 	allPkgs = append(allPkgs, baseAptPackages...)
 	allPkgs = append(allPkgs, userPkgs...)
 	sort.Strings(allPkgs)
+	// Clipboard and display both need xvfb; list each package once.
+	allPkgs = slices.Compact(allPkgs)
 
 	b.WriteString("# System packages\n")
 	if useBuildKit {
@@ -598,19 +706,55 @@ func formatHookCommand(cmd string) string {
 	return strings.Join(lines, " && \\\n    ")
 }
 
+// GitHooksDir is where images with git installed carry moat's hook
+// dispatcher. Runs point core.hooksPath here to protect branches.
+const GitHooksDir = "/usr/local/share/moat/git-hooks"
+
+// gitHookNames are the client-side hooks routed through the dispatcher. Every
+// one must be present: core.hooksPath replaces .git/hooks wholesale, so a
+// missing name would silently skip the repository's own hook.
+var gitHookNames = []string{
+	"applypatch-msg", "pre-applypatch", "post-applypatch",
+	"pre-commit", "pre-merge-commit", "prepare-commit-msg", "commit-msg", "post-commit",
+	"pre-rebase", "post-checkout", "post-merge", "pre-push", "post-rewrite",
+	"pre-auto-gc", "sendemail-validate",
+}
+
+// writeGitHooks installs the git hook dispatcher when the image carries the
+// host's git identity (i.e. git is a dependency).
+func writeGitHooks(b *strings.Builder, opts *ImageSpec, contextFiles map[string][]byte) {
+	if !opts.NeedsGitIdentity {
+		return
+	}
+	contextFiles["moat-git-hook.sh"] = []byte(MoatGitHookScript)
+	b.WriteString("# Git hook dispatcher (protected branches)\n")
+	b.WriteString("COPY moat-git-hook.sh " + GitHooksDir + "/moat-git-hook\n")
+	b.WriteString("RUN chmod 755 " + GitHooksDir + "/moat-git-hook && cd " + GitHooksDir)
+	for _, name := range gitHookNames {
+		b.WriteString(" && ln -s moat-git-hook " + name)
+	}
+	b.WriteString("\n\n")
+}
+
 // writeEntrypoint writes the entrypoint configuration and working directory.
 // When the init script is needed, it is added as a context file and COPYed
 // into the image. This avoids embedding a large base64 blob inline in a RUN
 // command, which triggers gRPC transport errors in Apple's container builder.
+//
+// tini is always PID 1: it reaps zombies left by long agent sessions and
+// exec'd processes, and runs the command in its own foreground process group
+// so Ctrl-C reaches it once and signals from `docker stop` reach the whole
+// group (-g). moat-init execs into the command, so it stays tini's child.
 func writeEntrypoint(b *strings.Builder, opts *ImageSpec, dockerMode DockerMode, contextFiles map[string][]byte) {
 	if opts.needsInit(dockerMode) {
 		contextFiles["moat-init.sh"] = []byte(MoatInitScript)
 		b.WriteString("# Moat initialization script (privilege drop + feature setup)\n")
 		b.WriteString("COPY moat-init.sh /usr/local/bin/moat-init\n")
 		b.WriteString("RUN chmod +x /usr/local/bin/moat-init\n")
-		b.WriteString("ENTRYPOINT [\"/usr/local/bin/moat-init\"]\n")
+		b.WriteString("ENTRYPOINT [\"" + TiniPath + "\", \"-g\", \"--\", \"/usr/local/bin/moat-init\"]\n")
 	} else {
 		b.WriteString(fmt.Sprintf("# Run as non-root user\nUSER %s\n", containerUser))
+		b.WriteString("ENTRYPOINT [\"" + TiniPath + "\", \"-g\", \"--\"]\n")
 	}
 	b.WriteString(fmt.Sprintf("WORKDIR /home/%s\n", containerUser))
 }
@@ -6,6 +6,7 @@ import (
 	"os"
 	"os/exec"
 	"runtime"
+	"slices"
 	"strings"
 	"testing"
 
@@ -264,6 +265,34 @@ func TestGenerateDockerfileWithSSH(t *testing.T) {
 	}
 }
 
+func TestGenerateDockerfileGitHooks(t *testing.T) {
+	result, err := GenerateDockerfile(nil, &ImageSpec{NeedsGitIdentity: true})
+	if err != nil {
+		t.Fatalf("GenerateDockerfile error: %v", err)
+	}
+	if _, ok := result.ContextFiles["moat-git-hook.sh"]; !ok {
+		t.Error("ContextFiles should include moat-git-hook.sh")
+	}
+	for _, want := range []string{
+		"COPY moat-git-hook.sh /usr/local/share/moat/git-hooks/moat-git-hook",
+		"ln -s moat-git-hook pre-commit",
+		"ln -s moat-git-hook pre-push",
+		"ln -s moat-git-hook commit-msg",
+	} {
+		if !strings.Contains(result.Dockerfile, want) {
+			t.Errorf("Dockerfile missing %q", want)
+		}
+	}
+
+	result, err = GenerateDockerfile(nil, &ImageSpec{NeedsSSH: true})
+	if err != nil {
+		t.Fatalf("GenerateDockerfile error: %v", err)
+	}
+	if _, ok := result.ContextFiles["moat-git-hook.sh"]; ok {
+		t.Error("ContextFiles should not include moat-git-hook.sh without git")
+	}
+}
+
 func TestGenerateDockerfileContextFiles(t *testing.T) {
 	// Verify that all init-triggering options produce context files with non-empty content
 	tests := []struct {
@@ -1000,6 +1029,16 @@ func TestGenerateDockerfilePluginValidation(t *testing.T) {
 	})
 }
 
+func TestCategorizeDepsMultiPackageApt(t *testing.T) {
+	// browser-fonts names several apt packages in one registry entry
+	c := categorizeDeps([]Dependency{{Name: "browser-fonts"}})
+
+	want := []string{"fonts-liberation", "fonts-noto-cjk", "fonts-noto-color-emoji"}
+	if !slices.Equal(c.aptPkgs, want) {
+		t.Errorf("apt packages: got %v, want %v", c.aptPkgs, want)
+	}
+}
+
 // Test helper functions
 
 func TestCategorizeDeps(t *testing.T) {
@@ -1871,6 +1910,9 @@ func TestImageSpecNeedsInit(t *testing.T) {
 		// rely on MOAT_EXTRA_HOSTS to write synthetic hostnames to /etc/hosts.
 		{"firewall only", &ImageSpec{NeedsFirewall: true}, "", true},
 		{"clipboard", &ImageSpec{NeedsClipboard: true}, "", true},
+		{"display", &ImageSpec{NeedsDisplay: true}, "", true},
+		{"ssh server", &ImageSpec{NeedsSSHServer: true}, "", true},
+		{"devices", &ImageSpec{NeedsDevices: true}, "", true},
 		// Named volumes require moat-init: it chowns the root-owned volume root to
 		// the run user on root-entrypoint runtimes; without it the run hits EACCES.
 		{"named volumes", &ImageSpec{HasNamedVolumes: true}, "", true},
@@ -1910,6 +1952,33 @@ func TestGenerateDockerfileNoClipboard(t *testing.T) {
 	}
 }
 
+func TestGenerateDockerfileDisplay(t *testing.T) {
+	result, err := GenerateDockerfile(nil, &ImageSpec{NeedsDisplay: true, NeedsClipboard: true})
+	if err != nil {
+		t.Fatalf("GenerateDockerfile error: %v", err)
+	}
+	for _, want := range []string{"x11vnc", "novnc", "websockify", "/usr/share/novnc/index.html"} {
+		if !strings.Contains(result.Dockerfile, want) {
+			t.Errorf("Dockerfile should contain %q when NeedsDisplay is true.\nGenerated Dockerfile:\n%s", want, result.Dockerfile)
+		}
+	}
+	if n := strings.Count(result.Dockerfile, "       xvfb "); n != 1 {
+		t.Errorf("xvfb listed %d times, want once (clipboard and display share it)", n)
+	}
+}
+
+func TestGenerateDockerfileSSHServer(t *testing.T) {
+	result, err := GenerateDockerfile(nil, &ImageSpec{NeedsSSHServer: true})
+	if err != nil {
+		t.Fatalf("GenerateDockerfile error: %v", err)
+	}
+	for _, want := range []string{"openssh-server", "rm -f /etc/ssh/ssh_host_*", "moat-init"} {
+		if !strings.Contains(result.Dockerfile, want) {
+			t.Errorf("Dockerfile should contain %q when NeedsSSHServer is true.\nGenerated Dockerfile:\n%s", want, result.Dockerfile)
+		}
+	}
+}
+
 func TestGenerateDockerfileClipboardNeedsInit(t *testing.T) {
 	opts := &ImageSpec{NeedsClipboard: true}
 	if !opts.needsInit("") {
@@ -1917,6 +1986,83 @@ func TestGenerateDockerfileClipboardNeedsInit(t *testing.T) {
 	}
 }
 
+func TestGenerateDockerfileClock(t *testing.T) {
+	result, err := GenerateDockerfile(nil, &ImageSpec{NeedsTimezone: true, NeedsFakeClock: true})
+	if err != nil {
+		t.Fatalf("GenerateDockerfile error: %v", err)
+	}
+	for _, want := range []string{"tzdata", "libfaketime", FakeTimeLibPath} {
+		if !strings.Contains(result.Dockerfile, want) {
+			t.Errorf("Dockerfile should contain %q.\nGenerated Dockerfile:\n%s", want, result.Dockerfile)
+		}
+	}
+	if !(&ImageSpec{NeedsFakeClock: true}).NeedsCustomImage(false) {
+		t.Error("NeedsFakeClock should require a custom image")
+	}
+
+	result, err = GenerateDockerfile(nil, nil)
+	if err != nil {
+		t.Fatalf("GenerateDockerfile error: %v", err)
+	}
+	if strings.Contains(result.Dockerfile, "libfaketime") {
+		t.Errorf("Dockerfile should NOT install libfaketime by default.\nGenerated Dockerfile:\n%s", result.Dockerfile)
+	}
+}
+
+func TestGenerateDockerfileLocale(t *testing.T) {
+	tests := []struct {
+		locale      string
+		want        []string
+		wantLocales bool
+	}{
+		{"ja_JP.UTF-8", []string{"RUN localedef -i ja_JP -c -f UTF-8 -A /usr/share/locale/locale.alias ja_JP.UTF-8", "ENV LANG=ja_JP.UTF-8"}, true},
+		{"en_US.utf8", []string{"-f UTF-8", "ENV LANG=en_US.utf8"}, true},
+		{"de_DE.ISO-8859-15@euro", []string{"localedef -i de_DE@euro -c -f ISO-8859-15", "ENV LANG=de_DE.ISO-8859-15@euro"}, true},
+		{"C.UTF-8", []string{"ENV LANG=C.UTF-8"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.locale, func(t *testing.T) {
+			result, err := GenerateDockerfile(nil, &ImageSpec{Locale: tt.locale})
+			if err != nil {
+				t.Fatalf("GenerateDockerfile error: %v", err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(result.Dockerfile, want) {
+					t.Errorf("Dockerfile should contain %q.\nGenerated Dockerfile:\n%s", want, result.Dockerfile)
+				}
+			}
+			if got := strings.Contains(result.Dockerfile, "       locales"); got != tt.wantLocales {
+				t.Errorf("installs locales package = %v, want %v", got, tt.wantLocales)
+			}
+		})
+	}
+}
+
+func TestGenerateDockerfileTiniEntrypoint(t *testing.T) {
+	tests := []struct {
+		name string
+		opts *ImageSpec
+		want string
+	}{
+		{"with moat-init", &ImageSpec{NeedsSSH: true}, `ENTRYPOINT ["/usr/bin/tini", "-g", "--", "/usr/local/bin/moat-init"]`},
+		{"without moat-init", nil, `ENTRYPOINT ["/usr/bin/tini", "-g", "--"]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := GenerateDockerfile([]Dependency{{Name: "node", Version: "20"}}, tt.opts)
+			if err != nil {
+				t.Fatalf("GenerateDockerfile error: %v", err)
+			}
+			if !strings.Contains(result.Dockerfile, tt.want) {
+				t.Errorf("Dockerfile should contain %s.\nGenerated Dockerfile:\n%s", tt.want, result.Dockerfile)
+			}
+			if !strings.Contains(result.Dockerfile, "       tini ") {
+				t.Errorf("Dockerfile should install tini.\nGenerated Dockerfile:\n%s", result.Dockerfile)
+			}
+		})
+	}
+}
+
 func TestGenerateDockerfileValidForLegacyBuilder(t *testing.T) {
 	// Validate that generated Dockerfiles are parseable by Docker's legacy builder.
 	// Every non-blank, non-comment line must either:
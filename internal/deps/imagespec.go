@@ -45,6 +45,38 @@ type ImageSpec struct {
 	// Xvfb :99 in the moat-init entrypoint.
 	NeedsClipboard bool
 
+	// NeedsDisplay indicates display.enabled is set, so the image needs
+	// Xvfb, x11vnc, and noVNC. The moat-init entrypoint starts them when
+	// MOAT_DISPLAY is set.
+	NeedsDisplay bool
+
+	// NeedsSSHServer indicates ide.enabled is set, so the image needs
+	// openssh-server. The moat-init entrypoint starts sshd with the run's
+	// keys when MOAT_SSHD is set.
+	NeedsSSHServer bool
+
+	// NeedsDevices indicates container.devices passes host devices through.
+	// The moat-init entrypoint adds moatuser to the devices' groups (see
+	// MOAT_DEVICES in moat-init.sh) before dropping privileges.
+	NeedsDevices bool
+
+	// NeedsBrowserTrust indicates a browser dependency runs behind the
+	// TLS-intercepting proxy, so the moat-init entrypoint must add the proxy
+	// CA to the user's NSS database (see MOAT_BROWSER in moat-init.sh).
+	NeedsBrowserTrust bool
+
+	// NeedsTimezone indicates container.timezone is set, so the image needs
+	// tzdata for TZ to take effect.
+	NeedsTimezone bool
+
+	// Locale is the container.locale to generate in the image and set as
+	// LANG, such as "en_US.UTF-8". Empty leaves the base image's locale.
+	Locale string
+
+	// NeedsFakeClock indicates container.clock is set, so the image needs
+	// libfaketime at FakeTimeLibPath for LD_PRELOAD.
+	NeedsFakeClock bool
+
 	// UseBuildKit enables BuildKit-specific features like cache mounts.
 	// Used only by Dockerfile generation. Defaults to false if nil.
 	UseBuildKit *bool
@@ -81,7 +113,8 @@ func (s *ImageSpec) NeedsCustomImage(hasDeps bool) bool {
 	}
 	hasHooks := s.Hooks != nil && (s.Hooks.PostBuild != "" || s.Hooks.PostBuildRoot != "" || s.Hooks.PreRun != "")
 	return hasDeps || s.BaseImage != "" || s.NeedsSSH || len(s.InitProviders) > 0 ||
-		s.NeedsFirewall || s.NeedsInitFiles || s.NeedsClipboard ||
+		s.NeedsFirewall || s.NeedsInitFiles || s.NeedsClipboard || s.NeedsBrowserTrust ||
+		s.NeedsDisplay || s.NeedsSSHServer || s.NeedsDevices || s.NeedsTimezone || s.NeedsFakeClock || s.Locale != "" ||
 		len(s.ClaudePlugins) > 0 || hasHooks || s.NeedsWorkspaceVolume
 }
 
@@ -105,7 +138,8 @@ func (s *ImageSpec) needsInit(dockerMode DockerMode) bool {
 	hasPreRun := s.Hooks != nil && s.Hooks.PreRun != ""
 	return s.NeedsSSH || len(s.InitProviders) > 0 || s.NeedsClipboard ||
 		dockerMode != "" || hasPreRun || s.NeedsGitIdentity || s.NeedsInitFiles ||
-		s.NeedsFirewall || s.HasNamedVolumes || s.NeedsWorkspaceVolume
+		s.NeedsFirewall || s.HasNamedVolumes || s.NeedsWorkspaceVolume || s.NeedsBrowserTrust ||
+		s.NeedsDisplay || s.NeedsSSHServer || s.NeedsDevices
 }
 
 // initProviderHashComponents returns sorted hash strings for InitProviders.
@@ -347,6 +347,20 @@ func getCustomCommands(name, version string) InstallCommands {
 				"PATH": "/opt/google-cloud-sdk/bin:$PATH",
 			},
 		}
+	case "az":
+		// Microsoft's apt repository install script. Extensions normally live
+		// under the installing user's ~/.azure, so put azure-devops in a shared
+		// directory the container user can read.
+		return InstallCommands{
+			Commands: []string{
+				"curl -fsSL https://aka.ms/InstallAzureCLIDeb | bash",
+				"AZURE_EXTENSION_DIR=/opt/az-extensions az extension add --name azure-devops --yes",
+				"chmod -R a+rX /opt/az-extensions",
+			},
+			EnvVars: map[string]string{
+				"AZURE_EXTENSION_DIR": "/opt/az-extensions",
+			},
+		}
 	case "rust":
 		// Install Rust toolchain to shared location for non-root access
 		return InstallCommands{
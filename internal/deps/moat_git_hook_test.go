@@ -0,0 +1,124 @@
+package deps
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// installGitHooks lays out the dispatcher the way writeGitHooks does and
+// returns the hooks directory.
+func installGitHooks(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	write(t, filepath.Join(dir, "moat-git-hook"), MoatGitHookScript)
+	if err := os.Chmod(filepath.Join(dir, "moat-git-hook"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range gitHookNames {
+		if err := os.Symlink("moat-git-hook", filepath.Join(dir, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// hookGit runs git in dir with moat's hooks dir configured the way a run does.
+func hookGit(t *testing.T, dir, hooks string, args ...string) (string, error) {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=Test",
+		"GIT_AUTHOR_EMAIL=test@test.com",
+		"GIT_COMMITTER_NAME=Test",
+		"GIT_COMMITTER_EMAIL=test@test.com",
+		"GIT_CONFIG_COUNT=1",
+		"GIT_CONFIG_KEY_0=core.hooksPath",
+		"GIT_CONFIG_VALUE_0="+hooks,
+		"MOAT_GIT_PROTECTED_BRANCHES=main release/*",
+		"MOAT_GIT_AGENT_BRANCH=agent/test-run",
+		"MOAT_GIT_TRAILERS=Co-Authored-By: Claude <noreply@anthropic.com>\nMoat-Run-ID: run_test",
+	)
+	out, err := cmd.CombinedOutput()
+	return string(out), err
+}
+
+func TestGitHookBlocksProtectedBranches(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("POSIX shell hooks; not run on Windows")
+	}
+	hooks := installGitHooks(t)
+	remote := t.TempDir()
+	repo := t.TempDir()
+	mustGit := func(dir string, args ...string) {
+		t.Helper()
+		if out, err := hookGit(t, dir, hooks, args...); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	mustGit(remote, "init", "--bare", "-q")
+	mustGit(repo, "init", "-q", "-b", "main")
+	mustGit(repo, "remote", "add", "origin", remote)
+	write(t, filepath.Join(repo, "a.txt"), "a")
+	mustGit(repo, "add", ".")
+
+	out, err := hookGit(t, repo, hooks, "commit", "-m", "on main")
+	if err == nil || !strings.Contains(out, "protected branch 'main'") || !strings.Contains(out, "git switch -c agent/test-run") {
+		t.Fatalf("commit on main: err=%v, output:\n%s", err, out)
+	}
+
+	// The repository's own hooks still run once moat's check passes.
+	write(t, filepath.Join(repo, ".git", "hooks", "pre-commit"), "#!/bin/sh\ntouch repo-hook-ran\n")
+	if err := os.Chmod(filepath.Join(repo, ".git", "hooks", "pre-commit"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	mustGit(repo, "switch", "-q", "-c", "agent/test-run")
+	mustGit(repo, "commit", "-q", "-m", "on agent branch")
+	if _, err := os.Stat(filepath.Join(repo, "repo-hook-ran")); err != nil {
+		t.Errorf("repository pre-commit hook did not run: %v", err)
+	}
+
+	mustGit(repo, "push", "-q", "origin", "agent/test-run")
+	for _, ref := range []string{"agent/test-run:main", "agent/test-run:release/1.0"} {
+		out, err := hookGit(t, repo, hooks, "push", "origin", ref)
+		if err == nil || !strings.Contains(out, "pushing to protected branch") {
+			t.Errorf("push %s: err=%v, output:\n%s", ref, err, out)
+		}
+	}
+}
+
+func TestGitHookAddsTrailers(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("POSIX shell hooks; not run on Windows")
+	}
+	hooks := installGitHooks(t)
+	repo := t.TempDir()
+	mustGit := func(args ...string) string {
+		t.Helper()
+		out, err := hookGit(t, repo, hooks, args...)
+		if err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+		return out
+	}
+	mustGit("init", "-q", "-b", "feature")
+	// A repository commit-msg hook sees the trailers already in place.
+	write(t, filepath.Join(repo, ".git", "hooks", "commit-msg"), "#!/bin/sh\ngrep -q '^Moat-Run-ID: run_test$' \"$1\"\n")
+	if err := os.Chmod(filepath.Join(repo, ".git", "hooks", "commit-msg"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	write(t, filepath.Join(repo, "a.txt"), "a")
+	mustGit("add", ".")
+	mustGit("commit", "-q", "-m", "Add a")
+	mustGit("commit", "-q", "--amend", "--no-edit")
+
+	msg := mustGit("log", "-1", "--format=%B")
+	want := "Add a\n\nCo-Authored-By: Claude <noreply@anthropic.com>\nMoat-Run-ID: run_test\n"
+	if strings.TrimSpace(msg) != strings.TrimSpace(want) {
+		t.Errorf("commit message = %q, want %q", msg, want)
+	}
+}
@@ -385,16 +385,18 @@ func Validate(deps []Dependency) error {
 // suggestDep returns a suggestion message if a similar dependency exists.
 func suggestDep(name string) string {
 	suggestions := map[string]string{
-		"nodejs":   "node",
-		"node.js":  "node",
-		"golang":   "go",
-		"python3":  "python",
-		"py":       "python",
-		"postgres": "psql",
-		"pg":       "psql",
-		"awscli":   "aws",
-		"aws-cli":  "aws",
-		"gcp":      "gcloud",
+		"nodejs":    "node",
+		"node.js":   "node",
+		"golang":    "go",
+		"python3":   "python",
+		"py":        "python",
+		"postgres":  "psql",
+		"pg":        "psql",
+		"awscli":    "aws",
+		"aws-cli":   "aws",
+		"gcp":       "gcloud",
+		"azure":     "az",
+		"azure-cli": "az",
 	}
 	if sugg, ok := suggestions[name]; ok {
 		return fmt.Sprintf("\n  Did you mean '%s'?", sugg)
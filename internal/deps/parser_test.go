@@ -685,6 +685,25 @@ func TestK8sMetaBundle(t *testing.T) {
 	}
 }
 
+func TestBrowserMetaBundle(t *testing.T) {
+	// browser expands to playwright, browser-fonts, nss-tools
+	// playwright requires node
+	deps, err := ParseAll([]string{"node", "browser"})
+	if err != nil {
+		t.Fatalf("ParseAll error: %v", err)
+	}
+
+	expected := []string{"node", "playwright", "browser-fonts", "nss-tools"}
+	if len(deps) != len(expected) {
+		t.Errorf("expected %d deps, got %d: %v", len(expected), len(deps), deps)
+	}
+
+	// Validate should pass
+	if err := Validate(deps); err != nil {
+		t.Errorf("Validate error: %v", err)
+	}
+}
+
 func TestDockerDependency(t *testing.T) {
 	// docker requires explicit mode - "docker" alone should error
 	_, err := Parse("docker")
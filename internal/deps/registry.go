@@ -13,6 +13,9 @@ var registryData []byte
 //go:embed scripts/moat-init.sh
 var MoatInitScript string
 
+//go:embed scripts/moat-git-hook.sh
+var MoatGitHookScript string
+
 // registry holds all available dependencies. It is read-only after init().
 var registry map[string]DepSpec
 
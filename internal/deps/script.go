@@ -38,7 +38,7 @@ func GenerateInstallScript(deps []Dependency) (string, error) {
 		spec, _ := GetSpec(dep.Name)
 		switch spec.Type {
 		case TypeApt:
-			aptPkgs = append(aptPkgs, spec.Package)
+			aptPkgs = append(aptPkgs, strings.Fields(spec.Package)...)
 		case TypeRuntime:
 			runtimes = append(runtimes, dep)
 		case TypeGithubBinary:
@@ -0,0 +1,100 @@
+#!/bin/sh
+# moat-git-hook.sh - Git hook dispatcher for moat containers
+# moat points core.hooksPath at a directory where every client-side hook name
+# links to this script. It refuses commits and pushes to protected branches,
+# then runs the repository's own hook of the same name so that repo hooks
+# (including ones under a repo-level core.hooksPath, e.g. husky) keep working.
+#
+# MOAT_GIT_PROTECTED_BRANCHES holds space-separated branch globs (e.g.
+# "main master release/*"). When it is empty, the dispatcher only chains.
+# MOAT_GIT_AGENT_BRANCH, when set, is suggested as the branch to use instead.
+# MOAT_GIT_TRAILERS holds newline-separated "Key: value" trailers (e.g.
+# Moat-Run-ID) that the commit-msg hook adds to every commit message.
+#
+# This is a guardrail against accidental commits, not a security boundary:
+# `git commit --no-verify` skips it.
+
+hook=$(basename "$0")
+
+# is_protected succeeds when branch $1 matches a protected glob.
+is_protected() {
+  [ -n "$MOAT_GIT_PROTECTED_BRANCHES" ] || return 1
+  # Disable pathname expansion so globs match branch names, not files.
+  set -f
+  for pattern in $MOAT_GIT_PROTECTED_BRANCHES; do
+    # shellcheck disable=SC2254 # the pattern is intentionally unquoted
+    case "$1" in
+      $pattern)
+        set +f
+        return 0
+        ;;
+    esac
+  done
+  set +f
+  return 1
+}
+
+refuse() {
+  echo "moat: $1 to protected branch '$2' is blocked in this run." >&2
+  if [ -n "$MOAT_GIT_AGENT_BRANCH" ]; then
+    echo "moat:   work on a separate branch instead, e.g.: git switch -c $MOAT_GIT_AGENT_BRANCH" >&2
+  else
+    echo "moat:   work on a separate branch instead: git switch -c <branch>" >&2
+  fi
+  echo "moat:   protected branches: $MOAT_GIT_PROTECTED_BRANCHES (workspace.protected_branches)" >&2
+  exit 1
+}
+
+input=""
+case "$hook" in
+  pre-commit | pre-merge-commit)
+    branch=$(git symbolic-ref --quiet --short HEAD 2>/dev/null) || branch=""
+    if [ -n "$branch" ] && is_protected "$branch"; then
+      refuse "committing" "$branch"
+    fi
+    ;;
+  commit-msg)
+    # Add trailers before the repository's hook runs, so message linters see
+    # the final message. addIfDifferent keeps amends from duplicating them.
+    if [ -n "$MOAT_GIT_TRAILERS" ] && [ -f "$1" ]; then
+      printf '%s\n' "$MOAT_GIT_TRAILERS" | while IFS= read -r trailer; do
+        [ -n "$trailer" ] || continue
+        git interpret-trailers --in-place --if-exists addIfDifferent --trailer "$trailer" "$1"
+      done
+    fi
+    ;;
+  pre-push)
+    # stdin: <local ref> <local oid> <remote ref> <remote oid>, one per line.
+    # Keep it to hand on to the repository's hook.
+    input=$(cat)
+    while read -r _ _ remote_ref _; do
+      case "$remote_ref" in
+        refs/heads/*)
+          if is_protected "${remote_ref#refs/heads/}"; then
+            refuse "pushing" "${remote_ref#refs/heads/}"
+          fi
+          ;;
+      esac
+    done <<EOF
+$input
+EOF
+    ;;
+esac
+
+# Chain to the repository's hook. A repo-level core.hooksPath wins over
+# .git/hooks, as it would without moat; the path is relative to the top of the
+# working tree, which is where git runs hooks.
+repo_hooks=$(git config --local core.hooksPath 2>/dev/null) || repo_hooks="$(git rev-parse --git-common-dir)/hooks"
+repo_hook="$repo_hooks/$hook"
+if [ ! -x "$repo_hook" ] || [ "$repo_hook" -ef "$0" ]; then
+  exit 0
+fi
+if [ "$hook" = "pre-push" ]; then
+  if [ -n "$input" ]; then
+    printf '%s\n' "$input" | "$repo_hook" "$@"
+  else
+    "$repo_hook" "$@" </dev/null
+  fi
+  exit $?
+fi
+exec "$repo_hook" "$@"
@@ -18,12 +18,13 @@ MOAT_DNS_WAIT_ITERS=25          # iterations * 0.2s = 5 second timeout for conta
 # Synthetic Host Entries
 # When MOAT_EXTRA_HOSTS is set, append space-separated "name:target" pairs to
 # /etc/hosts. Used on runtimes where the host side cannot supply a usable IP
-# via --add-host: Apple containers (no such flag) and Docker Desktop on
+# via --add-host: Apple containers (no such flag), Docker Desktop on
 # macOS/Windows (host-gateway resolves to the docker0 bridge, which is
-# unreachable from custom bridge networks created for services).
+# unreachable from custom bridge networks created for services), and Podman.
 #
 # target may be a literal IP (e.g. "192.168.64.1") or a hostname prefixed
-# with "@" (e.g. "@host.docker.internal"). The "@" form tells us to resolve
+# with "@" (e.g. "@host.docker.internal", or "@host.containers.internal",
+# which Podman writes to /etc/hosts). The "@" form tells us to resolve
 # the hostname via the container's DNS at startup — this is how we reach
 # Docker Desktop's host, which is only addressable by the container-only
 # DNS name host.docker.internal. DNS resolution is retried briefly because
@@ -126,6 +127,29 @@ if [ -n "$MOAT_SSH_TCP_ADDR" ]; then
   fi
 fi
 
+# Secret Files
+# When MOAT_SECRET_NAMES is set, write each secret to a file named after its
+# variable in MOAT_SECRETS_DIR, readable only by moatuser. `moat secrets
+# refresh` rewrites these files when a secret is rotated; the environment
+# keeps the values the container started with.
+if [ -n "$MOAT_SECRET_NAMES" ] && [ -n "$MOAT_SECRETS_DIR" ]; then
+  if mkdir -p "$MOAT_SECRETS_DIR" 2>/dev/null; then
+    chmod 700 "$MOAT_SECRETS_DIR" 2>/dev/null || true
+    for name in $MOAT_SECRET_NAMES; do
+      # printenv appends a newline; strip it (and only it) so the file holds
+      # the exact value, as refresh writes it.
+      value=$(printenv "$name"; echo x)
+      value=${value%?x}
+      (umask 077 && printf '%s' "$value" > "$MOAT_SECRETS_DIR/$name") || true
+    done
+    if [ "$(id -u)" = "0" ] && id moatuser >/dev/null 2>&1; then
+      chown -R moatuser:moatuser "$MOAT_SECRETS_DIR" 2>/dev/null || true
+    fi
+  else
+    echo "Warning: cannot create $MOAT_SECRETS_DIR; secrets are only in the environment" >&2
+  fi
+fi
+
 # Claude Code Setup
 # When MOAT_CLAUDE_INIT is set to the staging directory path, copy files
 # from the staging area to their final locations. This is needed because:
@@ -327,15 +351,73 @@ setup_workspace_mcp_json() {
   fi
 }
 
+# Virtual Display
+# When MOAT_DISPLAY is set, start an X display on :99 at
+# MOAT_DISPLAY_RESOLUTION for GUI tools, share it over VNC on localhost
+# only, and serve the noVNC viewer on port 6080. The host publishes 6080 as
+# the "gui" endpoint of the routing proxy. The VNC and web servers run as
+# moatuser so a bug in them cannot act as root.
+start_display() {
+  screen="${MOAT_DISPLAY_RESOLUTION:-1280x800x24}"
+  Xvfb :99 -screen 0 "$screen" -nolisten tcp >/dev/null 2>&1 &
+  i=0
+  while [ ! -e /tmp/.X11-unix/X99 ] && [ "$i" -lt 50 ]; do
+    sleep 0.1
+    i=$((i + 1))
+  done
+  run_as=""
+  if [ "$(id -u)" = "0" ] && id moatuser >/dev/null 2>&1; then
+    run_as="gosu moatuser"
+  fi
+  $run_as x11vnc -display :99 -forever -shared -nopw -localhost -rfbport 5900 -quiet >/dev/null 2>&1 &
+  $run_as websockify --web /usr/share/novnc 6080 localhost:5900 >/dev/null 2>&1 &
+}
+
+if [ "$MOAT_DISPLAY" = "1" ]; then
+  start_display
+  export DISPLAY=:99
+fi
+
 # Clipboard Bridging
 # When MOAT_CLIPBOARD is set, start a headless X server for clipboard
 # operations. The host writes clipboard data to /tmp/.moat-clipboard
-# and uses xclip to set the X selection.
-if [ "$MOAT_CLIPBOARD" = "1" ]; then
+# and uses xclip to set the X selection. A virtual display already
+# provides :99, so clipboard bridging shares it.
+if [ "$MOAT_CLIPBOARD" = "1" ] && [ "$MOAT_DISPLAY" != "1" ]; then
   Xvfb :99 -screen 0 1x1x8 >/dev/null 2>&1 &
   export DISPLAY=:99
 fi
 
+# Browser CA Trust
+# When MOAT_BROWSER is set, add the moat proxy CA to the agent user's NSS
+# database (~/.pki/nssdb). Chromium reads trust anchors from NSS and ignores
+# SSL_CERT_FILE, so without this every HTTPS page fails certificate checks
+# behind the TLS-intercepting proxy. Best-effort: a browser that cannot
+# trust the CA fails closed, it never bypasses the proxy.
+setup_browser_ca() {
+  ca_cert="/etc/ssl/certs/moat-ca/ca.crt"
+  if [ ! -f "$ca_cert" ]; then
+    return
+  fi
+  if ! command -v certutil >/dev/null 2>&1; then
+    echo "Warning: certutil not found; browsers will not trust the moat proxy CA (add the nss-tools dependency)" >&2
+    return
+  fi
+  nss_script="mkdir -p \"\$HOME/.pki/nssdb\" && \
+    { [ -f \"\$HOME/.pki/nssdb/cert9.db\" ] || certutil -N -d \"sql:\$HOME/.pki/nssdb\" --empty-password; } && \
+    certutil -A -d \"sql:\$HOME/.pki/nssdb\" -n moat-proxy-ca -t C,, -i $ca_cert"
+  if [ "$(id -u)" = "0" ] && id moatuser >/dev/null 2>&1; then
+    HOME=/home/moatuser gosu moatuser sh -c "$nss_script" || \
+      echo "Warning: failed to add moat proxy CA to the browser trust store" >&2
+  else
+    sh -c "$nss_script" || \
+      echo "Warning: failed to add moat proxy CA to the browser trust store" >&2
+  fi
+}
+if [ "$MOAT_BROWSER" = "1" ]; then
+  setup_browser_ca
+fi
+
 # Git Configuration
 # 1. Safe directory: The workspace is mounted from the host with different
 #    ownership than the container user. Git 2.35.2+ rejects operations on
@@ -344,6 +426,9 @@ fi
 #    passes them via MOAT_GIT_USER_NAME and MOAT_GIT_USER_EMAIL. Set them as
 #    system-level git config so commits inside the container use the host's
 #    identity.
+# Branch protection and commit trailers (Moat-Run-ID, Co-Authored-By) are
+# handled by the hook dispatcher, moat-git-hook.sh, which moat enables by
+# setting core.hooksPath through GIT_CONFIG_* env vars rather than here.
 if command -v git >/dev/null 2>&1; then
   git config --system --add safe.directory /workspace 2>/dev/null || true
   if [ -n "$MOAT_GIT_USER_NAME" ]; then
@@ -352,6 +437,23 @@ if command -v git >/dev/null 2>&1; then
   if [ -n "$MOAT_GIT_USER_EMAIL" ]; then
     git config --system user.email "$MOAT_GIT_USER_EMAIL" 2>/dev/null || true
   fi
+  # Repositories from workspaces: in moat.yaml arrive in MOAT_GIT_REPOS, one
+  # per line: "<dir>\037<gitdir>\037<name>\037<email>". Each is marked safe and,
+  # through an includeIf on its git dir, commits with its own identity.
+  # \037 is not whitespace, so empty fields are kept.
+  if [ -n "$MOAT_GIT_REPOS" ]; then
+    printf '%s\n' "$MOAT_GIT_REPOS" | while IFS="$(printf '\037')" read -r repo_dir repo_gitdir repo_name repo_email; do
+      [ -n "$repo_dir" ] || continue
+      git config --system --add safe.directory "$repo_dir" 2>/dev/null || true
+      if [ -n "$repo_name$repo_email" ]; then
+        repo_inc="/etc/moat-git/$(printf '%s' "$repo_dir" | tr '/' '_').gitconfig"
+        mkdir -p /etc/moat-git
+        [ -z "$repo_name" ] || git config --file "$repo_inc" user.name "$repo_name" 2>/dev/null || true
+        [ -z "$repo_email" ] || git config --file "$repo_inc" user.email "$repo_email" 2>/dev/null || true
+        git config --system "includeIf.gitdir:$repo_gitdir.path" "$repo_inc" 2>/dev/null || true
+      fi
+    done
+  fi
   # Authenticate to the moat proxy preemptively with Basic. Unlike curl, git
   # does not send Proxy-Authorization from the proxy URL and does not retry
   # after the proxy's 407 CONNECT challenge, so HTTPS git through the proxy
@@ -368,6 +470,55 @@ if command -v git >/dev/null 2>&1; then
   fi
 fi
 
+# Agent Branch
+# When MOAT_GIT_AGENT_BRANCH is set (workspace.branch_prefix + run name) and
+# /workspace is checked out on a protected branch (MOAT_GIT_PROTECTED_BRANCHES,
+# space-separated globs), create the agent branch and switch to it so the
+# agent's commits land there. The hook dispatcher (moat-git-hook.sh) refuses
+# commits to protected branches either way; this just makes the default path
+# work. `git switch -c` carries uncommitted changes over. If the branch already
+# exists (e.g. a reused run name) we leave the checkout alone rather than move
+# the user's tree to an older commit. A function so it runs after
+# populate_workspace_volume, as the workspace user.
+setup_agent_branch() {
+  if [ -z "$MOAT_GIT_AGENT_BRANCH" ] || [ -z "$MOAT_GIT_PROTECTED_BRANCHES" ] || ! command -v git >/dev/null 2>&1; then
+    return
+  fi
+  current=$(git -C /workspace symbolic-ref --quiet --short HEAD 2>/dev/null) || return 0
+  protected=""
+  set -f
+  for pattern in $MOAT_GIT_PROTECTED_BRANCHES; do
+    # shellcheck disable=SC2254 # the pattern is intentionally unquoted
+    case "$current" in
+      $pattern) protected=1 ;;
+    esac
+  done
+  set +f
+  if [ -z "$protected" ]; then
+    return
+  fi
+  if git -C /workspace show-ref --verify --quiet "refs/heads/$MOAT_GIT_AGENT_BRANCH"; then
+    echo "moat: /workspace is on protected branch '$current' and $MOAT_GIT_AGENT_BRANCH already exists; not switching. Commits to '$current' will be refused." >&2
+    return
+  fi
+  set +e
+  if [ "$(id -u)" != "0" ]; then
+    git -C /workspace switch --quiet -c "$MOAT_GIT_AGENT_BRANCH"
+    switch_status=$?
+  elif id moatuser >/dev/null 2>&1; then
+    gosu moatuser git -C /workspace switch --quiet -c "$MOAT_GIT_AGENT_BRANCH"
+    switch_status=$?
+  else
+    switch_status=1
+  fi
+  set -e
+  if [ "$switch_status" -eq 0 ]; then
+    echo "moat: switched /workspace from protected branch '$current' to $MOAT_GIT_AGENT_BRANCH" >&2
+  else
+    echo "moat: could not switch /workspace to $MOAT_GIT_AGENT_BRANCH; commits to '$current' will be refused." >&2
+  fi
+}
+
 # Docker Access Setup
 # Two mutually exclusive modes:
 # 1. MOAT_DOCKER_GID (host mode): Docker socket mounted from host, just need group access
@@ -458,6 +609,28 @@ if [ -n "$MOAT_DOCKER_GID" ] && [ "$(id -u)" = "0" ] && [ -S /var/run/docker.soc
   fi
 fi
 
+# Device Groups
+# When MOAT_DEVICES is set (space-separated device paths passed through with
+# container.devices), add moatuser to the group owning each device node so it
+# can open them after the privilege drop. Like the docker socket, ownership is
+# read inside the container because the runtime may translate it.
+if [ -n "$MOAT_DEVICES" ] && [ "$(id -u)" = "0" ] && id moatuser >/dev/null 2>&1; then
+  for dev in $MOAT_DEVICES; do
+    find "$dev" \( -type c -o -type b \) -exec stat -c '%g' {} + 2>/dev/null
+  done | sort -un | while read -r gid; do
+    if [ "$gid" = "0" ]; then
+      continue
+    fi
+    if ! getent group "$gid" >/dev/null 2>&1; then
+      groupadd -g "$gid" "moat-dev-$gid" 2>/dev/null || true
+    fi
+    DEVICE_GROUP=$(getent group "$gid" | cut -d: -f1)
+    if [ -n "$DEVICE_GROUP" ]; then
+      usermod -aG "$DEVICE_GROUP" moatuser 2>/dev/null || true
+    fi
+  done
+fi
+
 # Workspace Volume Population
 # When MOAT_WORKSPACE_VOLUME=1, copy the read-only staging tree into /workspace
 # before dropping privileges. The staging mount (MOAT_WORKSPACE_STAGING, default
@@ -581,6 +754,50 @@ if [ -n "$MOAT_VOLUME_CHOWN" ] && [ "$(id -u)" = "0" ] && id moatuser >/dev/null
   set +f
 fi
 
+# IDE Attach
+# When MOAT_SSHD is set, start sshd on port 2222 so a developer can attach an
+# IDE to the sandbox. The host mints the run's host key and client key and
+# mounts them at MOAT_SSHD_DIR. The host key is copied into /etc/ssh so it is
+# root-owned with mode 0600, as sshd requires. Only the run's key is accepted,
+# and only for the agent's user. SSH sessions do not inherit the container's
+# environment, so it is saved to /etc/profile.d for the login shells IDEs
+# start — IDE terminals then use the same proxy and credentials as the agent.
+start_sshd() {
+  key_dir="${MOAT_SSHD_DIR:-/moat/ide}"
+  if [ ! -f "$key_dir/ssh_host_ed25519_key" ] || [ ! -x /usr/sbin/sshd ]; then
+    echo "Warning: ide.enabled is set but the SSH server could not be set up" >&2
+    return
+  fi
+  if [ "$(id -u)" != "0" ] || ! id moatuser >/dev/null 2>&1; then
+    echo "Warning: the IDE SSH server needs the container to start as root; skipping" >&2
+    return
+  fi
+  install -m 600 -o root -g root "$key_dir/ssh_host_ed25519_key" /etc/ssh/ssh_host_ed25519_key
+  install -m 644 -o root -g root "$key_dir/authorized_keys" /etc/ssh/moat_authorized_keys
+  mkdir -p /run/sshd
+  # sshd refuses key logins to accounts locked with "!"; "*" still has no
+  # usable password but is not treated as locked.
+  case "$(getent shadow moatuser | cut -d: -f2)" in
+    '!'*) usermod -p '*' moatuser 2>/dev/null || true ;;
+  esac
+  export -p | grep -v -E '^export (HOME|PWD|OLDPWD|SHLVL|HOSTNAME|USER|LOGNAME|SHELL|TERM|MOAT_SSHD[A-Z_]*)=' \
+    > /etc/profile.d/moat-env.sh 2>/dev/null || true
+  /usr/sbin/sshd -p 2222 \
+    -h /etc/ssh/ssh_host_ed25519_key \
+    -o AuthorizedKeysFile=/etc/ssh/moat_authorized_keys \
+    -o PasswordAuthentication=no \
+    -o KbdInteractiveAuthentication=no \
+    -o PermitRootLogin=no \
+    -o AllowUsers=moatuser \
+    -o AllowTcpForwarding=local \
+    -o X11Forwarding=no \
+    || echo "Warning: failed to start the IDE SSH server" >&2
+}
+
+if [ "$MOAT_SSHD" = "1" ]; then
+  start_sshd
+fi
+
 # Execute the user's command
 # First run the pre_run hook (if set), then exec the main command.
 # If we're already running as a non-root user (UID != 0), just exec directly.
@@ -589,6 +806,7 @@ fi
 # If moatuser doesn't exist, fail - running as root defeats the security model.
 populate_workspace_volume
 setup_workspace_mcp_json
+setup_agent_branch
 run_pre_run_hook
 if [ "$(id -u)" != "0" ]; then
   # Already non-root (e.g., --user was passed to docker run)
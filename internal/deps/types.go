@@ -88,6 +88,10 @@ type DepSpec struct {
 	// For service type
 	Service *ServiceDef `yaml:"service,omitempty"`
 
+	// Container holds runtime settings the dependency needs from the
+	// container it runs in (e.g., a larger /dev/shm for browsers).
+	Container *ContainerDef `yaml:"container,omitempty"`
+
 	// Env specifies environment variables to set after installing this dependency.
 	// These are emitted as Dockerfile ENV instructions, making them available
 	// to all subsequent build steps and at runtime.
@@ -130,6 +134,46 @@ type ServiceDef struct {
 	// The placeholder {item} is replaced with each item value.
 	// Commands run via sh -c inside the service container after readiness.
 	ProvisionCmd string `yaml:"provision_cmd,omitempty"`
+
+	// Publish serves the service's default port through the routing proxy
+	// as an endpoint of the run named after the service, and injects
+	// MOAT_HOST_<NAME> and MOAT_URL_<NAME> like a ports: endpoint.
+	Publish bool `yaml:"publish,omitempty"`
+
+	// PublishPath is appended to MOAT_URL_<NAME>, with {password} replaced
+	// by the service's generated password (e.g., a login token).
+	PublishPath string `yaml:"publish_path,omitempty"`
+}
+
+// ContainerDef holds container runtime settings for a dependency.
+// Parsed from the `container:` block in registry.yaml entries.
+type ContainerDef struct {
+	// ShmSizeMB is the minimum /dev/shm size in megabytes. Chromium keeps
+	// renderer buffers in shared memory and crashes tabs under Docker's
+	// 64MB default.
+	ShmSizeMB int `yaml:"shm-size-mb,omitempty"`
+
+	// Browser marks dependencies that ship a web browser. Browsers ignore
+	// SSL_CERT_FILE and credentials embedded in HTTPS_PROXY, so moat trusts
+	// its CA in the user's NSS database and exposes the proxy settings
+	// separately for browser launchers.
+	Browser bool `yaml:"browser,omitempty"`
+}
+
+// ContainerNeeds merges the container settings of all dependencies in
+// the list: the largest /dev/shm size wins and Browser is set if any
+// dependency ships a browser.
+func ContainerNeeds(deps []Dependency) ContainerDef {
+	var needs ContainerDef
+	for _, dep := range deps {
+		spec, ok := GetSpec(dep.Name)
+		if !ok || spec.Container == nil {
+			continue
+		}
+		needs.ShmSizeMB = max(needs.ShmSizeMB, spec.Container.ShmSizeMB)
+		needs.Browser = needs.Browser || spec.Container.Browser
+	}
+	return needs
 }
 
 // Dependency represents a parsed dependency from moat.yaml.
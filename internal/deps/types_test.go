@@ -27,3 +27,25 @@ func TestInstallTypeConstants(t *testing.T) {
 		}
 	}
 }
+
+func TestContainerNeeds(t *testing.T) {
+	deps, err := ParseAll([]string{"node", "browser", "jq"})
+	if err != nil {
+		t.Fatalf("ParseAll error: %v", err)
+	}
+	needs := ContainerNeeds(deps)
+	if needs.ShmSizeMB != 2048 {
+		t.Errorf("ShmSizeMB = %d, want 2048", needs.ShmSizeMB)
+	}
+	if !needs.Browser {
+		t.Error("Browser = false, want true")
+	}
+
+	deps, err = ParseAll([]string{"node", "jq"})
+	if err != nil {
+		t.Fatalf("ParseAll error: %v", err)
+	}
+	if needs := ContainerNeeds(deps); needs != (ContainerDef{}) {
+		t.Errorf("ContainerNeeds() = %+v, want zero value", needs)
+	}
+}
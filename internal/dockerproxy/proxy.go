@@ -0,0 +1,553 @@
+// Package dockerproxy filters the Docker Engine API for docker:host runs.
+//
+// Mounting the host Docker socket gives the agent control of the host: a
+// container started with --privileged, a bind mount of /, or the host
+// network namespace escapes any isolation moat provides. The Proxy sits
+// between the container and the host socket and forwards only requests that
+// build images and run unprivileged containers. Containers, networks, and
+// volumes it creates are labeled with the run ID, and requests naming an
+// existing one are only forwarded if it carries that label, so the agent
+// cannot exec into or reconfigure containers it did not start, or join
+// other runs' networks and volumes.
+package dockerproxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/majorcontext/moat/internal/log"
+)
+
+// Label is set on containers, networks, and volumes created through the
+// proxy. Its value is the run ID.
+const Label = "moat.docker-proxy.run"
+
+// maxBodySize bounds the request bodies the proxy decodes. Container, exec,
+// network, and volume create requests are a few kilobytes.
+const maxBodySize = 1 << 20
+
+// versionPrefix matches the optional API version at the start of a path,
+// e.g. /v1.45.
+var versionPrefix = regexp.MustCompile(`^/v[0-9]+(\.[0-9]+)*`)
+
+// DeniedError is returned for a request the proxy refuses to forward.
+type DeniedError struct {
+	Method string
+	Path   string
+	Reason string
+}
+
+func (e *DeniedError) Error() string {
+	return fmt.Sprintf("%s %s denied: %s", e.Method, e.Path, e.Reason)
+}
+
+// Proxy is an http.Handler that forwards allowed Docker API requests to the
+// upstream daemon socket.
+type Proxy struct {
+	runID   string
+	client  *http.Client // upstream client for ownership checks
+	forward *httputil.ReverseProxy
+	onDeny  func(*DeniedError)
+}
+
+// NewProxy returns a Proxy forwarding to the Docker API socket at
+// upstreamSocket. runID labels the containers the run creates.
+func NewProxy(upstreamSocket, runID string) *Proxy {
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", upstreamSocket)
+		},
+	}
+	return &Proxy{
+		runID:  runID,
+		client: &http.Client{Transport: transport},
+		forward: &httputil.ReverseProxy{
+			Rewrite: func(pr *httputil.ProxyRequest) {
+				pr.Out.URL.Scheme = "http"
+				pr.Out.URL.Host = "docker"
+				pr.Out.Host = "docker"
+			},
+			Transport: transport,
+			// Stream logs, events, and build output as they arrive.
+			FlushInterval: -1,
+		},
+	}
+}
+
+// SetDenyFunc sets a callback invoked for each denied request.
+func (p *Proxy) SetDenyFunc(fn func(*DeniedError)) {
+	p.onDeny = fn
+}
+
+// ServeHTTP checks the request and forwards it, or answers 403 with the
+// reason in Docker's error format so the docker CLI prints it.
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := p.authorize(r); err != nil {
+		status := http.StatusForbidden
+		msg := "moat: " + err.Error()
+		if denied, ok := err.(*DeniedError); ok {
+			msg = "moat: " + denied.Reason
+			if p.onDeny != nil {
+				p.onDeny(denied)
+			}
+			log.Debug("docker API request denied", "method", denied.Method, "path", denied.Path, "reason", denied.Reason)
+		} else {
+			status = http.StatusBadGateway
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]string{"message": msg})
+		return
+	}
+	p.forward.ServeHTTP(w, r)
+}
+
+// authorize returns nil if r may be forwarded, a *DeniedError if not, or
+// another error if the decision could not be made. It may replace r.Body.
+func (p *Proxy) authorize(r *http.Request) error {
+	path := versionPrefix.ReplaceAllString(r.URL.Path, "")
+	seg := strings.Split(strings.Trim(path, "/"), "/")
+	deny := func(format string, args ...any) error {
+		return &DeniedError{Method: r.Method, Path: path, Reason: fmt.Sprintf(format, args...)}
+	}
+
+	switch seg[0] {
+	case "_ping", "version", "info", "events", "system", "auth", "distribution", "images", "exec":
+		return nil
+
+	case "session", "grpc":
+		// BuildKit clients ask for the network.host and security.insecure
+		// entitlements themselves, and the build definition travels over
+		// the session where the proxy cannot see it.
+		return deny("BuildKit is not available through the moat Docker API filter; set DOCKER_BUILDKIT=0 to use the classic builder")
+
+	case "build":
+		if r.Method == http.MethodPost && len(seg) == 1 {
+			if r.URL.Query().Get("version") == "2" {
+				return deny("BuildKit is not available through the moat Docker API filter; set DOCKER_BUILDKIT=0 to use the classic builder")
+			}
+			if r.URL.Query().Get("networkmode") == "host" {
+				return deny("builds cannot use the host network")
+			}
+		}
+		return nil
+
+	case "commit":
+		return p.requireOwned(r, path, r.URL.Query().Get("container"))
+
+	case "containers":
+		if len(seg) == 1 {
+			return deny("unsupported request")
+		}
+		switch seg[1] {
+		case "json":
+			return nil
+		case "create":
+			return p.authorizeCreate(r, path)
+		case "prune":
+			return deny("pruning would remove containers the run did not create")
+		}
+		if err := p.requireOwned(r, path, seg[1]); err != nil {
+			return err
+		}
+		if len(seg) == 3 && seg[2] == "exec" {
+			var body struct{ Privileged bool }
+			if err := readJSON(r, &body); err != nil {
+				return err
+			}
+			if body.Privileged {
+				return deny("privileged exec is not allowed")
+			}
+		}
+		return nil
+
+	case "networks":
+		if len(seg) == 1 {
+			return nil
+		}
+		switch seg[1] {
+		case "create":
+			var body struct{ Driver string }
+			if err := readJSON(r, &body); err != nil {
+				return err
+			}
+			if body.Driver != "" && body.Driver != "bridge" {
+				return deny("only bridge networks can be created, not %q", body.Driver)
+			}
+			return p.labelBody(r)
+		case "prune":
+			return deny("pruning would remove networks the run did not create")
+		}
+		if r.Method == http.MethodGet {
+			return nil
+		}
+		// Connecting, disconnecting, and removing.
+		return p.requireOwnedNetwork(r, path, seg[1])
+
+	case "volumes":
+		if len(seg) == 1 {
+			return nil
+		}
+		switch seg[1] {
+		case "create":
+			var body struct {
+				Driver     string
+				DriverOpts map[string]string
+			}
+			if err := readJSON(r, &body); err != nil {
+				return err
+			}
+			if (body.Driver != "" && body.Driver != "local") || len(body.DriverOpts) > 0 {
+				return deny("volumes with driver options can mount host paths")
+			}
+			return p.labelBody(r)
+		case "prune":
+			return deny("pruning would remove volumes the run did not create")
+		}
+		if r.Method == http.MethodGet {
+			return nil
+		}
+		return p.requireOwnedVolume(r, path, seg[1])
+	}
+	return deny("the %s API is not available through the moat Docker API filter", seg[0])
+}
+
+// createRequest holds the fields of a container create request the proxy
+// checks.
+type createRequest struct {
+	HostConfig struct {
+		Binds  []string
+		Mounts []struct {
+			Type          string
+			Source        string
+			VolumeOptions *struct {
+				DriverConfig *struct {
+					Name    string
+					Options map[string]string
+				}
+			}
+		}
+		Privileged        bool
+		NetworkMode       string
+		PidMode           string
+		IpcMode           string
+		UTSMode           string
+		UsernsMode        string
+		CgroupnsMode      string
+		CapAdd            []string
+		Devices           []json.RawMessage
+		DeviceRequests    []json.RawMessage
+		DeviceCgroupRules []string
+		SecurityOpt       []string
+		MaskedPaths       []string
+		ReadonlyPaths     []string
+		VolumesFrom       []string
+	}
+	NetworkingConfig struct {
+		EndpointsConfig map[string]json.RawMessage
+	}
+}
+
+// authorizeCreate checks a container create request and labels the
+// container with the run ID. Named volumes the container mounts must have
+// been created by the run; ones that do not exist yet are created with the
+// run's label, as the daemon would otherwise create them unlabeled.
+func (p *Proxy) authorizeCreate(r *http.Request, path string) error {
+	deny := func(format string, args ...any) error {
+		return &DeniedError{Method: r.Method, Path: path, Reason: fmt.Sprintf(format, args...)}
+	}
+
+	data, err := readBody(r)
+	if err != nil {
+		return err
+	}
+	var req createRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		return deny("invalid request body: %v", err)
+	}
+	hc := req.HostConfig
+
+	if hc.Privileged {
+		return deny("privileged containers are not allowed")
+	}
+	var volumes []string
+	for _, bind := range hc.Binds {
+		src, _, _ := strings.Cut(bind, ":")
+		if strings.HasPrefix(bind, "/") {
+			return deny("bind mounts of host paths are not allowed (%s); use a named volume", src)
+		}
+		volumes = append(volumes, src)
+	}
+	for _, m := range hc.Mounts {
+		if m.Type != "volume" && m.Type != "tmpfs" {
+			return deny("%s mounts are not allowed (%s); use a named volume", m.Type, m.Source)
+		}
+		// A volume mount can create its volume inline, with the same driver
+		// options volumes/create refuses.
+		if m.VolumeOptions != nil && m.VolumeOptions.DriverConfig != nil {
+			dc := m.VolumeOptions.DriverConfig
+			if (dc.Name != "" && dc.Name != "local") || len(dc.Options) > 0 {
+				return deny("volume mounts with driver options can mount host paths (%s)", m.Source)
+			}
+		}
+		if m.Type == "volume" && m.Source != "" {
+			volumes = append(volumes, m.Source)
+		}
+	}
+	if err := p.requireNetwork(r, path, hc.NetworkMode); err != nil {
+		return err
+	}
+	for name := range req.NetworkingConfig.EndpointsConfig {
+		if err := p.requireNetwork(r, path, name); err != nil {
+			return err
+		}
+	}
+	for _, ns := range []struct{ name, mode string }{
+		{"PID", hc.PidMode},
+		{"IPC", hc.IpcMode},
+		{"UTS", hc.UTSMode},
+		{"user", hc.UsernsMode},
+		{"cgroup", hc.CgroupnsMode},
+	} {
+		mode := ns.mode
+		if mode == "host" {
+			return deny("the host %s namespace is not allowed", ns.name)
+		}
+		if id, ok := strings.CutPrefix(mode, "container:"); ok {
+			if err := p.requireOwned(r, path, id); err != nil {
+				return err
+			}
+		}
+	}
+	if len(hc.CapAdd) > 0 {
+		return deny("adding capabilities is not allowed (%s)", strings.Join(hc.CapAdd, ", "))
+	}
+	if len(hc.Devices) > 0 || len(hc.DeviceRequests) > 0 {
+		return deny("host devices are not allowed")
+	}
+	// With CAP_MKNOD, which containers have by default, a device cgroup
+	// rule lets the container create and open the host's device nodes.
+	if len(hc.DeviceCgroupRules) > 0 {
+		return deny("device cgroup rules are not allowed (%s)", strings.Join(hc.DeviceCgroupRules, ", "))
+	}
+	// Any value, even an empty list, replaces the default masking of
+	// /proc and /sys; an empty list is --security-opt systempaths=unconfined.
+	if hc.MaskedPaths != nil || hc.ReadonlyPaths != nil {
+		return deny("overriding masked and read-only system paths is not allowed")
+	}
+	for _, opt := range hc.SecurityOpt {
+		// Any SELinux label override can select an unconfined type such as
+		// spc_t, not just label=disable.
+		if strings.Contains(opt, "unconfined") || strings.HasPrefix(opt, "label=") || strings.HasPrefix(opt, "label:") {
+			return deny("security option %q is not allowed", opt)
+		}
+	}
+	for _, from := range hc.VolumesFrom {
+		id, _, _ := strings.Cut(from, ":")
+		if err := p.requireOwned(r, path, id); err != nil {
+			return err
+		}
+	}
+	for _, name := range volumes {
+		if err := p.requireVolume(r, path, name); err != nil {
+			return err
+		}
+	}
+
+	// Label the container so later requests naming it are allowed.
+	return p.labelBody(r)
+}
+
+// labelBody adds the run label to the Labels of r's JSON body, so later
+// requests naming the object it creates are allowed.
+func (p *Proxy) labelBody(r *http.Request) error {
+	data, err := readBody(r)
+	if err != nil {
+		return err
+	}
+	body := make(map[string]any)
+	if len(bytes.TrimSpace(data)) > 0 {
+		if err := json.Unmarshal(data, &body); err != nil {
+			return &DeniedError{Method: r.Method, Path: r.URL.Path, Reason: fmt.Sprintf("invalid request body: %v", err)}
+		}
+	}
+	labels, _ := body["Labels"].(map[string]any)
+	if labels == nil {
+		labels = make(map[string]any)
+	}
+	labels[Label] = p.runID
+	body["Labels"] = labels
+	data, err = json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	setBody(r, data)
+	return nil
+}
+
+// requireNetwork returns a *DeniedError unless a container may use the
+// network mode or network name: the default bridge, no network, a network
+// the run created, or the network namespace of a container the run started.
+func (p *Proxy) requireNetwork(r *http.Request, path, mode string) error {
+	switch mode {
+	case "", "bridge", "default", "none":
+		return nil
+	case "host":
+		return &DeniedError{Method: r.Method, Path: path, Reason: "the host network namespace is not allowed"}
+	}
+	if id, ok := strings.CutPrefix(mode, "container:"); ok {
+		return p.requireOwned(r, path, id)
+	}
+	return p.requireOwnedNetwork(r, path, mode)
+}
+
+// requireVolume returns a *DeniedError unless the named volume was created
+// through this proxy for the run. A volume that does not exist yet is
+// created with the run's label.
+func (p *Proxy) requireVolume(r *http.Request, path, name string) error {
+	labels, found, err := p.inspectLabels(r.Context(), "volumes", name)
+	if err != nil {
+		return err
+	}
+	if found {
+		if labels[Label] != p.runID {
+			return &DeniedError{Method: r.Method, Path: path, Reason: fmt.Sprintf("volume %s was not created by this run", name)}
+		}
+		return nil
+	}
+	body, _ := json.Marshal(map[string]any{"Name": name, "Labels": map[string]string{Label: p.runID}})
+	req, err := http.NewRequestWithContext(r.Context(), http.MethodPost, "http://docker/volumes/create", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("creating volume %s: %w", name, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("creating volume %s: HTTP %d", name, resp.StatusCode)
+	}
+	return nil
+}
+
+// requireOwned returns a *DeniedError unless the container named id was
+// created through this proxy for the run.
+func (p *Proxy) requireOwned(r *http.Request, path, id string) error {
+	return p.requireLabeled(r, path, "containers", id, fmt.Sprintf("container %s was not started by this run", id))
+}
+
+// requireOwnedNetwork returns a *DeniedError unless the network named id
+// was created through this proxy for the run.
+func (p *Proxy) requireOwnedNetwork(r *http.Request, path, id string) error {
+	return p.requireLabeled(r, path, "networks", id, fmt.Sprintf("network %s was not created by this run", id))
+}
+
+// requireOwnedVolume returns a *DeniedError unless the volume named name
+// was created through this proxy for the run.
+func (p *Proxy) requireOwnedVolume(r *http.Request, path, name string) error {
+	return p.requireLabeled(r, path, "volumes", name, fmt.Sprintf("volume %s was not created by this run", name))
+}
+
+// requireLabeled returns a *DeniedError with reason unless the object of
+// kind ("containers", "networks", or "volumes") named id carries the run
+// label. A missing object is allowed, so the daemon answers with its own
+// "not found" error.
+func (p *Proxy) requireLabeled(r *http.Request, path, kind, id, reason string) error {
+	deny := &DeniedError{Method: r.Method, Path: path, Reason: reason}
+	if id == "" {
+		return deny
+	}
+	labels, found, err := p.inspectLabels(r.Context(), kind, id)
+	if err != nil {
+		return err
+	}
+	if found && labels[Label] != p.runID {
+		return deny
+	}
+	return nil
+}
+
+// inspectLabels returns the labels of the object of kind named id, and
+// whether it exists.
+func (p *Proxy) inspectLabels(ctx context.Context, kind, id string) (map[string]string, bool, error) {
+	u := "http://docker/" + kind + "/" + url.PathEscape(id)
+	if kind == "containers" {
+		u += "/json"
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, false, fmt.Errorf("inspecting %s %s: %w", strings.TrimSuffix(kind, "s"), id, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, false, nil
+	}
+	// Containers keep their labels under Config; networks and volumes at
+	// the top level.
+	var info struct {
+		Labels map[string]string
+		Config struct {
+			Labels map[string]string
+		}
+	}
+	if resp.StatusCode != http.StatusOK || json.NewDecoder(resp.Body).Decode(&info) != nil {
+		return nil, false, fmt.Errorf("inspecting %s %s: HTTP %d", strings.TrimSuffix(kind, "s"), id, resp.StatusCode)
+	}
+	if kind == "containers" {
+		return info.Config.Labels, true, nil
+	}
+	return info.Labels, true, nil
+}
+
+// readJSON decodes r's body into v and restores the body for forwarding.
+// An empty body leaves v unchanged.
+func readJSON(r *http.Request, v any) error {
+	data, err := readBody(r)
+	if err != nil || len(bytes.TrimSpace(data)) == 0 {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return &DeniedError{Method: r.Method, Path: r.URL.Path, Reason: fmt.Sprintf("invalid request body: %v", err)}
+	}
+	return nil
+}
+
+// readBody reads r's body, up to maxBodySize, and restores it.
+func readBody(r *http.Request) ([]byte, error) {
+	if r.Body == nil {
+		return nil, nil
+	}
+	data, err := io.ReadAll(io.LimitReader(r.Body, maxBodySize+1))
+	r.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("reading request body: %w", err)
+	}
+	if len(data) > maxBodySize {
+		return nil, &DeniedError{Method: r.Method, Path: r.URL.Path, Reason: "request body too large"}
+	}
+	setBody(r, data)
+	return data, nil
+}
+
+// setBody replaces r's body with data.
+func setBody(r *http.Request, data []byte) {
+	r.Body = io.NopCloser(bytes.NewReader(data))
+	r.ContentLength = int64(len(data))
+	r.TransferEncoding = nil
+}
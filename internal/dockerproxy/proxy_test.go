@@ -0,0 +1,260 @@
+package dockerproxy
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeDaemon is a Docker API stand-in on a Unix socket. It has one
+// container, network, and volume labeled for run "run-1" and one of each
+// created outside the run.
+type fakeDaemon struct {
+	socket    string
+	forwarded []string // "METHOD path" of requests other than the proxy's own
+	lastBody  []byte
+	created   []string // "name label" of volumes the proxy created itself
+}
+
+func startFakeDaemon(t *testing.T) *fakeDaemon {
+	t.Helper()
+	d := &fakeDaemon{socket: filepath.Join(t.TempDir(), "docker.sock")}
+	l, err := net.Listen("unix", d.socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		labels := map[string]map[string]map[string]string{
+			"containers": {"owned": {Label: "run-1"}, "foreign": {}},
+			"networks":   {"owned-net": {Label: "run-1"}, "foreign-net": {}},
+			"volumes":    {"owned-vol": {Label: "run-1"}, "foreign-vol": {}},
+		}
+		// The proxy inspects and creates without an API version prefix.
+		if r.Method == http.MethodGet {
+			kind, id, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+			if kind == "containers" {
+				id, _ = strings.CutSuffix(id, "/json")
+			}
+			if objects, ok := labels[kind]; ok && id != "" && id != "json" {
+				l, ok := objects[id]
+				if !ok {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+				info := map[string]any{"Labels": l}
+				if kind == "containers" {
+					info = map[string]any{"Config": info}
+				}
+				json.NewEncoder(w).Encode(info)
+				return
+			}
+		}
+		if r.Method == http.MethodPost && r.URL.Path == "/volumes/create" {
+			var body struct {
+				Name   string
+				Labels map[string]string
+			}
+			json.NewDecoder(r.Body).Decode(&body)
+			d.created = append(d.created, body.Name+" "+body.Labels[Label])
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte("{}"))
+			return
+		}
+		d.forwarded = append(d.forwarded, r.Method+" "+r.URL.Path)
+		d.lastBody, _ = io.ReadAll(r.Body)
+		w.Write([]byte("{}"))
+	})}
+	go srv.Serve(l)
+	t.Cleanup(func() { srv.Close() })
+	return d
+}
+
+// startProxy serves a Proxy for run-1 and returns a client for it.
+func startProxy(t *testing.T, d *fakeDaemon) *http.Client {
+	t.Helper()
+	sock := filepath.Join(t.TempDir(), "proxy.sock")
+	s := NewServer(NewProxy(d.socket, "run-1"), sock)
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Stop() })
+	return &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, "unix", sock)
+		},
+	}}
+}
+
+func TestProxy_Filter(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		allow  bool
+	}{
+		{"ping", "GET", "/_ping", "", true},
+		{"list containers", "GET", "/v1.45/containers/json", "", true},
+		{"pull image", "POST", "/v1.45/images/create?fromImage=alpine", "", true},
+		{"build", "POST", "/v1.45/build?t=app", "", true},
+		{"build on host network", "POST", "/v1.45/build?networkmode=host", "", false},
+		{"buildkit build", "POST", "/v1.45/build?version=2&session=s", "", false},
+		{"buildkit session", "POST", "/v1.45/session", "", false},
+		{"buildkit grpc", "POST", "/v1.45/grpc", "", false},
+		{"create unprivileged", "POST", "/v1.45/containers/create", `{"Image":"alpine","HostConfig":{"Binds":["data:/data"],"Mounts":[{"Type":"tmpfs","Target":"/tmp"}]}}`, true},
+		{"create privileged", "POST", "/v1.45/containers/create", `{"Image":"alpine","HostConfig":{"Privileged":true}}`, false},
+		{"create host bind", "POST", "/v1.45/containers/create", `{"Image":"alpine","HostConfig":{"Binds":["/:/host"]}}`, false},
+		{"create bind mount", "POST", "/v1.45/containers/create", `{"Image":"alpine","HostConfig":{"Mounts":[{"Type":"bind","Source":"/etc","Target":"/etc"}]}}`, false},
+		{"create host network", "POST", "/v1.45/containers/create", `{"Image":"alpine","HostConfig":{"NetworkMode":"host"}}`, false},
+		{"create host pid", "POST", "/v1.45/containers/create", `{"Image":"alpine","HostConfig":{"PidMode":"host"}}`, false},
+		{"create cap add", "POST", "/v1.45/containers/create", `{"Image":"alpine","HostConfig":{"CapAdd":["SYS_ADMIN"]}}`, false},
+		{"create device", "POST", "/v1.45/containers/create", `{"Image":"alpine","HostConfig":{"Devices":[{"PathOnHost":"/dev/sda"}]}}`, false},
+		{"create device cgroup rule", "POST", "/v1.45/containers/create", `{"Image":"alpine","HostConfig":{"DeviceCgroupRules":["b *:* rwm"]}}`, false},
+		{"create unmasked system paths", "POST", "/v1.45/containers/create", `{"Image":"alpine","HostConfig":{"MaskedPaths":[],"ReadonlyPaths":[]}}`, false},
+		{"create writable system paths", "POST", "/v1.45/containers/create", `{"Image":"alpine","HostConfig":{"ReadonlyPaths":["/proc/bus"]}}`, false},
+		{"create null system paths", "POST", "/v1.45/containers/create", `{"Image":"alpine","HostConfig":{"MaskedPaths":null,"ReadonlyPaths":null}}`, true},
+		{"create seccomp unconfined", "POST", "/v1.45/containers/create", `{"Image":"alpine","HostConfig":{"SecurityOpt":["seccomp=unconfined"]}}`, false},
+		{"create selinux disabled", "POST", "/v1.45/containers/create", `{"Image":"alpine","HostConfig":{"SecurityOpt":["label=disable"]}}`, false},
+		{"create selinux spc_t", "POST", "/v1.45/containers/create", `{"Image":"alpine","HostConfig":{"SecurityOpt":["label=type:spc_t"]}}`, false},
+		{"create no-new-privileges", "POST", "/v1.45/containers/create", `{"Image":"alpine","HostConfig":{"SecurityOpt":["no-new-privileges"]}}`, true},
+		{"create volume mount", "POST", "/v1.45/containers/create", `{"Image":"alpine","HostConfig":{"Mounts":[{"Type":"volume","Source":"data","Target":"/data","VolumeOptions":{"NoCopy":true}}]}}`, true},
+		{"create volume mount binding host root", "POST", "/v1.45/containers/create", `{"Image":"alpine","HostConfig":{"Mounts":[{"Type":"volume","Source":"root","Target":"/host","VolumeOptions":{"DriverConfig":{"Name":"local","Options":{"type":"none","o":"bind","device":"/"}}}}]}}`, false},
+		{"create volume mount with plugin driver", "POST", "/v1.45/containers/create", `{"Image":"alpine","HostConfig":{"Mounts":[{"Type":"volume","Source":"v","Target":"/v","VolumeOptions":{"DriverConfig":{"Name":"sshfs"}}}]}}`, false},
+		{"create joining foreign netns", "POST", "/v1.45/containers/create", `{"Image":"alpine","HostConfig":{"NetworkMode":"container:foreign"}}`, false},
+		{"create joining owned netns", "POST", "/v1.45/containers/create", `{"Image":"alpine","HostConfig":{"NetworkMode":"container:owned"}}`, true},
+		{"create on bridge network", "POST", "/v1.45/containers/create", `{"Image":"alpine","HostConfig":{"NetworkMode":"bridge"}}`, true},
+		{"create on owned network", "POST", "/v1.45/containers/create", `{"Image":"alpine","HostConfig":{"NetworkMode":"owned-net"}}`, true},
+		{"create on foreign network", "POST", "/v1.45/containers/create", `{"Image":"alpine","HostConfig":{"NetworkMode":"foreign-net"}}`, false},
+		{"create with foreign endpoint", "POST", "/v1.45/containers/create", `{"Image":"alpine","NetworkingConfig":{"EndpointsConfig":{"foreign-net":{}}}}`, false},
+		{"create binding owned volume", "POST", "/v1.45/containers/create", `{"Image":"alpine","HostConfig":{"Binds":["owned-vol:/data"]}}`, true},
+		{"create binding foreign volume", "POST", "/v1.45/containers/create", `{"Image":"alpine","HostConfig":{"Binds":["foreign-vol:/data:ro"]}}`, false},
+		{"create mounting foreign volume", "POST", "/v1.45/containers/create", `{"Image":"alpine","HostConfig":{"Mounts":[{"Type":"volume","Source":"foreign-vol","Target":"/data"}]}}`, false},
+		{"start owned", "POST", "/v1.45/containers/owned/start", "", true},
+		{"exec owned", "POST", "/v1.45/containers/owned/exec", `{"Cmd":["ls"]}`, true},
+		{"privileged exec owned", "POST", "/v1.45/containers/owned/exec", `{"Cmd":["ls"],"Privileged":true}`, false},
+		{"exec foreign", "POST", "/v1.45/containers/foreign/exec", `{"Cmd":["ls"]}`, false},
+		{"inspect foreign", "GET", "/v1.45/containers/foreign/json", "", false},
+		{"remove foreign", "DELETE", "/v1.45/containers/foreign", "", false},
+		{"commit foreign", "POST", "/v1.45/commit?container=foreign", "", false},
+		{"prune containers", "POST", "/v1.45/containers/prune", "", false},
+		{"bind volume", "POST", "/v1.45/volumes/create", `{"Name":"v","DriverOpts":{"type":"none","o":"bind","device":"/etc"}}`, false},
+		{"plain volume", "POST", "/v1.45/volumes/create", `{"Name":"v"}`, true},
+		{"macvlan network", "POST", "/v1.45/networks/create", `{"Name":"n","Driver":"macvlan"}`, false},
+		{"connect to owned network", "POST", "/v1.45/networks/owned-net/connect", `{"Container":"owned"}`, true},
+		{"connect to foreign network", "POST", "/v1.45/networks/foreign-net/connect", `{"Container":"owned"}`, false},
+		{"remove foreign network", "DELETE", "/v1.45/networks/foreign-net", "", false},
+		{"inspect foreign network", "GET", "/v1.45/networks/foreign-net", "", true},
+		{"remove owned volume", "DELETE", "/v1.45/volumes/owned-vol", "", true},
+		{"remove foreign volume", "DELETE", "/v1.45/volumes/foreign-vol", "", false},
+		{"plugins", "POST", "/v1.45/plugins/pull", "", false},
+		{"swarm", "POST", "/v1.45/swarm/init", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := startFakeDaemon(t)
+			client := startProxy(t, d)
+
+			req, _ := http.NewRequest(tt.method, "http://docker"+tt.path, strings.NewReader(tt.body))
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			if tt.allow {
+				if resp.StatusCode != http.StatusOK || len(d.forwarded) != 1 {
+					body, _ := io.ReadAll(resp.Body)
+					t.Errorf("status = %d (%s), forwarded = %v; want the request forwarded", resp.StatusCode, body, d.forwarded)
+				}
+				return
+			}
+			if resp.StatusCode != http.StatusForbidden {
+				t.Errorf("status = %d, want 403", resp.StatusCode)
+			}
+			if len(d.forwarded) != 0 {
+				t.Errorf("forwarded = %v, want nothing", d.forwarded)
+			}
+			var msg struct{ Message string }
+			json.NewDecoder(resp.Body).Decode(&msg)
+			if !strings.HasPrefix(msg.Message, "moat: ") {
+				t.Errorf("message = %q, want a Docker error message naming moat", msg.Message)
+			}
+		})
+	}
+}
+
+func TestProxy_CreateLabelsContainer(t *testing.T) {
+	d := startFakeDaemon(t)
+	client := startProxy(t, d)
+
+	body := `{"Image":"alpine","Labels":{"app":"web"},"Cmd":["true"]}`
+	resp, err := client.Post("http://docker/v1.45/containers/create?name=web", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	var got struct {
+		Image  string
+		Cmd    []string
+		Labels map[string]string
+	}
+	if err := json.Unmarshal(d.lastBody, &got); err != nil {
+		t.Fatalf("forwarded body %q: %v", d.lastBody, err)
+	}
+	if got.Labels[Label] != "run-1" || got.Labels["app"] != "web" {
+		t.Errorf("labels = %v, want the run label added to the existing ones", got.Labels)
+	}
+	if got.Image != "alpine" || len(got.Cmd) != 1 {
+		t.Errorf("forwarded body = %s, want other fields kept", d.lastBody)
+	}
+}
+
+func TestProxy_CreateLabelsNetworksAndVolumes(t *testing.T) {
+	for _, path := range []string{"/v1.45/networks/create", "/v1.45/volumes/create"} {
+		t.Run(path, func(t *testing.T) {
+			d := startFakeDaemon(t)
+			client := startProxy(t, d)
+
+			resp, err := client.Post("http://docker"+path, "application/json", strings.NewReader(`{"Name":"cache","Labels":{"app":"web"}}`))
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+
+			var got struct {
+				Name   string
+				Labels map[string]string
+			}
+			if err := json.Unmarshal(d.lastBody, &got); err != nil {
+				t.Fatalf("forwarded body %q: %v", d.lastBody, err)
+			}
+			if got.Name != "cache" || got.Labels[Label] != "run-1" || got.Labels["app"] != "web" {
+				t.Errorf("forwarded body = %s, want the run label added", d.lastBody)
+			}
+		})
+	}
+}
+
+func TestProxy_CreateMakesMissingVolumesOwned(t *testing.T) {
+	d := startFakeDaemon(t)
+	client := startProxy(t, d)
+
+	body := `{"Image":"alpine","HostConfig":{"Binds":["cache:/cache"],"Mounts":[{"Type":"volume","Source":"owned-vol","Target":"/data"}]}}`
+	resp, err := client.Post("http://docker/v1.45/containers/create", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want the create forwarded", resp.StatusCode)
+	}
+	if len(d.created) != 1 || d.created[0] != "cache run-1" {
+		t.Errorf("created volumes = %v, want [cache run-1]", d.created)
+	}
+}
@@ -0,0 +1,79 @@
+package dockerproxy
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+
+	"github.com/majorcontext/moat/internal/log"
+)
+
+// Server serves a Proxy on a Unix socket.
+type Server struct {
+	proxy      *Proxy
+	socketPath string
+	srv        *http.Server
+	wg         sync.WaitGroup
+	closeOnce  sync.Once
+}
+
+// NewServer creates a server for proxy listening on socketPath.
+func NewServer(proxy *Proxy, socketPath string) *Server {
+	return &Server{
+		proxy:      proxy,
+		socketPath: socketPath,
+		srv:        &http.Server{Handler: proxy},
+	}
+}
+
+// SocketPath returns the path of the Unix socket.
+func (s *Server) SocketPath() string {
+	return s.socketPath
+}
+
+// Proxy returns the underlying filtering proxy.
+func (s *Server) Proxy() *Proxy {
+	return s.proxy
+}
+
+// Start begins listening on the Unix socket.
+func (s *Server) Start() error {
+	os.Remove(s.socketPath)
+
+	listener, err := net.Listen("unix", s.socketPath)
+	if err != nil {
+		return fmt.Errorf("listening on socket: %w", err)
+	}
+
+	// The container user has a different UID, so the socket must be world
+	// read/write. The directory is per-run and only mounted into the run's
+	// container, and every request passes the filter.
+	if err := os.Chmod(s.socketPath, 0o666); err != nil {
+		listener.Close()
+		return fmt.Errorf("setting socket permissions: %w", err)
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		if err := s.srv.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Debug("docker API proxy stopped", "error", err)
+		}
+	}()
+	return nil
+}
+
+// Stop shuts down the server and removes the socket. Connections hijacked
+// for attach or exec end when their container process exits.
+func (s *Server) Stop() error {
+	var err error
+	s.closeOnce.Do(func() {
+		err = s.srv.Close()
+	})
+	s.wg.Wait()
+	os.Remove(s.socketPath)
+	return err
+}
@@ -0,0 +1,22 @@
+//go:build e2e
+// +build e2e
+
+package e2e
+
+import (
+	"testing"
+
+	"github.com/majorcontext/moat/internal/container"
+	"github.com/majorcontext/moat/internal/container/conformance"
+)
+
+// TestRuntimeConformance runs the container.Runtime conformance suite on
+// every available runtime.
+func TestRuntimeConformance(t *testing.T) {
+	testOnAllRuntimes(t, func(t *testing.T, rt container.Runtime) {
+		conformance.Run(t, rt, conformance.Options{
+			// Apple containers only support bind mounts.
+			NoVolumes: rt.Type() == container.RuntimeApple,
+		})
+	})
+}
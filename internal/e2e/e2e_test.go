@@ -2071,6 +2071,15 @@ func hasDocker() bool {
 	return exec.CommandContext(ctx, "docker", "version").Run() == nil
 }
 
+// hasPodman checks if the Podman API service is reachable. --remote goes
+// through the same socket moat uses, so a CLI that works only in local mode
+// does not count.
+func hasPodman() bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	return exec.CommandContext(ctx, "podman", "--remote", "info").Run() == nil
+}
+
 // hasApple checks if Apple containers are available (macOS 15+ on Apple Silicon).
 func hasApple() bool {
 	if runtime.GOOS != "darwin" || runtime.GOARCH != "arm64" {
@@ -2168,6 +2177,10 @@ func detectAvailableRuntimes(t *testing.T) []container.RuntimeType {
 		available = append(available, container.RuntimeApple)
 	}
 
+	if hasPodman() {
+		available = append(available, container.RuntimePodman)
+	}
+
 	return available
 }
 
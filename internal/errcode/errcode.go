@@ -0,0 +1,116 @@
+// Package errcode defines stable error codes (MOAT-E####) for failures that
+// users and automation need to recognize. The CLI prints the code with the
+// error and includes it in --json output, so support docs and scripts can key
+// off the code instead of matching message text.
+//
+// Codes are attached without changing messages: Wrap marks an error at the
+// site where it is created, and packages that own sentinel errors map them to
+// codes with Register. Codes are never reused or renumbered; retired codes
+// stay reserved.
+package errcode
+
+import (
+	"errors"
+	"sync"
+)
+
+// Code is a stable error code such as "MOAT-E1001".
+type Code string
+
+// Runs (1xxx).
+const (
+	RunNotFound        Code = "MOAT-E1001"
+	RunNotRunning      Code = "MOAT-E1002"
+	MissingGrants      Code = "MOAT-E1003"
+	NoConfigManifest   Code = "MOAT-E1004"
+	NoEnvSnapshot      Code = "MOAT-E1005"
+	RuntimeUnavailable Code = "MOAT-E1006"
+)
+
+// Credentials and providers (2xxx).
+const (
+	CredentialNotFound     Code = "MOAT-E2001"
+	CredentialDecrypt      Code = "MOAT-E2002"
+	CredentialExpired      Code = "MOAT-E2003"
+	CredentialRejected     Code = "MOAT-E2004"
+	TokenRevoked           Code = "MOAT-E2005"
+	RefreshNotSupported    Code = "MOAT-E2006"
+	ProviderNotFound       Code = "MOAT-E2007"
+	InputRequired          Code = "MOAT-E2008"
+	GrantFailed            Code = "MOAT-E2009"
+	KeyNotMigrated         Code = "MOAT-E2010"
+	KeyInsecurePermissions Code = "MOAT-E2011"
+)
+
+// Proxy daemon (3xxx).
+const (
+	DaemonUnavailable       Code = "MOAT-E3001"
+	DaemonStreamUnsupported Code = "MOAT-E3002"
+)
+
+// Error attaches a Code to an error. Its message is the wrapped error's.
+type Error struct {
+	Code Code
+	Err  error
+}
+
+func (e *Error) Error() string { return e.Err.Error() }
+
+func (e *Error) Unwrap() error { return e.Err }
+
+// Wrap attaches code to err. It returns nil when err is nil.
+func Wrap(code Code, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Code: code, Err: err}
+}
+
+// Coder is implemented by error types that carry their own code.
+type Coder interface {
+	ErrorCode() Code
+}
+
+type registration struct {
+	sentinel error
+	code     Code
+}
+
+var (
+	mu       sync.RWMutex
+	registry []registration
+)
+
+// Register maps a sentinel error to code, so any error matching it with
+// errors.Is reports the code. Packages call it from init for the sentinels
+// they own.
+func Register(sentinel error, code Code) {
+	mu.Lock()
+	defer mu.Unlock()
+	registry = append(registry, registration{sentinel, code})
+}
+
+// Of returns the code for err, or "" when it has none. The outermost code
+// attached with Wrap takes precedence, then the outermost Coder, then
+// registered sentinels in registration order.
+func Of(err error) Code {
+	if err == nil {
+		return ""
+	}
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Code
+	}
+	var c Coder
+	if errors.As(err, &c) {
+		return c.ErrorCode()
+	}
+	mu.RLock()
+	defer mu.RUnlock()
+	for _, r := range registry {
+		if errors.Is(err, r.sentinel) {
+			return r.code
+		}
+	}
+	return ""
+}
@@ -0,0 +1,50 @@
+package errcode
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+type codedError struct{}
+
+func (codedError) Error() string   { return "coded" }
+func (codedError) ErrorCode() Code { return GrantFailed }
+
+func TestOf(t *testing.T) {
+	sentinel := errors.New("sentinel")
+	Register(sentinel, RunNotFound)
+
+	tests := []struct {
+		name string
+		err  error
+		want Code
+	}{
+		{"nil", nil, ""},
+		{"uncoded", errors.New("boom"), ""},
+		{"wrapped", Wrap(RunNotRunning, errors.New("not running")), RunNotRunning},
+		{"wrapped deeper", fmt.Errorf("starting: %w", Wrap(MissingGrants, errors.New("missing"))), MissingGrants},
+		{"coder", fmt.Errorf("grant: %w", codedError{}), GrantFailed},
+		{"registered sentinel", fmt.Errorf("%w: run_1", sentinel), RunNotFound},
+		{"outermost wins", Wrap(DaemonUnavailable, fmt.Errorf("%w: run_1", sentinel)), DaemonUnavailable},
+	}
+	for _, tt := range tests {
+		if got := Of(tt.err); got != tt.want {
+			t.Errorf("%s: Of() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestWrapKeepsMessageAndChain(t *testing.T) {
+	if Wrap(RunNotFound, nil) != nil {
+		t.Error("Wrap(nil) should be nil")
+	}
+	inner := errors.New("run not found")
+	err := Wrap(RunNotFound, inner)
+	if err.Error() != "run not found" {
+		t.Errorf("Error() = %q, want the wrapped message", err.Error())
+	}
+	if !errors.Is(err, inner) {
+		t.Error("Wrap should keep the error chain")
+	}
+}
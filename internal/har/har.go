@@ -0,0 +1,226 @@
+// Package har converts a run's network trace to HTTP Archive (HAR 1.2)
+// format, which browser developer tools, Fiddler, and Charles can load.
+//
+// The trace records what network.capture allows: in the default metadata
+// mode, entries carry no headers and only error response bodies. Fields the
+// trace lacks are written as HAR's "unknown" values (-1 sizes, empty lists).
+// Moat-specific details are kept in underscore-prefixed custom fields, as
+// the HAR spec allows: _error, _denied, _denyReason, and _truncated.
+package har
+
+import (
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/majorcontext/moat/internal/storage"
+)
+
+// Version is the HAR format version written.
+const Version = "1.2"
+
+// HAR is the top-level HAR document.
+type HAR struct {
+	Log Log `json:"log"`
+}
+
+// Log is the HAR log object.
+type Log struct {
+	Version string  `json:"version"`
+	Creator Creator `json:"creator"`
+	Entries []Entry `json:"entries"`
+}
+
+// Creator names the tool that wrote the archive.
+type Creator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// Entry is one request and its response.
+type Entry struct {
+	StartedDateTime string   `json:"startedDateTime"`
+	Time            float64  `json:"time"`
+	Request         Request  `json:"request"`
+	Response        Response `json:"response"`
+	Cache           struct{} `json:"cache"`
+	Timings         Timings  `json:"timings"`
+
+	Error      string `json:"_error,omitempty"`
+	Denied     bool   `json:"_denied,omitempty"`
+	DenyReason string `json:"_denyReason,omitempty"`
+	Truncated  bool   `json:"_truncated,omitempty"`
+}
+
+// Request is the HAR request object.
+type Request struct {
+	Method      string    `json:"method"`
+	URL         string    `json:"url"`
+	HTTPVersion string    `json:"httpVersion"`
+	Cookies     []Cookie  `json:"cookies"`
+	Headers     []NVP     `json:"headers"`
+	QueryString []NVP     `json:"queryString"`
+	PostData    *PostData `json:"postData,omitempty"`
+	HeadersSize int       `json:"headersSize"`
+	BodySize    int64     `json:"bodySize"`
+}
+
+// Response is the HAR response object.
+type Response struct {
+	Status      int      `json:"status"`
+	StatusText  string   `json:"statusText"`
+	HTTPVersion string   `json:"httpVersion"`
+	Cookies     []Cookie `json:"cookies"`
+	Headers     []NVP    `json:"headers"`
+	Content     Content  `json:"content"`
+	RedirectURL string   `json:"redirectURL"`
+	HeadersSize int      `json:"headersSize"`
+	BodySize    int64    `json:"bodySize"`
+}
+
+// NVP is a HAR name/value pair, used for headers and query parameters.
+type NVP struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// Cookie is a HAR cookie. Moat never records cookies (they are redacted), so
+// cookie lists are always empty; the type exists for the schema.
+type Cookie struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// PostData is a request body.
+type PostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+// Content is a response body.
+type Content struct {
+	Size     int64  `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+}
+
+// Timings breaks down an entry's time. The trace records only the total, so
+// it is reported as waiting on the server.
+type Timings struct {
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+// FromNetworkRequests builds a HAR document from a run's network trace, in
+// the order the requests started. creatorVersion is the moat version.
+func FromNetworkRequests(reqs []storage.NetworkRequest, creatorVersion string) HAR {
+	entries := make([]Entry, 0, len(reqs))
+	for _, r := range reqs {
+		entries = append(entries, entry(r))
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].StartedDateTime < entries[j].StartedDateTime
+	})
+	return HAR{Log: Log{
+		Version: Version,
+		Creator: Creator{Name: "moat", Version: creatorVersion},
+		Entries: entries,
+	}}
+}
+
+func entry(r storage.NetworkRequest) Entry {
+	// The trace timestamps a request when it completes.
+	duration := time.Duration(r.Duration) * time.Millisecond
+	started := r.Timestamp.Add(-duration).UTC()
+
+	e := Entry{
+		StartedDateTime: started.Format("2006-01-02T15:04:05.000Z"),
+		Time:            float64(r.Duration),
+		Request: Request{
+			Method:      r.Method,
+			URL:         r.URL,
+			HTTPVersion: "HTTP/1.1",
+			Cookies:     []Cookie{},
+			Headers:     headers(r.RequestHeaders),
+			QueryString: queryString(r.URL),
+			HeadersSize: -1,
+			BodySize:    -1,
+		},
+		Response: Response{
+			Status:      r.StatusCode,
+			StatusText:  http.StatusText(r.StatusCode),
+			HTTPVersion: "HTTP/1.1",
+			Cookies:     []Cookie{},
+			Headers:     headers(r.ResponseHeaders),
+			Content: Content{
+				Size:     int64(len(r.ResponseBody)),
+				MimeType: headerValue(r.ResponseHeaders, "Content-Type"),
+				Text:     r.ResponseBody,
+			},
+			RedirectURL: headerValue(r.ResponseHeaders, "Location"),
+			HeadersSize: -1,
+			BodySize:    -1,
+		},
+		Timings:    Timings{Send: 0, Wait: float64(r.Duration), Receive: 0},
+		Error:      r.Error,
+		Denied:     r.Denied,
+		DenyReason: r.DenyReason,
+		Truncated:  r.BodyTruncated,
+	}
+	if r.RequestSize > 0 {
+		e.Request.BodySize = r.RequestSize
+	}
+	if r.RequestBody != "" {
+		e.Request.PostData = &PostData{
+			MimeType: headerValue(r.RequestHeaders, "Content-Type"),
+			Text:     r.RequestBody,
+		}
+	}
+	return e
+}
+
+// headers converts a recorded header map to HAR pairs, sorted by name.
+func headers(h map[string]string) []NVP {
+	out := make([]NVP, 0, len(h))
+	for name, value := range h {
+		out = append(out, NVP{Name: name, Value: value})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// headerValue looks up a recorded header case-insensitively.
+func headerValue(h map[string]string, name string) string {
+	for k, v := range h {
+		if strings.EqualFold(k, name) {
+			return v
+		}
+	}
+	return ""
+}
+
+// queryString returns the URL's query parameters as HAR pairs, in order.
+func queryString(rawURL string) []NVP {
+	out := []NVP{}
+	u, err := url.Parse(rawURL)
+	if err != nil || u.RawQuery == "" {
+		return out
+	}
+	for _, part := range strings.Split(u.RawQuery, "&") {
+		if part == "" {
+			continue
+		}
+		name, value, _ := strings.Cut(part, "=")
+		if n, err := url.QueryUnescape(name); err == nil {
+			name = n
+		}
+		if v, err := url.QueryUnescape(value); err == nil {
+			value = v
+		}
+		out = append(out, NVP{Name: name, Value: value})
+	}
+	return out
+}
@@ -0,0 +1,88 @@
+package har
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/majorcontext/moat/internal/storage"
+)
+
+func TestFromNetworkRequests(t *testing.T) {
+	ts := time.Date(2026, 3, 1, 12, 0, 1, 0, time.UTC)
+	reqs := []storage.NetworkRequest{
+		{
+			// Logged first, but started after the POST.
+			Timestamp:  ts.Add(-500 * time.Millisecond),
+			Method:     "GET",
+			URL:        "https://blocked.example.com/",
+			Duration:   0,
+			Denied:     true,
+			DenyReason: "network_policy",
+		},
+		{
+			Timestamp:       ts,
+			Method:          "POST",
+			URL:             "https://api.example.com/v1/items?page=2&q=a%20b",
+			StatusCode:      401,
+			Duration:        800,
+			RequestHeaders:  map[string]string{"Content-Type": "application/json", "Authorization": "[REDACTED]"},
+			ResponseHeaders: map[string]string{"content-type": "application/json"},
+			RequestBody:     `{"name":"widget"}`,
+			ResponseBody:    `{"error":"unauthorized"}`,
+			RequestSize:     17,
+		},
+	}
+
+	doc := FromNetworkRequests(reqs, "v1.2.3")
+	if doc.Log.Version != "1.2" || doc.Log.Creator.Name != "moat" || doc.Log.Creator.Version != "v1.2.3" {
+		t.Errorf("log = %+v", doc.Log)
+	}
+	if len(doc.Log.Entries) != 2 {
+		t.Fatalf("got %d entries, want 2", len(doc.Log.Entries))
+	}
+
+	// Entries are ordered by start time: the POST started at ts-800ms.
+	post := doc.Log.Entries[0]
+	if post.StartedDateTime != "2026-03-01T12:00:00.200Z" || post.Time != 800 {
+		t.Errorf("started %s, time %v", post.StartedDateTime, post.Time)
+	}
+	if post.Request.PostData == nil || post.Request.PostData.Text != `{"name":"widget"}` || post.Request.PostData.MimeType != "application/json" {
+		t.Errorf("postData = %+v", post.Request.PostData)
+	}
+	if post.Request.BodySize != 17 {
+		t.Errorf("request bodySize = %d, want 17", post.Request.BodySize)
+	}
+	wantQuery := []NVP{{"page", "2"}, {"q", "a b"}}
+	if len(post.Request.QueryString) != 2 || post.Request.QueryString[0] != wantQuery[0] || post.Request.QueryString[1] != wantQuery[1] {
+		t.Errorf("queryString = %+v", post.Request.QueryString)
+	}
+	if post.Request.Headers[0].Name != "Authorization" || post.Request.Headers[0].Value != "[REDACTED]" {
+		t.Errorf("headers = %+v, want sorted with Authorization redacted", post.Request.Headers)
+	}
+	if post.Response.Status != 401 || post.Response.StatusText != "Unauthorized" || post.Response.Content.MimeType != "application/json" || post.Response.Content.Text != `{"error":"unauthorized"}` {
+		t.Errorf("response = %+v", post.Response)
+	}
+
+	denied := doc.Log.Entries[1]
+	if !denied.Denied || denied.DenyReason != "network_policy" || denied.Request.PostData != nil {
+		t.Errorf("denied entry = %+v", denied)
+	}
+
+	// Lists the trace lacks are empty arrays, not null: HAR viewers reject null.
+	data, err := json.Marshal(denied)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var raw struct {
+		Request map[string]any `json:"request"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		t.Fatal(err)
+	}
+	for _, field := range []string{"headers", "cookies", "queryString"} {
+		if _, ok := raw.Request[field].([]any); !ok {
+			t.Errorf("request.%s = %v, want an array", field, raw.Request[field])
+		}
+	}
+}
@@ -13,3 +13,18 @@ const Proxy = "moat-proxy"
 // host. It is intentionally NOT in NO_PROXY so that host-service requests
 // flow through the proxy for policy enforcement (network.host rules).
 const HostGateway = "moat-host"
+
+// AzureIdentity is the hostname in the IDENTITY_ENDPOINT/MSI_ENDPOINT URLs
+// given to containers with an azure grant. It is never resolved and must NOT
+// be in NO_PROXY: Azure SDKs do not send the Bearer token a direct request to
+// the proxy needs, so the request goes through the proxy, which authenticates
+// it with Proxy-Authorization and serves it from the run's credential
+// endpoint handler.
+const AzureIdentity = "moat-azure-identity"
+
+// GCPMetadata is the hostname in GCE_METADATA_HOST/GCE_METADATA_IP given to
+// containers with a gcp grant. Like AzureIdentity it is never resolved and
+// must NOT be in NO_PROXY: metadata requests carry no run token, so they go
+// through the proxy to be authenticated and served from the run's credential
+// endpoint handler.
+const GCPMetadata = "moat-gcp-metadata"
@@ -3,6 +3,7 @@ package id
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
 	"strings"
 	"time"
@@ -27,6 +28,14 @@ func Generate(prefix string) string {
 	return prefix + "_" + hex.EncodeToString(b)
 }
 
+// FromKey derives a stable identifier with the given prefix from key, in
+// the same format as Generate. The same key always yields the same ID, so
+// callers can use it to make creation idempotent.
+func FromKey(prefix, key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return prefix + "_" + hex.EncodeToString(sum[:6])
+}
+
 // IsValid checks if an ID has the expected format: <prefix>_<12 hex chars>.
 // Returns true if the ID matches the format, false otherwise.
 // Returns false if prefix is empty or contains whitespace.
@@ -133,3 +133,16 @@ func TestIsValidWithGenerate(t *testing.T) {
 		}
 	}
 }
+
+func TestFromKey(t *testing.T) {
+	a := FromKey("run", "webhook-delivery-42")
+	if !IsValid(a, "run") {
+		t.Errorf("FromKey() = %q, not a valid run ID", a)
+	}
+	if b := FromKey("run", "webhook-delivery-42"); b != a {
+		t.Errorf("FromKey() = %q then %q, want the same ID for the same key", a, b)
+	}
+	if c := FromKey("run", "webhook-delivery-43"); c == a {
+		t.Errorf("FromKey() = %q for different keys", c)
+	}
+}
@@ -20,7 +20,7 @@ func TestIntegration_FullLifecycle(t *testing.T) {
 
 	// Initialize logger
 	err := Init(Options{
-		Verbose:       false,
+		Verbosity:     0,
 		Interactive:   false,
 		DebugDir:      tmpDir,
 		RetentionDays: 14,
@@ -10,13 +10,14 @@ import (
 var (
 	logger     *slog.Logger
 	fileWriter *FileWriter
-	verbose    bool
+	verbosity  int
 )
 
 // Options configures the logger.
 type Options struct {
-	// Verbose enables debug/info output to stderr (non-interactive only)
-	Verbose bool
+	// Verbosity selects which levels reach stderr (non-interactive only):
+	// 0 none, 1 info and above (-v), 2 or more everything (-vv).
+	Verbosity int
 	// JSONFormat uses JSON output format for stderr
 	JSONFormat bool
 	// Interactive mode suppresses debug/info to stderr regardless of Verbose
@@ -36,15 +37,21 @@ func Init(opts Options) error {
 		stderr = os.Stderr
 	}
 
-	verbose = opts.Verbose
+	verbosity = opts.Verbosity
 
 	var handlers []slog.Handler
 
-	// Stderr handler: suppressed by default, all levels if verbose && !interactive.
-	// User-visible output uses the ui package instead of slog.
+	// Stderr handler: suppressed by default, then widened one step per -v
+	// when not interactive. User-visible output uses the ui package instead
+	// of slog.
 	stderrLevel := slog.LevelError + 1 // nothing passes in normal mode
-	if opts.Verbose && !opts.Interactive {
-		stderrLevel = slog.LevelDebug
+	if !opts.Interactive {
+		switch {
+		case opts.Verbosity >= 2:
+			stderrLevel = slog.LevelDebug
+		case opts.Verbosity == 1:
+			stderrLevel = slog.LevelInfo
+		}
 	}
 
 	stderrOpts := &slog.HandlerOptions{
@@ -150,11 +157,11 @@ func Error(msg string, args ...any) {
 	logger.Error(msg, args...)
 }
 
-// Verbose returns true if verbose logging was enabled at init.
+// Verbose returns true if debug logging (-vv) was enabled at init.
 // Use this to gate sensitive debug output (tokens, credentials, raw output)
-// that should only appear when the user explicitly requests verbose mode.
+// that should only appear when the user explicitly requests it.
 func Verbose() bool {
-	return verbose
+	return verbosity >= 2
 }
 
 // With returns a logger with additional context.
@@ -14,7 +14,7 @@ func TestInit_FileLogging(t *testing.T) {
 
 	// Initialize with file logging
 	err := Init(Options{
-		Verbose:     false,
+		Verbosity:   0,
 		JSONFormat:  false,
 		Interactive: false,
 		DebugDir:    tmpDir,
@@ -48,7 +48,7 @@ func TestInit_StderrLevels(t *testing.T) {
 
 	// Initialize non-verbose, non-interactive
 	if err := Init(Options{
-		Verbose:     false,
+		Verbosity:   0,
 		JSONFormat:  false,
 		Interactive: false,
 		DebugDir:    tmpDir,
@@ -90,7 +90,7 @@ func TestInit_VerboseNonInteractive(t *testing.T) {
 
 	// Initialize verbose, non-interactive
 	if err := Init(Options{
-		Verbose:     true,
+		Verbosity:   2,
 		JSONFormat:  false,
 		Interactive: false,
 		DebugDir:    tmpDir,
@@ -115,13 +115,43 @@ func TestInit_VerboseNonInteractive(t *testing.T) {
 	Close()
 }
 
+func TestInit_VerbosityOneHidesDebug(t *testing.T) {
+	var stderr bytes.Buffer
+	tmpDir := t.TempDir()
+
+	// -v: info and above, but not debug
+	if err := Init(Options{
+		Verbosity: 1,
+		DebugDir:  tmpDir,
+		Stderr:    &stderr,
+	}); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+
+	Debug("debug message")
+	Info("info message")
+
+	output := stderr.String()
+	if strings.Contains(output, "debug message") {
+		t.Error("debug should not appear on stderr at verbosity 1")
+	}
+	if !strings.Contains(output, "info message") {
+		t.Error("info should appear on stderr at verbosity 1")
+	}
+	if Verbose() {
+		t.Error("Verbose() should be false at verbosity 1")
+	}
+
+	Close()
+}
+
 func TestInit_InteractiveIgnoresVerbose(t *testing.T) {
 	var stderr bytes.Buffer
 	tmpDir := t.TempDir()
 
 	// Initialize verbose + interactive (verbose should be ignored for stderr)
 	if err := Init(Options{
-		Verbose:     true,
+		Verbosity:   2,
 		JSONFormat:  false,
 		Interactive: true,
 		DebugDir:    tmpDir,
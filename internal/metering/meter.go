@@ -0,0 +1,233 @@
+// Package metering extracts LLM token usage from API responses as they stream
+// through the proxy, prices it, and aggregates it into cost reports.
+//
+// The proxy daemon wraps each response body from a metered host in a Meter.
+// When the body is fully read, the usage the provider reported (the "usage"
+// object of a JSON response, or of the message_start/message_delta and
+// response.completed events of a stream) is written to the run's usage.jsonl.
+// Reports are built from those records and the run metadata only; nothing is
+// sent anywhere.
+package metering
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"mime"
+	"strings"
+
+	"github.com/majorcontext/moat/internal/storage"
+)
+
+// Providers whose responses are metered.
+const (
+	ProviderAnthropic = "anthropic"
+	ProviderOpenAI    = "openai"
+)
+
+// hosts maps metered API hosts to the provider whose usage format they use.
+var hosts = map[string]string{
+	"api.anthropic.com": ProviderAnthropic,
+	"api.openai.com":    ProviderOpenAI,
+}
+
+// ProviderForHost returns the provider metered on host, or "" if responses
+// from host are not metered.
+func ProviderForHost(host string) string {
+	return hosts[strings.ToLower(host)]
+}
+
+// Hosts returns the metered API hosts.
+func Hosts() []string {
+	out := make([]string, 0, len(hosts))
+	for h := range hosts {
+		out = append(out, h)
+	}
+	return out
+}
+
+// maxBufferedBody bounds how much of a non-streaming or compressed response
+// a Meter keeps. Usage in larger bodies is not recorded.
+const maxBufferedBody = 16 << 20
+
+// Meter accumulates the usage reported in one response body. Feed it the
+// body with Write as the client reads it, then call Usage.
+type Meter struct {
+	provider string
+	sse      bool
+	gzip     bool
+
+	buf      []byte // whole body, or the unfinished line of an identity stream
+	overflow bool
+
+	usage storage.Usage
+	seen  bool
+}
+
+// NewMeter returns a Meter for a response from provider with the given
+// Content-Type and Content-Encoding headers. It returns nil for encodings it
+// cannot decode (such as br), whose usage is not recorded.
+func NewMeter(provider, contentType, contentEncoding string) *Meter {
+	m := &Meter{provider: provider, usage: storage.Usage{Provider: provider}}
+	if mt, _, err := mime.ParseMediaType(contentType); err == nil {
+		m.sse = mt == "text/event-stream"
+	}
+	switch strings.ToLower(strings.TrimSpace(contentEncoding)) {
+	case "", "identity":
+	case "gzip":
+		m.gzip = true
+	default:
+		return nil
+	}
+	return m
+}
+
+// Write feeds response bytes to the meter. It never fails, so it can sit
+// behind an io.TeeReader without disturbing the response.
+func (m *Meter) Write(p []byte) (int, error) {
+	if m.overflow {
+		return len(p), nil
+	}
+	m.buf = append(m.buf, p...)
+	if m.sse && !m.gzip {
+		// Parse complete lines as they arrive so a long stream is not held
+		// in memory.
+		if i := bytes.LastIndexByte(m.buf, '\n'); i >= 0 {
+			m.scanEvents(m.buf[:i+1])
+			m.buf = append(m.buf[:0], m.buf[i+1:]...)
+		}
+	}
+	if len(m.buf) > maxBufferedBody {
+		m.overflow = true
+		m.buf = nil
+	}
+	return len(p), nil
+}
+
+// Usage returns the usage reported in the body fed so far, and whether the
+// body reported any. Call it once the body has been read to the end.
+func (m *Meter) Usage() (storage.Usage, bool) {
+	if !m.overflow && len(m.buf) > 0 {
+		body := m.buf
+		m.buf = nil
+		if m.gzip {
+			zr, err := gzip.NewReader(bytes.NewReader(body))
+			if err != nil {
+				return m.usage, m.seen
+			}
+			body, err = io.ReadAll(io.LimitReader(zr, maxBufferedBody))
+			if err != nil && len(body) == 0 {
+				return m.usage, m.seen
+			}
+		}
+		if m.sse {
+			m.scanEvents(body)
+		} else {
+			m.parse(body)
+		}
+	}
+	return m.usage, m.seen
+}
+
+// scanEvents parses the data lines of server-sent events.
+func (m *Meter) scanEvents(b []byte) {
+	sc := bufio.NewScanner(bytes.NewReader(b))
+	sc.Buffer(make([]byte, 0, 64*1024), maxBufferedBody)
+	for sc.Scan() {
+		data, ok := bytes.CutPrefix(sc.Bytes(), []byte("data:"))
+		if !ok {
+			continue
+		}
+		data = bytes.TrimSpace(data)
+		if len(data) == 0 || data[0] != '{' {
+			continue // e.g. OpenAI's "[DONE]"
+		}
+		m.parse(data)
+	}
+}
+
+// payload covers the response and event shapes that carry usage:
+// Anthropic messages and message_start/message_delta events, OpenAI chat
+// completions and their final stream chunk, and OpenAI Responses API
+// responses and response.completed events.
+type payload struct {
+	Model    string       `json:"model"`
+	Usage    *usageFields `json:"usage"`
+	Message  *payload     `json:"message"`
+	Response *payload     `json:"response"`
+}
+
+type usageFields struct {
+	InputTokens              int64 `json:"input_tokens"`
+	OutputTokens             int64 `json:"output_tokens"`
+	CacheCreationInputTokens int64 `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int64 `json:"cache_read_input_tokens"`
+	PromptTokens             int64 `json:"prompt_tokens"`
+	CompletionTokens         int64 `json:"completion_tokens"`
+	PromptTokensDetails      struct {
+		CachedTokens int64 `json:"cached_tokens"`
+	} `json:"prompt_tokens_details"`
+	InputTokensDetails struct {
+		CachedTokens int64 `json:"cached_tokens"`
+	} `json:"input_tokens_details"`
+}
+
+func (m *Meter) parse(b []byte) {
+	var p payload
+	if json.Unmarshal(b, &p) != nil {
+		return
+	}
+	m.apply(&p)
+}
+
+func (m *Meter) apply(p *payload) {
+	if p.Message != nil {
+		m.apply(p.Message)
+	}
+	if p.Response != nil {
+		m.apply(p.Response)
+	}
+	if p.Model != "" {
+		m.usage.Model = p.Model
+	}
+	if p.Usage == nil {
+		return
+	}
+	u := normalize(m.provider, p.Usage)
+	// Stream events repeat cumulative counts (message_delta carries the
+	// running output total), so keep the largest value seen per field.
+	m.usage.InputTokens = max(m.usage.InputTokens, u.InputTokens)
+	m.usage.OutputTokens = max(m.usage.OutputTokens, u.OutputTokens)
+	m.usage.CacheReadTokens = max(m.usage.CacheReadTokens, u.CacheReadTokens)
+	m.usage.CacheWriteTokens = max(m.usage.CacheWriteTokens, u.CacheWriteTokens)
+	m.seen = m.seen || u != (storage.Usage{})
+}
+
+// normalize maps a provider's usage object onto storage.Usage, where
+// InputTokens excludes cached input. Anthropic already reports it that way;
+// OpenAI includes cached tokens in its input count.
+func normalize(provider string, f *usageFields) storage.Usage {
+	if provider == ProviderAnthropic {
+		return storage.Usage{
+			InputTokens:      f.InputTokens,
+			OutputTokens:     f.OutputTokens,
+			CacheReadTokens:  f.CacheReadInputTokens,
+			CacheWriteTokens: f.CacheCreationInputTokens,
+		}
+	}
+	// Chat Completions uses prompt/completion; the Responses API uses
+	// input/output.
+	input, cached := f.PromptTokens, f.PromptTokensDetails.CachedTokens
+	output := f.CompletionTokens
+	if input == 0 && output == 0 {
+		input, cached = f.InputTokens, f.InputTokensDetails.CachedTokens
+		output = f.OutputTokens
+	}
+	return storage.Usage{
+		InputTokens:     max(input-cached, 0),
+		OutputTokens:    output,
+		CacheReadTokens: cached,
+	}
+}
@@ -0,0 +1,130 @@
+package metering
+
+import (
+	"bytes"
+	"compress/gzip"
+	"testing"
+
+	"github.com/majorcontext/moat/internal/storage"
+)
+
+func meter(t *testing.T, provider, contentType, encoding string, chunks ...string) (storage.Usage, bool) {
+	t.Helper()
+	m := NewMeter(provider, contentType, encoding)
+	if m == nil {
+		t.Fatalf("NewMeter(%q, %q, %q) = nil", provider, contentType, encoding)
+	}
+	for _, c := range chunks {
+		if n, err := m.Write([]byte(c)); n != len(c) || err != nil {
+			t.Fatalf("Write = %d, %v", n, err)
+		}
+	}
+	return m.Usage()
+}
+
+func TestMeter(t *testing.T) {
+	tests := []struct {
+		name        string
+		provider    string
+		contentType string
+		chunks      []string
+		want        storage.Usage
+	}{
+		{
+			name:        "anthropic message",
+			provider:    ProviderAnthropic,
+			contentType: "application/json",
+			chunks: []string{`{"id":"msg_1","model":"claude-opus-4-5","content":[],` +
+				`"usage":{"input_tokens":20,"cache_creation_input_tokens":100,"cache_read_input_tokens":300,"output_tokens":50}}`},
+			want: storage.Usage{Model: "claude-opus-4-5", InputTokens: 20, OutputTokens: 50, CacheReadTokens: 300, CacheWriteTokens: 100},
+		},
+		{
+			name:        "anthropic stream split mid-line",
+			provider:    ProviderAnthropic,
+			contentType: "text/event-stream",
+			chunks: []string{
+				"event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"model\":\"claude-haiku-4-5\",",
+				"\"usage\":{\"input_tokens\":7,\"output_tokens\":1}}}\n\n",
+				"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"delta\":{\"text\":\"hi\"}}\n\n",
+				"event: message_delta\ndata: {\"type\":\"message_delta\",\"usage\":{\"output_tokens\":9}}\n\n",
+			},
+			want: storage.Usage{Model: "claude-haiku-4-5", InputTokens: 7, OutputTokens: 9},
+		},
+		{
+			name:        "openai chat completion",
+			provider:    ProviderOpenAI,
+			contentType: "application/json",
+			chunks: []string{`{"model":"gpt-4o-2024-08-06","usage":{"prompt_tokens":1000,"completion_tokens":30,` +
+				`"prompt_tokens_details":{"cached_tokens":600}}}`},
+			want: storage.Usage{Model: "gpt-4o-2024-08-06", InputTokens: 400, OutputTokens: 30, CacheReadTokens: 600},
+		},
+		{
+			name:        "openai chat stream",
+			provider:    ProviderOpenAI,
+			contentType: "text/event-stream",
+			chunks: []string{
+				"data: {\"model\":\"gpt-4o-mini\",\"choices\":[{\"delta\":{\"content\":\"hi\"}}],\"usage\":null}\n\n",
+				"data: {\"model\":\"gpt-4o-mini\",\"choices\":[],\"usage\":{\"prompt_tokens\":12,\"completion_tokens\":3}}\n\n",
+				"data: [DONE]\n\n",
+			},
+			want: storage.Usage{Model: "gpt-4o-mini", InputTokens: 12, OutputTokens: 3},
+		},
+		{
+			name:        "openai responses stream",
+			provider:    ProviderOpenAI,
+			contentType: "text/event-stream",
+			chunks: []string{
+				"event: response.created\ndata: {\"type\":\"response.created\",\"response\":{\"model\":\"gpt-5\",\"usage\":null}}\n\n",
+				"event: response.completed\ndata: {\"type\":\"response.completed\",\"response\":{\"model\":\"gpt-5\"," +
+					"\"usage\":{\"input_tokens\":50,\"input_tokens_details\":{\"cached_tokens\":10},\"output_tokens\":8}}}\n\n",
+			},
+			want: storage.Usage{Model: "gpt-5", InputTokens: 40, OutputTokens: 8, CacheReadTokens: 10},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := meter(t, tt.provider, tt.contentType, "", tt.chunks...)
+			if !ok {
+				t.Fatal("no usage recorded")
+			}
+			tt.want.Provider = tt.provider
+			if got != tt.want {
+				t.Errorf("usage = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMeterGzip(t *testing.T) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write([]byte(`{"model":"claude-sonnet-4-5","usage":{"input_tokens":3,"output_tokens":4}}`))
+	zw.Close()
+	b := buf.Bytes()
+
+	got, ok := meter(t, ProviderAnthropic, "application/json", "gzip", string(b[:10]), string(b[10:]))
+	if !ok || got.InputTokens != 3 || got.OutputTokens != 4 {
+		t.Errorf("usage = %+v, %v; want 3 in / 4 out", got, ok)
+	}
+}
+
+func TestMeterNoUsage(t *testing.T) {
+	if _, ok := meter(t, ProviderAnthropic, "application/json", "", `{"type":"error"}`); ok {
+		t.Error("usage recorded for a body without usage")
+	}
+	if _, ok := meter(t, ProviderOpenAI, "application/json", "", `not json`); ok {
+		t.Error("usage recorded for a non-JSON body")
+	}
+	if m := NewMeter(ProviderOpenAI, "application/json", "br"); m != nil {
+		t.Error("NewMeter accepted brotli encoding")
+	}
+}
+
+func TestProviderForHost(t *testing.T) {
+	if got := ProviderForHost("API.Anthropic.com"); got != ProviderAnthropic {
+		t.Errorf("ProviderForHost(API.Anthropic.com) = %q", got)
+	}
+	if got := ProviderForHost("api.github.com"); got != "" {
+		t.Errorf("ProviderForHost(api.github.com) = %q, want empty", got)
+	}
+}
@@ -0,0 +1,97 @@
+package metering
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/majorcontext/moat/internal/storage"
+)
+
+// Price is a model's price in USD per million tokens.
+type Price struct {
+	Input      float64 `yaml:"input" json:"input"`
+	Output     float64 `yaml:"output" json:"output"`
+	CacheRead  float64 `yaml:"cache_read" json:"cache_read"`
+	CacheWrite float64 `yaml:"cache_write" json:"cache_write"`
+}
+
+// Prices maps model name prefixes to prices. The longest matching prefix
+// wins, so "claude-opus-4-5" can be priced apart from "claude-opus-4".
+type Prices map[string]Price
+
+// DefaultPrices are the providers' published list prices for standard
+// (non-batch) requests. They do not reflect negotiated discounts; load a
+// price file with LoadPrices for those.
+func DefaultPrices() Prices {
+	return Prices{
+		"claude-opus-4-5":   {Input: 5, Output: 25, CacheRead: 0.50, CacheWrite: 6.25},
+		"claude-opus-4":     {Input: 15, Output: 75, CacheRead: 1.50, CacheWrite: 18.75},
+		"claude-3-opus":     {Input: 15, Output: 75, CacheRead: 1.50, CacheWrite: 18.75},
+		"claude-sonnet-4":   {Input: 3, Output: 15, CacheRead: 0.30, CacheWrite: 3.75},
+		"claude-3-7-sonnet": {Input: 3, Output: 15, CacheRead: 0.30, CacheWrite: 3.75},
+		"claude-3-5-sonnet": {Input: 3, Output: 15, CacheRead: 0.30, CacheWrite: 3.75},
+		"claude-haiku-4-5":  {Input: 1, Output: 5, CacheRead: 0.10, CacheWrite: 1.25},
+		"claude-3-5-haiku":  {Input: 0.80, Output: 4, CacheRead: 0.08, CacheWrite: 1},
+		"claude-3-haiku":    {Input: 0.25, Output: 1.25, CacheRead: 0.03, CacheWrite: 0.30},
+		"gpt-5":             {Input: 1.25, Output: 10, CacheRead: 0.125},
+		"gpt-5-mini":        {Input: 0.25, Output: 2, CacheRead: 0.025},
+		"gpt-5-nano":        {Input: 0.05, Output: 0.40, CacheRead: 0.005},
+		"gpt-4.1":           {Input: 2, Output: 8, CacheRead: 0.50},
+		"gpt-4.1-mini":      {Input: 0.40, Output: 1.60, CacheRead: 0.10},
+		"gpt-4.1-nano":      {Input: 0.10, Output: 0.40, CacheRead: 0.025},
+		"gpt-4o":            {Input: 2.50, Output: 10, CacheRead: 1.25},
+		"gpt-4o-mini":       {Input: 0.15, Output: 0.60, CacheRead: 0.075},
+		"o3":                {Input: 2, Output: 8, CacheRead: 0.50},
+		"o4-mini":           {Input: 1.10, Output: 4.40, CacheRead: 0.275},
+	}
+}
+
+// LoadPrices reads a YAML price file (model prefix to Price) and layers it
+// over DefaultPrices.
+func LoadPrices(path string) (Prices, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading price file: %w", err)
+	}
+	var overrides Prices
+	if err := yaml.Unmarshal(data, &overrides); err != nil {
+		return nil, fmt.Errorf("parsing price file %s: %w", path, err)
+	}
+	prices := DefaultPrices()
+	for model, p := range overrides {
+		prices[model] = p
+	}
+	return prices, nil
+}
+
+// Lookup returns the price for model, matching the longest prefix.
+func (p Prices) Lookup(model string) (Price, bool) {
+	model = strings.ToLower(model)
+	prefixes := make([]string, 0, len(p))
+	for prefix := range p {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Slice(prefixes, func(i, j int) bool { return len(prefixes[i]) > len(prefixes[j]) })
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(model, strings.ToLower(prefix)) {
+			return p[prefix], true
+		}
+	}
+	return Price{}, false
+}
+
+// Cost returns the USD cost of u, and false if u's model has no price.
+func (p Prices) Cost(u storage.Usage) (float64, bool) {
+	price, ok := p.Lookup(u.Model)
+	if !ok {
+		return 0, false
+	}
+	return (float64(u.InputTokens)*price.Input +
+		float64(u.OutputTokens)*price.Output +
+		float64(u.CacheReadTokens)*price.CacheRead +
+		float64(u.CacheWriteTokens)*price.CacheWrite) / 1e6, true
+}
@@ -0,0 +1,203 @@
+package metering
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/majorcontext/moat/internal/storage"
+)
+
+// Group-by keys accepted by ParseGroupBy. Labels are grouped with
+// "label:<key>".
+const (
+	GroupRun   = "run"
+	GroupAgent = "agent"
+	GroupRepo  = "repo"
+	GroupModel = "model"
+	GroupName  = "name"
+	GroupGroup = "group"
+)
+
+// ParseGroupBy validates group-by keys, accepting comma-separated lists.
+func ParseGroupBy(specs []string) ([]string, error) {
+	var keys []string
+	for _, spec := range specs {
+		for _, k := range strings.Split(spec, ",") {
+			k = strings.TrimSpace(k)
+			switch {
+			case k == "":
+				continue
+			case k == GroupRun, k == GroupAgent, k == GroupRepo, k == GroupModel, k == GroupName, k == GroupGroup:
+			case strings.HasPrefix(k, "label:") && len(k) > len("label:"):
+			default:
+				return nil, fmt.Errorf("invalid group-by key %q: use run, name, group, agent, repo, model, or label:<key>", k)
+			}
+			keys = append(keys, k)
+		}
+	}
+	return keys, nil
+}
+
+// RunUsage is one run's usage records with the run attributes reports group
+// by.
+type RunUsage struct {
+	RunID  string
+	Name   string
+	Agent  string
+	Repo   string
+	Group  string
+	Labels map[string]string
+	Usage  []storage.Usage
+}
+
+// Totals are token counts and cost summed over usage records.
+type Totals struct {
+	Runs             int     `json:"runs"`
+	Requests         int     `json:"requests"`
+	InputTokens      int64   `json:"input_tokens"`
+	OutputTokens     int64   `json:"output_tokens"`
+	CacheReadTokens  int64   `json:"cache_read_tokens"`
+	CacheWriteTokens int64   `json:"cache_write_tokens"`
+	CostUSD          float64 `json:"cost_usd"`
+	// UnpricedRequests counts requests whose model has no price; their
+	// tokens are included but their cost is not.
+	UnpricedRequests int `json:"unpriced_requests"`
+}
+
+func (t *Totals) add(u storage.Usage, prices Prices) {
+	t.Requests++
+	t.InputTokens += u.InputTokens
+	t.OutputTokens += u.OutputTokens
+	t.CacheReadTokens += u.CacheReadTokens
+	t.CacheWriteTokens += u.CacheWriteTokens
+	if cost, ok := prices.Cost(u); ok {
+		t.CostUSD += cost
+	} else {
+		t.UnpricedRequests++
+	}
+}
+
+// Row is the totals for one combination of group-by values.
+type Row struct {
+	Group map[string]string `json:"group"`
+	Totals
+}
+
+// Report is a cost report over a time window.
+type Report struct {
+	Since   time.Time `json:"since,omitzero"`
+	Until   time.Time `json:"until,omitzero"`
+	GroupBy []string  `json:"group_by"`
+	Rows    []Row     `json:"rows"`
+	Total   Totals    `json:"total"`
+}
+
+// BuildReport sums the usage records with timestamps in [since, until) by
+// the groupBy keys. A zero since or until leaves that end open. Rows are
+// sorted by cost, highest first.
+func BuildReport(runs []RunUsage, groupBy []string, since, until time.Time, prices Prices) Report {
+	rep := Report{Since: since, Until: until, GroupBy: groupBy}
+	rows := map[string]*Row{}
+	var order []string
+	runsSeen := map[string]map[string]bool{} // row key -> run IDs
+
+	for _, r := range runs {
+		counted := false
+		for _, u := range r.Usage {
+			if !since.IsZero() && u.Timestamp.Before(since) {
+				continue
+			}
+			if !until.IsZero() && !u.Timestamp.Before(until) {
+				continue
+			}
+			group := make(map[string]string, len(groupBy))
+			parts := make([]string, len(groupBy))
+			for i, k := range groupBy {
+				group[k] = groupValue(r, u, k)
+				parts[i] = group[k]
+			}
+			key := strings.Join(parts, "\x00")
+			row, ok := rows[key]
+			if !ok {
+				row = &Row{Group: group}
+				rows[key] = row
+				runsSeen[key] = map[string]bool{}
+				order = append(order, key)
+			}
+			row.add(u, prices)
+			if !runsSeen[key][r.RunID] {
+				runsSeen[key][r.RunID] = true
+				row.Runs++
+			}
+			rep.Total.add(u, prices)
+			if !counted {
+				counted = true
+				rep.Total.Runs++
+			}
+		}
+	}
+
+	rep.Rows = make([]Row, 0, len(order))
+	for _, key := range order {
+		rep.Rows = append(rep.Rows, *rows[key])
+	}
+	sort.SliceStable(rep.Rows, func(i, j int) bool { return rep.Rows[i].CostUSD > rep.Rows[j].CostUSD })
+	return rep
+}
+
+func groupValue(r RunUsage, u storage.Usage, key string) string {
+	switch key {
+	case GroupRun:
+		return r.RunID
+	case GroupName:
+		return r.Name
+	case GroupGroup:
+		return r.Group
+	case GroupAgent:
+		return r.Agent
+	case GroupRepo:
+		return r.Repo
+	case GroupModel:
+		return u.Model
+	}
+	return r.Labels[strings.TrimPrefix(key, "label:")]
+}
+
+// WriteCSV writes the report's rows as CSV with a header line: one column per
+// group-by key, then the totals. The grand total is left to the reader's
+// spreadsheet.
+func WriteCSV(w io.Writer, rep Report) error {
+	cw := csv.NewWriter(w)
+	header := append(append([]string{}, rep.GroupBy...),
+		"runs", "requests", "input_tokens", "output_tokens",
+		"cache_read_tokens", "cache_write_tokens", "cost_usd", "unpriced_requests")
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	for _, row := range rep.Rows {
+		rec := make([]string, 0, len(header))
+		for _, k := range rep.GroupBy {
+			rec = append(rec, row.Group[k])
+		}
+		rec = append(rec,
+			strconv.Itoa(row.Runs),
+			strconv.Itoa(row.Requests),
+			strconv.FormatInt(row.InputTokens, 10),
+			strconv.FormatInt(row.OutputTokens, 10),
+			strconv.FormatInt(row.CacheReadTokens, 10),
+			strconv.FormatInt(row.CacheWriteTokens, 10),
+			strconv.FormatFloat(row.CostUSD, 'f', 4, 64),
+			strconv.Itoa(row.UnpricedRequests),
+		)
+		if err := cw.Write(rec); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,131 @@
+package metering
+
+import (
+	"bytes"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/majorcontext/moat/internal/storage"
+)
+
+func TestPricesLookup(t *testing.T) {
+	p := DefaultPrices()
+	tests := []struct {
+		model string
+		input float64
+		ok    bool
+	}{
+		{"claude-opus-4-5-20251101", 5, true},
+		{"claude-opus-4-1-20250805", 15, true},
+		{"gpt-4o-mini-2024-07-18", 0.15, true},
+		{"gpt-4o-2024-08-06", 2.50, true},
+		{"mystery-model", 0, false},
+	}
+	for _, tt := range tests {
+		price, ok := p.Lookup(tt.model)
+		if ok != tt.ok || price.Input != tt.input {
+			t.Errorf("Lookup(%q) = %+v, %v; want input %v, %v", tt.model, price, ok, tt.input, tt.ok)
+		}
+	}
+
+	cost, ok := p.Cost(storage.Usage{Model: "claude-sonnet-4-5", InputTokens: 1_000_000, OutputTokens: 100_000, CacheReadTokens: 1_000_000})
+	if !ok || math.Abs(cost-(3+1.5+0.3)) > 1e-9 {
+		t.Errorf("Cost = %v, %v; want 4.8", cost, ok)
+	}
+}
+
+func TestLoadPrices(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "prices.yaml")
+	data := "claude-sonnet-4: {input: 2, output: 10}\ninternal-model: {input: 1, output: 1}\n"
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	p, err := LoadPrices(path)
+	if err != nil {
+		t.Fatalf("LoadPrices: %v", err)
+	}
+	if got, _ := p.Lookup("claude-sonnet-4-5"); got.Input != 2 {
+		t.Errorf("override not applied: %+v", got)
+	}
+	if _, ok := p.Lookup("internal-model-v2"); !ok {
+		t.Error("added model not found")
+	}
+	if _, ok := p.Lookup("gpt-4o"); !ok {
+		t.Error("defaults not kept")
+	}
+}
+
+func TestBuildReport(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2024, 1, d, 12, 0, 0, 0, time.UTC) }
+	use := func(d int, model string, in, out int64) storage.Usage {
+		return storage.Usage{Timestamp: day(d), Model: model, InputTokens: in, OutputTokens: out}
+	}
+	runs := []RunUsage{
+		{RunID: "run_1", Agent: "claude", Labels: map[string]string{"team": "infra"}, Usage: []storage.Usage{
+			use(2, "claude-sonnet-4-5", 1_000_000, 0),
+			use(3, "claude-sonnet-4-5", 0, 1_000_000),
+		}},
+		{RunID: "run_2", Agent: "codex", Labels: map[string]string{"team": "infra"}, Usage: []storage.Usage{
+			use(2, "gpt-4o", 1_000_000, 0),
+			use(2, "mystery-model", 10, 10),
+		}},
+		{RunID: "run_3", Agent: "claude", Labels: map[string]string{"team": "web"}, Usage: []storage.Usage{
+			use(1, "claude-sonnet-4-5", 1_000_000, 0), // before since
+			use(5, "claude-sonnet-4-5", 1_000_000, 0),
+			use(9, "claude-sonnet-4-5", 1_000_000, 0), // at until
+		}},
+		{RunID: "run_4", Agent: "claude", Usage: []storage.Usage{use(1, "claude-sonnet-4-5", 5, 5)}},
+	}
+
+	rep := BuildReport(runs, []string{"label:team"}, day(2), day(9), DefaultPrices())
+	if len(rep.Rows) != 2 {
+		t.Fatalf("got %d rows, want 2: %+v", len(rep.Rows), rep.Rows)
+	}
+	infra, web := rep.Rows[0], rep.Rows[1]
+	if infra.Group["label:team"] != "infra" || web.Group["label:team"] != "web" {
+		t.Fatalf("rows not sorted by cost: %+v", rep.Rows)
+	}
+	if infra.Runs != 2 || infra.Requests != 4 || infra.UnpricedRequests != 1 {
+		t.Errorf("infra = %+v", infra.Totals)
+	}
+	if math.Abs(infra.CostUSD-(3+15+2.5)) > 1e-9 {
+		t.Errorf("infra cost = %v, want 20.5", infra.CostUSD)
+	}
+	if web.Runs != 1 || web.Requests != 1 || math.Abs(web.CostUSD-3) > 1e-9 {
+		t.Errorf("web = %+v", web.Totals)
+	}
+	if rep.Total.Runs != 3 || rep.Total.Requests != 5 {
+		t.Errorf("total = %+v", rep.Total)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteCSV(&buf, rep); err != nil {
+		t.Fatalf("WriteCSV: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("CSV has %d lines, want 3:\n%s", len(lines), buf.String())
+	}
+	if !strings.HasPrefix(lines[0], "label:team,runs,requests,") {
+		t.Errorf("header = %q", lines[0])
+	}
+	if lines[1] != "infra,2,4,2000010,1000010,0,0,20.5000,1" {
+		t.Errorf("infra row = %q", lines[1])
+	}
+}
+
+func TestParseGroupBy(t *testing.T) {
+	got, err := ParseGroupBy([]string{"agent, repo", "label:team", "group"})
+	if err != nil || strings.Join(got, "|") != "agent|repo|label:team|group" {
+		t.Errorf("ParseGroupBy = %v, %v", got, err)
+	}
+	for _, bad := range []string{"team", "label:"} {
+		if _, err := ParseGroupBy([]string{bad}); err == nil {
+			t.Errorf("ParseGroupBy(%q) succeeded", bad)
+		}
+	}
+}
@@ -0,0 +1,263 @@
+// Package moatctl implements the moatctl helper that moat mounts into every
+// run at /moat/bin/moatctl. It calls the proxy daemon's moatctl endpoint with
+// the run's proxy token, and is built as a static binary so it works in any
+// image, including ones without a shell or curl.
+package moatctl
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Exit codes.
+const (
+	exitOK       = 0
+	exitError    = 1
+	exitUsage    = 2
+	exitDeclined = 3 // `moatctl approve` was denied or timed out
+)
+
+const usage = `Usage: moatctl <command> [options]
+
+Commands:
+  snapshot [--label LABEL]                  Snapshot the workspace
+  progress MESSAGE [--percent N]            Report progress to the host
+  budget                                    Show remaining LLM quota for today
+  approve HOST [--reason R] [--wait SECS]   Ask the user to allow a network host
+  clip [FILE]                               Offer stdin or FILE to the host
+  paste [--output FILE]                     Print or save what the host sent
+`
+
+// defaultApproveWait is how long `moatctl approve` waits for a decision
+// unless --wait is given.
+const defaultApproveWait = 300
+
+// Main runs moatctl with args (without the program name) and returns the
+// exit code. The endpoint and token are read from MOAT_CTL_URL and
+// MOAT_CTL_TOKEN.
+func Main(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return exitUsage
+	}
+	cmd, args := args[0], args[1:]
+	if cmd == "help" || cmd == "-h" || cmd == "--help" {
+		fmt.Fprint(stdout, usage)
+		return exitOK
+	}
+	base := os.Getenv("MOAT_CTL_URL")
+	if base == "" {
+		fmt.Fprintln(stderr, "moatctl: MOAT_CTL_URL not set (not running under moat?)")
+		return exitError
+	}
+	c := &client{base: strings.TrimSuffix(base, "/"), token: os.Getenv("MOAT_CTL_TOKEN"), stdout: stdout, stderr: stderr}
+
+	switch cmd {
+	case "snapshot":
+		opts, rest, ok := parseOptions(args, "--label")
+		if !ok || len(rest) > 0 {
+			break
+		}
+		return c.print(c.call(http.MethodPost, "snapshot", 5*time.Minute, form("label", opts["--label"])))
+	case "progress":
+		opts, rest, ok := parseOptions(args, "--percent")
+		if !ok || len(rest) == 0 {
+			break
+		}
+		_, code := c.call(http.MethodPost, "progress", 10*time.Second,
+			form("message", strings.Join(rest, " "), "percent", opts["--percent"]))
+		return code
+	case "budget":
+		if len(args) > 0 {
+			break
+		}
+		return c.print(c.call(http.MethodGet, "budget", 10*time.Second, nil))
+	case "approve":
+		opts, rest, ok := parseOptions(args, "--reason", "--wait")
+		if !ok || len(rest) != 1 {
+			break
+		}
+		wait := defaultApproveWait
+		if v, set := opts["--wait"]; set {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				break
+			}
+			wait = n
+		}
+		body, code := c.call(http.MethodPost, "approve", time.Duration(wait+10)*time.Second,
+			form("host", rest[0], "reason", opts["--reason"], "wait", strconv.Itoa(wait)))
+		if code != exitOK {
+			return code
+		}
+		c.print(body, code)
+		var resp struct {
+			Status string `json:"status"`
+		}
+		if json.Unmarshal(body, &resp) != nil || resp.Status != "approved" {
+			return exitDeclined
+		}
+		return exitOK
+	case "clip":
+		if len(args) > 1 {
+			break
+		}
+		return c.clip(args, stdin)
+	case "paste":
+		opts, rest, ok := parseOptions(args, "--output", "-o")
+		if !ok || len(rest) > 0 {
+			break
+		}
+		body, code := c.call(http.MethodGet, "paste", 10*time.Second, nil)
+		if code != exitOK {
+			return code
+		}
+		output := opts["--output"]
+		if v, set := opts["-o"]; set {
+			output = v
+		}
+		if output == "" {
+			return c.print(body, code)
+		}
+		if err := os.WriteFile(output, body, 0o644); err != nil {
+			fmt.Fprintf(stderr, "moatctl: %v\n", err)
+			return exitError
+		}
+		return exitOK
+	}
+	fmt.Fprint(stderr, usage)
+	return exitUsage
+}
+
+// parseOptions splits args into the values of the named options, each of
+// which takes a value, and the remaining arguments. It reports false if an
+// option is missing its value or an unknown option is given.
+func parseOptions(args []string, names ...string) (map[string]string, []string, bool) {
+	opts := make(map[string]string)
+	var rest []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		known := false
+		for _, name := range names {
+			if arg == name {
+				known = true
+			}
+		}
+		switch {
+		case known:
+			if i+1 >= len(args) {
+				return nil, nil, false
+			}
+			opts[arg] = args[i+1]
+			i++
+		case strings.HasPrefix(arg, "-") && arg != "-":
+			return nil, nil, false
+		default:
+			rest = append(rest, arg)
+		}
+	}
+	return opts, rest, true
+}
+
+// form returns a form-encoded request body from key/value pairs.
+func form(kv ...string) *request {
+	v := url.Values{}
+	for i := 0; i+1 < len(kv); i += 2 {
+		v.Set(kv[i], kv[i+1])
+	}
+	return &request{body: strings.NewReader(v.Encode()), contentType: "application/x-www-form-urlencoded"}
+}
+
+type request struct {
+	body        io.Reader
+	contentType string
+	clipName    string
+}
+
+type client struct {
+	base   string
+	token  string
+	stdout io.Writer
+	stderr io.Writer
+}
+
+// call sends a moatctl command and returns the response body, with exitOK
+// or, after reporting the failure, exitError.
+func (c *client) call(method, cmd string, timeout time.Duration, req *request) ([]byte, int) {
+	var body io.Reader
+	if req != nil {
+		body = req.body
+	}
+	httpReq, err := http.NewRequest(method, c.base+"/"+cmd, body)
+	if err != nil {
+		fmt.Fprintf(c.stderr, "moatctl: %s failed: %v\n", cmd, err)
+		return nil, exitError
+	}
+	httpReq.Header.Set("Authorization", "Bearer "+c.token)
+	if req != nil {
+		httpReq.Header.Set("Content-Type", req.contentType)
+		if req.clipName != "" {
+			httpReq.Header.Set("X-Moat-Clip-Name", req.clipName)
+		}
+	}
+	// MOAT_CTL_URL is the proxy itself, so proxy settings must not apply.
+	hc := &http.Client{Timeout: timeout, Transport: &http.Transport{Proxy: nil}}
+	resp, err := hc.Do(httpReq)
+	if err != nil {
+		fmt.Fprintf(c.stderr, "moatctl: %s failed:\n%v\n", cmd, err)
+		return nil, exitError
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		fmt.Fprintf(c.stderr, "moatctl: %s failed: %v\n", cmd, err)
+		return nil, exitError
+	}
+	if resp.StatusCode >= 400 {
+		fmt.Fprintf(c.stderr, "moatctl: %s failed (HTTP %d):\n%s", cmd, resp.StatusCode, data)
+		return nil, exitError
+	}
+	return data, exitOK
+}
+
+// print writes a successful response body to stdout and passes code through.
+func (c *client) print(body []byte, code int) int {
+	if code == exitOK {
+		c.stdout.Write(body)
+	}
+	return code
+}
+
+func (c *client) clip(args []string, stdin io.Reader) int {
+	req := &request{contentType: "application/octet-stream"}
+	if len(args) == 1 {
+		info, err := os.Stat(args[0])
+		if err != nil || !info.Mode().IsRegular() {
+			fmt.Fprintf(c.stderr, "moatctl: %s: not a file\n", args[0])
+			return exitError
+		}
+		f, err := os.Open(args[0])
+		if err != nil {
+			fmt.Fprintf(c.stderr, "moatctl: %v\n", err)
+			return exitError
+		}
+		defer f.Close()
+		req.body = f
+		req.clipName = filepath.Base(args[0])
+	} else {
+		req.body = stdin
+	}
+	code := c.print(c.call(http.MethodPost, "clip", 30*time.Second, req))
+	if code == exitOK {
+		fmt.Fprintln(c.stderr, "moatctl: offered to the host; the user accepts it with 'moat clip'")
+	}
+	return code
+}
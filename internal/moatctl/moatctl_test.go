@@ -0,0 +1,139 @@
+package moatctl
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// serve points moatctl at a test endpoint that records each request and
+// answers with respond.
+func serve(t *testing.T, respond func(w http.ResponseWriter, r *http.Request)) *[]*http.Request {
+	t.Helper()
+	var got []*http.Request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		got = append(got, r)
+		if r.Header.Get("Authorization") != "Bearer tok" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		respond(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	t.Setenv("MOAT_CTL_URL", srv.URL+"/_aws/credentials/moatctl")
+	t.Setenv("MOAT_CTL_TOKEN", "tok")
+	return &got
+}
+
+func run(args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	code := Main(args, strings.NewReader("from stdin"), &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestMoatctl_Progress(t *testing.T) {
+	got := serve(t, func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) })
+	if code, _, stderr := run("progress", "tests", "passing", "--percent", "80"); code != 0 {
+		t.Fatalf("exit = %d: %s", code, stderr)
+	}
+	r := (*got)[0]
+	if r.Method != http.MethodPost || r.URL.Path != "/_aws/credentials/moatctl/progress" {
+		t.Errorf("request = %s %s", r.Method, r.URL.Path)
+	}
+	if err := r.ParseForm(); err != nil {
+		t.Fatal(err)
+	}
+	if r.PostForm.Get("message") != "tests passing" || r.PostForm.Get("percent") != "80" {
+		t.Errorf("form = %v", r.PostForm)
+	}
+}
+
+func TestMoatctl_Approve(t *testing.T) {
+	status := "approved"
+	serve(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"host":"pypi.org","status":"`+status+`"}`)
+	})
+	if code, stdout, _ := run("approve", "pypi.org", "--reason", "install deps"); code != 0 || !strings.Contains(stdout, "approved") {
+		t.Errorf("approved: exit = %d, stdout = %q", code, stdout)
+	}
+	status = "denied"
+	if code, _, _ := run("approve", "pypi.org"); code != exitDeclined {
+		t.Errorf("denied: exit = %d, want %d", code, exitDeclined)
+	}
+}
+
+func TestMoatctl_Clip(t *testing.T) {
+	got := serve(t, func(w http.ResponseWriter, r *http.Request) { io.WriteString(w, `{"id":"clp_1"}`) })
+	path := filepath.Join(t.TempDir(), "notes.txt")
+	if err := os.WriteFile(path, []byte("hello"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if code, _, stderr := run("clip", path); code != 0 {
+		t.Fatalf("clip FILE: exit = %d: %s", code, stderr)
+	}
+	if code, _, stderr := run("clip"); code != 0 {
+		t.Fatalf("clip: exit = %d: %s", code, stderr)
+	}
+	file, stdin := (*got)[0], (*got)[1]
+	if file.Header.Get("X-Moat-Clip-Name") != "notes.txt" || file.Header.Get("Content-Type") != "application/octet-stream" {
+		t.Errorf("clip FILE headers = %v", file.Header)
+	}
+	if body, _ := io.ReadAll(stdin.Body); string(body) != "from stdin" || stdin.Header.Get("X-Moat-Clip-Name") != "" {
+		t.Errorf("clip from stdin: body = %q, headers = %v", body, stdin.Header)
+	}
+	if code, _, _ := run("clip", t.TempDir()); code != exitError {
+		t.Errorf("clip DIR: exit = %d, want %d", code, exitError)
+	}
+}
+
+func TestMoatctl_Paste(t *testing.T) {
+	serve(t, func(w http.ResponseWriter, r *http.Request) { io.WriteString(w, "pasted") })
+	if code, stdout, _ := run("paste"); code != 0 || stdout != "pasted" {
+		t.Errorf("paste: exit = %d, stdout = %q", code, stdout)
+	}
+	out := filepath.Join(t.TempDir(), "out")
+	if code, _, stderr := run("paste", "-o", out); code != 0 {
+		t.Fatalf("paste -o: exit = %d: %s", code, stderr)
+	}
+	if data, _ := os.ReadFile(out); string(data) != "pasted" {
+		t.Errorf("paste -o wrote %q", data)
+	}
+}
+
+func TestMoatctl_Errors(t *testing.T) {
+	serve(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "too many snapshots", http.StatusTooManyRequests)
+	})
+	code, _, stderr := run("snapshot", "--label", "x")
+	if code != exitError || !strings.Contains(stderr, "HTTP 429") || !strings.Contains(stderr, "too many snapshots") {
+		t.Errorf("HTTP error: exit = %d, stderr = %q", code, stderr)
+	}
+
+	for _, args := range [][]string{
+		nil,
+		{"bogus"},
+		{"progress"},
+		{"approve"},
+		{"approve", "a.com", "b.com"},
+		{"approve", "a.com", "--wait", "soon"},
+		{"snapshot", "--label"},
+		{"paste", "--force"},
+	} {
+		if code, _, _ := run(args...); code != exitUsage {
+			t.Errorf("moatctl %v: exit = %d, want %d", args, code, exitUsage)
+		}
+	}
+
+	t.Setenv("MOAT_CTL_URL", "")
+	if code, _, stderr := run("budget"); code != exitError || !strings.Contains(stderr, "MOAT_CTL_URL") {
+		t.Errorf("without MOAT_CTL_URL: exit = %d, stderr = %q", code, stderr)
+	}
+}
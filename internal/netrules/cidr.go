@@ -0,0 +1,62 @@
+package netrules
+
+import (
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// MaxCIDRAddresses is the largest CIDR range a network.rules host may
+// cover. It bounds the addresses one rule can admit HTTPS tunnels to.
+const MaxCIDRAddresses = 4096
+
+// A CIDRHost is a parsed CIDR network.rules host.
+type CIDRHost struct {
+	Prefix netip.Prefix
+	Port   int // 0 means the default HTTP and HTTPS ports
+}
+
+// IsCIDRHost reports whether a network.rules host is written as a CIDR
+// range, such as "10.0.0.0/24" or "10.0.0.0/24:8080".
+func IsCIDRHost(host string) bool {
+	return strings.Contains(host, "/")
+}
+
+// ParseCIDRHost parses a CIDR network.rules host into its range and port.
+// Only IPv4 ranges of at most MaxCIDRAddresses addresses are supported.
+func ParseCIDRHost(host string) (CIDRHost, error) {
+	cidr, port := host, 0
+	if i := strings.LastIndex(host, ":"); i > strings.Index(host, "/") {
+		cidr = host[:i]
+		p, err := strconv.Atoi(host[i+1:])
+		if err != nil || p < 1 || p > 65535 {
+			return CIDRHost{}, fmt.Errorf("invalid port in %q", host)
+		}
+		port = p
+	}
+	prefix, err := netip.ParsePrefix(cidr)
+	if err != nil {
+		return CIDRHost{}, fmt.Errorf("invalid CIDR range %q", host)
+	}
+	if !prefix.Addr().Is4() {
+		return CIDRHost{}, fmt.Errorf("CIDR range %q: only IPv4 ranges are supported", host)
+	}
+	if size := 1 << (32 - prefix.Bits()); size > MaxCIDRAddresses {
+		return CIDRHost{}, fmt.Errorf("CIDR range %q covers %d addresses; the limit is %d (/20)", host, size, MaxCIDRAddresses)
+	}
+	return CIDRHost{Prefix: prefix.Masked(), Port: port}, nil
+}
+
+// Match reports whether host:port falls within c. Hostnames never match;
+// only literal IP addresses do.
+func (c CIDRHost) Match(host string, port int) bool {
+	if c.Port != 0 && port != c.Port || c.Port == 0 && port != 80 && port != 443 {
+		return false
+	}
+	addr, err := netip.ParseAddr(strings.Trim(host, "[]"))
+	if err != nil {
+		return false
+	}
+	return c.Prefix.Contains(addr.Unmap())
+}
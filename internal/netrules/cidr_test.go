@@ -0,0 +1,61 @@
+package netrules
+
+import (
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestCIDRHostMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		host    string
+		port    int
+		want    bool
+	}{
+		{"10.0.0.0/24", "10.0.0.17", 443, true},
+		{"10.0.0.0/24", "10.0.0.17", 80, true},
+		{"10.0.0.0/24", "10.0.0.17", 8080, false},
+		{"10.0.0.0/24", "10.0.1.17", 443, false},
+		{"10.0.0.0/24", "example.com", 443, false},
+		{"10.0.0.0/24:8080", "10.0.0.17", 8080, true},
+		{"10.0.0.0/24:8080", "10.0.0.17", 443, false},
+		{"10.0.0.5/24", "10.0.0.200", 443, true}, // host bits are masked
+		{"192.168.1.1/32", "::ffff:192.168.1.1", 443, true},
+	}
+	for _, tt := range tests {
+		c, err := ParseCIDRHost(tt.pattern)
+		if err != nil {
+			t.Fatalf("ParseCIDRHost(%q): %v", tt.pattern, err)
+		}
+		if got := c.Match(tt.host, tt.port); got != tt.want {
+			t.Errorf("ParseCIDRHost(%q).Match(%q, %d) = %v, want %v", tt.pattern, tt.host, tt.port, got, tt.want)
+		}
+	}
+}
+
+func TestParseCIDRHostErrors(t *testing.T) {
+	tests := map[string]string{
+		"10.0.0.0/8":       "limit is 4096",
+		"fd00::/120":       "only IPv4",
+		"10.0.0.0/24:0":    "invalid port",
+		"10.0.0.0/33":      "invalid CIDR",
+		"example.com/24":   "invalid CIDR",
+		"10.0.0.0/24:http": "invalid port",
+	}
+	for host, want := range tests {
+		if _, err := ParseCIDRHost(host); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("ParseCIDRHost(%q) error = %v, want containing %q", host, err, want)
+		}
+	}
+}
+
+func TestNetworkRuleEntryRejectsLargeCIDR(t *testing.T) {
+	for _, doc := range []string{`"10.0.0.0/8"`, `{"10.0.0.0/8": ["allow GET /*"]}`} {
+		var e NetworkRuleEntry
+		if err := yaml.Unmarshal([]byte(doc), &e); err == nil {
+			t.Errorf("Unmarshal(%s) succeeded, want CIDR size error", doc)
+		}
+	}
+}
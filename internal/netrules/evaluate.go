@@ -10,17 +10,25 @@ type HostMatcher func(pattern, host string, port int) bool
 // Returns "allow", "deny", or "" (no rule matched — fall through to policy default).
 // First matching rule wins.
 func EvaluateRules(rules []Rule, method, path string) string {
+	if rule := MatchRule(rules, method, path); rule != nil {
+		return rule.Action
+	}
+	return ""
+}
+
+// MatchRule returns the first rule in rules that matches method and path,
+// or nil if none does. Like EvaluateRules, the query string is ignored.
+func MatchRule(rules []Rule, method, path string) *Rule {
 	// Strip query string if present
 	if idx := strings.IndexByte(path, '?'); idx != -1 {
 		path = path[:idx]
 	}
-
-	for _, rule := range rules {
-		if matchesRule(rule, method, path) {
-			return rule.Action
+	for i := range rules {
+		if matchesRule(rules[i], method, path) {
+			return &rules[i]
 		}
 	}
-	return ""
+	return nil
 }
 
 // matchesRule checks if a single rule matches the given method and path.
@@ -67,3 +75,17 @@ func Check(policy string, hostRules []HostRules, host string, port int, method,
 	// No host entry matched — fall through to policy default
 	return policy != "strict"
 }
+
+// Explain reports which entry in hostRules Check would consult for a request:
+// the matching host entry (nil if none) and the path rule that decided it
+// (nil for host-level entries, or when the request fell through to the
+// policy default).
+func Explain(hostRules []HostRules, host string, port int, method, path string, hostMatches HostMatcher) (*HostRules, *Rule) {
+	for i := range hostRules {
+		if !hostMatches(hostRules[i].Host, host, port) {
+			continue
+		}
+		return &hostRules[i], MatchRule(hostRules[i].Rules, method, path)
+	}
+	return nil, nil
+}
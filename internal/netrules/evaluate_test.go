@@ -156,3 +156,45 @@ func TestCheckPermissive(t *testing.T) {
 		})
 	}
 }
+
+func TestExplain(t *testing.T) {
+	hostRules := []HostRules{
+		{Host: "api.github.com", Rules: []Rule{
+			{Action: "allow", Method: "GET", PathPattern: "/repos/*"},
+		}},
+		{Host: "pypi.org"},
+	}
+
+	tests := []struct {
+		name     string
+		host     string
+		path     string
+		wantHost string
+		wantRule string
+	}{
+		{"path rule", "api.github.com", "/repos/foo?page=2", "api.github.com", "/repos/*"},
+		{"host entry, no rule match", "api.github.com", "/user", "api.github.com", ""},
+		{"host-level entry", "pypi.org", "/simple/", "pypi.org", ""},
+		{"no entry", "other.com", "/", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry, rule := Explain(hostRules, tt.host, 443, "GET", tt.path, exactHostMatch)
+			var host string
+			if entry != nil {
+				host = entry.Host
+			}
+			if host != tt.wantHost {
+				t.Errorf("host = %q, want %q", host, tt.wantHost)
+			}
+			var gotRule string
+			if rule != nil {
+				gotRule = rule.PathPattern
+			}
+			if gotRule != tt.wantRule {
+				t.Errorf("rule = %q, want %q", gotRule, tt.wantRule)
+			}
+		})
+	}
+}
@@ -20,7 +20,7 @@ func (e *NetworkRuleEntry) UnmarshalYAML(value *yaml.Node) error {
 			return fmt.Errorf("network.rules entry: host cannot be empty")
 		}
 		e.Host = value.Value
-		return nil
+		return validateHost(e.Host)
 
 	case yaml.MappingNode:
 		if len(value.Content) != 2 {
@@ -30,6 +30,9 @@ func (e *NetworkRuleEntry) UnmarshalYAML(value *yaml.Node) error {
 		if e.Host == "" {
 			return fmt.Errorf("network.rules entry: host cannot be empty")
 		}
+		if err := validateHost(e.Host); err != nil {
+			return err
+		}
 
 		var ruleStrings []string
 		if err := value.Content[1].Decode(&ruleStrings); err != nil {
@@ -48,3 +51,14 @@ func (e *NetworkRuleEntry) UnmarshalYAML(value *yaml.Node) error {
 		return fmt.Errorf("network.rules entry must be a string or map, got %v", value.Kind)
 	}
 }
+
+// validateHost rejects CIDR hosts the proxy cannot enforce.
+func validateHost(host string) error {
+	if !IsCIDRHost(host) {
+		return nil
+	}
+	if _, err := ParseCIDRHost(host); err != nil {
+		return fmt.Errorf("network.rules entry: %w", err)
+	}
+	return nil
+}
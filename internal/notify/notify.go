@@ -0,0 +1,127 @@
+// Package notify shows desktop notifications on the host. It shells out to
+// tools that ship with each OS (osascript, notify-send or gdbus, and
+// PowerShell), passing text as arguments or encoded scripts, never through a
+// shell.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"unicode/utf16"
+)
+
+// ErrUnavailable is returned when the host has no way to show a
+// notification.
+var ErrUnavailable = errors.New("no desktop notifier available")
+
+// Notification is a desktop notification.
+type Notification struct {
+	Title string
+	Body  string
+}
+
+// Send shows n as a desktop notification. Callers should log an error and
+// carry on: a notification is never worth failing for.
+func Send(ctx context.Context, n Notification) error {
+	name, args, err := command(runtime.GOOS, n)
+	if err != nil {
+		return err
+	}
+	return runCommand(ctx, name, args...)
+}
+
+// command returns the command that shows n on goos.
+func command(goos string, n Notification) (string, []string, error) {
+	switch goos {
+	case "darwin":
+		// The text is passed as script arguments, so it needs no escaping.
+		return "osascript", []string{
+			"-e", "on run argv",
+			"-e", "display notification (item 2 of argv) with title (item 1 of argv)",
+			"-e", "end run",
+			n.Title, n.Body,
+		}, nil
+	case "linux", "freebsd", "openbsd", "netbsd":
+		if os.Getenv("DBUS_SESSION_BUS_ADDRESS") == "" {
+			return "", nil, fmt.Errorf("%w: no D-Bus session bus", ErrUnavailable)
+		}
+		if _, err := lookPath("notify-send"); err == nil {
+			return "notify-send", []string{"--app-name=moat", "--", n.Title, n.Body}, nil
+		}
+		if _, err := lookPath("gdbus"); err == nil {
+			return "gdbus", []string{
+				"call", "--session",
+				"--dest", "org.freedesktop.Notifications",
+				"--object-path", "/org/freedesktop/Notifications",
+				"--method", "org.freedesktop.Notifications.Notify",
+				"moat", "0", "''", gvariantString(n.Title), gvariantString(n.Body), "[]", "{}", "-1",
+			}, nil
+		}
+		return "", nil, fmt.Errorf("%w: install notify-send (libnotify)", ErrUnavailable)
+	case "windows":
+		return "powershell", []string{
+			"-NoProfile", "-NonInteractive", "-WindowStyle", "Hidden",
+			"-EncodedCommand", encodePowerShell(toastScript(n)),
+		}, nil
+	}
+	return "", nil, fmt.Errorf("%w on %s", ErrUnavailable, goos)
+}
+
+// gvariantString quotes s as a GVariant text-format string literal.
+func gvariantString(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+}
+
+// toastScript returns a PowerShell script that shows n as a toast. Text
+// nodes are created through the XML DOM, so only PowerShell quoting is
+// needed.
+func toastScript(n Notification) string {
+	quote := func(s string) string { return "'" + strings.ReplaceAll(s, "'", "''") + "'" }
+	return strings.Join([]string{
+		"[Windows.UI.Notifications.ToastNotificationManager, Windows.UI.Notifications, ContentType = WindowsRuntime] > $null",
+		"$t = [Windows.UI.Notifications.ToastNotificationManager]::GetTemplateContent([Windows.UI.Notifications.ToastTemplateType]::ToastText02)",
+		"$x = $t.GetElementsByTagName('text')",
+		"$x.Item(0).AppendChild($t.CreateTextNode(" + quote(n.Title) + ")) > $null",
+		"$x.Item(1).AppendChild($t.CreateTextNode(" + quote(n.Body) + ")) > $null",
+		"[Windows.UI.Notifications.ToastNotificationManager]::CreateToastNotifier('moat').Show([Windows.UI.Notifications.ToastNotification]::new($t))",
+	}, "\n")
+}
+
+// encodePowerShell encodes script for -EncodedCommand: base64 of UTF-16LE.
+func encodePowerShell(script string) string {
+	units := utf16.Encode([]rune(script))
+	buf := make([]byte, 0, 2*len(units))
+	for _, u := range units {
+		buf = append(buf, byte(u), byte(u>>8))
+	}
+	return base64.StdEncoding.EncodeToString(buf)
+}
+
+// lookPath and runCommand are variables so tests can stub out the notifier
+// tools.
+var (
+	lookPath = exec.LookPath
+
+	runCommand = func(ctx context.Context, name string, args ...string) error {
+		if _, err := lookPath(name); err != nil {
+			return fmt.Errorf("%w: %s not found in PATH", ErrUnavailable, name)
+		}
+		cmd := exec.CommandContext(ctx, name, args...)
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err != nil {
+			if msg := strings.TrimSpace(stderr.String()); msg != "" {
+				return fmt.Errorf("%s: %s", name, msg)
+			}
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		return nil
+	}
+)
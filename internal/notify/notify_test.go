@@ -0,0 +1,120 @@
+package notify
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"unicode/utf16"
+)
+
+var testNotification = Notification{
+	Title: "moat: my-agent is waiting for approval",
+	Body:  `Allow it's "evil".com\? Run: moat approvals respond apr_1`,
+}
+
+func TestCommand_Darwin(t *testing.T) {
+	name, args, err := command("darwin", testNotification)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "osascript" {
+		t.Errorf("name = %s", name)
+	}
+	// The text is passed verbatim as the last two arguments.
+	if got := args[len(args)-2:]; got[0] != testNotification.Title || got[1] != testNotification.Body {
+		t.Errorf("args = %q", args)
+	}
+}
+
+func TestCommand_Linux(t *testing.T) {
+	t.Setenv("DBUS_SESSION_BUS_ADDRESS", "unix:path=/run/user/1000/bus")
+	available := map[string]bool{"notify-send": true, "gdbus": true}
+	origLookPath := lookPath
+	t.Cleanup(func() { lookPath = origLookPath })
+	lookPath = func(name string) (string, error) {
+		if available[name] {
+			return "/usr/bin/" + name, nil
+		}
+		return "", errors.New("not found")
+	}
+
+	name, args, err := command("linux", testNotification)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "notify-send" || !slices.Equal(args[len(args)-3:], []string{"--", testNotification.Title, testNotification.Body}) {
+		t.Errorf("command = %s %q", name, args)
+	}
+
+	available["notify-send"] = false
+	name, args, err = command("linux", testNotification)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `'Allow it\'s "evil".com\\? Run: moat approvals respond apr_1'`; name != "gdbus" || !slices.Contains(args, want) {
+		t.Errorf("command = %s %q, want body %s", name, args, want)
+	}
+
+	available["gdbus"] = false
+	if _, _, err := command("linux", testNotification); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("without a notifier: err = %v, want ErrUnavailable", err)
+	}
+
+	t.Setenv("DBUS_SESSION_BUS_ADDRESS", "")
+	available["notify-send"] = true
+	if _, _, err := command("linux", testNotification); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("without a session bus: err = %v, want ErrUnavailable", err)
+	}
+}
+
+func TestCommand_Windows(t *testing.T) {
+	name, args, err := command("windows", testNotification)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "powershell" || args[len(args)-2] != "-EncodedCommand" {
+		t.Fatalf("command = %s %q", name, args)
+	}
+	raw, err := base64.StdEncoding.DecodeString(args[len(args)-1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	units := make([]uint16, len(raw)/2)
+	for i := range units {
+		units[i] = uint16(raw[2*i]) | uint16(raw[2*i+1])<<8
+	}
+	script := string(utf16.Decode(units))
+	if !strings.Contains(script, `CreateTextNode('Allow it''s "evil".com\? Run: moat approvals respond apr_1')`) {
+		t.Errorf("script does not quote the body:\n%s", script)
+	}
+}
+
+func TestCommand_Unsupported(t *testing.T) {
+	if _, _, err := command("plan9", testNotification); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("err = %v, want ErrUnavailable", err)
+	}
+}
+
+func TestSend(t *testing.T) {
+	var ran string
+	origRunCommand := runCommand
+	t.Cleanup(func() { runCommand = origRunCommand })
+	runCommand = func(_ context.Context, name string, _ ...string) error {
+		ran = name
+		return nil
+	}
+
+	err := Send(context.Background(), testNotification)
+	if errors.Is(err, ErrUnavailable) {
+		t.Skipf("no notifier on this host: %v", err)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ran == "" {
+		t.Error("Send() ran no command")
+	}
+}
@@ -3,6 +3,8 @@ package provider
 import (
 	"errors"
 	"fmt"
+
+	"github.com/majorcontext/moat/internal/errcode"
 )
 
 var (
@@ -16,8 +18,20 @@ var (
 	ErrRefreshNotSupported = errors.New("credential refresh not supported")
 	// ErrTokenRevoked is returned when a refresh token has been revoked.
 	ErrTokenRevoked = errors.New("refresh token revoked")
+	// ErrCredentialRejected is returned by CredentialChecker when the upstream
+	// service refuses a credential (expired, revoked, or invalid).
+	ErrCredentialRejected = errors.New("credential rejected")
 )
 
+func init() {
+	errcode.Register(ErrProviderNotFound, errcode.ProviderNotFound)
+	errcode.Register(ErrCredentialNotFound, errcode.CredentialNotFound)
+	errcode.Register(ErrCredentialExpired, errcode.CredentialExpired)
+	errcode.Register(ErrRefreshNotSupported, errcode.RefreshNotSupported)
+	errcode.Register(ErrTokenRevoked, errcode.TokenRevoked)
+	errcode.Register(ErrCredentialRejected, errcode.CredentialRejected)
+}
+
 // GrantError wraps provider-specific grant failures with actionable guidance.
 type GrantError struct {
 	Provider string
@@ -35,3 +49,12 @@ func (e *GrantError) Error() string {
 func (e *GrantError) Unwrap() error {
 	return e.Cause
 }
+
+// ErrorCode reports a failed grant. Causes with a more specific code, such
+// as missing input, keep theirs.
+func (e *GrantError) ErrorCode() errcode.Code {
+	if code := errcode.Of(e.Cause); code != "" {
+		return code
+	}
+	return errcode.GrantFailed
+}
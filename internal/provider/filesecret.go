@@ -0,0 +1,105 @@
+package provider
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// FileSecret declares a credential embedded in a file the container reads,
+// such as a CLI's credentials file. The file holds Placeholder where the
+// credential belongs; the proxy swaps in the value Ref names on requests to
+// each of Hosts, in URL paths, Authorization headers, and request bodies,
+// so the real value never enters the container.
+type FileSecret struct {
+	// Path is the file's path under the container home, with or without
+	// a leading "~/".
+	Path string
+	// Content is the file's contents, holding Placeholder.
+	Content string
+	// Placeholder stands in for the credential in Content and on the wire.
+	Placeholder string
+	// Ref names the credential value: "token" (the default) or
+	// "metadata.<key>".
+	Ref string
+	// Hosts are the hosts whose requests get the real value.
+	Hosts []string
+}
+
+// Value resolves Ref against cred. It returns "" when the value is unset.
+func (s FileSecret) Value(cred *Credential) string {
+	if cred == nil {
+		return ""
+	}
+	if key, ok := strings.CutPrefix(s.Ref, "metadata."); ok {
+		return cred.Metadata[key]
+	}
+	return cred.Token
+}
+
+// ValidateFileSecrets checks a set of file secrets for a single credential.
+// The proxy keeps one substitution per host, so two secrets may share a
+// host only when they share a placeholder.
+func ValidateFileSecrets(secrets []FileSecret) error {
+	placeholders := make(map[string]string)
+	for _, s := range secrets {
+		if s.Path == "" {
+			return fmt.Errorf("file secret: path is required")
+		}
+		if p := path.Clean(strings.TrimPrefix(s.Path, "~/")); path.IsAbs(p) || p == "." || p == ".." || strings.HasPrefix(p, "../") {
+			return fmt.Errorf("file secret %s: path must be under the home directory", s.Path)
+		}
+		if s.Placeholder == "" {
+			return fmt.Errorf("file secret %s: placeholder is required", s.Path)
+		}
+		if s.Content != "" && !strings.Contains(s.Content, s.Placeholder) {
+			return fmt.Errorf("file secret %s: content does not contain the placeholder", s.Path)
+		}
+		if s.Ref != "" && s.Ref != "token" && !strings.HasPrefix(s.Ref, "metadata.") {
+			return fmt.Errorf("file secret %s: ref %q must be \"token\" or \"metadata.<key>\"", s.Path, s.Ref)
+		}
+		if len(s.Hosts) == 0 {
+			return fmt.Errorf("file secret %s: at least one host is required", s.Path)
+		}
+		for _, host := range s.Hosts {
+			if prev, ok := placeholders[host]; ok && prev != s.Placeholder {
+				return fmt.Errorf("file secret %s: host %s already substitutes a different placeholder", s.Path, host)
+			}
+			placeholders[host] = s.Placeholder
+		}
+	}
+	return nil
+}
+
+// ConfigureFileSecrets sets up the proxy substitutions for secrets resolved
+// against cred. Providers call it from ConfigureProxy, and again from
+// Refresh with the refreshed credential. Secrets whose value is unset are
+// skipped.
+func ConfigureFileSecrets(p ProxyConfigurer, cred *Credential, secrets []FileSecret) {
+	for _, s := range secrets {
+		value := s.Value(cred)
+		if value == "" {
+			continue
+		}
+		for _, host := range s.Hosts {
+			p.SetTokenSubstitution(host, s.Placeholder, value)
+		}
+	}
+}
+
+// FileSecretInitFiles returns the files for secrets as container paths to
+// contents, for InitFileProvider.ContainerInitFiles. Secrets without content
+// are skipped; their provider writes the file some other way.
+func FileSecretInitFiles(secrets []FileSecret, containerHome string) map[string]string {
+	files := make(map[string]string)
+	for _, s := range secrets {
+		if s.Content == "" {
+			continue
+		}
+		files[path.Join(containerHome, strings.TrimPrefix(s.Path, "~/"))] = s.Content
+	}
+	if len(files) == 0 {
+		return nil
+	}
+	return files
+}
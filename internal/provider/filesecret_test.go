@@ -0,0 +1,106 @@
+package provider
+
+import (
+	"strings"
+	"testing"
+)
+
+// substitutions records the token substitutions a provider configures.
+type substitutions map[string][2]string
+
+func (s substitutions) SetCredential(host, value string)                                   {}
+func (s substitutions) SetCredentialHeader(host, headerName, headerValue string)           {}
+func (s substitutions) SetCredentialWithGrant(host, headerName, headerValue, grant string) {}
+func (s substitutions) AddExtraHeader(host, headerName, headerValue string)                {}
+func (s substitutions) AddResponseTransformer(host string, transformer ResponseTransformer) {
+}
+func (s substitutions) RemoveRequestHeader(host, headerName string) {}
+func (s substitutions) SetTokenSubstitution(host, placeholder, realToken string) {
+	s[host] = [2]string{placeholder, realToken}
+}
+
+func TestConfigureFileSecrets_MultiHost(t *testing.T) {
+	cred := &Credential{Token: "real-token", Metadata: map[string]string{"refresh_token": "real-refresh"}}
+	secrets := []FileSecret{
+		{Path: ".tool/auth.json", Placeholder: "placeholder-access", Hosts: []string{"api.example.com", "auth.example.com"}},
+		{Path: ".tool/refresh", Placeholder: "placeholder-refresh", Ref: "metadata.refresh_token", Hosts: []string{"oauth.example.com"}},
+		{Path: ".tool/unset", Placeholder: "placeholder-unset", Ref: "metadata.missing", Hosts: []string{"other.example.com"}},
+	}
+	if err := ValidateFileSecrets(secrets); err != nil {
+		t.Fatalf("ValidateFileSecrets() = %v", err)
+	}
+
+	subs := substitutions{}
+	ConfigureFileSecrets(subs, cred, secrets)
+
+	want := substitutions{
+		"api.example.com":   {"placeholder-access", "real-token"},
+		"auth.example.com":  {"placeholder-access", "real-token"},
+		"oauth.example.com": {"placeholder-refresh", "real-refresh"},
+	}
+	if len(subs) != len(want) {
+		t.Fatalf("substitutions = %v, want %v", subs, want)
+	}
+	for host, w := range want {
+		if subs[host] != w {
+			t.Errorf("substitution for %s = %v, want %v", host, subs[host], w)
+		}
+	}
+}
+
+func TestValidateFileSecrets(t *testing.T) {
+	valid := FileSecret{Path: "~/.tool/auth", Content: `{"t":"ph"}`, Placeholder: "ph", Hosts: []string{"a.example.com"}}
+	tests := []struct {
+		name    string
+		secrets []FileSecret
+		wantErr string
+	}{
+		{"valid", []FileSecret{valid}, ""},
+		{"shared placeholder on a host", []FileSecret{valid, {Path: ".tool/other", Placeholder: "ph", Hosts: []string{"a.example.com"}}}, ""},
+		{"missing path", []FileSecret{{Placeholder: "ph", Hosts: []string{"a"}}}, "path is required"},
+		{"absolute path", []FileSecret{{Path: "/etc/auth", Placeholder: "ph", Hosts: []string{"a"}}}, "under the home directory"},
+		{"escaping path", []FileSecret{{Path: "~/../auth", Placeholder: "ph", Hosts: []string{"a"}}}, "under the home directory"},
+		{"missing placeholder", []FileSecret{{Path: "auth", Hosts: []string{"a"}}}, "placeholder is required"},
+		{"content without placeholder", []FileSecret{{Path: "auth", Content: "{}", Placeholder: "ph", Hosts: []string{"a"}}}, "does not contain"},
+		{"bad ref", []FileSecret{{Path: "auth", Placeholder: "ph", Ref: "env.X", Hosts: []string{"a"}}}, "ref"},
+		{"no hosts", []FileSecret{{Path: "auth", Placeholder: "ph"}}, "at least one host"},
+		{"conflicting placeholders on a host", []FileSecret{valid, {Path: "other", Placeholder: "ph2", Hosts: []string{"a.example.com"}}}, "different placeholder"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateFileSecrets(tt.secrets)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("ValidateFileSecrets() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ValidateFileSecrets() = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFileSecretInitFiles(t *testing.T) {
+	files := FileSecretInitFiles([]FileSecret{
+		{Path: "~/.tool/auth.json", Content: "a"},
+		{Path: ".config/tool", Content: "b"},
+		{Path: ".written/elsewhere"},
+	}, "/home/moatuser")
+	want := map[string]string{
+		"/home/moatuser/.tool/auth.json": "a",
+		"/home/moatuser/.config/tool":    "b",
+	}
+	if len(files) != len(want) {
+		t.Fatalf("files = %v, want %v", files, want)
+	}
+	for p, content := range want {
+		if files[p] != content {
+			t.Errorf("files[%s] = %q, want %q", p, files[p], content)
+		}
+	}
+	if got := FileSecretInitFiles([]FileSecret{{Path: "x"}}, "/home/moatuser"); got != nil {
+		t.Errorf("FileSecretInitFiles() without content = %v, want nil", got)
+	}
+}
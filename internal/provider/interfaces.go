@@ -9,12 +9,22 @@ import (
 	"github.com/spf13/cobra"
 )
 
-// RunStoppedContext provides run information to shutdown hooks.
-type RunStoppedContext struct {
+// RunContext provides run information to lifecycle hooks.
+type RunContext struct {
+	RunID     string
+	Name      string
 	Workspace string
-	StartedAt time.Time
+	StartedAt time.Time // zero before the run has started
 }
 
+// RunStoppedContext provides run information to shutdown hooks.
+type RunStoppedContext = RunContext
+
+// Lifecycle hooks are optional interfaces for providers that react to run
+// events. The manager calls them for each grant provider that implements
+// them, once per provider. Hooks that return metadata have it merged into
+// the run's metadata.json under "provider_meta"; later values win.
+
 // RunStoppedHook is an optional interface for providers that need to perform
 // actions after a run stops. The manager calls OnRunStopped for each grant
 // provider that implements this interface.
@@ -25,6 +35,71 @@ type RunStoppedHook interface {
 	OnRunStopped(ctx RunStoppedContext) map[string]string
 }
 
+// RunStartingHook is an optional interface for providers that need to act
+// before a run's container starts.
+type RunStartingHook interface {
+	// OnRunStarting is called after the container is created, just before
+	// it starts. It returns metadata key-value pairs to persist.
+	OnRunStarting(ctx RunContext) map[string]string
+}
+
+// NetworkBlockedEvent describes a request the proxy denied.
+type NetworkBlockedEvent struct {
+	Time   time.Time
+	Method string
+	Host   string
+	Path   string
+	Reason string // deny reason, e.g. "policy"
+	Rule   string // matching network.rules entry, if any
+}
+
+// NetworkBlockedHook is an optional interface for providers that react to
+// requests the proxy denies while a run is active.
+type NetworkBlockedHook interface {
+	// OnNetworkBlocked is called for each denied request. It runs on the
+	// goroutine that follows the proxy's request stream and should return
+	// quickly. It returns metadata key-value pairs to persist.
+	OnNetworkBlocked(ctx RunContext, ev NetworkBlockedEvent) map[string]string
+}
+
+// BudgetExceededEvent describes an LLM API response reporting exhausted
+// credits or quota. Host and StatusCode are zero when the budget error was
+// found by classifying the run's exit rather than seen live.
+type BudgetExceededEvent struct {
+	Time       time.Time
+	Host       string
+	StatusCode int
+}
+
+// BudgetExceededHook is an optional interface for providers that react to a
+// run exhausting its LLM credits or quota.
+type BudgetExceededHook interface {
+	// OnBudgetExceeded is called at most once per run: when the proxy sees
+	// an HTTP 402 from an LLM API, or when the run exits with failure class
+	// budget_exceeded, whichever comes first. It returns metadata key-value
+	// pairs to persist.
+	OnBudgetExceeded(ctx RunContext, ev BudgetExceededEvent) map[string]string
+}
+
+// SnapshotEvent describes a workspace snapshot.
+type SnapshotEvent struct {
+	ID    string
+	Type  string // "pre-run", "git", "post-run", ...
+	Label string
+	Time  time.Time
+}
+
+// SnapshotCreatedHook is an optional interface for providers that react to
+// workspace snapshots. Snapshots follow each git commit during a run, which
+// makes this a natural point to flush state that would otherwise only be
+// recorded when the run stops.
+type SnapshotCreatedHook interface {
+	// OnSnapshotCreated is called after a snapshot of the run's workspace is
+	// saved. It returns metadata key-value pairs to persist; the run's
+	// metadata is saved right away when the run is still active.
+	OnSnapshotCreated(ctx RunContext, ev SnapshotEvent) map[string]string
+}
+
 // ProxyConfigurer configures proxy credentials and response transformations.
 // This is an alias for credential.ProxyConfigurer to ensure type compatibility.
 type ProxyConfigurer = credential.ProxyConfigurer
@@ -90,6 +165,16 @@ type RefreshableProvider interface {
 	Refresh(ctx context.Context, p ProxyConfigurer, cred *Credential) (*Credential, error)
 }
 
+// CredentialChecker is an optional interface for providers that can verify a
+// stored credential against the upstream service before a run starts. The
+// check should hit the provider's cheapest authenticated endpoint and return
+// an error wrapping ErrCredentialRejected when the service refuses the
+// credential (expired, revoked). Any other error means the check was
+// inconclusive (offline, timeout, 5xx) and must not block the run.
+type CredentialChecker interface {
+	CheckCredential(ctx context.Context, cred *Credential) error
+}
+
 // JoinOpts carries the parsed flags for a joined agent session.
 type JoinOpts struct {
 	Continue bool
@@ -147,6 +232,37 @@ type InitFileProvider interface {
 	ContainerInitFiles(cred *Credential, containerHome string) map[string]string
 }
 
+// SendRoute identifies API requests that deliver a message to a third party
+// (an SMS, a phone call, an email).
+type SendRoute struct {
+	Host   string // e.g. "api.sendgrid.com"
+	Method string // e.g. "POST"
+	Path   string // netrules path pattern, e.g. "/2010-04-01/Accounts/*/Messages.json"
+}
+
+// SendingProvider is an optional interface for providers whose APIs send
+// messages to third parties. The proxy counts requests matching SendRoutes
+// against the run's messaging.max_messages cap and records each in the
+// audit log. Implemented by twilio and sendgrid.
+type SendingProvider interface {
+	SendRoutes() []SendRoute
+}
+
+// RequestGuard names a check the proxy daemon runs on a grant's requests to
+// Host before forwarding them. Kind selects the check; the daemon refuses
+// requests a check fails.
+type RequestGuard struct {
+	Host string // e.g. "api.stripe.com"
+	Kind string // e.g. "stripe-live-mode"
+}
+
+// GuardingProvider is an optional interface for providers whose credentials
+// restrict which requests a run may make with them. Implemented by stripe,
+// whose test-mode-only grants must never reach live-mode data.
+type GuardingProvider interface {
+	RequestGuards(cred *Credential) []RequestGuard
+}
+
 // EndpointProvider exposes HTTP endpoints to containers.
 // Implemented by aws for the credential endpoint.
 type EndpointProvider interface {
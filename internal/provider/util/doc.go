@@ -1,4 +1,4 @@
 // Package util provides shared utilities for provider implementations.
 // Includes helpers for prompting users, checking environment variables,
-// and validating token formats.
+// validating token formats, and signing JWTs for key-pair authentication.
 package util
@@ -0,0 +1,61 @@
+package util
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+)
+
+// ParseRSAPrivateKey decodes a PEM-encoded RSA private key in PKCS#8
+// ("PRIVATE KEY") or PKCS#1 ("RSA PRIVATE KEY") form. Encrypted keys are
+// rejected; callers should ask for an unencrypted copy.
+func ParseRSAPrivateKey(pemData []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(pemData)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data found")
+	}
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		rsaKey, ok := key.(*rsa.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("private key is %T, not RSA", key)
+		}
+		return rsaKey, nil
+	case "ENCRYPTED PRIVATE KEY":
+		return nil, fmt.Errorf("private key is encrypted")
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+}
+
+// SignJWT returns claims encoded as a compact JWT signed with RS256.
+func SignJWT(key *rsa.PrivateKey, claims any) (string, error) {
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", fmt.Errorf("encoding JWT claims: %w", err)
+	}
+	enc := base64.RawURLEncoding
+	signingInput := enc.EncodeToString(header) + "." + enc.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signingInput))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("signing JWT: %w", err)
+	}
+	return signingInput + "." + enc.EncodeToString(sig), nil
+}
@@ -0,0 +1,82 @@
+package util
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"strings"
+	"testing"
+)
+
+func TestParseRSAPrivateKey(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkcs8, _ := x509.MarshalPKCS8PrivateKey(key)
+
+	for name, data := range map[string][]byte{
+		"pkcs1": pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}),
+		"pkcs8": pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8}),
+	} {
+		got, err := ParseRSAPrivateKey(data)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if !got.Equal(key) {
+			t.Errorf("%s: parsed key differs", name)
+		}
+	}
+
+	encrypted := pem.EncodeToMemory(&pem.Block{Type: "ENCRYPTED PRIVATE KEY", Bytes: []byte("x")})
+	if _, err := ParseRSAPrivateKey(encrypted); err == nil || !strings.Contains(err.Error(), "encrypted") {
+		t.Errorf("encrypted key: err = %v, want encrypted error", err)
+	}
+	if _, err := ParseRSAPrivateKey([]byte("not pem")); err == nil {
+		t.Error("expected error for non-PEM input")
+	}
+}
+
+func TestSignJWT(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := SignJWT(key, map[string]any{"sub": "agent", "exp": 123})
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		t.Fatalf("token has %d parts, want 3", len(parts))
+	}
+
+	var header, claims map[string]any
+	for i, v := range []*map[string]any{&header, &claims} {
+		data, err := base64.RawURLEncoding.DecodeString(parts[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := json.Unmarshal(data, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if header["alg"] != "RS256" {
+		t.Errorf("alg = %v, want RS256", header["alg"])
+	}
+	if claims["sub"] != "agent" {
+		t.Errorf("sub = %v, want agent", claims["sub"])
+	}
+
+	sig, _ := base64.RawURLEncoding.DecodeString(parts[2])
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest[:], sig); err != nil {
+		t.Errorf("signature does not verify: %v", err)
+	}
+}
@@ -0,0 +1,37 @@
+package util
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+
+	"github.com/majorcontext/moat/internal/provider"
+)
+
+// ProbeCredential issues a credential-check request and classifies the
+// response for provider.CredentialChecker implementations. A 2xx response is
+// success; a status in rejectStatus (401 if none given) wraps
+// provider.ErrCredentialRejected; anything else, including transport errors,
+// is an inconclusive error.
+func ProbeCredential(ctx context.Context, req *http.Request, rejectStatus ...int) error {
+	if len(rejectStatus) == 0 {
+		rejectStatus = []int{http.StatusUnauthorized}
+	}
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return fmt.Errorf("contacting %s: %w", req.URL.Host, err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	case slices.Contains(rejectStatus, resp.StatusCode):
+		return fmt.Errorf("%w (%s returned %d)", provider.ErrCredentialRejected, req.URL.Host, resp.StatusCode)
+	default:
+		return fmt.Errorf("%s returned unexpected status %d", req.URL.Host, resp.StatusCode)
+	}
+}
@@ -0,0 +1,57 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/majorcontext/moat/internal/provider"
+)
+
+func TestProbeCredential(t *testing.T) {
+	tests := []struct {
+		name         string
+		status       int
+		reject       []int
+		wantErr      bool
+		wantRejected bool
+	}{
+		{name: "ok", status: http.StatusOK},
+		{name: "unauthorized", status: http.StatusUnauthorized, wantErr: true, wantRejected: true},
+		{name: "server error is inconclusive", status: http.StatusBadGateway, wantErr: true},
+		{name: "rate limit is inconclusive", status: http.StatusTooManyRequests, wantErr: true},
+		{name: "custom reject status", status: http.StatusBadRequest, reject: []int{http.StatusBadRequest}, wantErr: true, wantRejected: true},
+		{name: "custom list replaces 401", status: http.StatusUnauthorized, reject: []int{http.StatusBadRequest}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+			}))
+			defer srv.Close()
+
+			req, _ := http.NewRequest("GET", srv.URL, nil)
+			err := ProbeCredential(context.Background(), req, tt.reject...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := errors.Is(err, provider.ErrCredentialRejected); got != tt.wantRejected {
+				t.Errorf("rejected = %v, want %v (err: %v)", got, tt.wantRejected, err)
+			}
+		})
+	}
+}
+
+func TestProbeCredentialUnreachable(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	url := srv.URL
+	srv.Close()
+
+	req, _ := http.NewRequest("GET", url, nil)
+	err := ProbeCredential(context.Background(), req)
+	if err == nil || errors.Is(err, provider.ErrCredentialRejected) {
+		t.Errorf("unreachable server should be inconclusive, got %v", err)
+	}
+}
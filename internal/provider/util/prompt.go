@@ -2,16 +2,88 @@ package util
 
 import (
 	"bufio"
+	"context"
+	"errors"
 	"fmt"
 	"os"
 	"strings"
 
 	"golang.org/x/term"
+
+	"github.com/majorcontext/moat/internal/errcode"
 )
 
+// ErrInputRequired is returned when a grant needs input that was not
+// supplied through the environment or flags and prompting is disabled.
+var ErrInputRequired = errors.New("input required")
+
+func init() {
+	errcode.Register(ErrInputRequired, errcode.InputRequired)
+}
+
+// GrantOptions are the provisioning flags shared by every `moat grant`
+// subcommand.
+type GrantOptions struct {
+	// NonInteractive disables prompting. Prompts fail with ErrInputRequired
+	// and menus take their default answer. Set by --no-interactive so CI
+	// scripts fail fast instead of blocking on stdin.
+	NonInteractive bool
+	// EnvOnly restricts grants to credentials read from environment
+	// variables, skipping imports from host CLIs and their config files (gh,
+	// Gemini CLI, .npmrc). Set by --from-env.
+	EnvOnly bool
+}
+
+// ctxKeyOptions is the context key for GrantOptions.
+type ctxKeyOptions struct{}
+
+// WithGrantOptions returns a context carrying the grant flags.
+func WithGrantOptions(ctx context.Context, opts GrantOptions) context.Context {
+	return context.WithValue(ctx, ctxKeyOptions{}, opts)
+}
+
+// GrantOptionsFrom returns the grant flags carried by ctx, or the zero
+// value (interactive, all sources) if none are set.
+func GrantOptionsFrom(ctx context.Context) GrantOptions {
+	if ctx == nil {
+		return GrantOptions{}
+	}
+	opts, _ := ctx.Value(ctxKeyOptions{}).(GrantOptions)
+	return opts
+}
+
+// RequireInput returns an ErrInputRequired error naming what is missing when
+// ctx's grant options disable prompting, and nil otherwise. Providers call it
+// before an interactive fallback so the error names the environment
+// variables to set.
+func RequireInput(ctx context.Context, what string) error {
+	if GrantOptionsFrom(ctx).NonInteractive {
+		return fmt.Errorf("%w: %s", ErrInputRequired, what)
+	}
+	return nil
+}
+
+// ReadChoice prints prompt and reads an answer from reader. With prompting
+// disabled it returns def without reading, or ErrInputRequired when there is
+// no default.
+func ReadChoice(ctx context.Context, reader *bufio.Reader, prompt, def string) (string, error) {
+	if GrantOptionsFrom(ctx).NonInteractive {
+		if def == "" {
+			return "", RequireInput(ctx, strings.TrimSuffix(strings.TrimSpace(prompt), ":"))
+		}
+		return def, nil
+	}
+	fmt.Print(prompt)
+	line, err := reader.ReadString('\n')
+	return strings.TrimSpace(line), err
+}
+
 // PromptForToken prompts the user for a token with the given message.
 // Input is hidden (not echoed to terminal).
-func PromptForToken(prompt string) (string, error) {
+func PromptForToken(ctx context.Context, prompt string) (string, error) {
+	if err := RequireInput(ctx, prompt); err != nil {
+		return "", err
+	}
 	fmt.Print(prompt + ": ")
 
 	// Check if stdin is a terminal
@@ -36,7 +108,10 @@ func PromptForToken(prompt string) (string, error) {
 
 // PromptForChoice displays options and returns the selected index (0-based).
 // Returns -1 and error if input is invalid.
-func PromptForChoice(prompt string, options []string) (int, error) {
+func PromptForChoice(ctx context.Context, prompt string, options []string) (int, error) {
+	if err := RequireInput(ctx, prompt); err != nil {
+		return -1, err
+	}
 	fmt.Println(prompt)
 	for i, opt := range options {
 		fmt.Printf("  %d. %s\n", i+1, opt)
@@ -61,8 +136,12 @@ func PromptForChoice(prompt string, options []string) (int, error) {
 	return choice - 1, nil
 }
 
-// Confirm prompts for yes/no confirmation. Returns true for yes.
-func Confirm(prompt string) (bool, error) {
+// Confirm prompts for yes/no confirmation. Returns true for yes. With
+// prompting disabled it returns the default, no.
+func Confirm(ctx context.Context, prompt string) (bool, error) {
+	if GrantOptionsFrom(ctx).NonInteractive {
+		return false, nil
+	}
 	fmt.Print(prompt + " [y/N]: ")
 
 	reader := bufio.NewReader(os.Stdin)
@@ -0,0 +1,62 @@
+package util
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+var nonInteractive = WithGrantOptions(context.Background(), GrantOptions{NonInteractive: true})
+
+func TestGrantOptionsFrom(t *testing.T) {
+	if got := GrantOptionsFrom(context.Background()); got != (GrantOptions{}) {
+		t.Errorf("GrantOptionsFrom() without options = %+v, want zero value", got)
+	}
+	want := GrantOptions{NonInteractive: true, EnvOnly: true}
+	if got := GrantOptionsFrom(WithGrantOptions(context.Background(), want)); got != want {
+		t.Errorf("GrantOptionsFrom() = %+v, want %+v", got, want)
+	}
+}
+
+func TestRequireInput(t *testing.T) {
+	if err := RequireInput(context.Background(), "set TOKEN"); err != nil {
+		t.Errorf("RequireInput() interactive = %v, want nil", err)
+	}
+
+	err := RequireInput(nonInteractive, "set TOKEN")
+	if !errors.Is(err, ErrInputRequired) {
+		t.Fatalf("RequireInput() = %v, want ErrInputRequired", err)
+	}
+	if !strings.Contains(err.Error(), "set TOKEN") {
+		t.Errorf("RequireInput() = %q, want it to name what is missing", err)
+	}
+}
+
+func TestReadChoice(t *testing.T) {
+	got, err := ReadChoice(context.Background(), bufio.NewReader(strings.NewReader(" 2 \n")), "Choice: ", "1")
+	if err != nil || got != "2" {
+		t.Errorf("ReadChoice() = %q, %v; want \"2\", nil", got, err)
+	}
+
+	got, err = ReadChoice(nonInteractive, bufio.NewReader(strings.NewReader("2\n")), "Choice: ", "1")
+	if err != nil || got != "1" {
+		t.Errorf("ReadChoice() non-interactive = %q, %v; want default \"1\"", got, err)
+	}
+	if _, err := ReadChoice(nonInteractive, bufio.NewReader(strings.NewReader("")), "Choice: ", ""); !errors.Is(err, ErrInputRequired) {
+		t.Errorf("ReadChoice() without default = %v, want ErrInputRequired", err)
+	}
+}
+
+func TestPromptsNonInteractive(t *testing.T) {
+	if _, err := PromptForToken(nonInteractive, "Token"); !errors.Is(err, ErrInputRequired) {
+		t.Errorf("PromptForToken() = %v, want ErrInputRequired", err)
+	}
+	if _, err := PromptForChoice(nonInteractive, "Pick", []string{"a", "b"}); !errors.Is(err, ErrInputRequired) {
+		t.Errorf("PromptForChoice() = %v, want ErrInputRequired", err)
+	}
+	if ok, err := Confirm(nonInteractive, "Proceed?"); ok || err != nil {
+		t.Errorf("Confirm() = %v, %v; want false, nil", ok, err)
+	}
+}
@@ -0,0 +1,93 @@
+package atlassian
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Products an Account can hold credentials for.
+const (
+	ProductBitbucket = "bitbucket" // Bitbucket Cloud REST API
+	ProductAtlassian = "atlassian" // Atlassian Cloud platform gateway (Jira, Confluence)
+)
+
+// Hosts the proxy injects credentials for.
+const (
+	BitbucketAPIHost = "api.bitbucket.org"
+	AtlassianAPIHost = "api.atlassian.com"
+)
+
+// Account is the credential for one product.
+type Account struct {
+	Product string `json:"product"` // ProductBitbucket or ProductAtlassian
+
+	// App password (or API token) logins. Bitbucket only.
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+
+	// OAuth logins.
+	AccessToken  string    `json:"access_token,omitempty"`
+	RefreshToken string    `json:"refresh_token,omitempty"`
+	ExpiresAt    time.Time `json:"expires_at,omitzero"`
+	ClientID     string    `json:"client_id,omitempty"`
+	ClientSecret string    `json:"client_secret,omitempty"`
+
+	TokenSource string `json:"token_source,omitempty"`
+}
+
+// Host returns the API host the account's credential is injected for.
+func (a Account) Host() string {
+	switch a.Product {
+	case ProductBitbucket:
+		return BitbucketAPIHost
+	case ProductAtlassian:
+		return AtlassianAPIHost
+	}
+	return ""
+}
+
+// IsOAuth returns true if the account holds an OAuth access token.
+func (a Account) IsOAuth() bool {
+	return a.AccessToken != ""
+}
+
+// Authorization returns the Authorization header value for the account:
+// Bearer for OAuth access tokens, Basic for app passwords.
+func (a Account) Authorization() string {
+	if a.IsOAuth() {
+		return "Bearer " + a.AccessToken
+	}
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(a.Username+":"+a.Password))
+}
+
+// MarshalAccounts encodes accounts for storage in the Token field.
+func MarshalAccounts(accounts []Account) (string, error) {
+	data, err := json.Marshal(accounts)
+	if err != nil {
+		return "", fmt.Errorf("marshaling accounts: %w", err)
+	}
+	return string(data), nil
+}
+
+// UnmarshalAccounts decodes accounts from the JSON-encoded Token field.
+func UnmarshalAccounts(token string) ([]Account, error) {
+	var accounts []Account
+	if err := json.Unmarshal([]byte(token), &accounts); err != nil {
+		return nil, fmt.Errorf("unmarshaling accounts: %w", err)
+	}
+	return accounts, nil
+}
+
+// MergeAccount adds acct to accounts, replacing any entry for the same
+// product.
+func MergeAccount(accounts []Account, acct Account) []Account {
+	for i, e := range accounts {
+		if e.Product == acct.Product {
+			accounts[i] = acct
+			return accounts
+		}
+	}
+	return append(accounts, acct)
+}
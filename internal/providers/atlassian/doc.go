@@ -0,0 +1,13 @@
+// Package atlassian implements a credential provider for Bitbucket Cloud and
+// Atlassian Cloud APIs (grant name "atlassian").
+//
+// A credential holds one Account per product, JSON-encoded in the Token
+// field, so a single grant can cover both Bitbucket Cloud (api.bitbucket.org)
+// and the Atlassian platform gateway (api.atlassian.com) that Jira and
+// Confluence clients and MCP servers call. Bitbucket accepts an app password
+// (or API token) with HTTP Basic auth or an OAuth access token; Atlassian
+// Cloud accepts OAuth 2.0 (3LO) access tokens. The proxy injects the
+// Authorization header per product host, and OAuth access tokens are
+// refreshed by the daemon before they expire. The refresh token never
+// enters the container.
+package atlassian
@@ -0,0 +1,206 @@
+package atlassian
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/majorcontext/moat/internal/credential"
+	"github.com/majorcontext/moat/internal/provider"
+	"github.com/majorcontext/moat/internal/provider/util"
+)
+
+// Token source values stored in Account.TokenSource.
+const (
+	SourceEnv    = "env"    // From BITBUCKET_USERNAME and BITBUCKET_APP_PASSWORD
+	SourceManual = "manual" // Interactive prompt entry
+	SourceOAuth  = "oauth"  // Browser authorization code flow
+)
+
+// GrantOptions carries the grant flags.
+type GrantOptions struct {
+	Product      string // --product; ProductBitbucket when empty
+	OAuth        bool   // --oauth
+	ClientID     string // --client-id
+	ClientSecret string // --client-secret
+	Scopes       string // --scope, space-separated (Atlassian only)
+	CallbackPort int    // --callback-port; DefaultCallbackPort when zero
+}
+
+// ctxKeyOptions is the context key for GrantOptions.
+type ctxKeyOptions struct{}
+
+// WithGrantOptions returns a context carrying the grant flags.
+func WithGrantOptions(ctx context.Context, opts GrantOptions) context.Context {
+	return context.WithValue(ctx, ctxKeyOptions{}, opts)
+}
+
+// clientEnv names the environment variables holding each product's OAuth
+// client ID and secret.
+var clientEnv = map[string][2]string{
+	ProductBitbucket: {"BITBUCKET_CLIENT_ID", "BITBUCKET_CLIENT_SECRET"},
+	ProductAtlassian: {"ATLASSIAN_CLIENT_ID", "ATLASSIAN_CLIENT_SECRET"},
+}
+
+// Grant adds a product's account to the credential. Accounts granted earlier
+// for other products are kept.
+//
+// Bitbucket accepts an app password, read from BITBUCKET_USERNAME and
+// BITBUCKET_APP_PASSWORD or prompted for, or with --oauth an OAuth consumer.
+// Atlassian Cloud requires --oauth with an OAuth 2.0 (3LO) app.
+func (p *Provider) Grant(ctx context.Context) (*provider.Credential, error) {
+	opts, _ := ctx.Value(ctxKeyOptions{}).(GrantOptions)
+	product := opts.Product
+	if product == "" {
+		product = ProductBitbucket
+	}
+	if _, ok := clientEnv[product]; !ok {
+		return nil, &provider.GrantError{
+			Provider: "atlassian",
+			Cause:    fmt.Errorf("unknown product %q", product),
+			Hint:     "Use --product bitbucket or --product atlassian",
+		}
+	}
+	if product == ProductAtlassian && !opts.OAuth {
+		return nil, &provider.GrantError{
+			Provider: "atlassian",
+			Cause:    fmt.Errorf("Atlassian Cloud APIs require OAuth"),
+			Hint:     "Run 'moat grant atlassian --product atlassian --oauth' with the client ID and secret of an OAuth 2.0 (3LO) app",
+		}
+	}
+	if opts.Scopes != "" && product != ProductAtlassian {
+		return nil, &provider.GrantError{
+			Provider: "atlassian",
+			Cause:    fmt.Errorf("--scope applies to --product atlassian only"),
+			Hint:     "Bitbucket OAuth consumers take their scopes from the consumer's permissions",
+		}
+	}
+
+	var (
+		acct Account
+		err  error
+	)
+	if opts.OAuth {
+		acct, err = grantOAuthAccount(ctx, product, opts)
+	} else {
+		acct, err = grantAppPassword(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Println("Validating...")
+	if err := checkAccount(ctx, acct); err != nil {
+		return nil, &provider.GrantError{
+			Provider: "atlassian",
+			Cause:    fmt.Errorf("validation failed for %s: %w", acct.Host(), err),
+			Hint:     "Check the credential's permissions and try again",
+		}
+	}
+	fmt.Printf("Authenticated to %s\n", acct.Host())
+
+	accounts, _ := loadExisting()
+	token, err := MarshalAccounts(MergeAccount(accounts, acct))
+	if err != nil {
+		return nil, err
+	}
+	return &provider.Credential{
+		Provider:  "atlassian",
+		Token:     token,
+		CreatedAt: time.Now(),
+	}, nil
+}
+
+// grantAppPassword reads a Bitbucket username and app password from the
+// environment or interactive prompts.
+func grantAppPassword(ctx context.Context) (Account, error) {
+	acct := Account{
+		Product:     ProductBitbucket,
+		Username:    os.Getenv("BITBUCKET_USERNAME"),
+		Password:    os.Getenv("BITBUCKET_APP_PASSWORD"),
+		TokenSource: SourceEnv,
+	}
+	if acct.Username != "" && acct.Password != "" {
+		fmt.Println("Using app password from BITBUCKET_APP_PASSWORD environment variable")
+		return acct, nil
+	}
+	if err := util.RequireInput(ctx, "set BITBUCKET_USERNAME and BITBUCKET_APP_PASSWORD"); err != nil {
+		return acct, err
+	}
+	acct.TokenSource = SourceManual
+
+	if acct.Username == "" {
+		fmt.Print("Bitbucket username: ")
+		line, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		acct.Username = strings.TrimSpace(line)
+	}
+	if acct.Password == "" {
+		fmt.Println(`Enter a Bitbucket app password.
+
+To create one:
+  1. Visit https://bitbucket.org/account/settings/app-passwords/
+  2. Click "Create app password" and grant the permissions the agent needs
+     (e.g. Repositories: Read, Pull requests: Write)
+  3. Paste it below`)
+		var err error
+		acct.Password, err = util.PromptForToken(ctx, "App password")
+		if err != nil {
+			return acct, fmt.Errorf("reading app password: %w", err)
+		}
+	}
+	if acct.Username == "" || acct.Password == "" {
+		return acct, &provider.GrantError{
+			Provider: "atlassian",
+			Cause:    fmt.Errorf("username and app password are required"),
+			Hint:     "Run 'moat grant atlassian' and enter both",
+		}
+	}
+	return acct, nil
+}
+
+// grantOAuthAccount resolves the OAuth client from flags or the environment
+// and runs the browser flow.
+func grantOAuthAccount(ctx context.Context, product string, opts GrantOptions) (Account, error) {
+	env := clientEnv[product]
+	if opts.ClientID == "" {
+		opts.ClientID = os.Getenv(env[0])
+	}
+	if opts.ClientSecret == "" {
+		opts.ClientSecret = os.Getenv(env[1])
+	}
+	if opts.ClientID == "" || opts.ClientSecret == "" {
+		return Account{}, &provider.GrantError{
+			Provider: "atlassian",
+			Cause:    fmt.Errorf("OAuth needs a client ID and secret"),
+			Hint:     fmt.Sprintf("Pass --client-id and --client-secret, or set %s and %s", env[0], env[1]),
+		}
+	}
+	acct, err := grantOAuth(ctx, product, opts)
+	if err != nil {
+		return acct, &provider.GrantError{Provider: "atlassian", Cause: err}
+	}
+	if acct.RefreshToken == "" {
+		fmt.Println("Warning: no refresh token was issued; re-grant when the access token expires.")
+	}
+	return acct, nil
+}
+
+// loadExisting loads the accounts already granted.
+func loadExisting() ([]Account, error) {
+	key, err := credential.DefaultEncryptionKey()
+	if err != nil {
+		return nil, err
+	}
+	store, err := credential.NewFileStore(credential.DefaultStoreDir(), key)
+	if err != nil {
+		return nil, err
+	}
+	cred, err := store.Get(credential.ProviderAtlassian)
+	if err != nil {
+		return nil, err
+	}
+	return UnmarshalAccounts(cred.Token)
+}
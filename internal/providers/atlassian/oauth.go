@@ -0,0 +1,176 @@
+package atlassian
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/majorcontext/moat/internal/providers/oauth"
+)
+
+const (
+	// DefaultCallbackPort is the port of the OAuth redirect URI,
+	// http://127.0.0.1:8976/callback. Atlassian and Bitbucket only redirect
+	// to a callback URL registered with the app, so the port is fixed.
+	DefaultCallbackPort = 8976
+
+	// DefaultAtlassianScopes are requested when --scope is not given.
+	// offline_access is always requested; without it there is no refresh
+	// token.
+	DefaultAtlassianScopes = "read:jira-work read:jira-user"
+
+	// refreshWindow is how long before expiry an access token is refreshed.
+	// Bitbucket access tokens last two hours and Atlassian's one hour; the
+	// daemon asks every few minutes.
+	refreshWindow = 15 * time.Minute
+
+	// maxResponseBytes caps token endpoint response bodies.
+	maxResponseBytes = 1 << 20
+)
+
+// endpoints are a product's OAuth authorization and token URLs.
+type endpoints struct {
+	AuthURL  string
+	TokenURL string
+}
+
+// oauthEndpoints maps products to their authorization servers. Bitbucket
+// Cloud has its own, separate from Atlassian's. The audience and prompt
+// parameters are required by Atlassian's authorization server. A variable
+// so tests can point the token URLs at a local server.
+var oauthEndpoints = map[string]endpoints{
+	ProductBitbucket: {
+		AuthURL:  "https://bitbucket.org/site/oauth2/authorize",
+		TokenURL: "https://bitbucket.org/site/oauth2/access_token",
+	},
+	ProductAtlassian: {
+		AuthURL:  "https://auth.atlassian.com/authorize?audience=api.atlassian.com&prompt=consent",
+		TokenURL: "https://auth.atlassian.com/oauth/token",
+	},
+}
+
+// Probe endpoints. Variables so tests can point them at a local server.
+var (
+	bitbucketUserURL      = "https://" + BitbucketAPIHost + "/2.0/user"
+	atlassianResourcesURL = "https://" + AtlassianAPIHost + "/oauth/token/accessible-resources"
+)
+
+// tokenClient is the HTTP client for token refresh requests.
+var tokenClient = &http.Client{Timeout: 30 * time.Second}
+
+// OAuthError is an error response from a token endpoint.
+type OAuthError struct {
+	Code        string
+	Description string
+}
+
+func (e *OAuthError) Error() string {
+	if e.Description != "" {
+		return fmt.Sprintf("%s: %s", e.Code, e.Description)
+	}
+	return e.Code
+}
+
+// grantOAuth runs the authorization code flow for product in the browser
+// and returns the resulting account.
+func grantOAuth(ctx context.Context, product string, opts GrantOptions) (Account, error) {
+	ep := oauthEndpoints[product]
+	cfg := &oauth.Config{
+		AuthURL:      ep.AuthURL,
+		TokenURL:     ep.TokenURL,
+		ClientID:     opts.ClientID,
+		ClientSecret: opts.ClientSecret,
+		CallbackPort: opts.CallbackPort,
+	}
+	if cfg.CallbackPort == 0 {
+		cfg.CallbackPort = DefaultCallbackPort
+	}
+	if product == ProductAtlassian {
+		// Bitbucket takes scopes from the consumer's settings instead.
+		scopes := opts.Scopes
+		if scopes == "" {
+			scopes = DefaultAtlassianScopes
+		}
+		if !strings.Contains(" "+scopes+" ", " offline_access ") {
+			scopes += " offline_access"
+		}
+		cfg.Scopes = scopes
+	}
+
+	fmt.Printf("The redirect URI registered with your app must be http://127.0.0.1:%d/callback\n", cfg.CallbackPort)
+	cred, err := oauth.RunGrant(ctx, "atlassian", cfg, "")
+	if err != nil {
+		return Account{}, err
+	}
+	return Account{
+		Product:      product,
+		AccessToken:  cred.Token,
+		RefreshToken: cred.Metadata["refresh_token"],
+		ExpiresAt:    cred.ExpiresAt,
+		ClientID:     cfg.ClientID,
+		ClientSecret: cfg.ClientSecret,
+		TokenSource:  SourceOAuth,
+	}, nil
+}
+
+// refreshAccount exchanges acct's refresh token for a new access token.
+func refreshAccount(ctx context.Context, acct Account) (Account, error) {
+	form := url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {acct.RefreshToken},
+		"client_id":     {acct.ClientID},
+		"client_secret": {acct.ClientSecret},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, oauthEndpoints[acct.Product].TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return acct, fmt.Errorf("creating refresh request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := tokenClient.Do(req)
+	if err != nil {
+		return acct, fmt.Errorf("making refresh request: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBytes))
+	if err != nil {
+		return acct, fmt.Errorf("reading refresh response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var errResp struct {
+			Error       string `json:"error"`
+			Description string `json:"error_description"`
+		}
+		if json.Unmarshal(body, &errResp) == nil && errResp.Error != "" {
+			return acct, &OAuthError{Code: errResp.Error, Description: errResp.Description}
+		}
+		return acct, fmt.Errorf("token refresh failed (HTTP %d): %s", resp.StatusCode, body)
+	}
+
+	var tokenResp struct {
+		AccessToken  string `json:"access_token"`
+		RefreshToken string `json:"refresh_token"`
+		ExpiresIn    int64  `json:"expires_in"`
+	}
+	if err := json.Unmarshal(body, &tokenResp); err != nil {
+		return acct, fmt.Errorf("parsing refresh response: %w", err)
+	}
+	if tokenResp.AccessToken == "" {
+		return acct, fmt.Errorf("no access token in refresh response")
+	}
+
+	acct.AccessToken = tokenResp.AccessToken
+	if tokenResp.RefreshToken != "" {
+		acct.RefreshToken = tokenResp.RefreshToken
+	}
+	if tokenResp.ExpiresIn > 0 {
+		acct.ExpiresAt = time.Now().Add(time.Duration(tokenResp.ExpiresIn) * time.Second)
+	}
+	return acct, nil
+}
@@ -0,0 +1,165 @@
+package atlassian
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/majorcontext/moat/internal/provider"
+	"github.com/majorcontext/moat/internal/provider/util"
+)
+
+// Provider implements provider.CredentialProvider for Bitbucket Cloud and
+// Atlassian Cloud.
+type Provider struct{}
+
+// Verify interface compliance at compile time.
+var (
+	_ provider.CredentialProvider  = (*Provider)(nil)
+	_ provider.CredentialChecker   = (*Provider)(nil)
+	_ provider.RefreshableProvider = (*Provider)(nil)
+)
+
+func init() {
+	provider.Register(&Provider{})
+}
+
+// Name returns the provider identifier.
+func (p *Provider) Name() string {
+	return "atlassian"
+}
+
+// ConfigureProxy injects each account's Authorization header for its
+// product's API host.
+func (p *Provider) ConfigureProxy(proxy provider.ProxyConfigurer, cred *provider.Credential) {
+	accounts, err := UnmarshalAccounts(cred.Token)
+	if err != nil {
+		return
+	}
+	for _, acct := range accounts {
+		if host := acct.Host(); host != "" {
+			proxy.SetCredentialWithGrant(host, "Authorization", acct.Authorization(), "atlassian")
+		}
+	}
+}
+
+// ContainerEnv returns no environment variables. Clients authenticate
+// through the proxy.
+func (p *Provider) ContainerEnv(cred *provider.Credential) []string {
+	return nil
+}
+
+// ContainerMounts returns no mounts.
+func (p *Provider) ContainerMounts(cred *provider.Credential, containerHome string) ([]provider.MountConfig, string, error) {
+	return nil, "", nil
+}
+
+// Cleanup is a no-op.
+func (p *Provider) Cleanup(cleanupPath string) {}
+
+// ImpliedDependencies returns dependencies implied by this provider.
+func (p *Provider) ImpliedDependencies() []string {
+	return nil
+}
+
+// CheckCredential verifies every app password account against its API.
+// OAuth accounts are refreshed at run start instead, so they are not
+// checked here.
+func (p *Provider) CheckCredential(ctx context.Context, cred *provider.Credential) error {
+	accounts, err := UnmarshalAccounts(cred.Token)
+	if err != nil {
+		return err
+	}
+	for _, acct := range accounts {
+		if acct.IsOAuth() {
+			continue
+		}
+		if err := checkAccount(ctx, acct); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkAccount fetches the authenticated Bitbucket user, or the Atlassian
+// sites an OAuth token can reach.
+func checkAccount(ctx context.Context, acct Account) error {
+	probeURL := bitbucketUserURL
+	if acct.Product == ProductAtlassian {
+		probeURL = atlassianResourcesURL
+	}
+	req, err := http.NewRequest("GET", probeURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", acct.Authorization())
+	req.Header.Set("User-Agent", "moat")
+	probeCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	return util.ProbeCredential(probeCtx, req, http.StatusUnauthorized, http.StatusForbidden)
+}
+
+// CanRefresh reports whether the credential holds an OAuth account with a
+// refresh token. App passwords do not expire.
+func (p *Provider) CanRefresh(cred *provider.Credential) bool {
+	accounts, err := UnmarshalAccounts(cred.Token)
+	if err != nil {
+		return false
+	}
+	for _, acct := range accounts {
+		if acct.RefreshToken != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// RefreshInterval returns how often to attempt refresh.
+func (p *Provider) RefreshInterval() time.Duration {
+	return 10 * time.Minute
+}
+
+// Refresh exchanges the refresh token of each OAuth account whose access
+// token is within refreshWindow of expiring, and updates the proxy. When no
+// account is due, cred is returned unchanged. Atlassian rotates refresh
+// tokens, so the returned credential carries the new ones.
+func (p *Provider) Refresh(ctx context.Context, proxy provider.ProxyConfigurer, cred *provider.Credential) (*provider.Credential, error) {
+	if !p.CanRefresh(cred) {
+		return nil, provider.ErrRefreshNotSupported
+	}
+	accounts, err := UnmarshalAccounts(cred.Token)
+	if err != nil {
+		return nil, err
+	}
+
+	var changed bool
+	for i, acct := range accounts {
+		if acct.RefreshToken == "" || (!acct.ExpiresAt.IsZero() && time.Until(acct.ExpiresAt) > refreshWindow) {
+			continue
+		}
+		updated, err := refreshAccount(ctx, acct)
+		if err != nil {
+			var oauthErr *OAuthError
+			if errors.As(err, &oauthErr) && oauthErr.Code == "invalid_grant" {
+				return nil, fmt.Errorf("%w: %s: %w", provider.ErrTokenRevoked, acct.Product, err)
+			}
+			return nil, fmt.Errorf("refreshing %s token: %w", acct.Product, err)
+		}
+		accounts[i] = updated
+		proxy.SetCredentialWithGrant(updated.Host(), "Authorization", updated.Authorization(), "atlassian")
+		changed = true
+	}
+	if !changed {
+		return cred, nil
+	}
+
+	token, err := MarshalAccounts(accounts)
+	if err != nil {
+		return nil, err
+	}
+	newCred := *cred
+	newCred.Token = token
+	return &newCred, nil
+}
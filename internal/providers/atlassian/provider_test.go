@@ -0,0 +1,196 @@
+package atlassian
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/majorcontext/moat/internal/provider"
+)
+
+type mockProxyConfigurer struct {
+	credentials map[string]string
+}
+
+func (m *mockProxyConfigurer) SetCredential(host, value string)                         {}
+func (m *mockProxyConfigurer) SetCredentialHeader(host, headerName, headerValue string) {}
+func (m *mockProxyConfigurer) SetCredentialWithGrant(host, headerName, headerValue, grant string) {
+	m.credentials[host] = headerName + ": " + headerValue
+}
+func (m *mockProxyConfigurer) AddExtraHeader(host, headerName, headerValue string)                {}
+func (m *mockProxyConfigurer) AddResponseTransformer(host string, t provider.ResponseTransformer) {}
+func (m *mockProxyConfigurer) RemoveRequestHeader(host, header string)                            {}
+func (m *mockProxyConfigurer) SetTokenSubstitution(host, placeholder, realToken string)           {}
+
+func credentialFor(t *testing.T, accounts ...Account) *provider.Credential {
+	t.Helper()
+	token, err := MarshalAccounts(accounts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &provider.Credential{Provider: "atlassian", Token: token}
+}
+
+func TestProvider_ConfigureProxy(t *testing.T) {
+	p := &Provider{}
+	cred := credentialFor(t,
+		Account{Product: ProductBitbucket, Username: "jdoe", Password: "app-pw"},
+		Account{Product: ProductAtlassian, AccessToken: "atl-token"},
+	)
+
+	proxy := &mockProxyConfigurer{credentials: map[string]string{}}
+	p.ConfigureProxy(proxy, cred)
+
+	wantBasic := "Authorization: Basic " + base64.StdEncoding.EncodeToString([]byte("jdoe:app-pw"))
+	if got := proxy.credentials[BitbucketAPIHost]; got != wantBasic {
+		t.Errorf("%s = %q, want %q", BitbucketAPIHost, got, wantBasic)
+	}
+	if got := proxy.credentials[AtlassianAPIHost]; got != "Authorization: Bearer atl-token" {
+		t.Errorf("%s = %q, want the OAuth bearer token", AtlassianAPIHost, got)
+	}
+}
+
+func TestMergeAccount(t *testing.T) {
+	accounts := []Account{{Product: ProductBitbucket, Username: "old"}}
+	accounts = MergeAccount(accounts, Account{Product: ProductAtlassian, AccessToken: "a"})
+	accounts = MergeAccount(accounts, Account{Product: ProductBitbucket, AccessToken: "b"})
+
+	if len(accounts) != 2 {
+		t.Fatalf("accounts = %+v, want one per product", accounts)
+	}
+	if accounts[0].Username != "" || accounts[0].AccessToken != "b" {
+		t.Errorf("bitbucket account = %+v, want the replacement", accounts[0])
+	}
+}
+
+func TestProvider_CanRefresh(t *testing.T) {
+	p := &Provider{}
+	if p.CanRefresh(credentialFor(t, Account{Product: ProductBitbucket, Username: "u", Password: "p"})) {
+		t.Error("app password credential should not be refreshable")
+	}
+	if !p.CanRefresh(credentialFor(t, Account{Product: ProductAtlassian, AccessToken: "a", RefreshToken: "r"})) {
+		t.Error("OAuth credential with a refresh token should be refreshable")
+	}
+}
+
+// withTokenServer points product's token URL at handler for the test.
+func withTokenServer(t *testing.T, product string, handler http.HandlerFunc) {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	orig := oauthEndpoints[product]
+	oauthEndpoints[product] = endpoints{AuthURL: orig.AuthURL, TokenURL: srv.URL}
+	t.Cleanup(func() { oauthEndpoints[product] = orig })
+}
+
+func TestProvider_Refresh(t *testing.T) {
+	var gotForm map[string]string
+	withTokenServer(t, ProductAtlassian, func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		gotForm = map[string]string{
+			"grant_type":    r.PostForm.Get("grant_type"),
+			"refresh_token": r.PostForm.Get("refresh_token"),
+			"client_secret": r.PostForm.Get("client_secret"),
+		}
+		w.Write([]byte(`{"access_token":"new-access","refresh_token":"new-refresh","expires_in":3600}`))
+	})
+
+	p := &Provider{}
+	fresh := Account{Product: ProductBitbucket, AccessToken: "bb", RefreshToken: "bb-r", ExpiresAt: time.Now().Add(time.Hour)}
+	due := Account{Product: ProductAtlassian, AccessToken: "old", RefreshToken: "old-refresh", ClientID: "id", ClientSecret: "secret", ExpiresAt: time.Now().Add(time.Minute)}
+	cred := credentialFor(t, fresh, due)
+
+	proxy := &mockProxyConfigurer{credentials: map[string]string{}}
+	updated, err := p.Refresh(context.Background(), proxy, cred)
+	if err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	if gotForm["grant_type"] != "refresh_token" || gotForm["refresh_token"] != "old-refresh" || gotForm["client_secret"] != "secret" {
+		t.Errorf("refresh form = %v", gotForm)
+	}
+	if got := proxy.credentials[AtlassianAPIHost]; got != "Authorization: Bearer new-access" {
+		t.Errorf("proxy credential = %q, want the new access token", got)
+	}
+	if _, ok := proxy.credentials[BitbucketAPIHost]; ok {
+		t.Error("Bitbucket token is not due and should not be refreshed")
+	}
+
+	accounts, err := UnmarshalAccounts(updated.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if accounts[0].AccessToken != "bb" {
+		t.Errorf("bitbucket account = %+v, want unchanged", accounts[0])
+	}
+	if accounts[1].AccessToken != "new-access" || accounts[1].RefreshToken != "new-refresh" {
+		t.Errorf("atlassian account = %+v, want rotated tokens", accounts[1])
+	}
+	if time.Until(accounts[1].ExpiresAt) < 50*time.Minute {
+		t.Errorf("expires at %v, want about an hour from now", accounts[1].ExpiresAt)
+	}
+}
+
+func TestProvider_RefreshNotDue(t *testing.T) {
+	p := &Provider{}
+	cred := credentialFor(t, Account{Product: ProductAtlassian, AccessToken: "a", RefreshToken: "r", ExpiresAt: time.Now().Add(time.Hour)})
+	updated, err := p.Refresh(context.Background(), &mockProxyConfigurer{credentials: map[string]string{}}, cred)
+	if err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	if updated != cred {
+		t.Error("Refresh() should return the credential unchanged when no token is due")
+	}
+}
+
+func TestProvider_RefreshRevoked(t *testing.T) {
+	withTokenServer(t, ProductBitbucket, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":"invalid_grant","error_description":"Invalid refresh_token"}`))
+	})
+
+	p := &Provider{}
+	cred := credentialFor(t, Account{Product: ProductBitbucket, AccessToken: "a", RefreshToken: "r"})
+	_, err := p.Refresh(context.Background(), &mockProxyConfigurer{credentials: map[string]string{}}, cred)
+	if !errors.Is(err, provider.ErrTokenRevoked) {
+		t.Errorf("err = %v, want ErrTokenRevoked", err)
+	}
+}
+
+func TestProvider_CheckCredential(t *testing.T) {
+	var gotAuth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+	orig := bitbucketUserURL
+	bitbucketUserURL = srv.URL + "/2.0/user"
+	defer func() { bitbucketUserURL = orig }()
+
+	p := &Provider{}
+	cred := credentialFor(t,
+		Account{Product: ProductBitbucket, Username: "jdoe", Password: "revoked"},
+		Account{Product: ProductAtlassian, AccessToken: "not-checked"},
+	)
+	err := p.CheckCredential(context.Background(), cred)
+	if !errors.Is(err, provider.ErrCredentialRejected) {
+		t.Errorf("err = %v, want ErrCredentialRejected", err)
+	}
+	if gotAuth != "Basic "+base64.StdEncoding.EncodeToString([]byte("jdoe:revoked")) {
+		t.Errorf("Authorization = %q, want the app password", gotAuth)
+	}
+}
+
+func TestGrant_AtlassianRequiresOAuth(t *testing.T) {
+	p := &Provider{}
+	ctx := WithGrantOptions(context.Background(), GrantOptions{Product: ProductAtlassian})
+	_, err := p.Grant(ctx)
+	var grantErr *provider.GrantError
+	if !errors.As(err, &grantErr) {
+		t.Fatalf("err = %v, want a GrantError", err)
+	}
+}
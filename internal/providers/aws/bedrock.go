@@ -0,0 +1,119 @@
+package aws
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"time"
+
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+)
+
+// BedrockEndpointPath is the path on the proxy's credential endpoint that
+// relays requests to the Bedrock runtime API. Claude Code reaches it through
+// ANTHROPIC_BEDROCK_BASE_URL and authenticates with the run's proxy token.
+const BedrockEndpointPath = "/_aws/bedrock"
+
+// bedrockSigningName is the SigV4 service name for the Bedrock runtime API.
+const bedrockSigningName = "bedrock"
+
+// maxBedrockBodySize caps request bodies the relay buffers for signing.
+const maxBedrockBodySize = 32 << 20
+
+// BedrockHost returns the Bedrock runtime API host for region.
+func BedrockHost(region string) string {
+	return "bedrock-runtime." + region + ".amazonaws.com"
+}
+
+// NewBedrockHandler returns a handler that forwards requests under
+// BedrockEndpointPath to the Bedrock runtime API in region, signed with SigV4
+// using credentials from getCredentials. The caller's Authorization header
+// (the run's proxy token) is dropped, so the container never holds AWS keys.
+func NewBedrockHandler(getCredentials func(ctx context.Context) (*Credentials, error), region string) http.Handler {
+	return newBedrockHandler(getCredentials, region, "https://"+BedrockHost(region))
+}
+
+func newBedrockHandler(getCredentials func(ctx context.Context) (*Credentials, error), region, target string) http.Handler {
+	return &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			rest := strings.TrimPrefix(pr.In.URL.EscapedPath(), BedrockEndpointPath)
+			u, err := url.Parse(target + rest)
+			if err != nil {
+				u, _ = url.Parse(target)
+			}
+			u.RawQuery = pr.In.URL.RawQuery
+			pr.Out.URL = u
+			pr.Out.Host = u.Host
+			pr.Out.Header.Del("Authorization")
+			for name := range pr.Out.Header {
+				if strings.HasPrefix(strings.ToLower(name), "x-amz-") {
+					pr.Out.Header.Del(name)
+				}
+			}
+		},
+		Transport: &sigV4Transport{
+			base:           http.DefaultTransport,
+			getCredentials: getCredentials,
+			region:         region,
+			signer:         v4.NewSigner(),
+		},
+		// Streaming responses (invoke-with-response-stream) are flushed
+		// as each event arrives.
+		FlushInterval: -1,
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			slog.Error("bedrock relay error", "error", err)
+			http.Error(w, "bedrock relay failed", http.StatusBadGateway)
+		},
+	}
+}
+
+// sigV4Transport signs each request for the Bedrock runtime API before
+// sending it.
+type sigV4Transport struct {
+	base           http.RoundTripper
+	getCredentials func(ctx context.Context) (*Credentials, error)
+	region         string
+	signer         *v4.Signer
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *sigV4Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(io.LimitReader(req.Body, maxBedrockBodySize+1))
+		req.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("reading request body: %w", err)
+		}
+		if len(body) > maxBedrockBodySize {
+			return nil, fmt.Errorf("request body exceeds %d bytes", maxBedrockBodySize)
+		}
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.ContentLength = int64(len(body))
+	sum := sha256.Sum256(body)
+
+	creds, err := t.getCredentials(req.Context())
+	if err != nil {
+		return nil, fmt.Errorf("getting AWS credentials: %w", err)
+	}
+	awsCreds := awssdk.Credentials{
+		AccessKeyID:     creds.AccessKeyID,
+		SecretAccessKey: creds.SecretAccessKey,
+		SessionToken:    creds.SessionToken,
+	}
+	if err := t.signer.SignHTTP(req.Context(), awsCreds, req, hex.EncodeToString(sum[:]), bedrockSigningName, t.region, time.Now()); err != nil {
+		return nil, fmt.Errorf("signing request: %w", err)
+	}
+	return t.base.RoundTrip(req)
+}
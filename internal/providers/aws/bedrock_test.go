@@ -0,0 +1,86 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestBedrockHandler(t *testing.T) {
+	var got *http.Request
+	var gotBody string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		b, _ := io.ReadAll(r.Body)
+		gotBody = string(b)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	defer upstream.Close()
+
+	h := newBedrockHandler(func(ctx context.Context) (*Credentials, error) {
+		return &Credentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "secret", SessionToken: "session"}, nil
+	}, "us-west-2", upstream.URL)
+
+	body := `{"messages":[]}`
+	req := httptest.NewRequest("POST", BedrockEndpointPath+"/model/anthropic.claude-v1%3A0/invoke?trace=1", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer run-token")
+	req.Header.Set("X-Amz-Security-Token", "container-supplied")
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body.String())
+	}
+	if got.URL.EscapedPath() != "/model/anthropic.claude-v1%3A0/invoke" {
+		t.Errorf("path = %q, want prefix stripped and escaping kept", got.URL.EscapedPath())
+	}
+	if got.URL.RawQuery != "trace=1" {
+		t.Errorf("query = %q", got.URL.RawQuery)
+	}
+	if gotBody != body {
+		t.Errorf("body = %q, want %q", gotBody, body)
+	}
+	auth := got.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/") || !strings.Contains(auth, "/us-west-2/bedrock/aws4_request") {
+		t.Errorf("Authorization = %q, want a SigV4 signature for bedrock in us-west-2", auth)
+	}
+	if tok := got.Header.Get("X-Amz-Security-Token"); tok != "session" {
+		t.Errorf("X-Amz-Security-Token = %q, want the role session token", tok)
+	}
+	if got.Header.Get("X-Amz-Date") == "" {
+		t.Error("X-Amz-Date missing")
+	}
+}
+
+func TestBedrockHandler_CredentialError(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("request forwarded without credentials")
+	}))
+	defer upstream.Close()
+
+	h := newBedrockHandler(func(ctx context.Context) (*Credentials, error) {
+		return nil, errors.New("assume role denied")
+	}, "us-east-1", upstream.URL)
+
+	req := httptest.NewRequest("POST", BedrockEndpointPath+"/model/m/invoke", strings.NewReader("{}"))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusBadGateway {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusBadGateway)
+	}
+	if strings.Contains(w.Body.String(), "denied") {
+		t.Error("credential error leaked to the container")
+	}
+}
+
+func TestBedrockHost(t *testing.T) {
+	if got := BedrockHost("eu-central-1"); got != "bedrock-runtime.eu-central-1.amazonaws.com" {
+		t.Errorf("BedrockHost = %q", got)
+	}
+}
@@ -73,7 +73,10 @@ func grant(ctx context.Context) (*provider.Credential, error) {
 
 	// Prompt if not provided via context
 	if roleARN == "" {
-		roleARN, err = util.PromptForToken("Enter IAM role ARN")
+		if err := util.RequireInput(ctx, "pass --role"); err != nil {
+			return nil, err
+		}
+		roleARN, err = util.PromptForToken(ctx, "Enter IAM role ARN")
 		if err != nil {
 			return nil, &provider.GrantError{
 				Provider: "aws",
@@ -0,0 +1,35 @@
+// Package azure implements the Azure credential provider for moat.
+//
+// Like the AWS provider, Azure uses a credential endpoint instead of header
+// injection. The daemon serves a managed-identity-compatible token endpoint:
+// each request names an audience (resource), and the daemon obtains a token
+// for it on demand, caching tokens per audience until shortly before they
+// expire. Tokens come from one of two identities:
+//
+//   - the host's `az login` session, via `az account get-access-token`
+//   - a service principal, via the OAuth client credentials flow against
+//     Entra ID with the client secret from the credential store
+//
+// The container is configured with the App Service managed identity variables
+// (IDENTITY_ENDPOINT/IDENTITY_HEADER, and MSI_ENDPOINT/MSI_SECRET for older
+// clients), so Azure SDKs and `az login --identity` pick up tokens without
+// any secret in the container.
+//
+// Grant flow (az CLI):
+//  1. User runs `moat grant azure` with an active `az login` on the host,
+//     or with --use-device-code to sign in first
+//  2. The account is checked with `az account show`
+//  3. Tenant ID stored in Credential.Token, tenant/subscription in Metadata
+//
+// Grant flow (service principal):
+//  1. User runs `moat grant azure --client-id ID --tenant T`
+//  2. The client secret is read from AZURE_CLIENT_SECRET or a prompt and
+//     validated by requesting an Azure Resource Manager token
+//  3. Client secret stored in Credential.Token, tenant/client ID in Metadata
+//
+// Runtime flow:
+//  1. Azure SDK in the container requests a token from IDENTITY_ENDPOINT
+//  2. The request goes through the proxy, which hands it to the run's
+//     credential endpoint handler
+//  3. The daemon returns a cached token or fetches a new one
+package azure
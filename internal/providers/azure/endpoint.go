@@ -0,0 +1,209 @@
+package azure
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/majorcontext/moat/internal/log"
+)
+
+// EndpointPath is where the token endpoint is served on the proxy.
+// Gatekeeper hands every request under /_aws/credentials to the run's
+// credential endpoint handler, so the Azure endpoint lives beneath it.
+const EndpointPath = "/_aws/credentials/azure"
+
+// tokenRefreshBuffer is the time before expiration when a cached token is
+// replaced. Azure SDKs refresh on their own once a token is within five
+// minutes of expiring, so handing out one closer than that causes a loop.
+const tokenRefreshBuffer = 10 * time.Minute
+
+// Token is an access token for one audience.
+type Token struct {
+	AccessToken string
+	ExpiresOn   time.Time
+}
+
+// azToken is the subset of `az account get-access-token` output moat uses.
+// expires_on (Unix seconds) is only present in az 2.54 and later; older
+// versions report expiresOn in local time.
+type azToken struct {
+	AccessToken string `json:"accessToken"`
+	ExpiresOn   string `json:"expiresOn"`
+	ExpiresOnTS int64  `json:"expires_on"`
+}
+
+// fetchToken obtains a token for resource: from Entra ID directly for a
+// service principal, otherwise from the host az CLI.
+func fetchToken(ctx context.Context, cfg Config, resource string) (*Token, error) {
+	if cfg.ClientID != "" {
+		return fetchServicePrincipalToken(ctx, cfg, resource)
+	}
+	args := []string{"account", "get-access-token", "--output", "json", "--resource=" + resource}
+	if cfg.Subscription != "" {
+		args = append(args, "--subscription="+cfg.Subscription)
+	} else if cfg.Tenant != "" {
+		args = append(args, "--tenant="+cfg.Tenant)
+	}
+	out, err := runAz(ctx, args...)
+	if err != nil {
+		return nil, err
+	}
+	var t azToken
+	if err := json.Unmarshal(out, &t); err != nil {
+		return nil, fmt.Errorf("parsing az get-access-token output: %w", err)
+	}
+	if t.AccessToken == "" {
+		return nil, fmt.Errorf("az returned an empty token for %s", resource)
+	}
+	tok := &Token{AccessToken: t.AccessToken}
+	if t.ExpiresOnTS > 0 {
+		tok.ExpiresOn = time.Unix(t.ExpiresOnTS, 0)
+	} else if tok.ExpiresOn, err = time.ParseInLocation("2006-01-02 15:04:05.999999", t.ExpiresOn, time.Local); err != nil {
+		return nil, fmt.Errorf("parsing token expiry %q: %w", t.ExpiresOn, err)
+	}
+	return tok, nil
+}
+
+// EndpointHandler serves tokens in the App Service managed identity format
+// (api-version 2019-08-01, and 2017-09-01 for MSI_ENDPOINT clients).
+type EndpointHandler struct {
+	cfg       Config
+	authToken string // Required value of X-IDENTITY-HEADER / Secret
+
+	mu     sync.Mutex
+	cached map[string]*Token // by resource
+
+	// fetch obtains a token for a resource (injectable for testing)
+	fetch func(ctx context.Context, resource string) (*Token, error)
+}
+
+// NewEndpointHandler creates a token endpoint handler for cfg.
+func NewEndpointHandler(cfg Config) *EndpointHandler {
+	h := &EndpointHandler{cfg: cfg, cached: make(map[string]*Token)}
+	h.fetch = func(ctx context.Context, resource string) (*Token, error) {
+		return fetchToken(ctx, h.cfg, resource)
+	}
+	return h
+}
+
+// SetAuthToken sets the secret the container must present in
+// X-IDENTITY-HEADER (or Secret, for 2017-09-01 clients).
+func (h *EndpointHandler) SetAuthToken(token string) {
+	h.authToken = token
+}
+
+// SetFetcher replaces how tokens are obtained (for testing).
+func (h *EndpointHandler) SetFetcher(fetch func(ctx context.Context, resource string) (*Token, error)) {
+	h.fetch = fetch
+}
+
+// ServeHTTP implements http.Handler.
+func (h *EndpointHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.authToken != "" {
+		secret := r.Header.Get("X-IDENTITY-HEADER")
+		if secret == "" {
+			secret = r.Header.Get("Secret")
+		}
+		// Use constant-time comparison to prevent timing attacks
+		if secret == "" || subtle.ConstantTimeCompare([]byte(secret), []byte(h.authToken)) != 1 {
+			writeError(w, http.StatusUnauthorized, "invalid_request", "missing or invalid X-IDENTITY-HEADER")
+			return
+		}
+	}
+
+	// Cloud Shell-style clients POST the resource as a form field.
+	if err := r.ParseForm(); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request", "malformed request")
+		return
+	}
+	resource := r.Form.Get("resource")
+	if resource == "" {
+		// MSAL clients may pass a scope ("https://vault.azure.net/.default").
+		resource = strings.TrimSuffix(r.Form.Get("scope"), "/.default")
+	}
+	if resource == "" || strings.ContainsAny(resource, " \t\r\n") {
+		writeError(w, http.StatusBadRequest, "invalid_resource", "the resource parameter is required")
+		return
+	}
+	if r.Form.Get("client_id") != "" || r.Form.Get("mi_res_id") != "" || r.Form.Get("object_id") != "" {
+		log.Debug("azure token request names a user-assigned identity; serving the granted identity", "resource", resource)
+	}
+
+	tok, err := h.getToken(r.Context(), resource)
+	if err != nil {
+		log.Error("Azure token fetch error", "error", err, "resource", resource)
+		writeError(w, http.StatusInternalServerError, "token_unavailable", classifyAzureError(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]string{
+		"access_token": tok.AccessToken,
+		"expires_on":   strconv.FormatInt(tok.ExpiresOn.Unix(), 10),
+		"resource":     resource,
+		"token_type":   "Bearer",
+	})
+}
+
+// getToken returns a cached token for resource or fetches a new one.
+func (h *EndpointHandler) getToken(ctx context.Context, resource string) (*Token, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	// Held across the fetch so concurrent requests for a cold audience run
+	// az once.
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if tok := h.cached[resource]; tok != nil && time.Now().Add(tokenRefreshBuffer).Before(tok.ExpiresOn) {
+		return tok, nil
+	}
+	tok, err := h.fetch(ctx, resource)
+	if err != nil {
+		return nil, err
+	}
+	h.cached[resource] = tok
+	return tok, nil
+}
+
+// writeError writes an error body in the shape managed identity clients expect.
+func writeError(w http.ResponseWriter, status int, code, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]string{
+		"error":             code,
+		"error_description": msg,
+	})
+}
+
+// classifyAzureError returns an actionable message for a failed token fetch.
+// The full error is logged by the daemon.
+func classifyAzureError(err error) string {
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "no client secret"):
+		return "Azure credential error: the service principal's client secret could not be loaded. Run 'moat grant azure --client-id ...' again, then restart the run."
+	case strings.Contains(msg, "AADSTS7000215") || strings.Contains(msg, "AADSTS7000222"):
+		return "Azure credential error: the service principal's client secret is invalid or expired. Create a new secret and run 'moat grant azure --client-id ...' again."
+	case strings.Contains(msg, "AADSTS700016"):
+		return "Azure credential error: the service principal was not found in the tenant. Check the client ID and tenant of the azure grant."
+	case strings.Contains(msg, "az login") || strings.Contains(msg, "AADSTS700082") || strings.Contains(msg, "AADSTS50173"):
+		return "Azure credential error: the host's az login session has expired or is missing. Run 'az login' on your host and retry."
+	case strings.Contains(msg, "executable file not found"):
+		return "Azure credential error: the az CLI is not installed on the host running the moat daemon."
+	case strings.Contains(msg, "AADSTS500011") || strings.Contains(msg, "AADSTS65001"):
+		return "Azure credential error: the requested resource is not available to the host account. Check the resource URI."
+	case strings.Contains(msg, "context deadline exceeded") || strings.Contains(msg, "context canceled"):
+		return "Azure credential error: request canceled or timed out. Retry or check network connectivity."
+	case strings.HasPrefix(msg, "token request"):
+		return "Azure credential error: Entra ID did not issue a token for the service principal. Check the daemon log for details."
+	default:
+		return "Azure credential error: the host az CLI could not issue a token. Run 'az account get-access-token' on your host to diagnose."
+	}
+}
@@ -0,0 +1,155 @@
+package azure
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFetchToken(t *testing.T) {
+	var gotArgs []string
+	old := runAz
+	defer func() { runAz = old }()
+
+	runAz = func(ctx context.Context, args ...string) ([]byte, error) {
+		gotArgs = args
+		return []byte(`{"accessToken":"tok","expiresOn":"2030-01-02 03:04:05.000000","expires_on":1893553445}`), nil
+	}
+	tok, err := fetchToken(context.Background(), Config{Tenant: "t1"}, "https://vault.azure.net")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tok.AccessToken != "tok" || tok.ExpiresOn.Unix() != 1893553445 {
+		t.Errorf("token = %+v", tok)
+	}
+	if !slices.Contains(gotArgs, "--resource=https://vault.azure.net") || !slices.Contains(gotArgs, "--tenant=t1") {
+		t.Errorf("az args = %v", gotArgs)
+	}
+
+	// Older az versions only report expiresOn in local time.
+	runAz = func(ctx context.Context, args ...string) ([]byte, error) {
+		gotArgs = args
+		return []byte(`{"accessToken":"tok","expiresOn":"2030-01-02 03:04:05.000000"}`), nil
+	}
+	tok, err = fetchToken(context.Background(), Config{Tenant: "t1", Subscription: "s1"}, "https://management.azure.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2030, 1, 2, 3, 4, 5, 0, time.Local); !tok.ExpiresOn.Equal(want) {
+		t.Errorf("ExpiresOn = %v, want %v", tok.ExpiresOn, want)
+	}
+	if !slices.Contains(gotArgs, "--subscription=s1") || slices.Contains(gotArgs, "--tenant=t1") {
+		t.Errorf("az args with subscription = %v", gotArgs)
+	}
+}
+
+func newTestHandler(fetches *[]string) *EndpointHandler {
+	h := NewEndpointHandler(Config{Tenant: "t1"})
+	h.SetAuthToken("secret")
+	h.SetFetcher(func(ctx context.Context, resource string) (*Token, error) {
+		*fetches = append(*fetches, resource)
+		if resource == "https://broken.example.com" {
+			return nil, errors.New("az account: Please run 'az login' to setup account.")
+		}
+		return &Token{AccessToken: "tok-for-" + resource, ExpiresOn: time.Now().Add(time.Hour)}, nil
+	})
+	return h
+}
+
+func TestEndpointHandler(t *testing.T) {
+	var fetches []string
+	h := newTestHandler(&fetches)
+
+	get := func(header, secret, resource string) *httptest.ResponseRecorder {
+		q := url.Values{"api-version": {"2019-08-01"}, "resource": {resource}}
+		req := httptest.NewRequest("GET", EndpointPath+"?"+q.Encode(), nil)
+		if header != "" {
+			req.Header.Set(header, secret)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := get("X-IDENTITY-HEADER", "secret", "https://management.azure.com/")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+	}
+	var resp map[string]string
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp["access_token"] != "tok-for-https://management.azure.com/" || resp["token_type"] != "Bearer" || resp["expires_on"] == "" {
+		t.Errorf("response = %v", resp)
+	}
+
+	// Cached per audience: a second request for the same resource does not
+	// call az again, a different resource does.
+	get("X-IDENTITY-HEADER", "secret", "https://management.azure.com/")
+	get("Secret", "secret", "https://vault.azure.net")
+	if len(fetches) != 2 {
+		t.Errorf("fetches = %v, want one per audience", fetches)
+	}
+
+	for _, tc := range []struct{ header, secret string }{{"", ""}, {"X-IDENTITY-HEADER", "wrong"}} {
+		if rec := get(tc.header, tc.secret, "https://vault.azure.net"); rec.Code != http.StatusUnauthorized {
+			t.Errorf("%s=%q: status = %d, want 401", tc.header, tc.secret, rec.Code)
+		}
+	}
+	if rec := get("X-IDENTITY-HEADER", "secret", ""); rec.Code != http.StatusBadRequest {
+		t.Errorf("missing resource: status = %d, want 400", rec.Code)
+	}
+	rec = get("X-IDENTITY-HEADER", "secret", "https://broken.example.com")
+	if rec.Code != http.StatusInternalServerError || !strings.Contains(rec.Body.String(), "az login") {
+		t.Errorf("az failure: status = %d, body %s", rec.Code, rec.Body)
+	}
+}
+
+func TestEndpointHandler_FormAndScope(t *testing.T) {
+	var fetches []string
+	h := newTestHandler(&fetches)
+
+	req := httptest.NewRequest("POST", EndpointPath, strings.NewReader("resource=https%3A%2F%2Fstorage.azure.com"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("X-IDENTITY-HEADER", "secret")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("POST form: status = %d, body %s", rec.Code, rec.Body)
+	}
+
+	req = httptest.NewRequest("GET", EndpointPath+"?scope="+url.QueryEscape("https://vault.azure.net/.default"), nil)
+	req.Header.Set("X-IDENTITY-HEADER", "secret")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("scope: status = %d, body %s", rec.Code, rec.Body)
+	}
+	if want := []string{"https://storage.azure.com", "https://vault.azure.net"}; !slices.Equal(fetches, want) {
+		t.Errorf("fetches = %v, want %v", fetches, want)
+	}
+}
+
+func TestEndpointHandler_RefreshesNearExpiry(t *testing.T) {
+	h := NewEndpointHandler(Config{Tenant: "t1"})
+	calls := 0
+	h.SetFetcher(func(ctx context.Context, resource string) (*Token, error) {
+		calls++
+		return &Token{AccessToken: "tok", ExpiresOn: time.Now().Add(5 * time.Minute)}, nil
+	})
+	for i := 0; i < 2; i++ {
+		if _, err := h.getToken(context.Background(), "https://management.azure.com/"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if calls != 2 {
+		t.Errorf("calls = %d, want a refetch for a token inside the refresh buffer", calls)
+	}
+}
@@ -0,0 +1,292 @@
+package azure
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/majorcontext/moat/internal/provider"
+	"github.com/majorcontext/moat/internal/provider/util"
+)
+
+// Metadata keys for Azure credentials.
+const (
+	MetaKeyTenant       = "tenant"
+	MetaKeySubscription = "subscription"
+	MetaKeyUser         = "user"
+	MetaKeyClientID     = "client_id"
+)
+
+// Context keys for passing grant options from CLI.
+type ctxKey string
+
+const (
+	ctxKeyTenant        ctxKey = "azure_tenant"
+	ctxKeySubscription  ctxKey = "azure_subscription"
+	ctxKeyClientID      ctxKey = "azure_client_id"
+	ctxKeyUseDeviceCode ctxKey = "azure_use_device_code"
+)
+
+// GrantOptions are the `moat grant azure` flags.
+type GrantOptions struct {
+	Tenant        string
+	Subscription  string
+	ClientID      string // service principal application (client) ID
+	UseDeviceCode bool   // run 'az login --use-device-code' first
+}
+
+// WithGrantOptions returns a context with Azure grant options set.
+func WithGrantOptions(ctx context.Context, opts GrantOptions) context.Context {
+	ctx = context.WithValue(ctx, ctxKeyTenant, opts.Tenant)
+	ctx = context.WithValue(ctx, ctxKeySubscription, opts.Subscription)
+	ctx = context.WithValue(ctx, ctxKeyClientID, opts.ClientID)
+	ctx = context.WithValue(ctx, ctxKeyUseDeviceCode, opts.UseDeviceCode)
+	return ctx
+}
+
+// Config selects the identity tokens are requested for. With ClientID set,
+// tokens come from Entra ID for that service principal; otherwise from the
+// host's az CLI session.
+type Config struct {
+	Tenant       string // Entra ID tenant ID
+	Subscription string // optional; pins tokens to this subscription's account
+	ClientID     string // service principal application (client) ID
+	ClientSecret string // service principal client secret
+}
+
+// ConfigFromCredential extracts the Azure configuration from a stored credential.
+// Service principal credentials keep the client secret in Credential.Token;
+// az CLI credentials keep the tenant there.
+func ConfigFromCredential(cred *provider.Credential) (*Config, error) {
+	if cred == nil {
+		return nil, fmt.Errorf("credential is nil")
+	}
+	cfg := &Config{}
+	if cred.Metadata != nil {
+		cfg.Tenant = cred.Metadata[MetaKeyTenant]
+		cfg.Subscription = cred.Metadata[MetaKeySubscription]
+		cfg.ClientID = cred.Metadata[MetaKeyClientID]
+	}
+	if cfg.ClientID != "" {
+		cfg.ClientSecret = cred.Token
+		if cfg.ClientSecret == "" {
+			return nil, fmt.Errorf("azure service principal credential has no client secret")
+		}
+	} else if cfg.Tenant == "" {
+		cfg.Tenant = cred.Token
+	}
+	if cfg.Tenant == "" {
+		return nil, fmt.Errorf("azure credential has no tenant")
+	}
+	return cfg, nil
+}
+
+// account is the subset of `az account show` output moat uses.
+type account struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	TenantID string `json:"tenantId"`
+	User     struct {
+		Name string `json:"name"`
+	} `json:"user"`
+}
+
+// runAz runs the host az CLI and returns its stdout. A variable so tests can
+// replace it.
+var runAz = func(ctx context.Context, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "az", args...)
+	out, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+			return nil, fmt.Errorf("az %s: %s", args[0], strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return nil, fmt.Errorf("az %s: %w", args[0], err)
+	}
+	return out, nil
+}
+
+// runAzInteractive runs the host az CLI attached to the terminal. A variable
+// so tests can replace it.
+var runAzInteractive = func(ctx context.Context, args ...string) error {
+	cmd := exec.CommandContext(ctx, "az", args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("az %s: %w", args[0], err)
+	}
+	return nil
+}
+
+// grant records a service principal when a client ID is given (by flag, or by
+// AZURE_CLIENT_ID with --from-env), and otherwise the host's az CLI account.
+func grant(ctx context.Context) (*provider.Credential, error) {
+	clientID, _ := ctx.Value(ctxKeyClientID).(string)
+	if clientID == "" && util.GrantOptionsFrom(ctx).EnvOnly {
+		clientID = os.Getenv("AZURE_CLIENT_ID")
+	}
+	if clientID != "" {
+		if deviceCode, _ := ctx.Value(ctxKeyUseDeviceCode).(bool); deviceCode {
+			return nil, &provider.GrantError{
+				Provider: "azure",
+				Cause:    fmt.Errorf("--use-device-code and --client-id cannot be combined"),
+				Hint:     "Device-code login signs in a user; a service principal authenticates with its client secret",
+			}
+		}
+		return grantServicePrincipal(ctx, clientID)
+	}
+	return grantAzLogin(ctx)
+}
+
+// grantServicePrincipal validates a service principal's client secret by
+// requesting a token with it. The secret is read from AZURE_CLIENT_SECRET or
+// an interactive prompt.
+func grantServicePrincipal(ctx context.Context, clientID string) (*provider.Credential, error) {
+	tenant, _ := ctx.Value(ctxKeyTenant).(string)
+	subscription, _ := ctx.Value(ctxKeySubscription).(string)
+	if tenant == "" {
+		tenant = os.Getenv("AZURE_TENANT_ID")
+	}
+	if tenant == "" {
+		return nil, &provider.GrantError{
+			Provider: "azure",
+			Cause:    fmt.Errorf("a tenant is required for a service principal"),
+			Hint:     "Pass --tenant or set AZURE_TENANT_ID",
+		}
+	}
+
+	secret := os.Getenv("AZURE_CLIENT_SECRET")
+	if secret != "" {
+		fmt.Println("Using client secret from AZURE_CLIENT_SECRET environment variable")
+	} else {
+		if err := util.RequireInput(ctx, "set AZURE_CLIENT_SECRET"); err != nil {
+			return nil, err
+		}
+		var err error
+		secret, err = util.PromptForToken(ctx, "Client secret for "+clientID)
+		if err != nil {
+			return nil, fmt.Errorf("reading client secret: %w", err)
+		}
+		if secret == "" {
+			return nil, &provider.GrantError{
+				Provider: "azure",
+				Cause:    fmt.Errorf("no client secret provided"),
+				Hint:     "Create one with 'az ad app credential reset --id " + clientID + "'",
+			}
+		}
+	}
+
+	fmt.Println("Validating service principal...")
+	cfg := Config{Tenant: tenant, ClientID: clientID, ClientSecret: secret}
+	if _, err := fetchServicePrincipalToken(ctx, cfg, "https://management.azure.com/"); err != nil {
+		return nil, &provider.GrantError{
+			Provider: "azure",
+			Cause:    err,
+			Hint:     "Check the client ID, tenant, and client secret",
+		}
+	}
+	fmt.Printf("Using service principal %s (tenant %s)\n", clientID, tenant)
+
+	meta := map[string]string{
+		MetaKeyTenant:   tenant,
+		MetaKeyClientID: clientID,
+	}
+	if subscription != "" {
+		meta[MetaKeySubscription] = subscription
+	}
+	return &provider.Credential{
+		Provider:  "azure",
+		Token:     secret,
+		CreatedAt: time.Now(),
+		Metadata:  meta,
+	}, nil
+}
+
+// grantAzLogin verifies the host's az CLI session and records which account
+// to use. With --use-device-code it signs in first.
+func grantAzLogin(ctx context.Context) (*provider.Credential, error) {
+	tenant, _ := ctx.Value(ctxKeyTenant).(string)
+	subscription, _ := ctx.Value(ctxKeySubscription).(string)
+	deviceCode, _ := ctx.Value(ctxKeyUseDeviceCode).(bool)
+
+	if _, err := exec.LookPath("az"); err != nil {
+		return nil, &provider.GrantError{
+			Provider: "azure",
+			Cause:    fmt.Errorf("az CLI not found"),
+			Hint:     "Install the Azure CLI (https://aka.ms/azure-cli) and run 'az login'",
+		}
+	}
+
+	if deviceCode {
+		if err := util.RequireInput(ctx, "device-code login needs someone to complete it in a browser; use a service principal (--client-id) instead"); err != nil {
+			return nil, err
+		}
+		args := []string{"login", "--use-device-code"}
+		if tenant != "" {
+			args = append(args, "--tenant="+tenant)
+		}
+		if err := runAzInteractive(ctx, args...); err != nil {
+			return nil, &provider.GrantError{Provider: "azure", Cause: err}
+		}
+	}
+
+	args := []string{"account", "show", "--output", "json"}
+	if subscription != "" {
+		args = append(args, "--subscription="+subscription)
+	}
+	out, err := runAz(ctx, args...)
+	if err != nil {
+		return nil, &provider.GrantError{
+			Provider: "azure",
+			Cause:    err,
+			Hint:     "Run 'az login' on this machine, then retry",
+		}
+	}
+	var acct account
+	if err := json.Unmarshal(out, &acct); err != nil {
+		return nil, fmt.Errorf("parsing az account show output: %w", err)
+	}
+	if tenant != "" && !strings.EqualFold(tenant, acct.TenantID) && subscription != "" {
+		return nil, &provider.GrantError{
+			Provider: "azure",
+			Cause:    fmt.Errorf("subscription %s belongs to tenant %s, not %s", subscription, acct.TenantID, tenant),
+		}
+	}
+	if tenant == "" {
+		tenant = acct.TenantID
+	}
+
+	// Confirm the session can mint tokens, not just list accounts.
+	fmt.Println("Validating az login...")
+	cfg := Config{Tenant: tenant, Subscription: subscription}
+	if _, err := fetchToken(ctx, cfg, "https://management.azure.com/"); err != nil {
+		return nil, &provider.GrantError{
+			Provider: "azure",
+			Cause:    err,
+			Hint:     "Run 'az login' (or 'az login --tenant " + tenant + "') and retry",
+		}
+	}
+
+	fmt.Printf("Using Azure account %s (tenant %s)\n", acct.User.Name, tenant)
+	if subscription != "" {
+		fmt.Printf("Subscription: %s (%s)\n", acct.Name, acct.ID)
+	}
+
+	meta := map[string]string{
+		MetaKeyTenant: tenant,
+		MetaKeyUser:   acct.User.Name,
+	}
+	if subscription != "" {
+		meta[MetaKeySubscription] = subscription
+	}
+	return &provider.Credential{
+		Provider:  "azure",
+		Token:     tenant,
+		CreatedAt: time.Now(),
+		Metadata:  meta,
+	}, nil
+}
@@ -0,0 +1,70 @@
+package azure
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/majorcontext/moat/internal/provider"
+	"github.com/majorcontext/moat/internal/ui"
+)
+
+// Provider implements provider.CredentialProvider and provider.EndpointProvider
+// for Azure credentials from the host's az CLI session.
+type Provider struct{}
+
+// Compile-time interface assertions.
+var (
+	_ provider.CredentialProvider = (*Provider)(nil)
+	_ provider.EndpointProvider   = (*Provider)(nil)
+)
+
+func init() {
+	provider.Register(&Provider{})
+}
+
+// Name returns the provider identifier.
+func (p *Provider) Name() string {
+	return "azure"
+}
+
+// Grant checks the host's az CLI login and records its tenant.
+func (p *Provider) Grant(ctx context.Context) (*provider.Credential, error) {
+	return grant(ctx)
+}
+
+// ConfigureProxy is a no-op for Azure since it uses the endpoint pattern.
+// Tokens are served via the credential endpoint, not header injection.
+func (p *Provider) ConfigureProxy(pc provider.ProxyConfigurer, cred *provider.Credential) {
+	// No-op: Azure uses credential endpoint, not proxy header injection
+}
+
+// ContainerEnv returns nil; the run manager sets IDENTITY_ENDPOINT and
+// MSI_ENDPOINT, which depend on the run's proxy token.
+func (p *Provider) ContainerEnv(cred *provider.Credential) []string {
+	return nil
+}
+
+// ContainerMounts returns nil; Azure doesn't require any mounts.
+func (p *Provider) ContainerMounts(cred *provider.Credential, containerHome string) ([]provider.MountConfig, string, error) {
+	return nil, "", nil
+}
+
+// Cleanup is a no-op for Azure.
+func (p *Provider) Cleanup(cleanupPath string) {
+	// No cleanup needed
+}
+
+// ImpliedDependencies returns dependencies implied by the Azure grant.
+func (p *Provider) ImpliedDependencies() []string {
+	return []string{"az"}
+}
+
+// RegisterEndpoints registers the Azure token endpoint handler.
+func (p *Provider) RegisterEndpoints(mux *http.ServeMux, cred *provider.Credential) {
+	cfg, err := ConfigFromCredential(cred)
+	if err != nil {
+		ui.Warnf("Failed to parse Azure config from credential: %v", err)
+		return
+	}
+	mux.Handle(EndpointPath, NewEndpointHandler(*cfg))
+}
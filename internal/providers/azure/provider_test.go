@@ -0,0 +1,91 @@
+package azure
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/majorcontext/moat/internal/provider"
+	"github.com/majorcontext/moat/internal/provider/util"
+)
+
+func TestConfigFromCredential(t *testing.T) {
+	cfg, err := ConfigFromCredential(&provider.Credential{
+		Token:    "t1",
+		Metadata: map[string]string{MetaKeySubscription: "s1"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Tenant != "t1" || cfg.Subscription != "s1" {
+		t.Errorf("config = %+v", cfg)
+	}
+	if _, err := ConfigFromCredential(&provider.Credential{}); err == nil {
+		t.Error("expected error for credential without tenant")
+	}
+
+	cfg, err = ConfigFromCredential(&provider.Credential{
+		Token:    "s3cret",
+		Metadata: map[string]string{MetaKeyTenant: "t1", MetaKeyClientID: "app-1"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Tenant != "t1" || cfg.ClientID != "app-1" || cfg.ClientSecret != "s3cret" {
+		t.Errorf("service principal config = %+v", cfg)
+	}
+}
+
+func TestGrantServicePrincipal(t *testing.T) {
+	fakeAuthority(t)
+	t.Setenv("AZURE_TENANT_ID", "t1")
+	t.Setenv("AZURE_CLIENT_SECRET", "s3cret")
+
+	ctx := WithGrantOptions(context.Background(), GrantOptions{ClientID: "app-1", Subscription: "s1"})
+	cred, err := grant(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cred.Token != "s3cret" || cred.Metadata[MetaKeyClientID] != "app-1" ||
+		cred.Metadata[MetaKeyTenant] != "t1" || cred.Metadata[MetaKeySubscription] != "s1" {
+		t.Errorf("credential = %+v", cred)
+	}
+
+	// --from-env picks the service principal up from AZURE_CLIENT_ID.
+	t.Setenv("AZURE_CLIENT_ID", "app-1")
+	envOnly := util.WithGrantOptions(context.Background(), util.GrantOptions{EnvOnly: true})
+	if cred, err := grant(envOnly); err != nil || cred.Metadata[MetaKeyClientID] != "app-1" {
+		t.Errorf("grant with --from-env: %+v, %v", cred, err)
+	}
+
+	t.Setenv("AZURE_CLIENT_SECRET", "wrong")
+	var ge *provider.GrantError
+	if _, err := grant(ctx); !errors.As(err, &ge) {
+		t.Errorf("bad secret: err = %v, want GrantError", err)
+	}
+
+	t.Setenv("AZURE_TENANT_ID", "")
+	if _, err := grant(ctx); err == nil {
+		t.Error("expected an error without a tenant")
+	}
+
+	ctx = WithGrantOptions(context.Background(), GrantOptions{ClientID: "app-1", Tenant: "t1", UseDeviceCode: true})
+	if _, err := grant(ctx); err == nil {
+		t.Error("expected an error combining --client-id and --use-device-code")
+	}
+}
+
+func TestGrantServicePrincipalNonInteractive(t *testing.T) {
+	t.Setenv("AZURE_CLIENT_SECRET", "")
+	ctx := util.WithGrantOptions(context.Background(), util.GrantOptions{NonInteractive: true})
+	ctx = WithGrantOptions(ctx, GrantOptions{ClientID: "app-1", Tenant: "t1"})
+	if _, err := grant(ctx); !errors.Is(err, util.ErrInputRequired) {
+		t.Errorf("err = %v, want ErrInputRequired", err)
+	}
+}
+
+func TestProvider_Registered(t *testing.T) {
+	if provider.GetEndpoint("azure") == nil {
+		t.Fatal("azure endpoint provider not registered")
+	}
+}
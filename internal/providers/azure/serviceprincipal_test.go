@@ -0,0 +1,74 @@
+package azure
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeAuthority serves an Entra ID token endpoint that accepts client
+// "app-1" with secret "s3cret" and records the last form it received.
+func fakeAuthority(t *testing.T) *http.Request {
+	t.Helper()
+	last := &http.Request{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		*last = *r
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path != "/t1/oauth2/v2.0/token" || r.PostForm.Get("client_secret") != "s3cret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error":"invalid_client","error_description":"AADSTS7000215: Invalid client secret provided.\r\nTrace ID: x"}`))
+			return
+		}
+		w.Write([]byte(`{"token_type":"Bearer","expires_in":3599,"access_token":"sp-token"}`))
+	}))
+	t.Cleanup(srv.Close)
+	old := authorityHost
+	authorityHost = srv.URL
+	t.Cleanup(func() { authorityHost = old })
+	return last
+}
+
+func TestFetchServicePrincipalToken(t *testing.T) {
+	last := fakeAuthority(t)
+	cfg := Config{Tenant: "t1", ClientID: "app-1", ClientSecret: "s3cret"}
+
+	// fetchToken dispatches to the client credentials flow when a client
+	// ID is set, without calling az.
+	old := runAz
+	defer func() { runAz = old }()
+	runAz = func(ctx context.Context, args ...string) ([]byte, error) {
+		t.Fatal("az should not be called for a service principal")
+		return nil, nil
+	}
+
+	tok, err := fetchToken(context.Background(), cfg, "https://management.azure.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tok.AccessToken != "sp-token" || time.Until(tok.ExpiresOn) < 59*time.Minute {
+		t.Errorf("token = %+v", tok)
+	}
+	form := last.PostForm
+	if form.Get("grant_type") != "client_credentials" || form.Get("client_id") != "app-1" ||
+		form.Get("scope") != "https://management.azure.com//.default" {
+		t.Errorf("token request form = %v", form)
+	}
+
+	cfg.ClientSecret = "wrong"
+	_, err = fetchToken(context.Background(), cfg, "https://vault.azure.net")
+	if err == nil || !strings.Contains(err.Error(), "AADSTS7000215") || strings.Contains(err.Error(), "Trace ID") {
+		t.Fatalf("bad secret: err = %v", err)
+	}
+	if msg := classifyAzureError(err); !strings.Contains(msg, "client secret") {
+		t.Errorf("classifyAzureError = %q", msg)
+	}
+
+	cfg.ClientSecret = ""
+	if _, err := fetchToken(context.Background(), cfg, "https://vault.azure.net"); err == nil || !strings.Contains(err.Error(), "moat grant azure") {
+		t.Errorf("missing secret: err = %v", err)
+	}
+}
@@ -0,0 +1,18 @@
+// Package azuredevops implements the Azure DevOps credential provider.
+//
+// The provider stores a personal access token (PAT) and the organizations it
+// was granted for. Tokens can be obtained from:
+//   - Environment variables (AZURE_DEVOPS_EXT_PAT, AZURE_DEVOPS_PAT)
+//   - Interactive PAT prompt
+//
+// The proxy injects Basic ":<pat>" auth, the scheme Azure DevOps uses for
+// both git smart-HTTP and the REST API, for dev.azure.com and its service
+// subdomains, and for each organization's legacy <org>.visualstudio.com
+// hosts. dev.azure.com is shared by every organization and the proxy matches
+// on host only, so the token's own organization scope is what keeps it from
+// authenticating elsewhere; grant validates it against each organization.
+//
+// Containers receive a placeholder AZURE_DEVOPS_EXT_PAT so the az devops CLI
+// does not prompt, while the real token is injected at the network layer.
+// PATs are static and are not refreshed.
+package azuredevops
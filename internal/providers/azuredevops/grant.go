@@ -0,0 +1,202 @@
+package azuredevops
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/majorcontext/moat/internal/provider"
+	"github.com/majorcontext/moat/internal/provider/util"
+)
+
+// ctxKeyOrgs is the context key for the --org grant flag.
+type ctxKeyOrgs struct{}
+
+// WithGrantOptions returns a context carrying the --org grant flag values.
+func WithGrantOptions(ctx context.Context, orgs []string) context.Context {
+	return context.WithValue(ctx, ctxKeyOrgs{}, orgs)
+}
+
+// orgPattern matches Azure DevOps organization names.
+var orgPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9-]{0,49}$`)
+
+// ParseOrganization accepts an organization name or URL
+// ("contoso", "https://dev.azure.com/contoso", "https://contoso.visualstudio.com")
+// and returns the organization name.
+func ParseOrganization(s string) (string, error) {
+	org := strings.TrimSpace(s)
+	if strings.Contains(org, "://") {
+		u, err := url.Parse(org)
+		if err != nil {
+			return "", fmt.Errorf("invalid organization URL %q: %w", s, err)
+		}
+		switch host := strings.ToLower(u.Hostname()); {
+		case host == "dev.azure.com":
+			org, _, _ = strings.Cut(strings.TrimPrefix(u.Path, "/"), "/")
+		case strings.HasSuffix(host, ".visualstudio.com"):
+			org = strings.TrimSuffix(host, ".visualstudio.com")
+		default:
+			return "", fmt.Errorf("invalid organization URL %q: expected dev.azure.com/<org> or <org>.visualstudio.com", s)
+		}
+	}
+	if !orgPattern.MatchString(org) {
+		return "", fmt.Errorf("invalid organization name %q", s)
+	}
+	return org, nil
+}
+
+// Grant acquires an Azure DevOps PAT from the environment or an interactive
+// prompt and validates it against each organization given with --org or
+// AZURE_DEVOPS_ORG.
+func (p *Provider) Grant(ctx context.Context) (*provider.Credential, error) {
+	rawOrgs, _ := ctx.Value(ctxKeyOrgs{}).([]string)
+	if len(rawOrgs) == 0 {
+		if env := os.Getenv("AZURE_DEVOPS_ORG"); env != "" {
+			rawOrgs = strings.Split(env, ",")
+		}
+	}
+	if len(rawOrgs) == 0 {
+		return nil, &provider.GrantError{
+			Provider: "azure-devops",
+			Cause:    fmt.Errorf("no organization given"),
+			Hint:     "Run 'moat grant azure-devops --org <organization>' or set AZURE_DEVOPS_ORG",
+		}
+	}
+	var orgs []string
+	for _, raw := range rawOrgs {
+		org, err := ParseOrganization(raw)
+		if err != nil {
+			return nil, &provider.GrantError{Provider: "azure-devops", Cause: err}
+		}
+		orgs = append(orgs, org)
+	}
+
+	token, name := util.CheckEnvVarWithName("AZURE_DEVOPS_EXT_PAT", "AZURE_DEVOPS_PAT")
+	source := SourceEnv
+	if token != "" {
+		fmt.Printf("Using token from %s environment variable\n", name)
+	} else {
+		if err := util.RequireInput(ctx, "set AZURE_DEVOPS_EXT_PAT or AZURE_DEVOPS_PAT"); err != nil {
+			return nil, err
+		}
+		source = SourcePAT
+		fmt.Printf(`Enter an Azure DevOps personal access token.
+
+To create one:
+  1. Visit https://dev.azure.com/%s/_usersSettings/tokens
+  2. Click "New Token" and select the organization (or all accessible organizations)
+  3. Grant the scopes the agent needs, e.g. "Code (Read & write)"
+  4. Copy the generated token
+`, orgs[0])
+		var err error
+		token, err = util.PromptForToken(ctx, "Token")
+		if err != nil {
+			return nil, fmt.Errorf("reading token: %w", err)
+		}
+		if token == "" {
+			return nil, &provider.GrantError{
+				Provider: "azure-devops",
+				Cause:    fmt.Errorf("no token provided"),
+				Hint:     "Run 'moat grant azure-devops --org <organization>' and enter a valid personal access token",
+			}
+		}
+	}
+
+	fmt.Println("Validating token...")
+	for _, org := range orgs {
+		user, err := validateToken(ctx, org, token)
+		if err != nil {
+			return nil, &provider.GrantError{
+				Provider: "azure-devops",
+				Cause:    fmt.Errorf("validation failed for organization %s: %w", org, err),
+				Hint:     "Ensure the token is not expired and is scoped to this organization",
+			}
+		}
+		fmt.Printf("Authenticated to %s as: %s\n", org, user)
+	}
+
+	return &provider.Credential{
+		Provider:  "azure-devops",
+		Token:     token,
+		CreatedAt: time.Now(),
+		Metadata: map[string]string{
+			provider.MetaKeyTokenSource: source,
+			MetaKeyOrganizations:        strings.Join(orgs, ","),
+		},
+	}, nil
+}
+
+// baseURL is the Azure DevOps service root. A variable so tests can point it
+// at a local server.
+var baseURL = "https://dev.azure.com"
+
+// connectionDataRequest builds a request for the organization's connection
+// data, which any valid token can read regardless of its scopes.
+func connectionDataRequest(org, token string) (*http.Request, error) {
+	req, err := http.NewRequest("GET", baseURL+"/"+org+"/_apis/connectionData", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(":"+token)))
+	// Without this, a rejected token gets a 203 sign-in page instead of a 401.
+	req.Header.Set("X-TFS-FedAuthRedirect", "Suppress")
+	req.Header.Set("User-Agent", "moat")
+	return req, nil
+}
+
+// validateToken checks token against org and returns the authenticated
+// user's display name.
+func validateToken(ctx context.Context, org, token string) (string, error) {
+	reqCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	req, err := connectionDataRequest(org, token)
+	if err != nil {
+		return "", fmt.Errorf("creating request: %w", err)
+	}
+
+	resp, err := http.DefaultClient.Do(req.WithContext(reqCtx))
+	if err != nil {
+		return "", fmt.Errorf("validating token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		var data struct {
+			AuthenticatedUser struct {
+				ProviderDisplayName string `json:"providerDisplayName"`
+			} `json:"authenticatedUser"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+			return "", fmt.Errorf("parsing connection data: %w", err)
+		}
+		return data.AuthenticatedUser.ProviderDisplayName, nil
+	case http.StatusUnauthorized:
+		return "", fmt.Errorf("invalid token (401 Unauthorized)")
+	case http.StatusNotFound:
+		return "", fmt.Errorf("organization not found (404)")
+	default:
+		return "", fmt.Errorf("unexpected status validating token: %d", resp.StatusCode)
+	}
+}
+
+// CheckCredential verifies the token against each granted organization.
+func (p *Provider) CheckCredential(ctx context.Context, cred *provider.Credential) error {
+	for _, org := range Organizations(cred) {
+		req, err := connectionDataRequest(org, cred.Token)
+		if err != nil {
+			return err
+		}
+		if err := util.ProbeCredential(ctx, req); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package azuredevops
+
+import (
+	"encoding/base64"
+	"path/filepath"
+	"strings"
+
+	"github.com/majorcontext/moat/internal/credential"
+	"github.com/majorcontext/moat/internal/provider"
+)
+
+// Token source values stored in Credential.Metadata[provider.MetaKeyTokenSource].
+const (
+	SourceEnv = "env" // From AZURE_DEVOPS_EXT_PAT/AZURE_DEVOPS_PAT env var
+	SourcePAT = "pat" // Interactive PAT entry
+)
+
+// MetaKeyOrganizations is the metadata key holding the comma-separated
+// organizations the token was granted for.
+const MetaKeyOrganizations = "organizations"
+
+// sharedHosts serve every organization under dev.azure.com: git and the core
+// REST API, identity (vssps), release management (vsrm), and Artifacts feeds.
+var sharedHosts = []string{
+	"dev.azure.com",
+	"vssps.dev.azure.com",
+	"vsrm.dev.azure.com",
+	"feeds.dev.azure.com",
+	"pkgs.dev.azure.com",
+}
+
+// Provider implements provider.CredentialProvider for Azure DevOps.
+type Provider struct{}
+
+// Verify interface compliance at compile time.
+var (
+	_ provider.CredentialProvider = (*Provider)(nil)
+	_ provider.InitFileProvider   = (*Provider)(nil)
+	_ provider.CredentialChecker  = (*Provider)(nil)
+)
+
+func init() {
+	provider.Register(&Provider{})
+}
+
+// Name returns the provider identifier.
+func (p *Provider) Name() string {
+	return "azure-devops"
+}
+
+// Organizations returns the organizations recorded on cred.
+func Organizations(cred *provider.Credential) []string {
+	if cred.Metadata == nil || cred.Metadata[MetaKeyOrganizations] == "" {
+		return nil
+	}
+	return strings.Split(cred.Metadata[MetaKeyOrganizations], ",")
+}
+
+// Hosts returns the hosts the proxy injects the token for.
+func Hosts(orgs []string) []string {
+	hosts := append([]string(nil), sharedHosts...)
+	for _, org := range orgs {
+		hosts = append(hosts,
+			org+".visualstudio.com",
+			org+".vssps.visualstudio.com",
+			org+".vsrm.visualstudio.com",
+		)
+	}
+	return hosts
+}
+
+// ConfigureProxy injects Basic auth for Azure DevOps hosts. Azure DevOps
+// takes a PAT as the password with an empty username, for git and REST alike.
+func (p *Provider) ConfigureProxy(proxy provider.ProxyConfigurer, cred *provider.Credential) {
+	basic := "Basic " + base64.StdEncoding.EncodeToString([]byte(":"+cred.Token))
+	for _, host := range Hosts(Organizations(cred)) {
+		proxy.SetCredentialWithGrant(host, "Authorization", basic, "azure-devops")
+	}
+}
+
+// ContainerEnv returns environment variables for Azure DevOps.
+//
+// AZURE_DEVOPS_EXT_PAT: Read by the az devops CLI extension, which sends it as
+// Basic auth. The proxy overwrites that header with the real token.
+//
+// GIT_TERMINAL_PROMPT: Set to 0 to disable interactive credential prompts from git.
+func (p *Provider) ContainerEnv(cred *provider.Credential) []string {
+	return []string{
+		"AZURE_DEVOPS_EXT_PAT=" + credential.ProxyInjectedPlaceholder,
+		"GIT_TERMINAL_PROMPT=0",
+	}
+}
+
+// ContainerInitFiles writes the az devops CLI defaults so commands run
+// against the granted organization without --organization. Only done when a
+// single organization was granted; with several there is no obvious default.
+func (p *Provider) ContainerInitFiles(cred *provider.Credential, containerHome string) map[string]string {
+	orgs := Organizations(cred)
+	if len(orgs) != 1 {
+		return nil
+	}
+	configPath := filepath.Join(containerHome, ".azure", "azuredevops", "config")
+	return map[string]string{
+		configPath: "[defaults]\norganization = https://dev.azure.com/" + orgs[0] + "\n",
+	}
+}
+
+// ContainerMounts returns no mounts — config is written via moat-init.sh.
+func (p *Provider) ContainerMounts(cred *provider.Credential, containerHome string) ([]provider.MountConfig, string, error) {
+	return nil, "", nil
+}
+
+// Cleanup is a no-op — no temp files are created.
+func (p *Provider) Cleanup(cleanupPath string) {}
+
+// ImpliedDependencies returns dependencies implied by this provider.
+func (p *Provider) ImpliedDependencies() []string {
+	return []string{"az", "git"}
+}
@@ -0,0 +1,123 @@
+package azuredevops
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/majorcontext/moat/internal/credential"
+	"github.com/majorcontext/moat/internal/provider"
+)
+
+type mockProxyConfigurer struct {
+	credentials map[string]string
+}
+
+func (m *mockProxyConfigurer) SetCredential(host, value string)                         {}
+func (m *mockProxyConfigurer) SetCredentialHeader(host, headerName, headerValue string) {}
+func (m *mockProxyConfigurer) SetCredentialWithGrant(host, headerName, headerValue, grant string) {
+	m.credentials[host] = headerName + ": " + headerValue
+}
+func (m *mockProxyConfigurer) AddExtraHeader(host, headerName, headerValue string)                {}
+func (m *mockProxyConfigurer) AddResponseTransformer(host string, t provider.ResponseTransformer) {}
+func (m *mockProxyConfigurer) RemoveRequestHeader(host, header string)                            {}
+func (m *mockProxyConfigurer) SetTokenSubstitution(host, placeholder, realToken string)           {}
+
+func testCred(token, orgs string) *provider.Credential {
+	return &provider.Credential{
+		Provider: "azure-devops",
+		Token:    token,
+		Metadata: map[string]string{MetaKeyOrganizations: orgs},
+	}
+}
+
+func TestProvider_ConfigureProxy(t *testing.T) {
+	proxy := &mockProxyConfigurer{credentials: map[string]string{}}
+	(&Provider{}).ConfigureProxy(proxy, testCred("pat123", "contoso,fabrikam"))
+
+	want := "Authorization: Basic " + base64.StdEncoding.EncodeToString([]byte(":pat123"))
+	for _, host := range []string{"dev.azure.com", "vssps.dev.azure.com", "contoso.visualstudio.com", "fabrikam.visualstudio.com"} {
+		if got := proxy.credentials[host]; got != want {
+			t.Errorf("credential for %s = %q, want %q", host, got, want)
+		}
+	}
+	if _, ok := proxy.credentials["other.visualstudio.com"]; ok {
+		t.Error("credential injected for an organization that was not granted")
+	}
+}
+
+func TestProvider_ContainerEnv(t *testing.T) {
+	env := (&Provider{}).ContainerEnv(testCred("pat123", "contoso"))
+	if !slices.Contains(env, "AZURE_DEVOPS_EXT_PAT="+credential.ProxyInjectedPlaceholder) {
+		t.Errorf("ContainerEnv() = %v, want placeholder AZURE_DEVOPS_EXT_PAT", env)
+	}
+	for _, e := range env {
+		if e == "AZURE_DEVOPS_EXT_PAT=pat123" {
+			t.Error("real token leaked into container env")
+		}
+	}
+}
+
+func TestProvider_ContainerInitFiles(t *testing.T) {
+	p := &Provider{}
+	files := p.ContainerInitFiles(testCred("pat123", "contoso"), "/home/moatuser")
+	want := "[defaults]\norganization = https://dev.azure.com/contoso\n"
+	if got := files["/home/moatuser/.azure/azuredevops/config"]; got != want {
+		t.Errorf("config = %q, want %q", got, want)
+	}
+	if files := p.ContainerInitFiles(testCred("pat123", "contoso,fabrikam"), "/home/moatuser"); files != nil {
+		t.Errorf("ContainerInitFiles() with two organizations = %v, want nil", files)
+	}
+}
+
+func TestParseOrganization(t *testing.T) {
+	tests := map[string]string{
+		"contoso":                            "contoso",
+		"https://dev.azure.com/contoso":      "contoso",
+		"https://dev.azure.com/contoso/proj": "contoso",
+		"https://contoso.visualstudio.com/":  "contoso",
+	}
+	for in, want := range tests {
+		if got, err := ParseOrganization(in); err != nil || got != want {
+			t.Errorf("ParseOrganization(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"", "-bad", "con toso", "https://example.com/contoso", "https://dev.azure.com/"} {
+		if _, err := ParseOrganization(in); err == nil {
+			t.Errorf("ParseOrganization(%q) succeeded, want error", in)
+		}
+	}
+}
+
+func TestProvider_CheckCredential(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/contoso/_apis/connectionData" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if _, pass, _ := r.BasicAuth(); pass != "good" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"authenticatedUser":{"providerDisplayName":"Alice"}}`))
+	}))
+	defer srv.Close()
+	old := baseURL
+	baseURL = srv.URL
+	defer func() { baseURL = old }()
+
+	p := &Provider{}
+	if err := p.CheckCredential(context.Background(), testCred("good", "contoso")); err != nil {
+		t.Errorf("CheckCredential(valid) = %v", err)
+	}
+	if err := p.CheckCredential(context.Background(), testCred("bad", "contoso")); !errors.Is(err, provider.ErrCredentialRejected) {
+		t.Errorf("CheckCredential(invalid) = %v, want ErrCredentialRejected", err)
+	}
+	if user, err := validateToken(context.Background(), "contoso", "good"); err != nil || user != "Alice" {
+		t.Errorf("validateToken() = %q, %v; want Alice", user, err)
+	}
+}
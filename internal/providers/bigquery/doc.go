@@ -0,0 +1,12 @@
+// Package bigquery implements the BigQuery credential provider.
+//
+// The provider is backed by Google application default credentials on the
+// host: an authorized_user file from 'gcloud auth application-default login'
+// or a service_account key file. The refresh token or service account key
+// stays in the encrypted credential store; the credential's Token is a
+// short-lived OAuth access token minted from it.
+//
+// The proxy injects the access token as a Bearer token for
+// bigquery.googleapis.com and re-mints it before it expires. Containers only
+// receive GOOGLE_CLOUD_PROJECT; no Google credential file is written.
+package bigquery
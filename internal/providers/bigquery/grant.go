@@ -0,0 +1,121 @@
+package bigquery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/majorcontext/moat/internal/provider"
+	"github.com/majorcontext/moat/internal/provider/util"
+	"github.com/majorcontext/moat/internal/providers/gcp"
+)
+
+// GrantOptions carries the grant flags.
+type GrantOptions struct {
+	CredentialsFile string // --credentials-file
+	Project         string // --project
+}
+
+// ctxKeyOptions is the context key for GrantOptions.
+type ctxKeyOptions struct{}
+
+// WithGrantOptions returns a context carrying the grant flags.
+func WithGrantOptions(ctx context.Context, opts GrantOptions) context.Context {
+	return context.WithValue(ctx, ctxKeyOptions{}, opts)
+}
+
+// apiBaseURL is the BigQuery API base URL. A variable so tests can point it
+// at a local server.
+var apiBaseURL = "https://" + Host
+
+// Grant reads application default credentials from --credentials-file,
+// GOOGLE_APPLICATION_CREDENTIALS, or gcloud's default location, mints an
+// access token, and validates it against the BigQuery API.
+func (p *Provider) Grant(ctx context.Context) (*provider.Credential, error) {
+	opts, _ := ctx.Value(ctxKeyOptions{}).(GrantOptions)
+	path := gcp.ADCPath(opts.CredentialsFile)
+	adc, err := gcp.ReadADC(path)
+	if err != nil {
+		var pathErr *fs.PathError
+		if !errors.As(err, &pathErr) {
+			return nil, &provider.GrantError{Provider: "bigquery", Cause: err}
+		}
+		return nil, &provider.GrantError{
+			Provider: "bigquery",
+			Cause:    err,
+			Hint: "Run 'gcloud auth application-default login', or pass a service account key with\n" +
+				"'moat grant bigquery --credentials-file <key.json>'",
+		}
+	}
+	meta, err := adc.Metadata()
+	if err != nil {
+		return nil, &provider.GrantError{
+			Provider: "bigquery",
+			Cause:    fmt.Errorf("%w in %s", err, path),
+			Hint:     "Use user credentials from 'gcloud auth application-default login' or a service account key file",
+		}
+	}
+
+	project := opts.Project
+	if project == "" {
+		project = os.Getenv("GOOGLE_CLOUD_PROJECT")
+	}
+	if project == "" {
+		project = adc.Project()
+	}
+	if project != "" {
+		meta[MetaKeyProject] = project
+	}
+
+	fmt.Printf("Using %s credentials from %s\n", adc.Type, path)
+	token, expiresAt, err := gcp.FetchAccessToken(ctx, meta, []string{Scope})
+	if err != nil {
+		return nil, &provider.GrantError{
+			Provider: "bigquery",
+			Cause:    fmt.Errorf("minting access token: %w", err),
+			Hint:     "Run 'gcloud auth application-default login' again, or check the service account key",
+		}
+	}
+
+	fmt.Println("Validating access to BigQuery...")
+	if err := validate(ctx, token, project); err != nil {
+		return nil, &provider.GrantError{
+			Provider: "bigquery",
+			Cause:    fmt.Errorf("validation failed: %w", err),
+			Hint:     "Ensure the BigQuery API is enabled and the account has a BigQuery role on the project",
+		}
+	}
+	if project != "" {
+		fmt.Printf("Default project: %s\n", project)
+	}
+
+	return &provider.Credential{
+		Provider:  "bigquery",
+		Token:     token,
+		ExpiresAt: expiresAt,
+		CreatedAt: time.Now(),
+		Metadata:  meta,
+	}, nil
+}
+
+// validate lists one dataset in project, or one project when none is set.
+func validate(ctx context.Context, token, project string) error {
+	endpoint := apiBaseURL + "/bigquery/v2/projects?maxResults=1"
+	if project != "" {
+		endpoint = apiBaseURL + "/bigquery/v2/projects/" + url.PathEscape(project) + "/datasets?maxResults=1"
+	}
+	req, err := http.NewRequest("GET", endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("User-Agent", "moat")
+	probeCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	return util.ProbeCredential(probeCtx, req, http.StatusUnauthorized, http.StatusForbidden)
+}
@@ -0,0 +1,122 @@
+package bigquery
+
+import (
+	"context"
+	"time"
+
+	gkproxy "github.com/majorcontext/gatekeeper/proxy"
+	"github.com/majorcontext/moat/internal/provider"
+	"github.com/majorcontext/moat/internal/providers/gcp"
+)
+
+// Host is the BigQuery REST API host the proxy injects tokens for.
+const Host = "bigquery.googleapis.com"
+
+// Metadata keys stored on the credential, shared with the gcp grant.
+// Secrets (refresh_token, client_secret, private_key) never leave the host.
+const (
+	MetaKeyAuthType     = gcp.MetaKeyAuthType
+	MetaKeyProject      = gcp.MetaKeyProject
+	MetaKeyClientEmail  = gcp.MetaKeyClientEmail
+	MetaKeyClientID     = gcp.MetaKeyClientID
+	MetaKeyClientSecret = gcp.MetaKeyClientSecret
+	MetaKeyRefreshToken = gcp.MetaKeyRefreshToken
+	MetaKeyPrivateKey   = gcp.MetaKeyPrivateKey
+	MetaKeyTokenURL     = gcp.MetaKeyTokenURL
+)
+
+// Application default credential types.
+const (
+	AuthTypeUser           = gcp.AuthTypeUser
+	AuthTypeServiceAccount = gcp.AuthTypeServiceAccount
+)
+
+// refreshBuffer is how long before expiry the access token is re-minted.
+const refreshBuffer = 10 * time.Minute
+
+// Provider implements provider.CredentialProvider for BigQuery.
+type Provider struct{}
+
+// Verify interface compliance at compile time.
+var (
+	_ provider.CredentialProvider  = (*Provider)(nil)
+	_ provider.RefreshableProvider = (*Provider)(nil)
+)
+
+func init() {
+	provider.Register(&Provider{})
+	// Declare the hosts the grant is used with, for grant scopes and
+	// network suggestions.
+	gkproxy.RegisterGrantHosts("bigquery", []string{Host})
+}
+
+// Name returns the provider identifier.
+func (p *Provider) Name() string {
+	return "bigquery"
+}
+
+// ConfigureProxy injects the access token for the BigQuery API.
+func (p *Provider) ConfigureProxy(proxy provider.ProxyConfigurer, cred *provider.Credential) {
+	proxy.SetCredentialWithGrant(Host, "Authorization", "Bearer "+cred.Token, "bigquery")
+}
+
+// CanRefresh reports whether cred carries a refresh token or service
+// account key to mint access tokens from.
+func (p *Provider) CanRefresh(cred *provider.Credential) bool {
+	switch cred.Metadata[MetaKeyAuthType] {
+	case AuthTypeUser:
+		return cred.Metadata[MetaKeyRefreshToken] != ""
+	case AuthTypeServiceAccount:
+		return cred.Metadata[MetaKeyPrivateKey] != ""
+	}
+	return false
+}
+
+// RefreshInterval returns how often to attempt refresh.
+// Google access tokens expire after 1 hour; refresh 15 minutes before.
+func (p *Provider) RefreshInterval() time.Duration {
+	return 45 * time.Minute
+}
+
+// Refresh mints a new access token when the current one is within
+// refreshBuffer of expiring, and updates the proxy.
+func (p *Provider) Refresh(ctx context.Context, proxy provider.ProxyConfigurer, cred *provider.Credential) (*provider.Credential, error) {
+	if !p.CanRefresh(cred) {
+		return nil, provider.ErrRefreshNotSupported
+	}
+	if time.Until(cred.ExpiresAt) > refreshBuffer {
+		return cred, nil
+	}
+
+	token, expiresAt, err := gcp.FetchAccessToken(ctx, cred.Metadata, []string{Scope})
+	if err != nil {
+		return nil, err
+	}
+	proxy.SetCredentialWithGrant(Host, "Authorization", "Bearer "+token, "bigquery")
+
+	updated := *cred
+	updated.Token = token
+	updated.ExpiresAt = expiresAt
+	return &updated, nil
+}
+
+// ContainerEnv sets the default project for client libraries and the bq CLI.
+func (p *Provider) ContainerEnv(cred *provider.Credential) []string {
+	if project := cred.Metadata[MetaKeyProject]; project != "" {
+		return []string{"GOOGLE_CLOUD_PROJECT=" + project}
+	}
+	return nil
+}
+
+// ContainerMounts returns no mounts.
+func (p *Provider) ContainerMounts(cred *provider.Credential, containerHome string) ([]provider.MountConfig, string, error) {
+	return nil, "", nil
+}
+
+// Cleanup is a no-op.
+func (p *Provider) Cleanup(cleanupPath string) {}
+
+// ImpliedDependencies returns dependencies implied by this provider.
+func (p *Provider) ImpliedDependencies() []string {
+	return nil
+}
@@ -0,0 +1,136 @@
+package bigquery
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/majorcontext/moat/internal/provider"
+	"github.com/majorcontext/moat/internal/providers/gcp/gcptest"
+)
+
+type mockProxyConfigurer struct {
+	credentials map[string]string
+}
+
+func (m *mockProxyConfigurer) SetCredential(host, value string)                         {}
+func (m *mockProxyConfigurer) SetCredentialHeader(host, headerName, headerValue string) {}
+func (m *mockProxyConfigurer) SetCredentialWithGrant(host, headerName, headerValue, grant string) {
+	m.credentials[host] = headerName + ": " + headerValue
+}
+func (m *mockProxyConfigurer) AddExtraHeader(host, headerName, headerValue string)                {}
+func (m *mockProxyConfigurer) AddResponseTransformer(host string, t provider.ResponseTransformer) {}
+func (m *mockProxyConfigurer) RemoveRequestHeader(host, header string)                            {}
+func (m *mockProxyConfigurer) SetTokenSubstitution(host, placeholder, realToken string)           {}
+
+func TestGrant(t *testing.T) {
+	keyPEM := gcptest.KeyPEM(t)
+	tokenSrv := gcptest.NewTokenServer(t, nil)
+	apiSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path != "/bigquery/v2/projects/analytics/datasets" {
+			t.Errorf("path = %s", r.URL.Path)
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer apiSrv.Close()
+	orig := apiBaseURL
+	apiBaseURL = apiSrv.URL
+	defer func() { apiBaseURL = orig }()
+	t.Setenv("GOOGLE_CLOUD_PROJECT", "")
+
+	tests := []struct {
+		name string
+		adc  map[string]string
+	}{
+		{"authorized_user", map[string]string{
+			"type": AuthTypeUser, "client_id": "cid", "client_secret": "cs", "refresh_token": "rt",
+			"quota_project_id": "analytics", "token_uri": tokenSrv.URL,
+		}},
+		{"service_account", map[string]string{
+			"type": AuthTypeServiceAccount, "client_email": "agent@analytics.iam.gserviceaccount.com",
+			"private_key": keyPEM, "project_id": "analytics", "token_uri": tokenSrv.URL,
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := WithGrantOptions(context.Background(), GrantOptions{CredentialsFile: gcptest.WriteADC(t, tt.adc)})
+			cred, err := (&Provider{}).Grant(ctx)
+			if err != nil {
+				t.Fatalf("Grant: %v", err)
+			}
+			if cred.Token == "" || cred.ExpiresAt.Before(time.Now()) {
+				t.Errorf("Token = %q, ExpiresAt = %v", cred.Token, cred.ExpiresAt)
+			}
+			if cred.Metadata[MetaKeyProject] != "analytics" || cred.Metadata[MetaKeyAuthType] != tt.name {
+				t.Errorf("Metadata = %v", cred.Metadata)
+			}
+		})
+	}
+
+	t.Run("unsupported type", func(t *testing.T) {
+		ctx := WithGrantOptions(context.Background(), GrantOptions{CredentialsFile: gcptest.WriteADC(t, map[string]string{"type": "external_account"})})
+		if _, err := (&Provider{}).Grant(ctx); err == nil {
+			t.Error("expected error for external_account credentials")
+		}
+	})
+}
+
+func TestProvider_Refresh(t *testing.T) {
+	var revoked bool
+	tokenSrv := gcptest.NewTokenServer(t, &revoked)
+	cred := &provider.Credential{
+		Token:     "at-old",
+		ExpiresAt: time.Now().Add(time.Hour),
+		Metadata: map[string]string{
+			MetaKeyAuthType: AuthTypeUser, MetaKeyRefreshToken: "rt", MetaKeyTokenURL: tokenSrv.URL,
+		},
+	}
+	p := &Provider{}
+	proxy := &mockProxyConfigurer{credentials: map[string]string{}}
+
+	updated, err := p.Refresh(context.Background(), proxy, cred)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Token != "at-old" || len(proxy.credentials) != 0 {
+		t.Errorf("token far from expiry was re-minted: %q", updated.Token)
+	}
+
+	cred.ExpiresAt = time.Now().Add(5 * time.Minute)
+	updated, err = p.Refresh(context.Background(), proxy, cred)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Token == "at-old" || proxy.credentials[Host] != "Authorization: Bearer "+updated.Token {
+		t.Errorf("Token = %q, proxy = %q", updated.Token, proxy.credentials[Host])
+	}
+
+	revoked = true
+	if _, err := p.Refresh(context.Background(), proxy, cred); !errors.Is(err, provider.ErrTokenRevoked) {
+		t.Errorf("Refresh with revoked token: err = %v, want ErrTokenRevoked", err)
+	}
+
+	if p.CanRefresh(&provider.Credential{Metadata: map[string]string{MetaKeyAuthType: AuthTypeUser}}) {
+		t.Error("CanRefresh without a refresh token")
+	}
+}
+
+func TestProvider_ContainerEnv(t *testing.T) {
+	env := (&Provider{}).ContainerEnv(&provider.Credential{Metadata: map[string]string{MetaKeyProject: "analytics"}})
+	if len(env) != 1 || env[0] != "GOOGLE_CLOUD_PROJECT=analytics" {
+		t.Errorf("ContainerEnv = %v", env)
+	}
+}
+
+func TestProvider_Registered(t *testing.T) {
+	if provider.Get("bigquery") == nil {
+		t.Fatal("bigquery provider not registered")
+	}
+}
@@ -0,0 +1,6 @@
+package bigquery
+
+// Scope is the OAuth scope requested for service account tokens. Tokens
+// from an authorized_user refresh token carry the scopes granted at
+// 'gcloud auth application-default login' (cloud-platform by default).
+const Scope = "https://www.googleapis.com/auth/bigquery"
@@ -0,0 +1,10 @@
+// Package bitbucket implements a credential provider for self-hosted
+// Bitbucket Server and Bitbucket Data Center instances (grant name
+// "bitbucket-server"). Bitbucket Cloud is not covered.
+//
+// Bitbucket Server accepts an HTTP access token (or password) with HTTP
+// Basic auth for both its REST API and git over HTTPS. The grant records each
+// instance's base URL with the username and token; the proxy injects Basic
+// auth for the instance's host, and the container gets a ~/.netrc with a
+// placeholder password so git does not prompt.
+package bitbucket
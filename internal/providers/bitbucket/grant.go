@@ -0,0 +1,22 @@
+package bitbucket
+
+import (
+	"context"
+
+	"github.com/majorcontext/moat/internal/provider"
+	"github.com/majorcontext/moat/internal/providers/githttp"
+)
+
+// Grant adds a Bitbucket Server instance to the credential.
+//
+// The instance URL comes from --url or BITBUCKET_SERVER_URL; the username and
+// token from BITBUCKET_SERVER_USERNAME and BITBUCKET_SERVER_TOKEN, or prompts.
+func (p *Provider) Grant(ctx context.Context) (*provider.Credential, error) {
+	return githttp.Grant(ctx, spec)
+}
+
+// checkInstance lists the caller's recently used repositories, which
+// requires authentication.
+func checkInstance(ctx context.Context, inst githttp.Instance) error {
+	return githttp.Probe(ctx, inst, "/rest/api/1.0/profile/recent/repos?limit=1")
+}
@@ -0,0 +1,77 @@
+package bitbucket
+
+import (
+	"context"
+
+	"github.com/majorcontext/moat/internal/provider"
+	"github.com/majorcontext/moat/internal/providers/githttp"
+)
+
+// Provider implements provider.CredentialProvider for Bitbucket Server.
+type Provider struct{}
+
+// Verify interface compliance at compile time.
+var (
+	_ provider.CredentialProvider = (*Provider)(nil)
+	_ provider.InitFileProvider   = (*Provider)(nil)
+	_ provider.CredentialChecker  = (*Provider)(nil)
+)
+
+func init() {
+	provider.Register(&Provider{})
+}
+
+// spec drives the shared grant flow.
+var spec = githttp.Spec{
+	Provider:    "bitbucket-server",
+	DisplayName: "Bitbucket Server",
+	SecretName:  "HTTP access token",
+	SecretHelp: `Enter a Bitbucket Server HTTP access token.
+
+To create one:
+  1. Open your profile > Manage account > HTTP access tokens
+  2. Click "Create token" and grant repository read (and write, to push)
+  3. Paste it below`,
+	URLEnv:    "BITBUCKET_SERVER_URL",
+	UserEnv:   "BITBUCKET_SERVER_USERNAME",
+	SecretEnv: "BITBUCKET_SERVER_TOKEN",
+	Check:     checkInstance,
+}
+
+// Name returns the provider identifier.
+func (p *Provider) Name() string {
+	return "bitbucket-server"
+}
+
+// ConfigureProxy injects Basic auth for each granted Bitbucket Server instance.
+func (p *Provider) ConfigureProxy(proxy provider.ProxyConfigurer, cred *provider.Credential) {
+	githttp.ConfigureProxy(proxy, cred, "bitbucket-server")
+}
+
+// ContainerEnv disables git's interactive credential prompts.
+func (p *Provider) ContainerEnv(cred *provider.Credential) []string {
+	return []string{"GIT_TERMINAL_PROMPT=0"}
+}
+
+// ContainerInitFiles writes a ~/.netrc with placeholder passwords.
+func (p *Provider) ContainerInitFiles(cred *provider.Credential, containerHome string) map[string]string {
+	return githttp.InitFiles(cred, containerHome)
+}
+
+// ContainerMounts returns no mounts — ~/.netrc is written via moat-init.sh.
+func (p *Provider) ContainerMounts(cred *provider.Credential, containerHome string) ([]provider.MountConfig, string, error) {
+	return nil, "", nil
+}
+
+// Cleanup is a no-op — no temp files are created.
+func (p *Provider) Cleanup(cleanupPath string) {}
+
+// ImpliedDependencies returns dependencies implied by this provider.
+func (p *Provider) ImpliedDependencies() []string {
+	return []string{"git"}
+}
+
+// CheckCredential verifies every granted instance still accepts its token.
+func (p *Provider) CheckCredential(ctx context.Context, cred *provider.Credential) error {
+	return githttp.CheckInstances(ctx, cred, checkInstance)
+}
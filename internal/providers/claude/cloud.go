@@ -0,0 +1,49 @@
+package claude
+
+// API providers for claude.provider in moat.yaml. The default (empty or
+// "anthropic") sends Claude Code to api.anthropic.com.
+const (
+	APIProviderAnthropic = "anthropic"
+	APIProviderBedrock   = "bedrock"
+	APIProviderVertex    = "vertex"
+)
+
+// DefaultVertexRegion is the Vertex AI region used when claude.region is unset.
+const DefaultVertexRegion = "global"
+
+// VertexHost returns the Vertex AI API host for region. The global region
+// has no regional prefix.
+func VertexHost(region string) string {
+	if region == "" || region == DefaultVertexRegion {
+		return "aiplatform.googleapis.com"
+	}
+	return region + "-aiplatform.googleapis.com"
+}
+
+// BedrockEnv returns the container environment that points Claude Code at
+// the Bedrock relay on the Moat proxy. Claude Code sends proxyToken as a
+// Bedrock API key; the relay swaps it for a SigV4 signature made with the
+// aws grant's role, so no AWS keys enter the container. AWS_REGION is set
+// by the aws grant.
+func BedrockEnv(relayURL, proxyToken string) []string {
+	return []string{
+		"CLAUDE_CODE_USE_BEDROCK=1",
+		"ANTHROPIC_BEDROCK_BASE_URL=" + relayURL,
+		"AWS_BEARER_TOKEN_BEDROCK=" + proxyToken,
+	}
+}
+
+// VertexEnv returns the container environment that runs Claude Code against
+// Vertex AI. Claude Code skips Google authentication; the proxy injects an
+// OAuth access token minted from the gcp grant.
+func VertexEnv(project, region string) []string {
+	if region == "" {
+		region = DefaultVertexRegion
+	}
+	return []string{
+		"CLAUDE_CODE_USE_VERTEX=1",
+		"CLAUDE_CODE_SKIP_VERTEX_AUTH=1",
+		"CLOUD_ML_REGION=" + region,
+		"ANTHROPIC_VERTEX_PROJECT_ID=" + project,
+	}
+}
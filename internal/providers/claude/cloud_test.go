@@ -0,0 +1,38 @@
+package claude
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestVertexHost(t *testing.T) {
+	tests := map[string]string{
+		"":          "aiplatform.googleapis.com",
+		"global":    "aiplatform.googleapis.com",
+		"us-east5":  "us-east5-aiplatform.googleapis.com",
+		"europe-w1": "europe-w1-aiplatform.googleapis.com",
+	}
+	for region, want := range tests {
+		if got := VertexHost(region); got != want {
+			t.Errorf("VertexHost(%q) = %q, want %q", region, got, want)
+		}
+	}
+}
+
+func TestVertexEnv(t *testing.T) {
+	env := VertexEnv("my-project", "")
+	for _, want := range []string{"CLAUDE_CODE_USE_VERTEX=1", "CLAUDE_CODE_SKIP_VERTEX_AUTH=1", "CLOUD_ML_REGION=global", "ANTHROPIC_VERTEX_PROJECT_ID=my-project"} {
+		if !slices.Contains(env, want) {
+			t.Errorf("VertexEnv missing %q: %v", want, env)
+		}
+	}
+}
+
+func TestBedrockEnv(t *testing.T) {
+	env := BedrockEnv("http://10.0.0.1:8080/_aws/bedrock", "tok")
+	for _, want := range []string{"CLAUDE_CODE_USE_BEDROCK=1", "ANTHROPIC_BEDROCK_BASE_URL=http://10.0.0.1:8080/_aws/bedrock", "AWS_BEARER_TOKEN_BEDROCK=tok"} {
+		if !slices.Contains(env, want) {
+			t.Errorf("BedrockEnv missing %q: %v", want, env)
+		}
+	}
+}
@@ -17,6 +17,7 @@ import (
 	"github.com/majorcontext/moat/internal/credential/keyring"
 	"github.com/majorcontext/moat/internal/log"
 	"github.com/majorcontext/moat/internal/provider"
+	"github.com/majorcontext/moat/internal/provider/util"
 	"github.com/majorcontext/moat/internal/term"
 	"github.com/majorcontext/moat/internal/ui"
 )
@@ -24,7 +25,20 @@ import (
 // Grant acquires a Claude Code OAuth token interactively.
 // Offers OAuth-specific options: setup-token, paste existing token, or import
 // from local Claude Code installation.
+//
+// With --from-env or --no-interactive set in ctx, the token is read from
+// CLAUDE_CODE_OAUTH_TOKEN (the variable 'claude setup-token' tells users to
+// export) instead, since every interactive option needs a terminal.
 func (p *OAuthProvider) Grant(ctx context.Context) (*provider.Credential, error) {
+	if opts := util.GrantOptionsFrom(ctx); opts.EnvOnly || opts.NonInteractive {
+		token := os.Getenv("CLAUDE_CODE_OAUTH_TOKEN")
+		if token == "" {
+			return nil, fmt.Errorf("%w: set CLAUDE_CODE_OAUTH_TOKEN", util.ErrInputRequired)
+		}
+		fmt.Println("Using OAuth token from CLAUDE_CODE_OAUTH_TOKEN environment variable")
+		return validateOAuthToken(ctx, token)
+	}
+
 	reader := bufio.NewReader(os.Stdin)
 
 	claudeAvailable := isClaudeAvailable()
@@ -339,7 +353,12 @@ func promptAndValidateOAuthToken(ctx context.Context, reader *bufio.Reader) (*pr
 	if err != nil {
 		return nil, fmt.Errorf("reading input: %w", err)
 	}
+	return validateOAuthToken(ctx, token)
+}
 
+// validateOAuthToken checks an OAuth token's format, validates it against
+// the API, and returns the credential.
+func validateOAuthToken(ctx context.Context, token string) (*provider.Credential, error) {
 	if token == "" {
 		return nil, fmt.Errorf("OAuth token cannot be empty")
 	}
@@ -379,6 +398,9 @@ func grantViaAPIKey(ctx context.Context) (*provider.Credential, error) {
 		apiKey = envKey
 		fmt.Println("Using API key from ANTHROPIC_API_KEY environment variable")
 	} else {
+		if err := util.RequireInput(ctx, "set ANTHROPIC_API_KEY"); err != nil {
+			return nil, err
+		}
 		var err error
 		apiKey, err = auth.PromptForAPIKey()
 		if err != nil {
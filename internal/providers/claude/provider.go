@@ -1,11 +1,18 @@
 package claude
 
 import (
+	"context"
 	"net"
+	"net/http"
 
 	"github.com/majorcontext/moat/internal/provider"
+	"github.com/majorcontext/moat/internal/provider/util"
 )
 
+// anthropicModelsURL is the endpoint AnthropicProvider.CheckCredential probes.
+// A variable so tests can point it at a local server.
+var anthropicModelsURL = "https://api.anthropic.com/v1/models"
+
 // OAuthProvider implements provider.CredentialProvider and provider.AgentProvider
 // for Claude Code OAuth tokens (from Claude Pro/Max subscriptions).
 //
@@ -24,6 +31,7 @@ var (
 	_ provider.CredentialProvider = (*OAuthProvider)(nil)
 	_ provider.AgentProvider      = (*OAuthProvider)(nil)
 	_ provider.CredentialProvider = (*AnthropicProvider)(nil)
+	_ provider.CredentialChecker  = (*AnthropicProvider)(nil)
 )
 
 func init() {
@@ -107,6 +115,18 @@ func (p *AnthropicProvider) ContainerMounts(cred *provider.Credential, container
 // Cleanup cleans up Anthropic resources.
 func (p *AnthropicProvider) Cleanup(cleanupPath string) {}
 
+// CheckCredential verifies the API key by listing models, which is free,
+// unlike the one-token message ValidateKey sends at grant time.
+func (p *AnthropicProvider) CheckCredential(ctx context.Context, cred *provider.Credential) error {
+	req, err := http.NewRequest("GET", anthropicModelsURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("x-api-key", cred.Token)
+	req.Header.Set("anthropic-version", "2023-06-01")
+	return util.ProbeCredential(ctx, req)
+}
+
 // ImpliedDependencies returns dependencies implied by the Anthropic provider.
 func (p *AnthropicProvider) ImpliedDependencies() []string {
 	return nil
@@ -3,6 +3,9 @@ package claude
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"reflect"
@@ -1213,3 +1216,25 @@ func TestPrepareContainer_LocalMCPMinimalFields(t *testing.T) {
 		}
 	}
 }
+
+func TestAnthropicProvider_CheckCredential(t *testing.T) {
+	var gotKey, gotMethod string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotKey = r.Header.Get("x-api-key")
+		gotMethod = r.Method
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+	orig := anthropicModelsURL
+	anthropicModelsURL = srv.URL
+	defer func() { anthropicModelsURL = orig }()
+
+	p := &AnthropicProvider{}
+	err := p.CheckCredential(context.Background(), &provider.Credential{Token: "sk-ant-api-test"})
+	if !errors.Is(err, provider.ErrCredentialRejected) {
+		t.Errorf("err = %v, want ErrCredentialRejected", err)
+	}
+	if gotKey != "sk-ant-api-test" || gotMethod != "GET" {
+		t.Errorf("request = %s with x-api-key %q, want GET with the key", gotMethod, gotKey)
+	}
+}
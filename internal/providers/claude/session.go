@@ -18,6 +18,20 @@ var uuidPattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a
 // OnRunStopped extracts the Claude session ID from the projects directory
 // after the container exits. It implements provider.RunStoppedHook.
 func (p *OAuthProvider) OnRunStopped(ctx provider.RunStoppedContext) map[string]string {
+	return sessionMeta(ctx)
+}
+
+// OnSnapshotCreated records the Claude session ID as of each snapshot, so
+// `moat claude --resume` finds the session of a run that is still going or
+// whose host crashed before it stopped. It implements
+// provider.SnapshotCreatedHook.
+func (p *OAuthProvider) OnSnapshotCreated(ctx provider.RunContext, _ provider.SnapshotEvent) map[string]string {
+	return sessionMeta(ctx)
+}
+
+// sessionMeta returns the claude_session_id of the latest session in the
+// run's workspace, or nil if there is none yet.
+func sessionMeta(ctx provider.RunContext) map[string]string {
 	if ctx.Workspace == "" {
 		return nil
 	}
@@ -85,5 +99,8 @@ func findLatestSessionID(projectsDir string, startedAt time.Time) string {
 	return bestID
 }
 
-// Ensure OAuthProvider implements RunStoppedHook.
-var _ provider.RunStoppedHook = (*OAuthProvider)(nil)
+// Ensure OAuthProvider implements its lifecycle hooks.
+var (
+	_ provider.RunStoppedHook      = (*OAuthProvider)(nil)
+	_ provider.SnapshotCreatedHook = (*OAuthProvider)(nil)
+)
@@ -92,6 +92,29 @@ func TestOnRunStopped_NoWorkspace(t *testing.T) {
 	}
 }
 
+func TestOnSnapshotCreated(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	workspace := "/home/alice/projects/myapp"
+	projectsDir := filepath.Join(home, ".claude", "projects", WorkspaceToClaudeDir(workspace))
+	if err := os.MkdirAll(projectsDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	p := &OAuthProvider{}
+	ctx := provider.RunContext{Workspace: workspace, StartedAt: time.Now().Add(-time.Hour)}
+	ev := provider.SnapshotEvent{ID: "snap_1", Type: "git"}
+	if got := p.OnSnapshotCreated(ctx, ev); got != nil {
+		t.Errorf("OnSnapshotCreated() before a session = %v, want nil", got)
+	}
+
+	writeSessionFile(t, projectsDir, "aaaaaaaa-1111-2222-3333-444455556666", time.Now())
+	got := p.OnSnapshotCreated(ctx, ev)
+	if got["claude_session_id"] != "aaaaaaaa-1111-2222-3333-444455556666" {
+		t.Errorf("OnSnapshotCreated() = %v, want the session ID", got)
+	}
+}
+
 // writeSessionFile creates a fake Claude session JSONL file with the given
 // modification time.
 func writeSessionFile(t *testing.T, dir, uuid string, modTime time.Time) {
@@ -6,7 +6,9 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 
+	"github.com/majorcontext/moat/internal/credential"
 	"github.com/majorcontext/moat/internal/provider"
 )
 
@@ -96,7 +98,7 @@ func (p *Provider) PrepareContainer(ctx context.Context, opts provider.PrepareOp
 // PopulateStagingDir populates the Codex staging directory with auth configuration.
 //
 // Files added:
-//   - auth.json (placeholder API key - real auth is via proxy)
+//   - auth.json (placeholder API key or ChatGPT tokens - real auth is via proxy)
 //
 // SECURITY: The real token is NEVER written to the container filesystem.
 // Authentication is handled by the TLS-intercepting proxy at the network layer.
@@ -104,9 +106,24 @@ func PopulateStagingDir(cred *provider.Credential, stagingDir string) error {
 	// API key - use a placeholder that looks like a valid API key
 	// This bypasses local format validation in Codex CLI.
 	// The proxy will inject the real key in the Authorization header.
-	authFile := map[string]string{
+	var authFile any = map[string]string{
 		"OPENAI_API_KEY": OpenAIAPIKeyPlaceholder,
 	}
+	if IsChatGPTCredential(cred) {
+		// ChatGPT login - Codex CLI reads the account ID from the JWT claims
+		// of the placeholder tokens. last_refresh is now, so the CLI does not
+		// try to refresh on its own; the proxy keeps the real token fresh.
+		accountID := cred.Metadata["account_id"]
+		authFile = CLIAuth{
+			Tokens: &CLITokens{
+				IDToken:      credential.GenerateIDTokenPlaceholder(accountID),
+				AccessToken:  authJSONSecret(accountID).Placeholder,
+				RefreshToken: credential.ProxyInjectedPlaceholder,
+				AccountID:    accountID,
+			},
+			LastRefresh: time.Now().UTC().Format(time.RFC3339),
+		}
+	}
 
 	authJSON, err := json.MarshalIndent(authFile, "", "  ")
 	if err != nil {
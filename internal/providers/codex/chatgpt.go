@@ -0,0 +1,249 @@
+package codex
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/majorcontext/moat/internal/provider"
+)
+
+const (
+	// ChatGPTHost is the backend Codex CLI calls when signed in with a
+	// ChatGPT subscription instead of an API key.
+	ChatGPTHost = "chatgpt.com"
+
+	// OpenAIAuthHost is OpenAI's OAuth server.
+	OpenAIAuthHost = "auth.openai.com"
+
+	// ChatGPTTokenURL is the OAuth token endpoint for ChatGPT logins.
+	ChatGPTTokenURL = "https://auth.openai.com/oauth/token"
+
+	// ChatGPTClientID is the public OAuth client ID of Codex CLI.
+	ChatGPTClientID = "app_EMoamEEZ73f0CkXaXp7hrann"
+
+	// authTypeChatGPT marks a credential imported from a Codex CLI ChatGPT
+	// login.
+	authTypeChatGPT = "chatgpt"
+
+	// chatgptRefreshWindow is how long before expiry the access token is
+	// refreshed. The daemon asks every few minutes; refreshing only near
+	// expiry avoids rotating the refresh token needlessly.
+	chatgptRefreshWindow = time.Hour
+)
+
+// chatgptTokenURL is the token endpoint Provider.Refresh uses. A variable so
+// tests can point it at a local server.
+var chatgptTokenURL = ChatGPTTokenURL
+
+// IsChatGPTCredential returns true if the credential is a ChatGPT
+// subscription login rather than an API key.
+func IsChatGPTCredential(cred *provider.Credential) bool {
+	return cred != nil && cred.Metadata != nil && cred.Metadata["auth_type"] == authTypeChatGPT
+}
+
+// CLIAuth is the ~/.codex/auth.json file written by `codex login`.
+type CLIAuth struct {
+	OpenAIAPIKey *string    `json:"OPENAI_API_KEY"`
+	Tokens       *CLITokens `json:"tokens,omitempty"`
+	LastRefresh  string     `json:"last_refresh,omitempty"`
+}
+
+// CLITokens holds the OAuth tokens of a ChatGPT login.
+type CLITokens struct {
+	IDToken      string `json:"id_token"`
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	AccountID    string `json:"account_id,omitempty"`
+}
+
+// cliAuthPath returns the path of Codex CLI's auth.json, honoring CODEX_HOME.
+func cliAuthPath() string {
+	if dir := os.Getenv("CODEX_HOME"); dir != "" {
+		return filepath.Join(dir, "auth.json")
+	}
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".codex", "auth.json")
+}
+
+// readCLITokens returns the ChatGPT login tokens from Codex CLI's auth.json,
+// or an error if there is no ChatGPT login.
+func readCLITokens(path string) (*CLITokens, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var auth CLIAuth
+	if err := json.Unmarshal(data, &auth); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	if auth.Tokens == nil || auth.Tokens.AccessToken == "" || auth.Tokens.RefreshToken == "" {
+		return nil, fmt.Errorf("%s has no ChatGPT login", path)
+	}
+	if auth.Tokens.AccountID == "" {
+		auth.Tokens.AccountID = accountIDFromJWT(auth.Tokens.IDToken)
+	}
+	return auth.Tokens, nil
+}
+
+// jwtClaims decodes the payload of a JWT without verifying it.
+func jwtClaims(token string) map[string]any {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil
+	}
+	var claims map[string]any
+	if json.Unmarshal(payload, &claims) != nil {
+		return nil
+	}
+	return claims
+}
+
+// jwtExpiry returns the exp claim of a JWT, or the zero time.
+func jwtExpiry(token string) time.Time {
+	if exp, ok := jwtClaims(token)["exp"].(float64); ok {
+		return time.Unix(int64(exp), 0)
+	}
+	return time.Time{}
+}
+
+// accountIDFromJWT returns the ChatGPT account ID in an OpenAI token's
+// auth claims, or "".
+func accountIDFromJWT(token string) string {
+	auth, _ := jwtClaims(token)["https://api.openai.com/auth"].(map[string]any)
+	id, _ := auth["chatgpt_account_id"].(string)
+	return id
+}
+
+// OAuthError is an error response from OpenAI's token endpoint.
+type OAuthError struct {
+	Code        string
+	Description string
+}
+
+func (e *OAuthError) Error() string {
+	if e.Description != "" {
+		return fmt.Sprintf("%s: %s", e.Code, e.Description)
+	}
+	return e.Code
+}
+
+// IsRevoked returns true if the refresh token can no longer be used: it was
+// revoked, expired, or already exchanged by another client sharing the login.
+func (e *OAuthError) IsRevoked() bool {
+	switch e.Code {
+	case "invalid_grant", "refresh_token_expired", "refresh_token_reused", "refresh_token_invalidated":
+		return true
+	}
+	return false
+}
+
+// TokenRefresher exchanges ChatGPT refresh tokens for new tokens.
+type TokenRefresher struct {
+	TokenURL   string       // Override for testing; empty uses ChatGPTTokenURL
+	HTTPClient *http.Client // Override for testing
+}
+
+// RefreshResult holds the tokens returned by a refresh. OpenAI rotates
+// refresh tokens, so RefreshToken replaces the one that was exchanged.
+type RefreshResult struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresAt    time.Time
+}
+
+// Refresh exchanges a refresh token for a new access token.
+func (r *TokenRefresher) Refresh(ctx context.Context, refreshToken string) (*RefreshResult, error) {
+	tokenURL := r.TokenURL
+	if tokenURL == "" {
+		tokenURL = ChatGPTTokenURL
+	}
+	client := r.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	body, _ := json.Marshal(map[string]string{
+		"client_id":     ChatGPTClientID,
+		"grant_type":    "refresh_token",
+		"refresh_token": refreshToken,
+		"scope":         "openid profile email",
+	})
+	req, err := http.NewRequestWithContext(ctx, "POST", tokenURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("creating refresh request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("making refresh request: %w", err)
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading refresh response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		// OpenAI returns either an OAuth error or {"error": {"code": ...}}.
+		var errResp struct {
+			Error       json.RawMessage `json:"error"`
+			Description string          `json:"error_description"`
+		}
+		if json.Unmarshal(respBody, &errResp) == nil && len(errResp.Error) > 0 {
+			oauthErr := &OAuthError{Description: errResp.Description}
+			if json.Unmarshal(errResp.Error, &oauthErr.Code) != nil {
+				var nested struct {
+					Code    string `json:"code"`
+					Message string `json:"message"`
+				}
+				if json.Unmarshal(errResp.Error, &nested) == nil {
+					oauthErr.Code, oauthErr.Description = nested.Code, nested.Message
+				}
+			}
+			if oauthErr.Code != "" {
+				return nil, oauthErr
+			}
+		}
+		return nil, fmt.Errorf("token refresh failed (HTTP %d): %s", resp.StatusCode, string(respBody))
+	}
+
+	var tokenResp struct {
+		AccessToken  string `json:"access_token"`
+		RefreshToken string `json:"refresh_token"`
+		ExpiresIn    int    `json:"expires_in"`
+	}
+	if err := json.Unmarshal(respBody, &tokenResp); err != nil {
+		return nil, fmt.Errorf("parsing refresh response: %w", err)
+	}
+	if tokenResp.AccessToken == "" {
+		return nil, errors.New("no access token in refresh response")
+	}
+
+	result := &RefreshResult{
+		AccessToken:  tokenResp.AccessToken,
+		RefreshToken: tokenResp.RefreshToken,
+		ExpiresAt:    jwtExpiry(tokenResp.AccessToken),
+	}
+	if result.RefreshToken == "" {
+		result.RefreshToken = refreshToken
+	}
+	if tokenResp.ExpiresIn > 0 {
+		result.ExpiresAt = time.Now().Add(time.Duration(tokenResp.ExpiresIn) * time.Second)
+	}
+	return result, nil
+}
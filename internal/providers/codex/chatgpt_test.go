@@ -0,0 +1,227 @@
+package codex
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/majorcontext/moat/internal/credential"
+	"github.com/majorcontext/moat/internal/provider"
+)
+
+// testJWT returns an unsigned JWT with the given claims.
+func testJWT(t *testing.T, claims map[string]any) string {
+	t.Helper()
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return "eyJhbGciOiJub25lIn0." + base64.RawURLEncoding.EncodeToString(payload) + ".sig"
+}
+
+func chatgptCred(expiresAt time.Time) *provider.Credential {
+	return &provider.Credential{
+		Provider:  "openai",
+		Token:     "real-access-token",
+		ExpiresAt: expiresAt,
+		Metadata: map[string]string{
+			"auth_type":     "chatgpt",
+			"refresh_token": "real-refresh-token",
+			"account_id":    "acct-123",
+		},
+	}
+}
+
+func TestReadCLITokens(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "auth.json")
+	idToken := testJWT(t, map[string]any{
+		"https://api.openai.com/auth": map[string]any{"chatgpt_account_id": "acct-from-jwt"},
+	})
+	auth := `{"OPENAI_API_KEY": null, "tokens": {"id_token": "` + idToken + `", "access_token": "at", "refresh_token": "rt"}}`
+	if err := os.WriteFile(path, []byte(auth), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tokens, err := readCLITokens(path)
+	if err != nil {
+		t.Fatalf("readCLITokens() error = %v", err)
+	}
+	if tokens.AccountID != "acct-from-jwt" {
+		t.Errorf("AccountID = %q, want it read from the id_token claims", tokens.AccountID)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"OPENAI_API_KEY": "sk-test"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readCLITokens(path); err == nil {
+		t.Error("readCLITokens() on an API key login should fail")
+	}
+}
+
+func TestTokenRefresher(t *testing.T) {
+	exp := time.Now().Add(10 * 24 * time.Hour).Truncate(time.Second)
+	newAccess := testJWT(t, map[string]any{"exp": exp.Unix()})
+	var got map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+		json.NewEncoder(w).Encode(map[string]string{
+			"access_token":  newAccess,
+			"refresh_token": "rotated-refresh-token",
+		})
+	}))
+	defer srv.Close()
+
+	r := &TokenRefresher{TokenURL: srv.URL}
+	result, err := r.Refresh(context.Background(), "old-refresh-token")
+	if err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	if got["refresh_token"] != "old-refresh-token" || got["client_id"] != ChatGPTClientID || got["grant_type"] != "refresh_token" {
+		t.Errorf("request = %v", got)
+	}
+	if result.AccessToken != newAccess || result.RefreshToken != "rotated-refresh-token" {
+		t.Errorf("result = %+v, want the new access token and rotated refresh token", result)
+	}
+	if !result.ExpiresAt.Equal(exp) {
+		t.Errorf("ExpiresAt = %v, want %v from the access token", result.ExpiresAt, exp)
+	}
+}
+
+func TestTokenRefresher_Errors(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		wantRevoked bool
+	}{
+		{name: "oauth error", body: `{"error": "invalid_grant", "error_description": "revoked"}`, wantRevoked: true},
+		{name: "nested reused", body: `{"error": {"code": "refresh_token_reused", "message": "already used"}}`, wantRevoked: true},
+		{name: "other", body: `{"error": "invalid_request"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+
+			_, err := (&TokenRefresher{TokenURL: srv.URL}).Refresh(context.Background(), "rt")
+			var oauthErr *OAuthError
+			if !errors.As(err, &oauthErr) {
+				t.Fatalf("err = %v, want *OAuthError", err)
+			}
+			if oauthErr.IsRevoked() != tt.wantRevoked {
+				t.Errorf("IsRevoked() = %v, want %v (code %q)", oauthErr.IsRevoked(), tt.wantRevoked, oauthErr.Code)
+			}
+		})
+	}
+}
+
+func TestProvider_ConfigureProxy_ChatGPT(t *testing.T) {
+	p := &Provider{}
+	proxy := newMockProxyConfigurer()
+	p.ConfigureProxy(proxy, chatgptCred(time.Now().Add(24*time.Hour)))
+
+	if got := proxy.headers[ChatGPTHost]["Authorization"]; got != "Bearer real-access-token" {
+		t.Errorf("%s Authorization = %q", ChatGPTHost, got)
+	}
+	if _, ok := proxy.headers["api.openai.com"]; ok {
+		t.Error("ChatGPT login should not be injected for api.openai.com")
+	}
+	sub := proxy.substitutions[OpenAIAuthHost]
+	if sub[0] != credential.GenerateAccessTokenPlaceholder("acct-123") || sub[1] != "real-access-token" {
+		t.Errorf("%s substitution = %q, want the auth.json placeholder replaced by the access token", OpenAIAuthHost, sub)
+	}
+	if env := p.ContainerEnv(chatgptCred(time.Time{})); len(env) != 0 {
+		t.Errorf("ContainerEnv() = %v, want none for a ChatGPT login", env)
+	}
+}
+
+func TestProvider_Refresh_ChatGPT(t *testing.T) {
+	p := &Provider{}
+	proxy := newMockProxyConfigurer()
+
+	// Not yet near expiry: nothing is exchanged.
+	fresh := chatgptCred(time.Now().Add(24 * time.Hour))
+	got, err := p.Refresh(context.Background(), proxy, fresh)
+	if err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	if got.Token != fresh.Token || len(proxy.headers) != 0 {
+		t.Error("Refresh() exchanged a token that is not near expiry")
+	}
+
+	// Near expiry: the token is exchanged and the rotated refresh token kept.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"access_token":  "new-access-token",
+			"refresh_token": "rotated-refresh-token",
+			"expires_in":    864000,
+		})
+	}))
+	defer srv.Close()
+	orig := chatgptTokenURL
+	chatgptTokenURL = srv.URL
+	defer func() { chatgptTokenURL = orig }()
+
+	stale := chatgptCred(time.Now().Add(10 * time.Minute))
+	got, err = p.Refresh(context.Background(), proxy, stale)
+	if err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	if got.Token != "new-access-token" || got.Metadata["refresh_token"] != "rotated-refresh-token" {
+		t.Errorf("refreshed credential = %+v", got)
+	}
+	if stale.Metadata["refresh_token"] != "real-refresh-token" {
+		t.Error("Refresh() modified the input credential's metadata")
+	}
+	if proxy.headers[ChatGPTHost]["Authorization"] != "Bearer new-access-token" {
+		t.Error("proxy was not updated with the new access token")
+	}
+	if proxy.substitutions[OpenAIAuthHost][1] != "new-access-token" {
+		t.Error("auth.openai.com substitution was not updated")
+	}
+
+	if p.CanRefresh(&provider.Credential{Token: "sk-test"}) {
+		t.Error("API keys should not be refreshable")
+	}
+}
+
+func TestPopulateStagingDir_ChatGPT(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := PopulateStagingDir(chatgptCred(time.Now().Add(time.Hour)), tmpDir); err != nil {
+		t.Fatalf("PopulateStagingDir() error = %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(tmpDir, "auth.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "real-access-token") || strings.Contains(string(data), "real-refresh-token") {
+		t.Fatal("auth.json contains real tokens")
+	}
+	var auth CLIAuth
+	if err := json.Unmarshal(data, &auth); err != nil {
+		t.Fatal(err)
+	}
+	if auth.OpenAIAPIKey != nil {
+		t.Error("OPENAI_API_KEY should be null for a ChatGPT login")
+	}
+	if auth.Tokens == nil || auth.Tokens.AccountID != "acct-123" {
+		t.Fatalf("tokens = %+v, want account acct-123", auth.Tokens)
+	}
+	if accountIDFromJWT(auth.Tokens.IDToken) != "acct-123" {
+		t.Error("placeholder id_token should carry the account ID")
+	}
+	if auth.Tokens.AccessToken != credential.GenerateAccessTokenPlaceholder("acct-123") {
+		t.Error("access_token should be the placeholder the proxy substitutes")
+	}
+}
@@ -5,12 +5,19 @@
 //
 // # Authentication
 //
-// Codex supports OpenAI API key authentication:
-//
-//   - API keys are validated against the /v1/models endpoint
-//   - Keys must start with "sk-" prefix
-//   - The real API key is never exposed to containers
-//   - Proxy injection adds Authorization headers at network layer
+// Codex supports two authentication methods:
+//
+//  1. OpenAI API keys:
+//     - Validated against the /v1/models endpoint
+//     - Keys must start with "sk-" prefix
+//     - The real API key is never exposed to containers
+//     - Proxy injection adds Authorization headers at network layer
+//
+//  2. ChatGPT subscription logins imported from Codex CLI's auth.json:
+//     - The access token is injected for chatgpt.com
+//     - auth.json in the container holds placeholder tokens; the proxy
+//     substitutes the real access token on auth.openai.com
+//     - The proxy refreshes the tokens before the access token expires
 //
 // # Credential Provider
 //
@@ -1,6 +1,7 @@
 package codex
 
 import (
+	"bufio"
 	"context"
 	"fmt"
 	"os"
@@ -8,6 +9,7 @@ import (
 
 	"github.com/majorcontext/moat/internal/credential"
 	"github.com/majorcontext/moat/internal/provider"
+	"github.com/majorcontext/moat/internal/provider/util"
 )
 
 // Grant handles the OpenAI API key grant process.
@@ -35,8 +37,36 @@ func (g *Grant) Execute(ctx context.Context) (*provider.Credential, error) {
 	if envKey := os.Getenv("OPENAI_API_KEY"); envKey != "" {
 		apiKey = envKey
 		fmt.Println("Using API key from OPENAI_API_KEY environment variable")
+	} else if tokens, err := readCLITokens(cliAuthPath()); err == nil && !util.GrantOptionsFrom(ctx).EnvOnly {
+		// Offer choice between ChatGPT login import and API key
+		fmt.Println("Choose authentication method:")
+		fmt.Println()
+		fmt.Println("  1. Import Codex CLI ChatGPT login (recommended)")
+		fmt.Println("     Use your ChatGPT subscription; tokens are refreshed automatically.")
+		fmt.Println()
+		fmt.Println("  2. OpenAI API key")
+		fmt.Println("     Use an API key from platform.openai.com/api-keys")
+		fmt.Println()
+
+		choice, err := util.ReadChoice(ctx, bufio.NewReader(os.Stdin), "Enter choice [1 or 2]: ", "1")
+		if err != nil {
+			return nil, fmt.Errorf("reading choice: %w", err)
+		}
+		switch choice {
+		case "1":
+			return grantViaCLILogin(tokens)
+		case "2":
+			if apiKey, err = g.auth.PromptForAPIKey(); err != nil {
+				return nil, fmt.Errorf("reading API key: %w", err)
+			}
+		default:
+			return nil, fmt.Errorf("invalid choice %q: enter 1 or 2", choice)
+		}
 	} else {
 		// Prompt for API key
+		if err := util.RequireInput(ctx, "set OPENAI_API_KEY"); err != nil {
+			return nil, err
+		}
 		var err error
 		apiKey, err = g.auth.PromptForAPIKey()
 		if err != nil {
@@ -63,6 +93,37 @@ func (g *Grant) Execute(ctx context.Context) (*provider.Credential, error) {
 	}, nil
 }
 
+// grantViaCLILogin creates a credential from Codex CLI's ChatGPT login.
+// The tokens are not refreshed here: OpenAI rotates refresh tokens, and
+// refreshing would sign the host's Codex CLI out. The proxy refreshes them
+// when the access token nears expiry.
+func grantViaCLILogin(tokens *CLITokens) (*provider.Credential, error) {
+	if tokens.AccountID == "" {
+		return nil, fmt.Errorf("no ChatGPT account ID in Codex CLI login\n\nTry signing in again: codex login")
+	}
+	expiresAt := jwtExpiry(tokens.AccessToken)
+
+	fmt.Println()
+	fmt.Println("Found Codex CLI ChatGPT login.")
+	if !expiresAt.IsZero() {
+		fmt.Printf("  Access token expires: %s\n", expiresAt.Format(time.RFC3339))
+	}
+	fmt.Println("\nNote: moat and Codex CLI on this host now share the login. If Codex CLI")
+	fmt.Println("later reports that its session expired, run 'codex login' and grant again.")
+
+	return &provider.Credential{
+		Provider:  "openai",
+		Token:     tokens.AccessToken,
+		ExpiresAt: expiresAt,
+		CreatedAt: time.Now(),
+		Metadata: map[string]string{
+			"auth_type":     authTypeChatGPT,
+			"refresh_token": tokens.RefreshToken,
+			"account_id":    tokens.AccountID,
+		},
+	}, nil
+}
+
 // HasCredential checks if an OpenAI credential exists in the store.
 func HasCredential() bool {
 	key, err := credential.DefaultEncryptionKey()
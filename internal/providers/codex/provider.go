@@ -2,8 +2,14 @@ package codex
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
 
+	"github.com/majorcontext/moat/internal/credential"
 	"github.com/majorcontext/moat/internal/provider"
+	"github.com/majorcontext/moat/internal/provider/util"
 )
 
 // Provider implements provider.CredentialProvider and provider.AgentProvider
@@ -12,10 +18,16 @@ type Provider struct{}
 
 // Ensure Provider implements the required interfaces.
 var (
-	_ provider.CredentialProvider = (*Provider)(nil)
-	_ provider.AgentProvider      = (*Provider)(nil)
+	_ provider.CredentialProvider  = (*Provider)(nil)
+	_ provider.AgentProvider       = (*Provider)(nil)
+	_ provider.CredentialChecker   = (*Provider)(nil)
+	_ provider.RefreshableProvider = (*Provider)(nil)
 )
 
+// modelsURL is the endpoint CheckCredential probes. A variable so tests can
+// point it at a local server.
+var modelsURL = "https://api.openai.com/v1/models"
+
 func init() {
 	provider.Register(&Provider{})
 	// Register "openai" as an alias so credentials stored under either name work
@@ -41,16 +53,105 @@ func (p *Provider) Grant(ctx context.Context) (*provider.Credential, error) {
 // The proxy intercepts requests to api.openai.com and injects the
 // Authorization header with the real API key.
 func (p *Provider) ConfigureProxy(proxy provider.ProxyConfigurer, cred *provider.Credential) {
+	if IsChatGPTCredential(cred) {
+		setChatGPTToken(proxy, cred)
+		return
+	}
 	// OpenAI uses Bearer token authentication for API keys
 	proxy.SetCredentialWithGrant("api.openai.com", "Authorization", "Bearer "+cred.Token, "codex")
 }
 
+// setChatGPTToken injects a ChatGPT access token. Codex CLI in subscription
+// mode calls chatgpt.com with the placeholder access token from its
+// auth.json; requests it sends to auth.openai.com carrying that placeholder
+// get the real token substituted, as Gemini's OAuth mode does for
+// oauth2.googleapis.com. The refresh token never enters the container.
+func setChatGPTToken(proxy provider.ProxyConfigurer, cred *provider.Credential) {
+	proxy.SetCredentialWithGrant(ChatGPTHost, "Authorization", "Bearer "+cred.Token, "codex")
+	provider.ConfigureFileSecrets(proxy, cred, []provider.FileSecret{authJSONSecret(cred.Metadata["account_id"])})
+}
+
+// authJSONSecret is the placeholder access token in a ChatGPT login's
+// auth.json. The file itself is written by PopulateStagingDir.
+func authJSONSecret(accountID string) provider.FileSecret {
+	return provider.FileSecret{
+		Path:        ".codex/auth.json",
+		Placeholder: credential.GenerateAccessTokenPlaceholder(accountID),
+		Hosts:       []string{OpenAIAuthHost},
+	}
+}
+
+// CanRefresh reports whether this credential can be refreshed.
+// ChatGPT logins can be refreshed; API keys cannot.
+func (p *Provider) CanRefresh(cred *provider.Credential) bool {
+	return IsChatGPTCredential(cred) && cred.Metadata["refresh_token"] != ""
+}
+
+// RefreshInterval returns how often to attempt refresh.
+func (p *Provider) RefreshInterval() time.Duration {
+	return 45 * time.Minute
+}
+
+// Refresh exchanges the refresh token for a new access token once the
+// current one is within chatgptRefreshWindow of expiring, and updates the
+// proxy. Earlier calls return cred unchanged. The rotated refresh token is
+// kept in the returned credential's metadata.
+func (p *Provider) Refresh(ctx context.Context, proxy provider.ProxyConfigurer, cred *provider.Credential) (*provider.Credential, error) {
+	if !p.CanRefresh(cred) {
+		return nil, provider.ErrRefreshNotSupported
+	}
+	if !cred.ExpiresAt.IsZero() && time.Until(cred.ExpiresAt) > chatgptRefreshWindow {
+		return cred, nil
+	}
+
+	refresher := &TokenRefresher{TokenURL: chatgptTokenURL}
+	result, err := refresher.Refresh(ctx, cred.Metadata["refresh_token"])
+	if err != nil {
+		var oauthErr *OAuthError
+		if errors.As(err, &oauthErr) && oauthErr.IsRevoked() {
+			return nil, fmt.Errorf("%w: %w", provider.ErrTokenRevoked, err)
+		}
+		return nil, err
+	}
+
+	newCred := *cred
+	newCred.Token = result.AccessToken
+	newCred.ExpiresAt = result.ExpiresAt
+	newCred.Metadata = make(map[string]string, len(cred.Metadata))
+	for k, v := range cred.Metadata {
+		newCred.Metadata[k] = v
+	}
+	newCred.Metadata["refresh_token"] = result.RefreshToken
+
+	setChatGPTToken(proxy, &newCred)
+	return &newCred, nil
+}
+
+// CheckCredential verifies the API key by listing models. ChatGPT logins
+// are refreshed at run start instead, so they are not checked here.
+func (p *Provider) CheckCredential(ctx context.Context, cred *provider.Credential) error {
+	if IsChatGPTCredential(cred) {
+		return nil
+	}
+	req, err := http.NewRequest("GET", modelsURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+cred.Token)
+	return util.ProbeCredential(ctx, req)
+}
+
 // ContainerEnv returns environment variables for OpenAI.
 // Sets OPENAI_API_KEY with a placeholder that looks like a valid API key.
 // This tells Codex CLI it's authenticated (skips login prompts) and
 // bypasses local format validation.
 // The real token is injected by the proxy at the network layer.
 func (p *Provider) ContainerEnv(cred *provider.Credential) []string {
+	if IsChatGPTCredential(cred) {
+		// Subscription mode: auth.json carries placeholder tokens, and an
+		// OPENAI_API_KEY would switch Codex CLI to API key billing.
+		return nil
+	}
 	return []string{"OPENAI_API_KEY=" + OpenAIAPIKeyPlaceholder}
 }
 
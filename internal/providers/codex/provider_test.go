@@ -2,6 +2,8 @@ package codex
 
 import (
 	"context"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"testing"
@@ -12,14 +14,16 @@ import (
 
 // mockProxyConfigurer implements provider.ProxyConfigurer for testing.
 type mockProxyConfigurer struct {
-	credentials map[string]string
-	headers     map[string]map[string]string
+	credentials   map[string]string
+	headers       map[string]map[string]string
+	substitutions map[string][2]string // host -> {placeholder, real token}
 }
 
 func newMockProxyConfigurer() *mockProxyConfigurer {
 	return &mockProxyConfigurer{
-		credentials: make(map[string]string),
-		headers:     make(map[string]map[string]string),
+		credentials:   make(map[string]string),
+		headers:       make(map[string]map[string]string),
+		substitutions: make(map[string][2]string),
 	}
 }
 
@@ -54,7 +58,9 @@ func (m *mockProxyConfigurer) AddResponseTransformer(host string, transformer pr
 
 func (m *mockProxyConfigurer) RemoveRequestHeader(host, header string) {}
 
-func (m *mockProxyConfigurer) SetTokenSubstitution(host, placeholder, realToken string) {}
+func (m *mockProxyConfigurer) SetTokenSubstitution(host, placeholder, realToken string) {
+	m.substitutions[host] = [2]string{placeholder, realToken}
+}
 
 func TestProvider_Name(t *testing.T) {
 	p := &Provider{}
@@ -372,3 +378,22 @@ func contains(s, substr string) bool {
 	}
 	return false
 }
+
+func TestCheckCredential(t *testing.T) {
+	var gotAuth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+	}))
+	defer srv.Close()
+	orig := modelsURL
+	modelsURL = srv.URL
+	defer func() { modelsURL = orig }()
+
+	p := &Provider{}
+	if err := p.CheckCredential(context.Background(), &provider.Credential{Token: "sk-test"}); err != nil {
+		t.Fatalf("CheckCredential: %v", err)
+	}
+	if gotAuth != "Bearer sk-test" {
+		t.Errorf("Authorization = %q, want Bearer token", gotAuth)
+	}
+}
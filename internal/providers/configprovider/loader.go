@@ -120,7 +120,10 @@ func registerAll(defs map[string]ProviderDef, userNames map[string]bool) {
 			source = "custom"
 		}
 
-		cp := NewConfigProvider(def, source)
+		var cp provider.CredentialProvider = NewConfigProvider(def, source)
+		if len(def.Files) > 0 {
+			cp = &fileConfigProvider{NewConfigProvider(def, source)}
+		}
 		provider.Register(cp)
 
 		// Register aliases
@@ -129,7 +132,7 @@ func registerAll(defs map[string]ProviderDef, userNames map[string]bool) {
 		}
 
 		// Register hosts for network policy
-		proxy.RegisterGrantHosts(def.Name, def.Hosts)
+		proxy.RegisterGrantHosts(def.Name, def.GrantHosts())
 
 		// Register as a known credential provider
 		credential.RegisterDynamicProvider(credential.Provider(def.Name))
@@ -153,8 +156,23 @@ func parseProviderDef(data []byte) (ProviderDef, error) {
 	if def.Description == "" {
 		return ProviderDef{}, fmt.Errorf("provider %q: description is required", def.Name)
 	}
-	if def.Inject.Header == "" && def.ContainerEnv == "" {
-		return ProviderDef{}, fmt.Errorf("provider %q: inject.header or container_env is required", def.Name)
+	if def.Inject.Header == "" && def.ContainerEnv == "" && len(def.Files) == 0 {
+		return ProviderDef{}, fmt.Errorf("provider %q: inject.header, container_env, or files is required", def.Name)
+	}
+	secrets := make([]provider.FileSecret, 0, len(def.Files))
+	for _, f := range def.Files {
+		if f.Content == "" {
+			return ProviderDef{}, fmt.Errorf("provider %q: file %s: content is required", def.Name, f.Path)
+		}
+		secrets = append(secrets, provider.FileSecret{
+			Path:        f.Path,
+			Content:     f.Content,
+			Placeholder: tokenRef,
+			Hosts:       def.FileSecretHosts(f),
+		})
+	}
+	if err := provider.ValidateFileSecrets(secrets); err != nil {
+		return ProviderDef{}, fmt.Errorf("provider %q: %w", def.Name, err)
 	}
 	return def, nil
 }
@@ -145,7 +145,7 @@ func TestParseProviderDefValidation(t *testing.T) {
 		{
 			name:    "missing inject header and container_env",
 			yaml:    "name: test\ndescription: Test\nhosts: [example.com]\n",
-			wantErr: "inject.header or container_env is required",
+			wantErr: "inject.header, container_env, or files is required",
 		},
 	}
 	for _, tt := range tests {
@@ -253,7 +253,45 @@ hosts: ["example.com"]
 	if err == nil {
 		t.Fatal("expected error for missing inject.header and container_env")
 	}
-	if !strings.Contains(err.Error(), "inject.header or container_env is required") {
-		t.Errorf("error = %q, want to contain 'inject.header or container_env is required'", err.Error())
+	if !strings.Contains(err.Error(), "inject.header, container_env, or files is required") {
+		t.Errorf("error = %q, want to contain 'inject.header, container_env, or files is required'", err.Error())
+	}
+}
+
+func TestParseProviderDefFiles(t *testing.T) {
+	yaml := `
+name: acme
+description: "Acme CLI"
+hosts: ["api.acme.example"]
+files:
+  - path: ~/.acme/credentials.json
+    content: '{"token": "${token}"}'
+    hosts: ["api.acme.example", "auth.acme.example"]
+  - path: .acme/env
+    content: "ACME_TOKEN=${token}"
+`
+	def, err := parseProviderDef([]byte(yaml))
+	if err != nil {
+		t.Fatalf("parseProviderDef() error: %v", err)
+	}
+	if len(def.Files) != 2 {
+		t.Fatalf("Files = %+v, want 2", def.Files)
+	}
+	if got := def.FileSecretHosts(def.Files[1]); len(got) != 1 || got[0] != "api.acme.example" {
+		t.Errorf("FileSecretHosts(default) = %v, want the provider's hosts", got)
+	}
+	if got := def.GrantHosts(); len(got) != 2 || got[1] != "auth.acme.example" {
+		t.Errorf("GrantHosts() = %v, want the provider's and the files' hosts", got)
+	}
+
+	for name, files := range map[string]string{
+		"no token":      `[{path: .acme/creds, content: "static"}]`,
+		"no content":    `[{path: .acme/creds}]`,
+		"absolute path": `[{path: /etc/acme, content: "${token}"}]`,
+	} {
+		bad := "name: acme\ndescription: Acme\nhosts: [api.acme.example]\nfiles: " + files + "\n"
+		if _, err := parseProviderDef([]byte(bad)); err == nil {
+			t.Errorf("%s: parseProviderDef() = nil, want error", name)
+		}
 	}
 }
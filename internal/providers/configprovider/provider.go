@@ -26,6 +26,10 @@ func tokenSubPlaceholder(token string) string {
 	return "moat-" + hex.EncodeToString(h[:8])
 }
 
+// tokenRef marks where the token goes in a definition's validate URL and
+// file contents.
+const tokenRef = "${token}"
+
 // maxScrubBodySize is the maximum response body size for token scrubbing.
 // Larger responses are passed through unscrubbed to avoid memory issues.
 const maxScrubBodySize = 512 * 1024
@@ -90,6 +94,7 @@ type ConfigProvider struct {
 var (
 	_ provider.CredentialProvider  = (*ConfigProvider)(nil)
 	_ provider.DescribableProvider = (*ConfigProvider)(nil)
+	_ provider.InitFileProvider    = (*fileConfigProvider)(nil)
 )
 
 // NewConfigProvider creates a new ConfigProvider from a definition.
@@ -113,13 +118,18 @@ func (p *ConfigProvider) Grant(ctx context.Context) (*provider.Credential, error
 	}
 
 	// Interactive prompt
+	if len(p.def.SourceEnv) > 0 {
+		if err := util.RequireInput(ctx, "set "+strings.Join(p.def.SourceEnv, " or ")); err != nil {
+			return nil, err
+		}
+	}
 	if p.def.Prompt != "" {
 		fmt.Print(p.def.Prompt)
 	} else {
 		fmt.Printf("Enter a %s token.\n", p.def.Description)
 	}
 
-	token, err := util.PromptForToken("Token")
+	token, err := util.PromptForToken(ctx, "Token")
 	if err != nil {
 		return nil, fmt.Errorf("reading token: %w", err)
 	}
@@ -170,9 +180,9 @@ func (p *ConfigProvider) validateToken(ctx context.Context, token string) error
 
 	// Check if the URL contains a token placeholder
 	url := v.URL
-	tokenInURL := strings.Contains(url, "${token}")
+	tokenInURL := strings.Contains(url, tokenRef)
 	if tokenInURL {
-		url = strings.ReplaceAll(url, "${token}", token)
+		url = strings.ReplaceAll(url, tokenRef, token)
 	}
 
 	client := &http.Client{}
@@ -230,6 +240,7 @@ func (p *ConfigProvider) validateToken(ctx context.Context, token string) error
 // token in URL paths, Authorization headers, and request bodies. This is used
 // for APIs like Telegram Bot API where the token is embedded in the URL path.
 func (p *ConfigProvider) ConfigureProxy(proxy provider.ProxyConfigurer, cred *provider.Credential) {
+	p.configureFileSecrets(proxy, cred)
 	if p.def.HasHeaderInjection() {
 		headerValue := p.def.Inject.Prefix + cred.Token
 		for _, host := range p.def.Hosts {
@@ -254,6 +265,51 @@ func (p *ConfigProvider) ConfigureProxy(proxy provider.ProxyConfigurer, cred *pr
 	}
 }
 
+// fileSecrets declares the definition's files for cred. Every file carries
+// the same per-credential placeholder token substitution uses.
+func (p *ConfigProvider) fileSecrets(cred *provider.Credential) []provider.FileSecret {
+	if len(p.def.Files) == 0 {
+		return nil
+	}
+	placeholder := tokenSubPlaceholder(cred.Token)
+	secrets := make([]provider.FileSecret, 0, len(p.def.Files))
+	for _, f := range p.def.Files {
+		secrets = append(secrets, provider.FileSecret{
+			Path:        f.Path,
+			Content:     strings.ReplaceAll(f.Content, tokenRef, placeholder),
+			Placeholder: placeholder,
+			Hosts:       p.def.FileSecretHosts(f),
+		})
+	}
+	return secrets
+}
+
+// configureFileSecrets substitutes the token for the placeholder in the
+// definition's files on their hosts, and scrubs it from the responses of
+// hosts not already scrubbed by ConfigureProxy.
+func (p *ConfigProvider) configureFileSecrets(proxy provider.ProxyConfigurer, cred *provider.Credential) {
+	secrets := p.fileSecrets(cred)
+	if len(secrets) == 0 {
+		return
+	}
+	provider.ConfigureFileSecrets(proxy, cred, secrets)
+	placeholder := tokenSubPlaceholder(cred.Token)
+	scrubbed := make(map[string]bool)
+	if !p.def.HasHeaderInjection() {
+		for _, host := range p.def.Hosts {
+			scrubbed[host] = true
+		}
+	}
+	for _, s := range secrets {
+		for _, host := range s.Hosts {
+			if !scrubbed[host] {
+				scrubbed[host] = true
+				proxy.AddResponseTransformer(host, buildResponseScrubber(cred.Token, placeholder))
+			}
+		}
+	}
+}
+
 // ContainerEnv returns environment variables to set in the container.
 // The env var is always set to a placeholder — the real token is injected by
 // the proxy at the network layer (either via header injection or token substitution).
@@ -292,3 +348,16 @@ func (p *ConfigProvider) Description() string {
 func (p *ConfigProvider) Source() string {
 	return p.source
 }
+
+// fileConfigProvider is a ConfigProvider whose definition declares files.
+// Only these implement provider.InitFileProvider, so other definitions'
+// images don't carry init-file support they never use.
+type fileConfigProvider struct {
+	*ConfigProvider
+}
+
+// ContainerInitFiles writes the definition's files with the placeholder in
+// place of the token.
+func (p *fileConfigProvider) ContainerInitFiles(cred *provider.Credential, containerHome string) map[string]string {
+	return provider.FileSecretInitFiles(p.fileSecrets(cred), containerHome)
+}
@@ -281,6 +281,54 @@ func TestConfigureProxyTokenSubstitution(t *testing.T) {
 	}
 }
 
+func TestConfigureProxyFiles(t *testing.T) {
+	def := ProviderDef{
+		Name:   "acme",
+		Hosts:  []string{"api.acme.example"},
+		Inject: InjectConfig{Header: "Authorization", Prefix: "Bearer "},
+		Files: []FileDef{{
+			Path:    ".acme/credentials.json",
+			Content: `{"token": "${token}"}`,
+			Hosts:   []string{"api.acme.example", "auth.acme.example"},
+		}},
+	}
+	cp := &fileConfigProvider{NewConfigProvider(def, "custom")}
+	mock := &mockProxy{}
+	token := "acme-real-token"
+	cred := &provider.Credential{Token: token}
+	cp.ConfigureProxy(mock, cred)
+
+	// The file's placeholder is substituted on both of its hosts.
+	if len(mock.tokenSubs) != 2 {
+		t.Fatalf("token substitutions = %+v, want 2", mock.tokenSubs)
+	}
+	placeholder := tokenSubPlaceholder(token)
+	for i, host := range []string{"api.acme.example", "auth.acme.example"} {
+		if sub := mock.tokenSubs[i]; sub.host != host || sub.placeholder != placeholder || sub.realToken != token {
+			t.Errorf("tokenSubs[%d] = %+v, want %s substituted on %s", i, sub, placeholder, host)
+		}
+	}
+	// Header injection is unchanged.
+	if len(mock.calls) != 1 || mock.calls[0].headerValue != "Bearer "+token {
+		t.Errorf("header calls = %+v, want the Bearer token on api.acme.example", mock.calls)
+	}
+	if mock.transformers != 2 {
+		t.Errorf("response transformers = %d, want a scrubber per file host", mock.transformers)
+	}
+
+	files := cp.ContainerInitFiles(cred, "/home/moatuser")
+	content, ok := files["/home/moatuser/.acme/credentials.json"]
+	if !ok {
+		t.Fatalf("ContainerInitFiles() = %v, want ~/.acme/credentials.json", files)
+	}
+	if content != `{"token": "`+placeholder+`"}` {
+		t.Errorf("file content = %q, want the placeholder in place of ${token}", content)
+	}
+	if strings.Contains(content, token) {
+		t.Error("file content contains the real token")
+	}
+}
+
 func TestResponseScrubber(t *testing.T) {
 	realToken := "123456:ABC-DEF-secret"
 	placeholder := "moat-abc123"
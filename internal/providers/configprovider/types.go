@@ -1,5 +1,7 @@
 package configprovider
 
+import "slices"
+
 // ProviderDef defines a credential provider via YAML configuration.
 type ProviderDef struct {
 	Name         string          `yaml:"name"`
@@ -11,6 +13,7 @@ type ProviderDef struct {
 	ContainerEnv string          `yaml:"container_env,omitempty"`
 	Validate     *ValidateConfig `yaml:"validate,omitempty"`
 	Prompt       string          `yaml:"prompt,omitempty"`
+	Files        []FileDef       `yaml:"files,omitempty"`
 }
 
 // HasHeaderInjection returns true if the provider injects credentials via HTTP headers.
@@ -20,6 +23,38 @@ func (d ProviderDef) HasHeaderInjection() bool {
 	return d.Inject.Header != ""
 }
 
+// FileDef declares a file written into the container that embeds the
+// credential, such as a CLI's credentials file. ${token} in Content is
+// written as a placeholder, which the proxy replaces with the real token on
+// requests to Hosts.
+type FileDef struct {
+	Path    string   `yaml:"path"`            // relative to the container home
+	Content string   `yaml:"content"`         // must contain ${token}
+	Hosts   []string `yaml:"hosts,omitempty"` // default: the provider's hosts
+}
+
+// FileSecretHosts returns the hosts f's placeholder is substituted on.
+func (d ProviderDef) FileSecretHosts(f FileDef) []string {
+	if len(f.Hosts) > 0 {
+		return f.Hosts
+	}
+	return d.Hosts
+}
+
+// GrantHosts returns every host the provider configures: its hosts plus
+// the hosts of its files.
+func (d ProviderDef) GrantHosts() []string {
+	hosts := append([]string(nil), d.Hosts...)
+	for _, f := range d.Files {
+		for _, h := range f.Hosts {
+			if !slices.Contains(hosts, h) {
+				hosts = append(hosts, h)
+			}
+		}
+	}
+	return hosts
+}
+
 // InjectConfig defines how credentials are injected into HTTP requests.
 type InjectConfig struct {
 	Header string `yaml:"header"`
@@ -0,0 +1,103 @@
+package containerregistry
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/majorcontext/moat/internal/provider/util"
+)
+
+// apiURL returns the registry API base URL for host. A variable so tests can
+// point it at a local server.
+var apiURL = func(host string) string {
+	return "https://" + host + "/v2/"
+}
+
+// challengeParam matches a key="value" parameter of a WWW-Authenticate
+// challenge.
+var challengeParam = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+// challenge is a parsed WWW-Authenticate header.
+type challenge struct {
+	scheme string            // "bearer" or "basic", lowercased
+	params map[string]string // realm, service, ...
+}
+
+// parseChallenge parses a WWW-Authenticate header.
+func parseChallenge(header string) challenge {
+	scheme, rest, _ := strings.Cut(strings.TrimSpace(header), " ")
+	c := challenge{scheme: strings.ToLower(scheme), params: make(map[string]string)}
+	for _, m := range challengeParam.FindAllStringSubmatch(rest, -1) {
+		c.params[strings.ToLower(m[1])] = m[2]
+	}
+	return c
+}
+
+// probe verifies r's credentials and returns the host Docker clients send
+// them to. It asks the registry API how it authenticates, then either
+// requests a token from the token service or retries the API with Basic
+// auth.
+func probe(ctx context.Context, r Registry) (authHost string, err error) {
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+
+	base := apiURL(r.APIHost())
+	req, err := http.NewRequestWithContext(ctx, "GET", base, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("User-Agent", "moat")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("contacting %s: %w", req.URL.Host, err)
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		// No authentication at all; clients send none.
+		return req.URL.Host, nil
+	case http.StatusUnauthorized:
+	default:
+		return "", fmt.Errorf("%s is not a container registry (GET /v2/ returned %d)", req.URL.Host, resp.StatusCode)
+	}
+
+	c := parseChallenge(resp.Header.Get("WWW-Authenticate"))
+	var check *http.Request
+	switch c.scheme {
+	case "bearer":
+		realm, err := url.Parse(c.params["realm"])
+		if err != nil || realm.Host == "" {
+			return "", fmt.Errorf("%s returned an invalid token realm %q", req.URL.Host, c.params["realm"])
+		}
+		q := realm.Query()
+		if svc := c.params["service"]; svc != "" {
+			q.Set("service", svc)
+		}
+		realm.RawQuery = q.Encode()
+		authHost = realm.Host
+		check, err = http.NewRequestWithContext(ctx, "GET", realm.String(), nil)
+		if err != nil {
+			return "", err
+		}
+	case "basic":
+		authHost = req.URL.Host
+		check = req.Clone(ctx)
+	default:
+		return "", fmt.Errorf("%s uses unsupported authentication %q", req.URL.Host, c.scheme)
+	}
+
+	check.Header.Set("Authorization", "Basic "+r.RealAuth())
+	check.Header.Set("User-Agent", "moat")
+	if err := util.ProbeCredential(ctx, check, http.StatusUnauthorized, http.StatusForbidden); err != nil {
+		return "", err
+	}
+	return authHost, nil
+}
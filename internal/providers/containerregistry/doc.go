@@ -0,0 +1,15 @@
+// Package containerregistry implements a credential provider for container
+// image registries such as Docker Hub and ghcr.io (grant name
+// "container-registry").
+//
+// Registries authenticate pulls with HTTP Basic credentials, either sent
+// directly or, for most registries, exchanged at a token service for a
+// short-lived Bearer token. The container gets a ~/.docker/config.json whose
+// passwords are placeholders; the proxy rewrites the placeholder Basic
+// credentials to the real ones on requests to the registry's auth host, and
+// leaves the Bearer tokens the token service issues alone.
+//
+// This makes docker pull work under docker:dind, where the run's own Docker
+// daemon sends its registry traffic through the proxy, and in tools like
+// crane and skopeo that read the same config file.
+package containerregistry
@@ -0,0 +1,128 @@
+package containerregistry
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/majorcontext/moat/internal/credential"
+	"github.com/majorcontext/moat/internal/provider"
+	"github.com/majorcontext/moat/internal/provider/util"
+)
+
+// GrantOptions carries the grant arguments.
+type GrantOptions struct {
+	Registry string // registry argument
+	Username string // --username
+}
+
+// ctxKeyOptions is the context key for GrantOptions.
+type ctxKeyOptions struct{}
+
+// WithGrantOptions returns a context carrying the grant arguments.
+func WithGrantOptions(ctx context.Context, opts GrantOptions) context.Context {
+	return context.WithValue(ctx, ctxKeyOptions{}, opts)
+}
+
+// Grant adds a registry to the credential, replacing an earlier grant for
+// the same registry. The username is read from --username or
+// CONTAINER_REGISTRY_USERNAME and the password from
+// CONTAINER_REGISTRY_PASSWORD, or both are prompted for.
+func (p *Provider) Grant(ctx context.Context) (*provider.Credential, error) {
+	opts, _ := ctx.Value(ctxKeyOptions{}).(GrantOptions)
+	host, err := NormalizeHost(opts.Registry)
+	if err != nil {
+		return nil, &provider.GrantError{
+			Provider: "container-registry",
+			Cause:    err,
+			Hint:     "Run 'moat grant container-registry <registry>', e.g. ghcr.io or docker.io",
+		}
+	}
+
+	r := Registry{Host: host, Username: opts.Username, TokenSource: SourceEnv}
+	if r.Username == "" {
+		r.Username = os.Getenv("CONTAINER_REGISTRY_USERNAME")
+	}
+	r.Password = os.Getenv("CONTAINER_REGISTRY_PASSWORD")
+	if r.Username == "" || r.Password == "" {
+		if err := util.RequireInput(ctx, "pass --username and set CONTAINER_REGISTRY_PASSWORD"); err != nil {
+			return nil, err
+		}
+		r.TokenSource = SourceManual
+	}
+	if r.Username == "" {
+		fmt.Printf("Username for %s: ", host)
+		line, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		r.Username = strings.TrimSpace(line)
+	}
+	if r.Password == "" {
+		fmt.Println(passwordHelp(host))
+		r.Password, err = util.PromptForToken(ctx, "Password or token")
+		if err != nil {
+			return nil, fmt.Errorf("reading password: %w", err)
+		}
+	} else {
+		fmt.Println("Using password from CONTAINER_REGISTRY_PASSWORD environment variable")
+	}
+	if r.Username == "" || r.Password == "" {
+		return nil, &provider.GrantError{
+			Provider: "container-registry",
+			Cause:    fmt.Errorf("username and password are required"),
+			Hint:     "Run 'moat grant container-registry " + host + "' and enter both",
+		}
+	}
+
+	fmt.Print("Validating... ")
+	r.AuthHost, err = probe(ctx, r)
+	if err != nil {
+		fmt.Println()
+		return nil, &provider.GrantError{
+			Provider: "container-registry",
+			Cause:    fmt.Errorf("validation failed for %s: %w", host, err),
+			Hint:     "Check the username and password, and that the token can read packages",
+		}
+	}
+	fmt.Println("✓")
+
+	registries, _ := loadExisting()
+	token, err := MarshalRegistries(MergeRegistry(registries, r))
+	if err != nil {
+		return nil, err
+	}
+	return &provider.Credential{
+		Provider:  "container-registry",
+		Token:     token,
+		CreatedAt: time.Now(),
+	}, nil
+}
+
+// passwordHelp returns where to create a token for host.
+func passwordHelp(host string) string {
+	switch host {
+	case "ghcr.io":
+		return "Enter a GitHub personal access token (classic) with the read:packages scope.\nCreate one at: https://github.com/settings/tokens"
+	case DockerHub:
+		return "Enter a Docker Hub personal access token with read access.\nCreate one at: https://app.docker.com/settings/personal-access-tokens"
+	}
+	return "Enter the password or access token for " + host + "."
+}
+
+// loadExisting loads the registries already granted.
+func loadExisting() ([]Registry, error) {
+	key, err := credential.DefaultEncryptionKey()
+	if err != nil {
+		return nil, err
+	}
+	store, err := credential.NewFileStore(credential.DefaultStoreDir(), key)
+	if err != nil {
+		return nil, err
+	}
+	cred, err := store.Get(credential.ProviderContainerRegistry)
+	if err != nil {
+		return nil, err
+	}
+	return UnmarshalRegistries(cred.Token)
+}
@@ -0,0 +1,93 @@
+package containerregistry
+
+import (
+	"context"
+	"path/filepath"
+
+	"github.com/majorcontext/moat/internal/provider"
+)
+
+// Provider implements provider.CredentialProvider for container registries.
+type Provider struct{}
+
+// Verify interface compliance at compile time.
+var (
+	_ provider.CredentialProvider = (*Provider)(nil)
+	_ provider.InitFileProvider   = (*Provider)(nil)
+	_ provider.CredentialChecker  = (*Provider)(nil)
+)
+
+func init() {
+	provider.Register(&Provider{})
+}
+
+// Name returns the provider identifier.
+func (p *Provider) Name() string {
+	return "container-registry"
+}
+
+// ConfigureProxy substitutes each registry's real Basic credentials for the
+// placeholder ones on requests to its auth host.
+func (p *Provider) ConfigureProxy(proxy provider.ProxyConfigurer, cred *provider.Credential) {
+	registries, err := UnmarshalRegistries(cred.Token)
+	if err != nil {
+		return
+	}
+	for _, r := range registries {
+		if r.AuthHost != "" {
+			proxy.SetTokenSubstitution(r.AuthHost, r.PlaceholderAuth(), r.RealAuth())
+		}
+	}
+}
+
+// ContainerEnv returns no environment variables.
+func (p *Provider) ContainerEnv(cred *provider.Credential) []string {
+	return nil
+}
+
+// ContainerInitFiles writes ~/.docker/config.json with placeholder
+// credentials. It is written at startup rather than mounted because the
+// Docker CLI keeps other state next to it.
+func (p *Provider) ContainerInitFiles(cred *provider.Credential, containerHome string) map[string]string {
+	registries, err := UnmarshalRegistries(cred.Token)
+	if err != nil || len(registries) == 0 {
+		return nil
+	}
+	return map[string]string{
+		DockerConfigPath(containerHome): GenerateDockerConfig(registries),
+	}
+}
+
+// DockerConfigPath returns the path of the Docker config file in the
+// container.
+func DockerConfigPath(containerHome string) string {
+	return filepath.Join(containerHome, ".docker", "config.json")
+}
+
+// ContainerMounts returns no mounts — the config is written via moat-init.sh.
+func (p *Provider) ContainerMounts(cred *provider.Credential, containerHome string) ([]provider.MountConfig, string, error) {
+	return nil, "", nil
+}
+
+// Cleanup is a no-op — no temp files are created.
+func (p *Provider) Cleanup(cleanupPath string) {}
+
+// ImpliedDependencies returns dependencies implied by this provider.
+func (p *Provider) ImpliedDependencies() []string {
+	return nil
+}
+
+// CheckCredential verifies every granted registry still accepts its
+// credentials.
+func (p *Provider) CheckCredential(ctx context.Context, cred *provider.Credential) error {
+	registries, err := UnmarshalRegistries(cred.Token)
+	if err != nil {
+		return err
+	}
+	for _, r := range registries {
+		if _, err := probe(ctx, r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+package containerregistry
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/majorcontext/moat/internal/credential"
+)
+
+// Docker Hub has three names: the one users type, the host serving the
+// registry API, and the key Docker clients file its credentials under.
+const (
+	DockerHub          = "docker.io"
+	DockerHubAPIHost   = "registry-1.docker.io"
+	DockerHubConfigKey = "https://index.docker.io/v1/"
+)
+
+// Placeholder is the password written to the container's Docker config. The
+// proxy replaces the Basic credentials built from it with the real ones.
+const Placeholder = credential.ProxyInjectedPlaceholder
+
+// Token source values stored in Registry.TokenSource.
+const (
+	SourceEnv    = "env"    // From CONTAINER_REGISTRY_PASSWORD
+	SourceManual = "manual" // Interactive prompt entry
+)
+
+// Registry is one granted container registry.
+type Registry struct {
+	Host     string `json:"host"`     // registry name, e.g. "ghcr.io" or "docker.io"
+	Username string `json:"username"` // account name
+	Password string `json:"password"` // password or access token
+
+	// AuthHost is the host Docker clients send the Basic credentials to:
+	// the token service for registries that issue Bearer tokens (e.g.
+	// auth.docker.io), or the registry itself. Found at grant time.
+	AuthHost string `json:"auth_host"`
+
+	TokenSource string `json:"token_source,omitempty"`
+}
+
+// APIHost returns the host serving the registry API.
+func (r Registry) APIHost() string {
+	if r.Host == DockerHub {
+		return DockerHubAPIHost
+	}
+	return r.Host
+}
+
+// ConfigKey returns the key for the registry in a Docker config file.
+func (r Registry) ConfigKey() string {
+	if r.Host == DockerHub {
+		return DockerHubConfigKey
+	}
+	return r.Host
+}
+
+// basicAuth returns the base64 Basic credentials for username and password.
+func basicAuth(username, password string) string {
+	return base64.StdEncoding.EncodeToString([]byte(username + ":" + password))
+}
+
+// PlaceholderAuth returns the Basic credentials the container sends.
+func (r Registry) PlaceholderAuth() string {
+	return basicAuth(r.Username, Placeholder)
+}
+
+// RealAuth returns the Basic credentials the proxy substitutes.
+func (r Registry) RealAuth() string {
+	return basicAuth(r.Username, r.Password)
+}
+
+// NormalizeHost returns the registry name for raw, which may be a bare host
+// ("ghcr.io", "registry.example.com:5000") or a URL. Docker Hub's aliases
+// map to DockerHub.
+func NormalizeHost(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return "", fmt.Errorf("a registry is required")
+	}
+	if !strings.Contains(raw, "://") {
+		raw = "https://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", fmt.Errorf("invalid registry %q: %w", raw, err)
+	}
+	if u.Scheme != "https" {
+		return "", fmt.Errorf("invalid registry %q: registries must be reached over https", raw)
+	}
+	if u.Host == "" || u.User != nil {
+		return "", fmt.Errorf("invalid registry %q: need a host and no embedded credentials", raw)
+	}
+	if p := strings.Trim(u.Path, "/"); p != "" && p != "v1" && p != "v2" {
+		return "", fmt.Errorf("invalid registry %q: give the registry host, not an image (e.g. ghcr.io)", raw)
+	}
+	host := strings.ToLower(u.Host)
+	switch host {
+	case "docker.io", "index.docker.io", "registry-1.docker.io", "hub.docker.com", "registry.hub.docker.com":
+		return DockerHub, nil
+	}
+	return host, nil
+}
+
+// MarshalRegistries encodes registries for storage in the Token field.
+func MarshalRegistries(registries []Registry) (string, error) {
+	data, err := json.Marshal(registries)
+	if err != nil {
+		return "", fmt.Errorf("marshaling registries: %w", err)
+	}
+	return string(data), nil
+}
+
+// UnmarshalRegistries decodes registries from the JSON-encoded Token field.
+func UnmarshalRegistries(token string) ([]Registry, error) {
+	var registries []Registry
+	if err := json.Unmarshal([]byte(token), &registries); err != nil {
+		return nil, fmt.Errorf("unmarshaling registries: %w", err)
+	}
+	return registries, nil
+}
+
+// MergeRegistry adds r to registries, replacing any entry for the same host.
+func MergeRegistry(registries []Registry, r Registry) []Registry {
+	for i, e := range registries {
+		if e.Host == r.Host {
+			registries[i] = r
+			return registries
+		}
+	}
+	return append(registries, r)
+}
+
+// dockerConfig is the subset of ~/.docker/config.json the provider writes.
+type dockerConfig struct {
+	Auths map[string]dockerAuth `json:"auths"`
+}
+
+type dockerAuth struct {
+	Auth string `json:"auth"`
+}
+
+// GenerateDockerConfig returns a Docker config file with placeholder
+// credentials for each registry.
+func GenerateDockerConfig(registries []Registry) string {
+	cfg := dockerConfig{Auths: make(map[string]dockerAuth, len(registries))}
+	for _, r := range registries {
+		cfg.Auths[r.ConfigKey()] = dockerAuth{Auth: r.PlaceholderAuth()}
+	}
+	data, _ := json.MarshalIndent(cfg, "", "\t")
+	return string(data) + "\n"
+}
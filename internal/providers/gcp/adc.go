@@ -0,0 +1,109 @@
+package gcp
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// Metadata keys stored on Google credentials. The gcp and bigquery grants
+// share them. Secrets (refresh_token, client_secret, private_key) never
+// leave the host.
+const (
+	MetaKeyAuthType     = "auth_type"     // AuthTypeUser or AuthTypeServiceAccount
+	MetaKeyProject      = "project"       // default project for the container
+	MetaKeyClientEmail  = "client_email"  // service account email
+	MetaKeyClientID     = "client_id"     // OAuth client of an authorized_user
+	MetaKeyClientSecret = "client_secret" // OAuth client secret of an authorized_user
+	MetaKeyRefreshToken = "refresh_token" // refresh token of an authorized_user
+	MetaKeyPrivateKey   = "private_key"   // PEM key of a service account
+	MetaKeyTokenURL     = "token_url"     // OAuth token endpoint
+	MetaKeyEmail        = "email"         // account of an authorized_user, if known
+	MetaKeyScopes       = "scopes"        // space-separated scopes of an authorized_user
+)
+
+// Application default credential types.
+const (
+	AuthTypeUser           = "authorized_user"
+	AuthTypeServiceAccount = "service_account"
+)
+
+// ADC is the subset of an application default credentials file moat reads.
+type ADC struct {
+	Type           string `json:"type"`
+	ClientID       string `json:"client_id"`
+	ClientSecret   string `json:"client_secret"`
+	RefreshToken   string `json:"refresh_token"`
+	QuotaProjectID string `json:"quota_project_id"`
+	ClientEmail    string `json:"client_email"`
+	PrivateKey     string `json:"private_key"`
+	TokenURI       string `json:"token_uri"`
+	ProjectID      string `json:"project_id"`
+}
+
+// ADCPath returns the credentials file to read: path if set, otherwise
+// GOOGLE_APPLICATION_CREDENTIALS, otherwise where gcloud writes application
+// default credentials.
+func ADCPath(path string) string {
+	if path != "" {
+		return path
+	}
+	if env := os.Getenv("GOOGLE_APPLICATION_CREDENTIALS"); env != "" {
+		return env
+	}
+	if dir := os.Getenv("CLOUDSDK_CONFIG"); dir != "" {
+		return filepath.Join(dir, "application_default_credentials.json")
+	}
+	if runtime.GOOS == "windows" {
+		return filepath.Join(os.Getenv("APPDATA"), "gcloud", "application_default_credentials.json")
+	}
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".config", "gcloud", "application_default_credentials.json")
+}
+
+// ReadADC reads an application default credentials file. A missing or
+// unreadable file is reported as an *fs.PathError.
+func ReadADC(path string) (*ADC, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading application default credentials: %w", err)
+	}
+	var adc ADC
+	if err := json.Unmarshal(data, &adc); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return &adc, nil
+}
+
+// Metadata returns the credential metadata that access tokens are minted
+// from: the auth type, the refresh token or service account key, and the
+// token endpoint if the file names one.
+func (a *ADC) Metadata() (map[string]string, error) {
+	meta := map[string]string{MetaKeyAuthType: a.Type}
+	switch a.Type {
+	case AuthTypeUser:
+		meta[MetaKeyClientID] = a.ClientID
+		meta[MetaKeyClientSecret] = a.ClientSecret
+		meta[MetaKeyRefreshToken] = a.RefreshToken
+	case AuthTypeServiceAccount:
+		meta[MetaKeyClientEmail] = a.ClientEmail
+		meta[MetaKeyPrivateKey] = a.PrivateKey
+	default:
+		return nil, fmt.Errorf("unsupported credential type %q", a.Type)
+	}
+	if a.TokenURI != "" {
+		meta[MetaKeyTokenURL] = a.TokenURI
+	}
+	return meta, nil
+}
+
+// Project returns the project named in the file: the quota project of user
+// credentials or the project of a service account key.
+func (a *ADC) Project() string {
+	if a.QuotaProjectID != "" {
+		return a.QuotaProjectID
+	}
+	return a.ProjectID
+}
@@ -0,0 +1,36 @@
+// Package gcp implements the Google Cloud credential provider for moat.
+//
+// Like the Azure provider, GCP uses a credential endpoint instead of header
+// injection. The daemon emulates the parts of the GCE metadata server
+// (metadata.google.internal) that Google client libraries use to find
+// credentials: the project ID, the default service account's email and
+// scopes, and its access token. Tokens are minted on demand from the host's
+// application default credentials and cached until shortly before they
+// expire.
+//
+// The refresh token or service account key stays in the encrypted credential
+// store; the daemon reads it from there, so it never travels over the
+// register API or into the container.
+//
+// The container is configured with GCE_METADATA_HOST and GCE_METADATA_IP
+// pointing at a synthetic hostname plus the endpoint path. Requests to it go
+// through the proxy, which authenticates them with Proxy-Authorization and
+// serves them from the run's credential endpoint handler. Clients must
+// therefore send metadata requests through HTTP_PROXY and accept a path in
+// GCE_METADATA_HOST; google-auth for Python (and the google-cloud-* Python
+// libraries built on it) does both.
+//
+// Grant flow:
+//  1. User runs `moat grant gcp` after `gcloud auth application-default
+//     login`, or with --credentials-file for a service account key
+//  2. An access token is minted and checked against Google's tokeninfo
+//     endpoint, which also reports the user's email and scopes
+//  3. Refresh token or key stored in Metadata, project in Metadata
+//
+// Runtime flow:
+//  1. A client library in the container pings the metadata server and asks
+//     for instance/service-accounts/default/token
+//  2. The request goes through the proxy to the run's credential endpoint
+//     handler
+//  3. The daemon returns a cached token or mints a new one
+package gcp
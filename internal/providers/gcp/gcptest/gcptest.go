@@ -0,0 +1,76 @@
+// Package gcptest provides fake Google OAuth endpoints and credential files
+// for tests of providers built on the gcp token code.
+package gcptest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// NewTokenServer returns a fake OAuth token endpoint that issues "at-<n>"
+// tokens for refresh token and JWT bearer grants, or answers invalid_grant
+// while *revoked is set. revoked may be nil. Refresh grants must carry the
+// refresh token "rt".
+func NewTokenServer(t *testing.T, revoked *bool) *httptest.Server {
+	t.Helper()
+	var n int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Fatal(err)
+		}
+		switch r.PostForm.Get("grant_type") {
+		case "refresh_token":
+			if r.PostForm.Get("refresh_token") != "rt" {
+				t.Errorf("refresh_token = %q", r.PostForm.Get("refresh_token"))
+			}
+		case "urn:ietf:params:oauth:grant-type:jwt-bearer":
+			if r.PostForm.Get("assertion") == "" {
+				t.Error("missing assertion")
+			}
+		default:
+			t.Errorf("grant_type = %q", r.PostForm.Get("grant_type"))
+		}
+		if revoked != nil && *revoked {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"invalid_grant","error_description":"Token has been expired or revoked."}`))
+			return
+		}
+		n++
+		json.NewEncoder(w).Encode(map[string]any{"access_token": fmt.Sprintf("at-%d", n), "expires_in": 3600})
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// WriteADC writes adc as an application default credentials file in a
+// temporary directory and returns its path.
+func WriteADC(t *testing.T, adc map[string]string) string {
+	t.Helper()
+	data, _ := json.Marshal(adc)
+	path := filepath.Join(t.TempDir(), "adc.json")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// KeyPEM returns a new PKCS#8 RSA private key in PEM form, for service
+// account credentials.
+func KeyPEM(t *testing.T) string {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, _ := x509.MarshalPKCS8PrivateKey(key)
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+}
@@ -0,0 +1,155 @@
+package gcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/majorcontext/moat/internal/provider"
+)
+
+// GrantOptions carries the grant flags.
+type GrantOptions struct {
+	CredentialsFile string // --credentials-file
+	Project         string // --project
+}
+
+// ctxKeyOptions is the context key for GrantOptions.
+type ctxKeyOptions struct{}
+
+// WithGrantOptions returns a context carrying the grant flags.
+func WithGrantOptions(ctx context.Context, opts GrantOptions) context.Context {
+	return context.WithValue(ctx, ctxKeyOptions{}, opts)
+}
+
+// tokenInfoURL is Google's token introspection endpoint. A variable so tests
+// can point it at a local server.
+var tokenInfoURL = "https://oauth2.googleapis.com/tokeninfo"
+
+// grant reads application default credentials from --credentials-file,
+// GOOGLE_APPLICATION_CREDENTIALS, or gcloud's default location, mints an
+// access token, and checks it with tokeninfo.
+func grant(ctx context.Context) (*provider.Credential, error) {
+	opts, _ := ctx.Value(ctxKeyOptions{}).(GrantOptions)
+	path := ADCPath(opts.CredentialsFile)
+	adc, err := ReadADC(path)
+	if err != nil {
+		var pathErr *fs.PathError
+		if !errors.As(err, &pathErr) {
+			return nil, &provider.GrantError{Provider: "gcp", Cause: err}
+		}
+		return nil, &provider.GrantError{
+			Provider: "gcp",
+			Cause:    err,
+			Hint: "Run 'gcloud auth application-default login', or pass a service account key with\n" +
+				"'moat grant gcp --credentials-file <key.json>'",
+		}
+	}
+	meta, err := adc.Metadata()
+	if err != nil {
+		return nil, &provider.GrantError{
+			Provider: "gcp",
+			Cause:    fmt.Errorf("%w in %s", err, path),
+			Hint:     "Use user credentials from 'gcloud auth application-default login' or a service account key file",
+		}
+	}
+
+	project := opts.Project
+	if project == "" {
+		project = os.Getenv("GOOGLE_CLOUD_PROJECT")
+	}
+	if project == "" {
+		project = adc.Project()
+	}
+	if project != "" {
+		meta[MetaKeyProject] = project
+	}
+
+	fmt.Printf("Using %s credentials from %s\n", adc.Type, path)
+	token, expiresAt, err := FetchAccessToken(ctx, meta, []string{ScopeCloudPlatform})
+	if err != nil {
+		return nil, &provider.GrantError{
+			Provider: "gcp",
+			Cause:    fmt.Errorf("minting access token: %w", err),
+			Hint:     "Run 'gcloud auth application-default login' again, or check the service account key",
+		}
+	}
+
+	info, err := fetchTokenInfo(ctx, token)
+	if err != nil {
+		return nil, &provider.GrantError{
+			Provider: "gcp",
+			Cause:    fmt.Errorf("validation failed: %w", err),
+			Hint:     "Run 'gcloud auth application-default login' again, or check the service account key",
+		}
+	}
+	if adc.Type == AuthTypeUser {
+		if info.Email != "" {
+			meta[MetaKeyEmail] = info.Email
+		}
+		if info.Scope != "" {
+			meta[MetaKeyScopes] = info.Scope
+		}
+	}
+
+	account := meta[MetaKeyClientEmail]
+	if account == "" {
+		account = info.Email
+	}
+	if account != "" {
+		fmt.Printf("Account: %s\n", account)
+	}
+	if project != "" {
+		fmt.Printf("Default project: %s\n", project)
+	}
+
+	return &provider.Credential{
+		Provider:  "gcp",
+		Token:     token,
+		ExpiresAt: expiresAt,
+		CreatedAt: time.Now(),
+		Metadata:  meta,
+	}, nil
+}
+
+// tokenInfo is the subset of a tokeninfo response moat uses.
+type tokenInfo struct {
+	Email string `json:"email"`
+	Scope string `json:"scope"`
+}
+
+// fetchTokenInfo asks Google to introspect token. An invalid token is
+// answered with HTTP 400.
+func fetchTokenInfo(ctx context.Context, token string) (*tokenInfo, error) {
+	reqCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(reqCtx, "GET", tokenInfoURL+"?access_token="+url.QueryEscape(token), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "moat")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("tokeninfo request: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading tokeninfo response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("tokeninfo rejected the access token (HTTP %d)", resp.StatusCode)
+	}
+	var info tokenInfo
+	if err := json.Unmarshal(body, &info); err != nil {
+		return nil, fmt.Errorf("parsing tokeninfo response: %w", err)
+	}
+	return &info, nil
+}
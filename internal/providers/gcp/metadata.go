@@ -0,0 +1,210 @@
+package gcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/majorcontext/moat/internal/log"
+	"github.com/majorcontext/moat/internal/provider"
+)
+
+// EndpointPath is where the metadata server is served on the proxy.
+// Gatekeeper hands every request under /_aws/credentials to the run's
+// credential endpoint handler, so the GCP endpoint lives beneath it.
+const EndpointPath = "/_aws/credentials/gcp"
+
+// tokenRefreshBuffer is the time before expiration when a cached token is
+// replaced. Google client libraries refresh a token once it is within
+// 3m45s of expiring, so handing out one closer than that causes a loop.
+const tokenRefreshBuffer = 5 * time.Minute
+
+// Token is an access token for one set of scopes.
+type Token struct {
+	AccessToken string
+	ExpiresOn   time.Time
+}
+
+// MetadataHandler serves the subset of the GCE metadata server API that
+// Google client libraries use to discover credentials.
+type MetadataHandler struct {
+	cfg Config
+
+	mu     sync.Mutex
+	cached map[string]*Token // by space-joined scopes
+
+	// fetch mints a token (injectable for testing)
+	fetch func(ctx context.Context, scopes []string) (*Token, error)
+}
+
+// NewMetadataHandler creates a metadata server handler for cfg.
+func NewMetadataHandler(cfg Config) *MetadataHandler {
+	h := &MetadataHandler{cfg: cfg, cached: make(map[string]*Token)}
+	h.fetch = func(ctx context.Context, scopes []string) (*Token, error) {
+		token, expiresAt, err := FetchAccessToken(ctx, h.cfg.Meta, scopes)
+		if err != nil {
+			return nil, err
+		}
+		return &Token{AccessToken: token, ExpiresOn: expiresAt}, nil
+	}
+	return h
+}
+
+// SetFetcher replaces how tokens are minted (for testing).
+func (h *MetadataHandler) SetFetcher(fetch func(ctx context.Context, scopes []string) (*Token, error)) {
+	h.fetch = fetch
+}
+
+// ServeHTTP implements http.Handler.
+func (h *MetadataHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Clients detect the metadata server by this response header.
+	w.Header().Set("Metadata-Flavor", "Google")
+
+	path := strings.TrimPrefix(r.URL.Path, EndpointPath)
+	if path == "" || path == "/" {
+		writeText(w, "computeMetadata/\n")
+		return
+	}
+	// Like the real server, refuse requests without the header, which a
+	// browser or an SSRF'd fetch would not send.
+	if r.Header.Get("Metadata-Flavor") != "Google" {
+		http.Error(w, "Missing Metadata-Flavor:Google header.", http.StatusForbidden)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	key, ok := strings.CutPrefix(path, "/computeMetadata/v1/")
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch key {
+	case "project/project-id":
+		if h.cfg.Project == "" {
+			http.NotFound(w, r)
+			return
+		}
+		writeText(w, h.cfg.Project)
+		return
+	case "universe/universe-domain":
+		writeText(w, "googleapis.com")
+		return
+	case "instance/service-accounts", "instance/service-accounts/":
+		writeText(w, "default/\n"+h.cfg.Email()+"/\n")
+		return
+	}
+
+	rest, ok := strings.CutPrefix(key, "instance/service-accounts/")
+	if !ok {
+		log.Debug("gcp metadata request for unsupported key", "key", key)
+		http.NotFound(w, r)
+		return
+	}
+	account, attr, _ := strings.Cut(rest, "/")
+	if account != "default" && account != h.cfg.Email() {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch attr {
+	case "":
+		if r.URL.Query().Get("recursive") == "true" {
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"aliases": []string{"default"},
+				"email":   h.cfg.Email(),
+				"scopes":  h.cfg.Scopes(),
+			})
+			return
+		}
+		writeText(w, "aliases\nemail\nscopes\ntoken\n")
+	case "aliases":
+		writeText(w, "default")
+	case "email":
+		writeText(w, h.cfg.Email())
+	case "scopes":
+		writeText(w, strings.Join(h.cfg.Scopes(), "\n")+"\n")
+	case "token":
+		h.serveToken(w, r)
+	default:
+		// identity (ID tokens) is not emulated.
+		log.Debug("gcp metadata request for unsupported key", "key", key)
+		http.NotFound(w, r)
+	}
+}
+
+// serveToken writes an access token in the metadata server's format. A
+// service account token is minted for the requested scopes; a user's token
+// carries the scopes granted at login whatever is asked for.
+func (h *MetadataHandler) serveToken(w http.ResponseWriter, r *http.Request) {
+	scopes := []string{ScopeCloudPlatform}
+	if h.cfg.Meta[MetaKeyAuthType] == AuthTypeUser {
+		scopes = nil
+	} else if s := r.URL.Query().Get("scopes"); s != "" {
+		scopes = strings.FieldsFunc(s, func(c rune) bool { return c == ',' || c == ' ' })
+		slices.Sort(scopes)
+	}
+
+	tok, err := h.getToken(r.Context(), scopes)
+	if err != nil {
+		log.Error("GCP token fetch error", "error", err)
+		http.Error(w, classifyGCPError(err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"access_token": tok.AccessToken,
+		"expires_in":   int(time.Until(tok.ExpiresOn).Seconds()),
+		"token_type":   "Bearer",
+	})
+}
+
+// getToken returns a cached token for scopes or mints a new one.
+func (h *MetadataHandler) getToken(ctx context.Context, scopes []string) (*Token, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	key := strings.Join(scopes, " ")
+	// Held across the fetch so concurrent requests for a cold token mint
+	// it once.
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if tok := h.cached[key]; tok != nil && time.Now().Add(tokenRefreshBuffer).Before(tok.ExpiresOn) {
+		return tok, nil
+	}
+	tok, err := h.fetch(ctx, scopes)
+	if err != nil {
+		return nil, err
+	}
+	h.cached[key] = tok
+	return tok, nil
+}
+
+func writeText(w http.ResponseWriter, s string) {
+	w.Header().Set("Content-Type", "application/text")
+	_, _ = w.Write([]byte(s))
+}
+
+// classifyGCPError returns an actionable message for a failed token fetch.
+// The full error is logged by the daemon.
+func classifyGCPError(err error) string {
+	msg := err.Error()
+	switch {
+	case errors.Is(err, provider.ErrTokenRevoked):
+		return "GCP credential error: the granted credentials were revoked or have expired. Run 'gcloud auth application-default login' and 'moat grant gcp' again, then restart the run."
+	case strings.Contains(msg, "no gcp credential") || strings.Contains(msg, "run 'moat grant gcp' again"):
+		return "GCP credential error: the gcp grant could not be loaded. Run 'moat grant gcp' again, then restart the run."
+	case strings.Contains(msg, "token request"):
+		return "GCP credential error: could not reach Google's token endpoint from the host running the moat daemon."
+	}
+	return "GCP credential error: could not mint an access token. See the moat daemon log for details."
+}
@@ -0,0 +1,119 @@
+package gcp
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestHandler(authType string, calls *[]string) *MetadataHandler {
+	meta := map[string]string{MetaKeyAuthType: authType}
+	if authType == AuthTypeServiceAccount {
+		meta[MetaKeyClientEmail] = "agent@proj.iam.gserviceaccount.com"
+	}
+	h := NewMetadataHandler(Config{Project: "proj", Meta: meta})
+	h.SetFetcher(func(ctx context.Context, scopes []string) (*Token, error) {
+		*calls = append(*calls, strings.Join(scopes, " "))
+		return &Token{AccessToken: "tok", ExpiresOn: time.Now().Add(time.Hour)}, nil
+	})
+	return h
+}
+
+func metadataGet(h http.Handler, path string, flavor bool) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, EndpointPath+path, nil)
+	if flavor {
+		req.Header.Set("Metadata-Flavor", "Google")
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestMetadataHandler(t *testing.T) {
+	var calls []string
+	h := newTestHandler(AuthTypeServiceAccount, &calls)
+
+	tests := []struct {
+		name     string
+		path     string
+		flavor   bool
+		wantCode int
+		wantBody string
+	}{
+		{"ping", "/", false, http.StatusOK, "computeMetadata/\n"},
+		{"missing header", "/computeMetadata/v1/project/project-id", false, http.StatusForbidden, ""},
+		{"project", "/computeMetadata/v1/project/project-id", true, http.StatusOK, "proj"},
+		{"email by alias", "/computeMetadata/v1/instance/service-accounts/default/email", true, http.StatusOK, "agent@proj.iam.gserviceaccount.com"},
+		{"unknown account", "/computeMetadata/v1/instance/service-accounts/other@example.com/email", true, http.StatusNotFound, ""},
+		{"identity unsupported", "/computeMetadata/v1/instance/service-accounts/default/identity", true, http.StatusNotFound, ""},
+		{"unsupported key", "/computeMetadata/v1/instance/zone", true, http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := metadataGet(h, tt.path, tt.flavor)
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantCode)
+			}
+			if rec.Header().Get("Metadata-Flavor") != "Google" {
+				t.Error("missing Metadata-Flavor response header")
+			}
+			if tt.wantBody != "" && rec.Body.String() != tt.wantBody {
+				t.Errorf("body = %q, want %q", rec.Body.String(), tt.wantBody)
+			}
+		})
+	}
+
+	t.Run("recursive", func(t *testing.T) {
+		rec := metadataGet(h, "/computeMetadata/v1/instance/service-accounts/default/?recursive=true", true)
+		var got struct {
+			Email  string   `json:"email"`
+			Scopes []string `json:"scopes"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		if got.Email != "agent@proj.iam.gserviceaccount.com" || len(got.Scopes) != 1 || got.Scopes[0] != ScopeCloudPlatform {
+			t.Errorf("got %+v", got)
+		}
+	})
+}
+
+func TestMetadataHandler_Token(t *testing.T) {
+	var calls []string
+	h := newTestHandler(AuthTypeServiceAccount, &calls)
+
+	for i := 0; i < 2; i++ {
+		rec := metadataGet(h, "/computeMetadata/v1/instance/service-accounts/default/token", true)
+		var got struct {
+			AccessToken string `json:"access_token"`
+			ExpiresIn   int    `json:"expires_in"`
+			TokenType   string `json:"token_type"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		if got.AccessToken != "tok" || got.TokenType != "Bearer" || got.ExpiresIn <= 0 {
+			t.Errorf("got %+v", got)
+		}
+	}
+	metadataGet(h, "/computeMetadata/v1/instance/service-accounts/default/token?scopes=b,a", true)
+
+	want := []string{ScopeCloudPlatform, "a b"}
+	if strings.Join(calls, "|") != strings.Join(want, "|") {
+		t.Errorf("fetches = %q, want %q (cached per scope set)", calls, want)
+	}
+}
+
+func TestMetadataHandler_TokenIgnoresScopesForUser(t *testing.T) {
+	var calls []string
+	h := newTestHandler(AuthTypeUser, &calls)
+	metadataGet(h, "/computeMetadata/v1/instance/service-accounts/default/token?scopes=a", true)
+	metadataGet(h, "/computeMetadata/v1/instance/service-accounts/default/token", true)
+	if len(calls) != 1 || calls[0] != "" {
+		t.Errorf("fetches = %q, want one fetch without scopes", calls)
+	}
+}
@@ -0,0 +1,130 @@
+package gcp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/majorcontext/moat/internal/provider"
+	"github.com/majorcontext/moat/internal/ui"
+)
+
+// Provider implements provider.CredentialProvider and provider.EndpointProvider
+// for Google Cloud credentials from application default credentials.
+type Provider struct{}
+
+// Compile-time interface assertions.
+var (
+	_ provider.CredentialProvider = (*Provider)(nil)
+	_ provider.EndpointProvider   = (*Provider)(nil)
+)
+
+func init() {
+	provider.Register(&Provider{})
+}
+
+// Name returns the provider identifier.
+func (p *Provider) Name() string {
+	return "gcp"
+}
+
+// Grant reads application default credentials and validates them.
+func (p *Provider) Grant(ctx context.Context) (*provider.Credential, error) {
+	return grant(ctx)
+}
+
+// ConfigureProxy is a no-op for GCP since it uses the endpoint pattern.
+// Tokens are served by the metadata endpoint, not header injection.
+func (p *Provider) ConfigureProxy(pc provider.ProxyConfigurer, cred *provider.Credential) {
+	// No-op: GCP uses credential endpoint, not proxy header injection
+}
+
+// ContainerEnv sets the default project. The run manager sets
+// GCE_METADATA_HOST and GCE_METADATA_IP.
+func (p *Provider) ContainerEnv(cred *provider.Credential) []string {
+	if project := cred.Metadata[MetaKeyProject]; project != "" {
+		return []string{"GOOGLE_CLOUD_PROJECT=" + project}
+	}
+	return nil
+}
+
+// ContainerMounts returns nil; no credential file is mounted.
+func (p *Provider) ContainerMounts(cred *provider.Credential, containerHome string) ([]provider.MountConfig, string, error) {
+	return nil, "", nil
+}
+
+// Cleanup is a no-op for GCP.
+func (p *Provider) Cleanup(cleanupPath string) {
+	// No cleanup needed
+}
+
+// ImpliedDependencies returns nil. gcloud reads the metadata server without
+// the proxy, so it cannot use the emulated one.
+func (p *Provider) ImpliedDependencies() []string {
+	return nil
+}
+
+// RegisterEndpoints registers the metadata server handler.
+func (p *Provider) RegisterEndpoints(mux *http.ServeMux, cred *provider.Credential) {
+	cfg, err := ConfigFromCredential(cred)
+	if err != nil {
+		ui.Warnf("Failed to parse GCP config from credential: %v", err)
+		return
+	}
+	h := NewMetadataHandler(*cfg)
+	mux.Handle(EndpointPath, h)
+	mux.Handle(EndpointPath+"/", h)
+}
+
+// Config is what the metadata endpoint needs to mint tokens and describe the
+// default service account.
+type Config struct {
+	Project string
+	// Meta holds the auth type and the refresh token or service account key,
+	// in the credential metadata format.
+	Meta map[string]string
+}
+
+// ConfigFromCredential extracts Config from a stored gcp credential.
+func ConfigFromCredential(cred *provider.Credential) (*Config, error) {
+	if cred == nil || cred.Metadata == nil {
+		return nil, fmt.Errorf("gcp credential has no metadata; run 'moat grant gcp' again")
+	}
+	switch cred.Metadata[MetaKeyAuthType] {
+	case AuthTypeUser:
+		if cred.Metadata[MetaKeyRefreshToken] == "" {
+			return nil, fmt.Errorf("gcp credential has no refresh token; run 'moat grant gcp' again")
+		}
+	case AuthTypeServiceAccount:
+		if cred.Metadata[MetaKeyPrivateKey] == "" {
+			return nil, fmt.Errorf("gcp credential has no service account key; run 'moat grant gcp' again")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported gcp credential type %q", cred.Metadata[MetaKeyAuthType])
+	}
+	return &Config{Project: cred.Metadata[MetaKeyProject], Meta: cred.Metadata}, nil
+}
+
+// Email returns the account tokens are issued for: the service account, or
+// the user if tokeninfo reported one. Unknown accounts are "default".
+func (c Config) Email() string {
+	if e := c.Meta[MetaKeyClientEmail]; e != "" {
+		return e
+	}
+	if e := c.Meta[MetaKeyEmail]; e != "" {
+		return e
+	}
+	return "default"
+}
+
+// Scopes returns the default service account's scopes: cloud-platform for a
+// service account, the granted scopes for a user.
+func (c Config) Scopes() []string {
+	if c.Meta[MetaKeyAuthType] == AuthTypeUser {
+		if s := strings.Fields(c.Meta[MetaKeyScopes]); len(s) > 0 {
+			return s
+		}
+	}
+	return []string{ScopeCloudPlatform}
+}
@@ -0,0 +1,106 @@
+package gcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/majorcontext/moat/internal/provider"
+	"github.com/majorcontext/moat/internal/providers/gcp/gcptest"
+)
+
+// newTokenInfoServer answers tokeninfo for "at-*" tokens as user@example.com.
+func newTokenInfoServer(t *testing.T) {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Query().Get("access_token"), "at-") {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"invalid_token"}`))
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{
+			"email": "user@example.com",
+			"scope": "openid https://www.googleapis.com/auth/userinfo.email " + ScopeCloudPlatform,
+		})
+	}))
+	t.Cleanup(srv.Close)
+	orig := tokenInfoURL
+	tokenInfoURL = srv.URL
+	t.Cleanup(func() { tokenInfoURL = orig })
+}
+
+func TestGrant(t *testing.T) {
+	tokenSrv := gcptest.NewTokenServer(t, nil)
+	newTokenInfoServer(t)
+	t.Setenv("GOOGLE_CLOUD_PROJECT", "")
+
+	t.Run("authorized_user", func(t *testing.T) {
+		path := gcptest.WriteADC(t, map[string]string{
+			"type": AuthTypeUser, "client_id": "cid", "client_secret": "cs", "refresh_token": "rt",
+			"quota_project_id": "proj", "token_uri": tokenSrv.URL,
+		})
+		cred, err := grant(WithGrantOptions(context.Background(), GrantOptions{CredentialsFile: path}))
+		if err != nil {
+			t.Fatalf("grant: %v", err)
+		}
+		if cred.Metadata[MetaKeyEmail] != "user@example.com" || !strings.Contains(cred.Metadata[MetaKeyScopes], ScopeCloudPlatform) {
+			t.Errorf("Metadata = %v, want tokeninfo email and scopes", cred.Metadata)
+		}
+		if cred.Metadata[MetaKeyProject] != "proj" || cred.Metadata[MetaKeyRefreshToken] != "rt" {
+			t.Errorf("Metadata = %v", cred.Metadata)
+		}
+	})
+
+	t.Run("service_account", func(t *testing.T) {
+		path := gcptest.WriteADC(t, map[string]string{
+			"type": AuthTypeServiceAccount, "client_email": "agent@proj.iam.gserviceaccount.com",
+			"private_key": gcptest.KeyPEM(t), "project_id": "proj", "token_uri": tokenSrv.URL,
+		})
+		cred, err := grant(WithGrantOptions(context.Background(), GrantOptions{CredentialsFile: path, Project: "other"}))
+		if err != nil {
+			t.Fatalf("grant: %v", err)
+		}
+		if cred.Metadata[MetaKeyProject] != "other" || cred.Metadata[MetaKeyEmail] != "" {
+			t.Errorf("Metadata = %v, want --project and no user email", cred.Metadata)
+		}
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		_, err := grant(WithGrantOptions(context.Background(), GrantOptions{CredentialsFile: filepath.Join(t.TempDir(), "none.json")}))
+		var ge *provider.GrantError
+		if !errors.As(err, &ge) || !strings.Contains(ge.Hint, "gcloud auth application-default login") {
+			t.Errorf("err = %v, want GrantError with a login hint", err)
+		}
+	})
+}
+
+func TestConfigFromCredential(t *testing.T) {
+	cfg, err := ConfigFromCredential(&provider.Credential{Metadata: map[string]string{
+		MetaKeyAuthType: AuthTypeUser, MetaKeyRefreshToken: "rt", MetaKeyProject: "proj",
+		MetaKeyEmail: "user@example.com", MetaKeyScopes: "openid " + ScopeCloudPlatform,
+	}})
+	if err != nil {
+		t.Fatalf("ConfigFromCredential: %v", err)
+	}
+	if cfg.Project != "proj" || cfg.Email() != "user@example.com" || len(cfg.Scopes()) != 2 {
+		t.Errorf("cfg = %+v, email %q, scopes %v", cfg, cfg.Email(), cfg.Scopes())
+	}
+
+	if _, err := ConfigFromCredential(&provider.Credential{Metadata: map[string]string{MetaKeyAuthType: AuthTypeUser}}); err == nil {
+		t.Error("expected error for a user credential without a refresh token")
+	}
+	if _, err := ConfigFromCredential(&provider.Credential{Metadata: map[string]string{MetaKeyAuthType: "external_account"}}); err == nil {
+		t.Error("expected error for an unsupported credential type")
+	}
+}
+
+func TestProvider_Registered(t *testing.T) {
+	if provider.GetEndpoint("gcp") == nil {
+		t.Fatal("gcp endpoint provider not registered")
+	}
+}
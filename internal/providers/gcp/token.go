@@ -0,0 +1,111 @@
+package gcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/majorcontext/moat/internal/provider"
+	"github.com/majorcontext/moat/internal/provider/util"
+)
+
+// DefaultTokenURL is Google's OAuth token endpoint.
+const DefaultTokenURL = "https://oauth2.googleapis.com/token"
+
+// ScopeCloudPlatform is the OAuth scope for all Google Cloud APIs.
+const ScopeCloudPlatform = "https://www.googleapis.com/auth/cloud-platform"
+
+// FetchAccessToken mints an access token from the refresh token or service
+// account key in meta. scopes apply to service account tokens only; tokens
+// from an authorized_user refresh token carry the scopes granted at
+// 'gcloud auth application-default login' (cloud-platform by default).
+func FetchAccessToken(ctx context.Context, meta map[string]string, scopes []string) (string, time.Time, error) {
+	tokenURL := meta[MetaKeyTokenURL]
+	if tokenURL == "" {
+		tokenURL = DefaultTokenURL
+	}
+
+	var form url.Values
+	switch meta[MetaKeyAuthType] {
+	case AuthTypeUser:
+		form = url.Values{
+			"grant_type":    {"refresh_token"},
+			"client_id":     {meta[MetaKeyClientID]},
+			"client_secret": {meta[MetaKeyClientSecret]},
+			"refresh_token": {meta[MetaKeyRefreshToken]},
+		}
+	case AuthTypeServiceAccount:
+		key, err := util.ParseRSAPrivateKey([]byte(meta[MetaKeyPrivateKey]))
+		if err != nil {
+			return "", time.Time{}, fmt.Errorf("parsing service account key: %w", err)
+		}
+		now := time.Now()
+		assertion, err := util.SignJWT(key, map[string]any{
+			"iss":   meta[MetaKeyClientEmail],
+			"scope": strings.Join(scopes, " "),
+			"aud":   tokenURL,
+			"iat":   now.Unix(),
+			"exp":   now.Add(time.Hour).Unix(),
+		})
+		if err != nil {
+			return "", time.Time{}, err
+		}
+		form = url.Values{
+			"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+			"assertion":  {assertion},
+		}
+	default:
+		return "", time.Time{}, fmt.Errorf("unsupported credential type %q", meta[MetaKeyAuthType])
+	}
+
+	reqCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(reqCtx, "POST", tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("creating token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("User-Agent", "moat")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("token request: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("reading token response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var errResp struct {
+			Error       string `json:"error"`
+			Description string `json:"error_description"`
+		}
+		if json.Unmarshal(body, &errResp) != nil || errResp.Error == "" {
+			return "", time.Time{}, fmt.Errorf("token request failed (HTTP %d)", resp.StatusCode)
+		}
+		// invalid_grant: the refresh token was revoked or the key deleted.
+		if errResp.Error == "invalid_grant" {
+			return "", time.Time{}, fmt.Errorf("%w: %s: %s", provider.ErrTokenRevoked, errResp.Error, errResp.Description)
+		}
+		return "", time.Time{}, fmt.Errorf("token request failed: %s: %s", errResp.Error, errResp.Description)
+	}
+
+	var tokenResp struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.Unmarshal(body, &tokenResp); err != nil {
+		return "", time.Time{}, fmt.Errorf("parsing token response: %w", err)
+	}
+	if tokenResp.AccessToken == "" {
+		return "", time.Time{}, fmt.Errorf("no access token in token response")
+	}
+	return tokenResp.AccessToken, time.Now().Add(time.Duration(tokenResp.ExpiresIn) * time.Second), nil
+}
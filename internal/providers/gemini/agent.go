@@ -93,6 +93,9 @@ func (p *Provider) PrepareContainer(ctx context.Context, opts provider.PrepareOp
 
 // populateStagingDir populates the Gemini staging directory with auth configuration.
 func populateStagingDir(cred *provider.Credential, stagingDir string) error {
+	if IsVertexCredential(cred) {
+		return writeSettings(stagingDir, "vertex-ai")
+	}
 	if IsOAuthCredential(cred) {
 		return populateOAuthStagingDir(stagingDir)
 	}
@@ -108,7 +111,7 @@ func populateOAuthStagingDir(stagingDir string) error {
 	// The real token is NEVER placed in the container — the proxy substitutes
 	// the placeholder with the real token at the network layer.
 	oauthCreds := OAuthCreds{
-		AccessToken:  ProxyInjectedPlaceholder,
+		AccessToken:  oauthCredsSecret.Placeholder,
 		Scope:        "https://www.googleapis.com/auth/userinfo.email https://www.googleapis.com/auth/userinfo.profile https://www.googleapis.com/auth/cloud-platform openid",
 		TokenType:    "Bearer",
 		ExpiryDate:   time.Now().Add(365 * 24 * time.Hour).UnixMilli(), // Far future — proxy handles real expiry
@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"github.com/majorcontext/moat/internal/provider"
+	"github.com/majorcontext/moat/internal/provider/util"
 )
 
 // Auth handles Gemini API key authentication.
@@ -34,7 +35,10 @@ func (a *Auth) apiURL() string {
 }
 
 // PromptForAPIKey prompts the user to enter their Gemini API key.
-func (a *Auth) PromptForAPIKey() (string, error) {
+func (a *Auth) PromptForAPIKey(ctx context.Context) (string, error) {
+	if err := util.RequireInput(ctx, "set GEMINI_API_KEY"); err != nil {
+		return "", err
+	}
 	fmt.Println("Enter your Gemini API key.")
 	fmt.Println("You can create one at: https://aistudio.google.com/apikey")
 	fmt.Print("\nAPI Key: ")
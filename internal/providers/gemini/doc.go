@@ -8,10 +8,12 @@
 //
 // # Authentication
 //
-// Gemini supports two authentication methods:
+// Gemini supports three authentication methods:
 //
 //  1. API Key - Standard API access via x-goog-api-key header
 //  2. OAuth - Google OAuth2 access with automatic token refresh
+//  3. Vertex AI - an express mode API key for aiplatform.googleapis.com, or
+//     routing through a Google Cloud project authenticated by the gcp grant
 //
 // Credentials are handled via proxy injection, never exposed to containers:
 //   - Container receives placeholder credentials in ~/.gemini/
@@ -22,6 +24,7 @@
 // Gemini CLI routes to different API backends depending on authentication:
 //   - API key mode: generativelanguage.googleapis.com (Google AI SDK)
 //   - OAuth mode: cloudcode-pa.googleapis.com (Cloud Code Private API)
+//   - Vertex AI mode: aiplatform.googleapis.com or <location>-aiplatform.googleapis.com
 //
 // The proxy must inject credentials for the correct host based on auth type.
 //
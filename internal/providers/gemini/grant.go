@@ -5,15 +5,19 @@ import (
 	"context"
 	"fmt"
 	"os"
-	"strings"
 	"time"
 
 	"github.com/majorcontext/moat/internal/credential"
 	"github.com/majorcontext/moat/internal/provider"
+	"github.com/majorcontext/moat/internal/provider/util"
 )
 
 // Grant acquires Gemini credentials interactively or from environment.
 func (p *Provider) Grant(ctx context.Context) (*provider.Credential, error) {
+	if opts, _ := ctx.Value(ctxKeyOptions{}).(GrantOptions); opts.Vertex {
+		return grantVertex(ctx, opts)
+	}
+
 	// Check for GEMINI_API_KEY in environment
 	if envKey := os.Getenv("GEMINI_API_KEY"); envKey != "" {
 		fmt.Println("Using API key from GEMINI_API_KEY environment variable")
@@ -22,7 +26,7 @@ func (p *Provider) Grant(ctx context.Context) (*provider.Credential, error) {
 
 	// Check for existing Gemini CLI credentials
 	cliCreds := &CLICredentials{}
-	hasOAuth := cliCreds.HasCredentials()
+	hasOAuth := !util.GrantOptionsFrom(ctx).EnvOnly && cliCreds.HasCredentials()
 
 	if hasOAuth {
 		// Offer choice between OAuth import and API key
@@ -36,13 +40,10 @@ func (p *Provider) Grant(ctx context.Context) (*provider.Credential, error) {
 		fmt.Println("     Use an API key from aistudio.google.com/apikey")
 		fmt.Println()
 
-		reader := bufio.NewReader(os.Stdin)
-		fmt.Print("Enter choice [1 or 2]: ")
-		choice, err := reader.ReadString('\n')
+		choice, err := util.ReadChoice(ctx, bufio.NewReader(os.Stdin), "Enter choice [1 or 2]: ", "1")
 		if err != nil {
 			return nil, fmt.Errorf("reading choice: %w", err)
 		}
-		choice = strings.TrimSpace(choice)
 
 		switch choice {
 		case "1":
@@ -80,7 +81,7 @@ func grantViaAPIKey(ctx context.Context, apiKey string) (*provider.Credential, e
 // grantViaPromptedAPIKey prompts for an API key and validates it.
 func grantViaPromptedAPIKey(ctx context.Context) (*provider.Credential, error) {
 	auth := &Auth{}
-	apiKey, err := auth.PromptForAPIKey()
+	apiKey, err := auth.PromptForAPIKey(ctx)
 	if err != nil {
 		return nil, err
 	}
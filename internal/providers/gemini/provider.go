@@ -4,9 +4,11 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net/http"
 	"time"
 
 	"github.com/majorcontext/moat/internal/provider"
+	"github.com/majorcontext/moat/internal/provider/util"
 )
 
 // Provider implements provider.CredentialProvider and provider.AgentProvider
@@ -18,8 +20,24 @@ var (
 	_ provider.CredentialProvider  = (*Provider)(nil)
 	_ provider.AgentProvider       = (*Provider)(nil)
 	_ provider.RefreshableProvider = (*Provider)(nil)
+	_ provider.CredentialChecker   = (*Provider)(nil)
 )
 
+// oauthCredsSecret is the placeholder access token in oauth_creds.json.
+// When Gemini CLI validates the token at startup, it POSTs to
+// oauth2.googleapis.com/tokeninfo with the placeholder in the Authorization
+// header; the proxy substitutes the real token so Google validates the real
+// credential. The file itself is written by populateOAuthStagingDir.
+var oauthCredsSecret = provider.FileSecret{
+	Path:        CredentialsFile,
+	Placeholder: ProxyInjectedPlaceholder,
+	Hosts:       []string{GeminiOAuthHost},
+}
+
+// modelsURL is the endpoint CheckCredential probes. A variable so tests can
+// point it at a local server.
+var modelsURL = ModelsURL
+
 func init() {
 	provider.Register(&Provider{})
 	// Register "google" as an alias so credentials stored under either name work
@@ -33,16 +51,19 @@ func (p *Provider) Name() string {
 
 // ConfigureProxy sets up proxy headers for Gemini API.
 func (p *Provider) ConfigureProxy(proxy provider.ProxyConfigurer, cred *provider.Credential) {
+	if IsVertexCredential(cred) {
+		// Vertex AI: an express mode key is injected for the global endpoint.
+		// Project routing is authenticated by the gcp grant instead.
+		if VertexProject(cred) == "" {
+			proxy.SetCredentialHeader(VertexAPIHost, "x-goog-api-key", cred.Token)
+		}
+		return
+	}
 	if IsOAuthCredential(cred) {
 		// OAuth mode: Gemini CLI uses cloudcode-pa.googleapis.com (Cloud Code Private API),
 		// NOT generativelanguage.googleapis.com. Inject real Bearer token for the API host.
 		proxy.SetCredential(GeminiAPIHost, "Bearer "+cred.Token)
-
-		// The container has a placeholder token in oauth_creds.json. When Gemini CLI
-		// validates the token at startup, it POSTs to oauth2.googleapis.com/tokeninfo
-		// with the placeholder in the Authorization header. We substitute the real token
-		// so Google validates the real credential and returns a real response.
-		proxy.SetTokenSubstitution(GeminiOAuthHost, ProxyInjectedPlaceholder, cred.Token)
+		provider.ConfigureFileSecrets(proxy, cred, []provider.FileSecret{oauthCredsSecret})
 	} else {
 		// API key: use x-goog-api-key header (Gemini API accepts this)
 		proxy.SetCredentialHeader(GeminiAPIKeyHost, "x-goog-api-key", cred.Token)
@@ -51,6 +72,9 @@ func (p *Provider) ConfigureProxy(proxy provider.ProxyConfigurer, cred *provider
 
 // ContainerEnv returns environment variables for Gemini.
 func (p *Provider) ContainerEnv(cred *provider.Credential) []string {
+	if IsVertexCredential(cred) {
+		return vertexEnv(cred)
+	}
 	if IsOAuthCredential(cred) {
 		// OAuth mode: no env vars needed — auth is handled via oauth_creds.json
 		// and proxy credential injection.
@@ -97,17 +121,39 @@ func (p *Provider) Refresh(ctx context.Context, proxy provider.ProxyConfigurer,
 		return nil, err
 	}
 
-	// Update proxy with new token
-	proxy.SetCredential(GeminiAPIHost, "Bearer "+result.AccessToken)
-	proxy.SetTokenSubstitution(GeminiOAuthHost, ProxyInjectedPlaceholder, result.AccessToken)
-
-	// Return updated credential
 	newCred := *cred
 	newCred.Token = result.AccessToken
 	newCred.ExpiresAt = result.ExpiresAt
+
+	// Update proxy with new token
+	proxy.SetCredential(GeminiAPIHost, "Bearer "+newCred.Token)
+	provider.ConfigureFileSecrets(proxy, &newCred, []provider.FileSecret{oauthCredsSecret})
 	return &newCred, nil
 }
 
+// CheckCredential verifies an API key by listing models, or a Vertex AI
+// express mode key by counting tokens. OAuth credentials are refreshed at run
+// start instead, and Vertex AI project routing uses the gcp grant, so neither
+// is checked here. Gemini answers an invalid key with 400 (API_KEY_INVALID)
+// as well as 401.
+func (p *Provider) CheckCredential(ctx context.Context, cred *provider.Credential) error {
+	if IsOAuthCredential(cred) {
+		return nil
+	}
+	if IsVertexCredential(cred) {
+		if VertexProject(cred) != "" {
+			return nil
+		}
+		return checkVertexKey(ctx, cred.Token)
+	}
+	req, err := http.NewRequest("GET", modelsURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("x-goog-api-key", cred.Token)
+	return util.ProbeCredential(ctx, req, http.StatusBadRequest, http.StatusUnauthorized)
+}
+
 // Cleanup cleans up Gemini resources.
 func (p *Provider) Cleanup(cleanupPath string) {
 	// Nothing to clean up - staging directory is handled by the caller
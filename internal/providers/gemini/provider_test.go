@@ -3,6 +3,9 @@ package gemini
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"strings"
@@ -113,3 +116,33 @@ func TestPrepareContainer_LocalMCP_MultipleServers(t *testing.T) {
 		t.Errorf("beta args[0] = %q, want '--verbose'", mcpCfg.MCPServers["beta"].Args[0])
 	}
 }
+
+func TestCheckCredential(t *testing.T) {
+	var gotKey string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotKey = r.Header.Get("x-goog-api-key")
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer srv.Close()
+	orig := modelsURL
+	modelsURL = srv.URL
+	defer func() { modelsURL = orig }()
+
+	p := &Provider{}
+	err := p.CheckCredential(context.Background(), &provider.Credential{Token: "AIza-test"})
+	if !errors.Is(err, provider.ErrCredentialRejected) {
+		t.Errorf("err = %v, want ErrCredentialRejected", err)
+	}
+	if gotKey != "AIza-test" {
+		t.Errorf("x-goog-api-key = %q, want AIza-test", gotKey)
+	}
+
+	gotKey = ""
+	oauth := &provider.Credential{Token: "ya29.test", Metadata: map[string]string{"auth_type": "oauth"}}
+	if err := p.CheckCredential(context.Background(), oauth); err != nil {
+		t.Errorf("OAuth credential: err = %v, want nil (not checked)", err)
+	}
+	if gotKey != "" {
+		t.Error("OAuth credential should not be probed")
+	}
+}
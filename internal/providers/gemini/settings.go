@@ -12,7 +12,7 @@ type SecuritySettings struct {
 
 // AuthSettings holds authentication configuration.
 type AuthSettings struct {
-	SelectedType string `json:"selectedType"` // "oauth-personal", "gemini-api-key"
+	SelectedType string `json:"selectedType"` // "oauth-personal", "gemini-api-key", "vertex-ai"
 }
 
 // OAuthCreds represents the ~/.gemini/oauth_creds.json file structure.
@@ -0,0 +1,168 @@
+package gemini
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/majorcontext/moat/internal/provider"
+	"github.com/majorcontext/moat/internal/provider/util"
+)
+
+const (
+	// VertexAPIHost is the global Vertex AI endpoint Gemini CLI calls with an
+	// express mode API key.
+	VertexAPIHost = "aiplatform.googleapis.com"
+
+	// DefaultVertexLocation is the Vertex AI location used when none is given.
+	DefaultVertexLocation = "global"
+
+	// authTypeVertex marks a credential granted with --vertex.
+	authTypeVertex = "vertex"
+
+	// Metadata keys of a Vertex AI credential.
+	metaKeyProject  = "project"
+	metaKeyLocation = "location"
+)
+
+// vertexCountTokensURL is the endpoint used to validate express mode API
+// keys. A variable so tests can point it at a local server.
+var vertexCountTokensURL = "https://" + VertexAPIHost + "/v1/publishers/google/models/gemini-2.5-flash:countTokens"
+
+// GrantOptions carries the Vertex AI grant flags.
+type GrantOptions struct {
+	Vertex   bool   // --vertex
+	Project  string // --project
+	Location string // --location
+}
+
+// ctxKeyOptions is the context key for GrantOptions.
+type ctxKeyOptions struct{}
+
+// WithGrantOptions returns a context carrying the grant flags.
+func WithGrantOptions(ctx context.Context, opts GrantOptions) context.Context {
+	return context.WithValue(ctx, ctxKeyOptions{}, opts)
+}
+
+// IsVertexCredential returns true if the credential routes Gemini CLI to
+// Vertex AI.
+func IsVertexCredential(cred *provider.Credential) bool {
+	return cred != nil && cred.Metadata != nil && cred.Metadata["auth_type"] == authTypeVertex
+}
+
+// VertexProject returns the project of a Vertex AI credential that routes
+// through a Google Cloud project, or "" for an express mode API key.
+func VertexProject(cred *provider.Credential) string {
+	if !IsVertexCredential(cred) {
+		return ""
+	}
+	return cred.Metadata[metaKeyProject]
+}
+
+// vertexEnv returns the container environment for a Vertex AI credential.
+// Express mode keys get a placeholder GOOGLE_API_KEY that the proxy replaces;
+// project routing authenticates through the gcp grant's metadata server.
+func vertexEnv(cred *provider.Credential) []string {
+	env := []string{"GOOGLE_GENAI_USE_VERTEXAI=true"}
+	project := VertexProject(cred)
+	if project == "" {
+		return append(env, "GOOGLE_API_KEY="+ProxyInjectedPlaceholder)
+	}
+	location := cred.Metadata[metaKeyLocation]
+	if location == "" {
+		location = DefaultVertexLocation
+	}
+	return append(env,
+		"GOOGLE_CLOUD_PROJECT="+project,
+		"GOOGLE_CLOUD_LOCATION="+location,
+	)
+}
+
+// grantVertex creates a Vertex AI credential. With a project (from --project
+// or GOOGLE_CLOUD_PROJECT) requests are routed through that project and
+// authenticated by the gcp grant; otherwise an express mode API key is read
+// from GOOGLE_API_KEY or prompted for and validated.
+func grantVertex(ctx context.Context, opts GrantOptions) (*provider.Credential, error) {
+	project := opts.Project
+	if project == "" {
+		project = os.Getenv("GOOGLE_CLOUD_PROJECT")
+	}
+	location := opts.Location
+	if location == "" {
+		location = os.Getenv("GOOGLE_CLOUD_LOCATION")
+	}
+
+	if project != "" {
+		if location == "" {
+			location = DefaultVertexLocation
+		}
+		fmt.Printf("Routing Gemini CLI through Vertex AI in project %s (%s).\n", project, location)
+		fmt.Println("Runs also need the gcp grant: moat grant gcp")
+		return &provider.Credential{
+			Provider:  "gemini",
+			CreatedAt: time.Now(),
+			Metadata: map[string]string{
+				"auth_type":     authTypeVertex,
+				metaKeyProject:  project,
+				metaKeyLocation: location,
+			},
+		}, nil
+	}
+	if location != "" {
+		return nil, &provider.GrantError{
+			Provider: "gemini",
+			Cause:    fmt.Errorf("--location requires --project"),
+			Hint:     "Express mode API keys use the global endpoint; pass --project to route through a project",
+		}
+	}
+
+	apiKey := os.Getenv("GOOGLE_API_KEY")
+	if apiKey != "" {
+		fmt.Println("Using Vertex AI API key from GOOGLE_API_KEY environment variable")
+	} else {
+		if err := util.RequireInput(ctx, "set GOOGLE_API_KEY or pass --project"); err != nil {
+			return nil, err
+		}
+		fmt.Println("Enter a Vertex AI express mode API key.")
+		fmt.Println("You can create one at: https://console.cloud.google.com/vertex-ai/studio")
+		var err error
+		apiKey, err = util.PromptForToken(ctx, "\nAPI Key")
+		if err != nil {
+			return nil, err
+		}
+		if apiKey == "" {
+			return nil, fmt.Errorf("API key cannot be empty")
+		}
+	}
+
+	fmt.Println("\nValidating API key...")
+	validateCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	if err := checkVertexKey(validateCtx, apiKey); err != nil {
+		return nil, fmt.Errorf("validating API key: %w", err)
+	}
+	fmt.Println("API key is valid.")
+
+	return &provider.Credential{
+		Provider:  "gemini",
+		Token:     apiKey,
+		CreatedAt: time.Now(),
+		Metadata:  map[string]string{"auth_type": authTypeVertex},
+	}, nil
+}
+
+// checkVertexKey counts the tokens of a one-word prompt with an express mode
+// API key. Vertex AI answers an invalid key with 400 as well as 401.
+func checkVertexKey(ctx context.Context, apiKey string) error {
+	body := `{"contents":[{"role":"user","parts":[{"text":"hi"}]}]}`
+	req, err := http.NewRequest("POST", vertexCountTokensURL, strings.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-goog-api-key", apiKey)
+	return util.ProbeCredential(ctx, req, http.StatusBadRequest, http.StatusUnauthorized)
+}
@@ -0,0 +1,173 @@
+package gemini
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/majorcontext/moat/internal/provider"
+)
+
+// headerProxy records credential headers set by ConfigureProxy.
+type headerProxy struct {
+	headers map[string]string // "host header" -> value
+}
+
+func (p *headerProxy) set(host, name, value string) {
+	if p.headers == nil {
+		p.headers = make(map[string]string)
+	}
+	p.headers[host+" "+name] = value
+}
+
+func (p *headerProxy) SetCredential(host, value string) { p.set(host, "Authorization", value) }
+func (p *headerProxy) SetCredentialHeader(host, name, value string) {
+	p.set(host, name, value)
+}
+func (p *headerProxy) SetCredentialWithGrant(host, name, value, grant string) {
+	p.set(host, name, value)
+}
+func (p *headerProxy) AddExtraHeader(host, name, value string)                            {}
+func (p *headerProxy) AddResponseTransformer(host string, t provider.ResponseTransformer) {}
+func (p *headerProxy) RemoveRequestHeader(host, name string)                              {}
+func (p *headerProxy) SetTokenSubstitution(host, placeholder, realToken string)           {}
+
+func TestVertexExpressKey(t *testing.T) {
+	p := &Provider{}
+	cred := &provider.Credential{Provider: "gemini", Token: "AQ.express", Metadata: map[string]string{"auth_type": "vertex"}}
+
+	proxy := &headerProxy{}
+	p.ConfigureProxy(proxy, cred)
+	if got := proxy.headers[VertexAPIHost+" x-goog-api-key"]; got != "AQ.express" {
+		t.Errorf("x-goog-api-key for %s = %q, want the express key", VertexAPIHost, got)
+	}
+	if len(proxy.headers) != 1 {
+		t.Errorf("headers = %v, want only the Vertex AI key", proxy.headers)
+	}
+
+	env := p.ContainerEnv(cred)
+	for _, want := range []string{"GOOGLE_GENAI_USE_VERTEXAI=true", "GOOGLE_API_KEY=" + ProxyInjectedPlaceholder} {
+		if !slices.Contains(env, want) {
+			t.Errorf("env = %v, missing %s", env, want)
+		}
+	}
+	if slices.Contains(env, "GEMINI_API_KEY="+ProxyInjectedPlaceholder) {
+		t.Error("express key should not set GEMINI_API_KEY")
+	}
+}
+
+func TestVertexProjectRouting(t *testing.T) {
+	p := &Provider{}
+	cred := &provider.Credential{Provider: "gemini", Metadata: map[string]string{
+		"auth_type": "vertex",
+		"project":   "my-proj",
+		"location":  "us-central1",
+	}}
+
+	proxy := &headerProxy{}
+	p.ConfigureProxy(proxy, cred)
+	if len(proxy.headers) != 0 {
+		t.Errorf("headers = %v, want none (gcp grant authenticates)", proxy.headers)
+	}
+
+	env := p.ContainerEnv(cred)
+	want := []string{
+		"GOOGLE_GENAI_USE_VERTEXAI=true",
+		"GOOGLE_CLOUD_PROJECT=my-proj",
+		"GOOGLE_CLOUD_LOCATION=us-central1",
+	}
+	if !slices.Equal(env, want) {
+		t.Errorf("env = %v, want %v", env, want)
+	}
+	if err := p.CheckCredential(context.Background(), cred); err != nil {
+		t.Errorf("CheckCredential = %v, want nil (not checked)", err)
+	}
+}
+
+func TestPrepareContainer_VertexSettings(t *testing.T) {
+	p := &Provider{}
+	cfg, err := p.PrepareContainer(context.Background(), provider.PrepareOpts{
+		Credential: &provider.Credential{Token: "AQ.express", Metadata: map[string]string{"auth_type": "vertex"}},
+	})
+	if err != nil {
+		t.Fatalf("PrepareContainer() error = %v", err)
+	}
+	defer cfg.Cleanup()
+
+	data, err := os.ReadFile(filepath.Join(cfg.StagingDir, "settings.json"))
+	if err != nil {
+		t.Fatalf("reading settings.json: %v", err)
+	}
+	var settings Settings
+	if err := json.Unmarshal(data, &settings); err != nil {
+		t.Fatalf("parsing settings.json: %v", err)
+	}
+	if settings.Security.Auth.SelectedType != "vertex-ai" {
+		t.Errorf("selectedType = %q, want vertex-ai", settings.Security.Auth.SelectedType)
+	}
+	if _, err := os.Stat(filepath.Join(cfg.StagingDir, "oauth_creds.json")); !os.IsNotExist(err) {
+		t.Error("oauth_creds.json should not be written for Vertex AI")
+	}
+}
+
+func TestCheckCredential_VertexExpressKey(t *testing.T) {
+	var gotKey, gotPath string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotKey = r.Header.Get("x-goog-api-key")
+		gotPath = r.URL.Path
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer srv.Close()
+	orig := vertexCountTokensURL
+	vertexCountTokensURL = srv.URL + "/v1/publishers/google/models/m:countTokens"
+	defer func() { vertexCountTokensURL = orig }()
+
+	p := &Provider{}
+	cred := &provider.Credential{Token: "AQ.express", Metadata: map[string]string{"auth_type": "vertex"}}
+	err := p.CheckCredential(context.Background(), cred)
+	if !errors.Is(err, provider.ErrCredentialRejected) {
+		t.Errorf("err = %v, want ErrCredentialRejected", err)
+	}
+	if gotKey != "AQ.express" {
+		t.Errorf("x-goog-api-key = %q, want AQ.express", gotKey)
+	}
+	if gotPath != "/v1/publishers/google/models/m:countTokens" {
+		t.Errorf("path = %q, want the countTokens endpoint", gotPath)
+	}
+}
+
+func TestGrantVertexProject(t *testing.T) {
+	t.Setenv("GOOGLE_CLOUD_PROJECT", "env-proj")
+	t.Setenv("GOOGLE_CLOUD_LOCATION", "")
+
+	p := &Provider{}
+	ctx := WithGrantOptions(context.Background(), GrantOptions{Vertex: true})
+	cred, err := p.Grant(ctx)
+	if err != nil {
+		t.Fatalf("Grant() error = %v", err)
+	}
+	if VertexProject(cred) != "env-proj" {
+		t.Errorf("project = %q, want env-proj from GOOGLE_CLOUD_PROJECT", VertexProject(cred))
+	}
+	if cred.Metadata["location"] != DefaultVertexLocation {
+		t.Errorf("location = %q, want %q", cred.Metadata["location"], DefaultVertexLocation)
+	}
+	if cred.Token != "" {
+		t.Error("project routing should store no token")
+	}
+
+	ctx = WithGrantOptions(context.Background(), GrantOptions{Vertex: true, Project: "flag-proj", Location: "europe-west4"})
+	cred, err = p.Grant(ctx)
+	if err != nil {
+		t.Fatalf("Grant() error = %v", err)
+	}
+	if VertexProject(cred) != "flag-proj" || cred.Metadata["location"] != "europe-west4" {
+		t.Errorf("metadata = %v, want flag values over environment", cred.Metadata)
+	}
+}
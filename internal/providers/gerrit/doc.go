@@ -0,0 +1,9 @@
+// Package gerrit implements a credential provider for self-hosted Gerrit
+// Code Review servers.
+//
+// Gerrit authenticates REST calls under /a/ and git over HTTPS with the
+// account's HTTP password (Settings > HTTP Credentials) using HTTP Basic auth.
+// The grant records each server's base URL with the username and password;
+// the proxy injects Basic auth for the server's host, and the container gets
+// a ~/.netrc with a placeholder password so git does not prompt.
+package gerrit
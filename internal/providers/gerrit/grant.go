@@ -0,0 +1,22 @@
+package gerrit
+
+import (
+	"context"
+
+	"github.com/majorcontext/moat/internal/provider"
+	"github.com/majorcontext/moat/internal/providers/githttp"
+)
+
+// Grant adds a Gerrit server to the credential.
+//
+// The server URL comes from --url or GERRIT_URL; the username and HTTP
+// password from GERRIT_USERNAME and GERRIT_HTTP_PASSWORD, or prompts.
+func (p *Provider) Grant(ctx context.Context) (*provider.Credential, error) {
+	return githttp.Grant(ctx, spec)
+}
+
+// checkInstance reads the caller's own account, which requires
+// authentication under Gerrit's /a/ prefix.
+func checkInstance(ctx context.Context, inst githttp.Instance) error {
+	return githttp.Probe(ctx, inst, "/a/accounts/self")
+}
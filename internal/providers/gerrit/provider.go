@@ -0,0 +1,77 @@
+package gerrit
+
+import (
+	"context"
+
+	"github.com/majorcontext/moat/internal/provider"
+	"github.com/majorcontext/moat/internal/providers/githttp"
+)
+
+// Provider implements provider.CredentialProvider for Gerrit.
+type Provider struct{}
+
+// Verify interface compliance at compile time.
+var (
+	_ provider.CredentialProvider = (*Provider)(nil)
+	_ provider.InitFileProvider   = (*Provider)(nil)
+	_ provider.CredentialChecker  = (*Provider)(nil)
+)
+
+func init() {
+	provider.Register(&Provider{})
+}
+
+// spec drives the shared grant flow.
+var spec = githttp.Spec{
+	Provider:    "gerrit",
+	DisplayName: "Gerrit",
+	SecretName:  "HTTP password",
+	SecretHelp: `Enter your Gerrit HTTP password.
+
+To generate one:
+  1. Open Settings > HTTP Credentials on your Gerrit server
+  2. Click "Generate new password"
+  3. Paste it below`,
+	URLEnv:    "GERRIT_URL",
+	UserEnv:   "GERRIT_USERNAME",
+	SecretEnv: "GERRIT_HTTP_PASSWORD",
+	Check:     checkInstance,
+}
+
+// Name returns the provider identifier.
+func (p *Provider) Name() string {
+	return "gerrit"
+}
+
+// ConfigureProxy injects Basic auth for each granted Gerrit server.
+func (p *Provider) ConfigureProxy(proxy provider.ProxyConfigurer, cred *provider.Credential) {
+	githttp.ConfigureProxy(proxy, cred, "gerrit")
+}
+
+// ContainerEnv disables git's interactive credential prompts.
+func (p *Provider) ContainerEnv(cred *provider.Credential) []string {
+	return []string{"GIT_TERMINAL_PROMPT=0"}
+}
+
+// ContainerInitFiles writes a ~/.netrc with placeholder passwords.
+func (p *Provider) ContainerInitFiles(cred *provider.Credential, containerHome string) map[string]string {
+	return githttp.InitFiles(cred, containerHome)
+}
+
+// ContainerMounts returns no mounts — ~/.netrc is written via moat-init.sh.
+func (p *Provider) ContainerMounts(cred *provider.Credential, containerHome string) ([]provider.MountConfig, string, error) {
+	return nil, "", nil
+}
+
+// Cleanup is a no-op — no temp files are created.
+func (p *Provider) Cleanup(cleanupPath string) {}
+
+// ImpliedDependencies returns dependencies implied by this provider.
+func (p *Provider) ImpliedDependencies() []string {
+	return []string{"git"}
+}
+
+// CheckCredential verifies every granted server still accepts its password.
+func (p *Provider) CheckCredential(ctx context.Context, cred *provider.Credential) error {
+	return githttp.CheckInstances(ctx, cred, checkInstance)
+}
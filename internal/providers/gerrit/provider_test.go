@@ -0,0 +1,53 @@
+package gerrit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/majorcontext/moat/internal/provider"
+	"github.com/majorcontext/moat/internal/providers/githttp"
+)
+
+func TestProvider_Registered(t *testing.T) {
+	p := provider.Get("gerrit")
+	if p == nil {
+		t.Fatal("gerrit provider not registered")
+	}
+	if deps := p.ImpliedDependencies(); len(deps) != 1 || deps[0] != "git" {
+		t.Errorf("ImpliedDependencies() = %v, want [git]", deps)
+	}
+}
+
+func TestProvider_CheckCredential(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/a/accounts/self" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if _, pass, _ := r.BasicAuth(); pass != "good" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	credFor := func(password string) *provider.Credential {
+		token, err := githttp.MarshalInstances([]githttp.Instance{{URL: srv.URL, Username: "alice", Password: password}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &provider.Credential{Provider: "gerrit", Token: token}
+	}
+
+	p := &Provider{}
+	if err := p.CheckCredential(context.Background(), credFor("good")); err != nil {
+		t.Errorf("CheckCredential(valid) = %v", err)
+	}
+	if err := p.CheckCredential(context.Background(), credFor("bad")); !errors.Is(err, provider.ErrCredentialRejected) {
+		t.Errorf("CheckCredential(invalid) = %v, want ErrCredentialRejected", err)
+	}
+}
@@ -0,0 +1,288 @@
+// Package githttp holds the pieces shared by credential providers for
+// self-hosted git servers that authenticate git and REST traffic over HTTPS
+// with a username and password or token (Gerrit, Bitbucket Server).
+//
+// A credential holds one Instance per server, JSON-encoded in the Token
+// field, so a single grant can cover several servers. The proxy injects HTTP
+// Basic auth per server host; the container only sees a ~/.netrc with
+// placeholder passwords, which keeps git and curl from prompting.
+package githttp
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/majorcontext/moat/internal/credential"
+	"github.com/majorcontext/moat/internal/provider"
+	"github.com/majorcontext/moat/internal/provider/util"
+)
+
+// Instance is one server and the account used to reach it.
+type Instance struct {
+	URL         string `json:"url"`      // base URL, e.g. "https://review.example.com/gerrit"
+	Username    string `json:"username"` // account name
+	Password    string `json:"password"` // HTTP password or access token
+	TokenSource string `json:"token_source,omitempty"`
+}
+
+// Host returns the host (with port, if any) the proxy injects credentials for.
+func (i Instance) Host() string {
+	u, err := url.Parse(i.URL)
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
+
+// BasicAuth returns the Authorization header value for the instance.
+func (i Instance) BasicAuth() string {
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(i.Username+":"+i.Password))
+}
+
+// Token source values stored in Instance.TokenSource.
+const (
+	SourceEnv    = "env"    // From the provider's environment variables
+	SourceManual = "manual" // Interactive prompt entry
+)
+
+// Placeholder is the password written to the container's ~/.netrc. The proxy
+// replaces the resulting Authorization header with the real credentials.
+const Placeholder = credential.ProxyInjectedPlaceholder
+
+// MarshalInstances encodes instances for storage in the Token field.
+func MarshalInstances(instances []Instance) (string, error) {
+	data, err := json.Marshal(instances)
+	if err != nil {
+		return "", fmt.Errorf("marshaling server entries: %w", err)
+	}
+	return string(data), nil
+}
+
+// UnmarshalInstances decodes instances from the JSON-encoded Token field.
+func UnmarshalInstances(token string) ([]Instance, error) {
+	var instances []Instance
+	if err := json.Unmarshal([]byte(token), &instances); err != nil {
+		return nil, fmt.Errorf("unmarshaling server entries: %w", err)
+	}
+	return instances, nil
+}
+
+// MergeInstance adds inst to instances, replacing any entry with the same URL.
+func MergeInstance(instances []Instance, inst Instance) []Instance {
+	for i, e := range instances {
+		if e.URL == inst.URL {
+			instances[i] = inst
+			return instances
+		}
+	}
+	return append(instances, inst)
+}
+
+// NormalizeURL validates a server base URL and strips trailing slashes, query,
+// and fragment.
+func NormalizeURL(raw string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return "", fmt.Errorf("invalid server URL %q: %w", raw, err)
+	}
+	if u.Scheme != "https" && u.Scheme != "http" {
+		return "", fmt.Errorf("invalid server URL %q: must start with https://", raw)
+	}
+	if u.Host == "" || u.User != nil {
+		return "", fmt.Errorf("invalid server URL %q: need a host and no embedded credentials", raw)
+	}
+	u.RawQuery, u.Fragment = "", ""
+	u.Path = strings.TrimRight(u.Path, "/")
+	u.RawPath = ""
+	return u.String(), nil
+}
+
+// ConfigureProxy injects Basic auth for each instance in cred, attributed to
+// grant.
+func ConfigureProxy(proxy provider.ProxyConfigurer, cred *provider.Credential, grant string) {
+	instances, err := UnmarshalInstances(cred.Token)
+	if err != nil {
+		return
+	}
+	for _, inst := range instances {
+		if host := inst.Host(); host != "" {
+			proxy.SetCredentialWithGrant(host, "Authorization", inst.BasicAuth(), grant)
+		}
+	}
+}
+
+// Netrc returns ~/.netrc entries for instances with placeholder passwords.
+// netrc matches on hostname only, so ports are dropped.
+func Netrc(instances []Instance) string {
+	var b strings.Builder
+	for _, inst := range instances {
+		u, err := url.Parse(inst.URL)
+		if err != nil || u.Hostname() == "" {
+			continue
+		}
+		fmt.Fprintf(&b, "machine %s login %s password %s\n", u.Hostname(), inst.Username, Placeholder)
+	}
+	return b.String()
+}
+
+// InitFiles returns the container's ~/.netrc for cred.
+func InitFiles(cred *provider.Credential, containerHome string) map[string]string {
+	instances, err := UnmarshalInstances(cred.Token)
+	if err != nil || len(instances) == 0 {
+		return nil
+	}
+	return map[string]string{
+		filepath.Join(containerHome, ".netrc"): Netrc(instances),
+	}
+}
+
+// CheckInstances probes every instance in cred with check, returning the
+// first error. Used by providers' CheckCredential.
+func CheckInstances(ctx context.Context, cred *provider.Credential, check func(ctx context.Context, inst Instance) error) error {
+	instances, err := UnmarshalInstances(cred.Token)
+	if err != nil {
+		return err
+	}
+	for _, inst := range instances {
+		if err := check(ctx, inst); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Probe issues an authenticated GET for path under inst's base URL and
+// classifies the response like util.ProbeCredential: 401 and 403 reject the
+// credential.
+func Probe(ctx context.Context, inst Instance, path string) error {
+	req, err := http.NewRequest("GET", inst.URL+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", inst.BasicAuth())
+	req.Header.Set("User-Agent", "moat")
+	probeCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	return util.ProbeCredential(probeCtx, req, http.StatusUnauthorized, http.StatusForbidden)
+}
+
+// Spec describes a provider built on this package.
+type Spec struct {
+	Provider    string // provider and grant name, e.g. "gerrit"
+	DisplayName string // e.g. "Gerrit"
+	SecretName  string // what the password is called, e.g. "HTTP password"
+	SecretHelp  string // where to create one, printed before the prompt
+	URLEnv      string // env var holding the server URL
+	UserEnv     string // env var holding the username
+	SecretEnv   string // env var holding the password
+
+	// Check verifies inst against the server.
+	Check func(ctx context.Context, inst Instance) error
+}
+
+// ctxKeyURL is the context key for the --url flag.
+type ctxKeyURL struct{}
+
+// WithGrantOptions returns a context carrying the --url grant flag.
+func WithGrantOptions(ctx context.Context, serverURL string) context.Context {
+	return context.WithValue(ctx, ctxKeyURL{}, serverURL)
+}
+
+// Grant adds a server to spec's credential, reading the URL from the --url
+// flag or spec.URLEnv and the username and password from the environment or
+// interactive prompts. Servers granted earlier are kept.
+func Grant(ctx context.Context, spec Spec) (*provider.Credential, error) {
+	rawURL, _ := ctx.Value(ctxKeyURL{}).(string)
+	if rawURL == "" {
+		rawURL = os.Getenv(spec.URLEnv)
+	}
+	if rawURL == "" {
+		return nil, &provider.GrantError{
+			Provider: spec.Provider,
+			Cause:    fmt.Errorf("no server URL"),
+			Hint:     fmt.Sprintf("Run 'moat grant %s --url https://<server>' or set %s", spec.Provider, spec.URLEnv),
+		}
+	}
+	serverURL, err := NormalizeURL(rawURL)
+	if err != nil {
+		return nil, &provider.GrantError{Provider: spec.Provider, Cause: err}
+	}
+
+	inst := Instance{URL: serverURL, TokenSource: SourceEnv}
+	inst.Username = os.Getenv(spec.UserEnv)
+	inst.Password = os.Getenv(spec.SecretEnv)
+	if inst.Username == "" || inst.Password == "" {
+		if err := util.RequireInput(ctx, fmt.Sprintf("set %s and %s", spec.UserEnv, spec.SecretEnv)); err != nil {
+			return nil, err
+		}
+		inst.TokenSource = SourceManual
+	}
+	if inst.Username == "" {
+		fmt.Printf("%s username for %s: ", spec.DisplayName, serverURL)
+		line, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		inst.Username = strings.TrimSpace(line)
+	}
+	if inst.Password == "" {
+		fmt.Println(spec.SecretHelp)
+		inst.Password, err = util.PromptForToken(ctx, spec.SecretName)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", spec.SecretName, err)
+		}
+	} else {
+		fmt.Printf("Using %s from %s environment variable\n", spec.SecretName, spec.SecretEnv)
+	}
+	if inst.Username == "" || inst.Password == "" {
+		return nil, &provider.GrantError{
+			Provider: spec.Provider,
+			Cause:    fmt.Errorf("username and %s are required", spec.SecretName),
+			Hint:     fmt.Sprintf("Run 'moat grant %s --url %s' and enter both", spec.Provider, serverURL),
+		}
+	}
+
+	fmt.Println("Validating...")
+	if err := spec.Check(ctx, inst); err != nil {
+		return nil, &provider.GrantError{
+			Provider: spec.Provider,
+			Cause:    fmt.Errorf("validation failed for %s: %w", serverURL, err),
+			Hint:     fmt.Sprintf("Check the username and %s, and that %s is reachable", spec.SecretName, serverURL),
+		}
+	}
+	fmt.Printf("Authenticated to %s as %q\n", serverURL, inst.Username)
+
+	instances, _ := loadExisting(credential.Provider(spec.Provider))
+	token, err := MarshalInstances(MergeInstance(instances, inst))
+	if err != nil {
+		return nil, err
+	}
+	return &provider.Credential{
+		Provider:  spec.Provider,
+		Token:     token,
+		CreatedAt: time.Now(),
+	}, nil
+}
+
+// loadExisting loads the servers already granted for prov.
+func loadExisting(prov credential.Provider) ([]Instance, error) {
+	key, err := credential.DefaultEncryptionKey()
+	if err != nil {
+		return nil, err
+	}
+	store, err := credential.NewFileStore(credential.DefaultStoreDir(), key)
+	if err != nil {
+		return nil, err
+	}
+	cred, err := store.Get(prov)
+	if err != nil {
+		return nil, err
+	}
+	return UnmarshalInstances(cred.Token)
+}
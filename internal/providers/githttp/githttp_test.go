@@ -0,0 +1,111 @@
+package githttp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/majorcontext/moat/internal/provider"
+)
+
+type mockProxy struct {
+	credentials map[string]string
+	grants      map[string]string
+}
+
+func (m *mockProxy) SetCredential(host, value string)                                   {}
+func (m *mockProxy) SetCredentialHeader(host, headerName, headerValue string)           {}
+func (m *mockProxy) AddExtraHeader(host, headerName, headerValue string)                {}
+func (m *mockProxy) RemoveRequestHeader(host, header string)                            {}
+func (m *mockProxy) SetTokenSubstitution(host, placeholder, realToken string)           {}
+func (m *mockProxy) AddResponseTransformer(host string, t provider.ResponseTransformer) {}
+func (m *mockProxy) SetCredentialWithGrant(host, headerName, headerValue, grant string) {
+	m.credentials[host] = headerName + ": " + headerValue
+	m.grants[host] = grant
+}
+
+func TestNormalizeURL(t *testing.T) {
+	tests := map[string]string{
+		"https://review.example.com":          "https://review.example.com",
+		"https://review.example.com/":         "https://review.example.com",
+		" https://example.com/gerrit/?q=1#x ": "https://example.com/gerrit",
+		"http://bitbucket.internal:7990/":     "http://bitbucket.internal:7990",
+	}
+	for in, want := range tests {
+		got, err := NormalizeURL(in)
+		if err != nil || got != want {
+			t.Errorf("NormalizeURL(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"review.example.com", "ssh://review.example.com", "https://", "https://user:pw@example.com"} {
+		if _, err := NormalizeURL(in); err == nil {
+			t.Errorf("NormalizeURL(%q) succeeded, want error", in)
+		}
+	}
+}
+
+func TestMergeInstance(t *testing.T) {
+	instances := []Instance{{URL: "https://a.example.com", Username: "old"}}
+	instances = MergeInstance(instances, Instance{URL: "https://b.example.com", Username: "b"})
+	instances = MergeInstance(instances, Instance{URL: "https://a.example.com", Username: "new"})
+	if len(instances) != 2 || instances[0].Username != "new" || instances[1].Username != "b" {
+		t.Errorf("MergeInstance result = %+v", instances)
+	}
+}
+
+func TestConfigureProxyAndNetrc(t *testing.T) {
+	token, err := MarshalInstances([]Instance{
+		{URL: "https://review.example.com/gerrit", Username: "alice", Password: "s3cret"},
+		{URL: "https://bitbucket.example.com:7990", Username: "bob", Password: "tok"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cred := &provider.Credential{Token: token}
+
+	proxy := &mockProxy{credentials: map[string]string{}, grants: map[string]string{}}
+	ConfigureProxy(proxy, cred, "gerrit")
+	if got := proxy.credentials["review.example.com"]; got != "Authorization: Basic YWxpY2U6czNjcmV0" {
+		t.Errorf("review.example.com credential = %q", got)
+	}
+	if got := proxy.credentials["bitbucket.example.com:7990"]; got != "Authorization: Basic Ym9iOnRvaw==" {
+		t.Errorf("bitbucket.example.com:7990 credential = %q", got)
+	}
+	if proxy.grants["review.example.com"] != "gerrit" {
+		t.Errorf("grant = %q, want gerrit", proxy.grants["review.example.com"])
+	}
+
+	files := InitFiles(cred, "/home/moatuser")
+	want := "machine review.example.com login alice password moat-proxy-injected\n" +
+		"machine bitbucket.example.com login bob password moat-proxy-injected\n"
+	if got := files["/home/moatuser/.netrc"]; got != want {
+		t.Errorf(".netrc = %q, want %q", got, want)
+	}
+}
+
+func TestProbe(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, ok := r.BasicAuth()
+		if r.URL.Path != "/base/a/accounts/self" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if !ok || user != "alice" || pass != "good" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	inst := Instance{URL: srv.URL + "/base", Username: "alice", Password: "good"}
+	if err := Probe(context.Background(), inst, "/a/accounts/self"); err != nil {
+		t.Errorf("Probe(valid) = %v", err)
+	}
+	inst.Password = "bad"
+	if err := Probe(context.Background(), inst, "/a/accounts/self"); !errors.Is(err, provider.ErrCredentialRejected) {
+		t.Errorf("Probe(invalid) = %v, want ErrCredentialRejected", err)
+	}
+}
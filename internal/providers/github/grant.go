@@ -19,6 +19,8 @@ import (
 //  1. GITHUB_TOKEN or GH_TOKEN environment variable
 //  2. gh CLI token via `gh auth token`
 //  3. Interactive PAT prompt
+//
+// With --from-env set in ctx, the gh CLI is not consulted.
 func (p *Provider) Grant(ctx context.Context) (*provider.Credential, error) {
 	// Priority 1: Environment variable
 	if token, name := util.CheckEnvVarWithName("GITHUB_TOKEN", "GH_TOKEN"); token != "" {
@@ -27,25 +29,30 @@ func (p *Provider) Grant(ctx context.Context) (*provider.Credential, error) {
 	}
 
 	// Priority 2: gh CLI
-	token, ghErr := getGHCLIToken(ctx)
-	if ghErr == nil && token != "" {
-		fmt.Println("Found gh CLI authentication")
-		confirmed, err := util.Confirm("Use token from gh CLI?")
-		if err != nil {
-			return nil, fmt.Errorf("reading confirmation: %w", err)
-		}
-		if confirmed {
-			return p.validateAndCreateCredential(ctx, token, SourceCLI)
+	if !util.GrantOptionsFrom(ctx).EnvOnly {
+		token, ghErr := getGHCLIToken(ctx)
+		if ghErr == nil && token != "" {
+			fmt.Println("Found gh CLI authentication")
+			confirmed, err := util.Confirm(ctx, "Use token from gh CLI?")
+			if err != nil {
+				return nil, fmt.Errorf("reading confirmation: %w", err)
+			}
+			if confirmed {
+				return p.validateAndCreateCredential(ctx, token, SourceCLI)
+			}
+			fmt.Println() // spacing before prompt
+		} else if ghErr != nil && isGHCLIInstalled() {
+			// gh CLI is installed but failed - warn user
+			fmt.Printf("Note: gh CLI found but 'gh auth token' failed: %v\n", ghErr)
+			fmt.Println("You may need to run 'gh auth login' first.")
+			fmt.Println()
 		}
-		fmt.Println() // spacing before prompt
-	} else if ghErr != nil && isGHCLIInstalled() {
-		// gh CLI is installed but failed - warn user
-		fmt.Printf("Note: gh CLI found but 'gh auth token' failed: %v\n", ghErr)
-		fmt.Println("You may need to run 'gh auth login' first.")
-		fmt.Println()
 	}
 
 	// Priority 3: Interactive prompt
+	if err := util.RequireInput(ctx, "set GITHUB_TOKEN or GH_TOKEN"); err != nil {
+		return nil, err
+	}
 	fmt.Println(`Enter a GitHub Personal Access Token.
 
 To create one:
@@ -55,7 +62,7 @@ To create one:
   4. Under "Repository permissions", grant "Contents" read/write access
   5. Copy the generated token`)
 
-	token, err := util.PromptForToken("Token")
+	token, err := util.PromptForToken(ctx, "Token")
 	if err != nil {
 		return nil, fmt.Errorf("reading token: %w", err)
 	}
@@ -133,6 +140,23 @@ func validateGitHubToken(ctx context.Context, token string) (string, error) {
 	}
 }
 
+// userURL is the endpoint CheckCredential probes. A variable so tests can
+// point it at a local server.
+var userURL = "https://api.github.com/user"
+
+// CheckCredential verifies the token against GitHub's /user endpoint.
+// Only 401 counts as a rejection: a 403 is usually rate limiting.
+func (p *Provider) CheckCredential(ctx context.Context, cred *provider.Credential) error {
+	req, err := http.NewRequest("GET", userURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+cred.Token)
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("User-Agent", "moat")
+	return util.ProbeCredential(ctx, req)
+}
+
 // getGHCLIToken retrieves the GitHub token from gh CLI if available.
 func getGHCLIToken(ctx context.Context) (string, error) {
 	cmd := exec.CommandContext(ctx, "gh", "auth", "token")
@@ -27,6 +27,7 @@ var (
 	_ provider.CredentialProvider  = (*Provider)(nil)
 	_ provider.RefreshableProvider = (*Provider)(nil)
 	_ provider.InitFileProvider    = (*Provider)(nil)
+	_ provider.CredentialChecker   = (*Provider)(nil)
 )
 
 func init() {
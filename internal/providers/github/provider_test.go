@@ -3,6 +3,9 @@ package github
 import (
 	"context"
 	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"strings"
@@ -526,3 +529,36 @@ func TestProvider_InitRegistration(t *testing.T) {
 		t.Errorf("Name() = %v, want %v", p.Name(), "github")
 	}
 }
+
+func TestCheckCredential(t *testing.T) {
+	var gotAuth string
+	status := http.StatusOK
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+	orig := userURL
+	userURL = srv.URL
+	defer func() { userURL = orig }()
+
+	p := &Provider{}
+	cred := &provider.Credential{Token: "ghp_test"}
+	if err := p.CheckCredential(context.Background(), cred); err != nil {
+		t.Fatalf("CheckCredential: %v", err)
+	}
+	if gotAuth != "Bearer ghp_test" {
+		t.Errorf("Authorization = %q, want Bearer token", gotAuth)
+	}
+
+	status = http.StatusUnauthorized
+	if err := p.CheckCredential(context.Background(), cred); !errors.Is(err, provider.ErrCredentialRejected) {
+		t.Errorf("401: err = %v, want ErrCredentialRejected", err)
+	}
+
+	// 403 is usually rate limiting, not a bad token.
+	status = http.StatusForbidden
+	if err := p.CheckCredential(context.Background(), cred); err == nil || errors.Is(err, provider.ErrCredentialRejected) {
+		t.Errorf("403: err = %v, want inconclusive error", err)
+	}
+}
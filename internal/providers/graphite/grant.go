@@ -31,6 +31,9 @@ func (p *Provider) Grant(ctx context.Context) (*provider.Credential, error) {
 	}
 
 	// Priority 2: Interactive prompt
+	if err := util.RequireInput(ctx, "set GRAPHITE_TOKEN or GT_TOKEN"); err != nil {
+		return nil, err
+	}
 	fmt.Println(`Enter a Graphite auth token.
 
 To get your token:
@@ -38,7 +41,7 @@ To get your token:
   2. Sign in and copy the token
   3. Paste it below`)
 
-	token, err := util.PromptForToken("Token")
+	token, err := util.PromptForToken(ctx, "Token")
 	if err != nil {
 		return nil, fmt.Errorf("reading token: %w", err)
 	}
@@ -41,6 +41,9 @@ func (p *Provider) Grant(ctx context.Context) (*provider.Credential, error) {
 		token = envToken
 	} else {
 		// Priority 2: Interactive prompt
+		if err := util.RequireInput(ctx, "set META_ACCESS_TOKEN"); err != nil {
+			return nil, err
+		}
 		fmt.Println(`Enter a Meta access token.
 
 To create one:
@@ -49,7 +52,7 @@ To create one:
   3. Provide your app ID and secret next to enable automatic token refresh`)
 
 		var err error
-		token, err = util.PromptForToken("Access token")
+		token, err = util.PromptForToken(ctx, "Access token")
 		if err != nil {
 			return nil, fmt.Errorf("reading token: %w", err)
 		}
@@ -83,13 +86,15 @@ To create one:
 		fmt.Println("Found META_APP_ID and META_APP_SECRET for token refresh")
 		metadata[MetaKeyAppID] = appID
 		metadata[MetaKeyAppSecret] = appSecret
+	} else if util.GrantOptionsFrom(ctx).NonInteractive {
+		fmt.Println("Token refresh disabled (set META_APP_ID and META_APP_SECRET to enable)")
 	} else {
 		fmt.Println("\nOptional: provide app ID and app secret to enable automatic token refresh.")
 		fmt.Println("Press Enter to skip.")
 
 		if appID == "" {
 			var promptErr error
-			appID, promptErr = util.PromptForToken("App ID (or Enter to skip)")
+			appID, promptErr = util.PromptForToken(ctx, "App ID (or Enter to skip)")
 			if promptErr != nil {
 				return nil, fmt.Errorf("reading app ID: %w", promptErr)
 			}
@@ -97,7 +102,7 @@ To create one:
 
 		if appID != "" && appSecret == "" {
 			var promptErr error
-			appSecret, promptErr = util.PromptForToken("App secret")
+			appSecret, promptErr = util.PromptForToken(ctx, "App secret")
 			if promptErr != nil {
 				return nil, fmt.Errorf("reading app secret: %w", promptErr)
 			}
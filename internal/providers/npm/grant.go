@@ -42,8 +42,10 @@ func (p *Provider) grantDiscover(ctx context.Context) (*provider.Credential, err
 	var entries []RegistryEntry
 
 	// Check .npmrc
-	npmrcEntries, _ := discoverFromNpmrc()
-	entries = append(entries, npmrcEntries...)
+	if !util.GrantOptionsFrom(ctx).EnvOnly {
+		npmrcEntries, _ := discoverFromNpmrc()
+		entries = append(entries, npmrcEntries...)
+	}
 
 	// Check NPM_TOKEN env var — fill in empty tokens from env var references
 	// (e.g., .npmrc has //registry.npmjs.org/:_authToken=${NPM_TOKEN})
@@ -76,6 +78,12 @@ func (p *Provider) grantDiscover(ctx context.Context) (*provider.Credential, err
 		}
 	}
 
+	if len(entries) == 0 {
+		if err := util.RequireInput(ctx, "set NPM_TOKEN"); err != nil {
+			return nil, err
+		}
+	}
+
 	reader := bufio.NewReader(os.Stdin)
 
 	for {
@@ -113,9 +121,7 @@ func (p *Provider) grantDiscover(ctx context.Context) (*provider.Credential, err
 		fmt.Println()
 
 		maxOpt := optNum
-		fmt.Printf("Enter choice [1-%d]: ", maxOpt)
-		response, _ := reader.ReadString('\n')
-		response = strings.TrimSpace(response)
+		response, _ := util.ReadChoice(ctx, reader, fmt.Sprintf("Enter choice [1-%d]: ", maxOpt), "1")
 
 		if response == "" {
 			response = "1"
@@ -183,7 +189,10 @@ func (p *Provider) grantSpecificHost(ctx context.Context, host string) (*provide
 	// Try to find token from .npmrc or environment
 	var discoveredToken string
 	var discoveredSource string
-	npmrcEntries, _ := discoverFromNpmrc()
+	var npmrcEntries []RegistryEntry
+	if !util.GrantOptionsFrom(ctx).EnvOnly {
+		npmrcEntries, _ = discoverFromNpmrc()
+	}
 	for _, entry := range npmrcEntries {
 		if entry.Host == host {
 			discoveredToken = entry.Token
@@ -212,9 +221,7 @@ func (p *Provider) grantSpecificHost(ctx context.Context, host string) (*provide
 			fmt.Printf("  1. Use token from %s\n", sourceName)
 			fmt.Println("  2. Enter token manually")
 			fmt.Println()
-			fmt.Print("Enter choice [1-2]: ")
-			response, _ := reader.ReadString('\n')
-			response = strings.TrimSpace(response)
+			response, _ := util.ReadChoice(ctx, reader, "Enter choice [1-2]: ", "1")
 			if response == "" {
 				response = "1"
 			}
@@ -227,7 +234,7 @@ func (p *Provider) grantSpecificHost(ctx context.Context, host string) (*provide
 				fmt.Println()
 				fmt.Printf("Enter npm token for %s\n", host)
 				var err error
-				token, err = util.PromptForToken("Token")
+				token, err = util.PromptForToken(ctx, "Token")
 				if err != nil {
 					return nil, fmt.Errorf("reading token: %w", err)
 				}
@@ -248,7 +255,7 @@ func (p *Provider) grantSpecificHost(ctx context.Context, host string) (*provide
 	} else {
 		fmt.Printf("Enter npm token for %s\n", host)
 		var err error
-		token, err = util.PromptForToken("Token")
+		token, err = util.PromptForToken(ctx, "Token")
 		if err != nil {
 			return nil, fmt.Errorf("reading token: %w", err)
 		}
@@ -310,7 +317,7 @@ func (p *Provider) grantSpecificHost(ctx context.Context, host string) (*provide
 // grantManualToken prompts for a token for a specific host, validates it, and saves.
 func (p *Provider) grantManualToken(ctx context.Context, host string) (*provider.Credential, error) {
 	fmt.Printf("Enter npm token for %s\n", host)
-	token, err := util.PromptForToken("Token")
+	token, err := util.PromptForToken(ctx, "Token")
 	if err != nil {
 		return nil, fmt.Errorf("reading token: %w", err)
 	}
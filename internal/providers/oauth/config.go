@@ -20,6 +20,11 @@ type Config struct {
 	ClientSecret string `yaml:"client_secret,omitempty"`
 	Scopes       string `yaml:"scopes,omitempty"`
 
+	// CallbackPort fixes the port of the local redirect URI
+	// (http://127.0.0.1:<port>/callback) for authorization servers that
+	// require an exact registered redirect URI. Zero picks a free port.
+	CallbackPort int `yaml:"callback_port,omitempty"`
+
 	// RegistrationEndpoint is set by discovery when Dynamic Client
 	// Registration (RFC 7591) is available. It is not persisted to YAML;
 	// once DCR succeeds the resulting ClientID is cached instead.
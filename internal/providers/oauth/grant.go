@@ -73,9 +73,10 @@ func buildAuthURL(cfg *Config, state, challenge, redirectURI, resource string) s
 	return u.String()
 }
 
-// startCallbackServer starts a local HTTP server on a random port to receive the OAuth callback.
-func startCallbackServer(expectedState string, codeCh chan<- string, errCh chan<- error) (*http.Server, int, error) {
-	ln, err := net.Listen("tcp", "127.0.0.1:0")
+// startCallbackServer starts a local HTTP server to receive the OAuth callback.
+// A zero port picks a random free port.
+func startCallbackServer(port int, expectedState string, codeCh chan<- string, errCh chan<- error) (*http.Server, int, error) {
+	ln, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port))
 	if err != nil {
 		return nil, 0, fmt.Errorf("listen: %w", err)
 	}
@@ -84,7 +85,7 @@ func startCallbackServer(expectedState string, codeCh chan<- string, errCh chan<
 		ln.Close()
 		return nil, 0, fmt.Errorf("unexpected address type %T", ln.Addr())
 	}
-	port := tcpAddr.Port
+	port = tcpAddr.Port
 
 	mux := http.NewServeMux()
 	mux.HandleFunc("/callback", func(w http.ResponseWriter, r *http.Request) {
@@ -214,7 +215,7 @@ func RunGrant(ctx context.Context, name string, cfg *Config, resource string) (*
 	codeCh := make(chan string, 1)
 	errCh := make(chan error, 1)
 
-	srv, port, err := startCallbackServer(state, codeCh, errCh)
+	srv, port, err := startCallbackServer(cfg.CallbackPort, state, codeCh, errCh)
 	if err != nil {
 		return nil, fmt.Errorf("starting callback server: %w", err)
 	}
@@ -98,7 +98,7 @@ func TestCallbackServer(t *testing.T) {
 	codeCh := make(chan string, 1)
 	errCh := make(chan error, 1)
 
-	srv, port, err := startCallbackServer("goodstate", codeCh, errCh)
+	srv, port, err := startCallbackServer(0, "goodstate", codeCh, errCh)
 	if err != nil {
 		t.Fatalf("startCallbackServer: %v", err)
 	}
@@ -129,7 +129,7 @@ func TestCallbackServerBadState(t *testing.T) {
 	codeCh := make(chan string, 1)
 	errCh := make(chan error, 1)
 
-	srv, port, err := startCallbackServer("expected", codeCh, errCh)
+	srv, port, err := startCallbackServer(0, "expected", codeCh, errCh)
 	if err != nil {
 		t.Fatalf("startCallbackServer: %v", err)
 	}
@@ -155,7 +155,7 @@ func TestCallbackServerErrorParam(t *testing.T) {
 	codeCh := make(chan string, 1)
 	errCh := make(chan error, 1)
 
-	srv, port, err := startCallbackServer("s", codeCh, errCh)
+	srv, port, err := startCallbackServer(0, "s", codeCh, errCh)
 	if err != nil {
 		t.Fatalf("startCallbackServer: %v", err)
 	}
@@ -6,16 +6,29 @@ package providers
 
 import (
 	// Import all providers to trigger their init() registration.
-	_ "github.com/majorcontext/moat/internal/providers/aws"      // registers AWS provider
-	_ "github.com/majorcontext/moat/internal/providers/claude"   // registers Claude/Anthropic provider
-	_ "github.com/majorcontext/moat/internal/providers/codex"    // registers Codex/OpenAI provider
-	_ "github.com/majorcontext/moat/internal/providers/gemini"   // registers Gemini/Google provider
-	_ "github.com/majorcontext/moat/internal/providers/github"   // registers GitHub provider
-	_ "github.com/majorcontext/moat/internal/providers/graphite" // registers Graphite provider
-	_ "github.com/majorcontext/moat/internal/providers/meta"     // registers Meta provider
-	_ "github.com/majorcontext/moat/internal/providers/npm"      // registers npm provider
-	_ "github.com/majorcontext/moat/internal/providers/oauth"    // registers OAuth provider
-	_ "github.com/majorcontext/moat/internal/providers/pi"       // registers Pi provider
+	_ "github.com/majorcontext/moat/internal/providers/atlassian"         // registers Atlassian/Bitbucket Cloud provider
+	_ "github.com/majorcontext/moat/internal/providers/aws"               // registers AWS provider
+	_ "github.com/majorcontext/moat/internal/providers/azure"             // registers Azure provider
+	_ "github.com/majorcontext/moat/internal/providers/azuredevops"       // registers Azure DevOps provider
+	_ "github.com/majorcontext/moat/internal/providers/bigquery"          // registers BigQuery provider
+	_ "github.com/majorcontext/moat/internal/providers/bitbucket"         // registers Bitbucket Server provider
+	_ "github.com/majorcontext/moat/internal/providers/claude"            // registers Claude/Anthropic provider
+	_ "github.com/majorcontext/moat/internal/providers/codex"             // registers Codex/OpenAI provider
+	_ "github.com/majorcontext/moat/internal/providers/containerregistry" // registers container registry provider
+	_ "github.com/majorcontext/moat/internal/providers/gcp"               // registers GCP provider
+	_ "github.com/majorcontext/moat/internal/providers/gemini"            // registers Gemini/Google provider
+	_ "github.com/majorcontext/moat/internal/providers/gerrit"            // registers Gerrit provider
+	_ "github.com/majorcontext/moat/internal/providers/github"            // registers GitHub provider
+	_ "github.com/majorcontext/moat/internal/providers/graphite"          // registers Graphite provider
+	_ "github.com/majorcontext/moat/internal/providers/meta"              // registers Meta provider
+	_ "github.com/majorcontext/moat/internal/providers/npm"               // registers npm provider
+	_ "github.com/majorcontext/moat/internal/providers/oauth"             // registers OAuth provider
+	_ "github.com/majorcontext/moat/internal/providers/pi"                // registers Pi provider
+	_ "github.com/majorcontext/moat/internal/providers/registry"          // registers private package registry provider
+	_ "github.com/majorcontext/moat/internal/providers/sendgrid"          // registers SendGrid provider
+	_ "github.com/majorcontext/moat/internal/providers/snowflake"         // registers Snowflake provider
+	_ "github.com/majorcontext/moat/internal/providers/stripe"            // registers Stripe provider
+	_ "github.com/majorcontext/moat/internal/providers/twilio"            // registers Twilio provider
 
 	"github.com/majorcontext/moat/internal/providers/configprovider"
 )
@@ -0,0 +1,101 @@
+package registry
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/majorcontext/moat/internal/providers/npm"
+)
+
+// Container paths of the generated client configuration.
+const (
+	ContainerConfigDir = "/moat/registry"
+	ContainerNpmrc     = ContainerConfigDir + "/npmrc"
+	ContainerPipConf   = ContainerConfigDir + "/pip.conf"
+)
+
+// byType returns the registries of the given type, in grant order.
+func byType(registries []Registry, typ string) []Registry {
+	var out []Registry
+	for _, r := range registries {
+		if r.Type == typ {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// GenerateNpmrc returns npm configuration routing scopes, or all packages,
+// to the npm registries. Tokens are placeholders; the proxy replaces the
+// Authorization header.
+func GenerateNpmrc(registries []Registry) string {
+	var b strings.Builder
+	var hasDefault bool
+	for _, r := range byType(registries, TypeNpm) {
+		if len(r.Scopes) == 0 && !hasDefault {
+			fmt.Fprintf(&b, "registry=%s\n", r.URL)
+			hasDefault = true
+		}
+		for _, scope := range r.Scopes {
+			fmt.Fprintf(&b, "%s:registry=%s\n", scope, r.URL)
+		}
+	}
+	// npm matches auth lines on the registry URL without its scheme.
+	for _, r := range byType(registries, TypeNpm) {
+		fmt.Fprintf(&b, "%s:_authToken=%s\n", strings.TrimPrefix(r.URL, "https:"), npm.NpmTokenPlaceholder)
+	}
+	return b.String()
+}
+
+// pypiIndexes returns the primary PyPI index URL ("" keeps pypi.org) and
+// the extra index URLs.
+func pypiIndexes(registries []Registry) (primary string, extra []string) {
+	for _, r := range byType(registries, TypePyPI) {
+		if !r.Extra && primary == "" {
+			primary = r.URL
+			continue
+		}
+		extra = append(extra, r.URL)
+	}
+	return primary, extra
+}
+
+// GeneratePipConf returns pip configuration pointing at the PyPI indexes.
+// The URLs carry no credentials; the proxy adds the Authorization header.
+func GeneratePipConf(registries []Registry) string {
+	primary, extra := pypiIndexes(registries)
+	var b strings.Builder
+	b.WriteString("[global]\n")
+	if primary != "" {
+		fmt.Fprintf(&b, "index-url = %s\n", primary)
+	}
+	if len(extra) > 0 {
+		b.WriteString("extra-index-url =\n")
+		for _, u := range extra {
+			fmt.Fprintf(&b, "    %s\n", u)
+		}
+	}
+	return b.String()
+}
+
+// containerEnv returns the variables pointing npm, pip, and uv at the
+// generated configuration.
+func containerEnv(registries []Registry) []string {
+	var env []string
+	if len(byType(registries, TypeNpm)) > 0 {
+		// The global config layer, so a ~/.npmrc from the npm grant or the
+		// project still applies on top.
+		env = append(env, "NPM_CONFIG_GLOBALCONFIG="+ContainerNpmrc)
+	}
+	if len(byType(registries, TypePyPI)) > 0 {
+		env = append(env, "PIP_CONFIG_FILE="+ContainerPipConf)
+		primary, extra := pypiIndexes(registries)
+		if primary != "" {
+			env = append(env, "UV_DEFAULT_INDEX="+primary)
+		}
+		if len(extra) > 0 {
+			env = append(env, "UV_INDEX="+strings.Join(extra, " "))
+		}
+	}
+	return env
+}
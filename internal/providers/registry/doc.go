@@ -0,0 +1,9 @@
+// Package registry implements a credential provider for private npm
+// registries and PyPI package indexes (grant name "registry").
+//
+// A credential holds one Registry per URL, JSON-encoded in the Token field.
+// The proxy injects each registry's Authorization header for its host, so
+// tokens stay on the host. The container gets a generated npmrc and
+// pip.conf that point npm, pip, and uv at the registries with placeholder
+// credentials; the real header is added as requests pass through the proxy.
+package registry
@@ -0,0 +1,148 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/majorcontext/moat/internal/credential"
+	"github.com/majorcontext/moat/internal/provider"
+	"github.com/majorcontext/moat/internal/provider/util"
+)
+
+// GrantOptions carries the grant arguments.
+type GrantOptions struct {
+	URL      string   // registry URL argument
+	Type     string   // --type
+	Username string   // --username
+	Scopes   []string // --scope (npm)
+	Extra    bool     // --extra (PyPI)
+}
+
+// ctxKeyOptions is the context key for GrantOptions.
+type ctxKeyOptions struct{}
+
+// WithGrantOptions returns a context carrying the grant arguments.
+func WithGrantOptions(ctx context.Context, opts GrantOptions) context.Context {
+	return context.WithValue(ctx, ctxKeyOptions{}, opts)
+}
+
+// Grant adds a registry to the credential, replacing an earlier grant for
+// the same URL. The token is read from REGISTRY_TOKEN or prompted for.
+func (p *Provider) Grant(ctx context.Context) (*provider.Credential, error) {
+	opts, _ := ctx.Value(ctxKeyOptions{}).(GrantOptions)
+	r, err := registryFromOptions(opts)
+	if err != nil {
+		return nil, &provider.GrantError{
+			Provider: "registry",
+			Cause:    err,
+			Hint:     "Run 'moat grant registry <url> --type npm|pypi'",
+		}
+	}
+
+	r.Token = os.Getenv("REGISTRY_TOKEN")
+	r.TokenSource = SourceEnv
+	if r.Token != "" {
+		fmt.Println("Using token from REGISTRY_TOKEN environment variable")
+	} else {
+		if err := util.RequireInput(ctx, "set REGISTRY_TOKEN"); err != nil {
+			return nil, err
+		}
+		r.TokenSource = SourceManual
+		fmt.Printf("Enter the token for %s\n", r.URL)
+		r.Token, err = util.PromptForToken(ctx, "Token")
+		if err != nil {
+			return nil, fmt.Errorf("reading token: %w", err)
+		}
+		if r.Token == "" {
+			return nil, &provider.GrantError{
+				Provider: "registry",
+				Cause:    fmt.Errorf("no token provided"),
+				Hint:     "Run 'moat grant registry " + r.URL + "' and enter a valid token",
+			}
+		}
+	}
+
+	fmt.Print("Validating... ")
+	if err := checkRegistry(ctx, r); err != nil {
+		fmt.Println()
+		return nil, &provider.GrantError{
+			Provider: "registry",
+			Cause:    fmt.Errorf("validation failed for %s: %w", r.URL, err),
+			Hint:     "Check the token, and --username if the registry uses Basic auth",
+		}
+	}
+	fmt.Println("✓")
+
+	registries, _ := loadExisting()
+	token, err := MarshalRegistries(MergeRegistry(registries, r))
+	if err != nil {
+		return nil, err
+	}
+	return &provider.Credential{
+		Provider:  "registry",
+		Token:     token,
+		CreatedAt: time.Now(),
+	}, nil
+}
+
+// registryFromOptions validates the grant arguments and returns the
+// registry they describe, without its token.
+func registryFromOptions(opts GrantOptions) (Registry, error) {
+	if opts.URL == "" {
+		return Registry{}, fmt.Errorf("a registry URL is required")
+	}
+	u, err := NormalizeURL(opts.URL)
+	if err != nil {
+		return Registry{}, err
+	}
+	r := Registry{Type: opts.Type, URL: u, Username: opts.Username, Extra: opts.Extra}
+
+	switch opts.Type {
+	case TypeNpm:
+		if opts.Extra {
+			return r, fmt.Errorf("--extra applies to PyPI indexes only")
+		}
+		for _, s := range opts.Scopes {
+			s = strings.TrimSpace(s)
+			if s == "" {
+				continue
+			}
+			if !strings.HasPrefix(s, "@") {
+				s = "@" + s
+			}
+			r.Scopes = append(r.Scopes, s)
+		}
+	case TypePyPI:
+		if len(opts.Scopes) > 0 {
+			return r, fmt.Errorf("--scope applies to npm registries only")
+		}
+		if r.Username == "" {
+			r.Username = PyPITokenUser
+		}
+	case "":
+		return r, fmt.Errorf("--type is required")
+	default:
+		return r, fmt.Errorf("unknown registry type %q (want npm or pypi)", opts.Type)
+	}
+	return r, nil
+}
+
+// loadExisting loads the registries already granted.
+func loadExisting() ([]Registry, error) {
+	key, err := credential.DefaultEncryptionKey()
+	if err != nil {
+		return nil, err
+	}
+	store, err := credential.NewFileStore(credential.DefaultStoreDir(), key)
+	if err != nil {
+		return nil, err
+	}
+	cred, err := store.Get(credential.ProviderRegistry)
+	if err != nil {
+		return nil, err
+	}
+	return UnmarshalRegistries(cred.Token)
+}
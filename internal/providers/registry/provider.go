@@ -0,0 +1,147 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/majorcontext/moat/internal/provider"
+	"github.com/majorcontext/moat/internal/provider/util"
+)
+
+// Provider implements provider.CredentialProvider for private npm
+// registries and PyPI indexes.
+type Provider struct{}
+
+// Verify interface compliance at compile time.
+var (
+	_ provider.CredentialProvider = (*Provider)(nil)
+	_ provider.CredentialChecker  = (*Provider)(nil)
+)
+
+func init() {
+	provider.Register(&Provider{})
+}
+
+// Name returns the provider identifier.
+func (p *Provider) Name() string {
+	return "registry"
+}
+
+// ConfigureProxy injects each registry's Authorization header for its host.
+func (p *Provider) ConfigureProxy(proxy provider.ProxyConfigurer, cred *provider.Credential) {
+	registries, err := UnmarshalRegistries(cred.Token)
+	if err != nil {
+		return
+	}
+	for _, r := range registries {
+		if host := r.Host(); host != "" {
+			proxy.SetCredentialWithGrant(host, "Authorization", r.Authorization(), "registry")
+		}
+	}
+}
+
+// ContainerEnv points npm, pip, and uv at the configuration ContainerMounts
+// writes.
+func (p *Provider) ContainerEnv(cred *provider.Credential) []string {
+	registries, err := UnmarshalRegistries(cred.Token)
+	if err != nil {
+		return nil
+	}
+	return containerEnv(registries)
+}
+
+// ContainerMounts writes the npmrc and pip.conf to a temp directory and
+// mounts it at ContainerConfigDir.
+func (p *Provider) ContainerMounts(cred *provider.Credential, containerHome string) ([]provider.MountConfig, string, error) {
+	registries, err := UnmarshalRegistries(cred.Token)
+	if err != nil {
+		return nil, "", fmt.Errorf("parsing registry credential: %w", err)
+	}
+	if len(registries) == 0 {
+		return nil, "", nil
+	}
+
+	tmpDir, err := os.MkdirTemp("", "moat-registry-*")
+	if err != nil {
+		return nil, "", fmt.Errorf("creating registry config dir: %w", err)
+	}
+	success := false
+	defer func() {
+		if !success {
+			os.RemoveAll(tmpDir)
+		}
+	}()
+
+	files := map[string]string{}
+	if len(byType(registries, TypeNpm)) > 0 {
+		files["npmrc"] = GenerateNpmrc(registries)
+	}
+	if len(byType(registries, TypePyPI)) > 0 {
+		files["pip.conf"] = GeneratePipConf(registries)
+	}
+	for name, content := range files {
+		// The files hold no secrets; they must be readable by the
+		// container user.
+		if err := os.WriteFile(filepath.Join(tmpDir, name), []byte(content), 0o644); err != nil {
+			return nil, "", fmt.Errorf("writing %s: %w", name, err)
+		}
+	}
+	if err := os.Chmod(tmpDir, 0o755); err != nil {
+		return nil, "", fmt.Errorf("setting permissions on registry config dir: %w", err)
+	}
+
+	success = true
+	return []provider.MountConfig{{
+		Source:   tmpDir,
+		Target:   ContainerConfigDir,
+		ReadOnly: true,
+	}}, tmpDir, nil
+}
+
+// Cleanup removes the generated configuration.
+func (p *Provider) Cleanup(cleanupPath string) {
+	if cleanupPath != "" {
+		os.RemoveAll(cleanupPath)
+	}
+}
+
+// ImpliedDependencies returns dependencies implied by this provider.
+func (p *Provider) ImpliedDependencies() []string {
+	return nil
+}
+
+// CheckCredential verifies each registry's token.
+func (p *Provider) CheckCredential(ctx context.Context, cred *provider.Credential) error {
+	registries, err := UnmarshalRegistries(cred.Token)
+	if err != nil {
+		return err
+	}
+	for _, r := range registries {
+		if err := checkRegistry(ctx, r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkRegistry fetches the npm whoami endpoint or the PyPI index root with
+// the registry's credential.
+func checkRegistry(ctx context.Context, r Registry) error {
+	probeURL := r.URL
+	if r.Type == TypeNpm {
+		probeURL += "-/whoami"
+	}
+	req, err := http.NewRequest("GET", probeURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", r.Authorization())
+	req.Header.Set("User-Agent", "moat")
+	probeCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	return util.ProbeCredential(probeCtx, req, http.StatusUnauthorized, http.StatusForbidden)
+}
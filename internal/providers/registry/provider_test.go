@@ -0,0 +1,178 @@
+package registry
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/majorcontext/moat/internal/provider"
+)
+
+type mockProxyConfigurer struct {
+	credentials map[string]string
+}
+
+func (m *mockProxyConfigurer) SetCredential(host, value string)                         {}
+func (m *mockProxyConfigurer) SetCredentialHeader(host, headerName, headerValue string) {}
+func (m *mockProxyConfigurer) SetCredentialWithGrant(host, headerName, headerValue, grant string) {
+	m.credentials[host] = headerName + ": " + headerValue
+}
+func (m *mockProxyConfigurer) AddExtraHeader(host, headerName, headerValue string)                {}
+func (m *mockProxyConfigurer) AddResponseTransformer(host string, t provider.ResponseTransformer) {}
+func (m *mockProxyConfigurer) RemoveRequestHeader(host, header string)                            {}
+func (m *mockProxyConfigurer) SetTokenSubstitution(host, placeholder, realToken string)           {}
+
+func credentialFor(t *testing.T, registries ...Registry) *provider.Credential {
+	t.Helper()
+	token, err := MarshalRegistries(registries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &provider.Credential{Provider: "registry", Token: token}
+}
+
+var (
+	corpNpm   = Registry{Type: TypeNpm, URL: "https://npm.corp.com/", Token: "npm-secret", Scopes: []string{"@corp"}}
+	corpPyPI  = Registry{Type: TypePyPI, URL: "https://pypi.corp.com/simple/", Username: PyPITokenUser, Token: "pypi-secret"}
+	extraPyPI = Registry{Type: TypePyPI, URL: "https://corp.jfrog.io/api/pypi/ml/simple/", Username: "jdoe", Token: "jf", Extra: true}
+)
+
+func TestProvider_ConfigureProxy(t *testing.T) {
+	p := &Provider{}
+	proxy := &mockProxyConfigurer{credentials: map[string]string{}}
+	p.ConfigureProxy(proxy, credentialFor(t, corpNpm, corpPyPI))
+
+	if got := proxy.credentials["npm.corp.com"]; got != "Authorization: Bearer npm-secret" {
+		t.Errorf("npm.corp.com = %q, want the Bearer token", got)
+	}
+	wantBasic := "Authorization: Basic " + base64.StdEncoding.EncodeToString([]byte("__token__:pypi-secret"))
+	if got := proxy.credentials["pypi.corp.com"]; got != wantBasic {
+		t.Errorf("pypi.corp.com = %q, want %q", got, wantBasic)
+	}
+}
+
+func TestGenerateNpmrc(t *testing.T) {
+	def := Registry{Type: TypeNpm, URL: "https://corp.jfrog.io/api/npm/npm/", Token: "t"}
+	got := GenerateNpmrc([]Registry{corpNpm, def, corpPyPI})
+	want := "@corp:registry=https://npm.corp.com/\n" +
+		"registry=https://corp.jfrog.io/api/npm/npm/\n" +
+		"//npm.corp.com/:_authToken=npm_moatProxyInjected00000000\n" +
+		"//corp.jfrog.io/api/npm/npm/:_authToken=npm_moatProxyInjected00000000\n"
+	if got != want {
+		t.Errorf("npmrc =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestGeneratePipConf(t *testing.T) {
+	got := GeneratePipConf([]Registry{corpNpm, extraPyPI, corpPyPI})
+	want := "[global]\n" +
+		"index-url = https://pypi.corp.com/simple/\n" +
+		"extra-index-url =\n" +
+		"    https://corp.jfrog.io/api/pypi/ml/simple/\n"
+	if got != want {
+		t.Errorf("pip.conf =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestProvider_ContainerEnv(t *testing.T) {
+	p := &Provider{}
+	env := p.ContainerEnv(credentialFor(t, corpNpm, corpPyPI, extraPyPI))
+	want := []string{
+		"NPM_CONFIG_GLOBALCONFIG=" + ContainerNpmrc,
+		"PIP_CONFIG_FILE=" + ContainerPipConf,
+		"UV_DEFAULT_INDEX=https://pypi.corp.com/simple/",
+		"UV_INDEX=https://corp.jfrog.io/api/pypi/ml/simple/",
+	}
+	if !slices.Equal(env, want) {
+		t.Errorf("env = %v, want %v", env, want)
+	}
+
+	env = p.ContainerEnv(credentialFor(t, extraPyPI))
+	for _, kv := range env {
+		if strings.HasPrefix(kv, "UV_DEFAULT_INDEX=") {
+			t.Errorf("extra-only grant set %s; pypi.org should stay the default", kv)
+		}
+	}
+}
+
+func TestProvider_ContainerMounts(t *testing.T) {
+	p := &Provider{}
+	mounts, cleanup, err := p.ContainerMounts(credentialFor(t, corpPyPI), "/home/moatuser")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Cleanup(cleanup)
+
+	if len(mounts) != 1 || mounts[0].Target != ContainerConfigDir || !mounts[0].ReadOnly {
+		t.Fatalf("mounts = %+v, want %s read-only", mounts, ContainerConfigDir)
+	}
+	if _, err := os.Stat(filepath.Join(cleanup, "pip.conf")); err != nil {
+		t.Errorf("pip.conf not written: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(cleanup, "npmrc")); !os.IsNotExist(err) {
+		t.Error("npmrc written without npm registries")
+	}
+	data, _ := os.ReadFile(filepath.Join(cleanup, "pip.conf"))
+	if strings.Contains(string(data), "pypi-secret") {
+		t.Error("pip.conf contains the token")
+	}
+}
+
+func TestRegistryFromOptions(t *testing.T) {
+	r, err := registryFromOptions(GrantOptions{URL: "https://pypi.corp.com/simple", Type: TypePyPI})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.URL != "https://pypi.corp.com/simple/" || r.Username != PyPITokenUser {
+		t.Errorf("registry = %+v, want trailing slash and __token__ user", r)
+	}
+
+	r, err = registryFromOptions(GrantOptions{URL: "https://npm.corp.com", Type: TypeNpm, Scopes: []string{"corp", "@tools"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(r.Scopes, []string{"@corp", "@tools"}) {
+		t.Errorf("scopes = %v, want @-prefixed", r.Scopes)
+	}
+
+	for _, opts := range []GrantOptions{
+		{URL: "https://npm.corp.com/"},
+		{URL: "http://npm.corp.com/", Type: TypeNpm},
+		{URL: "https://user:pw@npm.corp.com/", Type: TypeNpm},
+		{URL: "https://npm.corp.com/", Type: TypeNpm, Extra: true},
+		{URL: "https://pypi.corp.com/simple/", Type: TypePyPI, Scopes: []string{"@corp"}},
+		{URL: "https://maven.corp.com/", Type: "maven"},
+	} {
+		if _, err := registryFromOptions(opts); err == nil {
+			t.Errorf("registryFromOptions(%+v) succeeded, want error", opts)
+		}
+	}
+}
+
+func TestProvider_CheckCredential(t *testing.T) {
+	var gotPath, gotAuth string
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath, gotAuth = r.URL.Path, r.Header.Get("Authorization")
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+	orig := http.DefaultClient
+	http.DefaultClient = srv.Client()
+	defer func() { http.DefaultClient = orig }()
+
+	p := &Provider{}
+	err := p.CheckCredential(context.Background(), credentialFor(t, Registry{Type: TypeNpm, URL: srv.URL + "/api/npm/", Token: "revoked"}))
+	if !errors.Is(err, provider.ErrCredentialRejected) {
+		t.Errorf("err = %v, want ErrCredentialRejected", err)
+	}
+	if gotPath != "/api/npm/-/whoami" || gotAuth != "Bearer revoked" {
+		t.Errorf("probe = %s with %q, want npm whoami with the token", gotPath, gotAuth)
+	}
+}
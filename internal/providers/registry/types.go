@@ -0,0 +1,111 @@
+package registry
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Registry types.
+const (
+	TypeNpm  = "npm"
+	TypePyPI = "pypi"
+)
+
+// PyPITokenUser is the username PyPI-compatible indexes expect with an API
+// token.
+const PyPITokenUser = "__token__"
+
+// Token source values stored in Registry.TokenSource.
+const (
+	SourceEnv    = "env"    // From REGISTRY_TOKEN
+	SourceManual = "manual" // Interactive prompt entry
+)
+
+// Registry is one private package registry and its credential.
+type Registry struct {
+	Type string `json:"type"` // TypeNpm or TypePyPI
+	// URL is the registry root for npm or the simple index for PyPI, with
+	// a trailing slash (e.g. "https://pypi.corp.com/simple/").
+	URL string `json:"url"`
+	// Username selects HTTP Basic auth. Empty sends Token as a Bearer
+	// token, which npm registries accept.
+	Username string `json:"username,omitempty"`
+	Token    string `json:"token"`
+	// Scopes lists the npm scopes resolved from this registry. An npm
+	// registry without scopes replaces the default registry.
+	Scopes []string `json:"scopes,omitempty"`
+	// Extra marks a PyPI index searched in addition to the primary index
+	// instead of replacing it.
+	Extra       bool   `json:"extra,omitempty"`
+	TokenSource string `json:"token_source,omitempty"`
+}
+
+// Host returns the registry's host.
+func (r Registry) Host() string {
+	u, err := url.Parse(r.URL)
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
+
+// Authorization returns the Authorization header value for the registry.
+func (r Registry) Authorization() string {
+	if r.Username != "" {
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(r.Username+":"+r.Token))
+	}
+	return "Bearer " + r.Token
+}
+
+// NormalizeURL validates a registry URL and returns it with a trailing
+// slash. Only https URLs are accepted: the proxy injects credentials into
+// TLS traffic only.
+func NormalizeURL(raw string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return "", fmt.Errorf("invalid registry URL %q: %w", raw, err)
+	}
+	if u.Scheme != "https" || u.Host == "" {
+		return "", fmt.Errorf("registry URL %q must be an https:// URL", raw)
+	}
+	if u.User != nil {
+		return "", fmt.Errorf("registry URL %q must not contain credentials", raw)
+	}
+	u.RawQuery, u.Fragment = "", ""
+	if !strings.HasSuffix(u.Path, "/") {
+		u.Path += "/"
+	}
+	return u.String(), nil
+}
+
+// MarshalRegistries encodes registries as JSON for storage in the Token field.
+func MarshalRegistries(registries []Registry) (string, error) {
+	data, err := json.Marshal(registries)
+	if err != nil {
+		return "", fmt.Errorf("marshaling registries: %w", err)
+	}
+	return string(data), nil
+}
+
+// UnmarshalRegistries decodes registries from the JSON-encoded Token field.
+func UnmarshalRegistries(token string) ([]Registry, error) {
+	var registries []Registry
+	if err := json.Unmarshal([]byte(token), &registries); err != nil {
+		return nil, fmt.Errorf("unmarshaling registries: %w", err)
+	}
+	return registries, nil
+}
+
+// MergeRegistry merges r into registries, replacing any with the same URL.
+func MergeRegistry(registries []Registry, r Registry) []Registry {
+	for i, e := range registries {
+		if e.URL == r.URL {
+			registries[i] = r
+			return registries
+		}
+	}
+	return append(registries, r)
+}
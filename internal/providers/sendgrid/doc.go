@@ -0,0 +1,14 @@
+// Package sendgrid implements the SendGrid credential provider.
+//
+// The provider stores a SendGrid API key (SG.). Keys can be obtained from:
+//   - Environment variable (SENDGRID_API_KEY)
+//   - Interactive prompt
+//
+// The proxy injects the key as a Bearer token for api.sendgrid.com.
+// Containers receive a placeholder SENDGRID_API_KEY that keeps the SG.
+// prefix some SDKs check for.
+//
+// Sending email reaches real inboxes, so the proxy counts POSTs to
+// /v3/mail/send against the run's messaging.max_messages cap and records
+// each in the audit log.
+package sendgrid
@@ -0,0 +1,118 @@
+package sendgrid
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/majorcontext/moat/internal/provider"
+	"github.com/majorcontext/moat/internal/provider/util"
+)
+
+// Grant acquires a SendGrid API key from the environment or an interactive
+// prompt.
+//
+// Key acquisition order:
+//  1. SENDGRID_API_KEY environment variable
+//  2. Interactive prompt
+func (p *Provider) Grant(ctx context.Context) (*provider.Credential, error) {
+	if key := util.CheckEnvVars("SENDGRID_API_KEY"); key != "" {
+		fmt.Println("Using key from SENDGRID_API_KEY environment variable")
+		return p.validateAndCreateCredential(ctx, key, SourceEnv)
+	}
+
+	if err := util.RequireInput(ctx, "set SENDGRID_API_KEY"); err != nil {
+		return nil, err
+	}
+	fmt.Println(`Enter a SendGrid API key.
+
+To create a key:
+  1. Visit https://app.sendgrid.com/settings/api_keys
+  2. Create a key with Restricted Access and only the permissions the
+     agent needs (e.g. Mail Send)
+  3. Paste it below`)
+
+	key, err := util.PromptForToken(ctx, "API key")
+	if err != nil {
+		return nil, fmt.Errorf("reading API key: %w", err)
+	}
+	if key == "" {
+		return nil, &provider.GrantError{
+			Provider: "sendgrid",
+			Cause:    fmt.Errorf("no API key provided"),
+			Hint:     "Run 'moat grant sendgrid' and enter a valid SendGrid API key",
+		}
+	}
+	return p.validateAndCreateCredential(ctx, key, SourceManual)
+}
+
+// validateAndCreateCredential checks the key's format, validates it against
+// the API, and creates a credential.
+func (p *Provider) validateAndCreateCredential(ctx context.Context, key, source string) (*provider.Credential, error) {
+	if err := util.ValidateTokenPrefix(key, "SG.", "API key"); err != nil {
+		return nil, &provider.GrantError{
+			Provider: "sendgrid",
+			Cause:    err,
+			Hint:     "Create a key at https://app.sendgrid.com/settings/api_keys",
+		}
+	}
+
+	fmt.Println("Validating key...")
+	scopes, err := fetchScopes(ctx, key)
+	if err != nil {
+		return nil, &provider.GrantError{
+			Provider: "sendgrid",
+			Cause:    err,
+			Hint:     "Check the key in the SendGrid dashboard; deleted keys stop working",
+		}
+	}
+	if slices.Contains(scopes, "mail.send") {
+		fmt.Println("Key validated (can send mail)")
+	} else {
+		fmt.Println("Key validated (no mail.send scope; the agent cannot send email)")
+	}
+
+	return &provider.Credential{
+		Provider:  "sendgrid",
+		Token:     key,
+		Scopes:    scopes,
+		CreatedAt: time.Now(),
+		Metadata:  map[string]string{provider.MetaKeyTokenSource: source},
+	}, nil
+}
+
+// fetchScopes returns the key's permission scopes.
+func fetchScopes(ctx context.Context, key string) ([]string, error) {
+	req, err := scopesRequest(key)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("contacting SendGrid: %w", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 256*1024))
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return nil, fmt.Errorf("invalid API key (%d %s)", resp.StatusCode, http.StatusText(resp.StatusCode))
+	default:
+		return nil, fmt.Errorf("unexpected status validating API key: %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Scopes []string `json:"scopes"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("parsing scopes response: %w", err)
+	}
+	return result.Scopes, nil
+}
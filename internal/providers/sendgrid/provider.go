@@ -0,0 +1,98 @@
+package sendgrid
+
+import (
+	"context"
+	"net/http"
+
+	gkproxy "github.com/majorcontext/gatekeeper/proxy"
+	"github.com/majorcontext/moat/internal/credential"
+	"github.com/majorcontext/moat/internal/provider"
+	"github.com/majorcontext/moat/internal/provider/util"
+)
+
+// Token source values stored in Credential.Metadata[provider.MetaKeyTokenSource].
+const (
+	SourceEnv    = "env"    // From SENDGRID_API_KEY env var
+	SourceManual = "manual" // Interactive prompt entry
+)
+
+// Host is the SendGrid API host the key is injected for.
+const Host = "api.sendgrid.com"
+
+// scopesURL is the endpoint CheckCredential and Grant probe. Every API key
+// may read its own scopes. A variable so tests can point it at a local
+// server.
+var scopesURL = "https://" + Host + "/v3/scopes"
+
+// Provider implements provider.CredentialProvider for SendGrid.
+type Provider struct{}
+
+// Verify interface compliance at compile time.
+var (
+	_ provider.CredentialProvider = (*Provider)(nil)
+	_ provider.CredentialChecker  = (*Provider)(nil)
+	_ provider.SendingProvider    = (*Provider)(nil)
+)
+
+func init() {
+	provider.Register(&Provider{})
+	// Declare the hosts the grant is used with, for grant scopes and
+	// network suggestions.
+	gkproxy.RegisterGrantHosts("sendgrid", []string{Host})
+}
+
+// Name returns the provider identifier.
+func (p *Provider) Name() string {
+	return "sendgrid"
+}
+
+// ConfigureProxy injects the key for api.sendgrid.com.
+func (p *Provider) ConfigureProxy(proxy provider.ProxyConfigurer, cred *provider.Credential) {
+	proxy.SetCredentialWithGrant(Host, "Authorization", "Bearer "+cred.Token, "sendgrid")
+}
+
+// ContainerEnv sets a placeholder SENDGRID_API_KEY.
+// The real key is injected by the proxy at the network layer.
+func (p *Provider) ContainerEnv(cred *provider.Credential) []string {
+	return []string{"SENDGRID_API_KEY=SG." + credential.ProxyInjectedPlaceholder}
+}
+
+// ContainerMounts returns no mounts.
+func (p *Provider) ContainerMounts(cred *provider.Credential, containerHome string) ([]provider.MountConfig, string, error) {
+	return nil, "", nil
+}
+
+// Cleanup is a no-op.
+func (p *Provider) Cleanup(cleanupPath string) {}
+
+// ImpliedDependencies returns dependencies implied by this provider.
+func (p *Provider) ImpliedDependencies() []string {
+	return nil
+}
+
+// SendRoutes returns the request that sends email.
+func (p *Provider) SendRoutes() []provider.SendRoute {
+	return []provider.SendRoute{
+		{Host: Host, Method: "POST", Path: "/v3/mail/send"},
+	}
+}
+
+// CheckCredential verifies the key by listing its scopes.
+func (p *Provider) CheckCredential(ctx context.Context, cred *provider.Credential) error {
+	req, err := scopesRequest(cred.Token)
+	if err != nil {
+		return err
+	}
+	return util.ProbeCredential(ctx, req, http.StatusUnauthorized, http.StatusForbidden)
+}
+
+// scopesRequest builds a GET for the scopes of key.
+func scopesRequest(key string) (*http.Request, error) {
+	req, err := http.NewRequest("GET", scopesURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+key)
+	req.Header.Set("User-Agent", "moat")
+	return req, nil
+}
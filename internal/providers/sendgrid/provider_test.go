@@ -0,0 +1,76 @@
+package sendgrid
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/majorcontext/moat/internal/provider"
+)
+
+type mockProxyConfigurer struct {
+	credentials map[string]string
+}
+
+func (m *mockProxyConfigurer) SetCredential(host, value string)                         {}
+func (m *mockProxyConfigurer) SetCredentialHeader(host, headerName, headerValue string) {}
+func (m *mockProxyConfigurer) SetCredentialWithGrant(host, headerName, headerValue, grant string) {
+	m.credentials[host] = headerName + ": " + headerValue
+}
+func (m *mockProxyConfigurer) AddExtraHeader(host, headerName, headerValue string)                {}
+func (m *mockProxyConfigurer) AddResponseTransformer(host string, t provider.ResponseTransformer) {}
+func (m *mockProxyConfigurer) RemoveRequestHeader(host, header string)                            {}
+func (m *mockProxyConfigurer) SetTokenSubstitution(host, placeholder, realToken string)           {}
+
+func TestProvider_ConfigureProxy(t *testing.T) {
+	p := &Provider{}
+	proxy := &mockProxyConfigurer{credentials: map[string]string{}}
+	p.ConfigureProxy(proxy, &provider.Credential{Token: "SG.abc"})
+
+	if got := proxy.credentials[Host]; got != "Authorization: Bearer SG.abc" {
+		t.Errorf("credential = %q", got)
+	}
+}
+
+func TestProvider_ContainerEnv(t *testing.T) {
+	env := (&Provider{}).ContainerEnv(&provider.Credential{Token: "SG.abc"})
+	if want := []string{"SENDGRID_API_KEY=SG.moat-proxy-injected"}; !slices.Equal(env, want) {
+		t.Errorf("ContainerEnv = %v, want %v", env, want)
+	}
+}
+
+func TestValidateAndCreateCredential(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Header.Get("Authorization") {
+		case "Bearer SG.good":
+			w.Write([]byte(`{"scopes":["mail.send","user.profile.read"]}`))
+		case "Bearer SG.forbidden":
+			w.WriteHeader(http.StatusForbidden)
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer srv.Close()
+	orig := scopesURL
+	scopesURL = srv.URL
+	defer func() { scopesURL = orig }()
+
+	p := &Provider{}
+	ctx := context.Background()
+
+	cred, err := p.validateAndCreateCredential(ctx, "SG.good", SourceEnv)
+	if err != nil {
+		t.Fatalf("valid key: %v", err)
+	}
+	if !slices.Contains(cred.Scopes, "mail.send") || cred.Metadata[provider.MetaKeyTokenSource] != SourceEnv {
+		t.Errorf("credential = %+v", cred)
+	}
+
+	for _, key := range []string{"SG.bad", "SG.forbidden", "not-a-key"} {
+		if _, err := p.validateAndCreateCredential(ctx, key, SourceEnv); err == nil {
+			t.Errorf("key %q accepted", key)
+		}
+	}
+}
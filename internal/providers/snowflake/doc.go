@@ -0,0 +1,14 @@
+// Package snowflake implements the Snowflake credential provider.
+//
+// The provider stores an RSA private key registered with a Snowflake user
+// (key-pair authentication) along with the account and user names. The key
+// is read from a PEM file given with --private-key or
+// SNOWFLAKE_PRIVATE_KEY_PATH at grant time.
+//
+// The proxy signs a short-lived JWT with the key and injects it as a Bearer
+// token, with X-Snowflake-Authorization-Token-Type: KEYPAIR_JWT, for the
+// account's <account>.snowflakecomputing.com host, which serves the SQL API
+// (/api/v2/statements) and the REST API. JWTs are valid for under an hour and
+// are re-signed on every refresh, so the container never sees the key or a
+// token that outlives the run by more than that.
+package snowflake
@@ -0,0 +1,158 @@
+package snowflake
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/majorcontext/moat/internal/provider"
+	"github.com/majorcontext/moat/internal/provider/util"
+)
+
+// GrantOptions carries the grant flags.
+type GrantOptions struct {
+	Account        string // --account
+	User           string // --user
+	PrivateKeyPath string // --private-key
+}
+
+// ctxKeyOptions is the context key for GrantOptions.
+type ctxKeyOptions struct{}
+
+// WithGrantOptions returns a context carrying the grant flags.
+func WithGrantOptions(ctx context.Context, opts GrantOptions) context.Context {
+	return context.WithValue(ctx, ctxKeyOptions{}, opts)
+}
+
+// apiBaseURL returns the API base URL for account. A variable so tests can
+// point it at a local server.
+var apiBaseURL = func(account string) string {
+	return "https://" + Host(account)
+}
+
+// accountPattern matches account identifiers in organization-account
+// ("myorg-myaccount") and account locator ("xy12345.us-east-2.aws") forms.
+var accountPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// ParseAccount accepts an account identifier or account URL
+// ("https://myorg-myaccount.snowflakecomputing.com") and returns the
+// identifier.
+func ParseAccount(s string) (string, error) {
+	account := strings.TrimSpace(s)
+	if strings.Contains(account, "://") {
+		u, err := url.Parse(account)
+		if err != nil {
+			return "", fmt.Errorf("invalid account URL %q: %w", s, err)
+		}
+		account = strings.TrimSuffix(u.Hostname(), ".snowflakecomputing.com")
+	}
+	account = strings.TrimSuffix(account, ".snowflakecomputing.com")
+	if !accountPattern.MatchString(account) {
+		return "", fmt.Errorf("invalid account identifier %q", s)
+	}
+	return account, nil
+}
+
+// Grant reads the account, user, and private key from flags or the
+// SNOWFLAKE_ACCOUNT, SNOWFLAKE_USER, and SNOWFLAKE_PRIVATE_KEY_PATH
+// environment variables, and validates them with a SQL API query.
+func (p *Provider) Grant(ctx context.Context) (*provider.Credential, error) {
+	opts, _ := ctx.Value(ctxKeyOptions{}).(GrantOptions)
+	if opts.Account == "" {
+		opts.Account = os.Getenv("SNOWFLAKE_ACCOUNT")
+	}
+	if opts.User == "" {
+		opts.User = os.Getenv("SNOWFLAKE_USER")
+	}
+	if opts.PrivateKeyPath == "" {
+		opts.PrivateKeyPath = os.Getenv("SNOWFLAKE_PRIVATE_KEY_PATH")
+	}
+	if opts.Account == "" || opts.User == "" || opts.PrivateKeyPath == "" {
+		return nil, &provider.GrantError{
+			Provider: "snowflake",
+			Cause:    fmt.Errorf("account, user, and private key are required"),
+			Hint: "Run 'moat grant snowflake --account <account> --user <user> --private-key <path>'\n" +
+				"or set SNOWFLAKE_ACCOUNT, SNOWFLAKE_USER, and SNOWFLAKE_PRIVATE_KEY_PATH",
+		}
+	}
+	account, err := ParseAccount(opts.Account)
+	if err != nil {
+		return nil, &provider.GrantError{Provider: "snowflake", Cause: err}
+	}
+
+	keyPath := opts.PrivateKeyPath
+	if rest, ok := strings.CutPrefix(keyPath, "~/"); ok {
+		if home, err := os.UserHomeDir(); err == nil {
+			keyPath = filepath.Join(home, rest)
+		}
+	}
+	keyPEM, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, &provider.GrantError{Provider: "snowflake", Cause: fmt.Errorf("reading private key: %w", err)}
+	}
+	key, err := util.ParseRSAPrivateKey(keyPEM)
+	if err != nil {
+		return nil, &provider.GrantError{
+			Provider: "snowflake",
+			Cause:    fmt.Errorf("reading private key %s: %w", keyPath, err),
+			Hint: "Provide an unencrypted PKCS#8 RSA key, e.g.:\n" +
+				"  openssl pkcs8 -in rsa_key.p8 -out rsa_key_plain.p8 -nocrypt",
+		}
+	}
+	fingerprint, err := Fingerprint(&key.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	cred := &provider.Credential{
+		Provider: "snowflake",
+		Token:    string(keyPEM),
+		Metadata: map[string]string{
+			MetaKeyAccount:     account,
+			MetaKeyUser:        opts.User,
+			MetaKeyFingerprint: fingerprint,
+		},
+		CreatedAt: time.Now(),
+	}
+
+	fmt.Printf("Validating key %s for %s on %s...\n", fingerprint, opts.User, account)
+	if err := validate(ctx, cred); err != nil {
+		return nil, &provider.GrantError{
+			Provider: "snowflake",
+			Cause:    fmt.Errorf("validation failed: %w", err),
+			Hint: "Check that the account identifier is correct and that the public key is set on the user:\n" +
+				"  ALTER USER <user> SET RSA_PUBLIC_KEY='<public key>';\n" +
+				"DESC USER <user> shows the registered key's fingerprint as RSA_PUBLIC_KEY_FP.",
+		}
+	}
+	fmt.Printf("Authenticated to %s as %s\n", account, opts.User)
+	return cred, nil
+}
+
+// validate runs a metadata-only statement through the SQL API. It needs no
+// warehouse.
+func validate(ctx context.Context, cred *provider.Credential) error {
+	token, err := signToken(cred, time.Now())
+	if err != nil {
+		return err
+	}
+	body := strings.NewReader(`{"statement":"SELECT CURRENT_USER()","timeout":30}`)
+	req, err := http.NewRequest("POST", apiBaseURL(cred.Metadata[MetaKeyAccount])+"/api/v2/statements", body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("X-Snowflake-Authorization-Token-Type", "KEYPAIR_JWT")
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", "moat")
+	probeCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	return util.ProbeCredential(probeCtx, req, http.StatusUnauthorized, http.StatusForbidden)
+}
@@ -0,0 +1,156 @@
+package snowflake
+
+import (
+	"context"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/majorcontext/moat/internal/provider"
+	"github.com/majorcontext/moat/internal/provider/util"
+)
+
+// Metadata keys stored on the credential.
+const (
+	MetaKeyAccount     = "account"                // account identifier, e.g. "myorg-myaccount"
+	MetaKeyUser        = "user"                   // Snowflake user the key is registered to
+	MetaKeyFingerprint = "public_key_fingerprint" // "SHA256:<base64>", as shown by DESC USER
+)
+
+// jwtLifetime is how long each signed JWT is valid. Snowflake rejects
+// tokens that expire more than an hour after they were issued.
+const jwtLifetime = 59 * time.Minute
+
+// Provider implements provider.CredentialProvider for Snowflake.
+type Provider struct{}
+
+// Verify interface compliance at compile time.
+var (
+	_ provider.CredentialProvider  = (*Provider)(nil)
+	_ provider.RefreshableProvider = (*Provider)(nil)
+)
+
+func init() {
+	provider.Register(&Provider{})
+}
+
+// Name returns the provider identifier.
+func (p *Provider) Name() string {
+	return "snowflake"
+}
+
+// Host returns the API host for account. Snowflake URLs use hyphens where
+// account names have underscores.
+func Host(account string) string {
+	return strings.ToLower(strings.ReplaceAll(account, "_", "-")) + ".snowflakecomputing.com"
+}
+
+// jwtAccount returns the account part of the JWT issuer and subject: upper
+// case, without the region and cloud suffix of a legacy account locator
+// ("xy12345.us-east-2.aws" → "XY12345").
+func jwtAccount(account string) string {
+	name, _, _ := strings.Cut(account, ".")
+	return strings.ToUpper(name)
+}
+
+// Fingerprint returns the public key fingerprint Snowflake records for a
+// user's RSA_PUBLIC_KEY.
+func Fingerprint(pub *rsa.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(der)
+	return "SHA256:" + base64.StdEncoding.EncodeToString(sum[:]), nil
+}
+
+// signToken returns a key-pair JWT for the account and user on cred.
+func signToken(cred *provider.Credential, now time.Time) (string, error) {
+	key, err := util.ParseRSAPrivateKey([]byte(cred.Token))
+	if err != nil {
+		return "", fmt.Errorf("parsing private key: %w", err)
+	}
+	fp, err := Fingerprint(&key.PublicKey)
+	if err != nil {
+		return "", err
+	}
+	subject := jwtAccount(cred.Metadata[MetaKeyAccount]) + "." + strings.ToUpper(cred.Metadata[MetaKeyUser])
+	return util.SignJWT(key, map[string]any{
+		"iss": subject + "." + fp,
+		"sub": subject,
+		"iat": now.Unix(),
+		"exp": now.Add(jwtLifetime).Unix(),
+	})
+}
+
+// setToken signs a fresh JWT and injects it for the account's host.
+func setToken(proxy provider.ProxyConfigurer, cred *provider.Credential) error {
+	token, err := signToken(cred, time.Now())
+	if err != nil {
+		return err
+	}
+	proxy.SetCredentialWithGrant(Host(cred.Metadata[MetaKeyAccount]), "Authorization", "Bearer "+token, "snowflake")
+	return nil
+}
+
+// ConfigureProxy injects a key-pair JWT for the account's API host.
+func (p *Provider) ConfigureProxy(proxy provider.ProxyConfigurer, cred *provider.Credential) {
+	if err := setToken(proxy, cred); err != nil {
+		return
+	}
+	proxy.AddExtraHeader(Host(cred.Metadata[MetaKeyAccount]), "X-Snowflake-Authorization-Token-Type", "KEYPAIR_JWT")
+}
+
+// CanRefresh reports whether cred holds a key to sign JWTs with.
+func (p *Provider) CanRefresh(cred *provider.Credential) bool {
+	return cred.Token != "" && cred.Metadata[MetaKeyAccount] != ""
+}
+
+// RefreshInterval returns how often to re-sign the JWT, well inside its
+// lifetime.
+func (p *Provider) RefreshInterval() time.Duration {
+	return 30 * time.Minute
+}
+
+// Refresh signs a new JWT and updates the proxy. The stored credential (the
+// key) does not change.
+func (p *Provider) Refresh(ctx context.Context, proxy provider.ProxyConfigurer, cred *provider.Credential) (*provider.Credential, error) {
+	if !p.CanRefresh(cred) {
+		return nil, provider.ErrRefreshNotSupported
+	}
+	if err := setToken(proxy, cred); err != nil {
+		return nil, err
+	}
+	return cred, nil
+}
+
+// ContainerEnv identifies the account and user for tools in the container.
+// No secret is exposed; the proxy authenticates requests to the account host.
+func (p *Provider) ContainerEnv(cred *provider.Credential) []string {
+	account := cred.Metadata[MetaKeyAccount]
+	if account == "" {
+		return nil
+	}
+	return []string{
+		"SNOWFLAKE_ACCOUNT=" + account,
+		"SNOWFLAKE_USER=" + cred.Metadata[MetaKeyUser],
+		"SNOWFLAKE_HOST=" + Host(account),
+	}
+}
+
+// ContainerMounts returns no mounts.
+func (p *Provider) ContainerMounts(cred *provider.Credential, containerHome string) ([]provider.MountConfig, string, error) {
+	return nil, "", nil
+}
+
+// Cleanup is a no-op.
+func (p *Provider) Cleanup(cleanupPath string) {}
+
+// ImpliedDependencies returns dependencies implied by this provider.
+func (p *Provider) ImpliedDependencies() []string {
+	return nil
+}
@@ -0,0 +1,226 @@
+package snowflake
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/majorcontext/moat/internal/provider"
+)
+
+type mockProxyConfigurer struct {
+	credentials  map[string]string
+	extraHeaders map[string]string
+}
+
+func (m *mockProxyConfigurer) SetCredential(host, value string)                         {}
+func (m *mockProxyConfigurer) SetCredentialHeader(host, headerName, headerValue string) {}
+func (m *mockProxyConfigurer) SetCredentialWithGrant(host, headerName, headerValue, grant string) {
+	m.credentials[host] = headerName + ": " + headerValue
+}
+func (m *mockProxyConfigurer) AddExtraHeader(host, headerName, headerValue string) {
+	m.extraHeaders[host] = headerName + ": " + headerValue
+}
+func (m *mockProxyConfigurer) AddResponseTransformer(host string, t provider.ResponseTransformer) {}
+func (m *mockProxyConfigurer) RemoveRequestHeader(host, header string)                            {}
+func (m *mockProxyConfigurer) SetTokenSubstitution(host, placeholder, realToken string)           {}
+
+func testKey(t *testing.T) (*rsa.PrivateKey, string) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key, string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+}
+
+// jwtClaims decodes the claims of a "Bearer <jwt>" header value.
+func jwtClaims(t *testing.T, header string) map[string]any {
+	t.Helper()
+	parts := strings.Split(strings.TrimPrefix(header, "Bearer "), ".")
+	if len(parts) != 3 {
+		t.Fatalf("not a JWT: %q", header)
+	}
+	data, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var claims map[string]any
+	if err := json.Unmarshal(data, &claims); err != nil {
+		t.Fatal(err)
+	}
+	return claims
+}
+
+func TestParseAccount(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{in: "myorg-myaccount", want: "myorg-myaccount"},
+		{in: "xy12345.us-east-2.aws", want: "xy12345.us-east-2.aws"},
+		{in: "https://myorg-myaccount.snowflakecomputing.com", want: "myorg-myaccount"},
+		{in: "myorg-myaccount.snowflakecomputing.com", want: "myorg-myaccount"},
+		{in: "bad/account", wantErr: true},
+		{in: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseAccount(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseAccount(%q) err = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseAccount(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestHost(t *testing.T) {
+	if got := Host("MyOrg-My_Account"); got != "myorg-my-account.snowflakecomputing.com" {
+		t.Errorf("Host = %q", got)
+	}
+	if got := Host("xy12345.us-east-2.aws"); got != "xy12345.us-east-2.aws.snowflakecomputing.com" {
+		t.Errorf("Host = %q", got)
+	}
+}
+
+func TestSignToken(t *testing.T) {
+	key, keyPEM := testKey(t)
+	fp, err := Fingerprint(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cred := &provider.Credential{
+		Token:    keyPEM,
+		Metadata: map[string]string{MetaKeyAccount: "xy12345.us-east-2.aws", MetaKeyUser: "agent_user"},
+	}
+	now := time.Unix(1700000000, 0)
+	token, err := signToken(cred, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims := jwtClaims(t, token)
+	if claims["sub"] != "XY12345.AGENT_USER" {
+		t.Errorf("sub = %v", claims["sub"])
+	}
+	if claims["iss"] != "XY12345.AGENT_USER."+fp {
+		t.Errorf("iss = %v, want fingerprint %s", claims["iss"], fp)
+	}
+	if exp := claims["exp"].(float64); int64(exp) != now.Add(jwtLifetime).Unix() {
+		t.Errorf("exp = %v", exp)
+	}
+}
+
+func TestProvider_ConfigureProxyAndRefresh(t *testing.T) {
+	_, keyPEM := testKey(t)
+	cred := &provider.Credential{
+		Token:    keyPEM,
+		Metadata: map[string]string{MetaKeyAccount: "myorg-myaccount", MetaKeyUser: "agent"},
+	}
+	p := &Provider{}
+	proxy := &mockProxyConfigurer{credentials: map[string]string{}, extraHeaders: map[string]string{}}
+	p.ConfigureProxy(proxy, cred)
+
+	host := "myorg-myaccount.snowflakecomputing.com"
+	if !strings.HasPrefix(proxy.credentials[host], "Authorization: Bearer ") {
+		t.Fatalf("credential for %s = %q", host, proxy.credentials[host])
+	}
+	if proxy.extraHeaders[host] != "X-Snowflake-Authorization-Token-Type: KEYPAIR_JWT" {
+		t.Errorf("extra header = %q", proxy.extraHeaders[host])
+	}
+
+	delete(proxy.credentials, host)
+	updated, err := p.Refresh(context.Background(), proxy, cred)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Token != cred.Token {
+		t.Error("Refresh changed the stored key")
+	}
+	if proxy.credentials[host] == "" {
+		t.Error("Refresh did not update the proxy")
+	}
+}
+
+func TestProvider_ContainerEnv(t *testing.T) {
+	cred := &provider.Credential{Metadata: map[string]string{MetaKeyAccount: "myorg-myaccount", MetaKeyUser: "agent"}}
+	env := strings.Join((&Provider{}).ContainerEnv(cred), "\n")
+	for _, want := range []string{"SNOWFLAKE_ACCOUNT=myorg-myaccount", "SNOWFLAKE_USER=agent", "SNOWFLAKE_HOST=myorg-myaccount.snowflakecomputing.com"} {
+		if !strings.Contains(env, want) {
+			t.Errorf("ContainerEnv missing %s:\n%s", want, env)
+		}
+	}
+	for _, e := range (&Provider{}).ContainerEnv(cred) {
+		if strings.Contains(e, "PRIVATE KEY") {
+			t.Errorf("ContainerEnv leaks the key: %s", e)
+		}
+	}
+}
+
+func TestGrant(t *testing.T) {
+	_, keyPEM := testKey(t)
+	keyPath := filepath.Join(t.TempDir(), "rsa_key.p8")
+	if err := os.WriteFile(keyPath, []byte(keyPEM), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var status int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v2/statements" || r.Header.Get("X-Snowflake-Authorization-Token-Type") != "KEYPAIR_JWT" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+	orig := apiBaseURL
+	apiBaseURL = func(string) string { return srv.URL }
+	defer func() { apiBaseURL = orig }()
+
+	ctx := WithGrantOptions(context.Background(), GrantOptions{
+		Account:        "https://myorg-myaccount.snowflakecomputing.com",
+		User:           "agent",
+		PrivateKeyPath: keyPath,
+	})
+
+	status = http.StatusOK
+	cred, err := (&Provider{}).Grant(ctx)
+	if err != nil {
+		t.Fatalf("Grant: %v", err)
+	}
+	if cred.Metadata[MetaKeyAccount] != "myorg-myaccount" || cred.Token != keyPEM {
+		t.Errorf("credential = %+v", cred.Metadata)
+	}
+	if !strings.HasPrefix(cred.Metadata[MetaKeyFingerprint], "SHA256:") {
+		t.Errorf("fingerprint = %q", cred.Metadata[MetaKeyFingerprint])
+	}
+
+	status = http.StatusUnauthorized
+	_, err = (&Provider{}).Grant(ctx)
+	if !errors.Is(err, provider.ErrCredentialRejected) {
+		t.Errorf("Grant with rejected key: err = %v, want ErrCredentialRejected", err)
+	}
+}
+
+func TestProvider_Registered(t *testing.T) {
+	if provider.Get("snowflake") == nil {
+		t.Fatal("snowflake provider not registered")
+	}
+}
@@ -0,0 +1,17 @@
+// Package stripe implements the Stripe credential provider.
+//
+// The provider stores a Stripe secret key (sk_) or restricted key (rk_).
+// Keys can be obtained from:
+//   - Environment variables (STRIPE_API_KEY, STRIPE_SECRET_KEY)
+//   - Interactive prompt
+//
+// The proxy injects the key as a Bearer token for api.stripe.com and
+// files.stripe.com. Containers receive a placeholder STRIPE_API_KEY that
+// keeps the key's mode prefix (sk_test_, rk_live_, ...), so SDKs and the
+// Stripe CLI see which mode they are in without holding the key.
+//
+// A grant made with --test-mode-only refuses live-mode keys when it is
+// saved, and the proxy daemon refuses, before forwarding, any request that
+// would carry a live key (see GuardLiveMode), so a run cannot act on live
+// data even if a live key reaches the store by other means.
+package stripe
@@ -0,0 +1,135 @@
+package stripe
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/majorcontext/moat/internal/provider"
+	"github.com/majorcontext/moat/internal/provider/util"
+)
+
+// ctxKeyTestModeOnly is the context key for the --test-mode-only grant flag.
+type ctxKeyTestModeOnly struct{}
+
+// WithGrantOptions returns a context carrying the --test-mode-only grant flag.
+func WithGrantOptions(ctx context.Context, testModeOnly bool) context.Context {
+	return context.WithValue(ctx, ctxKeyTestModeOnly{}, testModeOnly)
+}
+
+// Grant acquires a Stripe key from the environment or an interactive prompt.
+//
+// Key acquisition order:
+//  1. STRIPE_API_KEY or STRIPE_SECRET_KEY environment variable
+//  2. Interactive prompt
+func (p *Provider) Grant(ctx context.Context) (*provider.Credential, error) {
+	testOnly, _ := ctx.Value(ctxKeyTestModeOnly{}).(bool)
+
+	if key, name := util.CheckEnvVarWithName("STRIPE_API_KEY", "STRIPE_SECRET_KEY"); key != "" {
+		fmt.Printf("Using key from %s environment variable\n", name)
+		return p.validateAndCreateCredential(ctx, key, SourceEnv, testOnly)
+	}
+
+	if err := util.RequireInput(ctx, "set STRIPE_API_KEY or STRIPE_SECRET_KEY"); err != nil {
+		return nil, err
+	}
+	dashboard := "https://dashboard.stripe.com/apikeys"
+	if testOnly {
+		dashboard = "https://dashboard.stripe.com/test/apikeys"
+	}
+	fmt.Printf(`Enter a Stripe secret key (sk_...) or restricted key (rk_...).
+
+To get a key:
+  1. Visit %s
+  2. Create a restricted key with only the permissions the agent needs,
+     or reveal the secret key
+  3. Paste it below
+`, dashboard)
+
+	key, err := util.PromptForToken(ctx, "Key")
+	if err != nil {
+		return nil, fmt.Errorf("reading key: %w", err)
+	}
+	if key == "" {
+		return nil, &provider.GrantError{
+			Provider: "stripe",
+			Cause:    fmt.Errorf("no key provided"),
+			Hint:     "Run 'moat grant stripe' and enter a valid Stripe key",
+		}
+	}
+	return p.validateAndCreateCredential(ctx, key, SourceManual, testOnly)
+}
+
+// validateAndCreateCredential checks the key's format and mode, validates it
+// against the API, and creates a credential.
+func (p *Provider) validateAndCreateCredential(ctx context.Context, key, source string, testOnly bool) (*provider.Credential, error) {
+	if keyPrefix(key) == "" {
+		return nil, &provider.GrantError{
+			Provider: "stripe",
+			Cause:    fmt.Errorf("not a Stripe secret or restricted key"),
+			Hint:     "Use a key starting with sk_ or rk_; publishable keys (pk_) cannot call the API",
+		}
+	}
+	cred := &provider.Credential{
+		Provider:  "stripe",
+		Token:     key,
+		CreatedAt: time.Now(),
+		Metadata:  map[string]string{provider.MetaKeyTokenSource: source},
+	}
+	if testOnly {
+		cred.Metadata[MetaKeyTestModeOnly] = "true"
+	}
+	if err := CheckKey(cred); err != nil {
+		return nil, err
+	}
+
+	fmt.Println("Validating key...")
+	if err := validateKey(ctx, key); err != nil {
+		return nil, &provider.GrantError{
+			Provider: "stripe",
+			Cause:    err,
+			Hint:     "Check the key in the Stripe dashboard; rolled keys stop working",
+		}
+	}
+
+	mode := "live"
+	if IsTestKey(key) {
+		mode = "test"
+	}
+	if testOnly {
+		fmt.Println("Key validated (test mode only; live-mode requests will be blocked)")
+	} else {
+		fmt.Printf("Key validated (%s mode)\n", mode)
+	}
+	return cred, nil
+}
+
+// validateKey reads the account balance with key. A restricted key without
+// balance access gets 403, which still proves the key authenticates.
+func validateKey(ctx context.Context, key string) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", balanceURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+key)
+	req.Header.Set("User-Agent", "moat")
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("contacting Stripe: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300, resp.StatusCode == http.StatusForbidden:
+		return nil
+	case resp.StatusCode == http.StatusUnauthorized:
+		return fmt.Errorf("invalid key (401 Unauthorized)")
+	default:
+		return fmt.Errorf("unexpected status validating key: %d", resp.StatusCode)
+	}
+}
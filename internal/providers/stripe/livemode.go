@@ -0,0 +1,73 @@
+package stripe
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/majorcontext/moat/internal/log"
+)
+
+// maxLiveModeBodySize caps how much of a response BlockLiveMode inspects.
+// Stripe API objects and list pages are far smaller.
+const maxLiveModeBodySize = 4 << 20
+
+// liveModePattern matches a live-mode object anywhere in a JSON response.
+var liveModePattern = regexp.MustCompile(`"livemode"\s*:\s*true`)
+
+// liveModeBlockedBody is returned in place of a live-mode response, shaped
+// like a Stripe API error so SDKs surface the message.
+const liveModeBlockedBody = `{"error":{"type":"invalid_request_error","code":"moat_live_mode_blocked",` +
+	`"message":"moat: this stripe grant is test-mode only; a live-mode response was blocked by the proxy"}}`
+
+// GuardLiveMode is the request guard kind that has the proxy daemon refuse
+// a test-mode-only grant's live-mode requests before forwarding them: those
+// that would carry a live key, whether the agent sent it or the proxy would
+// inject it.
+const GuardLiveMode = "stripe-live-mode"
+
+// BlockLiveMode is a response transformer that replaces any JSON response
+// reporting "livemode": true with a 403 error. Bodies over
+// maxLiveModeBodySize and non-JSON responses pass through. The daemon keeps
+// it for runs registered by CLIs that predate GuardLiveMode.
+func BlockLiveMode(reqInterface, respInterface any) (any, bool) {
+	resp, ok := respInterface.(*http.Response)
+	if !ok || resp.Body == nil {
+		return respInterface, false
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "" && !strings.Contains(ct, "json") {
+		return resp, false
+	}
+	if resp.ContentLength > maxLiveModeBodySize {
+		return resp, false
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxLiveModeBodySize))
+	resp.Body.Close()
+	if err != nil || !liveModePattern.Match(body) {
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+		return resp, false
+	}
+
+	path := ""
+	if req, ok := reqInterface.(*http.Request); ok {
+		path = req.URL.Path
+	}
+	log.Warn("blocked live-mode stripe response",
+		"subsystem", "stripe",
+		"path", path,
+		"status", resp.StatusCode)
+
+	resp.StatusCode = http.StatusForbidden
+	resp.Status = "403 Forbidden"
+	resp.Header.Set("Content-Type", "application/json")
+	resp.Header.Set("Content-Length", strconv.Itoa(len(liveModeBlockedBody)))
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Set("X-Moat-Blocked", "stripe-live-mode")
+	resp.Body = io.NopCloser(strings.NewReader(liveModeBlockedBody))
+	resp.ContentLength = int64(len(liveModeBlockedBody))
+	return resp, true
+}
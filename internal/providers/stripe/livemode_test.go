@@ -0,0 +1,52 @@
+package stripe
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestBlockLiveMode(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		wantBlocked bool
+	}{
+		{name: "live object", contentType: "application/json", body: `{"id":"ch_1","object":"charge","livemode": true}`, wantBlocked: true},
+		{name: "live object in list", contentType: "application/json", body: `{"object":"list","data":[{"id":"cus_1","livemode":true}]}`, wantBlocked: true},
+		{name: "test object", contentType: "application/json", body: `{"id":"ch_1","object":"charge","livemode":false}`},
+		{name: "non-JSON", contentType: "application/pdf", body: `"livemode":true`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &http.Request{URL: &url.URL{Path: "/v1/charges"}}
+			resp := &http.Response{
+				StatusCode:    http.StatusOK,
+				Header:        http.Header{"Content-Type": {tt.contentType}},
+				Body:          io.NopCloser(strings.NewReader(tt.body)),
+				ContentLength: int64(len(tt.body)),
+			}
+			out, blocked := BlockLiveMode(req, resp)
+			if blocked != tt.wantBlocked {
+				t.Fatalf("blocked = %v, want %v", blocked, tt.wantBlocked)
+			}
+			got := out.(*http.Response)
+			body, _ := io.ReadAll(got.Body)
+			if tt.wantBlocked {
+				if got.StatusCode != http.StatusForbidden || !strings.Contains(string(body), "moat_live_mode_blocked") {
+					t.Errorf("status = %d, body = %s", got.StatusCode, body)
+				}
+				if got.ContentLength != int64(len(body)) {
+					t.Errorf("ContentLength = %d, body is %d bytes", got.ContentLength, len(body))
+				}
+				return
+			}
+			if got.StatusCode != http.StatusOK || string(body) != tt.body {
+				t.Errorf("passed-through response changed: status = %d, body = %s", got.StatusCode, body)
+			}
+		})
+	}
+}
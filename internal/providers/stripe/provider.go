@@ -0,0 +1,150 @@
+package stripe
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	gkproxy "github.com/majorcontext/gatekeeper/proxy"
+	"github.com/majorcontext/moat/internal/credential"
+	"github.com/majorcontext/moat/internal/log"
+	"github.com/majorcontext/moat/internal/provider"
+	"github.com/majorcontext/moat/internal/provider/util"
+)
+
+// Token source values stored in Credential.Metadata[provider.MetaKeyTokenSource].
+const (
+	SourceEnv    = "env"    // From STRIPE_API_KEY or STRIPE_SECRET_KEY env var
+	SourceManual = "manual" // Interactive prompt entry
+)
+
+// MetaKeyTestModeOnly is the metadata key set to "true" when the grant was
+// restricted to test-mode keys.
+const MetaKeyTestModeOnly = "test_mode_only"
+
+// Hosts are the Stripe API hosts the key is injected for.
+var Hosts = []string{"api.stripe.com", "files.stripe.com"}
+
+// balanceURL is the endpoint CheckCredential probes. A variable so tests can
+// point it at a local server.
+var balanceURL = "https://api.stripe.com/v1/balance"
+
+// Provider implements provider.CredentialProvider for Stripe.
+type Provider struct{}
+
+// Verify interface compliance at compile time.
+var (
+	_ provider.CredentialProvider = (*Provider)(nil)
+	_ provider.CredentialChecker  = (*Provider)(nil)
+	_ provider.GuardingProvider   = (*Provider)(nil)
+)
+
+func init() {
+	provider.Register(&Provider{})
+	// Declare the hosts the grant is used with, for grant scopes and
+	// network suggestions.
+	gkproxy.RegisterGrantHosts("stripe", Hosts)
+}
+
+// Name returns the provider identifier.
+func (p *Provider) Name() string {
+	return "stripe"
+}
+
+// keyPrefix returns the type and mode prefix of a key, e.g. "sk_test_", or
+// "" if key is not a secret or restricted key.
+func keyPrefix(key string) string {
+	for _, prefix := range []string{"sk_test_", "sk_live_", "rk_test_", "rk_live_"} {
+		if strings.HasPrefix(key, prefix) {
+			return prefix
+		}
+	}
+	return ""
+}
+
+// IsTestKey reports whether key is a test-mode secret or restricted key.
+func IsTestKey(key string) bool {
+	return strings.HasSuffix(keyPrefix(key), "_test_")
+}
+
+// ContainsLiveKey reports whether s holds a live-mode secret or restricted
+// key anywhere, as in a header value or query string.
+func ContainsLiveKey(s string) bool {
+	return strings.Contains(s, "sk_live_") || strings.Contains(s, "rk_live_")
+}
+
+// CheckKey refuses a credential whose key its grant does not allow: a
+// test-mode-only grant holding a live key.
+func CheckKey(cred *provider.Credential) error {
+	if TestModeOnly(cred) && !IsTestKey(cred.Token) {
+		return &provider.GrantError{
+			Provider: "stripe",
+			Cause:    fmt.Errorf("live-mode key rejected: --test-mode-only allows only sk_test_ and rk_test_ keys"),
+			Hint:     "Use a test-mode key from https://dashboard.stripe.com/test/apikeys",
+		}
+	}
+	return nil
+}
+
+// TestModeOnly reports whether cred was granted with --test-mode-only.
+func TestModeOnly(cred *provider.Credential) bool {
+	return cred.Metadata[MetaKeyTestModeOnly] == "true"
+}
+
+// ConfigureProxy injects the key for the Stripe API hosts. For test-mode-only
+// grants it refuses to inject a live key.
+func (p *Provider) ConfigureProxy(proxy provider.ProxyConfigurer, cred *provider.Credential) {
+	if TestModeOnly(cred) && !IsTestKey(cred.Token) {
+		log.Warn("stripe grant is test-mode only but holds a live key; not injecting it",
+			"subsystem", "stripe")
+		return
+	}
+	for _, host := range Hosts {
+		proxy.SetCredentialWithGrant(host, "Authorization", "Bearer "+cred.Token, "stripe")
+	}
+}
+
+// RequestGuards has the daemon refuse live-mode requests for test-mode-only
+// grants before forwarding them. See GuardLiveMode.
+func (p *Provider) RequestGuards(cred *provider.Credential) []provider.RequestGuard {
+	if !TestModeOnly(cred) {
+		return nil
+	}
+	guards := make([]provider.RequestGuard, 0, len(Hosts))
+	for _, host := range Hosts {
+		guards = append(guards, provider.RequestGuard{Host: host, Kind: GuardLiveMode})
+	}
+	return guards
+}
+
+// ContainerEnv sets a placeholder STRIPE_API_KEY with the key's mode prefix.
+// The real key is injected by the proxy at the network layer.
+func (p *Provider) ContainerEnv(cred *provider.Credential) []string {
+	return []string{"STRIPE_API_KEY=" + keyPrefix(cred.Token) + credential.ProxyInjectedPlaceholder}
+}
+
+// ContainerMounts returns no mounts.
+func (p *Provider) ContainerMounts(cred *provider.Credential, containerHome string) ([]provider.MountConfig, string, error) {
+	return nil, "", nil
+}
+
+// Cleanup is a no-op.
+func (p *Provider) Cleanup(cleanupPath string) {}
+
+// ImpliedDependencies returns dependencies implied by this provider.
+func (p *Provider) ImpliedDependencies() []string {
+	return nil
+}
+
+// CheckCredential verifies the key by reading the account balance. A
+// restricted key without balance access gets 403, which is inconclusive.
+func (p *Provider) CheckCredential(ctx context.Context, cred *provider.Credential) error {
+	req, err := http.NewRequest("GET", balanceURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+cred.Token)
+	req.Header.Set("User-Agent", "moat")
+	return util.ProbeCredential(ctx, req)
+}
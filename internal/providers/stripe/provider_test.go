@@ -0,0 +1,173 @@
+package stripe
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/majorcontext/moat/internal/provider"
+)
+
+type mockProxyConfigurer struct {
+	credentials    map[string]string
+	removedHeaders map[string]string
+	transformers   map[string]int
+}
+
+func newMockProxy() *mockProxyConfigurer {
+	return &mockProxyConfigurer{
+		credentials:    map[string]string{},
+		removedHeaders: map[string]string{},
+		transformers:   map[string]int{},
+	}
+}
+
+func (m *mockProxyConfigurer) SetCredential(host, value string)                         {}
+func (m *mockProxyConfigurer) SetCredentialHeader(host, headerName, headerValue string) {}
+func (m *mockProxyConfigurer) SetCredentialWithGrant(host, headerName, headerValue, grant string) {
+	m.credentials[host] = headerName + ": " + headerValue
+}
+func (m *mockProxyConfigurer) AddExtraHeader(host, headerName, headerValue string) {}
+func (m *mockProxyConfigurer) AddResponseTransformer(host string, t provider.ResponseTransformer) {
+	m.transformers[host]++
+}
+func (m *mockProxyConfigurer) RemoveRequestHeader(host, header string) {
+	m.removedHeaders[host] = header
+}
+func (m *mockProxyConfigurer) SetTokenSubstitution(host, placeholder, realToken string) {}
+
+func TestIsTestKey(t *testing.T) {
+	for key, want := range map[string]bool{
+		"sk_test_abc": true,
+		"rk_test_abc": true,
+		"sk_live_abc": false,
+		"rk_live_abc": false,
+		"pk_test_abc": false,
+		"abc":         false,
+	} {
+		if got := IsTestKey(key); got != want {
+			t.Errorf("IsTestKey(%q) = %v, want %v", key, got, want)
+		}
+	}
+}
+
+func TestProvider_ConfigureProxy(t *testing.T) {
+	p := &Provider{}
+
+	proxy := newMockProxy()
+	p.ConfigureProxy(proxy, &provider.Credential{Token: "sk_live_abc"})
+	if proxy.credentials["api.stripe.com"] != "Authorization: Bearer sk_live_abc" {
+		t.Errorf("api.stripe.com credential = %q", proxy.credentials["api.stripe.com"])
+	}
+
+	testOnly := map[string]string{MetaKeyTestModeOnly: "true"}
+	proxy = newMockProxy()
+	p.ConfigureProxy(proxy, &provider.Credential{Token: "sk_test_abc", Metadata: testOnly})
+	for _, host := range Hosts {
+		if proxy.credentials[host] != "Authorization: Bearer sk_test_abc" {
+			t.Errorf("%s credential = %q", host, proxy.credentials[host])
+		}
+	}
+	if len(proxy.transformers) != 0 || len(proxy.removedHeaders) != 0 {
+		t.Errorf("transformers = %v, removed headers = %v; want none", proxy.transformers, proxy.removedHeaders)
+	}
+
+	proxy = newMockProxy()
+	p.ConfigureProxy(proxy, &provider.Credential{Token: "sk_live_abc", Metadata: testOnly})
+	if len(proxy.credentials) != 0 {
+		t.Errorf("live key injected for a test-mode-only grant: %v", proxy.credentials)
+	}
+}
+
+func TestProvider_RequestGuards(t *testing.T) {
+	p := &Provider{}
+	if guards := p.RequestGuards(&provider.Credential{Token: "sk_live_abc"}); len(guards) != 0 {
+		t.Errorf("guards without --test-mode-only = %v", guards)
+	}
+	guards := p.RequestGuards(&provider.Credential{Token: "sk_test_abc", Metadata: map[string]string{MetaKeyTestModeOnly: "true"}})
+	if len(guards) != len(Hosts) {
+		t.Fatalf("guards = %v, want one per host", guards)
+	}
+	for i, g := range guards {
+		if g.Host != Hosts[i] || g.Kind != GuardLiveMode {
+			t.Errorf("guard %d = %+v", i, g)
+		}
+	}
+}
+
+func TestCheckKey(t *testing.T) {
+	testOnly := map[string]string{MetaKeyTestModeOnly: "true"}
+	if err := CheckKey(&provider.Credential{Token: "sk_live_abc", Metadata: testOnly}); err == nil {
+		t.Error("live key accepted for a test-mode-only grant")
+	}
+	if err := CheckKey(&provider.Credential{Token: "rk_test_abc", Metadata: testOnly}); err != nil {
+		t.Errorf("test key refused: %v", err)
+	}
+	if err := CheckKey(&provider.Credential{Token: "sk_live_abc"}); err != nil {
+		t.Errorf("live key refused without --test-mode-only: %v", err)
+	}
+}
+
+func TestProvider_ContainerEnv(t *testing.T) {
+	env := (&Provider{}).ContainerEnv(&provider.Credential{Token: "rk_test_secret"})
+	if len(env) != 1 || env[0] != "STRIPE_API_KEY=rk_test_moat-proxy-injected" {
+		t.Errorf("ContainerEnv = %v", env)
+	}
+}
+
+func TestGrant(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Header.Get("Authorization") {
+		case "Bearer sk_test_good", "Bearer sk_live_good":
+			w.Write([]byte(`{"object":"balance"}`))
+		case "Bearer rk_test_limited":
+			w.WriteHeader(http.StatusForbidden)
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer srv.Close()
+	orig := balanceURL
+	balanceURL = srv.URL
+	defer func() { balanceURL = orig }()
+
+	tests := []struct {
+		name     string
+		key      string
+		testOnly bool
+		wantErr  string
+	}{
+		{name: "test key", key: "sk_test_good", testOnly: true},
+		{name: "restricted key without balance access", key: "rk_test_limited", testOnly: true},
+		{name: "live key allowed", key: "sk_live_good"},
+		{name: "live key rejected by test-mode-only", key: "sk_live_good", testOnly: true, wantErr: "live-mode key rejected"},
+		{name: "publishable key", key: "pk_test_good", wantErr: "not a Stripe secret"},
+		{name: "invalid key", key: "sk_test_bad", wantErr: "401"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("STRIPE_API_KEY", tt.key)
+			cred, err := (&Provider{}).Grant(WithGrantOptions(context.Background(), tt.testOnly))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Grant: %v", err)
+			}
+			if TestModeOnly(cred) != tt.testOnly {
+				t.Errorf("TestModeOnly = %v, want %v", TestModeOnly(cred), tt.testOnly)
+			}
+		})
+	}
+}
+
+func TestProvider_Registered(t *testing.T) {
+	if provider.Get("stripe") == nil {
+		t.Fatal("stripe provider not registered")
+	}
+}
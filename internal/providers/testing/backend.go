@@ -0,0 +1,264 @@
+// Package providertest runs fake provider APIs for hermetic tests of the
+// credential-injecting proxy.
+//
+// Each Backend is a TLS server on loopback that stands in for one real API
+// host (api.anthropic.com, api.openai.com, api.github.com). It answers a few
+// representative endpoints, rejects requests that do not carry the backend's
+// token the way the real API would, and records every request it receives so
+// tests can assert what the proxy injected or stripped.
+//
+// NewProxy starts an in-process proxy configured from a daemon.RunContext and
+// routes the backend's real host name to the fake, so tests exercise the same
+// provider ConfigureProxy code, credential injection, and response
+// transformers as a real run without tokens or network access.
+package providertest
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Request is a request received by a Backend.
+type Request struct {
+	Method string
+	Path   string
+	Header http.Header
+	Body   []byte
+}
+
+// Backend is a fake provider API served over TLS.
+type Backend struct {
+	// Host is the real API host the backend stands in for.
+	Host string
+	// Token is the credential the backend accepts. Tests grant it to the
+	// provider under test.
+	Token string
+
+	server     *httptest.Server
+	mux        *http.ServeMux
+	authorized func(r *http.Request) bool
+	deny       func(w http.ResponseWriter)
+
+	mu       sync.Mutex
+	requests []Request
+}
+
+// newBackend starts a backend and stops it when the test ends. Routes are
+// added by the caller before the first request.
+func newBackend(t testing.TB, host, token string) *Backend {
+	t.Helper()
+	b := &Backend{Host: host, Token: token, mux: http.NewServeMux()}
+	b.server = httptest.NewTLSServer(http.HandlerFunc(b.serve))
+	t.Cleanup(b.server.Close)
+	return b
+}
+
+func (b *Backend) serve(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	b.mu.Lock()
+	b.requests = append(b.requests, Request{
+		Method: r.Method,
+		Path:   r.URL.Path,
+		Header: r.Header.Clone(),
+		Body:   body,
+	})
+	b.mu.Unlock()
+
+	if !b.authorized(r) {
+		b.deny(w)
+		return
+	}
+	b.mux.ServeHTTP(w, r)
+}
+
+// Handle registers a handler for pattern, replacing the backend's canned
+// behavior for matching paths. Requests still have to pass the backend's
+// authentication check.
+func (b *Backend) Handle(pattern string, handler http.HandlerFunc) {
+	b.mux.HandleFunc(pattern, handler)
+}
+
+// Addr returns the backend's listen address (127.0.0.1:port).
+func (b *Backend) Addr() string {
+	return b.server.Listener.Addr().String()
+}
+
+// Requests returns the requests the backend has received, oldest first.
+func (b *Backend) Requests() []Request {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]Request(nil), b.requests...)
+}
+
+// LastRequest returns the most recent request. It fails the test when the
+// backend has not received any.
+func (b *Backend) LastRequest(t testing.TB) Request {
+	t.Helper()
+	reqs := b.Requests()
+	if len(reqs) == 0 {
+		t.Fatalf("%s backend received no requests", b.Host)
+	}
+	return reqs[len(reqs)-1]
+}
+
+// loopbackHost is the host name the proxy sees for every backend.
+func (b *Backend) loopbackHost() string {
+	host, _, _ := net.SplitHostPort(b.Addr())
+	return host
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// Anthropic starts a fake api.anthropic.com. It accepts Token as an API key
+// (x-api-key) or as an OAuth token (Authorization: Bearer). Like the real
+// API, the /api/oauth/ endpoints answer 403 to tokens without the profile
+// scopes, and a request with an x-api-key that is not Token is rejected even
+// when Authorization is valid.
+func Anthropic(t testing.TB) *Backend {
+	b := newBackend(t, "api.anthropic.com", "sk-ant-providertest")
+	b.authorized = func(r *http.Request) bool {
+		if key := r.Header.Get("x-api-key"); key != "" {
+			return key == b.Token
+		}
+		return r.Header.Get("Authorization") == "Bearer "+b.Token
+	}
+	b.deny = func(w http.ResponseWriter) {
+		writeJSON(w, http.StatusUnauthorized, map[string]any{
+			"type":  "error",
+			"error": map[string]string{"type": "authentication_error", "message": "invalid x-api-key"},
+		})
+	}
+	b.Handle("POST /v1/messages", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Model string `json:"model"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		writeJSON(w, http.StatusOK, map[string]any{
+			"id":          "msg_providertest",
+			"type":        "message",
+			"role":        "assistant",
+			"model":       req.Model,
+			"content":     []map[string]string{{"type": "text", "text": "ok"}},
+			"stop_reason": "end_turn",
+			"usage":       map[string]int{"input_tokens": 1, "output_tokens": 1},
+		})
+	})
+	b.Handle("/api/oauth/", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusForbidden, map[string]any{
+			"type":  "error",
+			"error": map[string]string{"type": "permission_error", "message": "OAuth token does not meet scope requirement user:profile"},
+		})
+	})
+	return b
+}
+
+// OpenAI starts a fake api.openai.com that accepts Token as a Bearer token.
+func OpenAI(t testing.TB) *Backend {
+	b := newBackend(t, "api.openai.com", "sk-proj-providertest")
+	b.authorized = func(r *http.Request) bool {
+		return r.Header.Get("Authorization") == "Bearer "+b.Token
+	}
+	b.deny = func(w http.ResponseWriter) {
+		writeJSON(w, http.StatusUnauthorized, map[string]any{
+			"error": map[string]any{"type": "invalid_request_error", "code": "invalid_api_key", "message": "Incorrect API key provided"},
+		})
+	}
+	b.Handle("POST /v1/chat/completions", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]any{
+			"id":     "chatcmpl-providertest",
+			"object": "chat.completion",
+			"choices": []map[string]any{{
+				"index":         0,
+				"message":       map[string]string{"role": "assistant", "content": "ok"},
+				"finish_reason": "stop",
+			}},
+		})
+	})
+	b.Handle("GET /v1/models", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]any{
+			"object": "list",
+			"data":   []map[string]string{{"id": "gpt-4o", "object": "model"}},
+		})
+	})
+	return b
+}
+
+// GitHub starts a fake api.github.com that accepts Token with either the
+// "Bearer" or the legacy "token" scheme.
+func GitHub(t testing.TB) *Backend {
+	b := newBackend(t, "api.github.com", "ghp_providertest")
+	b.authorized = func(r *http.Request) bool {
+		scheme, token, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+		return (strings.EqualFold(scheme, "Bearer") || strings.EqualFold(scheme, "token")) && token == b.Token
+	}
+	b.deny = func(w http.ResponseWriter) {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{
+			"message":           "Bad credentials",
+			"documentation_url": "https://docs.github.com/rest",
+		})
+	}
+	b.Handle("GET /user", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]any{"login": "moat-providertest", "id": 1})
+	})
+	return b
+}
+
+// GHCR starts a fake ghcr.io that authenticates like a Docker registry: the
+// /token endpoint exchanges Basic credentials with Token as the password for
+// a Bearer token, which the /v2/ API requires. Unauthenticated requests get
+// a WWW-Authenticate challenge pointing at /token.
+func GHCR(t testing.TB) *Backend {
+	b := newBackend(t, "ghcr.io", "ghp_ghcrtest")
+	const registryToken = "ghcr-registry-token"
+	b.authorized = func(r *http.Request) bool {
+		if r.URL.Path == "/token" {
+			_, password, ok := r.BasicAuth()
+			return ok && password == b.Token
+		}
+		return r.Header.Get("Authorization") == "Bearer "+registryToken
+	}
+	b.deny = func(w http.ResponseWriter) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="https://ghcr.io/token",service="ghcr.io"`)
+		writeJSON(w, http.StatusUnauthorized, map[string]any{
+			"errors": []map[string]string{{"code": "UNAUTHORIZED", "message": "authentication required"}},
+		})
+	}
+	b.Handle("GET /token", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{"token": registryToken})
+	})
+	b.Handle("GET /v2/", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]any{})
+	})
+	return b
+}
+
+// Bearer starts a fake API at host that accepts Token as a Bearer token and
+// answers every authorized request with an empty JSON object. It stands in
+// for APIs without a dedicated backend, such as config-defined providers.
+// Every Bearer backend accepts the same Token.
+func Bearer(t testing.TB, host string) *Backend {
+	b := newBackend(t, host, "bearer_providertest")
+	b.authorized = func(r *http.Request) bool {
+		return r.Header.Get("Authorization") == "Bearer "+b.Token
+	}
+	b.deny = func(w http.ResponseWriter) {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_token"})
+	}
+	b.Handle("/", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]any{})
+	})
+	return b
+}
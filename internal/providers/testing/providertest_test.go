@@ -0,0 +1,196 @@
+package providertest_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/majorcontext/moat/internal/daemon"
+	"github.com/majorcontext/moat/internal/provider"
+	"github.com/majorcontext/moat/internal/providers/claude"
+	"github.com/majorcontext/moat/internal/providers/codex"
+	"github.com/majorcontext/moat/internal/providers/configprovider"
+	"github.com/majorcontext/moat/internal/providers/containerregistry"
+	"github.com/majorcontext/moat/internal/providers/github"
+	providertest "github.com/majorcontext/moat/internal/providers/testing"
+)
+
+func send(t *testing.T, p *providertest.Proxy, method, url string, header http.Header, body string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, url, err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func TestAnthropicAPIKeyInjected(t *testing.T) {
+	backend := providertest.Anthropic(t)
+	rc := daemon.NewRunContext("run_providertest")
+	(&claude.AnthropicProvider{}).ConfigureProxy(rc, &provider.Credential{Token: backend.Token})
+	p := providertest.NewProxy(t, rc, backend)
+
+	resp := send(t, p, http.MethodPost, "https://api.anthropic.com/v1/messages",
+		http.Header{"X-Api-Key": {claude.ProxyInjectedPlaceholder}}, `{"model":"claude-sonnet-4-5"}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+	if got := backend.LastRequest(t).Header.Get("x-api-key"); got != backend.Token {
+		t.Errorf("backend saw x-api-key %q, want the granted key", got)
+	}
+}
+
+func TestClaudeOAuthHeadersAndTransformer(t *testing.T) {
+	backend := providertest.Anthropic(t)
+	rc := daemon.NewRunContext("run_providertest")
+	(&claude.OAuthProvider{}).ConfigureProxy(rc, &provider.Credential{Token: backend.Token})
+	p := providertest.NewProxy(t, rc, backend)
+
+	resp := send(t, p, http.MethodPost, "https://api.anthropic.com/v1/messages",
+		http.Header{"X-Api-Key": {claude.ProxyInjectedPlaceholder}}, `{}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+	got := backend.LastRequest(t).Header
+	if got.Get("Authorization") != "Bearer "+backend.Token {
+		t.Errorf("Authorization = %q, want the granted OAuth token", got.Get("Authorization"))
+	}
+	if got.Get("x-api-key") != "" {
+		t.Errorf("x-api-key = %q, want it stripped", got.Get("x-api-key"))
+	}
+	if got.Get("anthropic-beta") != "oauth-2025-04-20" {
+		t.Errorf("anthropic-beta = %q, want the OAuth beta flag", got.Get("anthropic-beta"))
+	}
+
+	// The backend answers 403 on profile endpoints; the transformer turns
+	// that into an empty success.
+	resp = send(t, p, http.MethodGet, "https://api.anthropic.com/api/oauth/profile", nil, "")
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("X-Moat-Transformed") == "" {
+		t.Errorf("profile status = %d, transformed = %q; want a transformed 200",
+			resp.StatusCode, resp.Header.Get("X-Moat-Transformed"))
+	}
+}
+
+func TestOpenAIBearerInjected(t *testing.T) {
+	backend := providertest.OpenAI(t)
+	rc := daemon.NewRunContext("run_providertest")
+	(&codex.Provider{}).ConfigureProxy(rc, &provider.Credential{Token: backend.Token})
+	p := providertest.NewProxy(t, rc, backend)
+
+	resp := send(t, p, http.MethodPost, "https://api.openai.com/v1/chat/completions",
+		http.Header{"Authorization": {"Bearer placeholder"}}, `{"model":"gpt-4o"}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+	if got := backend.LastRequest(t).Header.Get("Authorization"); got != "Bearer "+backend.Token {
+		t.Errorf("Authorization = %q, want the granted key", got)
+	}
+}
+
+func TestGitHubTokenInjected(t *testing.T) {
+	backend := providertest.GitHub(t)
+	rc := daemon.NewRunContext("run_providertest")
+	(&github.Provider{}).ConfigureProxy(rc, &provider.Credential{Token: backend.Token})
+	p := providertest.NewProxy(t, rc, backend)
+
+	resp := send(t, p, http.MethodGet, "https://api.github.com/user", nil, "")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+	var user struct{ Login string }
+	if err := json.NewDecoder(resp.Body).Decode(&user); err != nil || user.Login == "" {
+		t.Errorf("decoding /user: login=%q err=%v", user.Login, err)
+	}
+}
+
+func TestContainerRegistryPlaceholderSubstituted(t *testing.T) {
+	backend := providertest.GHCR(t)
+	reg := containerregistry.Registry{Host: "ghcr.io", Username: "octocat", Password: backend.Token, AuthHost: "ghcr.io"}
+	token, err := containerregistry.MarshalRegistries([]containerregistry.Registry{reg})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rc := daemon.NewRunContext("run_providertest")
+	(&containerregistry.Provider{}).ConfigureProxy(rc, &provider.Credential{Token: token})
+	p := providertest.NewProxy(t, rc, backend)
+
+	// The Docker client sends the placeholder credentials from its config
+	// to the token service; the proxy swaps in the real password.
+	resp := send(t, p, http.MethodGet, "https://ghcr.io/token?service=ghcr.io&scope=repository:org/app:pull",
+		http.Header{"Authorization": {"Basic " + reg.PlaceholderAuth()}}, "")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("token status = %d, want 200", resp.StatusCode)
+	}
+	var tok struct{ Token string }
+	if err := json.NewDecoder(resp.Body).Decode(&tok); err != nil || tok.Token == "" {
+		t.Fatalf("decoding token: token=%q err=%v", tok.Token, err)
+	}
+
+	// The Bearer token the registry issued passes through untouched.
+	resp = send(t, p, http.MethodGet, "https://ghcr.io/v2/org/app/manifests/latest",
+		http.Header{"Authorization": {"Bearer " + tok.Token}}, "")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("manifest status = %d, want 200", resp.StatusCode)
+	}
+	if got := backend.LastRequest(t).Header.Get("Authorization"); got != "Bearer "+tok.Token {
+		t.Errorf("manifest Authorization = %q, want the registry's Bearer token", got)
+	}
+}
+
+func TestFileSecretSubstitutedOnEveryHost(t *testing.T) {
+	api := providertest.Bearer(t, "api.acme.example")
+	auth := providertest.Bearer(t, "auth.acme.example")
+	cp := configprovider.NewConfigProvider(configprovider.ProviderDef{
+		Name:         "acme",
+		Hosts:        []string{api.Host},
+		ContainerEnv: "ACME_TOKEN",
+		Files: []configprovider.FileDef{{
+			Path:    ".acme/credentials.json",
+			Content: `{"token": "${token}"}`,
+			Hosts:   []string{api.Host, auth.Host},
+		}},
+	}, "custom")
+	cred := &provider.Credential{Token: api.Token}
+	rc := daemon.NewRunContext("run_providertest")
+	cp.ConfigureProxy(rc, cred)
+	_, placeholder, _ := strings.Cut(cp.ContainerEnv(cred)[0], "=")
+
+	// The CLI sends the placeholder from its credentials file to both
+	// hosts, in the Authorization header and in the body; each gets the
+	// real token.
+	for _, backend := range []*providertest.Backend{api, auth} {
+		p := providertest.NewProxy(t, rc, backend)
+		resp := send(t, p, http.MethodPost, "https://"+backend.Host+"/session",
+			http.Header{"Authorization": {"Bearer " + placeholder}}, `{"token":"`+placeholder+`"}`)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("%s status = %d, want 200", backend.Host, resp.StatusCode)
+		}
+		if body := string(backend.LastRequest(t).Body); body != `{"token":"`+backend.Token+`"}` {
+			t.Errorf("%s body = %s, want the real token substituted", backend.Host, body)
+		}
+	}
+}
+
+func TestBackendRejectsMissingGrant(t *testing.T) {
+	backend := providertest.GitHub(t)
+	p := providertest.NewProxy(t, daemon.NewRunContext("run_providertest"), backend)
+
+	resp := send(t, p, http.MethodGet, "https://api.github.com/user", nil, "")
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("status = %d (%s), want 401 without a grant", resp.StatusCode, body)
+	}
+	if len(backend.Requests()) != 1 {
+		t.Errorf("backend recorded %d requests, want 1", len(backend.Requests()))
+	}
+}
@@ -0,0 +1,114 @@
+package providertest
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/majorcontext/gatekeeper/proxy"
+	"github.com/majorcontext/moat/internal/daemon"
+)
+
+// proxyToken is the proxy auth token the test client presents.
+const proxyToken = "providertest-proxy-token"
+
+// Proxy is an in-process TLS-intercepting proxy that serves one run context
+// and routes one backend's real host to the fake.
+type Proxy struct {
+	// Client sends requests through the proxy. Requests are addressed to
+	// the real host (https://api.anthropic.com/v1/messages); the client
+	// connects to the backend instead.
+	Client *http.Client
+}
+
+// NewProxy starts a proxy for rc that sends traffic for backend.Host to
+// backend, and stops it when the test ends.
+//
+// The proxy only sees the backend's loopback address, so the run context's
+// per-host configuration for backend.Host (credentials, extra and removed
+// headers, token substitutions, and response transformers) is moved to that
+// address when each request is resolved. Every backend listens on the same
+// loopback IP, so a proxy routes a single backend; use one proxy per backend
+// when a test needs several.
+func NewProxy(t testing.TB, rc *daemon.RunContext, backend *Backend) *Proxy {
+	t.Helper()
+
+	ca, err := proxy.NewCA(t.TempDir())
+	if err != nil {
+		t.Fatalf("creating proxy CA: %v", err)
+	}
+	upstream := x509.NewCertPool()
+	upstream.AddCert(backend.server.Certificate())
+
+	p := proxy.NewProxy()
+	p.SetCA(ca)
+	p.SetUpstreamCAs(upstream)
+	p.SetContextResolver(func(token string) (*proxy.RunContextData, bool) {
+		if token != proxyToken {
+			return nil, false
+		}
+		d := rc.ToProxyContextData()
+		rehost(d, backend.Host, backend.loopbackHost())
+		return d, true
+	})
+	server := httptest.NewServer(p)
+	t.Cleanup(server.Close)
+
+	proxyURL, _ := url.Parse(server.URL)
+	proxyURL.User = url.UserPassword("moat", proxyToken)
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM(ca.CertPEM())
+	transport := &http.Transport{
+		Proxy:           http.ProxyURL(proxyURL),
+		TLSClientConfig: &tls.Config{RootCAs: roots},
+	}
+	t.Cleanup(transport.CloseIdleConnections)
+
+	return &Proxy{Client: &http.Client{
+		Transport: &routeTransport{host: backend.Host, addr: backend.Addr(), next: transport},
+	}}
+}
+
+// routeTransport sends requests for host to addr.
+type routeTransport struct {
+	host string
+	addr string
+	next http.RoundTripper
+}
+
+func (rt *routeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Hostname() != rt.host {
+		return rt.next.RoundTrip(req)
+	}
+	req = req.Clone(req.Context())
+	req.URL.Host = rt.addr
+	req.Host = ""
+	return rt.next.RoundTrip(req)
+}
+
+// rehost moves the per-host entries for from to to.
+func rehost(d *proxy.RunContextData, from, to string) {
+	if v, ok := d.Credentials[from]; ok {
+		d.Credentials[to] = v
+		delete(d.Credentials, from)
+	}
+	if v, ok := d.ExtraHeaders[from]; ok {
+		d.ExtraHeaders[to] = v
+		delete(d.ExtraHeaders, from)
+	}
+	if v, ok := d.RemoveHeaders[from]; ok {
+		d.RemoveHeaders[to] = v
+		delete(d.RemoveHeaders, from)
+	}
+	if v, ok := d.TokenSubstitutions[from]; ok {
+		d.TokenSubstitutions[to] = v
+		delete(d.TokenSubstitutions, from)
+	}
+	if v, ok := d.ResponseTransformers[from]; ok {
+		d.ResponseTransformers[to] = v
+		delete(d.ResponseTransformers, from)
+	}
+}
@@ -0,0 +1,16 @@
+// Package twilio implements the Twilio credential provider.
+//
+// The provider stores an Account SID with its auth token, or an API key SID
+// and secret for that account. Credentials can be obtained from:
+//   - Environment variables (TWILIO_ACCOUNT_SID with TWILIO_AUTH_TOKEN, or
+//     with TWILIO_API_KEY and TWILIO_API_SECRET)
+//   - Interactive prompt
+//
+// The proxy injects HTTP Basic auth for api.twilio.com. Containers receive
+// the real SIDs, which are identifiers rather than secrets, and a placeholder
+// in place of the auth token or API secret.
+//
+// Sending an SMS or placing a call reaches a real person, so the proxy
+// counts POSTs to the Messages and Calls resources against the run's
+// messaging.max_messages cap and records each in the audit log.
+package twilio
@@ -0,0 +1,152 @@
+package twilio
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/majorcontext/moat/internal/provider"
+	"github.com/majorcontext/moat/internal/provider/util"
+)
+
+// Grant acquires Twilio credentials from the environment or interactive
+// prompts.
+//
+// Credential acquisition order:
+//  1. TWILIO_ACCOUNT_SID with TWILIO_API_KEY and TWILIO_API_SECRET
+//  2. TWILIO_ACCOUNT_SID with TWILIO_AUTH_TOKEN
+//  3. Interactive prompts for the Account SID and auth token
+func (p *Provider) Grant(ctx context.Context) (*provider.Credential, error) {
+	accountSID := os.Getenv("TWILIO_ACCOUNT_SID")
+	if accountSID != "" {
+		if keySID, secret := os.Getenv("TWILIO_API_KEY"), os.Getenv("TWILIO_API_SECRET"); keySID != "" && secret != "" {
+			fmt.Println("Using API key from TWILIO_API_KEY and TWILIO_API_SECRET environment variables")
+			return p.validateAndCreateCredential(ctx, accountSID, keySID, secret, SourceEnv)
+		}
+		if token := os.Getenv("TWILIO_AUTH_TOKEN"); token != "" {
+			fmt.Println("Using auth token from TWILIO_AUTH_TOKEN environment variable")
+			return p.validateAndCreateCredential(ctx, accountSID, "", token, SourceEnv)
+		}
+	}
+
+	if err := util.RequireInput(ctx, "set TWILIO_ACCOUNT_SID with TWILIO_AUTH_TOKEN, or with TWILIO_API_KEY and TWILIO_API_SECRET"); err != nil {
+		return nil, err
+	}
+	fmt.Println(`Enter your Twilio Account SID and auth token.
+
+To find them:
+  1. Visit https://console.twilio.com
+  2. Copy the Account SID and auth token from Account Info
+  3. Paste them below
+
+To use an API key instead, set TWILIO_ACCOUNT_SID, TWILIO_API_KEY, and
+TWILIO_API_SECRET and run this command again.`)
+
+	if accountSID == "" {
+		fmt.Print("Account SID: ")
+		line, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		accountSID = strings.TrimSpace(line)
+	}
+	token, err := util.PromptForToken(ctx, "Auth token")
+	if err != nil {
+		return nil, fmt.Errorf("reading auth token: %w", err)
+	}
+	if accountSID == "" || token == "" {
+		return nil, &provider.GrantError{
+			Provider: "twilio",
+			Cause:    fmt.Errorf("account SID and auth token are required"),
+			Hint:     "Run 'moat grant twilio' and enter both",
+		}
+	}
+	return p.validateAndCreateCredential(ctx, accountSID, "", token, SourceManual)
+}
+
+// validateAndCreateCredential checks the SIDs' format, validates the
+// credentials against the API, and creates a credential.
+func (p *Provider) validateAndCreateCredential(ctx context.Context, accountSID, keySID, secret, source string) (*provider.Credential, error) {
+	if err := util.ValidateTokenPrefix(accountSID, "AC", "Account SID"); err != nil {
+		return nil, &provider.GrantError{
+			Provider: "twilio",
+			Cause:    err,
+			Hint:     "The Account SID starts with AC; find it at https://console.twilio.com",
+		}
+	}
+	if keySID != "" {
+		if err := util.ValidateTokenPrefix(keySID, "SK", "API key SID"); err != nil {
+			return nil, &provider.GrantError{
+				Provider: "twilio",
+				Cause:    err,
+				Hint:     "Set TWILIO_API_KEY to the key's SID (SK...), not its friendly name",
+			}
+		}
+	}
+
+	user := accountSID
+	if keySID != "" {
+		user = keySID
+	}
+	fmt.Println("Validating credentials...")
+	friendlyName, err := fetchAccountName(ctx, accountSID, user, secret)
+	if err != nil {
+		return nil, &provider.GrantError{
+			Provider: "twilio",
+			Cause:    err,
+			Hint:     "Check the SID and secret in the Twilio console; rotated auth tokens stop working",
+		}
+	}
+	fmt.Printf("Authenticated to account %q (%s)\n", friendlyName, accountSID)
+
+	cred := &provider.Credential{
+		Provider:  "twilio",
+		Token:     secret,
+		CreatedAt: time.Now(),
+		Metadata: map[string]string{
+			provider.MetaKeyTokenSource: source,
+			MetaKeyAccountSID:           accountSID,
+		},
+	}
+	if keySID != "" {
+		cred.Metadata[MetaKeyAPIKeySID] = keySID
+	}
+	return cred, nil
+}
+
+// fetchAccountName fetches the account and returns its friendly name.
+func fetchAccountName(ctx context.Context, accountSID, user, secret string) (string, error) {
+	req, err := accountRequest(accountSID, user, secret)
+	if err != nil {
+		return "", err
+	}
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return "", fmt.Errorf("contacting Twilio: %w", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized:
+		return "", fmt.Errorf("invalid credentials (401 Unauthorized)")
+	case http.StatusNotFound:
+		return "", fmt.Errorf("account %s not found, or not reachable with this API key", accountSID)
+	default:
+		return "", fmt.Errorf("unexpected status validating credentials: %d", resp.StatusCode)
+	}
+
+	var account struct {
+		FriendlyName string `json:"friendly_name"`
+	}
+	if err := json.Unmarshal(body, &account); err != nil {
+		return "", fmt.Errorf("parsing account response: %w", err)
+	}
+	return account.FriendlyName, nil
+}
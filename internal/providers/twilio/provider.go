@@ -0,0 +1,129 @@
+package twilio
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+
+	gkproxy "github.com/majorcontext/gatekeeper/proxy"
+	"github.com/majorcontext/moat/internal/credential"
+	"github.com/majorcontext/moat/internal/provider"
+	"github.com/majorcontext/moat/internal/provider/util"
+)
+
+// Token source values stored in Credential.Metadata[provider.MetaKeyTokenSource].
+const (
+	SourceEnv    = "env"    // From TWILIO_* env vars
+	SourceManual = "manual" // Interactive prompt entry
+)
+
+// Metadata keys. The credential's Token holds the auth token, or the API key
+// secret when MetaKeyAPIKeySID is set.
+const (
+	MetaKeyAccountSID = "account_sid"
+	MetaKeyAPIKeySID  = "api_key_sid"
+)
+
+// Host is the Twilio REST API host the credentials are injected for.
+const Host = "api.twilio.com"
+
+// apiBaseURL is the base URL CheckCredential and Grant probe. A variable so
+// tests can point it at a local server.
+var apiBaseURL = "https://" + Host
+
+// Provider implements provider.CredentialProvider for Twilio.
+type Provider struct{}
+
+// Verify interface compliance at compile time.
+var (
+	_ provider.CredentialProvider = (*Provider)(nil)
+	_ provider.CredentialChecker  = (*Provider)(nil)
+	_ provider.SendingProvider    = (*Provider)(nil)
+)
+
+func init() {
+	provider.Register(&Provider{})
+	// Declare the hosts the grant is used with, for grant scopes and
+	// network suggestions.
+	gkproxy.RegisterGrantHosts("twilio", []string{Host})
+}
+
+// Name returns the provider identifier.
+func (p *Provider) Name() string {
+	return "twilio"
+}
+
+// username returns the Basic auth username for cred: the API key SID if the
+// grant holds an API key, otherwise the Account SID.
+func username(cred *provider.Credential) string {
+	if sid := cred.Metadata[MetaKeyAPIKeySID]; sid != "" {
+		return sid
+	}
+	return cred.Metadata[MetaKeyAccountSID]
+}
+
+// basicAuth returns the Authorization header value for user and secret.
+func basicAuth(user, secret string) string {
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+secret))
+}
+
+// ConfigureProxy injects Basic auth for api.twilio.com.
+func (p *Provider) ConfigureProxy(proxy provider.ProxyConfigurer, cred *provider.Credential) {
+	proxy.SetCredentialWithGrant(Host, "Authorization", basicAuth(username(cred), cred.Token), "twilio")
+}
+
+// ContainerEnv sets the account's SIDs and a placeholder secret. Twilio
+// SDKs read these to build their own Basic auth header, which the proxy
+// replaces.
+func (p *Provider) ContainerEnv(cred *provider.Credential) []string {
+	env := []string{"TWILIO_ACCOUNT_SID=" + cred.Metadata[MetaKeyAccountSID]}
+	if sid := cred.Metadata[MetaKeyAPIKeySID]; sid != "" {
+		return append(env,
+			"TWILIO_API_KEY="+sid,
+			"TWILIO_API_SECRET="+credential.ProxyInjectedPlaceholder,
+		)
+	}
+	return append(env, "TWILIO_AUTH_TOKEN="+credential.ProxyInjectedPlaceholder)
+}
+
+// ContainerMounts returns no mounts.
+func (p *Provider) ContainerMounts(cred *provider.Credential, containerHome string) ([]provider.MountConfig, string, error) {
+	return nil, "", nil
+}
+
+// Cleanup is a no-op.
+func (p *Provider) Cleanup(cleanupPath string) {}
+
+// ImpliedDependencies returns dependencies implied by this provider.
+func (p *Provider) ImpliedDependencies() []string {
+	return nil
+}
+
+// SendRoutes returns the requests that send an SMS, MMS, or WhatsApp
+// message, or place a call.
+func (p *Provider) SendRoutes() []provider.SendRoute {
+	return []provider.SendRoute{
+		{Host: Host, Method: "POST", Path: "/2010-04-01/Accounts/*/Messages.json"},
+		{Host: Host, Method: "POST", Path: "/2010-04-01/Accounts/*/Calls.json"},
+	}
+}
+
+// CheckCredential verifies the credentials by fetching the account.
+func (p *Provider) CheckCredential(ctx context.Context, cred *provider.Credential) error {
+	req, err := accountRequest(cred.Metadata[MetaKeyAccountSID], username(cred), cred.Token)
+	if err != nil {
+		return err
+	}
+	return util.ProbeCredential(ctx, req)
+}
+
+// accountRequest builds a GET for the account resource of accountSID.
+func accountRequest(accountSID, user, secret string) (*http.Request, error) {
+	req, err := http.NewRequest("GET", apiBaseURL+"/2010-04-01/Accounts/"+accountSID+".json", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", basicAuth(user, secret))
+	req.Header.Set("User-Agent", "moat")
+	return req, nil
+}
@@ -0,0 +1,148 @@
+package twilio
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/majorcontext/moat/internal/netrules"
+	"github.com/majorcontext/moat/internal/provider"
+)
+
+type mockProxyConfigurer struct {
+	credentials map[string]string
+}
+
+func (m *mockProxyConfigurer) SetCredential(host, value string)                         {}
+func (m *mockProxyConfigurer) SetCredentialHeader(host, headerName, headerValue string) {}
+func (m *mockProxyConfigurer) SetCredentialWithGrant(host, headerName, headerValue, grant string) {
+	m.credentials[host] = headerName + ": " + headerValue
+}
+func (m *mockProxyConfigurer) AddExtraHeader(host, headerName, headerValue string)                {}
+func (m *mockProxyConfigurer) AddResponseTransformer(host string, t provider.ResponseTransformer) {}
+func (m *mockProxyConfigurer) RemoveRequestHeader(host, header string)                            {}
+func (m *mockProxyConfigurer) SetTokenSubstitution(host, placeholder, realToken string)           {}
+
+func basic(user, secret string) string {
+	return "Authorization: Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+secret))
+}
+
+func TestProvider_ConfigureProxy(t *testing.T) {
+	p := &Provider{}
+
+	proxy := &mockProxyConfigurer{credentials: map[string]string{}}
+	p.ConfigureProxy(proxy, &provider.Credential{
+		Token:    "auth-token",
+		Metadata: map[string]string{MetaKeyAccountSID: "AC123"},
+	})
+	if got, want := proxy.credentials[Host], basic("AC123", "auth-token"); got != want {
+		t.Errorf("credential = %q, want %q", got, want)
+	}
+
+	proxy = &mockProxyConfigurer{credentials: map[string]string{}}
+	p.ConfigureProxy(proxy, &provider.Credential{
+		Token:    "key-secret",
+		Metadata: map[string]string{MetaKeyAccountSID: "AC123", MetaKeyAPIKeySID: "SK456"},
+	})
+	if got, want := proxy.credentials[Host], basic("SK456", "key-secret"); got != want {
+		t.Errorf("API key credential = %q, want %q", got, want)
+	}
+}
+
+func TestProvider_ContainerEnv(t *testing.T) {
+	p := &Provider{}
+
+	env := p.ContainerEnv(&provider.Credential{
+		Token:    "auth-token",
+		Metadata: map[string]string{MetaKeyAccountSID: "AC123"},
+	})
+	want := []string{"TWILIO_ACCOUNT_SID=AC123", "TWILIO_AUTH_TOKEN=moat-proxy-injected"}
+	if !slices.Equal(env, want) {
+		t.Errorf("ContainerEnv = %v, want %v", env, want)
+	}
+
+	env = p.ContainerEnv(&provider.Credential{
+		Token:    "key-secret",
+		Metadata: map[string]string{MetaKeyAccountSID: "AC123", MetaKeyAPIKeySID: "SK456"},
+	})
+	want = []string{"TWILIO_ACCOUNT_SID=AC123", "TWILIO_API_KEY=SK456", "TWILIO_API_SECRET=moat-proxy-injected"}
+	if !slices.Equal(env, want) {
+		t.Errorf("API key ContainerEnv = %v, want %v", env, want)
+	}
+}
+
+func TestProvider_SendRoutes(t *testing.T) {
+	routes := (&Provider{}).SendRoutes()
+	matches := func(method, path string) bool {
+		for _, r := range routes {
+			if r.Host == Host && r.Method == method && netrules.MatchPath(r.Path, path) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, tt := range []struct {
+		method, path string
+		want         bool
+	}{
+		{"POST", "/2010-04-01/Accounts/AC123/Messages.json", true},
+		{"POST", "/2010-04-01/Accounts/AC123/Calls.json", true},
+		{"GET", "/2010-04-01/Accounts/AC123/Messages.json", false},
+		{"POST", "/2010-04-01/Accounts/AC123/Messages/SM1.json", false},
+		{"POST", "/2010-04-01/Accounts/AC123/IncomingPhoneNumbers.json", false},
+	} {
+		if got := matches(tt.method, tt.path); got != tt.want {
+			t.Errorf("%s %s counted as send = %v, want %v", tt.method, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestValidateAndCreateCredential(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, _ := r.BasicAuth()
+		switch {
+		case r.URL.Path != "/2010-04-01/Accounts/AC123.json":
+			w.WriteHeader(http.StatusNotFound)
+		case (user == "AC123" && pass == "auth-token") || (user == "SK456" && pass == "key-secret"):
+			w.Write([]byte(`{"sid":"AC123","friendly_name":"Acme"}`))
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer srv.Close()
+	orig := apiBaseURL
+	apiBaseURL = srv.URL
+	defer func() { apiBaseURL = orig }()
+
+	p := &Provider{}
+	ctx := context.Background()
+
+	cred, err := p.validateAndCreateCredential(ctx, "AC123", "", "auth-token", SourceEnv)
+	if err != nil {
+		t.Fatalf("auth token: %v", err)
+	}
+	if cred.Metadata[MetaKeyAccountSID] != "AC123" || cred.Metadata[MetaKeyAPIKeySID] != "" {
+		t.Errorf("Metadata = %v", cred.Metadata)
+	}
+
+	cred, err = p.validateAndCreateCredential(ctx, "AC123", "SK456", "key-secret", SourceEnv)
+	if err != nil {
+		t.Fatalf("API key: %v", err)
+	}
+	if cred.Metadata[MetaKeyAPIKeySID] != "SK456" || cred.Token != "key-secret" {
+		t.Errorf("API key credential = %+v", cred)
+	}
+
+	if _, err := p.validateAndCreateCredential(ctx, "AC123", "", "wrong", SourceEnv); err == nil {
+		t.Error("wrong auth token accepted")
+	}
+	if _, err := p.validateAndCreateCredential(ctx, "123", "", "auth-token", SourceEnv); err == nil {
+		t.Error("Account SID without AC prefix accepted")
+	}
+	if _, err := p.validateAndCreateCredential(ctx, "AC123", "my-key", "key-secret", SourceEnv); err == nil {
+		t.Error("API key SID without SK prefix accepted")
+	}
+}
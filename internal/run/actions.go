@@ -0,0 +1,308 @@
+package run
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/majorcontext/moat/internal/audit"
+	"github.com/majorcontext/moat/internal/storage"
+)
+
+// Action kinds.
+const (
+	ActionCommand = "command" // a process the agent ran, or a `moat exec` command
+	ActionAPI     = "api"     // a request through the proxy
+)
+
+// Action is one entry in a run's action log: a command or an API request,
+// in the order they happened.
+type Action struct {
+	Time time.Time `json:"time"`
+	Kind string    `json:"kind"`
+
+	// Command fields.
+	Command    []string `json:"command,omitempty"`
+	Dir        string   `json:"dir,omitempty"`
+	ExitCode   *int     `json:"exit_code,omitempty"`
+	DurationMS int64    `json:"duration_ms,omitempty"`
+	Source     string   `json:"source,omitempty"` // "trace" or "moat exec"
+	Files      []string `json:"files,omitempty"`  // paths the command writes, from its arguments
+
+	// API request fields.
+	Method string   `json:"method,omitempty"`
+	Host   string   `json:"host,omitempty"`
+	Path   string   `json:"path,omitempty"`
+	Status int      `json:"status,omitempty"`
+	Grants []string `json:"grants,omitempty"`
+	Denied bool     `json:"denied,omitempty"`
+	Reason string   `json:"reason,omitempty"` // deny reason
+	Error  string   `json:"error,omitempty"`
+}
+
+// ActionSummary totals a run's action log.
+type ActionSummary struct {
+	Commands       int      `json:"commands"`
+	FailedCommands int      `json:"failed_commands"`
+	APIRequests    int      `json:"api_requests"`
+	DeniedRequests int      `json:"denied_requests"`
+	Hosts          []string `json:"hosts,omitempty"`
+	Files          []string `json:"files,omitempty"`
+}
+
+// ActionLog builds a run's action log from its exec trace, the commands run
+// with `moat exec`, and the proxy's request decisions. Runs recorded before
+// decisions were logged fall back to the network log.
+func ActionLog(store *storage.RunStore) ([]Action, error) {
+	var actions []Action
+
+	events, err := store.ReadExecEvents()
+	if err != nil {
+		return nil, fmt.Errorf("reading exec trace: %w", err)
+	}
+	for _, e := range events {
+		a := Action{
+			Time:     e.Timestamp,
+			Kind:     ActionCommand,
+			Command:  append([]string{e.Command}, e.Args...),
+			Dir:      e.WorkingDir,
+			ExitCode: e.ExitCode,
+			Source:   "trace",
+		}
+		if e.Duration != nil {
+			a.DurationMS = e.Duration.Milliseconds()
+		}
+		a.Files = filesWritten(a.Command, a.Dir)
+		actions = append(actions, a)
+	}
+
+	execs, err := auditExecActions(filepath.Join(store.Dir(), "audit.db"))
+	if err != nil {
+		return nil, err
+	}
+	actions = append(actions, execs...)
+
+	decisions, err := store.ReadDecisions()
+	if err != nil {
+		return nil, fmt.Errorf("reading decisions: %w", err)
+	}
+	for _, d := range decisions {
+		actions = append(actions, Action{
+			Time:   d.Timestamp,
+			Kind:   ActionAPI,
+			Method: d.Method,
+			Host:   d.Host,
+			Path:   d.Path,
+			Status: d.StatusCode,
+			Grants: d.Grants,
+			Denied: d.Decision == "deny",
+			Reason: denyReason(d),
+			Error:  d.Error,
+		})
+	}
+	if len(decisions) == 0 {
+		reqs, err := store.ReadNetworkRequests()
+		if err != nil {
+			return nil, fmt.Errorf("reading network requests: %w", err)
+		}
+		for _, r := range reqs {
+			a := Action{
+				Time:   r.Timestamp,
+				Kind:   ActionAPI,
+				Method: r.Method,
+				Status: r.StatusCode,
+				Denied: r.Denied,
+				Reason: r.DenyReason,
+				Error:  r.Error,
+			}
+			if u, err := url.Parse(r.URL); err == nil {
+				a.Host, a.Path = u.Hostname(), u.Path
+			} else {
+				a.Host = r.URL
+			}
+			actions = append(actions, a)
+		}
+	}
+
+	sort.SliceStable(actions, func(i, j int) bool { return actions[i].Time.Before(actions[j].Time) })
+	return actions, nil
+}
+
+func denyReason(d storage.Decision) string {
+	if d.Decision != "deny" {
+		return ""
+	}
+	return d.Reason
+}
+
+// auditExecActions returns the `moat exec` commands recorded in the run's
+// audit log. Runs without an audit log have none.
+func auditExecActions(dbPath string) ([]Action, error) {
+	if _, err := os.Stat(dbPath); err != nil {
+		return nil, nil
+	}
+	store, err := audit.OpenStore(dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("opening audit log: %w", err)
+	}
+	defer store.Close()
+
+	count, err := store.Count()
+	if err != nil || count == 0 {
+		return nil, err
+	}
+	entries, err := store.Range(1, count)
+	if err != nil {
+		return nil, fmt.Errorf("reading audit log: %w", err)
+	}
+
+	var actions []Action
+	for _, e := range entries {
+		if e.Type != audit.EntryExec {
+			continue
+		}
+		// Entries read back from the database hold their data as a map.
+		raw, err := json.Marshal(e.Data)
+		if err != nil {
+			continue
+		}
+		var data audit.ExecData
+		if json.Unmarshal(raw, &data) != nil || len(data.Command) == 0 {
+			continue
+		}
+		exitCode := data.ExitCode
+		actions = append(actions, Action{
+			Time:     e.Timestamp,
+			Kind:     ActionCommand,
+			Command:  data.Command,
+			ExitCode: &exitCode,
+			Source:   "moat exec",
+			Files:    filesWritten(data.Command, ""),
+		})
+	}
+	return actions, nil
+}
+
+// SummarizeActions totals actions. Hosts and files are sorted and
+// deduplicated.
+func SummarizeActions(actions []Action) ActionSummary {
+	var s ActionSummary
+	hosts := map[string]bool{}
+	files := map[string]bool{}
+	for _, a := range actions {
+		switch a.Kind {
+		case ActionCommand:
+			s.Commands++
+			if a.ExitCode != nil && *a.ExitCode != 0 {
+				s.FailedCommands++
+			}
+			for _, f := range a.Files {
+				files[f] = true
+			}
+		case ActionAPI:
+			s.APIRequests++
+			if a.Denied {
+				s.DeniedRequests++
+			}
+			if a.Host != "" {
+				hosts[a.Host] = true
+			}
+		}
+	}
+	s.Hosts = sortedKeys(hosts)
+	s.Files = sortedKeys(files)
+	return s
+}
+
+func sortedKeys(m map[string]bool) []string {
+	if len(m) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// filesWritten returns the paths a command creates, modifies, or removes,
+// judged from its arguments for common file commands. Relative paths are
+// resolved against dir when it is known. Writes a command makes on its own
+// (a compiler's output, shell redirection) are not visible here.
+func filesWritten(argv []string, dir string) []string {
+	if len(argv) == 0 {
+		return nil
+	}
+	name := path.Base(argv[0])
+	args := operands(argv[1:])
+
+	var paths []string
+	switch name {
+	case "touch", "rm", "rmdir", "mkdir", "tee", "mv", "unlink":
+		paths = args
+	case "cp", "ln", "install":
+		if len(args) > 1 {
+			paths = args[len(args)-1:]
+		}
+	case "chmod", "chown", "chgrp":
+		if len(args) > 1 {
+			paths = args[1:]
+		}
+	case "sed", "perl":
+		if hasInPlaceFlag(argv[1:]) && len(args) > 1 {
+			paths = args[1:]
+		}
+	case "git":
+		if len(args) > 1 {
+			switch args[0] {
+			case "add", "rm", "mv", "restore":
+				paths = args[1:]
+			}
+		}
+	}
+
+	var files []string
+	for _, p := range paths {
+		if p == "" || p == "-" || (name == "git" && p == ".") {
+			continue
+		}
+		if dir != "" && !path.IsAbs(p) {
+			p = path.Join(dir, p)
+		}
+		files = append(files, path.Clean(p))
+	}
+	return files
+}
+
+// operands returns the non-flag arguments. Everything after "--" is an
+// operand.
+func operands(args []string) []string {
+	var out []string
+	for i, a := range args {
+		if a == "--" {
+			return append(out, args[i+1:]...)
+		}
+		if strings.HasPrefix(a, "-") && a != "-" {
+			continue
+		}
+		out = append(out, a)
+	}
+	return out
+}
+
+func hasInPlaceFlag(args []string) bool {
+	for _, a := range args {
+		// -i, -i.bak, and clusters such as perl's -pi.
+		if strings.HasPrefix(a, "--in-place") || (len(a) > 1 && a[0] == '-' && a[1] != '-' && strings.ContainsRune(a, 'i')) {
+			return true
+		}
+	}
+	return false
+}
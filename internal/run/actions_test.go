@@ -0,0 +1,130 @@
+package run
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/majorcontext/moat/internal/audit"
+	"github.com/majorcontext/moat/internal/storage"
+)
+
+func TestActionLog(t *testing.T) {
+	store, err := storage.NewRunStore(t.TempDir(), "run_actions1234")
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := time.Now().UTC().Add(-time.Hour)
+	zero, one := 0, 1
+	second := time.Second
+
+	if err := store.WriteExecEvent(storage.ExecEvent{
+		Timestamp: base.Add(3 * time.Second), Command: "go", Args: []string{"test", "./..."},
+		WorkingDir: "/workspace", ExitCode: &one, Duration: &second,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.WriteExecEvent(storage.ExecEvent{
+		Timestamp: base, Command: "sed", Args: []string{"-i", "s/a/b/", "main.go"},
+		WorkingDir: "/workspace", ExitCode: &zero,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range []storage.Decision{
+		{Timestamp: base.Add(time.Second), Method: "POST", Host: "api.anthropic.com", Path: "/v1/messages", Decision: "allow", Reason: "allowed", StatusCode: 200, Grants: []string{"anthropic"}},
+		{Timestamp: base.Add(2 * time.Second), Method: "GET", Host: "evil.example.com", Path: "/", Decision: "deny", Reason: "Host not in allow list"},
+	} {
+		if err := store.WriteDecision(d); err != nil {
+			t.Fatal(err)
+		}
+	}
+	auditStore, err := audit.OpenStore(filepath.Join(store.Dir(), "audit.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := auditStore.AppendExec(audit.ExecData{Command: []string{"git", "add", "notes.md"}}); err != nil {
+		t.Fatal(err)
+	}
+	auditStore.Close()
+
+	actions, err := ActionLog(store)
+	if err != nil {
+		t.Fatalf("ActionLog: %v", err)
+	}
+	var kinds []string
+	for _, a := range actions {
+		kinds = append(kinds, a.Kind)
+	}
+	// The audit entry was written now, after every other fixture timestamp.
+	want := []string{ActionCommand, ActionAPI, ActionAPI, ActionCommand, ActionCommand}
+	if !reflect.DeepEqual(kinds, want) {
+		t.Fatalf("kinds = %v, want %v", kinds, want)
+	}
+	if got := actions[0].Files; !reflect.DeepEqual(got, []string{"/workspace/main.go"}) {
+		t.Errorf("sed files = %v, want [/workspace/main.go]", got)
+	}
+	if !actions[2].Denied || actions[2].Reason != "Host not in allow list" {
+		t.Errorf("denied request = %+v, want Denied with its reason", actions[2])
+	}
+	if actions[1].Reason != "" {
+		t.Errorf("allowed request reason = %q, want empty", actions[1].Reason)
+	}
+	if last := actions[4]; last.Source != "moat exec" || !reflect.DeepEqual(last.Files, []string{"notes.md"}) {
+		t.Errorf("moat exec action = %+v, want source moat exec and files [notes.md]", last)
+	}
+
+	s := SummarizeActions(actions)
+	if s.Commands != 3 || s.FailedCommands != 1 || s.APIRequests != 2 || s.DeniedRequests != 1 {
+		t.Errorf("summary counts = %+v", s)
+	}
+	if !reflect.DeepEqual(s.Hosts, []string{"api.anthropic.com", "evil.example.com"}) {
+		t.Errorf("summary hosts = %v", s.Hosts)
+	}
+	if !reflect.DeepEqual(s.Files, []string{"/workspace/main.go", "notes.md"}) {
+		t.Errorf("summary files = %v", s.Files)
+	}
+}
+
+func TestActionLogFallsBackToNetworkLog(t *testing.T) {
+	store, err := storage.NewRunStore(t.TempDir(), "run_actions5678")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.WriteNetworkRequest(storage.NetworkRequest{
+		Timestamp: time.Now(), Method: "GET", URL: "https://api.github.com/user", StatusCode: 200,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	actions, err := ActionLog(store)
+	if err != nil {
+		t.Fatalf("ActionLog: %v", err)
+	}
+	if len(actions) != 1 || actions[0].Host != "api.github.com" || actions[0].Path != "/user" {
+		t.Errorf("actions = %+v, want one request to api.github.com/user", actions)
+	}
+}
+
+func TestFilesWritten(t *testing.T) {
+	tests := []struct {
+		argv []string
+		dir  string
+		want []string
+	}{
+		{[]string{"touch", "a", "b"}, "/w", []string{"/w/a", "/w/b"}},
+		{[]string{"/bin/rm", "-rf", "build"}, "/w", []string{"/w/build"}},
+		{[]string{"cp", "-r", "src", "/tmp/dst"}, "/w", []string{"/tmp/dst"}},
+		{[]string{"chmod", "+x", "run.sh"}, "", []string{"run.sh"}},
+		{[]string{"sed", "s/a/b/", "file"}, "", nil},
+		{[]string{"perl", "-pi", "-e", "s/a/b/", "file"}, "", []string{"file"}},
+		{[]string{"git", "add", "."}, "/w", nil},
+		{[]string{"git", "status"}, "/w", nil},
+		{[]string{"rm", "--", "-weird"}, "", []string{"-weird"}},
+		{[]string{"go", "build", "./..."}, "/w", nil},
+	}
+	for _, tt := range tests {
+		if got := filesWritten(tt.argv, tt.dir); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("filesWritten(%q, %q) = %v, want %v", tt.argv, tt.dir, got, tt.want)
+		}
+	}
+}
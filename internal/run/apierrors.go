@@ -0,0 +1,121 @@
+package run
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/majorcontext/moat/internal/storage"
+)
+
+// APIErrorSummary aggregates the error responses LLM provider APIs returned
+// to a run, so a run that looks stuck can be told apart from one the API is
+// throttling. Built from the run's network log by SummarizeAPIErrors.
+type APIErrorSummary struct {
+	Requests      int       `json:"requests"`       // LLM API requests logged
+	RateLimited   int       `json:"rate_limited"`   // 429 without a quota marker
+	Overloaded    int       `json:"overloaded"`     // 529, or 503 reporting overload
+	ContextLength int       `json:"context_length"` // prompt exceeded the model's context window
+	Quota         int       `json:"quota"`          // exhausted credits or quota (see isBudgetError)
+	ServerErrors  int       `json:"server_errors"`  // other 5xx
+	LastError     time.Time `json:"last_error,omitzero"`
+}
+
+// Errors returns the total number of classified error responses.
+func (s APIErrorSummary) Errors() int {
+	return s.RateLimited + s.Overloaded + s.ContextLength + s.Quota + s.ServerErrors
+}
+
+// String renders the non-zero counts, e.g. "12 rate limited, 2 overloaded".
+func (s APIErrorSummary) String() string {
+	var parts []string
+	add := func(n int, label string) {
+		if n > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", n, label))
+		}
+	}
+	add(s.RateLimited, "rate limited")
+	add(s.Overloaded, "overloaded")
+	add(s.ContextLength, "context length")
+	add(s.Quota, "quota exhausted")
+	add(s.ServerErrors, "server error")
+	return strings.Join(parts, ", ")
+}
+
+// contextLengthMarkers are substrings of provider error bodies for prompts
+// that exceed the model's context window.
+var contextLengthMarkers = []string{
+	"prompt is too long",                   // Anthropic
+	"context_length_exceeded",              // OpenAI
+	"exceeds the maximum number of tokens", // Gemini
+}
+
+// overloadedMarkers are substrings of provider error bodies for capacity
+// errors that some providers return with a plain 5xx status.
+var overloadedMarkers = []string{
+	"overloaded_error",     // Anthropic
+	"model is overloaded",  // Gemini
+	"engine is overloaded", // OpenAI
+}
+
+// SummarizeAPIErrors counts error responses from LLM provider APIs in a run's
+// network log. Requests the proxy denied never reached the provider and are
+// not counted.
+func SummarizeAPIErrors(reqs []storage.NetworkRequest) APIErrorSummary {
+	var s APIErrorSummary
+	for _, req := range reqs {
+		if req.Denied {
+			continue
+		}
+		u, err := url.Parse(req.URL)
+		if err != nil || !llmAPIHosts[u.Hostname()] {
+			continue
+		}
+		s.Requests++
+
+		counted := true
+		switch {
+		case isBudgetError(req):
+			s.Quota++
+		case req.StatusCode == 429:
+			s.RateLimited++
+		case req.StatusCode == 529 || req.StatusCode >= 500 && containsAny(req.ResponseBody, overloadedMarkers):
+			s.Overloaded++
+		case (req.StatusCode == 400 || req.StatusCode == 413) && containsAny(req.ResponseBody, contextLengthMarkers):
+			s.ContextLength++
+		case req.StatusCode >= 500:
+			s.ServerErrors++
+		default:
+			counted = false
+		}
+		if counted && req.Timestamp.After(s.LastError) {
+			s.LastError = req.Timestamp
+		}
+	}
+	return s
+}
+
+func containsAny(s string, markers []string) bool {
+	for _, m := range markers {
+		if strings.Contains(s, m) {
+			return true
+		}
+	}
+	return false
+}
+
+// APIErrorHint explains what a run's API errors mean for the user, or ""
+// when there is nothing actionable.
+func APIErrorHint(s APIErrorSummary) string {
+	throttled := s.RateLimited + s.Overloaded
+	switch {
+	case throttled > 0 && 2*throttled >= s.Errors():
+		return "The provider API was throttling this run (rate limits or overload). Slow progress is the API, not the agent;\n" +
+			"agents retry with backoff. Check your plan's rate limits if this persists."
+	case s.ContextLength > 0:
+		return "Some requests exceeded the model's context window. The agent may be looping on a large file or a\n" +
+			"long conversation; start a fresh session or narrow the task."
+	}
+	return ""
+}
@@ -0,0 +1,70 @@
+package run
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/majorcontext/moat/internal/storage"
+)
+
+func TestSummarizeAPIErrors(t *testing.T) {
+	t0 := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	reqs := []storage.NetworkRequest{
+		{URL: "https://api.anthropic.com/v1/messages", StatusCode: 200, Timestamp: t0},
+		{URL: "https://api.anthropic.com/v1/messages", StatusCode: 429, ResponseBody: `{"error":{"type":"rate_limit_error"}}`, Timestamp: t0.Add(time.Second)},
+		{URL: "https://api.anthropic.com/v1/messages", StatusCode: 529, ResponseBody: `{"error":{"type":"overloaded_error"}}`, Timestamp: t0.Add(2 * time.Second)},
+		{URL: "https://generativelanguage.googleapis.com/v1beta/models/x:generate", StatusCode: 503, ResponseBody: "The model is overloaded.", Timestamp: t0.Add(3 * time.Second)},
+		{URL: "https://api.openai.com/v1/responses", StatusCode: 400, ResponseBody: `{"error":{"code":"context_length_exceeded"}}`, Timestamp: t0.Add(4 * time.Second)},
+		{URL: "https://api.openai.com/v1/responses", StatusCode: 429, ResponseBody: `{"error":{"code":"insufficient_quota"}}`, Timestamp: t0.Add(5 * time.Second)},
+		{URL: "https://api.openai.com/v1/responses", StatusCode: 502, Timestamp: t0.Add(6 * time.Second)},
+		{URL: "https://api.openai.com/v1/responses", StatusCode: 400, ResponseBody: `{"error":{"code":"invalid_request"}}`, Timestamp: t0.Add(7 * time.Second)},
+		// Not LLM APIs, or never reached the provider.
+		{URL: "https://api.github.com/user", StatusCode: 429, Timestamp: t0.Add(8 * time.Second)},
+		{URL: "https://api.anthropic.com/v1/messages", StatusCode: 407, Denied: true, Timestamp: t0.Add(9 * time.Second)},
+	}
+
+	s := SummarizeAPIErrors(reqs)
+	want := APIErrorSummary{
+		Requests:      8,
+		RateLimited:   1,
+		Overloaded:    2,
+		ContextLength: 1,
+		Quota:         1,
+		ServerErrors:  1,
+		LastError:     t0.Add(6 * time.Second),
+	}
+	if s != want {
+		t.Errorf("SummarizeAPIErrors() = %+v\nwant %+v", s, want)
+	}
+	if s.Errors() != 6 {
+		t.Errorf("Errors() = %d, want 6", s.Errors())
+	}
+	if got := s.String(); got != "1 rate limited, 2 overloaded, 1 context length, 1 quota exhausted, 1 server error" {
+		t.Errorf("String() = %q", got)
+	}
+}
+
+func TestSummarizeAPIErrorsClean(t *testing.T) {
+	s := SummarizeAPIErrors([]storage.NetworkRequest{
+		{URL: "https://api.anthropic.com/v1/messages", StatusCode: 200},
+	})
+	if s.Errors() != 0 || s.String() != "" || !s.LastError.IsZero() {
+		t.Errorf("clean run: %+v", s)
+	}
+	if hint := APIErrorHint(s); hint != "" {
+		t.Errorf("clean run hint = %q, want empty", hint)
+	}
+}
+
+func TestAPIErrorHint(t *testing.T) {
+	if hint := APIErrorHint(APIErrorSummary{RateLimited: 5, ServerErrors: 1}); !strings.Contains(hint, "throttling") {
+		t.Errorf("rate-limited hint = %q", hint)
+	}
+	if hint := APIErrorHint(APIErrorSummary{ContextLength: 2}); !strings.Contains(hint, "context window") {
+		t.Errorf("context-length hint = %q", hint)
+	}
+	if hint := APIErrorHint(APIErrorSummary{ServerErrors: 4}); hint != "" {
+		t.Errorf("server-error-only hint = %q, want empty", hint)
+	}
+}
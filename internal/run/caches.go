@@ -0,0 +1,95 @@
+package run
+
+import (
+	"github.com/majorcontext/moat/internal/config"
+	"github.com/majorcontext/moat/internal/container"
+	"github.com/majorcontext/moat/internal/deps"
+)
+
+// packageCache describes where a package manager keeps its download cache
+// inside the container. Env, when set, points the tool at Target so the mount
+// works regardless of the container user's home directory.
+type packageCache struct {
+	Target string
+	Env    string
+}
+
+// packageCaches maps each cache name to its container-side location. Cargo has
+// no env var for the registry alone (CARGO_HOME also holds installed binaries),
+// so it mounts directly over the registry directory under the moatuser home
+// configured by the rust install step.
+var packageCaches = map[string]packageCache{
+	config.CacheNpm:   {Target: "/var/cache/moat/npm", Env: "npm_config_cache"},
+	config.CacheGo:    {Target: "/var/cache/moat/go-mod", Env: "GOMODCACHE"},
+	config.CachePip:   {Target: "/var/cache/moat/pip", Env: "PIP_CACHE_DIR"},
+	config.CacheCargo: {Target: "/home/moatuser/.cargo/registry"},
+}
+
+// cacheForDependency returns the cache name a dependency populates, or "" if
+// it does not use one of the supported package managers.
+func cacheForDependency(dep deps.Dependency) string {
+	switch dep.Type {
+	case deps.TypeDynamicNpm:
+		return config.CacheNpm
+	case deps.TypeDynamicGo:
+		return config.CacheGo
+	case deps.TypeDynamicPip:
+		return config.CachePip
+	case deps.TypeDynamicCargo:
+		return config.CacheCargo
+	}
+	switch dep.Name {
+	case "node", "npm":
+		return config.CacheNpm
+	case "go":
+		return config.CacheGo
+	case "python":
+		return config.CachePip
+	case "rust":
+		return config.CacheCargo
+	}
+	if spec, ok := deps.GetSpec(dep.Name); ok {
+		switch spec.Type {
+		case deps.TypeNpm:
+			return config.CacheNpm
+		case deps.TypeGoInstall:
+			return config.CacheGo
+		}
+	}
+	return ""
+}
+
+// packageCacheMounts returns the host cache mounts and env vars for the
+// package managers implied by depList. Each cache appears at most once and
+// follows config.CacheNames order so the mount list is deterministic.
+//
+// This function is pure (no filesystem side effects); the caller creates the
+// host directories before mounting.
+func packageCacheMounts(cfg config.CachesConfig, depList []deps.Dependency) ([]container.MountConfig, []string) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	wanted := make(map[string]bool)
+	for _, dep := range depList {
+		if name := cacheForDependency(dep); name != "" {
+			wanted[name] = true
+		}
+	}
+
+	var mounts []container.MountConfig
+	var env []string
+	for _, name := range config.CacheNames {
+		if !wanted[name] || cfg.Excludes(name) {
+			continue
+		}
+		pc := packageCaches[name]
+		mounts = append(mounts, container.MountConfig{
+			Source: config.CacheDir(name),
+			Target: pc.Target,
+		})
+		if pc.Env != "" {
+			env = append(env, pc.Env+"="+pc.Target)
+		}
+	}
+	return mounts, env
+}
@@ -0,0 +1,77 @@
+package run
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/majorcontext/moat/internal/config"
+	"github.com/majorcontext/moat/internal/deps"
+)
+
+func TestCacheForDependency(t *testing.T) {
+	tests := []struct {
+		dep  deps.Dependency
+		want string
+	}{
+		{deps.Dependency{Name: "node"}, config.CacheNpm},
+		{deps.Dependency{Name: "typescript"}, config.CacheNpm},
+		{deps.Dependency{Name: "eslint", Type: deps.TypeDynamicNpm, Package: "eslint"}, config.CacheNpm},
+		{deps.Dependency{Name: "go"}, config.CacheGo},
+		{deps.Dependency{Name: "gopls"}, config.CacheGo},
+		{deps.Dependency{Name: "python"}, config.CachePip},
+		{deps.Dependency{Name: "ruff", Type: deps.TypeDynamicPip, Package: "ruff"}, config.CachePip},
+		{deps.Dependency{Name: "rust"}, config.CacheCargo},
+		{deps.Dependency{Name: "git"}, ""},
+		{deps.Dependency{Name: "postgres"}, ""},
+	}
+	for _, tt := range tests {
+		if got := cacheForDependency(tt.dep); got != tt.want {
+			t.Errorf("cacheForDependency(%+v) = %q, want %q", tt.dep, got, tt.want)
+		}
+	}
+}
+
+func TestPackageCacheMounts(t *testing.T) {
+	depList := []deps.Dependency{{Name: "python"}, {Name: "node"}, {Name: "typescript"}, {Name: "git"}}
+
+	mounts, env := packageCacheMounts(config.CachesConfig{Enabled: true}, depList)
+	if len(mounts) != 2 {
+		t.Fatalf("got %d mounts, want 2 (npm, pip): %+v", len(mounts), mounts)
+	}
+	// Order follows config.CacheNames, not dependency order, and npm is deduped.
+	if mounts[0].Source != config.CacheDir("npm") || mounts[0].Target != "/var/cache/moat/npm" {
+		t.Errorf("mounts[0] = %+v, want npm cache", mounts[0])
+	}
+	if mounts[1].Source != config.CacheDir("pip") || mounts[1].Target != "/var/cache/moat/pip" {
+		t.Errorf("mounts[1] = %+v, want pip cache", mounts[1])
+	}
+	for _, m := range mounts {
+		if m.ReadOnly || m.Volume {
+			t.Errorf("cache mount %s should be a writable bind mount", m.Target)
+		}
+	}
+	wantEnv := []string{"npm_config_cache=/var/cache/moat/npm", "PIP_CACHE_DIR=/var/cache/moat/pip"}
+	if !slices.Equal(env, wantEnv) {
+		t.Errorf("env = %v, want %v", env, wantEnv)
+	}
+}
+
+func TestPackageCacheMountsDisabled(t *testing.T) {
+	depList := []deps.Dependency{{Name: "node"}, {Name: "go"}}
+	mounts, env := packageCacheMounts(config.CachesConfig{}, depList)
+	if len(mounts) != 0 || len(env) != 0 {
+		t.Errorf("disabled caches should produce nothing, got mounts=%v env=%v", mounts, env)
+	}
+}
+
+func TestPackageCacheMountsExclude(t *testing.T) {
+	depList := []deps.Dependency{{Name: "node"}, {Name: "rust"}}
+	mounts, env := packageCacheMounts(config.CachesConfig{Enabled: true, Exclude: []string{"npm"}}, depList)
+	if len(mounts) != 1 || mounts[0].Target != "/home/moatuser/.cargo/registry" {
+		t.Fatalf("mounts = %+v, want only the cargo registry", mounts)
+	}
+	// Cargo has no cache env var; the excluded npm cache must not leak one.
+	if len(env) != 0 {
+		t.Errorf("env = %v, want none", env)
+	}
+}
@@ -3,18 +3,34 @@ package run
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"runtime"
 	"strconv"
+	"strings"
 	"syscall"
 
+	"github.com/majorcontext/moat/internal/config"
 	"github.com/majorcontext/moat/internal/container"
 	"github.com/majorcontext/moat/internal/deps"
+	"github.com/majorcontext/moat/internal/dockerproxy"
 	"github.com/majorcontext/moat/internal/log"
 )
 
 // DockerSocketPath is the standard path for the Docker socket on Linux.
 const DockerSocketPath = "/var/run/docker.sock"
 
+// DockerSocketKind identifies the daemon behind the socket docker:host mounts.
+type DockerSocketKind string
+
+const (
+	// DockerSocketRootful is a Docker daemon running as root.
+	DockerSocketRootful DockerSocketKind = "docker"
+	// DockerSocketRootless is a Docker daemon running as the host user.
+	DockerSocketRootless DockerSocketKind = "docker-rootless"
+	// DockerSocketPodman is Podman's Docker-compatible API socket.
+	DockerSocketPodman DockerSocketKind = "podman"
+)
+
 // DockerDependencyConfig holds the configuration needed to enable docker
 // inside a container.
 type DockerDependencyConfig struct {
@@ -32,6 +48,19 @@ type DockerDependencyConfig struct {
 	// Privileged indicates the container needs privileged mode.
 	// Only set for dind mode.
 	Privileged bool
+
+	// Socket is the kind of daemon behind SocketMount.Source.
+	// Only set for host mode.
+	Socket DockerSocketKind
+
+	// Rootless is true when the daemon behind the socket runs as an
+	// unprivileged host user. Only set for host mode.
+	Rootless bool
+
+	// Limitations lists what the agent can't do through this socket that it
+	// could through a rootful Docker daemon, for display before the run.
+	// Empty for a rootful Docker daemon.
+	Limitations []string
 }
 
 // ErrDockerHostRequiresDockerRuntime is returned when docker:host mode is used
@@ -63,6 +92,21 @@ Docker-in-Docker to start its own dockerd daemon.
 Use Docker runtime: moat run --runtime docker`
 }
 
+// ErrDockerHostRequiresDockerDaemon is returned when docker:host mode is used
+// with the Podman runtime outside Linux. On Linux docker:host mounts Podman's
+// Docker-compatible API socket instead.
+type ErrDockerHostRequiresDockerDaemon struct{}
+
+func (e ErrDockerHostRequiresDockerDaemon) Error() string {
+	return `'docker:host' dependency requires Docker runtime on macOS
+
+The Podman socket on macOS belongs to the Podman machine, not the host, so
+it cannot be shared with the container.
+Either:
+  - Use 'docker:dind' mode (runs isolated Docker daemon), or
+  - Use Docker runtime: moat run --runtime docker`
+}
+
 // HasDockerDependency checks if the dependency list includes the docker dependency.
 // Returns true if docker dependency is present, false otherwise.
 func HasDockerDependency(depList []deps.Dependency) bool {
@@ -86,30 +130,139 @@ func GetDockerDependency(depList []deps.Dependency) *deps.Dependency {
 // Both docker modes require Docker runtime:
 // - Host mode needs socket access (Apple containers cannot mount host socket)
 // - Dind mode needs privileged mode (Apple containers don't support this)
+//
+// Podman supports dind (privileged containers), and host mode on Linux,
+// where its API socket can be mounted.
 func ValidateDockerDependency(runtimeType container.RuntimeType, mode deps.DockerMode) error {
-	if runtimeType == container.RuntimeApple {
+	switch runtimeType {
+	case container.RuntimeApple:
 		if mode == deps.DockerModeDind {
 			return ErrDockerDindRequiresDockerRuntime{}
 		}
 		return ErrDockerHostRequiresDockerRuntime{}
+	case container.RuntimePodman:
+		if mode != deps.DockerModeDind && runtime.GOOS != "linux" {
+			return ErrDockerHostRequiresDockerDaemon{}
+		}
 	}
 	return nil
 }
 
+// Capability differences reported in DockerDependencyConfig.Limitations.
+const (
+	limitationRootless = "rootless daemon: containers the agent starts cannot use --privileged to reach host devices or publish ports below 1024"
+	limitationPodman   = "Podman API: docker build uses the legacy builder, and swarm and plugin commands are unavailable"
+)
+
+// hostDockerSocket is the socket docker:host mounts.
+type hostDockerSocket struct {
+	path     string
+	kind     DockerSocketKind
+	rootless bool
+}
+
+// findHostDockerSocket returns the Docker API socket of the host daemon that
+// runtimeType talks to.
+//
+// Outside Linux this is always DockerSocketPath, which Docker Desktop and
+// similar VMs expose to containers. On Linux the Podman runtime shares its
+// own API socket; the Docker runtime checks DOCKER_HOST (unix:// only), then
+// DockerSocketPath, then the rootless socket under XDG_RUNTIME_DIR or
+// /run/user/<uid>. Sockets owned by a non-root user are rootless, and
+// sockets named podman.sock (including a docker.sock symlink to one, as the
+// podman-docker package installs) are Podman.
+func findHostDockerSocket(runtimeType container.RuntimeType) (hostDockerSocket, error) {
+	if runtime.GOOS != "linux" {
+		return hostDockerSocket{path: DockerSocketPath, kind: DockerSocketRootful}, nil
+	}
+
+	var candidates []string
+	if runtimeType == container.RuntimePodman {
+		path, err := container.PodmanSocket()
+		if err != nil {
+			return hostDockerSocket{}, err
+		}
+		candidates = []string{path}
+	} else if host := os.Getenv("DOCKER_HOST"); host != "" {
+		path, ok := strings.CutPrefix(host, "unix://")
+		if !ok {
+			return hostDockerSocket{}, fmt.Errorf("DOCKER_HOST=%s is not supported by docker:host; only local unix:// sockets can be mounted", host)
+		}
+		candidates = []string{path}
+	} else {
+		candidates = rootlessDockerSocketCandidates()
+	}
+
+	for _, path := range candidates {
+		info, err := os.Stat(path)
+		if err != nil || info.Mode()&os.ModeSocket == 0 {
+			continue
+		}
+		sock := hostDockerSocket{path: path, kind: DockerSocketRootful}
+		if stat, ok := info.Sys().(*syscall.Stat_t); ok && stat.Uid != 0 {
+			sock.kind = DockerSocketRootless
+			sock.rootless = true
+		}
+		if resolved, err := filepath.EvalSymlinks(path); err == nil && filepath.Base(resolved) == "podman.sock" {
+			sock.kind = DockerSocketPodman
+		}
+		return sock, nil
+	}
+	return hostDockerSocket{}, fmt.Errorf("docker socket not found at %s", strings.Join(candidates, ", "))
+}
+
+// rootlessDockerSocketCandidates returns DockerSocketPath followed by the
+// rootless Docker socket paths for the current user.
+func rootlessDockerSocketCandidates() []string {
+	paths := []string{DockerSocketPath}
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		paths = append(paths, filepath.Join(dir, "docker.sock"))
+	}
+	if uid := os.Getuid(); uid > 0 {
+		path := filepath.Join("/run/user", strconv.Itoa(uid), "docker.sock")
+		if paths[len(paths)-1] != path {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// socketLimitations returns the capability differences of sock from a
+// rootful Docker daemon, including a socket the container user cannot open.
+func socketLimitations(sock hostDockerSocket, mode os.FileMode) []string {
+	var limits []string
+	if sock.rootless {
+		limits = append(limits, limitationRootless)
+	}
+	if sock.kind == DockerSocketPodman {
+		limits = append(limits, limitationPodman)
+	}
+	if mode&0o060 != 0o060 {
+		limits = append(limits, fmt.Sprintf("socket %s is not group read-write (mode %s), so the agent user may be unable to open it", sock.path, mode.Perm()))
+	}
+	return limits
+}
+
 // GetDockerSocketGID returns the GID of the Docker socket.
 // This is needed to add the container user to the docker group so they can
 // access the socket.
 //
 // Returns an error if the socket doesn't exist or cannot be stat'd.
 func GetDockerSocketGID() (uint32, error) {
-	info, err := os.Stat(DockerSocketPath)
+	gid, _, err := socketGID(DockerSocketPath)
+	return gid, err
+}
+
+// socketGID returns the GID and mode of the socket at path.
+func socketGID(path string) (uint32, os.FileMode, error) {
+	info, err := os.Stat(path)
 	if err != nil {
-		return 0, fmt.Errorf("docker socket not found at %s: %w", DockerSocketPath, err)
+		return 0, 0, fmt.Errorf("docker socket not found at %s: %w", path, err)
 	}
 
 	stat, ok := info.Sys().(*syscall.Stat_t)
 	if !ok {
-		return 0, fmt.Errorf("failed to get docker socket stats (unsupported platform)")
+		return 0, 0, fmt.Errorf("failed to get docker socket stats (unsupported platform)")
 	}
 
 	gid := stat.Gid
@@ -128,7 +281,7 @@ func GetDockerSocketGID() (uint32, error) {
 			"gid", gid)
 	}
 
-	return gid, nil
+	return gid, mode, nil
 }
 
 // ResolveDockerDependency validates the docker dependency against the runtime
@@ -136,8 +289,14 @@ func GetDockerSocketGID() (uint32, error) {
 //
 // For host mode:
 // 1. Validates that the runtime supports socket access (not Apple containers)
-// 2. Gets the GID of the docker socket for group permissions
-// 3. Returns the mount config and group ID
+// 2. Finds the host daemon's socket: rootful or rootless Docker, or Podman
+// 3. Gets the GID of the socket for group permissions
+// 4. Returns the mount config, group ID, and the socket's limitations
+//
+// The socket is always mounted at DockerSocketPath in the container, where
+// the docker CLI looks for it. moat-init grants the agent user the socket's
+// group as seen inside the container, which for a rootless daemon is the
+// group the host user maps to.
 //
 // For dind mode:
 // 1. Returns config indicating privileged mode is needed
@@ -173,25 +332,78 @@ func ResolveDockerDependency(depList []deps.Dependency, runtimeType container.Ru
 		}, nil
 	}
 
-	// Handle host mode - mount the Docker socket
-	gid, err := GetDockerSocketGID()
+	// Handle host mode - mount the host daemon's socket
+	sock, err := findHostDockerSocket(runtimeType)
+	if err != nil {
+		return nil, err
+	}
+	gid, sockMode, err := socketGID(sock.path)
 	if err != nil {
 		return nil, err
 	}
 
 	log.Debug("resolved docker dependency",
 		"mode", "host",
-		"socket", DockerSocketPath,
+		"socket", sock.path,
+		"kind", sock.kind,
 		"gid", gid)
 
 	return &DockerDependencyConfig{
 		Mode: deps.DockerModeHost,
 		SocketMount: container.MountConfig{
-			Source:   DockerSocketPath,
+			Source:   sock.path,
 			Target:   DockerSocketPath,
 			ReadOnly: false,
 		},
-		GroupID: strconv.FormatUint(uint64(gid), 10),
+		GroupID:     strconv.FormatUint(uint64(gid), 10),
+		Socket:      sock.kind,
+		Rootless:    sock.rootless,
+		Limitations: socketLimitations(sock, sockMode),
+	}, nil
+}
+
+// containerDockerFilterDir is where the Docker API filter's socket directory
+// is mounted in the container.
+const containerDockerFilterDir = "/run/moat/docker"
+
+// dockerFilterSetup is the result of starting a Docker API filter for a run:
+// the server plus the container mount and env that point the docker CLI at it.
+type dockerFilterSetup struct {
+	server *dockerproxy.Server
+	mount  container.MountConfig
+	env    []string
+}
+
+// startDockerFilter starts a Docker API filter in front of upstreamSocket
+// for docker.filter. The container gets the filter's socket instead of the
+// host socket, with DOCKER_HOST pointing at it. Only Linux is supported:
+// elsewhere containers run in a VM that cannot reach a host Unix socket.
+func startDockerFilter(runID, upstreamSocket string) (dockerFilterSetup, error) {
+	if runtime.GOOS != "linux" {
+		return dockerFilterSetup{}, fmt.Errorf("docker.filter is only supported on Linux\n\n" +
+			"Remove docker.filter from moat.yaml, or use docker:dind for an isolated Docker daemon")
+	}
+
+	socketDir := filepath.Join(config.GlobalConfigDir(), "sockets", "docker", runID)
+	if err := os.MkdirAll(socketDir, 0o755); err != nil {
+		return dockerFilterSetup{}, fmt.Errorf("creating docker API filter socket directory: %w", err)
+	}
+	server := dockerproxy.NewServer(dockerproxy.NewProxy(upstreamSocket, runID), filepath.Join(socketDir, "docker.sock"))
+	if err := server.Start(); err != nil {
+		os.RemoveAll(socketDir)
+		return dockerFilterSetup{}, fmt.Errorf("starting docker API filter: %w", err)
+	}
+
+	log.Debug("docker API filter started", "socket", server.SocketPath(), "upstream", upstreamSocket)
+	return dockerFilterSetup{
+		server: server,
+		mount: container.MountConfig{
+			Source: socketDir,
+			Target: containerDockerFilterDir,
+		},
+		// The filter denies BuildKit, so docker build uses the classic
+		// builder.
+		env: []string{"DOCKER_HOST=unix://" + containerDockerFilterDir + "/docker.sock", "DOCKER_BUILDKIT=0"},
 	}, nil
 }
 
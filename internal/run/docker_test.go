@@ -1,7 +1,11 @@
 package run
 
 import (
+	"net"
 	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
 	"strings"
 	"syscall"
 	"testing"
@@ -176,6 +180,26 @@ func TestValidateDockerDependency(t *testing.T) {
 			wantErr:     true,
 			errType:     "host",
 		},
+		{
+			name:        "host mode on Podman runtime allowed only on Linux",
+			runtimeType: container.RuntimePodman,
+			mode:        deps.DockerModeHost,
+			wantErr:     runtime.GOOS != "linux",
+			errType:     "podman-host",
+		},
+		{
+			name:        "empty mode (defaults to host) on Podman runtime allowed only on Linux",
+			runtimeType: container.RuntimePodman,
+			mode:        "",
+			wantErr:     runtime.GOOS != "linux",
+			errType:     "podman-host",
+		},
+		{
+			name:        "dind mode on Podman runtime allowed",
+			runtimeType: container.RuntimePodman,
+			mode:        deps.DockerModeDind,
+			wantErr:     false,
+		},
 	}
 
 	for _, tt := range tests {
@@ -195,6 +219,10 @@ func TestValidateDockerDependency(t *testing.T) {
 					if _, ok := err.(ErrDockerDindRequiresDockerRuntime); !ok {
 						t.Errorf("error type = %T, want ErrDockerDindRequiresDockerRuntime", err)
 					}
+				case "podman-host":
+					if _, ok := err.(ErrDockerHostRequiresDockerDaemon); !ok {
+						t.Errorf("error type = %T, want ErrDockerHostRequiresDockerDaemon", err)
+					}
 				}
 			}
 		})
@@ -438,3 +466,111 @@ func TestGetDockerSocketGID_NotExists(t *testing.T) {
 		t.Log("docker socket exists, skipping not-found test")
 	}
 }
+
+// listenUnix creates a Unix socket at path that is closed when the test ends.
+func listenUnix(t *testing.T, path string) {
+	t.Helper()
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatalf("listen %s: %v", path, err)
+	}
+	t.Cleanup(func() { l.Close() })
+}
+
+// TestFindHostDockerSocket verifies DOCKER_HOST sockets are mounted at the
+// standard path and Podman sockets behind a docker.sock symlink are detected.
+func TestFindHostDockerSocket(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("socket detection only applies on Linux")
+	}
+	dir := t.TempDir()
+	podmanSock := filepath.Join(dir, "podman.sock")
+	listenUnix(t, podmanSock)
+	dockerSock := filepath.Join(dir, "docker.sock")
+	if err := os.Symlink(podmanSock, dockerSock); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("DOCKER_HOST", "unix://"+dockerSock)
+
+	sock, err := findHostDockerSocket(container.RuntimeDocker)
+	if err != nil {
+		t.Fatalf("findHostDockerSocket() error = %v", err)
+	}
+	if sock.path != dockerSock {
+		t.Errorf("path = %q, want %q from DOCKER_HOST", sock.path, dockerSock)
+	}
+	if sock.kind != DockerSocketPodman {
+		t.Errorf("kind = %q, want %q for a symlink to podman.sock", sock.kind, DockerSocketPodman)
+	}
+	if sock.rootless != (os.Getuid() != 0) {
+		t.Errorf("rootless = %v for a socket owned by uid %d", sock.rootless, os.Getuid())
+	}
+
+	depList := []deps.Dependency{{Name: "docker", DockerMode: deps.DockerModeHost}}
+	cfg, err := ResolveDockerDependency(depList, container.RuntimeDocker)
+	if err != nil {
+		t.Fatalf("ResolveDockerDependency() error = %v", err)
+	}
+	if cfg.SocketMount.Source != dockerSock || cfg.SocketMount.Target != DockerSocketPath {
+		t.Errorf("SocketMount = %+v, want %s mounted at %s", cfg.SocketMount, dockerSock, DockerSocketPath)
+	}
+	if !slices.Contains(cfg.Limitations, limitationPodman) {
+		t.Errorf("Limitations = %v, want the Podman API limitation", cfg.Limitations)
+	}
+
+	t.Setenv("DOCKER_HOST", "tcp://127.0.0.1:2375")
+	if _, err := findHostDockerSocket(container.RuntimeDocker); err == nil {
+		t.Error("expected error for a tcp:// DOCKER_HOST")
+	}
+}
+
+// TestFindHostDockerSocket_Rootless verifies the rootless socket under
+// XDG_RUNTIME_DIR is found when DOCKER_HOST is unset and the standard
+// socket is missing.
+func TestFindHostDockerSocket_Rootless(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("socket detection only applies on Linux")
+	}
+	if _, err := os.Stat(DockerSocketPath); err == nil {
+		t.Skipf("%s exists and takes precedence", DockerSocketPath)
+	}
+	dir := t.TempDir()
+	rootlessSock := filepath.Join(dir, "docker.sock")
+	listenUnix(t, rootlessSock)
+	t.Setenv("DOCKER_HOST", "")
+	t.Setenv("XDG_RUNTIME_DIR", dir)
+
+	sock, err := findHostDockerSocket(container.RuntimeDocker)
+	if err != nil {
+		t.Fatalf("findHostDockerSocket() error = %v", err)
+	}
+	if sock.path != rootlessSock {
+		t.Errorf("path = %q, want %q", sock.path, rootlessSock)
+	}
+	if sock.kind == DockerSocketPodman {
+		t.Errorf("kind = %q, want a Docker socket", sock.kind)
+	}
+}
+
+// TestSocketLimitations verifies the capability differences reported for
+// each kind of socket.
+func TestSocketLimitations(t *testing.T) {
+	tests := []struct {
+		name string
+		sock hostDockerSocket
+		mode os.FileMode
+		want int
+	}{
+		{"rootful docker", hostDockerSocket{kind: DockerSocketRootful}, 0o660, 0},
+		{"rootless docker", hostDockerSocket{kind: DockerSocketRootless, rootless: true}, 0o660, 1},
+		{"rootless podman", hostDockerSocket{kind: DockerSocketPodman, rootless: true}, 0o660, 2},
+		{"owner-only socket", hostDockerSocket{kind: DockerSocketRootless, rootless: true}, 0o600, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := socketLimitations(tt.sock, tt.mode); len(got) != tt.want {
+				t.Errorf("socketLimitations() = %v, want %d entries", got, tt.want)
+			}
+		})
+	}
+}
@@ -41,6 +41,9 @@ func (f *flexibleRuntime) Type() container.RuntimeType {
 	return container.RuntimeDocker
 }
 func (f *flexibleRuntime) Ping(context.Context) error { return nil }
+func (f *flexibleRuntime) Version(context.Context) (string, error) {
+	return "test", nil
+}
 func (f *flexibleRuntime) CreateContainer(context.Context, container.Config) (string, error) {
 	return "ctr-test", nil
 }
@@ -104,6 +107,22 @@ func (f *flexibleRuntime) ContainerLogsAll(ctx context.Context, id string) ([]by
 	return nil, nil
 }
 
+func (f *flexibleRuntime) UserNamespaceMode(context.Context) (string, error) {
+	return container.UsernsNone, nil
+}
+
+func (f *flexibleRuntime) UpdatePriority(context.Context, string, container.Priority) error {
+	return nil
+}
+
+func (f *flexibleRuntime) ContainerOOMKilled(context.Context, string) (bool, error) {
+	return false, nil
+}
+
+func (f *flexibleRuntime) ContainerStats(context.Context, string) (container.Stats, error) {
+	return container.Stats{}, container.ErrStatsUnsupported
+}
+
 func (f *flexibleRuntime) ContainerState(_ context.Context, id string) (string, error) {
 	if f.states != nil {
 		state, ok := f.states[id]
@@ -131,6 +150,9 @@ func (f *flexibleRuntime) SetupFirewall(ctx context.Context, id, host string, po
 	}
 	return nil
 }
+func (f *flexibleRuntime) RestrictServiceAccess(context.Context, string, []container.ServiceAccess) error {
+	return nil
+}
 
 func (f *flexibleRuntime) ListImages(context.Context) ([]container.ImageInfo, error) {
 	return nil, nil
@@ -977,6 +999,16 @@ func TestMonitorContainerExitSetsStateFailed(t *testing.T) {
 	if r.GetState() != StateFailed {
 		t.Errorf("expected StateFailed, got %s", r.GetState())
 	}
+	if got := r.GetFailureClass(); got != FailureAgentError {
+		t.Errorf("FailureClass = %q, want %q", got, FailureAgentError)
+	}
+	meta, err := store.LoadMetadata()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.ExitCode != 1 || meta.FailureClass != string(FailureAgentError) {
+		t.Errorf("persisted exit_code=%d failure_class=%q, want 1 and %q", meta.ExitCode, meta.FailureClass, FailureAgentError)
+	}
 
 	// exitCh should be closed
 	select {
@@ -1036,6 +1068,9 @@ func TestMonitorContainerExitSetsStateStopped(t *testing.T) {
 	if r.GetState() != StateStopped {
 		t.Errorf("expected StateStopped, got %s", r.GetState())
 	}
+	if got := r.GetFailureClass(); got != "" {
+		t.Errorf("FailureClass = %q, want empty for a successful run", got)
+	}
 }
 
 // --- lastNLines edge case tests ---
@@ -0,0 +1,253 @@
+package run
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/majorcontext/moat/internal/errcode"
+	"github.com/majorcontext/moat/internal/log"
+	"github.com/majorcontext/moat/internal/storage"
+)
+
+// ErrNoEnvSnapshot is returned for runs created before moat recorded
+// container environment snapshots.
+var ErrNoEnvSnapshot = errors.New("run has no recorded environment")
+
+func init() {
+	errcode.Register(ErrNoEnvSnapshot, errcode.NoEnvSnapshot)
+}
+
+// envSourceDefault is the source of variables moat sets for its own
+// plumbing (init script settings, host names, caches).
+const envSourceDefault = "moat"
+
+// envSources maps an env var name to where its value came from. Noting the
+// same name again overwrites the source, matching how the runtime resolves
+// duplicate names (last wins).
+type envSources map[string]string
+
+// note records source for every NAME=value entry in env.
+func (s envSources) note(env []string, source string) {
+	for _, e := range env {
+		if name, _, ok := strings.Cut(e, "="); ok {
+			s[name] = source
+		}
+	}
+}
+
+// newEnvSnapshot builds the recorded environment from the env list passed to
+// the container runtime. Secrets and credential-like values are redacted.
+func newEnvSnapshot(runID string, env []string, sources envSources) []storage.EnvVar {
+	values := make(map[string]string, len(env))
+	for _, e := range env {
+		if name, value, ok := strings.Cut(e, "="); ok {
+			values[name] = value
+		}
+	}
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	snapshot := make([]storage.EnvVar, 0, len(names))
+	for _, name := range names {
+		source := sources[name]
+		if source == "" {
+			source = envSourceDefault
+		}
+		v := storage.EnvVar{Name: name, Value: values[name], Source: source}
+		if shown, redacted := redactEnvValue(name, v.Value, source); redacted {
+			v.Value = shown
+			v.Redacted = true
+			v.Digest = envDigest(runID, name, values[name])
+		}
+		snapshot = append(snapshot, v)
+	}
+	return snapshot
+}
+
+// recordEnvSnapshot saves the container environment to the run's store.
+// Best-effort: a failure only disables `moat env` for this run.
+func recordEnvSnapshot(store *storage.RunStore, runID string, env []string, sources envSources) {
+	if err := store.SaveEnvSnapshot(newEnvSnapshot(runID, env, sources)); err != nil {
+		log.Debug("failed to save env snapshot", "error", err)
+	}
+}
+
+// sensitiveEnvNameParts mark variable names whose values are redacted.
+var sensitiveEnvNameParts = []string{"TOKEN", "SECRET", "PASSWORD", "PASSWD", "API_KEY", "PRIVATE_KEY", "CREDENTIAL", "AUTH"}
+
+// redactEnvValue returns the value to show for an env var and whether it was
+// redacted. Secrets and credential-like names are hidden entirely; URLs with
+// a password (the proxy URL carries the run's auth token) keep everything
+// but the password.
+func redactEnvValue(name, value, source string) (string, bool) {
+	if value == "" {
+		return value, false
+	}
+	if strings.HasPrefix(source, "secret") {
+		return "<redacted>", true
+	}
+	upper := strings.ToUpper(name)
+	for _, part := range sensitiveEnvNameParts {
+		if strings.Contains(upper, part) {
+			return "<redacted>", true
+		}
+	}
+	if strings.Contains(value, "://") {
+		if u, err := url.Parse(value); err == nil && u.User != nil {
+			if _, hasPassword := u.User.Password(); hasPassword {
+				u.User = url.UserPassword(u.User.Username(), "redacted")
+				return u.String(), true
+			}
+		}
+	}
+	return value, false
+}
+
+// envDigest identifies a redacted value without revealing it. The run ID
+// salts the hash so equal secrets in different runs have different digests.
+func envDigest(runID, name, value string) string {
+	sum := sha256.Sum256([]byte(runID + "\x00" + name + "\x00" + value))
+	return hex.EncodeToString(sum[:8])
+}
+
+// EnvSnapshot returns the environment r's container was created with.
+func EnvSnapshot(r *Run) ([]storage.EnvVar, error) {
+	if r.Store == nil {
+		return nil, ErrNoEnvSnapshot
+	}
+	env, err := r.Store.LoadEnvSnapshot()
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNoEnvSnapshot
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading recorded environment: %w", err)
+	}
+	return env, nil
+}
+
+// EnvChange describes one difference between the environment a run's
+// container was created with and the environment of its main process now.
+// Redacted values are never included.
+type EnvChange struct {
+	Name   string `json:"name"`
+	Change string `json:"change"` // "added", "removed", or "changed"
+	Source string `json:"source,omitempty"`
+	Old    string `json:"old,omitempty"`
+	New    string `json:"new,omitempty"`
+}
+
+func (c EnvChange) String() string {
+	switch {
+	case c.Change == "changed" && (c.Old != "" || c.New != ""):
+		return fmt.Sprintf("%s %s: %q → %q", c.Change, c.Name, c.Old, c.New)
+	case c.Change == "added" && c.New != "":
+		return fmt.Sprintf("%s %s=%s", c.Change, c.Name, c.New)
+	}
+	return c.Change + " " + c.Name
+}
+
+// EnvDrift compares the recorded environment of a running run with the
+// environment of the container's main process (PID 1), which reflects any
+// changes the init script made before starting the agent. Variables the
+// image sets are not reported: they are present in a fresh exec as well.
+func (m *Manager) EnvDrift(ctx context.Context, runID string) ([]EnvChange, error) {
+	r, err := m.Get(runID)
+	if err != nil {
+		return nil, err
+	}
+	recorded, err := EnvSnapshot(r)
+	if err != nil {
+		return nil, err
+	}
+	live, err := m.readContainerEnviron(ctx, runID, "/proc/1/environ")
+	if err != nil {
+		return nil, fmt.Errorf("reading the agent's environment: %w", err)
+	}
+	fresh, err := m.readContainerEnviron(ctx, runID, "/proc/self/environ")
+	if err != nil {
+		return nil, fmt.Errorf("reading the container's environment: %w", err)
+	}
+	return diffEnv(r.ID, recorded, live, fresh), nil
+}
+
+// readContainerEnviron reads a NUL-separated environ file in the container.
+func (m *Manager) readContainerEnviron(ctx context.Context, runID, path string) (map[string]string, error) {
+	var stdout, stderr bytes.Buffer
+	if err := m.Exec(ctx, runID, []string{"cat", path}, nil, &stdout, &stderr); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%w: %s", err, msg)
+		}
+		return nil, err
+	}
+	return parseEnviron(stdout.Bytes()), nil
+}
+
+// parseEnviron parses NUL-separated NAME=value entries.
+func parseEnviron(data []byte) map[string]string {
+	env := map[string]string{}
+	for _, e := range bytes.Split(data, []byte{0}) {
+		if name, value, ok := strings.Cut(string(e), "="); ok && name != "" {
+			env[name] = value
+		}
+	}
+	return env
+}
+
+// diffEnv returns the changes from the recorded environment to the live one,
+// sorted by name. fresh is the environment of a new exec in the container,
+// used to recognize variables that come from the image.
+func diffEnv(runID string, recorded []storage.EnvVar, live, fresh map[string]string) []EnvChange {
+	var changes []EnvChange
+	seen := make(map[string]bool, len(recorded))
+	for _, v := range recorded {
+		seen[v.Name] = true
+		cur, ok := live[v.Name]
+		switch {
+		case !ok:
+			changes = append(changes, EnvChange{Name: v.Name, Change: "removed", Source: v.Source})
+		case v.Redacted:
+			if envDigest(runID, v.Name, cur) != v.Digest {
+				changes = append(changes, EnvChange{Name: v.Name, Change: "changed", Source: v.Source})
+			}
+		case cur != v.Value:
+			c := EnvChange{Name: v.Name, Change: "changed", Source: v.Source, Old: v.Value, New: cur}
+			if _, redacted := redactEnvValue(v.Name, cur, v.Source); redacted {
+				c.Old, c.New = "", ""
+			}
+			changes = append(changes, c)
+		}
+	}
+
+	var added []string
+	for name, value := range live {
+		if seen[name] {
+			continue
+		}
+		if imageValue, ok := fresh[name]; ok && imageValue == value {
+			continue
+		}
+		added = append(added, name)
+	}
+	sort.Strings(added)
+	for _, name := range added {
+		c := EnvChange{Name: name, Change: "added"}
+		if shown, redacted := redactEnvValue(name, live[name], ""); !redacted {
+			c.New = shown
+		}
+		changes = append(changes, c)
+	}
+
+	sort.SliceStable(changes, func(i, j int) bool { return changes[i].Name < changes[j].Name })
+	return changes
+}
@@ -0,0 +1,254 @@
+package run
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/majorcontext/moat/internal/container"
+	"github.com/majorcontext/moat/internal/log"
+	"github.com/majorcontext/moat/internal/storage"
+)
+
+// FailureClass categorizes why a run failed, so `moat list` and automation
+// can react without parsing error strings. Values are persisted in run
+// metadata and must not be renamed.
+type FailureClass string
+
+const (
+	// FailureAgentError is a non-zero exit from the agent with no more
+	// specific cause detected.
+	FailureAgentError FailureClass = "agent_error"
+	// FailureToolFailure is an exit code of 126 (command not executable) or
+	// 127 (command not found): the configured command could not be launched.
+	FailureToolFailure FailureClass = "tool_failure"
+	// FailureNetworkBlocked is a non-zero exit from a run whose traffic the
+	// proxy blocked by network or Keep policy.
+	FailureNetworkBlocked FailureClass = "network_blocked"
+	// FailureOOMKilled is a container terminated by the kernel OOM killer.
+	FailureOOMKilled FailureClass = "oom_killed"
+	// FailureKilled is a container whose main process was killed with
+	// SIGKILL (exit code 137) without the runtime reporting an OOM kill:
+	// a memory limit on runtimes that do not expose OOM state, a process
+	// limit, or an external kill.
+	FailureKilled FailureClass = "killed"
+	// FailureBudgetExceeded is a non-zero exit whose last LLM API response
+	// reported exhausted quota, credits, or usage limits.
+	FailureBudgetExceeded FailureClass = "budget_exceeded"
+)
+
+// exitCodeSIGKILL is the exit code a shell reports for a process killed by
+// SIGKILL (128 + 9).
+const exitCodeSIGKILL = 137
+
+// exitSignals collects the evidence used to classify a failed run.
+type exitSignals struct {
+	ExitCode        int64
+	OOMKilled       bool
+	BlockedRequests int  // requests denied by network or Keep policy
+	BudgetExhausted bool // last LLM API response was a quota/credit error
+}
+
+// classifyFailure returns the failure class for a run, or "" if the run
+// succeeded. Precedence runs from the most specific, host-observed cause to
+// the generic fallback: an OOM kill or SIGKILL explains any agent behavior;
+// an exhausted budget or blocked traffic explains why the agent gave up;
+// exit codes 126/127 mean the command never ran.
+func classifyFailure(s exitSignals) FailureClass {
+	switch {
+	case s.OOMKilled:
+		return FailureOOMKilled
+	case s.ExitCode == 0:
+		return ""
+	case s.ExitCode == exitCodeSIGKILL:
+		return FailureKilled
+	case s.BudgetExhausted:
+		return FailureBudgetExceeded
+	case s.BlockedRequests > 0:
+		return FailureNetworkBlocked
+	case s.ExitCode == 126 || s.ExitCode == 127:
+		return FailureToolFailure
+	default:
+		return FailureAgentError
+	}
+}
+
+// llmAPIHosts are the LLM provider API hosts whose error responses are
+// classified for budget exhaustion and API error summaries.
+var llmAPIHosts = map[string]bool{
+	"api.anthropic.com":                 true,
+	"api.openai.com":                    true,
+	"generativelanguage.googleapis.com": true,
+	"cloudcode-pa.googleapis.com":       true, // Gemini CLI in OAuth mode
+}
+
+// budgetErrorMarkers are substrings of provider error bodies that indicate
+// exhausted credits or quota rather than a transient failure.
+var budgetErrorMarkers = []string{
+	"credit balance is too low", // Anthropic
+	"insufficient_quota",        // OpenAI
+	"usage limit",               // Anthropic/OpenAI subscription limits
+	"RESOURCE_EXHAUSTED",        // Gemini
+}
+
+// isBudgetError reports whether a logged LLM API response signals exhausted
+// credits or quota. HTTP 402 always does; 429 only when the body says so,
+// since plain rate limiting is transient.
+func isBudgetError(req storage.NetworkRequest) bool {
+	if req.StatusCode == 402 {
+		return true
+	}
+	if req.StatusCode != 429 && req.StatusCode != 400 && req.StatusCode != 403 {
+		return false
+	}
+	for _, marker := range budgetErrorMarkers {
+		if strings.Contains(req.ResponseBody, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// networkSignals scans the run's network log for policy denials and for a
+// budget error in the last LLM API response.
+func networkSignals(reqs []storage.NetworkRequest) (blocked int, budgetExhausted bool) {
+	var lastLLM *storage.NetworkRequest
+	for i := range reqs {
+		req := &reqs[i]
+		if req.Denied {
+			blocked++
+			continue
+		}
+		u, err := url.Parse(req.URL)
+		if err != nil || !llmAPIHosts[u.Hostname()] {
+			continue
+		}
+		lastLLM = req
+	}
+	return blocked, lastLLM != nil && isBudgetError(*lastLLM)
+}
+
+// recordFailureClass gathers exit signals for a run whose container exited
+// with exitCode and stores the exit code and failure class on the run. It
+// must run after the container has exited and before SaveMetadata.
+func (m *Manager) recordFailureClass(r *Run, rt container.Runtime, exitCode int64) {
+	s := exitSignals{ExitCode: exitCode}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	oom, err := rt.ContainerOOMKilled(ctx, r.ContainerID)
+	if err != nil {
+		log.Debug("failed to check OOM state", "runID", r.ID, "error", err)
+	}
+	s.OOMKilled = oom
+
+	if exitCode != 0 && r.Store != nil {
+		reqs, readErr := r.Store.ReadNetworkRequests()
+		if readErr != nil {
+			log.Debug("failed to read network log for failure classification", "runID", r.ID, "error", readErr)
+		}
+		s.BlockedRequests, s.BudgetExhausted = networkSignals(reqs)
+	}
+
+	class := classifyFailure(s)
+	if class != "" {
+		log.Debug("classified run failure", "runID", r.ID, "class", class, "exit_code", exitCode,
+			"oom_killed", s.OOMKilled, "blocked_requests", s.BlockedRequests)
+	}
+
+	r.stateMu.Lock()
+	r.ExitCode = exitCode
+	r.FailureClass = class
+	r.stateMu.Unlock()
+}
+
+// failureMessage returns the run error for a failed exit: the exit code,
+// plus what happened when the class identifies a resource kill. peakMB is
+// the highest memory usage sampled during the run (see recordMetrics), or
+// 0 if none was recorded; it is reported with resource kills so users can
+// size container.memory.
+func failureMessage(class FailureClass, exitCode int64, memoryMB, peakMB int) string {
+	usage := ""
+	if peakMB > 0 {
+		usage = fmt.Sprintf(" (peak sampled usage %d MB)", peakMB)
+	}
+	switch class {
+	case FailureOOMKilled:
+		if memoryMB > 0 {
+			return fmt.Sprintf("exit code %d: container exceeded its %d MB memory limit%s and was killed by the OOM killer", exitCode, memoryMB, usage)
+		}
+		return fmt.Sprintf("exit code %d: container ran out of memory%s and was killed by the OOM killer", exitCode, usage)
+	case FailureKilled:
+		if memoryMB > 0 && peakMB > 0 {
+			return fmt.Sprintf("exit code %d: container process was killed (SIGKILL); peak sampled memory usage was %d MB of its %d MB limit", exitCode, peakMB, memoryMB)
+		}
+		return fmt.Sprintf("exit code %d: container process was killed (SIGKILL)", exitCode)
+	default:
+		return fmt.Sprintf("exit code %d", exitCode)
+	}
+}
+
+// peakMemoryMB returns the highest memory usage in r's recorded resource
+// samples, in MB, or 0 if none were recorded. Samples are taken every
+// MetricsInterval, so the usage at the moment of a kill may be higher.
+func peakMemoryMB(r *Run) int {
+	if r.Store == nil {
+		return 0
+	}
+	samples, err := r.Store.ReadMetrics()
+	if err != nil {
+		log.Debug("failed to read resource usage for failure message", "runID", r.ID, "error", err)
+		return 0
+	}
+	return int(SummarizeMetrics(samples).MemoryMax / (1024 * 1024)) // #nosec G115 -- memory in MB fits in int
+}
+
+// FailureHint returns actionable guidance for a failed run's failure class,
+// or "" when there is nothing specific to suggest. The CLI prints it after
+// the run error.
+func FailureHint(r *Run) string {
+	r.stateMu.Lock()
+	class := r.FailureClass
+	r.stateMu.Unlock()
+
+	switch class {
+	case FailureOOMKilled:
+		if r.MemoryMB > 0 {
+			return fmt.Sprintf("The agent ran out of memory (limit: %d MB). Raise the limit in moat.yaml:\n\n"+
+				"  container:\n    memory: %d\n\n"+
+				"See https://majorcontext.com/moat/reference/moat-yaml", r.MemoryMB, r.MemoryMB*2)
+		}
+		return "The agent ran out of memory with no container limit set, so the host (or Docker VM) is out of memory.\n" +
+			"Increase the memory available to Docker (Docker Desktop: Settings → Resources), or set container.memory\n" +
+			"in moat.yaml to cap the agent below what the host can provide."
+	case FailureKilled:
+		if container.RuntimeType(r.Runtime) == container.RuntimeApple {
+			limit := "its memory limit"
+			if r.MemoryMB > 0 {
+				limit = fmt.Sprintf("its %d MB memory limit", r.MemoryMB)
+			}
+			return "The container was killed (SIGKILL). Apple containers do not report OOM kills; this usually means\n" +
+				"the agent exceeded " + limit + ". Raise it with container.memory in moat.yaml."
+		}
+		return "The container was killed (SIGKILL) without an OOM report. Common causes: a process limit\n" +
+			"(container.ulimits.nproc) was reached, or the container was killed externally (e.g. docker kill)."
+	case FailureBudgetExceeded:
+		return "The LLM provider reported exhausted credits or quota. Check your plan or billing, then retry."
+	case FailureNetworkBlocked:
+		return "The proxy blocked requests from this run. Run `moat suggest " + r.ID + "` to list the blocked\n" +
+			"hosts and the moat.yaml additions that would allow them."
+	case FailureToolFailure:
+		return "The command could not be executed (not found or not executable). Check the command and that\n" +
+			"its dependency is listed under dependencies in moat.yaml."
+	}
+	return ""
+}
+
+// GetFailureClass safely reads the run's failure class (thread-safe).
+func (r *Run) GetFailureClass() FailureClass {
+	r.stateMu.Lock()
+	defer r.stateMu.Unlock()
+	return r.FailureClass
+}
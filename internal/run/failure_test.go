@@ -0,0 +1,166 @@
+package run
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/majorcontext/moat/internal/storage"
+)
+
+func TestClassifyFailure(t *testing.T) {
+	tests := []struct {
+		name string
+		s    exitSignals
+		want FailureClass
+	}{
+		{"success", exitSignals{ExitCode: 0}, ""},
+		{"success ignores blocked traffic", exitSignals{ExitCode: 0, BlockedRequests: 3}, ""},
+		{"plain non-zero", exitSignals{ExitCode: 1}, FailureAgentError},
+		{"command not found", exitSignals{ExitCode: 127}, FailureToolFailure},
+		{"command not executable", exitSignals{ExitCode: 126}, FailureToolFailure},
+		{"blocked traffic", exitSignals{ExitCode: 1, BlockedRequests: 2}, FailureNetworkBlocked},
+		{"budget", exitSignals{ExitCode: 1, BudgetExhausted: true}, FailureBudgetExceeded},
+		{"budget wins over blocked", exitSignals{ExitCode: 1, BudgetExhausted: true, BlockedRequests: 1}, FailureBudgetExceeded},
+		{"blocked wins over tool failure", exitSignals{ExitCode: 127, BlockedRequests: 1}, FailureNetworkBlocked},
+		{"oom", exitSignals{ExitCode: 137, OOMKilled: true}, FailureOOMKilled},
+		{"oom wins over everything", exitSignals{ExitCode: 137, OOMKilled: true, BudgetExhausted: true, BlockedRequests: 5}, FailureOOMKilled},
+		{"sigkill without oom", exitSignals{ExitCode: 137}, FailureKilled},
+		{"sigkill wins over blocked traffic", exitSignals{ExitCode: 137, BlockedRequests: 1}, FailureKilled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := classifyFailure(tt.s); got != tt.want {
+				t.Errorf("classifyFailure(%+v) = %q, want %q", tt.s, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNetworkSignals(t *testing.T) {
+	reqs := []storage.NetworkRequest{
+		{URL: "https://evil.example.com/", StatusCode: 407, Denied: true},
+		{URL: "https://api.anthropic.com/v1/messages", StatusCode: 400, ResponseBody: `{"error":{"message":"Your credit balance is too low"}}`},
+		{URL: "https://github.com/", StatusCode: 200},
+	}
+	blocked, budget := networkSignals(reqs)
+	if blocked != 1 {
+		t.Errorf("blocked = %d, want 1", blocked)
+	}
+	if !budget {
+		t.Error("budget should be exhausted when the last LLM response is a credit error")
+	}
+
+	// A later successful LLM call means the earlier budget error was not fatal.
+	reqs = append(reqs, storage.NetworkRequest{URL: "https://api.anthropic.com/v1/messages", StatusCode: 200})
+	if _, budget := networkSignals(reqs); budget {
+		t.Error("budget should not be exhausted when the last LLM response succeeded")
+	}
+}
+
+func TestIsBudgetError(t *testing.T) {
+	tests := []struct {
+		name string
+		req  storage.NetworkRequest
+		want bool
+	}{
+		{"payment required", storage.NetworkRequest{StatusCode: 402}, true},
+		{"openai quota", storage.NetworkRequest{StatusCode: 429, ResponseBody: `{"error":{"code":"insufficient_quota"}}`}, true},
+		{"plain rate limit", storage.NetworkRequest{StatusCode: 429, ResponseBody: `{"error":{"type":"rate_limit_error"}}`}, false},
+		{"server error with marker", storage.NetworkRequest{StatusCode: 500, ResponseBody: "usage limit"}, false},
+		{"ok", storage.NetworkRequest{StatusCode: 200}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isBudgetError(tt.req); got != tt.want {
+				t.Errorf("isBudgetError(%+v) = %v, want %v", tt.req, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFailureMessage(t *testing.T) {
+	tests := []struct {
+		name     string
+		class    FailureClass
+		exitCode int64
+		memoryMB int
+		peakMB   int
+		want     string
+	}{
+		{"oom with limit", FailureOOMKilled, 137, 4096, 0, "exit code 137: container exceeded its 4096 MB memory limit and was killed by the OOM killer"},
+		{"oom with limit and usage", FailureOOMKilled, 137, 4096, 3980, "exit code 137: container exceeded its 4096 MB memory limit (peak sampled usage 3980 MB) and was killed by the OOM killer"},
+		{"oom without limit", FailureOOMKilled, 137, 0, 0, "exit code 137: container ran out of memory and was killed by the OOM killer"},
+		{"oom without limit with usage", FailureOOMKilled, 137, 0, 7000, "exit code 137: container ran out of memory (peak sampled usage 7000 MB) and was killed by the OOM killer"},
+		{"killed", FailureKilled, 137, 4096, 0, "exit code 137: container process was killed (SIGKILL)"},
+		{"killed with usage", FailureKilled, 137, 4096, 4000, "exit code 137: container process was killed (SIGKILL); peak sampled memory usage was 4000 MB of its 4096 MB limit"},
+		{"agent error", FailureAgentError, 1, 4096, 100, "exit code 1"},
+		{"unclassified", "", 2, 0, 0, "exit code 2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := failureMessage(tt.class, tt.exitCode, tt.memoryMB, tt.peakMB); got != tt.want {
+				t.Errorf("failureMessage() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPeakMemoryMB(t *testing.T) {
+	store, err := storage.NewRunStore(t.TempDir(), "run_peakmem1234")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &Run{ID: "run_peakmem1234", Store: store}
+	if got := peakMemoryMB(r); got != 0 {
+		t.Errorf("peakMemoryMB() with no samples = %d, want 0", got)
+	}
+	for _, mb := range []uint64{100, 300, 200} {
+		if err := store.WriteMetric(storage.Metric{Timestamp: time.Now(), MemoryBytes: mb << 20}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := peakMemoryMB(r); got != 300 {
+		t.Errorf("peakMemoryMB() = %d, want 300", got)
+	}
+}
+
+func TestFailureHint(t *testing.T) {
+	// OOM with a limit suggests a concrete, larger container.memory value.
+	r := &Run{ID: "run_aaaaaaaaaaaa", FailureClass: FailureOOMKilled, MemoryMB: 2048}
+	hint := FailureHint(r)
+	if !strings.Contains(hint, "2048 MB") || !strings.Contains(hint, "memory: 4096") {
+		t.Errorf("OOM hint should cite the limit and suggest doubling it, got: %q", hint)
+	}
+
+	// OOM without a limit points at host/VM memory instead.
+	r = &Run{ID: "run_aaaaaaaaaaaa", FailureClass: FailureOOMKilled}
+	if hint := FailureHint(r); !strings.Contains(hint, "Docker") {
+		t.Errorf("unlimited OOM hint should mention Docker memory, got: %q", hint)
+	}
+
+	// SIGKILL on Apple is most likely a memory limit.
+	r = &Run{ID: "run_aaaaaaaaaaaa", FailureClass: FailureKilled, Runtime: "apple", MemoryMB: 8192}
+	if hint := FailureHint(r); !strings.Contains(hint, "8192 MB") || !strings.Contains(hint, "container.memory") {
+		t.Errorf("Apple kill hint should cite the memory limit, got: %q", hint)
+	}
+
+	// SIGKILL on Docker without OOM points at process limits or external kills.
+	r = &Run{ID: "run_aaaaaaaaaaaa", FailureClass: FailureKilled, Runtime: "docker"}
+	if hint := FailureHint(r); !strings.Contains(hint, "nproc") {
+		t.Errorf("Docker kill hint should mention process limits, got: %q", hint)
+	}
+
+	// Network-blocked hint names the run so the suggest command is copy-pasteable.
+	r = &Run{ID: "run_bbbbbbbbbbbb", FailureClass: FailureNetworkBlocked}
+	if hint := FailureHint(r); !strings.Contains(hint, "moat suggest run_bbbbbbbbbbbb") {
+		t.Errorf("network hint should include the suggest command, got: %q", hint)
+	}
+
+	// Generic agent errors and successful runs have nothing specific to say.
+	for _, class := range []FailureClass{FailureAgentError, ""} {
+		if hint := FailureHint(&Run{FailureClass: class}); hint != "" {
+			t.Errorf("FailureHint(%q) = %q, want empty", class, hint)
+		}
+	}
+}
@@ -2,7 +2,10 @@ package run
 
 import (
 	"errors"
+	"fmt"
+	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/majorcontext/moat/internal/config"
 	"github.com/majorcontext/moat/internal/credential"
@@ -21,6 +24,59 @@ func AppendMCPGrants(grants []string, cfg *config.Config) []string {
 	return appendMCPGrants(grants, cfg)
 }
 
+// ExpandGrantBundles replaces each grant bundle name in grants with the
+// bundle's grants. Exported wrapper over expandGrantBundles for the CLI
+// pre-flight, which must detect against the same grant set Create builds.
+func ExpandGrantBundles(grants []string, bundles map[string][]string) ([]string, error) {
+	expanded, _, err := expandGrantBundles(grants, bundles)
+	return expanded, err
+}
+
+// expandGrantBundles replaces each grant bundle name in grants with the
+// bundle's grants, in order, dropping duplicates. The returned origins map
+// each grant that came only from a bundle to that bundle's name, so
+// validation errors can say where a grant was asked for.
+//
+// A bundle named after a registered provider is rejected rather than
+// silently winning: "github" in moat.yaml should never mean something other
+// than the GitHub grant depending on whose machine the run starts on.
+func expandGrantBundles(grants []string, bundles map[string][]string) ([]string, map[string]string, error) {
+	if len(bundles) == 0 {
+		return grants, nil, nil
+	}
+	var (
+		expanded []string
+		origins  = map[string]string{}
+		seen     = map[string]bool{}
+	)
+	for _, grant := range grants {
+		members, ok := bundles[grant]
+		if !ok {
+			if !seen[grant] {
+				seen[grant] = true
+				expanded = append(expanded, grant)
+			}
+			// An explicit grant is not attributed to a bundle, even if a
+			// bundle listed earlier also pulled it in.
+			delete(origins, grant)
+			continue
+		}
+		if provider.Get(grant) != nil {
+			return nil, nil, fmt.Errorf("grant bundle %q has the same name as the %s provider\n\nRename the bundle in %s.",
+				grant, grant, filepath.Join(config.GlobalConfigDir(), "config.yaml"))
+		}
+		for _, m := range members {
+			if seen[m] {
+				continue
+			}
+			seen[m] = true
+			expanded = append(expanded, m)
+			origins[m] = grant
+		}
+	}
+	return expanded, origins, nil
+}
+
 // OpenDefaultStore opens the default-profile credential store. Mirrors the
 // store construction inside Create so the CLI pre-flight reads the same source.
 func OpenDefaultStore() (*credential.FileStore, error) {
@@ -39,6 +95,7 @@ const (
 	ReasonDecryptFailed                        // stored but encryption key changed
 	ReasonUnknownProvider                      // typo / not a registered provider
 	ReasonReadFailed                           // store read failed (e.g. permission denied, corrupt file)
+	ReasonExpired                              // stored but past its --expires time
 )
 
 // MissingGrant describes a grant a run needs but does not have.
@@ -95,7 +152,12 @@ func DetectMissingGrants(grants []string, cfg *config.Config, store *credential.
 			continue
 		}
 		credName := credentialStoreKey(grantName, grant)
-		if _, err := store.Get(credName); err != nil {
+		cred, err := store.Get(credName)
+		if err == nil && cred.GrantExpired(time.Now()) {
+			add(MissingGrant{Grant: grant, Reason: ReasonExpired, FixCommand: fix, Promptable: grantName != "aws"})
+			continue
+		}
+		if err != nil {
 			reason := classifyMissingReason(err)
 			// AWS needs mandatory flags (--role, …); cannot prompt cleanly. A
 			// read failure (permission/corrupt file) isn't fixed by re-granting
@@ -118,7 +180,12 @@ func DetectMissingGrants(grants []string, cfg *config.Config, store *credential.
 			if mcp.Auth == nil || mcp.Auth.Grant == "" {
 				continue
 			}
-			if _, err := store.Get(credential.Provider(mcp.Auth.Grant)); err != nil {
+			cred, err := store.Get(credential.Provider(mcp.Auth.Grant))
+			if err == nil && cred.GrantExpired(time.Now()) {
+				add(MissingGrant{Grant: mcp.Auth.Grant, Reason: ReasonExpired, FixCommand: "moat grant " + grantToCommand(mcp.Auth.Grant), Promptable: true})
+				continue
+			}
+			if err != nil {
 				reason := classifyMissingReason(err)
 				detail := ""
 				if reason == ReasonReadFailed {
@@ -6,7 +6,9 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/majorcontext/moat/internal/config"
 	"github.com/majorcontext/moat/internal/credential"
@@ -36,6 +38,48 @@ func TestAppendMCPGrantsExported(t *testing.T) {
 	}
 }
 
+func TestExpandGrantBundles(t *testing.T) {
+	bundles := map[string][]string{
+		"web-dev": {"github", "npm", "vercel"},
+		"infra":   {"aws", "github"},
+	}
+	got, origins, err := expandGrantBundles([]string{"web-dev", "npm", "infra", "ssh:github.com"}, bundles)
+	if err != nil {
+		t.Fatalf("expandGrantBundles: %v", err)
+	}
+	want := []string{"github", "npm", "vercel", "aws", "ssh:github.com"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("grants = %v, want %v", got, want)
+	}
+	// npm was also listed explicitly, so it is not attributed to web-dev;
+	// github keeps the first bundle that pulled it in.
+	wantOrigins := map[string]string{"github": "web-dev", "vercel": "web-dev", "aws": "infra"}
+	if fmt.Sprint(origins) != fmt.Sprint(wantOrigins) {
+		t.Errorf("origins = %v, want %v", origins, wantOrigins)
+	}
+}
+
+func TestExpandGrantBundlesNoBundles(t *testing.T) {
+	grants := []string{"github", "web-dev"}
+	got, origins, err := expandGrantBundles(grants, nil)
+	if err != nil {
+		t.Fatalf("expandGrantBundles: %v", err)
+	}
+	if fmt.Sprint(got) != fmt.Sprint(grants) || origins != nil {
+		t.Errorf("got %v, %v; want grants unchanged and no origins", got, origins)
+	}
+}
+
+func TestExpandGrantBundlesShadowsProvider(t *testing.T) {
+	_, _, err := expandGrantBundles([]string{"github"}, map[string][]string{"github": {"npm"}})
+	if err == nil {
+		t.Fatal("expected error for a bundle named after a provider")
+	}
+	if !strings.Contains(err.Error(), "same name as the github provider") {
+		t.Errorf("error = %v", err)
+	}
+}
+
 func newGrantsTestStore(t *testing.T) *credential.FileStore {
 	t.Helper()
 	key := make([]byte, 32)
@@ -90,7 +134,7 @@ func TestDetectMissingGrantsMatchesValidators(t *testing.T) {
 	grants := AppendMCPGrants([]string{"github"}, cfg)
 
 	detected := len(DetectMissingGrants(grants, cfg, store)) > 0
-	rejected := validateGrants(grants, store) != nil || validateMCPGrants(cfg, store) != nil
+	rejected := validateGrants(grants, nil, store) != nil || validateMCPGrants(cfg, store) != nil
 	if detected != rejected {
 		t.Fatalf("missing case: detector=%v validators=%v — they must agree", detected, rejected)
 	}
@@ -105,7 +149,7 @@ func TestDetectMissingGrantsMatchesValidators(t *testing.T) {
 		}
 	}
 	detected = len(DetectMissingGrants(grants, cfg, full)) > 0
-	rejected = validateGrants(grants, full) != nil || validateMCPGrants(cfg, full) != nil
+	rejected = validateGrants(grants, nil, full) != nil || validateMCPGrants(cfg, full) != nil
 	if detected || rejected {
 		t.Fatalf("present case: detector=%v validators=%v — both must report none", detected, rejected)
 	}
@@ -212,3 +256,35 @@ func TestDetectMissingGrantsDecryptFailed(t *testing.T) {
 		}
 	}
 }
+
+func TestDetectMissingGrantsExpired(t *testing.T) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatalf("rand.Read: %v", err)
+	}
+	store, err := credential.NewFileStore(t.TempDir(), key)
+	if err != nil {
+		t.Fatalf("NewFileStore: %v", err)
+	}
+	for _, p := range []string{"github", "mcp:render"} {
+		cred := credential.Credential{Provider: credential.Provider(p), Token: "tok"}
+		cred.SetGrantExpiry(time.Now().Add(-time.Minute))
+		if err := store.Save(cred); err != nil {
+			t.Fatalf("Save %s: %v", p, err)
+		}
+	}
+
+	cfg := &config.Config{MCP: []config.MCPServerConfig{
+		{Name: "render", Auth: &config.MCPAuthConfig{Grant: "mcp:render"}},
+	}}
+	got := DetectMissingGrants(AppendMCPGrants([]string{"github"}, cfg), cfg, store)
+	by := map[string]MissingGrant{}
+	for _, m := range got {
+		by[m.Grant] = m
+	}
+	for _, g := range []string{"github", "mcp:render"} {
+		if m, ok := by[g]; !ok || m.Reason != ReasonExpired || !m.Promptable {
+			t.Errorf("%s: want promptable ReasonExpired, got %+v (ok=%v)", g, m, ok)
+		}
+	}
+}
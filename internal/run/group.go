@@ -0,0 +1,127 @@
+package run
+
+// This file holds run groups: related runs (a pipeline, a swarm, a
+// supervisor and its workers) listed, stopped, and cleaned as a unit.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/majorcontext/moat/internal/log"
+	"github.com/majorcontext/moat/internal/metering"
+)
+
+// maxGroupLen bounds group IDs so they stay readable in tables.
+const maxGroupLen = 63
+
+// ValidateGroup checks a group ID given with --group. IDs use the
+// characters of label keys.
+func ValidateGroup(id string) error {
+	if len(id) > maxGroupLen || !labelKeyRe.MatchString(id) {
+		return fmt.Errorf("invalid group %q: use up to %d letters, digits, '.', '_', '-', or '/', starting and ending with a letter or digit", id, maxGroupLen)
+	}
+	return nil
+}
+
+// GroupSummary is the aggregate status of a run group.
+type GroupSummary struct {
+	ID string `json:"id"`
+	// State rolls up the runs' states: running if any run is running,
+	// starting if any is being created or started, failed if any failed,
+	// and stopped once every run has stopped.
+	State     State           `json:"state"`
+	Runs      int             `json:"runs"`
+	States    map[State]int   `json:"states"`
+	CreatedAt time.Time       `json:"created_at"` // earliest run
+	Usage     metering.Totals `json:"usage"`
+}
+
+// GroupRuns returns the runs in group id, newest first.
+func (m *Manager) GroupRuns(id string) []*Run {
+	var runs []*Run
+	for _, r := range m.List() {
+		if r.Group == id {
+			runs = append(runs, r)
+		}
+	}
+	sort.Slice(runs, func(i, j int) bool { return runs[i].CreatedAt.After(runs[j].CreatedAt) })
+	return runs
+}
+
+// Groups returns a summary of every group with at least one run, sorted by
+// ID. Costs use prices.
+func (m *Manager) Groups(prices metering.Prices) []GroupSummary {
+	byGroup := make(map[string][]*Run)
+	for _, r := range m.List() {
+		if r.Group != "" {
+			byGroup[r.Group] = append(byGroup[r.Group], r)
+		}
+	}
+	summaries := make([]GroupSummary, 0, len(byGroup))
+	for id, runs := range byGroup {
+		summaries = append(summaries, SummarizeGroup(id, runs, prices))
+	}
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].ID < summaries[j].ID })
+	return summaries
+}
+
+// SummarizeGroup rolls up the states and LLM usage of a group's runs.
+func SummarizeGroup(id string, runs []*Run, prices metering.Prices) GroupSummary {
+	s := GroupSummary{ID: id, Runs: len(runs), States: make(map[State]int)}
+	usage := make([]metering.RunUsage, 0, len(runs))
+	for _, r := range runs {
+		s.States[r.GetState()]++
+		if s.CreatedAt.IsZero() || r.CreatedAt.Before(s.CreatedAt) {
+			s.CreatedAt = r.CreatedAt
+		}
+		if r.Store == nil {
+			continue
+		}
+		records, err := r.Store.ReadUsage()
+		if err != nil {
+			log.Debug("reading run usage", "run_id", r.ID, "error", err)
+			continue
+		}
+		usage = append(usage, metering.RunUsage{RunID: r.ID, Group: id, Usage: records})
+	}
+	s.Usage = metering.BuildReport(usage, nil, time.Time{}, time.Time{}, prices).Total
+	s.State = groupState(s.States)
+	return s
+}
+
+// groupState rolls up run states; see GroupSummary.State.
+func groupState(states map[State]int) State {
+	switch {
+	case states[StateRunning] > 0:
+		return StateRunning
+	case states[StateCreated] > 0 || states[StateStarting] > 0:
+		return StateStarting
+	case states[StateStopping] > 0:
+		return StateStopping
+	case states[StateFailed] > 0:
+		return StateFailed
+	}
+	return StateStopped
+}
+
+// StopGroup stops every running run in group id and returns the IDs of the
+// runs it stopped. It keeps going past a run that fails to stop and returns
+// the errors together.
+func (m *Manager) StopGroup(ctx context.Context, id string) ([]string, error) {
+	var stopped []string
+	var errs []error
+	for _, r := range m.GroupRuns(id) {
+		if r.GetState() != StateRunning {
+			continue
+		}
+		if err := m.Stop(ctx, r.ID); err != nil {
+			errs = append(errs, fmt.Errorf("stopping run %s: %w", r.ID, err))
+			continue
+		}
+		stopped = append(stopped, r.ID)
+	}
+	return stopped, errors.Join(errs...)
+}
@@ -0,0 +1,98 @@
+package run
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/majorcontext/moat/internal/metering"
+	"github.com/majorcontext/moat/internal/storage"
+)
+
+func TestValidateGroup(t *testing.T) {
+	for _, id := range []string{"nightly", "triage-2024.06", "team/pipeline"} {
+		if err := ValidateGroup(id); err != nil {
+			t.Errorf("ValidateGroup(%q) = %v", id, err)
+		}
+	}
+	for _, id := range []string{"", "-lead", "has space", string(make([]byte, 64))} {
+		if err := ValidateGroup(id); err == nil {
+			t.Errorf("ValidateGroup(%q) succeeded", id)
+		}
+	}
+}
+
+func TestGroupState(t *testing.T) {
+	tests := []struct {
+		states map[State]int
+		want   State
+	}{
+		{map[State]int{StateRunning: 1, StateFailed: 1, StateStopped: 2}, StateRunning},
+		{map[State]int{StateCreated: 1, StateStopped: 1}, StateStarting},
+		{map[State]int{StateFailed: 1, StateStopped: 3}, StateFailed},
+		{map[State]int{StateStopped: 2}, StateStopped},
+	}
+	for _, tt := range tests {
+		if got := groupState(tt.states); got != tt.want {
+			t.Errorf("groupState(%v) = %s, want %s", tt.states, got, tt.want)
+		}
+	}
+}
+
+func TestManagerGroups(t *testing.T) {
+	base := t.TempDir()
+	now := time.Now()
+	newRun := func(id, group string, state State, age time.Duration) *Run {
+		store, err := storage.NewRunStore(base, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &Run{ID: id, Name: id, Group: group, State: state, Store: store, CreatedAt: now.Add(-age)}
+	}
+	lead := newRun("run_lead", "swarm", StateRunning, 2*time.Hour)
+	worker := newRun("run_worker", "swarm", StateStopped, time.Hour)
+	other := newRun("run_other", "nightly", StateFailed, time.Minute)
+	loose := newRun("run_loose", "", StateRunning, time.Minute)
+
+	usage := storage.Usage{Timestamp: now, Provider: "anthropic", Model: "claude-sonnet-4-5", InputTokens: 1_000_000}
+	for _, r := range []*Run{lead, worker} {
+		if err := r.Store.WriteUsage(usage); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	m := &Manager{runs: map[string]*Run{}}
+	for _, r := range []*Run{lead, worker, other, loose} {
+		m.runs[r.ID] = r
+	}
+
+	runs := m.GroupRuns("swarm")
+	if len(runs) != 2 || runs[0] != worker || runs[1] != lead {
+		t.Fatalf("GroupRuns(swarm) = %v, want worker then lead", runs)
+	}
+
+	groups := m.Groups(metering.DefaultPrices())
+	if len(groups) != 2 || groups[0].ID != "nightly" || groups[1].ID != "swarm" {
+		t.Fatalf("Groups = %+v, want nightly and swarm", groups)
+	}
+	swarm := groups[1]
+	if swarm.State != StateRunning || swarm.Runs != 2 || swarm.States[StateRunning] != 1 || swarm.States[StateStopped] != 1 {
+		t.Errorf("swarm summary = %+v", swarm)
+	}
+	if !swarm.CreatedAt.Equal(lead.CreatedAt) {
+		t.Errorf("CreatedAt = %v, want the earliest run's", swarm.CreatedAt)
+	}
+	want := 2 * usage.InputTokens
+	if swarm.Usage.InputTokens != want || swarm.Usage.Runs != 2 || swarm.Usage.CostUSD <= 0 {
+		t.Errorf("usage = %+v, want %d input tokens over 2 priced runs", swarm.Usage, want)
+	}
+	if groups[0].State != StateFailed || groups[0].Usage.Requests != 0 {
+		t.Errorf("nightly summary = %+v", groups[0])
+	}
+
+	// Only running runs are stopped; a group with none has nothing to do.
+	stopped, err := m.StopGroup(context.Background(), "nightly")
+	if err != nil || len(stopped) != 0 {
+		t.Errorf("StopGroup(nightly) = %v, %v; want nothing stopped", stopped, err)
+	}
+}
@@ -0,0 +1,156 @@
+package run
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/majorcontext/moat/internal/config"
+	"github.com/majorcontext/moat/internal/container"
+	"github.com/majorcontext/moat/internal/storage"
+)
+
+// ideMountDir is where the run's sshd keys are mounted in the container
+// (MOAT_SSHD_DIR in moat-init.sh).
+const ideMountDir = "/moat/ide"
+
+// ideUser is the account IDEs log in as: the agent's user.
+const ideUser = "moatuser"
+
+// IDEAttach describes how to reach a run's SSH server from the host.
+type IDEAttach struct {
+	// HostAlias names the run in SSH config, e.g. "moat-my-agent".
+	HostAlias string
+	// Port is the loopback port the container's sshd is published on.
+	Port int
+	User string
+	// IdentityFile is the run's private key.
+	IdentityFile string
+	// KnownHostsFile pins the run's host key under HostKeyAlias, so the
+	// first connection needs no trust-on-first-use prompt.
+	KnownHostsFile string
+	HostKeyAlias   string
+}
+
+// SSHConfig returns an ssh_config Host block for the run.
+func (a IDEAttach) SSHConfig() string {
+	return fmt.Sprintf(`Host %s
+  HostName 127.0.0.1
+  Port %d
+  User %s
+  IdentityFile %q
+  IdentitiesOnly yes
+  UserKnownHostsFile %q
+  HostKeyAlias %s
+  StrictHostKeyChecking yes
+`, a.HostAlias, a.Port, a.User, a.IdentityFile, a.KnownHostsFile, a.HostKeyAlias)
+}
+
+// ideDir returns the directory holding a run's IDE keys, inside the run's
+// storage directory so the keys are removed with the run.
+func ideDir(runID string) string {
+	return filepath.Join(storage.DefaultBaseDir(), runID, "ide")
+}
+
+// setupIDE mints a host key and a client key for the run's SSH server. The
+// host's private key and the client's public key are written to a
+// subdirectory mounted into the container; the client's private key and a
+// known_hosts entry for the host key stay on the host. It returns the
+// directory (for cleanup), the mount, and the env vars moat-init reads.
+func setupIDE(runID string) (string, container.MountConfig, []string, error) {
+	dir := ideDir(runID)
+	sshdDir := filepath.Join(dir, "sshd")
+	if err := os.MkdirAll(sshdDir, 0o700); err != nil {
+		return "", container.MountConfig{}, nil, fmt.Errorf("creating IDE key directory: %w", err)
+	}
+	fail := func(err error) (string, container.MountConfig, []string, error) {
+		os.RemoveAll(dir)
+		return "", container.MountConfig{}, nil, fmt.Errorf("minting IDE keys: %w", err)
+	}
+
+	hostPub, err := writeSSHKey(filepath.Join(sshdDir, "ssh_host_ed25519_key"), "moat host "+runID)
+	if err != nil {
+		return fail(err)
+	}
+	clientPub, err := writeSSHKey(filepath.Join(dir, "id_ed25519"), "moat ide "+runID)
+	if err != nil {
+		return fail(err)
+	}
+	if err := os.WriteFile(filepath.Join(sshdDir, "authorized_keys"), ssh.MarshalAuthorizedKey(clientPub), 0o600); err != nil {
+		return fail(err)
+	}
+	knownHost := ideHostKeyAlias(runID) + " " + string(ssh.MarshalAuthorizedKey(hostPub))
+	if err := os.WriteFile(filepath.Join(dir, "known_hosts"), []byte(knownHost), 0o600); err != nil {
+		return fail(err)
+	}
+
+	mount := container.MountConfig{Source: sshdDir, Target: ideMountDir, ReadOnly: true}
+	env := []string{"MOAT_SSHD=1", "MOAT_SSHD_DIR=" + ideMountDir}
+	return dir, mount, env, nil
+}
+
+// writeSSHKey generates an ed25519 key, writes its private half to path in
+// OpenSSH format, and returns the public half.
+func writeSSHKey(path, comment string) (ssh.PublicKey, error) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	block, err := ssh.MarshalPrivateKey(priv, comment)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, pem.EncodeToMemory(block), 0o600); err != nil {
+		return nil, err
+	}
+	return ssh.NewPublicKey(pub)
+}
+
+// ideHostKeyAlias is the name the run's host key is pinned under. It uses
+// the run ID, not the loopback port, so a port reused by a later run never
+// matches a stale entry.
+func ideHostKeyAlias(runID string) string {
+	return "moat-" + runID
+}
+
+// IDEAttach returns how to reach a running run's SSH server. The run must
+// have been started with ide.enabled.
+func (m *Manager) IDEAttach(ctx context.Context, runID string) (IDEAttach, error) {
+	r, err := m.Get(runID)
+	if err != nil {
+		return IDEAttach{}, err
+	}
+	if !r.IDE {
+		return IDEAttach{}, fmt.Errorf("run %s was not started with IDE attach (set ide.enabled: true in moat.yaml)", r.Name)
+	}
+	if r.GetState() != StateRunning {
+		return IDEAttach{}, fmt.Errorf("run %s is not running", r.Name)
+	}
+	rt, err := m.runtimeForRun(r)
+	if err != nil {
+		return IDEAttach{}, err
+	}
+	bindings, err := rt.GetPortBindings(ctx, r.ContainerID)
+	if err != nil {
+		return IDEAttach{}, fmt.Errorf("getting port bindings: %w", err)
+	}
+	port, ok := bindings[config.IDEPort]
+	if !ok {
+		return IDEAttach{}, fmt.Errorf("run %s has no SSH port published (the %s runtime does not publish ports to the host)", r.Name, rt.Type())
+	}
+	dir := ideDir(r.ID)
+	return IDEAttach{
+		HostAlias:      "moat-" + r.Name,
+		Port:           port,
+		User:           ideUser,
+		IdentityFile:   filepath.Join(dir, "id_ed25519"),
+		KnownHostsFile: filepath.Join(dir, "known_hosts"),
+		HostKeyAlias:   ideHostKeyAlias(r.ID),
+	}, nil
+}
@@ -0,0 +1,92 @@
+package run
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func TestSetupIDE(t *testing.T) {
+	t.Setenv("MOAT_HOME", t.TempDir())
+
+	dir, mount, env, err := setupIDE("run_abc123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mount.Source != filepath.Join(dir, "sshd") || mount.Target != ideMountDir || !mount.ReadOnly {
+		t.Errorf("mount = %+v", mount)
+	}
+	if !slices.Contains(env, "MOAT_SSHD=1") {
+		t.Errorf("env = %v, missing MOAT_SSHD=1", env)
+	}
+
+	// The client's private key stays out of the mounted directory.
+	if _, err := os.Stat(filepath.Join(mount.Source, "id_ed25519")); !os.IsNotExist(err) {
+		t.Errorf("client key is in the container mount (stat err = %v)", err)
+	}
+
+	// The container authorizes the client key the host keeps.
+	clientKey, err := os.ReadFile(filepath.Join(dir, "id_ed25519"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.ParsePrivateKey(clientKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	authorized, err := os.ReadFile(filepath.Join(mount.Source, "authorized_keys"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(authorized, ssh.MarshalAuthorizedKey(signer.PublicKey())) {
+		t.Errorf("authorized_keys = %q, want the client key", authorized)
+	}
+
+	// known_hosts pins the container's host key under the run's alias.
+	hostKey, err := os.ReadFile(filepath.Join(mount.Source, "ssh_host_ed25519_key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hostSigner, err := ssh.ParsePrivateKey(hostKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	knownHosts, err := os.ReadFile(filepath.Join(dir, "known_hosts"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "moat-run_abc123 " + string(ssh.MarshalAuthorizedKey(hostSigner.PublicKey()))
+	if string(knownHosts) != want {
+		t.Errorf("known_hosts = %q, want %q", knownHosts, want)
+	}
+}
+
+func TestIDEAttachSSHConfig(t *testing.T) {
+	a := IDEAttach{
+		HostAlias:      "moat-demo",
+		Port:           49152,
+		User:           ideUser,
+		IdentityFile:   "/home/me/.moat/runs/run_1/ide/id_ed25519",
+		KnownHostsFile: "/home/me/.moat/runs/run_1/ide/known_hosts",
+		HostKeyAlias:   "moat-run_1",
+	}
+	got := a.SSHConfig()
+	for _, want := range []string{
+		"Host moat-demo\n",
+		"HostName 127.0.0.1\n",
+		"Port 49152\n",
+		"User moatuser\n",
+		`IdentityFile "/home/me/.moat/runs/run_1/ide/id_ed25519"`,
+		"HostKeyAlias moat-run_1\n",
+		"StrictHostKeyChecking yes\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("SSHConfig() missing %q:\n%s", want, got)
+		}
+	}
+}
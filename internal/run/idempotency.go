@@ -0,0 +1,65 @@
+package run
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/majorcontext/moat/internal/log"
+	"github.com/majorcontext/moat/internal/storage"
+)
+
+// ExistsError is returned by Create when Options.IdempotencyKey names a run
+// that was already created. Callers report or follow Run rather than
+// starting a duplicate.
+type ExistsError struct {
+	Run *Run
+}
+
+func (e *ExistsError) Error() string {
+	return fmt.Sprintf("run %s already exists for this idempotency key (%s)", e.Run.ID, e.Run.GetState())
+}
+
+// claimRunID takes an exclusive lock on runID under baseDir, waiting for a
+// concurrent Create of the same ID to finish. The lock lives beside the run
+// directories rather than in one so that Create's cleanup of a failed run
+// never removes a lock another process holds. The returned func releases it.
+func claimRunID(ctx context.Context, baseDir, runID string) (func(), error) {
+	if err := os.MkdirAll(baseDir, 0o700); err != nil {
+		return nil, fmt.Errorf("creating runs directory: %w", err)
+	}
+	f, err := os.OpenFile(filepath.Join(baseDir, runID+".lock"), os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("opening run lock: %w", err)
+	}
+	if err := flockContext(ctx, f); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("waiting for another create of run %s: %w", runID, err)
+	}
+	// Closing the file releases the flock.
+	return func() { f.Close() }, nil
+}
+
+// existingRun returns the run with runID if one was created, loading it from
+// disk when another process created it after this manager started.
+func (m *Manager) existingRun(baseDir, runID string) *Run {
+	if r, err := m.Get(runID); err == nil {
+		return r
+	}
+	if _, err := os.Stat(filepath.Join(baseDir, runID, "metadata.json")); err != nil {
+		return nil
+	}
+	store, err := storage.NewRunStore(baseDir, runID)
+	if err != nil {
+		return nil
+	}
+	meta, err := store.LoadMetadata()
+	if err != nil || meta.ContainerID == "" {
+		log.Debug("ignoring run without a container", "id", runID, "error", err)
+		return nil
+	}
+	m.registerPersistedRun(State(meta.State), false, false, meta, store, runID, nil)
+	r, _ := m.Get(runID)
+	return r
+}
@@ -0,0 +1,65 @@
+package run
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/majorcontext/moat/internal/storage"
+)
+
+func TestClaimRunID(t *testing.T) {
+	base := t.TempDir()
+	release, err := claimRunID(context.Background(), base, "run_0123456789ab")
+	if err != nil {
+		t.Fatalf("claimRunID() = %v", err)
+	}
+
+	// A second claim waits for the first and gives up with its context.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := claimRunID(ctx, base, "run_0123456789ab"); err == nil {
+		t.Fatal("second claimRunID() succeeded while the first was held")
+	}
+
+	release()
+	release2, err := claimRunID(context.Background(), base, "run_0123456789ab")
+	if err != nil {
+		t.Fatalf("claimRunID() after release = %v", err)
+	}
+	release2()
+}
+
+func TestExistingRun(t *testing.T) {
+	base := t.TempDir()
+	m := &Manager{runs: map[string]*Run{}}
+
+	if r := m.existingRun(base, "run_0123456789ab"); r != nil {
+		t.Fatalf("existingRun() = %v for an unknown ID", r)
+	}
+
+	// A run another process created after this manager loaded its runs.
+	store, err := storage.NewRunStore(base, "run_0123456789ab")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SaveMetadata(storage.Metadata{Name: "nightly", ContainerID: "abc", State: string(StateStopped), IdempotencyKey: "k"}); err != nil {
+		t.Fatal(err)
+	}
+	r := m.existingRun(base, "run_0123456789ab")
+	if r == nil || r.Name != "nightly" || r.GetState() != StateStopped || r.IdempotencyKey != "k" {
+		t.Fatalf("existingRun() = %+v, want the persisted run", r)
+	}
+
+	// A run whose create failed before it had a container is not reused.
+	store, err = storage.NewRunStore(base, "run_ba9876543210")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SaveMetadata(storage.Metadata{Name: "half"}); err != nil {
+		t.Fatal(err)
+	}
+	if r := m.existingRun(base, "run_ba9876543210"); r != nil {
+		t.Errorf("existingRun() = %v for a run without a container", r)
+	}
+}
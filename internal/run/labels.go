@@ -0,0 +1,103 @@
+package run
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// labelKeyRe restricts label keys to the character set Docker and Kubernetes
+// accept, so labels can be forwarded to other systems unchanged.
+var labelKeyRe = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9._/-]*[A-Za-z0-9])?$`)
+
+// maxLabelValueLen bounds label values; labels are for grouping, not payloads.
+const maxLabelValueLen = 256
+
+// ParseLabels parses --label flag values of the form key=value into a map.
+// An empty value ("ticket=") is allowed; a missing "=" or a repeated key is
+// an error.
+func ParseLabels(specs []string) (map[string]string, error) {
+	if len(specs) == 0 {
+		return nil, nil
+	}
+	labels := make(map[string]string, len(specs))
+	for _, spec := range specs {
+		key, value, ok := strings.Cut(spec, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid label %q: expected key=value (e.g., team=payments)", spec)
+		}
+		if err := validateLabel(key, value); err != nil {
+			return nil, err
+		}
+		if _, dup := labels[key]; dup {
+			return nil, fmt.Errorf("label %q specified more than once", key)
+		}
+		labels[key] = value
+	}
+	return labels, nil
+}
+
+func validateLabel(key, value string) error {
+	if !labelKeyRe.MatchString(key) {
+		return fmt.Errorf("invalid label key %q: use letters, digits, '.', '_', '-', or '/', starting and ending with a letter or digit", key)
+	}
+	if len(value) > maxLabelValueLen {
+		return fmt.Errorf("label %q: value exceeds %d characters", key, maxLabelValueLen)
+	}
+	if strings.ContainsFunc(value, func(r rune) bool { return r < 0x20 || r == 0x7f }) {
+		return fmt.Errorf("label %q: value must not contain control characters", key)
+	}
+	return nil
+}
+
+// ParseLabelSelector parses `moat list -l` values. Each value is either
+// key=value (the run's label must equal value) or a bare key (the run must
+// have the label, with any value).
+func ParseLabelSelector(specs []string) (map[string]*string, error) {
+	if len(specs) == 0 {
+		return nil, nil
+	}
+	sel := make(map[string]*string, len(specs))
+	for _, spec := range specs {
+		key, value, hasValue := strings.Cut(spec, "=")
+		if !labelKeyRe.MatchString(key) {
+			return nil, fmt.Errorf("invalid label selector %q: expected key or key=value", spec)
+		}
+		if hasValue {
+			sel[key] = &value
+		} else {
+			sel[key] = nil
+		}
+	}
+	return sel, nil
+}
+
+// MatchesLabels reports whether labels satisfy every term of the selector.
+// A nil or empty selector matches everything.
+func MatchesLabels(labels map[string]string, sel map[string]*string) bool {
+	for key, want := range sel {
+		got, ok := labels[key]
+		if !ok {
+			return false
+		}
+		if want != nil && got != *want {
+			return false
+		}
+	}
+	return true
+}
+
+// FormatLabels renders labels as "k1=v1,k2=v2" sorted by key.
+func FormatLabels(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = k + "=" + labels[k]
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,102 @@
+package run
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/majorcontext/moat/internal/storage"
+)
+
+func TestParseLabels(t *testing.T) {
+	got, err := ParseLabels([]string{"team=payments", "ticket=JIRA-123", "note="})
+	if err != nil {
+		t.Fatalf("ParseLabels: %v", err)
+	}
+	want := map[string]string{"team": "payments", "ticket": "JIRA-123", "note": ""}
+	if len(got) != len(want) {
+		t.Fatalf("ParseLabels = %v, want %v", got, want)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("label %q = %q, want %q", k, got[k], v)
+		}
+	}
+
+	if got, err := ParseLabels(nil); err != nil || got != nil {
+		t.Errorf("ParseLabels(nil) = %v, %v; want nil, nil", got, err)
+	}
+}
+
+func TestParseLabels_Invalid(t *testing.T) {
+	tests := []struct {
+		specs   []string
+		wantErr string
+	}{
+		{[]string{"team"}, "expected key=value"},
+		{[]string{"=payments"}, "invalid label key"},
+		{[]string{"-team=x"}, "invalid label key"},
+		{[]string{"team=a", "team=b"}, "more than once"},
+		{[]string{"team=a\nb"}, "control characters"},
+		{[]string{"team=" + strings.Repeat("x", maxLabelValueLen+1)}, "exceeds"},
+	}
+	for _, tt := range tests {
+		_, err := ParseLabels(tt.specs)
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("ParseLabels(%q) error = %v, want containing %q", tt.specs, err, tt.wantErr)
+		}
+	}
+}
+
+func TestMatchesLabels(t *testing.T) {
+	labels := map[string]string{"team": "payments", "ticket": "JIRA-123"}
+	tests := []struct {
+		sel  []string
+		want bool
+	}{
+		{nil, true},
+		{[]string{"team=payments"}, true},
+		{[]string{"team=payments", "ticket=JIRA-123"}, true},
+		{[]string{"team"}, true},
+		{[]string{"team=search"}, false},
+		{[]string{"team=payments", "env"}, false},
+		{[]string{"team="}, false},
+	}
+	for _, tt := range tests {
+		sel, err := ParseLabelSelector(tt.sel)
+		if err != nil {
+			t.Fatalf("ParseLabelSelector(%q): %v", tt.sel, err)
+		}
+		if got := MatchesLabels(labels, sel); got != tt.want {
+			t.Errorf("MatchesLabels(%q) = %v, want %v", tt.sel, got, tt.want)
+		}
+	}
+
+	if _, err := ParseLabelSelector([]string{"bad key=x"}); err == nil {
+		t.Error("ParseLabelSelector should reject invalid keys")
+	}
+}
+
+func TestFormatLabels(t *testing.T) {
+	got := FormatLabels(map[string]string{"ticket": "JIRA-123", "team": "payments"})
+	if want := "team=payments,ticket=JIRA-123"; got != want {
+		t.Errorf("FormatLabels = %q, want %q", got, want)
+	}
+}
+
+func TestLabelsPersistedInMetadata(t *testing.T) {
+	store, err := storage.NewRunStore(t.TempDir(), "run_labels")
+	if err != nil {
+		t.Fatalf("NewRunStore: %v", err)
+	}
+	r := &Run{ID: "run_labels", Name: "labels", Store: store, Labels: map[string]string{"team": "payments"}}
+	if err := r.SaveMetadata(); err != nil {
+		t.Fatalf("SaveMetadata: %v", err)
+	}
+	meta, err := store.LoadMetadata()
+	if err != nil {
+		t.Fatalf("LoadMetadata: %v", err)
+	}
+	if meta.Labels["team"] != "payments" {
+		t.Errorf("persisted Labels = %v, want team=payments", meta.Labels)
+	}
+}
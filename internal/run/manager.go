@@ -35,6 +35,8 @@ const (
 const (
 	syntheticProxyHost   = hostnames.Proxy
 	syntheticHostGateway = hostnames.HostGateway
+	syntheticAzureHost   = hostnames.AzureIdentity
+	syntheticGCPHost     = hostnames.GCPMetadata
 )
 
 // Manager handles run lifecycle operations.
@@ -47,6 +49,10 @@ type Manager struct {
 	daemonClient   *daemon.Client
 	mu             sync.RWMutex
 
+	// retry retries transient failures of image pulls, network creation,
+	// and sidecar and service starts in Create.
+	retry retryPolicy
+
 	// ctx/cancel for general manager lifecycle.
 	ctx    context.Context
 	cancel context.CancelFunc
@@ -96,6 +102,21 @@ type ManagerOptions struct {
 	ReapOrphanNetworks bool
 }
 
+// kubernetesOptions converts the global config's kubernetes settings into
+// runtime options.
+func kubernetesOptions(k config.KubernetesConfig) container.KubernetesOptions {
+	return container.KubernetesOptions{
+		Context:      k.Context,
+		Namespace:    k.Namespace,
+		Workspace:    k.Workspace,
+		Claim:        k.Claim,
+		ProxyHost:    k.ProxyHost,
+		ProxyPort:    k.ProxyPort,
+		Registry:     k.Registry,
+		SidecarImage: k.SidecarImage,
+	}
+}
+
 // NewManagerWithOptions creates a new run manager with the given options.
 func NewManagerWithOptions(opts ManagerOptions) (*Manager, error) {
 	var runtimeOpts container.RuntimeOptions
@@ -107,6 +128,12 @@ func NewManagerWithOptions(opts ManagerOptions) (*Manager, error) {
 		runtimeOpts = container.DefaultRuntimeOptions()
 	}
 
+	globalCfg, err := config.LoadGlobal()
+	if err != nil {
+		globalCfg = config.DefaultGlobalConfig()
+	}
+	runtimeOpts.Kubernetes = kubernetesOptions(globalCfg.Kubernetes)
+
 	pool, err := container.NewRuntimePool(runtimeOpts)
 	if err != nil {
 		return nil, fmt.Errorf("initializing container runtime: %w", err)
@@ -114,7 +141,6 @@ func NewManagerWithOptions(opts ManagerOptions) (*Manager, error) {
 
 	proxyDir := filepath.Join(config.GlobalConfigDir(), "proxy")
 
-	globalCfg, _ := config.LoadGlobal()
 	proxyPort := globalCfg.Proxy.Port
 
 	lifecycle, err := routing.NewLifecycle(proxyDir, proxyPort)
@@ -136,6 +162,7 @@ func NewManagerWithOptions(opts ManagerOptions) (*Manager, error) {
 		cancel:         cancel,
 		monitorCtx:     monitorCtx,
 		monitorCancel:  monitorCancel,
+		retry:          newRetryPolicy(globalCfg.Retry.EffectiveAttempts()),
 	}
 
 	// Load existing runs from disk and reconcile with container state.
@@ -257,7 +284,7 @@ func (m *Manager) List() []*Run {
 	return runs
 }
 
-// RuntimeType returns the container runtime type (docker or apple).
+// RuntimeType returns the container runtime type (docker, apple, podman, or kubernetes).
 // Uses a value cached at init, so it is safe to call after Close().
 func (m *Manager) RuntimeType() string {
 	return m.runtimeType
@@ -329,6 +356,9 @@ func (m *Manager) Close() error {
 		if err := r.stopSSHAgentServer(); err != nil {
 			log.Debug("failed to stop SSH agent during manager close", "run", r.ID, "error", err)
 		}
+		if err := r.stopDockerAPIServer(); err != nil {
+			log.Debug("failed to stop docker API filter during manager close", "run", r.ID, "error", err)
+		}
 	}
 	m.mu.RUnlock()
 
@@ -15,9 +15,11 @@ import (
 
 	"github.com/majorcontext/moat/internal/config"
 	"github.com/majorcontext/moat/internal/credential"
+	"github.com/majorcontext/moat/internal/errcode"
 	"github.com/majorcontext/moat/internal/log"
 	"github.com/majorcontext/moat/internal/provider"
 	"github.com/majorcontext/moat/internal/providers/claude"
+	"github.com/majorcontext/moat/internal/providers/gemini"
 	"github.com/majorcontext/moat/internal/ui"
 )
 
@@ -110,6 +112,12 @@ func (m *Manager) setupGeminiStaging(ctx context.Context, geminiProvider provide
 			}
 		}
 	}
+	// Vertex AI project routing authenticates with the gcp grant's
+	// metadata server.
+	if gemini.VertexProject(geminiCred) != "" && !hasGrant(opts.Grants, "gcp") {
+		return nil, errcode.Wrap(errcode.MissingGrants, fmt.Errorf("the gemini grant routes through Vertex AI project %s, which requires the gcp grant\n\nRun: moat grant gcp, then add gcp to grants: in moat.yaml",
+			gemini.VertexProject(geminiCred)))
+	}
 
 	// Build local MCP server config from gemini.mcp entries.
 	var geminiLocalMCP map[string]provider.LocalMCPServerConfig
@@ -7,7 +7,6 @@ import (
 	"context"
 	"os"
 	"path/filepath"
-	"strings"
 	"time"
 
 	"github.com/majorcontext/moat/internal/container"
@@ -95,48 +94,6 @@ func (m *Manager) captureLogs(r *Run) {
 	log.Debug("logs captured successfully", "runID", r.ID, "bytes", len(allLogs))
 }
 
-// runProviderStoppedHooks iterates the run's grant providers and calls
-// OnRunStopped on each that implements provider.RunStoppedHook. Returned
-// metadata is merged into r.ProviderMeta.
-func runProviderStoppedHooks(r *Run) {
-	// Ensure hooks run exactly once — multiple call sites race
-	// (monitorContainerExit goroutine vs StartAttached/Stop on main goroutine).
-	if !r.providerHooksDone.CompareAndSwap(false, true) {
-		return
-	}
-
-	ctx := provider.RunStoppedContext{
-		Workspace: r.Workspace,
-		StartedAt: r.StartedAt,
-	}
-
-	for _, grant := range r.Grants {
-		grantName := strings.Split(grant, ":")[0]
-		prov := provider.Get(grantName)
-		if prov == nil {
-			continue
-		}
-		hook, ok := prov.(provider.RunStoppedHook)
-		if !ok {
-			continue
-		}
-		meta := hook.OnRunStopped(ctx)
-		if len(meta) == 0 {
-			continue
-		}
-		// ProviderMeta is guarded by stateMu — SaveMetadata may read it
-		// concurrently from monitorContainerExit/Stop.
-		r.stateMu.Lock()
-		if r.ProviderMeta == nil {
-			r.ProviderMeta = make(map[string]string)
-		}
-		for k, v := range meta {
-			r.ProviderMeta[k] = v
-		}
-		r.stateMu.Unlock()
-	}
-}
-
 // cleanupResources tears down all resources associated with a run. It is
 // idempotent — only the first call does work, subsequent calls are no-ops.
 // This is safe to call from Stop, Wait, monitorContainerExit, or Destroy.
@@ -178,6 +135,11 @@ func (m *Manager) cleanupResources(ctx context.Context, r *Run) {
 			log.Debug("cleanup: stopping SSH agent", "error", err)
 		}
 
+		// Stop the Docker API filter
+		if err := r.stopDockerAPIServer(); err != nil {
+			log.Debug("cleanup: stopping docker API filter", "error", err)
+		}
+
 		// Stop service containers
 		if rt != nil && len(r.ServiceContainers) > 0 {
 			svcMgr := rt.ServiceManager()
@@ -216,14 +178,17 @@ func (m *Manager) cleanupResources(ctx context.Context, r *Run) {
 		// survive run-stop so the user can `moat snapshot` (extract) it before
 		// tearing down. Volume removal happens only in Destroy (full teardown).
 
-		// Remove network (with force-disconnect fallback)
-		if rt != nil && r.NetworkID != "" {
+		// Remove networks (with force-disconnect fallback)
+		for _, networkID := range []string{r.InternalNetworkID, r.NetworkID} {
+			if rt == nil || networkID == "" {
+				continue
+			}
 			netMgr := rt.NetworkManager()
 			if netMgr != nil {
-				if err := netMgr.RemoveNetwork(ctx, r.NetworkID); err != nil {
-					log.Debug("cleanup: network removal failed, trying force", "network", r.NetworkID, "error", err)
-					if forceErr := netMgr.ForceRemoveNetwork(ctx, r.NetworkID); forceErr != nil {
-						log.Debug("cleanup: force network removal failed", "network", r.NetworkID, "error", forceErr)
+				if err := netMgr.RemoveNetwork(ctx, networkID); err != nil {
+					log.Debug("cleanup: network removal failed, trying force", "network", networkID, "error", err)
+					if forceErr := netMgr.ForceRemoveNetwork(ctx, networkID); forceErr != nil {
+						log.Debug("cleanup: force network removal failed", "network", networkID, "error", forceErr)
 					}
 				}
 			}
@@ -247,7 +212,7 @@ func (m *Manager) cleanupResources(ctx context.Context, r *Run) {
 		}
 
 		// Clean up temp directories
-		for _, dir := range []string{r.awsTempDir, r.ClaudeConfigTempDir, r.CodexConfigTempDir, r.GeminiConfigTempDir, r.PiConfigTempDir} {
+		for _, dir := range []string{r.awsTempDir, r.ctlTempDir, r.ideDir, r.sshKnownHostsDir, r.ClaudeConfigTempDir, r.CodexConfigTempDir, r.GeminiConfigTempDir, r.PiConfigTempDir} {
 			if dir != "" {
 				if err := os.RemoveAll(dir); err != nil {
 					log.Debug("cleanup: failed to remove temp dir", "path", dir, "error", err)
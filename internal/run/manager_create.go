@@ -10,6 +10,7 @@ import (
 	"encoding/base64"
 	"fmt"
 	"io"
+	"maps"
 	"net"
 	"net/url"
 	"os"
@@ -31,15 +32,20 @@ import (
 	"github.com/majorcontext/moat/internal/credential"
 	"github.com/majorcontext/moat/internal/daemon"
 	"github.com/majorcontext/moat/internal/deps"
+	"github.com/majorcontext/moat/internal/dockerproxy"
+	"github.com/majorcontext/moat/internal/id"
 	"github.com/majorcontext/moat/internal/image"
 
 	internalkeep "github.com/majorcontext/moat/internal/keep"
 	"github.com/majorcontext/moat/internal/langserver"
 	"github.com/majorcontext/moat/internal/log"
 	"github.com/majorcontext/moat/internal/name"
+	"github.com/majorcontext/moat/internal/netrules"
 	"github.com/majorcontext/moat/internal/provider"
 	awsprov "github.com/majorcontext/moat/internal/providers/aws"
+	azureprov "github.com/majorcontext/moat/internal/providers/azure"
 	"github.com/majorcontext/moat/internal/providers/claude" // only for settings types (LoadAllSettings, Settings, MarketplaceConfig) - provider setup uses provider interfaces
+	gcpprov "github.com/majorcontext/moat/internal/providers/gcp"
 	"github.com/majorcontext/moat/internal/runctx"
 	"github.com/majorcontext/moat/internal/secrets"
 	"github.com/majorcontext/moat/internal/snapshot"
@@ -69,6 +75,23 @@ func getWorkspaceOwner(workspace string) (uid, gid int) {
 
 // Create initializes a new run without starting it.
 func (m *Manager) Create(ctx context.Context, opts Options) (resRun *Run, retErr error) {
+	// An idempotency key fixes the run ID. Hold the ID's lock for the whole
+	// create so a concurrent create with the same key waits, then finds
+	// this run instead of starting a second one. This comes before name
+	// resolution, which would otherwise reject the retry's reused name.
+	runID := generateID()
+	if opts.IdempotencyKey != "" {
+		runID = id.FromKey("run", opts.IdempotencyKey)
+		release, err := claimRunID(ctx, storage.DefaultBaseDir(), runID)
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+		if existing := m.existingRun(storage.DefaultBaseDir(), runID); existing != nil {
+			return nil, &ExistsError{Run: existing}
+		}
+	}
+
 	// Resolve agent name
 	agentName := opts.Name
 	if agentName == "" {
@@ -104,6 +127,50 @@ func (m *Manager) Create(ctx context.Context, opts Options) (resRun *Run, retErr
 		if err := config.CheckVolumeRuntimeSupport(opts.Config.Volumes, isApple); err != nil {
 			return nil, err
 		}
+		if err := config.CheckDeviceRuntimeSupport(opts.Config.Container.Devices, isApple); err != nil {
+			return nil, err
+		}
+	}
+
+	// Host devices are deny-by-default: each one moat.yaml asks for must be
+	// allowed by the host's global config, which the workspace cannot edit.
+	var devicePaths []string
+	if opts.Config != nil && len(opts.Config.Container.Devices) > 0 {
+		globalCfg, _ := config.LoadGlobal()
+		paths, err := globalCfg.Devices.CheckDevicePolicy(opts.Config.Container.Devices)
+		if err != nil {
+			return nil, err
+		}
+		devicePaths = paths
+	}
+
+	// No-egress runs are validated before MCP grants are folded in so the
+	// error names the mcp: block rather than an implied grant. The signing
+	// key is loaded here, before any resources exist, so a key problem needs
+	// no cleanup.
+	var isolationSigner *audit.Signer
+	if opts.NoEgress {
+		if err := validateNoEgress(opts); err != nil {
+			return nil, err
+		}
+		opts.Config = noEgressConfig(opts.Config)
+		signer, err := newIsolationSigner()
+		if err != nil {
+			return nil, err
+		}
+		isolationSigner = signer
+	}
+
+	// Expand grant bundles from the global config before anything else reads
+	// the grant list, so MCP folding, validation, and the credential loop all
+	// see provider grants. A missing or malformed global config is reported
+	// where global mounts are added below.
+	var grantOrigins map[string]string
+	if globalCfg, err := config.LoadGlobal(); err == nil {
+		opts.Grants, grantOrigins, err = expandGrantBundles(opts.Grants, globalCfg.GrantBundles)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	// Auto-include MCP auth grants so the credential processing loop loads
@@ -137,6 +204,10 @@ func (m *Manager) Create(ctx context.Context, opts Options) (resRun *Run, retErr
 		return credStoreCache, credStoreErr
 	}
 
+	if err := checkClaudeProviderGrant(opts.Config, opts.Grants); err != nil {
+		return nil, err
+	}
+
 	// Validate grants before allocating any resources (proxy, container, etc.)
 	needsGrantValidation := len(opts.Grants) > 0 || (opts.Config != nil && len(opts.Config.MCP) > 0)
 	if needsGrantValidation {
@@ -144,7 +215,10 @@ func (m *Manager) Create(ctx context.Context, opts Options) (resRun *Run, retErr
 		if err != nil {
 			return nil, err
 		}
-		if err := validateGrants(opts.Grants, store); err != nil {
+		if err := validateGrants(opts.Grants, grantOrigins, store); err != nil {
+			return nil, err
+		}
+		if err := checkGrantRestrictions(opts.Grants, opts.Workspace, store); err != nil {
 			return nil, err
 		}
 		if opts.Config != nil && len(opts.Config.MCP) > 0 {
@@ -154,23 +228,37 @@ func (m *Manager) Create(ctx context.Context, opts Options) (resRun *Run, retErr
 		}
 	}
 
-	// Get ports from config
+	// Get ports from config. A virtual display adds its noVNC viewer as the
+	// "gui" endpoint, so the routing proxy serves it at gui.<agent>.localhost.
 	var ports map[string]int
 	if opts.Config != nil && len(opts.Config.Ports) > 0 {
 		ports = opts.Config.Ports
 	}
+	if opts.Config != nil && opts.Config.Display.Enabled {
+		ports = maps.Clone(ports)
+		if ports == nil {
+			ports = make(map[string]int, 1)
+		}
+		ports[config.DisplayEndpoint] = config.DisplayPort
+	}
+	ideEnabled := opts.Config != nil && opts.Config.IDE.Enabled
 
 	r := &Run{
-		ID:            generateID(),
-		Name:          agentName,
-		Workspace:     opts.Workspace,
-		Grants:        opts.Grants,
-		Ports:         ports,
-		State:         StateCreated,
-		KeepContainer: opts.KeepContainer,
-		Interactive:   opts.Interactive,
-		CreatedAt:     time.Now(),
-		exitCh:        make(chan struct{}),
+		ID:             runID,
+		Name:           agentName,
+		Workspace:      opts.Workspace,
+		Grants:         opts.Grants,
+		Labels:         opts.Labels,
+		Group:          opts.Group,
+		IdempotencyKey: opts.IdempotencyKey,
+		PullRequest:    opts.PullRequest,
+		Ports:          ports,
+		IDE:            ideEnabled,
+		State:          StateCreated,
+		KeepContainer:  opts.KeepContainer,
+		Interactive:    opts.Interactive,
+		CreatedAt:      time.Now(),
+		exitCh:         make(chan struct{}),
 	}
 
 	// Create the run directory before any network/container operations so that
@@ -200,6 +288,7 @@ func (m *Manager) Create(ctx context.Context, opts Options) (resRun *Run, retErr
 	// Proxy environment and mount configuration
 	var proxyEnv []string
 	var providerEnv []string // Provider-specific env vars (e.g., dummy ANTHROPIC_API_KEY)
+	envSrc := envSources{}   // Where each env var came from, for `moat env`
 	var hostAddr string      // Host address for proxy (may be rewritten for custom networks)
 	var mounts []container.MountConfig
 	var tmpfsMounts []container.TmpfsMount
@@ -234,7 +323,7 @@ func (m *Manager) Create(ctx context.Context, opts Options) (resRun *Run, retErr
 		mounts = append(mounts, container.MountConfig{
 			Source:   opts.Workspace,
 			Target:   "/workspace",
-			ReadOnly: false,
+			ReadOnly: opts.NoEgress,
 		})
 	}
 
@@ -250,12 +339,29 @@ func (m *Manager) Create(ctx context.Context, opts Options) (resRun *Run, retErr
 			mounts = append(mounts, container.MountConfig{
 				Source:   info.MainGitDir,
 				Target:   info.MainGitDir,
-				ReadOnly: false,
+				ReadOnly: opts.NoEgress,
 			})
 			log.Debug("mounted main git dir for worktree", "path", info.MainGitDir)
 		}
 	}
 
+	// Mount the repositories from workspaces: under /workspace.
+	var workspaceRepos []workspaceRepo
+	if opts.Config != nil && len(opts.Config.Workspaces) > 0 {
+		if volumeMode {
+			return nil, fmt.Errorf("workspaces: cannot be combined with workspace mode volume; use workspace.mode: bind")
+		}
+		repos, repoMounts, err := resolveWorkspaceRepos(opts.Config.Workspaces, opts.Workspace, r.Name, worktree.ProvisionFor(opts.Config), opts.NoEgress)
+		if err != nil {
+			return nil, err
+		}
+		workspaceRepos = repos
+		mounts = append(mounts, repoMounts...)
+		for _, repo := range repos {
+			r.WorkspaceRepos = append(r.WorkspaceRepos, repo.WorkspaceRepo)
+		}
+	}
+
 	// Add mounts from config
 	if opts.Config != nil {
 		for _, me := range opts.Config.Mounts {
@@ -296,7 +402,7 @@ func (m *Manager) Create(ctx context.Context, opts Options) (resRun *Run, retErr
 			mounts = append(mounts, container.MountConfig{
 				Source:   gm.Source,
 				Target:   gm.Target,
-				ReadOnly: gm.ReadOnly,
+				ReadOnly: gm.ReadOnly || opts.NoEgress,
 			})
 			log.Debug("added global mount", "source", gm.Source, "target", gm.Target)
 		}
@@ -390,9 +496,11 @@ func (m *Manager) Create(ctx context.Context, opts Options) (resRun *Run, retErr
 
 		// Capture daemon build commit and capabilities for version skew detection.
 		var daemonCapabilities []string
+		var daemonQuotas map[string]daemon.QuotaStatus
 		if health, healthErr := daemonCl.Health(ctx); healthErr == nil {
 			r.DaemonCommit = health.Commit
 			daemonCapabilities = health.Capabilities
+			daemonQuotas = health.Quotas
 		} else {
 			log.Warn("daemon health check failed", "error", healthErr)
 		}
@@ -400,6 +508,7 @@ func (m *Manager) Create(ctx context.Context, opts Options) (resRun *Run, retErr
 		// Create a RunContext that implements credential.ProxyConfigurer.
 		// Providers will configure their credentials on this context.
 		runCtx := daemon.NewRunContext(r.ID)
+		runCtx.Workspace = opts.Workspace
 
 		// Load credentials for granted providers
 		store, err := openCredStore()
@@ -407,8 +516,16 @@ func (m *Manager) Create(ctx context.Context, opts Options) (resRun *Run, retErr
 			return nil, err
 		}
 
-		// Track Anthropic/Claude credential for base URL proxy setup
+		// Track Anthropic/Claude credential (and its grant) for base URL
+		// proxy setup
 		var anthropicCred *provider.Credential
+		var anthropicGrant string
+
+		// Send routes of messaging grants, capped by messaging.max_messages
+		var sendRoutes []daemon.SendRoute
+
+		// Checks grants have the daemon run before forwarding requests
+		var requestGuards []daemon.RequestGuard
 
 		if err == nil {
 			for _, grant := range opts.Grants {
@@ -456,19 +573,55 @@ func (m *Manager) Create(ctx context.Context, opts Options) (resRun *Run, retErr
 				if prov == nil {
 					continue
 				}
-				// Configure the RunContext (which implements ProxyConfigurer)
-				prov.ConfigureProxy(runCtx, provCred)
+				// Configure the RunContext on the grant's behalf, so the
+				// daemon can revoke everything it injects when it expires.
+				prov.ConfigureProxy(runCtx.ForGrant(grantName), provCred)
+				if sp, ok := prov.(provider.SendingProvider); ok {
+					for _, r := range sp.SendRoutes() {
+						sendRoutes = append(sendRoutes, daemon.SendRoute{Grant: grantName, Host: r.Host, Method: r.Method, Path: r.Path})
+					}
+				}
+				if gp, ok := prov.(provider.GuardingProvider); ok {
+					for _, g := range gp.RequestGuards(provCred) {
+						requestGuards = append(requestGuards, daemon.RequestGuard{Grant: grantName, Host: g.Host, Kind: g.Kind})
+					}
+				}
 				envVars := prov.ContainerEnv(provCred)
 				log.Debug("adding provider env vars", "provider", credName, "vars", envVars)
 				providerEnv = append(providerEnv, envVars...)
+				envSrc.note(envVars, "grant "+grantName)
 
 				// Capture Anthropic/Claude credential for base URL proxy setup
 				if credName == credential.ProviderClaude || credName == credential.ProviderAnthropic {
 					anthropicCred = provCred
+					anthropicGrant = grantName
 				}
 
-				// Handle AWS endpoint provider
-				if ep := provider.GetEndpoint(string(credName)); ep != nil {
+				// Handle Azure endpoint provider: the daemon serves tokens from the
+				// host's az CLI or for the granted service principal; the
+				// container env is set up after registration.
+				if ep := provider.GetEndpoint(string(credName)); ep != nil && credName == credential.ProviderAzure {
+					azureCfg, err := azureprov.ConfigFromCredential(provCred)
+					if err != nil {
+						return nil, fmt.Errorf("parsing Azure credential: %w", err)
+					}
+					runCtx.AzureConfig = &daemon.AzureConfig{
+						Tenant:       azureCfg.Tenant,
+						Subscription: azureCfg.Subscription,
+						ClientID:     azureCfg.ClientID,
+					}
+				} else if ep != nil && credName == credential.ProviderGCP {
+					// The daemon serves GCE metadata from the gcp grant in
+					// the credential store; only the project is sent.
+					runCtx.GCPConfig = &daemon.GCPConfig{Project: provCred.Metadata[gcpprov.MetaKeyProject]}
+					if opts.Config != nil && opts.Config.Claude.Provider == claude.APIProviderVertex {
+						if runCtx.GCPConfig.Project == "" {
+							cleanupDaemonRun()
+							return nil, fmt.Errorf("claude.provider: vertex needs a project; run 'moat grant gcp --project <id>'")
+						}
+						runCtx.GCPConfig.VertexHost = claude.VertexHost(opts.Config.Claude.Region)
+					}
+				} else if ep != nil {
 					// AWS credentials are handled via credential endpoint
 					// Parse stored config from Metadata (new format) with fallback to Scopes (legacy)
 					awsCfg, err := awsprov.ConfigFromCredential(provCred)
@@ -501,6 +654,7 @@ func (m *Manager) Create(ctx context.Context, opts Options) (resRun *Run, retErr
 						SessionDuration: awsCfg.SessionDuration,
 						ExternalID:      awsCfg.ExternalID,
 						Profile:         awsCfg.Profile,
+						Bedrock:         opts.Config != nil && opts.Config.Claude.Provider == claude.APIProviderBedrock,
 					}
 				}
 			}
@@ -518,7 +672,29 @@ func (m *Manager) Create(ctx context.Context, opts Options) (resRun *Run, retErr
 				runCtx.NetworkAllow = append(runCtx.NetworkAllow, entry.Host)
 			}
 			runCtx.AllowedHostPorts = opts.Config.Network.Host
+			runCtx.Mirror = opts.Config.Network.Mirror
+			runCtx.Faults = opts.Config.Network.Faults
+			runCtx.Scrub = opts.Config.Network.Scrub
+			runCtx.Capture = opts.Config.Network.Capture
+			if u := opts.Config.Network.Uploads; u != nil {
+				runCtx.UploadGuard = daemon.NewUploadGuard(u.MaxMB, u.Hosts)
+			}
+			for i, t := range opts.Config.Network.Transforms {
+				spec := daemon.TransformerSpec{Host: t.Host, Kind: t.Kind, Args: t.Args}
+				if err := daemon.ApplyTransformerSpec(runCtx, spec); err != nil {
+					return nil, fmt.Errorf("network.transforms[%d]: %w", i, err)
+				}
+			}
+		}
+
+		if len(sendRoutes) > 0 {
+			var messaging config.MessagingConfig
+			if opts.Config != nil {
+				messaging = opts.Config.Messaging
+			}
+			runCtx.SendGuard = &daemon.SendGuard{Max: messaging.Limit(), Routes: sendRoutes}
 		}
+		runCtx.RequestGuards = requestGuards
 
 		// Configure MCP servers on the RunContext
 		if opts.Config != nil && len(opts.Config.MCP) > 0 {
@@ -639,6 +815,116 @@ func (m *Manager) Create(ctx context.Context, opts Options) (resRun *Run, retErr
 			return nil, fmt.Errorf("proxy daemon is too old for this CLI (missing 'host-gateway-v2' capability); run 'moat proxy restart' to upgrade")
 		}
 
+		// An older daemon ignores the mirror field, which would silently
+		// disable mirroring the user asked for.
+		if runCtx.Mirror != nil && !slices.Contains(daemonCapabilities, daemon.CapRequestMirror) {
+			return nil, fmt.Errorf("proxy daemon does not support network.mirror (missing 'request-mirror' capability); run 'moat proxy restart' to upgrade")
+		}
+		// An older daemon POSTs JSON records to the mirror URL, which a
+		// replay endpoint cannot consume.
+		if runCtx.Mirror != nil && runCtx.Mirror.Replays() && !slices.Contains(daemonCapabilities, daemon.CapMirrorReplay) {
+			return nil, fmt.Errorf("proxy daemon does not replay requests for network.mirror (missing 'mirror-replay' capability); run 'moat proxy restart' to upgrade, or set network.mirror.format: record")
+		}
+
+		// An older daemon ignores faults, which would leave a chaos test
+		// silently running against a healthy API.
+		if len(runCtx.Faults) > 0 && !slices.Contains(daemonCapabilities, daemon.CapFaults) {
+			return nil, fmt.Errorf("proxy daemon does not support network.faults (missing 'fault-injection' capability); run 'moat proxy restart' to upgrade")
+		}
+
+		// An older daemon ignores the scrub policy and would let upstream
+		// session cookies into the container.
+		if runCtx.Scrub != nil && !slices.Contains(daemonCapabilities, daemon.CapResponseScrub) {
+			return nil, fmt.Errorf("proxy daemon does not support network.scrub (missing 'response-scrub-policy' capability); run 'moat proxy restart' to upgrade")
+		}
+
+		// An older daemon ignores the Azure config and would leave the
+		// container's IDENTITY_ENDPOINT unserved.
+		if runCtx.AzureConfig != nil && !slices.Contains(daemonCapabilities, daemon.CapAzureIdentity) {
+			return nil, fmt.Errorf("proxy daemon does not support the azure grant (missing 'azure-identity' capability); run 'moat proxy restart' to upgrade")
+		}
+
+		// An older daemon ignores the client ID and would serve tokens for the
+		// host's az account instead of the granted service principal.
+		if runCtx.AzureConfig != nil && runCtx.AzureConfig.ClientID != "" && !slices.Contains(daemonCapabilities, daemon.CapAzureServicePrincipal) {
+			return nil, fmt.Errorf("proxy daemon does not support Azure service principal grants (missing 'azure-service-principal' capability); run 'moat proxy restart' to upgrade")
+		}
+
+		// An older daemon ignores the GCP config and would leave the
+		// container's GCE_METADATA_HOST unserved.
+		if runCtx.GCPConfig != nil && !slices.Contains(daemonCapabilities, daemon.CapGCPMetadata) {
+			return nil, fmt.Errorf("proxy daemon does not support the gcp grant (missing 'gcp-metadata' capability); run 'moat proxy restart' to upgrade")
+		}
+
+		// An older daemon would neither sign Bedrock requests nor inject a
+		// Vertex AI token, so Claude Code could not authenticate.
+		if ((runCtx.AWSConfig != nil && runCtx.AWSConfig.Bedrock) || (runCtx.GCPConfig != nil && runCtx.GCPConfig.VertexHost != "")) &&
+			!slices.Contains(daemonCapabilities, daemon.CapClaudeCloud) {
+			return nil, fmt.Errorf("proxy daemon does not support claude.provider %s (missing 'claude-cloud' capability); run 'moat proxy restart' to upgrade", opts.Config.Claude.Provider)
+		}
+
+		// An older daemon drops response transformer kinds it doesn't know,
+		// which would silently skip the transforms the user configured.
+		if len(runCtx.TransformerSpecs) > 0 && !slices.Contains(daemonCapabilities, daemon.CapTransformers) {
+			return nil, fmt.Errorf("proxy daemon does not support network.transforms response kinds (missing 'transformer-registry' capability); run 'moat proxy restart' to upgrade")
+		}
+		// An older daemon forwards requests without the path rewrites and
+		// body templates the user configured.
+		if slices.ContainsFunc(runCtx.TransformerSpecs, func(s daemon.TransformerSpec) bool { return daemon.IsRequestTransformer(s.Kind) }) &&
+			!slices.Contains(daemonCapabilities, daemon.CapRequestTransforms) {
+			return nil, fmt.Errorf("proxy daemon does not support network.transforms request kinds (missing 'request-transforms' capability); run 'moat proxy restart' to upgrade")
+		}
+		// An older daemon skips request kinds it does not know.
+		if slices.ContainsFunc(runCtx.TransformerSpecs, func(s daemon.TransformerSpec) bool { return s.Kind == daemon.TransformRequestStarlark }) &&
+			!slices.Contains(daemonCapabilities, daemon.CapStarlarkTransforms) {
+			return nil, fmt.Errorf("proxy daemon does not support request-starlark transforms (missing 'starlark-transforms' capability); run 'moat proxy restart' to upgrade")
+		}
+
+		// An older daemon treats a CIDR range as a literal hostname, so
+		// nothing in the range would be reachable.
+		if slices.ContainsFunc(runCtx.NetworkRules, func(hr netrules.HostRules) bool { return netrules.IsCIDRHost(hr.Host) }) &&
+			!slices.Contains(daemonCapabilities, daemon.CapNetworkCIDR) {
+			return nil, fmt.Errorf("proxy daemon does not support CIDR ranges in network.rules (missing 'network-cidr' capability); run 'moat proxy restart' to upgrade")
+		}
+
+		// An older daemon ignores the send guard and would forward every
+		// message the agent sends.
+		if runCtx.SendGuard != nil && !slices.Contains(daemonCapabilities, daemon.CapSendGuard) {
+			return nil, fmt.Errorf("proxy daemon does not support messaging send caps (missing 'send-guard' capability); run 'moat proxy restart' to upgrade")
+		}
+
+		// An older daemon ignores the upload caps and would forward uploads
+		// of any size.
+		if runCtx.UploadGuard != nil && !slices.Contains(daemonCapabilities, daemon.CapUploadGuard) {
+			return nil, fmt.Errorf("proxy daemon does not support network.uploads (missing 'upload-guard' capability); run 'moat proxy restart' to upgrade")
+		}
+
+		// An older daemon records headers and bodies without redaction.
+		if runCtx.Capture == config.NetworkCaptureFull && !slices.Contains(daemonCapabilities, daemon.CapNetworkCapture) {
+			return nil, fmt.Errorf("proxy daemon does not support network.capture (missing 'network-capture' capability); run 'moat proxy restart' to upgrade")
+		}
+
+		// An older daemon ignores request guards, such as a test-mode-only
+		// Stripe grant's live-mode check, and would forward what they refuse.
+		if len(runCtx.RequestGuards) > 0 && !slices.Contains(daemonCapabilities, daemon.CapRequestGuards) {
+			return nil, fmt.Errorf("proxy daemon does not support grant request guards (missing 'request-guards' capability); run 'moat proxy restart' to upgrade")
+		}
+
+		// An older daemon ignores grant scopes and would inject or forward
+		// a grant's credential to any host.
+		if runCtx.HasGrants() && !slices.Contains(daemonCapabilities, daemon.CapGrantScope) {
+			return nil, fmt.Errorf("proxy daemon does not support grant scopes (missing 'grant-scope' capability); run 'moat proxy restart' to upgrade")
+		}
+
+		// The daemon reads LLM quotas when it starts, so one started before
+		// they were set or changed would not enforce them.
+		if globalErr == nil && !quotasMatch(globalCfg.Quotas, daemonQuotas) {
+			if len(globalCfg.Quotas) > 0 {
+				return nil, fmt.Errorf("proxy daemon is not enforcing the quotas in %s; run 'moat proxy restart' to apply them", filepath.Join(config.GlobalConfigDir(), "config.yaml"))
+			}
+			ui.Warnf("The proxy daemon still enforces LLM quotas removed from the global config; run 'moat proxy restart' to lift them")
+		}
+
 		// Get proxy host address — needed for registration, proxy URL, and firewall.
 		// Must be set before buildRegisterRequest so HostGateway is included.
 		hostAddr = m.defaultRuntime().GetHostAddress()
@@ -684,8 +970,12 @@ func (m *Manager) Create(ctx context.Context, opts Options) (resRun *Run, retErr
 		// Host-network mode is used on Docker Linux when no ports need publishing.
 		// In that mode, the container shares the host loopback, so localhost
 		// must NOT be in NO_PROXY (otherwise it bypasses network.host enforcement).
-		isHostNet := m.defaultRuntime().SupportsHostNetwork() && (opts.Config == nil || len(opts.Config.Ports) == 0)
+		isHostNet := m.defaultRuntime().SupportsHostNetwork() && len(ports) == 0 && opts.Network == nil
 		proxyEnv = buildProxyEnv(regResp.AuthToken, regResp.ProxyPort, isHostNet)
+		if opts.Network != nil {
+			proxyEnv = appendNoProxy(proxyEnv, opts.Network.Peers)
+		}
+		envSrc.note(proxyEnv, "proxy")
 		proxyHost := syntheticProxyHost + ":" + strconv.Itoa(regResp.ProxyPort)
 
 		// Docker-on-Linux resolves the synthetic hostnames via --add-host (set
@@ -725,6 +1015,7 @@ func (m *Manager) Create(ctx context.Context, opts Options) (resRun *Run, retErr
 		proxyEnv = append(proxyEnv, "REQUESTS_CA_BUNDLE="+caCertInContainer)  // Python requests
 		proxyEnv = append(proxyEnv, "NODE_EXTRA_CA_CERTS="+caCertInContainer) // Node.js
 		proxyEnv = append(proxyEnv, "GIT_SSL_CAINFO="+caCertInContainer)      // Git (for HTTPS clones)
+		envSrc.note(proxyEnv[len(proxyEnv)-4:], "proxy")
 
 		// Add provider-specific env vars (collected during credential loading)
 		proxyEnv = append(proxyEnv, providerEnv...)
@@ -745,7 +1036,7 @@ func (m *Manager) Create(ctx context.Context, opts Options) (resRun *Run, retErr
 					"url", opts.Config.Claude.BaseURL, "error", parseErr)
 			} else {
 				// Register credential injection for the base URL host on the RunContext
-				claude.ConfigureBaseURLProxy(runCtx, anthropicCred, baseURL.Host)
+				claude.ConfigureBaseURLProxy(runCtx.ForGrant(anthropicGrant), anthropicCred, baseURL.Host)
 
 				// The relay endpoint runs on the daemon's proxy.
 				// Set ANTHROPIC_BASE_URL to the relay endpoint.
@@ -822,6 +1113,64 @@ region = %s
 			fmt.Printf("AWS credential_process configured (role: %s)\n",
 				filepath.Base(r.AWSCredentialProvider.RoleARN()))
 		}
+
+		// Run Claude Code against Bedrock or Vertex AI. Bedrock requests go
+		// to the daemon's signing relay; Vertex AI requests go through the
+		// proxy, which injects the access token.
+		if runCtx.AWSConfig != nil && runCtx.AWSConfig.Bedrock {
+			cloudEnv := claude.BedrockEnv("http://"+proxyHost+awsprov.BedrockEndpointPath, regResp.AuthToken)
+			proxyEnv = append(proxyEnv, cloudEnv...)
+			envSrc.note(cloudEnv, "claude.provider")
+		}
+		if runCtx.GCPConfig != nil && runCtx.GCPConfig.VertexHost != "" {
+			cloudEnv := claude.VertexEnv(runCtx.GCPConfig.Project, opts.Config.Claude.Region)
+			proxyEnv = append(proxyEnv, cloudEnv...)
+			envSrc.note(cloudEnv, "claude.provider")
+		}
+
+		// Mount moatctl so the agent can ask for snapshots, report progress,
+		// check its budget, and request network approvals.
+		if slices.Contains(daemonCapabilities, daemon.CapMoatctl) {
+			if ctlBinary, err := moatctlBinary(goruntime.GOARCH); err != nil {
+				// Builds made without go generate, such as go install,
+				// lack the helper. Say so rather than leave the agent to
+				// find /moat/bin empty.
+				ui.Warnf("/moat/bin/moatctl will not be available in this run: %v", err)
+			} else {
+				ctlDir, ctlMount, ctlEnv, err := setupMoatctl(proxyHost, regResp.AuthToken, ctlBinary)
+				if err != nil {
+					cleanupDaemonRun()
+					return nil, err
+				}
+				r.ctlTempDir = ctlDir // Track for cleanup
+				mounts = append(mounts, ctlMount)
+				proxyEnv = append(proxyEnv, ctlEnv...)
+			}
+		}
+
+		// Point Azure SDKs and `az login --identity` at the daemon's managed
+		// identity endpoint. IDENTITY_* is the App Service 2019-08-01
+		// protocol; MSI_* covers older clients. The run's proxy token doubles
+		// as the identity secret.
+		if runCtx.AzureConfig != nil {
+			identityURL := "http://" + syntheticAzureHost + azureprov.EndpointPath
+			proxyEnv = append(proxyEnv,
+				"IDENTITY_ENDPOINT="+identityURL,
+				"IDENTITY_HEADER="+regResp.AuthToken,
+				"MSI_ENDPOINT="+identityURL,
+				"MSI_SECRET="+regResp.AuthToken,
+			)
+		}
+
+		// Point Google client libraries at the daemon's metadata server
+		// emulation. GCE_METADATA_IP is what google-auth pings to detect it.
+		if runCtx.GCPConfig != nil {
+			metadataHost := syntheticGCPHost + gcpprov.EndpointPath
+			proxyEnv = append(proxyEnv,
+				"GCE_METADATA_HOST="+metadataHost,
+				"GCE_METADATA_IP="+metadataHost,
+			)
+		}
 	}
 
 	// Set up SSH agent proxy for SSH grants (e.g., git clone git@github.com:...)
@@ -834,11 +1183,43 @@ region = %s
 	}
 	sshServer = sshSetup.server
 	proxyEnv = append(proxyEnv, sshSetup.env...)
+	envSrc.note(sshSetup.env, "grant ssh")
 	mounts = append(mounts, sshSetup.mounts...)
 
+	// IDE attach: sshd in the container accepts only the key minted here.
+	if ideEnabled {
+		ideKeyDir, ideMount, ideEnv, ideErr := setupIDE(r.ID)
+		if ideErr != nil {
+			cleanupDaemonRun()
+			return nil, ideErr
+		}
+		r.ideDir = ideKeyDir // Track for cleanup
+		mounts = append(mounts, ideMount)
+		proxyEnv = append(proxyEnv, ideEnv...)
+	}
+
 	// Configure network mode and extra hosts based on runtime capabilities.
 	needsProxy := r.ProxyAuthToken != ""
-	networkMode, extraHosts := m.resolveNetworkConfig(len(ports) > 0, needsProxy, hostAddr)
+	// A container in host network mode cannot join a shared network.
+	networkMode, extraHosts := m.resolveNetworkConfig(len(ports) > 0 || ideEnabled || opts.Network != nil, needsProxy, hostAddr)
+
+	// Add timezone and fake-clock env vars (before config env so they can be
+	// overridden). The monotonic clock stays real so timeouts and sleeps
+	// behave under a frozen clock.
+	if opts.Config != nil {
+		if tz := opts.Config.Container.Timezone; tz != "" {
+			proxyEnv = append(proxyEnv, "TZ="+tz)
+			envSrc["TZ"] = "container.timezone"
+		}
+		if fakeTime := opts.Config.Container.FakeTime(); fakeTime != "" {
+			proxyEnv = append(proxyEnv,
+				"LD_PRELOAD="+deps.FakeTimeLibPath,
+				"FAKETIME="+fakeTime,
+				"FAKETIME_DONT_FAKE_MONOTONIC=1",
+			)
+			envSrc["FAKETIME"] = "container.clock"
+		}
+	}
 
 	// Add config env vars, filtering out proxy-related variables that would
 	// override moat's proxy settings and re-open the host traffic bypass.
@@ -849,6 +1230,7 @@ region = %s
 				continue
 			}
 			proxyEnv = append(proxyEnv, k+"="+v)
+			envSrc[k] = "moat.yaml env"
 		}
 	}
 
@@ -857,6 +1239,7 @@ region = %s
 	type resolvedSecret struct {
 		name   string
 		scheme string
+		digest string
 	}
 	var resolvedSecrets []resolvedSecret
 	if opts.Config != nil && len(opts.Config.Secrets) > 0 {
@@ -870,7 +1253,19 @@ region = %s
 			resolvedSecrets = append(resolvedSecrets, resolvedSecret{
 				name:   k,
 				scheme: secrets.ParseScheme(opts.Config.Secrets[k]),
+				digest: envDigest(r.ID, k, v),
 			})
+			envSrc[k] = "secret " + secrets.ParseScheme(opts.Config.Secrets[k])
+		}
+		// moat-init also writes each secret to a file under SecretsDir,
+		// which moat secrets refresh rewrites when a secret is rotated.
+		secretNames := slices.Sorted(maps.Keys(resolved))
+		proxyEnv = append(proxyEnv,
+			"MOAT_SECRETS_DIR="+SecretsDir,
+			"MOAT_SECRET_NAMES="+strings.Join(secretNames, " "),
+		)
+		if opts.Config.Hooks.SecretsRefresh != "" {
+			proxyEnv = append(proxyEnv, "MOAT_SECRETS_REFRESH="+opts.Config.Hooks.SecretsRefresh)
 		}
 	}
 
@@ -884,6 +1279,20 @@ region = %s
 		proxyEnv = append(proxyEnv, "MOAT_CLIPBOARD=1", "DISPLAY=:99")
 	}
 
+	// Passed-through devices: moat-init adds moatuser to each device's group
+	// before dropping privileges.
+	if len(devicePaths) > 0 {
+		proxyEnv = append(proxyEnv, "MOAT_DEVICES="+strings.Join(devicePaths, " "))
+	}
+
+	// Virtual display for GUI tools; shares :99 with clipboard bridging.
+	if opts.Config != nil && opts.Config.Display.Enabled {
+		proxyEnv = append(proxyEnv, "MOAT_DISPLAY=1", "MOAT_DISPLAY_RESOLUTION="+opts.Config.Display.Screen())
+		if !needsClipboard {
+			proxyEnv = append(proxyEnv, "DISPLAY=:99")
+		}
+	}
+
 	// Add explicit env vars (highest priority - can override config),
 	// but filter proxy-related vars when proxy is active.
 	for _, e := range opts.Env {
@@ -894,6 +1303,7 @@ region = %s
 			}
 		}
 		proxyEnv = append(proxyEnv, e)
+		envSrc.note([]string{e}, "--env")
 	}
 
 	// Build port bindings for exposed services
@@ -906,6 +1316,20 @@ region = %s
 			portBindings[containerPort] = "0.0.0.0"
 		}
 	}
+	// The display's noVNC viewer has no password, and the routing proxy
+	// listening on localhost does not stop other hosts from reaching the
+	// published port directly; publish it on loopback only.
+	if opts.Config != nil && opts.Config.Display.Enabled {
+		portBindings[config.DisplayPort] = "127.0.0.1"
+	}
+	// The IDE SSH server is not routed by the proxy; publish it on loopback
+	// only so nothing off the host can reach it.
+	if ideEnabled {
+		if portBindings == nil {
+			portBindings = make(map[int]string, 1)
+		}
+		portBindings[config.IDEPort] = "127.0.0.1"
+	}
 
 	// Build MOAT_* environment variables for host injection
 	if len(ports) > 0 {
@@ -975,11 +1399,63 @@ region = %s
 	// Inject host git identity when git is a dependency.
 	gitEnv, hasGit := hostGitIdentity(depList)
 	proxyEnv = append(proxyEnv, gitEnv...)
+	if hasGit {
+		proxyEnv = append(proxyEnv, workspaceReposGitEnv(workspaceRepos)...)
+		var ws config.WorkspaceConfig
+		userSetsGitConfig := envHasKey(opts.Env, "GIT_CONFIG_COUNT")
+		if opts.Config != nil {
+			ws = opts.Config.Workspace
+			userSetsGitConfig = userSetsGitConfig || opts.Config.Env["GIT_CONFIG_COUNT"] != ""
+		}
+		hookEnv := gitHookEnv(ws, agentName, commitTrailers(ws.CommitTrailers, depList, r.ID))
+		if userSetsGitConfig && len(hookEnv) > 0 {
+			ui.Warnf("GIT_CONFIG_COUNT is set; protected branches and commit trailers are not applied for this run")
+		} else {
+			proxyEnv = append(proxyEnv, hookEnv...)
+		}
+	}
+
+	// Mount shared package-manager caches (~/.moat/caches/<tool>) for the
+	// tools implied by the dependency list when caches.enabled is set.
+	if opts.Config != nil {
+		cacheMounts, cacheEnv := packageCacheMounts(opts.Config.Caches, depList)
+		for _, mc := range cacheMounts {
+			if err := os.MkdirAll(mc.Source, 0o755); err != nil {
+				cleanupDaemonRun()
+				cleanupSSH(sshServer)
+				return nil, fmt.Errorf("creating cache directory %s: %w", mc.Source, err)
+			}
+			log.Debug("added package cache mount", "dir", mc.Source, "target", mc.Target)
+		}
+		mounts = append(mounts, cacheMounts...)
+		proxyEnv = append(proxyEnv, cacheEnv...)
+	}
+
+	// Browser dependencies (e.g., playwright) need a larger /dev/shm and
+	// their own proxy settings: Chromium drops the credentials embedded in
+	// HTTPS_PROXY and reads trust anchors from NSS, not SSL_CERT_FILE.
+	ctrNeeds := deps.ContainerNeeds(depList)
+	if ctrNeeds.Browser && needsProxy {
+		browserEnv := buildBrowserProxyEnv(r.ProxyAuthToken, r.ProxyPort)
+		proxyEnv = append(proxyEnv, browserEnv...)
+		envSrc.note(browserEnv, "proxy")
+	}
 
 	// Split dependencies into installable and services
 	serviceDeps := deps.FilterServices(depList)
 	installableDeps := deps.FilterInstallable(depList)
 
+	// A published service is served as an endpoint named after it.
+	for _, dep := range serviceDeps {
+		if spec, ok := deps.GetSpec(dep.Name); ok && spec.Service != nil && spec.Service.Publish {
+			if _, taken := ports[dep.Name]; taken {
+				cleanupDaemonRun()
+				cleanupSSH(sshServer)
+				return nil, fmt.Errorf("ports.%s conflicts with the %s service, which is served as the %q endpoint; rename the port", dep.Name, dep.Name, dep.Name)
+			}
+		}
+	}
+
 	// Resolve docker dependency if present
 	// This validates that Apple containers are not used with docker:host dependency,
 	// and returns the appropriate config for the mode (socket mount for host, privileged for dind).
@@ -991,10 +1467,35 @@ region = %s
 	}
 	// Compute BuildKit configuration (automatic with docker:dind)
 	buildkitCfg := computeBuildKitConfig(dockerConfig, r.ID)
+	var dockerFilter *dockerproxy.Server
 
 	if dockerConfig != nil {
 		switch dockerConfig.Mode {
 		case deps.DockerModeHost:
+			for _, limit := range dockerConfig.Limitations {
+				ui.Infof("docker:host via %s: %s", dockerConfig.Socket, limit)
+			}
+			if opts.Config != nil && opts.Config.Docker.Filter {
+				// Filtered: the container only reaches the host socket
+				// through the Docker API filter.
+				filter, filterErr := startDockerFilter(r.ID, dockerConfig.SocketMount.Source)
+				if filterErr != nil {
+					cleanupDaemonRun()
+					cleanupSSH(sshServer)
+					return nil, filterErr
+				}
+				dockerFilter = filter.server
+				defer func() {
+					if retErr != nil {
+						if err := dockerFilter.Stop(); err != nil {
+							log.Debug("failed to stop docker API filter during cleanup", "error", err)
+						}
+					}
+				}()
+				mounts = append(mounts, filter.mount)
+				proxyEnv = append(proxyEnv, filter.env...)
+				break
+			}
 			// Host mode: mount Docker socket and pass GID for group setup
 			mounts = append(mounts, dockerConfig.SocketMount)
 			proxyEnv = append(proxyEnv, "MOAT_DOCKER_GID="+dockerConfig.GroupID)
@@ -1148,9 +1649,12 @@ region = %s
 	// builder, which can fail to parse BuildKit syntax (e.g., --mount=type=cache
 	// confuses legacy parser line counting, causing "unknown instruction" errors).
 	useBuildKit := os.Getenv("BUILDKIT_HOST") != "" && os.Getenv("MOAT_DISABLE_BUILDKIT") != "1"
-	var baseImage string
+	var baseImage, timezone, fakeTime, locale string
 	if opts.Config != nil {
 		baseImage = opts.Config.BaseImage
+		timezone = opts.Config.Container.Timezone
+		fakeTime = opts.Config.Container.FakeTime()
+		locale = opts.Config.Container.Locale
 	}
 	// NeedsGitIdentity (hasGit) also gates whether moat-init.sh is deployed, which
 	// is what sets git http.proxyAuthMethod=basic for HTTPS git through the proxy
@@ -1167,6 +1671,13 @@ region = %s
 		NeedsGitIdentity:   hasGit,
 		NeedsInitFiles:     imgNeeds.initFiles,
 		NeedsClipboard:     needsClipboard,
+		NeedsBrowserTrust:  ctrNeeds.Browser && needsProxy,
+		NeedsDisplay:       opts.Config != nil && opts.Config.Display.Enabled,
+		NeedsSSHServer:     ideEnabled,
+		NeedsDevices:       len(devicePaths) > 0,
+		NeedsTimezone:      timezone != "",
+		NeedsFakeClock:     fakeTime != "",
+		Locale:             locale,
 		UseBuildKit:        &useBuildKit,
 		ClaudeMarketplaces: claudeMarketplaces,
 		ClaudePlugins:      claudePlugins,
@@ -1278,7 +1789,9 @@ region = %s
 				}
 			}
 			buildOpts.ContextFiles = result.ContextFiles
-			if err := buildMgr.BuildImage(ctx, result.Dockerfile, containerImage, buildOpts); err != nil {
+			if err := m.retry.do(ctx, "Building image", func() error {
+				return buildMgr.BuildImage(ctx, result.Dockerfile, containerImage, buildOpts)
+			}); err != nil {
 				cleanupDaemonRun()
 				return nil, fmt.Errorf("building image with dependencies [%s]: %w",
 					strings.Join(depNames, ", "), err)
@@ -1325,6 +1838,9 @@ region = %s
 	// Set up provider-specific container mounts and init files.
 	providerMounts, initFiles := m.setupProviderMounts(r, opts.Grants, containerHome, openCredStore)
 	mounts = append(mounts, providerMounts...)
+	if dockerConfig != nil && dockerConfig.Mode == deps.DockerModeHost {
+		dropHostDockerConfig(initFiles, containerHome)
+	}
 	if len(initFiles) > 0 {
 		var buf strings.Builder
 		for initPath, content := range initFiles {
@@ -1377,6 +1893,7 @@ region = %s
 			claudeConfig = cfg
 			mounts = append(mounts, claudeConfig.Mounts...)
 			proxyEnv = append(proxyEnv, claudeConfig.Env...)
+			envSrc.note(claudeConfig.Env, "agent claude")
 		}
 	}
 
@@ -1402,6 +1919,7 @@ region = %s
 		codexConfig = cfg
 		mounts = append(mounts, codexConfig.Mounts...)
 		proxyEnv = append(proxyEnv, codexConfig.Env...)
+		envSrc.note(codexConfig.Env, "agent codex")
 	}
 
 	// Set up Gemini staging directory for init script using the provider interface.
@@ -1428,6 +1946,7 @@ region = %s
 		geminiConfig = cfg
 		mounts = append(mounts, geminiConfig.Mounts...)
 		proxyEnv = append(proxyEnv, geminiConfig.Env...)
+		envSrc.note(geminiConfig.Env, "agent gemini")
 	}
 
 	// Set up Pi staging directory for init script using the provider interface.
@@ -1457,6 +1976,7 @@ region = %s
 		piConfig = cfg
 		mounts = append(mounts, piConfig.Mounts...)
 		proxyEnv = append(proxyEnv, piConfig.Env...)
+		envSrc.note(piConfig.Env, "agent pi")
 		// Clean the Pi staging dir on any later error return. On success the run
 		// owns it via r.PiConfigTempDir (cleaned when the run is stopped/destroyed).
 		defer func() {
@@ -1506,6 +2026,16 @@ region = %s
 	}
 	// On macOS/Windows, leave containerUser empty to use the image default (moatuser)
 
+	var usernsMode string
+	if opts.Config != nil && opts.Config.Container.Userns && goruntime.GOOS == "linux" {
+		// keep-id maps the host user to the user the container runs as.
+		mappedUser := containerUser
+		if mappedUser == "" {
+			mappedUser = fmt.Sprintf("%d:%d", moatuserUID, moatuserUID)
+		}
+		usernsMode = m.resolveUsernsMode(ctx, mappedUser, volumeMode)
+	}
+
 	// Determine if container needs privileged mode (only for docker:dind)
 	var privileged bool
 	if dockerConfig != nil && dockerConfig.Privileged {
@@ -1535,7 +2065,12 @@ region = %s
 			cleanupAgentConfig(codexConfig)
 			return nil, fmt.Errorf("BuildKit requires Docker runtime (networks not supported by %s)", m.defaultRuntime().Type())
 		}
-		netID, netErr := netMgr.CreateNetwork(ctx, buildkitCfg.NetworkName)
+		var netID string
+		netErr := m.retry.do(ctx, "Creating network", func() error {
+			var err error
+			netID, err = netMgr.CreateNetwork(ctx, buildkitCfg.NetworkName)
+			return err
+		})
 		if netErr != nil {
 			cleanupDaemonRun()
 			cleanupSSH(sshServer)
@@ -1586,7 +2121,12 @@ region = %s
 			cleanupAgentConfig(codexConfig)
 			return nil, fmt.Errorf("BuildKit requires Docker runtime (sidecars not supported by %s)", m.defaultRuntime().Type())
 		}
-		buildkitContainerID, sidecarErr := sidecarMgr.StartSidecar(ctx, sidecarCfg)
+		var buildkitContainerID string
+		sidecarErr := m.retry.do(ctx, "Starting BuildKit sidecar", func() error {
+			var err error
+			buildkitContainerID, err = sidecarMgr.StartSidecar(ctx, sidecarCfg)
+			return err
+		})
 		if sidecarErr != nil {
 			// Clean up network on failure
 			netMgr := m.defaultRuntime().NetworkManager()
@@ -1672,8 +2212,11 @@ region = %s
 				return nil, fmt.Errorf("service dependencies require network support")
 			}
 			networkName := fmt.Sprintf("moat-%s", r.ID)
-			var netErr error
-			networkID, netErr = netMgr.CreateNetwork(ctx, networkName)
+			netErr := m.retry.do(ctx, "Creating service network", func() error {
+				var err error
+				networkID, err = netMgr.CreateNetwork(ctx, networkName)
+				return err
+			})
 			if netErr != nil {
 				cleanupDaemonRun()
 				cleanupSSH(sshServer)
@@ -1688,6 +2231,38 @@ region = %s
 		// Set network on service manager
 		svcMgr.SetNetworkID(networkID)
 
+		// Services without egress run on an internal network, which has no
+		// route out of the host. The agent and the other services join it
+		// too, so they can still reach them by name.
+		netMgr := m.defaultRuntime().NetworkManager()
+		var internalNetworkID string
+		for _, dep := range serviceDeps {
+			if serviceEgress(opts.Config, dep.Name) {
+				continue
+			}
+			var netErr error
+			if spec, _ := deps.GetSpec(dep.Name); spec.Service != nil && spec.Service.Publish {
+				netErr = fmt.Errorf("services.%s.network.egress: false is not supported for a service served through the routing proxy", dep.Name)
+			} else if internalNetworkID == "" {
+				internalNetworkID, netErr = netMgr.CreateInternalNetwork(ctx, fmt.Sprintf("moat-%s-internal", r.ID))
+				if netErr != nil {
+					netErr = fmt.Errorf("creating internal service network: %w", netErr)
+				}
+			}
+			if netErr != nil {
+				if internalNetworkID != "" {
+					_ = netMgr.RemoveNetwork(ctx, internalNetworkID) //nolint:errcheck
+				}
+				cleanupDaemonRun()
+				cleanupSSH(sshServer)
+				cleanupAgentConfig(claudeConfig)
+				cleanupAgentConfig(codexConfig)
+				cleanupAgentConfig(geminiConfig)
+				return nil, netErr
+			}
+		}
+		r.InternalNetworkID = internalNetworkID
+
 		// Start services
 		r.ServiceContainers = make(map[string]string)
 		var serviceInfos []container.ServiceInfo
@@ -1697,6 +2272,9 @@ region = %s
 			for _, info := range serviceInfos {
 				_ = svcMgr.StopService(ctx, info)
 			}
+			if internalNetworkID != "" {
+				_ = netMgr.RemoveNetwork(ctx, internalNetworkID) //nolint:errcheck
+			}
 		}
 
 		for _, dep := range serviceDeps {
@@ -1733,7 +2311,21 @@ region = %s
 				}
 			}
 
-			info, err := svcMgr.StartService(ctx, svcCfg)
+			egress := serviceEgress(opts.Config, dep.Name)
+			if internalNetworkID != "" {
+				if egress {
+					svcMgr.SetNetworkID(networkID)
+				} else {
+					svcMgr.SetNetworkID(internalNetworkID)
+				}
+			}
+
+			var info container.ServiceInfo
+			err = m.retry.do(ctx, "Starting "+dep.Name+" service", func() error {
+				var startErr error
+				info, startErr = svcMgr.StartService(ctx, svcCfg)
+				return startErr
+			})
 			if err != nil {
 				cleanupServices()
 				cleanupDaemonRun()
@@ -1746,7 +2338,20 @@ region = %s
 
 			serviceInfos = append(serviceInfos, info)
 			r.ServiceContainers[dep.Name] = info.ID
+
+			if internalNetworkID != "" && egress {
+				if connErr := netMgr.ConnectContainer(ctx, internalNetworkID, info.ID, []string{dep.Name}); connErr != nil {
+					cleanupServices()
+					cleanupDaemonRun()
+					cleanupSSH(sshServer)
+					cleanupAgentConfig(claudeConfig)
+					cleanupAgentConfig(codexConfig)
+					cleanupAgentConfig(geminiConfig)
+					return nil, fmt.Errorf("connecting %s service to the internal network: %w", dep.Name, connErr)
+				}
+			}
 		}
+		r.ServiceAccess = serviceAccessRules(opts.Config, serviceInfos)
 
 		// Create run storage early so provision output can be captured in logs.
 		// NewRunStore is idempotent (uses MkdirAll), so it's safe to call now
@@ -1846,6 +2451,18 @@ region = %s
 			}
 			svcEnv := generateServiceEnv(spec.Service, serviceInfos[i], userSpec)
 
+			// Serve published services through the routing proxy.
+			if spec.Service.Publish {
+				if svc, ok := publishService(spec.Service, serviceInfos[i]); ok {
+					if r.PublishedServices == nil {
+						r.PublishedServices = make(map[string]PublishedService)
+					}
+					r.PublishedServices[dep.Name] = svc
+					endpointHost := fmt.Sprintf("%s.%s.localhost:%d", dep.Name, agentName, routingProxyPort())
+					maps.Copy(svcEnv, publishedServiceEnv(dep.Name, endpointHost, svc))
+				}
+			}
+
 			// Sort env var keys for deterministic ordering
 			envKeys := make([]string, 0, len(svcEnv))
 			for k := range svcEnv {
@@ -1855,11 +2472,17 @@ region = %s
 
 			for _, k := range envKeys {
 				proxyEnv = append(proxyEnv, k+"="+svcEnv[k])
+				envSrc[k] = "service " + dep.Name
 			}
 		}
 
 		// Use network for main container
 		networkMode = networkID
+
+		// Restricting the agent's access to services needs iptables too.
+		if len(r.ServiceAccess) > 0 && !r.FirewallEnabled {
+			capAdd = append(capAdd, "NET_ADMIN")
+		}
 	}
 
 	// When a custom network is used (for services or BuildKit), the container
@@ -1908,6 +2531,8 @@ region = %s
 
 	// Extract container resource limits (memory, CPUs, DNS, ulimits) for the run.
 	memoryMB, cpus, dns, ulimits := m.resolveResourceLimits(opts.Config)
+	r.MemoryMB = memoryMB
+	r.Priority = m.resolvePriority(opts)
 
 	// Named-volume roots are chowned to the run user by one of two mutually
 	// exclusive mechanisms (see volumeChownEnv): moat-init on the root-entrypoint
@@ -1951,8 +2576,9 @@ region = %s
 		}()
 	}
 
-	// Create container
-	containerID, err := m.defaultRuntime().CreateContainer(ctx, container.Config{
+	// Create container. The runtime pulls the image first if it is not
+	// present, which is what usually fails transiently.
+	ctrCfg := container.Config{
 		Name:         r.ID,
 		Image:        containerImage,
 		Cmd:          cmd,
@@ -1968,11 +2594,22 @@ region = %s
 		GroupAdd:     groupAdd,
 		Privileged:   privileged,
 		Interactive:  opts.Interactive,
-		HasMoatUser:  needsCustomImage, // moat-built images have moatuser; base images don't
+		HasMoatUser:  needsCustomImage,  // moat-built images have moatuser; base images don't
+		Init:         !needsCustomImage, // moat-built images run tini as PID 1; base images need the runtime's
 		MemoryMB:     memoryMB,
 		CPUs:         cpus,
 		DNS:          dns,
 		Ulimits:      ulimits,
+		ShmSizeMB:    ctrNeeds.ShmSizeMB,
+		Devices:      devicePaths,
+		UsernsMode:   usernsMode,
+		Priority:     r.Priority,
+	}
+	var containerID string
+	err := m.retry.do(ctx, "Creating container", func() error {
+		var createErr error
+		containerID, createErr = m.defaultRuntime().CreateContainer(ctx, ctrCfg)
+		return createErr
 	})
 	if err != nil {
 		// Clean up BuildKit resources on failure
@@ -1993,12 +2630,54 @@ region = %s
 		return nil, fmt.Errorf("creating container: %w", err)
 	}
 
+	if opts.Network != nil {
+		var connErr error
+		if netMgr := m.defaultRuntime().NetworkManager(); netMgr == nil {
+			connErr = fmt.Errorf("shared networks are not supported by %s", m.defaultRuntime().Type())
+		} else {
+			connErr = netMgr.ConnectContainer(ctx, opts.Network.ID, containerID, []string{opts.Network.Alias})
+		}
+		if connErr != nil {
+			if rmErr := m.defaultRuntime().RemoveContainer(ctx, containerID); rmErr != nil {
+				log.Debug("failed to remove container during cleanup", "error", rmErr)
+			}
+			if buildkitCfg.Enabled && r.BuildkitContainerID != "" {
+				_ = m.defaultRuntime().StopContainer(ctx, r.BuildkitContainerID)   //nolint:errcheck
+				_ = m.defaultRuntime().RemoveContainer(ctx, r.BuildkitContainerID) //nolint:errcheck
+				if netMgr := m.defaultRuntime().NetworkManager(); netMgr != nil {
+					_ = netMgr.RemoveNetwork(ctx, r.NetworkID) //nolint:errcheck
+				}
+			}
+			cleanupDaemonRun()
+			cleanupSSH(sshServer)
+			cleanupAgentConfig(claudeConfig)
+			cleanupAgentConfig(codexConfig)
+			cleanupAgentConfig(geminiConfig)
+			return nil, fmt.Errorf("joining shared network: %w", connErr)
+		}
+	}
+
+	if r.InternalNetworkID != "" {
+		if connErr := m.defaultRuntime().NetworkManager().ConnectContainer(ctx, r.InternalNetworkID, containerID, nil); connErr != nil {
+			if rmErr := m.defaultRuntime().RemoveContainer(ctx, containerID); rmErr != nil {
+				log.Debug("failed to remove container during cleanup", "error", rmErr)
+			}
+			cleanupDaemonRun()
+			cleanupSSH(sshServer)
+			cleanupAgentConfig(claudeConfig)
+			cleanupAgentConfig(codexConfig)
+			cleanupAgentConfig(geminiConfig)
+			return nil, fmt.Errorf("joining internal service network: %w", connErr)
+		}
+	}
+
 	r.ContainerID = containerID
 	r.SSHAgentServer = sshServer
+	r.DockerAPIServer = dockerFilter
 
-	// Update daemon with the container ID (phase 2 of registration)
+	// Update daemon with the container IDs (phase 2 of registration)
 	if r.ProxyAuthToken != "" && m.daemonClient != nil {
-		if updErr := m.daemonClient.UpdateRun(ctx, r.ProxyAuthToken, containerID); updErr != nil {
+		if updErr := m.daemonClient.UpdateRun(ctx, r.ProxyAuthToken, r.daemonUpdate()); updErr != nil {
 			log.Debug("failed to update daemon with container ID", "error", updErr)
 		}
 	}
@@ -2016,8 +2695,8 @@ region = %s
 		r.PiConfigTempDir = piConfig.StagingDir
 	}
 
-	// Ensure proxy is running if we have ports to expose
-	if len(ports) > 0 {
+	// Ensure proxy is running if we have ports or services to expose
+	if len(ports) > 0 || len(r.PublishedServices) > 0 {
 		// Enable TLS on the routing proxy
 		if _, tlsErr := m.proxyLifecycle.EnableTLS(); tlsErr != nil {
 			// Clean up container
@@ -2059,6 +2738,12 @@ region = %s
 		r.Store = runStore
 	}
 
+	// Under network.policy record the proxy daemon keeps the suggested
+	// policy up to date as destinations are seen.
+	if opts.Config != nil && opts.Config.Network.Policy == daemon.PolicyRecord {
+		ui.Infof("Recording network destinations to %s", filepath.Join(r.Store.Dir(), daemon.SuggestedPolicyFile))
+	}
+
 	// Save the generated Dockerfile to the run directory for debugging/inspection
 	if generatedDockerfile != "" {
 		if saveErr := r.Store.SaveDockerfile(generatedDockerfile); saveErr != nil {
@@ -2066,6 +2751,12 @@ region = %s
 		}
 	}
 
+	// Record the moat.yaml settings for drift detection (moat status --check-config)
+	recordConfigManifest(r.Store, opts.Workspace)
+
+	// Record the container environment for `moat env`
+	recordEnvSnapshot(r.Store, r.ID, proxyEnv, envSrc)
+
 	// Open audit store for tamper-proof logging
 	auditStore, err := audit.OpenStore(filepath.Join(r.Store.Dir(), "audit.db"))
 	if err != nil {
@@ -2082,7 +2773,7 @@ region = %s
 	r.AuditStore = auditStore
 
 	// Log container creation event, including privileged mode for security compliance
-	containerAuditData := audit.ContainerData{Action: "created"}
+	containerAuditData := audit.ContainerData{Action: "created", Labels: r.Labels}
 	if privileged {
 		containerAuditData.Privileged = true
 		// Determine reason for privileged mode
@@ -2097,6 +2788,30 @@ region = %s
 	containerAuditData.BuildKitNetworkID = r.NetworkID
 	_, _ = auditStore.AppendContainer(containerAuditData)
 
+	// Record and sign the isolation guarantees of a no-egress run. Unlike the
+	// best-effort entries above, the attestation is the point of the mode, so
+	// failing to write it fails the run.
+	if isolationSigner != nil {
+		attestErr := func() error {
+			if _, err := auditStore.AppendIsolation(isolationRecord(r, mounts, privileged)); err != nil {
+				return err
+			}
+			_, err := auditStore.Attest(isolationSigner)
+			return err
+		}()
+		if attestErr != nil {
+			auditStore.Close()
+			if rmErr := m.defaultRuntime().RemoveContainer(ctx, containerID); rmErr != nil {
+				log.Debug("failed to remove container during cleanup", "error", rmErr)
+			}
+			cleanupDaemonRun()
+			cleanupAgentConfig(claudeConfig)
+			cleanupAgentConfig(codexConfig)
+			cleanupAgentConfig(geminiConfig)
+			return nil, fmt.Errorf("recording isolation attestation: %w", attestErr)
+		}
+	}
+
 	// Initialize snapshot engine if not disabled
 	if opts.Config != nil && !opts.Config.Snapshots.Disabled {
 		snapshotDir := filepath.Join(r.Store.Dir(), "snapshots")
@@ -2108,10 +2823,13 @@ region = %s
 			// Log debug but don't fail - snapshots are best-effort
 			log.Debug("failed to initialize snapshot engine", "error", snapErr)
 		} else {
+			snapEngine.OnCreate(func(meta snapshot.Metadata) { runProviderSnapshotHooks(r, meta) })
 			r.SnapEngine = snapEngine
 		}
 		// Track trigger settings for use in Start()
 		r.DisablePreRunSnapshot = opts.Config.Snapshots.Triggers.DisablePreRun
+		r.DisablePostRunSnapshot = opts.Config.Snapshots.Triggers.DisablePostRun
+		r.DisableCommitSnapshots = opts.Config.Snapshots.Triggers.DisableGitCommits
 	}
 
 	// Save initial metadata (best-effort; non-fatal if it fails)
@@ -2123,6 +2841,7 @@ region = %s
 			Timestamp: time.Now().UTC(),
 			Name:      secret.name,
 			Backend:   secret.scheme,
+			Digest:    secret.digest,
 		})
 		// Also log to tamper-proof audit trail
 		_, _ = auditStore.AppendSecret(audit.SecretData{
@@ -2131,6 +2850,18 @@ region = %s
 		})
 	}
 
+	// Record requests the Docker API filter refused
+	if dockerFilter != nil {
+		dockerFilter.Proxy().SetDenyFunc(func(denied *dockerproxy.DeniedError) {
+			_, _ = auditStore.AppendPolicy(audit.PolicyDecisionData{
+				Scope:     "docker",
+				Operation: denied.Method + " " + denied.Path,
+				Decision:  "deny",
+				Message:   denied.Reason,
+			})
+		})
+	}
+
 	// Wire up SSH audit logging if SSH server is active
 	if sshServer != nil {
 		sshServer.Proxy().SetAuditFunc(func(event sshagent.AuditEvent) {
@@ -2141,6 +2872,17 @@ region = %s
 				Error:       event.Error,
 			})
 		})
+		// Alerts flag SSH use that looks like a runaway or compromised agent:
+		// a key tried against an ungranted host, or a signature cap reached.
+		sshServer.Proxy().SetAlertFunc(func(alert sshagent.Alert) {
+			ui.Warnf("SSH agent alert for run %s: %s", r.Name, alert.Message)
+			_, _ = auditStore.AppendSSH(audit.SSHData{
+				Action:      "alert_" + alert.Kind,
+				Host:        alert.Host,
+				Fingerprint: alert.Fingerprint,
+				Error:       alert.Message,
+			})
+		})
 	}
 
 	m.mu.Lock()
@@ -2256,6 +2998,67 @@ func hostGitIdentity(depList []deps.Dependency) (env []string, hasGit bool) {
 	return env, true
 }
 
+// gitHookEnv returns the env that routes git hooks through moat's
+// dispatcher, or nil when there is nothing for it to do. The dispatcher
+// refuses commits and pushes to the protected branches and adds trailers
+// (newline-separated in MOAT_GIT_TRAILERS) to commit messages. The env also
+// names the agent branch moat-init switches to when /workspace starts on a
+// protected branch. core.hooksPath is set through GIT_CONFIG_* so that it
+// outranks a repository's own core.hooksPath; the dispatcher chains to that
+// instead.
+func gitHookEnv(ws config.WorkspaceConfig, runName string, trailers []string) []string {
+	protected := ws.Protected()
+	if len(protected) == 0 && len(trailers) == 0 {
+		return nil
+	}
+	env := []string{
+		"GIT_CONFIG_COUNT=1",
+		"GIT_CONFIG_KEY_0=core.hooksPath",
+		"GIT_CONFIG_VALUE_0=" + deps.GitHooksDir,
+	}
+	if len(protected) > 0 {
+		env = append(env, "MOAT_GIT_PROTECTED_BRANCHES="+strings.Join(protected, " "))
+		if prefix := ws.AgentBranchPrefix(); prefix != "" {
+			env = append(env, "MOAT_GIT_AGENT_BRANCH="+prefix+runName)
+		}
+	}
+	if len(trailers) > 0 {
+		env = append(env, "MOAT_GIT_TRAILERS="+strings.Join(trailers, "\n"))
+	}
+	return env
+}
+
+// agentCoAuthors maps agent dependencies to the identity their vendor uses
+// for Co-Authored-By trailers. Agents without a well-known identity get no
+// co-author trailer unless workspace.commit_trailers.co_author sets one.
+var agentCoAuthors = map[string]string{
+	"claude-code": "Claude <noreply@anthropic.com>",
+}
+
+// commitTrailers returns the "Key: value" trailers to add to commits made in
+// run runID.
+func commitTrailers(tc config.CommitTrailersConfig, depList []deps.Dependency, runID string) []string {
+	var trailers []string
+	coAuthor := ""
+	if tc.CoAuthor != nil {
+		coAuthor = *tc.CoAuthor
+	} else {
+		for _, d := range depList {
+			if id, ok := agentCoAuthors[d.Name]; ok {
+				coAuthor = id
+				break
+			}
+		}
+	}
+	if coAuthor != "" {
+		trailers = append(trailers, "Co-Authored-By: "+coAuthor)
+	}
+	if tc.RunID == nil || *tc.RunID {
+		trailers = append(trailers, "Moat-Run-ID: "+runID)
+	}
+	return trailers
+}
+
 // filterSSHGrants extracts SSH host grants from the grants list.
 // SSH grants have the format "ssh:<host>" (e.g., "ssh:github.com").
 func filterSSHGrants(grants []string) []string {
@@ -2352,6 +3155,11 @@ func ensureCACertOnlyDir(caDir, certOnlyDir string) error {
 //     with an "@" prefix; moat-init.sh resolves it inside the container
 //     where Docker Desktop's embedded DNS answers.
 //
+//   - Podman — entries via MOAT_EXTRA_HOSTS with the "@" sentinel, like
+//     Docker Desktop. Rootless Podman's "host-gateway" is not guaranteed to
+//     reach the host, but Podman writes host.containers.internal into every
+//     container's /etc/hosts.
+//
 //   - Apple runtime — entries via MOAT_EXTRA_HOSTS. Apple's container CLI
 //     has no --add-host equivalent, and Apple's GetHostAddress() already
 //     returns a literal IP, so the env carries it directly (no sentinel).
@@ -2425,6 +3233,32 @@ func buildProxyEnv(authToken string, proxyPort int, hostNetworkMode bool) []stri
 	}
 }
 
+// buildBrowserProxyEnv returns the environment browser launchers need to route
+// through the moat proxy. Chromium ignores userinfo in HTTPS_PROXY and answers
+// the proxy's 407 with an auth prompt, so the credentials are split out for
+// Playwright's proxy launch option:
+//
+//	chromium.launch({ proxy: {
+//	  server: process.env.MOAT_BROWSER_PROXY_SERVER,
+//	  username: process.env.MOAT_BROWSER_PROXY_USERNAME,
+//	  password: process.env.MOAT_BROWSER_PROXY_PASSWORD } })
+//
+// MOAT_BROWSER=1 tells moat-init.sh to trust the proxy CA in the user's NSS
+// database, which Chromium consults instead of SSL_CERT_FILE.
+func buildBrowserProxyEnv(authToken string, proxyPort int) []string {
+	env := []string{
+		"MOAT_BROWSER=1",
+		"MOAT_BROWSER_PROXY_SERVER=http://" + syntheticProxyHost + ":" + strconv.Itoa(proxyPort),
+	}
+	if authToken != "" {
+		env = append(env,
+			"MOAT_BROWSER_PROXY_USERNAME=moat",
+			"MOAT_BROWSER_PROXY_PASSWORD="+authToken,
+		)
+	}
+	return env
+}
+
 // isMoatOwnedProxyVar returns true if the given environment variable name is
 // one that moat owns and sets for the container. User-supplied values for any
 // of these would override moat's proxy configuration and could bypass network
@@ -2458,10 +3292,20 @@ func buildRegisterRequest(rc *daemon.RunContext, grants []string) daemon.Registe
 		HostGateway:      rc.HostGateway,
 		HostGatewayIP:    rc.HostGatewayIP,
 		AllowedHostPorts: rc.AllowedHostPorts,
+		Mirror:           rc.Mirror,
+		SendGuard:        rc.SendGuard,
+		UploadGuard:      rc.UploadGuard,
+		RequestGuards:    rc.RequestGuards,
+		Faults:           rc.Faults,
+		Scrub:            rc.Scrub,
+		Capture:          rc.Capture,
 		MCPServers:       rc.MCPServers,
 		Grants:           grants,
 		AWSConfig:        rc.AWSConfig,
+		AzureConfig:      rc.AzureConfig,
+		GCPConfig:        rc.GCPConfig,
 		CredProfile:      credential.ActiveProfile,
+		Workspace:        rc.Workspace,
 	}
 
 	for host, creds := range rc.Credentials {
@@ -2481,6 +3325,7 @@ func buildRegisterRequest(rc *daemon.RunContext, grants []string) daemon.Registe
 				Host:       host,
 				HeaderName: h.Name,
 				Value:      h.Value,
+				Grant:      h.Grant,
 			})
 		}
 	}
@@ -2499,6 +3344,7 @@ func buildRegisterRequest(rc *daemon.RunContext, grants []string) daemon.Registe
 			Host:        host,
 			Placeholder: ts.Placeholder,
 			RealToken:   ts.RealToken,
+			Grant:       ts.Grant,
 		})
 	}
 
@@ -2506,21 +3352,39 @@ func buildRegisterRequest(rc *daemon.RunContext, grants []string) daemon.Registe
 	// Response transformers are Go functions (not serializable), so we convert
 	// them to well-known specs that the daemon can reconstruct.
 	// - Hosts with token substitutions use "response-scrub" (token redaction)
-	// - Hosts without use "oauth-endpoint-workaround" (403 graceful degradation)
+	// - Other hosts use "oauth-endpoint-workaround" (403 graceful degradation)
 	for host := range rc.ResponseTransformers {
-		kind := "oauth-endpoint-workaround"
+		kind := daemon.TransformOAuthEndpointWorkaround
 		if _, hasTS := rc.TokenSubstitutions[host]; hasTS {
-			kind = "response-scrub"
+			kind = daemon.TransformResponseScrub
 		}
 		req.ResponseTransformers = append(req.ResponseTransformers, daemon.TransformerSpec{
-			Host: host,
-			Kind: kind,
+			Host:  host,
+			Kind:  kind,
+			Grant: rc.TransformerGrant(host),
 		})
 	}
+	// Registry-based specs from network.transforms are already serializable.
+	req.ResponseTransformers = append(req.ResponseTransformers, rc.TransformerSpecs...)
 
 	return req
 }
 
+// quotasMatch reports whether the daemon enforces exactly the configured
+// quotas.
+func quotasMatch(configured map[string]config.ProviderQuota, enforced map[string]daemon.QuotaStatus) bool {
+	if len(configured) != len(enforced) {
+		return false
+	}
+	for provider, q := range configured {
+		status, ok := enforced[provider]
+		if !ok || status.ProviderQuota != q {
+			return false
+		}
+	}
+	return true
+}
+
 // preclonedInfo holds the result of successfully cloning a single marketplace.
 type preclonedInfo struct {
 	index         int    // index into the original MarketplaceConfig slice
@@ -2619,6 +3483,33 @@ func grantToPlaceholder(grant string) string {
 	}
 }
 
+// resolveUsernsMode returns the user namespace mode for a container.userns
+// run, or "" to keep the default user handling. On rootless Podman the host
+// user is mapped to user ("uid:gid"), so workspace files keep their host
+// owner while root in the container maps to an unprivileged ID. Volume-mode
+// runs start as root and use keep-id's default mapping.
+func (m *Manager) resolveUsernsMode(ctx context.Context, user string, volumeMode bool) string {
+	rt := m.defaultRuntime()
+	support, err := rt.UserNamespaceMode(ctx)
+	if err != nil {
+		log.Debug("user namespace detection failed", "runtime", rt.Type(), "error", err)
+	}
+	switch support {
+	case container.UsernsKeepID:
+		if volumeMode {
+			return container.UsernsKeepID
+		}
+		uid, gid, _ := strings.Cut(user, ":")
+		return fmt.Sprintf("%s:uid=%s,gid=%s", container.UsernsKeepID, uid, gid)
+	case container.UsernsDaemon:
+		log.Debug("container runs in the daemon's user namespace", "runtime", rt.Type())
+		return ""
+	default:
+		ui.Warnf("container.userns: %s cannot run containers in a user namespace; using the default user mapping", rt.Type())
+		return ""
+	}
+}
+
 // hasGrant checks whether a grant name appears in the grants list.
 func hasGrant(grants []string, name string) bool {
 	for _, g := range grants {
@@ -0,0 +1,112 @@
+package run
+
+// This file holds environment overrides for exec sessions in a running
+// container (moat env set).
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/majorcontext/moat/internal/audit"
+	"github.com/majorcontext/moat/internal/errcode"
+	"github.com/majorcontext/moat/internal/log"
+	"github.com/majorcontext/moat/internal/storage"
+)
+
+// envSourceOverride is the source shown for variables set with moat env set.
+const envSourceOverride = "moat env set"
+
+// SetEnv updates the environment overrides of a running run: values in set
+// are added or replaced, names in unset are removed. Later exec sessions
+// (moat exec, moat join) start with the overrides applied; processes already
+// running in the container, including the agent, are unaffected. Each change
+// is recorded in the run's audit log by name only. Returns the overrides now
+// in effect.
+func (m *Manager) SetEnv(runID string, set map[string]string, unset []string) (map[string]string, error) {
+	r, err := m.Get(runID)
+	if err != nil {
+		return nil, err
+	}
+	if state := r.GetState(); state != StateRunning {
+		return nil, errcode.Wrap(errcode.RunNotRunning, fmt.Errorf("run %s is not running (state: %s)", runID, state))
+	}
+	if r.Store == nil {
+		return nil, fmt.Errorf("run %s has no storage", runID)
+	}
+
+	env, err := r.Store.LoadEnvOverrides()
+	if err != nil {
+		return nil, fmt.Errorf("reading environment overrides: %w", err)
+	}
+	if env == nil {
+		env = make(map[string]string)
+	}
+	maps.Copy(env, set)
+	for _, name := range unset {
+		delete(env, name)
+	}
+	if err := r.Store.SaveEnvOverrides(env); err != nil {
+		return nil, fmt.Errorf("saving environment overrides: %w", err)
+	}
+
+	if len(set) > 0 {
+		m.auditEnv(r, audit.EnvData{Action: "set", Names: slices.Sorted(maps.Keys(set))})
+	}
+	if len(unset) > 0 {
+		m.auditEnv(r, audit.EnvData{Action: "unset", Names: unset})
+	}
+	return env, nil
+}
+
+// auditEnv appends an environment override entry to r's audit log.
+func (m *Manager) auditEnv(r *Run, data audit.EnvData) {
+	withAuditStore(r, "env override", func(as *audit.Store) error {
+		_, err := as.AppendEnv(data)
+		return err
+	})
+}
+
+// EnvOverrides returns the environment overrides of r for display, sorted
+// by name, with secret-like values redacted.
+func EnvOverrides(r *Run) ([]storage.EnvVar, error) {
+	if r.Store == nil {
+		return nil, nil
+	}
+	env, err := r.Store.LoadEnvOverrides()
+	if err != nil {
+		return nil, err
+	}
+	vars := make([]storage.EnvVar, 0, len(env))
+	for _, name := range slices.Sorted(maps.Keys(env)) {
+		v := storage.EnvVar{Name: name, Value: env[name], Source: envSourceOverride}
+		if shown, redacted := redactEnvValue(name, v.Value, v.Source); redacted {
+			v.Value = shown
+			v.Redacted = true
+		}
+		vars = append(vars, v)
+	}
+	return vars, nil
+}
+
+// withEnvOverrides prefixes cmd with env(1) setting r's overrides, so exec
+// sessions see them on every runtime. Returns cmd unchanged if r has none.
+func withEnvOverrides(r *Run, cmd []string) []string {
+	if r.Store == nil {
+		return cmd
+	}
+	env, err := r.Store.LoadEnvOverrides()
+	if err != nil {
+		log.Warn("ignoring unreadable environment overrides", "run_id", r.ID, "error", err)
+		return cmd
+	}
+	if len(env) == 0 {
+		return cmd
+	}
+	assignments := make([]string, 0, len(env))
+	for name, value := range env {
+		assignments = append(assignments, name+"="+value)
+	}
+	slices.Sort(assignments)
+	return append(append([]string{"env"}, assignments...), cmd...)
+}
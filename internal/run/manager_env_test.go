@@ -0,0 +1,88 @@
+package run
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/majorcontext/moat/internal/audit"
+	"github.com/majorcontext/moat/internal/storage"
+)
+
+func TestManagerSetEnv(t *testing.T) {
+	store, err := storage.NewRunStore(t.TempDir(), "run_env")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &Run{ID: "run_env", State: StateRunning, Store: store}
+	m := &Manager{runs: map[string]*Run{r.ID: r}}
+
+	env, err := m.SetEnv(r.ID, map[string]string{"DEBUG": "1", "API_TOKEN": "secret"}, nil)
+	if err != nil {
+		t.Fatalf("SetEnv: %v", err)
+	}
+	if len(env) != 2 {
+		t.Errorf("overrides = %v", env)
+	}
+	env, err = m.SetEnv(r.ID, map[string]string{"DEBUG": "2"}, []string{"API_TOKEN"})
+	if err != nil {
+		t.Fatalf("SetEnv: %v", err)
+	}
+	if len(env) != 1 || env["DEBUG"] != "2" {
+		t.Errorf("overrides after update = %v, want DEBUG=2", env)
+	}
+
+	// The audit log records names, never values.
+	as, err := audit.OpenStore(filepath.Join(store.Dir(), "audit.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer as.Close()
+	entries, err := as.Range(1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var logged []string
+	for _, e := range entries {
+		if e.Type != audit.EntryEnv {
+			continue
+		}
+		data, _ := e.Data.(map[string]any)
+		logged = append(logged, data["action"].(string))
+	}
+	if want := []string{"set", "set", "unset"}; !slices.Equal(logged, want) {
+		t.Errorf("audit actions = %v, want %v", logged, want)
+	}
+
+	stopped := &Run{ID: "run_stopped", State: StateStopped, Store: store}
+	m.runs[stopped.ID] = stopped
+	if _, err := m.SetEnv(stopped.ID, map[string]string{"A": "1"}, nil); err == nil || !strings.Contains(err.Error(), "not running") {
+		t.Errorf("SetEnv on a stopped run = %v, want a 'not running' error", err)
+	}
+}
+
+func TestWithEnvOverrides(t *testing.T) {
+	store, err := storage.NewRunStore(t.TempDir(), "run_env")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &Run{ID: "run_env", Store: store}
+	cmd := []string{"npm", "test"}
+	if got := withEnvOverrides(r, cmd); !slices.Equal(got, cmd) {
+		t.Errorf("without overrides: %v", got)
+	}
+
+	if err := store.SaveEnvOverrides(map[string]string{"B": "two words", "A": "1"}); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"env", "A=1", "B=two words", "npm", "test"}
+	if got := withEnvOverrides(r, cmd); !slices.Equal(got, want) {
+		t.Errorf("withEnvOverrides = %v, want %v", got, want)
+	}
+
+	vars, err := EnvOverrides(r)
+	if err != nil || len(vars) != 2 || vars[0].Name != "A" || vars[0].Source != envSourceOverride {
+		t.Errorf("EnvOverrides = %+v, %v", vars, err)
+	}
+}
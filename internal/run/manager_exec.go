@@ -8,9 +8,13 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"path/filepath"
+	"strings"
 
 	"github.com/majorcontext/moat/internal/audit"
 	"github.com/majorcontext/moat/internal/container"
+	"github.com/majorcontext/moat/internal/errcode"
+	"github.com/majorcontext/moat/internal/log"
 )
 
 // ResizeTTY resizes the container's TTY to the given dimensions.
@@ -61,7 +65,8 @@ func (m *Manager) WriteClipboard(ctx context.Context, runID string, data []byte,
 	return m.Exec(ctx, runID, cmd, data, io.Discard, io.Discard)
 }
 
-// Exec runs a command inside a running container and streams output.
+// Exec runs a command inside a running container and streams output. The
+// command sees the run's environment overrides (see SetEnv).
 func (m *Manager) Exec(ctx context.Context, runID string, cmd []string, stdin []byte, stdout, stderr io.Writer) error {
 	m.mu.RLock()
 	r, ok := m.runs[runID]
@@ -70,12 +75,11 @@ func (m *Manager) Exec(ctx context.Context, runID string, cmd []string, stdin []
 		return fmt.Errorf("%w: %s", ErrRunNotFound, runID)
 	}
 	containerID := r.ContainerID
-	auditStore := r.AuditStore
 	state := r.GetState()
 	m.mu.RUnlock()
 
 	if state != StateRunning {
-		return fmt.Errorf("run %s is not running (state: %s)", runID, state)
+		return errcode.Wrap(errcode.RunNotRunning, fmt.Errorf("run %s is not running (state: %s)", runID, state))
 	}
 
 	rt, rtErr := m.runtimeForRun(r)
@@ -83,21 +87,12 @@ func (m *Manager) Exec(ctx context.Context, runID string, cmd []string, stdin []
 		return fmt.Errorf("resolving runtime for run %s: %w", runID, rtErr)
 	}
 
-	execErr := rt.Exec(ctx, containerID, cmd, stdin, stdout, stderr)
-
-	if auditStore != nil {
-		exitCode := 0
-		var ee *container.ExecError
-		if errors.As(execErr, &ee) {
-			exitCode = ee.ExitCode
-		}
-		_, _ = auditStore.AppendExec(audit.ExecData{
-			Command:  cmd,
-			HasStdin: len(stdin) > 0,
-			ExitCode: exitCode,
-		})
-	}
-
+	execErr := rt.Exec(ctx, containerID, withEnvOverrides(r, cmd), stdin, stdout, stderr)
+	auditExec(r, audit.ExecData{
+		Command:  cmd,
+		HasStdin: len(stdin) > 0,
+		ExitCode: execExitCode(execErr),
+	})
 	return execErr
 }
 
@@ -111,12 +106,11 @@ func (m *Manager) ExecInteractive(ctx context.Context, runID string, cmd []strin
 		return fmt.Errorf("%w: %s", ErrRunNotFound, runID)
 	}
 	containerID := r.ContainerID
-	auditStore := r.AuditStore
 	state := r.GetState()
 	m.mu.RUnlock()
 
 	if state != StateRunning {
-		return fmt.Errorf("run %s is not running (state: %s)", runID, state)
+		return errcode.Wrap(errcode.RunNotRunning, fmt.Errorf("run %s is not running (state: %s)", runID, state))
 	}
 
 	rt, rtErr := m.runtimeForRun(r)
@@ -124,23 +118,122 @@ func (m *Manager) ExecInteractive(ctx context.Context, runID string, cmd []strin
 		return fmt.Errorf("resolving runtime for run %s: %w", runID, rtErr)
 	}
 
-	execErr := rt.ExecInteractive(ctx, containerID, cmd, opts)
+	execErr := rt.ExecInteractive(ctx, containerID, withEnvOverrides(r, cmd), opts)
+	auditExec(r, audit.ExecData{
+		Command:  cmd,
+		HasStdin: opts.Stdin != nil,
+		ExitCode: execExitCode(execErr),
+	})
+	return execErr
+}
 
-	if auditStore != nil {
-		exitCode := 0
-		var ee *container.ExecError
-		if errors.As(execErr, &ee) {
-			exitCode = ee.ExitCode
-		}
-		_, _ = auditStore.AppendExec(audit.ExecData{
-			Command:  cmd,
-			HasStdin: opts.Stdin != nil,
-			ExitCode: exitCode,
-		})
+// CommandOptions holds the settings 'moat exec' applies to a single command.
+type CommandOptions struct {
+	// WorkDir is the directory to run in. Relative paths resolve against
+	// the container's working directory (/workspace). Empty keeps it.
+	WorkDir string
+	// Env holds KEY=VALUE pairs set for this command only, on top of the
+	// run's overrides (see SetEnv).
+	Env []string
+}
+
+// ExecCommand runs a user-requested command inside a running container,
+// applying copts on top of the run's environment overrides. With opts.TTY
+// the command gets a PTY. The command, working directory, environment
+// variable names (not values), and exit code are recorded in the run's
+// audit log.
+func (m *Manager) ExecCommand(ctx context.Context, runID string, cmd []string, copts CommandOptions, opts container.ExecOptions) error {
+	m.mu.RLock()
+	r, ok := m.runs[runID]
+	if !ok {
+		m.mu.RUnlock()
+		return fmt.Errorf("%w: %s", ErrRunNotFound, runID)
 	}
+	containerID := r.ContainerID
+	state := r.GetState()
+	m.mu.RUnlock()
+
+	if state != StateRunning {
+		return errcode.Wrap(errcode.RunNotRunning, fmt.Errorf("run %s is not running (state: %s)", runID, state))
+	}
+
+	rt, rtErr := m.runtimeForRun(r)
+	if rtErr != nil {
+		return fmt.Errorf("resolving runtime for run %s: %w", runID, rtErr)
+	}
+
+	execErr := rt.ExecInteractive(ctx, containerID, withEnvOverrides(r, wrapCommand(cmd, copts)), opts)
+
+	var envNames []string
+	for _, kv := range copts.Env {
+		name, _, _ := strings.Cut(kv, "=")
+		envNames = append(envNames, name)
+	}
+	auditExec(r, audit.ExecData{
+		Command:  cmd,
+		HasStdin: opts.Stdin != nil,
+		ExitCode: execExitCode(execErr),
+		WorkDir:  copts.WorkDir,
+		Env:      envNames,
+		TTY:      opts.TTY,
+	})
 	return execErr
 }
 
+// wrapCommand applies copts to cmd the same way on every runtime: env(1)
+// sets the variables and a shell changes directory before exec'ing cmd.
+// The directory is passed as a positional argument, never interpolated
+// into the script.
+func wrapCommand(cmd []string, copts CommandOptions) []string {
+	if copts.WorkDir != "" {
+		cmd = append([]string{"sh", "-c", `cd "$1" && shift && exec "$@"`, "sh", copts.WorkDir}, cmd...)
+	}
+	if len(copts.Env) > 0 {
+		cmd = append(append([]string{"env"}, copts.Env...), cmd...)
+	}
+	return cmd
+}
+
+// execExitCode returns the container command's exit code carried by err,
+// or 0 when the command succeeded or failed before it ran.
+func execExitCode(err error) int {
+	var ee *container.ExecError
+	if errors.As(err, &ee) {
+		return ee.ExitCode
+	}
+	return 0
+}
+
+// auditExec appends an exec entry to r's audit log.
+func auditExec(r *Run, data audit.ExecData) {
+	withAuditStore(r, "exec", func(as *audit.Store) error {
+		_, err := as.AppendExec(data)
+		return err
+	})
+}
+
+// withAuditStore calls fn with r's audit log, opening it if this process did
+// not create the run (e.g., 'moat exec' from another terminal). Best-effort:
+// failures are logged at debug level and never fail the caller.
+func withAuditStore(r *Run, what string, fn func(*audit.Store) error) {
+	as := r.AuditStore
+	if as == nil {
+		if r.Store == nil {
+			return
+		}
+		opened, err := audit.OpenStore(filepath.Join(r.Store.Dir(), "audit.db"))
+		if err != nil {
+			log.Debug("opening audit store", "run_id", r.ID, "entry", what, "error", err)
+			return
+		}
+		defer opened.Close()
+		as = opened
+	}
+	if err := fn(as); err != nil {
+		log.Debug("appending audit entry", "run_id", r.ID, "entry", what, "error", err)
+	}
+}
+
 // AttachedCount returns the number of live joined agents for a run (display-only).
 func (m *Manager) AttachedCount(runID string) int {
 	return attachedCount(runID)
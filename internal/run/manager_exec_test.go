@@ -0,0 +1,58 @@
+package run
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/majorcontext/moat/internal/audit"
+	"github.com/majorcontext/moat/internal/storage"
+)
+
+func TestWrapCommand(t *testing.T) {
+	cmd := []string{"make", "test"}
+	if got := wrapCommand(cmd, CommandOptions{}); !slices.Equal(got, cmd) {
+		t.Errorf("without options: %v", got)
+	}
+
+	got := wrapCommand(cmd, CommandOptions{WorkDir: "src; rm -rf /", Env: []string{"DEBUG=1"}})
+	want := []string{"env", "DEBUG=1", "sh", "-c", `cd "$1" && shift && exec "$@"`, "sh", "src; rm -rf /", "make", "test"}
+	if !slices.Equal(got, want) {
+		t.Errorf("wrapCommand = %q, want %q", got, want)
+	}
+}
+
+// TestAuditExecOpensStore covers 'moat exec' from a process that did not
+// create the run: r.AuditStore is nil, so the log is opened from disk.
+func TestAuditExecOpensStore(t *testing.T) {
+	store, err := storage.NewRunStore(t.TempDir(), "run_exec")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &Run{ID: "run_exec", Store: store}
+
+	auditExec(r, audit.ExecData{
+		Command:  []string{"make", "test"},
+		ExitCode: 2,
+		WorkDir:  "src",
+		Env:      []string{"DEBUG"},
+		TTY:      true,
+	})
+
+	as, err := audit.OpenStore(filepath.Join(store.Dir(), "audit.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer as.Close()
+	entries, err := as.Range(1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Type != audit.EntryExec {
+		t.Fatalf("entries = %+v, want one exec entry", entries)
+	}
+	data, _ := entries[0].Data.(map[string]any)
+	if data["workdir"] != "src" || data["tty"] != true || data["exit_code"] != float64(2) {
+		t.Errorf("exec entry data = %v", data)
+	}
+}
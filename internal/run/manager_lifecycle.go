@@ -31,6 +31,7 @@ func (m *Manager) Start(ctx context.Context, runID string) error {
 	m.mu.Unlock()
 	r.SetState(StateStarting)
 	setLogContext(r)
+	runProviderStartingHooks(r)
 
 	if err := m.defaultRuntime().StartContainer(ctx, r.ContainerID); err != nil {
 		r.SetStateFailedAt(err.Error(), time.Now())
@@ -55,6 +56,7 @@ func (m *Manager) Start(ctx context.Context, runID string) error {
 			log.Debug("failed to create pre-run snapshot", "error", err)
 		}
 	}
+	m.startCommitSnapshots(ctx, r)
 
 	// Start background monitor to capture logs when container exits.
 	// Tracked by monitorWg so Close() waits for completion. Uses monitorCtx
@@ -78,8 +80,34 @@ func (m *Manager) Start(ctx context.Context, runID string) error {
 			}()
 			m.monitorProxyHealth(proxyCtx, r)
 		}()
+
+		// Feed provider hooks from the proxy's request stream.
+		m.monitorWg.Add(1)
+		go func() {
+			defer m.monitorWg.Done()
+			eventsCtx, eventsCancel := context.WithCancel(m.monitorCtx)
+			defer eventsCancel()
+			go func() {
+				<-r.exitCh
+				eventsCancel()
+			}()
+			m.watchProviderEvents(eventsCtx, r)
+		}()
 	}
 
+	// Record resource usage until the container exits.
+	m.monitorWg.Add(1)
+	go func() {
+		defer m.monitorWg.Done()
+		metricsCtx, metricsCancel := context.WithCancel(m.monitorCtx)
+		defer metricsCancel()
+		go func() {
+			<-r.exitCh
+			metricsCancel()
+		}()
+		m.recordMetrics(metricsCtx, r, MetricsInterval())
+	}()
+
 	return nil
 }
 
@@ -98,6 +126,7 @@ func (m *Manager) StartAttached(ctx context.Context, runID string, stdin io.Read
 	m.mu.Unlock()
 	r.SetState(StateStarting)
 	setLogContext(r)
+	runProviderStartingHooks(r)
 
 	// Start with attachment - this ensures TTY is connected before process starts.
 	// TTY mode must match how the container was created (see CreateContainer in
@@ -194,15 +223,29 @@ func (m *Manager) StartAttached(ctx context.Context, runID string, stdin io.Read
 			defer m.monitorWg.Done()
 			m.monitorProxyHealth(proxyCtx, r)
 		}()
+		m.monitorWg.Add(1)
+		go func() {
+			defer m.monitorWg.Done()
+			m.watchProviderEvents(proxyCtx, r)
+		}()
 	}
 
+	// Record resource usage for the duration of the attached session.
+	metricsCtx, metricsCancel := context.WithCancel(m.monitorCtx)
+	m.monitorWg.Add(1)
+	go func() {
+		defer m.monitorWg.Done()
+		m.recordMetrics(metricsCtx, r, MetricsInterval())
+	}()
+
 	// Wait for the attachment to complete (container exits or context canceled)
 	attachErr := <-attachDone
 
-	// Stop proxy health monitor.
+	// Stop proxy health monitor and metrics sampling.
 	if proxyHealthCancel != nil {
 		proxyHealthCancel()
 	}
+	metricsCancel()
 
 	// Determine whether the caller will stop the container (escape-stop or context
 	// cancellation). In those cases, skip state updates and log capture here — the
@@ -249,6 +292,7 @@ func (m *Manager) StartAttached(ctx context.Context, runID string, stdin io.Read
 	// Must happen after the container has exited so session files are flushed.
 	if !callerWillStop {
 		runProviderStoppedHooks(r)
+		recordTestResults(r)
 	}
 	_ = r.SaveMetadata()
 
@@ -297,6 +341,7 @@ func (m *Manager) Stop(ctx context.Context, runID string) error {
 	// Capture logs and run provider hooks (both idempotent)
 	m.captureLogs(r)
 	runProviderStoppedHooks(r)
+	recordTestResults(r)
 
 	r.SetStateWithTime(StateStopped, time.Now())
 	_ = r.SaveMetadata()
@@ -416,34 +461,40 @@ func setLogContext(r *Run) {
 }
 
 // setupPortBindings retrieves the host-side port mappings for a container's
-// exposed ports and registers them as routes with both the local route table
-// and the proxy daemon. Port binding lookup is retried because the container
-// runtime may not have mappings ready immediately after start.
+// exposed ports and registers them, with the run's published services, as
+// routes with both the local route table and the proxy daemon. Port binding
+// lookup is retried because the container runtime may not have mappings
+// ready immediately after start.
 func (m *Manager) setupPortBindings(ctx context.Context, r *Run) {
-	if len(r.Ports) == 0 {
+	if len(r.Ports) == 0 && len(r.PublishedServices) == 0 {
 		return
 	}
 
-	var bindings map[int]int
-	var err error
-	for i := 0; i < 5; i++ {
-		bindings, err = m.defaultRuntime().GetPortBindings(ctx, r.ContainerID)
-		if err != nil || len(bindings) >= len(r.Ports) {
-			break
-		}
-		time.Sleep(50 * time.Millisecond)
-	}
-	if err != nil {
-		ui.Warnf("Getting port bindings: %v", err)
-		return
+	services := make(map[string]string)
+	for name, svc := range r.PublishedServices {
+		services[name] = svc.Addr
 	}
 
-	r.HostPorts = make(map[string]int)
-	services := make(map[string]string)
-	for serviceName, containerPort := range r.Ports {
-		if hostPort, ok := bindings[containerPort]; ok {
-			r.HostPorts[serviceName] = hostPort
-			services[serviceName] = fmt.Sprintf("127.0.0.1:%d", hostPort)
+	if len(r.Ports) > 0 {
+		var bindings map[int]int
+		var err error
+		for i := 0; i < 5; i++ {
+			bindings, err = m.defaultRuntime().GetPortBindings(ctx, r.ContainerID)
+			if err != nil || len(bindings) >= len(r.Ports) {
+				break
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+		if err != nil {
+			ui.Warnf("Getting port bindings: %v", err)
+		} else {
+			r.HostPorts = make(map[string]int)
+			for serviceName, containerPort := range r.Ports {
+				if hostPort, ok := bindings[containerPort]; ok {
+					r.HostPorts[serviceName] = hostPort
+					services[serviceName] = fmt.Sprintf("127.0.0.1:%d", hostPort)
+				}
+			}
 		}
 	}
 	if len(services) > 0 {
@@ -464,18 +515,33 @@ func (m *Manager) setupPortBindings(ctx context.Context, r *Run) {
 
 // setupFirewall configures iptables-based network isolation inside the
 // container so that only traffic through the credential-injecting proxy is
-// allowed. Returns an error if firewall setup fails, since a strict network
-// policy without a working firewall would leave the container unprotected.
+// allowed, then limits the container's access to services whose network
+// block restricts it. Returns an error if either fails, since a strict
+// network policy or service restriction without working rules would leave
+// the container unprotected.
 func (m *Manager) setupFirewall(ctx context.Context, r *Run) error {
-	if !r.FirewallEnabled || r.ProxyPort <= 0 {
-		return nil
+	if r.FirewallEnabled && r.ProxyPort > 0 {
+		if err := m.defaultRuntime().SetupFirewall(ctx, r.ContainerID, r.ProxyHost, r.ProxyPort); err != nil {
+			m.stopAfterFirewallError(ctx, r, err)
+			return fmt.Errorf("firewall setup failed (required for strict network policy): %w", err)
+		}
 	}
-	if err := m.defaultRuntime().SetupFirewall(ctx, r.ContainerID, r.ProxyHost, r.ProxyPort); err != nil {
-		r.SetStateFailedAt(fmt.Sprintf("firewall setup failed: %v", err), time.Now())
-		if stopErr := m.defaultRuntime().StopContainer(ctx, r.ContainerID); stopErr != nil {
-			ui.Warnf("Failed to stop container after firewall error: %v", stopErr)
+	// After SetupFirewall, which flushes OUTPUT and with it the jump to the
+	// service rules.
+	if len(r.ServiceAccess) > 0 {
+		if err := m.defaultRuntime().RestrictServiceAccess(ctx, r.ContainerID, r.ServiceAccess); err != nil {
+			m.stopAfterFirewallError(ctx, r, err)
+			return fmt.Errorf("firewall setup failed (required for services' network settings): %w", err)
 		}
-		return fmt.Errorf("firewall setup failed (required for strict network policy): %w", err)
 	}
 	return nil
 }
+
+// stopAfterFirewallError fails the run and stops its container, which must
+// not keep running without its firewall rules.
+func (m *Manager) stopAfterFirewallError(ctx context.Context, r *Run, err error) {
+	r.SetStateFailedAt(fmt.Sprintf("firewall setup failed: %v", err), time.Now())
+	if stopErr := m.defaultRuntime().StopContainer(ctx, r.ContainerID); stopErr != nil {
+		ui.Warnf("Failed to stop container after firewall error: %v", stopErr)
+	}
+}
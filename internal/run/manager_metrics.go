@@ -0,0 +1,156 @@
+package run
+
+// This file holds the resource usage sampler that writes a run's metrics.jsonl.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/majorcontext/moat/internal/container"
+	"github.com/majorcontext/moat/internal/log"
+	"github.com/majorcontext/moat/internal/storage"
+)
+
+// defaultMetricsInterval is how often a run's resource usage is sampled.
+const defaultMetricsInterval = 10 * time.Second
+
+// MetricsInterval returns how often resource usage is sampled: the Go
+// duration in MOAT_METRICS_INTERVAL (e.g. "5s"), or 10s. Zero disables
+// sampling; an invalid value falls back to the default with a warning.
+func MetricsInterval() time.Duration {
+	v := os.Getenv("MOAT_METRICS_INTERVAL")
+	if v == "" {
+		return defaultMetricsInterval
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		log.Warn("ignoring invalid MOAT_METRICS_INTERVAL", "value", v)
+		return defaultMetricsInterval
+	}
+	return d
+}
+
+// SampleMetrics reads the current resource usage of a running run. prev, if
+// non-nil, is the run's previous sample and is used to compute CPUPercent.
+func (m *Manager) SampleMetrics(ctx context.Context, runID string, prev *storage.Metric) (storage.Metric, error) {
+	r, err := m.Get(runID)
+	if err != nil {
+		return storage.Metric{}, err
+	}
+	if r.GetState() != StateRunning {
+		return storage.Metric{}, fmt.Errorf("run %s is not running", runID)
+	}
+	rt, err := m.runtimeForRun(r)
+	if err != nil {
+		return storage.Metric{}, err
+	}
+	stats, err := rt.ContainerStats(ctx, r.ContainerID)
+	if err != nil {
+		return storage.Metric{}, err
+	}
+	return newMetric(time.Now(), stats, prev), nil
+}
+
+// newMetric builds a sample from runtime stats taken at now.
+func newMetric(now time.Time, s container.Stats, prev *storage.Metric) storage.Metric {
+	metric := storage.Metric{
+		Timestamp:        now,
+		CPUNanos:         s.CPUNanos,
+		MemoryBytes:      s.MemoryBytes,
+		MemoryLimitBytes: s.MemoryLimitBytes,
+		NetRxBytes:       s.NetRxBytes,
+		NetTxBytes:       s.NetTxBytes,
+	}
+	if prev != nil && s.CPUNanos >= prev.CPUNanos {
+		if elapsed := now.Sub(prev.Timestamp); elapsed > 0 {
+			metric.CPUPercent = float64(s.CPUNanos-prev.CPUNanos) / float64(elapsed.Nanoseconds()) * 100
+		}
+	}
+	return metric
+}
+
+// recordMetrics samples r's resource usage every interval and appends each
+// sample to metrics.jsonl until ctx is done. It stops early if the runtime
+// does not expose resource usage.
+func (m *Manager) recordMetrics(ctx context.Context, r *Run, interval time.Duration) {
+	if interval <= 0 || r.Store == nil {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var prev *storage.Metric
+	for {
+		sampleCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		metric, err := m.SampleMetrics(sampleCtx, r.ID, prev)
+		cancel()
+		switch {
+		case errors.Is(err, container.ErrStatsUnsupported):
+			log.Debug("resource usage not recorded", "run_id", r.ID, "error", err)
+			return
+		case err != nil:
+			if ctx.Err() == nil {
+				log.Debug("sampling resource usage failed", "run_id", r.ID, "error", err)
+			}
+		default:
+			if werr := r.Store.WriteMetric(metric); werr != nil {
+				log.Debug("writing resource usage failed", "run_id", r.ID, "error", werr)
+			}
+			prev = &metric
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// MetricsSummary summarizes a run's recorded resource usage.
+type MetricsSummary struct {
+	Samples       int           `json:"samples"`
+	Start         time.Time     `json:"start"`
+	End           time.Time     `json:"end"`
+	CPUAvgPercent float64       `json:"cpu_avg_percent"`
+	CPUMaxPercent float64       `json:"cpu_max_percent"`
+	CPUTime       time.Duration `json:"cpu_time_ns"`
+	MemoryAvg     uint64        `json:"memory_avg_bytes"`
+	MemoryMax     uint64        `json:"memory_max_bytes"`
+	MemoryLimit   uint64        `json:"memory_limit_bytes,omitempty"`
+	NetRxBytes    uint64        `json:"net_rx_bytes"`
+	NetTxBytes    uint64        `json:"net_tx_bytes"`
+}
+
+// SummarizeMetrics summarizes samples in the order they were recorded. CPU
+// time and network totals come from the last sample, since the counters are
+// cumulative; the CPU average covers the samples after the first.
+func SummarizeMetrics(samples []storage.Metric) MetricsSummary {
+	var s MetricsSummary
+	if len(samples) == 0 {
+		return s
+	}
+	first, last := samples[0], samples[len(samples)-1]
+	s.Samples = len(samples)
+	s.Start, s.End = first.Timestamp, last.Timestamp
+	s.CPUTime = time.Duration(last.CPUNanos) // #nosec G115 -- CPU time fits in int64 nanoseconds for centuries
+	s.NetRxBytes, s.NetTxBytes = last.NetRxBytes, last.NetTxBytes
+
+	var cpuSum float64
+	var memSum uint64
+	for _, m := range samples {
+		cpuSum += m.CPUPercent
+		s.CPUMaxPercent = max(s.CPUMaxPercent, m.CPUPercent)
+		memSum += m.MemoryBytes
+		s.MemoryMax = max(s.MemoryMax, m.MemoryBytes)
+		s.MemoryLimit = max(s.MemoryLimit, m.MemoryLimitBytes)
+	}
+	if len(samples) > 1 {
+		s.CPUAvgPercent = cpuSum / float64(len(samples)-1)
+	}
+	s.MemoryAvg = memSum / uint64(len(samples))
+	return s
+}
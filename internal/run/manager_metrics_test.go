@@ -0,0 +1,77 @@
+package run
+
+import (
+	"testing"
+	"time"
+
+	"github.com/majorcontext/moat/internal/container"
+	"github.com/majorcontext/moat/internal/storage"
+)
+
+func TestNewMetric(t *testing.T) {
+	t0 := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	first := newMetric(t0, container.Stats{CPUNanos: 4e9, MemoryBytes: 100, MemoryLimitBytes: 1000, NetRxBytes: 10, NetTxBytes: 20}, nil)
+	if first.CPUPercent != 0 || first.MemoryBytes != 100 || first.NetRxBytes != 10 || first.NetTxBytes != 20 {
+		t.Errorf("first sample = %+v", first)
+	}
+
+	// 15s of CPU time over 10s of wall time is one and a half CPUs.
+	second := newMetric(t0.Add(10*time.Second), container.Stats{CPUNanos: 19e9}, &first)
+	if second.CPUPercent != 150 {
+		t.Errorf("CPUPercent = %v, want 150", second.CPUPercent)
+	}
+
+	// A counter reset (container restarted) yields no CPU figure rather
+	// than a wrapped-around one.
+	reset := newMetric(t0.Add(20*time.Second), container.Stats{CPUNanos: 1e9}, &second)
+	if reset.CPUPercent != 0 {
+		t.Errorf("CPUPercent after counter reset = %v, want 0", reset.CPUPercent)
+	}
+}
+
+func TestMetricsInterval(t *testing.T) {
+	for _, tt := range []struct {
+		env  string
+		want time.Duration
+	}{
+		{"", defaultMetricsInterval},
+		{"5s", 5 * time.Second},
+		{"0", 0},
+		{"fast", defaultMetricsInterval},
+		{"-1s", defaultMetricsInterval},
+	} {
+		t.Setenv("MOAT_METRICS_INTERVAL", tt.env)
+		if got := MetricsInterval(); got != tt.want {
+			t.Errorf("MOAT_METRICS_INTERVAL=%q: got %v, want %v", tt.env, got, tt.want)
+		}
+	}
+}
+
+func TestSummarizeMetrics(t *testing.T) {
+	if s := SummarizeMetrics(nil); s.Samples != 0 {
+		t.Errorf("SummarizeMetrics(nil) = %+v", s)
+	}
+
+	t0 := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	s := SummarizeMetrics([]storage.Metric{
+		{Timestamp: t0, CPUNanos: 1e9, MemoryBytes: 100, MemoryLimitBytes: 1000, NetRxBytes: 10},
+		{Timestamp: t0.Add(10 * time.Second), CPUPercent: 50, CPUNanos: 6e9, MemoryBytes: 300, NetRxBytes: 40, NetTxBytes: 5},
+		{Timestamp: t0.Add(20 * time.Second), CPUPercent: 150, CPUNanos: 21e9, MemoryBytes: 200, NetRxBytes: 90, NetTxBytes: 8},
+	})
+	want := MetricsSummary{
+		Samples:       3,
+		Start:         t0,
+		End:           t0.Add(20 * time.Second),
+		CPUAvgPercent: 100,
+		CPUMaxPercent: 150,
+		CPUTime:       21 * time.Second,
+		MemoryAvg:     200,
+		MemoryMax:     300,
+		MemoryLimit:   1000,
+		NetRxBytes:    90,
+		NetTxBytes:    8,
+	}
+	if s != want {
+		t.Errorf("SummarizeMetrics = %+v, want %+v", s, want)
+	}
+}
@@ -6,13 +6,13 @@ package run
 import (
 	"context"
 	"errors"
-	"fmt"
 	"path/filepath"
 	"time"
 
 	"github.com/majorcontext/moat/internal/config"
 	"github.com/majorcontext/moat/internal/daemon"
 	"github.com/majorcontext/moat/internal/log"
+	"github.com/majorcontext/moat/internal/provider"
 )
 
 // monitorContainerExit watches for container exit and captures logs.
@@ -46,9 +46,12 @@ func (m *Manager) monitorContainerExit(ctx context.Context, r *Run) {
 	// We must get the logs while the container is still in "exited" state.
 	m.captureLogs(r)
 
-	// Run provider stopped hooks (e.g., Claude session ID extraction).
-	// Must happen after captureLogs and before SaveMetadata.
+	// Run provider stopped hooks (e.g., Claude session ID extraction) and
+	// parse test results from the logs. Must happen after captureLogs and
+	// before SaveMetadata.
 	runProviderStoppedHooks(r)
+	recordTestResults(r)
+	snapshotAfterRun(r)
 
 	// Update run state BEFORE signaling exitCh so that Wait() reads
 	// the final state (including r.Error) when it unblocks.
@@ -59,7 +62,18 @@ func (m *Manager) monitorContainerExit(ctx context.Context, r *Run) {
 			if err != nil {
 				errMsg = err.Error()
 			} else {
-				errMsg = fmt.Sprintf("exit code %d", exitCode)
+				// Classify only runs that failed on their own; a run the
+				// user stopped exits non-zero by design.
+				m.recordFailureClass(r, rt, exitCode)
+				if r.GetFailureClass() == FailureBudgetExceeded {
+					runProviderBudgetHooks(r, provider.BudgetExceededEvent{Time: time.Now()})
+				}
+				class := r.GetFailureClass()
+				peakMB := 0
+				if class == FailureOOMKilled || class == FailureKilled {
+					peakMB = peakMemoryMB(r)
+				}
+				errMsg = failureMessage(class, exitCode, r.MemoryMB, peakMB)
 			}
 			r.SetStateFailedAt(errMsg, time.Now())
 		} else {
@@ -124,7 +138,7 @@ func (m *Manager) monitorProxyHealth(ctx context.Context, r *Run) {
 
 		// Verify our run is still registered by trying to update it.
 		updateCtx, updateCancel := context.WithTimeout(ctx, 5*time.Second)
-		updateErr := dc.UpdateRun(updateCtx, r.ProxyAuthToken, r.ContainerID)
+		updateErr := dc.UpdateRun(updateCtx, r.ProxyAuthToken, r.daemonUpdate())
 		updateCancel()
 
 		if errors.Is(updateErr, ErrRunNotFound) {
@@ -144,7 +158,7 @@ func (m *Manager) monitorProxyHealth(ctx context.Context, r *Run) {
 			// Update with container ID after re-registration.
 			if r.ContainerID != "" {
 				updCtx, updCancel := context.WithTimeout(ctx, 5*time.Second)
-				_ = dc.UpdateRun(updCtx, r.ProxyAuthToken, r.ContainerID)
+				_ = dc.UpdateRun(updCtx, r.ProxyAuthToken, r.daemonUpdate())
 				updCancel()
 			}
 			log.Info("run re-registered with proxy daemon",
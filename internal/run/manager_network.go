@@ -4,6 +4,7 @@ package run
 
 import (
 	goruntime "runtime"
+	"strings"
 
 	"github.com/majorcontext/moat/internal/container"
 )
@@ -59,3 +60,31 @@ func (m *Manager) resolveNetworkConfig(needsPorts, needsProxy bool, hostAddr str
 	extraHosts = append(extraHosts, synthHosts...)
 	return networkMode, extraHosts
 }
+
+// SharedNetwork is a network several runs join so they can reach each other
+// directly. The container joins it in addition to its own network, so the
+// run keeps its proxy access and any service containers stay private to it.
+type SharedNetwork struct {
+	// ID is the network to join. The caller creates and removes it.
+	ID string
+	// Alias is the hostname other runs on the network use for this run.
+	Alias string
+	// Peers are the hostnames of the runs on the network. They are added to
+	// NO_PROXY, since the proxy on the host cannot resolve them.
+	Peers []string
+}
+
+// appendNoProxy adds hosts to the NO_PROXY and no_proxy entries of env.
+func appendNoProxy(env []string, hosts []string) []string {
+	if len(hosts) == 0 {
+		return env
+	}
+	out := make([]string, len(env))
+	for i, kv := range env {
+		if strings.HasPrefix(kv, "NO_PROXY=") || strings.HasPrefix(kv, "no_proxy=") {
+			kv += "," + strings.Join(hosts, ",")
+		}
+		out[i] = kv
+	}
+	return out
+}
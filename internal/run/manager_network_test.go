@@ -72,3 +72,15 @@ func TestResolveNetworkConfig_BridgeWhenNoHostNetwork(t *testing.T) {
 		t.Fatalf("expected bridge mode when host network unsupported, got %q", mode)
 	}
 }
+
+func TestAppendNoProxy(t *testing.T) {
+	env := buildProxyEnv("tok", 8080, false)
+	got := appendNoProxy(env, []string{"api", "web"})
+	want := "NO_PROXY=" + syntheticProxyHost + ",buildkit,localhost,127.0.0.1,api,web"
+	if !slices.Contains(got, want) || !slices.Contains(got, "no_proxy"+want[len("NO_PROXY"):]) {
+		t.Errorf("appendNoProxy = %v, want %s in both cases", got, want)
+	}
+	if slices.Contains(env, want) {
+		t.Error("appendNoProxy modified its input")
+	}
+}
@@ -192,6 +192,10 @@ func (m *Manager) registerPersistedRun(runState State, stateConfirmed bool, skip
 		Name:              meta.Name,
 		Workspace:         meta.Workspace,
 		Grants:            meta.Grants,
+		Labels:            meta.Labels,
+		Group:             meta.Group,
+		IdempotencyKey:    meta.IdempotencyKey,
+		PullRequest:       meta.PullRequest,
 		Agent:             meta.Agent,
 		Image:             meta.Image,
 		Runtime:           meta.Runtime,
@@ -208,11 +212,19 @@ func (m *Manager) registerPersistedRun(runState State, stateConfirmed bool, skip
 		exitCh:            make(chan struct{}),
 		ServiceContainers: serviceContainers,
 		NetworkID:         meta.NetworkID,
+		InternalNetworkID: meta.InternalNetworkID,
 		WorktreeBranch:    meta.WorktreeBranch,
 		WorktreePath:      meta.WorktreePath,
 		WorktreeRepoID:    meta.WorktreeRepoID,
+		WorkspaceRepos:    meta.WorkspaceRepos,
 		WorkspaceMode:     meta.WorkspaceMode,
 		WorkspaceVolume:   meta.WorkspaceVolume,
+		TestResults:       meta.TestResults,
+		ExitCode:          meta.ExitCode,
+		FailureClass:      FailureClass(meta.FailureClass),
+		MemoryMB:          meta.MemoryMB,
+		Priority:          container.Priority(meta.Priority),
+		IDE:               meta.IDE,
 	}
 
 	// If container is confirmed stopped by a live check or by authoritative
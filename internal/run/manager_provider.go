@@ -10,6 +10,8 @@ import (
 	"github.com/majorcontext/moat/internal/credential"
 	"github.com/majorcontext/moat/internal/log"
 	"github.com/majorcontext/moat/internal/provider"
+	"github.com/majorcontext/moat/internal/providers/containerregistry"
+	"github.com/majorcontext/moat/internal/ui"
 )
 
 // setupProviderMounts collects provider-specific container mounts and init
@@ -56,6 +58,11 @@ func (m *Manager) setupProviderMounts(r *Run, grants []string, containerHome str
 							log.Warn("init file path outside container home, skipping", "provider", credName, "path", p)
 							continue
 						}
+						// Several providers can contribute ~/.netrc entries
+						// (e.g. gerrit and bitbucket-server); keep them all.
+						if existing, ok := initFiles[cleaned]; ok && filepath.Base(cleaned) == ".netrc" {
+							content = existing + content
+						}
 						initFiles[cleaned] = content
 					}
 				}
@@ -64,3 +71,16 @@ func (m *Manager) setupProviderMounts(r *Run, grants []string, containerHome str
 	}
 	return mounts, initFiles
 }
+
+// dropHostDockerConfig removes the container-registry grant's Docker config
+// from initFiles for docker:host runs. The host daemon pulls images without
+// going through the proxy, so the placeholder credentials would reach the
+// registry and turn anonymous pulls of public images into failed logins.
+func dropHostDockerConfig(initFiles map[string]string, containerHome string) {
+	path := containerregistry.DockerConfigPath(containerHome)
+	if _, ok := initFiles[path]; !ok {
+		return
+	}
+	delete(initFiles, path)
+	ui.Warn("The container-registry grant has no effect with docker:host; the host Docker daemon pulls images without the proxy. Use docker:dind instead.")
+}
@@ -37,3 +37,17 @@ func TestSetupProviderMounts_CredMissSkipped(t *testing.T) {
 		t.Fatalf("expected no cleanup paths, got %v", r.ProviderCleanupPaths)
 	}
 }
+
+func TestDropHostDockerConfig(t *testing.T) {
+	initFiles := map[string]string{
+		"/home/moatuser/.docker/config.json": `{"auths":{}}`,
+		"/home/moatuser/.netrc":              "machine example.com",
+	}
+	dropHostDockerConfig(initFiles, "/home/moatuser")
+	if _, ok := initFiles["/home/moatuser/.docker/config.json"]; ok {
+		t.Error("Docker config should be dropped for docker:host runs")
+	}
+	if _, ok := initFiles["/home/moatuser/.netrc"]; !ok {
+		t.Error("other init files should be kept")
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/majorcontext/moat/internal/config"
 	"github.com/majorcontext/moat/internal/container"
 	"github.com/majorcontext/moat/internal/log"
+	"github.com/majorcontext/moat/internal/ui"
 )
 
 // resolveResourceLimits extracts a run's container resource limits (memory,
@@ -40,3 +41,21 @@ func (m *Manager) resolveResourceLimits(cfg *config.Config) (memoryMB, cpus int,
 	}
 	return memoryMB, cpus, dns, ulimits
 }
+
+// resolvePriority returns a run's priority class: the --priority flag, then
+// container.priority in moat.yaml. Runtimes that cannot weight containers
+// get the default class with a warning.
+func (m *Manager) resolvePriority(opts Options) container.Priority {
+	priority := opts.Priority
+	if priority == "" && opts.Config != nil {
+		priority = container.Priority(opts.Config.Container.Priority)
+	}
+	if priority == "" || priority == container.PriorityNormal {
+		return priority
+	}
+	if rt := m.defaultRuntime().Type(); rt == container.RuntimeApple {
+		ui.Warnf("priority %s: %s containers cannot be weighted against each other; using the default", priority, rt)
+		return ""
+	}
+	return priority
+}
@@ -1,6 +1,7 @@
 package run
 
 import (
+	"context"
 	"testing"
 
 	"github.com/majorcontext/moat/internal/config"
@@ -74,3 +75,27 @@ func TestResolveResourceLimits_AppleExplicitMemoryKept(t *testing.T) {
 		t.Fatalf("explicit memory should be kept over the Apple default, got %d", mem)
 	}
 }
+
+func TestResolveUsernsMode(t *testing.T) {
+	tests := []struct {
+		name       string
+		support    string
+		user       string
+		volumeMode bool
+		want       string
+	}{
+		{name: "keep-id maps host user to workspace owner", support: container.UsernsKeepID, user: "1000:1000", want: "keep-id:uid=1000,gid=1000"},
+		{name: "keep-id maps host user to moatuser", support: container.UsernsKeepID, user: "5000:5000", want: "keep-id:uid=5000,gid=5000"},
+		{name: "volume mode keeps default mapping", support: container.UsernsKeepID, user: "0:0", volumeMode: true, want: "keep-id"},
+		{name: "daemon remaps every container", support: container.UsernsDaemon, user: "1000:1000", want: ""},
+		{name: "unsupported falls back", support: container.UsernsNone, user: "1000:1000", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &Manager{runtimePool: container.NewRuntimePoolWithDefault(&stubRuntime{userns: tt.support})}
+			if got := m.resolveUsernsMode(context.Background(), tt.user, tt.volumeMode); got != tt.want {
+				t.Errorf("resolveUsernsMode() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package run
+
+import (
+	"context"
+	"path/filepath"
+	goruntime "runtime"
+	"time"
+
+	"github.com/majorcontext/moat/internal/config"
+	"github.com/majorcontext/moat/internal/log"
+	"github.com/majorcontext/moat/internal/snapshot"
+	"github.com/majorcontext/moat/internal/trace"
+	"github.com/majorcontext/moat/internal/worktree"
+)
+
+// reflogPollInterval is how often the workspace's HEAD reflog is checked
+// for new commits.
+const reflogPollInterval = 2 * time.Second
+
+// hostPIDer is implemented by runtimes that report a container's host PID
+// (Docker, and Podman through it).
+type hostPIDer interface {
+	ContainerPID(ctx context.Context, id string) (int, error)
+}
+
+// startCommitSnapshots snapshots the workspace after each git commit made
+// during the run, until the container exits. Commits are detected from the
+// workspace's HEAD reflog and, where moat can trace the container's
+// processes, from git exec events. Skipped in volume mode, where the host
+// workspace is not the live tree.
+func (m *Manager) startCommitSnapshots(ctx context.Context, r *Run) {
+	if r.SnapEngine == nil || r.DisableCommitSnapshots || config.IsVolumeMode(r.WorkspaceMode) {
+		return
+	}
+	// In a worktree .git is a file pointing at the worktree's git directory,
+	// which holds its HEAD reflog.
+	gitDir, err := worktree.FindGitDir(r.Workspace)
+	if err != nil {
+		log.Debug("commit snapshots: workspace is not a git repository", "run", r.ID, "error", err)
+		gitDir = filepath.Join(r.Workspace, ".git")
+	}
+	trigger := snapshot.NewCommitTrigger(r.SnapEngine, gitDir)
+	r.commitTrigger = trigger
+
+	if tracer := m.startExecTracer(ctx, r, trigger.HandleExec); tracer != nil {
+		go func() {
+			<-r.exitCh
+			_ = tracer.Stop()
+		}()
+	}
+
+	watchCtx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-r.exitCh
+		cancel()
+	}()
+	go trigger.WatchReflog(watchCtx, reflogPollInterval)
+}
+
+// startExecTracer starts a tracer for the container's processes that calls
+// onExec for each exec. Returns nil when tracing is unavailable: off Linux
+// (Docker Desktop's PIDs belong to its VM), on runtimes without host PIDs,
+// or without the privileges the proc connector needs.
+func (m *Manager) startExecTracer(ctx context.Context, r *Run, onExec func(trace.ExecEvent)) trace.Tracer {
+	if goruntime.GOOS != "linux" {
+		return nil
+	}
+	rt, err := m.runtimeForRun(r)
+	if err != nil {
+		return nil
+	}
+	pider, ok := rt.(hostPIDer)
+	if !ok {
+		return nil
+	}
+	pid, err := pider.ContainerPID(ctx, r.ContainerID)
+	if err != nil {
+		log.Debug("exec tracing unavailable", "run", r.ID, "error", err)
+		return nil
+	}
+	tracer, err := trace.New(trace.Config{PID: pid})
+	if err != nil {
+		log.Debug("exec tracing unavailable", "run", r.ID, "error", err)
+		return nil
+	}
+	tracer.OnExec(onExec)
+	if err := tracer.Start(); err != nil {
+		log.Debug("exec tracing unavailable", "run", r.ID, "error", err)
+		return nil
+	}
+	// Callbacks receive every event; drain the channel so it never fills.
+	go func() {
+		for range tracer.Events() {
+		}
+	}()
+	return tracer
+}
+
+// snapshotAfterRun creates the post-run snapshot once the container has
+// exited, after any pending commit snapshots, so the last snapshot of a run
+// is its final workspace state.
+func snapshotAfterRun(r *Run) {
+	if r.commitTrigger != nil {
+		r.commitTrigger.Wait()
+	}
+	if r.SnapEngine == nil || r.DisablePostRunSnapshot || config.IsVolumeMode(r.WorkspaceMode) {
+		return
+	}
+	if _, err := r.SnapEngine.Create(snapshot.TypePostRun, ""); err != nil {
+		log.Debug("failed to create post-run snapshot", "error", err)
+	}
+}
@@ -13,10 +13,15 @@ import (
 	"github.com/majorcontext/moat/internal/config"
 	"github.com/majorcontext/moat/internal/container"
 	"github.com/majorcontext/moat/internal/credential"
+	"github.com/majorcontext/moat/internal/deps"
 	"github.com/majorcontext/moat/internal/log"
 	"github.com/majorcontext/moat/internal/sshagent"
+	"github.com/majorcontext/moat/internal/ui"
 )
 
+// containerKnownHostsDir holds the run's pinned known_hosts file.
+const containerKnownHostsDir = "/run/moat/ssh-known-hosts"
+
 // sshAgentSetup is the result of wiring an SSH agent proxy for a run's SSH
 // grants: the started proxy server (nil when there are no SSH grants) plus the
 // container env and mounts the run needs to reach it.
@@ -72,6 +77,12 @@ func (m *Manager) setupSSHAgent(r *Run, opts Options, sshGrants []string, hostAd
 	for _, mapping := range sshMappings {
 		sshProxy.AllowKey(mapping.KeyFingerprint, []string{mapping.Host})
 	}
+	if opts.Config != nil {
+		sshProxy.SetLimits(sshagent.Limits{
+			PerMinute: opts.Config.SSH.MaxSignaturesPerMinute,
+			PerRun:    opts.Config.SSH.MaxSignatures,
+		})
+	}
 
 	// Unix sockets can't be shared across VM boundaries. This affects:
 	// - Docker Desktop on macOS/Windows (containers run in a Linux VM)
@@ -155,6 +166,43 @@ func (m *Manager) setupSSHAgent(r *Run, opts Options, sshGrants []string, hostAd
 			"keys", len(sshMappings))
 	}
 
+	// Pin the granted hosts' SSH host keys: git in the container trusts only
+	// the keys verified at grant time rather than trusting on first use.
+	// Hosts granted before pinning existed are still trusted on first use,
+	// without loosening checks for the pinned ones. A user-supplied
+	// GIT_SSH_COMMAND takes precedence.
+	knownHosts, unpinned := sshKnownHosts(sshMappings)
+	if len(unpinned) > 0 {
+		ui.Warnf("SSH host keys are not pinned for %s, so they are trusted on first use; run 'moat grant ssh --host %s' again to pin them",
+			strings.Join(unpinned, ", "), unpinned[0])
+	}
+	if len(knownHosts) > 0 && !envHasKey(opts.Env, "GIT_SSH_COMMAND") && (opts.Config == nil || opts.Config.Env["GIT_SSH_COMMAND"] == "") {
+		knownHostsDir, err := os.MkdirTemp("", "moat-ssh-*")
+		if err == nil {
+			err = os.WriteFile(filepath.Join(knownHostsDir, "known_hosts"), []byte(strings.Join(knownHosts, "\n")+"\n"), 0o644)
+			if err != nil {
+				os.RemoveAll(knownHostsDir)
+			}
+		}
+		if err != nil {
+			if stopErr := setup.server.Stop(); stopErr != nil {
+				log.Debug("failed to stop SSH agent during cleanup", "error", stopErr)
+			}
+			upstreamAgent.Close()
+			return sshAgentSetup{}, fmt.Errorf("writing SSH known_hosts: %w", err)
+		}
+		r.sshKnownHostsDir = knownHostsDir // Track for cleanup
+
+		// Mount a directory (not the file): Apple containers only support
+		// directory mounts.
+		setup.mounts = append(setup.mounts, container.MountConfig{
+			Source:   knownHostsDir,
+			Target:   containerKnownHostsDir,
+			ReadOnly: true,
+		})
+		setup.env = append(setup.env, "GIT_SSH_COMMAND="+sshCommand(len(unpinned) > 0))
+	}
+
 	// When both github and ssh:github.com grants are active, prefer SSH for
 	// github.com git operations. HTTPS git works on its own (issue #370), so
 	// this is a routing preference, not a workaround: it makes git use the
@@ -162,16 +210,49 @@ func (m *Manager) setupSSHAgent(r *Run, opts Options, sshGrants []string, hostAd
 	// user opted into by granting ssh:github.com. The MOAT_GIT_SSH_GITHUB env
 	// var drives the url.insteadOf rewrite in moat-init.sh.
 	// Check if user explicitly set MOAT_GIT_SSH_GITHUB (e.g. =0 to opt out)
-	gitSSHAlreadySet := false
-	for _, e := range opts.Env {
-		if strings.HasPrefix(e, "MOAT_GIT_SSH_GITHUB=") {
-			gitSSHAlreadySet = true
-			break
-		}
-	}
-	if !gitSSHAlreadySet && slices.Contains(sshGrants, "github.com") && slices.Contains(opts.Grants, "github") {
+	if !envHasKey(opts.Env, "MOAT_GIT_SSH_GITHUB") && slices.Contains(sshGrants, "github.com") && slices.Contains(opts.Grants, "github") {
 		setup.env = append(setup.env, "MOAT_GIT_SSH_GITHUB=1")
 	}
 
 	return setup, nil
 }
+
+// sshKnownHosts returns the known_hosts entries for mappings: the host keys
+// pinned at grant time, or moat's built-in keys for well-known hosts granted
+// before pinning existed. Hosts with neither are returned as unpinned.
+func sshKnownHosts(mappings []credential.SSHMapping) (lines, unpinned []string) {
+	for _, m := range mappings {
+		keys := m.HostKeys
+		if len(keys) == 0 {
+			keys = deps.KnownSSHHostKeys(m.Host)
+		}
+		if len(keys) == 0 {
+			unpinned = append(unpinned, m.Host)
+			continue
+		}
+		lines = append(lines, keys...)
+	}
+	return lines, unpinned
+}
+
+// sshCommand returns the GIT_SSH_COMMAND that checks host keys against the
+// pinned known_hosts file. With unpinned hosts it uses accept-new: a host
+// missing from the file is trusted on first use, but a pinned host whose
+// key differs is still refused.
+func sshCommand(unpinned bool) string {
+	checking := "yes"
+	if unpinned {
+		checking = "accept-new"
+	}
+	return "ssh -o UserKnownHostsFile=" + containerKnownHostsDir + "/known_hosts -o StrictHostKeyChecking=" + checking
+}
+
+// envHasKey reports whether env (KEY=value entries) sets key.
+func envHasKey(env []string, key string) bool {
+	for _, e := range env {
+		if strings.HasPrefix(e, key+"=") {
+			return true
+		}
+	}
+	return false
+}
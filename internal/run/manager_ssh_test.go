@@ -79,3 +79,34 @@ func TestSetupSSHAgent_UpstreamConnectError(t *testing.T) {
 		t.Fatalf("expected connecting-to-agent error, got %v", err)
 	}
 }
+
+func TestSSHKnownHosts(t *testing.T) {
+	pinned := "git.example.com ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIOMqqnkVzrm0SdG6UOoqKLsabgH5C9okWi0dh2l9GKJl"
+	lines, unpinned := sshKnownHosts([]credential.SSHMapping{
+		{Host: "git.example.com", HostKeys: []string{pinned}},
+		{Host: "github.com"}, // granted before pinning: built-in keys
+		{Host: "legacy.example.com"},
+	})
+	if len(lines) < 2 || lines[0] != pinned {
+		t.Fatalf("lines = %v, want the pinned key followed by github.com's built-in keys", lines)
+	}
+	for _, l := range lines[1:] {
+		if !strings.HasPrefix(l, "github.com ") {
+			t.Errorf("unexpected known_hosts line %q", l)
+		}
+	}
+	if len(unpinned) != 1 || unpinned[0] != "legacy.example.com" {
+		t.Errorf("unpinned = %v, want [legacy.example.com]", unpinned)
+	}
+}
+
+func TestSSHCommand(t *testing.T) {
+	if got := sshCommand(false); !strings.Contains(got, "StrictHostKeyChecking=yes") || !strings.Contains(got, "UserKnownHostsFile="+containerKnownHostsDir+"/known_hosts") {
+		t.Errorf("sshCommand(false) = %q, want strict checking against the pinned keys", got)
+	}
+	// An unpinned host must not turn off checking for the pinned ones:
+	// accept-new still refuses a changed key.
+	if got := sshCommand(true); !strings.Contains(got, "StrictHostKeyChecking=accept-new") || !strings.Contains(got, "UserKnownHostsFile=") {
+		t.Errorf("sshCommand(true) = %q, want accept-new against the pinned keys", got)
+	}
+}
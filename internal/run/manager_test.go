@@ -9,6 +9,7 @@ import (
 	"path/filepath"
 	"reflect"
 	"runtime"
+	"slices"
 	"strings"
 	"testing"
 	"time"
@@ -832,6 +833,28 @@ func TestComputeBuildKitEnv(t *testing.T) {
 type stubRuntime struct {
 	states map[string]string // container ID -> state (e.g. "exited")
 	done   chan struct{}     // closed by test to unblock WaitContainer
+	userns string            // reported by UserNamespaceMode
+
+	priorities map[string]container.Priority // recorded by UpdatePriority, if non-nil
+}
+
+func (s *stubRuntime) UserNamespaceMode(context.Context) (string, error) {
+	return s.userns, nil
+}
+
+func (s *stubRuntime) UpdatePriority(_ context.Context, id string, p container.Priority) error {
+	if s.priorities != nil {
+		s.priorities[id] = p
+	}
+	return nil
+}
+
+func (s *stubRuntime) ContainerOOMKilled(context.Context, string) (bool, error) {
+	return false, nil
+}
+
+func (s *stubRuntime) ContainerStats(context.Context, string) (container.Stats, error) {
+	return container.Stats{}, container.ErrStatsUnsupported
 }
 
 func (s *stubRuntime) ContainerState(_ context.Context, id string) (string, error) {
@@ -844,6 +867,9 @@ func (s *stubRuntime) ContainerState(_ context.Context, id string) (string, erro
 
 func (s *stubRuntime) Type() container.RuntimeType { return container.RuntimeDocker }
 func (s *stubRuntime) Ping(context.Context) error  { return nil }
+func (s *stubRuntime) Version(context.Context) (string, error) {
+	return "test", nil
+}
 func (s *stubRuntime) CreateContainer(context.Context, container.Config) (string, error) {
 	panic("not implemented")
 }
@@ -892,6 +918,9 @@ func (s *stubRuntime) Close() error                             { return nil }
 func (s *stubRuntime) SetupFirewall(context.Context, string, string, int) error {
 	panic("not implemented")
 }
+func (s *stubRuntime) RestrictServiceAccess(context.Context, string, []container.ServiceAccess) error {
+	panic("not implemented")
+}
 
 func (s *stubRuntime) ListImages(context.Context) ([]container.ImageInfo, error) {
 	panic("not implemented")
@@ -1353,6 +1382,59 @@ func TestLoadPersistedRunsCleansRoutesForPersistedTerminalState(t *testing.T) {
 	}
 }
 
+func TestGitHookEnv(t *testing.T) {
+	env := gitHookEnv(config.WorkspaceConfig{}, "fix-login", []string{"Moat-Run-ID: run_1", "Co-Authored-By: A <a@b>"})
+	want := []string{
+		"GIT_CONFIG_COUNT=1",
+		"GIT_CONFIG_KEY_0=core.hooksPath",
+		"GIT_CONFIG_VALUE_0=/usr/local/share/moat/git-hooks",
+		"MOAT_GIT_PROTECTED_BRANCHES=main master release/*",
+		"MOAT_GIT_AGENT_BRANCH=agent/fix-login",
+		"MOAT_GIT_TRAILERS=Moat-Run-ID: run_1\nCo-Authored-By: A <a@b>",
+	}
+	if !slices.Equal(env, want) {
+		t.Errorf("gitHookEnv(defaults) = %v, want %v", env, want)
+	}
+
+	noPrefix := ""
+	env = gitHookEnv(config.WorkspaceConfig{BranchPrefix: &noPrefix, ProtectedBranches: []string{"trunk"}}, "fix-login", nil)
+	if !slices.Contains(env, "MOAT_GIT_PROTECTED_BRANCHES=trunk") || envHasKey(env, "MOAT_GIT_AGENT_BRANCH") || envHasKey(env, "MOAT_GIT_TRAILERS") {
+		t.Errorf("gitHookEnv(custom) = %v", env)
+	}
+
+	off := config.WorkspaceConfig{ProtectedBranches: []string{}}
+	if env := gitHookEnv(off, "fix-login", nil); env != nil {
+		t.Errorf("gitHookEnv(nothing to do) = %v, want nil", env)
+	}
+	// Trailers alone still need the dispatcher.
+	env = gitHookEnv(off, "fix-login", []string{"Moat-Run-ID: run_1"})
+	if !envHasKey(env, "GIT_CONFIG_COUNT") || envHasKey(env, "MOAT_GIT_PROTECTED_BRANCHES") {
+		t.Errorf("gitHookEnv(trailers only) = %v", env)
+	}
+}
+
+func TestCommitTrailers(t *testing.T) {
+	claude := []deps.Dependency{{Name: "node"}, {Name: "git"}, {Name: "claude-code"}}
+	got := commitTrailers(config.CommitTrailersConfig{}, claude, "run_abc")
+	want := []string{"Co-Authored-By: Claude <noreply@anthropic.com>", "Moat-Run-ID: run_abc"}
+	if !slices.Equal(got, want) {
+		t.Errorf("commitTrailers(claude defaults) = %v, want %v", got, want)
+	}
+
+	// No known identity for the agent: run ID only.
+	if got := commitTrailers(config.CommitTrailersConfig{}, []deps.Dependency{{Name: "git"}}, "run_abc"); !slices.Equal(got, []string{"Moat-Run-ID: run_abc"}) {
+		t.Errorf("commitTrailers(no agent) = %v", got)
+	}
+
+	bot, none, no := "Bot <bot@example.com>", "", false
+	if got := commitTrailers(config.CommitTrailersConfig{CoAuthor: &bot, RunID: &no}, claude, "run_abc"); !slices.Equal(got, []string{"Co-Authored-By: Bot <bot@example.com>"}) {
+		t.Errorf("commitTrailers(custom co-author) = %v", got)
+	}
+	if got := commitTrailers(config.CommitTrailersConfig{CoAuthor: &none, RunID: &no}, claude, "run_abc"); len(got) != 0 {
+		t.Errorf("commitTrailers(disabled) = %v, want none", got)
+	}
+}
+
 // TestHostGitIdentity verifies that hostGitIdentity returns env vars and the
 // hasGit flag based on the dependency list. When git is present on the host,
 // the function shells out to read user.name/user.email — we can't control that
@@ -1652,6 +1734,55 @@ func TestBuildRegisterRequest_HostGateway(t *testing.T) {
 	}
 }
 
+func TestBuildRegisterRequest_AzureConfig(t *testing.T) {
+	rc := daemon.NewRunContext("run_test")
+	rc.AzureConfig = &daemon.AzureConfig{Tenant: "tenant-1", Subscription: "sub-1"}
+
+	req := buildRegisterRequest(rc, nil)
+
+	if req.AzureConfig == nil || req.AzureConfig.Tenant != "tenant-1" || req.AzureConfig.Subscription != "sub-1" {
+		t.Errorf("AzureConfig = %+v, want tenant-1/sub-1", req.AzureConfig)
+	}
+}
+
+func TestQuotasMatch(t *testing.T) {
+	configured := map[string]config.ProviderQuota{"anthropic": {DailyTokens: 1000}}
+	if !quotasMatch(nil, nil) {
+		t.Error("no quotas should match")
+	}
+	if !quotasMatch(configured, map[string]daemon.QuotaStatus{"anthropic": {ProviderQuota: config.ProviderQuota{DailyTokens: 1000}, UsedTokens: 5}}) {
+		t.Error("same quotas with usage should match")
+	}
+	if quotasMatch(configured, nil) {
+		t.Error("quotas unknown to the daemon should not match")
+	}
+	if quotasMatch(configured, map[string]daemon.QuotaStatus{"anthropic": {ProviderQuota: config.ProviderQuota{DailyTokens: 500}}}) {
+		t.Error("changed quotas should not match")
+	}
+}
+
+func TestBuildRegisterRequest_RequestGuards(t *testing.T) {
+	rc := daemon.NewRunContext("run_test")
+	rc.RequestGuards = []daemon.RequestGuard{{Grant: "stripe", Host: "api.stripe.com", Kind: daemon.GuardStripeLiveMode}}
+
+	req := buildRegisterRequest(rc, nil)
+
+	if len(req.RequestGuards) != 1 || req.RequestGuards[0] != rc.RequestGuards[0] {
+		t.Errorf("RequestGuards = %+v, want %+v", req.RequestGuards, rc.RequestGuards)
+	}
+}
+
+func TestBuildRegisterRequest_SendGuard(t *testing.T) {
+	rc := daemon.NewRunContext("run_test")
+	rc.SendGuard = &daemon.SendGuard{Max: 20, Routes: []daemon.SendRoute{{Grant: "sendgrid", Host: "api.sendgrid.com", Method: "POST", Path: "/v3/mail/send"}}}
+
+	req := buildRegisterRequest(rc, nil)
+
+	if req.SendGuard == nil || req.SendGuard.Max != 20 || len(req.SendGuard.Routes) != 1 {
+		t.Errorf("SendGuard = %+v, want max 20 with 1 route", req.SendGuard)
+	}
+}
+
 func TestBuildRegisterRequest_HostGatewayEmpty(t *testing.T) {
 	rc := daemon.NewRunContext("run_test")
 
@@ -1767,6 +1898,28 @@ func TestBuildProxyEnv_NoToken(t *testing.T) {
 	t.Error("HTTP_PROXY not found in env")
 }
 
+// TestBuildBrowserProxyEnv verifies the proxy credentials are split out of
+// the URL for browser launchers, which ignore userinfo in HTTPS_PROXY.
+func TestBuildBrowserProxyEnv(t *testing.T) {
+	got := buildBrowserProxyEnv("secret-token", 19080)
+	want := []string{
+		"MOAT_BROWSER=1",
+		"MOAT_BROWSER_PROXY_SERVER=http://moat-proxy:19080",
+		"MOAT_BROWSER_PROXY_USERNAME=moat",
+		"MOAT_BROWSER_PROXY_PASSWORD=secret-token",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("buildBrowserProxyEnv() = %v, want %v", got, want)
+	}
+
+	got = buildBrowserProxyEnv("", 19080)
+	for _, e := range got {
+		if strings.HasPrefix(e, "MOAT_BROWSER_PROXY_PASSWORD=") {
+			t.Errorf("unexpected %q without an auth token", e)
+		}
+	}
+}
+
 // TestBuildProxyEnv_UsesConstants verifies that buildProxyEnv uses the
 // package-level syntheticProxyHost constant internally and accepts
 // syntheticHostGateway as the MOAT_HOST_GATEWAY value.
@@ -1889,6 +2042,14 @@ func TestSynthHostStrategy(t *testing.T) {
 			wantExtraHosts: nil,
 			wantEnv:        syntheticProxyHost + ":192.168.64.1 " + syntheticHostGateway + ":192.168.64.1",
 		},
+		{
+			name:           "podman linux uses env with resolve sentinel",
+			runtimeType:    container.RuntimePodman,
+			goos:           "linux",
+			hostAddr:       "host.containers.internal",
+			wantExtraHosts: nil,
+			wantEnv:        syntheticProxyHost + ":@host.containers.internal " + syntheticHostGateway + ":@host.containers.internal",
+		},
 		{
 			name:           "docker darwin with IP hostAddr skips sentinel",
 			runtimeType:    container.RuntimeDocker,
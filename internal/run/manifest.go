@@ -0,0 +1,228 @@
+package run
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/majorcontext/moat/internal/config"
+	"github.com/majorcontext/moat/internal/errcode"
+	"github.com/majorcontext/moat/internal/log"
+	"github.com/majorcontext/moat/internal/netrules"
+	"github.com/majorcontext/moat/internal/storage"
+)
+
+// ErrNoConfigManifest is returned by CheckConfigDrift for runs created before
+// moat recorded config manifests.
+var ErrNoConfigManifest = errors.New("run has no recorded configuration")
+
+func init() {
+	errcode.Register(ErrNoConfigManifest, errcode.NoConfigManifest)
+}
+
+// ConfigChange describes one difference between the moat.yaml a run was
+// created with and the moat.yaml on disk now.
+type ConfigChange struct {
+	Field  string `json:"field"`  // e.g. "dependencies", "env", "network.policy"
+	Change string `json:"change"` // "added", "removed", or "changed"
+	Item   string `json:"item"`   // dependency, env var name, rule host, or "old → new"
+}
+
+func (c ConfigChange) String() string {
+	return fmt.Sprintf("%s: %s %s", c.Field, c.Change, c.Item)
+}
+
+// newConfigManifest extracts the drift-relevant settings from cfg. A nil cfg
+// (no moat.yaml) yields an empty manifest.
+func newConfigManifest(cfg *config.Config) storage.ConfigManifest {
+	if cfg == nil {
+		return storage.ConfigManifest{}
+	}
+	m := storage.ConfigManifest{
+		Dependencies:  slices.Clone(cfg.Dependencies),
+		Env:           maps.Clone(cfg.Env),
+		Secrets:       maps.Clone(cfg.Secrets),
+		Grants:        slices.Clone(cfg.Grants),
+		NetworkPolicy: cfg.Network.Policy,
+		NetworkHost:   slices.Clone(cfg.Network.Host),
+		Command:       slices.Clone(cfg.Command),
+	}
+	for _, entry := range cfg.Network.Rules {
+		m.NetworkRules = append(m.NetworkRules, formatRuleEntry(entry.HostRules))
+	}
+	return m
+}
+
+// formatRuleEntry renders a network.rules entry as a single comparable line,
+// e.g. "api.github.com" or "api.github.com: allow GET /repos/*, deny * /*".
+func formatRuleEntry(hr netrules.HostRules) string {
+	if len(hr.Rules) == 0 {
+		return hr.Host
+	}
+	parts := make([]string, len(hr.Rules))
+	for i, r := range hr.Rules {
+		parts[i] = r.Action + " " + r.Method + " " + r.PathPattern
+	}
+	return hr.Host + ": " + strings.Join(parts, ", ")
+}
+
+// recordConfigManifest saves the manifest of the moat.yaml in workspace to
+// the run's store. It reloads moat.yaml rather than using the run's config
+// because CLI flags and agent commands add to that config in memory; the
+// manifest must match what a later reload of the file would produce.
+// Best-effort: a failure only disables drift detection for this run.
+func recordConfigManifest(store *storage.RunStore, workspace string) {
+	cfg, err := config.Load(workspace)
+	if err != nil {
+		log.Debug("skipping config manifest: failed to load config", "error", err)
+		return
+	}
+	if err := store.SaveConfigManifest(newConfigManifest(cfg)); err != nil {
+		log.Debug("failed to save config manifest", "error", err)
+	}
+}
+
+// CheckConfigDrift compares the moat.yaml a run was created with against the
+// moat.yaml currently in its workspace. It returns ErrNoConfigManifest for runs
+// created before manifests were recorded.
+func CheckConfigDrift(r *Run) ([]ConfigChange, error) {
+	if r.Store == nil {
+		return nil, ErrNoConfigManifest
+	}
+	recorded, err := r.Store.LoadConfigManifest()
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNoConfigManifest
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading recorded configuration: %w", err)
+	}
+	cfg, err := config.Load(r.Workspace)
+	if err != nil {
+		return nil, fmt.Errorf("loading current config from %s: %w", r.Workspace, err)
+	}
+	return diffConfigManifest(recorded, newConfigManifest(cfg)), nil
+}
+
+// diffConfigManifest returns the changes from old to new in a stable order.
+// Env and secret values are compared but never included in the output.
+func diffConfigManifest(old, cur storage.ConfigManifest) []ConfigChange {
+	var changes []ConfigChange
+	changes = append(changes, diffDependencies(old.Dependencies, cur.Dependencies)...)
+	changes = append(changes, diffKeyed("env", old.Env, cur.Env)...)
+	changes = append(changes, diffKeyed("secrets", old.Secrets, cur.Secrets)...)
+	changes = append(changes, diffSet("grants", old.Grants, cur.Grants)...)
+	if policyOrDefault(old.NetworkPolicy) != policyOrDefault(cur.NetworkPolicy) {
+		changes = append(changes, ConfigChange{
+			Field:  "network.policy",
+			Change: "changed",
+			Item:   policyOrDefault(old.NetworkPolicy) + " → " + policyOrDefault(cur.NetworkPolicy),
+		})
+	}
+	changes = append(changes, diffSet("network.rules", old.NetworkRules, cur.NetworkRules)...)
+	changes = append(changes, diffSet("network.host", intStrings(old.NetworkHost), intStrings(cur.NetworkHost))...)
+	if !slices.Equal(old.Command, cur.Command) {
+		changes = append(changes, ConfigChange{
+			Field:  "command",
+			Change: "changed",
+			Item:   fmt.Sprintf("%q → %q", strings.Join(old.Command, " "), strings.Join(cur.Command, " ")),
+		})
+	}
+	return changes
+}
+
+func policyOrDefault(p string) string {
+	if p == "" {
+		return "permissive"
+	}
+	return p
+}
+
+// diffDependencies compares dependency lists by name so a version bump
+// ("node@20" → "node@22") reports as one change.
+func diffDependencies(old, cur []string) []ConfigChange {
+	byName := func(specs []string) map[string]string {
+		m := make(map[string]string, len(specs))
+		for _, s := range specs {
+			name, _, _ := strings.Cut(s, "@")
+			m[name] = s
+		}
+		return m
+	}
+	oldBy, curBy := byName(old), byName(cur)
+	var changes []ConfigChange
+	for _, name := range sortedUnion(oldBy, curBy) {
+		o, inOld := oldBy[name]
+		c, inCur := curBy[name]
+		switch {
+		case !inOld:
+			changes = append(changes, ConfigChange{Field: "dependencies", Change: "added", Item: c})
+		case !inCur:
+			changes = append(changes, ConfigChange{Field: "dependencies", Change: "removed", Item: o})
+		case o != c:
+			changes = append(changes, ConfigChange{Field: "dependencies", Change: "changed", Item: o + " → " + c})
+		}
+	}
+	return changes
+}
+
+// diffKeyed compares key/value maps, reporting keys only.
+func diffKeyed(field string, old, cur map[string]string) []ConfigChange {
+	var changes []ConfigChange
+	for _, key := range sortedUnion(old, cur) {
+		o, inOld := old[key]
+		c, inCur := cur[key]
+		switch {
+		case !inOld:
+			changes = append(changes, ConfigChange{Field: field, Change: "added", Item: key})
+		case !inCur:
+			changes = append(changes, ConfigChange{Field: field, Change: "removed", Item: key})
+		case o != c:
+			changes = append(changes, ConfigChange{Field: field, Change: "changed", Item: key})
+		}
+	}
+	return changes
+}
+
+// diffSet compares two lists as sets.
+func diffSet(field string, old, cur []string) []ConfigChange {
+	var changes []ConfigChange
+	for _, s := range cur {
+		if !slices.Contains(old, s) {
+			changes = append(changes, ConfigChange{Field: field, Change: "added", Item: s})
+		}
+	}
+	for _, s := range old {
+		if !slices.Contains(cur, s) {
+			changes = append(changes, ConfigChange{Field: field, Change: "removed", Item: s})
+		}
+	}
+	return changes
+}
+
+func sortedUnion[V any](a, b map[string]V) []string {
+	keys := make(map[string]bool, len(a)+len(b))
+	for k := range a {
+		keys[k] = true
+	}
+	for k := range b {
+		keys[k] = true
+	}
+	out := make([]string, 0, len(keys))
+	for k := range keys {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func intStrings(ns []int) []string {
+	out := make([]string, len(ns))
+	for i, n := range ns {
+		out[i] = fmt.Sprint(n)
+	}
+	return out
+}
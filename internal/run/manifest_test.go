@@ -0,0 +1,95 @@
+package run
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/majorcontext/moat/internal/storage"
+)
+
+func TestDiffConfigManifest(t *testing.T) {
+	old := storage.ConfigManifest{
+		Dependencies: []string{"node@20", "git"},
+		Env:          map[string]string{"DEBUG": "1", "MODE": "dev"},
+		Secrets:      map[string]string{"TOKEN": "op://vault/item/token"},
+		Grants:       []string{"github"},
+		NetworkRules: []string{"api.github.com"},
+		Command:      []string{"npm", "test"},
+	}
+	cur := storage.ConfigManifest{
+		Dependencies:  []string{"node@22", "python"},
+		Env:           map[string]string{"DEBUG": "1", "MODE": "prod", "NEW": "x"},
+		Secrets:       map[string]string{"TOKEN": "op://vault/item/token"},
+		Grants:        []string{"github", "anthropic"},
+		NetworkPolicy: "strict",
+		NetworkRules:  []string{"api.github.com", "pypi.org"},
+		NetworkHost:   []int{5432},
+		Command:       []string{"npm", "test"},
+	}
+
+	got := diffConfigManifest(old, cur)
+	want := []ConfigChange{
+		{Field: "dependencies", Change: "removed", Item: "git"},
+		{Field: "dependencies", Change: "changed", Item: "node@20 → node@22"},
+		{Field: "dependencies", Change: "added", Item: "python"},
+		{Field: "env", Change: "changed", Item: "MODE"},
+		{Field: "env", Change: "added", Item: "NEW"},
+		{Field: "grants", Change: "added", Item: "anthropic"},
+		{Field: "network.policy", Change: "changed", Item: "permissive → strict"},
+		{Field: "network.rules", Change: "added", Item: "pypi.org"},
+		{Field: "network.host", Change: "added", Item: "5432"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("diffConfigManifest() = %v\nwant %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("change[%d] = %v, want %v", i, got[i], want[i])
+		}
+	}
+
+	if changes := diffConfigManifest(cur, cur); len(changes) != 0 {
+		t.Errorf("identical manifests should not drift, got %v", changes)
+	}
+}
+
+func TestCheckConfigDrift(t *testing.T) {
+	workspace := t.TempDir()
+	writeConfig := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(workspace, "moat.yaml"), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	store, err := storage.NewRunStore(t.TempDir(), "run_drift")
+	if err != nil {
+		t.Fatalf("NewRunStore: %v", err)
+	}
+	r := &Run{ID: "run_drift", Workspace: workspace, Store: store}
+
+	if _, err := CheckConfigDrift(r); !errors.Is(err, ErrNoConfigManifest) {
+		t.Fatalf("CheckConfigDrift without manifest = %v, want ErrNoConfigManifest", err)
+	}
+
+	writeConfig("dependencies:\n  - node@20\nenv:\n  MODE: dev\n")
+	recordConfigManifest(store, workspace)
+
+	changes, err := CheckConfigDrift(r)
+	if err != nil {
+		t.Fatalf("CheckConfigDrift: %v", err)
+	}
+	if len(changes) != 0 {
+		t.Fatalf("unchanged moat.yaml reported drift: %v", changes)
+	}
+
+	writeConfig("dependencies:\n  - node@22\nenv:\n  MODE: dev\n")
+	changes, err = CheckConfigDrift(r)
+	if err != nil {
+		t.Fatalf("CheckConfigDrift: %v", err)
+	}
+	if len(changes) != 1 || changes[0].Item != "node@20 → node@22" {
+		t.Errorf("changes = %v, want node version change", changes)
+	}
+}
@@ -0,0 +1,54 @@
+package run
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/majorcontext/moat/internal/container"
+	"github.com/majorcontext/moat/internal/daemon"
+)
+
+// moatctlBinaries holds static Linux builds of cmd/moatctl, the in-container
+// moatctl helper, one per architecture. They are built by go generate
+// (which the release build runs) and are not checked in.
+//
+//go:generate env CGO_ENABLED=0 GOOS=linux GOARCH=amd64 go build -trimpath "-ldflags=-s -w" -o moatctlbin/moatctl-linux-amd64 ../../cmd/moatctl
+//go:generate env CGO_ENABLED=0 GOOS=linux GOARCH=arm64 go build -trimpath "-ldflags=-s -w" -o moatctlbin/moatctl-linux-arm64 ../../cmd/moatctl
+//go:embed all:moatctlbin
+var moatctlBinaries embed.FS
+
+// moatctlDir is where the moatctl helper is mounted in the container.
+const moatctlDir = "/moat/bin"
+
+// moatctlBinary returns the moatctl helper built for containers of arch.
+func moatctlBinary(arch string) ([]byte, error) {
+	data, err := moatctlBinaries.ReadFile("moatctlbin/moatctl-linux-" + arch)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("moatctl for linux/%s is not built into this moat binary (run 'go generate ./internal/run' before building)", arch)
+	}
+	return data, err
+}
+
+// setupMoatctl writes the moatctl helper binary to a temp directory and
+// returns the directory (for cleanup), its mount, and the env vars the
+// helper reads.
+func setupMoatctl(proxyHost, authToken string, binary []byte) (string, container.MountConfig, []string, error) {
+	dir, err := os.MkdirTemp("", "moat-ctl-*")
+	if err != nil {
+		return "", container.MountConfig{}, nil, fmt.Errorf("creating moatctl directory: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "moatctl"), binary, 0o755); err != nil {
+		os.RemoveAll(dir)
+		return "", container.MountConfig{}, nil, fmt.Errorf("writing moatctl: %w", err)
+	}
+	mount := container.MountConfig{Source: dir, Target: moatctlDir, ReadOnly: true}
+	env := []string{
+		"MOAT_CTL_URL=http://" + proxyHost + daemon.CtlEndpointPath,
+		"MOAT_CTL_TOKEN=" + authToken,
+	}
+	return dir, mount, env, nil
+}
@@ -0,0 +1,41 @@
+package run
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestSetupMoatctl(t *testing.T) {
+	dir, mount, env, err := setupMoatctl("moat-proxy:9100", "tok", []byte("\x7fELF"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	info, err := os.Stat(filepath.Join(dir, "moatctl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm()&0o111 == 0 {
+		t.Errorf("moatctl mode = %v, want executable", info.Mode())
+	}
+	if mount.Source != dir || mount.Target != moatctlDir || !mount.ReadOnly {
+		t.Errorf("mount = %+v", mount)
+	}
+	for _, want := range []string{
+		"MOAT_CTL_URL=http://moat-proxy:9100/_aws/credentials/moatctl",
+		"MOAT_CTL_TOKEN=tok",
+	} {
+		if !slices.Contains(env, want) {
+			t.Errorf("env = %v, missing %s", env, want)
+		}
+	}
+}
+
+func TestMoatctlBinary_NotBuilt(t *testing.T) {
+	if _, err := moatctlBinary("mips"); err == nil {
+		t.Error("moatctlBinary() for an architecture moat does not build = nil error")
+	}
+}
@@ -0,0 +1,144 @@
+package run
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/majorcontext/moat/internal/audit"
+	"github.com/majorcontext/moat/internal/config"
+	"github.com/majorcontext/moat/internal/container"
+	"github.com/majorcontext/moat/internal/deps"
+)
+
+// isolationModeNoEgress is the IsolationData.Mode recorded for --no-egress runs.
+const isolationModeNoEgress = "no-egress"
+
+// validateNoEgress rejects configuration that would give a no-egress run
+// credentials, a path off the box, or write access to the host workspace.
+// Each error names the setting to remove so users can fix moat.yaml directly.
+func validateNoEgress(opts Options) error {
+	if len(opts.Grants) > 0 {
+		return fmt.Errorf("--no-egress runs cannot use grants (got: %s)\n\n"+
+			"Remove --grant flags and the grants: list from moat.yaml.", strings.Join(opts.Grants, ", "))
+	}
+	if opts.WorkspaceMode == config.WorkspaceModeVolume {
+		return fmt.Errorf("--no-egress requires the bind workspace mode (mounted read-only)\n\n" +
+			"Remove --workspace-mode volume or workspace.mode: volume from moat.yaml.")
+	}
+
+	cfg := opts.Config
+	if cfg == nil {
+		return nil
+	}
+	var conflicts []string
+	if len(cfg.MCP) > 0 {
+		conflicts = append(conflicts, "mcp: (remote MCP servers)")
+	}
+	if len(cfg.Network.Rules) > 0 {
+		conflicts = append(conflicts, "network.rules: (allowlist entries)")
+	}
+	if len(cfg.Network.Host) > 0 {
+		conflicts = append(conflicts, "network.host: (host port access)")
+	}
+	if cfg.Network.Mirror != nil {
+		conflicts = append(conflicts, "network.mirror: (request copies sent off the box)")
+	}
+	if cfg.Claude.BaseURL != "" {
+		conflicts = append(conflicts, "claude.base_url: (LLM relay)")
+	}
+	if len(cfg.Services) > 0 {
+		conflicts = append(conflicts, "services:")
+	}
+	if depList, err := deps.ParseAll(cfg.Dependencies); err == nil {
+		if HasDockerDependency(depList) {
+			conflicts = append(conflicts, "dependencies: docker (host or dind daemon access)")
+		}
+		// Service containers run on the run network with egress, and the
+		// agent holds their credentials.
+		for _, svc := range deps.FilterServices(depList) {
+			conflicts = append(conflicts, fmt.Sprintf("dependencies: %s (service containers have egress)", svc.Name))
+		}
+	}
+	for _, me := range cfg.Mounts {
+		if !me.ReadOnly {
+			conflicts = append(conflicts, fmt.Sprintf("mounts: %s (must be read-only; add :ro)", me.Target))
+		}
+	}
+	if len(conflicts) > 0 {
+		return fmt.Errorf("--no-egress cannot be combined with:\n  %s\n\n"+
+			"Remove these settings from moat.yaml (or the matching flags) to run isolated.",
+			strings.Join(conflicts, "\n  "))
+	}
+	return nil
+}
+
+// noEgressConfig returns a copy of cfg with the settings a no-egress run
+// enforces: strict network policy (with no rules, an empty allowlist) and
+// no shared package caches, which would be writable across runs.
+func noEgressConfig(cfg *config.Config) *config.Config {
+	var c config.Config
+	if cfg != nil {
+		c = *cfg
+	}
+	c.Network.Policy = "strict"
+	c.Caches.Enabled = false
+	return &c
+}
+
+// isolationSignerKeyPath returns the path of the Ed25519 key used to sign
+// isolation attestations. The key is shared across runs so attestations from
+// one host verify against the same public key.
+func isolationSignerKeyPath() string {
+	return filepath.Join(config.GlobalConfigDir(), "audit-signing.key")
+}
+
+// newIsolationSigner loads or creates the isolation attestation signing key.
+func newIsolationSigner() (*audit.Signer, error) {
+	keyPath := isolationSignerKeyPath()
+	if err := os.MkdirAll(filepath.Dir(keyPath), 0o700); err != nil {
+		return nil, fmt.Errorf("creating config directory: %w", err)
+	}
+	signer, err := audit.NewSigner(keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("loading attestation signing key %s: %w", keyPath, err)
+	}
+	return signer, nil
+}
+
+// isolationRecord describes a no-egress run's configuration for the audit log.
+// Only host bind mounts are listed; tmpfs overlays and named volumes never
+// expose host paths.
+func isolationRecord(r *Run, mounts []container.MountConfig, privileged bool) audit.IsolationData {
+	data := audit.IsolationData{
+		Mode:          isolationModeNoEgress,
+		Grants:        []string{},
+		NetworkPolicy: "strict",
+		AllowedHosts:  []string{},
+		Mounts:        []audit.IsolationMount{},
+		Services:      []string{},
+		Image:         r.Image,
+		Runtime:       r.Runtime,
+		Privileged:    privileged,
+	}
+	for name := range r.ServiceContainers {
+		data.Services = append(data.Services, name)
+	}
+	sort.Strings(data.Services)
+	for _, mc := range mounts {
+		if mc.Source == "" || mc.Volume {
+			continue
+		}
+		if mc.Target == "/workspace" {
+			data.WorkspaceReadOnly = mc.ReadOnly
+		}
+		data.Mounts = append(data.Mounts, audit.IsolationMount{
+			Source:   mc.Source,
+			Target:   mc.Target,
+			ReadOnly: mc.ReadOnly,
+		})
+	}
+	return data
+}
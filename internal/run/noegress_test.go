@@ -0,0 +1,101 @@
+package run
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/majorcontext/moat/internal/config"
+	"github.com/majorcontext/moat/internal/container"
+	"github.com/majorcontext/moat/internal/netrules"
+)
+
+func TestValidateNoEgress(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    Options
+		wantErr string
+	}{
+		{name: "no config", opts: Options{}},
+		{name: "read-only mount", opts: Options{Config: &config.Config{
+			Mounts: []config.MountEntry{{Source: "./data", Target: "/data", ReadOnly: true}},
+		}}},
+		{name: "grants", opts: Options{Grants: []string{"github"}}, wantErr: "cannot use grants (got: github)"},
+		{name: "volume mode", opts: Options{WorkspaceMode: config.WorkspaceModeVolume}, wantErr: "bind workspace mode"},
+		{name: "network rules", opts: Options{Config: &config.Config{
+			Network: config.NetworkConfig{Rules: []netrules.NetworkRuleEntry{{HostRules: netrules.HostRules{Host: "example.com"}}}},
+		}}, wantErr: "network.rules"},
+		{name: "host ports", opts: Options{Config: &config.Config{
+			Network: config.NetworkConfig{Host: []int{5432}},
+		}}, wantErr: "network.host"},
+		{name: "docker dependency", opts: Options{Config: &config.Config{
+			Dependencies: []string{"docker:dind"},
+		}}, wantErr: "dependencies: docker"},
+		{name: "service dependency", opts: Options{Config: &config.Config{
+			Dependencies: []string{"node@20", "postgres@17"},
+		}}, wantErr: "dependencies: postgres"},
+		{name: "writable mount", opts: Options{Config: &config.Config{
+			Mounts: []config.MountEntry{{Source: "./data", Target: "/data"}},
+		}}, wantErr: "mounts: /data"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateNoEgress(tt.opts)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("validateNoEgress() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("validateNoEgress() = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNoEgressConfig(t *testing.T) {
+	orig := &config.Config{
+		Network: config.NetworkConfig{Policy: "permissive"},
+		Caches:  config.CachesConfig{Enabled: true},
+	}
+	got := noEgressConfig(orig)
+	if got.Network.Policy != "strict" {
+		t.Errorf("Network.Policy = %q, want strict", got.Network.Policy)
+	}
+	if got.Caches.Enabled {
+		t.Error("Caches.Enabled = true, want false")
+	}
+	if orig.Network.Policy != "permissive" || !orig.Caches.Enabled {
+		t.Error("noEgressConfig modified its input")
+	}
+	if noEgressConfig(nil).Network.Policy != "strict" {
+		t.Error("noEgressConfig(nil) should return a strict config")
+	}
+}
+
+func TestIsolationRecord(t *testing.T) {
+	r := &Run{Image: "moat/run:abc", Runtime: "docker"}
+	mounts := []container.MountConfig{
+		{Source: "/home/u/proj", Target: "/workspace", ReadOnly: true},
+		{Source: "moat-vol", Target: "/data", Volume: true},
+	}
+	data := isolationRecord(r, mounts, false)
+	if data.Mode != isolationModeNoEgress || data.NetworkPolicy != "strict" {
+		t.Errorf("Mode/NetworkPolicy = %q/%q", data.Mode, data.NetworkPolicy)
+	}
+	if data.Grants == nil || len(data.Grants) != 0 || data.AllowedHosts == nil || len(data.AllowedHosts) != 0 {
+		t.Errorf("Grants/AllowedHosts = %v/%v, want empty non-nil", data.Grants, data.AllowedHosts)
+	}
+	if data.Services == nil || len(data.Services) != 0 {
+		t.Errorf("Services = %v, want empty non-nil", data.Services)
+	}
+	if !data.WorkspaceReadOnly {
+		t.Error("WorkspaceReadOnly = false, want true")
+	}
+	if len(data.Mounts) != 1 || data.Mounts[0].Target != "/workspace" {
+		t.Errorf("Mounts = %+v, want only the workspace bind", data.Mounts)
+	}
+	if data.Image != r.Image || data.Runtime != r.Runtime {
+		t.Errorf("Image/Runtime = %q/%q", data.Image, data.Runtime)
+	}
+}
@@ -0,0 +1,486 @@
+package run
+
+// This file posts a summary of a run to its pull request with the run's
+// github grant.
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/majorcontext/moat/internal/audit"
+	"github.com/majorcontext/moat/internal/credential"
+	"github.com/majorcontext/moat/internal/metering"
+	"github.com/majorcontext/moat/internal/storage"
+	"github.com/majorcontext/moat/internal/testresult"
+	"github.com/majorcontext/moat/internal/worktree"
+)
+
+// PRCommentMarker starts moat's summary comment on a pull request. A later
+// run finds the comment by it and updates it instead of posting another.
+const PRCommentMarker = "<!-- moat:run-summary -->"
+
+// maxPRCommentCommands caps the commands listed in the comment, keeping it
+// well under GitHub's comment size limit.
+const maxPRCommentCommands = 50
+
+// githubAPIBase is the GitHub REST API root (overridable for testing).
+var githubAPIBase = "https://api.github.com"
+
+// PRCommentOptions configures PostPRComment.
+type PRCommentOptions struct {
+	// PR is the pull request to comment on, as ParsePullRequest accepts.
+	// Empty uses the run's --pr, then the open pull request for the run's
+	// worktree branch.
+	PR string
+	// BundleURL is where the run's audit bundle is published, for the
+	// comment to link to. "{run}" expands to the run ID.
+	BundleURL string
+}
+
+var pullRequestURL = regexp.MustCompile(`^https://github\.com/([^/]+)/([^/]+)/pull/(\d+)/?$`)
+
+// ParsePullRequest parses a pull request reference: a number ("123" or
+// "#123") in the workspace's repository, or a pull request URL
+// (https://github.com/owner/repo/pull/123). repo is "owner/repo" for a URL
+// and empty for a number.
+func ParsePullRequest(s string) (repo string, number int, err error) {
+	if m := pullRequestURL.FindStringSubmatch(s); m != nil {
+		number, _ = strconv.Atoi(m[3])
+		return m[1] + "/" + m[2], number, nil
+	}
+	number, err = strconv.Atoi(strings.TrimPrefix(s, "#"))
+	if err != nil || number <= 0 {
+		return "", 0, fmt.Errorf("invalid pull request %q (use a number or https://github.com/owner/repo/pull/N)", s)
+	}
+	return "", number, nil
+}
+
+// PRSummary is what the summary comment reports about a run.
+type PRSummary struct {
+	RunID        string
+	Name         string
+	Agent        string
+	State        string
+	ExitCode     int64
+	FailureClass string
+	Duration     time.Duration
+
+	// Diff stats of the workspace since the run started.
+	Files      int
+	Insertions int
+	Deletions  int
+	Untracked  int
+
+	Tests    *testresult.Summary
+	Commands []Action // commands the run executed, in order
+	Usage    metering.Totals
+
+	// The exported audit bundle. BundleFile is empty when the run has no
+	// audit log.
+	BundleFile    string
+	BundleURL     string
+	BundleEntries int
+	BundleHash    string
+}
+
+// SummarizeForPR gathers a stopped run's PR summary. It exports the run's
+// audit bundle to <run-id>.proof.json in the run directory; bundleURL, if
+// set, is where that file is published, with "{run}" expanded.
+func SummarizeForPR(ctx context.Context, store *storage.RunStore, bundleURL string) (PRSummary, error) {
+	meta, err := store.LoadMetadata()
+	if err != nil {
+		return PRSummary{}, fmt.Errorf("loading run metadata: %w", err)
+	}
+	s := PRSummary{
+		RunID:        store.RunID(),
+		Name:         meta.Name,
+		Agent:        meta.Agent,
+		State:        meta.State,
+		ExitCode:     meta.ExitCode,
+		FailureClass: meta.FailureClass,
+		Tests:        meta.TestResults,
+	}
+	if !meta.StartedAt.IsZero() && meta.StoppedAt.After(meta.StartedAt) {
+		s.Duration = meta.StoppedAt.Sub(meta.StartedAt)
+	}
+
+	diff, untracked, err := workspaceDiff(ctx, meta.Workspace, meta.StartedAt)
+	if err != nil {
+		return PRSummary{}, err
+	}
+	s.Files, s.Insertions, s.Deletions = diffStat(diff)
+	s.Untracked = len(untracked)
+
+	actions, err := ActionLog(store)
+	if err != nil {
+		return PRSummary{}, err
+	}
+	for _, a := range actions {
+		if a.Kind == ActionCommand {
+			s.Commands = append(s.Commands, a)
+		}
+	}
+
+	usage, err := store.ReadUsage()
+	if err != nil {
+		return PRSummary{}, fmt.Errorf("reading usage: %w", err)
+	}
+	runUsage := []metering.RunUsage{{RunID: s.RunID, Usage: usage}}
+	s.Usage = metering.BuildReport(runUsage, nil, time.Time{}, time.Time{}, metering.DefaultPrices()).Total
+
+	dbPath := filepath.Join(store.Dir(), "audit.db")
+	if _, err := os.Stat(dbPath); err == nil {
+		bundle, err := exportProofBundle(dbPath)
+		if err != nil {
+			return PRSummary{}, fmt.Errorf("exporting audit bundle: %w", err)
+		}
+		data, err := json.MarshalIndent(bundle, "", "  ")
+		if err != nil {
+			return PRSummary{}, fmt.Errorf("marshaling audit bundle: %w", err)
+		}
+		s.BundleFile = s.RunID + ".proof.json"
+		if err := os.WriteFile(filepath.Join(store.Dir(), s.BundleFile), data, 0o644); err != nil {
+			return PRSummary{}, fmt.Errorf("writing audit bundle: %w", err)
+		}
+		s.BundleEntries = len(bundle.Entries)
+		s.BundleHash = bundle.LastHash
+		if bundleURL != "" {
+			s.BundleURL = strings.ReplaceAll(bundleURL, "{run}", s.RunID)
+		}
+	}
+	return s, nil
+}
+
+func exportProofBundle(dbPath string) (*audit.ProofBundle, error) {
+	store, err := audit.OpenStore(dbPath)
+	if err != nil {
+		return nil, err
+	}
+	defer store.Close()
+	return store.Export()
+}
+
+// diffStat counts the files, added lines, and removed lines in a unified
+// diff.
+func diffStat(diff string) (files, insertions, deletions int) {
+	for _, line := range strings.Split(diff, "\n") {
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			files++
+		case strings.HasPrefix(line, "+++ "), strings.HasPrefix(line, "--- "):
+		case strings.HasPrefix(line, "+"):
+			insertions++
+		case strings.HasPrefix(line, "-"):
+			deletions++
+		}
+	}
+	return files, insertions, deletions
+}
+
+// Markdown renders the summary as the body of the PR comment.
+func (s PRSummary) Markdown() string {
+	var sb strings.Builder
+	sb.WriteString(PRCommentMarker + "\n")
+	name := s.Name
+	if name == "" {
+		name = s.RunID
+	}
+	fmt.Fprintf(&sb, "### Moat run %s\n\n", mdCode(name))
+
+	runLine := mdCode(s.RunID)
+	if s.Agent != "" {
+		runLine += " (" + s.Agent + ")"
+	}
+	fmt.Fprintf(&sb, "- **Run:** %s\n", runLine)
+
+	result := "succeeded"
+	if s.State == string(StateFailed) || s.ExitCode != 0 {
+		result = fmt.Sprintf("failed (exit %d", s.ExitCode)
+		if s.FailureClass != "" {
+			result += ", " + s.FailureClass
+		}
+		result += ")"
+	}
+	if s.Duration > 0 {
+		result += " in " + s.Duration.Round(time.Second).String()
+	}
+	fmt.Fprintf(&sb, "- **Result:** %s\n", result)
+
+	changes := fmt.Sprintf("%d %s changed, +%d −%d", s.Files, pluralWord(s.Files, "file", "files"), s.Insertions, s.Deletions)
+	if s.Untracked > 0 {
+		changes += fmt.Sprintf(", %d new untracked %s", s.Untracked, pluralWord(s.Untracked, "file", "files"))
+	}
+	fmt.Fprintf(&sb, "- **Changes:** %s\n", changes)
+
+	testCommands := 0
+	for _, a := range s.Commands {
+		if isTestCommand(a.Command) {
+			testCommands++
+		}
+	}
+	switch {
+	case s.Tests != nil:
+		tests := s.Tests.String()
+		if len(s.Tests.Frameworks) > 0 {
+			tests += " (" + strings.Join(s.Tests.Frameworks, ", ") + ")"
+		}
+		fmt.Fprintf(&sb, "- **Tests:** %s\n", tests)
+	case testCommands > 0:
+		fmt.Fprintf(&sb, "- **Tests:** %d test %s run, results not parsed\n", testCommands, pluralWord(testCommands, "command", "commands"))
+	default:
+		sb.WriteString("- **Tests:** none run\n")
+	}
+
+	if s.Usage.Requests > 0 {
+		tokens := s.Usage.InputTokens + s.Usage.OutputTokens + s.Usage.CacheReadTokens + s.Usage.CacheWriteTokens
+		cost := fmt.Sprintf("$%.2f", s.Usage.CostUSD)
+		if s.Usage.UnpricedRequests > 0 {
+			cost += "+"
+		}
+		fmt.Fprintf(&sb, "- **LLM cost:** %s across %d %s (%d tokens)\n", cost, s.Usage.Requests, pluralWord(s.Usage.Requests, "request", "requests"), tokens)
+	}
+
+	if s.BundleFile != "" {
+		bundle := mdCode(s.BundleFile)
+		if s.BundleURL != "" {
+			bundle = "[" + s.BundleFile + "](" + s.BundleURL + ")"
+		}
+		hash := s.BundleHash
+		if len(hash) > 16 {
+			hash = hash[:16]
+		}
+		fmt.Fprintf(&sb, "- **Audit bundle:** %s, %d %s, last hash %s\n", bundle, s.BundleEntries, pluralWord(s.BundleEntries, "entry", "entries"), mdCode(hash))
+	}
+
+	if len(s.Commands) > 0 {
+		fmt.Fprintf(&sb, "\n<details>\n<summary>Commands run (%d)</summary>\n\n", len(s.Commands))
+		for i, a := range s.Commands {
+			if i == maxPRCommentCommands {
+				fmt.Fprintf(&sb, "- … %d more\n", len(s.Commands)-maxPRCommentCommands)
+				break
+			}
+			sb.WriteString("- " + mdCode(commandLine(a)))
+			if a.ExitCode != nil {
+				fmt.Fprintf(&sb, " (exit %d)", *a.ExitCode)
+			}
+			sb.WriteString("\n")
+		}
+		sb.WriteString("\n</details>\n")
+	}
+
+	if s.BundleFile != "" {
+		fmt.Fprintf(&sb, "\n<sub>Verify the audit bundle with %s.</sub>\n", mdCode("moat audit verify "+s.BundleFile))
+	}
+	return sb.String()
+}
+
+// commandLine renders a command's argv on one line, cut to a readable
+// length.
+func commandLine(a Action) string {
+	line := strings.Join(a.Command, " ")
+	if len(line) > 200 {
+		line = line[:200] + "…"
+	}
+	return line
+}
+
+// mdCode renders s as inline Markdown code, widening the fence when s
+// holds a backtick.
+func mdCode(s string) string {
+	s = strings.ReplaceAll(s, "\n", " ")
+	if strings.Contains(s, "`") {
+		return "`` " + s + " ``"
+	}
+	return "`" + s + "`"
+}
+
+func pluralWord(n int, singular, plural string) string {
+	if n == 1 {
+		return singular
+	}
+	return plural
+}
+
+// PostPRComment posts a stopped run's summary to its pull request, or
+// updates the summary comment a previous run posted there, and returns the
+// comment's URL. The GitHub API is called with the run's github grant
+// through the proxy daemon, as the run's container would.
+func PostPRComment(ctx context.Context, store *storage.RunStore, opts PRCommentOptions) (string, error) {
+	meta, err := store.LoadMetadata()
+	if err != nil {
+		return "", fmt.Errorf("loading run metadata: %w", err)
+	}
+	if !slices.ContainsFunc(meta.Grants, func(g string) bool { return strings.Split(g, ":")[0] == "github" }) {
+		return "", fmt.Errorf("the run was not granted github; PR comments are posted with the run's own github grant")
+	}
+
+	ref := opts.PR
+	if ref == "" {
+		ref = meta.PullRequest
+	}
+	var repo string
+	var number int
+	if ref != "" {
+		if repo, number, err = ParsePullRequest(ref); err != nil {
+			return "", err
+		}
+	} else if meta.WorktreeBranch == "" {
+		return "", fmt.Errorf("the run has no pull request; pass --pr or run on a worktree branch with an open pull request")
+	}
+	if repo == "" {
+		if repo, err = githubRepo(meta.Workspace); err != nil {
+			return "", err
+		}
+	}
+
+	summary, err := SummarizeForPR(ctx, store, opts.BundleURL)
+	if err != nil {
+		return "", err
+	}
+
+	client, done, err := grantProxyClient(ctx, store.RunID(), meta.Workspace, "github", "api.github.com")
+	if err != nil {
+		return "", err
+	}
+	defer done()
+	gh := &githubClient{client: client, base: githubAPIBase}
+
+	if number == 0 {
+		if number, err = gh.openPullRequest(ctx, repo, meta.WorktreeBranch); err != nil {
+			return "", err
+		}
+	}
+	return gh.upsertComment(ctx, repo, number, summary.Markdown())
+}
+
+// githubRepo returns the "owner/repo" of the GitHub repository dir's origin
+// remote points to.
+func githubRepo(dir string) (string, error) {
+	id, err := worktree.ResolveRepoID(dir)
+	if err != nil {
+		return "", err
+	}
+	repo, ok := strings.CutPrefix(id, "github.com/")
+	if !ok || strings.Count(repo, "/") != 1 {
+		return "", fmt.Errorf("workspace remote %s is not a GitHub repository; pass the pull request URL", id)
+	}
+	return repo, nil
+}
+
+// githubClient calls the GitHub REST API. The proxy injects the
+// credential.
+type githubClient struct {
+	client *http.Client
+	base   string
+}
+
+func (c *githubClient) do(ctx context.Context, method, path string, body, out any) error {
+	var r io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		r = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.base+path, r)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Authorization", "Bearer "+credential.ProxyInjectedPlaceholder)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("calling GitHub: %w", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 4<<20))
+	if err != nil {
+		return fmt.Errorf("reading GitHub response: %w", err)
+	}
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("GitHub %s %s returned %s: %s", method, path, resp.Status, strings.TrimSpace(string(data)))
+	}
+	if out != nil {
+		if err := json.Unmarshal(data, out); err != nil {
+			return fmt.Errorf("parsing GitHub response: %w", err)
+		}
+	}
+	return nil
+}
+
+// openPullRequest returns the number of the open pull request from branch
+// in repo.
+func (c *githubClient) openPullRequest(ctx context.Context, repo, branch string) (int, error) {
+	owner, _, _ := strings.Cut(repo, "/")
+	q := url.Values{"head": {owner + ":" + branch}, "state": {"open"}}
+	var pulls []struct {
+		Number int `json:"number"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/repos/"+repo+"/pulls?"+q.Encode(), nil, &pulls); err != nil {
+		return 0, err
+	}
+	if len(pulls) == 0 {
+		return 0, fmt.Errorf("no open pull request in %s for branch %s; pass --pr", repo, branch)
+	}
+	return pulls[0].Number, nil
+}
+
+type githubComment struct {
+	ID      int64  `json:"id"`
+	Body    string `json:"body"`
+	HTMLURL string `json:"html_url"`
+}
+
+// upsertComment updates the summary comment on the pull request, or posts
+// one if there is none, and returns its URL.
+func (c *githubClient) upsertComment(ctx context.Context, repo string, number int, body string) (string, error) {
+	const perPage = 100
+	var existing *githubComment
+	for page := 1; existing == nil && page <= 10; page++ {
+		var comments []githubComment
+		path := fmt.Sprintf("/repos/%s/issues/%d/comments?per_page=%d&page=%d", repo, number, perPage, page)
+		if err := c.do(ctx, http.MethodGet, path, nil, &comments); err != nil {
+			return "", err
+		}
+		for i := range comments {
+			if strings.HasPrefix(comments[i].Body, PRCommentMarker) {
+				existing = &comments[i]
+				break
+			}
+		}
+		if len(comments) < perPage {
+			break
+		}
+	}
+
+	var out githubComment
+	payload := map[string]string{"body": body}
+	if existing != nil {
+		path := fmt.Sprintf("/repos/%s/issues/comments/%d", repo, existing.ID)
+		if err := c.do(ctx, http.MethodPatch, path, payload, &out); err != nil {
+			return "", err
+		}
+	} else {
+		path := fmt.Sprintf("/repos/%s/issues/%d/comments", repo, number)
+		if err := c.do(ctx, http.MethodPost, path, payload, &out); err != nil {
+			return "", err
+		}
+	}
+	return out.HTMLURL, nil
+}
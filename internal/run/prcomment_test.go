@@ -0,0 +1,195 @@
+package run
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/majorcontext/moat/internal/metering"
+	"github.com/majorcontext/moat/internal/testresult"
+)
+
+func TestParsePullRequest(t *testing.T) {
+	tests := []struct {
+		in       string
+		repo     string
+		number   int
+		hasError bool
+	}{
+		{in: "42", number: 42},
+		{in: "#42", number: 42},
+		{in: "https://github.com/acme/api/pull/42", repo: "acme/api", number: 42},
+		{in: "https://github.com/acme/api/pull/42/", repo: "acme/api", number: 42},
+		{in: "https://github.com/acme/api/issues/42", hasError: true},
+		{in: "0", hasError: true},
+		{in: "main", hasError: true},
+	}
+	for _, tt := range tests {
+		repo, number, err := ParsePullRequest(tt.in)
+		if tt.hasError {
+			if err == nil {
+				t.Errorf("ParsePullRequest(%q) succeeded, want error", tt.in)
+			}
+			continue
+		}
+		if err != nil || repo != tt.repo || number != tt.number {
+			t.Errorf("ParsePullRequest(%q) = (%q, %d, %v), want (%q, %d)", tt.in, repo, number, err, tt.repo, tt.number)
+		}
+	}
+}
+
+func TestDiffStat(t *testing.T) {
+	diff := `diff --git a/main.go b/main.go
+--- a/main.go
++++ b/main.go
+@@ -1,3 +1,4 @@
+ package main
+-var x = 1
++var x = 2
++var y = 3
+diff --git a/new.go b/new.go
+--- /dev/null
++++ b/new.go
+@@ -0,0 +1 @@
++package main
+`
+	files, ins, del := diffStat(diff)
+	if files != 2 || ins != 3 || del != 1 {
+		t.Errorf("diffStat = (%d, %d, %d), want (2, 3, 1)", files, ins, del)
+	}
+}
+
+func TestPRSummaryMarkdown(t *testing.T) {
+	zero, one := 0, 1
+	s := PRSummary{
+		RunID:      "run_abc",
+		Name:       "fix-auth",
+		Agent:      "claude-code",
+		State:      string(StateStopped),
+		Duration:   12*time.Minute + 3*time.Second,
+		Files:      3,
+		Insertions: 40,
+		Deletions:  2,
+		Untracked:  1,
+		Tests:      &testresult.Summary{Frameworks: []string{"go"}, Passed: 12, Failed: 1},
+		Commands: []Action{
+			{Kind: ActionCommand, Command: []string{"go", "build", "./..."}, ExitCode: &zero},
+			{Kind: ActionCommand, Command: []string{"go", "test", "./..."}, ExitCode: &one},
+			{Kind: ActionCommand, Command: []string{"echo", "`date`"}},
+		},
+		Usage:         metering.Totals{Requests: 4, InputTokens: 1000, OutputTokens: 200, CostUSD: 0.5},
+		BundleFile:    "run_abc.proof.json",
+		BundleURL:     "https://ci.example.com/run_abc.proof.json",
+		BundleEntries: 31,
+		BundleHash:    "0123456789abcdef0123",
+	}
+	md := s.Markdown()
+	for _, want := range []string{
+		PRCommentMarker + "\n",
+		"### Moat run `fix-auth`",
+		"- **Run:** `run_abc` (claude-code)",
+		"- **Result:** succeeded in 12m3s",
+		"- **Changes:** 3 files changed, +40 −2, 1 new untracked file",
+		"- **Tests:** 12 passed, 1 failed (go)",
+		"- **LLM cost:** $0.50 across 4 requests (1200 tokens)",
+		"- **Audit bundle:** [run_abc.proof.json](https://ci.example.com/run_abc.proof.json), 31 entries, last hash `0123456789abcdef`",
+		"<summary>Commands run (3)</summary>",
+		"- `go test ./...` (exit 1)",
+		"- `` echo `date` ``",
+	} {
+		if !strings.Contains(md, want) {
+			t.Errorf("Markdown() missing %q:\n%s", want, md)
+		}
+	}
+	if !strings.HasPrefix(md, PRCommentMarker) {
+		t.Error("Markdown() does not start with the marker")
+	}
+
+	s.State, s.ExitCode, s.FailureClass = string(StateFailed), 137, "oom"
+	s.Tests, s.Usage, s.BundleFile = nil, metering.Totals{}, ""
+	md = s.Markdown()
+	for _, want := range []string{"- **Result:** failed (exit 137, oom) in 12m3s", "- **Tests:** 1 test command run, results not parsed"} {
+		if !strings.Contains(md, want) {
+			t.Errorf("Markdown() missing %q:\n%s", want, md)
+		}
+	}
+	if strings.Contains(md, "LLM cost") || strings.Contains(md, "Audit bundle") {
+		t.Errorf("Markdown() reports cost or bundle without them:\n%s", md)
+	}
+}
+
+// fakeGitHub serves the pull request and issue comment endpoints
+// upsertComment and openPullRequest use.
+type fakeGitHub struct {
+	comments []githubComment
+	posted   []string
+	patched  map[int64]string
+}
+
+func (f *fakeGitHub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	var payload struct {
+		Body string `json:"body"`
+	}
+	_ = json.Unmarshal(body, &payload)
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/repos/acme/api/pulls":
+		if r.URL.Query().Get("head") != "acme:moat/fix-auth" {
+			_, _ = w.Write([]byte(`[]`))
+			return
+		}
+		_, _ = w.Write([]byte(`[{"number": 42}]`))
+	case r.Method == http.MethodGet && r.URL.Path == "/repos/acme/api/issues/42/comments":
+		_ = json.NewEncoder(w).Encode(f.comments)
+	case r.Method == http.MethodPost && r.URL.Path == "/repos/acme/api/issues/42/comments":
+		f.posted = append(f.posted, payload.Body)
+		_, _ = w.Write([]byte(`{"id": 9, "html_url": "https://github.com/acme/api/pull/42#issuecomment-9"}`))
+	case r.Method == http.MethodPatch && strings.HasPrefix(r.URL.Path, "/repos/acme/api/issues/comments/"):
+		var id int64
+		_ = json.Unmarshal([]byte(strings.TrimPrefix(r.URL.Path, "/repos/acme/api/issues/comments/")), &id)
+		f.patched[id] = payload.Body
+		_, _ = w.Write([]byte(`{"id": 7, "html_url": "https://github.com/acme/api/pull/42#issuecomment-7"}`))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestGitHubClientUpsertComment(t *testing.T) {
+	fake := &fakeGitHub{patched: map[int64]string{}}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+	gh := &githubClient{client: srv.Client(), base: srv.URL}
+	ctx := context.Background()
+
+	number, err := gh.openPullRequest(ctx, "acme/api", "moat/fix-auth")
+	if err != nil || number != 42 {
+		t.Fatalf("openPullRequest = (%d, %v), want 42", number, err)
+	}
+	if _, err := gh.openPullRequest(ctx, "acme/api", "other"); err == nil {
+		t.Error("openPullRequest for a branch without a pull request succeeded")
+	}
+
+	// No summary comment yet: one is posted.
+	fake.comments = []githubComment{{ID: 5, Body: "LGTM"}}
+	url, err := gh.upsertComment(ctx, "acme/api", 42, PRCommentMarker+"\nfirst")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fake.posted) != 1 || !strings.HasSuffix(url, "issuecomment-9") {
+		t.Errorf("posted = %v, url = %q", fake.posted, url)
+	}
+
+	// A previous summary comment is updated in place.
+	fake.comments = append(fake.comments, githubComment{ID: 7, Body: PRCommentMarker + "\nfirst"})
+	if _, err := gh.upsertComment(ctx, "acme/api", 42, PRCommentMarker+"\nsecond"); err != nil {
+		t.Fatal(err)
+	}
+	if len(fake.posted) != 1 || fake.patched[7] != PRCommentMarker+"\nsecond" {
+		t.Errorf("posted = %v, patched = %v", fake.posted, fake.patched)
+	}
+}
@@ -0,0 +1,442 @@
+package run
+
+// This file drafts pull request descriptions from run data with the run's
+// LLM grant.
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/majorcontext/moat/internal/config"
+	"github.com/majorcontext/moat/internal/credential"
+	"github.com/majorcontext/moat/internal/daemon"
+	"github.com/majorcontext/moat/internal/log"
+	"github.com/majorcontext/moat/internal/provider"
+	"github.com/majorcontext/moat/internal/storage"
+)
+
+// PRDescriptionFile is the file in the run directory that holds the drafted
+// pull request description.
+const PRDescriptionFile = "pr-description.md"
+
+// maxPRDiffBytes caps the diff sent to the model. Larger diffs are cut and
+// the model is told so.
+const maxPRDiffBytes = 100_000
+
+// PRDescriptionOptions configures DraftPRDescription.
+type PRDescriptionOptions struct {
+	// Grant is the LLM grant to draft with. Empty picks the run's first
+	// grant that has a supported API.
+	Grant string
+	// Model overrides the API's default model.
+	Model string
+}
+
+// llmAPI is a chat completion API that a grant's credential unlocks.
+type llmAPI struct {
+	host         string
+	defaultModel string
+	// newRequest builds the completion request. The credential header holds
+	// the proxy placeholder; the proxy replaces it with the real credential.
+	newRequest func(ctx context.Context, model, prompt string) (*http.Request, error)
+	// text extracts the completion from a successful response body.
+	text func(body []byte) (string, error)
+}
+
+var anthropicMessagesAPI = &llmAPI{
+	host:         "api.anthropic.com",
+	defaultModel: "claude-sonnet-4-5",
+	newRequest: func(ctx context.Context, model, prompt string) (*http.Request, error) {
+		body, _ := json.Marshal(map[string]any{
+			"model":      model,
+			"max_tokens": 2048,
+			"messages":   []map[string]string{{"role": "user", "content": prompt}},
+		})
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, "https://api.anthropic.com/v1/messages", bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("anthropic-version", "2023-06-01")
+		req.Header.Set("x-api-key", credential.ProxyInjectedPlaceholder)
+		return req, nil
+	},
+	text: func(body []byte) (string, error) {
+		var resp struct {
+			Content []struct {
+				Type string `json:"type"`
+				Text string `json:"text"`
+			} `json:"content"`
+		}
+		if err := json.Unmarshal(body, &resp); err != nil {
+			return "", err
+		}
+		var sb strings.Builder
+		for _, c := range resp.Content {
+			if c.Type == "text" {
+				sb.WriteString(c.Text)
+			}
+		}
+		return sb.String(), nil
+	},
+}
+
+var openAIChatAPI = &llmAPI{
+	host:         "api.openai.com",
+	defaultModel: "gpt-4.1",
+	newRequest: func(ctx context.Context, model, prompt string) (*http.Request, error) {
+		body, _ := json.Marshal(map[string]any{
+			"model":    model,
+			"messages": []map[string]string{{"role": "user", "content": prompt}},
+		})
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, "https://api.openai.com/v1/chat/completions", bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+credential.ProxyInjectedPlaceholder)
+		return req, nil
+	},
+	text: func(body []byte) (string, error) {
+		var resp struct {
+			Choices []struct {
+				Message struct {
+					Content string `json:"content"`
+				} `json:"message"`
+			} `json:"choices"`
+		}
+		if err := json.Unmarshal(body, &resp); err != nil {
+			return "", err
+		}
+		if len(resp.Choices) == 0 {
+			return "", nil
+		}
+		return resp.Choices[0].Message.Content, nil
+	},
+}
+
+// prDescriptionAPIs maps LLM grant names to their API.
+var prDescriptionAPIs = map[string]*llmAPI{
+	"claude":    anthropicMessagesAPI,
+	"anthropic": anthropicMessagesAPI,
+	"openai":    openAIChatAPI,
+	"codex":     openAIChatAPI,
+}
+
+// PRDescriptionGrants returns the grant names DraftPRDescription can use.
+func PRDescriptionGrants() []string {
+	return []string{"claude", "anthropic", "openai", "codex"}
+}
+
+// DraftPRDescription drafts a pull request description for a stopped run
+// from its workspace diff, the commands it ran, and their test results, and
+// writes it to PRDescriptionFile in the run directory. It returns the file's
+// path.
+//
+// The model is called through the proxy daemon with a short-lived
+// registration that carries only the LLM grant, so the credential is
+// injected by the proxy exactly as it is for the run's container.
+func DraftPRDescription(ctx context.Context, store *storage.RunStore, opts PRDescriptionOptions) (string, error) {
+	meta, err := store.LoadMetadata()
+	if err != nil {
+		return "", fmt.Errorf("loading run metadata: %w", err)
+	}
+	grant, api, err := prDescriptionGrant(meta.Grants, opts.Grant)
+	if err != nil {
+		return "", err
+	}
+	actions, err := ActionLog(store)
+	if err != nil {
+		return "", err
+	}
+	diff, untracked, err := workspaceDiff(ctx, meta.Workspace, meta.StartedAt)
+	if err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(diff) == "" && len(untracked) == 0 {
+		return "", fmt.Errorf("no changes in %s since the run started; nothing to describe", meta.Workspace)
+	}
+
+	client, done, err := grantProxyClient(ctx, store.RunID(), meta.Workspace, grant, api.host)
+	if err != nil {
+		return "", err
+	}
+	defer done()
+
+	model := opts.Model
+	if model == "" {
+		model = api.defaultModel
+	}
+	text, err := completeLLM(ctx, client, api, model, prDescriptionPrompt(meta, diff, untracked, actions))
+	if err != nil {
+		return "", err
+	}
+
+	out := filepath.Join(store.Dir(), PRDescriptionFile)
+	if err := os.WriteFile(out, []byte(strings.TrimSpace(text)+"\n"), 0o600); err != nil {
+		return "", fmt.Errorf("writing %s: %w", PRDescriptionFile, err)
+	}
+	return out, nil
+}
+
+// prDescriptionGrant picks the grant to draft with: want if set (it must be
+// one of the run's grants), otherwise the first grant with a supported API.
+func prDescriptionGrant(grants []string, want string) (string, *llmAPI, error) {
+	for _, g := range grants {
+		name := strings.Split(g, ":")[0]
+		if want != "" && name != want {
+			continue
+		}
+		if api, ok := prDescriptionAPIs[name]; ok {
+			return name, api, nil
+		}
+	}
+	if want != "" {
+		if _, ok := prDescriptionAPIs[want]; !ok {
+			return "", nil, fmt.Errorf("grant %q cannot draft PR descriptions (use one of: %s)", want, strings.Join(PRDescriptionGrants(), ", "))
+		}
+		return "", nil, fmt.Errorf("the run was not granted %q; PR descriptions use the run's own LLM grant", want)
+	}
+	return "", nil, fmt.Errorf("the run has no LLM grant to draft a PR description with; grant one of: %s", strings.Join(PRDescriptionGrants(), ", "))
+}
+
+// workspaceDiff returns the changes in the git workspace dir since the run
+// started: commits made during the run plus uncommitted changes, and the
+// untracked files.
+func workspaceDiff(ctx context.Context, dir string, since time.Time) (string, []string, error) {
+	git := func(args ...string) (string, error) {
+		cmd := exec.CommandContext(ctx, "git", append([]string{"-C", dir}, args...)...)
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		if err != nil {
+			return "", fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+		}
+		return string(out), nil
+	}
+	if _, err := git("rev-parse", "--verify", "HEAD"); err != nil {
+		return "", nil, fmt.Errorf("workspace %s is not a git repository with commits: %w", dir, err)
+	}
+
+	// Diff against the parent of the oldest commit made during the run, or
+	// HEAD when the agent committed nothing.
+	base := "HEAD"
+	if !since.IsZero() {
+		out, err := git("rev-list", "--reverse", "--since="+strconv.FormatInt(since.Unix(), 10), "HEAD")
+		if err != nil {
+			return "", nil, err
+		}
+		if first, _, _ := strings.Cut(out, "\n"); first != "" {
+			if _, err := git("rev-parse", "--verify", "--quiet", first+"^"); err == nil {
+				base = first + "^"
+			} else {
+				// The run created the root commit; diff against the empty tree.
+				base = "4b825dc642cb6eb9a060e54bf8d69288fbee4904"
+			}
+		}
+	}
+	diff, err := git("diff", base)
+	if err != nil {
+		return "", nil, err
+	}
+	others, err := git("ls-files", "--others", "--exclude-standard")
+	if err != nil {
+		return "", nil, err
+	}
+	return diff, strings.Fields(others), nil
+}
+
+// prDescriptionPrompt builds the drafting prompt.
+func prDescriptionPrompt(meta storage.Metadata, diff string, untracked []string, actions []Action) string {
+	var sb strings.Builder
+	sb.WriteString(`Write a pull request description for the changes below, made by a coding agent.
+
+Format it as Markdown: a one-line title as a level-1 heading, then "## Summary" (what changed and why, in a few sentences), "## Changes" (a short bulleted list), and "## Testing" (the test commands that ran and whether they passed; say so plainly if none ran or some failed). Describe only what the diff and commands show. Do not invent motivation, issue numbers, or test results. Output only the description.
+`)
+	if meta.WorktreeBranch != "" {
+		fmt.Fprintf(&sb, "\nBranch: %s\n", meta.WorktreeBranch)
+	}
+
+	var commands, tests []string
+	for _, a := range actions {
+		if a.Kind != ActionCommand {
+			continue
+		}
+		line := strings.Join(a.Command, " ")
+		if a.ExitCode != nil {
+			line += "  (exit " + strconv.Itoa(*a.ExitCode) + ")"
+		}
+		commands = append(commands, line)
+		if isTestCommand(a.Command) {
+			tests = append(tests, line)
+		}
+	}
+	if len(tests) > 0 {
+		sb.WriteString("\nTest commands:\n")
+		for _, t := range tests {
+			sb.WriteString("- " + t + "\n")
+		}
+	} else {
+		sb.WriteString("\nNo test commands were recorded.\n")
+	}
+	if len(commands) > 0 {
+		const maxCommands = 200
+		sb.WriteString("\nCommands run:\n")
+		for i, c := range commands {
+			if i == maxCommands {
+				fmt.Fprintf(&sb, "- ... %d more\n", len(commands)-maxCommands)
+				break
+			}
+			sb.WriteString("- " + c + "\n")
+		}
+	}
+	if len(untracked) > 0 {
+		sb.WriteString("\nNew untracked files:\n")
+		for _, f := range untracked {
+			sb.WriteString("- " + f + "\n")
+		}
+	}
+	if len(diff) > maxPRDiffBytes {
+		diff = diff[:maxPRDiffBytes] + "\n[diff truncated]\n"
+	}
+	sb.WriteString("\nDiff:\n```diff\n" + diff + "```\n")
+	return sb.String()
+}
+
+// isTestCommand reports whether argv runs a project's tests.
+func isTestCommand(argv []string) bool {
+	if len(argv) == 0 {
+		return false
+	}
+	name := path.Base(argv[0])
+	sub := ""
+	if len(argv) > 1 {
+		sub = argv[1]
+	}
+	switch name {
+	case "pytest", "jest", "vitest", "mocha", "rspec", "phpunit", "tox":
+		return true
+	case "go", "cargo", "mvn", "gradle", "./gradlew", "dotnet", "deno", "bun", "mix":
+		return sub == "test"
+	case "npm", "pnpm", "yarn":
+		return sub == "test" || sub == "t" || (sub == "run" && len(argv) > 2 && strings.HasPrefix(argv[2], "test"))
+	case "make":
+		return sub == "test" || sub == "check"
+	case "python", "python3":
+		return len(argv) > 2 && sub == "-m" && (argv[2] == "pytest" || argv[2] == "unittest")
+	}
+	return false
+}
+
+// grantProxyClient registers a short-lived run context with the proxy daemon
+// that carries only grant's credential and allows only host, and returns a
+// client that sends requests through it. done unregisters the context.
+func grantProxyClient(ctx context.Context, runID, workspace, grant, host string) (*http.Client, func(), error) {
+	prov := provider.Get(grant)
+	if prov == nil {
+		return nil, nil, fmt.Errorf("unknown grant %q", grant)
+	}
+	key, err := credential.DefaultEncryptionKey()
+	if err != nil {
+		return nil, nil, fmt.Errorf("getting encryption key: %w", err)
+	}
+	store, err := credential.NewFileStore(credential.DefaultStoreDir(), key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("opening credential store: %w", err)
+	}
+	cred, err := store.Get(credentialStoreKey(grant, grant))
+	if err != nil {
+		return nil, nil, fmt.Errorf("grant %q: credential not found: %w", grant, err)
+	}
+	if err := cred.Restrict.Check(workspace); err != nil {
+		return nil, nil, fmt.Errorf("grant %q: %w", grant, err)
+	}
+
+	rc := daemon.NewRunContext(runID)
+	rc.Workspace = workspace
+	rc.NetworkPolicy = "strict"
+	rc.NetworkAllow = []string{host}
+	prov.ConfigureProxy(rc, provider.FromLegacy(cred))
+
+	daemonDir := filepath.Join(config.GlobalConfigDir(), "proxy")
+	dc, err := daemon.EnsureRunning(daemonDir, 0)
+	if err != nil {
+		return nil, nil, fmt.Errorf("starting proxy daemon: %w", err)
+	}
+	resp, err := dc.RegisterRun(ctx, buildRegisterRequest(rc, []string{grant}))
+	if err != nil {
+		return nil, nil, fmt.Errorf("registering with proxy daemon: %w", err)
+	}
+	done := func() {
+		if err := dc.UnregisterRun(context.Background(), resp.AuthToken); err != nil {
+			log.Debug("unregistering grant context from proxy daemon", "error", err)
+		}
+	}
+	if resp.Error != "" {
+		done()
+		return nil, nil, fmt.Errorf("registering with proxy daemon: %s", resp.Error)
+	}
+
+	caPEM, err := os.ReadFile(filepath.Join(daemonDir, "ca", "ca.crt"))
+	if err != nil {
+		done()
+		return nil, nil, fmt.Errorf("reading proxy CA certificate: %w", err)
+	}
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM(caPEM)
+	proxyURL := &url.URL{
+		Scheme: "http",
+		User:   url.UserPassword("moat", resp.AuthToken),
+		Host:   "127.0.0.1:" + strconv.Itoa(resp.ProxyPort),
+	}
+	client := &http.Client{
+		Timeout: 3 * time.Minute,
+		Transport: &http.Transport{
+			Proxy:           http.ProxyURL(proxyURL),
+			TLSClientConfig: &tls.Config{RootCAs: roots, MinVersion: tls.VersionTLS12},
+		},
+	}
+	return client, done, nil
+}
+
+// completeLLM sends prompt to api and returns the completion text.
+func completeLLM(ctx context.Context, client *http.Client, api *llmAPI, model, prompt string) (string, error) {
+	req, err := api.newRequest(ctx, model, prompt)
+	if err != nil {
+		return "", err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("calling %s: %w", api.host, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 4<<20))
+	if err != nil {
+		return "", fmt.Errorf("reading %s response: %w", api.host, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s returned %s: %s", api.host, resp.Status, strings.TrimSpace(string(body)))
+	}
+	text, err := api.text(body)
+	if err != nil {
+		return "", fmt.Errorf("parsing %s response: %w", api.host, err)
+	}
+	if strings.TrimSpace(text) == "" {
+		return "", fmt.Errorf("%s returned an empty description", api.host)
+	}
+	return text, nil
+}
@@ -0,0 +1,209 @@
+package run
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/majorcontext/moat/internal/daemon"
+	"github.com/majorcontext/moat/internal/provider"
+	"github.com/majorcontext/moat/internal/providers/claude"
+	"github.com/majorcontext/moat/internal/providers/codex"
+	providertest "github.com/majorcontext/moat/internal/providers/testing"
+	"github.com/majorcontext/moat/internal/storage"
+)
+
+func TestPRDescriptionGrant(t *testing.T) {
+	tests := []struct {
+		grants    []string
+		want      string
+		wantGrant string
+		wantErr   string
+	}{
+		{[]string{"github", "anthropic"}, "", "anthropic", ""},
+		{[]string{"claude", "openai"}, "openai", "openai", ""},
+		{[]string{"codex:org"}, "", "codex", ""},
+		{[]string{"github"}, "", "", "no LLM grant"},
+		{[]string{"anthropic"}, "openai", "", "not granted"},
+		{[]string{"github"}, "github", "", "cannot draft"},
+	}
+	for _, tt := range tests {
+		grant, api, err := prDescriptionGrant(tt.grants, tt.want)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("prDescriptionGrant(%v, %q) error = %v, want %q", tt.grants, tt.want, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || grant != tt.wantGrant || api != prDescriptionAPIs[tt.wantGrant] {
+			t.Errorf("prDescriptionGrant(%v, %q) = %q, %v, want %q", tt.grants, tt.want, grant, err, tt.wantGrant)
+		}
+	}
+}
+
+func TestIsTestCommand(t *testing.T) {
+	yes := [][]string{
+		{"go", "test", "./..."},
+		{"/usr/local/bin/npm", "test"},
+		{"npm", "run", "test:unit"},
+		{"pytest", "-q"},
+		{"python3", "-m", "pytest"},
+		{"cargo", "test"},
+		{"make", "test"},
+	}
+	no := [][]string{
+		{"go", "build", "./..."},
+		{"npm", "install"},
+		{"python", "main.py"},
+		{"make"},
+		nil,
+	}
+	for _, argv := range yes {
+		if !isTestCommand(argv) {
+			t.Errorf("isTestCommand(%q) = false, want true", argv)
+		}
+	}
+	for _, argv := range no {
+		if isTestCommand(argv) {
+			t.Errorf("isTestCommand(%q) = true, want false", argv)
+		}
+	}
+}
+
+func gitCmd(t *testing.T, dir string, env []string, args ...string) {
+	t.Helper()
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.com",
+		"GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.com",
+	)
+	cmd.Env = append(cmd.Env, env...)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git %v: %v\n%s", args, err, out)
+	}
+}
+
+func TestWorkspaceDiff(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	gitCmd(t, dir, nil, "init", "-q")
+	os.WriteFile(filepath.Join(dir, "old.txt"), []byte("before\n"), 0o644)
+	gitCmd(t, dir, nil, "add", ".")
+	past := "GIT_COMMITTER_DATE=" + time.Now().Add(-time.Hour).Format(time.RFC3339)
+	gitCmd(t, dir, []string{past}, "commit", "-q", "-m", "before the run")
+
+	started := time.Now().Add(-time.Minute)
+	os.WriteFile(filepath.Join(dir, "committed.txt"), []byte("agent commit\n"), 0o644)
+	gitCmd(t, dir, nil, "add", ".")
+	gitCmd(t, dir, nil, "commit", "-q", "-m", "agent commit")
+	os.WriteFile(filepath.Join(dir, "old.txt"), []byte("after\n"), 0o644)
+	os.WriteFile(filepath.Join(dir, "new.txt"), []byte("untracked\n"), 0o644)
+
+	diff, untracked, err := workspaceDiff(context.Background(), dir, started)
+	if err != nil {
+		t.Fatalf("workspaceDiff: %v", err)
+	}
+	for _, want := range []string{"+agent commit", "-before", "+after"} {
+		if !strings.Contains(diff, want) {
+			t.Errorf("diff missing %q:\n%s", want, diff)
+		}
+	}
+	if len(untracked) != 1 || untracked[0] != "new.txt" {
+		t.Errorf("untracked = %v, want [new.txt]", untracked)
+	}
+
+	if _, _, err := workspaceDiff(context.Background(), t.TempDir(), started); err == nil {
+		t.Error("workspaceDiff on a non-git directory should fail")
+	}
+}
+
+func TestPRDescriptionPrompt(t *testing.T) {
+	zero, one := 0, 1
+	actions := []Action{
+		{Kind: ActionCommand, Command: []string{"go", "test", "./..."}, ExitCode: &one},
+		{Kind: ActionAPI, Host: "api.anthropic.com"},
+		{Kind: ActionCommand, Command: []string{"gofmt", "-w", "main.go"}, ExitCode: &zero},
+	}
+	meta := storage.Metadata{WorktreeBranch: "fix-login"}
+	prompt := prDescriptionPrompt(meta, strings.Repeat("x", maxPRDiffBytes+10), []string{"new.go"}, actions)
+
+	for _, want := range []string{
+		"Branch: fix-login",
+		"Test commands:\n- go test ./...  (exit 1)\n",
+		"- gofmt -w main.go  (exit 0)",
+		"New untracked files:\n- new.go",
+		"[diff truncated]",
+	} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("prompt missing %q", want)
+		}
+	}
+	if strings.Contains(prompt, "api.anthropic.com") {
+		t.Error("prompt should not list API requests")
+	}
+}
+
+func TestCompleteLLMThroughProxy(t *testing.T) {
+	t.Run("anthropic", func(t *testing.T) {
+		backend := providertest.Anthropic(t)
+		rc := daemon.NewRunContext("run_prdesc")
+		(&claude.AnthropicProvider{}).ConfigureProxy(rc, &provider.Credential{Token: backend.Token})
+		p := providertest.NewProxy(t, rc, backend)
+
+		text, err := completeLLM(context.Background(), p.Client, anthropicMessagesAPI, "test-model", "describe")
+		if err != nil {
+			t.Fatalf("completeLLM: %v", err)
+		}
+		if text != "ok" {
+			t.Errorf("text = %q, want ok", text)
+		}
+		req := backend.LastRequest(t)
+		if got := req.Header.Get("x-api-key"); got != backend.Token {
+			t.Errorf("x-api-key = %q, want the injected token", got)
+		}
+		var body struct {
+			Model    string `json:"model"`
+			Messages []struct {
+				Content string `json:"content"`
+			} `json:"messages"`
+		}
+		if err := json.Unmarshal(req.Body, &body); err != nil {
+			t.Fatal(err)
+		}
+		if body.Model != "test-model" || len(body.Messages) != 1 || body.Messages[0].Content != "describe" {
+			t.Errorf("request body = %s", req.Body)
+		}
+	})
+
+	t.Run("openai", func(t *testing.T) {
+		backend := providertest.OpenAI(t)
+		rc := daemon.NewRunContext("run_prdesc")
+		(&codex.Provider{}).ConfigureProxy(rc, &provider.Credential{Token: backend.Token})
+		p := providertest.NewProxy(t, rc, backend)
+
+		text, err := completeLLM(context.Background(), p.Client, openAIChatAPI, "test-model", "describe")
+		if err != nil {
+			t.Fatalf("completeLLM: %v", err)
+		}
+		if text != "ok" {
+			t.Errorf("text = %q, want ok", text)
+		}
+	})
+
+	t.Run("api error", func(t *testing.T) {
+		backend := providertest.Anthropic(t)
+		p := providertest.NewProxy(t, daemon.NewRunContext("run_prdesc"), backend)
+
+		_, err := completeLLM(context.Background(), p.Client, anthropicMessagesAPI, "test-model", "describe")
+		if err == nil || !strings.Contains(err.Error(), "401") {
+			t.Errorf("completeLLM without a credential: err = %v, want a 401 error", err)
+		}
+	})
+}
@@ -0,0 +1,93 @@
+package run
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/majorcontext/moat/internal/credential"
+	"github.com/majorcontext/moat/internal/mcpcatalog"
+	"github.com/majorcontext/moat/internal/provider"
+)
+
+// PreflightBudget bounds the whole credential pre-flight. Checks run in
+// parallel, so the budget is the slowest check, not the sum.
+const PreflightBudget = 2 * time.Second
+
+// PreflightResult is the outcome of checking one grant's credential.
+type PreflightResult struct {
+	Grant string
+	// Err is nil when the provider accepted the credential.
+	Err error
+	// Rejected is true when the provider refused the credential (expired,
+	// revoked). Otherwise a non-nil Err means the check was inconclusive
+	// (offline, timed out) and should not block the run.
+	Rejected bool
+}
+
+// credentialCheck is one pending pre-flight check.
+type credentialCheck struct {
+	grant   string
+	cred    *provider.Credential
+	checker provider.CredentialChecker
+}
+
+// PreflightGrants validates each granted credential against its provider
+// within PreflightBudget. Grants whose provider has no CredentialChecker,
+// whose credential moat refreshes on its own, or whose credential is missing
+// (validateGrants reports those) are skipped.
+func PreflightGrants(ctx context.Context, grants []string, store credential.Store) []PreflightResult {
+	var checks []credentialCheck
+	for _, grant := range grants {
+		grantName := strings.Split(grant, ":")[0]
+		if grantName == "ssh" || mcpcatalog.IsGrant(grant) {
+			continue
+		}
+		prov := provider.Get(grantName)
+		checker, ok := prov.(provider.CredentialChecker)
+		if !ok {
+			continue
+		}
+		cred, err := store.Get(credentialStoreKey(grantName, grant))
+		if err != nil {
+			continue
+		}
+		provCred := provider.FromLegacy(cred)
+		// A refreshable credential is re-acquired from its source when the
+		// run starts; the stored copy being stale says nothing about the run.
+		if rp, ok := prov.(provider.RefreshableProvider); ok && rp.CanRefresh(provCred) {
+			continue
+		}
+		checks = append(checks, credentialCheck{grant: grant, cred: provCred, checker: checker})
+	}
+	return runCredentialChecks(ctx, checks, PreflightBudget)
+}
+
+// runCredentialChecks runs checks in parallel under budget and returns one
+// result per check, in input order.
+func runCredentialChecks(ctx context.Context, checks []credentialCheck, budget time.Duration) []PreflightResult {
+	if len(checks) == 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, budget)
+	defer cancel()
+
+	results := make([]PreflightResult, len(checks))
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := c.checker.CheckCredential(ctx, c.cred)
+			results[i] = PreflightResult{
+				Grant:    c.grant,
+				Err:      err,
+				Rejected: errors.Is(err, provider.ErrCredentialRejected),
+			}
+		}()
+	}
+	wg.Wait()
+	return results
+}
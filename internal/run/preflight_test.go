@@ -0,0 +1,79 @@
+package run
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/majorcontext/moat/internal/provider"
+)
+
+type fakeChecker struct {
+	err   error
+	delay time.Duration
+}
+
+func (f fakeChecker) CheckCredential(ctx context.Context, cred *provider.Credential) error {
+	select {
+	case <-time.After(f.delay):
+		return f.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func TestRunCredentialChecks(t *testing.T) {
+	rejected := fmt.Errorf("%w (401)", provider.ErrCredentialRejected)
+	checks := []credentialCheck{
+		{grant: "github", checker: fakeChecker{}},
+		{grant: "anthropic", checker: fakeChecker{err: rejected}},
+		{grant: "openai", checker: fakeChecker{err: errors.New("connection refused")}},
+	}
+	results := runCredentialChecks(context.Background(), checks, time.Second)
+	if len(results) != 3 {
+		t.Fatalf("got %d results, want 3", len(results))
+	}
+	for i, want := range []struct {
+		grant    string
+		err      bool
+		rejected bool
+	}{
+		{"github", false, false},
+		{"anthropic", true, true},
+		{"openai", true, false},
+	} {
+		r := results[i]
+		if r.Grant != want.grant || (r.Err != nil) != want.err || r.Rejected != want.rejected {
+			t.Errorf("results[%d] = %+v, want grant %s err=%v rejected=%v", i, r, want.grant, want.err, want.rejected)
+		}
+	}
+}
+
+func TestRunCredentialChecksBudget(t *testing.T) {
+	checks := []credentialCheck{
+		{grant: "slow1", checker: fakeChecker{delay: time.Minute}},
+		{grant: "slow2", checker: fakeChecker{delay: time.Minute}},
+		{grant: "fast", checker: fakeChecker{}},
+	}
+	start := time.Now()
+	results := runCredentialChecks(context.Background(), checks, 50*time.Millisecond)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("checks took %v, want them bounded by the budget", elapsed)
+	}
+	for _, r := range results[:2] {
+		if !errors.Is(r.Err, context.DeadlineExceeded) || r.Rejected {
+			t.Errorf("%s: %+v, want inconclusive deadline error", r.Grant, r)
+		}
+	}
+	if results[2].Err != nil {
+		t.Errorf("fast: err = %v, want nil", results[2].Err)
+	}
+}
+
+func TestRunCredentialChecksEmpty(t *testing.T) {
+	if got := runCredentialChecks(context.Background(), nil, time.Second); got != nil {
+		t.Errorf("got %v, want nil", got)
+	}
+}
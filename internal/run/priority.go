@@ -0,0 +1,44 @@
+package run
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/majorcontext/moat/internal/container"
+	"github.com/majorcontext/moat/internal/errcode"
+)
+
+// SetPriority changes a running run's priority class. The container's CPU
+// shares and IO weight change immediately; processes keep running.
+func (m *Manager) SetPriority(ctx context.Context, runID string, priority container.Priority) error {
+	r, err := m.Get(runID)
+	if err != nil {
+		return err
+	}
+	if state := r.GetState(); state != StateRunning {
+		return errcode.Wrap(errcode.RunNotRunning, fmt.Errorf("run %s is not running (state: %s)", runID, state))
+	}
+	rt, err := m.runtimeForRun(r)
+	if err != nil {
+		return fmt.Errorf("resolving runtime for run %s: %w", runID, err)
+	}
+	if err := rt.UpdatePriority(ctx, r.ContainerID, priority); err != nil {
+		return err
+	}
+
+	r.stateMu.Lock()
+	r.Priority = priority
+	r.stateMu.Unlock()
+	return r.SaveMetadata()
+}
+
+// GetPriority returns the run's priority class, PriorityNormal if none was
+// set.
+func (r *Run) GetPriority() container.Priority {
+	r.stateMu.Lock()
+	defer r.stateMu.Unlock()
+	if r.Priority == "" {
+		return container.PriorityNormal
+	}
+	return r.Priority
+}
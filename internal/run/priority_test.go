@@ -0,0 +1,60 @@
+package run
+
+import (
+	"context"
+	"testing"
+
+	"github.com/majorcontext/moat/internal/config"
+	"github.com/majorcontext/moat/internal/container"
+	"github.com/majorcontext/moat/internal/storage"
+)
+
+func TestSetPriority(t *testing.T) {
+	store, err := storage.NewRunStore(t.TempDir(), "run_prio")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rt := &stubRuntime{priorities: map[string]container.Priority{}}
+	r := &Run{ID: "run_prio", Name: "prio", ContainerID: "ctr", State: StateRunning, Store: store}
+	m := &Manager{
+		runs:        map[string]*Run{r.ID: r},
+		runtimePool: container.NewRuntimePoolWithDefault(rt),
+	}
+
+	if got := r.GetPriority(); got != container.PriorityNormal {
+		t.Errorf("initial priority = %s, want normal", got)
+	}
+	if err := m.SetPriority(context.Background(), r.ID, container.PriorityBackground); err != nil {
+		t.Fatalf("SetPriority: %v", err)
+	}
+	if rt.priorities["ctr"] != container.PriorityBackground {
+		t.Errorf("runtime priorities = %v, want ctr=background", rt.priorities)
+	}
+	meta, err := store.LoadMetadata()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.Priority != "background" {
+		t.Errorf("saved priority = %q, want background", meta.Priority)
+	}
+
+	r.State = StateStopped
+	if err := m.SetPriority(context.Background(), r.ID, container.PriorityHigh); err == nil {
+		t.Error("SetPriority on a stopped run succeeded")
+	}
+}
+
+func TestResolvePriority(t *testing.T) {
+	m := &Manager{runtimePool: container.NewRuntimePoolWithDefault(&stubRuntime{})}
+	cfg := &config.Config{Container: config.ContainerConfig{Priority: "background"}}
+
+	if got := m.resolvePriority(Options{Config: cfg}); got != container.PriorityBackground {
+		t.Errorf("from config = %q, want background", got)
+	}
+	if got := m.resolvePriority(Options{Config: cfg, Priority: container.PriorityHigh}); got != container.PriorityHigh {
+		t.Errorf("flag over config = %q, want high", got)
+	}
+	if got := m.resolvePriority(Options{}); got != "" {
+		t.Errorf("unset = %q, want empty", got)
+	}
+}
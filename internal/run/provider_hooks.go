@@ -0,0 +1,234 @@
+package run
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/majorcontext/moat/internal/daemon"
+	"github.com/majorcontext/moat/internal/log"
+	"github.com/majorcontext/moat/internal/provider"
+	"github.com/majorcontext/moat/internal/snapshot"
+	"github.com/majorcontext/moat/internal/storage"
+)
+
+// providerEventRetryInterval is how long watchProviderEvents waits before
+// reconnecting to the daemon's request stream after it drops.
+const providerEventRetryInterval = 5 * time.Second
+
+// grantProviders returns the providers of r's grants, each once.
+func grantProviders(r *Run) []provider.CredentialProvider {
+	var provs []provider.CredentialProvider
+	seen := make(map[string]bool)
+	for _, grant := range r.Grants {
+		prov := provider.Get(strings.Split(grant, ":")[0])
+		if prov == nil || seen[prov.Name()] {
+			continue
+		}
+		seen[prov.Name()] = true
+		provs = append(provs, prov)
+	}
+	return provs
+}
+
+// providerRunContext describes r to provider lifecycle hooks.
+func providerRunContext(r *Run) provider.RunContext {
+	r.stateMu.Lock()
+	defer r.stateMu.Unlock()
+	return provider.RunContext{
+		RunID:     r.ID,
+		Name:      r.Name,
+		Workspace: r.Workspace,
+		StartedAt: r.StartedAt,
+	}
+}
+
+// mergeProviderMeta merges metadata returned by a provider hook into
+// r.ProviderMeta and reports whether anything changed.
+func mergeProviderMeta(r *Run, meta map[string]string) bool {
+	if len(meta) == 0 {
+		return false
+	}
+	// ProviderMeta is guarded by stateMu — SaveMetadata may read it
+	// concurrently from monitorContainerExit/Stop.
+	r.stateMu.Lock()
+	defer r.stateMu.Unlock()
+	changed := false
+	for k, v := range meta {
+		if r.ProviderMeta[k] == v {
+			continue
+		}
+		if r.ProviderMeta == nil {
+			r.ProviderMeta = make(map[string]string)
+		}
+		r.ProviderMeta[k] = v
+		changed = true
+	}
+	return changed
+}
+
+// callProviderHooks calls hook for each of r's grant providers and merges
+// the metadata it returns. hook returns nil for providers that do not
+// implement the hook in question.
+func callProviderHooks(r *Run, hook func(provider.CredentialProvider, provider.RunContext) map[string]string) bool {
+	ctx := providerRunContext(r)
+	changed := false
+	for _, prov := range grantProviders(r) {
+		if mergeProviderMeta(r, hook(prov, ctx)) {
+			changed = true
+		}
+	}
+	return changed
+}
+
+// runProviderStartingHooks calls OnRunStarting on each grant provider that
+// implements provider.RunStartingHook.
+func runProviderStartingHooks(r *Run) {
+	callProviderHooks(r, func(prov provider.CredentialProvider, ctx provider.RunContext) map[string]string {
+		if hook, ok := prov.(provider.RunStartingHook); ok {
+			return hook.OnRunStarting(ctx)
+		}
+		return nil
+	})
+}
+
+// runProviderStoppedHooks iterates the run's grant providers and calls
+// OnRunStopped on each that implements provider.RunStoppedHook. Returned
+// metadata is merged into r.ProviderMeta.
+func runProviderStoppedHooks(r *Run) {
+	// Ensure hooks run exactly once — multiple call sites race
+	// (monitorContainerExit goroutine vs StartAttached/Stop on main goroutine).
+	if !r.providerHooksDone.CompareAndSwap(false, true) {
+		return
+	}
+	callProviderHooks(r, func(prov provider.CredentialProvider, ctx provider.RunContext) map[string]string {
+		if hook, ok := prov.(provider.RunStoppedHook); ok {
+			return hook.OnRunStopped(ctx)
+		}
+		return nil
+	})
+}
+
+// runProviderSnapshotHooks calls OnSnapshotCreated on each grant provider
+// that implements provider.SnapshotCreatedHook. Metadata that changes while
+// the run is active is saved right away, so it survives a crashed host.
+func runProviderSnapshotHooks(r *Run, meta snapshot.Metadata) {
+	ev := provider.SnapshotEvent{
+		ID:    meta.ID,
+		Type:  string(meta.Type),
+		Label: meta.Label,
+		Time:  meta.CreatedAt,
+	}
+	changed := callProviderHooks(r, func(prov provider.CredentialProvider, ctx provider.RunContext) map[string]string {
+		if hook, ok := prov.(provider.SnapshotCreatedHook); ok {
+			return hook.OnSnapshotCreated(ctx, ev)
+		}
+		return nil
+	})
+	if changed && r.GetState() == StateRunning {
+		if err := r.SaveMetadata(); err != nil {
+			log.Debug("failed to save provider metadata after snapshot", "runID", r.ID, "error", err)
+		}
+	}
+}
+
+// runProviderNetworkBlockedHooks calls OnNetworkBlocked on each grant
+// provider that implements provider.NetworkBlockedHook.
+func runProviderNetworkBlockedHooks(r *Run, ev provider.NetworkBlockedEvent) {
+	callProviderHooks(r, func(prov provider.CredentialProvider, ctx provider.RunContext) map[string]string {
+		if hook, ok := prov.(provider.NetworkBlockedHook); ok {
+			return hook.OnNetworkBlocked(ctx, ev)
+		}
+		return nil
+	})
+}
+
+// runProviderBudgetHooks calls OnBudgetExceeded on each grant provider that
+// implements provider.BudgetExceededHook, at most once per run.
+func runProviderBudgetHooks(r *Run, ev provider.BudgetExceededEvent) {
+	if !r.budgetHooksDone.CompareAndSwap(false, true) {
+		return
+	}
+	callProviderHooks(r, func(prov provider.CredentialProvider, ctx provider.RunContext) map[string]string {
+		if hook, ok := prov.(provider.BudgetExceededHook); ok {
+			return hook.OnBudgetExceeded(ctx, ev)
+		}
+		return nil
+	})
+}
+
+// handleProviderDecision dispatches a proxy decision for r to the provider
+// hooks it concerns: denials to NetworkBlockedHook, and an HTTP 402 from an
+// LLM API to BudgetExceededHook. Plain 429s are not budget errors, and the
+// stream carries no response bodies to tell them apart, so quota errors
+// reported with a 429 are only caught by the exit classification.
+func handleProviderDecision(r *Run, d storage.Decision) {
+	if d.Decision == "deny" {
+		runProviderNetworkBlockedHooks(r, provider.NetworkBlockedEvent{
+			Time:   d.Timestamp,
+			Method: d.Method,
+			Host:   d.Host,
+			Path:   d.Path,
+			Reason: d.Reason,
+			Rule:   d.Rule,
+		})
+		return
+	}
+	host := d.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if d.StatusCode == 402 && llmAPIHosts[host] {
+		runProviderBudgetHooks(r, provider.BudgetExceededEvent{
+			Time:       d.Timestamp,
+			Host:       host,
+			StatusCode: d.StatusCode,
+		})
+	}
+}
+
+// wantsProviderEvents reports whether any of r's grant providers implements
+// a hook fed by the daemon's request stream.
+func wantsProviderEvents(r *Run) bool {
+	for _, prov := range grantProviders(r) {
+		switch prov.(type) {
+		case provider.NetworkBlockedHook, provider.BudgetExceededHook:
+			return true
+		}
+	}
+	return false
+}
+
+// watchProviderEvents follows the daemon's request stream for r and
+// dispatches it to provider hooks until ctx is canceled, which callers do
+// when the container exits. The stream is reopened if the daemon restarts.
+func (m *Manager) watchProviderEvents(ctx context.Context, r *Run) {
+	if !wantsProviderEvents(r) {
+		return
+	}
+	for {
+		m.mu.RLock()
+		dc := m.daemonClient
+		m.mu.RUnlock()
+		if dc == nil {
+			return
+		}
+		err := dc.StreamRequests(ctx, r.ID, func(ev daemon.RequestEvent) {
+			handleProviderDecision(r, ev.Decision)
+		})
+		if ctx.Err() != nil || errors.Is(err, daemon.ErrStreamUnsupported) {
+			return
+		}
+		// The stream also ends cleanly when the daemon shuts down, and
+		// reports ErrRunNotFound until a restarted daemon has the run
+		// re-registered; retry until the run exits.
+		log.Debug("provider event stream ended", "runID", r.ID, "error", err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(providerEventRetryInterval):
+		}
+	}
+}
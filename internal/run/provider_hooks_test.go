@@ -0,0 +1,141 @@
+package run
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/majorcontext/moat/internal/provider"
+	"github.com/majorcontext/moat/internal/snapshot"
+	"github.com/majorcontext/moat/internal/storage"
+)
+
+// hookProvider implements every provider lifecycle hook and records the
+// calls it receives.
+type hookProvider struct {
+	name     string
+	calls    []string
+	blocked  []provider.NetworkBlockedEvent
+	budget   []provider.BudgetExceededEvent
+	lastCtx  provider.RunContext
+	snapshot provider.SnapshotEvent
+}
+
+func (p *hookProvider) Name() string                                                  { return p.name }
+func (p *hookProvider) Grant(context.Context) (*provider.Credential, error)           { return nil, nil }
+func (p *hookProvider) ConfigureProxy(provider.ProxyConfigurer, *provider.Credential) {}
+func (p *hookProvider) ContainerEnv(*provider.Credential) []string                    { return nil }
+func (p *hookProvider) ContainerMounts(*provider.Credential, string) ([]provider.MountConfig, string, error) {
+	return nil, "", nil
+}
+func (p *hookProvider) Cleanup(string)                {}
+func (p *hookProvider) ImpliedDependencies() []string { return nil }
+
+func (p *hookProvider) OnRunStarting(ctx provider.RunContext) map[string]string {
+	p.calls = append(p.calls, "starting")
+	p.lastCtx = ctx
+	return map[string]string{"phase": "starting"}
+}
+
+func (p *hookProvider) OnRunStopped(ctx provider.RunStoppedContext) map[string]string {
+	p.calls = append(p.calls, "stopped")
+	return map[string]string{"phase": "stopped"}
+}
+
+func (p *hookProvider) OnNetworkBlocked(ctx provider.RunContext, ev provider.NetworkBlockedEvent) map[string]string {
+	p.calls = append(p.calls, "blocked")
+	p.blocked = append(p.blocked, ev)
+	return nil
+}
+
+func (p *hookProvider) OnBudgetExceeded(ctx provider.RunContext, ev provider.BudgetExceededEvent) map[string]string {
+	p.calls = append(p.calls, "budget")
+	p.budget = append(p.budget, ev)
+	return map[string]string{"budget_exceeded": ev.Host}
+}
+
+func (p *hookProvider) OnSnapshotCreated(ctx provider.RunContext, ev provider.SnapshotEvent) map[string]string {
+	p.calls = append(p.calls, "snapshot")
+	p.snapshot = ev
+	return map[string]string{"last_snapshot": ev.ID}
+}
+
+func registerHookProvider(t *testing.T) *hookProvider {
+	t.Helper()
+	p := &hookProvider{name: "testhooks"}
+	provider.Register(p)
+	t.Cleanup(func() { provider.Unregister(p.name) })
+	return p
+}
+
+func TestProviderHooks(t *testing.T) {
+	p := registerHookProvider(t)
+	// Two grants of the same provider call its hooks once.
+	r := &Run{ID: "run_hooks", Name: "hooks", Workspace: "/ws", Grants: []string{"testhooks", "testhooks:other", "unknown"}}
+
+	runProviderStartingHooks(r)
+	if p.lastCtx.RunID != "run_hooks" || p.lastCtx.Name != "hooks" || p.lastCtx.Workspace != "/ws" {
+		t.Errorf("OnRunStarting ctx = %+v", p.lastCtx)
+	}
+	if r.ProviderMeta["phase"] != "starting" {
+		t.Errorf("ProviderMeta = %v, want phase=starting", r.ProviderMeta)
+	}
+
+	runProviderSnapshotHooks(r, snapshot.Metadata{ID: "snap_1", Type: snapshot.TypeGit, Label: "fix bug"})
+	if p.snapshot.ID != "snap_1" || p.snapshot.Type != "git" || p.snapshot.Label != "fix bug" {
+		t.Errorf("OnSnapshotCreated event = %+v", p.snapshot)
+	}
+	if r.ProviderMeta["last_snapshot"] != "snap_1" {
+		t.Errorf("ProviderMeta = %v, want last_snapshot=snap_1", r.ProviderMeta)
+	}
+
+	runProviderStoppedHooks(r)
+	runProviderStoppedHooks(r)
+
+	want := []string{"starting", "snapshot", "stopped"}
+	if len(p.calls) != len(want) {
+		t.Fatalf("calls = %v, want %v", p.calls, want)
+	}
+	for i := range want {
+		if p.calls[i] != want[i] {
+			t.Errorf("calls = %v, want %v", p.calls, want)
+			break
+		}
+	}
+	if r.ProviderMeta["phase"] != "stopped" {
+		t.Errorf("ProviderMeta = %v, want phase=stopped", r.ProviderMeta)
+	}
+}
+
+func TestHandleProviderDecision(t *testing.T) {
+	p := registerHookProvider(t)
+	r := &Run{ID: "run_hooks", Grants: []string{"testhooks"}}
+	if !wantsProviderEvents(r) {
+		t.Fatal("wantsProviderEvents() = false for a provider with stream hooks")
+	}
+
+	now := time.Now()
+	handleProviderDecision(r, storage.Decision{Timestamp: now, Method: "GET", Host: "evil.example.com", Decision: "deny", Reason: "policy"})
+	handleProviderDecision(r, storage.Decision{Host: "api.anthropic.com", Decision: "allow", StatusCode: 200})
+	handleProviderDecision(r, storage.Decision{Host: "api.anthropic.com", Decision: "allow", StatusCode: 429})
+	handleProviderDecision(r, storage.Decision{Host: "example.com", Decision: "allow", StatusCode: 402})
+	handleProviderDecision(r, storage.Decision{Host: "api.anthropic.com:443", Decision: "allow", StatusCode: 402})
+	handleProviderDecision(r, storage.Decision{Host: "api.openai.com", Decision: "allow", StatusCode: 402})
+
+	if len(p.blocked) != 1 || p.blocked[0].Host != "evil.example.com" || p.blocked[0].Reason != "policy" || !p.blocked[0].Time.Equal(now) {
+		t.Errorf("blocked = %+v, want one evil.example.com denial", p.blocked)
+	}
+	// Budget hooks run once per run, for the first LLM API 402.
+	if len(p.budget) != 1 || p.budget[0].Host != "api.anthropic.com" || p.budget[0].StatusCode != 402 {
+		t.Errorf("budget = %+v, want one api.anthropic.com 402", p.budget)
+	}
+	if r.ProviderMeta["budget_exceeded"] != "api.anthropic.com" {
+		t.Errorf("ProviderMeta = %v", r.ProviderMeta)
+	}
+}
+
+func TestWantsProviderEvents_NoHooks(t *testing.T) {
+	if wantsProviderEvents(&Run{Grants: []string{"unknown"}}) {
+		t.Error("wantsProviderEvents() = true with no stream hooks")
+	}
+}
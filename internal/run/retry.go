@@ -0,0 +1,114 @@
+package run
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/containerd/errdefs"
+
+	"github.com/majorcontext/moat/internal/log"
+	"github.com/majorcontext/moat/internal/ui"
+)
+
+// Retry backoff bounds. The delay doubles after each failure, starting at
+// retryInitialDelay and capped at retryMaxDelay, with jitter so concurrent
+// runs hitting the same registry do not retry in lockstep.
+const (
+	retryInitialDelay = 2 * time.Second
+	retryMaxDelay     = 30 * time.Second
+)
+
+// retryPolicy retries operations that fail transiently while a run is
+// created: image pulls, network creation, and sidecar and service starts.
+// The zero value makes a single attempt.
+type retryPolicy struct {
+	attempts int
+	initial  time.Duration
+	max      time.Duration
+}
+
+// newRetryPolicy returns a policy making up to attempts attempts with the
+// default backoff.
+func newRetryPolicy(attempts int) retryPolicy {
+	return retryPolicy{attempts: attempts, initial: retryInitialDelay, max: retryMaxDelay}
+}
+
+// do calls fn until it succeeds, fails with an error that is not transient,
+// the attempts run out, or ctx is canceled. op names the operation in
+// messages. The error of the last attempt is returned.
+func (p retryPolicy) do(ctx context.Context, op string, fn func() error) error {
+	delay := p.initial
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= p.attempts || !isTransient(err) || ctx.Err() != nil {
+			return err
+		}
+
+		wait := jitter(delay)
+		log.Debug("retrying after transient failure", "op", op, "attempt", attempt, "wait", wait, "error", err)
+		ui.Warnf("%s failed (%v); retrying in %s (attempt %d of %d)", op, err, wait.Round(100*time.Millisecond), attempt+1, p.attempts)
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		delay = min(2*delay, p.max)
+	}
+}
+
+// jitter returns a random duration in [d/2, d).
+func jitter(d time.Duration) time.Duration {
+	if d <= 1 {
+		return d
+	}
+	return d/2 + rand.N(d/2)
+}
+
+// transientMarkers are substrings of errors from registries, the Docker
+// daemon, and the network that are worth retrying. Errors from the container
+// CLIs arrive as text, so matching on the message is the only option there.
+var transientMarkers = []string{
+	"tls handshake timeout",
+	"i/o timeout",
+	"connection reset by peer",
+	"connection refused",
+	"unexpected eof",
+	"temporary failure in name resolution",
+	"server misbehaving",
+	"toomanyrequests",
+	"too many requests",
+	"502 bad gateway",
+	"503 service unavailable",
+	"504 gateway timeout",
+	"net/http: request canceled while waiting for connection",
+}
+
+// isTransient returns true if err looks like a temporary network, registry,
+// or daemon failure rather than a problem with the run's configuration.
+func isTransient(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	if errdefs.IsUnavailable(err) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	msg := strings.ToLower(err.Error())
+	for _, marker := range transientMarkers {
+		if strings.Contains(msg, marker) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,128 @@
+package run
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicy_RetriesTransientErrors(t *testing.T) {
+	p := retryPolicy{attempts: 3, initial: time.Millisecond, max: time.Millisecond}
+	calls := 0
+	err := p.do(context.Background(), "Pulling image", func() error {
+		calls++
+		if calls < 3 {
+			return errors.New("Get https://registry-1.docker.io/v2/: net/http: TLS handshake timeout")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("do() = %v, want success on the third attempt", err)
+	}
+	if calls != 3 {
+		t.Errorf("calls = %d, want 3", calls)
+	}
+}
+
+func TestRetryPolicy_StopsOnPermanentError(t *testing.T) {
+	p := retryPolicy{attempts: 5, initial: time.Millisecond, max: time.Millisecond}
+	calls := 0
+	want := errors.New("pull access denied for nosuchimage, repository does not exist")
+	err := p.do(context.Background(), "Pulling image", func() error {
+		calls++
+		return want
+	})
+	if err != want {
+		t.Errorf("do() = %v, want %v", err, want)
+	}
+	if calls != 1 {
+		t.Errorf("calls = %d, want 1 (permanent errors are not retried)", calls)
+	}
+}
+
+func TestRetryPolicy_GivesUpAfterAttempts(t *testing.T) {
+	p := retryPolicy{attempts: 2, initial: time.Millisecond, max: time.Millisecond}
+	calls := 0
+	err := p.do(context.Background(), "Creating network", func() error {
+		calls++
+		return fmt.Errorf("attempt %d: %w", calls, io.ErrUnexpectedEOF)
+	})
+	if err == nil || err.Error() != "attempt 2: unexpected EOF" {
+		t.Errorf("do() = %v, want the last attempt's error", err)
+	}
+	if calls != 2 {
+		t.Errorf("calls = %d, want 2", calls)
+	}
+}
+
+func TestRetryPolicy_ZeroValueMakesOneAttempt(t *testing.T) {
+	var p retryPolicy
+	calls := 0
+	_ = p.do(context.Background(), "Starting service", func() error {
+		calls++
+		return io.ErrUnexpectedEOF
+	})
+	if calls != 1 {
+		t.Errorf("calls = %d, want 1", calls)
+	}
+}
+
+func TestRetryPolicy_StopsWhenCanceled(t *testing.T) {
+	p := retryPolicy{attempts: 3, initial: time.Hour, max: time.Hour}
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	done := make(chan error, 1)
+	go func() {
+		done <- p.do(ctx, "Pulling image", func() error {
+			calls++
+			return io.ErrUnexpectedEOF
+		})
+	}()
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	select {
+	case err := <-done:
+		if !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Errorf("do() = %v, want the last attempt's error", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("do() did not return after cancel")
+	}
+	if calls != 1 {
+		t.Errorf("calls = %d, want 1", calls)
+	}
+}
+
+func TestIsTransient(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{errors.New("read tcp 10.0.0.2:51234->1.2.3.4:443: read: connection reset by peer"), true},
+		{errors.New("toomanyrequests: You have reached your pull rate limit"), true},
+		{errors.New("dial tcp: lookup registry-1.docker.io: Temporary failure in name resolution"), true},
+		{errors.New("received unexpected HTTP status: 503 Service Unavailable"), true},
+		{fmt.Errorf("pulling image: %w", io.ErrUnexpectedEOF), true},
+		{errors.New("manifest for alpine:nope not found: manifest unknown"), false},
+		{errors.New("invalid reference format"), false},
+		{context.Canceled, false},
+		{nil, false},
+	}
+	for _, tt := range tests {
+		if got := isTransient(tt.err); got != tt.want {
+			t.Errorf("isTransient(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestJitter(t *testing.T) {
+	d := 4 * time.Second
+	for range 100 {
+		if got := jitter(d); got < d/2 || got >= d {
+			t.Fatalf("jitter(%s) = %s, want within [%s, %s)", d, got, d/2, d)
+		}
+	}
+}
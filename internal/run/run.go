@@ -13,15 +13,21 @@ import (
 
 	"github.com/majorcontext/moat/internal/audit"
 	"github.com/majorcontext/moat/internal/config"
+	"github.com/majorcontext/moat/internal/container"
 	"github.com/majorcontext/moat/internal/credential"
 	"github.com/majorcontext/moat/internal/daemon"
+	"github.com/majorcontext/moat/internal/dockerproxy"
+	"github.com/majorcontext/moat/internal/errcode"
 	"github.com/majorcontext/moat/internal/id"
 	"github.com/majorcontext/moat/internal/mcpcatalog"
 	"github.com/majorcontext/moat/internal/provider"
 	awsprov "github.com/majorcontext/moat/internal/providers/aws"
+	"github.com/majorcontext/moat/internal/providers/claude"
 	"github.com/majorcontext/moat/internal/snapshot"
 	"github.com/majorcontext/moat/internal/sshagent"
 	"github.com/majorcontext/moat/internal/storage"
+	"github.com/majorcontext/moat/internal/telemetry"
+	"github.com/majorcontext/moat/internal/testresult"
 )
 
 // State represents the current state of a run.
@@ -47,39 +53,72 @@ type Run struct {
 	Name      string // Human-friendly name (e.g., "myapp" or "fluffy-chicken")
 	Workspace string
 	// Worktree tracking (set when created via moat wt or --wt flag)
-	WorktreeBranch    string
-	WorktreePath      string
-	WorktreeRepoID    string
-	Grants            []string
-	Agent             string            // Agent type from config (e.g., "claude-code", "codex")
-	Image             string            // Container image used for this run
-	Runtime           string            // Container runtime type ("docker" or "apple")
-	ProviderMeta      map[string]string // Provider-specific metadata (e.g., claude_session_id)
-	Ports             map[string]int    // endpoint name -> container port
-	HostPorts         map[string]int    // endpoint name -> host port (after binding)
+	WorktreeBranch string
+	WorktreePath   string
+	WorktreeRepoID string
+	WorkspaceRepos []storage.WorkspaceRepo // Repositories from workspaces: in moat.yaml
+	Grants         []string
+	Labels         map[string]string // User-supplied labels (--label key=value)
+	Group          string            // Run group (--group), see ValidateGroup
+	IdempotencyKey string            // Key the run ID was derived from (--id-from)
+	PullRequest    string            // Pull request the run works on (--pr), see ParsePullRequest
+	Agent          string            // Agent type from config (e.g., "claude-code", "codex")
+	Image          string            // Container image used for this run
+	Runtime        string            // Container runtime type ("docker", "apple", or "podman")
+	ProviderMeta   map[string]string // Provider-specific metadata (e.g., claude_session_id)
+	Ports          map[string]int    // endpoint name -> container port
+	HostPorts      map[string]int    // endpoint name -> host port (after binding)
+
+	// PublishedServices are service dependencies the routing proxy serves
+	// as endpoints of the run, keyed by service name.
+	PublishedServices map[string]PublishedService
+	// IDE is true when the run serves SSH for IDE attach (ide.enabled).
+	IDE               bool
 	State             State
 	ContainerID       string
-	SSHAgentServer    *sshagent.Server  // SSH agent proxy for SSH key access
-	Store             *storage.RunStore // Run data storage
-	logsCaptured      atomic.Bool       // Track if logs have been captured (for idempotency)
-	providerHooksDone atomic.Bool       // Track if provider stopped hooks have run (for idempotency)
-	exitCh            chan struct{}     // Closed when container exits (signaled by monitorContainerExit)
-	AuditStore        *audit.Store      // Tamper-proof audit log
-	SnapEngine        *snapshot.Engine  // Snapshot engine for workspace protection
-	KeepContainer     bool              // If true, don't auto-remove container after run
-	Interactive       bool              // If true, run was started in interactive mode
-	Clipboard         bool              // If true, host clipboard bridging is enabled
+	SSHAgentServer    *sshagent.Server        // SSH agent proxy for SSH key access
+	DockerAPIServer   *dockerproxy.Server     // Docker API filter for docker:host with docker.filter
+	Store             *storage.RunStore       // Run data storage
+	logsCaptured      atomic.Bool             // Track if logs have been captured (for idempotency)
+	providerHooksDone atomic.Bool             // Track if provider stopped hooks have run (for idempotency)
+	budgetHooksDone   atomic.Bool             // Track if provider budget hooks have run (at most once per run)
+	exitCh            chan struct{}           // Closed when container exits (signaled by monitorContainerExit)
+	AuditStore        *audit.Store            // Tamper-proof audit log
+	SnapEngine        *snapshot.Engine        // Snapshot engine for workspace protection
+	commitTrigger     *snapshot.CommitTrigger // Snapshots after git commits (nil when disabled)
+	KeepContainer     bool                    // If true, don't auto-remove container after run
+	Interactive       bool                    // If true, run was started in interactive mode
+	Clipboard         bool                    // If true, host clipboard bridging is enabled
 	CreatedAt         time.Time
 	StartedAt         time.Time
 	StoppedAt         time.Time
 	Error             string
 
+	// TestResults holds pass/fail counts parsed from the run's logs after the
+	// container exits. Nil when no test runner output was detected.
+	TestResults *testresult.Summary
+
+	// ExitCode is the main container's exit code as observed by the
+	// container monitor. FailureClass categorizes a failed run; empty when
+	// the run succeeded or its exit was not observed.
+	ExitCode     int64
+	FailureClass FailureClass
+
+	// MemoryMB is the memory limit passed to the container runtime
+	// (0 = runtime default). Used to explain OOM kills.
+	MemoryMB int
+
+	// Priority is the run's CPU and IO weight class (empty = normal).
+	// Protected by stateMu; SetPriority changes it while the run is live.
+	Priority container.Priority
+
 	// Shutdown coordination to prevent race conditions
-	sshAgentStopOnce sync.Once // Ensures SSHAgentServer.Stop() called only once
-	cleanupOnce      sync.Once // Ensures resource cleanup runs only once
+	sshAgentStopOnce  sync.Once // Ensures SSHAgentServer.Stop() called only once
+	dockerAPIStopOnce sync.Once // Ensures DockerAPIServer.Stop() called only once
+	cleanupOnce       sync.Once // Ensures resource cleanup runs only once
 
-	// State protection - guards State, Error, StartedAt, StoppedAt, and
-	// ProviderMeta. Use this lock when reading or modifying these fields to
+	// State protection - guards State, Error, StartedAt, StoppedAt,
+	// ProviderMeta, TestResults, ExitCode, and FailureClass. Use this lock when reading or modifying these fields to
 	// prevent races between the monitorContainerExit goroutine, provider
 	// stopped-hooks, and user-facing methods.
 	stateMu sync.Mutex
@@ -105,7 +144,9 @@ type Run struct {
 	ProviderCleanupPaths map[string]string
 
 	// Snapshot settings
-	DisablePreRunSnapshot bool // If true, skip pre-run snapshot creation
+	DisablePreRunSnapshot  bool // If true, skip pre-run snapshot creation
+	DisablePostRunSnapshot bool // If true, skip the snapshot taken when the container exits
+	DisableCommitSnapshots bool // If true, skip snapshots after git commits
 
 	// Workspace mode (set when workspace.mode: volume). WorkspaceMode is the
 	// resolved mode ("bind" or "volume"); WorkspaceVolume is the per-run Docker
@@ -119,6 +160,15 @@ type Run struct {
 	// awsTempDir is the temp directory for AWS credential helper (cleaned up on destroy)
 	awsTempDir string
 
+	// ctlTempDir is the temp directory for the moatctl helper (cleaned up on destroy)
+	ctlTempDir string
+	// ideDir holds the run's IDE attach keys (removed on destroy)
+	ideDir string
+
+	// sshKnownHostsDir is the temp directory holding the pinned SSH known_hosts
+	// file (cleaned up on destroy)
+	sshKnownHostsDir string
+
 	// ClaudeConfigTempDir is the temporary directory containing Claude configuration files
 	// (settings.json, .mcp.json) that are mounted into the container. This should be
 	// cleaned up when the run is stopped or destroyed.
@@ -145,6 +195,15 @@ type Run struct {
 
 	// ServiceContainers maps service name to container ID (e.g., "postgres" -> "abc123").
 	ServiceContainers map[string]string
+
+	// InternalNetworkID is the run's internal network, without a route out
+	// of the host, created when a service sets network.egress: false. The
+	// agent and every service join it.
+	InternalNetworkID string
+
+	// ServiceAccess limits the agent's connections to services whose
+	// network block restricts it. Applied with the firewall on start.
+	ServiceAccess []container.ServiceAccess
 }
 
 // Options configures a new run.
@@ -161,6 +220,29 @@ type Options struct {
 	Clipboard     bool           // Enable host clipboard bridging
 	// WorkspaceMode is the resolved workspace mode (bind|volume). Empty == bind.
 	WorkspaceMode config.WorkspaceMode
+	// NoEgress runs without grants, behind a strict firewall with an empty
+	// allowlist, with the workspace mounted read-only, and records a signed
+	// isolation attestation in the audit log.
+	NoEgress bool
+	// Labels are arbitrary key=value pairs for grouping and filtering runs
+	// (see ParseLabels).
+	Labels map[string]string
+	// Group ties the run to related runs that are listed, stopped, and
+	// cleaned together (see Manager.GroupRuns).
+	Group string
+	// IdempotencyKey, if set, derives the run ID from the key (--id-from).
+	// Create returns an *ExistsError instead of creating a second run for a
+	// key already used.
+	IdempotencyKey string
+	// PullRequest is the pull request the run works on (--pr): a number in
+	// the workspace's GitHub repository or a pull request URL.
+	PullRequest string
+	// Priority sets the run's CPU and IO weight class, overriding
+	// container.priority in moat.yaml. Empty uses the config.
+	Priority container.Priority
+	// Network, if set, joins the run's container to a network shared with
+	// other runs (see moat compose).
+	Network *SharedNetwork
 }
 
 // generateID creates a unique run identifier.
@@ -184,12 +266,20 @@ func (r *Run) SaveMetadata() error {
 	stoppedAt := r.StoppedAt
 	errMsg := r.Error
 	providerMeta := maps.Clone(r.ProviderMeta)
+	testResults := r.TestResults
+	exitCode := r.ExitCode
+	failureClass := r.FailureClass
+	priority := r.Priority
 	r.stateMu.Unlock()
 
 	return r.Store.SaveMetadata(storage.Metadata{
 		Name:                r.Name,
 		Workspace:           r.Workspace,
 		Grants:              r.Grants,
+		Labels:              r.Labels,
+		Group:               r.Group,
+		IdempotencyKey:      r.IdempotencyKey,
+		PullRequest:         r.PullRequest,
 		Agent:               r.Agent,
 		Image:               r.Image,
 		Ports:               r.Ports,
@@ -204,15 +294,33 @@ func (r *Run) SaveMetadata() error {
 		WorktreeBranch:      r.WorktreeBranch,
 		WorktreePath:        r.WorktreePath,
 		WorktreeRepoID:      r.WorktreeRepoID,
+		WorkspaceRepos:      r.WorkspaceRepos,
 		Runtime:             r.Runtime,
 		BuildkitContainerID: r.BuildkitContainerID,
 		NetworkID:           r.NetworkID,
+		InternalNetworkID:   r.InternalNetworkID,
 		ServiceContainers:   r.ServiceContainers,
 		WorkspaceMode:       r.WorkspaceMode,
 		WorkspaceVolume:     r.WorkspaceVolume,
+		TestResults:         testResults,
+		ExitCode:            exitCode,
+		FailureClass:        string(failureClass),
+		MemoryMB:            r.MemoryMB,
+		Priority:            string(priority),
+		IDE:                 r.IDE,
 	})
 }
 
+// daemonUpdate returns the run's containers as reported to the proxy
+// daemon once the container is created.
+func (r *Run) daemonUpdate() daemon.UpdateRunRequest {
+	return daemon.UpdateRunRequest{
+		ContainerID:       r.ContainerID,
+		Runtime:           r.Runtime,
+		ServiceContainers: r.ServiceContainers,
+	}
+}
+
 // stopProxyServer is a no-op. The proxy is managed by the daemon process
 // and token refresh is handled by the daemon. Daemon run unregistration
 // is handled separately by the Manager.
@@ -235,6 +343,19 @@ func (r *Run) stopSSHAgentServer() error {
 	return stopErr
 }
 
+// stopDockerAPIServer safely stops the Docker API filter exactly once.
+// This method is safe to call concurrently from multiple goroutines.
+func (r *Run) stopDockerAPIServer() error {
+	var stopErr error
+	r.dockerAPIStopOnce.Do(func() {
+		if r.DockerAPIServer != nil {
+			stopErr = r.DockerAPIServer.Stop()
+			r.DockerAPIServer = nil
+		}
+	})
+	return stopErr
+}
+
 // GetState safely reads the run state (thread-safe).
 func (r *Run) GetState() State {
 	r.stateMu.Lock()
@@ -245,28 +366,37 @@ func (r *Run) GetState() State {
 // SetState safely updates the run state (thread-safe).
 func (r *Run) SetState(state State) {
 	r.stateMu.Lock()
-	defer r.stateMu.Unlock()
+	from := r.State
 	r.State = state
+	t := r.transitionLocked(from)
+	r.stateMu.Unlock()
+	telemetry.RunState(t)
 }
 
 // SetStateWithError safely updates the run state and error (thread-safe).
 func (r *Run) SetStateWithError(state State, err string) {
 	r.stateMu.Lock()
-	defer r.stateMu.Unlock()
+	from := r.State
 	r.State = state
 	r.Error = err
+	t := r.transitionLocked(from)
+	r.stateMu.Unlock()
+	telemetry.RunState(t)
 }
 
 // SetStateWithTime safely updates the run state and timestamp (thread-safe).
 func (r *Run) SetStateWithTime(state State, timestamp time.Time) {
 	r.stateMu.Lock()
-	defer r.stateMu.Unlock()
+	from := r.State
 	r.State = state
 	if state == StateRunning {
 		r.StartedAt = timestamp
 	} else if state == StateStopped || state == StateFailed {
 		r.StoppedAt = timestamp
 	}
+	t := r.transitionLocked(from)
+	r.stateMu.Unlock()
+	telemetry.RunState(t)
 }
 
 // SetStateFailedAt atomically sets state to StateFailed with both error and
@@ -274,10 +404,28 @@ func (r *Run) SetStateWithTime(state State, timestamp time.Time) {
 // from observing StateFailed with no StoppedAt set.
 func (r *Run) SetStateFailedAt(errMsg string, timestamp time.Time) {
 	r.stateMu.Lock()
-	defer r.stateMu.Unlock()
+	from := r.State
 	r.State = StateFailed
 	r.Error = errMsg
 	r.StoppedAt = timestamp
+	t := r.transitionLocked(from)
+	r.stateMu.Unlock()
+	telemetry.RunState(t)
+}
+
+// transitionLocked describes the change from state from for telemetry.
+// Callers hold stateMu.
+func (r *Run) transitionLocked(from State) telemetry.RunTransition {
+	return telemetry.RunTransition{
+		RunID:     r.ID,
+		Name:      r.Name,
+		Agent:     r.Agent,
+		From:      string(from),
+		To:        string(r.State),
+		Error:     r.Error,
+		CreatedAt: r.CreatedAt,
+		StoppedAt: r.StoppedAt,
+	}
 }
 
 // validateGrants checks that all requested grants have credentials available.
@@ -289,11 +437,20 @@ func (r *Run) SetStateFailedAt(errMsg string, timestamp time.Time) {
 //   - "mcp:*" / "mcp-*" — validated by validateMCPGrants
 //
 // For all other grants, we check that (1) the provider is registered and
-// (2) the credential exists and can be decrypted from the store.
-func validateGrants(grants []string, store *credential.FileStore) error {
+// (2) the credential exists, can be decrypted from the store, and has not
+// passed the expiry set by `moat grant --expires`.
+//
+// origins maps grants that came from a grant bundle to the bundle's name
+// (see expandGrantBundles); those grants are labelled with their bundle so
+// each failure still names the grant the user needs to fix.
+func validateGrants(grants []string, origins map[string]string, store *credential.FileStore) error {
 	var errs []string
 	for _, grant := range grants {
 		grantName := strings.Split(grant, ":")[0]
+		var from string
+		if bundle, ok := origins[grant]; ok {
+			from = " (from bundle " + bundle + ")"
+		}
 
 		// Skip grants validated by dedicated code paths. MCP grants accept
 		// both "mcp:<name>" (canonical) and "mcp-<name>" (deprecated) forms.
@@ -303,8 +460,8 @@ func validateGrants(grants []string, store *credential.FileStore) error {
 
 		// Check provider exists in registry (catches typos)
 		if provider.Get(grantName) == nil {
-			errs = append(errs, fmt.Sprintf("  - %s: unknown provider (available: %s)",
-				grantName, strings.Join(provider.Names(), ", ")))
+			errs = append(errs, fmt.Sprintf("  - %s%s: unknown provider (available: %s)",
+				grantName, from, strings.Join(provider.Names(), ", ")))
 			continue
 		}
 
@@ -312,27 +469,81 @@ func validateGrants(grants []string, store *credential.FileStore) error {
 		// "openai" → codex provider but credential stored under "openai").
 		credName := credentialStoreKey(grantName, grant)
 
-		// Check credential exists and can be decrypted
-		_, err := store.Get(credName)
+		// Check credential exists, can be decrypted, and has not expired
+		cred, err := store.Get(credName)
+		if err == nil && cred.GrantExpired(time.Now()) {
+			errs = append(errs, fmt.Sprintf("  - %s%s: expired at %s\n    Run: moat grant %s",
+				grant, from, cred.GrantExpiry().Local().Format(time.RFC1123), grantToCommand(grant)))
+			continue
+		}
 		if err != nil {
 			grantCmd := grantToCommand(grant)
 			switch {
 			case errors.Is(err, credential.ErrNotFound):
-				errs = append(errs, fmt.Sprintf("  - %s: not configured\n    Run: moat grant %s", grant, grantCmd))
+				errs = append(errs, fmt.Sprintf("  - %s%s: not configured\n    Run: moat grant %s", grant, from, grantCmd))
 			case errors.Is(err, credential.ErrDecrypt):
-				errs = append(errs, fmt.Sprintf("  - %s: encryption key changed\n    Run: moat grant %s", grant, grantCmd))
+				errs = append(errs, fmt.Sprintf("  - %s%s: encryption key changed\n    Run: moat grant %s", grant, from, grantCmd))
 			default:
-				errs = append(errs, fmt.Sprintf("  - %s: %v\n    Run: moat grant %s", grant, err, grantCmd))
+				errs = append(errs, fmt.Sprintf("  - %s%s: %v\n    Run: moat grant %s", grant, from, err, grantCmd))
 			}
 		}
 	}
 	if len(errs) > 0 {
-		return fmt.Errorf("missing grants:\n%s\n\nConfigure the grants above, then run again.",
-			strings.Join(errs, "\n"))
+		return errcode.Wrap(errcode.MissingGrants, fmt.Errorf("missing grants:\n%s\n\nConfigure the grants above, then run again.",
+			strings.Join(errs, "\n")))
+	}
+	return nil
+}
+
+// checkGrantRestrictions refuses grants whose credential is restricted to
+// other workspaces or repositories than workspace. Missing credentials are
+// left to validateGrants.
+func checkGrantRestrictions(grants []string, workspace string, store *credential.FileStore) error {
+	var errs []string
+	for _, grant := range grants {
+		grantName := strings.Split(grant, ":")[0]
+		if grantName == "ssh" {
+			continue
+		}
+		cred, err := store.Get(credentialStoreKey(grantName, grant))
+		if err != nil {
+			continue
+		}
+		if err := cred.Restrict.Check(workspace); err != nil {
+			errs = append(errs, fmt.Sprintf("  - %s: %v", grant, err))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("grants not available in workspace %s:\n%s\n\nRun from an allowed workspace, or grant again without --only-repo/--only-workspace.",
+			workspace, strings.Join(errs, "\n"))
 	}
 	return nil
 }
 
+// checkClaudeProviderGrant ensures the grant that authenticates
+// claude.provider is in the run's grants: aws for Bedrock, gcp for Vertex AI.
+func checkClaudeProviderGrant(cfg *config.Config, grants []string) error {
+	if cfg == nil {
+		return nil
+	}
+	var want string
+	switch cfg.Claude.Provider {
+	case claude.APIProviderBedrock:
+		want = "aws"
+	case claude.APIProviderVertex:
+		want = "gcp"
+	default:
+		return nil
+	}
+	for _, g := range grants {
+		if strings.Split(g, ":")[0] == want {
+			return nil
+		}
+	}
+	return errcode.Wrap(errcode.MissingGrants, fmt.Errorf("claude.provider: %s requires the %s grant\n\nRun: moat grant %s, then add %s to grants: in moat.yaml",
+		cfg.Claude.Provider, want, want, want))
+}
+
 // grantToCommand converts a grant name like "oauth:notion" or "mcp:context7"
 // to a CLI-friendly form suitable for use in "moat grant <args>" instructions.
 // Examples: "oauth:notion" → "oauth notion", "mcp:context7" → "mcp context7",
@@ -372,7 +583,15 @@ func validateMCPGrants(cfg *config.Config, store *credential.FileStore) error {
 			continue // No auth required (or no grant named)
 		}
 
-		_, err := store.Get(credential.Provider(mcp.Auth.Grant))
+		cred, err := store.Get(credential.Provider(mcp.Auth.Grant))
+		if err == nil && cred.GrantExpired(time.Now()) {
+			return fmt.Errorf(`MCP server '%s' requires grant '%s' but it expired at %s
+
+To fix:
+  moat grant %s
+
+Then run again.`, mcp.Name, mcp.Auth.Grant, cred.GrantExpiry().Local().Format(time.RFC1123), grantToCommand(mcp.Auth.Grant))
+		}
 		if err != nil {
 			return fmt.Errorf(`MCP server '%s' requires grant '%s' but it's not configured
 
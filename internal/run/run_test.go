@@ -315,7 +315,7 @@ func TestValidateGrants(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			err := validateGrants(tt.grants, store)
+			err := validateGrants(tt.grants, nil, store)
 			if tt.wantErr && err == nil {
 				t.Fatal("expected error, got nil")
 			}
@@ -338,7 +338,7 @@ func TestValidateGrantsErrorFormat(t *testing.T) {
 	rand.Read(key)
 	store, _ := credential.NewFileStore(credDir, key)
 
-	err := validateGrants([]string{"github"}, store)
+	err := validateGrants([]string{"github"}, nil, store)
 	if err == nil {
 		t.Fatal("expected error for missing github grant")
 	}
@@ -354,6 +354,58 @@ func TestValidateGrantsErrorFormat(t *testing.T) {
 	}
 }
 
+func TestValidateGrantsBundleOrigin(t *testing.T) {
+	key := make([]byte, 32)
+	rand.Read(key)
+	store, _ := credential.NewFileStore(t.TempDir(), key)
+
+	origins := map[string]string{"github": "web-dev", "bogusprov": "web-dev"}
+	err := validateGrants([]string{"github", "npm", "bogusprov"}, origins, store)
+	if err == nil {
+		t.Fatal("expected error for missing grants")
+	}
+	msg := err.Error()
+	for _, want := range []string{
+		"github (from bundle web-dev): not configured",
+		"npm: not configured",
+		"bogusprov (from bundle web-dev): unknown provider",
+		"moat grant github",
+	} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("error should contain %q, got: %s", want, msg)
+		}
+	}
+}
+
+func TestCheckGrantRestrictions(t *testing.T) {
+	key := make([]byte, 32)
+	rand.Read(key)
+	store, _ := credential.NewFileStore(t.TempDir(), key)
+
+	allowed := t.TempDir()
+	restrict, err := credential.NewRestriction(nil, []string{allowed})
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Save(credential.Credential{Provider: "github", Token: "ghp_test", Restrict: restrict})
+	store.Save(credential.Credential{Provider: "npm", Token: "npm_test"})
+
+	if err := checkGrantRestrictions([]string{"github", "npm"}, allowed, store); err != nil {
+		t.Errorf("allowed workspace: %v", err)
+	}
+	err = checkGrantRestrictions([]string{"github", "npm"}, t.TempDir(), store)
+	if err == nil {
+		t.Fatal("expected error for a restricted grant outside its workspace")
+	}
+	msg := err.Error()
+	if !strings.Contains(msg, "github: restricted to workspace "+restrict.Workspaces[0]) {
+		t.Errorf("error should name the grant and restriction, got: %s", msg)
+	}
+	if strings.Contains(msg, "npm") {
+		t.Errorf("unrestricted grant listed in error: %s", msg)
+	}
+}
+
 func TestValidateGrantsDecryptionFailure(t *testing.T) {
 	tmpDir := t.TempDir()
 	credDir := filepath.Join(tmpDir, "credentials")
@@ -374,7 +426,7 @@ func TestValidateGrantsDecryptionFailure(t *testing.T) {
 	rand.Read(key2)
 	store2, _ := credential.NewFileStore(credDir, key2)
 
-	err := validateGrants([]string{"github"}, store2)
+	err := validateGrants([]string{"github"}, nil, store2)
 	if err == nil {
 		t.Fatal("expected error for credential encrypted with different key")
 	}
@@ -389,6 +441,30 @@ func TestValidateGrantsDecryptionFailure(t *testing.T) {
 	}
 }
 
+func TestValidateGrantsExpired(t *testing.T) {
+	key := make([]byte, 32)
+	rand.Read(key)
+	store, _ := credential.NewFileStore(t.TempDir(), key)
+
+	cred := credential.Credential{Provider: "github", Token: "ghp_test", CreatedAt: time.Now()}
+	cred.SetGrantExpiry(time.Now().Add(-time.Minute))
+	store.Save(cred)
+
+	err := validateGrants([]string{"github"}, nil, store)
+	if err == nil {
+		t.Fatal("expected error for an expired grant")
+	}
+	if msg := err.Error(); !strings.Contains(msg, "github: expired at") || !strings.Contains(msg, "moat grant github") {
+		t.Errorf("error should name the expiry and fix command, got: %s", msg)
+	}
+
+	cred.SetGrantExpiry(time.Now().Add(time.Hour))
+	store.Save(cred)
+	if err := validateGrants([]string{"github"}, nil, store); err != nil {
+		t.Errorf("unexpired grant rejected: %v", err)
+	}
+}
+
 func TestValidateMCPGrants(t *testing.T) {
 	// Set up temporary credential store
 	tmpDir := t.TempDir()
@@ -667,3 +743,28 @@ func TestBuildProxyEnv_LoopbackNotBypassed(t *testing.T) {
 		t.Errorf("NO_PROXY must NOT contain 127.0.0.1 in host-network mode, got %q", noProxy)
 	}
 }
+
+func TestCheckClaudeProviderGrant(t *testing.T) {
+	tests := []struct {
+		provider string
+		grants   []string
+		wantErr  bool
+	}{
+		{"", nil, false},
+		{"anthropic", []string{"anthropic"}, false},
+		{"bedrock", []string{"aws"}, false},
+		{"bedrock", []string{"anthropic"}, true},
+		{"vertex", []string{"github", "gcp"}, false},
+		{"vertex", []string{"aws"}, true},
+	}
+	for _, tt := range tests {
+		cfg := &config.Config{Claude: config.ClaudeConfig{Provider: tt.provider}}
+		err := checkClaudeProviderGrant(cfg, tt.grants)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("provider %q, grants %v: err = %v, wantErr %v", tt.provider, tt.grants, err, tt.wantErr)
+		}
+	}
+	if err := checkClaudeProviderGrant(nil, nil); err != nil {
+		t.Errorf("nil config: %v", err)
+	}
+}
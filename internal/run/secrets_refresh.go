@@ -0,0 +1,166 @@
+package run
+
+// This file holds secret rotation for running containers (moat secrets
+// refresh).
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/majorcontext/moat/internal/audit"
+	"github.com/majorcontext/moat/internal/errcode"
+	"github.com/majorcontext/moat/internal/secrets"
+	"github.com/majorcontext/moat/internal/storage"
+)
+
+// SecretsDir is the directory in the container holding one file per secret,
+// named after its variable. moat-init writes the values the container was
+// created with; RefreshSecrets rewrites them.
+const SecretsDir = "/run/moat/secrets"
+
+// writeSecretScript replaces the file of secret $2 in directory $1 with
+// stdin. The rename keeps readers from seeing a partly written value.
+const writeSecretScript = `set -e
+umask 077
+mkdir -p "$1"
+cat > "$1/.$2.tmp"
+if [ "$(id -u)" = 0 ] && id moatuser >/dev/null 2>&1; then
+  chown moatuser:moatuser "$1" "$1/.$2.tmp"
+fi
+mv -f "$1/.$2.tmp" "$1/$2"`
+
+// secretsRefreshHookScript runs hooks.secrets_refresh, passed to the
+// container as MOAT_SECRETS_REFRESH, as moatuser in /workspace.
+const secretsRefreshHookScript = `[ -n "$MOAT_SECRETS_REFRESH" ] || exit 0
+cd /workspace
+if [ "$(id -u)" = 0 ] && id moatuser >/dev/null 2>&1; then
+  exec gosu moatuser sh -c "$MOAT_SECRETS_REFRESH"
+fi
+exec sh -c "$MOAT_SECRETS_REFRESH"`
+
+// SecretRefresh is the outcome of re-resolving one secret.
+type SecretRefresh struct {
+	Name    string `json:"name"`
+	Backend string `json:"backend"`
+	Changed bool   `json:"changed"`
+}
+
+// RefreshSecrets re-resolves the secrets runID was created with and writes
+// the values to SecretsDir in the running container. If any value changed,
+// the hooks.secrets_refresh command then runs so long-lived processes can
+// re-read them. The container's environment is not changed: processes keep
+// the values they started with unless they read the files. Each secret is
+// recorded in the run's audit log with whether it changed; values are
+// never stored.
+func (m *Manager) RefreshSecrets(ctx context.Context, runID string) ([]SecretRefresh, error) {
+	r, err := m.Get(runID)
+	if err != nil {
+		return nil, err
+	}
+	if state := r.GetState(); state != StateRunning {
+		return nil, errcode.Wrap(errcode.RunNotRunning, fmt.Errorf("run %s is not running (state: %s)", runID, state))
+	}
+	if r.Store == nil {
+		return nil, fmt.Errorf("run %s has no storage", runID)
+	}
+	manifest, err := r.Store.LoadConfigManifest()
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("run %s has no recorded configuration; it was created by an older moat version", runID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading run configuration: %w", err)
+	}
+	if len(manifest.Secrets) == 0 {
+		return nil, fmt.Errorf("run %s has no secrets", runID)
+	}
+
+	resolved, err := secrets.ResolveAll(ctx, manifest.Secrets)
+	if err != nil {
+		return nil, err
+	}
+	rt, err := m.runtimeForRun(r)
+	if err != nil {
+		return nil, fmt.Errorf("resolving runtime for run %s: %w", runID, err)
+	}
+
+	previous := secretDigests(r)
+	refreshed := make([]SecretRefresh, 0, len(resolved))
+	changed := false
+	for _, name := range slices.Sorted(maps.Keys(resolved)) {
+		var stderr bytes.Buffer
+		cmd := []string{"sh", "-c", writeSecretScript, "sh", SecretsDir, name}
+		if err := rt.Exec(ctx, r.ContainerID, cmd, []byte(resolved[name]), io.Discard, &stderr); err != nil {
+			return refreshed, fmt.Errorf("writing secret %s in the container: %w", name, execError(err, &stderr))
+		}
+		digest := envDigest(r.ID, name, resolved[name])
+		s := SecretRefresh{
+			Name:    name,
+			Backend: secrets.ParseScheme(manifest.Secrets[name]),
+			Changed: digest != previous[name],
+		}
+		changed = changed || s.Changed
+		refreshed = append(refreshed, s)
+
+		_ = r.Store.WriteSecretResolution(storage.SecretResolution{
+			Timestamp: time.Now().UTC(),
+			Name:      name,
+			Backend:   s.Backend,
+			Digest:    digest,
+		})
+		withAuditStore(r, "secret refresh", func(as *audit.Store) error {
+			_, err := as.AppendSecret(audit.SecretData{
+				Name:    name,
+				Backend: s.Backend,
+				Refresh: true,
+				Changed: s.Changed,
+			})
+			return err
+		})
+	}
+
+	if changed {
+		var output bytes.Buffer
+		cmd := []string{"sh", "-c", secretsRefreshHookScript}
+		if err := rt.Exec(ctx, r.ContainerID, cmd, nil, &output, &output); err != nil {
+			return refreshed, fmt.Errorf("secrets_refresh hook failed: %w", execError(err, &output))
+		}
+	}
+	return refreshed, nil
+}
+
+// secretDigests returns the digest of the latest value of each of r's
+// secrets: from the last refresh, or else from the environment the container
+// was created with.
+func secretDigests(r *Run) map[string]string {
+	digests := make(map[string]string)
+	if env, err := r.Store.LoadEnvSnapshot(); err == nil {
+		for _, v := range env {
+			if strings.HasPrefix(v.Source, "secret") {
+				digests[v.Name] = v.Digest
+			}
+		}
+	}
+	resolutions, _ := r.Store.ReadSecretResolutions()
+	for _, res := range resolutions {
+		if res.Digest != "" {
+			digests[res.Name] = res.Digest
+		}
+	}
+	return digests
+}
+
+// execError adds the command's output, if any, to err.
+func execError(err error, output *bytes.Buffer) error {
+	if msg := strings.TrimSpace(output.String()); msg != "" {
+		return fmt.Errorf("%w: %s", err, msg)
+	}
+	return err
+}
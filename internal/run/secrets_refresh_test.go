@@ -0,0 +1,139 @@
+package run
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/majorcontext/moat/internal/audit"
+	"github.com/majorcontext/moat/internal/container"
+	"github.com/majorcontext/moat/internal/storage"
+)
+
+// execRecorder is a stubRuntime that records Exec calls.
+type execRecorder struct {
+	*stubRuntime
+	cmds   [][]string
+	stdins []string
+}
+
+func (e *execRecorder) Exec(_ context.Context, _ string, cmd []string, stdin []byte, _, _ io.Writer) error {
+	e.cmds = append(e.cmds, cmd)
+	e.stdins = append(e.stdins, string(stdin))
+	return nil
+}
+
+func TestManagerRefreshSecrets(t *testing.T) {
+	t.Setenv("MOAT_TEST_DB_PASSWORD", "old")
+	t.Setenv("MOAT_TEST_API_KEY", "key")
+
+	store, err := storage.NewRunStore(t.TempDir(), "run_secrets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SaveConfigManifest(storage.ConfigManifest{Secrets: map[string]string{
+		"DB_PASSWORD": "env://MOAT_TEST_DB_PASSWORD",
+		"API_KEY":     "env://MOAT_TEST_API_KEY",
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	// The container was created with the current values.
+	recordEnvSnapshot(store, "run_secrets",
+		[]string{"DB_PASSWORD=old", "API_KEY=key"},
+		envSources{"DB_PASSWORD": "secret env", "API_KEY": "secret env"})
+
+	rt := &execRecorder{stubRuntime: &stubRuntime{}}
+	r := &Run{ID: "run_secrets", State: StateRunning, Store: store, ContainerID: "c1"}
+	m := &Manager{
+		runs:        map[string]*Run{r.ID: r},
+		runtimePool: container.NewRuntimePoolWithDefault(rt),
+	}
+
+	refreshed, err := m.RefreshSecrets(context.Background(), r.ID)
+	if err != nil {
+		t.Fatalf("RefreshSecrets: %v", err)
+	}
+	for _, s := range refreshed {
+		if s.Changed {
+			t.Errorf("%s changed, want unchanged", s.Name)
+		}
+	}
+	// One write per secret and no hook, since nothing changed.
+	if len(rt.cmds) != 2 {
+		t.Fatalf("exec calls = %d, want 2", len(rt.cmds))
+	}
+	if got := rt.cmds[0][len(rt.cmds[0])-2:]; !slices.Equal(got, []string{SecretsDir, "API_KEY"}) || rt.stdins[0] != "key" {
+		t.Errorf("first write = %v with %q, want API_KEY with its value", got, rt.stdins[0])
+	}
+
+	// Rotate one secret.
+	t.Setenv("MOAT_TEST_DB_PASSWORD", "new")
+	rt.cmds, rt.stdins = nil, nil
+	refreshed, err = m.RefreshSecrets(context.Background(), r.ID)
+	if err != nil {
+		t.Fatalf("RefreshSecrets: %v", err)
+	}
+	want := []SecretRefresh{
+		{Name: "API_KEY", Backend: "env", Changed: false},
+		{Name: "DB_PASSWORD", Backend: "env", Changed: true},
+	}
+	if !slices.Equal(refreshed, want) {
+		t.Errorf("refreshed = %v, want %v", refreshed, want)
+	}
+	if len(rt.cmds) != 3 || rt.stdins[1] != "new" || !strings.Contains(rt.cmds[2][2], "MOAT_SECRETS_REFRESH") {
+		t.Errorf("exec calls = %v, want two writes and the refresh hook", rt.cmds)
+	}
+
+	// A second refresh compares against the refreshed value.
+	refreshed, err = m.RefreshSecrets(context.Background(), r.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if refreshed[1].Changed {
+		t.Error("DB_PASSWORD reported changed again")
+	}
+
+	// The audit log records refreshes without values.
+	as, err := audit.OpenStore(filepath.Join(store.Dir(), "audit.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer as.Close()
+	entries, err := as.Range(1, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var changed []string
+	for _, e := range entries {
+		data, _ := e.Data.(map[string]any)
+		if e.Type == audit.EntrySecret && data["changed"] == true {
+			changed = append(changed, data["name"].(string))
+		}
+	}
+	if !slices.Equal(changed, []string{"DB_PASSWORD"}) {
+		t.Errorf("changed secrets in audit log = %v, want [DB_PASSWORD]", changed)
+	}
+}
+
+func TestManagerRefreshSecrets_NoSecrets(t *testing.T) {
+	store, err := storage.NewRunStore(t.TempDir(), "run_nosecrets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SaveConfigManifest(storage.ConfigManifest{}); err != nil {
+		t.Fatal(err)
+	}
+	r := &Run{ID: "run_nosecrets", State: StateRunning, Store: store}
+	m := &Manager{runs: map[string]*Run{r.ID: r}}
+	if _, err := m.RefreshSecrets(context.Background(), r.ID); err == nil || !strings.Contains(err.Error(), "no secrets") {
+		t.Errorf("err = %v, want no secrets error", err)
+	}
+
+	r.State = StateStopped
+	if _, err := m.RefreshSecrets(context.Background(), r.ID); err == nil || !strings.Contains(err.Error(), "not running") {
+		t.Errorf("err = %v, want not running error", err)
+	}
+}
@@ -81,13 +81,7 @@ func generateServiceEnv(def *deps.ServiceDef, info container.ServiceInfo, userSp
 	}
 
 	// Password
-	password := ""
-	if def.PasswordEnv != "" {
-		password = info.Env[def.PasswordEnv]
-	}
-	if password == "" {
-		password = info.Env["password"]
-	}
+	password := servicePassword(def, info)
 	if password != "" {
 		env[prefix+"_PASSWORD"] = password
 	}
@@ -111,6 +105,88 @@ func generateServiceEnv(def *deps.ServiceDef, info container.ServiceInfo, userSp
 	return env
 }
 
+// servicePassword returns the password generated for a started service, or
+// "" for services without auth.
+func servicePassword(def *deps.ServiceDef, info container.ServiceInfo) string {
+	if def.PasswordEnv != "" {
+		if pw := info.Env[def.PasswordEnv]; pw != "" {
+			return pw
+		}
+	}
+	return info.Env["password"]
+}
+
+// PublishedService is a service dependency served through the routing proxy.
+type PublishedService struct {
+	// Addr is the host:port the route points at.
+	Addr string
+	// Path is appended to the endpoint URL, e.g. a login token query.
+	Path string
+}
+
+// publishService returns how the routing proxy serves a started service
+// whose definition sets publish, and false if its default port was not
+// published.
+func publishService(def *deps.ServiceDef, info container.ServiceInfo) (PublishedService, bool) {
+	addr, ok := info.Published[def.Ports["default"]]
+	if !ok {
+		return PublishedService{}, false
+	}
+	path := strings.ReplaceAll(def.PublishPath, "{password}", servicePassword(def, info))
+	return PublishedService{Addr: addr, Path: path}, true
+}
+
+// routingProxyPort returns the routing proxy port from the global config,
+// which the MOAT_HOST_* and MOAT_URL_* variables name.
+func routingProxyPort() int {
+	if globalCfg, err := config.LoadGlobal(); err == nil {
+		return globalCfg.Proxy.Port
+	}
+	return config.DefaultGlobalConfig().Proxy.Port
+}
+
+// publishedServiceEnv returns the MOAT_HOST_<NAME> and MOAT_URL_<NAME>
+// variables for a published service, as for a ports: endpoint.
+func publishedServiceEnv(name, endpointHost string, svc PublishedService) map[string]string {
+	upper := strings.ToUpper(name)
+	return map[string]string{
+		"MOAT_HOST_" + upper: endpointHost,
+		"MOAT_URL_" + upper:  "http://" + endpointHost + svc.Path,
+	}
+}
+
+// serviceAccessRules returns the rules limiting the agent's connections to
+// the run's started services, from each service's network block. A service
+// the agent may reach on every port needs no rule.
+func serviceAccessRules(cfg *config.Config, infos []container.ServiceInfo) []container.ServiceAccess {
+	if cfg == nil {
+		return nil
+	}
+	var rules []container.ServiceAccess
+	for _, info := range infos {
+		spec, ok := cfg.Services[info.Name]
+		if !ok {
+			continue
+		}
+		ports, allowed := spec.AgentAccess()
+		if allowed && len(ports) == 0 {
+			continue
+		}
+		rules = append(rules, container.ServiceAccess{Service: info.Name, Host: info.Host, Ports: ports})
+	}
+	return rules
+}
+
+// serviceEgress reports whether the named service may connect outside the
+// run.
+func serviceEgress(cfg *config.Config, name string) bool {
+	if cfg == nil {
+		return true
+	}
+	spec, ok := cfg.Services[name]
+	return !ok || spec.Egress()
+}
+
 // serviceUsesPasswordPlaceholder reports whether a service's extra_cmd or
 // readiness_cmd contains the {password} placeholder, indicating it needs a
 // generated password even when password_env is empty (e.g., Redis).
@@ -227,6 +303,11 @@ func buildServiceConfig(dep deps.Dependency, runID string, userSpec *config.Serv
 		memoryMB = userSpec.Memory
 	}
 
+	var publish []int
+	if port, ok := spec.Service.Ports["default"]; ok && spec.Service.Publish {
+		publish = []int{port}
+	}
+
 	// Fall back to the registry default when no version was specified
 	// (e.g. "ministack" rather than "ministack@latest"). Without this the
 	// image reference is built as "repo:" with an empty tag, which the
@@ -252,6 +333,7 @@ func buildServiceConfig(dep deps.Dependency, runID string, userSpec *config.Serv
 		Provisions:    provisions,
 		ProvisionCmd:  spec.Service.ProvisionCmd,
 		MemoryMB:      memoryMB,
+		Publish:       publish,
 	}, nil
 }
 
@@ -311,7 +393,7 @@ func provisionItem(ctx context.Context, mgr container.ServiceManager, info conta
 	// Acquire/release lock per-item to avoid holding it across the full batch.
 	if lockFile != nil {
 		if err := flockContext(cmdCtx, lockFile); err != nil {
-			return err
+			return fmt.Errorf("acquiring cache lock: %w", err)
 		}
 		defer func() { _ = syscall.Flock(int(lockFile.Fd()), syscall.LOCK_UN) }()
 	}
@@ -335,11 +417,11 @@ func flockContext(ctx context.Context, f *os.File) error {
 	select {
 	case err := <-done:
 		if err != nil {
-			return fmt.Errorf("acquiring cache lock: %w", err)
+			return err
 		}
 		return nil
 	case <-ctx.Done():
-		return fmt.Errorf("acquiring cache lock: %w", ctx.Err())
+		return ctx.Err()
 	}
 }
 
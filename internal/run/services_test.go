@@ -383,3 +383,76 @@ func TestGenerateServiceEnvOllama(t *testing.T) {
 	_, hasDB := env["MOAT_OLLAMA_DB"]
 	assert.False(t, hasDB)
 }
+
+func TestBuildServiceConfigJupyter(t *testing.T) {
+	cfg, err := buildServiceConfig(deps.Dependency{Name: "jupyter"}, "run_1", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "quay.io/jupyter/scipy-notebook", cfg.Image)
+	assert.Equal(t, "python-3.12", cfg.Version)
+	assert.Equal(t, []int{8888}, cfg.Publish)
+	assert.Len(t, cfg.Env["JUPYTER_TOKEN"], passwordLength)
+}
+
+func TestBuildServiceConfigUnpublished(t *testing.T) {
+	cfg, err := buildServiceConfig(deps.Dependency{Name: "postgres", Version: "17"}, "run_1", nil)
+	require.NoError(t, err)
+	assert.Empty(t, cfg.Publish)
+}
+
+func TestPublishServiceJupyter(t *testing.T) {
+	spec, ok := deps.GetSpec("jupyter")
+	require.True(t, ok)
+
+	info := container.ServiceInfo{
+		Name:      "jupyter",
+		Host:      "jupyter",
+		Ports:     map[string]int{"default": 8888},
+		Env:       map[string]string{"JUPYTER_TOKEN": "tok123"},
+		Published: map[int]string{8888: "127.0.0.1:49153"},
+	}
+
+	svc, ok := publishService(spec.Service, info)
+	require.True(t, ok)
+	assert.Equal(t, PublishedService{Addr: "127.0.0.1:49153", Path: "/lab?token=tok123"}, svc)
+
+	env := publishedServiceEnv("jupyter", "jupyter.my-agent.localhost:8080", svc)
+	assert.Equal(t, "jupyter.my-agent.localhost:8080", env["MOAT_HOST_JUPYTER"])
+	assert.Equal(t, "http://jupyter.my-agent.localhost:8080/lab?token=tok123", env["MOAT_URL_JUPYTER"])
+
+	// The in-network URL lets the agent reach the server directly.
+	svcEnv := generateServiceEnv(spec.Service, info, nil)
+	assert.Equal(t, "http://jupyter:8888/lab?token=tok123", svcEnv["MOAT_JUPYTER_URL"])
+	assert.Equal(t, "tok123", svcEnv["MOAT_JUPYTER_PASSWORD"])
+
+	// Without a published port there is nothing to route to.
+	info.Published = nil
+	_, ok = publishService(spec.Service, info)
+	assert.False(t, ok)
+}
+
+func TestServiceAccessRules(t *testing.T) {
+	no := false
+	cfg := &config.Config{Services: map[string]config.ServiceSpec{
+		"postgres": {Network: &config.ServiceNetworkConfig{Ports: []int{5432}}},
+		"redis":    {Network: &config.ServiceNetworkConfig{Agent: &no}},
+		"ollama":   {Network: &config.ServiceNetworkConfig{Egress: &no}},
+	}}
+	infos := []container.ServiceInfo{
+		{Name: "postgres", Host: "postgres"},
+		{Name: "redis", Host: "192.168.64.9"},
+		{Name: "ollama", Host: "ollama"},
+		{Name: "mysql", Host: "mysql"},
+	}
+
+	// ollama and mysql are reachable on every port, so need no rule.
+	assert.Equal(t, []container.ServiceAccess{
+		{Service: "postgres", Host: "postgres", Ports: []int{5432}},
+		{Service: "redis", Host: "192.168.64.9"},
+	}, serviceAccessRules(cfg, infos))
+	assert.Nil(t, serviceAccessRules(nil, infos))
+
+	assert.False(t, serviceEgress(cfg, "ollama"))
+	assert.True(t, serviceEgress(cfg, "postgres"))
+	assert.True(t, serviceEgress(cfg, "mysql"))
+	assert.True(t, serviceEgress(nil, "ollama"))
+}
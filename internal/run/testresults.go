@@ -0,0 +1,47 @@
+package run
+
+import (
+	"math"
+
+	"github.com/majorcontext/moat/internal/log"
+	"github.com/majorcontext/moat/internal/testresult"
+)
+
+// recordTestResults scans the run's captured logs for test runner output and
+// stores the parsed pass/fail counts on r.TestResults. It must run after
+// captureLogs so the log file is complete, and before SaveMetadata so the
+// summary is persisted. Recomputing is harmless, so it needs no idempotency
+// guard of its own.
+func recordTestResults(r *Run) {
+	if r.Store == nil {
+		return
+	}
+	entries, err := r.Store.ReadLogs(0, math.MaxInt)
+	if err != nil {
+		log.Debug("failed to read logs for test results", "runID", r.ID, "error", err)
+		return
+	}
+	var p testresult.Parser
+	for _, e := range entries {
+		p.Add(e.Line)
+	}
+	summary := p.Summary()
+	if summary == nil {
+		return
+	}
+	log.Debug("parsed test results", "runID", r.ID, "frameworks", summary.Frameworks,
+		"passed", summary.Passed, "failed", summary.Failed, "skipped", summary.Skipped)
+
+	// TestResults is guarded by stateMu — SaveMetadata may read it
+	// concurrently from monitorContainerExit/Stop.
+	r.stateMu.Lock()
+	r.TestResults = summary
+	r.stateMu.Unlock()
+}
+
+// GetTestResults safely reads the run's parsed test results (thread-safe).
+func (r *Run) GetTestResults() *testresult.Summary {
+	r.stateMu.Lock()
+	defer r.stateMu.Unlock()
+	return r.TestResults
+}
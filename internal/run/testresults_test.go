@@ -0,0 +1,63 @@
+package run
+
+import (
+	"testing"
+
+	"github.com/majorcontext/moat/internal/storage"
+)
+
+func TestRecordTestResults(t *testing.T) {
+	store, err := storage.NewRunStore(t.TempDir(), "run_aaaaaaaaaaaa")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lw, err := store.LogWriter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = lw.Write([]byte("--- PASS: TestA (0.00s)\n--- FAIL: TestB (0.00s)\n"))
+	lw.Close()
+
+	r := &Run{ID: "run_aaaaaaaaaaaa", Store: store}
+	recordTestResults(r)
+
+	got := r.GetTestResults()
+	if got == nil {
+		t.Fatal("TestResults not set after recordTestResults")
+	}
+	if got.Passed != 1 || got.Failed != 1 {
+		t.Errorf("TestResults = %+v, want 1 passed, 1 failed", got)
+	}
+
+	// The summary must survive a metadata round-trip so moat list sees it
+	// after the CLI that ran the agent exits.
+	if err := r.SaveMetadata(); err != nil {
+		t.Fatal(err)
+	}
+	meta, err := store.LoadMetadata()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.TestResults == nil || meta.TestResults.Failed != 1 {
+		t.Errorf("persisted TestResults = %+v, want 1 failed", meta.TestResults)
+	}
+}
+
+func TestRecordTestResultsNoTestOutput(t *testing.T) {
+	store, err := storage.NewRunStore(t.TempDir(), "run_bbbbbbbbbbbb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lw, err := store.LogWriter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = lw.Write([]byte("hello world\n"))
+	lw.Close()
+
+	r := &Run{ID: "run_bbbbbbbbbbbb", Store: store}
+	recordTestResults(r)
+	if got := r.GetTestResults(); got != nil {
+		t.Errorf("TestResults = %+v, want nil without test output", got)
+	}
+}
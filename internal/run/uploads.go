@@ -0,0 +1,51 @@
+package run
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/majorcontext/moat/internal/storage"
+)
+
+// UploadViolationSummary aggregates the requests the proxy refused because
+// their bodies exceeded the run's upload caps (network.uploads). Built from
+// the run's network log by SummarizeUploadViolations.
+type UploadViolationSummary struct {
+	Violations int      `json:"violations"` // requests over their host's cap
+	Hosts      []string `json:"hosts"`      // hosts with a violation, in order of first violation
+	Largest    int64    `json:"largest"`    // largest declared body size, in bytes; 0 if none was declared
+}
+
+// String renders the hosts and the largest body, e.g.
+// "uploads.example.com (largest 112.0 MB)".
+func (s UploadViolationSummary) String() string {
+	hosts := strings.Join(s.Hosts, ", ")
+	if s.Largest == 0 {
+		return hosts
+	}
+	return fmt.Sprintf("%s (largest %.1f MB)", hosts, float64(s.Largest)/(1<<20))
+}
+
+// SummarizeUploadViolations counts the upload cap violations in a run's
+// network log.
+func SummarizeUploadViolations(reqs []storage.NetworkRequest) UploadViolationSummary {
+	var s UploadViolationSummary
+	for _, req := range reqs {
+		if req.UploadLimit == 0 {
+			continue
+		}
+		u, err := url.Parse(req.URL)
+		if err != nil {
+			continue
+		}
+		host := strings.ToLower(u.Hostname())
+		s.Violations++
+		s.Largest = max(s.Largest, req.RequestSize)
+		if !slices.Contains(s.Hosts, host) {
+			s.Hosts = append(s.Hosts, host)
+		}
+	}
+	return s
+}
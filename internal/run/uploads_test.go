@@ -0,0 +1,35 @@
+package run
+
+import (
+	"testing"
+
+	"github.com/majorcontext/moat/internal/storage"
+)
+
+func TestSummarizeUploadViolations(t *testing.T) {
+	reqs := []storage.NetworkRequest{
+		{URL: "https://paste.example.com/upload", Method: "POST", RequestSize: 1 << 20},
+		{URL: "https://paste.example.com/upload", Method: "POST", Denied: true},
+		{URL: "https://paste.example.com/upload", Method: "POST", RequestSize: 30 << 20, Denied: true, UploadLimit: 25 << 20},
+		// A chunked body cut off at the cap has no declared size.
+		{URL: "https://PASTE.example.com/other", Method: "POST", Denied: true, UploadLimit: 25 << 20},
+		{URL: "https://files.example.org/put", Method: "PUT", RequestSize: 40 << 20, Denied: true, UploadLimit: 25 << 20},
+		{URL: "https://api.github.com/user", Method: "GET", Denied: true},
+	}
+	s := SummarizeUploadViolations(reqs)
+	if s.Violations != 3 || s.Largest != 40<<20 {
+		t.Errorf("summary = %+v, want 3 violations, largest %d", s, 40<<20)
+	}
+	if want := "paste.example.com, files.example.org (largest 40.0 MB)"; s.String() != want {
+		t.Errorf("String() = %q, want %q", s.String(), want)
+	}
+}
+
+func TestSummarizeUploadViolationsClean(t *testing.T) {
+	s := SummarizeUploadViolations([]storage.NetworkRequest{
+		{URL: "https://api.github.com/user", Method: "POST", RequestSize: 100},
+	})
+	if s.Violations != 0 {
+		t.Errorf("Violations = %d, want 0", s.Violations)
+	}
+}
@@ -28,11 +28,12 @@ func VolumeWorkspaceMounts(hostWorkspace, volumeName string) []container.MountCo
 	}
 }
 
-// GuardVolumeWorkspace rejects volume mode when it cannot work: non-Docker
-// runtime, or a git worktree/submodule (.git is a file, not a directory).
+// GuardVolumeWorkspace rejects volume mode when it cannot work: a runtime
+// without named volumes (Apple), or a git worktree/submodule (.git is a file,
+// not a directory).
 func GuardVolumeWorkspace(hostWorkspace string, rt container.RuntimeType) error {
-	if rt != container.RuntimeDocker {
-		return fmt.Errorf("volume mode requires the Docker runtime; set workspace.mode: bind or run with --runtime docker")
+	if rt != container.RuntimeDocker && rt != container.RuntimePodman {
+		return fmt.Errorf("volume mode requires the Docker or Podman runtime; set workspace.mode: bind or run with --runtime docker")
 	}
 	if info, err := os.Lstat(filepath.Join(hostWorkspace, ".git")); err == nil && !info.IsDir() {
 		return fmt.Errorf("volume mode does not support git worktrees or submodules (.git is a file at %s/.git); use the main checkout or workspace.mode: bind", hostWorkspace)
@@ -0,0 +1,129 @@
+package run
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/majorcontext/moat/internal/config"
+	"github.com/majorcontext/moat/internal/container"
+	"github.com/majorcontext/moat/internal/log"
+	"github.com/majorcontext/moat/internal/storage"
+	"github.com/majorcontext/moat/internal/ui"
+	"github.com/majorcontext/moat/internal/worktree"
+)
+
+// workspaceRepo is a resolved entry of the workspaces: list.
+type workspaceRepo struct {
+	storage.WorkspaceRepo
+	gitDir   string // git directory as seen in the container
+	identity config.GitIdentity
+}
+
+// resolveWorkspaceRepos resolves the workspaces: list into mounts under
+// /workspace. Relative paths are resolved against workspace. An entry with a
+// worktree branch is mounted from a moat-managed worktree of the repository,
+// created if needed. As for the primary workspace, the main git directory of
+// a worktree is mounted at its host path so the worktree's .git file
+// resolves in the container.
+func resolveWorkspaceRepos(entries []config.WorkspaceRepo, workspace, runName string, prov worktree.Provision, readOnly bool) ([]workspaceRepo, []container.MountConfig, error) {
+	var repos []workspaceRepo
+	var mounts []container.MountConfig
+	for _, e := range entries {
+		source := e.Path
+		if rest, ok := strings.CutPrefix(source, "~/"); ok {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return nil, nil, err
+			}
+			source = filepath.Join(home, rest)
+		} else if !filepath.IsAbs(source) {
+			source = filepath.Join(workspace, source)
+		}
+		if info, err := os.Stat(source); err != nil || !info.IsDir() {
+			return nil, nil, fmt.Errorf("workspaces: %s is not a directory", source)
+		}
+
+		repo := workspaceRepo{
+			WorkspaceRepo: storage.WorkspaceRepo{Target: e.ContainerTarget(), Source: source},
+			identity:      e.Git,
+		}
+		if e.Worktree != "" {
+			repoRoot, err := worktree.FindRepoRoot(source)
+			if err != nil {
+				return nil, nil, fmt.Errorf("workspaces: %s: worktree requires a git repository: %w", e.Path, err)
+			}
+			repoID, err := worktree.ResolveRepoID(repoRoot)
+			if err != nil {
+				return nil, nil, fmt.Errorf("workspaces: %s: resolving repo identity: %w", e.Path, err)
+			}
+			result, err := worktree.Resolve(repoRoot, repoID, e.Worktree, runName, prov)
+			if err != nil {
+				return nil, nil, fmt.Errorf("workspaces: %s: resolving worktree: %w", e.Path, err)
+			}
+			for _, w := range result.Warnings {
+				ui.Warnf("%s worktree: %s", e.Worktree, w)
+			}
+			repo.Source = result.WorkspacePath
+			repo.Branch = e.Worktree
+			repo.Worktree = true
+		} else if branch, err := worktree.CurrentBranch(source); err == nil {
+			repo.Branch = branch
+		}
+
+		mounts = append(mounts, container.MountConfig{
+			Source:   repo.Source,
+			Target:   repo.Target,
+			ReadOnly: e.ReadOnly || readOnly,
+		})
+		repo.gitDir = repo.Target
+		if info, err := worktree.ResolveGitDir(repo.Source); err != nil {
+			log.Debug("failed to resolve worktree git dir", "path", repo.Source, "error", err)
+		} else if info != nil {
+			mounts = append(mounts, container.MountConfig{
+				Source:   info.MainGitDir,
+				Target:   info.MainGitDir,
+				ReadOnly: e.ReadOnly || readOnly,
+			})
+			repo.gitDir = info.WorktreeGitDir
+		}
+		log.Debug("added workspace repo mount", "source", repo.Source, "target", repo.Target, "branch", repo.Branch)
+		repos = append(repos, repo)
+	}
+	return repos, mounts, nil
+}
+
+// workspaceReposGitEnv returns MOAT_GIT_REPOS for moat-init.sh: a line per
+// repository with its container path, the git directory an includeIf
+// matches, and the identity to commit with, separated by \x1f. The identity
+// is the workspaces: entry's git block, falling back field by field to the
+// repository's own identity on the host.
+func workspaceReposGitEnv(repos []workspaceRepo) []string {
+	if len(repos) == 0 {
+		return nil
+	}
+	lines := make([]string, 0, len(repos))
+	for _, r := range repos {
+		name, email := r.identity.Name, r.identity.Email
+		if name == "" {
+			name = repoGitConfig(r.Source, "user.name")
+		}
+		if email == "" {
+			email = repoGitConfig(r.Source, "user.email")
+		}
+		lines = append(lines, strings.Join([]string{r.Target, strings.TrimSuffix(r.gitDir, "/") + "/", name, email}, "\x1f"))
+	}
+	return []string{"MOAT_GIT_REPOS=" + strings.Join(lines, "\n")}
+}
+
+// repoGitConfig returns a git config value as the repository at dir sees
+// it, including its local config and includes.
+func repoGitConfig(dir, key string) string {
+	out, err := exec.Command("git", "-C", dir, "config", key).Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
@@ -0,0 +1,94 @@
+package run
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/majorcontext/moat/internal/config"
+	"github.com/majorcontext/moat/internal/worktree"
+)
+
+// initRepo creates a git repository with one commit at dir.
+func initRepo(t *testing.T, dir string) {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"config", "user.name", "Repo User"},
+		{"config", "user.email", "repo@example.com"},
+		{"commit", "-q", "--allow-empty", "-m", "init"},
+	} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+}
+
+func TestResolveWorkspaceRepos(t *testing.T) {
+	root, _ := filepath.EvalSymlinks(t.TempDir())
+	initRepo(t, filepath.Join(root, "api"))
+	initRepo(t, filepath.Join(root, "web"))
+	t.Setenv("MOAT_WORKTREE_BASE", t.TempDir())
+
+	entries := []config.WorkspaceRepo{
+		{Path: "api", Git: config.GitIdentity{Email: "me@work.example"}},
+		{Path: "web", Target: "frontend", Worktree: "feature", ReadOnly: true},
+	}
+	repos, mounts, err := resolveWorkspaceRepos(entries, root, "myapp", worktree.Provision{}, false)
+	if err != nil {
+		t.Fatalf("resolveWorkspaceRepos() = %v", err)
+	}
+	if len(repos) != 2 {
+		t.Fatalf("got %d repos, want 2", len(repos))
+	}
+
+	api := repos[0]
+	if api.Target != "/workspace/api" || api.Source != filepath.Join(root, "api") || api.Worktree {
+		t.Errorf("api = %+v", api.WorkspaceRepo)
+	}
+	web := repos[1]
+	if web.Target != "/workspace/frontend" || !web.Worktree || web.Branch != "feature" {
+		t.Errorf("web = %+v", web.WorkspaceRepo)
+	}
+	if !strings.HasPrefix(web.Source, worktree.BasePath()) {
+		t.Errorf("web source = %s, want a worktree under %s", web.Source, worktree.BasePath())
+	}
+
+	// api, web's worktree, and web's main git dir.
+	if len(mounts) != 3 {
+		t.Fatalf("mounts = %+v, want 3", mounts)
+	}
+	if mounts[1].Target != "/workspace/frontend" || !mounts[1].ReadOnly {
+		t.Errorf("web mount = %+v, want read-only at /workspace/frontend", mounts[1])
+	}
+	if mounts[2].Source != filepath.Join(root, "web", ".git") || mounts[2].Target != mounts[2].Source {
+		t.Errorf("git dir mount = %+v, want web/.git at its host path", mounts[2])
+	}
+
+	env := workspaceReposGitEnv(repos)
+	if len(env) != 1 {
+		t.Fatalf("env = %v", env)
+	}
+	lines := strings.Split(strings.TrimPrefix(env[0], "MOAT_GIT_REPOS="), "\n")
+	if got, want := lines[0], "/workspace/api\x1f/workspace/api/\x1fRepo User\x1fme@work.example"; got != want {
+		t.Errorf("api line = %q, want %q", got, want)
+	}
+	fields := strings.Split(lines[1], "\x1f")
+	if fields[0] != "/workspace/frontend" || !strings.Contains(fields[1], filepath.Join("web", ".git", "worktrees")) {
+		t.Errorf("web line = %q, want its worktree git dir", lines[1])
+	}
+}
+
+func TestResolveWorkspaceRepos_MissingPath(t *testing.T) {
+	_, _, err := resolveWorkspaceRepos([]config.WorkspaceRepo{{Path: "nope"}}, t.TempDir(), "myapp", worktree.Provision{}, false)
+	if err == nil || !strings.Contains(err.Error(), "not a directory") {
+		t.Errorf("resolveWorkspaceRepos() = %v, want not a directory error", err)
+	}
+}
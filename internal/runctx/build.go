@@ -16,6 +16,13 @@ var grantDescriptions = map[string]string{
 	"openai":    "OpenAI API access via proxy.",
 	"gemini":    "Google Gemini API access via proxy.",
 	"aws":       "AWS credentials via IAM role assumption.",
+	"azure":     "Azure tokens via a managed identity endpoint (`az login --identity`, Azure SDKs).",
+	"snowflake": "Snowflake SQL API access via proxy (key-pair JWT). Use `$SNOWFLAKE_HOST/api/v2/statements`.",
+	"bigquery":  "BigQuery API access via proxy.",
+	"gcp":       "Google Cloud tokens via an emulated GCE metadata server (google-auth for Python).",
+	"stripe":    "Stripe API access via proxy. `STRIPE_API_KEY` is a placeholder; its prefix shows test or live mode.",
+	"twilio":    "Twilio API access via proxy. SMS and calls count against the run's message cap.",
+	"sendgrid":  "SendGrid API access via proxy. Email sends count against the run's message cap.",
 	"telegram":  "Telegram Bot API access.",
 }
 
@@ -0,0 +1,48 @@
+package secrets
+
+import (
+	"context"
+	"sync"
+)
+
+// cacheKey is the context key for a runCache.
+type cacheKey struct{}
+
+// runCache memoizes backend lookups during one ResolveAll call, so a run
+// that references the same secret twice, or several fields of one Vault or
+// Doppler secret, queries the backend once. Values never outlive the call.
+type runCache struct {
+	mu     sync.Mutex
+	values map[string]any
+}
+
+// withRunCache returns a context carrying a new, empty runCache.
+func withRunCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, cacheKey{}, &runCache{values: make(map[string]any)})
+}
+
+// cached returns the value stored under key in ctx's runCache, calling fetch
+// and storing its result on a miss. Errors are not cached. Without a cache in
+// ctx, fetch is called every time.
+func cached[T any](ctx context.Context, key string, fetch func() (T, error)) (T, error) {
+	c, _ := ctx.Value(cacheKey{}).(*runCache)
+	if c == nil {
+		return fetch()
+	}
+
+	c.mu.Lock()
+	v, ok := c.values[key]
+	c.mu.Unlock()
+	if ok {
+		return v.(T), nil
+	}
+
+	val, err := fetch()
+	if err != nil {
+		return val, err
+	}
+	c.mu.Lock()
+	c.values[key] = val
+	c.mu.Unlock()
+	return val, nil
+}
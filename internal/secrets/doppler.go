@@ -0,0 +1,133 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os/exec"
+	"strings"
+)
+
+// DopplerResolver resolves secrets from Doppler using the doppler CLI.
+type DopplerResolver struct{}
+
+// Scheme returns "doppler".
+func (r *DopplerResolver) Scheme() string {
+	return "doppler"
+}
+
+// Resolve fetches a secret using `doppler secrets download`.
+// Reference format: doppler://PROJECT/CONFIG/NAME, e.g. doppler://backend/prd/DATABASE_URL.
+func (r *DopplerResolver) Resolve(ctx context.Context, reference string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	project, config, name, err := parseDopplerReference(reference)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := exec.LookPath("doppler"); err != nil {
+		return "", &BackendError{
+			Backend: "Doppler",
+			Reason:  "doppler CLI not found in PATH",
+			Fix:     "Install from https://docs.doppler.com/docs/install-cli\nThen run: doppler login",
+		}
+	}
+
+	// Download the config once; other secrets in it are served from the run
+	// cache.
+	values, err := cached(ctx, "doppler:"+project+"/"+config, func() (map[string]string, error) {
+		cmd := exec.CommandContext(ctx, "doppler", "secrets", "download",
+			"--no-file", "--format", "json",
+			"--project", project, "--config", config)
+		var stdout, stderr bytes.Buffer
+		cmd.Stdout = &stdout
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err != nil {
+			return nil, r.parseDopplerError(stderr.Bytes(), reference)
+		}
+		var values map[string]string
+		if err := json.Unmarshal(stdout.Bytes(), &values); err != nil {
+			return nil, &BackendError{Backend: "Doppler", Reference: reference, Reason: "unexpected doppler output: " + err.Error()}
+		}
+		return values, nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	v, ok := values[name]
+	if !ok {
+		return "", &NotFoundError{Reference: reference, Backend: "Doppler"}
+	}
+	return v, nil
+}
+
+// parseDopplerReference splits a doppler:// URI into project, config, and
+// secret name.
+// doppler://backend/prd/DATABASE_URL -> ("backend", "prd", "DATABASE_URL")
+func parseDopplerReference(ref string) (project, config, name string, err error) {
+	rest, ok := strings.CutPrefix(ref, "doppler://")
+	if !ok {
+		return "", "", "", &InvalidReferenceError{
+			Reference: ref,
+			Reason:    "Doppler references must start with doppler://",
+		}
+	}
+	parts := strings.Split(rest, "/")
+	if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+		return "", "", "", &InvalidReferenceError{
+			Reference: ref,
+			Reason:    "expected doppler://PROJECT/CONFIG/NAME",
+		}
+	}
+	return parts[0], parts[1], parts[2], nil
+}
+
+// parseDopplerError converts doppler CLI errors to actionable error types.
+func (r *DopplerResolver) parseDopplerError(stderr []byte, reference string) error {
+	msg := string(stderr)
+
+	// Not signed in, or the token was revoked
+	if strings.Contains(msg, "must provide a token") || strings.Contains(msg, "Invalid Auth token") || strings.Contains(msg, "Unable to authenticate") {
+		return &BackendError{
+			Backend:   "Doppler",
+			Reference: reference,
+			Reason:    "not signed in",
+			Fix:       "Run: doppler login\n\nOr for CI/automation, set DOPPLER_TOKEN.",
+		}
+	}
+
+	// Unknown project or config
+	if strings.Contains(msg, "Could not find requested project") || strings.Contains(msg, "Could not find requested config") {
+		project, config, _, _ := parseDopplerReference(reference)
+		return &BackendError{
+			Backend:   "Doppler",
+			Reference: reference,
+			Reason:    "project or config not found",
+			Fix:       "Project \"" + project + "\" or config \"" + config + "\" not found.\n\nList them with: doppler projects && doppler configs --project " + project,
+		}
+	}
+
+	// Token lacks access
+	if strings.Contains(msg, "not have access") || strings.Contains(msg, "Forbidden") {
+		return &BackendError{
+			Backend:   "Doppler",
+			Reference: reference,
+			Reason:    "access denied",
+			Fix:       "Check that your Doppler token can read this project and config.",
+		}
+	}
+
+	return &BackendError{
+		Backend:   "Doppler",
+		Reference: reference,
+		Reason:    strings.TrimSpace(msg),
+	}
+}
+
+func init() {
+	Register(&DopplerResolver{})
+}
@@ -0,0 +1,78 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseDopplerReference(t *testing.T) {
+	project, config, name, err := parseDopplerReference("doppler://backend/prd/DATABASE_URL")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if project != "backend" || config != "prd" || name != "DATABASE_URL" {
+		t.Errorf("got (%q, %q, %q)", project, config, name)
+	}
+
+	for _, ref := range []string{"doppler://backend/DATABASE_URL", "doppler://backend/prd/", "doppler://a/b/c/d", "vault://a/b/c"} {
+		var invalid *InvalidReferenceError
+		if _, _, _, err := parseDopplerReference(ref); !errors.As(err, &invalid) {
+			t.Errorf("%s: err = %v, want InvalidReferenceError", ref, err)
+		}
+	}
+}
+
+func TestDopplerResolver_ParseError(t *testing.T) {
+	r := &DopplerResolver{}
+	ref := "doppler://backend/prd/API_KEY"
+
+	tests := []struct {
+		stderr, reason, fix string
+	}{
+		{"Doppler Error: you must provide a token", "not signed in", "doppler login"},
+		{"Doppler Error: Could not find requested project 'backend'", "not found", "doppler projects"},
+		{"Doppler Error: You do not have access to this config", "access denied", "token"},
+		{"something else", "something else", ""},
+	}
+	for _, tt := range tests {
+		var backendErr *BackendError
+		if err := r.parseDopplerError([]byte(tt.stderr), ref); !errors.As(err, &backendErr) {
+			t.Errorf("%q: got %T, want BackendError", tt.stderr, err)
+			continue
+		}
+		if !strings.Contains(backendErr.Reason, tt.reason) || !strings.Contains(backendErr.Fix, tt.fix) {
+			t.Errorf("%q: reason %q fix %q, want %q and %q", tt.stderr, backendErr.Reason, backendErr.Fix, tt.reason, tt.fix)
+		}
+	}
+}
+
+func TestDopplerResolver_CachesConfigPerRun(t *testing.T) {
+	callLog := fakeCLI(t, "doppler", `{"API_KEY": "k", "DATABASE_URL": "postgres://db"}`)
+
+	withTestRegistry(func() {
+		Register(&DopplerResolver{})
+		resolved, err := ResolveAll(context.Background(), map[string]string{
+			"API_KEY":      "doppler://backend/prd/API_KEY",
+			"DATABASE_URL": "doppler://backend/prd/DATABASE_URL",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resolved["API_KEY"] != "k" || resolved["DATABASE_URL"] != "postgres://db" {
+			t.Errorf("resolved = %v", resolved)
+		}
+
+		_, err = ResolveAll(context.Background(), map[string]string{"X": "doppler://backend/prd/MISSING"})
+		var notFound *NotFoundError
+		if !errors.As(err, &notFound) {
+			t.Errorf("err = %v, want NotFoundError", err)
+		}
+	})
+	// Once for the first run, once more for the second: the cache does not
+	// outlive a ResolveAll call.
+	if n := calls(t, callLog); n != 2 {
+		t.Errorf("doppler ran %d times, want 2", n)
+	}
+}
@@ -46,16 +46,21 @@ func Resolve(ctx context.Context, reference string) (string, error) {
 		return "", &UnsupportedSchemeError{Scheme: scheme}
 	}
 
-	return r.Resolve(ctx, reference)
+	return cached(ctx, "ref:"+reference, func() (string, error) {
+		return r.Resolve(ctx, reference)
+	})
 }
 
 // ResolveAll resolves all secrets in the map, returning resolved values.
 // Keys are environment variable names, values are secret references.
+// Backend lookups are cached for the duration of the call, so each
+// reference (and each Vault or Doppler secret) is fetched once per run.
 // Fails fast on first error.
 func ResolveAll(ctx context.Context, secrets map[string]string) (map[string]string, error) {
 	if len(secrets) == 0 {
 		return nil, nil
 	}
+	ctx = withRunCache(ctx)
 
 	resolved := make(map[string]string, len(secrets))
 	for name, ref := range secrets {
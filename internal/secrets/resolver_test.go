@@ -9,6 +9,7 @@ import (
 type mockResolver struct {
 	scheme string
 	values map[string]string
+	calls  int
 }
 
 func (m *mockResolver) Scheme() string {
@@ -16,6 +17,7 @@ func (m *mockResolver) Scheme() string {
 }
 
 func (m *mockResolver) Resolve(ctx context.Context, ref string) (string, error) {
+	m.calls++
 	if v, ok := m.values[ref]; ok {
 		return v, nil
 	}
@@ -116,3 +118,28 @@ func TestResolveAll_FailsOnError(t *testing.T) {
 		}
 	})
 }
+
+func TestResolveAll_ResolvesEachReferenceOnce(t *testing.T) {
+	withTestRegistry(func() {
+		mock := &mockResolver{
+			scheme: "mock",
+			values: map[string]string{"mock://vault/key": "value"},
+		}
+		Register(mock)
+
+		secrets := map[string]string{
+			"API_KEY":       "mock://vault/key",
+			"API_KEY_ALIAS": "mock://vault/key",
+		}
+		resolved, err := ResolveAll(context.Background(), secrets)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resolved["API_KEY"] != "value" || resolved["API_KEY_ALIAS"] != "value" {
+			t.Errorf("resolved = %v", resolved)
+		}
+		if mock.calls != 1 {
+			t.Errorf("resolver called %d times, want 1", mock.calls)
+		}
+	})
+}
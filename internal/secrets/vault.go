@@ -0,0 +1,191 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os/exec"
+	"slices"
+	"strings"
+)
+
+// VaultResolver resolves secrets from a HashiCorp Vault KV secrets engine
+// (version 1 or 2) using the vault CLI. The CLI reads VAULT_ADDR and the
+// token from `vault login` or VAULT_TOKEN.
+type VaultResolver struct{}
+
+// Scheme returns "vault".
+func (r *VaultResolver) Scheme() string {
+	return "vault"
+}
+
+// Resolve fetches a field of a KV secret using `vault kv get`.
+// Reference format: vault://MOUNT/PATH#FIELD, e.g. vault://secret/myapp#api_key.
+// The field may be omitted for a secret with a single field.
+func (r *VaultResolver) Resolve(ctx context.Context, reference string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	mount, path, field, err := parseVaultReference(reference)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := exec.LookPath("vault"); err != nil {
+		return "", &BackendError{
+			Backend: "Vault",
+			Reason:  "vault CLI not found in PATH",
+			Fix:     "Install from https://developer.hashicorp.com/vault/install\nThen run: vault login",
+		}
+	}
+
+	// Fetch the whole secret once; other fields of it are served from the
+	// run cache.
+	data, err := cached(ctx, "vault:"+mount+"/"+path, func() (map[string]string, error) {
+		cmd := exec.CommandContext(ctx, "vault", "kv", "get", "-format=json", "-mount="+mount, path)
+		var stdout, stderr bytes.Buffer
+		cmd.Stdout = &stdout
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err != nil {
+			return nil, r.parseVaultError(stderr.Bytes(), reference)
+		}
+		return parseVaultSecret(stdout.Bytes(), reference)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if field == "" {
+		if len(data) != 1 {
+			return "", &InvalidReferenceError{
+				Reference: reference,
+				Reason:    "secret has several fields; name one with #FIELD (fields: " + strings.Join(sortedKeys(data), ", ") + ")",
+			}
+		}
+		for _, v := range data {
+			return v, nil
+		}
+	}
+	v, ok := data[field]
+	if !ok {
+		return "", &NotFoundError{Reference: reference, Backend: "Vault"}
+	}
+	return v, nil
+}
+
+// parseVaultReference splits a vault:// URI into the KV mount, the secret
+// path within it, and the optional field.
+// vault://secret/myapp#api_key -> ("secret", "myapp", "api_key")
+func parseVaultReference(ref string) (mount, path, field string, err error) {
+	rest, ok := strings.CutPrefix(ref, "vault://")
+	if !ok {
+		return "", "", "", &InvalidReferenceError{
+			Reference: ref,
+			Reason:    "Vault references must start with vault://",
+		}
+	}
+	rest, field, _ = strings.Cut(rest, "#")
+	mount, path, _ = strings.Cut(rest, "/")
+	path = strings.Trim(path, "/")
+	if mount == "" || path == "" {
+		return "", "", "", &InvalidReferenceError{
+			Reference: ref,
+			Reason:    "expected vault://MOUNT/PATH#FIELD",
+		}
+	}
+	return mount, path, field, nil
+}
+
+// parseVaultSecret extracts the key/value data from `vault kv get
+// -format=json` output. KV version 2 nests it under data.data beside
+// data.metadata; version 1 returns it as data. Non-string values are
+// returned as JSON.
+func parseVaultSecret(out []byte, reference string) (map[string]string, error) {
+	var resp struct {
+		Data map[string]json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(out, &resp); err != nil {
+		return nil, &BackendError{Backend: "Vault", Reference: reference, Reason: "unexpected vault output: " + err.Error()}
+	}
+	fields := resp.Data
+	if inner, ok := resp.Data["data"]; ok {
+		if _, v2 := resp.Data["metadata"]; v2 {
+			fields = nil
+			if err := json.Unmarshal(inner, &fields); err != nil {
+				return nil, &BackendError{Backend: "Vault", Reference: reference, Reason: "unexpected vault output: " + err.Error()}
+			}
+		}
+	}
+
+	data := make(map[string]string, len(fields))
+	for k, raw := range fields {
+		var s string
+		if json.Unmarshal(raw, &s) == nil {
+			data[k] = s
+		} else {
+			data[k] = string(raw)
+		}
+	}
+	return data, nil
+}
+
+// parseVaultError converts vault CLI errors to actionable error types.
+func (r *VaultResolver) parseVaultError(stderr []byte, reference string) error {
+	msg := string(stderr)
+
+	// Secret not found
+	if strings.Contains(msg, "No value found at") {
+		return &NotFoundError{Reference: reference, Backend: "Vault"}
+	}
+
+	// No token
+	if strings.Contains(msg, "missing client token") {
+		return &BackendError{
+			Backend:   "Vault",
+			Reference: reference,
+			Reason:    "not signed in",
+			Fix:       "Run: vault login\n\nOr for CI/automation, set VAULT_TOKEN.",
+		}
+	}
+
+	// Token lacks access, or has expired
+	if strings.Contains(msg, "permission denied") {
+		return &BackendError{
+			Backend:   "Vault",
+			Reference: reference,
+			Reason:    "permission denied",
+			Fix:       "Check that your Vault policy allows reading this path, or sign in again: vault login",
+		}
+	}
+
+	// Server unreachable
+	if strings.Contains(msg, "connection refused") || strings.Contains(msg, "no such host") || strings.Contains(msg, "dial tcp") {
+		return &BackendError{
+			Backend:   "Vault",
+			Reference: reference,
+			Reason:    "cannot reach the Vault server",
+			Fix:       "Set VAULT_ADDR to your Vault server's address.",
+		}
+	}
+
+	return &BackendError{
+		Backend:   "Vault",
+		Reference: reference,
+		Reason:    strings.TrimSpace(msg),
+	}
+}
+
+// sortedKeys returns the keys of m in order.
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+func init() {
+	Register(&VaultResolver{})
+}
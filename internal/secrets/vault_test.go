@@ -0,0 +1,149 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeCLI installs an executable named name on PATH that prints stdout and
+// appends a line to the returned log file on each call.
+func fakeCLI(t *testing.T, name, stdout string) (callLog string) {
+	t.Helper()
+	dir := t.TempDir()
+	callLog = filepath.Join(dir, "calls")
+	script := "#!/bin/sh\necho \"$@\" >> " + callLog + "\ncat <<'EOF'\n" + stdout + "\nEOF\n"
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return callLog
+}
+
+// calls returns the number of times a fakeCLI was run.
+func calls(t *testing.T, callLog string) int {
+	t.Helper()
+	data, err := os.ReadFile(callLog)
+	if err != nil {
+		return 0
+	}
+	return strings.Count(string(data), "\n")
+}
+
+func TestParseVaultReference(t *testing.T) {
+	tests := []struct {
+		ref                string
+		mount, path, field string
+		wantErr            bool
+	}{
+		{ref: "vault://secret/myapp#api_key", mount: "secret", path: "myapp", field: "api_key"},
+		{ref: "vault://kv/teams/backend/db#password", mount: "kv", path: "teams/backend/db", field: "password"},
+		{ref: "vault://secret/token", mount: "secret", path: "token"},
+		{ref: "vault://secret#key", wantErr: true},
+		{ref: "vault:///myapp#key", wantErr: true},
+		{ref: "op://secret/myapp", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			mount, path, field, err := parseVaultReference(tt.ref)
+			if tt.wantErr {
+				var invalid *InvalidReferenceError
+				if !errors.As(err, &invalid) {
+					t.Errorf("err = %v, want InvalidReferenceError", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if mount != tt.mount || path != tt.path || field != tt.field {
+				t.Errorf("got (%q, %q, %q), want (%q, %q, %q)", mount, path, field, tt.mount, tt.path, tt.field)
+			}
+		})
+	}
+}
+
+func TestParseVaultSecret(t *testing.T) {
+	v2 := `{"data": {"data": {"api_key": "k2", "port": 5432}, "metadata": {"version": 3}}}`
+	data, err := parseVaultSecret([]byte(v2), "vault://secret/app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data["api_key"] != "k2" || data["port"] != "5432" || len(data) != 2 {
+		t.Errorf("KV v2 data = %v", data)
+	}
+
+	// KV v1 has no metadata, so a field named "data" is just a field.
+	v1 := `{"data": {"api_key": "k1", "data": "payload"}}`
+	data, err = parseVaultSecret([]byte(v1), "vault://kv/app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data["api_key"] != "k1" || data["data"] != "payload" {
+		t.Errorf("KV v1 data = %v", data)
+	}
+}
+
+func TestVaultResolver_ParseError(t *testing.T) {
+	r := &VaultResolver{}
+	ref := "vault://secret/app#key"
+
+	var notFound *NotFoundError
+	if err := r.parseVaultError([]byte("No value found at secret/data/app"), ref); !errors.As(err, &notFound) {
+		t.Errorf("not found: got %T, want NotFoundError", err)
+	}
+
+	tests := []struct {
+		stderr, reason, fix string
+	}{
+		{"Error making API request.\n\nCode: 400. Errors:\n\n* missing client token", "not signed in", "vault login"},
+		{"Code: 403. Errors:\n\n* permission denied", "permission denied", "policy"},
+		{"Get \"https://127.0.0.1:8200/v1/sys/internal/ui/mounts/secret/app\": dial tcp 127.0.0.1:8200: connect: connection refused", "cannot reach", "VAULT_ADDR"},
+	}
+	for _, tt := range tests {
+		var backendErr *BackendError
+		if err := r.parseVaultError([]byte(tt.stderr), ref); !errors.As(err, &backendErr) {
+			t.Errorf("%q: got %T, want BackendError", tt.stderr, err)
+			continue
+		}
+		if !strings.Contains(backendErr.Reason, tt.reason) || !strings.Contains(backendErr.Fix, tt.fix) {
+			t.Errorf("%q: reason %q fix %q, want %q and %q", tt.stderr, backendErr.Reason, backendErr.Fix, tt.reason, tt.fix)
+		}
+	}
+}
+
+func TestVaultResolver_CachesSecretPerRun(t *testing.T) {
+	callLog := fakeCLI(t, "vault", `{"data": {"data": {"user": "app", "password": "hunter2"}, "metadata": {}}}`)
+
+	withTestRegistry(func() {
+		Register(&VaultResolver{})
+		resolved, err := ResolveAll(context.Background(), map[string]string{
+			"DB_USER":     "vault://secret/db#user",
+			"DB_PASSWORD": "vault://secret/db#password",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resolved["DB_USER"] != "app" || resolved["DB_PASSWORD"] != "hunter2" {
+			t.Errorf("resolved = %v", resolved)
+		}
+	})
+	if n := calls(t, callLog); n != 1 {
+		t.Errorf("vault ran %d times, want once for both fields", n)
+	}
+
+	r := &VaultResolver{}
+	_, err := r.Resolve(context.Background(), "vault://secret/db")
+	var invalid *InvalidReferenceError
+	if !errors.As(err, &invalid) || !strings.Contains(err.Error(), "password, user") {
+		t.Errorf("err = %v, want InvalidReferenceError listing the fields", err)
+	}
+	_, err = r.Resolve(context.Background(), "vault://secret/db#missing")
+	var notFound *NotFoundError
+	if !errors.As(err, &notFound) {
+		t.Errorf("err = %v, want NotFoundError", err)
+	}
+}
@@ -383,10 +383,16 @@ func (b *ArchiveBackend) List(_ string) ([]string, error) {
 
 // buildMatcher creates a gitignore matcher from .gitignore files and additional patterns.
 func (b *ArchiveBackend) buildMatcher(workspacePath string) (gitignore.Matcher, error) {
-	patterns := make([]gitignore.Pattern, 0, len(b.opts.Additional)+16)
+	return buildIgnoreMatcher(workspacePath, b.opts.UseGitignore, b.opts.Additional)
+}
+
+// buildIgnoreMatcher creates a gitignore matcher from the .gitignore files
+// under workspacePath (when useGitignore is set) and additional patterns.
+func buildIgnoreMatcher(workspacePath string, useGitignore bool, additional []string) (gitignore.Matcher, error) {
+	patterns := make([]gitignore.Pattern, 0, len(additional)+16)
 
 	// Add patterns from .gitignore files if enabled
-	if b.opts.UseGitignore {
+	if useGitignore {
 		err := filepath.WalkDir(workspacePath, func(path string, d fs.DirEntry, err error) error {
 			if err != nil {
 				return err
@@ -431,7 +437,7 @@ func (b *ArchiveBackend) buildMatcher(workspacePath string) (gitignore.Matcher,
 	}
 
 	// Add additional patterns
-	for _, pattern := range b.opts.Additional {
+	for _, pattern := range additional {
 		patterns = append(patterns, gitignore.ParsePattern(pattern, nil))
 	}
 
@@ -0,0 +1,284 @@
+package snapshot
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
+)
+
+// ChangeKind describes how a file differs between a snapshot and the workspace.
+type ChangeKind string
+
+const (
+	ChangeAdded    ChangeKind = "added"    // in the workspace, not the snapshot
+	ChangeModified ChangeKind = "modified" // in both, with different content, type, or mode
+	ChangeDeleted  ChangeKind = "deleted"  // in the snapshot, not the workspace
+)
+
+// Change is a file that differs between a snapshot and the workspace.
+type Change struct {
+	Path string     `json:"path"` // slash-separated, relative to the workspace root
+	Kind ChangeKind `json:"kind"`
+}
+
+// DiffTrees compares the files under oldDir against newDir. Only regular
+// files and symlinks are compared; directories show up through their
+// contents. Paths excluded by opts (gitignore rules read from newDir and
+// additional patterns) and .git (unless opts.IncludeGit) are skipped on
+// both sides, so files a snapshot never captured are not reported.
+// Changes are sorted by path.
+func DiffTrees(oldDir, newDir string, opts EngineOptions) ([]Change, error) {
+	matcher, err := buildIgnoreMatcher(newDir, opts.UseGitignore, opts.Additional)
+	if err != nil {
+		return nil, fmt.Errorf("build ignore matcher: %w", err)
+	}
+	oldFiles, err := walkTree(oldDir, matcher, opts.IncludeGit)
+	if err != nil {
+		return nil, err
+	}
+	newFiles, err := walkTree(newDir, matcher, opts.IncludeGit)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []Change
+	for rel, oldInfo := range oldFiles {
+		newInfo, ok := newFiles[rel]
+		if !ok {
+			changes = append(changes, Change{Path: rel, Kind: ChangeDeleted})
+			continue
+		}
+		same, err := sameFile(filepath.Join(oldDir, rel), filepath.Join(newDir, rel), oldInfo, newInfo)
+		if err != nil {
+			return nil, fmt.Errorf("compare %s: %w", rel, err)
+		}
+		if !same {
+			changes = append(changes, Change{Path: rel, Kind: ChangeModified})
+		}
+	}
+	for rel := range newFiles {
+		if _, ok := oldFiles[rel]; !ok {
+			changes = append(changes, Change{Path: rel, Kind: ChangeAdded})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes, nil
+}
+
+// walkTree returns the regular files and symlinks under root, keyed by
+// slash-separated relative path.
+func walkTree(root string, matcher gitignore.Matcher, includeGit bool) (map[string]fs.FileInfo, error) {
+	files := make(map[string]fs.FileInfo)
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		if relPath == "." {
+			return nil
+		}
+		if !includeGit && (relPath == ".git" || strings.HasPrefix(relPath, ".git"+string(filepath.Separator))) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if matcher.Match(strings.Split(relPath, string(filepath.Separator)), d.IsDir()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return fmt.Errorf("get file info for %s: %w", relPath, err)
+		}
+		if info.Mode().IsRegular() || info.Mode()&os.ModeSymlink != 0 {
+			files[filepath.ToSlash(relPath)] = info
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("walk %s: %w", root, err)
+	}
+	return files, nil
+}
+
+// sameFile reports whether two files have the same type, executable bits,
+// and content (or symlink target).
+func sameFile(a, b string, ai, bi fs.FileInfo) (bool, error) {
+	if ai.Mode().Type() != bi.Mode().Type() {
+		return false, nil
+	}
+	if ai.Mode()&os.ModeSymlink != 0 {
+		at, err := os.Readlink(a)
+		if err != nil {
+			return false, err
+		}
+		bt, err := os.Readlink(b)
+		if err != nil {
+			return false, err
+		}
+		return at == bt, nil
+	}
+	if ai.Size() != bi.Size() || ai.Mode().Perm()&0o111 != bi.Mode().Perm()&0o111 {
+		return false, nil
+	}
+
+	af, err := os.Open(a)
+	if err != nil {
+		return false, err
+	}
+	defer af.Close()
+	bf, err := os.Open(b)
+	if err != nil {
+		return false, err
+	}
+	defer bf.Close()
+
+	abuf := make([]byte, 32*1024)
+	bbuf := make([]byte, 32*1024)
+	for {
+		an, aerr := io.ReadFull(af, abuf)
+		bn, berr := io.ReadFull(bf, bbuf)
+		if !bytes.Equal(abuf[:an], bbuf[:bn]) {
+			return false, nil
+		}
+		if aerr == io.EOF || aerr == io.ErrUnexpectedEOF {
+			return berr == io.EOF || berr == io.ErrUnexpectedEOF, nil
+		}
+		if aerr != nil {
+			return false, aerr
+		}
+		if berr != nil {
+			return false, berr
+		}
+	}
+}
+
+// FilterChanges returns the changes at or beneath any of paths, which are
+// relative to the workspace root. A path naming a directory selects every
+// change inside it. Absolute paths and paths that leave the workspace are
+// rejected.
+func FilterChanges(changes []Change, paths []string) ([]Change, error) {
+	prefixes := make([]string, 0, len(paths))
+	for _, p := range paths {
+		clean := path.Clean(filepath.ToSlash(p))
+		if filepath.IsAbs(p) || path.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") {
+			return nil, fmt.Errorf("path %q must be relative to the workspace", p)
+		}
+		if clean == "." {
+			return changes, nil
+		}
+		prefixes = append(prefixes, clean)
+	}
+
+	var out []Change
+	for _, c := range changes {
+		for _, prefix := range prefixes {
+			if c.Path == prefix || strings.HasPrefix(c.Path, prefix+"/") {
+				out = append(out, c)
+				break
+			}
+		}
+	}
+	return out, nil
+}
+
+// ModifiedSince returns the paths of changes whose workspace copy was
+// modified after t, sorted. Restoring them would discard those edits.
+// Deleted files have no workspace copy and are never returned.
+func ModifiedSince(workspace string, changes []Change, t time.Time) []string {
+	var newer []string
+	for _, c := range changes {
+		if c.Kind == ChangeDeleted {
+			continue
+		}
+		info, err := os.Lstat(filepath.Join(workspace, filepath.FromSlash(c.Path)))
+		if err != nil {
+			continue
+		}
+		if info.ModTime().After(t) {
+			newer = append(newer, c.Path)
+		}
+	}
+	sort.Strings(newer)
+	return newer
+}
+
+// applyChanges makes the files named by changes in dstRoot match srcRoot:
+// files the source has are copied over (replacing whatever is in the way),
+// files it lacks are removed.
+func applyChanges(srcRoot, dstRoot string, changes []Change) error {
+	for _, c := range changes {
+		src := filepath.Join(srcRoot, filepath.FromSlash(c.Path))
+		dst := filepath.Join(dstRoot, filepath.FromSlash(c.Path))
+
+		if c.Kind == ChangeAdded {
+			if err := os.Remove(dst); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("remove %s: %w", c.Path, err)
+			}
+			continue
+		}
+		if err := copyEntry(src, dst); err != nil {
+			return fmt.Errorf("restore %s: %w", c.Path, err)
+		}
+	}
+	return nil
+}
+
+// copyEntry copies a regular file or symlink from src to dst, creating
+// parent directories and preserving the file mode.
+func copyEntry(src, dst string) error {
+	info, err := os.Lstat(src)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	// Clear whatever is at dst (a file, or a directory that replaced the
+	// file) so the copy never writes through a symlink.
+	if err := os.RemoveAll(dst); err != nil {
+		return err
+	}
+
+	if info.Mode()&os.ModeSymlink != 0 {
+		target, err := os.Readlink(src)
+		if err != nil {
+			return err
+		}
+		return os.Symlink(target, dst)
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_EXCL, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,145 @@
+package snapshot
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, root, rel, content string) {
+	t.Helper()
+	p := filepath.Join(root, filepath.FromSlash(rel))
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDiffTrees(t *testing.T) {
+	oldDir := t.TempDir()
+	newDir := t.TempDir()
+
+	writeFile(t, oldDir, "same.txt", "same")
+	writeFile(t, newDir, "same.txt", "same")
+	writeFile(t, oldDir, "src/main.go", "package main")
+	writeFile(t, newDir, "src/main.go", "package main // edited")
+	writeFile(t, oldDir, "gone.txt", "bye")
+	writeFile(t, newDir, "src/new.go", "package main")
+	writeFile(t, oldDir, "run.sh", "echo")
+	writeFile(t, newDir, "run.sh", "echo")
+	if err := os.Chmod(filepath.Join(newDir, "run.sh"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	// Ignored and .git paths are not compared.
+	writeFile(t, newDir, ".gitignore", "build/\n")
+	writeFile(t, oldDir, ".gitignore", "build/\n")
+	writeFile(t, newDir, "build/out.bin", "x")
+	writeFile(t, newDir, ".git/HEAD", "ref: refs/heads/main")
+
+	got, err := DiffTrees(oldDir, newDir, EngineOptions{UseGitignore: true})
+	if err != nil {
+		t.Fatalf("DiffTrees: %v", err)
+	}
+	want := []Change{
+		{Path: "gone.txt", Kind: ChangeDeleted},
+		{Path: "run.sh", Kind: ChangeModified},
+		{Path: "src/main.go", Kind: ChangeModified},
+		{Path: "src/new.go", Kind: ChangeAdded},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("DiffTrees = %+v, want %+v", got, want)
+	}
+}
+
+func TestFilterChanges(t *testing.T) {
+	changes := []Change{
+		{Path: "a.txt", Kind: ChangeModified},
+		{Path: "src/main.go", Kind: ChangeModified},
+		{Path: "src/new.go", Kind: ChangeAdded},
+		{Path: "srcx/other.go", Kind: ChangeAdded},
+	}
+
+	got, err := FilterChanges(changes, []string{"./src/", "a.txt"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Change{changes[0], changes[1], changes[2]}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("FilterChanges = %+v, want %+v", got, want)
+	}
+
+	for _, bad := range []string{"/etc/passwd", "../outside", "src/../../x"} {
+		if _, err := FilterChanges(changes, []string{bad}); err == nil {
+			t.Errorf("FilterChanges(%q) should fail", bad)
+		}
+	}
+}
+
+func TestModifiedSince(t *testing.T) {
+	ws := t.TempDir()
+	writeFile(t, ws, "old.txt", "x")
+	writeFile(t, ws, "new.txt", "x")
+	stopped := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(filepath.Join(ws, "old.txt"), stopped.Add(-time.Minute), stopped.Add(-time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+
+	changes := []Change{
+		{Path: "old.txt", Kind: ChangeModified},
+		{Path: "new.txt", Kind: ChangeAdded},
+		{Path: "gone.txt", Kind: ChangeDeleted},
+	}
+	got := ModifiedSince(ws, changes, stopped)
+	if !reflect.DeepEqual(got, []string{"new.txt"}) {
+		t.Errorf("ModifiedSince = %v, want [new.txt]", got)
+	}
+}
+
+func TestEngineDiffAndRestorePaths(t *testing.T) {
+	ws := t.TempDir()
+	engine, err := NewEngine(ws, t.TempDir(), EngineOptions{ForceBackend: BackendArchive})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeFile(t, ws, "keep.txt", "v1")
+	writeFile(t, ws, "src/a.go", "v1")
+	snap, err := engine.Create(TypeManual, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeFile(t, ws, "keep.txt", "v2")
+	writeFile(t, ws, "src/a.go", "v2")
+	writeFile(t, ws, "src/b.go", "new")
+
+	changes, err := engine.Diff(snap.ID)
+	if err != nil {
+		t.Fatalf("Diff: %v", err)
+	}
+	if len(changes) != 3 {
+		t.Fatalf("Diff = %+v, want 3 changes", changes)
+	}
+
+	restored, err := engine.RestorePaths(snap.ID, []string{"src"})
+	if err != nil {
+		t.Fatalf("RestorePaths: %v", err)
+	}
+	if len(restored) != 2 {
+		t.Errorf("RestorePaths applied %+v, want 2 changes", restored)
+	}
+
+	if b, _ := os.ReadFile(filepath.Join(ws, "src/a.go")); string(b) != "v1" {
+		t.Errorf("src/a.go = %q, want v1", b)
+	}
+	if _, err := os.Stat(filepath.Join(ws, "src/b.go")); !os.IsNotExist(err) {
+		t.Errorf("src/b.go should be removed, stat err = %v", err)
+	}
+	if b, _ := os.ReadFile(filepath.Join(ws, "keep.txt")); string(b) != "v2" {
+		t.Errorf("keep.txt = %q, want v2 (outside restored path)", b)
+	}
+}
@@ -32,6 +32,7 @@ type Engine struct {
 	opts        EngineOptions
 	mu          sync.Mutex
 	snapshots   map[string]Metadata
+	onCreate    func(Metadata)
 }
 
 // metadataFile is the filename for persisted snapshot metadata.
@@ -134,15 +135,32 @@ func (e *Engine) Backend() Backend {
 	return e.backend
 }
 
+// OnCreate registers fn to be called with each snapshot Create makes,
+// after its metadata is saved. fn runs on the caller's goroutine without the
+// engine lock held, so it may use the engine.
+func (e *Engine) OnCreate(fn func(Metadata)) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.onCreate = fn
+}
+
 // Create creates a new snapshot with the given type and label.
 func (e *Engine) Create(typ Type, label string) (Metadata, error) {
+	meta, fn, err := e.create(typ, label)
+	if err == nil && fn != nil {
+		fn(meta)
+	}
+	return meta, err
+}
+
+func (e *Engine) create(typ Type, label string) (Metadata, func(Metadata), error) {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
 	id := NewID()
 	nativeRef, err := e.backend.Create(e.workspace, id)
 	if err != nil {
-		return Metadata{}, fmt.Errorf("backend create: %w", err)
+		return Metadata{}, nil, fmt.Errorf("backend create: %w", err)
 	}
 
 	meta := Metadata{
@@ -160,10 +178,10 @@ func (e *Engine) Create(typ Type, label string) (Metadata, error) {
 		// Clean up the snapshot if we can't save metadata
 		_ = e.backend.Delete(nativeRef)
 		delete(e.snapshots, id)
-		return Metadata{}, fmt.Errorf("save metadata: %w", err)
+		return Metadata{}, nil, fmt.Errorf("save metadata: %w", err)
 	}
 
-	return meta, nil
+	return meta, e.onCreate, nil
 }
 
 // Restore restores a snapshot in-place to the workspace.
@@ -200,6 +218,68 @@ func (e *Engine) RestoreTo(id, destPath string) error {
 	return nil
 }
 
+// Diff compares snapshot id against the current workspace, using the
+// engine's exclude options on both sides.
+func (e *Engine) Diff(id string) ([]Change, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	dir, err := e.extractLocked(id)
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	return DiffTrees(dir, e.workspace, e.opts)
+}
+
+// RestorePaths restores the given workspace-relative paths (files or
+// directories) from snapshot id and leaves the rest of the workspace
+// untouched. Files in the snapshot are written back; files added since
+// are removed. Returns the changes it applied.
+func (e *Engine) RestorePaths(id string, paths []string) ([]Change, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	dir, err := e.extractLocked(id)
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	changes, err := DiffTrees(dir, e.workspace, e.opts)
+	if err != nil {
+		return nil, err
+	}
+	changes, err = FilterChanges(changes, paths)
+	if err != nil {
+		return nil, err
+	}
+	if err := applyChanges(dir, e.workspace, changes); err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+// extractLocked writes snapshot id to a new temporary directory and
+// returns its path. The caller holds e.mu and removes the directory.
+func (e *Engine) extractLocked(id string) (string, error) {
+	meta, ok := e.snapshots[id]
+	if !ok {
+		return "", fmt.Errorf("snapshot not found: %s", id)
+	}
+
+	dir, err := os.MkdirTemp("", "moat-snap-")
+	if err != nil {
+		return "", fmt.Errorf("create temp directory: %w", err)
+	}
+	if err := e.backend.RestoreTo(meta.NativeRef, dir); err != nil {
+		os.RemoveAll(dir)
+		return "", fmt.Errorf("backend restore to: %w", err)
+	}
+	return dir, nil
+}
+
 // Delete removes a snapshot and its metadata.
 func (e *Engine) Delete(id string) error {
 	e.mu.Lock()
@@ -601,3 +601,27 @@ func TestEngineWorkspaceNotExist(t *testing.T) {
 		t.Error("NewEngine() should return error for non-existent workspace")
 	}
 }
+
+func TestEngineOnCreate(t *testing.T) {
+	engine, err := NewEngine(t.TempDir(), t.TempDir(), EngineOptions{ForceBackend: "archive"})
+	if err != nil {
+		t.Fatalf("NewEngine() error: %v", err)
+	}
+
+	var got []Metadata
+	engine.OnCreate(func(meta Metadata) {
+		// The engine lock is released, so the callback may use the engine.
+		if _, ok := engine.Get(meta.ID); !ok {
+			t.Errorf("snapshot %s not in engine during callback", meta.ID)
+		}
+		got = append(got, meta)
+	})
+
+	meta, err := engine.Create(TypeManual, "checkpoint")
+	if err != nil {
+		t.Fatalf("Create() error: %v", err)
+	}
+	if len(got) != 1 || got[0].ID != meta.ID || got[0].Label != "checkpoint" {
+		t.Errorf("OnCreate got %+v, want one call for %s", got, meta.ID)
+	}
+}
@@ -11,12 +11,13 @@ import (
 type Type string
 
 const (
-	TypePreRun Type = "pre-run"
-	TypeGit    Type = "git"
-	TypeBuild  Type = "build"
-	TypeIdle   Type = "idle"
-	TypeManual Type = "manual"
-	TypeSafety Type = "safety"
+	TypePreRun  Type = "pre-run"
+	TypePostRun Type = "post-run"
+	TypeGit     Type = "git"
+	TypeBuild   Type = "build"
+	TypeIdle    Type = "idle"
+	TypeManual  Type = "manual"
+	TypeSafety  Type = "safety"
 )
 
 func (t Type) String() string {
@@ -0,0 +1,222 @@
+package snapshot
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/majorcontext/moat/internal/log"
+	"github.com/majorcontext/moat/internal/trace"
+)
+
+// commitExitTimeout bounds how long a commit trigger waits for a traced
+// git process to exit before snapshotting anyway (e.g. an editor left open
+// for the commit message).
+const commitExitTimeout = 10 * time.Minute
+
+// reflogTailSize is how much of the end of a reflog is read to find its
+// newest entry.
+const reflogTailSize = 8 * 1024
+
+// CommitTrigger creates a TypeGit snapshot after each git commit made in a
+// run. Commits are observed from exec events (HandleExec, fed by
+// trace.Tracer.OnExec) and by watching the workspace's HEAD reflog
+// (WatchReflog); the tracer sees commits as they happen, the reflog works
+// where the host cannot trace container processes. Both may run at once:
+// a commit already snapshotted by one is skipped by the other.
+type CommitTrigger struct {
+	engine *Engine
+	gitDir string
+
+	// waitExit blocks until the process exits. Exec events arrive when git
+	// starts, before the commit is written. Overridden in tests.
+	waitExit func(pid int)
+
+	mu       sync.Mutex
+	lastHash string
+	wg       sync.WaitGroup
+}
+
+// NewCommitTrigger returns a trigger that snapshots with engine. gitDir is
+// the workspace's git directory (in a worktree, the worktree's own), used to
+// identify commits; it may not exist.
+func NewCommitTrigger(engine *Engine, gitDir string) *CommitTrigger {
+	return &CommitTrigger{engine: engine, gitDir: gitDir, waitExit: waitProcExit}
+}
+
+// HandleExec snapshots the workspace once a traced `git commit` exits.
+// Other events are ignored. Safe to register with trace.Tracer.OnExec.
+func (t *CommitTrigger) HandleExec(ev trace.ExecEvent) {
+	if !ev.IsGitCommit() {
+		return
+	}
+	t.wg.Add(1)
+	go func() {
+		defer t.wg.Done()
+		t.waitExit(ev.PID)
+		entry, _ := lastReflogEntry(filepath.Join(t.gitDir, "logs", "HEAD"))
+		t.snapshot(entry)
+	}()
+}
+
+// WatchReflog polls the HEAD reflog every interval until ctx is done and
+// snapshots the workspace after new commit entries are appended to it.
+// Entries present when the watch starts are skipped. Returns immediately
+// if the git directory does not exist.
+func (t *CommitTrigger) WatchReflog(ctx context.Context, interval time.Duration) {
+	if info, err := os.Stat(t.gitDir); err != nil || !info.IsDir() {
+		return
+	}
+	path := filepath.Join(t.gitDir, "logs", "HEAD")
+	var offset int64
+	if info, err := os.Stat(path); err == nil {
+		offset = info.Size()
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		commits, next, err := readReflogCommits(path, offset)
+		if err != nil {
+			log.Debug("reading git reflog", "path", path, "error", err)
+			continue
+		}
+		offset = next
+		if len(commits) > 0 {
+			// One snapshot captures the workspace after all new commits.
+			t.snapshot(commits[len(commits)-1])
+		}
+	}
+}
+
+// Wait blocks until snapshots for traced commits have been created.
+func (t *CommitTrigger) Wait() {
+	t.wg.Wait()
+}
+
+// snapshot creates a snapshot labeled with the commit subject, unless the
+// commit was already snapshotted.
+func (t *CommitTrigger) snapshot(entry reflogEntry) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if entry.Hash != "" && entry.Hash == t.lastHash {
+		return
+	}
+	if _, err := t.engine.Create(TypeGit, entry.Subject); err != nil {
+		log.Debug("failed to create git commit snapshot", "error", err)
+		return
+	}
+	t.lastHash = entry.Hash
+}
+
+// reflogEntry is a commit recorded in a reflog.
+type reflogEntry struct {
+	Hash    string
+	Subject string
+}
+
+// parseReflogLine parses "<old> <new> <ident> <time> <tz>\t<action>: <subject>"
+// and reports whether it records a commit: an action of "commit",
+// "commit (amend)", "commit (initial)", or "commit (merge)".
+func parseReflogLine(line string) (reflogEntry, bool) {
+	head, entry, ok := strings.Cut(line, "\t")
+	if !ok {
+		return reflogEntry{}, false
+	}
+	fields := strings.Fields(head)
+	if len(fields) < 2 {
+		return reflogEntry{}, false
+	}
+	action, subject, _ := strings.Cut(entry, ": ")
+	if action != "commit" && !strings.HasPrefix(action, "commit (") {
+		return reflogEntry{}, false
+	}
+	return reflogEntry{Hash: fields[1], Subject: subject}, true
+}
+
+// readReflogCommits returns the commit entries written to the reflog after
+// offset, and the new offset. A truncated reflog is read from the start; a
+// missing one (no commits yet) has no entries.
+func readReflogCommits(path string, offset int64) ([]reflogEntry, int64, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, offset, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, offset, err
+	}
+	if info.Size() < offset {
+		offset = 0
+	}
+	if info.Size() == offset {
+		return nil, offset, nil
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return nil, offset, fmt.Errorf("seek reflog: %w", err)
+	}
+
+	var commits []reflogEntry
+	r := bufio.NewReader(f)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			// Leave a partially written line for the next poll.
+			break
+		}
+		offset += int64(len(line))
+		if entry, ok := parseReflogLine(strings.TrimSuffix(line, "\n")); ok {
+			commits = append(commits, entry)
+		}
+	}
+	return commits, offset, nil
+}
+
+// lastReflogEntry returns the newest reflog entry if it records a commit.
+func lastReflogEntry(path string) (reflogEntry, bool) {
+	f, err := os.Open(path)
+	if err != nil {
+		return reflogEntry{}, false
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return reflogEntry{}, false
+	}
+	start := max(info.Size()-reflogTailSize, 0)
+	buf := make([]byte, info.Size()-start)
+	if _, err := f.ReadAt(buf, start); err != nil && err != io.EOF {
+		return reflogEntry{}, false
+	}
+	lines := strings.Split(strings.TrimRight(string(buf), "\n"), "\n")
+	return parseReflogLine(lines[len(lines)-1])
+}
+
+// waitProcExit polls /proc until pid exits or commitExitTimeout passes.
+// Where /proc does not exist it returns immediately.
+func waitProcExit(pid int) {
+	deadline := time.Now().Add(commitExitTimeout)
+	proc := fmt.Sprintf("/proc/%d", pid)
+	for time.Now().Before(deadline) {
+		if _, err := os.Stat(proc); os.IsNotExist(err) {
+			return
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+}
@@ -0,0 +1,173 @@
+package snapshot
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/majorcontext/moat/internal/trace"
+)
+
+const (
+	zeroHash = "0000000000000000000000000000000000000000"
+	hashA    = "1111111111111111111111111111111111111111"
+	hashB    = "2222222222222222222222222222222222222222"
+)
+
+func reflogLine(old, new, action string) string {
+	return old + " " + new + " Dev <dev@example.com> 1700000000 +0000\t" + action + "\n"
+}
+
+func appendReflog(t *testing.T, gitDir, line string) {
+	t.Helper()
+	path := filepath.Join(gitDir, "logs", "HEAD")
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(line); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParseReflogLine(t *testing.T) {
+	tests := []struct {
+		line    string
+		want    reflogEntry
+		wantOK  bool
+		comment string
+	}{
+		{reflogLine(zeroHash, hashA, "commit (initial): first"), reflogEntry{hashA, "first"}, true, "initial"},
+		{reflogLine(hashA, hashB, "commit: fix: handle nil"), reflogEntry{hashB, "fix: handle nil"}, true, "subject with colon"},
+		{reflogLine(hashA, hashB, "commit (amend): reword"), reflogEntry{hashB, "reword"}, true, "amend"},
+		{reflogLine(hashA, hashB, "checkout: moving from main to dev"), reflogEntry{}, false, "checkout"},
+		{reflogLine(hashA, hashB, "reset: moving to HEAD~1"), reflogEntry{}, false, "reset"},
+		{"garbage", reflogEntry{}, false, "no tab"},
+	}
+	for _, tt := range tests {
+		got, ok := parseReflogLine(strings.TrimSuffix(tt.line, "\n"))
+		if ok != tt.wantOK || got != tt.want {
+			t.Errorf("%s: parseReflogLine = %+v, %v; want %+v, %v", tt.comment, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestReadReflogCommits(t *testing.T) {
+	gitDir := t.TempDir()
+	path := filepath.Join(gitDir, "logs", "HEAD")
+
+	commits, offset, err := readReflogCommits(path, 0)
+	if err != nil || len(commits) != 0 || offset != 0 {
+		t.Fatalf("missing reflog: %v, %d, %v", commits, offset, err)
+	}
+
+	appendReflog(t, gitDir, reflogLine(zeroHash, hashA, "commit (initial): first"))
+	appendReflog(t, gitDir, reflogLine(hashA, hashA, "checkout: moving from main to dev"))
+	appendReflog(t, gitDir, reflogLine(hashA, hashB, "commit: second"))
+	// A partial line is left for the next read.
+	appendReflog(t, gitDir, hashB+" "+hashB)
+
+	commits, offset, err = readReflogCommits(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(commits) != 2 || commits[0].Subject != "first" || commits[1].Hash != hashB {
+		t.Errorf("commits = %+v", commits)
+	}
+
+	appendReflog(t, gitDir, "\tcommit: third\n")
+	commits, _, err = readReflogCommits(path, offset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(commits) != 1 || commits[0].Subject != "third" || commits[0].Hash != hashB {
+		t.Errorf("after partial line, commits = %+v", commits)
+	}
+}
+
+func newTriggerEngine(t *testing.T) (*Engine, string) {
+	t.Helper()
+	ws := t.TempDir()
+	if err := os.WriteFile(filepath.Join(ws, "main.go"), []byte("package main"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	engine, err := NewEngine(ws, t.TempDir(), EngineOptions{ForceBackend: BackendArchive})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return engine, ws
+}
+
+func countSnapshots(t *testing.T, e *Engine, typ Type) []Metadata {
+	t.Helper()
+	list, err := e.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out []Metadata
+	for _, m := range list {
+		if m.Type == typ {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+func TestCommitTriggerWatchReflog(t *testing.T) {
+	engine, ws := newTriggerEngine(t)
+	gitDir := filepath.Join(ws, ".git")
+	appendReflog(t, gitDir, reflogLine(zeroHash, hashA, "commit (initial): existing"))
+
+	trigger := NewCommitTrigger(engine, gitDir)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		trigger.WatchReflog(ctx, 10*time.Millisecond)
+		close(done)
+	}()
+
+	time.Sleep(30 * time.Millisecond)
+	appendReflog(t, gitDir, reflogLine(hashA, hashB, "commit: add feature"))
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(countSnapshots(t, engine, TypeGit)) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	<-done
+
+	snaps := countSnapshots(t, engine, TypeGit)
+	if len(snaps) != 1 {
+		t.Fatalf("git snapshots = %d, want 1 (existing entries skipped)", len(snaps))
+	}
+	if snaps[0].Label != "add feature" {
+		t.Errorf("label = %q, want commit subject", snaps[0].Label)
+	}
+}
+
+func TestCommitTriggerHandleExec(t *testing.T) {
+	engine, ws := newTriggerEngine(t)
+	gitDir := filepath.Join(ws, ".git")
+	appendReflog(t, gitDir, reflogLine(zeroHash, hashA, "commit (initial): first"))
+
+	trigger := NewCommitTrigger(engine, gitDir)
+	trigger.waitExit = func(int) {}
+
+	trigger.HandleExec(trace.ExecEvent{Command: "git", Args: []string{"status"}})
+	trigger.HandleExec(trace.ExecEvent{Command: "git", Args: []string{"commit", "-m", "first"}})
+	trigger.Wait()
+	// The reflog watcher seeing the same commit does not snapshot it again.
+	trigger.snapshot(reflogEntry{Hash: hashA, Subject: "first"})
+
+	snaps := countSnapshots(t, engine, TypeGit)
+	if len(snaps) != 1 || snaps[0].Label != "first" {
+		t.Errorf("git snapshots = %+v, want one labeled %q", snaps, "first")
+	}
+}
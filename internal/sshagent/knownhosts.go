@@ -0,0 +1,165 @@
+package sshagent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// hostKeyAlgorithms are requested one at a time so a host's key of each type
+// is collected; a single handshake only reveals the negotiated one.
+var hostKeyAlgorithms = []string{
+	ssh.KeyAlgoED25519,
+	ssh.KeyAlgoECDSA256,
+	ssh.KeyAlgoRSASHA512,
+}
+
+// errHostKeyCaptured aborts a handshake once the host key has been seen.
+var errHostKeyCaptured = errors.New("host key captured")
+
+// FetchHostKeys connects to host on port 22 and returns its SSH host keys,
+// one per supported key type. No authentication is attempted.
+func FetchHostKeys(ctx context.Context, host string) ([]ssh.PublicKey, error) {
+	addr := net.JoinHostPort(host, "22")
+	var keys []ssh.PublicKey
+	var lastErr error
+	for _, algo := range hostKeyAlgorithms {
+		key, err := fetchHostKey(ctx, addr, algo)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("fetching host keys from %s: %w", addr, lastErr)
+	}
+	return keys, nil
+}
+
+func fetchHostKey(ctx context.Context, addr, algo string) (ssh.PublicKey, error) {
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	} else {
+		_ = conn.SetDeadline(time.Now().Add(10 * time.Second))
+	}
+
+	var captured ssh.PublicKey
+	cfg := &ssh.ClientConfig{
+		User:              "moat",
+		HostKeyAlgorithms: []string{algo},
+		HostKeyCallback: func(_ string, _ net.Addr, key ssh.PublicKey) error {
+			captured = key
+			return errHostKeyCaptured
+		},
+	}
+	_, _, _, err = ssh.NewClientConn(conn, addr, cfg)
+	if captured != nil {
+		return captured, nil
+	}
+	if err == nil {
+		err = errors.New("no host key presented")
+	}
+	return nil, err
+}
+
+// KnownHostsLine formats key as a known_hosts entry for host.
+func KnownHostsLine(host string, key ssh.PublicKey) string {
+	return knownhosts.Line([]string{host}, key)
+}
+
+// ParseKnownHostsLine parses a "host keytype base64" known_hosts entry and
+// returns its key.
+func ParseKnownHostsLine(line string) (ssh.PublicKey, error) {
+	_, _, key, _, _, err := ssh.ParseKnownHosts([]byte(line))
+	return key, err
+}
+
+// HostKeyVerdict is the outcome of checking fetched host keys against a set
+// of trusted ones.
+type HostKeyVerdict int
+
+const (
+	// HostKeyUnknown means no trusted key is recorded for the host.
+	HostKeyUnknown HostKeyVerdict = iota
+	// HostKeyMatch means at least one fetched key matches a trusted key.
+	HostKeyMatch
+	// HostKeyMismatch means trusted keys exist but none was presented, which
+	// may indicate a man-in-the-middle.
+	HostKeyMismatch
+)
+
+// VerifyHostKeys checks fetched keys for host against the trusted
+// known_hosts files (e.g. ~/.ssh/known_hosts) and returns the fetched keys
+// the files vouch for. Missing files are ignored.
+func VerifyHostKeys(host string, fetched []ssh.PublicKey, knownHostsFiles ...string) ([]ssh.PublicKey, HostKeyVerdict, error) {
+	var files []string
+	for _, f := range knownHostsFiles {
+		if _, err := os.Stat(f); err == nil {
+			files = append(files, f)
+		}
+	}
+	if len(files) == 0 {
+		return nil, HostKeyUnknown, nil
+	}
+	callback, err := knownhosts.New(files...)
+	if err != nil {
+		return nil, HostKeyUnknown, err
+	}
+
+	addr := net.JoinHostPort(host, "22")
+	remote := &net.TCPAddr{IP: net.IPv4zero, Port: 22}
+	var matched []ssh.PublicKey
+	verdict := HostKeyUnknown
+	for _, key := range fetched {
+		err := callback(addr, remote, key)
+		if err == nil {
+			matched = append(matched, key)
+			continue
+		}
+		var keyErr *knownhosts.KeyError
+		if errors.As(err, &keyErr) && len(keyErr.Want) > 0 {
+			verdict = HostKeyMismatch
+		}
+	}
+	if len(matched) > 0 {
+		return matched, HostKeyMatch, nil
+	}
+	return nil, verdict, nil
+}
+
+// MatchTrustedKeys returns the fetched keys that appear in trusted, a list
+// of known_hosts entries.
+func MatchTrustedKeys(fetched []ssh.PublicKey, trusted []string) []ssh.PublicKey {
+	want := make(map[string]bool, len(trusted))
+	for _, line := range trusted {
+		if key, err := ParseKnownHostsLine(line); err == nil {
+			want[string(key.Marshal())] = true
+		}
+	}
+	var matched []ssh.PublicKey
+	for _, key := range fetched {
+		if want[string(key.Marshal())] {
+			matched = append(matched, key)
+		}
+	}
+	return matched
+}
+
+// HostKeyFingerprint returns the SHA256 fingerprint of key for display.
+func HostKeyFingerprint(key ssh.PublicKey) string {
+	return key.Type() + " " + strings.TrimSpace(ssh.FingerprintSHA256(key))
+}
@@ -0,0 +1,77 @@
+package sshagent
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func testHostKey(t *testing.T) ssh.PublicKey {
+	t.Helper()
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestKnownHostsLineRoundTrip(t *testing.T) {
+	key := testHostKey(t)
+	line := KnownHostsLine("git.example.com", key)
+	parsed, err := ParseKnownHostsLine(line)
+	if err != nil {
+		t.Fatalf("ParseKnownHostsLine(%q): %v", line, err)
+	}
+	if string(parsed.Marshal()) != string(key.Marshal()) {
+		t.Error("round-tripped key differs")
+	}
+}
+
+func TestMatchTrustedKeys(t *testing.T) {
+	trusted, other := testHostKey(t), testHostKey(t)
+	matched := MatchTrustedKeys([]ssh.PublicKey{other, trusted}, []string{KnownHostsLine("h", trusted), "garbage"})
+	if len(matched) != 1 || string(matched[0].Marshal()) != string(trusted.Marshal()) {
+		t.Errorf("matched %d keys, want only the trusted one", len(matched))
+	}
+}
+
+func TestVerifyHostKeys(t *testing.T) {
+	known, other := testHostKey(t), testHostKey(t)
+	path := filepath.Join(t.TempDir(), "known_hosts")
+	if err := os.WriteFile(path, []byte(KnownHostsLine("git.example.com", known)+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		host    string
+		fetched []ssh.PublicKey
+		files   []string
+		want    HostKeyVerdict
+		matched int
+	}{
+		{"match", "git.example.com", []ssh.PublicKey{other, known}, []string{path}, HostKeyMatch, 1},
+		{"mismatch", "git.example.com", []ssh.PublicKey{other}, []string{path}, HostKeyMismatch, 0},
+		{"unknown host", "new.example.com", []ssh.PublicKey{known}, []string{path}, HostKeyUnknown, 0},
+		{"missing file", "git.example.com", []ssh.PublicKey{known}, []string{filepath.Join(t.TempDir(), "none")}, HostKeyUnknown, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matched, verdict, err := VerifyHostKeys(tt.host, tt.fetched, tt.files...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if verdict != tt.want || len(matched) != tt.matched {
+				t.Errorf("VerifyHostKeys = %d keys, verdict %d; want %d keys, verdict %d", len(matched), verdict, tt.matched, tt.want)
+			}
+		})
+	}
+}
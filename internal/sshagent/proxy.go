@@ -1,14 +1,16 @@
 package sshagent
 
 import (
+	"errors"
 	"fmt"
 	"sync"
 	"sync/atomic"
+	"time"
 )
 
 // AuditEvent represents an auditable SSH agent operation.
 type AuditEvent struct {
-	Action      string // "list", "sign_allowed", "sign_denied"
+	Action      string // "list", "sign_allowed", "sign_denied", "sign_limited"
 	Host        string // target host (for sign operations)
 	Fingerprint string // key fingerprint (for sign operations)
 	Error       string // error message (for denied operations)
@@ -17,6 +19,32 @@ type AuditEvent struct {
 // AuditFunc is a callback for audit logging.
 type AuditFunc func(event AuditEvent)
 
+// Alert kinds.
+const (
+	AlertUnexpectedHost = "unexpected_host" // a key was used for a host it is not granted for
+	AlertRateLimit      = "rate_limit"      // signatures exceeded Limits.PerMinute
+	AlertRunLimit       = "run_limit"       // signatures exceeded Limits.PerRun
+)
+
+// Alert reports SSH agent usage that suggests an agent is misbehaving, such
+// as mass-cloning or trying a key against hosts it was not granted for.
+type Alert struct {
+	Kind        string // one of the Alert* constants
+	Host        string
+	Fingerprint string
+	Message     string
+}
+
+// AlertFunc is a callback for usage alerts.
+type AlertFunc func(alert Alert)
+
+// Limits caps how many signatures the proxy will perform. Zero values mean
+// no limit.
+type Limits struct {
+	PerMinute int // signatures in any 60-second window
+	PerRun    int // signatures over the proxy's lifetime
+}
+
 // Proxy is a filtering SSH agent proxy that only exposes keys
 // for granted hosts.
 type Proxy struct {
@@ -24,7 +52,16 @@ type Proxy struct {
 	allowedKeys map[string][]string // fingerprint -> allowed hosts
 	currentHost atomic.Value        // string - target host for current operation
 	auditFunc   AuditFunc           // optional audit callback
+	alertFunc   AlertFunc           // optional alert callback
 	mu          sync.RWMutex
+
+	// Usage accounting for limits, guarded by usageMu.
+	usageMu     sync.Mutex
+	limits      Limits
+	recentSigns []time.Time         // signature times within the last minute
+	totalSigns  int                 // signatures over the proxy's lifetime
+	alerted     map[string]struct{} // alerts already raised, to avoid repeats
+	now         func() time.Time
 }
 
 // NewProxy creates a new filtering SSH agent proxy.
@@ -32,11 +69,72 @@ func NewProxy(upstream AgentClient) *Proxy {
 	p := &Proxy{
 		upstream:    upstream,
 		allowedKeys: make(map[string][]string),
+		alerted:     make(map[string]struct{}),
+		now:         time.Now,
 	}
 	p.currentHost.Store("")
 	return p
 }
 
+// SetLimits sets the signature caps enforced by Sign.
+func (p *Proxy) SetLimits(l Limits) {
+	p.usageMu.Lock()
+	defer p.usageMu.Unlock()
+	p.limits = l
+}
+
+// SetAlertFunc sets the alert callback function.
+func (p *Proxy) SetAlertFunc(fn AlertFunc) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.alertFunc = fn
+}
+
+// alert raises an alert once per kind, host, and key. Repeats are dropped so
+// a runaway agent produces one alert rather than thousands.
+func (p *Proxy) alert(a Alert) {
+	key := a.Kind + "|" + a.Host + "|" + a.Fingerprint
+	p.usageMu.Lock()
+	_, seen := p.alerted[key]
+	p.alerted[key] = struct{}{}
+	p.usageMu.Unlock()
+	if seen {
+		return
+	}
+
+	p.mu.RLock()
+	fn := p.alertFunc
+	p.mu.RUnlock()
+	if fn != nil {
+		fn(a)
+	}
+}
+
+// reserveSign counts a signature against the limits. It returns the alert
+// kind of the exceeded limit, or "" if the signature may proceed.
+func (p *Proxy) reserveSign() string {
+	p.usageMu.Lock()
+	defer p.usageMu.Unlock()
+
+	now := p.now()
+	cutoff := now.Add(-time.Minute)
+	i := 0
+	for i < len(p.recentSigns) && !p.recentSigns[i].After(cutoff) {
+		i++
+	}
+	p.recentSigns = p.recentSigns[i:]
+
+	if p.limits.PerRun > 0 && p.totalSigns >= p.limits.PerRun {
+		return AlertRunLimit
+	}
+	if p.limits.PerMinute > 0 && len(p.recentSigns) >= p.limits.PerMinute {
+		return AlertRateLimit
+	}
+	p.recentSigns = append(p.recentSigns, now)
+	p.totalSigns++
+	return ""
+}
+
 // AllowKey permits a key (by fingerprint) for specific hosts.
 func (p *Proxy) AllowKey(fingerprint string, hosts []string) {
 	p.mu.Lock()
@@ -152,9 +250,39 @@ func (p *Proxy) Sign(key *Identity, data []byte) ([]byte, error) {
 			Fingerprint: fp,
 			Error:       errMsg,
 		})
+		p.alert(Alert{
+			Kind:        AlertUnexpectedHost,
+			Host:        host,
+			Fingerprint: fp,
+			Message:     fmt.Sprintf("key %s was used for %s but is only granted for %v", fp, host, hosts),
+		})
 		return nil, fmt.Errorf("key %s not allowed for host %s", fp, host)
 	}
 
+	if kind := p.reserveSign(); kind != "" {
+		var errMsg string
+		p.usageMu.Lock()
+		if kind == AlertRunLimit {
+			errMsg = fmt.Sprintf("SSH signature limit of %d per run reached", p.limits.PerRun)
+		} else {
+			errMsg = fmt.Sprintf("SSH signature limit of %d per minute reached", p.limits.PerMinute)
+		}
+		p.usageMu.Unlock()
+		p.audit(AuditEvent{
+			Action:      "sign_limited",
+			Host:        host,
+			Fingerprint: fp,
+			Error:       errMsg,
+		})
+		p.alert(Alert{
+			Kind:        kind,
+			Host:        host,
+			Fingerprint: fp,
+			Message:     errMsg,
+		})
+		return nil, errors.New(errMsg)
+	}
+
 	sig, err := p.upstream.Sign(key, data)
 	if err != nil {
 		return nil, err
@@ -3,6 +3,7 @@ package sshagent
 import (
 	"errors"
 	"testing"
+	"time"
 )
 
 func TestProxyFilterIdentities(t *testing.T) {
@@ -164,3 +165,109 @@ func TestProxyUpstreamError(t *testing.T) {
 		t.Error("Sign should propagate upstream error")
 	}
 }
+
+func TestProxySignPerMinuteLimit(t *testing.T) {
+	proxy := NewProxy(&mockAgent{})
+	proxy.AllowKey(Fingerprint([]byte("key1")), []string{"github.com"})
+	proxy.SetCurrentHost("github.com")
+	proxy.SetLimits(Limits{PerMinute: 2})
+
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	proxy.now = func() time.Time { return now }
+
+	var alerts []Alert
+	proxy.SetAlertFunc(func(a Alert) { alerts = append(alerts, a) })
+	var limited int
+	proxy.SetAuditFunc(func(e AuditEvent) {
+		if e.Action == "sign_limited" {
+			limited++
+		}
+	})
+
+	key := &Identity{KeyBlob: []byte("key1")}
+	for i := 0; i < 2; i++ {
+		if _, err := proxy.Sign(key, []byte("data")); err != nil {
+			t.Fatalf("Sign %d: %v", i, err)
+		}
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := proxy.Sign(key, []byte("data")); err == nil {
+			t.Fatal("Sign should fail once the per-minute limit is reached")
+		}
+	}
+	if limited != 3 {
+		t.Errorf("sign_limited audit events = %d, want 3", limited)
+	}
+	if len(alerts) != 1 || alerts[0].Kind != AlertRateLimit {
+		t.Errorf("alerts = %+v, want one %s alert", alerts, AlertRateLimit)
+	}
+
+	// The window slides: a minute later signing works again.
+	now = now.Add(time.Minute + time.Second)
+	if _, err := proxy.Sign(key, []byte("data")); err != nil {
+		t.Errorf("Sign after window: %v", err)
+	}
+}
+
+func TestProxySignPerRunLimit(t *testing.T) {
+	proxy := NewProxy(&mockAgent{})
+	proxy.AllowKey(Fingerprint([]byte("key1")), []string{"github.com"})
+	proxy.SetCurrentHost("github.com")
+	proxy.SetLimits(Limits{PerRun: 1})
+
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	proxy.now = func() time.Time { return now }
+	var alerts []Alert
+	proxy.SetAlertFunc(func(a Alert) { alerts = append(alerts, a) })
+
+	key := &Identity{KeyBlob: []byte("key1")}
+	if _, err := proxy.Sign(key, []byte("data")); err != nil {
+		t.Fatalf("first Sign: %v", err)
+	}
+	now = now.Add(time.Hour)
+	if _, err := proxy.Sign(key, []byte("data")); err == nil {
+		t.Error("Sign should fail once the per-run limit is reached")
+	}
+	if len(alerts) != 1 || alerts[0].Kind != AlertRunLimit {
+		t.Errorf("alerts = %+v, want one %s alert", alerts, AlertRunLimit)
+	}
+}
+
+func TestProxySignDeniedDoesNotCountTowardLimit(t *testing.T) {
+	proxy := NewProxy(&mockAgent{})
+	proxy.AllowKey(Fingerprint([]byte("key1")), []string{"github.com"})
+	proxy.SetLimits(Limits{PerRun: 1})
+
+	key := &Identity{KeyBlob: []byte("key1")}
+	proxy.SetCurrentHost("gitlab.com")
+	if _, err := proxy.Sign(key, []byte("data")); err == nil {
+		t.Fatal("Sign should fail for an ungranted host")
+	}
+	proxy.SetCurrentHost("github.com")
+	if _, err := proxy.Sign(key, []byte("data")); err != nil {
+		t.Errorf("Sign for granted host: %v", err)
+	}
+}
+
+func TestProxyAlertUnexpectedHost(t *testing.T) {
+	proxy := NewProxy(&mockAgent{})
+	fp := Fingerprint([]byte("key1"))
+	proxy.AllowKey(fp, []string{"github.com"})
+	var alerts []Alert
+	proxy.SetAlertFunc(func(a Alert) { alerts = append(alerts, a) })
+
+	key := &Identity{KeyBlob: []byte("key1")}
+	proxy.SetCurrentHost("evil.example.com")
+	for i := 0; i < 3; i++ {
+		_, _ = proxy.Sign(key, []byte("data"))
+	}
+	proxy.SetCurrentHost("gitlab.com")
+	_, _ = proxy.Sign(key, []byte("data"))
+
+	if len(alerts) != 2 {
+		t.Fatalf("alerts = %+v, want one per unexpected host", alerts)
+	}
+	if a := alerts[0]; a.Kind != AlertUnexpectedHost || a.Host != "evil.example.com" || a.Fingerprint != fp {
+		t.Errorf("alert = %+v, want unexpected_host for evil.example.com", a)
+	}
+}
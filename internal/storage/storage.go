@@ -13,23 +13,29 @@ import (
 	"time"
 
 	"github.com/majorcontext/moat/internal/config"
+	"github.com/majorcontext/moat/internal/testresult"
 )
 
 // Metadata holds information about an agent run.
 type Metadata struct {
-	Name        string         `json:"name"`
-	Workspace   string         `json:"workspace"`
-	Grants      []string       `json:"grants,omitempty"`
-	Agent       string         `json:"agent,omitempty"` // Agent type from config (e.g., "claude-code")
-	Image       string         `json:"image,omitempty"` // Container image used
-	Ports       map[string]int `json:"ports,omitempty"`
-	ContainerID string         `json:"container_id,omitempty"`
-	State       string         `json:"state,omitempty"`
-	Interactive bool           `json:"interactive,omitempty"`
-	CreatedAt   time.Time      `json:"created_at,omitempty"`
-	StartedAt   time.Time      `json:"started_at,omitempty"`
-	StoppedAt   time.Time      `json:"stopped_at,omitempty"`
-	Error       string         `json:"error,omitempty"`
+	Name      string   `json:"name"`
+	Workspace string   `json:"workspace"`
+	Grants    []string `json:"grants,omitempty"`
+	// Labels are user-supplied key=value pairs (moat run --label).
+	Labels         map[string]string `json:"labels,omitempty"`
+	Group          string            `json:"group,omitempty"`           // Run group (moat run --group, moat compose)
+	IdempotencyKey string            `json:"idempotency_key,omitempty"` // moat run --id-from
+	PullRequest    string            `json:"pull_request,omitempty"`    // moat run --pr
+	Agent          string            `json:"agent,omitempty"`           // Agent type from config (e.g., "claude-code")
+	Image          string            `json:"image,omitempty"`           // Container image used
+	Ports          map[string]int    `json:"ports,omitempty"`
+	ContainerID    string            `json:"container_id,omitempty"`
+	State          string            `json:"state,omitempty"`
+	Interactive    bool              `json:"interactive,omitempty"`
+	CreatedAt      time.Time         `json:"created_at,omitempty"`
+	StartedAt      time.Time         `json:"started_at,omitempty"`
+	StoppedAt      time.Time         `json:"stopped_at,omitempty"`
+	Error          string            `json:"error,omitempty"`
 
 	// ProviderMeta holds provider-specific metadata captured during the run lifecycle.
 	// For example, the Claude provider stores {"claude_session_id": "<uuid>"}.
@@ -40,10 +46,13 @@ type Metadata struct {
 	WorktreePath   string `json:"worktree_path,omitempty"`
 	WorktreeRepoID string `json:"worktree_repo_id,omitempty"`
 
+	// WorkspaceRepos are the repositories mounted from workspaces: in moat.yaml.
+	WorkspaceRepos []WorkspaceRepo `json:"workspace_repos,omitempty"`
+
 	// Service dependency fields
 	ServiceContainers map[string]string `json:"service_containers,omitempty"` // service name -> container ID
 
-	// Runtime records which container runtime was used ("docker" or "apple").
+	// Runtime records which container runtime was used ("docker", "apple", or "podman").
 	// Used during reconciliation to skip cross-runtime container state checks.
 	Runtime string `json:"runtime,omitempty"`
 
@@ -51,12 +60,46 @@ type Metadata struct {
 	BuildkitContainerID string `json:"buildkit_container_id,omitempty"`
 	NetworkID           string `json:"network_id,omitempty"`
 
+	// InternalNetworkID is the run's internal network for services without
+	// egress, removed during cleanup.
+	InternalNetworkID string `json:"internal_network_id,omitempty"`
+
 	// Workspace mode fields (set when workspace.mode: volume).
 	// WorkspaceMode records the resolved mode ("bind" or "volume").
 	// WorkspaceVolume is the per-run Docker volume name backing /workspace,
 	// removed during cleanup.
 	WorkspaceMode   string `json:"workspace_mode,omitempty"`
 	WorkspaceVolume string `json:"workspace_volume,omitempty"`
+
+	// TestResults holds pass/fail counts parsed from test runner output in
+	// the run's logs (go test, jest, pytest). Nil when no test output was seen.
+	TestResults *testresult.Summary `json:"test_results,omitempty"`
+
+	// ExitCode is the main container's exit code, recorded when the exit was
+	// observed by the container monitor. FailureClass categorizes a failed
+	// run (see run.FailureClass); empty for successful runs.
+	ExitCode     int64  `json:"exit_code,omitempty"`
+	FailureClass string `json:"failure_class,omitempty"`
+
+	// MemoryMB is the container memory limit in megabytes (0 = runtime default).
+	MemoryMB int `json:"memory_mb,omitempty"`
+
+	// Priority is the CPU and IO weight class (high, normal, background).
+	// Empty means normal.
+	Priority string `json:"priority,omitempty"`
+
+	// IDE is true when the run serves SSH for IDE attach (ide.enabled).
+	IDE bool `json:"ide,omitempty"`
+}
+
+// WorkspaceRepo is a repository mounted under /workspace from the
+// workspaces: list in moat.yaml.
+type WorkspaceRepo struct {
+	Target string `json:"target"` // container path, e.g. /workspace/api
+	Source string `json:"source"` // host path mounted there
+	Branch string `json:"branch,omitempty"`
+	// Worktree is true when Source is a moat-managed worktree.
+	Worktree bool `json:"worktree,omitempty"`
 }
 
 // RunStore manages storage for a single agent run.
@@ -343,6 +386,10 @@ type NetworkRequest struct {
 	RequestBody     string            `json:"req_body,omitempty"`
 	ResponseBody    string            `json:"resp_body,omitempty"`
 	BodyTruncated   bool              `json:"truncated,omitempty"`
+	Denied          bool              `json:"denied,omitempty"`       // Blocked by network or Keep policy
+	DenyReason      string            `json:"deny_reason,omitempty"`  // Why the proxy blocked the request
+	RequestSize     int64             `json:"req_size,omitempty"`     // Content-Length of the request body, when known
+	UploadLimit     int64             `json:"upload_limit,omitempty"` // Upload cap the request body exceeded (network.uploads)
 }
 
 // WriteNetworkRequest appends a network request to the log.
@@ -368,11 +415,268 @@ func (s *RunStore) WriteNetworkRequest(req NetworkRequest) error {
 	return err
 }
 
+// Decision records why the proxy allowed or denied a request and what it
+// attached. Decisions are written to decisions.jsonl, one per proxied
+// request, alongside (but independent of) the network trace.
+type Decision struct {
+	Timestamp     time.Time `json:"ts"`
+	RequestID     string    `json:"request_id,omitempty"`
+	Type          string    `json:"type,omitempty"` // "http", "connect", "mcp", "relay", "postgres"
+	Method        string    `json:"method,omitempty"`
+	Host          string    `json:"host"`
+	Path          string    `json:"path,omitempty"`
+	Decision      string    `json:"decision"`       // "allow" or "deny"
+	Reason        string    `json:"reason"`         // deny reason, or the basis for allowing (see Decision* constants)
+	Rule          string    `json:"rule,omitempty"` // matching network.rules entry, e.g. "api.github.com: allow GET /repos/*"
+	Policy        string    `json:"policy,omitempty"`
+	Grants        []string  `json:"grants,omitempty"`           // credential grants injected
+	Injected      []string  `json:"injected_headers,omitempty"` // header names injected (lower-cased)
+	Transformers  []string  `json:"transformers,omitempty"`     // transformer kinds configured for the host
+	StatusCode    int       `json:"status_code,omitempty"`
+	RequestBytes  int64     `json:"req_bytes"`  // -1 if unknown
+	ResponseBytes int64     `json:"resp_bytes"` // -1 if unknown
+	Duration      int64     `json:"duration_ms"`
+	Error         string    `json:"error,omitempty"`
+}
+
+// Reasons recorded for allowed requests.
+const (
+	DecisionPolicy   = "policy"    // no rule matched; the network policy default allowed it
+	DecisionRule     = "rule"      // a network.rules entry allowed it
+	DecisionHostPort = "host_port" // a network.host port on the host gateway
+)
+
+// WriteDecision appends a proxy decision to the decision log.
+func (s *RunStore) WriteDecision(d Decision) error {
+	f, err := os.OpenFile(
+		filepath.Join(s.dir, "decisions.jsonl"),
+		os.O_CREATE|os.O_WRONLY|os.O_APPEND,
+		0o600,
+	)
+	if err != nil {
+		return fmt.Errorf("opening decision file: %w", err)
+	}
+	defer f.Close()
+
+	data, err := json.Marshal(d)
+	if err != nil {
+		return fmt.Errorf("marshaling decision: %w", err)
+	}
+	if _, writeErr := f.Write(data); writeErr != nil {
+		return fmt.Errorf("writing decision: %w", writeErr)
+	}
+	_, err = f.Write([]byte("\n"))
+	return err
+}
+
+// ReadDecisions reads all recorded proxy decisions.
+func (s *RunStore) ReadDecisions() ([]Decision, error) {
+	f, err := os.Open(filepath.Join(s.dir, "decisions.jsonl"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var decisions []Decision
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var d Decision
+		if err := json.Unmarshal(scanner.Bytes(), &d); err != nil {
+			continue
+		}
+		decisions = append(decisions, d)
+	}
+	return decisions, scanner.Err()
+}
+
+// Usage records the tokens one LLM API response consumed, as reported by the
+// provider in the response body. Usage records are written to usage.jsonl by
+// the proxy daemon's metering.
+type Usage struct {
+	Timestamp        time.Time `json:"ts"`
+	Host             string    `json:"host"`
+	Path             string    `json:"path,omitempty"`
+	Provider         string    `json:"provider"` // "anthropic" or "openai"
+	Model            string    `json:"model,omitempty"`
+	InputTokens      int64     `json:"input_tokens"` // excludes cache reads and writes
+	OutputTokens     int64     `json:"output_tokens"`
+	CacheReadTokens  int64     `json:"cache_read_tokens,omitempty"`
+	CacheWriteTokens int64     `json:"cache_write_tokens,omitempty"`
+}
+
+// WriteUsage appends an LLM usage record to the usage log.
+func (s *RunStore) WriteUsage(u Usage) error {
+	f, err := os.OpenFile(
+		filepath.Join(s.dir, "usage.jsonl"),
+		os.O_CREATE|os.O_WRONLY|os.O_APPEND,
+		0o600,
+	)
+	if err != nil {
+		return fmt.Errorf("opening usage file: %w", err)
+	}
+	defer f.Close()
+
+	data, err := json.Marshal(u)
+	if err != nil {
+		return fmt.Errorf("marshaling usage: %w", err)
+	}
+	if _, writeErr := f.Write(data); writeErr != nil {
+		return fmt.Errorf("writing usage: %w", writeErr)
+	}
+	_, err = f.Write([]byte("\n"))
+	return err
+}
+
+// ReadUsage reads all recorded LLM usage.
+func (s *RunStore) ReadUsage() ([]Usage, error) {
+	f, err := os.Open(filepath.Join(s.dir, "usage.jsonl"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var usage []Usage
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var u Usage
+		if err := json.Unmarshal(scanner.Bytes(), &u); err != nil {
+			continue
+		}
+		usage = append(usage, u)
+	}
+	return usage, scanner.Err()
+}
+
+// Metric is one sample of a run container's resource usage, written to
+// metrics.jsonl by the run manager while the run is attached.
+type Metric struct {
+	Timestamp time.Time `json:"ts"`
+	// CPUPercent is the CPU used since the previous sample, as a percentage
+	// of one CPU (200 means two CPUs fully busy). Zero for the first sample.
+	CPUPercent       float64 `json:"cpu_percent"`
+	CPUNanos         uint64  `json:"cpu_ns"` // cumulative CPU time
+	MemoryBytes      uint64  `json:"memory_bytes"`
+	MemoryLimitBytes uint64  `json:"memory_limit_bytes,omitempty"`
+	NetRxBytes       uint64  `json:"net_rx_bytes"` // cumulative
+	NetTxBytes       uint64  `json:"net_tx_bytes"` // cumulative
+}
+
+// WriteMetric appends a resource usage sample to the metrics log.
+func (s *RunStore) WriteMetric(m Metric) error {
+	f, err := os.OpenFile(
+		filepath.Join(s.dir, "metrics.jsonl"),
+		os.O_CREATE|os.O_WRONLY|os.O_APPEND,
+		0o600,
+	)
+	if err != nil {
+		return fmt.Errorf("opening metrics file: %w", err)
+	}
+	defer f.Close()
+
+	data, err := json.Marshal(m)
+	if err != nil {
+		return fmt.Errorf("marshaling metric: %w", err)
+	}
+	if _, writeErr := f.Write(data); writeErr != nil {
+		return fmt.Errorf("writing metric: %w", writeErr)
+	}
+	_, err = f.Write([]byte("\n"))
+	return err
+}
+
+// ReadMetrics reads all recorded resource usage samples.
+func (s *RunStore) ReadMetrics() ([]Metric, error) {
+	f, err := os.Open(filepath.Join(s.dir, "metrics.jsonl"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var metrics []Metric
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var m Metric
+		if err := json.Unmarshal(scanner.Bytes(), &m); err != nil {
+			continue
+		}
+		metrics = append(metrics, m)
+	}
+	return metrics, scanner.Err()
+}
+
+// Progress is a structured progress event a run reported with
+// `moatctl progress`, written to progress.jsonl by the proxy daemon.
+type Progress struct {
+	Timestamp time.Time `json:"ts"`
+	Message   string    `json:"message"`
+	// Percent is the reported completion from 0 to 100, or nil if the event
+	// did not include one.
+	Percent *int `json:"percent,omitempty"`
+}
+
+// WriteProgress appends a progress event to the progress log.
+func (s *RunStore) WriteProgress(p Progress) error {
+	f, err := os.OpenFile(
+		filepath.Join(s.dir, "progress.jsonl"),
+		os.O_CREATE|os.O_WRONLY|os.O_APPEND,
+		0o600,
+	)
+	if err != nil {
+		return fmt.Errorf("opening progress file: %w", err)
+	}
+	defer f.Close()
+
+	data, err := json.Marshal(p)
+	if err != nil {
+		return fmt.Errorf("marshaling progress: %w", err)
+	}
+	if _, writeErr := f.Write(data); writeErr != nil {
+		return fmt.Errorf("writing progress: %w", writeErr)
+	}
+	_, err = f.Write([]byte("\n"))
+	return err
+}
+
+// ReadProgress reads all recorded progress events.
+func (s *RunStore) ReadProgress() ([]Progress, error) {
+	f, err := os.Open(filepath.Join(s.dir, "progress.jsonl"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var events []Progress
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var p Progress
+		if err := json.Unmarshal(scanner.Bytes(), &p); err != nil {
+			continue
+		}
+		events = append(events, p)
+	}
+	return events, scanner.Err()
+}
+
 // SecretResolution records a resolved secret (without the value).
 type SecretResolution struct {
 	Timestamp time.Time `json:"ts"`
 	Name      string    `json:"name"`    // env var name
 	Backend   string    `json:"backend"` // e.g., "1password"
+	// Digest identifies the value without revealing it, so a refresh can
+	// tell whether the secret was rotated.
+	Digest string `json:"digest,omitempty"`
 }
 
 // WriteSecretResolution records that a secret was resolved.
@@ -521,3 +825,103 @@ func (s *RunStore) ReadDockerfile() (string, error) {
 	}
 	return string(data), nil
 }
+
+// ConfigManifest records the moat.yaml settings a run was created with, so
+// later edits to moat.yaml can be detected (moat status --check-config).
+// Values come from moat.yaml as written, before CLI flags or agent commands
+// add to the configuration.
+type ConfigManifest struct {
+	Dependencies  []string          `json:"dependencies,omitempty"`
+	Env           map[string]string `json:"env,omitempty"`
+	Secrets       map[string]string `json:"secrets,omitempty"` // env var -> secret reference (never the value)
+	Grants        []string          `json:"grants,omitempty"`
+	NetworkPolicy string            `json:"network_policy,omitempty"`
+	NetworkRules  []string          `json:"network_rules,omitempty"` // one rendered entry per host
+	NetworkHost   []int             `json:"network_host,omitempty"`
+	Command       []string          `json:"command,omitempty"`
+}
+
+// SaveConfigManifest writes the config manifest to manifest.json in the run directory.
+func (s *RunStore) SaveConfigManifest(m ConfigManifest) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(s.dir, "manifest.json"), data, 0o600)
+}
+
+// LoadConfigManifest reads the config manifest from manifest.json in the run
+// directory. Runs created before manifests were recorded return an error
+// satisfying errors.Is(err, fs.ErrNotExist).
+func (s *RunStore) LoadConfigManifest() (ConfigManifest, error) {
+	var m ConfigManifest
+	data, err := os.ReadFile(filepath.Join(s.dir, "manifest.json"))
+	if err != nil {
+		return m, err
+	}
+	err = json.Unmarshal(data, &m)
+	return m, err
+}
+
+// EnvVar is one environment variable a run's container was created with.
+// Values of secrets and credentials are never stored; Digest lets a later
+// comparison tell whether such a value changed without revealing it.
+type EnvVar struct {
+	Name     string `json:"name"`
+	Value    string `json:"value"`
+	Redacted bool   `json:"redacted,omitempty"`
+	Digest   string `json:"digest,omitempty"`
+	// Source says where the value came from, e.g. "moat.yaml env",
+	// "--env", "secret op", "grant github", "proxy", or "moat".
+	Source string `json:"source"`
+}
+
+// SaveEnvSnapshot writes the container environment to env.json in the run
+// directory.
+func (s *RunStore) SaveEnvSnapshot(env []EnvVar) error {
+	data, err := json.MarshalIndent(env, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(s.dir, "env.json"), data, 0o600)
+}
+
+// LoadEnvSnapshot reads the container environment from env.json in the run
+// directory. Runs created before snapshots were recorded return an error
+// satisfying errors.Is(err, fs.ErrNotExist).
+func (s *RunStore) LoadEnvSnapshot() ([]EnvVar, error) {
+	data, err := os.ReadFile(filepath.Join(s.dir, "env.json"))
+	if err != nil {
+		return nil, err
+	}
+	var env []EnvVar
+	err = json.Unmarshal(data, &env)
+	return env, err
+}
+
+// SaveEnvOverrides writes the environment overrides set with `moat env set`
+// to env_overrides.json in the run directory. They are kept apart from
+// metadata.json, which the process that started the run rewrites from
+// memory.
+func (s *RunStore) SaveEnvOverrides(env map[string]string) error {
+	data, err := json.MarshalIndent(env, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(s.dir, "env_overrides.json"), data, 0o600)
+}
+
+// LoadEnvOverrides reads the environment overrides from env_overrides.json
+// in the run directory. A run without overrides returns nil.
+func (s *RunStore) LoadEnvOverrides() (map[string]string, error) {
+	data, err := os.ReadFile(filepath.Join(s.dir, "env_overrides.json"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var env map[string]string
+	err = json.Unmarshal(data, &env)
+	return env, err
+}
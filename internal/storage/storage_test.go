@@ -547,6 +547,174 @@ func TestWriteNetworkRequest(t *testing.T) {
 	}
 }
 
+func TestWriteDecision(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewRunStore(dir, "run_decide1")
+	if err != nil {
+		t.Fatalf("NewRunStore: %v", err)
+	}
+
+	if got, err := s.ReadDecisions(); err != nil || got != nil {
+		t.Fatalf("ReadDecisions before write = %v, %v; want nil, nil", got, err)
+	}
+
+	want := []Decision{
+		{
+			Timestamp:     time.Now().UTC(),
+			Method:        "GET",
+			Host:          "api.github.com",
+			Path:          "/repos/org/repo",
+			Decision:      "allow",
+			Reason:        DecisionRule,
+			Rule:          "api.github.com: allow GET /repos/*",
+			Grants:        []string{"github"},
+			Injected:      []string{"authorization"},
+			StatusCode:    200,
+			RequestBytes:  0,
+			ResponseBytes: 1234,
+			Duration:      80,
+		},
+		{
+			Timestamp:     time.Now().UTC(),
+			Method:        "GET",
+			Host:          "evil.example.com",
+			Decision:      "deny",
+			Reason:        "network_policy",
+			RequestBytes:  -1,
+			ResponseBytes: -1,
+		},
+	}
+	for _, d := range want {
+		if err := s.WriteDecision(d); err != nil {
+			t.Fatalf("WriteDecision: %v", err)
+		}
+	}
+
+	got, err := s.ReadDecisions()
+	if err != nil {
+		t.Fatalf("ReadDecisions: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("got %d decisions, want 2", len(got))
+	}
+	if got[0].Rule != want[0].Rule || len(got[0].Grants) != 1 || got[0].ResponseBytes != 1234 {
+		t.Errorf("decision[0] = %+v, want %+v", got[0], want[0])
+	}
+	if got[1].Decision != "deny" || got[1].Reason != "network_policy" {
+		t.Errorf("decision[1] = %+v, want deny/network_policy", got[1])
+	}
+}
+
+func TestWriteUsage(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewRunStore(dir, "run_usage1")
+	if err != nil {
+		t.Fatalf("NewRunStore: %v", err)
+	}
+
+	if got, err := s.ReadUsage(); err != nil || got != nil {
+		t.Fatalf("ReadUsage before write = %v, %v; want nil, nil", got, err)
+	}
+
+	want := Usage{
+		Timestamp:       time.Now().UTC().Truncate(time.Second),
+		Host:            "api.anthropic.com",
+		Path:            "/v1/messages",
+		Provider:        "anthropic",
+		Model:           "claude-sonnet-4-5",
+		InputTokens:     12,
+		OutputTokens:    340,
+		CacheReadTokens: 5000,
+	}
+	if err := s.WriteUsage(want); err != nil {
+		t.Fatalf("WriteUsage: %v", err)
+	}
+	got, err := s.ReadUsage()
+	if err != nil {
+		t.Fatalf("ReadUsage: %v", err)
+	}
+	if len(got) != 1 || !got[0].Timestamp.Equal(want.Timestamp) {
+		t.Fatalf("ReadUsage = %+v, want [%+v]", got, want)
+	}
+	got[0].Timestamp = want.Timestamp
+	if got[0] != want {
+		t.Errorf("usage = %+v, want %+v", got[0], want)
+	}
+}
+
+func TestWriteMetric(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewRunStore(dir, "run_metric1")
+	if err != nil {
+		t.Fatalf("NewRunStore: %v", err)
+	}
+
+	if got, err := s.ReadMetrics(); err != nil || got != nil {
+		t.Fatalf("ReadMetrics before write = %v, %v; want nil, nil", got, err)
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	want := []Metric{
+		{Timestamp: now, CPUNanos: 1e9, MemoryBytes: 64 << 20, MemoryLimitBytes: 2 << 30, NetRxBytes: 100, NetTxBytes: 50},
+		{Timestamp: now.Add(10 * time.Second), CPUPercent: 50, CPUNanos: 6e9, MemoryBytes: 80 << 20, NetRxBytes: 900, NetTxBytes: 70},
+	}
+	for _, m := range want {
+		if err := s.WriteMetric(m); err != nil {
+			t.Fatalf("WriteMetric: %v", err)
+		}
+	}
+	got, err := s.ReadMetrics()
+	if err != nil {
+		t.Fatalf("ReadMetrics: %v", err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("ReadMetrics returned %d samples, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if !got[i].Timestamp.Equal(want[i].Timestamp) {
+			t.Errorf("sample %d timestamp = %v, want %v", i, got[i].Timestamp, want[i].Timestamp)
+		}
+		got[i].Timestamp = want[i].Timestamp
+		if got[i] != want[i] {
+			t.Errorf("sample %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestWriteProgress(t *testing.T) {
+	s, err := NewRunStore(t.TempDir(), "run_progress1")
+	if err != nil {
+		t.Fatalf("NewRunStore: %v", err)
+	}
+
+	if got, err := s.ReadProgress(); err != nil || got != nil {
+		t.Fatalf("ReadProgress before write = %v, %v; want nil, nil", got, err)
+	}
+
+	half := 50
+	now := time.Now().UTC().Truncate(time.Second)
+	if err := s.WriteProgress(Progress{Timestamp: now, Message: "tests passing", Percent: &half}); err != nil {
+		t.Fatalf("WriteProgress: %v", err)
+	}
+	if err := s.WriteProgress(Progress{Timestamp: now, Message: "opening PR"}); err != nil {
+		t.Fatalf("WriteProgress: %v", err)
+	}
+
+	got, err := s.ReadProgress()
+	if err != nil {
+		t.Fatalf("ReadProgress: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("ReadProgress returned %d events, want 2", len(got))
+	}
+	if got[0].Message != "tests passing" || got[0].Percent == nil || *got[0].Percent != 50 {
+		t.Errorf("event 0 = %+v, want tests passing at 50%%", got[0])
+	}
+	if got[1].Percent != nil {
+		t.Errorf("event 1 percent = %v, want nil", *got[1].Percent)
+	}
+}
+
 func TestWriteNetworkRequestWithError(t *testing.T) {
 	dir := t.TempDir()
 	s, err := NewRunStore(dir, "run_neterr1")
@@ -744,3 +912,24 @@ func TestMetadata_BuildKitFields(t *testing.T) {
 		t.Errorf("NetworkID: got %q, want %q", loaded.NetworkID, original.NetworkID)
 	}
 }
+
+func TestEnvOverrides(t *testing.T) {
+	s, err := NewRunStore(t.TempDir(), "run_env1")
+	if err != nil {
+		t.Fatalf("NewRunStore: %v", err)
+	}
+	if got, err := s.LoadEnvOverrides(); err != nil || got != nil {
+		t.Fatalf("LoadEnvOverrides before save = %v, %v; want nil, nil", got, err)
+	}
+	want := map[string]string{"DEBUG": "1", "API_URL": "http://localhost:3000"}
+	if err := s.SaveEnvOverrides(want); err != nil {
+		t.Fatalf("SaveEnvOverrides: %v", err)
+	}
+	got, err := s.LoadEnvOverrides()
+	if err != nil {
+		t.Fatalf("LoadEnvOverrides: %v", err)
+	}
+	if len(got) != 2 || got["DEBUG"] != "1" || got["API_URL"] != "http://localhost:3000" {
+		t.Errorf("LoadEnvOverrides = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,156 @@
+package telemetry
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/majorcontext/moat/internal/storage"
+)
+
+// RunTransition describes a run changing state.
+type RunTransition struct {
+	RunID     string
+	Name      string
+	Agent     string
+	From      string
+	To        string
+	Error     string    // set when the run failed
+	CreatedAt time.Time // run creation, the start of its root span
+	StoppedAt time.Time // set when the run stopped or failed
+}
+
+// RunState records a run state transition as a span in the run's trace.
+// Entering "stopped" or "failed" also ends the trace with the run's root
+// span, from creation to stop.
+func RunState(t RunTransition) {
+	if !enabled.Load() || t.From == t.To {
+		return
+	}
+	tr, in := current()
+	now := time.Now()
+	attrs := []attribute.KeyValue{
+		attribute.String("moat.run.id", t.RunID),
+		attribute.String("moat.run.name", t.Name),
+		attribute.String("moat.agent", t.Agent),
+	}
+
+	_, span := tr.Start(runContext(t.RunID), "run "+t.To,
+		trace.WithTimestamp(now),
+		trace.WithAttributes(attrs...),
+		trace.WithAttributes(
+			attribute.String("moat.run.state.from", t.From),
+			attribute.String("moat.run.state", t.To),
+		))
+	if t.Error != "" {
+		span.SetStatus(codes.Error, t.Error)
+	}
+	span.End(trace.WithTimestamp(now))
+
+	in.runTransitions.Add(context.Background(), 1, metric.WithAttributes(
+		attribute.String("moat.run.state", t.To),
+		attribute.String("moat.agent", t.Agent),
+	))
+
+	if t.To != "stopped" && t.To != "failed" {
+		return
+	}
+	start, end := t.CreatedAt, t.StoppedAt
+	if end.IsZero() {
+		end = now
+	}
+	if start.IsZero() || start.After(end) {
+		start = end
+	}
+	ctx := context.WithValue(context.Background(), rootRunKey{}, t.RunID)
+	_, root := tr.Start(ctx, "run "+t.Name,
+		trace.WithNewRoot(),
+		trace.WithTimestamp(start),
+		trace.WithAttributes(attrs...),
+		trace.WithAttributes(attribute.String("moat.run.state", t.To)))
+	if t.To == "failed" {
+		root.SetStatus(codes.Error, t.Error)
+	}
+	root.End(trace.WithTimestamp(end))
+}
+
+// ProxyRequest records a request the proxy handled for runID as a span in
+// the run's trace, with a "credential.injected" event per grant injected.
+func ProxyRequest(runID string, d storage.Decision) {
+	if !enabled.Load() {
+		return
+	}
+	tr, in := current()
+	end := d.Timestamp
+	if end.IsZero() {
+		end = time.Now()
+	}
+	start := end.Add(-time.Duration(d.Duration) * time.Millisecond)
+
+	name := d.Method
+	if name == "" {
+		name = d.Type
+	}
+	attrs := []attribute.KeyValue{
+		attribute.String("moat.run.id", runID),
+		attribute.String("server.address", d.Host),
+		attribute.String("moat.decision", d.Decision),
+		attribute.String("moat.reason", d.Reason),
+	}
+	if d.Method != "" {
+		attrs = append(attrs, attribute.String("http.request.method", d.Method))
+	}
+	if d.Path != "" {
+		attrs = append(attrs, attribute.String("url.path", d.Path))
+	}
+	if d.Type != "" {
+		attrs = append(attrs, attribute.String("moat.request.type", d.Type))
+	}
+	if d.Rule != "" {
+		attrs = append(attrs, attribute.String("moat.rule", d.Rule))
+	}
+	if d.StatusCode != 0 {
+		attrs = append(attrs, attribute.Int("http.response.status_code", d.StatusCode))
+	}
+	if d.RequestID != "" {
+		attrs = append(attrs, attribute.String("moat.request.id", d.RequestID))
+	}
+
+	_, span := tr.Start(runContext(runID), name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithTimestamp(start),
+		trace.WithAttributes(attrs...))
+	for _, grant := range d.Grants {
+		span.AddEvent("credential.injected", trace.WithTimestamp(start), trace.WithAttributes(
+			attribute.String("moat.grant", grant),
+			attribute.StringSlice("moat.injected_headers", d.Injected),
+		))
+		in.injections.Add(context.Background(), 1, metric.WithAttributes(
+			attribute.String("moat.grant", grant),
+			attribute.String("server.address", d.Host),
+		))
+	}
+	switch {
+	case d.Decision == "deny":
+		span.SetStatus(codes.Error, "denied: "+d.Reason)
+	case d.Error != "":
+		span.SetStatus(codes.Error, d.Error)
+	case d.StatusCode >= 500:
+		span.SetStatus(codes.Error, "HTTP "+strconv.Itoa(d.StatusCode))
+	}
+	span.End(trace.WithTimestamp(end))
+
+	ctx := context.Background()
+	in.requests.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("moat.decision", d.Decision),
+		attribute.String("server.address", d.Host),
+	))
+	in.requestDuration.Record(ctx, float64(d.Duration), metric.WithAttributes(
+		attribute.String("moat.decision", d.Decision),
+	))
+}
@@ -0,0 +1,224 @@
+// Package telemetry exports run lifecycle and proxy events as OpenTelemetry
+// traces and metrics over OTLP/HTTP.
+//
+// Export is off unless MOAT_OTEL_ENDPOINT is set; until Init enables it,
+// every recording function is a cheap no-op. Each run gets one trace whose
+// ID is derived from the run ID, so the CLI (run state transitions) and the
+// proxy daemon (proxied requests) contribute spans to the same trace
+// without passing context between processes.
+package telemetry
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/metric"
+	metricnoop "go.opentelemetry.io/otel/metric/noop"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	tracenoop "go.opentelemetry.io/otel/trace/noop"
+)
+
+// EnvEndpoint names the environment variable holding the OTLP/HTTP endpoint,
+// e.g. http://localhost:4318. Traces go to /v1/traces and metrics to
+// /v1/metrics under it. The standard OTEL_EXPORTER_OTLP_HEADERS variable
+// adds headers, such as an API key.
+const EnvEndpoint = "MOAT_OTEL_ENDPOINT"
+
+const instrumentationName = "github.com/majorcontext/moat"
+
+// instruments are the metrics moat records.
+type instruments struct {
+	runTransitions  metric.Int64Counter
+	requests        metric.Int64Counter
+	requestDuration metric.Float64Histogram
+	injections      metric.Int64Counter
+}
+
+var (
+	enabled atomic.Bool
+
+	mu     sync.RWMutex
+	tracer trace.Tracer = tracenoop.NewTracerProvider().Tracer(instrumentationName)
+	inst                = mustInstruments(metricnoop.NewMeterProvider().Meter(instrumentationName))
+)
+
+// Init starts exporting to the endpoint in MOAT_OTEL_ENDPOINT, labelling
+// everything with component ("cli" or "daemon") and version. It returns a
+// function that flushes pending telemetry and stops export. With the
+// variable unset, Init does nothing and the returned function is a no-op.
+func Init(ctx context.Context, component, version string) (shutdown func(context.Context) error, err error) {
+	noop := func(context.Context) error { return nil }
+	endpoint := strings.TrimSpace(envLookup(EnvEndpoint))
+	if endpoint == "" {
+		return noop, nil
+	}
+	tracesURL, metricsURL, err := endpointURLs(endpoint)
+	if err != nil {
+		return noop, err
+	}
+
+	traceExp, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(tracesURL))
+	if err != nil {
+		return noop, fmt.Errorf("creating OTLP trace exporter: %w", err)
+	}
+	metricExp, err := otlpmetrichttp.New(ctx, otlpmetrichttp.WithEndpointURL(metricsURL))
+	if err != nil {
+		return noop, fmt.Errorf("creating OTLP metric exporter: %w", err)
+	}
+
+	res := resource.NewSchemaless(
+		attribute.String("service.name", "moat"),
+		attribute.String("service.version", version),
+		attribute.String("moat.component", component),
+	)
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(traceExp),
+		sdktrace.WithResource(res),
+		sdktrace.WithIDGenerator(runIDGenerator{}),
+	)
+	mp := sdkmetric.NewMeterProvider(
+		sdkmetric.WithReader(sdkmetric.NewPeriodicReader(metricExp)),
+		sdkmetric.WithResource(res),
+	)
+	if err := install(tp, mp); err != nil {
+		_ = tp.Shutdown(ctx)
+		_ = mp.Shutdown(ctx)
+		return noop, err
+	}
+
+	return func(ctx context.Context) error {
+		enabled.Store(false)
+		return errors.Join(tp.Shutdown(ctx), mp.Shutdown(ctx))
+	}, nil
+}
+
+// envLookup reads environment variables (replaceable for testing).
+var envLookup = os.Getenv
+
+// install makes tp and mp the destination for recorded telemetry.
+func install(tp trace.TracerProvider, mp metric.MeterProvider) error {
+	in, err := newInstruments(mp.Meter(instrumentationName))
+	if err != nil {
+		return err
+	}
+	mu.Lock()
+	tracer = tp.Tracer(instrumentationName)
+	inst = in
+	mu.Unlock()
+	enabled.Store(true)
+	return nil
+}
+
+func current() (trace.Tracer, *instruments) {
+	mu.RLock()
+	defer mu.RUnlock()
+	return tracer, inst
+}
+
+// endpointURLs returns the trace and metric URLs under an OTLP/HTTP base
+// endpoint. A bare host:port is taken as plain HTTP.
+func endpointURLs(endpoint string) (traces, metrics string, err error) {
+	if !strings.Contains(endpoint, "://") {
+		endpoint = "http://" + endpoint
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return "", "", fmt.Errorf("%s must be an http(s) URL such as http://localhost:4318, got %q", EnvEndpoint, endpoint)
+	}
+	base := strings.TrimRight(u.String(), "/")
+	return base + "/v1/traces", base + "/v1/metrics", nil
+}
+
+func newInstruments(m metric.Meter) (*instruments, error) {
+	var in instruments
+	var errs []error
+	var err error
+	in.runTransitions, err = m.Int64Counter("moat.run.transitions",
+		metric.WithDescription("Run state transitions, by the state entered"))
+	errs = append(errs, err)
+	in.requests, err = m.Int64Counter("moat.proxy.requests",
+		metric.WithDescription("Requests the proxy handled, by decision and host"))
+	errs = append(errs, err)
+	in.requestDuration, err = m.Float64Histogram("moat.proxy.request.duration",
+		metric.WithDescription("Duration of proxied requests"), metric.WithUnit("ms"))
+	errs = append(errs, err)
+	in.injections, err = m.Int64Counter("moat.credential.injections",
+		metric.WithDescription("Credentials the proxy injected into requests, by grant and host"))
+	errs = append(errs, err)
+	if err := errors.Join(errs...); err != nil {
+		return nil, fmt.Errorf("creating metrics: %w", err)
+	}
+	return &in, nil
+}
+
+func mustInstruments(m metric.Meter) *instruments {
+	in, err := newInstruments(m)
+	if err != nil {
+		panic(err)
+	}
+	return in
+}
+
+// runIDs derives a run's trace ID and the span ID of its root span from the
+// run ID, so every process reports into the same trace.
+func runIDs(runID string) (trace.TraceID, trace.SpanID) {
+	sum := sha256.Sum256([]byte("moat-run:" + runID))
+	var tid trace.TraceID
+	var sid trace.SpanID
+	copy(tid[:], sum[:16])
+	copy(sid[:], sum[16:24])
+	return tid, sid
+}
+
+// runContext returns a context whose parent span is runID's root span.
+func runContext(runID string) context.Context {
+	tid, sid := runIDs(runID)
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    tid,
+		SpanID:     sid,
+		TraceFlags: trace.FlagsSampled,
+		Remote:     true,
+	})
+	return trace.ContextWithRemoteSpanContext(context.Background(), sc)
+}
+
+type rootRunKey struct{}
+
+// runIDGenerator gives a run's root span its derived IDs and every other
+// span random ones.
+type runIDGenerator struct{}
+
+func (runIDGenerator) NewIDs(ctx context.Context) (trace.TraceID, trace.SpanID) {
+	if runID, ok := ctx.Value(rootRunKey{}).(string); ok {
+		return runIDs(runID)
+	}
+	var tid trace.TraceID
+	randRead(tid[:])
+	return tid, randomSpanID()
+}
+
+func (runIDGenerator) NewSpanID(context.Context, trace.TraceID) trace.SpanID {
+	return randomSpanID()
+}
+
+func randomSpanID() trace.SpanID {
+	var sid trace.SpanID
+	randRead(sid[:])
+	return sid
+}
+
+func randRead(b []byte) { _, _ = rand.Read(b) }
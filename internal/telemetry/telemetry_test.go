@@ -0,0 +1,250 @@
+package telemetry
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	metricnoop "go.opentelemetry.io/otel/metric/noop"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	tracenoop "go.opentelemetry.io/otel/trace/noop"
+
+	"github.com/majorcontext/moat/internal/storage"
+)
+
+// record installs in-memory providers for the test and returns the span
+// recorder and metric reader.
+func record(t *testing.T) (*tracetest.SpanRecorder, *sdkmetric.ManualReader) {
+	t.Helper()
+	spans := tracetest.NewSpanRecorder()
+	reader := sdkmetric.NewManualReader()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans), sdktrace.WithIDGenerator(runIDGenerator{}))
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	if err := install(tp, mp); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := install(tracenoop.NewTracerProvider(), metricnoop.NewMeterProvider()); err != nil {
+			t.Fatal(err)
+		}
+		enabled.Store(false)
+	})
+	return spans, reader
+}
+
+func attr(attrs []attribute.KeyValue, key string) string {
+	for _, kv := range attrs {
+		if string(kv.Key) == key {
+			return kv.Value.Emit()
+		}
+	}
+	return ""
+}
+
+func counterSum(t *testing.T, reader *sdkmetric.ManualReader, name string) int64 {
+	t.Helper()
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatal(err)
+	}
+	var total int64
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name != name {
+				continue
+			}
+			for _, dp := range m.Data.(metricdata.Sum[int64]).DataPoints {
+				total += dp.Value
+			}
+		}
+	}
+	return total
+}
+
+func TestEndpointURLs(t *testing.T) {
+	tests := []struct {
+		in, traces string
+		wantErr    bool
+	}{
+		{"http://localhost:4318", "http://localhost:4318/v1/traces", false},
+		{"localhost:4318/", "http://localhost:4318/v1/traces", false},
+		{"https://otlp.example.com/otlp", "https://otlp.example.com/otlp/v1/traces", false},
+		{"grpc://localhost:4317", "", true},
+		{"http://", "", true},
+	}
+	for _, tt := range tests {
+		traces, metrics, err := endpointURLs(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("endpointURLs(%q) err = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if traces != tt.traces {
+			t.Errorf("endpointURLs(%q) traces = %q, want %q", tt.in, traces, tt.traces)
+		}
+		if !tt.wantErr && metrics != tt.traces[:len(tt.traces)-len("traces")]+"metrics" {
+			t.Errorf("endpointURLs(%q) metrics = %q", tt.in, metrics)
+		}
+	}
+}
+
+func TestInit_Disabled(t *testing.T) {
+	envLookup = func(string) string { return "" }
+	t.Cleanup(func() { envLookup = os.Getenv })
+
+	shutdown, err := Init(context.Background(), "cli", "dev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if enabled.Load() {
+		t.Error("export enabled without an endpoint")
+	}
+	if err := shutdown(context.Background()); err != nil {
+		t.Error(err)
+	}
+	// Recording without export is a no-op.
+	RunState(RunTransition{RunID: "run_1", From: "created", To: "running"})
+}
+
+func TestInit_BadEndpoint(t *testing.T) {
+	envLookup = func(string) string { return "ftp://example.com" }
+	t.Cleanup(func() { envLookup = os.Getenv })
+
+	if _, err := Init(context.Background(), "cli", "dev"); err == nil {
+		t.Error("Init accepted an ftp endpoint")
+	}
+	if enabled.Load() {
+		t.Error("export enabled after a bad endpoint")
+	}
+}
+
+func TestInit_Exports(t *testing.T) {
+	var mu sync.Mutex
+	paths := map[string]int{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths[r.Method+" "+r.URL.Path]++
+		mu.Unlock()
+	}))
+	defer srv.Close()
+	envLookup = func(string) string { return srv.URL }
+	t.Cleanup(func() { envLookup = os.Getenv })
+
+	shutdown, err := Init(context.Background(), "daemon", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ProxyRequest("run_1", storage.Decision{Method: "GET", Host: "example.com", Decision: "allow"})
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = install(tracenoop.NewTracerProvider(), metricnoop.NewMeterProvider())
+		enabled.Store(false)
+	})
+
+	mu.Lock()
+	defer mu.Unlock()
+	if paths["POST /v1/traces"] == 0 || paths["POST /v1/metrics"] == 0 {
+		t.Errorf("exported to %v, want traces and metrics", paths)
+	}
+	if enabled.Load() {
+		t.Error("export still enabled after shutdown")
+	}
+}
+
+func TestRunState(t *testing.T) {
+	spans, reader := record(t)
+	created := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	stopped := created.Add(10 * time.Minute)
+
+	RunState(RunTransition{RunID: "run_1", Name: "my-agent", Agent: "claude-code", From: "created", To: "running", CreatedAt: created})
+	RunState(RunTransition{RunID: "run_1", From: "running", To: "running"}) // no change
+	RunState(RunTransition{RunID: "run_1", Name: "my-agent", Agent: "claude-code", From: "running", To: "failed",
+		Error: "exit 1", CreatedAt: created, StoppedAt: stopped})
+
+	ended := spans.Ended()
+	if len(ended) != 3 {
+		t.Fatalf("got %d spans, want 2 transitions and the root", len(ended))
+	}
+	tid, rootID := runIDs("run_1")
+	for _, s := range ended {
+		if s.SpanContext().TraceID() != tid {
+			t.Errorf("span %q trace ID = %s, want the run's", s.Name(), s.SpanContext().TraceID())
+		}
+	}
+	if got := ended[0]; got.Name() != "run running" || got.Parent().SpanID() != rootID {
+		t.Errorf("transition span = %q parent %s, want child of the root", got.Name(), got.Parent().SpanID())
+	}
+	if got := ended[1]; got.Status().Code != codes.Error || attr(got.Attributes(), "moat.run.state.from") != "running" {
+		t.Errorf("failed transition = %+v", got.Attributes())
+	}
+	root := ended[2]
+	if root.SpanContext().SpanID() != rootID || root.Parent().IsValid() {
+		t.Errorf("root span ID = %s, parent valid = %v", root.SpanContext().SpanID(), root.Parent().IsValid())
+	}
+	if !root.StartTime().Equal(created) || !root.EndTime().Equal(stopped) {
+		t.Errorf("root span %s–%s, want creation to stop", root.StartTime(), root.EndTime())
+	}
+	if root.Name() != "run my-agent" || root.Status().Code != codes.Error {
+		t.Errorf("root span = %q status %v", root.Name(), root.Status())
+	}
+
+	if got := counterSum(t, reader, "moat.run.transitions"); got != 2 {
+		t.Errorf("moat.run.transitions = %d, want 2", got)
+	}
+}
+
+func TestProxyRequest(t *testing.T) {
+	spans, reader := record(t)
+	ts := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	ProxyRequest("run_1", storage.Decision{
+		Timestamp: ts, Method: "GET", Host: "api.github.com", Path: "/repos",
+		Decision: "allow", Reason: storage.DecisionRule, Grants: []string{"github"},
+		Injected: []string{"authorization"}, StatusCode: 200, Duration: 250,
+	})
+	ProxyRequest("run_1", storage.Decision{
+		Timestamp: ts, Type: "connect", Host: "evil.example.com",
+		Decision: "deny", Reason: "not allowed by network policy",
+	})
+
+	ended := spans.Ended()
+	if len(ended) != 2 {
+		t.Fatalf("got %d spans, want 2", len(ended))
+	}
+	tid, rootID := runIDs("run_1")
+	get := ended[0]
+	if get.Name() != "GET" || get.SpanContext().TraceID() != tid || get.Parent().SpanID() != rootID {
+		t.Errorf("request span %q not in the run's trace", get.Name())
+	}
+	if d := get.EndTime().Sub(get.StartTime()); d != 250*time.Millisecond {
+		t.Errorf("request span lasted %s, want 250ms", d)
+	}
+	if attr(get.Attributes(), "server.address") != "api.github.com" || attr(get.Attributes(), "http.response.status_code") != "200" {
+		t.Errorf("request attributes = %+v", get.Attributes())
+	}
+	if ev := get.Events(); len(ev) != 1 || ev[0].Name != "credential.injected" || attr(ev[0].Attributes, "moat.grant") != "github" {
+		t.Errorf("events = %+v, want one credential.injected for github", ev)
+	}
+
+	deny := ended[1]
+	if deny.Name() != "connect" || deny.Status().Code != codes.Error {
+		t.Errorf("denied span = %q status %v", deny.Name(), deny.Status())
+	}
+
+	if got := counterSum(t, reader, "moat.proxy.requests"); got != 2 {
+		t.Errorf("moat.proxy.requests = %d, want 2", got)
+	}
+	if got := counterSum(t, reader, "moat.credential.injections"); got != 1 {
+		t.Errorf("moat.credential.injections = %d, want 1", got)
+	}
+}
@@ -0,0 +1,118 @@
+package templates
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// placeholder matches ${{ params.NAME }} and ${{ params.NAME | default "VALUE" }}.
+var placeholder = regexp.MustCompile(`\$\{\{\s*params\.([A-Za-z_][A-Za-z0-9_-]*)\s*(?:\|\s*default\s+"([^"]*)"\s*)?\}\}`)
+
+// Param is a parameter a template refers to.
+type Param struct {
+	Name    string `json:"name"`
+	Default string `json:"default,omitempty"`
+	// Required is true when no placeholder for the parameter has a default.
+	Required bool `json:"required"`
+}
+
+// Params returns the parameters referenced in a template, sorted by name.
+func Params(data []byte) []Param {
+	byName := make(map[string]*Param)
+	for _, m := range placeholder.FindAllSubmatchIndex(data, -1) {
+		name := string(data[m[2]:m[3]])
+		p, ok := byName[name]
+		if !ok {
+			p = &Param{Name: name, Required: true}
+			byName[name] = p
+		}
+		if m[4] >= 0 {
+			p.Default = string(data[m[4]:m[5]])
+			p.Required = false
+		}
+	}
+	params := make([]Param, 0, len(byName))
+	for _, p := range byName {
+		params = append(params, *p)
+	}
+	sort.Slice(params, func(i, j int) bool { return params[i].Name < params[j].Name })
+	return params
+}
+
+// Render substitutes values into the placeholders of a template and returns
+// the resulting moat.yaml. Substitution happens in parsed YAML scalars, so a
+// value can never change the structure of the file. A placeholder in an
+// unquoted scalar takes the type of its value (a number stays a number); in
+// a quoted scalar it is always a string.
+//
+// Every required parameter must have a value, and every value must name a
+// parameter the template uses, so a misspelled --param fails instead of
+// being ignored.
+func Render(data []byte, values map[string]string) ([]byte, error) {
+	resolved := make(map[string]string)
+	var missing []string
+	for _, p := range Params(data) {
+		v, ok := values[p.Name]
+		switch {
+		case ok:
+			resolved[p.Name] = v
+		case p.Required:
+			missing = append(missing, p.Name)
+		default:
+			resolved[p.Name] = p.Default
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("missing template parameters: %s (pass --param %s=VALUE)", strings.Join(missing, ", "), missing[0])
+	}
+	var unknown []string
+	for name := range values {
+		if _, ok := resolved[name]; !ok {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, fmt.Errorf("the template has no parameter %s", strings.Join(unknown, ", "))
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	substitute(&doc, resolved)
+	return yaml.Marshal(&doc)
+}
+
+// substitute replaces placeholders in every scalar under n.
+func substitute(n *yaml.Node, values map[string]string) {
+	if n.Kind == yaml.ScalarNode && placeholder.MatchString(n.Value) {
+		n.Value = placeholder.ReplaceAllStringFunc(n.Value, func(s string) string {
+			return values[placeholder.FindStringSubmatch(s)[1]]
+		})
+		if n.Style == 0 {
+			// Let the value decide the type, as if it had been typed in.
+			n.Tag = ""
+		}
+	}
+	for _, c := range n.Content {
+		substitute(c, values)
+	}
+}
+
+// ParseParams parses --param KEY=VALUE flags.
+func ParseParams(specs []string) (map[string]string, error) {
+	values := make(map[string]string, len(specs))
+	for _, s := range specs {
+		k, v, ok := strings.Cut(s, "=")
+		if !ok || k == "" {
+			return nil, fmt.Errorf("invalid --param %q: expected KEY=VALUE", s)
+		}
+		values[k] = v
+	}
+	return values, nil
+}
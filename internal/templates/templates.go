@@ -0,0 +1,201 @@
+// Package templates finds and instantiates run templates: moat.yaml files
+// with ${{ params.NAME }} placeholders, kept in a directory or a git
+// repository so a team can share one agent configuration.
+package templates
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/majorcontext/moat/internal/config"
+)
+
+// validName matches template names: a file name without .yaml.
+var validName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// Template is a template found in a source.
+type Template struct {
+	Name   string
+	Source string // source the template came from, as configured
+	Path   string // template file
+}
+
+// Description returns the template's leading comment, which by convention
+// says what the template is for.
+func (t Template) Description() string {
+	data, err := os.ReadFile(t.Path)
+	if err != nil {
+		return ""
+	}
+	var lines []string
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		line, ok := strings.CutPrefix(sc.Text(), "#")
+		if !ok {
+			break
+		}
+		lines = append(lines, strings.TrimSpace(line))
+	}
+	return strings.TrimSpace(strings.Join(lines, " "))
+}
+
+// Instantiate renders the template with values and parses the result.
+func (t Template) Instantiate(values map[string]string) (*config.Config, error) {
+	data, err := os.ReadFile(t.Path)
+	if err != nil {
+		return nil, fmt.Errorf("reading template %s: %w", t.Name, err)
+	}
+	rendered, err := Render(data, values)
+	if err != nil {
+		return nil, fmt.Errorf("template %s: %w", t.Name, err)
+	}
+	return config.Parse(rendered, "template "+t.Name)
+}
+
+// Dir returns the local template directory, <GlobalConfigDir>/templates.
+func Dir() string {
+	return filepath.Join(config.GlobalConfigDir(), "templates")
+}
+
+// Sources returns the sources searched for templates: Dir, then each
+// configured source in order.
+func Sources(configured []string) []string {
+	return append([]string{Dir()}, configured...)
+}
+
+// IsGit reports whether source is a git repository URL rather than a
+// directory.
+func IsGit(source string) bool {
+	return strings.Contains(source, "://") || strings.HasPrefix(source, "git@")
+}
+
+// cloneDir returns where a git source is cloned.
+func cloneDir(source string) string {
+	sum := sha256.Sum256([]byte(source))
+	return filepath.Join(config.GlobalConfigDir(), "cache", "templates", hex.EncodeToString(sum[:6]))
+}
+
+// sourceDir returns the local directory holding source's templates,
+// cloning a git source the first time it is used.
+func sourceDir(ctx context.Context, source string) (string, error) {
+	if !IsGit(source) {
+		if rest, ok := strings.CutPrefix(source, "~/"); ok {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return "", err
+			}
+			return filepath.Join(home, rest), nil
+		}
+		return source, nil
+	}
+	dir := cloneDir(source)
+	if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+		return dir, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(dir), 0o700); err != nil {
+		return "", err
+	}
+	if out, err := git(ctx, "clone", "--depth", "1", "--no-recurse-submodules", source, dir); err != nil {
+		os.RemoveAll(dir)
+		return "", fmt.Errorf("git clone %s: %w\n%s", source, err, out)
+	}
+	return dir, nil
+}
+
+// Update pulls the latest templates for every git source.
+func Update(ctx context.Context, sources []string) error {
+	for _, source := range sources {
+		if !IsGit(source) {
+			continue
+		}
+		dir := cloneDir(source)
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err != nil {
+			if _, err := sourceDir(ctx, source); err != nil {
+				return err
+			}
+			continue
+		}
+		if out, err := git(ctx, "-C", dir, "pull", "--ff-only"); err != nil {
+			return fmt.Errorf("git pull %s: %w\n%s", source, err, out)
+		}
+	}
+	return nil
+}
+
+func git(ctx context.Context, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	// Fail instead of prompting for credentials for a private repository.
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	return cmd.CombinedOutput()
+}
+
+// templatesIn returns the templates in dir: <name>.yaml files and
+// <name>/moat.yaml directories.
+func templatesIn(dir, source string) []Template {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var found []Template
+	for _, e := range entries {
+		var name, path string
+		if e.IsDir() {
+			name, path = e.Name(), filepath.Join(dir, e.Name(), config.ConfigFilename)
+			if _, err := os.Stat(path); err != nil {
+				continue
+			}
+		} else if n, ok := strings.CutSuffix(e.Name(), ".yaml"); ok {
+			name, path = n, filepath.Join(dir, e.Name())
+		}
+		if validName.MatchString(name) {
+			found = append(found, Template{Name: name, Source: source, Path: path})
+		}
+	}
+	return found
+}
+
+// List returns the templates in sources. When two sources have a template
+// with the same name, the earlier source wins.
+func List(ctx context.Context, sources []string) ([]Template, error) {
+	seen := make(map[string]bool)
+	var all []Template
+	for _, source := range sources {
+		dir, err := sourceDir(ctx, source)
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range templatesIn(dir, source) {
+			if !seen[t.Name] {
+				seen[t.Name] = true
+				all = append(all, t)
+			}
+		}
+	}
+	return all, nil
+}
+
+// Find returns the template called name from the first source that has it.
+func Find(ctx context.Context, sources []string, name string) (Template, error) {
+	if !validName.MatchString(name) {
+		return Template{}, fmt.Errorf("invalid template name %q", name)
+	}
+	all, err := List(ctx, sources)
+	if err != nil {
+		return Template{}, err
+	}
+	for _, t := range all {
+		if t.Name == name {
+			return t, nil
+		}
+	}
+	return Template{}, fmt.Errorf("template %q not found in %s (see 'moat template list')", name, strings.Join(sources, ", "))
+}
@@ -0,0 +1,117 @@
+package templates
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const codeReview = `# Review a pull request and post comments.
+grants:
+  - github
+env:
+  REPO: ${{ params.repo }}
+  BRANCH: '${{ params.branch | default "main" }}'
+container:
+  memory: ${{ params.memory | default "4096" }}
+command: ["review", "--repo", "${{ params.repo }}"]
+`
+
+func TestParams(t *testing.T) {
+	got := Params([]byte(codeReview))
+	want := []Param{
+		{Name: "branch", Default: "main"},
+		{Name: "memory", Default: "4096"},
+		{Name: "repo", Required: true},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Params() = %+v, want %+v", got, want)
+	}
+}
+
+func TestInstantiate(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "code-review.yaml")
+	if err := os.WriteFile(path, []byte(codeReview), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	tmpl := Template{Name: "code-review", Path: path}
+
+	cfg, err := tmpl.Instantiate(map[string]string{"repo": "acme/api: v2"})
+	if err != nil {
+		t.Fatalf("Instantiate() = %v", err)
+	}
+	if cfg.Env["REPO"] != "acme/api: v2" || cfg.Env["BRANCH"] != "main" {
+		t.Errorf("env = %v, want the value kept as one string and the default branch", cfg.Env)
+	}
+	if cfg.Container.Memory != 4096 {
+		t.Errorf("container.memory = %d, want the default parsed as a number", cfg.Container.Memory)
+	}
+	if want := []string{"review", "--repo", "acme/api: v2"}; !reflect.DeepEqual(cfg.Command, want) {
+		t.Errorf("command = %v, want %v", cfg.Command, want)
+	}
+	if got := tmpl.Description(); got != "Review a pull request and post comments." {
+		t.Errorf("Description() = %q", got)
+	}
+
+	if _, err := tmpl.Instantiate(nil); err == nil || !strings.Contains(err.Error(), "repo") {
+		t.Errorf("Instantiate() without repo = %v, want a missing parameter error", err)
+	}
+	if _, err := tmpl.Instantiate(map[string]string{"repo": "x", "rpeo": "y"}); err == nil || !strings.Contains(err.Error(), "rpeo") {
+		t.Errorf("Instantiate() with a misspelled parameter = %v, want an error naming it", err)
+	}
+}
+
+func TestFind(t *testing.T) {
+	first, second := t.TempDir(), t.TempDir()
+	write := func(path, content string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(filepath.Join(first, "review.yaml"), "# first\n")
+	write(filepath.Join(second, "review.yaml"), "# second\n")
+	write(filepath.Join(second, "triage", "moat.yaml"), "# triage\n")
+	write(filepath.Join(second, "notes.txt"), "not a template\n")
+
+	sources := []string{first, second}
+	all, err := List(context.Background(), sources)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, tmpl := range all {
+		names = append(names, tmpl.Name)
+	}
+	if want := []string{"review", "triage"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("List() names = %v, want %v", names, want)
+	}
+
+	tmpl, err := Find(context.Background(), sources, "review")
+	if err != nil || tmpl.Source != first {
+		t.Errorf("Find(review) = %+v, %v; want the first source's template", tmpl, err)
+	}
+	if _, err := Find(context.Background(), sources, "missing"); err == nil {
+		t.Error("Find(missing) succeeded")
+	}
+	if _, err := Find(context.Background(), sources, "../etc/passwd"); err == nil {
+		t.Error("Find() accepted a path as a name")
+	}
+}
+
+func TestParseParams(t *testing.T) {
+	got, err := ParseParams([]string{"repo=acme/api", "query=a=b"})
+	if err != nil || got["repo"] != "acme/api" || got["query"] != "a=b" {
+		t.Errorf("ParseParams() = %v, %v", got, err)
+	}
+	if _, err := ParseParams([]string{"repo"}); err == nil {
+		t.Error("ParseParams() accepted a value without =")
+	}
+}
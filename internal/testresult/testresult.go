@@ -0,0 +1,156 @@
+// Package testresult detects test runner output in captured run logs and
+// reduces it to pass/fail counts.
+//
+// Supported formats:
+//   - go test -v: "--- PASS: TestX", "--- FAIL: TestX", "--- SKIP: TestX" (subtests included)
+//   - jest: the "Tests: 1 failed, 2 skipped, 10 passed, 13 total" summary line
+//   - pytest: the "==== 1 failed, 10 passed, 2 skipped in 1.23s ====" summary line
+//
+// Jest and pytest print a summary per invocation; when a run invokes the same
+// runner more than once, each summary is added to the total.
+package testresult
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Framework names recorded in Summary.Frameworks.
+const (
+	FrameworkGo     = "go"
+	FrameworkJest   = "jest"
+	FrameworkPytest = "pytest"
+)
+
+// Summary holds aggregated test counts for a run.
+type Summary struct {
+	// Frameworks lists the test runners detected, in first-seen order.
+	Frameworks []string `json:"frameworks"`
+	Passed     int      `json:"passed"`
+	Failed     int      `json:"failed"`
+	Skipped    int      `json:"skipped,omitempty"`
+}
+
+// OK reports whether no test failed.
+func (s *Summary) OK() bool {
+	return s == nil || s.Failed == 0
+}
+
+// String returns a short human-readable summary, e.g. "12 passed, 1 failed".
+func (s *Summary) String() string {
+	if s == nil {
+		return ""
+	}
+	parts := []string{fmt.Sprintf("%d passed", s.Passed)}
+	if s.Failed > 0 {
+		parts = append(parts, fmt.Sprintf("%d failed", s.Failed))
+	}
+	if s.Skipped > 0 {
+		parts = append(parts, fmt.Sprintf("%d skipped", s.Skipped))
+	}
+	return strings.Join(parts, ", ")
+}
+
+var (
+	ansiRe = regexp.MustCompile(`\x1b\[[0-9;?]*[A-Za-z]`)
+
+	goTestRe = regexp.MustCompile(`^\s*--- (PASS|FAIL|SKIP): \S`)
+
+	// jestRe matches "Tests:       1 failed, 10 passed, 11 total".
+	jestRe = regexp.MustCompile(`^Tests:\s+(.+),\s+\d+ total\s*$`)
+
+	// pytestRe matches "===== 1 failed, 10 passed in 0.12s =====". pytest
+	// also appends a duration like "(0:01:02)" for long runs.
+	pytestRe = regexp.MustCompile(`^=+ (.+) in [\d.]+s(?: \([\d:]+\))? =+$`)
+
+	// countRe matches one "<n> <word>" term in a jest/pytest summary.
+	countRe = regexp.MustCompile(`(\d+) (\w+)`)
+)
+
+// Parser accumulates test results line by line.
+// The zero value is ready to use.
+type Parser struct {
+	sum   Summary
+	found bool
+}
+
+// Add feeds one log line to the parser.
+func (p *Parser) Add(line string) {
+	line = strings.TrimRight(ansiRe.ReplaceAllString(line, ""), "\r")
+
+	if m := goTestRe.FindStringSubmatch(line); m != nil {
+		p.record(FrameworkGo)
+		switch m[1] {
+		case "PASS":
+			p.sum.Passed++
+		case "FAIL":
+			p.sum.Failed++
+		case "SKIP":
+			p.sum.Skipped++
+		}
+		return
+	}
+	if m := jestRe.FindStringSubmatch(line); m != nil {
+		p.record(FrameworkJest)
+		p.addCounts(m[1])
+		return
+	}
+	if m := pytestRe.FindStringSubmatch(line); m != nil {
+		// "no tests ran in 0.01s" matches the shape but carries no counts.
+		if countRe.MatchString(m[1]) {
+			p.record(FrameworkPytest)
+			p.addCounts(m[1])
+		}
+	}
+}
+
+// Summary returns the accumulated results, or nil if no test output was seen.
+func (p *Parser) Summary() *Summary {
+	if !p.found {
+		return nil
+	}
+	s := p.sum
+	s.Frameworks = slices.Clone(p.sum.Frameworks)
+	return &s
+}
+
+func (p *Parser) record(framework string) {
+	p.found = true
+	if !slices.Contains(p.sum.Frameworks, framework) {
+		p.sum.Frameworks = append(p.sum.Frameworks, framework)
+	}
+}
+
+// addCounts adds the terms of a jest/pytest summary ("1 failed, 10 passed").
+// pytest "error"/"errors" (collection or fixture errors) count as failures;
+// "xfailed" counts as skipped. Other terms (warnings, deselected, rerun,
+// todo) are ignored.
+func (p *Parser) addCounts(terms string) {
+	for _, m := range countRe.FindAllStringSubmatch(terms, -1) {
+		n, err := strconv.Atoi(m[1])
+		if err != nil {
+			continue
+		}
+		switch m[2] {
+		case "passed", "xpassed":
+			p.sum.Passed += n
+		case "failed", "error", "errors":
+			p.sum.Failed += n
+		case "skipped", "xfailed":
+			p.sum.Skipped += n
+		}
+	}
+}
+
+// Parse returns the test summary for a set of log lines, or nil if none of
+// the lines contain recognized test output.
+func Parse(lines []string) *Summary {
+	var p Parser
+	for _, line := range lines {
+		p.Add(line)
+	}
+	return p.Summary()
+}
@@ -0,0 +1,137 @@
+package testresult
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseGoTest(t *testing.T) {
+	lines := []string{
+		"=== RUN   TestA",
+		"--- PASS: TestA (0.00s)",
+		"=== RUN   TestB",
+		"=== RUN   TestB/sub",
+		"    --- FAIL: TestB/sub (0.01s)",
+		"--- FAIL: TestB (0.01s)",
+		"--- SKIP: TestC (0.00s)",
+		"FAIL",
+		"FAIL\texample.com/pkg\t0.123s",
+	}
+	got := Parse(lines)
+	if got == nil {
+		t.Fatal("Parse returned nil for go test output")
+	}
+	if got.Passed != 1 || got.Failed != 2 || got.Skipped != 1 {
+		t.Errorf("counts = %+v, want 1 passed, 2 failed, 1 skipped", got)
+	}
+	if !slices.Equal(got.Frameworks, []string{FrameworkGo}) {
+		t.Errorf("frameworks = %v, want [go]", got.Frameworks)
+	}
+	if got.OK() {
+		t.Error("OK() should be false when tests failed")
+	}
+}
+
+func TestParseJest(t *testing.T) {
+	lines := []string{
+		"PASS src/a.test.js",
+		"Test Suites: 1 failed, 1 passed, 2 total",
+		"Tests:       1 failed, 2 skipped, 10 passed, 13 total",
+		"Snapshots:   0 total",
+	}
+	got := Parse(lines)
+	if got == nil {
+		t.Fatal("Parse returned nil for jest output")
+	}
+	// "Test Suites:" must not be counted as tests.
+	if got.Passed != 10 || got.Failed != 1 || got.Skipped != 2 {
+		t.Errorf("counts = %+v, want 10 passed, 1 failed, 2 skipped", got)
+	}
+	if !slices.Equal(got.Frameworks, []string{FrameworkJest}) {
+		t.Errorf("frameworks = %v, want [jest]", got.Frameworks)
+	}
+}
+
+func TestParsePytest(t *testing.T) {
+	lines := []string{
+		"tests/test_a.py ..F.s",
+		"=================== FAILURES ===================",
+		"========= 1 failed, 3 passed, 1 skipped, 2 warnings, 1 error in 0.52s =========",
+	}
+	got := Parse(lines)
+	if got == nil {
+		t.Fatal("Parse returned nil for pytest output")
+	}
+	// Errors count as failures; warnings are ignored.
+	if got.Passed != 3 || got.Failed != 2 || got.Skipped != 1 {
+		t.Errorf("counts = %+v, want 3 passed, 2 failed, 1 skipped", got)
+	}
+	if !slices.Equal(got.Frameworks, []string{FrameworkPytest}) {
+		t.Errorf("frameworks = %v, want [pytest]", got.Frameworks)
+	}
+}
+
+func TestParsePytestLongDuration(t *testing.T) {
+	got := Parse([]string{"==== 5 passed in 62.10s (0:01:02) ===="})
+	if got == nil || got.Passed != 5 {
+		t.Fatalf("Parse = %+v, want 5 passed", got)
+	}
+	if !got.OK() {
+		t.Error("OK() should be true when no tests failed")
+	}
+}
+
+func TestParseStripsANSI(t *testing.T) {
+	got := Parse([]string{"\x1b[1mTests:\x1b[22m       \x1b[1m\x1b[32m4 passed\x1b[39m\x1b[22m, 4 total"})
+	if got == nil || got.Passed != 4 {
+		t.Fatalf("Parse = %+v, want 4 passed", got)
+	}
+}
+
+func TestParseMultipleFrameworks(t *testing.T) {
+	lines := []string{
+		"--- PASS: TestA (0.00s)",
+		"Tests:       2 passed, 2 total",
+		"==== 1 failed in 0.10s ====",
+	}
+	got := Parse(lines)
+	if got == nil {
+		t.Fatal("Parse returned nil")
+	}
+	if got.Passed != 3 || got.Failed != 1 {
+		t.Errorf("counts = %+v, want 3 passed, 1 failed", got)
+	}
+	want := []string{FrameworkGo, FrameworkJest, FrameworkPytest}
+	if !slices.Equal(got.Frameworks, want) {
+		t.Errorf("frameworks = %v, want %v", got.Frameworks, want)
+	}
+}
+
+func TestParseNoTestOutput(t *testing.T) {
+	lines := []string{
+		"Building project...",
+		"ok  \texample.com/pkg\t0.1s",
+		"==== no tests ran in 0.01s ====",
+		"==== FAILURES ====",
+	}
+	if got := Parse(lines); got != nil {
+		t.Errorf("Parse = %+v, want nil for output without test results", got)
+	}
+}
+
+func TestSummaryString(t *testing.T) {
+	tests := []struct {
+		s    *Summary
+		want string
+	}{
+		{nil, ""},
+		{&Summary{Passed: 12}, "12 passed"},
+		{&Summary{Passed: 12, Failed: 1}, "12 passed, 1 failed"},
+		{&Summary{Passed: 0, Failed: 2, Skipped: 3}, "0 passed, 2 failed, 3 skipped"},
+	}
+	for _, tt := range tests {
+		if got := tt.s.String(); got != tt.want {
+			t.Errorf("String() = %q, want %q", got, tt.want)
+		}
+	}
+}
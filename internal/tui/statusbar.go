@@ -122,6 +122,10 @@ func runtimeColor(runtime string) string {
 		return fgCyan
 	case "apple":
 		return fgMagenta
+	case "podman":
+		return fgYellow
+	case "kubernetes":
+		return fgGreen
 	default:
 		return ""
 	}
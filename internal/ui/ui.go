@@ -9,13 +9,28 @@ import (
 	"github.com/mattn/go-isatty"
 )
 
-var writer io.Writer = os.Stderr
+var (
+	writer       io.Writer = os.Stderr
+	statusWriter io.Writer = os.Stdout
+	quiet        bool
+)
 
 // SetWriter overrides the output writer (for testing).
 func SetWriter(w io.Writer) {
 	writer = w
 }
 
+// SetQuiet suppresses warnings, info messages, and status lines (--quiet).
+// Errors are always printed.
+func SetQuiet(q bool) {
+	quiet = q
+}
+
+// Quiet reports whether --quiet output is in effect.
+func Quiet() bool {
+	return quiet
+}
+
 // --- Color detection ---
 
 var (
@@ -99,11 +114,17 @@ func InfoTag() string { return Cyan("ℹ") }
 
 // Warn prints a user-facing warning to stderr.
 func Warn(msg string) {
+	if quiet {
+		return
+	}
 	fmt.Fprintf(writer, "%s %s\n", ansiStderr("33", "Warning:"), msg)
 }
 
 // Warnf prints a formatted user-facing warning to stderr.
 func Warnf(format string, args ...any) {
+	if quiet {
+		return
+	}
 	fmt.Fprintf(writer, "%s %s\n", ansiStderr("33", "Warning:"), fmt.Sprintf(format, args...))
 }
 
@@ -119,10 +140,36 @@ func Errorf(format string, args ...any) {
 
 // Info prints a user-facing message to stderr with no prefix.
 func Info(msg string) {
+	if quiet {
+		return
+	}
 	fmt.Fprintf(writer, "%s\n", msg)
 }
 
 // Infof prints a formatted user-facing message to stderr with no prefix.
 func Infof(format string, args ...any) {
+	if quiet {
+		return
+	}
 	fmt.Fprintf(writer, format+"\n", args...)
 }
+
+// --- Status (stdout) ---
+
+// Status prints a progress line to stdout, such as "Initializing..." or
+// "Press Ctrl+C to stop". Scripts that only want a command's result pass
+// --quiet to drop these.
+func Status(msg string) {
+	if quiet {
+		return
+	}
+	fmt.Fprintln(statusWriter, msg)
+}
+
+// Statusf prints a formatted progress line to stdout.
+func Statusf(format string, args ...any) {
+	if quiet {
+		return
+	}
+	fmt.Fprintf(statusWriter, format+"\n", args...)
+}
@@ -81,6 +81,39 @@ func TestInfof(t *testing.T) {
 	}
 }
 
+func TestQuiet(t *testing.T) {
+	var stderr, stdout bytes.Buffer
+	SetWriter(&stderr)
+	defer SetWriter(nil)
+	oldStatus := statusWriter
+	statusWriter = &stdout
+	defer func() { statusWriter = oldStatus }()
+
+	Status("Initializing...")
+	if stdout.String() != "Initializing...\n" {
+		t.Errorf("Status output = %q, want %q", stdout.String(), "Initializing...\n")
+	}
+	stdout.Reset()
+
+	SetQuiet(true)
+	defer SetQuiet(false)
+
+	Warn("warning")
+	Warnf("warning %d", 2)
+	Info("info")
+	Infof("info %d", 2)
+	Status("status")
+	Statusf("status %d", 2)
+	if stderr.Len() != 0 || stdout.Len() != 0 {
+		t.Errorf("quiet mode printed stderr %q, stdout %q", stderr.String(), stdout.String())
+	}
+
+	Error("failed")
+	if stderr.String() != "Error: failed\n" {
+		t.Errorf("Error output in quiet mode = %q, want %q", stderr.String(), "Error: failed\n")
+	}
+}
+
 func TestColorFunctionsEnabled(t *testing.T) {
 	SetColorEnabled(true)
 	defer SetColorEnabled(false)
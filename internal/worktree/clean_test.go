@@ -16,7 +16,7 @@ func TestClean_RemovesWorktree(t *testing.T) {
 	defer os.RemoveAll(wtBase)
 	t.Setenv("MOAT_WORKTREE_BASE", wtBase)
 
-	result, err := Resolve(repoDir, "github.com/acme/myrepo", "to-clean", "myapp")
+	result, err := Resolve(repoDir, "github.com/acme/myrepo", "to-clean", "myapp", Provision{})
 	if err != nil {
 		t.Fatalf("Resolve() error = %v", err)
 	}
@@ -106,11 +106,11 @@ func TestListWorktrees(t *testing.T) {
 
 	repoID := "github.com/acme/myrepo"
 
-	_, err = Resolve(repoDir, repoID, "feat-a", "")
+	_, err = Resolve(repoDir, repoID, "feat-a", "", Provision{})
 	if err != nil {
 		t.Fatalf("Resolve feat-a: %v", err)
 	}
-	_, err = Resolve(repoDir, repoID, "feat-b", "")
+	_, err = Resolve(repoDir, repoID, "feat-b", "", Provision{})
 	if err != nil {
 		t.Fatalf("Resolve feat-b: %v", err)
 	}
@@ -137,11 +137,11 @@ func TestListWorktrees_SlashedBranch(t *testing.T) {
 
 	repoID := "github.com/acme/myrepo"
 
-	_, err = Resolve(repoDir, repoID, "feature/dark-mode", "")
+	_, err = Resolve(repoDir, repoID, "feature/dark-mode", "", Provision{})
 	if err != nil {
 		t.Fatalf("Resolve feature/dark-mode: %v", err)
 	}
-	_, err = Resolve(repoDir, repoID, "simple", "")
+	_, err = Resolve(repoDir, repoID, "simple", "", Provision{})
 	if err != nil {
 		t.Fatalf("Resolve simple: %v", err)
 	}
@@ -0,0 +1,185 @@
+package worktree
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// MergePlan describes merging a worktree branch back into its base branch.
+type MergePlan struct {
+	Branch string
+	Base   string
+	Ahead  int // commits on Branch that are not on Base
+	Behind int // commits on Base that are not on Branch
+
+	// Conflicts lists the files that would conflict. It is only computed
+	// when the merge is not a fast-forward.
+	Conflicts []string
+
+	tree string // merged tree, for a merge that needs a merge commit
+}
+
+// UpToDate reports whether Base already contains every commit of Branch.
+func (p *MergePlan) UpToDate() bool { return p.Ahead == 0 }
+
+// FastForward reports whether Base can be fast-forwarded to Branch.
+func (p *MergePlan) FastForward() bool { return p.Behind == 0 }
+
+// PlanMerge works out what merging branch into base would do, including
+// which files would conflict, without changing the repository.
+func PlanMerge(repoRoot, branch, base string) (*MergePlan, error) {
+	for _, b := range []string{branch, base} {
+		if _, err := gitOutput(repoRoot, "rev-parse", "--verify", "--quiet", "refs/heads/"+b); err != nil {
+			return nil, fmt.Errorf("branch %q not found", b)
+		}
+	}
+	plan := &MergePlan{Branch: branch, Base: base}
+	counts, err := gitOutput(repoRoot, "rev-list", "--left-right", "--count", "refs/heads/"+base+"...refs/heads/"+branch)
+	if err != nil {
+		return nil, err
+	}
+	fields := strings.Fields(counts)
+	if len(fields) != 2 {
+		return nil, fmt.Errorf("unexpected rev-list output %q", counts)
+	}
+	plan.Behind, _ = strconv.Atoi(fields[0])
+	plan.Ahead, _ = strconv.Atoi(fields[1])
+	if plan.UpToDate() || plan.FastForward() {
+		return plan, nil
+	}
+
+	// merge-tree merges in memory: it writes the merged tree to the object
+	// store and exits 1 when there are conflicts, touching no checkout.
+	cmd := exec.Command("git", "merge-tree", "--write-tree", "--name-only", "--no-messages", "refs/heads/"+base, "refs/heads/"+branch)
+	cmd.Dir = repoRoot
+	out, err := cmd.Output()
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+	case errors.As(err, &exitErr) && exitErr.ExitCode() == 1:
+	default:
+		return nil, fmt.Errorf("checking for conflicts (needs git 2.38 or later): %w", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	plan.tree = lines[0]
+	for _, f := range lines[1:] {
+		if f != "" {
+			plan.Conflicts = append(plan.Conflicts, f)
+		}
+	}
+	return plan, nil
+}
+
+// Merge carries out plan. If Base is checked out in repoRoot, it is merged
+// there with git merge, which needs a checkout without uncommitted changes
+// to tracked files; otherwise the branch ref is updated directly. With
+// ffOnly, a merge that needs a merge commit is refused.
+func Merge(repoRoot string, plan *MergePlan, ffOnly bool) error {
+	switch {
+	case plan.UpToDate():
+		return nil
+	case len(plan.Conflicts) > 0:
+		return fmt.Errorf("merging %s into %s would conflict in %s", plan.Branch, plan.Base, strings.Join(plan.Conflicts, ", "))
+	case ffOnly && !plan.FastForward():
+		return fmt.Errorf("%s has %d commit(s) not on %s; not a fast-forward", plan.Base, plan.Behind, plan.Branch)
+	}
+
+	if current, _ := CurrentBranch(repoRoot); current == plan.Base {
+		status, err := gitOutput(repoRoot, "status", "--porcelain", "--untracked-files=no")
+		if err != nil {
+			return err
+		}
+		if status != "" {
+			return fmt.Errorf("%s has uncommitted changes; commit or stash them first", repoRoot)
+		}
+		if plan.FastForward() {
+			return runGit(repoRoot, "merge", "--ff-only", "refs/heads/"+plan.Branch)
+		}
+		return runGit(repoRoot, "merge", "--no-edit", "-m", mergeMessage(plan), "refs/heads/"+plan.Branch)
+	}
+
+	if wt, err := checkedOutIn(repoRoot, plan.Base); err != nil {
+		return err
+	} else if wt != "" {
+		return fmt.Errorf("%s is checked out in %s; merge there or check it out in %s", plan.Base, wt, repoRoot)
+	}
+	baseRev, err := gitOutput(repoRoot, "rev-parse", "refs/heads/"+plan.Base)
+	if err != nil {
+		return err
+	}
+	newRev, err := gitOutput(repoRoot, "rev-parse", "refs/heads/"+plan.Branch)
+	if err != nil {
+		return err
+	}
+	if !plan.FastForward() {
+		newRev, err = gitOutput(repoRoot, "commit-tree", plan.tree, "-p", baseRev, "-p", newRev, "-m", mergeMessage(plan))
+		if err != nil {
+			return err
+		}
+	}
+	// Passing the old value makes the update fail if Base moved since the
+	// plan was made.
+	return runGit(repoRoot, "update-ref", "-m", "moat wt merge", "refs/heads/"+plan.Base, newRev, baseRev)
+}
+
+func mergeMessage(plan *MergePlan) string {
+	return fmt.Sprintf("Merge branch '%s' into %s", plan.Branch, plan.Base)
+}
+
+// CurrentBranch returns the branch checked out in dir, or "" for a
+// detached HEAD.
+func CurrentBranch(dir string) (string, error) {
+	out, err := gitOutput(dir, "rev-parse", "--abbrev-ref", "HEAD")
+	if err != nil {
+		return "", err
+	}
+	if out == "HEAD" {
+		return "", nil
+	}
+	return out, nil
+}
+
+// Dirty reports whether the worktree at dir has uncommitted changes,
+// including untracked files.
+func Dirty(dir string) (bool, error) {
+	out, err := gitOutput(dir, "status", "--porcelain")
+	if err != nil {
+		return false, err
+	}
+	return out != "", nil
+}
+
+// checkedOutIn returns the worktree that has branch checked out, or "".
+func checkedOutIn(repoRoot, branch string) (string, error) {
+	out, err := gitOutput(repoRoot, "worktree", "list", "--porcelain")
+	if err != nil {
+		return "", err
+	}
+	var path string
+	for _, line := range strings.Split(out, "\n") {
+		if p, ok := strings.CutPrefix(line, "worktree "); ok {
+			path = p
+		} else if line == "branch refs/heads/"+branch {
+			return path, nil
+		}
+	}
+	return "", nil
+}
+
+// gitOutput runs a git command in dir and returns its trimmed output.
+func gitOutput(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return "", fmt.Errorf("git %s: %w\n%s", strings.Join(args, " "), err, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", fmt.Errorf("git %s: %w", strings.Join(args, " "), err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
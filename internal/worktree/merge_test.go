@@ -0,0 +1,158 @@
+package worktree
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// commitFile writes name in dir and commits it.
+func commitFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, args := range [][]string{{"add", name}, {"commit", "-m", "update " + name}} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+}
+
+// setupMerge creates a repo on base branch "main" with a worktree for
+// "feature".
+func setupMerge(t *testing.T) (repoDir, wtPath string) {
+	t.Helper()
+	for k, v := range map[string]string{
+		"GIT_AUTHOR_NAME": "Test", "GIT_AUTHOR_EMAIL": "test@test.com",
+		"GIT_COMMITTER_NAME": "Test", "GIT_COMMITTER_EMAIL": "test@test.com",
+	} {
+		t.Setenv(k, v)
+	}
+	repoDir = initTestRepo(t)
+	t.Cleanup(func() { os.RemoveAll(repoDir) })
+	if err := runGit(repoDir, "branch", "-M", "main"); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("MOAT_WORKTREE_BASE", t.TempDir())
+	result, err := Resolve(repoDir, "github.com/acme/myrepo", "feature", "", Provision{})
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	return repoDir, result.WorkspacePath
+}
+
+func TestMerge_FastForward(t *testing.T) {
+	repoDir, wtPath := setupMerge(t)
+	commitFile(t, wtPath, "a.txt", "a")
+
+	plan, err := PlanMerge(repoDir, "feature", "main")
+	if err != nil {
+		t.Fatalf("PlanMerge() error = %v", err)
+	}
+	if plan.Ahead != 1 || plan.Behind != 0 || !plan.FastForward() {
+		t.Fatalf("plan = %+v, want 1 ahead, fast-forward", plan)
+	}
+	if err := Merge(repoDir, plan, true); err != nil {
+		t.Fatalf("Merge() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(repoDir, "a.txt")); err != nil {
+		t.Error("a.txt not in the base checkout after merge")
+	}
+
+	plan, err = PlanMerge(repoDir, "feature", "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !plan.UpToDate() {
+		t.Errorf("plan after merge = %+v, want up to date", plan)
+	}
+}
+
+func TestMerge_MergeCommit(t *testing.T) {
+	repoDir, wtPath := setupMerge(t)
+	commitFile(t, wtPath, "a.txt", "a")
+	commitFile(t, repoDir, "b.txt", "b")
+
+	plan, err := PlanMerge(repoDir, "feature", "main")
+	if err != nil {
+		t.Fatalf("PlanMerge() error = %v", err)
+	}
+	if plan.FastForward() || len(plan.Conflicts) != 0 {
+		t.Fatalf("plan = %+v, want a clean non-fast-forward merge", plan)
+	}
+	if err := Merge(repoDir, plan, true); err == nil {
+		t.Error("Merge(ffOnly) succeeded, want not-a-fast-forward error")
+	}
+	if err := Merge(repoDir, plan, false); err != nil {
+		t.Fatalf("Merge() error = %v", err)
+	}
+	parents, _ := gitOutput(repoDir, "rev-list", "--parents", "-n", "1", "main")
+	if len(strings.Fields(parents)) != 3 {
+		t.Errorf("main head = %q, want a merge commit", parents)
+	}
+}
+
+func TestMerge_Conflict(t *testing.T) {
+	repoDir, wtPath := setupMerge(t)
+	commitFile(t, wtPath, "README.md", "feature")
+	commitFile(t, repoDir, "README.md", "main")
+
+	plan, err := PlanMerge(repoDir, "feature", "main")
+	if err != nil {
+		t.Fatalf("PlanMerge() error = %v", err)
+	}
+	if len(plan.Conflicts) != 1 || plan.Conflicts[0] != "README.md" {
+		t.Fatalf("Conflicts = %v, want [README.md]", plan.Conflicts)
+	}
+	if err := Merge(repoDir, plan, false); err == nil || !strings.Contains(err.Error(), "conflict") {
+		t.Errorf("Merge() error = %v, want conflict error", err)
+	}
+	if status, _ := gitOutput(repoDir, "status", "--porcelain"); status != "" {
+		t.Errorf("base checkout changed by a refused merge:\n%s", status)
+	}
+}
+
+func TestMerge_BaseNotCheckedOut(t *testing.T) {
+	repoDir, wtPath := setupMerge(t)
+	if err := runGit(repoDir, "branch", "release"); err != nil {
+		t.Fatal(err)
+	}
+	commitFile(t, wtPath, "a.txt", "a")
+	commitFile(t, repoDir, "b.txt", "b")
+
+	// feature is not a fast-forward of main; the merge commit is made
+	// without a checkout of main.
+	if err := runGit(repoDir, "checkout", "release"); err != nil {
+		t.Fatal(err)
+	}
+	plan, err := PlanMerge(repoDir, "feature", "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Merge(repoDir, plan, false); err != nil {
+		t.Fatalf("Merge() error = %v", err)
+	}
+	files, _ := gitOutput(repoDir, "ls-tree", "--name-only", "main")
+	if !strings.Contains(files, "a.txt") || !strings.Contains(files, "b.txt") {
+		t.Errorf("main tree = %q, want a.txt and b.txt", files)
+	}
+	if status, _ := gitOutput(repoDir, "status", "--porcelain"); status != "" {
+		t.Errorf("checkout changed by a ref-only merge:\n%s", status)
+	}
+}
+
+func TestDirty(t *testing.T) {
+	_, wtPath := setupMerge(t)
+	if dirty, err := Dirty(wtPath); err != nil || dirty {
+		t.Fatalf("Dirty(clean) = %v, %v", dirty, err)
+	}
+	os.WriteFile(filepath.Join(wtPath, "scratch.txt"), []byte("x"), 0o644)
+	if dirty, err := Dirty(wtPath); err != nil || !dirty {
+		t.Errorf("Dirty(untracked file) = %v, %v, want true", dirty, err)
+	}
+}
@@ -23,6 +23,24 @@ func FindRepoRoot(dir string) (string, error) {
 	return strings.TrimSpace(string(out)), nil
 }
 
+// FindGitDir returns the git directory of the repository containing dir, as
+// reported by git rev-parse --git-dir. In a worktree this is the worktree's
+// own directory under the main repository's .git/worktrees, not dir/.git,
+// which is a file there.
+func FindGitDir(dir string) (string, error) {
+	cmd := exec.Command("git", "rev-parse", "--git-dir")
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("not a git repository: %w", err)
+	}
+	gitDir := strings.TrimSpace(string(out))
+	if !filepath.IsAbs(gitDir) {
+		gitDir = filepath.Join(dir, gitDir)
+	}
+	return filepath.Clean(gitDir), nil
+}
+
 // ResolveRepoID returns a normalized repository identifier.
 // Uses the origin remote URL if available, otherwise falls back to _local/<dirname>.
 func ResolveRepoID(repoRoot string) (string, error) {
@@ -160,7 +160,7 @@ func TestResolveGitDir_Worktree(t *testing.T) {
 	defer os.RemoveAll(wtBase)
 	t.Setenv("MOAT_WORKTREE_BASE", wtBase)
 
-	result, err := Resolve(repoDir, "github.com/acme/myrepo", "test-branch", "myapp")
+	result, err := Resolve(repoDir, "github.com/acme/myrepo", "test-branch", "myapp", Provision{})
 	if err != nil {
 		t.Fatalf("Resolve() error = %v", err)
 	}
@@ -197,7 +197,7 @@ func TestResolveGitDir_SubdirInvariant(t *testing.T) {
 	defer os.RemoveAll(wtBase)
 	t.Setenv("MOAT_WORKTREE_BASE", wtBase)
 
-	result, err := Resolve(repoDir, "github.com/acme/myrepo", "subdir-test", "myapp")
+	result, err := Resolve(repoDir, "github.com/acme/myrepo", "subdir-test", "myapp", Provision{})
 	if err != nil {
 		t.Fatalf("Resolve() error = %v", err)
 	}
@@ -221,6 +221,42 @@ func TestResolveGitDir_SubdirInvariant(t *testing.T) {
 	}
 }
 
+func TestFindGitDir(t *testing.T) {
+	repoDir := initTestRepo(t)
+	defer os.RemoveAll(repoDir)
+
+	gitDir, err := FindGitDir(repoDir)
+	if err != nil {
+		t.Fatalf("FindGitDir() error = %v", err)
+	}
+	if want := filepath.Join(repoDir, ".git"); gitDir != want {
+		t.Errorf("FindGitDir() = %q, want %q", gitDir, want)
+	}
+
+	wtBase, err := os.MkdirTemp("", "test-wt-base-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(wtBase)
+	t.Setenv("MOAT_WORKTREE_BASE", wtBase)
+
+	result, err := Resolve(repoDir, "github.com/acme/myrepo", "gitdir-test", "myapp", Provision{})
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	gitDir, err = FindGitDir(result.WorkspacePath)
+	if err != nil {
+		t.Fatalf("FindGitDir() error = %v", err)
+	}
+	if !strings.HasPrefix(gitDir, filepath.Join(repoDir, ".git", "worktrees")) {
+		t.Errorf("FindGitDir() = %q, want the worktree's directory under .git/worktrees", gitDir)
+	}
+
+	if _, err := FindGitDir(t.TempDir()); err == nil {
+		t.Error("FindGitDir() outside a repository = nil error, want error")
+	}
+}
+
 func TestFindRepoRoot(t *testing.T) {
 	tmpDir, err := os.MkdirTemp("", "test-repo-*")
 	if err != nil {
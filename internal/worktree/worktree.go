@@ -6,6 +6,8 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strings"
+
+	"github.com/majorcontext/moat/internal/config"
 )
 
 // Result holds the outcome of a worktree resolution.
@@ -15,6 +17,34 @@ type Result struct {
 	RunName       string // auto-generated run name ({agent}-{branch} or {branch})
 	Reused        bool   // true if worktree already existed
 	RepoID        string // normalized repo identifier
+
+	// Provisioned lists the extra checkout steps run for a new worktree
+	// (e.g. "submodules", "lfs"). Warnings describes steps that failed; the
+	// worktree is still usable without them.
+	Provisioned []string
+	Warnings    []string
+}
+
+// Provision controls the extra checkout steps for a new worktree. Nil
+// fields mean "when the branch needs it".
+type Provision struct {
+	Submodules *bool
+	LFS        *bool
+	Filter     string   // partial-clone filter for submodule clones, e.g. "blob:none"
+	Paths      []string // sparse-checkout directories; empty checks out everything
+}
+
+// ProvisionFor returns the provisioning settings from cfg's workspace block.
+func ProvisionFor(cfg *config.Config) Provision {
+	if cfg == nil {
+		return Provision{}
+	}
+	return Provision{
+		Submodules: cfg.Workspace.Submodules,
+		LFS:        cfg.Workspace.LFS,
+		Filter:     cfg.Workspace.Filter,
+		Paths:      cfg.Workspace.Paths,
+	}
 }
 
 // ValidateBranch checks that a branch name is safe to use in filesystem paths.
@@ -29,8 +59,10 @@ func ValidateBranch(branch string) error {
 }
 
 // Resolve ensures a branch and worktree exist for the given branch name.
-// It creates them if necessary, reuses them if they already exist.
-func Resolve(repoRoot, repoID, branch, agentName string) (*Result, error) {
+// It creates them if necessary, reuses them if they already exist. A new
+// worktree is limited to prov.Paths, if any, and gets its submodules and LFS
+// objects per prov.
+func Resolve(repoRoot, repoID, branch, agentName string, prov Provision) (*Result, error) {
 	if err := ValidateBranch(branch); err != nil {
 		return nil, err
 	}
@@ -65,16 +97,124 @@ func Resolve(repoRoot, repoID, branch, agentName string) (*Result, error) {
 		return nil, fmt.Errorf("creating worktree parent directory: %w", err)
 	}
 
-	// Create worktree
-	cmd := exec.Command("git", "worktree", "add", wtPath, branch)
+	// Decide on LFS before checkout: smudging each LFS file during
+	// `worktree add` is much slower than one batched `git lfs pull`.
+	usesLFS := branchUsesLFS(repoRoot, branch)
+	pullLFS := usesLFS && enabled(prov.LFS, lfsInstalled())
+
+	// Create worktree. A sparse worktree is created empty so that files
+	// outside Paths are never written to disk.
+	var env []string
+	if usesLFS {
+		env = append(os.Environ(), "GIT_LFS_SKIP_SMUDGE=1")
+	}
+	args := []string{"worktree", "add"}
+	if len(prov.Paths) > 0 {
+		args = append(args, "--no-checkout")
+	}
+	cmd := exec.Command("git", append(args, wtPath, branch)...)
 	cmd.Dir = repoRoot
+	cmd.Env = env
 	if out, err := cmd.CombinedOutput(); err != nil {
 		return nil, fmt.Errorf("creating worktree: %w\n%s", err, out)
 	}
+	if len(prov.Paths) > 0 {
+		if err := checkoutSparse(wtPath, branch, prov.Paths, env); err != nil {
+			// Don't leave an empty worktree behind for the next run to reuse.
+			_ = runGit(repoRoot, "worktree", "remove", "--force", wtPath)
+			return nil, fmt.Errorf("creating sparse worktree: %w", err)
+		}
+		result.Provisioned = append(result.Provisioned, "sparse")
+	}
+
+	if _, err := os.Stat(filepath.Join(wtPath, ".gitmodules")); err == nil && enabled(prov.Submodules, true) {
+		args := []string{"submodule", "update", "--init", "--recursive"}
+		if prov.Filter != "" {
+			args = append(args, "--filter="+prov.Filter)
+		}
+		if err := runGit(wtPath, args...); err != nil {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("initializing submodules: %v", err))
+		} else {
+			result.Provisioned = append(result.Provisioned, "submodules")
+		}
+	}
+
+	if pullLFS {
+		lfsArgs := []string{"lfs", "pull"}
+		if len(prov.Paths) > 0 {
+			lfsArgs = append(lfsArgs, "--include="+lfsIncludes(prov.Paths))
+		}
+		if err := runGit(wtPath, lfsArgs...); err != nil {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("fetching LFS objects: %v", err))
+		} else {
+			result.Provisioned = append(result.Provisioned, "lfs")
+		}
+	} else if usesLFS && prov.LFS == nil {
+		result.Warnings = append(result.Warnings, "the branch uses Git LFS but git-lfs is not installed; LFS files are pointer stubs")
+	}
 
 	return result, nil
 }
 
+// checkoutSparse limits a worktree created with --no-checkout to paths and
+// then checks out branch.
+func checkoutSparse(wtPath, branch string, paths, env []string) error {
+	if err := runGit(wtPath, append([]string{"sparse-checkout", "set", "--cone"}, paths...)...); err != nil {
+		return err
+	}
+	cmd := exec.Command("git", "checkout", branch)
+	cmd.Dir = wtPath
+	cmd.Env = env
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("git checkout %s: %w\n%s", branch, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// lfsIncludes returns a `git lfs pull --include` pattern list for paths.
+func lfsIncludes(paths []string) string {
+	patterns := make([]string, len(paths))
+	for i, p := range paths {
+		patterns[i] = strings.TrimSuffix(p, "/") + "/**"
+	}
+	return strings.Join(patterns, ",")
+}
+
+// enabled resolves an optional setting, using def when it is unset.
+func enabled(setting *bool, def bool) bool {
+	if setting == nil {
+		return def
+	}
+	return *setting
+}
+
+// branchUsesLFS reports whether branch's root .gitattributes routes any
+// files through the LFS filter.
+func branchUsesLFS(repoRoot, branch string) bool {
+	cmd := exec.Command("git", "show", "refs/heads/"+branch+":.gitattributes")
+	cmd.Dir = repoRoot
+	out, err := cmd.Output()
+	if err != nil {
+		return false
+	}
+	return strings.Contains(string(out), "filter=lfs")
+}
+
+func lfsInstalled() bool {
+	_, err := exec.LookPath("git-lfs")
+	return err == nil
+}
+
+// runGit runs a git command in dir, folding its output into the error.
+func runGit(dir string, args ...string) error {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("git %s: %w\n%s", strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
 // ensureBranch creates the branch from HEAD if it doesn't already exist.
 func ensureBranch(repoRoot, branch string) error {
 	// Use refs/heads/ prefix to check specifically for a branch, not a tag or other ref.
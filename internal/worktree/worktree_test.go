@@ -51,7 +51,7 @@ func TestResolve_CreatesNewBranchAndWorktree(t *testing.T) {
 	defer os.RemoveAll(wtBase)
 	t.Setenv("MOAT_WORKTREE_BASE", wtBase)
 
-	result, err := Resolve(repoDir, "github.com/acme/myrepo", "new-feature", "myapp")
+	result, err := Resolve(repoDir, "github.com/acme/myrepo", "new-feature", "myapp", Provision{})
 	if err != nil {
 		t.Fatalf("Resolve() error = %v", err)
 	}
@@ -85,12 +85,12 @@ func TestResolve_ReusesExistingWorktree(t *testing.T) {
 	defer os.RemoveAll(wtBase)
 	t.Setenv("MOAT_WORKTREE_BASE", wtBase)
 
-	_, err = Resolve(repoDir, "github.com/acme/myrepo", "existing", "myapp")
+	_, err = Resolve(repoDir, "github.com/acme/myrepo", "existing", "myapp", Provision{})
 	if err != nil {
 		t.Fatalf("first Resolve() error = %v", err)
 	}
 
-	result, err := Resolve(repoDir, "github.com/acme/myrepo", "existing", "myapp")
+	result, err := Resolve(repoDir, "github.com/acme/myrepo", "existing", "myapp", Provision{})
 	if err != nil {
 		t.Fatalf("second Resolve() error = %v", err)
 	}
@@ -100,7 +100,7 @@ func TestResolve_ReusesExistingWorktree(t *testing.T) {
 }
 
 func TestResolve_EmptyBranchName(t *testing.T) {
-	_, err := Resolve("/tmp/repo", "github.com/acme/myrepo", "", "myapp")
+	_, err := Resolve("/tmp/repo", "github.com/acme/myrepo", "", "myapp", Provision{})
 	if err == nil {
 		t.Fatal("expected error for empty branch name")
 	}
@@ -110,7 +110,7 @@ func TestResolve_EmptyBranchName(t *testing.T) {
 }
 
 func TestResolve_PathTraversalBranchName(t *testing.T) {
-	_, err := Resolve("/tmp/repo", "github.com/acme/myrepo", "../../etc/passwd", "myapp")
+	_, err := Resolve("/tmp/repo", "github.com/acme/myrepo", "../../etc/passwd", "myapp", Provision{})
 	if err == nil {
 		t.Fatal("expected error for branch name containing '..'")
 	}
@@ -130,7 +130,7 @@ func TestResolve_NoAgentName(t *testing.T) {
 	defer os.RemoveAll(wtBase)
 	t.Setenv("MOAT_WORKTREE_BASE", wtBase)
 
-	result, err := Resolve(repoDir, "github.com/acme/myrepo", "feat-x", "")
+	result, err := Resolve(repoDir, "github.com/acme/myrepo", "feat-x", "", Provision{})
 	if err != nil {
 		t.Fatalf("Resolve() error = %v", err)
 	}
@@ -138,3 +138,131 @@ func TestResolve_NoAgentName(t *testing.T) {
 		t.Errorf("RunName = %q, want %q", result.RunName, "feat-x")
 	}
 }
+
+// gitIn runs a git command in dir with a fixed identity, failing the test on error.
+func gitIn(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=Test",
+		"GIT_AUTHOR_EMAIL=test@test.com",
+		"GIT_COMMITTER_NAME=Test",
+		"GIT_COMMITTER_EMAIL=test@test.com",
+	)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git %v failed: %v\n%s", args, err, out)
+	}
+}
+
+func TestResolve_InitializesSubmodules(t *testing.T) {
+	// Local submodule URLs need the file protocol, which git disallows by
+	// default for submodules.
+	t.Setenv("GIT_CONFIG_COUNT", "1")
+	t.Setenv("GIT_CONFIG_KEY_0", "protocol.file.allow")
+	t.Setenv("GIT_CONFIG_VALUE_0", "always")
+
+	sub := initTestRepo(t)
+	defer os.RemoveAll(sub)
+	repoDir := initTestRepo(t)
+	defer os.RemoveAll(repoDir)
+	gitIn(t, repoDir, "submodule", "add", sub, "vendor/sub")
+	gitIn(t, repoDir, "commit", "-m", "add submodule")
+
+	t.Setenv("MOAT_WORKTREE_BASE", t.TempDir())
+
+	result, err := Resolve(repoDir, "github.com/acme/myrepo", "with-sub", "", Provision{})
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	if len(result.Warnings) > 0 {
+		t.Fatalf("Warnings = %v", result.Warnings)
+	}
+	if len(result.Provisioned) != 1 || result.Provisioned[0] != "submodules" {
+		t.Errorf("Provisioned = %v, want [submodules]", result.Provisioned)
+	}
+	if _, err := os.Stat(filepath.Join(result.WorkspacePath, "vendor", "sub", "README.md")); err != nil {
+		t.Errorf("submodule not checked out: %v", err)
+	}
+
+	off := false
+	result, err = Resolve(repoDir, "github.com/acme/myrepo", "without-sub", "", Provision{Submodules: &off})
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	if len(result.Provisioned) != 0 {
+		t.Errorf("Provisioned = %v with submodules disabled, want none", result.Provisioned)
+	}
+	if _, err := os.Stat(filepath.Join(result.WorkspacePath, "vendor", "sub", "README.md")); err == nil {
+		t.Error("submodule checked out with submodules disabled")
+	}
+}
+
+func TestResolve_SubmoduleFailureIsWarning(t *testing.T) {
+	t.Setenv("GIT_CONFIG_COUNT", "1")
+	t.Setenv("GIT_CONFIG_KEY_0", "protocol.file.allow")
+	t.Setenv("GIT_CONFIG_VALUE_0", "always")
+
+	sub := initTestRepo(t)
+	repoDir := initTestRepo(t)
+	defer os.RemoveAll(repoDir)
+	gitIn(t, repoDir, "submodule", "add", sub, "vendor/sub")
+	gitIn(t, repoDir, "commit", "-m", "add submodule")
+	// Make the submodule unreachable for the new worktree's clone.
+	os.RemoveAll(sub)
+	os.RemoveAll(filepath.Join(repoDir, ".git", "modules"))
+
+	t.Setenv("MOAT_WORKTREE_BASE", t.TempDir())
+
+	result, err := Resolve(repoDir, "github.com/acme/myrepo", "broken", "", Provision{})
+	if err != nil {
+		t.Fatalf("Resolve() error = %v, want the worktree created with a warning", err)
+	}
+	if _, err := os.Stat(filepath.Join(result.WorkspacePath, "README.md")); err != nil {
+		t.Fatalf("worktree not checked out: %v", err)
+	}
+	if len(result.Warnings) != 1 || len(result.Provisioned) != 0 {
+		t.Errorf("Warnings = %v, Provisioned = %v; want one submodule warning", result.Warnings, result.Provisioned)
+	}
+}
+
+func TestResolve_SparsePaths(t *testing.T) {
+	repoDir := initTestRepo(t)
+	defer os.RemoveAll(repoDir)
+	for _, dir := range []string{"services/api", "services/web", "libs"} {
+		if err := os.MkdirAll(filepath.Join(repoDir, dir), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(repoDir, dir, "main.go"), []byte("package main\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	gitIn(t, repoDir, "add", ".")
+	gitIn(t, repoDir, "commit", "-m", "monorepo layout")
+
+	t.Setenv("MOAT_WORKTREE_BASE", t.TempDir())
+
+	result, err := Resolve(repoDir, "github.com/acme/myrepo", "api-only", "", Provision{Paths: []string{"services/api", "libs"}})
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	for _, want := range []string{"README.md", "services/api/main.go", "libs/main.go"} {
+		if _, err := os.Stat(filepath.Join(result.WorkspacePath, want)); err != nil {
+			t.Errorf("%s missing from sparse worktree: %v", want, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(result.WorkspacePath, "services", "web")); err == nil {
+		t.Error("services/web checked out despite workspace.paths")
+	}
+
+	// The main checkout is not made sparse.
+	if _, err := os.Stat(filepath.Join(repoDir, "services", "web", "main.go")); err != nil {
+		t.Errorf("main checkout lost services/web: %v", err)
+	}
+}
+
+func TestLFSIncludes(t *testing.T) {
+	if got := lfsIncludes([]string{"services/api/", "libs"}); got != "services/api/**,libs/**" {
+		t.Errorf("lfsIncludes = %q", got)
+	}
+}